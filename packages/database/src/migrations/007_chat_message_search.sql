@@ -0,0 +1,28 @@
+-- Chat Message Full-Text Search
+-- Migration: 007_chat_message_search
+
+-- Search vector maintained by trigger so ranking queries can use a GIN index
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION chat_messages_search_vector_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := to_tsvector('english', COALESCE(NEW.content, ''));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_chat_messages_search_vector ON chat_messages;
+CREATE TRIGGER trigger_chat_messages_search_vector
+    BEFORE INSERT OR UPDATE OF content ON chat_messages
+    FOR EACH ROW
+    EXECUTE FUNCTION chat_messages_search_vector_update();
+
+-- Backfill existing messages
+UPDATE chat_messages
+SET search_vector = to_tsvector('english', COALESCE(content, ''))
+WHERE search_vector IS NULL;
+
+-- Replace the expression index with one on the stored vector
+DROP INDEX IF EXISTS idx_chat_messages_search;
+CREATE INDEX IF NOT EXISTS idx_chat_messages_search_vector ON chat_messages USING gin(search_vector);
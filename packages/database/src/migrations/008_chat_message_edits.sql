@@ -0,0 +1,14 @@
+-- Chat Message Edit History
+-- Migration: 008_chat_message_edits
+
+-- Each row holds the content a message had before one edit. Rows are kept
+-- when a message is soft deleted and removed with it on a hard delete.
+CREATE TABLE IF NOT EXISTS chat_message_edits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id UUID NOT NULL REFERENCES chat_messages(id) ON DELETE CASCADE,
+    previous_content TEXT NOT NULL,
+    edited_by UUID NOT NULL REFERENCES users(id),
+    edited_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_message_edits_message ON chat_message_edits(message_id, edited_at);
@@ -0,0 +1,16 @@
+-- Chat Custom Emoji
+-- Migration: 009_chat_custom_emojis
+
+-- Custom emoji registered by an organization. Reactions refer to them as
+-- :name:, so names are unique within an organization.
+CREATE TABLE IF NOT EXISTS chat_custom_emojis (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(32) NOT NULL,
+    image_url TEXT NOT NULL,
+    storage_path TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (organization_id, name)
+);
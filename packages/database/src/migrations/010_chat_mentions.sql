@@ -0,0 +1,31 @@
+-- Chat Mentions and Notification Preferences
+-- Migration: 010_chat_mentions
+
+-- Mentions parsed from message content. User mentions reference the
+-- mentioned user; @channel and @here have no user.
+CREATE TABLE IF NOT EXISTS chat_message_mentions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id UUID NOT NULL REFERENCES chat_messages(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('user', 'channel', 'here')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK ((type = 'user') = (user_id IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_mentions_unique
+    ON chat_message_mentions(message_id, type, COALESCE(user_id, '00000000-0000-0000-0000-000000000000'));
+CREATE INDEX IF NOT EXISTS idx_chat_mentions_user ON chat_message_mentions(user_id, created_at DESC) WHERE user_id IS NOT NULL;
+
+-- Per-channel notification preference of each member: every message,
+-- only mentions (including @channel and @here), or nothing. is_muted is
+-- kept in step for existing readers.
+ALTER TABLE chat_channel_members
+    ADD COLUMN IF NOT EXISTS notification_level VARCHAR(20) NOT NULL DEFAULT 'mentions'
+    CHECK (notification_level IN ('all', 'mentions', 'muted'));
+
+UPDATE chat_channel_members SET notification_level = 'muted' WHERE is_muted = TRUE;
+
+-- Mentioned users are looked up by the local part of their email address
+CREATE INDEX IF NOT EXISTS idx_users_email_local_part ON users (LOWER(split_part(email, '@', 1)));
+
+COMMENT ON TABLE chat_message_mentions IS 'Users and groups mentioned in chat messages';
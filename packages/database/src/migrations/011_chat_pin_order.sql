@@ -0,0 +1,21 @@
+-- Chat Pin Ordering
+-- Migration: 011_chat_pin_order
+
+-- Pinned messages are listed by pin_position, lowest first. New pins are
+-- appended; the channel's pins can be reordered explicitly.
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS pin_position INTEGER;
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS pinned_by UUID REFERENCES users(id);
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMPTZ;
+
+-- Keep the newest-first order existing pins were listed in
+UPDATE chat_messages m
+SET pin_position = p.position, pinned_at = m.updated_at
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY channel_id ORDER BY created_at DESC) AS position
+    FROM chat_messages
+    WHERE is_pinned = TRUE
+) p
+WHERE m.id = p.id AND m.pin_position IS NULL;
+
+DROP INDEX IF EXISTS idx_chat_messages_pinned;
+CREATE INDEX IF NOT EXISTS idx_chat_messages_pinned ON chat_messages(channel_id, pin_position) WHERE is_pinned = TRUE;
@@ -0,0 +1,7 @@
+-- Chat Message HTML Rendering
+-- Migration: 012_chat_message_html
+
+-- Markdown messages keep their source in content and a sanitized HTML
+-- rendering in content_html, refreshed on every edit. Other content types
+-- leave it NULL.
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS content_html TEXT;
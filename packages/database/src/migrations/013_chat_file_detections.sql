@@ -0,0 +1,19 @@
+-- Chat File Detections
+-- Migration: 013_chat_file_detections
+
+-- Uploads are scanned for malware before they are stored when scanning is
+-- enabled. Infected files are rejected and the detection recorded here; the
+-- file itself is not kept.
+CREATE TABLE IF NOT EXISTS chat_file_detections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    file_name VARCHAR(255) NOT NULL,
+    file_size BIGINT NOT NULL,
+    content_type VARCHAR(100),
+    scanner VARCHAR(50) NOT NULL,
+    signature VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_file_detections_org ON chat_file_detections(organization_id, created_at DESC);
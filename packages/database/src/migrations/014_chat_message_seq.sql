@@ -0,0 +1,48 @@
+-- Chat Message Sequence Numbers
+-- Migration: 014_chat_message_seq
+
+-- Every change to a channel's messages (a new message or reply, an edit or a
+-- delete) takes the channel's next sequence number, so a client that knows
+-- the last sequence it received can fetch exactly what it missed. The
+-- channel row is locked while the number is taken, so numbers are assigned
+-- in commit order.
+ALTER TABLE chat_channels ADD COLUMN IF NOT EXISTS last_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS seq BIGINT;
+
+-- Number existing messages in the order they were written
+UPDATE chat_messages m
+SET seq = n.seq
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY channel_id ORDER BY created_at, id) AS seq
+    FROM chat_messages
+) n
+WHERE m.id = n.id AND m.seq IS NULL;
+
+UPDATE chat_channels c
+SET last_seq = COALESCE((SELECT MAX(seq) FROM chat_messages WHERE channel_id = c.id), 0);
+
+ALTER TABLE chat_messages ALTER COLUMN seq SET NOT NULL;
+
+CREATE OR REPLACE FUNCTION chat_messages_next_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- Pins and other metadata changes don't count as a new version
+    IF TG_OP = 'UPDATE'
+        AND NEW.content IS NOT DISTINCT FROM OLD.content
+        AND NEW.is_deleted IS NOT DISTINCT FROM OLD.is_deleted THEN
+        RETURN NEW;
+    END IF;
+
+    UPDATE chat_channels SET last_seq = last_seq + 1
+    WHERE id = NEW.channel_id
+    RETURNING last_seq INTO NEW.seq;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS chat_messages_seq ON chat_messages;
+CREATE TRIGGER chat_messages_seq
+    BEFORE INSERT OR UPDATE ON chat_messages
+    FOR EACH ROW EXECUTE FUNCTION chat_messages_next_seq();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_messages_seq ON chat_messages(channel_id, seq);
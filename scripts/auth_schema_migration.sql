@@ -222,6 +222,10 @@ CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_user_sessions_token_hash ON user_sessions(token_hash);
 CREATE INDEX IF NOT EXISTS idx_user_sessions_expires_at ON user_sessions(expires_at);
 
+-- OIDC session ID (sid) from the login, matched by back-channel logout
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS sso_sid VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_sso_sid ON user_sessions(sso_sid) WHERE sso_sid IS NOT NULL;
+
 -- ============================================================
 -- 11. PASSWORD_RESET_TOKENS table
 -- ============================================================
@@ -250,10 +254,14 @@ CREATE TABLE IF NOT EXISTS domain_sso_configs (
     default_role VARCHAR(50) NOT NULL DEFAULT 'member',
     saml_config JSONB,
     oidc_config JSONB,
+    group_role_mapping JSONB,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- IdP group to role mapping applied on each SSO login
+ALTER TABLE domain_sso_configs ADD COLUMN IF NOT EXISTS group_role_mapping JSONB;
+
 -- ============================================================
 -- 13. SSO_IDENTITIES table
 -- ============================================================
@@ -312,6 +320,23 @@ CREATE INDEX IF NOT EXISTS idx_audit_logs_organization_id ON audit_logs(organiza
 CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+-- Keyset pagination for the admin audit log listing and CSV export
+CREATE INDEX IF NOT EXISTS idx_audit_logs_org_created ON audit_logs(organization_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_org_action_created ON audit_logs(organization_id, action, created_at DESC);
+
+-- ============================================================
+-- 16. PASSWORD_HISTORY table
+-- ============================================================
+-- Hashes of passwords a user replaced, checked against new passwords when
+-- the organization's password policy sets historyCount
+CREATE TABLE IF NOT EXISTS password_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user_created ON password_history(user_id, created_at DESC);
 
 -- ============================================================
 -- UPDATE TRIGGERS for tables with updated_at
@@ -0,0 +1,325 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oonrumail/ai-assistant/provider"
+)
+
+// Analysis output formats
+const (
+	FormatText       = "text"
+	FormatStructured = "structured"
+)
+
+// StructuredAnalysis is schema-validated analysis extracted through the
+// provider's tool calling, or JSON mode where tool calling is unavailable
+type StructuredAnalysis struct {
+	Sentiment   string         `json:"sentiment"` // positive, neutral, negative, mixed
+	ActionItems []ActionItem   `json:"action_items"`
+	Dates       []DetectedDate `json:"dates"`
+	Entities    []Entity       `json:"entities"`
+
+	// Metadata
+	Model     string `json:"model"`
+	Provider  string `json:"provider"`
+	Cached    bool   `json:"cached"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// DetectedDate is a date or time mentioned in the email
+type DetectedDate struct {
+	Text string `json:"text"` // as written in the email
+	Date string `json:"date"` // ISO 8601 date or date-time
+	Type string `json:"type"` // deadline, meeting, event, other
+}
+
+// Entity is a named entity mentioned in the email
+type Entity struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // person, organization, location, product, other
+}
+
+var (
+	sentimentValues      = []string{"positive", "neutral", "negative", "mixed"}
+	actionPriorityValues = []string{"low", "medium", "high"}
+	dateTypeValues       = []string{"deadline", "meeting", "event", "other"}
+	entityTypeValues     = []string{"person", "organization", "location", "product", "other"}
+)
+
+// structuredAnalysisTool is the tool the model is forced to call. Its
+// parameters are the JSON Schema the output is validated against.
+var structuredAnalysisTool = &provider.ToolDefinition{
+	Name:        "record_email_analysis",
+	Description: "Record the sentiment, action items, dates and entities found in an email",
+	Parameters: map[string]interface{}{
+		"type":     "object",
+		"required": []string{"sentiment", "action_items", "dates", "entities"},
+		"properties": map[string]interface{}{
+			"sentiment": map[string]interface{}{
+				"type": "string",
+				"enum": sentimentValues,
+			},
+			"action_items": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"description", "priority"},
+					"properties": map[string]interface{}{
+						"description": map[string]interface{}{"type": "string"},
+						"priority":    map[string]interface{}{"type": "string", "enum": actionPriorityValues},
+						"due_date":    map[string]interface{}{"type": "string", "description": "ISO 8601 date, if any"},
+						"assignee":    map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+			"dates": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"text", "date", "type"},
+					"properties": map[string]interface{}{
+						"text": map[string]interface{}{"type": "string", "description": "The date as written in the email"},
+						"date": map[string]interface{}{"type": "string", "description": "ISO 8601 date or date-time"},
+						"type": map[string]interface{}{"type": "string", "enum": dateTypeValues},
+					},
+				},
+			},
+			"entities": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"name", "type"},
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{"type": "string"},
+						"type": map[string]interface{}{"type": "string", "enum": entityTypeValues},
+					},
+				},
+			},
+		},
+	},
+}
+
+// GetStructuredAnalysis extracts schema-validated analysis from an email. A
+// malformed response is retried once, with the validation error fed back to
+// the model, before giving up.
+func (s *Service) GetStructuredAnalysis(ctx context.Context, req *AnalysisRequest) (*StructuredAnalysis, error) {
+	start := time.Now()
+
+	cacheKey := structuredCacheKey(req.Body)
+
+	if !req.SkipCache {
+		if cached, err := s.getStructuredFromCache(ctx, cacheKey); err == nil {
+			cached.Cached = true
+			cached.LatencyMs = time.Since(start).Milliseconds()
+			return cached, nil
+		}
+	}
+
+	body := req.Body
+	if len(body) > s.maxBodyLen {
+		body = body[:s.maxBodyLen] + "\n...[truncated]"
+	}
+
+	compReq := &provider.CompletionRequest{
+		SystemPrompt: structuredSystemPrompt,
+		Messages: []provider.Message{
+			{Role: provider.RoleUser, Content: s.buildStructuredPrompt(req, body)},
+		},
+		MaxTokens:   2000,
+		Temperature: 0.1,
+		Tool:        structuredAnalysisTool,
+		Metadata: provider.RequestMetadata{
+			OrgID:   req.OrgID,
+			UserID:  req.UserID,
+			EmailID: req.EmailID,
+			Feature: "analysis",
+		},
+	}
+
+	var result *StructuredAnalysis
+	var compResp *provider.CompletionResponse
+	for attempt := 0; attempt < 2; attempt++ {
+		var err error
+		compResp, err = s.router.CompleteWithFallback(ctx, compReq, "analysis")
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze email: %w", err)
+		}
+
+		result, err = parseStructuredAnalysis(compResp.Content)
+		if err == nil {
+			break
+		}
+		if attempt == 1 {
+			return nil, fmt.Errorf("invalid structured analysis from %s: %w", compResp.Provider, err)
+		}
+
+		s.logger.Warn().Err(err).Str("provider", compResp.Provider).Msg("Invalid structured analysis, retrying")
+		compReq.Messages = append(compReq.Messages,
+			provider.Message{Role: provider.RoleAssistant, Content: compResp.Content},
+			provider.Message{Role: provider.RoleUser, Content: fmt.Sprintf(
+				"That response was invalid: %s. Respond again with only a valid JSON object matching the schema.", err)},
+		)
+	}
+
+	result.Model = compResp.Model
+	result.Provider = compResp.Provider
+	result.Cached = false
+	result.LatencyMs = time.Since(start).Milliseconds()
+
+	if err := s.setStructuredInCache(ctx, cacheKey, result); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to cache structured analysis result")
+	}
+
+	return result, nil
+}
+
+// buildStructuredPrompt creates the prompt for structured extraction
+func (s *Service) buildStructuredPrompt(req *AnalysisRequest, body string) string {
+	var sb strings.Builder
+
+	sb.WriteString("Extract structured analysis from the following email.\n\n")
+	sb.WriteString(fmt.Sprintf("From: %s <%s>\n", req.FromName, req.FromAddress))
+	sb.WriteString(fmt.Sprintf("Date: %s\n", req.Date))
+	sb.WriteString(fmt.Sprintf("Subject: %s\n", req.Subject))
+	sb.WriteString(fmt.Sprintf("Recipient: %s <%s>\n", req.UserName, req.UserEmail))
+	sb.WriteString("\nEMAIL BODY:\n")
+	sb.WriteString(body)
+
+	return sb.String()
+}
+
+// parseStructuredAnalysis decodes model output and validates it against the
+// schema. Providers without tool calling may wrap the JSON in prose or code
+// fences, so the outermost object is extracted first.
+func parseStructuredAnalysis(content string) (*StructuredAnalysis, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start == -1 || end <= start {
+		return nil, errors.New("no JSON object found")
+	}
+
+	var result StructuredAnalysis
+	if err := json.Unmarshal([]byte(content[start:end+1]), &result); err != nil {
+		return nil, fmt.Errorf("malformed JSON: %w", err)
+	}
+
+	result.Sentiment = strings.ToLower(result.Sentiment)
+	if !oneOf(result.Sentiment, sentimentValues) {
+		return nil, fmt.Errorf("sentiment %q must be one of %s", result.Sentiment, strings.Join(sentimentValues, ", "))
+	}
+
+	for i := range result.ActionItems {
+		item := &result.ActionItems[i]
+		if strings.TrimSpace(item.Description) == "" {
+			return nil, fmt.Errorf("action_items[%d].description is required", i)
+		}
+		item.Priority = strings.ToLower(item.Priority)
+		if !oneOf(item.Priority, actionPriorityValues) {
+			return nil, fmt.Errorf("action_items[%d].priority %q must be one of %s", i, item.Priority, strings.Join(actionPriorityValues, ", "))
+		}
+		if item.ID == "" {
+			item.ID = fmt.Sprintf("%d", i+1)
+		}
+	}
+
+	for i := range result.Dates {
+		d := &result.Dates[i]
+		if !isISODate(d.Date) {
+			return nil, fmt.Errorf("dates[%d].date %q is not an ISO 8601 date", i, d.Date)
+		}
+		d.Type = strings.ToLower(d.Type)
+		if !oneOf(d.Type, dateTypeValues) {
+			return nil, fmt.Errorf("dates[%d].type %q must be one of %s", i, d.Type, strings.Join(dateTypeValues, ", "))
+		}
+	}
+
+	for i := range result.Entities {
+		e := &result.Entities[i]
+		if strings.TrimSpace(e.Name) == "" {
+			return nil, fmt.Errorf("entities[%d].name is required", i)
+		}
+		e.Type = strings.ToLower(e.Type)
+		if !oneOf(e.Type, entityTypeValues) {
+			return nil, fmt.Errorf("entities[%d].type %q must be one of %s", i, e.Type, strings.Join(entityTypeValues, ", "))
+		}
+	}
+
+	// Always return arrays, never null
+	if result.ActionItems == nil {
+		result.ActionItems = []ActionItem{}
+	}
+	if result.Dates == nil {
+		result.Dates = []DetectedDate{}
+	}
+	if result.Entities == nil {
+		result.Entities = []Entity{}
+	}
+
+	return &result, nil
+}
+
+func oneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func isISODate(value string) bool {
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// structuredCacheKey keys structured results on the body hash and format
+func structuredCacheKey(body string) string {
+	hash := sha256.Sum256([]byte(body))
+	return "analysis:" + FormatStructured + ":" + hex.EncodeToString(hash[:])
+}
+
+// getStructuredFromCache retrieves a cached structured analysis
+func (s *Service) getStructuredFromCache(ctx context.Context, key string) (*StructuredAnalysis, error) {
+	data, err := s.cache.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var result StructuredAnalysis
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// setStructuredInCache stores a structured analysis in cache
+func (s *Service) setStructuredInCache(ctx context.Context, key string, result *StructuredAnalysis) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return s.cache.Set(ctx, key, data, s.cacheTTL).Err()
+}
+
+const structuredSystemPrompt = `You are an email analysis assistant that extracts structured data from emails.
+
+Guidelines:
+1. Sentiment reflects the overall tone of the sender
+2. Action items are concrete tasks the recipient is asked or expected to do
+3. Dates include deadlines, meetings and events; resolve relative dates ("next Friday") against the email date
+4. Entities are people, organizations, locations and products that are named in the email
+5. Use empty arrays when nothing is found; never invent information`
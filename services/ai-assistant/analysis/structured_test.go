@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStructuredAnalysis(t *testing.T) {
+	content := "```json\n" + `{
+		"sentiment": "Positive",
+		"action_items": [{"description": "Send the report", "priority": "HIGH", "due_date": "2026-03-01"}],
+		"dates": [{"text": "next Friday", "date": "2026-03-06", "type": "meeting"}],
+		"entities": [{"name": "Acme Corp", "type": "organization"}]
+	}` + "\n```"
+
+	result, err := parseStructuredAnalysis(content)
+	if err != nil {
+		t.Fatalf("parseStructuredAnalysis() error = %v", err)
+	}
+
+	if result.Sentiment != "positive" {
+		t.Errorf("Sentiment = %q, want positive", result.Sentiment)
+	}
+	if len(result.ActionItems) != 1 || result.ActionItems[0].Priority != "high" || result.ActionItems[0].ID != "1" {
+		t.Errorf("ActionItems = %+v", result.ActionItems)
+	}
+	if len(result.Dates) != 1 || result.Dates[0].Date != "2026-03-06" {
+		t.Errorf("Dates = %+v", result.Dates)
+	}
+	if len(result.Entities) != 1 || result.Entities[0].Type != "organization" {
+		t.Errorf("Entities = %+v", result.Entities)
+	}
+}
+
+func TestParseStructuredAnalysis_EmptyArrays(t *testing.T) {
+	result, err := parseStructuredAnalysis(`{"sentiment": "neutral"}`)
+	if err != nil {
+		t.Fatalf("parseStructuredAnalysis() error = %v", err)
+	}
+	if result.ActionItems == nil || result.Dates == nil || result.Entities == nil {
+		t.Errorf("Expected empty arrays, got %+v", result)
+	}
+}
+
+func TestParseStructuredAnalysis_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"not JSON", "I could not analyze this email", "no JSON object"},
+		{"truncated JSON", `{"sentiment": "positive", "entities": [`, "no JSON object"},
+		{"malformed JSON", `{"sentiment": positive}`, "malformed JSON"},
+		{"unknown sentiment", `{"sentiment": "happy"}`, "sentiment"},
+		{"missing description", `{"sentiment": "neutral", "action_items": [{"priority": "low"}]}`, "description"},
+		{"bad priority", `{"sentiment": "neutral", "action_items": [{"description": "x", "priority": "urgent"}]}`, "priority"},
+		{"bad date", `{"sentiment": "neutral", "dates": [{"text": "soon", "date": "soon", "type": "deadline"}]}`, "ISO 8601"},
+		{"bad entity type", `{"sentiment": "neutral", "entities": [{"name": "Bob", "type": "animal"}]}`, "type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseStructuredAnalysis(tt.content)
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStructuredCacheKey(t *testing.T) {
+	if structuredCacheKey("body") != structuredCacheKey("body") {
+		t.Error("Cache key should be deterministic")
+	}
+	if structuredCacheKey("body") == structuredCacheKey("other body") {
+		t.Error("Different bodies should have different cache keys")
+	}
+	if !strings.HasPrefix(structuredCacheKey("body"), "analysis:structured:") {
+		t.Errorf("Cache key = %q", structuredCacheKey("body"))
+	}
+}
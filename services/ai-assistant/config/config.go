@@ -33,6 +33,9 @@ type Config struct {
 
 	// Embedding settings
 	Embedding EmbeddingConfig
+
+	// Summarization settings
+	Summarization SummarizationConfig
 }
 
 // DatabaseConfig holds database connection settings
@@ -126,6 +129,9 @@ type CacheConfig struct {
 	// Smart reply cache TTL
 	SmartReplyTTL time.Duration
 
+	// Draft (help me write) cache TTL
+	DraftTTL time.Duration
+
 	// Max cache entries per type
 	MaxAnalysisEntries  int
 	MaxEmbeddingEntries int
@@ -184,6 +190,13 @@ type EmbeddingConfig struct {
 	MaxConcurrent int
 }
 
+// SummarizationConfig holds summarization settings
+type SummarizationConfig struct {
+	// Token budget for one chunk of a thread; longer threads are summarized
+	// chunk by chunk and the partial summaries combined
+	ThreadChunkTokens int
+}
+
 // Load creates a Config from environment variables
 func Load() (*Config, error) {
 	return &Config{
@@ -251,6 +264,7 @@ func Load() (*Config, error) {
 			AnalysisTTL:         getDuration("CACHE_ANALYSIS_TTL", 24*time.Hour),
 			EmbeddingTTL:        getDuration("CACHE_EMBEDDING_TTL", 7*24*time.Hour),
 			SmartReplyTTL:       getDuration("CACHE_SMART_REPLY_TTL", 1*time.Hour),
+			DraftTTL:            getDuration("CACHE_DRAFT_TTL", 1*time.Hour),
 			MaxAnalysisEntries:  getInt("CACHE_MAX_ANALYSIS_ENTRIES", 100000),
 			MaxEmbeddingEntries: getInt("CACHE_MAX_EMBEDDING_ENTRIES", 500000),
 		},
@@ -282,6 +296,11 @@ func Load() (*Config, error) {
 			BatchSize:     getInt("EMBEDDING_BATCH_SIZE", 100),
 			MaxConcurrent: getInt("EMBEDDING_MAX_CONCURRENT", 20),
 		},
+
+		// Summarization
+		Summarization: SummarizationConfig{
+			ThreadChunkTokens: getInt("SUMMARY_THREAD_CHUNK_TOKENS", 6000),
+		},
 	}, nil
 }
 
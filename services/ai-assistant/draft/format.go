@@ -0,0 +1,137 @@
+package draft
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// DraftFormat is the markup a generated draft body is written in
+type DraftFormat string
+
+const (
+	FormatPlain    DraftFormat = "plain"    // No markup at all (default)
+	FormatMarkdown DraftFormat = "markdown" // Markdown, for composers that render it
+	FormatHTML     DraftFormat = "html"     // An HTML fragment for rich-text composers
+)
+
+// DraftLength is how long a generated draft should be
+type DraftLength string
+
+const (
+	LengthShort  DraftLength = "short"
+	LengthMedium DraftLength = "medium" // Default
+	LengthLong   DraftLength = "long"
+)
+
+// Validate checks the format, tone and length options, filling in defaults
+func (req *HelpMeWriteRequest) Validate() error {
+	if req.Tone == "" {
+		req.Tone = req.TonePreference
+	}
+
+	switch req.Format {
+	case "":
+		req.Format = FormatPlain
+	case FormatPlain, FormatMarkdown, FormatHTML:
+	default:
+		return fmt.Errorf("invalid format %q: must be plain, markdown or html", req.Format)
+	}
+
+	switch req.Length {
+	case "":
+		req.Length = LengthMedium
+	case LengthShort, LengthMedium, LengthLong:
+	default:
+		return fmt.Errorf("invalid length %q: must be short, medium or long", req.Length)
+	}
+
+	if req.Tone != "" {
+		if _, ok := writeToneInstructions[req.Tone]; !ok {
+			return fmt.Errorf("invalid tone %q", req.Tone)
+		}
+	}
+
+	return nil
+}
+
+// formatInstructions tell the model which markup to write the body in
+var formatInstructions = map[DraftFormat]string{
+	FormatPlain: "Format: Plain text only. Do not use Markdown, HTML or any other markup " +
+		"(no asterisks, pound signs, backticks or tags). Separate paragraphs with a blank line.",
+	FormatMarkdown: "Format: Markdown. Use it sparingly: paragraphs, bullet lists and emphasis where they help.",
+	FormatHTML: "Format: An HTML fragment using only <p>, <br>, <ul>, <ol>, <li>, <strong>, <em> and <a>. " +
+		"Do not include <html>, <head> or <body>, and do not wrap it in a code block.",
+}
+
+var (
+	codeFenceRe   = regexp.MustCompile("(?m)^\\s*```[\\w-]*\\s*$\\n?")
+	htmlBreakRe   = regexp.MustCompile(`(?i)<br\s*/?>|</tr>`)
+	htmlBlockRe   = regexp.MustCompile(`(?i)</(p|div|h[1-6]|blockquote|ul|ol)>`)
+	htmlItemRe    = regexp.MustCompile(`(?i)<li[^>]*>`)
+	htmlTagRe     = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	mdImageRe     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdHeadingRe   = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	mdQuoteRe     = regexp.MustCompile(`(?m)^\s{0,3}>\s?`)
+	mdRuleRe      = regexp.MustCompile(`(?m)^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	mdBulletRe    = regexp.MustCompile(`(?m)^(\s*)[*+]\s+`)
+	mdBoldRe      = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	mdItalicRe    = regexp.MustCompile(`(^|[^\w*])\*(\S(?:[^*]*?\S)?)\*`)
+	mdUnderItalic = regexp.MustCompile(`(^|[^\w_])_(\S(?:[^_]*?\S)?)_([^\w_]|$)`)
+	mdCodeRe      = regexp.MustCompile("`([^`]+)`")
+	blankLinesRe  = regexp.MustCompile(`\n{3,}`)
+)
+
+// stripCodeFence removes the code fence a model sometimes wraps its whole
+// answer in
+func stripCodeFence(text string) string {
+	return strings.TrimSpace(codeFenceRe.ReplaceAllString(text, ""))
+}
+
+// stripMarkup turns HTML or Markdown into plain text, keeping paragraphs,
+// list items and link targets readable
+func stripMarkup(text string) string {
+	text = stripCodeFence(text)
+
+	// HTML
+	text = htmlBreakRe.ReplaceAllString(text, "\n")
+	text = htmlBlockRe.ReplaceAllString(text, "\n\n")
+	text = htmlItemRe.ReplaceAllString(text, "\n- ")
+	text = htmlTagRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	// Markdown
+	text = mdImageRe.ReplaceAllString(text, "$1")
+	text = mdLinkRe.ReplaceAllStringFunc(text, func(m string) string {
+		parts := mdLinkRe.FindStringSubmatch(m)
+		if parts[1] == parts[2] || "mailto:"+parts[1] == parts[2] {
+			return parts[1]
+		}
+		return parts[1] + " (" + parts[2] + ")"
+	})
+	text = mdRuleRe.ReplaceAllString(text, "")
+	text = mdHeadingRe.ReplaceAllString(text, "")
+	text = mdQuoteRe.ReplaceAllString(text, "")
+	text = mdBulletRe.ReplaceAllString(text, "$1- ")
+	text = mdBoldRe.ReplaceAllString(text, "$2")
+	text = mdItalicRe.ReplaceAllString(text, "$1$2")
+	text = mdUnderItalic.ReplaceAllString(text, "$1$2$3")
+	text = mdCodeRe.ReplaceAllString(text, "$1")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = strings.Join(lines, "\n")
+	text = blankLinesRe.ReplaceAllString(text, "\n\n")
+
+	return strings.TrimSpace(text)
+}
+
+// htmlParagraph renders plain text appended to an HTML draft, such as a
+// greeting or signature, as a paragraph
+func htmlParagraph(text string) string {
+	return "<p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>") + "</p>"
+}
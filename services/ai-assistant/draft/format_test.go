@@ -0,0 +1,73 @@
+package draft
+
+import "testing"
+
+func TestStripMarkup(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text unchanged", "Hi Sam,\n\nThe report is attached.", "Hi Sam,\n\nThe report is attached."},
+		{"code fence", "```markdown\nThanks for the update.\n```", "Thanks for the update."},
+		{"markdown emphasis", "This is **important** and *urgent*, see `config_v2`.", "This is important and urgent, see config_v2."},
+		{"snake_case kept", "Set max_retry_count to 3.", "Set max_retry_count to 3."},
+		{"headings and bullets", "## Agenda\n\n* Budget\n+ Hiring", "Agenda\n\n- Budget\n- Hiring"},
+		{"links", "See [the doc](https://example.com/doc) or [a@b.com](mailto:a@b.com).", "See the doc (https://example.com/doc) or a@b.com."},
+		{"html", "<p>Hello &amp; welcome.</p><ul><li>One</li><li>Two</li></ul><p>Bye<br/>Ann</p>", "Hello & welcome.\n\n- One\n- Two\n\nBye\nAnn"},
+		{"blank lines collapsed", "One\n\n\n\nTwo", "One\n\nTwo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripMarkup(tt.input); got != tt.want {
+				t.Errorf("stripMarkup(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelpMeWriteRequestValidate(t *testing.T) {
+	req := &HelpMeWriteRequest{TonePreference: ToneCasual}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if req.Format != FormatPlain || req.Length != LengthMedium || req.Tone != ToneCasual {
+		t.Errorf("defaults = %q/%q/%q, want plain/medium/casual", req.Format, req.Length, req.Tone)
+	}
+
+	for _, bad := range []*HelpMeWriteRequest{
+		{Format: "rtf"},
+		{Length: "epic"},
+		{Tone: "sarcastic"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestDraftCacheKey(t *testing.T) {
+	s := &Service{}
+	base := HelpMeWriteRequest{Prompt: "Decline the meeting", Tone: ToneFormal, Length: LengthShort, Format: FormatPlain}
+
+	other := base
+	other.Format = FormatHTML
+	if s.draftCacheKey(&base) == s.draftCacheKey(&other) {
+		t.Error("format should change the cache key")
+	}
+	other = base
+	other.Tone = ToneCasual
+	if s.draftCacheKey(&base) == s.draftCacheKey(&other) {
+		t.Error("tone should change the cache key")
+	}
+	other = base
+	other.Length = LengthLong
+	if s.draftCacheKey(&base) == s.draftCacheKey(&other) {
+		t.Error("length should change the cache key")
+	}
+	same := base
+	if s.draftCacheKey(&base) != s.draftCacheKey(&same) {
+		t.Error("identical requests should share a cache key")
+	}
+}
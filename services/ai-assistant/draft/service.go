@@ -2,6 +2,8 @@ package draft
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -29,22 +31,25 @@ const (
 
 // Service handles draft assistance
 type Service struct {
-	router *provider.Router
-	cache  *redis.Client
-	logger zerolog.Logger
+	router   *provider.Router
+	cache    *redis.Client
+	cacheTTL time.Duration
+	logger   zerolog.Logger
 }
 
 // ServiceConfig contains draft service configuration
 type ServiceConfig struct {
 	MaxSuggestionLength int
+	CacheTTL            time.Duration
 }
 
 // NewService creates a new draft assistant service
 func NewService(router *provider.Router, cache *redis.Client, cfg ServiceConfig, logger zerolog.Logger) *Service {
 	return &Service{
-		router: router,
-		cache:  cache,
-		logger: logger.With().Str("component", "draft").Logger(),
+		router:   router,
+		cache:    cache,
+		cacheTTL: cfg.CacheTTL,
+		logger:   logger.With().Str("component", "draft").Logger(),
 	}
 }
 
@@ -194,10 +199,13 @@ type HelpMeWriteRequest struct {
 	CustomInstructions string `json:"custom_instructions,omitempty"`
 
 	// Options
-	TonePreference ToneAdjustment `json:"tone_preference,omitempty"`
-	Length         string `json:"length,omitempty"` // short/medium/long
+	Tone           ToneAdjustment `json:"tone,omitempty"`
+	TonePreference ToneAdjustment `json:"tone_preference,omitempty"` // Older name for tone
+	Length         DraftLength `json:"length,omitempty"` // short/medium/long
+	Format         DraftFormat `json:"format,omitempty"` // plain/markdown/html
 	IncludeGreeting bool `json:"include_greeting"`
 	IncludeClosing  bool `json:"include_closing"`
+	SkipCache       bool `json:"skip_cache"`
 }
 
 // RecipientInfo contains recipient details
@@ -214,44 +222,63 @@ type HelpMeWriteResponse struct {
 	Preview   string `json:"preview"`           // First 100 chars
 	WordCount int    `json:"word_count"`
 	Tone      string `json:"tone"`              // Detected tone
+	Length    string `json:"length"`
+	Format    string `json:"format"`
 	Model     string `json:"model"`
 	Provider  string `json:"provider"`
+	Cached    bool   `json:"cached"`
 	LatencyMs int64  `json:"latency_ms"`
 }
 
-// HelpMeWrite generates a full email draft
+// writeToneInstructions describe each tone to write a draft in
+var writeToneInstructions = map[ToneAdjustment]string{
+	ToneFormal:    "Tone: Formal and professional. Use proper salutations and closings.",
+	ToneCasual:    "Tone: Casual and friendly. Keep it conversational.",
+	ToneShorter:   "Tone: Very concise. Get to the point quickly.",
+	ToneLonger:    "Tone: Thorough, explaining the reasoning and details.",
+	TonePolite:    "Tone: Polite and courteous, with please and thank you where appropriate.",
+	ToneDirect:    "Tone: Direct and to the point, without hedging.",
+	ToneFriendly:  "Tone: Warm and friendly while remaining professional.",
+	ToneAssertive: "Tone: Confident and assertive without being aggressive.",
+}
+
+// lengthInstructions describe each draft length
+var lengthInstructions = map[DraftLength]string{
+	LengthShort:  "Length: Keep it brief, 2-3 sentences max.",
+	LengthMedium: "Length: Moderate, covering key points clearly.",
+	LengthLong:   "Length: Detailed and comprehensive.",
+}
+
+// HelpMeWrite generates a full email draft in the requested format, tone
+// and length. Plain drafts have any markup the model added stripped. Drafts
+// are cached on everything in the request that shapes them.
 func (s *Service) HelpMeWrite(ctx context.Context, req *HelpMeWriteRequest) (*HelpMeWriteResponse, error) {
 	start := time.Now()
 
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	cacheKey := s.draftCacheKey(req)
+	if !req.SkipCache && s.cache != nil {
+		if cached, err := s.getCachedDraft(ctx, cacheKey); err == nil && cached != nil {
+			cached.Cached = true
+			cached.LatencyMs = time.Since(start).Milliseconds()
+			return cached, nil
+		}
+	}
+
 	// Build system prompt
 	var systemBuilder strings.Builder
 	systemBuilder.WriteString("You are an expert email writer. Help compose professional, clear emails.\n\n")
 
-	// Tone instructions
-	switch req.TonePreference {
-	case ToneFormal:
-		systemBuilder.WriteString("Tone: Formal and professional. Use proper salutations and closings.\n")
-	case ToneCasual:
-		systemBuilder.WriteString("Tone: Casual and friendly. Keep it conversational.\n")
-	case ToneShorter:
-		systemBuilder.WriteString("Tone: Very concise. Get to the point quickly.\n")
-	case ToneFriendly:
-		systemBuilder.WriteString("Tone: Warm and friendly while remaining professional.\n")
-	case ToneAssertive:
-		systemBuilder.WriteString("Tone: Confident and assertive without being aggressive.\n")
-	default:
+	if instruction, ok := writeToneInstructions[req.Tone]; ok {
+		systemBuilder.WriteString(instruction + "\n")
+	} else {
 		systemBuilder.WriteString("Tone: Professional but approachable.\n")
 	}
-
-	// Length instructions
-	switch req.Length {
-	case "short":
-		systemBuilder.WriteString("Length: Keep it brief, 2-3 sentences max.\n")
-	case "long":
-		systemBuilder.WriteString("Length: Detailed and comprehensive.\n")
-	default:
-		systemBuilder.WriteString("Length: Moderate, covering key points clearly.\n")
-	}
+	systemBuilder.WriteString(lengthInstructions[req.Length] + "\n")
+	systemBuilder.WriteString(formatInstructions[req.Format] + "\n")
 
 	if req.CustomInstructions != "" {
 		systemBuilder.WriteString(fmt.Sprintf("\nUser's style preferences: %s\n", req.CustomInstructions))
@@ -300,46 +327,129 @@ func (s *Service) HelpMeWrite(ctx context.Context, req *HelpMeWriteRequest) (*He
 		return nil, fmt.Errorf("failed to generate draft: %w", err)
 	}
 
-	body := strings.TrimSpace(result.Content)
+	body := stripCodeFence(result.Content)
+	if req.Format == FormatPlain {
+		body = stripMarkup(body)
+	}
+	text := body
+	if req.Format == FormatHTML {
+		text = stripMarkup(body)
+	}
+
+	// Greeting, closing and signature are added as paragraphs of the body's format
+	appendText := func(body, text string, prepend bool) string {
+		if req.Format == FormatHTML {
+			text = htmlParagraph(text)
+		}
+		if prepend {
+			return text + "\n\n" + body
+		}
+		return body + "\n\n" + text
+	}
 
 	// Add greeting if requested and not present
-	if req.IncludeGreeting && !startsWithGreeting(body) {
+	if req.IncludeGreeting && !startsWithGreeting(text) {
 		greeting := "Hi"
 		if len(req.Recipients) > 0 && req.Recipients[0].Name != "" {
 			firstName := strings.Split(req.Recipients[0].Name, " ")[0]
 			greeting = fmt.Sprintf("Hi %s", firstName)
 		}
-		if req.TonePreference == ToneFormal {
+		if req.Tone == ToneFormal {
 			greeting = "Dear " + strings.TrimPrefix(greeting, "Hi ")
 		}
-		body = greeting + ",\n\n" + body
+		body = appendText(body, greeting+",", true)
 	}
 
 	// Add closing if requested and not present
-	if req.IncludeClosing && !endsWithClosing(body) {
+	if req.IncludeClosing && !endsWithClosing(text) {
 		closing := "Best regards"
-		if req.TonePreference == ToneCasual {
+		if req.Tone == ToneCasual {
 			closing = "Thanks"
-		} else if req.TonePreference == ToneFormal {
+		} else if req.Tone == ToneFormal {
 			closing = "Sincerely"
 		}
-		body = body + "\n\n" + closing + ",\n" + req.UserName
+		body = appendText(body, closing+",\n"+req.UserName, false)
 	}
 
 	// Append signature
 	if req.UserSignature != "" {
-		body = body + "\n\n" + req.UserSignature
+		body = appendText(body, req.UserSignature, false)
 	}
 
-	return &HelpMeWriteResponse{
+	if req.Format == FormatHTML {
+		text = stripMarkup(body)
+	} else {
+		text = body
+	}
+
+	response := &HelpMeWriteResponse{
 		Body:      body,
-		Preview:   truncateText(body, 100),
-		WordCount: len(strings.Fields(body)),
-		Tone:      string(req.TonePreference),
+		Preview:   truncateText(text, 100),
+		WordCount: len(strings.Fields(text)),
+		Tone:      string(req.Tone),
+		Length:    string(req.Length),
+		Format:    string(req.Format),
 		Model:     result.Model,
 		Provider:  result.Provider,
 		LatencyMs: time.Since(start).Milliseconds(),
-	}, nil
+	}
+
+	if s.cache != nil && !req.SkipCache {
+		go s.cacheDraft(context.Background(), cacheKey, response)
+	}
+
+	return response, nil
+}
+
+// draftCacheKey creates a cache key from every request field that shapes
+// the draft, including format, tone and length
+func (s *Service) draftCacheKey(req *HelpMeWriteRequest) string {
+	hasher := sha256.New()
+	for _, part := range []string{
+		req.OrgID, req.UserID, req.Prompt, req.CurrentText, req.Subject,
+		req.UserName, req.UserEmail, req.UserSignature, req.CustomInstructions,
+		string(req.Tone), string(req.Length), string(req.Format),
+		fmt.Sprintf("%v:%v", req.IncludeGreeting, req.IncludeClosing),
+	} {
+		hasher.Write([]byte(part))
+		hasher.Write([]byte{0})
+	}
+	for _, r := range req.Recipients {
+		hasher.Write([]byte(r.Name + "\x00" + r.Email + "\x00" + r.Type + "\x00"))
+	}
+	if req.InReplyTo != nil {
+		hasher.Write([]byte(req.InReplyTo.FromName + "\x00" + req.InReplyTo.FromAddress + "\x00" +
+			req.InReplyTo.Subject + "\x00" + req.InReplyTo.Body))
+	}
+	return "draft:help:" + hex.EncodeToString(hasher.Sum(nil))[:32]
+}
+
+// getCachedDraft retrieves a cached draft
+func (s *Service) getCachedDraft(ctx context.Context, key string) (*HelpMeWriteResponse, error) {
+	data, err := s.cache.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp HelpMeWriteResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// cacheDraft stores a draft in cache
+func (s *Service) cacheDraft(ctx context.Context, key string, resp *HelpMeWriteResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to marshal draft for caching")
+		return
+	}
+
+	if err := s.cache.Set(ctx, key, data, s.cacheTTL).Err(); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to cache draft")
+	}
 }
 
 // ============================================================
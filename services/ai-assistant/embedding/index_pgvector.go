@@ -0,0 +1,104 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PGVectorIndex is a VectorIndex in PostgreSQL using the pgvector extension
+// (migration 002_embedding_index.sql)
+type PGVectorIndex struct {
+	db *pgxpool.Pool
+}
+
+// NewPGVectorIndex returns a vector index in db, or an error when the
+// pgvector table is not there because the extension isn't available
+func NewPGVectorIndex(ctx context.Context, db *pgxpool.Pool) (*PGVectorIndex, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass('ai_embedding_items') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("ai_embedding_items table not found; pgvector is not installed")
+	}
+	return &PGVectorIndex{db: db}, nil
+}
+
+// Name identifies the index backend
+func (i *PGVectorIndex) Name() string {
+	return "pgvector"
+}
+
+// Upsert stores vectors, replacing any stored under the same item ID
+func (i *PGVectorIndex) Upsert(ctx context.Context, orgID, userID string, vectors []IndexedVector) error {
+	query := `
+		INSERT INTO ai_embedding_items (org_id, user_id, item_id, embedding, model, content_hash, metadata)
+		VALUES ($1, $2, $3, $4::vector, $5, $6, $7)
+		ON CONFLICT (org_id, user_id, item_id) DO UPDATE SET
+			embedding = EXCLUDED.embedding,
+			model = EXCLUDED.model,
+			content_hash = EXCLUDED.content_hash,
+			metadata = EXCLUDED.metadata,
+			indexed_at = NOW()`
+
+	batch := &pgx.Batch{}
+	for _, v := range vectors {
+		metadata, err := json.Marshal(v.Metadata)
+		if err != nil {
+			return err
+		}
+		batch.Queue(query, orgID, userID, v.ID, vectorLiteral(v.Embedding), v.Model, v.ContentHash, metadata)
+	}
+
+	return i.db.SendBatch(ctx, batch).Close()
+}
+
+// Search returns the topK stored vectors from model most similar to query
+func (i *PGVectorIndex) Search(ctx context.Context, orgID, userID, model string, query []float64, topK int) ([]SearchResult, error) {
+	rows, err := i.db.Query(ctx, `
+		SELECT item_id, 1 - (embedding <=> $1::vector) AS score, metadata
+		FROM ai_embedding_items
+		WHERE org_id = $2 AND user_id = $3 AND model = $4 AND vector_dims(embedding) = $5
+		ORDER BY embedding <=> $1::vector
+		LIMIT $6`,
+		vectorLiteral(query), orgID, userID, model, len(query), topK)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		var metadata []byte
+		if err := rows.Scan(&r.ID, &r.Score, &metadata); err != nil {
+			return nil, err
+		}
+		if len(metadata) > 0 {
+			json.Unmarshal(metadata, &r.Metadata)
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// vectorLiteral formats v in pgvector's text representation
+func vectorLiteral(v []float64) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(f, 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
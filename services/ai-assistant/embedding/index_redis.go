@@ -0,0 +1,101 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redisIndexScanCount is the number of vectors fetched per HSCAN call
+const redisIndexScanCount = 500
+
+// RedisIndex is a VectorIndex for deployments without pgvector. Each user's
+// vectors are kept in one hash and searched by scanning it, so it suits
+// indexes of a few thousand items per user.
+type RedisIndex struct {
+	client *redis.Client
+}
+
+// NewRedisIndex creates a vector index stored in Redis
+func NewRedisIndex(client *redis.Client) *RedisIndex {
+	return &RedisIndex{client: client}
+}
+
+// Name identifies the index backend
+func (i *RedisIndex) Name() string {
+	return "redis"
+}
+
+// key returns the hash holding a user's vectors. The org and user IDs are
+// escaped so no pair of them can name another pair's hash.
+func (i *RedisIndex) key(orgID, userID string) string {
+	return fmt.Sprintf("embedding_index:%s:%s", url.QueryEscape(orgID), url.QueryEscape(userID))
+}
+
+// Upsert stores vectors, replacing any stored under the same item ID
+func (i *RedisIndex) Upsert(ctx context.Context, orgID, userID string, vectors []IndexedVector) error {
+	values := make([]interface{}, 0, len(vectors)*2)
+	for _, v := range vectors {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		values = append(values, v.ID, data)
+	}
+	return i.client.HSet(ctx, i.key(orgID, userID), values...).Err()
+}
+
+// Search returns the topK stored vectors from model most similar to query
+func (i *RedisIndex) Search(ctx context.Context, orgID, userID, model string, query []float64, topK int) ([]SearchResult, error) {
+	key := i.key(orgID, userID)
+	results := []SearchResult{}
+
+	var cursor uint64
+	for {
+		fields, next, err := i.client.HScan(ctx, key, cursor, "*", redisIndexScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// fields alternates item IDs and their vectors
+		for j := 1; j < len(fields); j += 2 {
+			var v IndexedVector
+			if err := json.Unmarshal([]byte(fields[j]), &v); err != nil {
+				continue
+			}
+			if v.Model != model || len(v.Embedding) != len(query) {
+				continue
+			}
+			results = append(results, SearchResult{
+				ID:       v.ID,
+				Score:    cosineSimilarity(query, v.Embedding),
+				Metadata: v.Metadata,
+			})
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return topResults(uniqueResults(results), topK), nil
+}
+
+// uniqueResults drops repeated item IDs, which HSCAN can return when the
+// hash changes during a scan
+func uniqueResults(results []SearchResult) []SearchResult {
+	seen := make(map[string]bool, len(results))
+	unique := results[:0]
+	for _, r := range results {
+		if seen[r.ID] {
+			continue
+		}
+		seen[r.ID] = true
+		unique = append(unique, r)
+	}
+	return unique
+}
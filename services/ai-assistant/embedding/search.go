@@ -0,0 +1,237 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	defaultSearchTopK = 10
+	maxSearchTopK     = 100
+)
+
+// ErrIndexUnavailable is returned by Index and Search when no vector index
+// is configured
+var ErrIndexUnavailable = errors.New("vector index unavailable")
+
+// VectorIndex stores embeddings for similarity search. Every operation is
+// scoped to one organization and user; vectors are never read across them.
+type VectorIndex interface {
+	// Name identifies the index backend
+	Name() string
+
+	// Upsert stores vectors, replacing any stored under the same item ID
+	Upsert(ctx context.Context, orgID, userID string, vectors []IndexedVector) error
+
+	// Search returns the topK stored vectors from model most similar to
+	// query by cosine similarity, most similar first
+	Search(ctx context.Context, orgID, userID, model string, query []float64, topK int) ([]SearchResult, error)
+}
+
+// IndexedVector is an embedding stored in a VectorIndex
+type IndexedVector struct {
+	ID          string            `json:"id"`
+	Embedding   []float64         `json:"embedding"`
+	Model       string            `json:"model"`
+	ContentHash string            `json:"content_hash"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// IndexItem is an item to embed and store for search
+type IndexItem struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// IndexRequest represents a request to index items
+type IndexRequest struct {
+	Items  []IndexItem `json:"items"`
+	OrgID  string      `json:"org_id"`
+	UserID string      `json:"user_id"`
+}
+
+// IndexResponse represents the result of indexing items
+type IndexResponse struct {
+	Indexed    int              `json:"indexed"`
+	Duplicates int              `json:"duplicates"` // Items dropped for repeating an earlier item's ID
+	Errors     []EmbeddingError `json:"errors,omitempty"`
+	Backend    string           `json:"backend"`
+	LatencyMs  int64            `json:"latency_ms"`
+}
+
+// SearchRequest represents a similarity search over indexed items
+type SearchRequest struct {
+	Query    string  `json:"query"`
+	TopK     int     `json:"top_k"`
+	MinScore float64 `json:"min_score"`
+	OrgID    string  `json:"org_id"`
+	UserID   string  `json:"user_id"`
+}
+
+// SearchResult is an indexed item matching a search
+type SearchResult struct {
+	ID       string            `json:"id"`
+	Score    float64           `json:"score"` // Cosine similarity, 1 for identical direction
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// SearchResponse represents the results of a similarity search
+type SearchResponse struct {
+	Results   []SearchResult `json:"results"`
+	Model     string         `json:"model"`
+	Backend   string         `json:"backend"`
+	LatencyMs int64          `json:"latency_ms"`
+}
+
+// Index embeds items and stores them for search. Items repeating an ID
+// earlier in the batch are dropped in favour of the last one, and items
+// already indexed under an ID are replaced.
+func (s *Service) Index(ctx context.Context, req *IndexRequest) (*IndexResponse, error) {
+	if s.index == nil {
+		return nil, ErrIndexUnavailable
+	}
+	start := time.Now()
+
+	items, duplicates := dedupeIndexItems(req.Items)
+
+	embItems := make([]EmbeddingRequest, len(items))
+	metadata := make(map[string]map[string]string, len(items))
+	for i, item := range items {
+		embItems[i] = EmbeddingRequest{ID: item.ID, Text: item.Text, OrgID: req.OrgID, UserID: req.UserID}
+		metadata[item.ID] = item.Metadata
+	}
+
+	batch, err := s.GenerateBatch(ctx, &BatchEmbeddingRequest{
+		Items:  embItems,
+		OrgID:  req.OrgID,
+		UserID: req.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([]IndexedVector, 0, len(batch.Results))
+	for _, r := range batch.Results {
+		vectors = append(vectors, IndexedVector{
+			ID:          r.ID,
+			Embedding:   r.Embedding,
+			Model:       r.Model,
+			ContentHash: r.ContentHash,
+			Metadata:    metadata[r.ID],
+		})
+	}
+
+	if len(vectors) > 0 {
+		if err := s.index.Upsert(ctx, req.OrgID, req.UserID, vectors); err != nil {
+			return nil, fmt.Errorf("failed to store embeddings: %w", err)
+		}
+	}
+
+	return &IndexResponse{
+		Indexed:    len(vectors),
+		Duplicates: duplicates,
+		Errors:     batch.Errors,
+		Backend:    s.index.Name(),
+		LatencyMs:  time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// Search embeds a query and returns the indexed items most similar to it
+func (s *Service) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	if s.index == nil {
+		return nil, ErrIndexUnavailable
+	}
+	start := time.Now()
+
+	topK := req.TopK
+	if topK <= 0 {
+		topK = defaultSearchTopK
+	}
+	if topK > maxSearchTopK {
+		topK = maxSearchTopK
+	}
+
+	query, err := s.Generate(ctx, &EmbeddingRequest{
+		ID:     "search-query",
+		Text:   req.Query,
+		OrgID:  req.OrgID,
+		UserID: req.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.index.Search(ctx, req.OrgID, req.UserID, query.Model, query.Embedding, topK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search embeddings: %w", err)
+	}
+
+	filtered := results[:0]
+	for _, r := range results {
+		if r.Score >= req.MinScore {
+			filtered = append(filtered, r)
+		}
+	}
+
+	return &SearchResponse{
+		Results:   filtered,
+		Model:     query.Model,
+		Backend:   s.index.Name(),
+		LatencyMs: time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// dedupeIndexItems keeps the last item for each ID, in the order the IDs
+// first appear, and reports how many items were dropped
+func dedupeIndexItems(items []IndexItem) ([]IndexItem, int) {
+	pos := make(map[string]int, len(items))
+	deduped := make([]IndexItem, 0, len(items))
+	for _, item := range items {
+		if i, ok := pos[item.ID]; ok {
+			deduped[i] = item
+			continue
+		}
+		pos[item.ID] = len(deduped)
+		deduped = append(deduped, item)
+	}
+	return deduped, len(items) - len(deduped)
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0
+// when their dimensions differ or either is zero
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// topResults sorts results by descending score, ties by ID, and keeps the
+// first k
+func topResults(results []SearchResult, k int) []SearchResult {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
@@ -0,0 +1,206 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+
+	"github.com/oonrumail/ai-assistant/provider"
+)
+
+// letterProvider embeds text as the counts of the letters a, b and c, so
+// texts built from the same letters point the same way
+type letterProvider struct{}
+
+func (p *letterProvider) Name() string                         { return "openai" }
+func (p *letterProvider) IsAvailable(ctx context.Context) bool { return true }
+
+func (p *letterProvider) Complete(ctx context.Context, req *provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (p *letterProvider) CompleteStream(ctx context.Context, req *provider.CompletionRequest) (provider.CompletionStream, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (p *letterProvider) GenerateEmbedding(ctx context.Context, req *provider.EmbeddingRequest) (*provider.EmbeddingResponse, error) {
+	return &provider.EmbeddingResponse{Embedding: letterVector(req.Text), Model: "letters", Provider: "openai"}, nil
+}
+
+func (p *letterProvider) GenerateEmbeddingBatch(ctx context.Context, req *provider.EmbeddingBatchRequest) (*provider.EmbeddingBatchResponse, error) {
+	embeddings := make([][]float64, len(req.Texts))
+	for i, t := range req.Texts {
+		embeddings[i] = letterVector(t)
+	}
+	return &provider.EmbeddingBatchResponse{Embeddings: embeddings, Model: "letters", Provider: "openai"}, nil
+}
+
+func letterVector(text string) []float64 {
+	return []float64{
+		float64(strings.Count(text, "a")),
+		float64(strings.Count(text, "b")),
+		float64(strings.Count(text, "c")),
+	}
+}
+
+// memIndex is an in-memory VectorIndex
+type memIndex struct {
+	mu      sync.Mutex
+	vectors map[string]map[string]IndexedVector // org/user -> item ID -> vector
+	upserts [][]IndexedVector
+}
+
+func (i *memIndex) Name() string { return "memory" }
+
+func (i *memIndex) Upsert(ctx context.Context, orgID, userID string, vectors []IndexedVector) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.vectors == nil {
+		i.vectors = make(map[string]map[string]IndexedVector)
+	}
+	owner := orgID + "/" + userID
+	if i.vectors[owner] == nil {
+		i.vectors[owner] = make(map[string]IndexedVector)
+	}
+	for _, v := range vectors {
+		i.vectors[owner][v.ID] = v
+	}
+	i.upserts = append(i.upserts, vectors)
+	return nil
+}
+
+func (i *memIndex) Search(ctx context.Context, orgID, userID, model string, query []float64, topK int) ([]SearchResult, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	results := []SearchResult{}
+	for _, v := range i.vectors[orgID+"/"+userID] {
+		if v.Model == model {
+			results = append(results, SearchResult{ID: v.ID, Score: cosineSimilarity(query, v.Embedding), Metadata: v.Metadata})
+		}
+	}
+	return topResults(results, topK), nil
+}
+
+func newTestService(t *testing.T, index VectorIndex) *Service {
+	t.Helper()
+
+	router := provider.NewRouter(provider.RouterConfig{DefaultEmbeddingProvider: "openai"}, zerolog.Nop())
+	router.RegisterProvider(&letterProvider{})
+
+	// Nothing listens here, so every cache lookup misses
+	cache := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { cache.Close() })
+
+	return NewService(router, cache, ServiceConfig{
+		MaxTextLen:    1000,
+		BatchSize:     10,
+		MaxConcurrent: 2,
+		Index:         index,
+	}, zerolog.Nop())
+}
+
+func TestIndexDedupesByID(t *testing.T) {
+	index := &memIndex{}
+	svc := newTestService(t, index)
+
+	resp, err := svc.Index(context.Background(), &IndexRequest{
+		OrgID:  "org-1",
+		UserID: "user-1",
+		Items: []IndexItem{
+			{ID: "m1", Text: "aaa"},
+			{ID: "m2", Text: "bbb"},
+			{ID: "m1", Text: "ccc", Metadata: map[string]string{"subject": "latest"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+
+	if resp.Indexed != 2 || resp.Duplicates != 1 {
+		t.Errorf("Indexed = %d, Duplicates = %d, want 2 and 1", resp.Indexed, resp.Duplicates)
+	}
+	if len(index.upserts) != 1 || len(index.upserts[0]) != 2 {
+		t.Fatalf("upserts = %v, want one batch of 2 vectors", index.upserts)
+	}
+
+	m1 := index.vectors["org-1/user-1"]["m1"]
+	if m1.Embedding[2] != 3 || m1.Metadata["subject"] != "latest" {
+		t.Errorf("m1 = %+v, want the last item with ID m1", m1)
+	}
+}
+
+func TestSearchIsolatesOrganizations(t *testing.T) {
+	index := &memIndex{}
+	svc := newTestService(t, index)
+	ctx := context.Background()
+
+	for _, req := range []*IndexRequest{
+		{OrgID: "org-1", UserID: "user-1", Items: []IndexItem{{ID: "a-mail", Text: "aaaa"}, {ID: "mixed", Text: "aab"}}},
+		{OrgID: "org-2", UserID: "user-1", Items: []IndexItem{{ID: "other-org", Text: "aaaa"}}},
+	} {
+		if _, err := svc.Index(ctx, req); err != nil {
+			t.Fatalf("Index() error = %v", err)
+		}
+	}
+
+	resp, err := svc.Search(ctx, &SearchRequest{Query: "a", OrgID: "org-1", UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	if len(resp.Results) != 2 {
+		t.Fatalf("Results = %+v, want the 2 items of org-1", resp.Results)
+	}
+	if resp.Results[0].ID != "a-mail" || math.Abs(resp.Results[0].Score-1) > 1e-9 {
+		t.Errorf("top result = %+v, want a-mail with score 1", resp.Results[0])
+	}
+	if resp.Results[1].ID != "mixed" || resp.Results[1].Score >= resp.Results[0].Score {
+		t.Errorf("second result = %+v, want mixed with a lower score", resp.Results[1])
+	}
+
+	resp, err = svc.Search(ctx, &SearchRequest{Query: "a", MinScore: 0.99, OrgID: "org-1", UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].ID != "a-mail" {
+		t.Errorf("Results with min_score = %+v, want only a-mail", resp.Results)
+	}
+}
+
+func TestSearchWithoutIndex(t *testing.T) {
+	svc := newTestService(t, nil)
+	if _, err := svc.Search(context.Background(), &SearchRequest{Query: "a", OrgID: "o", UserID: "u"}); err != ErrIndexUnavailable {
+		t.Errorf("Search() error = %v, want ErrIndexUnavailable", err)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 0}, []float64{2, 0}, 1},
+		{[]float64{1, 0}, []float64{0, 1}, 0},
+		{[]float64{1, 0}, []float64{-1, 0}, -1},
+		{[]float64{1, 0}, []float64{1, 0, 0}, 0},
+		{[]float64{0, 0}, []float64{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := cosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("cosineSimilarity(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRedisIndexKeysDontCollide(t *testing.T) {
+	i := NewRedisIndex(nil)
+	if i.key("org:a", "user") == i.key("org", "a:user") {
+		t.Error("different org and user pairs share a key")
+	}
+}
@@ -23,6 +23,7 @@ type Service struct {
 	maxTextLen    int
 	batchSize     int
 	maxConcurrent int
+	index         VectorIndex
 	logger        zerolog.Logger
 }
 
@@ -32,6 +33,9 @@ type ServiceConfig struct {
 	MaxTextLen    int
 	BatchSize     int
 	MaxConcurrent int
+
+	// Index stores embeddings for similarity search; nil disables search
+	Index VectorIndex
 }
 
 // NewService creates a new embedding service
@@ -43,6 +47,7 @@ func NewService(router *provider.Router, cache *redis.Client, cfg ServiceConfig,
 		maxTextLen:    cfg.MaxTextLen,
 		batchSize:     cfg.BatchSize,
 		maxConcurrent: cfg.MaxConcurrent,
+		index:         cfg.Index,
 		logger:        logger.With().Str("component", "embedding").Logger(),
 	}
 }
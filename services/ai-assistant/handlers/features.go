@@ -3,10 +3,14 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog"
 
 	"github.com/oonrumail/ai-assistant/analysis"
@@ -31,6 +35,7 @@ type Handler struct {
 	draftAssist   *draft.Service
 	priority      *priority.Service
 	rateLimiter   *ratelimit.Limiter
+	redis         *redis.Client
 	logger        zerolog.Logger
 }
 
@@ -45,6 +50,7 @@ func NewHandler(
 	draftSvc *draft.Service,
 	prioritySvc *priority.Service,
 	limiter *ratelimit.Limiter,
+	redisClient *redis.Client,
 	logger zerolog.Logger,
 ) *Handler {
 	return &Handler{
@@ -57,6 +63,7 @@ func NewHandler(
 		draftAssist:   draftSvc,
 		priority:      prioritySvc,
 		rateLimiter:   limiter,
+		redis:         redisClient,
 		logger:        logger.With().Str("component", "handler").Logger(),
 	}
 }
@@ -67,6 +74,7 @@ func (h *Handler) Routes() chi.Router {
 
 	// Health check
 	r.Get("/health", h.healthCheck)
+	r.Get("/ready", h.readinessCheck)
 	r.Get("/health/ready", h.readinessCheck)
 
 	// API routes
@@ -76,6 +84,8 @@ func (h *Handler) Routes() chi.Router {
 			r.Post("/analyze", h.analyzeEmail)
 			r.Post("/embeddings", h.generateEmbedding)
 			r.Post("/embeddings/batch", h.generateEmbeddingBatch)
+			r.Post("/embeddings/index", h.indexEmbeddings)
+			r.Post("/embeddings/search", h.searchEmbeddings)
 
 			// Smart Reply
 			r.Post("/smart-reply", h.generateSmartReplies)
@@ -140,24 +150,61 @@ func (h *Handler) healthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// readinessCheck reports not ready when Redis is unreachable or no provider in
+// the fallback chain passed its last health check
 func (h *Handler) readinessCheck(w http.ResponseWriter, r *http.Request) {
-	status := h.router.GetHealthStatus()
-	hasProvider := false
-	for _, healthy := range status {
-		if healthy {
-			hasProvider = true
-			break
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	checks := make(map[string]string)
+	ready := true
+
+	if err := h.redis.Ping(ctx).Err(); err != nil {
+		h.logger.Warn().Err(err).Msg("Readiness check: Redis unreachable")
+		checks["redis"] = "unreachable"
+		ready = false
+	} else {
+		checks["redis"] = "ok"
+	}
+
+	report := h.router.GetHealthReport()
+	providers := make([]map[string]interface{}, 0, len(report))
+	healthyInChain := 0
+	for _, p := range report {
+		entry := map[string]interface{}{
+			"name":              p.Name,
+			"healthy":           p.Healthy,
+			"in_fallback_chain": p.InFallbackChain,
+			"latency_ms":        p.Latency.Milliseconds(),
+			"last_checked":      nil,
+		}
+		if !p.CheckedAt.IsZero() {
+			entry["last_checked"] = p.CheckedAt.UTC().Format(time.RFC3339)
+		}
+		providers = append(providers, entry)
+
+		if p.Healthy && p.InFallbackChain {
+			healthyInChain++
 		}
 	}
 
-	if !hasProvider {
-		h.errorResponse(w, http.StatusServiceUnavailable, "No AI providers available")
-		return
+	if healthyInChain == 0 {
+		checks["providers"] = "no healthy provider in fallback chain"
+		ready = false
+	} else {
+		checks["providers"] = "ok"
 	}
 
-	h.jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"status":    "ready",
-		"providers": status,
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+
+	h.jsonResponse(w, code, map[string]interface{}{
+		"status":    status,
+		"checks":    checks,
+		"providers": providers,
+		"time":      time.Now().UTC().Format(time.RFC3339),
 	})
 }
 
@@ -172,6 +219,8 @@ func (h *Handler) generateSmartReplies(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.EmailID == "" || req.Body == "" {
 		h.errorResponse(w, http.StatusBadRequest, "email_id and body are required")
 		return
@@ -183,7 +232,7 @@ func (h *Handler) generateSmartReplies(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check rate limit
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, len(req.Body)/4); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, len(req.Body)/4) {
 		return
 	}
 
@@ -315,12 +364,14 @@ func (h *Handler) summarizeEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.EmailID == "" || req.Body == "" {
 		h.errorResponse(w, http.StatusBadRequest, "email_id and body are required")
 		return
 	}
 
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, len(req.Body)/4); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, len(req.Body)/4) {
 		return
 	}
 
@@ -341,18 +392,22 @@ func (h *Handler) summarizeThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.ThreadID == "" || len(req.Messages) == 0 {
 		h.errorResponse(w, http.StatusBadRequest, "thread_id and messages are required")
 		return
 	}
-
-	// Estimate tokens from all messages
-	totalChars := 0
-	for _, msg := range req.Messages {
-		totalChars += len(msg.Body)
+	for i, msg := range req.Messages {
+		if msg.ID == "" || msg.Body == "" {
+			h.errorResponse(w, http.StatusBadRequest, fmt.Sprintf("messages[%d]: id and body are required", i))
+			return
+		}
 	}
 
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, totalChars/4); err != nil {
+	// Long threads take several requests, so estimate across all of them
+	estimatedTokens := h.summarization.EstimateThreadTokens(req.Messages)
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, estimatedTokens) {
 		return
 	}
 
@@ -363,6 +418,10 @@ func (h *Handler) summarizeThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !result.Cached {
+		h.rateLimiter.RecordUsage(r.Context(), req.OrgID, req.UserID, result.TokensUsed)
+	}
+
 	h.jsonResponse(w, http.StatusOK, result)
 }
 
@@ -373,6 +432,8 @@ func (h *Handler) generateDailySummary(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.UserID == "" || req.OrgID == "" {
 		h.errorResponse(w, http.StatusBadRequest, "user_id and org_id are required")
 		return
@@ -384,7 +445,7 @@ func (h *Handler) generateDailySummary(w http.ResponseWriter, r *http.Request) {
 		totalChars += len(email.Preview) + len(email.Subject)
 	}
 
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, totalChars/4); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, totalChars/4) {
 		return
 	}
 
@@ -409,13 +470,15 @@ func (h *Handler) getInlineSuggestion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.CurrentText == "" {
 		h.errorResponse(w, http.StatusBadRequest, "current_text is required")
 		return
 	}
 
 	// Light rate limit for suggestions
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, 50); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, 50) {
 		return
 	}
 
@@ -439,12 +502,18 @@ func (h *Handler) helpMeWrite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.Prompt == "" {
 		h.errorResponse(w, http.StatusBadRequest, "prompt is required")
 		return
 	}
+	if err := req.Validate(); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, 500); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, 500) {
 		return
 	}
 
@@ -465,12 +534,14 @@ func (h *Handler) adjustTone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.Text == "" || req.TargetTone == "" {
 		h.errorResponse(w, http.StatusBadRequest, "text and target_tone are required")
 		return
 	}
 
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, len(req.Text)/4); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, len(req.Text)/4) {
 		return
 	}
 
@@ -490,12 +561,14 @@ func (h *Handler) checkGrammar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.Text == "" {
 		h.errorResponse(w, http.StatusBadRequest, "text is required")
 		return
 	}
 
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, len(req.Text)/4); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, len(req.Text)/4) {
 		return
 	}
 
@@ -580,9 +653,10 @@ type AnalyzeRequest struct {
 	OrgID          string   `json:"org_id"`
 	UserName       string   `json:"user_name"`
 	UserEmail      string   `json:"user_email"`
-	ExtractActionItems bool `json:"extract_action_items"`
-	DetectQuestions    bool `json:"detect_questions"`
-	SkipCache          bool `json:"skip_cache"`
+	ExtractActionItems bool   `json:"extract_action_items"`
+	DetectQuestions    bool   `json:"detect_questions"`
+	SkipCache          bool   `json:"skip_cache"`
+	Format             string `json:"format"` // text (default) or structured
 }
 
 func (h *Handler) analyzeEmail(w http.ResponseWriter, r *http.Request) {
@@ -592,6 +666,19 @@ func (h *Handler) analyzeEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
+	if format := r.URL.Query().Get("format"); format != "" {
+		req.Format = format
+	}
+	if req.Format == "" {
+		req.Format = analysis.FormatText
+	}
+	if req.Format != analysis.FormatText && req.Format != analysis.FormatStructured {
+		h.errorResponse(w, http.StatusBadRequest, "format must be text or structured")
+		return
+	}
+
 	if req.EmailID == "" || req.Body == "" {
 		h.errorResponse(w, http.StatusBadRequest, "email_id and body are required")
 		return
@@ -604,7 +691,7 @@ func (h *Handler) analyzeEmail(w http.ResponseWriter, r *http.Request) {
 
 	estimatedTokens := (len(req.Body) + len(req.Subject)) / 4
 
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, estimatedTokens); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, estimatedTokens) {
 		return
 	}
 
@@ -627,6 +714,11 @@ func (h *Handler) analyzeEmail(w http.ResponseWriter, r *http.Request) {
 		SkipCache:          req.SkipCache,
 	}
 
+	if req.Format == analysis.FormatStructured {
+		h.analyzeEmailStructured(w, r, analysisReq, estimatedTokens)
+		return
+	}
+
 	result, err := h.analysis.Analyze(r.Context(), analysisReq)
 	if err != nil {
 		h.logger.Error().Err(err).Str("email_id", req.EmailID).Msg("Analysis failed")
@@ -649,6 +741,24 @@ func (h *Handler) analyzeEmail(w http.ResponseWriter, r *http.Request) {
 	h.jsonResponse(w, http.StatusOK, result)
 }
 
+// analyzeEmailStructured returns schema-validated analysis. Unlike the text
+// format there is no heuristic fallback: callers asking for structured output
+// rely on the schema, so a failure is reported as an error.
+func (h *Handler) analyzeEmailStructured(w http.ResponseWriter, r *http.Request, analysisReq *analysis.AnalysisRequest, estimatedTokens int) {
+	result, err := h.analysis.GetStructuredAnalysis(r.Context(), analysisReq)
+	if err != nil {
+		h.logger.Error().Err(err).Str("email_id", analysisReq.EmailID).Msg("Structured analysis failed")
+		h.errorResponse(w, http.StatusBadGateway, "Structured analysis failed: "+err.Error())
+		return
+	}
+
+	if !result.Cached {
+		h.rateLimiter.RecordUsage(r.Context(), analysisReq.OrgID, analysisReq.UserID, estimatedTokens)
+	}
+
+	h.jsonResponse(w, http.StatusOK, result)
+}
+
 // EmbeddingRequest is the request body for embedding generation
 type EmbeddingRequest struct {
 	ID     string `json:"id"`
@@ -664,6 +774,8 @@ func (h *Handler) generateEmbedding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if req.ID == "" || req.Text == "" {
 		h.errorResponse(w, http.StatusBadRequest, "id and text are required")
 		return
@@ -675,7 +787,7 @@ func (h *Handler) generateEmbedding(w http.ResponseWriter, r *http.Request) {
 	}
 
 	estimatedTokens := len(req.Text) / 4
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, estimatedTokens); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, estimatedTokens) {
 		return
 	}
 
@@ -714,6 +826,8 @@ func (h *Handler) generateEmbeddingBatch(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
 	if len(req.Items) == 0 {
 		h.errorResponse(w, http.StatusBadRequest, "items array is required")
 		return
@@ -729,7 +843,7 @@ func (h *Handler) generateEmbeddingBatch(w http.ResponseWriter, r *http.Request)
 		totalTokens += len(item.Text) / 4
 	}
 
-	if err := h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, totalTokens); err != nil {
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, totalTokens) {
 		return
 	}
 
@@ -764,13 +878,110 @@ func (h *Handler) generateEmbeddingBatch(w http.ResponseWriter, r *http.Request)
 	h.jsonResponse(w, http.StatusOK, result)
 }
 
+// IndexEmbeddingsRequest is the request body for indexing items for search
+type IndexEmbeddingsRequest struct {
+	Items  []embedding.IndexItem `json:"items"`
+	OrgID  string                `json:"org_id"`
+	UserID string                `json:"user_id"`
+}
+
+func (h *Handler) indexEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req IndexEmbeddingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
+	if len(req.Items) == 0 {
+		h.errorResponse(w, http.StatusBadRequest, "items array is required")
+		return
+	}
+
+	if req.OrgID == "" || req.UserID == "" {
+		h.errorResponse(w, http.StatusBadRequest, "org_id and user_id are required")
+		return
+	}
+
+	totalTokens := 0
+	for _, item := range req.Items {
+		if item.ID == "" || item.Text == "" {
+			h.errorResponse(w, http.StatusBadRequest, "each item needs an id and text")
+			return
+		}
+		totalTokens += len(item.Text) / 4
+	}
+
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, totalTokens) {
+		return
+	}
+
+	result, err := h.embedding.Index(r.Context(), &embedding.IndexRequest{
+		Items:  req.Items,
+		OrgID:  req.OrgID,
+		UserID: req.UserID,
+	})
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Embedding indexing failed")
+		h.errorResponse(w, embeddingIndexStatus(err), "Failed to index items: "+err.Error())
+		return
+	}
+
+	h.rateLimiter.RecordUsage(r.Context(), req.OrgID, req.UserID, totalTokens)
+
+	h.jsonResponse(w, http.StatusOK, result)
+}
+
+func (h *Handler) searchEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req embedding.SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	req.OrgID, req.UserID = requestIdentity(r, req.OrgID, req.UserID)
+
+	if req.Query == "" {
+		h.errorResponse(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	if req.OrgID == "" || req.UserID == "" {
+		h.errorResponse(w, http.StatusBadRequest, "org_id and user_id are required")
+		return
+	}
+
+	estimatedTokens := len(req.Query) / 4
+	if !h.checkRateLimit(r.Context(), w, req.OrgID, req.UserID, estimatedTokens) {
+		return
+	}
+
+	result, err := h.embedding.Search(r.Context(), &req)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Embedding search failed")
+		h.errorResponse(w, embeddingIndexStatus(err), "Failed to search: "+err.Error())
+		return
+	}
+
+	h.rateLimiter.RecordUsage(r.Context(), req.OrgID, req.UserID, estimatedTokens)
+
+	h.jsonResponse(w, http.StatusOK, result)
+}
+
+func embeddingIndexStatus(err error) int {
+	if errors.Is(err, embedding.ErrIndexUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
 // ============================================================
 // USAGE & PROVIDER STATS
 // ============================================================
 
 func (h *Handler) getUsageStats(w http.ResponseWriter, r *http.Request) {
-	orgID := r.URL.Query().Get("org_id")
-	userID := r.URL.Query().Get("user_id")
+	orgID, userID := requestIdentity(r, r.URL.Query().Get("org_id"), r.URL.Query().Get("user_id"))
 
 	if orgID == "" || userID == "" {
 		h.errorResponse(w, http.StatusBadRequest, "org_id and user_id are required")
@@ -878,20 +1089,49 @@ func (h *Handler) invalidatePriorityCache(w http.ResponseWriter, r *http.Request
 // HELPERS
 // ============================================================
 
-func (h *Handler) checkRateLimit(ctx context.Context, w http.ResponseWriter, orgID, userID string, tokens int) error {
+// Rate limit response headers
+const (
+	headerRemainingTokens   = "X-RateLimit-Remaining-Tokens"
+	headerRemainingRequests = "X-RateLimit-Remaining-Requests"
+)
+
+// checkRateLimit checks the org and user budgets and writes the remaining-budget headers
+// It responds with 429 and returns false when the org or user budget is exhausted,
+// so it must run before any output (including streamed tokens) is written
+func (h *Handler) checkRateLimit(ctx context.Context, w http.ResponseWriter, orgID, userID string, tokens int) bool {
 	limitResult, err := h.rateLimiter.CheckLimit(ctx, orgID, userID, tokens)
 	if err != nil {
 		h.logger.Warn().Err(err).Msg("Rate limit check failed")
-		return nil // Don't block on rate limit errors
+		return true // Don't block on rate limit errors
+	}
+	if limitResult == nil {
+		return true
+	}
+
+	if limitResult.RemainingTokens != ratelimit.RemainingUnknown {
+		w.Header().Set(headerRemainingTokens, strconv.Itoa(max(limitResult.RemainingTokens, 0)))
+		w.Header().Set(headerRemainingRequests, strconv.Itoa(max(limitResult.RemainingRequests, 0)))
 	}
 
-	if limitResult != nil && !limitResult.Allowed {
-		w.Header().Set("Retry-After", string(rune(limitResult.RetryAfter)))
+	if !limitResult.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(max(limitResult.RetryAfter, 1)))
 		h.errorResponse(w, http.StatusTooManyRequests, limitResult.Message)
-		return err
+		return false
 	}
 
-	return nil
+	return true
+}
+
+// requestIdentity returns the org and user for a request, preferring the
+// identity forwarded by the authenticating gateway over the request body
+func requestIdentity(r *http.Request, orgID, userID string) (string, string) {
+	if id := r.Header.Get("X-Org-ID"); id != "" {
+		orgID = id
+	}
+	if id := r.Header.Get("X-User-ID"); id != "" {
+		userID = id
+	}
+	return orgID, userID
 }
 
 func (h *Handler) jsonResponse(w http.ResponseWriter, status int, data interface{}) {
@@ -13,6 +13,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -141,6 +142,7 @@ func main() {
 		MaxTextLen:    cfg.Embedding.MaxTextLength,
 		BatchSize:     cfg.Embedding.BatchSize,
 		MaxConcurrent: cfg.Embedding.MaxConcurrent,
+		Index:         initVectorIndex(ctx, cfg.Database, redisClient, logger),
 	}
 	embeddingSvc := embedding.NewService(providerRouter, redisClient, embeddingCfg, logger)
 	logger.Info().Msg("Initialized embedding service")
@@ -159,13 +161,16 @@ func main() {
 
 	// Initialize summarization service
 	summarizationCfg := summarization.ServiceConfig{
-		CacheTTL: cfg.Cache.AnalysisTTL,
+		CacheTTL:          cfg.Cache.AnalysisTTL,
+		ThreadChunkTokens: cfg.Summarization.ThreadChunkTokens,
 	}
 	summarizationSvc := summarization.NewService(providerRouter, redisClient, summarizationCfg, logger)
 	logger.Info().Msg("Initialized summarization service")
 
 	// Initialize draft assistant service
-	draftCfg := draft.ServiceConfig{}
+	draftCfg := draft.ServiceConfig{
+		CacheTTL: cfg.Cache.DraftTTL,
+	}
 	draftSvc := draft.NewService(providerRouter, redisClient, draftCfg, logger)
 	logger.Info().Msg("Initialized draft service")
 
@@ -177,7 +182,7 @@ func main() {
 	logger.Info().Msg("Initialized priority service")
 
 	// Initialize HTTP handler
-	handler := handlers.NewHandler(providerRouter, analysisSvc, embeddingSvc, smartReplySvc, autoReplySvc, summarizationSvc, draftSvc, prioritySvc, rateLimiter, logger)
+	handler := handlers.NewHandler(providerRouter, analysisSvc, embeddingSvc, smartReplySvc, autoReplySvc, summarizationSvc, draftSvc, prioritySvc, rateLimiter, redisClient, logger)
 
 	// Setup HTTP server
 	r := chi.NewRouter()
@@ -192,7 +197,7 @@ func main() {
 		AllowedOrigins:   []string{"https://mail.oonrumail.com", "https://admin.oonrumail.com", "https://console.oonrumail.com", "https://oonrumail.com", "http://localhost:3000", "http://localhost:3001"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Retry-After", "X-RateLimit-Remaining-Tokens", "X-RateLimit-Remaining-Requests"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -239,3 +244,35 @@ func main() {
 
 	logger.Info().Msg("Server exited")
 }
+
+// initVectorIndex returns the pgvector index when the database has it, and
+// otherwise an index kept in Redis
+func initVectorIndex(ctx context.Context, cfg config.DatabaseConfig, redisClient *redis.Client, logger zerolog.Logger) embedding.VectorIndex {
+	poolCfg, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		logger.Warn().Err(err).Msg("Invalid database configuration, using Redis vector index")
+		return embedding.NewRedisIndex(redisClient)
+	}
+	poolCfg.MaxConns = int32(cfg.MaxConns)
+	poolCfg.MinConns = int32(cfg.MinConns)
+	poolCfg.MaxConnLifetime = cfg.ConnMaxLife
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err == nil {
+		err = pool.Ping(ctx)
+	}
+	if err != nil {
+		logger.Warn().Err(err).Msg("Database unavailable, using Redis vector index")
+		return embedding.NewRedisIndex(redisClient)
+	}
+
+	index, err := embedding.NewPGVectorIndex(ctx, pool)
+	if err != nil {
+		pool.Close()
+		logger.Warn().Err(err).Msg("pgvector unavailable, using Redis vector index")
+		return embedding.NewRedisIndex(redisClient)
+	}
+
+	logger.Info().Msg("Using pgvector index")
+	return index
+}
@@ -0,0 +1,32 @@
+-- Embedding Index Schema
+-- Migration: 002_embedding_index.sql
+--
+-- Stores embeddings for semantic search when the pgvector extension is
+-- available. Without it nothing is created and the service keeps its index
+-- in Redis instead.
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector') THEN
+        CREATE EXTENSION IF NOT EXISTS vector;
+
+        CREATE TABLE IF NOT EXISTS ai_embedding_items (
+            org_id          VARCHAR(255) NOT NULL,
+            user_id         VARCHAR(255) NOT NULL,
+            item_id         VARCHAR(255) NOT NULL,
+            -- Dimensions depend on the embedding model, so the column is
+            -- unconstrained and searches compare equal dimensions only
+            embedding       vector NOT NULL,
+            model           VARCHAR(255) NOT NULL,
+            content_hash    CHAR(64) NOT NULL,
+            metadata        JSONB NOT NULL DEFAULT '{}',
+            indexed_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+            PRIMARY KEY (org_id, user_id, item_id)
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_ai_embedding_items_owner
+            ON ai_embedding_items(org_id, user_id, model);
+    END IF;
+END
+$$;
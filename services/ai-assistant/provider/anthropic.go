@@ -75,6 +75,19 @@ type anthropicRequest struct {
 	TopP        float64            `json:"top_p,omitempty"`
 	StopSequences []string         `json:"stop_sequences,omitempty"`
 	Stream      bool               `json:"stream,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
 type anthropicMessage struct {
@@ -87,8 +100,9 @@ type anthropicResponse struct {
 	Type         string `json:"type"`
 	Role         string `json:"role"`
 	Content      []struct {
-		Type string `json:"type"`
-		Text string `json:"text"`
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input,omitempty"` // tool_use blocks
 	} `json:"content"`
 	Model        string `json:"model"`
 	StopReason   string `json:"stop_reason"`
@@ -174,6 +188,16 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		Stream:        false,
 	}
 
+	// Force a call to the tool so its input comes back as the content
+	if req.Tool != nil {
+		reqBody.Tools = []anthropicTool{{
+			Name:        req.Tool.Name,
+			Description: req.Tool.Description,
+			InputSchema: req.Tool.Parameters,
+		}}
+		reqBody.ToolChoice = &anthropicToolChoice{Type: "tool", Name: req.Tool.Name}
+	}
+
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -217,6 +241,12 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 	if len(anthropicResp.Content) > 0 {
 		content = anthropicResp.Content[0].Text
 	}
+	for _, block := range anthropicResp.Content {
+		if block.Type == "tool_use" {
+			content = string(block.Input)
+			break
+		}
+	}
 
 	return &CompletionResponse{
 		Content:      content,
@@ -79,6 +79,7 @@ type ollamaChatRequest struct {
 	Model    string          `json:"model"`
 	Messages []ollamaMessage `json:"messages"`
 	Stream   bool            `json:"stream"`
+	Format   string          `json:"format,omitempty"`
 	Options  *ollamaOptions  `json:"options,omitempty"`
 }
 
@@ -124,12 +125,17 @@ func (p *OllamaProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		model = p.model
 	}
 
-	// Build messages
+	// Build messages. Without tool calling, structured output is requested
+	// through JSON mode and the schema is given in the system prompt.
+	systemPrompt := req.SystemPrompt
+	if req.Tool != nil {
+		systemPrompt = strings.TrimSpace(systemPrompt + "\n\n" + req.Tool.JSONInstructions())
+	}
 	messages := make([]ollamaMessage, 0, len(req.Messages)+1)
-	if req.SystemPrompt != "" {
+	if systemPrompt != "" {
 		messages = append(messages, ollamaMessage{
 			Role:    "system",
-			Content: req.SystemPrompt,
+			Content: systemPrompt,
 		})
 	}
 	for _, m := range req.Messages {
@@ -159,6 +165,9 @@ func (p *OllamaProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		Stream:   false,
 		Options:  options,
 	}
+	if req.Tool != nil {
+		reqBody.Format = "json"
+	}
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
@@ -105,11 +105,28 @@ type openAIChatRequest struct {
 	TopP        float64         `json:"top_p,omitempty"`
 	Stop        []string        `json:"stop,omitempty"`
 	Stream      bool            `json:"stream,omitempty"`
+	Tools       []openAITool    `json:"tools,omitempty"`
+	ToolChoice  interface{}     `json:"tool_choice,omitempty"`
 }
 
 type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function ToolDefinition `json:"function"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type openAIChatResponse struct {
@@ -219,6 +236,15 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		Stream:      false,
 	}
 
+	// Force a call to the tool so its arguments come back as the content
+	if req.Tool != nil {
+		reqBody.Tools = []openAITool{{Type: "function", Function: *req.Tool}}
+		reqBody.ToolChoice = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": req.Tool.Name},
+		}
+	}
+
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -265,6 +291,9 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 	if len(chatResp.Choices) > 0 {
 		content = chatResp.Choices[0].Message.Content
 		finishReason = chatResp.Choices[0].FinishReason
+		if calls := chatResp.Choices[0].Message.ToolCalls; len(calls) > 0 {
+			content = calls[0].Function.Arguments
+		}
 	}
 
 	return &CompletionResponse{
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 )
 
@@ -49,10 +50,29 @@ type CompletionRequest struct {
 	// Stop sequences
 	StopSequences []string `json:"stop_sequences,omitempty"`
 
+	// Tool to force for structured output (optional). Providers with tool
+	// calling return the tool arguments as Content; others use JSON mode.
+	Tool *ToolDefinition `json:"tool,omitempty"`
+
 	// Request metadata for tracking
 	Metadata RequestMetadata `json:"metadata,omitempty"`
 }
 
+// ToolDefinition describes a function whose arguments the model must produce
+type ToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"` // JSON Schema
+}
+
+// JSONInstructions returns prompt instructions for providers without tool
+// calling, asking for a bare JSON object matching the tool's schema
+func (t *ToolDefinition) JSONInstructions() string {
+	schema, _ := json.MarshalIndent(t.Parameters, "", "  ")
+	return "Respond only with a JSON object (no prose, no code fences) for " + t.Name +
+		" matching this JSON Schema:\n" + string(schema)
+}
+
 // Message represents a chat message
 type Message struct {
 	Role    MessageRole `json:"role"`
@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,11 +16,20 @@ type Router struct {
 	providers     map[string]Provider
 	fallbackChain []string
 	defaults      map[string]string // feature -> provider name
-	healthStatus  map[string]bool
+	healthStatus  map[string]ProviderHealth
 	healthMutex   sync.RWMutex
 	logger        zerolog.Logger
 }
 
+// ProviderHealth is the result of the last health check of a provider
+type ProviderHealth struct {
+	Name            string
+	Healthy         bool
+	Latency         time.Duration
+	CheckedAt       time.Time // Zero if the provider has not been checked yet
+	InFallbackChain bool
+}
+
 // RouterConfig contains router configuration
 type RouterConfig struct {
 	FallbackChain             string // comma-separated provider names
@@ -43,7 +53,7 @@ func NewRouter(cfg RouterConfig, logger zerolog.Logger) *Router {
 			"embedding":   cfg.DefaultEmbeddingProvider,
 			"smart_reply": cfg.DefaultSmartReplyProvider,
 		},
-		healthStatus: make(map[string]bool),
+		healthStatus: make(map[string]ProviderHealth),
 		logger:       logger.With().Str("component", "router").Logger(),
 	}
 }
@@ -118,21 +128,33 @@ func (r *Router) GetEmbeddingProvider(ctx context.Context) (Provider, error) {
 // isHealthy checks if a provider is healthy (cached)
 func (r *Router) isHealthy(ctx context.Context, p Provider) bool {
 	r.healthMutex.RLock()
-	healthy, ok := r.healthStatus[p.Name()]
+	health, ok := r.healthStatus[p.Name()]
 	r.healthMutex.RUnlock()
 
 	if ok {
-		return healthy
+		return health.Healthy
 	}
 
 	// Check health and cache result
-	healthy = p.IsAvailable(ctx)
+	health = r.checkHealth(ctx, p)
 
 	r.healthMutex.Lock()
-	r.healthStatus[p.Name()] = healthy
+	r.healthStatus[p.Name()] = health
 	r.healthMutex.Unlock()
 
-	return healthy
+	return health.Healthy
+}
+
+// checkHealth probes a provider and times the check
+func (r *Router) checkHealth(ctx context.Context, p Provider) ProviderHealth {
+	start := time.Now()
+	healthy := p.IsAvailable(ctx)
+	return ProviderHealth{
+		Name:      p.Name(),
+		Healthy:   healthy,
+		Latency:   time.Since(start),
+		CheckedAt: start,
+	}
 }
 
 // StartHealthChecker starts periodic health checks
@@ -141,6 +163,9 @@ func (r *Router) StartHealthChecker(ctx context.Context, interval time.Duration)
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
+		// Check right away so readiness reflects providers from startup
+		r.checkAllHealth(ctx)
+
 		for {
 			select {
 			case <-ctx.Done():
@@ -155,15 +180,15 @@ func (r *Router) StartHealthChecker(ctx context.Context, interval time.Duration)
 // checkAllHealth checks health of all providers
 func (r *Router) checkAllHealth(ctx context.Context) {
 	for name, provider := range r.providers {
-		healthy := provider.IsAvailable(ctx)
+		health := r.checkHealth(ctx, provider)
 
 		r.healthMutex.Lock()
 		prev := r.healthStatus[name]
-		r.healthStatus[name] = healthy
+		r.healthStatus[name] = health
 		r.healthMutex.Unlock()
 
-		if prev != healthy {
-			if healthy {
+		if prev.Healthy != health.Healthy {
+			if health.Healthy {
 				r.logger.Info().Str("provider", name).Msg("Provider became available")
 			} else {
 				r.logger.Warn().Str("provider", name).Msg("Provider became unavailable")
@@ -179,11 +204,35 @@ func (r *Router) GetHealthStatus() map[string]bool {
 
 	status := make(map[string]bool, len(r.healthStatus))
 	for k, v := range r.healthStatus {
-		status[k] = v
+		status[k] = v.Healthy
 	}
 	return status
 }
 
+// GetHealthReport returns the last health check of every registered provider,
+// sorted by name. Providers that have not been checked yet are unhealthy.
+func (r *Router) GetHealthReport() []ProviderHealth {
+	inChain := make(map[string]bool, len(r.fallbackChain))
+	for _, name := range r.fallbackChain {
+		inChain[name] = true
+	}
+
+	r.healthMutex.RLock()
+	defer r.healthMutex.RUnlock()
+
+	report := make([]ProviderHealth, 0, len(r.providers))
+	for name := range r.providers {
+		health, ok := r.healthStatus[name]
+		if !ok {
+			health = ProviderHealth{Name: name}
+		}
+		health.InFallbackChain = inChain[name]
+		report = append(report, health)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Name < report[j].Name })
+	return report
+}
+
 // CompleteWithFallback attempts completion with fallback
 func (r *Router) CompleteWithFallback(ctx context.Context, req *CompletionRequest, feature string) (*CompletionResponse, error) {
 	var lastErr error
@@ -219,7 +268,11 @@ func (r *Router) CompleteWithFallback(ctx context.Context, req *CompletionReques
 
 		// Mark provider as unhealthy
 		r.healthMutex.Lock()
-		r.healthStatus[provider.Name()] = false
+		health := r.healthStatus[provider.Name()]
+		health.Name = provider.Name()
+		health.Healthy = false
+		health.CheckedAt = time.Now()
+		r.healthStatus[provider.Name()] = health
 		r.healthMutex.Unlock()
 
 		// Try next provider
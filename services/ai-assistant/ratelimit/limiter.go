@@ -47,14 +47,19 @@ func NewLimiter(cache *redis.Client, cfg LimiterConfig, logger zerolog.Logger) *
 	}
 }
 
+// RemainingUnknown is reported for remaining budgets when usage couldn't be read
+const RemainingUnknown = -1
+
 // LimitResult contains the result of a rate limit check
 type LimitResult struct {
-	Allowed         bool    `json:"allowed"`
-	OrgRemaining    int     `json:"org_remaining"`
-	UserRemaining   int     `json:"user_remaining"`
-	RetryAfter      int     `json:"retry_after,omitempty"` // seconds
-	DegradedMode    bool    `json:"degraded_mode"`
-	Message         string  `json:"message,omitempty"`
+	Allowed           bool    `json:"allowed"`
+	OrgRemaining      int     `json:"org_remaining"`
+	UserRemaining     int     `json:"user_remaining"`
+	RemainingTokens   int     `json:"remaining_tokens"`   // Lowest of the org and user token budgets
+	RemainingRequests int     `json:"remaining_requests"` // Lowest of the org and user request budgets
+	RetryAfter        int     `json:"retry_after,omitempty"` // seconds
+	DegradedMode      bool    `json:"degraded_mode"`
+	Message           string  `json:"message,omitempty"`
 }
 
 // CheckLimit checks if a request is allowed
@@ -66,7 +71,7 @@ func (l *Limiter) CheckLimit(ctx context.Context, orgID, userID string, estimate
 	orgResult, err := l.checkOrgLimit(ctx, orgID, minute, estimatedTokens)
 	if err != nil {
 		l.logger.Warn().Err(err).Msg("Failed to check org limit, allowing request")
-		return &LimitResult{Allowed: true, DegradedMode: true}, nil
+		return unknownResult(), nil
 	}
 
 	if !orgResult.Allowed {
@@ -77,22 +82,42 @@ func (l *Limiter) CheckLimit(ctx context.Context, orgID, userID string, estimate
 	userResult, err := l.checkUserLimit(ctx, userID, minute, estimatedTokens)
 	if err != nil {
 		l.logger.Warn().Err(err).Msg("Failed to check user limit, allowing request")
-		return &LimitResult{Allowed: true, DegradedMode: true}, nil
+		return unknownResult(), nil
 	}
 
 	if !userResult.Allowed {
+		userResult.OrgRemaining = orgResult.OrgRemaining
+		userResult.RemainingTokens = min(orgResult.RemainingTokens, userResult.RemainingTokens)
+		userResult.RemainingRequests = min(orgResult.RemainingRequests, userResult.RemainingRequests)
 		return userResult, nil
 	}
 
 	// Combine results
 	return &LimitResult{
-		Allowed:       true,
-		OrgRemaining:  orgResult.OrgRemaining,
-		UserRemaining: userResult.UserRemaining,
-		DegradedMode:  l.shouldDegrade(orgResult.OrgRemaining, userResult.UserRemaining),
+		Allowed:           true,
+		OrgRemaining:      orgResult.OrgRemaining,
+		UserRemaining:     userResult.UserRemaining,
+		RemainingTokens:   min(orgResult.RemainingTokens, userResult.RemainingTokens),
+		RemainingRequests: min(orgResult.RemainingRequests, userResult.RemainingRequests),
+		DegradedMode:      l.shouldDegrade(orgResult.OrgRemaining, userResult.UserRemaining),
 	}, nil
 }
 
+// unknownResult allows a request whose usage couldn't be checked
+func unknownResult() *LimitResult {
+	return &LimitResult{
+		Allowed:           true,
+		RemainingTokens:   RemainingUnknown,
+		RemainingRequests: RemainingUnknown,
+		DegradedMode:      true,
+	}
+}
+
+// secondsUntilReset returns the seconds left in the current rate limit window
+func secondsUntilReset(now time.Time) int {
+	return 60 - now.Second()
+}
+
 // RecordUsage records actual token usage after a request
 func (l *Limiter) RecordUsage(ctx context.Context, orgID, userID string, tokens int) error {
 	minute := time.Now().Truncate(time.Minute).Unix()
@@ -140,32 +165,39 @@ func (l *Limiter) checkOrgLimit(ctx context.Context, orgID string, minute int64,
 	tokenLimit := int(float64(l.orgTokensPerMin) * l.burstMultiplier)
 	reqLimit := int(float64(l.orgRequestsPerMin) * l.burstMultiplier)
 
+	remainingTokens := max(tokenLimit-tokenUsage, 0)
+	remainingRequests := max(reqLimit-reqUsage, 0)
+
 	// Check if would exceed limits
 	if tokenUsage+estimatedTokens > tokenLimit {
 		remaining := tokenLimit - tokenUsage
-		retryAfter := 60 - (time.Now().Second())
 		return &LimitResult{
-			Allowed:      false,
-			OrgRemaining: remaining,
-			RetryAfter:   retryAfter,
-			Message:      fmt.Sprintf("Organization token limit exceeded. Remaining: %d tokens", remaining),
+			Allowed:           false,
+			OrgRemaining:      remaining,
+			RemainingTokens:   remainingTokens,
+			RemainingRequests: remainingRequests,
+			RetryAfter:        secondsUntilReset(time.Now()),
+			Message:           fmt.Sprintf("Organization token limit exceeded. Remaining: %d tokens", remaining),
 		}, nil
 	}
 
 	if reqUsage+1 > reqLimit {
 		remaining := reqLimit - reqUsage
-		retryAfter := 60 - (time.Now().Second())
 		return &LimitResult{
-			Allowed:      false,
-			OrgRemaining: remaining,
-			RetryAfter:   retryAfter,
-			Message:      fmt.Sprintf("Organization request limit exceeded. Remaining: %d requests", remaining),
+			Allowed:           false,
+			OrgRemaining:      remaining,
+			RemainingTokens:   remainingTokens,
+			RemainingRequests: remainingRequests,
+			RetryAfter:        secondsUntilReset(time.Now()),
+			Message:           fmt.Sprintf("Organization request limit exceeded. Remaining: %d requests", remaining),
 		}, nil
 	}
 
 	return &LimitResult{
-		Allowed:      true,
-		OrgRemaining: l.orgTokensPerMin - tokenUsage - estimatedTokens,
+		Allowed:           true,
+		OrgRemaining:      l.orgTokensPerMin - tokenUsage - estimatedTokens,
+		RemainingTokens:   remainingTokens - estimatedTokens,
+		RemainingRequests: remainingRequests - 1,
 	}, nil
 }
 
@@ -189,32 +221,39 @@ func (l *Limiter) checkUserLimit(ctx context.Context, userID string, minute int6
 	tokenLimit := int(float64(l.userTokensPerMin) * l.burstMultiplier)
 	reqLimit := int(float64(l.userRequestsPerMin) * l.burstMultiplier)
 
+	remainingTokens := max(tokenLimit-tokenUsage, 0)
+	remainingRequests := max(reqLimit-reqUsage, 0)
+
 	// Check if would exceed limits
 	if tokenUsage+estimatedTokens > tokenLimit {
 		remaining := tokenLimit - tokenUsage
-		retryAfter := 60 - (time.Now().Second())
 		return &LimitResult{
-			Allowed:       false,
-			UserRemaining: remaining,
-			RetryAfter:    retryAfter,
-			Message:       fmt.Sprintf("User token limit exceeded. Remaining: %d tokens", remaining),
+			Allowed:           false,
+			UserRemaining:     remaining,
+			RemainingTokens:   remainingTokens,
+			RemainingRequests: remainingRequests,
+			RetryAfter:        secondsUntilReset(time.Now()),
+			Message:           fmt.Sprintf("User token limit exceeded. Remaining: %d tokens", remaining),
 		}, nil
 	}
 
 	if reqUsage+1 > reqLimit {
 		remaining := reqLimit - reqUsage
-		retryAfter := 60 - (time.Now().Second())
 		return &LimitResult{
-			Allowed:       false,
-			UserRemaining: remaining,
-			RetryAfter:    retryAfter,
-			Message:       fmt.Sprintf("User request limit exceeded. Remaining: %d requests", remaining),
+			Allowed:           false,
+			UserRemaining:     remaining,
+			RemainingTokens:   remainingTokens,
+			RemainingRequests: remainingRequests,
+			RetryAfter:        secondsUntilReset(time.Now()),
+			Message:           fmt.Sprintf("User request limit exceeded. Remaining: %d requests", remaining),
 		}, nil
 	}
 
 	return &LimitResult{
-		Allowed:       true,
-		UserRemaining: l.userTokensPerMin - tokenUsage - estimatedTokens,
+		Allowed:           true,
+		UserRemaining:     l.userTokensPerMin - tokenUsage - estimatedTokens,
+		RemainingTokens:   remainingTokens - estimatedTokens,
+		RemainingRequests: remainingRequests - 1,
 	}, nil
 }
 
@@ -17,17 +17,19 @@ import (
 
 // Service handles email summarization
 type Service struct {
-	router         *provider.Router
-	cache          *redis.Client
-	cacheTTL       time.Duration
-	tldrThreshold  int // Characters before TL;DR kicks in
-	logger         zerolog.Logger
+	router            *provider.Router
+	cache             *redis.Client
+	cacheTTL          time.Duration
+	tldrThreshold     int // Characters before TL;DR kicks in
+	threadChunkTokens int // Token budget per chunk of a long thread
+	logger            zerolog.Logger
 }
 
 // ServiceConfig contains summarization service configuration
 type ServiceConfig struct {
-	CacheTTL      time.Duration
-	TLDRThreshold int // Default: 500 characters
+	CacheTTL          time.Duration
+	TLDRThreshold     int // Default: 500 characters
+	ThreadChunkTokens int // Default: 6000 tokens
 }
 
 // NewService creates a new summarization service
@@ -36,12 +38,17 @@ func NewService(router *provider.Router, cache *redis.Client, cfg ServiceConfig,
 	if threshold <= 0 {
 		threshold = 500
 	}
+	chunkTokens := cfg.ThreadChunkTokens
+	if chunkTokens <= 0 {
+		chunkTokens = 6000
+	}
 	return &Service{
-		router:        router,
-		cache:         cache,
-		cacheTTL:      cfg.CacheTTL,
-		tldrThreshold: threshold,
-		logger:        logger.With().Str("component", "summarization").Logger(),
+		router:            router,
+		cache:             cache,
+		cacheTTL:          cfg.CacheTTL,
+		tldrThreshold:     threshold,
+		threadChunkTokens: chunkTokens,
+		logger:            logger.With().Str("component", "summarization").Logger(),
 	}
 }
 
@@ -228,13 +235,16 @@ type ThreadSummaryRequest struct {
 	SkipCache bool            `json:"skip_cache"`
 }
 
-// ThreadMessage represents a message in the thread
+// ThreadMessage represents a message in the thread. Sender and Timestamp
+// are accepted in place of FromName and Date.
 type ThreadMessage struct {
 	ID          string `json:"id"`
 	FromAddress string `json:"from_address"`
 	FromName    string `json:"from_name"`
+	Sender      string `json:"sender,omitempty"`
 	Body        string `json:"body"`
 	Date        string `json:"date"`
+	Timestamp   string `json:"timestamp,omitempty"`
 	IsFromUser  bool   `json:"is_from_user"`
 }
 
@@ -249,6 +259,8 @@ type ThreadSummaryResponse struct {
 	ActionItems    []ActionItem `json:"action_items"`
 	Timeline       []TimelineEvent `json:"timeline"`    // Key events
 	CurrentStatus  string       `json:"current_status"` // ongoing/resolved/pending
+	ChunkCount     int          `json:"chunk_count"`    // Parts summarized separately
+	TokensUsed     int          `json:"tokens_used"`
 	Model          string       `json:"model"`
 	Provider       string       `json:"provider"`
 	Cached         bool         `json:"cached"`
@@ -262,18 +274,14 @@ type TimelineEvent struct {
 	Actor       string `json:"actor"`
 }
 
-// SummarizeThread generates a summary for an email thread
+// SummarizeThread generates a summary for an email thread. Threads too long
+// for one request are split into chunks that are summarized separately and
+// then combined.
 func (s *Service) SummarizeThread(ctx context.Context, req *ThreadSummaryRequest) (*ThreadSummaryResponse, error) {
 	start := time.Now()
 
-	// Build content hash for caching
-	var contentBuilder strings.Builder
-	for _, msg := range req.Messages {
-		contentBuilder.WriteString(msg.ID)
-		contentBuilder.WriteString(msg.Body)
-	}
-
-	cacheKey := s.generateCacheKey("thread", req.ThreadID, contentBuilder.String())
+	// Messages are immutable, so their IDs identify the thread's content
+	cacheKey := s.generateCacheKey("thread", req.ThreadID, threadMessageIDs(req.Messages))
 	if !req.SkipCache && s.cache != nil {
 		if cached, err := s.getCachedThreadResponse(ctx, cacheKey); err == nil && cached != nil {
 			cached.Cached = true
@@ -282,53 +290,45 @@ func (s *Service) SummarizeThread(ctx context.Context, req *ThreadSummaryRequest
 		}
 	}
 
-	// Extract participants
-	participantMap := make(map[string]bool)
-	for _, msg := range req.Messages {
-		participantMap[msg.FromName] = true
+	chunks := chunkThread(req.Messages, s.threadChunkTokens*charsPerToken)
+
+	var response *ThreadSummaryResponse
+	var usage threadUsage
+	var err error
+	if len(chunks) == 1 {
+		response, err = s.summarizeThreadPart(ctx, req, threadSystemPrompt, chunks[0].format(req.Subject, len(req.Messages)), &usage)
+	} else {
+		response, err = s.summarizeThreadChunks(ctx, req, chunks, &usage)
 	}
-	participants := make([]string, 0, len(participantMap))
-	for p := range participantMap {
-		participants = append(participants, p)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build prompt
-	systemPrompt := `You are an expert at summarizing email threads. Analyze the conversation and provide:
-1. A comprehensive summary of the entire thread (2-3 sentences)
-2. Key decisions that were made
-3. Questions that remain open/unanswered
-4. Action items with assignees if clear
-5. A timeline of key events
-6. Current status (ongoing/resolved/pending)
-
-Output as JSON:
-{
-  "summary": "Thread summary...",
-  "key_decisions": ["Decision 1", "Decision 2"],
-  "open_questions": ["Question 1"],
-  "action_items": [{"description": "Task...", "priority": "high", "assignee": "John"}],
-  "timeline": [{"date": "Jan 15", "description": "Event...", "actor": "John"}],
-  "current_status": "ongoing"
-}`
-
-	// Build conversation context
-	var convoBuilder strings.Builder
-	convoBuilder.WriteString(fmt.Sprintf("Subject: %s\n\n", req.Subject))
-	convoBuilder.WriteString("=== Conversation Thread ===\n\n")
+	response.ThreadID = req.ThreadID
+	response.Participants = threadParticipants(req.Messages)
+	response.MessageCount = len(req.Messages)
+	response.ChunkCount = len(chunks)
+	response.TokensUsed = usage.tokens
+	response.Model = usage.model
+	response.Provider = usage.provider
+	response.Cached = false
+	response.LatencyMs = time.Since(start).Milliseconds()
 
-	for i, msg := range req.Messages {
-		direction := "→" // Incoming
-		if msg.IsFromUser {
-			direction = "←" // Outgoing
-		}
-		convoBuilder.WriteString(fmt.Sprintf("[%d] %s %s (%s):\n%s\n\n",
-			i+1, direction, msg.FromName, msg.Date, truncateText(msg.Body, 800)))
+	// Cache response
+	if s.cache != nil && !req.SkipCache {
+		go s.cacheThreadResponse(context.Background(), cacheKey, response)
 	}
 
+	return response, nil
+}
+
+// summarizeThreadPart runs one summarization prompt over thread content and
+// parses the result
+func (s *Service) summarizeThreadPart(ctx context.Context, req *ThreadSummaryRequest, systemPrompt, content string, usage *threadUsage) (*ThreadSummaryResponse, error) {
 	completionReq := &provider.CompletionRequest{
 		Messages: []provider.Message{
 			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: convoBuilder.String()},
+			{Role: "user", Content: content},
 		},
 		MaxTokens:   1200,
 		Temperature: 0.3,
@@ -338,25 +338,12 @@ Output as JSON:
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate thread summary: %w", err)
 	}
+	usage.add(result, content)
 
-	// Parse response
 	response, err := s.parseThreadSummaryResponse(result.Content, req.ThreadID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
-
-	response.Participants = participants
-	response.MessageCount = len(req.Messages)
-	response.Model = result.Model
-	response.Provider = result.Provider
-	response.Cached = false
-	response.LatencyMs = time.Since(start).Milliseconds()
-
-	// Cache response
-	if s.cache != nil && !req.SkipCache {
-		go s.cacheThreadResponse(context.Background(), cacheKey, response)
-	}
-
 	return response, nil
 }
 
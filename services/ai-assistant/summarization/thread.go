@@ -0,0 +1,315 @@
+package summarization
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/oonrumail/ai-assistant/provider"
+)
+
+const (
+	// charsPerToken approximates token counts from text length
+	charsPerToken = 4
+
+	// maxThreadMessageChars caps a single message so it always fits a chunk
+	maxThreadMessageChars = 8000
+
+	// maxConcurrentChunks bounds parallel requests while summarizing chunks
+	maxConcurrentChunks = 4
+)
+
+const threadSystemPrompt = `You are an expert at summarizing email threads. Analyze the conversation and provide:
+1. A comprehensive summary of the entire thread (2-3 sentences)
+2. Key decisions that were made
+3. Questions that remain open/unanswered
+4. Action items that are still open, with assignees if clear
+5. A timeline of key events
+6. Current status (ongoing/resolved/pending)
+
+Output as JSON:
+{
+  "summary": "Thread summary...",
+  "key_decisions": ["Decision 1", "Decision 2"],
+  "open_questions": ["Question 1"],
+  "action_items": [{"description": "Task...", "priority": "high", "assignee": "John"}],
+  "timeline": [{"date": "Jan 15", "description": "Event...", "actor": "John"}],
+  "current_status": "ongoing"
+}`
+
+const threadChunkSystemPrompt = `You are an expert at summarizing email threads. You are given one part of a longer
+conversation; earlier and later messages are summarized separately. Summarize only this part and provide:
+1. A summary of what happens in these messages (2-4 sentences)
+2. Decisions made in these messages
+3. Questions raised and not answered within these messages
+4. Action items raised, with assignees if clear
+5. A timeline of key events
+6. The status at the end of this part (ongoing/resolved/pending)
+
+Output as JSON:
+{
+  "summary": "Part summary...",
+  "key_decisions": ["Decision 1"],
+  "open_questions": ["Question 1"],
+  "action_items": [{"description": "Task...", "priority": "high", "assignee": "John"}],
+  "timeline": [{"date": "Jan 15", "description": "Event...", "actor": "John"}],
+  "current_status": "ongoing"
+}`
+
+const threadReduceSystemPrompt = `You are an expert at summarizing email threads. You are given summaries of consecutive
+parts of one conversation, in order. Combine them into a single coherent summary of the whole thread:
+1. A comprehensive summary of the entire thread (2-3 sentences)
+2. Key decisions, with later decisions replacing earlier ones they reverse
+3. Questions that are still open at the end of the thread (drop ones answered in a later part)
+4. Action items that are still open at the end of the thread (drop ones completed later), without duplicates
+5. A timeline of key events
+6. Current status (ongoing/resolved/pending)
+
+Output as JSON:
+{
+  "summary": "Thread summary...",
+  "key_decisions": ["Decision 1", "Decision 2"],
+  "open_questions": ["Question 1"],
+  "action_items": [{"description": "Task...", "priority": "high", "assignee": "John"}],
+  "timeline": [{"date": "Jan 15", "description": "Event...", "actor": "John"}],
+  "current_status": "ongoing"
+}`
+
+// threadChunk is a run of consecutive thread messages summarized together
+type threadChunk struct {
+	first    int // Index of the first message in the thread
+	messages []string
+}
+
+// format renders the chunk as prompt content
+func (c threadChunk) format(subject string, total int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Subject: %s\n\n", subject)
+	if len(c.messages) == total {
+		b.WriteString("=== Conversation Thread ===\n\n")
+	} else {
+		fmt.Fprintf(&b, "=== Conversation Thread (messages %d-%d of %d) ===\n\n", c.first+1, c.first+len(c.messages), total)
+	}
+	for _, msg := range c.messages {
+		b.WriteString(msg)
+	}
+	return b.String()
+}
+
+// chunkThread formats the messages in order and groups them into chunks of
+// at most budget characters. Oversized messages are truncated so that each
+// fits in a chunk on its own.
+func chunkThread(messages []ThreadMessage, budget int) []threadChunk {
+	maxBody := min(maxThreadMessageChars, budget/2)
+
+	var chunks []threadChunk
+	current := threadChunk{}
+	size := 0
+	for i, msg := range messages {
+		direction := "→" // Incoming
+		if msg.IsFromUser {
+			direction = "←" // Outgoing
+		}
+		text := fmt.Sprintf("[%d] %s %s (%s):\n%s\n\n",
+			i+1, direction, senderName(msg), messageDate(msg), truncateText(msg.Body, maxBody))
+
+		if len(current.messages) > 0 && size+len(text) > budget {
+			chunks = append(chunks, current)
+			current = threadChunk{first: i}
+			size = 0
+		}
+		current.messages = append(current.messages, text)
+		size += len(text)
+	}
+	return append(chunks, current)
+}
+
+// summarizeThreadChunks summarizes each chunk and combines the results (map-reduce)
+func (s *Service) summarizeThreadChunks(ctx context.Context, req *ThreadSummaryRequest, chunks []threadChunk, usage *threadUsage) (*ThreadSummaryResponse, error) {
+	partials := make([]*ThreadSummaryResponse, len(chunks))
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentChunks)
+	for i, chunk := range chunks {
+		i, chunk := i, chunk // capture
+		g.Go(func() error {
+			partial, err := s.summarizeThreadPart(gctx, req, threadChunkSystemPrompt, chunk.format(req.Subject, len(req.Messages)), usage)
+			if err != nil {
+				return fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+			}
+			partials[i] = partial
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return s.reduceThreadSummaries(ctx, req, partials, usage)
+}
+
+// reduceThreadSummaries combines partial summaries in order. When they don't
+// fit in one request, neighbouring partials are combined in groups first.
+func (s *Service) reduceThreadSummaries(ctx context.Context, req *ThreadSummaryRequest, partials []*ThreadSummaryResponse, usage *threadUsage) (*ThreadSummaryResponse, error) {
+	budget := s.threadChunkTokens * charsPerToken
+
+	for {
+		groups := groupPartials(partials, budget)
+		if len(groups) == 1 {
+			return s.summarizeThreadPart(ctx, req, threadReduceSystemPrompt, formatPartials(req.Subject, groups[0]), usage)
+		}
+
+		combined := make([]*ThreadSummaryResponse, 0, len(groups))
+		for _, group := range groups {
+			if len(group) == 1 {
+				combined = append(combined, group[0])
+				continue
+			}
+			partial, err := s.summarizeThreadPart(ctx, req, threadReduceSystemPrompt, formatPartials(req.Subject, group), usage)
+			if err != nil {
+				return nil, err
+			}
+			combined = append(combined, partial)
+		}
+		partials = combined
+	}
+}
+
+// groupPartials splits partial summaries into runs that fit in budget
+// characters. Every group but a trailing one holds at least two partials so
+// each round of combining makes progress.
+func groupPartials(partials []*ThreadSummaryResponse, budget int) [][]*ThreadSummaryResponse {
+	var groups [][]*ThreadSummaryResponse
+	var current []*ThreadSummaryResponse
+	size := 0
+	for _, p := range partials {
+		n := len(partialJSON(p))
+		if len(current) >= 2 && size+n > budget {
+			groups = append(groups, current)
+			current = nil
+			size = 0
+		}
+		current = append(current, p)
+		size += n
+	}
+	return append(groups, current)
+}
+
+// formatPartials renders partial summaries as prompt content for combining
+func formatPartials(subject string, partials []*ThreadSummaryResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Subject: %s\n\n", subject)
+	for i, p := range partials {
+		fmt.Fprintf(&b, "=== Part %d of %d ===\n%s\n\n", i+1, len(partials), partialJSON(p))
+	}
+	return b.String()
+}
+
+// partialJSON serializes the content of a partial summary
+func partialJSON(p *ThreadSummaryResponse) string {
+	type actionItem struct {
+		Description string `json:"description"`
+		Priority    string `json:"priority,omitempty"`
+		Assignee    string `json:"assignee,omitempty"`
+	}
+	items := make([]actionItem, len(p.ActionItems))
+	for i, item := range p.ActionItems {
+		items[i] = actionItem{Description: item.Description, Priority: item.Priority, Assignee: item.Assignee}
+	}
+
+	data, _ := json.Marshal(struct {
+		Summary       string          `json:"summary"`
+		KeyDecisions  []string        `json:"key_decisions,omitempty"`
+		OpenQuestions []string        `json:"open_questions,omitempty"`
+		ActionItems   []actionItem    `json:"action_items,omitempty"`
+		Timeline      []TimelineEvent `json:"timeline,omitempty"`
+		CurrentStatus string          `json:"current_status,omitempty"`
+	}{p.Summary, p.KeyDecisions, p.OpenQuestions, items, p.Timeline, p.CurrentStatus})
+	return string(data)
+}
+
+// threadUsage accumulates token usage across the requests for one thread
+type threadUsage struct {
+	mu       sync.Mutex
+	tokens   int
+	model    string
+	provider string
+}
+
+func (u *threadUsage) add(resp *provider.CompletionResponse, prompt string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	tokens := resp.Usage.TotalTokens
+	if tokens == 0 {
+		tokens = (len(prompt) + len(resp.Content)) / charsPerToken
+	}
+	u.tokens += tokens
+	u.model = resp.Model
+	u.provider = resp.Provider
+}
+
+// EstimateThreadTokens estimates the tokens needed to summarize a thread,
+// including the requests that combine chunk summaries
+func (s *Service) EstimateThreadTokens(messages []ThreadMessage) int {
+	chunks := chunkThread(messages, s.threadChunkTokens*charsPerToken)
+
+	chars := 0
+	for _, chunk := range chunks {
+		for _, msg := range chunk.messages {
+			chars += len(msg)
+		}
+	}
+	tokens := chars / charsPerToken
+	if len(chunks) > 1 {
+		// Each chunk summary is read again when combining
+		tokens += len(chunks) * 1200
+	}
+	return tokens
+}
+
+// threadMessageIDs joins the message IDs of a thread for use as a cache key
+func threadMessageIDs(messages []ThreadMessage) string {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	return strings.Join(ids, "\n")
+}
+
+// threadParticipants lists the senders of a thread in order of first message
+func threadParticipants(messages []ThreadMessage) []string {
+	seen := make(map[string]bool)
+	participants := make([]string, 0)
+	for _, msg := range messages {
+		name := senderName(msg)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		participants = append(participants, name)
+	}
+	return participants
+}
+
+func senderName(msg ThreadMessage) string {
+	switch {
+	case msg.FromName != "":
+		return msg.FromName
+	case msg.Sender != "":
+		return msg.Sender
+	default:
+		return msg.FromAddress
+	}
+}
+
+func messageDate(msg ThreadMessage) string {
+	if msg.Date != "" {
+		return msg.Date
+	}
+	return msg.Timestamp
+}
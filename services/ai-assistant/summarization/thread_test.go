@@ -0,0 +1,218 @@
+package summarization
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/oonrumail/ai-assistant/provider"
+)
+
+// fakeProvider answers every completion with a fixed summary and records prompts
+type fakeProvider struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *fakeProvider) Name() string                         { return "fake" }
+func (p *fakeProvider) IsAvailable(ctx context.Context) bool { return true }
+
+func (p *fakeProvider) Complete(ctx context.Context, req *provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	p.mu.Lock()
+	p.prompts = append(p.prompts, req.Messages[0].Content)
+	n := len(p.prompts)
+	p.mu.Unlock()
+
+	return &provider.CompletionResponse{
+		Content:  fmt.Sprintf(`{"summary": "summary %d", "key_decisions": ["decision %d"], "action_items": [{"description": "task %d"}], "current_status": "ongoing"}`, n, n, n),
+		Model:    "fake-model",
+		Provider: "fake",
+		Usage:    provider.TokenUsage{TotalTokens: 100},
+	}, nil
+}
+
+func (p *fakeProvider) CompleteStream(ctx context.Context, req *provider.CompletionRequest) (provider.CompletionStream, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (p *fakeProvider) GenerateEmbedding(ctx context.Context, req *provider.EmbeddingRequest) (*provider.EmbeddingResponse, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (p *fakeProvider) GenerateEmbeddingBatch(ctx context.Context, req *provider.EmbeddingBatchRequest) (*provider.EmbeddingBatchResponse, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+// countPrompts returns how many recorded requests used the system prompt
+func (p *fakeProvider) countPrompts(systemPrompt string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, prompt := range p.prompts {
+		if prompt == systemPrompt {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestService(chunkTokens int) (*Service, *fakeProvider) {
+	fake := &fakeProvider{}
+	router := provider.NewRouter(provider.RouterConfig{FallbackChain: "fake"}, zerolog.Nop())
+	router.RegisterProvider(fake)
+	return NewService(router, nil, ServiceConfig{ThreadChunkTokens: chunkTokens}, zerolog.Nop()), fake
+}
+
+func testThread(n, bodyLen int) []ThreadMessage {
+	messages := make([]ThreadMessage, n)
+	for i := range messages {
+		messages[i] = ThreadMessage{
+			ID:        fmt.Sprintf("msg-%d", i+1),
+			Sender:    fmt.Sprintf("Person %d", i%3+1),
+			Timestamp: fmt.Sprintf("2026-03-%02dT10:00:00Z", i+1),
+			Body:      strings.Repeat("x", bodyLen),
+		}
+	}
+	return messages
+}
+
+func TestChunkThread(t *testing.T) {
+	messages := testThread(10, 300)
+
+	if chunks := chunkThread(messages, 100000); len(chunks) != 1 || len(chunks[0].messages) != 10 {
+		t.Fatalf("short thread: got %d chunks, want 1", len(chunks))
+	}
+
+	chunks := chunkThread(messages, 1000)
+	if len(chunks) < 2 {
+		t.Fatalf("long thread: got %d chunks, want several", len(chunks))
+	}
+	next := 0
+	for i, chunk := range chunks {
+		if chunk.first != next {
+			t.Errorf("chunk %d starts at message %d, want %d", i, chunk.first, next)
+		}
+		size := 0
+		for _, msg := range chunk.messages {
+			size += len(msg)
+		}
+		if size > 1000 {
+			t.Errorf("chunk %d is %d characters, over budget", i, size)
+		}
+		next += len(chunk.messages)
+	}
+	if next != len(messages) {
+		t.Errorf("chunks cover %d messages, want %d", next, len(messages))
+	}
+
+	// A single oversized message is truncated to fit
+	huge := chunkThread(testThread(1, 50000), 1000)
+	if len(huge) != 1 || len(huge[0].messages[0]) > 1000 {
+		t.Errorf("oversized message not truncated to fit a chunk")
+	}
+}
+
+func TestSummarizeThread_SinglePass(t *testing.T) {
+	svc, fake := newTestService(6000)
+
+	resp, err := svc.SummarizeThread(context.Background(), &ThreadSummaryRequest{
+		ThreadID: "thread-1",
+		Subject:  "Launch plan",
+		Messages: testThread(4, 200),
+	})
+	if err != nil {
+		t.Fatalf("SummarizeThread() error = %v", err)
+	}
+
+	if len(fake.prompts) != 1 || fake.countPrompts(threadSystemPrompt) != 1 {
+		t.Errorf("expected one whole-thread request, got %d requests", len(fake.prompts))
+	}
+	if resp.ChunkCount != 1 || resp.MessageCount != 4 || resp.TokensUsed != 100 {
+		t.Errorf("response = %+v", resp)
+	}
+	if got := strings.Join(resp.Participants, ","); got != "Person 1,Person 2,Person 3" {
+		t.Errorf("Participants = %s, want senders in order of appearance", got)
+	}
+	if len(resp.ActionItems) != 1 || resp.ActionItems[0].ID != "thread-1-action-1" {
+		t.Errorf("ActionItems = %+v", resp.ActionItems)
+	}
+}
+
+func TestSummarizeThread_MapReduce(t *testing.T) {
+	// 250 tokens is 1000 characters, so every message or two is its own chunk
+	svc, fake := newTestService(250)
+	messages := testThread(12, 400)
+
+	resp, err := svc.SummarizeThread(context.Background(), &ThreadSummaryRequest{
+		ThreadID: "thread-2",
+		Subject:  "Budget review",
+		Messages: messages,
+	})
+	if err != nil {
+		t.Fatalf("SummarizeThread() error = %v", err)
+	}
+
+	chunks := chunkThread(messages, 250*charsPerToken)
+	if resp.ChunkCount != len(chunks) || resp.ChunkCount < 2 {
+		t.Fatalf("ChunkCount = %d, want %d", resp.ChunkCount, len(chunks))
+	}
+	if got := fake.countPrompts(threadChunkSystemPrompt); got != len(chunks) {
+		t.Errorf("chunk requests = %d, want %d", got, len(chunks))
+	}
+	if fake.countPrompts(threadReduceSystemPrompt) == 0 {
+		t.Error("expected the chunk summaries to be combined")
+	}
+	if fake.countPrompts(threadSystemPrompt) != 0 {
+		t.Error("long thread should not be sent in a single request")
+	}
+	if resp.TokensUsed != 100*len(fake.prompts) {
+		t.Errorf("TokensUsed = %d, want %d", resp.TokensUsed, 100*len(fake.prompts))
+	}
+
+	// The final response comes from the last combining request
+	if resp.Summary != fmt.Sprintf("summary %d", len(fake.prompts)) {
+		t.Errorf("Summary = %q, want the combined summary", resp.Summary)
+	}
+}
+
+func TestGroupPartials(t *testing.T) {
+	partials := make([]*ThreadSummaryResponse, 7)
+	for i := range partials {
+		partials[i] = &ThreadSummaryResponse{Summary: strings.Repeat("s", 100)}
+	}
+
+	groups := groupPartials(partials, 1)
+	total := 0
+	for i, group := range groups {
+		if len(group) < 2 && i != len(groups)-1 {
+			t.Errorf("group %d has %d partials, want at least 2", i, len(group))
+		}
+		total += len(group)
+	}
+	if total != len(partials) || len(groups) >= len(partials) {
+		t.Errorf("groups = %d covering %d partials", len(groups), total)
+	}
+
+	if groups := groupPartials(partials, 100000); len(groups) != 1 {
+		t.Errorf("got %d groups, want 1 when everything fits", len(groups))
+	}
+}
+
+func TestThreadCacheKey(t *testing.T) {
+	svc, _ := newTestService(6000)
+	a := testThread(3, 10)
+	b := testThread(3, 20) // Same IDs, different bodies
+	c := testThread(4, 10)
+
+	key := func(m []ThreadMessage) string { return svc.generateCacheKey("thread", "t", threadMessageIDs(m)) }
+	if key(a) != key(b) {
+		t.Error("cache key should depend only on message IDs")
+	}
+	if key(a) == key(c) {
+		t.Error("a new message should change the cache key")
+	}
+}
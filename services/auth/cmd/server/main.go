@@ -21,6 +21,7 @@ import (
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -240,6 +241,9 @@ func createRouter(
 	r.Get("/health", healthCheck)
 	r.Get("/ready", makeReadinessCheck(dbPool, redisClient))
 
+	// Prometheus metrics
+	r.Handle("/metrics", promhttp.Handler())
+
 	// API routes
 	r.Route("/api/auth", func(r chi.Router) {
 		authHandler.RegisterRoutes(r, authMiddleware)
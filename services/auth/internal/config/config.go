@@ -16,6 +16,7 @@ type Config struct {
 	Security SecurityConfig
 	SSO      SSOConfig
 	Email    EmailConfig
+	Avatar   AvatarConfig
 }
 
 // ServerConfig holds HTTP server configuration.
@@ -81,8 +82,11 @@ type SSOConfig struct {
 	EntityID           string // SAML SP Entity ID
 	SAMLCertPath       string
 	SAMLKeyPath        string
-	Certificate        string // SP certificate (PEM)
-	PrivateKey         string // SP private key (PEM)
+	Certificate        string    // SP certificate (PEM)
+	PrivateKey         string    // SP private key (PEM)
+	PreviousCert       string    // SP certificate being rotated out (PEM)
+	PreviousKey        string    // SP private key being rotated out (PEM)
+	RotationEndsAt     time.Time // When the previous certificate stops being accepted; zero keeps it until removed
 	OIDCCallbackPath   string
 	SAMLCallbackPath   string
 	DefaultRedirectURL string
@@ -101,6 +105,16 @@ type EmailConfig struct {
 	PasswordResetURL string // URL for password reset page
 }
 
+// AvatarConfig holds profile picture upload configuration. Images are stored
+// in the storage service and served by this service at BaseURL.
+type AvatarConfig struct {
+	StorageURL    string // Storage service base URL; uploads are disabled when empty
+	BaseURL       string // Public URL of this service, used in avatar URLs
+	MaxSize       int    // Maximum upload size in bytes
+	MaxDimension  int    // Maximum width or height of an uploaded image in pixels
+	ThumbnailSize int    // Width and height of the square thumbnail in pixels
+}
+
 // Load creates a Config from environment variables.
 func Load() *Config {
 	return &Config{
@@ -158,6 +172,9 @@ func Load() *Config {
 			SAMLKeyPath:        getEnv("SAML_KEY_PATH", ""),
 			Certificate:        getEnv("SSO_CERTIFICATE", ""),
 			PrivateKey:         getEnv("SSO_PRIVATE_KEY", ""),
+			PreviousCert:       getEnv("SSO_PREVIOUS_CERTIFICATE", ""),
+			PreviousKey:        getEnv("SSO_PREVIOUS_PRIVATE_KEY", ""),
+			RotationEndsAt:     getEnvTime("SSO_ROTATION_ENDS_AT"),
 			OIDCCallbackPath:   getEnv("OIDC_CALLBACK_PATH", "/api/auth/sso/oidc/callback"),
 			SAMLCallbackPath:   getEnv("SAML_CALLBACK_PATH", "/api/auth/sso/saml/callback"),
 			DefaultRedirectURL: getEnv("SSO_DEFAULT_REDIRECT", "http://localhost:3000/dashboard"),
@@ -173,6 +190,13 @@ func Load() *Config {
 			VerificationURL:  getEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify"),
 			PasswordResetURL: getEnv("EMAIL_PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
 		},
+		Avatar: AvatarConfig{
+			StorageURL:    getEnv("STORAGE_SERVICE_URL", ""),
+			BaseURL:       getEnv("AVATAR_BASE_URL", getEnv("SSO_BASE_URL", "http://localhost:8080")),
+			MaxSize:       getEnvInt("AVATAR_MAX_SIZE", 5*1024*1024),
+			MaxDimension:  getEnvInt("AVATAR_MAX_DIMENSION", 4096),
+			ThumbnailSize: getEnvInt("AVATAR_THUMBNAIL_SIZE", 256),
+		},
 	}
 }
 
@@ -212,6 +236,15 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+func getEnvTime(key string) time.Time {
+	if value := os.Getenv(key); value != "" {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
 func getEnvSlice(key string, defaultValue []string) []string {
 	if value := os.Getenv(key); value != "" {
 		// Simple comma-separated parsing
@@ -3,14 +3,20 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/artpromedia/email/services/auth/internal/middleware"
 	"github.com/artpromedia/email/services/auth/internal/models"
 	"github.com/artpromedia/email/services/auth/internal/service"
+	"github.com/artpromedia/email/services/auth/internal/token"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 )
 
 // AdminHandler handles admin-related HTTP requests.
@@ -76,12 +82,28 @@ func (h *AdminHandler) RegisterRoutes(r chi.Router, authMiddleware *middleware.A
 		r.Use(middleware.RequireOrganizationAdmin())
 
 		r.Get("/", h.ListUsers)
+		r.Post("/bulk", h.BulkProvisionUsers)
 		r.Get("/{userId}", h.GetUser)
 		r.Put("/{userId}", h.UpdateUser)
 		r.Delete("/{userId}", h.DeleteUser)
 		r.Post("/{userId}/suspend", h.SuspendUser)
 		r.Post("/{userId}/unsuspend", h.UnsuspendUser)
 		r.Post("/{userId}/reset-password", h.AdminResetPassword)
+		r.Post("/{userId}/revoke-sessions", h.RevokeUserSessions)
+	})
+
+	// Security monitoring (org admin)
+	r.Route("/security", func(r chi.Router) {
+		r.Use(middleware.RequireOrganizationAdmin())
+
+		r.Get("/token-reuse-events", h.ListTokenReuseEvents)
+	})
+
+	// Audit log (org admin)
+	r.Route("/audit-logs", func(r chi.Router) {
+		r.Use(middleware.RequireOrganizationAdmin())
+
+		r.Get("/", h.ListAuditLogs)
 	})
 }
 
@@ -625,6 +647,61 @@ func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, users)
 }
 
+// BulkProvisionUsers creates or updates users in bulk.
+// POST /api/admin/users/bulk
+//
+// With "Accept: application/x-ndjson" the response is streamed as one
+// BulkProvisionEvent per line, so large batches report progress.
+func (h *AdminHandler) BulkProvisionUsers(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserClaims(r.Context())
+	if claims == nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		return
+	}
+
+	var req models.BulkProvisionUsersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		resp, err := h.adminService.BulkProvisionUsers(r.Context(), claims.OrganizationID, &req, nil)
+		if err != nil {
+			handleServiceError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	write := func(event models.BulkProvisionEvent) {
+		enc.Encode(event)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	resp, err := h.adminService.BulkProvisionUsers(r.Context(), claims.OrganizationID, &req, func(result *models.BulkUserResult) {
+		write(models.BulkProvisionEvent{Result: result})
+	})
+	if err != nil {
+		write(models.BulkProvisionEvent{Error: "Bulk provisioning was aborted"})
+		return
+	}
+	write(models.BulkProvisionEvent{Summary: resp})
+}
+
 // GetUser gets a user by ID.
 // GET /api/admin/users/{userId}
 func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
@@ -754,6 +831,152 @@ func (h *AdminHandler) AdminResetPassword(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// RevokeUserSessions revokes all sessions of a user (admin action).
+// POST /api/admin/users/{userId}/revoke-sessions
+func (h *AdminHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserClaims(r.Context())
+	if claims == nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		return
+	}
+
+	userIDStr := chi.URLParam(r, "userId")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "Invalid user ID")
+		return
+	}
+
+	err = h.adminService.RevokeUserSessions(r.Context(), claims.OrganizationID, claims.UserID, userID, getClientIP(r), r.UserAgent())
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "All sessions revoked successfully",
+	})
+}
+
+// Security handlers
+
+// ListTokenReuseEvents lists recent refresh token reuse detections.
+// GET /api/admin/security/token-reuse-events
+func (h *AdminHandler) ListTokenReuseEvents(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserClaims(r.Context())
+	if claims == nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		return
+	}
+
+	page := parseIntQuery(r, "page", 1)
+	limit := parseIntQuery(r, "limit", 20)
+
+	events, err := h.adminService.ListTokenReuseEvents(r.Context(), claims.OrganizationID, page, limit)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, events)
+}
+
+// Audit log handlers
+
+// ListAuditLogs lists the organization's audit events, newest first.
+// GET /api/admin/audit-logs
+//
+// Filters: user_id, action, resource_type, from and to (RFC 3339). Pages are
+// requested with limit and the previous page's next_cursor. With format=csv
+// every matching event is streamed as a CSV attachment instead.
+func (h *AdminHandler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserClaims(r.Context())
+	if claims == nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		return
+	}
+
+	filter, err := parseAuditLogFilter(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	switch q.Get("format") {
+	case "", "json":
+	case "csv":
+		h.exportAuditLogs(w, r, claims, filter)
+		return
+	default:
+		respondError(w, http.StatusBadRequest, "invalid_request", "format must be json or csv")
+		return
+	}
+
+	logs, err := h.adminService.ListAuditLogs(r.Context(), claims.OrganizationID, filter, q.Get("cursor"), parseIntQuery(r, "limit", 0))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, logs)
+}
+
+func (h *AdminHandler) exportAuditLogs(w http.ResponseWriter, r *http.Request, claims *token.Claims, filter *models.AuditLogFilter) {
+	filename := fmt.Sprintf("audit-logs-%s.csv", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	err := h.adminService.ExportAuditLogs(r.Context(), claims.OrganizationID, claims.UserID, filter, w, getClientIP(r), r.UserAgent())
+	if err == nil {
+		return
+	}
+	if errors.Is(err, service.ErrAuditLogExportAborted) {
+		// The status line has already been sent; the truncated body is all
+		// the client will see.
+		log.Error().Err(err).Str("organization_id", claims.OrganizationID.String()).Msg("Audit log export aborted")
+		return
+	}
+
+	w.Header().Del("Content-Disposition")
+	handleServiceError(w, err)
+}
+
+func parseAuditLogFilter(r *http.Request) (*models.AuditLogFilter, error) {
+	q := r.URL.Query()
+	filter := &models.AuditLogFilter{
+		Action:       q.Get("action"),
+		ResourceType: q.Get("resource_type"),
+	}
+
+	if v := q.Get("user_id"); v != "" {
+		userID, err := uuid.Parse(v)
+		if err != nil {
+			return nil, errors.New("Invalid user ID")
+		}
+		filter.UserID = &userID
+	}
+	for _, p := range []struct {
+		key  string
+		dest **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		v := q.Get(p.key)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", p.key)
+		}
+		*p.dest = &t
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, errors.New("from must be before to")
+	}
+
+	return filter, nil
+}
+
 // Helper function to parse integer query parameters
 func parseIntQuery(r *http.Request, key string, defaultValue int) int {
 	value := r.URL.Query().Get(key)
@@ -3,7 +3,10 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -42,6 +45,7 @@ func (h *AuthHandler) RegisterRoutes(r chi.Router, authMiddleware *middleware.Au
 	r.Get("/verify-email/{token}", h.VerifyEmail)
 	r.Post("/forgot-password", h.ForgotPassword)
 	r.Post("/reset-password", h.ResetPassword)
+	r.Get("/users/{userId}/avatar", h.GetAvatar)
 
 	// Protected routes
 	r.Group(func(r chi.Router) {
@@ -57,6 +61,8 @@ func (h *AuthHandler) RegisterRoutes(r chi.Router, authMiddleware *middleware.Au
 		r.Get("/me", h.GetCurrentUser)
 		r.Put("/me", h.UpdateProfile)
 		r.Put("/me/password", h.ChangePassword)
+		r.Post("/me/avatar", h.UploadAvatar)
+		r.Delete("/me/avatar", h.DeleteAvatar)
 
 		// Sessions
 		r.Get("/sessions", h.GetSessions)
@@ -173,6 +179,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		MFACode:   req.MFACode,
 		IPAddress: clientIP,
 		UserAgent: userAgent,
+		Location:  getClientLocation(r),
 	}
 
 	response, err := h.authService.Login(r.Context(), params)
@@ -249,7 +256,7 @@ func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	setTokenCookies(w, response)
+	setTokenCookies(w, response.TokenPair)
 	respondJSON(w, http.StatusOK, response)
 }
 
@@ -436,6 +443,134 @@ func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, user)
 }
 
+// UploadAvatar sets the user's avatar from the "avatar" field of a
+// multipart/form-data upload.
+// POST /api/auth/me/avatar
+func (h *AuthHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserClaims(r.Context())
+	if claims == nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		return
+	}
+
+	// Leave room for the multipart framing around the image
+	maxSize := h.authService.MaxAvatarSize()
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxSize)+64*1024)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "Expected a multipart/form-data upload")
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			respondError(w, http.StatusBadRequest, "invalid_request", "Missing avatar file")
+			return
+		}
+		if err != nil {
+			respondAvatarReadError(w, err)
+			return
+		}
+		if part.FormName() != "avatar" {
+			part.Close()
+			continue
+		}
+
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), "image/") {
+			respondError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Avatar must be an image")
+			return
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, int64(maxSize)+1))
+		if err != nil {
+			respondAvatarReadError(w, err)
+			return
+		}
+
+		user, err := h.authService.UploadAvatar(r.Context(), claims.UserID, data, getClientIP(r), r.UserAgent())
+		if err != nil {
+			handleServiceError(w, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, user)
+		return
+	}
+}
+
+// DeleteAvatar removes the user's avatar, reverting to the default identicon.
+// DELETE /api/auth/me/avatar
+func (h *AuthHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserClaims(r.Context())
+	if claims == nil {
+		respondError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		return
+	}
+
+	user, err := h.authService.DeleteAvatar(r.Context(), claims.UserID, getClientIP(r), r.UserAgent())
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, user)
+}
+
+// GetAvatar serves a user's avatar, or a generated identicon if they have
+// none. ?size=thumbnail returns the square thumbnail.
+// GET /api/auth/users/{userId}/avatar
+func (h *AuthHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "Invalid user ID")
+		return
+	}
+
+	variant := service.AvatarVariantFull
+	switch r.URL.Query().Get("size") {
+	case "", "full":
+	case "thumbnail":
+		variant = service.AvatarVariantThumbnail
+	default:
+		respondError(w, http.StatusBadRequest, "invalid_request", "size must be full or thumbnail")
+		return
+	}
+
+	avatar, err := h.authService.GetAvatar(r.Context(), userID, variant)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	if avatar.RedirectURL != "" {
+		http.Redirect(w, r, avatar.RedirectURL, http.StatusFound)
+		return
+	}
+
+	// Uploaded images are addressed by a versioned URL and never change
+	cacheControl := "public, max-age=300"
+	if !avatar.Generated && r.URL.Query().Get("v") != "" {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+	w.Header().Set("Content-Type", avatar.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(avatar.Data)))
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(avatar.Data)
+}
+
+func respondAvatarReadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		handleServiceError(w, service.ErrAvatarTooLarge)
+		return
+	}
+	respondError(w, http.StatusBadRequest, "invalid_request", "Failed to read upload")
+}
+
 // ChangePassword handles password change.
 // PUT /api/auth/me/password
 func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
@@ -779,10 +914,28 @@ func handleServiceError(w http.ResponseWriter, err error) {
 		respondError(w, http.StatusNotFound, "session_not_found", "Session not found")
 	case err == service.ErrPasswordTooWeak:
 		respondError(w, http.StatusBadRequest, "password_too_weak", "Password does not meet security requirements")
+	case err == service.ErrPasswordReused:
+		respondError(w, http.StatusBadRequest, "password_reused", "Password was used recently, choose one you haven't used before")
+	case err == service.ErrPasswordChangeTooSoon:
+		respondError(w, http.StatusBadRequest, "password_change_too_soon", "Password was changed too recently to change it again")
 	case err == service.ErrCannotDeletePrimaryEmail:
 		respondError(w, http.StatusBadRequest, "cannot_delete_primary", "Cannot delete primary email address")
 	case err == service.ErrSSORequired:
 		respondError(w, http.StatusForbidden, "sso_required", "This domain requires SSO login")
+	case err == service.ErrMFAInvalidCode:
+		respondError(w, http.StatusUnauthorized, "invalid_mfa_code", "Invalid MFA or backup code")
+	case err == service.ErrBackupCodesAlreadyIssued:
+		respondError(w, http.StatusConflict, "backup_codes_issued", "Backup codes have already been issued. Regenerate them to get new codes")
+	case err == service.ErrAvatarTooLarge:
+		respondError(w, http.StatusRequestEntityTooLarge, "avatar_too_large", "Avatar image is too large")
+	case err == service.ErrUnsupportedAvatarType:
+		respondError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Avatar must be a PNG, JPEG or GIF image")
+	case err == service.ErrInvalidAvatar:
+		respondError(w, http.StatusBadRequest, "invalid_image", "Avatar image could not be read")
+	case err == service.ErrAvatarStorageUnavailable:
+		respondError(w, http.StatusServiceUnavailable, "avatar_storage_unavailable", "Avatar uploads are not available")
+	case err == service.ErrInvalidAuditLogCursor:
+		respondError(w, http.StatusBadRequest, "invalid_cursor", "Invalid audit log cursor")
 	case err == service.ErrTokenReuse:
 		respondError(w, http.StatusUnauthorized, "token_reuse", "Security alert: refresh token was already used. All sessions have been revoked for your protection. Please log in again.")
 	default:
@@ -837,6 +990,18 @@ func clearTokenCookies(w http.ResponseWriter) {
 	})
 }
 
+// getClientLocation returns the approximate client location set by an edge
+// proxy or CDN, or an empty string if none is present.
+func getClientLocation(r *http.Request) string {
+	for _, header := range []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Client-Country"} {
+		// XX and T1 are Cloudflare's unknown and Tor markers
+		if country := strings.TrimSpace(r.Header.Get(header)); country != "" && country != "XX" && country != "T1" {
+			return country
+		}
+	}
+	return ""
+}
+
 func getClientIP(r *http.Request) string {
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
 		ips := strings.Split(xff, ",")
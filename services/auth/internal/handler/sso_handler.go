@@ -3,6 +3,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/artpromedia/email/services/auth/internal/middleware"
@@ -36,7 +37,10 @@ func (h *SSOHandler) RegisterRoutes(r chi.Router, authMiddleware *middleware.Aut
 	r.Get("/sso/discover", h.DiscoverSSO)
 	r.Post("/sso/initiate", h.InitiateSSO)
 	r.Post("/sso/saml/callback", h.SAMLCallback)
+	r.Post("/sso/{domainId}/saml/callback", h.SAMLCallback)
 	r.Get("/sso/oidc/callback", h.OIDCCallback)
+	r.Post("/sso/{domainId}/oidc/backchannel-logout", h.BackchannelLogout)
+	r.Get("/sso/{domainId}/saml/metadata", h.SAMLMetadata)
 	r.Get("/sso/saml/metadata/{domainId}", h.SAMLMetadata)
 
 	// Protected SSO admin routes
@@ -110,7 +114,7 @@ func (h *SSOHandler) InitiateSSO(w http.ResponseWriter, r *http.Request) {
 }
 
 // SAMLCallback handles the SAML assertion callback from the IdP.
-// POST /api/auth/sso/saml/callback
+// POST /api/auth/sso/{domainId}/saml/callback
 func (h *SSOHandler) SAMLCallback(w http.ResponseWriter, r *http.Request) {
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
@@ -212,8 +216,45 @@ func (h *SSOHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/dashboard", http.StatusFound)
 }
 
-// SAMLMetadata returns the SAML service provider metadata for a domain.
-// GET /api/auth/sso/saml/metadata/{domainId}
+// BackchannelLogout handles an OIDC back-channel logout token from the IdP.
+// POST /api/auth/sso/{domainId}/oidc/backchannel-logout
+func (h *SSOHandler) BackchannelLogout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	domainID, err := uuid.Parse(chi.URLParam(r, "domainId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "Invalid domain ID")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_request", "Invalid form data")
+		return
+	}
+
+	logoutToken := r.PostFormValue("logout_token")
+	if logoutToken == "" {
+		respondError(w, http.StatusBadRequest, "invalid_request", "logout_token required")
+		return
+	}
+
+	if err := h.ssoService.HandleBackchannelLogout(r.Context(), domainID, logoutToken); err != nil {
+		if errors.Is(err, service.ErrInvalidLogoutToken) {
+			log.Warn().Err(err).Str("domain_id", domainID.String()).Msg("Rejected OIDC logout token")
+			respondError(w, http.StatusBadRequest, "invalid_request", "Invalid logout token")
+			return
+		}
+		handleSSOError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// SAMLMetadata returns the SAML service provider metadata for a domain, for
+// IdP administrators to import. During an SP certificate rotation it lists
+// both the current and the previous certificate.
+// GET /api/auth/sso/{domainId}/saml/metadata
 func (h *SSOHandler) SAMLMetadata(w http.ResponseWriter, r *http.Request) {
 	domainIDStr := chi.URLParam(r, "domainId")
 	domainID, err := uuid.Parse(domainIDStr)
@@ -228,7 +269,7 @@ func (h *SSOHandler) SAMLMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
 	w.Write([]byte(metadata))
 }
 
@@ -2,6 +2,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,7 +23,7 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
-	MFACode  string `json:"mfa_code,omitempty" validate:"omitempty,len=6"`
+	MFACode  string `json:"mfa_code,omitempty" validate:"omitempty,min=6,max=12"`
 }
 
 // RefreshTokenRequest is the request body for token refresh.
@@ -79,6 +80,7 @@ type SSOConfigRequest struct {
 	OIDCConfig         *OIDCConfig `json:"oidc_config,omitempty" validate:"required_if=Provider oidc"`
 	AutoProvisionUsers bool        `json:"auto_provision_users"`
 	DefaultRole        string      `json:"default_role" validate:"omitempty,oneof=admin member viewer"`
+	GroupRoleMapping   *GroupRoleMapping `json:"group_role_mapping,omitempty"`
 	EnforceSSO         bool        `json:"enforce_sso"`
 }
 
@@ -173,6 +175,7 @@ type SSOConfigResponse struct {
 	EnforceSSO         bool        `json:"enforce_sso"`
 	AutoProvisionUsers bool        `json:"auto_provision_users"`
 	DefaultRole        string      `json:"default_role"`
+	GroupRoleMapping   *GroupRoleMapping `json:"group_role_mapping,omitempty"`
 	SAMLConfig         *SAMLConfig `json:"saml_config,omitempty"`
 	OIDCConfig         *OIDCConfigResponse `json:"oidc_config,omitempty"`
 	CreatedAt          string      `json:"created_at"`
@@ -189,6 +192,8 @@ type OIDCConfigResponse struct {
 	TokenURL         *string           `json:"token_url,omitempty"`
 	UserInfoURL      *string           `json:"userinfo_url,omitempty"`
 	AttributeMapping map[string]string `json:"attribute_mapping"`
+	// BackchannelLogoutURI is registered with the IdP as backchannel_logout_uri
+	BackchannelLogoutURI string `json:"backchannel_logout_uri"`
 }
 
 // ============================================================
@@ -229,7 +234,7 @@ type ValidationErrorDetail struct {
 // MFAVerifyRequest is the request for MFA verification during login.
 type MFAVerifyRequest struct {
 	MFAToken string `json:"mfa_token" validate:"required"`
-	Code     string `json:"code" validate:"required,len=6"`
+	Code     string `json:"code" validate:"required,min=6,max=12"` // TOTP code or backup code
 }
 
 // DisableMFARequest is the request to disable MFA.
@@ -411,6 +416,101 @@ type PaginatedUsersResponse struct {
 	Limit int             `json:"limit"`
 }
 
+// BulkUserRecord is one user in a bulk provisioning request.
+type BulkUserRecord struct {
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Role        string `json:"role"`
+}
+
+// BulkProvisionUsersRequest is the request to provision users in bulk.
+// Records are validated one by one so a bad record only fails itself.
+type BulkProvisionUsersRequest struct {
+	Users  []BulkUserRecord `json:"users" validate:"required,min=1,max=1000"`
+	Upsert bool             `json:"upsert"`
+}
+
+// BulkUserResult is the outcome of provisioning one user.
+type BulkUserResult struct {
+	Index  int        `json:"index"`
+	Email  string     `json:"email"`
+	Status string     `json:"status"` // created, updated, failed
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// BulkProvisionUsersResponse summarizes a bulk provisioning run.
+type BulkProvisionUsersResponse struct {
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Results []*BulkUserResult `json:"results,omitempty"`
+}
+
+// BulkProvisionEvent is one line of a streamed bulk provisioning response:
+// a record result, then the summary, or an error if the run was aborted.
+type BulkProvisionEvent struct {
+	Result  *BulkUserResult             `json:"result,omitempty"`
+	Summary *BulkProvisionUsersResponse `json:"summary,omitempty"`
+	Error   string                      `json:"error,omitempty"`
+}
+
+// TokenReuseEventResponse is a detected refresh token reuse.
+type TokenReuseEventResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     *uuid.UUID `json:"user_id,omitempty"`
+	SessionID  *uuid.UUID `json:"session_id,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	DetectedAt time.Time  `json:"detected_at"`
+}
+
+// PaginatedTokenReuseEventsResponse is a paginated list of token reuse detections.
+type PaginatedTokenReuseEventsResponse struct {
+	Events []*TokenReuseEventResponse `json:"events"`
+	Total  int                        `json:"total"`
+	Page   int                        `json:"page"`
+	Limit  int                        `json:"limit"`
+}
+
+// AuditLogFilter narrows an organization's audit log. Zero fields match everything.
+type AuditLogFilter struct {
+	UserID       *uuid.UUID
+	Action       string
+	ResourceType string
+	From         *time.Time // inclusive
+	To           *time.Time // exclusive
+}
+
+// AuditLogCursor is the position of an audit event in newest-first order.
+// Listing resumes at the events after it.
+type AuditLogCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// AuditLogResponse is an audit event with sensitive detail values redacted.
+type AuditLogResponse struct {
+	ID           uuid.UUID       `json:"id"`
+	UserID       *uuid.UUID      `json:"user_id,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   *uuid.UUID      `json:"resource_id,omitempty"`
+	Details      json.RawMessage `json:"details,omitempty"`
+	IPAddress    string          `json:"ip_address,omitempty"`
+	UserAgent    string          `json:"user_agent,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// AuditLogPageResponse is a page of audit events, newest first. NextCursor
+// is set when there are older events.
+type AuditLogPageResponse struct {
+	Logs       []*AuditLogResponse `json:"logs"`
+	Limit      int                 `json:"limit"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
 // ============================================================
 // SSO TEST RESPONSE
 // ============================================================
@@ -42,11 +42,18 @@ type OrganizationSettings struct {
 	PasswordPolicy         PasswordPolicy `json:"passwordPolicy"`
 	EmailRetentionDays     int           `json:"emailRetentionDays"`
 	AllowedIPRanges        []string      `json:"allowedIpRanges"`
+	NotifyOnAccountLockout *bool         `json:"notifyOnAccountLockout,omitempty"`
 	Branding               Branding      `json:"branding"`
 	CreatedAt              time.Time     `json:"created_at" db:"created_at"`
 	UpdatedAt              time.Time     `json:"updated_at" db:"updated_at"`
 }
 
+// AccountLockoutNotificationsEnabled reports whether users should be emailed
+// when their account is locked. Notifications are on unless disabled.
+func (s OrganizationSettings) AccountLockoutNotificationsEnabled() bool {
+	return s.NotifyOnAccountLockout == nil || *s.NotifyOnAccountLockout
+}
+
 // PasswordPolicy defines password requirements.
 type PasswordPolicy struct {
 	MinLength           int  `json:"minLength"`
@@ -55,6 +62,8 @@ type PasswordPolicy struct {
 	RequireNumbers      bool `json:"requireNumbers"`
 	RequireSpecialChars bool `json:"requireSpecialChars"`
 	ExpirationDays      int  `json:"expirationDays"`
+	HistoryCount        int  `json:"historyCount"` // Recent passwords that can't be reused, including the current one
+	MinAgeDays          int  `json:"minAgeDays"`   // Days before a user can change their password again
 }
 
 // DefaultPasswordPolicy returns a sensible default password policy.
@@ -66,6 +75,7 @@ func DefaultPasswordPolicy() PasswordPolicy {
 		RequireNumbers:      true,
 		RequireSpecialChars: true,
 		ExpirationDays:      90,
+		HistoryCount:        5,
 	}
 }
 
@@ -255,12 +265,38 @@ type SSOConfig struct {
 	EnforceSSO          bool             `json:"enforce_sso" db:"enforce_sso"` // Password login disabled
 	AutoProvisionUsers  bool             `json:"auto_provision_users" db:"auto_provision_users"`
 	DefaultRole         string           `json:"default_role" db:"default_role"`
+	GroupRoleMapping    *GroupRoleMapping `json:"group_role_mapping,omitempty" db:"group_role_mapping"`
 	SAMLConfig          *SAMLConfig      `json:"saml_config,omitempty" db:"saml_config"`
 	OIDCConfig          *OIDCConfig      `json:"oidc_config,omitempty" db:"oidc_config"`
 	CreatedAt           time.Time        `json:"created_at" db:"created_at"`
 	UpdatedAt           time.Time        `json:"updated_at" db:"updated_at"`
 }
 
+// Policies for SSO users whose IdP groups match no group role mapping.
+const (
+	UnmappedGroupsKeep      = "keep"      // Leave role and permissions as they are
+	UnmappedGroupsDowngrade = "downgrade" // Viewer role without domain management permissions
+	UnmappedGroupsDisable   = "disable"   // Suspend the user
+)
+
+// GroupRoleMapping assigns roles and domain permissions from IdP group
+// claims. It is applied on every SSO login so changes in the IdP propagate.
+type GroupRoleMapping struct {
+	GroupAttribute string         `json:"group_attribute"` // SAML attribute or OIDC claim, default "groups"
+	Mappings       []GroupMapping `json:"mappings" validate:"required,min=1,dive"`
+	UnmappedPolicy string         `json:"unmapped_policy" validate:"omitempty,oneof=keep downgrade disable"`
+}
+
+// GroupMapping maps one IdP group to a role and permissions on the SSO
+// domain. Send-as on the user's own address is always kept.
+type GroupMapping struct {
+	Group            string `json:"group" validate:"required"`
+	Role             string `json:"role" validate:"required,oneof=admin member viewer"`
+	CanManage        bool   `json:"can_manage"`
+	CanViewAnalytics bool   `json:"can_view_analytics"`
+	CanManageUsers   bool   `json:"can_manage_users"`
+}
+
 // SAMLConfig holds SAML IdP configuration.
 type SAMLConfig struct {
 	IDPMetadataURL   string  `json:"idp_metadata_url"`
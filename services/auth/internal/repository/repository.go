@@ -3,6 +3,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -394,6 +395,82 @@ func (r *Repository) UpdateUser(ctx context.Context, user *models.User) error {
 	return nil
 }
 
+// ReplaceMFABackupCodes swaps the user's backup codes only if they still equal
+// expected. Returns false when another request changed them first.
+func (r *Repository) ReplaceMFABackupCodes(ctx context.Context, userID uuid.UUID, expected, updated sql.NullString) (bool, error) {
+	query := `
+		UPDATE users
+		SET mfa_backup_codes = $3, updated_at = $4
+		WHERE id = $1 AND mfa_backup_codes = $2
+	`
+	result, err := r.pool.Exec(ctx, query, userID, expected, updated, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to update backup codes: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// GetPasswordHistory returns the hashes of the user's previous passwords,
+// newest first, up to limit.
+func (r *Repository) GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	query := `
+		SELECT password_hash FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.pool.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password history: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to scan password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// AddPasswordHistory records the hash of a password the user is replacing and
+// deletes all but the newest keep entries. With keep 0 the history is cleared.
+func (r *Repository) AddPasswordHistory(ctx context.Context, userID uuid.UUID, passwordHash string, keep int) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if keep > 0 {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO password_history (id, user_id, password_hash, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, uuid.New(), userID, passwordHash, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to add password history: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		)
+	`, userID, keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
 // UpdateUserLoginSuccess updates user on successful login.
 func (r *Repository) UpdateUserLoginSuccess(ctx context.Context, userID uuid.UUID, ip string) error {
 	query := `
@@ -405,21 +482,33 @@ func (r *Repository) UpdateUserLoginSuccess(ctx context.Context, userID uuid.UUI
 	return err
 }
 
-// UpdateUserLoginFailure increments failed login attempts.
-func (r *Repository) UpdateUserLoginFailure(ctx context.Context, userID uuid.UUID, lockoutDuration time.Duration, maxAttempts int) error {
+// UpdateUserLoginFailure increments failed login attempts and locks the
+// account once maxAttempts is reached. It reports whether this failure started
+// a new lockout; failures while a lockout is already active don't extend it.
+func (r *Repository) UpdateUserLoginFailure(ctx context.Context, userID uuid.UUID, lockoutDuration time.Duration, maxAttempts int) (bool, error) {
 	query := `
 		UPDATE users
 		SET failed_login_attempts = failed_login_attempts + 1,
 		    locked_until = CASE
-		        WHEN failed_login_attempts + 1 >= $2 THEN $3
+		        WHEN failed_login_attempts + 1 >= $2
+		             AND (locked_until IS NULL OR locked_until <= NOW()) THEN $3
 		        ELSE locked_until
 		    END,
 		    updated_at = NOW()
 		WHERE id = $1
+		RETURNING locked_until IS NOT DISTINCT FROM $3
 	`
-	lockoutTime := time.Now().Add(lockoutDuration)
-	_, err := r.pool.Exec(ctx, query, userID, maxAttempts, lockoutTime)
-	return err
+	// Truncate to the database's precision so the RETURNING comparison matches
+	lockoutTime := time.Now().Add(lockoutDuration).Truncate(time.Microsecond)
+	var locked bool
+	err := r.pool.QueryRow(ctx, query, userID, maxAttempts, lockoutTime).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrNotFound
+		}
+		return false, fmt.Errorf("failed to update login failure: %w", err)
+	}
+	return locked, nil
 }
 
 // ============================================================
@@ -894,6 +983,40 @@ func (r *Repository) RevokeAllUserSessions(ctx context.Context, userID uuid.UUID
 	return err
 }
 
+// SetSessionSSOSID records the IdP session ID (OIDC sid) a session was created under.
+func (r *Repository) SetSessionSSOSID(ctx context.Context, sessionID uuid.UUID, sid string) error {
+	query := `UPDATE user_sessions SET sso_sid = $2 WHERE id = $1`
+	_, err := r.pool.Exec(ctx, query, sessionID, sid)
+	return err
+}
+
+// GetUserIDsBySSOSID returns the users with active sessions created under an
+// IdP session ID, limited to users with an SSO identity in the domain.
+func (r *Repository) GetUserIDsBySSOSID(ctx context.Context, domainID uuid.UUID, sid string) ([]uuid.UUID, error) {
+	query := `
+		SELECT DISTINCT s.user_id
+		FROM user_sessions s
+		JOIN sso_identities i ON i.user_id = s.user_id AND i.domain_id = $1
+		WHERE s.sso_sid = $2 AND s.revoked_at IS NULL
+	`
+
+	rows, err := r.pool.Query(ctx, query, domainID, sid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions by SSO sid: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan session user: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
 // DeleteSession deletes a session by ID (alias for RevokeSession).
 func (r *Repository) DeleteSession(ctx context.Context, sessionID uuid.UUID) error {
 	return r.RevokeSession(ctx, sessionID)
@@ -912,17 +1035,18 @@ func (r *Repository) DeleteUserSessions(ctx context.Context, userID uuid.UUID) e
 func (r *Repository) GetSSOConfigByDomainID(ctx context.Context, domainID uuid.UUID) (*models.SSOConfig, error) {
 	query := `
 		SELECT id, domain_id, provider, is_enabled, enforce_sso, auto_provision_users,
-		       default_role, saml_config, oidc_config, created_at, updated_at
+		       default_role, group_role_mapping, saml_config, oidc_config,
+		       created_at, updated_at
 		FROM domain_sso_configs
 		WHERE domain_id = $1
 	`
 
 	var config models.SSOConfig
-	var samlJSON, oidcJSON []byte
+	var mappingJSON, samlJSON, oidcJSON []byte
 	err := r.pool.QueryRow(ctx, query, domainID).Scan(
 		&config.ID, &config.DomainID, &config.Provider, &config.IsEnabled,
 		&config.EnforceSSO, &config.AutoProvisionUsers, &config.DefaultRole,
-		&samlJSON, &oidcJSON, &config.CreatedAt, &config.UpdatedAt,
+		&mappingJSON, &samlJSON, &oidcJSON, &config.CreatedAt, &config.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -931,6 +1055,13 @@ func (r *Repository) GetSSOConfigByDomainID(ctx context.Context, domainID uuid.U
 		return nil, fmt.Errorf("failed to get SSO config: %w", err)
 	}
 
+	if len(mappingJSON) > 0 {
+		var mapping models.GroupRoleMapping
+		if err := json.Unmarshal(mappingJSON, &mapping); err == nil {
+			config.GroupRoleMapping = &mapping
+		}
+	}
+
 	if samlJSON != nil && len(samlJSON) > 0 {
 		var samlConfig models.SAMLConfig
 		if err := json.Unmarshal(samlJSON, &samlConfig); err == nil {
@@ -952,21 +1083,25 @@ func (r *Repository) GetSSOConfigByDomainID(ctx context.Context, domainID uuid.U
 func (r *Repository) UpsertSSOConfig(ctx context.Context, config *models.SSOConfig) error {
 	query := `
 		INSERT INTO domain_sso_configs (id, domain_id, provider, is_enabled, enforce_sso,
-		                                 auto_provision_users, default_role, saml_config,
-		                                 oidc_config, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		                                 auto_provision_users, default_role, group_role_mapping,
+		                                 saml_config, oidc_config, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		ON CONFLICT (domain_id) DO UPDATE SET
 		    provider = EXCLUDED.provider,
 		    is_enabled = EXCLUDED.is_enabled,
 		    enforce_sso = EXCLUDED.enforce_sso,
 		    auto_provision_users = EXCLUDED.auto_provision_users,
 		    default_role = EXCLUDED.default_role,
+		    group_role_mapping = EXCLUDED.group_role_mapping,
 		    saml_config = EXCLUDED.saml_config,
 		    oidc_config = EXCLUDED.oidc_config,
 		    updated_at = EXCLUDED.updated_at
 	`
 
-	var samlJSON, oidcJSON []byte
+	var mappingJSON, samlJSON, oidcJSON []byte
+	if config.GroupRoleMapping != nil {
+		mappingJSON, _ = json.Marshal(config.GroupRoleMapping)
+	}
 	if config.SAMLConfig != nil {
 		samlJSON, _ = json.Marshal(config.SAMLConfig)
 	}
@@ -977,7 +1112,7 @@ func (r *Repository) UpsertSSOConfig(ctx context.Context, config *models.SSOConf
 	_, err := r.pool.Exec(ctx, query,
 		config.ID, config.DomainID, config.Provider, config.IsEnabled,
 		config.EnforceSSO, config.AutoProvisionUsers, config.DefaultRole,
-		samlJSON, oidcJSON, config.CreatedAt, config.UpdatedAt,
+		mappingJSON, samlJSON, oidcJSON, config.CreatedAt, config.UpdatedAt,
 	)
 	return err
 }
@@ -1090,6 +1225,105 @@ func (r *Repository) CreateAuditLog(ctx context.Context, log *models.AuditLog) e
 	return err
 }
 
+// ListAuditLogsByAction lists an organization's audit events with the given action, newest first.
+func (r *Repository) ListAuditLogsByAction(ctx context.Context, orgID uuid.UUID, action string, limit, offset int) ([]*models.AuditLog, int, error) {
+	var total int
+	err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM audit_logs WHERE organization_id = $1 AND action = $2`,
+		orgID, action,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	query := `
+		SELECT id, organization_id, user_id, action, resource_type,
+		       resource_id, details, ip_address, user_agent, created_at
+		FROM audit_logs
+		WHERE organization_id = $1 AND action = $2
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.pool.Query(ctx, query, orgID, action, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*models.AuditLog
+	for rows.Next() {
+		var l models.AuditLog
+		if err := rows.Scan(
+			&l.ID, &l.OrganizationID, &l.UserID, &l.Action, &l.ResourceType,
+			&l.ResourceID, &l.Details, &l.IPAddress, &l.UserAgent, &l.CreatedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		logs = append(logs, &l)
+	}
+
+	return logs, total, rows.Err()
+}
+
+// ListAuditLogs lists up to limit of an organization's audit events matching
+// filter, newest first, starting after cursor if it is set. It pages by
+// (created_at, id) rather than OFFSET and skips the total count, so every
+// page is an index range scan on idx_audit_logs_org_created however large
+// the organization's log is.
+func (r *Repository) ListAuditLogs(ctx context.Context, orgID uuid.UUID, filter *models.AuditLogFilter, cursor *models.AuditLogCursor, limit int) ([]*models.AuditLog, error) {
+	query := `
+		SELECT id, organization_id, user_id, action, resource_type,
+		       resource_id, details, ip_address, user_agent, created_at
+		FROM audit_logs
+		WHERE organization_id = $1
+	`
+	args := []interface{}{orgID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.UserID != nil {
+		query += " AND user_id = " + arg(*filter.UserID)
+	}
+	if filter.Action != "" {
+		query += " AND action = " + arg(filter.Action)
+	}
+	if filter.ResourceType != "" {
+		query += " AND resource_type = " + arg(filter.ResourceType)
+	}
+	if filter.From != nil {
+		query += " AND created_at >= " + arg(*filter.From)
+	}
+	if filter.To != nil {
+		query += " AND created_at < " + arg(*filter.To)
+	}
+	if cursor != nil {
+		query += fmt.Sprintf(" AND (created_at, id) < (%s, %s)", arg(cursor.CreatedAt), arg(cursor.ID))
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + arg(limit)
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*models.AuditLog
+	for rows.Next() {
+		var l models.AuditLog
+		if err := rows.Scan(
+			&l.ID, &l.OrganizationID, &l.UserID, &l.Action, &l.ResourceType,
+			&l.ResourceID, &l.Details, &l.IPAddress, &l.UserAgent, &l.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		logs = append(logs, &l)
+	}
+
+	return logs, rows.Err()
+}
+
 // CheckEmailExists checks if an email address already exists.
 func (r *Repository) CheckEmailExists(ctx context.Context, email string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM user_email_addresses WHERE LOWER(email_address) = LOWER($1))`
@@ -622,12 +622,11 @@ func (s *AdminService) CreateDomainUser(ctx context.Context, domainID uuid.UUID,
 		return nil, fmt.Errorf("organization not found: %w", err)
 	}
 
-	// Extract local part and validate email matches domain
+	// Validate email matches domain
 	parts := strings.Split(req.Email, "@")
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("invalid email format")
 	}
-	localPart := parts[0]
 	emailDomain := parts[1]
 
 	if !strings.EqualFold(emailDomain, domain.DomainName) {
@@ -654,21 +653,45 @@ func (s *AdminService) CreateDomainUser(ctx context.Context, domainID uuid.UUID,
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	now := time.Now()
-	userID := uuid.New()
-	emailAddressID := uuid.New()
-	mailboxID := uuid.New()
-
 	role := "member"
 	if req.Role == "admin" {
 		role = "admin"
 	}
 
+	user, err := s.createMailboxUser(ctx, org, domain, req.Email, req.DisplayName, role,
+		sql.NullString{String: string(passwordHash), Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DomainUserResponse{
+		UserID:           user.ID,
+		Email:            strings.ToLower(req.Email),
+		Name:             req.DisplayName,
+		CanSendAs:        true,
+		CanManage:        role == "admin",
+		CanViewAnalytics: role == "admin",
+		CanManageUsers:   role == "admin",
+		GrantedAt:        user.CreatedAt,
+	}, nil
+}
+
+// createMailboxUser creates a pre-verified user with a primary address on
+// domain and a mailbox. Domain admins also get manage rights on the domain.
+func (s *AdminService) createMailboxUser(ctx context.Context, org *models.Organization, domain *models.Domain, email, displayName, role string, passwordHash sql.NullString) (*models.User, error) {
+	email = strings.ToLower(email)
+	localPart, _, _ := strings.Cut(email, "@")
+
+	now := time.Now()
+	userID := uuid.New()
+	emailAddressID := uuid.New()
+	mailboxID := uuid.New()
+
 	user := &models.User{
 		ID:             userID,
 		OrganizationID: org.ID,
-		DisplayName:    req.DisplayName,
-		PasswordHash:   sql.NullString{String: string(passwordHash), Valid: true},
+		DisplayName:    displayName,
+		PasswordHash:   passwordHash,
 		Role:           role,
 		Status:         "active",
 		Timezone:       "UTC",
@@ -682,7 +705,7 @@ func (s *AdminService) CreateDomainUser(ctx context.Context, domainID uuid.UUID,
 		ID:           emailAddressID,
 		UserID:       userID,
 		DomainID:     domain.ID,
-		EmailAddress: strings.ToLower(req.Email),
+		EmailAddress: email,
 		LocalPart:    localPart,
 		IsPrimary:    true,
 		IsVerified:   true,
@@ -693,8 +716,8 @@ func (s *AdminService) CreateDomainUser(ctx context.Context, domainID uuid.UUID,
 		ID:             mailboxID,
 		UserID:         userID,
 		EmailAddressID: emailAddressID,
-		DomainEmail:    strings.ToLower(req.Email),
-		DisplayName:    sql.NullString{String: req.DisplayName, Valid: true},
+		DomainEmail:    email,
+		DisplayName:    sql.NullString{String: displayName, Valid: true},
 		QuotaBytes:     org.Settings.DefaultUserQuotaBytes,
 		UsedBytes:      0,
 		IsActive:       true,
@@ -711,7 +734,7 @@ func (s *AdminService) CreateDomainUser(ctx context.Context, domainID uuid.UUID,
 
 	// If role is admin, update domain permissions
 	if role == "admin" {
-		perm, _ := s.repo.GetUserDomainPermission(ctx, userID, domainID)
+		perm, _ := s.repo.GetUserDomainPermission(ctx, userID, domain.ID)
 		if perm != nil {
 			perm.CanManage = true
 			perm.CanViewAnalytics = true
@@ -720,16 +743,7 @@ func (s *AdminService) CreateDomainUser(ctx context.Context, domainID uuid.UUID,
 		}
 	}
 
-	return &models.DomainUserResponse{
-		UserID:           userID,
-		Email:            strings.ToLower(req.Email),
-		Name:             req.DisplayName,
-		CanSendAs:        true,
-		CanManage:        role == "admin",
-		CanViewAnalytics: role == "admin",
-		CanManageUsers:   role == "admin",
-		GrantedAt:        now,
-	}, nil
+	return user, nil
 }
 
 // RemoveDomainUser removes a user from a domain.
@@ -899,6 +913,75 @@ func (s *AdminService) UnsuspendUser(ctx context.Context, userID uuid.UUID) erro
 	return s.repo.UpdateUser(ctx, user)
 }
 
+// RevokeUserSessions revokes all sessions of a user in the admin's organization,
+// invalidating every refresh token the user holds.
+func (s *AdminService) RevokeUserSessions(ctx context.Context, orgID, adminID, userID uuid.UUID, ipAddress, userAgent string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil || user.OrganizationID != orgID {
+		return ErrUserNotFound
+	}
+
+	if err := s.repo.RevokeAllUserSessions(ctx, userID, nil); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	refreshTokenFamilyRevocations.WithLabelValues(revocationReasonAdmin).Inc()
+
+	s.repo.CreateAuditLog(ctx, &models.AuditLog{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		UserID:         &adminID,
+		Action:         "user.sessions_revoked",
+		ResourceType:   "user",
+		ResourceID:     &userID,
+		IPAddress:      sql.NullString{String: ipAddress, Valid: ipAddress != ""},
+		UserAgent:      sql.NullString{String: userAgent, Valid: userAgent != ""},
+		CreatedAt:      time.Now(),
+	})
+
+	log.Info().
+		Str("user_id", userID.String()).
+		Str("admin_id", adminID.String()).
+		Msg("All user sessions revoked by admin")
+
+	return nil
+}
+
+// ListTokenReuseEvents lists recent refresh token reuse detections in an organization.
+func (s *AdminService) ListTokenReuseEvents(ctx context.Context, orgID uuid.UUID, page, limit int) (*models.PaginatedTokenReuseEventsResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset := (page - 1) * limit
+
+	logs, total, err := s.repo.ListAuditLogsByAction(ctx, orgID, auditActionTokenReuseDetected, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token reuse events: %w", err)
+	}
+
+	events := make([]*models.TokenReuseEventResponse, 0, len(logs))
+	for _, l := range logs {
+		events = append(events, &models.TokenReuseEventResponse{
+			ID:         l.ID,
+			UserID:     l.UserID,
+			SessionID:  l.ResourceID,
+			IPAddress:  l.IPAddress.String,
+			UserAgent:  l.UserAgent.String,
+			DetectedAt: l.CreatedAt,
+		})
+	}
+
+	return &models.PaginatedTokenReuseEventsResponse{
+		Events: events,
+		Total:  total,
+		Page:   page,
+		Limit:  limit,
+	}, nil
+}
+
 // Password reset constants
 const (
 	passwordResetExpiry        = 1 * time.Hour  // Tokens expire in 1 hour
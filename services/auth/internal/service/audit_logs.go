@@ -0,0 +1,415 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Audit log listing and export limits.
+const (
+	auditLogDefaultLimit = 50
+	auditLogMaxLimit     = 200
+	auditLogExportBatch  = 500
+
+	auditActionAuditLogExported = "audit_logs.exported"
+
+	redactedValue = "[REDACTED]"
+)
+
+// Audit log errors
+var (
+	ErrInvalidAuditLogCursor = errors.New("invalid audit log cursor")
+	// ErrAuditLogExportAborted is returned when an export fails after part
+	// of it has been written, so the response can no longer be replaced.
+	ErrAuditLogExportAborted = errors.New("audit log export aborted")
+)
+
+// auditLogCSVHeader is the column order of an audit log export.
+var auditLogCSVHeader = []string{
+	"id", "created_at", "user_id", "action", "resource_type",
+	"resource_id", "ip_address", "user_agent", "details",
+}
+
+// ListAuditLogs lists a page of an organization's audit events, newest first.
+// cursor is the NextCursor of the previous page, or empty for the first.
+func (s *AdminService) ListAuditLogs(ctx context.Context, orgID uuid.UUID, filter *models.AuditLogFilter, cursor string, limit int) (*models.AuditLogPageResponse, error) {
+	if limit < 1 {
+		limit = auditLogDefaultLimit
+	}
+	if limit > auditLogMaxLimit {
+		limit = auditLogMaxLimit
+	}
+
+	after, err := decodeAuditLogCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra event to learn whether there is a next page without
+	// counting the whole log.
+	logs, err := s.repo.ListAuditLogs(ctx, orgID, filter, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+
+	resp := &models.AuditLogPageResponse{
+		Logs:  make([]*models.AuditLogResponse, 0, len(logs)),
+		Limit: limit,
+	}
+	if len(logs) > limit {
+		logs = logs[:limit]
+		last := logs[len(logs)-1]
+		resp.NextCursor = encodeAuditLogCursor(last.CreatedAt, last.ID)
+	}
+	for _, l := range logs {
+		resp.Logs = append(resp.Logs, auditLogResponse(l))
+	}
+
+	return resp, nil
+}
+
+// ExportAuditLogs writes every audit event of an organization matching filter
+// to w as CSV, newest first. Events are read in batches so the export streams
+// regardless of its size. Details are flattened into "key=value" pairs and
+// sensitive values are redacted. The export itself is recorded in the audit log.
+//
+// Nothing is written until the first batch has been read, so an error before
+// then can still be reported normally. Later errors are wrapped in
+// ErrAuditLogExportAborted.
+func (s *AdminService) ExportAuditLogs(ctx context.Context, orgID, adminID uuid.UUID, filter *models.AuditLogFilter, w io.Writer, ipAddress, userAgent string) error {
+	logs, err := s.repo.ListAuditLogs(ctx, orgID, filter, nil, auditLogExportBatch)
+	if err != nil {
+		return fmt.Errorf("failed to list audit logs: %w", err)
+	}
+
+	s.recordAuditLogExport(ctx, orgID, adminID, filter, ipAddress, userAgent)
+
+	cw := csv.NewWriter(w)
+	flusher, _ := w.(interface{ Flush() })
+
+	if err := cw.Write(auditLogCSVHeader); err != nil {
+		return fmt.Errorf("%w: %v", ErrAuditLogExportAborted, err)
+	}
+
+	exported := 0
+	for {
+		for _, l := range logs {
+			if err := cw.Write(auditLogCSVRecord(auditLogResponse(l))); err != nil {
+				return fmt.Errorf("%w: %v", ErrAuditLogExportAborted, err)
+			}
+		}
+		exported += len(logs)
+
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("%w: %v", ErrAuditLogExportAborted, err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(logs) < auditLogExportBatch {
+			break
+		}
+
+		last := logs[len(logs)-1]
+		after := &models.AuditLogCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		if logs, err = s.repo.ListAuditLogs(ctx, orgID, filter, after, auditLogExportBatch); err != nil {
+			return fmt.Errorf("%w: %v", ErrAuditLogExportAborted, err)
+		}
+	}
+
+	log.Info().
+		Str("organization_id", orgID.String()).
+		Str("admin_id", adminID.String()).
+		Int("events", exported).
+		Msg("Audit log exported")
+
+	return nil
+}
+
+func (s *AdminService) recordAuditLogExport(ctx context.Context, orgID, adminID uuid.UUID, filter *models.AuditLogFilter, ipAddress, userAgent string) {
+	details := map[string]interface{}{"format": "csv"}
+	if filter.UserID != nil {
+		details["user_id"] = filter.UserID.String()
+	}
+	if filter.Action != "" {
+		details["action"] = filter.Action
+	}
+	if filter.ResourceType != "" {
+		details["resource_type"] = filter.ResourceType
+	}
+	if filter.From != nil {
+		details["from"] = filter.From
+	}
+	if filter.To != nil {
+		details["to"] = filter.To
+	}
+	detailsJSON, _ := json.Marshal(details)
+
+	s.repo.CreateAuditLog(ctx, &models.AuditLog{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		UserID:         &adminID,
+		Action:         auditActionAuditLogExported,
+		ResourceType:   "organization",
+		ResourceID:     &orgID,
+		Details:        detailsJSON,
+		IPAddress:      sql.NullString{String: ipAddress, Valid: ipAddress != ""},
+		UserAgent:      sql.NullString{String: userAgent, Valid: userAgent != ""},
+		CreatedAt:      time.Now(),
+	})
+}
+
+func auditLogResponse(l *models.AuditLog) *models.AuditLogResponse {
+	return &models.AuditLogResponse{
+		ID:           l.ID,
+		UserID:       l.UserID,
+		Action:       l.Action,
+		ResourceType: l.ResourceType,
+		ResourceID:   l.ResourceID,
+		Details:      redactDetails(l.Details),
+		IPAddress:    l.IPAddress.String,
+		UserAgent:    l.UserAgent.String,
+		CreatedAt:    l.CreatedAt,
+	}
+}
+
+func auditLogCSVRecord(l *models.AuditLogResponse) []string {
+	record := []string{
+		l.ID.String(),
+		l.CreatedAt.UTC().Format(time.RFC3339),
+		uuidString(l.UserID),
+		l.Action,
+		l.ResourceType,
+		uuidString(l.ResourceID),
+		l.IPAddress,
+		l.UserAgent,
+		flattenDetails(l.Details),
+	}
+	for i, v := range record {
+		record[i] = csvSafe(v)
+	}
+	return record
+}
+
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// csvSafe stops spreadsheet applications from evaluating a cell as a formula.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// encodeAuditLogCursor returns the opaque cursor for the events after the given one.
+func encodeAuditLogCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeAuditLogCursor(cursor string) (*models.AuditLogCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidAuditLogCursor
+	}
+	nanos, idStr, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidAuditLogCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidAuditLogCursor
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ErrInvalidAuditLogCursor
+	}
+
+	return &models.AuditLogCursor{CreatedAt: time.Unix(0, n), ID: id}, nil
+}
+
+// Audit details are free-form, so secrets are recognized by the key they are
+// stored under or by their shape. Words are matched whole, except for the
+// longer ones that also appear compounded, as in "accesstoken".
+var (
+	sensitiveKeyWords = map[string]bool{
+		"code": true, "codes": true, "otp": true, "pin": true, "key": true,
+		"hash": true, "signature": true, "assertion": true, "jwt": true,
+	}
+	sensitiveKeyParts = []string{
+		"token", "secret", "password", "passwd", "credential",
+		"authorization", "cookie", "apikey",
+	}
+	keyWordSplit = regexp.MustCompile(`[^a-z0-9]+`)
+	jwtPattern   = regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+)
+
+// redactDetails replaces sensitive string values in audit event details.
+// Numbers and booleans are kept, so "remaining_backup_codes": 3 stays
+// readable. Details that are not valid JSON are redacted entirely.
+func redactDetails(details json.RawMessage) json.RawMessage {
+	if len(bytes.TrimSpace(details)) == 0 {
+		return nil
+	}
+
+	v, err := decodeDetails(details)
+	if err != nil {
+		return json.RawMessage(strconv.Quote(redactedValue))
+	}
+
+	redacted, err := json.Marshal(redactValue("", v))
+	if err != nil {
+		return json.RawMessage(strconv.Quote(redactedValue))
+	}
+	return redacted
+}
+
+func redactValue(key string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			val[k] = redactValue(k, child)
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = redactValue(key, child)
+		}
+	case string:
+		if isSensitiveKey(key) || looksLikeCredential(val) {
+			return redactedValue
+		}
+	}
+	return v
+}
+
+func isSensitiveKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	// Split camelCase keys before lowercasing
+	var b strings.Builder
+	for i, r := range key {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	lower := strings.ToLower(key)
+
+	for _, word := range keyWordSplit.Split(strings.ToLower(b.String()), -1) {
+		if sensitiveKeyWords[word] {
+			return true
+		}
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func looksLikeCredential(v string) bool {
+	lower := strings.ToLower(v)
+	return strings.HasPrefix(lower, "bearer ") ||
+		strings.HasPrefix(lower, "basic ") ||
+		jwtPattern.MatchString(v)
+}
+
+// flattenDetails renders details as "key=value" pairs sorted by key, with
+// nested keys joined by dots ("sso.provider=okta; tags.0=admin").
+func flattenDetails(details json.RawMessage) string {
+	if len(details) == 0 {
+		return ""
+	}
+
+	v, err := decodeDetails(details)
+	if err != nil {
+		return redactedValue
+	}
+
+	pairs := map[string]string{}
+	flattenValue("", v, pairs)
+	if len(pairs) == 1 {
+		if scalar, ok := pairs[""]; ok {
+			return scalar
+		}
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+pairs[k])
+	}
+	return strings.Join(parts, "; ")
+}
+
+func flattenValue(prefix string, v interface{}, pairs map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			flattenValue(join(k), child, pairs)
+		}
+	case []interface{}:
+		for i, child := range val {
+			flattenValue(join(strconv.Itoa(i)), child, pairs)
+		}
+	case nil:
+		pairs[prefix] = ""
+	case string:
+		pairs[prefix] = val
+	default:
+		pairs[prefix] = fmt.Sprint(val)
+	}
+}
+
+func decodeDetails(details json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(details))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
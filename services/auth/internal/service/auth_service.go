@@ -48,6 +48,7 @@ var (
 	ErrSSOUserNotAllowed        = errors.New("user is not allowed to access this organization")
 	ErrSSOStateInvalid          = errors.New("invalid or expired SSO state")
 	ErrSSOStateExpired          = errors.New("SSO state has expired")
+	ErrInvalidLogoutToken       = errors.New("invalid logout token")
 	ErrPermissionDenied         = errors.New("permission denied")
 	ErrSessionExpired           = errors.New("session has expired")
 	ErrSessionNotFound          = errors.New("session not found")
@@ -56,6 +57,7 @@ var (
 	ErrInvalidDomain            = errors.New("domain does not belong to your organization")
 	ErrDomainAccessDenied       = errors.New("you don't have access to this domain")
 	ErrTokenReuse               = errors.New("refresh token has already been used - possible token theft detected")
+	ErrBackupCodesAlreadyIssued = errors.New("backup codes have already been issued, regenerate them to get new codes")
 )
 
 // AuthService provides authentication operations.
@@ -64,15 +66,17 @@ type AuthService struct {
 	tokenService  *token.Service
 	config        *config.Config
 	emailService  *EmailService
+	avatarStorage *AvatarStorage
 }
 
 // NewAuthService creates a new AuthService.
 func NewAuthService(repo *repository.Repository, tokenService *token.Service, cfg *config.Config) *AuthService {
 	return &AuthService{
-		repo:         repo,
-		tokenService: tokenService,
-		config:       cfg,
-		emailService: NewEmailService(&cfg.Email),
+		repo:          repo,
+		tokenService:  tokenService,
+		config:        cfg,
+		emailService:  NewEmailService(&cfg.Email),
+		avatarStorage: NewAvatarStorage(cfg.Avatar.StorageURL),
 	}
 }
 
@@ -476,6 +480,8 @@ type LoginParams struct {
 	MFACode   string
 	IPAddress string
 	UserAgent string
+	// Location is the approximate client location, if known from the request
+	Location string
 }
 
 // LoginResult holds the result of user login.
@@ -485,6 +491,7 @@ type LoginResult struct {
 	Organization      *models.Organization
 	MFARequired       bool
 	MFAPendingToken   string
+	BackupCodesLow    bool
 }
 
 // Login authenticates a user.
@@ -551,12 +558,16 @@ func (s *AuthService) Login(ctx context.Context, params LoginParams) (*LoginResu
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(params.Password)); err != nil {
 		// Update failed login attempts
-		s.repo.UpdateUserLoginFailure(ctx, user.ID, s.config.Security.LockoutDuration, s.config.Security.MaxLoginAttempts)
+		locked, err := s.repo.UpdateUserLoginFailure(ctx, user.ID, s.config.Security.LockoutDuration, s.config.Security.MaxLoginAttempts)
 		s.recordLoginAttempt(ctx, &user.ID, params.Email, params.IPAddress, params.UserAgent, false, "invalid_password", "password")
+		if err == nil && locked {
+			s.handleAccountLocked(ctx, user, params)
+		}
 		return nil, ErrInvalidCredentials
 	}
 
 	// Check MFA
+	var backupCodesLow bool
 	if user.MFAEnabled {
 		if params.MFACode == "" {
 			// Return pending state - MFA required
@@ -568,11 +579,16 @@ func (s *AuthService) Login(ctx context.Context, params LoginParams) (*LoginResu
 			}, nil
 		}
 
-		// Verify MFA code
-		if !s.verifyMFACode(user, params.MFACode) {
+		// Verify MFA code or backup code
+		mfaResult, ok, err := s.verifyMFAOrBackupCode(ctx, user, params.MFACode, params.IPAddress, params.UserAgent)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
 			s.recordLoginAttempt(ctx, &user.ID, params.Email, params.IPAddress, params.UserAgent, false, "invalid_mfa", "mfa")
 			return nil, ErrMFAInvalidCode
 		}
+		backupCodesLow = mfaResult.used && mfaResult.remaining < backupCodesLowThreshold
 	}
 
 	// Get organization
@@ -601,9 +617,10 @@ func (s *AuthService) Login(ctx context.Context, params LoginParams) (*LoginResu
 	s.recordAuditLog(ctx, org.ID, &user.ID, "user.login", "session", nil, params.IPAddress, params.UserAgent, nil)
 
 	return &LoginResult{
-		User:         user,
-		TokenPair:    tokenPair,
-		Organization: org,
+		User:           user,
+		TokenPair:      tokenPair,
+		Organization:   org,
+		BackupCodesLow: backupCodesLow,
 	}, nil
 }
 
@@ -654,9 +671,10 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken, ipAddress,
 		// Token reuse detected! This is a security event.
 		// Revoke ALL user sessions to protect the account
 		s.repo.RevokeAllUserSessions(ctx, user.ID, nil)
+		refreshTokenFamilyRevocations.WithLabelValues(revocationReasonTokenReuse).Inc()
 
 		// Record security audit event
-		s.recordAuditLog(ctx, user.OrganizationID, &user.ID, "security.token_reuse_detected",
+		s.recordAuditLog(ctx, user.OrganizationID, &user.ID, auditActionTokenReuseDetected,
 			"session", &session.ID, ipAddress, userAgent, map[string]string{
 				"action": "all_sessions_revoked",
 				"reason": "refresh_token_reuse_detected",
@@ -1068,9 +1086,10 @@ func (s *AuthService) GetUserWithContext(ctx context.Context, userID uuid.UUID)
 		})
 	}
 
-	var avatarURL *string
-	if user.AvatarURL.Valid {
-		avatarURL = &user.AvatarURL.String
+	// Users without an avatar get the default identicon
+	avatarURL := s.avatarURL(user.ID, "")
+	if user.AvatarURL.Valid && user.AvatarURL.String != "" {
+		avatarURL = user.AvatarURL.String
 	}
 
 	return &models.UserResponse{
@@ -1079,7 +1098,7 @@ func (s *AuthService) GetUserWithContext(ctx context.Context, userID uuid.UUID)
 		DisplayName:    user.DisplayName,
 		Role:           user.Role,
 		Status:         user.Status,
-		AvatarURL:      avatarURL,
+		AvatarURL:      &avatarURL,
 		MFAEnabled:     user.MFAEnabled,
 		EmailAddresses: emailResponses,
 		Domains:        domainResponses,
@@ -1160,8 +1179,15 @@ type VerifyMFAParams struct {
 	UserAgent string
 }
 
+// MFAVerifyResult contains the tokens issued after MFA verification.
+type MFAVerifyResult struct {
+	*token.TokenPair
+	BackupCodesLow bool
+}
+
 // VerifyMFA completes MFA verification during login and returns tokens.
-func (s *AuthService) VerifyMFA(ctx context.Context, req *models.MFAVerifyRequest, ipAddress, userAgent string) (*token.TokenPair, error) {
+// The code may be a TOTP code or one of the user's backup codes.
+func (s *AuthService) VerifyMFA(ctx context.Context, req *models.MFAVerifyRequest, ipAddress, userAgent string) (*MFAVerifyResult, error) {
 	// Decode MFA pending token to get user ID
 	decoded, err := base64.URLEncoding.DecodeString(req.MFAToken)
 	if err != nil {
@@ -1191,8 +1217,12 @@ func (s *AuthService) VerifyMFA(ctx context.Context, req *models.MFAVerifyReques
 		return nil, ErrInvalidCredentials
 	}
 
-	// Verify MFA code
-	if !s.verifyMFACode(user, req.Code) {
+	// Verify MFA code or backup code
+	mfaResult, ok, err := s.verifyMFAOrBackupCode(ctx, user, req.Code, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
 		s.recordLoginAttempt(ctx, &user.ID, user.Email, ipAddress, userAgent, false, "invalid_mfa_code", "mfa")
 		return nil, ErrMFAInvalidCode
 	}
@@ -1216,7 +1246,10 @@ func (s *AuthService) VerifyMFA(ctx context.Context, req *models.MFAVerifyReques
 	// Record successful login
 	s.recordLoginAttempt(ctx, &user.ID, user.Email, ipAddress, userAgent, true, "", "mfa")
 
-	return tokenPair, nil
+	return &MFAVerifyResult{
+		TokenPair:      tokenPair,
+		BackupCodesLow: mfaResult.used && mfaResult.remaining < backupCodesLowThreshold,
+	}, nil
 }
 
 // ResendVerificationEmail resends a verification email for a user's email address.
@@ -1324,12 +1357,28 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID uuid.UUID, req
 		return err
 	}
 
+	// Enforce the organization's minimum password age and history
+	orgPolicy, err := s.orgPasswordPolicy(ctx, user.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if err := checkPasswordMinAge(user, orgPolicy, time.Now()); err != nil {
+		return err
+	}
+	if err := s.checkPasswordHistory(ctx, user, req.NewPassword, orgPolicy); err != nil {
+		return err
+	}
+
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
+	if err := s.recordPasswordHistory(ctx, user, orgPolicy); err != nil {
+		return err
+	}
+
 	// Update password
 	user.PasswordHash = sql.NullString{String: string(hashedPassword), Valid: true}
 	user.PasswordChangedAt = sql.NullTime{Time: time.Now(), Valid: true}
@@ -1393,12 +1442,26 @@ func (s *AuthService) ResetPassword(ctx context.Context, req *models.ResetPasswo
 		return err
 	}
 
+	// A reset is how a user recovers their account, so the minimum password
+	// age doesn't apply; the history does
+	orgPolicy, err := s.orgPasswordPolicy(ctx, user.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPasswordHistory(ctx, user, req.NewPassword, orgPolicy); err != nil {
+		return err
+	}
+
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
+	if err := s.recordPasswordHistory(ctx, user, orgPolicy); err != nil {
+		return err
+	}
+
 	// Update password
 	user.PasswordHash = sql.NullString{String: string(hashedPassword), Valid: true}
 	user.PasswordChangedAt = sql.NullTime{Time: time.Now(), Valid: true}
@@ -1501,7 +1564,8 @@ func (s *AuthService) DisableMFA(ctx context.Context, userID uuid.UUID, req *mod
 	return nil
 }
 
-// GetBackupCodes returns the MFA backup codes for the user.
+// GetBackupCodes issues the user's first set of MFA backup codes. Codes are
+// stored hashed, so once issued they can only be replaced by regenerating them.
 func (s *AuthService) GetBackupCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
 	user, err := s.repo.GetUserByID(ctx, userID)
 	if err != nil {
@@ -1512,20 +1576,15 @@ func (s *AuthService) GetBackupCodes(ctx context.Context, userID uuid.UUID) ([]s
 		return nil, ErrMFANotEnabled
 	}
 
-	if !user.MFABackupCodes.Valid || user.MFABackupCodes.String == "" {
-		// Generate new backup codes if none exist
-		codes := s.generateBackupCodes()
-		codesJSON, _ := json.Marshal(codes)
-		user.MFABackupCodes = sql.NullString{String: string(codesJSON), Valid: true}
-		if err := s.repo.UpdateUser(ctx, user); err != nil {
-			return nil, fmt.Errorf("failed to save backup codes: %w", err)
-		}
-		return codes, nil
+	if user.MFABackupCodes.Valid && user.MFABackupCodes.String != "" {
+		return nil, ErrBackupCodesAlreadyIssued
 	}
 
-	var codes []string
-	if err := json.Unmarshal([]byte(user.MFABackupCodes.String), &codes); err != nil {
-		return nil, fmt.Errorf("failed to parse backup codes: %w", err)
+	// Generate new backup codes if none exist
+	codes := s.generateBackupCodes()
+	user.MFABackupCodes = encodeBackupCodeHashes(codes)
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to save backup codes: %w", err)
 	}
 
 	return codes, nil
@@ -1552,8 +1611,7 @@ func (s *AuthService) RegenerateBackupCodes(ctx context.Context, userID uuid.UUI
 
 	// Generate new backup codes
 	codes := s.generateBackupCodes()
-	codesJSON, _ := json.Marshal(codes)
-	user.MFABackupCodes = sql.NullString{String: string(codesJSON), Valid: true}
+	user.MFABackupCodes = encodeBackupCodeHashes(codes)
 	if err := s.repo.UpdateUser(ctx, user); err != nil {
 		return nil, fmt.Errorf("failed to save backup codes: %w", err)
 	}
@@ -1563,8 +1621,8 @@ func (s *AuthService) RegenerateBackupCodes(ctx context.Context, userID uuid.UUI
 
 // generateBackupCodes generates a set of 10 backup codes.
 func (s *AuthService) generateBackupCodes() []string {
-	codes := make([]string, 10)
-	for i := 0; i < 10; i++ {
+	codes := make([]string, backupCodeCount)
+	for i := 0; i < backupCodeCount; i++ {
 		b := make([]byte, 5)
 		rand.Read(b)
 		codes[i] = fmt.Sprintf("%02x%02x%02x%02x%02x", b[0], b[1], b[2], b[3], b[4])
@@ -1721,6 +1779,45 @@ func (s *AuthService) verifyMFACode(user *models.User, code string) bool {
 	return valid
 }
 
+// handleAccountLocked records an audit entry for a new lockout and, unless the
+// organization has turned it off, emails the user's primary address. It is
+// only called when a failure starts a lockout, so further failures while the
+// account is locked don't send more mail.
+func (s *AuthService) handleAccountLocked(ctx context.Context, user *models.User, params LoginParams) {
+	lockedUntil := time.Now().Add(s.config.Security.LockoutDuration)
+
+	s.recordAuditLog(ctx, user.OrganizationID, &user.ID, "security.account_locked", "user", &user.ID, params.IPAddress, params.UserAgent, map[string]interface{}{
+		"failed_attempts": s.config.Security.MaxLoginAttempts,
+		"locked_until":    lockedUntil,
+		"location":        params.Location,
+	})
+
+	if s.emailService == nil {
+		return
+	}
+
+	org, err := s.repo.GetOrganizationByID(ctx, user.OrganizationID)
+	if err != nil || !org.Settings.AccountLockoutNotificationsEnabled() {
+		return
+	}
+
+	primaryEmail, err := s.repo.GetPrimaryEmailAddress(ctx, user.ID)
+	if err != nil {
+		return
+	}
+
+	resetToken := generateSecureToken()
+	if err := s.repo.CreatePasswordResetToken(ctx, user.ID, resetToken, time.Now().Add(1*time.Hour)); err != nil {
+		return
+	}
+
+	go func() {
+		if err := s.emailService.SendAccountLockedEmail(primaryEmail.EmailAddress, user.DisplayName, params.IPAddress, params.Location, lockedUntil, resetToken, s.config.Email.PasswordResetURL); err != nil {
+			fmt.Printf("Failed to send account locked email to %s: %v\n", primaryEmail.EmailAddress, err)
+		}
+	}()
+}
+
 func (s *AuthService) recordLoginAttempt(ctx context.Context, userID *uuid.UUID, email, ipAddress, userAgent string, success bool, failureReason, method string) {
 	attempt := &models.LoginAttempt{
 		ID:        uuid.New(),
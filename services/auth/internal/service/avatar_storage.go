@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errAvatarNotStored is returned by AvatarStorage.Get when the user has no
+// uploaded avatar
+var errAvatarNotStored = errors.New("avatar not stored")
+
+// AvatarStorage stores avatar images in the storage service.
+type AvatarStorage struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewAvatarStorage creates an AvatarStorage for the storage service at
+// baseURL. It returns nil when baseURL is empty.
+func NewAvatarStorage(baseURL string) *AvatarStorage {
+	if baseURL == "" {
+		return nil
+	}
+	return &AvatarStorage{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *AvatarStorage) url(orgID, userID uuid.UUID, variant string) string {
+	u := fmt.Sprintf("%s/api/v1/avatars/%s/%s", s.baseURL, orgID, userID)
+	if variant != "" {
+		u += "/" + url.PathEscape(variant)
+	}
+	return u
+}
+
+// Put stores one variant of a user's avatar.
+func (s *AvatarStorage) Put(ctx context.Context, orgID, userID uuid.UUID, variant, contentType string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(orgID, userID, variant), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("store avatar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("store avatar: storage service returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Get returns one variant of a user's avatar and its content type.
+func (s *AvatarStorage) Get(ctx context.Context, orgID, userID uuid.UUID, variant string, maxSize int) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(orgID, userID, variant), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("get avatar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", errAvatarNotStored
+	default:
+		return nil, "", fmt.Errorf("get avatar: storage service returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("get avatar: %w", err)
+	}
+	if len(data) > maxSize {
+		return nil, "", fmt.Errorf("get avatar: stored image exceeds %d bytes", maxSize)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// Delete removes every variant of a user's avatar.
+func (s *AvatarStorage) Delete(ctx context.Context, orgID, userID uuid.UUID) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.url(orgID, userID, ""), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete avatar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("delete avatar: storage service returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register GIF decoding
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"github.com/artpromedia/email/services/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Avatar errors
+var (
+	ErrAvatarTooLarge           = errors.New("avatar image is too large")
+	ErrUnsupportedAvatarType    = errors.New("avatar must be a PNG, JPEG or GIF image")
+	ErrInvalidAvatar            = errors.New("avatar image could not be decoded")
+	ErrAvatarStorageUnavailable = errors.New("avatar storage is not configured")
+)
+
+// Stored avatar variants
+const (
+	AvatarVariantFull      = "full"
+	AvatarVariantThumbnail = "thumbnail"
+)
+
+// identiconGrid is the number of cells across an identicon
+const identiconGrid = 5
+
+// avatarContentTypes are the accepted upload types, as sniffed from the data
+var avatarContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// AvatarImage is an avatar ready to serve. RedirectURL is set instead of Data
+// when the user's avatar is hosted elsewhere.
+type AvatarImage struct {
+	Data        []byte
+	ContentType string
+	RedirectURL string
+	Generated   bool // A default identicon
+}
+
+// MaxAvatarSize returns the largest accepted avatar upload in bytes.
+func (s *AuthService) MaxAvatarSize() int {
+	return s.config.Avatar.MaxSize
+}
+
+// UploadAvatar validates an uploaded image, stores it re-encoded along with a
+// square thumbnail, and makes it the user's avatar.
+func (s *AuthService) UploadAvatar(ctx context.Context, userID uuid.UUID, data []byte, ipAddress, userAgent string) (*models.UserResponse, error) {
+	if s.avatarStorage == nil {
+		return nil, ErrAvatarStorageUnavailable
+	}
+
+	img, contentType, err := decodeAvatar(data, s.config.Avatar.MaxSize, s.config.Avatar.MaxDimension)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Re-encoding drops metadata such as EXIF location. GIFs become static
+	// PNGs of their first frame.
+	full, fullType, err := encodeAvatar(img, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode avatar: %w", err)
+	}
+	var thumbnail bytes.Buffer
+	if err := png.Encode(&thumbnail, squareThumbnail(img, s.config.Avatar.ThumbnailSize)); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	if err := s.avatarStorage.Put(ctx, user.OrganizationID, userID, AvatarVariantFull, fullType, full); err != nil {
+		return nil, err
+	}
+	if err := s.avatarStorage.Put(ctx, user.OrganizationID, userID, AvatarVariantThumbnail, "image/png", thumbnail.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// The version changes the URL so clients don't show a cached old image
+	sum := sha256.Sum256(full)
+	user.AvatarURL = sql.NullString{String: s.avatarURL(userID, hex.EncodeToString(sum[:6])), Valid: true}
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	bounds := img.Bounds()
+	s.recordAuditLog(ctx, user.OrganizationID, &userID, "user.avatar_updated", "user", &userID, ipAddress, userAgent, map[string]interface{}{
+		"content_type": contentType,
+		"size":         len(data),
+		"width":        bounds.Dx(),
+		"height":       bounds.Dy(),
+	})
+
+	return s.GetUserWithContext(ctx, userID)
+}
+
+// DeleteAvatar removes the user's avatar, reverting to the default identicon.
+func (s *AuthService) DeleteAvatar(ctx context.Context, userID uuid.UUID, ipAddress, userAgent string) (*models.UserResponse, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if s.avatarStorage != nil {
+		if err := s.avatarStorage.Delete(ctx, user.OrganizationID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	hadAvatar := user.AvatarURL.Valid
+	user.AvatarURL = sql.NullString{}
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if hadAvatar {
+		s.recordAuditLog(ctx, user.OrganizationID, &userID, "user.avatar_deleted", "user", &userID, ipAddress, userAgent, nil)
+	}
+
+	return s.GetUserWithContext(ctx, userID)
+}
+
+// GetAvatar returns a user's avatar: the uploaded image in the requested
+// variant, a redirect to an externally hosted one, or a generated identicon
+// when none is set.
+func (s *AuthService) GetAvatar(ctx context.Context, userID uuid.UUID, variant string) (*AvatarImage, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.AvatarURL.Valid && user.AvatarURL.String != "" {
+		if !s.isStoredAvatar(userID, user.AvatarURL.String) {
+			return &AvatarImage{RedirectURL: user.AvatarURL.String}, nil
+		}
+		if s.avatarStorage != nil {
+			maxSize := s.config.Avatar.MaxSize * 2 // Re-encoding can grow the image
+			data, contentType, err := s.avatarStorage.Get(ctx, user.OrganizationID, userID, variant, maxSize)
+			if err == nil {
+				return &AvatarImage{Data: data, ContentType: contentType}, nil
+			}
+			if !errors.Is(err, errAvatarNotStored) {
+				return nil, err
+			}
+		}
+	}
+
+	data, err := identicon(userID.String(), s.config.Avatar.ThumbnailSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identicon: %w", err)
+	}
+	return &AvatarImage{Data: data, ContentType: "image/png", Generated: true}, nil
+}
+
+// avatarURL is the URL the avatar of userID is served at. version identifies
+// an uploaded image; it is empty for the default identicon.
+func (s *AuthService) avatarURL(userID uuid.UUID, version string) string {
+	url := fmt.Sprintf("%s/api/auth/users/%s/avatar", strings.TrimSuffix(s.config.Avatar.BaseURL, "/"), userID)
+	if version != "" {
+		url += "?v=" + version
+	}
+	return url
+}
+
+// isStoredAvatar reports whether avatarURL points at an image uploaded here
+// rather than one the user hosts elsewhere
+func (s *AuthService) isStoredAvatar(userID uuid.UUID, avatarURL string) bool {
+	return strings.HasPrefix(avatarURL, s.avatarURL(userID, "")+"?")
+}
+
+// decodeAvatar checks an upload's size, type and dimensions and decodes it.
+// The type is sniffed from the data rather than trusted from the client.
+func decodeAvatar(data []byte, maxSize, maxDimension int) (image.Image, string, error) {
+	if len(data) > maxSize {
+		return nil, "", ErrAvatarTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !avatarContentTypes[contentType] {
+		return nil, "", ErrUnsupportedAvatarType
+	}
+
+	// Check dimensions before decoding so a small file can't expand into a
+	// huge bitmap
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidAvatar
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, "", ErrInvalidAvatar
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, "", ErrAvatarTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidAvatar
+	}
+	return img, contentType, nil
+}
+
+// encodeAvatar re-encodes a decoded upload, as JPEG if it was one and as PNG
+// otherwise
+func encodeAvatar(img image.Image, contentType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// squareThumbnail crops the center square of img and scales it to size by
+// averaging the source pixels behind each thumbnail pixel
+func squareThumbnail(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	src := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(src, src.Bounds(), img, crop.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for dy := 0; dy < size; dy++ {
+		y0, y1 := dy*side/size, (dy+1)*side/size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < size; dx++ {
+			x0, x1 := dx*side/size, (dx+1)*side/size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					i := src.PixOffset(x, y)
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// identicon draws a PNG of a horizontally mirrored grid of cells whose
+// pattern and color are derived from seed
+func identicon(seed string, size int) ([]byte, error) {
+	sum := sha256.Sum256([]byte(seed))
+
+	foreground := hslColor(float64(sum[0])/255*360, 0.55, 0.5)
+	background := color.NRGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+
+	cell := size / (identiconGrid + 1)
+	margin := (size - cell*identiconGrid) / 2
+	half := (identiconGrid + 1) / 2
+	for row := 0; row < identiconGrid; row++ {
+		for col := 0; col < half; col++ {
+			if sum[1+row*half+col]&1 == 0 {
+				continue
+			}
+			for _, c := range []int{col, identiconGrid - 1 - col} {
+				rect := image.Rect(0, 0, cell, cell).Add(image.Pt(margin+c*cell, margin+row*cell))
+				draw.Draw(img, rect, &image.Uniform{C: foreground}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hslColor converts a hue in degrees and saturation and lightness in [0, 1]
+// to an opaque color
+func hslColor(h, s, l float64) color.NRGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	hp := h / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g = c, x
+	case hp < 2:
+		r, g = x, c
+	case hp < 3:
+		g, b = c, x
+	case hp < 4:
+		g, b = x, c
+	case hp < 5:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+
+	m := l - c/2
+	return color.NRGBA{
+		R: uint8((r + m) * 255),
+		G: uint8((g + m) * 255),
+		B: uint8((b + m) * 255),
+		A: 0xff,
+	}
+}
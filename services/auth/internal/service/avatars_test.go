@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeAvatar(t *testing.T) {
+	small := encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 40, 20)))
+
+	img, contentType, err := decodeAvatar(small, 1<<20, 100)
+	if err != nil {
+		t.Fatalf("decodeAvatar() error = %v", err)
+	}
+	if contentType != "image/png" || img.Bounds().Dx() != 40 {
+		t.Errorf("decodeAvatar() = %v, %q", img.Bounds(), contentType)
+	}
+
+	tests := []struct {
+		name         string
+		data         []byte
+		maxSize      int
+		maxDimension int
+		want         error
+	}{
+		{"too many bytes", small, len(small) - 1, 100, ErrAvatarTooLarge},
+		{"too many pixels", small, 1 << 20, 39, ErrAvatarTooLarge},
+		{"not an image", []byte("<html><body>hi</body></html>"), 1 << 20, 100, ErrUnsupportedAvatarType},
+		{"svg", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`), 1 << 20, 100, ErrUnsupportedAvatarType},
+		{"truncated png", small[:30], 1 << 20, 100, ErrInvalidAvatar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeAvatar(tt.data, tt.maxSize, tt.maxDimension); err != tt.want {
+				t.Errorf("decodeAvatar() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSquareThumbnail(t *testing.T) {
+	// A wide image with red sides and a blue center square
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.NRGBA{R: 0xff, A: 0xff}
+			if x >= 100 && x < 200 {
+				c = color.NRGBA{B: 0xff, A: 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	thumb := squareThumbnail(img, 32)
+	if thumb.Bounds().Dx() != 32 || thumb.Bounds().Dy() != 32 {
+		t.Fatalf("thumbnail bounds = %v, want 32x32", thumb.Bounds())
+	}
+	for _, p := range []image.Point{{0, 0}, {31, 31}, {16, 16}} {
+		if got := thumb.NRGBAAt(p.X, p.Y); got != (color.NRGBA{B: 0xff, A: 0xff}) {
+			t.Errorf("pixel %v = %v, want the cropped blue center", p, got)
+		}
+	}
+}
+
+func TestIdenticon(t *testing.T) {
+	a, err := identicon("user-a", 120)
+	if err != nil {
+		t.Fatalf("identicon() error = %v", err)
+	}
+	again, _ := identicon("user-a", 120)
+	b, _ := identicon("user-b", 120)
+
+	if !bytes.Equal(a, again) {
+		t.Error("identicon is not deterministic")
+	}
+	if bytes.Equal(a, b) {
+		t.Error("different seeds produced the same identicon")
+	}
+
+	img, err := png.Decode(bytes.NewReader(a))
+	if err != nil {
+		t.Fatalf("decode identicon: %v", err)
+	}
+	if img.Bounds().Dx() != 120 || img.Bounds().Dy() != 120 {
+		t.Fatalf("identicon bounds = %v, want 120x120", img.Bounds())
+	}
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 60; x++ {
+			if img.At(x, y) != img.At(119-x, y) {
+				t.Fatalf("identicon is not mirrored at (%d, %d)", x, y)
+			}
+		}
+	}
+}
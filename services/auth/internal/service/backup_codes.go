@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+)
+
+// auditActionBackupCodeUsed is the audit log action recorded when a backup code completes MFA.
+const auditActionBackupCodeUsed = "mfa.backup_code_used"
+
+const (
+	// backupCodeCount is the number of backup codes issued at a time.
+	backupCodeCount = 10
+	// backupCodesLowThreshold is the remaining count below which clients are warned to regenerate.
+	backupCodesLowThreshold = 3
+)
+
+// backupCodeResult describes the outcome of verifying an MFA code.
+type backupCodeResult struct {
+	used      bool
+	remaining int
+}
+
+// hashBackupCode returns the stored form of a backup code.
+func hashBackupCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeBackupCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeBackupCode strips the separators users commonly type and lowercases the code.
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// encodeBackupCodeHashes serializes hashed backup codes for the mfa_backup_codes column.
+func encodeBackupCodeHashes(codes []string) sql.NullString {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashBackupCode(code)
+	}
+	hashesJSON, _ := json.Marshal(hashes)
+	return sql.NullString{String: string(hashesJSON), Valid: true}
+}
+
+// decodeBackupCodeHashes parses the stored backup codes. Codes stored in
+// plaintext by earlier versions are hashed on read.
+func decodeBackupCodeHashes(stored sql.NullString) ([]string, error) {
+	if !stored.Valid || stored.String == "" {
+		return nil, nil
+	}
+
+	var hashes []string
+	if err := json.Unmarshal([]byte(stored.String), &hashes); err != nil {
+		return nil, fmt.Errorf("failed to parse backup codes: %w", err)
+	}
+	for i, h := range hashes {
+		if len(h) != sha256.Size*2 {
+			hashes[i] = hashBackupCode(h)
+		}
+	}
+	return hashes, nil
+}
+
+// removeBackupCode returns hashes without the entry matching code. Every entry
+// is compared in constant time so the position of a match isn't leaked.
+func removeBackupCode(hashes []string, code string) ([]string, bool) {
+	candidate := []byte(hashBackupCode(code))
+	match := -1
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), candidate) == 1 && match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return hashes, false
+	}
+
+	remaining := make([]string, 0, len(hashes)-1)
+	remaining = append(remaining, hashes[:match]...)
+	remaining = append(remaining, hashes[match+1:]...)
+	return remaining, true
+}
+
+// useBackupCode consumes code if it matches one of the user's backup codes.
+// The update only applies if the stored codes are unchanged, so concurrent
+// logins can't redeem the same code twice.
+func (s *AuthService) useBackupCode(ctx context.Context, user *models.User, code string) (backupCodeResult, error) {
+	hashes, err := decodeBackupCodeHashes(user.MFABackupCodes)
+	if err != nil || len(hashes) == 0 {
+		return backupCodeResult{}, err
+	}
+
+	remaining, ok := removeBackupCode(hashes, code)
+	if !ok {
+		return backupCodeResult{}, nil
+	}
+
+	remainingJSON, _ := json.Marshal(remaining)
+	updated := sql.NullString{String: string(remainingJSON), Valid: true}
+	consumed, err := s.repo.ReplaceMFABackupCodes(ctx, user.ID, user.MFABackupCodes, updated)
+	if err != nil {
+		return backupCodeResult{}, fmt.Errorf("failed to consume backup code: %w", err)
+	}
+	if !consumed {
+		return backupCodeResult{}, nil
+	}
+	user.MFABackupCodes = updated
+
+	return backupCodeResult{used: true, remaining: len(remaining)}, nil
+}
+
+// verifyMFAOrBackupCode accepts either a TOTP code or an unused backup code.
+// A backup code that completes login is consumed and audited.
+func (s *AuthService) verifyMFAOrBackupCode(ctx context.Context, user *models.User, code, ipAddress, userAgent string) (backupCodeResult, bool, error) {
+	if s.verifyMFACode(user, code) {
+		return backupCodeResult{}, true, nil
+	}
+
+	result, err := s.useBackupCode(ctx, user, code)
+	if err != nil || !result.used {
+		return result, false, err
+	}
+
+	s.recordAuditLog(ctx, user.OrganizationID, &user.ID, auditActionBackupCodeUsed, "user", &user.ID, ipAddress, userAgent, map[string]interface{}{
+		"remaining_backup_codes": result.remaining,
+	})
+
+	return result, true, nil
+}
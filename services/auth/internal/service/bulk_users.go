@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"github.com/artpromedia/email/services/auth/internal/repository"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Bulk provisioning record statuses.
+const (
+	bulkStatusCreated = "created"
+	bulkStatusUpdated = "updated"
+	bulkStatusFailed  = "failed"
+)
+
+// BulkProvisionUsers creates a user with a mailbox for every record, each in
+// its own transaction, so one bad record doesn't fail the rest. With upsert,
+// existing users in the organization get the record's display name and role
+// instead of failing as duplicates. New users have no password; they sign in
+// through SSO or after an admin password reset.
+//
+// If progress is set it is called with each result as it completes, and the
+// results are left out of the returned summary.
+func (s *AdminService) BulkProvisionUsers(ctx context.Context, orgID uuid.UUID, req *models.BulkProvisionUsersRequest, progress func(*models.BulkUserResult)) (*models.BulkProvisionUsersResponse, error) {
+	org, err := s.repo.GetOrganizationByID(ctx, orgID)
+	if err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	resp := &models.BulkProvisionUsersResponse{Total: len(req.Users)}
+	domains := make(map[string]*models.Domain)
+
+	for i := range req.Users {
+		if err := ctx.Err(); err != nil {
+			return resp, err
+		}
+
+		result := s.provisionUser(ctx, org, domains, &req.Users[i], req.Upsert)
+		result.Index = i
+
+		switch result.Status {
+		case bulkStatusCreated:
+			resp.Created++
+		case bulkStatusUpdated:
+			resp.Updated++
+		default:
+			resp.Failed++
+		}
+
+		if progress != nil {
+			progress(result)
+		} else {
+			resp.Results = append(resp.Results, result)
+		}
+	}
+
+	log.Info().
+		Str("organization_id", orgID.String()).
+		Int("total", resp.Total).
+		Int("created", resp.Created).
+		Int("updated", resp.Updated).
+		Int("failed", resp.Failed).
+		Msg("Bulk user provisioning completed")
+
+	return resp, nil
+}
+
+// provisionUser creates or, with upsert, updates the user for one record.
+// domains caches the domains looked up so far by name.
+func (s *AdminService) provisionUser(ctx context.Context, org *models.Organization, domains map[string]*models.Domain, record *models.BulkUserRecord, upsert bool) *models.BulkUserResult {
+	email := strings.ToLower(strings.TrimSpace(record.Email))
+	result := &models.BulkUserResult{Email: email}
+	fail := func(format string, args ...any) *models.BulkUserResult {
+		result.Status = bulkStatusFailed
+		result.Error = fmt.Sprintf(format, args...)
+		return result
+	}
+
+	localPart, domainName, ok := strings.Cut(email, "@")
+	if !ok || localPart == "" || domainName == "" || strings.ContainsAny(domainName, "@ ") {
+		return fail("invalid email address")
+	}
+
+	displayName := strings.TrimSpace(record.DisplayName)
+	if displayName == "" || len(displayName) > 255 {
+		return fail("display_name must be between 1 and 255 characters")
+	}
+
+	role := record.Role
+	if role == "" {
+		role = "member"
+	}
+	if !isValidRole(role) || role == "owner" {
+		return fail("invalid role %q", role)
+	}
+
+	domain, cached := domains[domainName]
+	if !cached {
+		var err error
+		domain, err = s.repo.GetDomainByName(ctx, domainName)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return fail("failed to look up domain")
+		}
+		domains[domainName] = domain
+	}
+	if domain == nil || domain.OrganizationID != org.ID {
+		return fail("domain %s does not belong to this organization", domainName)
+	}
+	if !domain.IsVerified {
+		return fail("domain %s is not verified", domainName)
+	}
+
+	existing, err := s.repo.GetUserByEmail(ctx, email)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		user, err := s.createMailboxUser(ctx, org, domain, email, displayName, role, sql.NullString{})
+		if errors.Is(err, ErrEmailExists) {
+			return fail("email address already exists")
+		}
+		if err != nil {
+			log.Error().Err(err).Str("email", email).Msg("Bulk provisioning failed to create user")
+			return fail("failed to create user")
+		}
+		result.Status = bulkStatusCreated
+		result.UserID = &user.ID
+
+	case err != nil:
+		return fail("failed to look up user")
+
+	case !upsert:
+		return fail("email address already exists")
+
+	case existing.OrganizationID != org.ID:
+		return fail("email address belongs to another organization")
+
+	default:
+		existing.DisplayName = displayName
+		existing.Role = role
+		if err := s.repo.UpdateUser(ctx, existing); err != nil {
+			log.Error().Err(err).Str("email", email).Msg("Bulk provisioning failed to update user")
+			return fail("failed to update user")
+		}
+		result.Status = bulkStatusUpdated
+		result.UserID = &existing.ID
+	}
+
+	return result
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestProvisionUserRejectsInvalidRecords(t *testing.T) {
+	s := &AdminService{}
+	org := &models.Organization{ID: uuid.New()}
+
+	tests := []struct {
+		name   string
+		record models.BulkUserRecord
+		want   string
+	}{
+		{"missing at sign", models.BulkUserRecord{Email: "alice.example.com", DisplayName: "Alice"}, "invalid email address"},
+		{"empty local part", models.BulkUserRecord{Email: "@example.com", DisplayName: "Alice"}, "invalid email address"},
+		{"two at signs", models.BulkUserRecord{Email: "a@b@example.com", DisplayName: "Alice"}, "invalid email address"},
+		{"blank display name", models.BulkUserRecord{Email: "alice@example.com", DisplayName: "  "}, "display_name"},
+		{"long display name", models.BulkUserRecord{Email: "alice@example.com", DisplayName: strings.Repeat("a", 256)}, "display_name"},
+		{"unknown role", models.BulkUserRecord{Email: "alice@example.com", DisplayName: "Alice", Role: "superuser"}, "invalid role"},
+		{"owner role", models.BulkUserRecord{Email: "alice@example.com", DisplayName: "Alice", Role: "owner"}, "invalid role"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := s.provisionUser(context.Background(), org, map[string]*models.Domain{}, &tt.record, false)
+			if result.Status != bulkStatusFailed {
+				t.Fatalf("Status = %q, want %q", result.Status, bulkStatusFailed)
+			}
+			if !strings.Contains(result.Error, tt.want) {
+				t.Errorf("Error = %q, want it to mention %q", result.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisionUserChecksDomainOwnership(t *testing.T) {
+	s := &AdminService{}
+	org := &models.Organization{ID: uuid.New()}
+	domains := map[string]*models.Domain{
+		"other.example":      {OrganizationID: uuid.New(), DomainName: "other.example", IsVerified: true},
+		"unverified.example": {OrganizationID: org.ID, DomainName: "unverified.example"},
+		"unknown.example":    nil,
+	}
+
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"alice@other.example", "does not belong to this organization"},
+		{"alice@unknown.example", "does not belong to this organization"},
+		{"Alice@Unverified.Example", "domain unverified.example is not verified"},
+	}
+
+	for _, tt := range tests {
+		record := &models.BulkUserRecord{Email: tt.email, DisplayName: "Alice"}
+		result := s.provisionUser(context.Background(), org, domains, record, true)
+		if result.Status != bulkStatusFailed || !strings.Contains(result.Error, tt.want) {
+			t.Errorf("provisionUser(%s) = %q %q, want failure mentioning %q", tt.email, result.Status, result.Error, tt.want)
+		}
+	}
+}
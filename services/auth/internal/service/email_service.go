@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"fmt"
+	"html"
 	"net/smtp"
 	"strings"
 	"time"
@@ -51,6 +52,9 @@ func (s *EmailService) Send(params EmailParams) error {
 	headers["Message-ID"] = msgID
 	headers["MIME-Version"] = "1.0"
 	headers["Content-Type"] = "text/html; charset=UTF-8"
+	// Account mail (verification, password resets) must not wait behind
+	// bulk sends in the outbound queue
+	headers["X-Queue-Priority"] = "high"
 
 	// Build message
 	var msg bytes.Buffer
@@ -302,6 +306,56 @@ func (s *EmailService) SendWelcomeEmail(to, displayName, orgName string) error {
 	})
 }
 
+// SendAccountLockedEmail notifies a user that their account was locked after
+// repeated failed sign-in attempts. location may be empty if it couldn't be
+// determined from the request.
+func (s *EmailService) SendAccountLockedEmail(to, displayName, ipAddress, location string, lockedUntil time.Time, resetToken, resetURL string) error {
+	fullResetURL := fmt.Sprintf("%s?token=%s", resetURL, resetToken)
+
+	source := html.EscapeString(ipAddress)
+	if location != "" {
+		source = fmt.Sprintf("%s (%s)", source, html.EscapeString(location))
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Account Locked</title>
+</head>
+<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333; max-width: 600px; margin: 0 auto; padding: 20px;">
+    <div style="background: linear-gradient(135deg, #f7971e 0%%, #e53935 100%%); padding: 30px; text-align: center; border-radius: 10px 10px 0 0;">
+        <h1 style="color: white; margin: 0;">Account Locked</h1>
+    </div>
+    <div style="background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px;">
+        <p>Hi %s,</p>
+        <p>Your account has been temporarily locked after too many failed sign-in attempts.</p>
+        <table style="margin: 20px 0; font-size: 14px;">
+            <tr><td style="color: #666; padding-right: 15px;">Source</td><td>%s</td></tr>
+            <tr><td style="color: #666; padding-right: 15px;">Locked until</td><td>%s</td></tr>
+        </table>
+        <p>If this was you, you can sign in again once the lockout expires. If you didn't try to sign in, someone may be trying to guess your password and you should reset it now:</p>
+        <div style="text-align: center; margin: 30px 0;">
+            <a href="%s" style="background: #e53935; color: white; padding: 14px 30px; text-decoration: none; border-radius: 5px; display: inline-block; font-weight: bold;">Reset Password</a>
+        </div>
+        <p>Or copy and paste this link into your browser:</p>
+        <p style="background: #e9e9e9; padding: 10px; border-radius: 5px; word-break: break-all; font-size: 14px;">%s</p>
+        <p style="color: #666; font-size: 14px;">This link will expire in 1 hour.</p>
+        <hr style="border: none; border-top: 1px solid #ddd; margin: 30px 0;">
+        <p style="color: #999; font-size: 12px;">This is an automated security notification. Please do not reply directly to this email.</p>
+    </div>
+</body>
+</html>
+`, html.EscapeString(displayName), source, lockedUntil.UTC().Format("Jan 2, 2006 15:04 MST"), fullResetURL, fullResetURL)
+
+	return s.Send(EmailParams{
+		To:       []string{to},
+		Subject:  "Your Account Has Been Locked",
+		HTMLBody: htmlBody,
+	})
+}
+
 // generateMessageID creates a unique Message-ID for email headers.
 func generateMessageID(fromAddress string) string {
 	b := make([]byte, 16)
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// auditActionTokenReuseDetected is the audit log action recorded on refresh token reuse.
+const auditActionTokenReuseDetected = "security.token_reuse_detected"
+
+// Reasons for revoking every session of a user.
+const (
+	revocationReasonTokenReuse = "token_reuse"
+	revocationReasonAdmin      = "admin"
+)
+
+// refreshTokenFamilyRevocations counts how often all of a user's sessions
+// (and with them every refresh token in the family) were revoked.
+var refreshTokenFamilyRevocations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_refresh_token_family_revocations_total",
+	Help: "Number of times all sessions of a user were revoked, by reason.",
+}, []string{"reason"})
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"github.com/artpromedia/email/services/auth/internal/repository"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// backchannelLogoutEvent is the events member identifying an OIDC
+// back-channel logout token.
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// Logout token jti values are remembered this long to reject replays
+const logoutTokenJTIKeyPrefix = "sso:logout_jti:"
+const logoutTokenJTITTL = 10 * time.Minute
+
+// logoutTokenMaxAge bounds how old a logout token's iat may be
+const logoutTokenMaxAge = 5 * time.Minute
+
+// jwksCacheTTL is how long an IdP's signing keys are reused before refetching.
+// An unknown kid triggers an earlier refetch, at most once per jwksMinRefetch.
+const jwksCacheTTL = time.Hour
+const jwksMinRefetch = time.Minute
+
+// logoutTokenClaims are the claims of a validated logout token
+type logoutTokenClaims struct {
+	Subject   string
+	SessionID string
+	JTI       string
+}
+
+// HandleBackchannelLogout validates an OIDC back-channel logout token sent by
+// the domain's IdP and revokes the sessions of the user it names. Tokens
+// carrying a sid end the sessions created under that IdP session; tokens
+// with only a sub end every session of that user.
+func (s *SSOService) HandleBackchannelLogout(ctx context.Context, domainID uuid.UUID, logoutToken string) error {
+	domain, err := s.repo.GetDomainByID(ctx, domainID)
+	if err != nil {
+		return fmt.Errorf("failed to get domain: %w", err)
+	}
+
+	ssoConfig, err := s.repo.GetSSOConfigByDomainID(ctx, domainID)
+	if err != nil {
+		return ErrSSONotConfigured
+	}
+	if ssoConfig.Provider != "oidc" || ssoConfig.OIDCConfig == nil {
+		return ErrSSONotConfigured
+	}
+
+	claims, err := parseLogoutToken(logoutToken, ssoConfig.OIDCConfig, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return s.oidcSigningKey(ctx, ssoConfig.OIDCConfig, kid)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Each logout token is accepted once
+	if claims.JTI != "" {
+		ok, err := s.redis.SetNX(ctx, logoutTokenJTIKeyPrefix+domainID.String()+":"+claims.JTI, 1, logoutTokenJTITTL).Result()
+		if err != nil {
+			return fmt.Errorf("failed to record logout token: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: token already used", ErrInvalidLogoutToken)
+		}
+	}
+
+	userIDs, err := s.logoutTokenUsers(ctx, domainID, claims)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := s.repo.RevokeAllUserSessions(ctx, userID, nil); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+		s.authService.recordAuditLog(ctx, domain.OrganizationID, &userID, "user.sso_logout", "session", nil, "", "", map[string]string{
+			"method": "oidc_backchannel",
+		})
+	}
+
+	return nil
+}
+
+// logoutTokenUsers resolves the users a logout token refers to. When both sid
+// and sub are present the sessions must belong to the sub's user.
+func (s *SSOService) logoutTokenUsers(ctx context.Context, domainID uuid.UUID, claims *logoutTokenClaims) ([]uuid.UUID, error) {
+	var subjectUserID *uuid.UUID
+	if claims.Subject != "" {
+		identity, err := s.repo.GetSSOIdentity(ctx, domainID, claims.Subject)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("failed to find SSO identity: %w", err)
+		}
+		if identity != nil {
+			subjectUserID = &identity.UserID
+		}
+	}
+
+	if claims.SessionID == "" {
+		if subjectUserID == nil {
+			return nil, nil
+		}
+		return []uuid.UUID{*subjectUserID}, nil
+	}
+
+	userIDs, err := s.repo.GetUserIDsBySSOSID(ctx, domainID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return userIDs, nil
+	}
+	for _, userID := range userIDs {
+		if subjectUserID != nil && userID == *subjectUserID {
+			return []uuid.UUID{userID}, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseLogoutToken verifies a logout token's signature and validates its
+// claims against the OIDC configuration, per OpenID Connect Back-Channel
+// Logout 1.0 section 2.6.
+func parseLogoutToken(raw string, cfg *models.OIDCConfig, keyFunc jwt.Keyfunc) (*logoutTokenClaims, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("%w: missing token", ErrInvalidLogoutToken)
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	mapClaims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(raw, mapClaims, keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogoutToken, err)
+	}
+
+	iat, err := mapClaims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return nil, fmt.Errorf("%w: missing iat", ErrInvalidLogoutToken)
+	}
+	if time.Since(iat.Time) > logoutTokenMaxAge {
+		return nil, fmt.Errorf("%w: token too old", ErrInvalidLogoutToken)
+	}
+
+	events, ok := mapClaims["events"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: missing events", ErrInvalidLogoutToken)
+	}
+	if _, ok := events[backchannelLogoutEvent].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("%w: not a back-channel logout event", ErrInvalidLogoutToken)
+	}
+
+	// A nonce would let a logout token be passed off as an ID token
+	if _, ok := mapClaims["nonce"]; ok {
+		return nil, fmt.Errorf("%w: nonce not allowed", ErrInvalidLogoutToken)
+	}
+
+	claims := &logoutTokenClaims{}
+	if v, ok := mapClaims["sub"]; ok {
+		if claims.Subject, ok = v.(string); !ok {
+			return nil, fmt.Errorf("%w: invalid sub", ErrInvalidLogoutToken)
+		}
+	}
+	if v, ok := mapClaims["sid"]; ok {
+		if claims.SessionID, ok = v.(string); !ok {
+			return nil, fmt.Errorf("%w: invalid sid", ErrInvalidLogoutToken)
+		}
+	}
+	if claims.Subject == "" && claims.SessionID == "" {
+		return nil, fmt.Errorf("%w: sub or sid required", ErrInvalidLogoutToken)
+	}
+	claims.JTI, _ = mapClaims["jti"].(string)
+
+	return claims, nil
+}
+
+// ============================================================
+// IdP SIGNING KEYS
+// ============================================================
+
+// jwksCache holds the signing keys fetched from each IdP's JWKS endpoint.
+type jwksCache struct {
+	mu         sync.Mutex
+	client     *http.Client
+	entries    map[string]*jwksEntry
+	discovered map[string]string // issuer to jwks_uri
+}
+
+type jwksEntry struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+var oidcKeys = &jwksCache{
+	client:     &http.Client{Timeout: 10 * time.Second},
+	entries:    make(map[string]*jwksEntry),
+	discovered: make(map[string]string),
+}
+
+// oidcSigningKey returns the IdP key with the given kid. Keys are refetched
+// when the kid is unknown, so IdP key rotation is picked up.
+func (s *SSOService) oidcSigningKey(ctx context.Context, cfg *models.OIDCConfig, kid string) (interface{}, error) {
+	jwksURL, err := oidcKeys.jwksURL(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcKeys.mu.Lock()
+	entry := oidcKeys.entries[jwksURL]
+	oidcKeys.mu.Unlock()
+
+	stale := entry == nil || time.Since(entry.fetchedAt) > jwksCacheTTL ||
+		lookupKey(entry.keys, kid) == nil && time.Since(entry.fetchedAt) > jwksMinRefetch
+	if stale {
+		keys, err := oidcKeys.fetch(ctx, jwksURL)
+		if err != nil {
+			return nil, err
+		}
+		entry = &jwksEntry{keys: keys, fetchedAt: time.Now()}
+		oidcKeys.mu.Lock()
+		oidcKeys.entries[jwksURL] = entry
+		oidcKeys.mu.Unlock()
+	}
+
+	key := lookupKey(entry.keys, kid)
+	if key == nil {
+		return nil, fmt.Errorf("no signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookupKey finds a key by kid; without a kid the IdP must publish exactly one key
+func lookupKey(keys map[string]interface{}, kid string) interface{} {
+	if kid != "" {
+		return keys[kid]
+	}
+	if len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+// jwksURL returns the configured JWKS URL, or the one advertised in the
+// issuer's discovery document.
+func (c *jwksCache) jwksURL(ctx context.Context, cfg *models.OIDCConfig) (string, error) {
+	if cfg.JWKSURL != nil && *cfg.JWKSURL != "" {
+		return *cfg.JWKSURL, nil
+	}
+
+	c.mu.Lock()
+	url, ok := c.discovered[cfg.Issuer]
+	c.mu.Unlock()
+	if ok {
+		return url, nil
+	}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := c.getJSON(ctx, strings.TrimSuffix(cfg.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return "", err
+	}
+	if discovery.JWKSURI == "" {
+		return "", fmt.Errorf("%w: no jwks_uri in discovery document", ErrSSOProviderError)
+	}
+
+	c.mu.Lock()
+	c.discovered[cfg.Issuer] = discovery.JWKSURI
+	c.mu.Unlock()
+	return discovery.JWKSURI, nil
+}
+
+func (c *jwksCache) fetch(ctx context.Context, url string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := c.getJSON(ctx, url, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+	for _, raw := range set.Keys {
+		kid, key, err := parseJWK(raw)
+		if err != nil {
+			// Skip keys we can't use, such as encryption keys
+			continue
+		}
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+func (c *jwksCache) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSSOProviderError, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSSOProviderError, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %d", ErrSSOProviderError, url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrSSOProviderError, err)
+	}
+	return nil
+}
+
+// parseJWK converts an RSA or EC signing key from JWK form
+func parseJWK(raw json.RawMessage) (string, interface{}, error) {
+	var jwk struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return "", nil, errors.New("not a signing key")
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return "", nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return "", nil, errors.New("invalid RSA exponent")
+		}
+		return jwk.Kid, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return "", nil, errors.New("EC point not on curve")
+		}
+		return jwk.Kid, key, nil
+	}
+	return "", nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseLogoutToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	cfg := &models.OIDCConfig{Issuer: "https://idp.example.com", ClientID: "mail-client"}
+	keyFunc := func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil }
+
+	baseClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":    cfg.Issuer,
+			"aud":    cfg.ClientID,
+			"iat":    time.Now().Unix(),
+			"jti":    "logout-1",
+			"sub":    "user-123",
+			"sid":    "session-abc",
+			"events": map[string]interface{}{backchannelLogoutEvent: map[string]interface{}{}},
+		}
+	}
+	sign := func(claims jwt.MapClaims, signer *rsa.PrivateKey) string {
+		raw, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(signer)
+		if err != nil {
+			t.Fatalf("SignedString() error = %v", err)
+		}
+		return raw
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		claims, err := parseLogoutToken(sign(baseClaims(), key), cfg, keyFunc)
+		if err != nil {
+			t.Fatalf("parseLogoutToken() error = %v", err)
+		}
+		if claims.Subject != "user-123" || claims.SessionID != "session-abc" || claims.JTI != "logout-1" {
+			t.Errorf("claims = %+v", claims)
+		}
+	})
+
+	t.Run("sub without sid", func(t *testing.T) {
+		c := baseClaims()
+		delete(c, "sid")
+		claims, err := parseLogoutToken(sign(c, key), cfg, keyFunc)
+		if err != nil {
+			t.Fatalf("parseLogoutToken() error = %v", err)
+		}
+		if claims.Subject != "user-123" || claims.SessionID != "" {
+			t.Errorf("claims = %+v", claims)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		signer *rsa.PrivateKey
+	}{
+		{"nonce present", func(c jwt.MapClaims) { c["nonce"] = "n-1" }, key},
+		{"wrong signature", func(jwt.MapClaims) {}, otherKey},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, key},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other-client" }, key},
+		{"missing iat", func(c jwt.MapClaims) { delete(c, "iat") }, key},
+		{"stale iat", func(c jwt.MapClaims) { c["iat"] = time.Now().Add(-time.Hour).Unix() }, key},
+		{"missing events", func(c jwt.MapClaims) { delete(c, "events") }, key},
+		{"other event", func(c jwt.MapClaims) {
+			c["events"] = map[string]interface{}{"http://example.com/event": map[string]interface{}{}}
+		}, key},
+		{"no sub or sid", func(c jwt.MapClaims) { delete(c, "sub"); delete(c, "sid") }, key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := baseClaims()
+			tt.modify(c)
+			_, err := parseLogoutToken(sign(c, tt.signer), cfg, keyFunc)
+			if !errors.Is(err, ErrInvalidLogoutToken) {
+				t.Errorf("parseLogoutToken() error = %v, want ErrInvalidLogoutToken", err)
+			}
+		})
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		raw, err := jwt.NewWithClaims(jwt.SigningMethodNone, baseClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatalf("SignedString() error = %v", err)
+		}
+		if _, err := parseLogoutToken(raw, cfg, keyFunc); !errors.Is(err, ErrInvalidLogoutToken) {
+			t.Errorf("parseLogoutToken() error = %v, want ErrInvalidLogoutToken", err)
+		}
+	})
+}
+
+func TestParseJWK(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	raw, _ := json.Marshal(map[string]string{
+		"kid": "key-1",
+		"kty": "RSA",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	})
+	kid, parsed, err := parseJWK(raw)
+	if err != nil {
+		t.Fatalf("parseJWK() error = %v", err)
+	}
+	if kid != "key-1" || !key.PublicKey.Equal(parsed) {
+		t.Errorf("parseJWK() = %q, %v", kid, parsed)
+	}
+
+	enc, _ := json.Marshal(map[string]string{"kid": "key-2", "kty": "RSA", "use": "enc"})
+	if _, _, err := parseJWK(enc); err == nil {
+		t.Error("parseJWK() accepted an encryption key")
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxPasswordHistory caps the policy's history count. Checking a new
+// password takes one bcrypt comparison per remembered password, about 50-100ms
+// each at the default cost, so a password change with the full history costs
+// up to a second of CPU.
+const MaxPasswordHistory = 10
+
+// Password history errors
+var (
+	ErrPasswordReused        = errors.New("password was used recently and cannot be reused")
+	ErrPasswordChangeTooSoon = errors.New("password was changed too recently")
+)
+
+// passwordHistoryCount returns how many recent passwords, the current one
+// included, a new password must differ from under the policy
+func passwordHistoryCount(policy models.PasswordPolicy) int {
+	switch {
+	case policy.HistoryCount < 0:
+		return 0
+	case policy.HistoryCount > MaxPasswordHistory:
+		return MaxPasswordHistory
+	}
+	return policy.HistoryCount
+}
+
+// orgPasswordPolicy returns the password policy of the user's organization
+func (s *AuthService) orgPasswordPolicy(ctx context.Context, orgID uuid.UUID) (models.PasswordPolicy, error) {
+	org, err := s.repo.GetOrganizationByID(ctx, orgID)
+	if err != nil {
+		return models.PasswordPolicy{}, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return org.Settings.PasswordPolicy, nil
+}
+
+// checkPasswordMinAge returns ErrPasswordChangeTooSoon while the user's
+// password is younger than the policy's minimum age
+func checkPasswordMinAge(user *models.User, policy models.PasswordPolicy, now time.Time) error {
+	if policy.MinAgeDays <= 0 || !user.PasswordChangedAt.Valid {
+		return nil
+	}
+	minAge := time.Duration(policy.MinAgeDays) * 24 * time.Hour
+	if now.Sub(user.PasswordChangedAt.Time) < minAge {
+		return ErrPasswordChangeTooSoon
+	}
+	return nil
+}
+
+// checkPasswordHistory returns ErrPasswordReused when password matches the
+// user's current password or one of the previous ones the policy remembers
+func (s *AuthService) checkPasswordHistory(ctx context.Context, user *models.User, password string, policy models.PasswordPolicy) error {
+	count := passwordHistoryCount(policy)
+	if count == 0 {
+		return nil
+	}
+
+	var hashes []string
+	if user.PasswordHash.Valid {
+		hashes = append(hashes, user.PasswordHash.String)
+	}
+	if count > 1 {
+		previous, err := s.repo.GetPasswordHistory(ctx, user.ID, count-1)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, previous...)
+	}
+
+	if matchesAnyHash(password, hashes) {
+		return ErrPasswordReused
+	}
+	return nil
+}
+
+// matchesAnyHash reports whether password matches one of the bcrypt hashes
+func matchesAnyHash(password string, hashes []string) bool {
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// recordPasswordHistory keeps the user's current password hash, which is
+// being replaced, and prunes the history to what the policy remembers
+func (s *AuthService) recordPasswordHistory(ctx context.Context, user *models.User, policy models.PasswordPolicy) error {
+	if !user.PasswordHash.Valid {
+		return nil
+	}
+	keep := passwordHistoryCount(policy) - 1
+	if keep < 0 {
+		keep = 0
+	}
+	return s.repo.AddPasswordHistory(ctx, user.ID, user.PasswordHash.String, keep)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHistoryCount(t *testing.T) {
+	tests := []struct {
+		historyCount int
+		want         int
+	}{
+		{0, 0},
+		{-3, 0},
+		{5, 5},
+		{MaxPasswordHistory, MaxPasswordHistory},
+		{100, MaxPasswordHistory},
+	}
+	for _, tt := range tests {
+		if got := passwordHistoryCount(models.PasswordPolicy{HistoryCount: tt.historyCount}); got != tt.want {
+			t.Errorf("passwordHistoryCount(%d) = %d, want %d", tt.historyCount, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPasswordMinAge(t *testing.T) {
+	now := time.Now()
+	changed := func(ago time.Duration) *models.User {
+		return &models.User{PasswordChangedAt: sql.NullTime{Time: now.Add(-ago), Valid: true}}
+	}
+	policy := models.PasswordPolicy{MinAgeDays: 1}
+
+	if err := checkPasswordMinAge(changed(time.Hour), policy, now); !errors.Is(err, ErrPasswordChangeTooSoon) {
+		t.Errorf("password changed an hour ago: error = %v, want ErrPasswordChangeTooSoon", err)
+	}
+	if err := checkPasswordMinAge(changed(25*time.Hour), policy, now); err != nil {
+		t.Errorf("password changed 25 hours ago: error = %v", err)
+	}
+	if err := checkPasswordMinAge(&models.User{}, policy, now); err != nil {
+		t.Errorf("password never changed: error = %v", err)
+	}
+	if err := checkPasswordMinAge(changed(time.Minute), models.PasswordPolicy{}, now); err != nil {
+		t.Errorf("no minimum age: error = %v", err)
+	}
+}
+
+func TestMatchesAnyHash(t *testing.T) {
+	var hashes []string
+	for _, password := range []string{"Old-Password-1", "Old-Password-2"} {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("GenerateFromPassword() error = %v", err)
+		}
+		hashes = append(hashes, string(hash))
+	}
+
+	if !matchesAnyHash("Old-Password-2", hashes) {
+		t.Error("matchesAnyHash() missed a previous password")
+	}
+	if matchesAnyHash("New-Password-3", hashes) {
+		t.Error("matchesAnyHash() matched a new password")
+	}
+	if matchesAnyHash("Old-Password-1", nil) {
+		t.Error("matchesAnyHash() matched an empty history")
+	}
+}
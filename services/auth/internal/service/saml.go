@@ -224,6 +224,11 @@ type SAMLService struct {
 	privateKey       *rsa.PrivateKey
 	certificate      *x509.Certificate
 	clockSkew        time.Duration
+
+	// Key pair being rotated out. It is published in metadata and still used
+	// to decrypt assertions until the rotation window ends.
+	previousKey         *rsa.PrivateKey
+	previousCertificate *x509.Certificate
 }
 
 // NewSAMLService creates a new SAML service
@@ -238,34 +243,18 @@ func NewSAMLService(entityID, acsURL, sloURL, metadataURL string, privateKeyPEM,
 
 	// Parse private key if provided
 	if len(privateKeyPEM) > 0 {
-		block, _ := pem.Decode(privateKeyPEM)
-		if block == nil {
-			return nil, errors.New("failed to parse private key PEM")
-		}
-		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		key, err := parseRSAPrivateKey(privateKeyPEM)
 		if err != nil {
-			// Try PKCS1
-			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse private key: %w", err)
-			}
-		}
-		rsaKey, ok := key.(*rsa.PrivateKey)
-		if !ok {
-			return nil, errors.New("private key is not RSA")
+			return nil, err
 		}
-		service.privateKey = rsaKey
+		service.privateKey = key
 	}
 
 	// Parse certificate if provided
 	if len(certificatePEM) > 0 {
-		block, _ := pem.Decode(certificatePEM)
-		if block == nil {
-			return nil, errors.New("failed to parse certificate PEM")
-		}
-		cert, err := x509.ParseCertificate(block.Bytes)
+		cert, err := parseCertificate(certificatePEM)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+			return nil, err
 		}
 		service.certificate = cert
 	}
@@ -273,6 +262,82 @@ func NewSAMLService(entityID, acsURL, sloURL, metadataURL string, privateKeyPEM,
 	return service, nil
 }
 
+// SetPreviousKeyPair keeps the SP key pair that is being rotated out active
+// alongside the current one. IdPs that have not yet refreshed our metadata
+// may still encrypt assertions to the previous certificate.
+func (s *SAMLService) SetPreviousKeyPair(privateKeyPEM, certificatePEM []byte) error {
+	key, err := parseRSAPrivateKey(privateKeyPEM)
+	if err != nil {
+		return fmt.Errorf("previous key pair: %w", err)
+	}
+	cert, err := parseCertificate(certificatePEM)
+	if err != nil {
+		return fmt.Errorf("previous key pair: %w", err)
+	}
+
+	s.previousKey = key
+	s.previousCertificate = cert
+	return nil
+}
+
+// certificates returns the active SP certificates, current first
+func (s *SAMLService) certificates() []*x509.Certificate {
+	var certs []*x509.Certificate
+	if s.certificate != nil {
+		certs = append(certs, s.certificate)
+	}
+	if s.previousCertificate != nil {
+		certs = append(certs, s.previousCertificate)
+	}
+	return certs
+}
+
+// decryptionKeys returns the active SP private keys, current first
+func (s *SAMLService) decryptionKeys() []*rsa.PrivateKey {
+	var keys []*rsa.PrivateKey
+	if s.privateKey != nil {
+		keys = append(keys, s.privateKey)
+	}
+	if s.previousKey != nil {
+		keys = append(keys, s.previousKey)
+	}
+	return keys
+}
+
+// parseRSAPrivateKey parses a PKCS#8 or PKCS#1 RSA private key PEM
+func parseRSAPrivateKey(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("failed to parse private key PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		// Try PKCS1
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+	return rsaKey, nil
+}
+
+// parseCertificate parses an X.509 certificate PEM
+func parseCertificate(certificatePEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certificatePEM)
+	if block == nil {
+		return nil, errors.New("failed to parse certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
+
 // ParseSAMLResponse parses and validates a SAML response
 func (s *SAMLService) ParseSAMLResponse(config *models.SAMLConfig, samlResponse, expectedRequestID, expectedAudience string) (*SAMLAssertion, map[string]interface{}, error) {
 	// Base64 decode the response
@@ -401,7 +466,8 @@ func (s *SAMLService) verifySignature(doc *etree.Document, idpCertPEM string) er
 
 // decryptAssertion decrypts an encrypted assertion
 func (s *SAMLService) decryptAssertion(encryptedEl *etree.Element) (*SAMLAssertion, error) {
-	if s.privateKey == nil {
+	keys := s.decryptionKeys()
+	if len(keys) == 0 {
 		return nil, errors.New("private key required for decryption")
 	}
 
@@ -429,7 +495,14 @@ func (s *SAMLService) decryptAssertion(encryptedEl *etree.Element) (*SAMLAsserti
 		return nil, fmt.Errorf("failed to decode encrypted key: %w", err)
 	}
 
-	sessionKey, err := rsa.DecryptPKCS1v15(nil, s.privateKey, encryptedKey)
+	// During a certificate rotation the IdP may have used either key
+	var sessionKey []byte
+	for _, key := range keys {
+		sessionKey, err = rsa.DecryptPKCS1v15(nil, key, encryptedKey)
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt session key: %w", err)
 	}
@@ -721,71 +794,125 @@ func (s *SAMLService) CreateLogoutResponse(inResponseTo, destination string, suc
 	return base64.StdEncoding.EncodeToString(xmlBytes), nil
 }
 
-// GenerateMetadata generates SAML SP metadata
+// SPMetadata is the SAML 2.0 metadata EntityDescriptor for this service provider
+type SPMetadata struct {
+	XMLName         xml.Name         `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID        string           `xml:"entityID,attr"`
+	SPSSODescriptor SPSSODescriptor  `xml:"SPSSODescriptor"`
+	Organization    *SPOrganization  `xml:"Organization,omitempty"`
+	ContactPerson   *SPContactPerson `xml:"ContactPerson,omitempty"`
+}
+
+// SPSSODescriptor describes the SP's SAML endpoints and keys
+type SPSSODescriptor struct {
+	AuthnRequestsSigned        bool                `xml:"AuthnRequestsSigned,attr"`
+	WantAssertionsSigned       bool                `xml:"WantAssertionsSigned,attr"`
+	ProtocolSupportEnumeration string              `xml:"protocolSupportEnumeration,attr"`
+	KeyDescriptors             []SPKeyDescriptor   `xml:"KeyDescriptor"`
+	SingleLogoutServices       []SPEndpoint        `xml:"SingleLogoutService"`
+	NameIDFormats              []string            `xml:"NameIDFormat"`
+	AssertionConsumerServices  []SPIndexedEndpoint `xml:"AssertionConsumerService"`
+}
+
+// SPKeyDescriptor publishes one SP certificate for signing or encryption
+type SPKeyDescriptor struct {
+	Use     string    `xml:"use,attr"`
+	KeyInfo SPKeyInfo `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+}
+
+// SPKeyInfo holds a base64 DER X.509 certificate
+type SPKeyInfo struct {
+	X509Certificate string `xml:"X509Data>X509Certificate"`
+}
+
+// SPEndpoint is a SAML metadata endpoint
+type SPEndpoint struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+// SPIndexedEndpoint is a SAML metadata endpoint with an index
+type SPIndexedEndpoint struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     int    `xml:"index,attr"`
+	IsDefault bool   `xml:"isDefault,attr"`
+}
+
+// SPOrganization identifies the organization operating the SP
+type SPOrganization struct {
+	Name        SPLocalizedName `xml:"OrganizationName"`
+	DisplayName SPLocalizedName `xml:"OrganizationDisplayName"`
+	URL         SPLocalizedName `xml:"OrganizationURL"`
+}
+
+// SPLocalizedName is a metadata string with an xml:lang attribute
+type SPLocalizedName struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+// SPContactPerson is the technical contact for the SP
+type SPContactPerson struct {
+	ContactType  string `xml:"contactType,attr"`
+	EmailAddress string `xml:"EmailAddress"`
+}
+
+// GenerateMetadata generates SAML SP metadata. Every active SP certificate is
+// published for both signing and encryption, so during a rotation IdPs see
+// the new certificate while the previous one is still accepted.
 func (s *SAMLService) GenerateMetadata(organization, contactEmail string) (string, error) {
-	certPEM := ""
-	if s.certificate != nil {
-		certPEM = base64.StdEncoding.EncodeToString(s.certificate.Raw)
-	}
-
-	metadata := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata"
-                     xmlns:ds="http://www.w3.org/2000/09/xmldsig#"
-                     entityID="%s">
-  <md:SPSSODescriptor AuthnRequestsSigned="true"
-                      WantAssertionsSigned="true"
-                      protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
-    <md:KeyDescriptor use="signing">
-      <ds:KeyInfo>
-        <ds:X509Data>
-          <ds:X509Certificate>%s</ds:X509Certificate>
-        </ds:X509Data>
-      </ds:KeyInfo>
-    </md:KeyDescriptor>
-    <md:KeyDescriptor use="encryption">
-      <ds:KeyInfo>
-        <ds:X509Data>
-          <ds:X509Certificate>%s</ds:X509Certificate>
-        </ds:X509Data>
-      </ds:KeyInfo>
-    </md:KeyDescriptor>
-    <md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
-                            Location="%s"/>
-    <md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
-                            Location="%s"/>
-    <md:NameIDFormat>%s</md:NameIDFormat>
-    <md:NameIDFormat>%s</md:NameIDFormat>
-    <md:NameIDFormat>%s</md:NameIDFormat>
-    <md:AssertionConsumerService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
-                                 Location="%s"
-                                 index="0"
-                                 isDefault="true"/>
-  </md:SPSSODescriptor>
-  <md:Organization>
-    <md:OrganizationName xml:lang="en">%s</md:OrganizationName>
-    <md:OrganizationDisplayName xml:lang="en">%s</md:OrganizationDisplayName>
-    <md:OrganizationURL xml:lang="en">%s</md:OrganizationURL>
-  </md:Organization>
-  <md:ContactPerson contactType="technical">
-    <md:EmailAddress>%s</md:EmailAddress>
-  </md:ContactPerson>
-</md:EntityDescriptor>`,
-		s.entityID,
-		certPEM,
-		certPEM,
-		s.sloURL,
-		s.sloURL,
-		NameIDFormatEmail,
-		NameIDFormatPersistent,
-		NameIDFormatTransient,
-		s.acsURL,
-		organization,
-		organization,
-		s.metadataURL,
-		contactEmail,
-	)
-
-	return metadata, nil
+	descriptor := SPSSODescriptor{
+		AuthnRequestsSigned:        s.privateKey != nil && s.certificate != nil,
+		WantAssertionsSigned:       true,
+		ProtocolSupportEnumeration: SAMLProtocolNamespace,
+		SingleLogoutServices: []SPEndpoint{
+			{Binding: "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST", Location: s.sloURL},
+			{Binding: "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect", Location: s.sloURL},
+		},
+		NameIDFormats: []string{
+			NameIDFormatEmail,
+			NameIDFormatPersistent,
+			NameIDFormatTransient,
+		},
+		AssertionConsumerServices: []SPIndexedEndpoint{
+			{Binding: "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST", Location: s.acsURL, Index: 0, IsDefault: true},
+		},
+	}
+
+	for _, use := range []string{"signing", "encryption"} {
+		for _, cert := range s.certificates() {
+			descriptor.KeyDescriptors = append(descriptor.KeyDescriptors, SPKeyDescriptor{
+				Use:     use,
+				KeyInfo: SPKeyInfo{X509Certificate: base64.StdEncoding.EncodeToString(cert.Raw)},
+			})
+		}
+	}
+
+	metadata := SPMetadata{
+		EntityID:        s.entityID,
+		SPSSODescriptor: descriptor,
+	}
+	if organization != "" {
+		metadata.Organization = &SPOrganization{
+			Name:        SPLocalizedName{Lang: "en", Value: organization},
+			DisplayName: SPLocalizedName{Lang: "en", Value: organization},
+			URL:         SPLocalizedName{Lang: "en", Value: s.metadataURL},
+		}
+	}
+	if contactEmail != "" {
+		metadata.ContactPerson = &SPContactPerson{
+			ContactType:  "technical",
+			EmailAddress: contactEmail,
+		}
+	}
+
+	out, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return xml.Header + string(out), nil
 }
 
 // signRequest signs a SAML request using the SP private key
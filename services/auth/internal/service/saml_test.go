@@ -0,0 +1,153 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generateSPKeyPair returns a PEM private key and self-signed certificate
+func generateSPKeyPair(t *testing.T, commonName string) ([]byte, []byte, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return keyPEM, certPEM, cert
+}
+
+func TestGenerateMetadata(t *testing.T) {
+	keyPEM, certPEM, cert := generateSPKeyPair(t, "current")
+
+	svc, err := NewSAMLService(
+		"https://sso.example.com/saml",
+		"https://sso.example.com/api/auth/sso/d1/saml/callback",
+		"https://sso.example.com/api/auth/sso/d1/saml/logout",
+		"https://sso.example.com/api/auth/sso/d1/saml/metadata",
+		keyPEM, certPEM,
+	)
+	if err != nil {
+		t.Fatalf("NewSAMLService() error = %v", err)
+	}
+
+	metadata, err := svc.GenerateMetadata("Acme & Sons", "admin@example.com")
+	if err != nil {
+		t.Fatalf("GenerateMetadata() error = %v", err)
+	}
+
+	var parsed SPMetadata
+	if err := xml.Unmarshal([]byte(metadata), &parsed); err != nil {
+		t.Fatalf("metadata is not valid XML: %v", err)
+	}
+
+	if parsed.EntityID != "https://sso.example.com/saml" {
+		t.Errorf("entityID = %q", parsed.EntityID)
+	}
+	if len(parsed.SPSSODescriptor.AssertionConsumerServices) != 1 ||
+		parsed.SPSSODescriptor.AssertionConsumerServices[0].Location != "https://sso.example.com/api/auth/sso/d1/saml/callback" {
+		t.Errorf("ACS = %+v", parsed.SPSSODescriptor.AssertionConsumerServices)
+	}
+	if !parsed.SPSSODescriptor.AuthnRequestsSigned {
+		t.Error("AuthnRequestsSigned = false with an SP key configured")
+	}
+	if parsed.Organization == nil || parsed.Organization.Name.Value != "Acme & Sons" {
+		t.Errorf("Organization = %+v", parsed.Organization)
+	}
+
+	want := base64.StdEncoding.EncodeToString(cert.Raw)
+	uses := map[string]int{}
+	for _, kd := range parsed.SPSSODescriptor.KeyDescriptors {
+		if kd.KeyInfo.X509Certificate != want {
+			t.Errorf("KeyDescriptor %s has unexpected certificate", kd.Use)
+		}
+		uses[kd.Use]++
+	}
+	if uses["signing"] != 1 || uses["encryption"] != 1 {
+		t.Errorf("KeyDescriptor uses = %v, want one signing and one encryption", uses)
+	}
+}
+
+func TestGenerateMetadata_RotationListsBothCertificates(t *testing.T) {
+	keyPEM, certPEM, current := generateSPKeyPair(t, "current")
+	oldKeyPEM, oldCertPEM, previous := generateSPKeyPair(t, "previous")
+
+	svc, err := NewSAMLService("sp", "acs", "slo", "metadata", keyPEM, certPEM)
+	if err != nil {
+		t.Fatalf("NewSAMLService() error = %v", err)
+	}
+	if err := svc.SetPreviousKeyPair(oldKeyPEM, oldCertPEM); err != nil {
+		t.Fatalf("SetPreviousKeyPair() error = %v", err)
+	}
+
+	metadata, err := svc.GenerateMetadata("", "")
+	if err != nil {
+		t.Fatalf("GenerateMetadata() error = %v", err)
+	}
+
+	var parsed SPMetadata
+	if err := xml.Unmarshal([]byte(metadata), &parsed); err != nil {
+		t.Fatalf("metadata is not valid XML: %v", err)
+	}
+
+	var signing []string
+	for _, kd := range parsed.SPSSODescriptor.KeyDescriptors {
+		if kd.Use == "signing" {
+			signing = append(signing, kd.KeyInfo.X509Certificate)
+		}
+	}
+	if len(signing) != 2 {
+		t.Fatalf("signing certificates = %d, want 2", len(signing))
+	}
+	if signing[0] != base64.StdEncoding.EncodeToString(current.Raw) {
+		t.Error("current certificate is not listed first")
+	}
+	if signing[1] != base64.StdEncoding.EncodeToString(previous.Raw) {
+		t.Error("previous certificate is not listed")
+	}
+	if strings.Contains(metadata, "Organization") || strings.Contains(metadata, "ContactPerson") {
+		t.Error("empty organization and contact should be omitted")
+	}
+}
+
+func TestSetPreviousKeyPair_InvalidPEM(t *testing.T) {
+	keyPEM, certPEM, _ := generateSPKeyPair(t, "current")
+
+	svc, err := NewSAMLService("sp", "acs", "slo", "metadata", keyPEM, certPEM)
+	if err != nil {
+		t.Fatalf("NewSAMLService() error = %v", err)
+	}
+
+	if err := svc.SetPreviousKeyPair([]byte("not a key"), certPEM); err == nil {
+		t.Error("SetPreviousKeyPair() accepted an invalid key")
+	}
+	if err := svc.SetPreviousKeyPair(keyPEM, []byte("not a cert")); err == nil {
+		t.Error("SetPreviousKeyPair() accepted an invalid certificate")
+	}
+}
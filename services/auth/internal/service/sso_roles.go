@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+	"github.com/artpromedia/email/services/auth/internal/repository"
+	"github.com/google/uuid"
+)
+
+// defaultGroupAttribute is the SAML attribute or OIDC claim holding group
+// memberships when a group role mapping doesn't name one.
+const defaultGroupAttribute = "groups"
+
+// groupRoleRank orders the roles a group mapping can grant, so a user in
+// several mapped groups gets the highest.
+var groupRoleRank = map[string]int{"viewer": 1, "member": 2, "admin": 3}
+
+// groupAccess is the role and SSO domain permissions granted by a user's
+// mapped IdP groups.
+type groupAccess struct {
+	Role             string
+	CanManage        bool
+	CanViewAnalytics bool
+	CanManageUsers   bool
+	Groups           []string // Mapped groups the user is in
+}
+
+// resolveGroupAccess returns the highest role and the union of the
+// permissions granted by the mapped groups in groups, or nil if none of them
+// is mapped. Group names are compared case-insensitively.
+func resolveGroupAccess(mapping *models.GroupRoleMapping, groups []string) *groupAccess {
+	member := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		member[strings.ToLower(strings.TrimSpace(g))] = true
+	}
+
+	var access *groupAccess
+	for _, m := range mapping.Mappings {
+		if !member[strings.ToLower(m.Group)] {
+			continue
+		}
+		if access == nil {
+			access = &groupAccess{Role: m.Role}
+		} else if groupRoleRank[m.Role] > groupRoleRank[access.Role] {
+			access.Role = m.Role
+		}
+		access.CanManage = access.CanManage || m.CanManage
+		access.CanViewAnalytics = access.CanViewAnalytics || m.CanViewAnalytics
+		access.CanManageUsers = access.CanManageUsers || m.CanManageUsers
+		access.Groups = append(access.Groups, m.Group)
+	}
+	if access != nil {
+		sort.Strings(access.Groups)
+	}
+	return access
+}
+
+// extractGroups reads group memberships from SAML attributes or OIDC claims
+// when the SSO config maps groups to roles. Single-valued attributes arrive
+// as a string, multi-valued ones as a list.
+func extractGroups(ssoConfig *models.SSOConfig, attrs map[string]interface{}) []string {
+	if !hasGroupRoleMapping(ssoConfig) {
+		return nil
+	}
+	attribute := ssoConfig.GroupRoleMapping.GroupAttribute
+	if attribute == "" {
+		attribute = defaultGroupAttribute
+	}
+
+	switch v := attrs[attribute].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []string:
+		return v
+	case []interface{}:
+		groups := make([]string, 0, len(v))
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// hasGroupRoleMapping reports whether SSO logins for the config derive roles
+// from IdP groups.
+func hasGroupRoleMapping(ssoConfig *models.SSOConfig) bool {
+	return ssoConfig.GroupRoleMapping != nil && len(ssoConfig.GroupRoleMapping.Mappings) > 0
+}
+
+// syncGroupRole applies the SSO config's group role mapping to a user on
+// login. The role and SSO domain permissions are updated when the groups now
+// grant something else, and users in no mapped group are handled per the
+// unmapped policy. Organization owners are never changed from the IdP.
+func (s *SSOService) syncGroupRole(ctx context.Context, domain *models.Domain, ssoConfig *models.SSOConfig, user *models.User, groups []string, ipAddress, userAgent string) error {
+	if !hasGroupRoleMapping(ssoConfig) || user.Role == "owner" {
+		return nil
+	}
+	mapping := ssoConfig.GroupRoleMapping
+
+	current, err := s.repo.GetUserDomainPermission(ctx, user.ID, domain.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("failed to get domain permission: %w", err)
+	}
+	if current == nil {
+		current = &models.UserDomainPermission{UserID: user.ID, DomainID: domain.ID, CanSendAs: true}
+	}
+
+	access := resolveGroupAccess(mapping, groups)
+	policy := ""
+	if access == nil {
+		policy = mapping.UnmappedPolicy
+		switch policy {
+		case models.UnmappedGroupsDowngrade:
+			access = &groupAccess{Role: "viewer"}
+		case models.UnmappedGroupsDisable:
+			if user.Status != "active" {
+				return nil
+			}
+			return s.disableUnmappedSSOUser(ctx, domain, user, groups, ipAddress, userAgent)
+		default:
+			return nil
+		}
+	}
+
+	permsChanged := current.CanManage != access.CanManage ||
+		current.CanViewAnalytics != access.CanViewAnalytics ||
+		current.CanManageUsers != access.CanManageUsers
+	previousRole := user.Role
+	if access.Role == previousRole && !permsChanged {
+		return nil
+	}
+
+	now := time.Now()
+	if access.Role != previousRole {
+		user.Role = access.Role
+		user.UpdatedAt = now
+		if err := s.repo.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+	}
+	if permsChanged {
+		perm := &models.UserDomainPermission{
+			ID:               uuid.New(),
+			UserID:           user.ID,
+			DomainID:         domain.ID,
+			CanSendAs:        current.CanSendAs,
+			CanManage:        access.CanManage,
+			CanViewAnalytics: access.CanViewAnalytics,
+			CanManageUsers:   access.CanManageUsers,
+			GrantedAt:        now,
+		}
+		if err := s.repo.CreateUserDomainPermission(ctx, perm); err != nil {
+			return fmt.Errorf("failed to update domain permission: %w", err)
+		}
+	}
+
+	s.authService.recordAuditLog(ctx, domain.OrganizationID, &user.ID, "user.sso_role_synced", "user", &user.ID, ipAddress, userAgent, map[string]interface{}{
+		"groups":             access.Groups,
+		"previous_role":      previousRole,
+		"role":               access.Role,
+		"can_manage":         access.CanManage,
+		"can_view_analytics": access.CanViewAnalytics,
+		"can_manage_users":   access.CanManageUsers,
+		"unmapped_policy":    policy,
+	})
+	return nil
+}
+
+// disableUnmappedSSOUser suspends a user who is no longer in any mapped group
+// and signs them out everywhere. Reactivation is left to an administrator.
+func (s *SSOService) disableUnmappedSSOUser(ctx context.Context, domain *models.Domain, user *models.User, groups []string, ipAddress, userAgent string) error {
+	now := time.Now()
+	user.Status = "suspended"
+	user.SuspendedAt = &now
+	user.SuspendReason = "no mapped SSO group"
+	user.UpdatedAt = now
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeAllUserSessions(ctx, user.ID, nil); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	s.authService.recordAuditLog(ctx, domain.OrganizationID, &user.ID, "user.sso_role_synced", "user", &user.ID, ipAddress, userAgent, map[string]interface{}{
+		"groups":          groups,
+		"previous_status": "active",
+		"status":          user.Status,
+		"unmapped_policy": models.UnmappedGroupsDisable,
+	})
+	return nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/artpromedia/email/services/auth/internal/models"
+)
+
+func TestResolveGroupAccess(t *testing.T) {
+	mapping := &models.GroupRoleMapping{
+		Mappings: []models.GroupMapping{
+			{Group: "Mail-Admins", Role: "admin", CanManage: true, CanManageUsers: true},
+			{Group: "Staff", Role: "member"},
+			{Group: "Analysts", Role: "viewer", CanViewAnalytics: true},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		groups []string
+		want   *groupAccess
+	}{
+		{"no groups", nil, nil},
+		{"only unmapped groups", []string{"Everyone", "VPN"}, nil},
+		{"single group", []string{"Everyone", "Staff"}, &groupAccess{Role: "member", Groups: []string{"Staff"}}},
+		{"case-insensitive", []string{"mail-admins"}, &groupAccess{Role: "admin", CanManage: true, CanManageUsers: true, Groups: []string{"Mail-Admins"}}},
+		{"highest role and union of permissions", []string{"Analysts", "Staff"}, &groupAccess{Role: "member", CanViewAnalytics: true, Groups: []string{"Analysts", "Staff"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveGroupAccess(mapping, tt.groups); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveGroupAccess() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractGroups(t *testing.T) {
+	config := &models.SSOConfig{GroupRoleMapping: &models.GroupRoleMapping{
+		Mappings: []models.GroupMapping{{Group: "Staff", Role: "member"}},
+	}}
+
+	tests := []struct {
+		name  string
+		attrs map[string]interface{}
+		want  []string
+	}{
+		{"SAML single value", map[string]interface{}{"groups": "Staff"}, []string{"Staff"}},
+		{"SAML multiple values", map[string]interface{}{"groups": []string{"Staff", "VPN"}}, []string{"Staff", "VPN"}},
+		{"OIDC claim", map[string]interface{}{"groups": []interface{}{"Staff", 42, "VPN"}}, []string{"Staff", "VPN"}},
+		{"missing", map[string]interface{}{"email": "alice@example.com"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractGroups(config, tt.attrs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	config.GroupRoleMapping.GroupAttribute = "memberOf"
+	if got := extractGroups(config, map[string]interface{}{"memberOf": "Staff"}); !reflect.DeepEqual(got, []string{"Staff"}) {
+		t.Errorf("extractGroups() with custom attribute = %v", got)
+	}
+
+	if got := extractGroups(&models.SSOConfig{}, map[string]interface{}{"groups": "Staff"}); got != nil {
+		t.Errorf("extractGroups() without a mapping = %v, want nil", got)
+	}
+}
@@ -189,7 +189,8 @@ func (s *SSOService) HandleSAMLCallback(ctx context.Context, domainID uuid.UUID,
 	}
 
 	// Process SSO login
-	return s.processSSOLogin(ctx, domain, ssoConfig, nameID, email, displayName, attributes, ipAddress, userAgent)
+	groups := extractGroups(ssoConfig, attributes)
+	return s.processSSOLogin(ctx, domain, ssoConfig, nameID, email, displayName, groups, attributes, ipAddress, userAgent)
 }
 
 // HandleOIDCCallback processes OIDC authorization code callback.
@@ -250,11 +251,25 @@ func (s *SSOService) HandleOIDCCallback(ctx context.Context, domainID uuid.UUID,
 		return nil, errors.New("email not found in OIDC response")
 	}
 
+	groups := extractGroups(ssoConfig, userInfo)
+
 	// Convert userInfo to JSON for storage
 	rawAttrs, _ := json.Marshal(userInfo)
 
 	// Process SSO login
-	return s.processSSOLogin(ctx, domain, ssoConfig, sub, email, displayName, rawAttrs, ipAddress, userAgent)
+	tokens, err := s.processSSOLogin(ctx, domain, ssoConfig, sub, email, displayName, groups, rawAttrs, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the IdP session so back-channel logout can find it
+	if sid, ok := userInfo["sid"].(string); ok && sid != "" {
+		if err := s.repo.SetSessionSSOSID(ctx, tokens.SessionID, sid); err != nil {
+			return nil, fmt.Errorf("failed to store SSO session ID: %w", err)
+		}
+	}
+
+	return tokens, nil
 }
 
 // ConfigureSSO configures SSO for a domain.
@@ -293,6 +308,7 @@ func (s *SSOService) ConfigureSSO(ctx context.Context, domainID uuid.UUID, req *
 		EnforceSSO:         req.EnforceSSO,
 		AutoProvisionUsers: req.AutoProvisionUsers,
 		DefaultRole:        req.DefaultRole,
+		GroupRoleMapping:   req.GroupRoleMapping,
 		SAMLConfig:         req.SAMLConfig,
 		OIDCConfig:         req.OIDCConfig,
 		CreatedAt:          now,
@@ -302,6 +318,14 @@ func (s *SSOService) ConfigureSSO(ctx context.Context, domainID uuid.UUID, req *
 	if config.DefaultRole == "" {
 		config.DefaultRole = "member"
 	}
+	if m := config.GroupRoleMapping; m != nil {
+		if m.GroupAttribute == "" {
+			m.GroupAttribute = defaultGroupAttribute
+		}
+		if m.UnmappedPolicy == "" {
+			m.UnmappedPolicy = models.UnmappedGroupsKeep
+		}
+	}
 
 	if err := s.repo.UpsertSSOConfig(ctx, config); err != nil {
 		return nil, fmt.Errorf("failed to save SSO config: %w", err)
@@ -333,6 +357,7 @@ func (s *SSOService) GetSSOConfig(ctx context.Context, domainID uuid.UUID) (*mod
 		EnforceSSO:         config.EnforceSSO,
 		AutoProvisionUsers: config.AutoProvisionUsers,
 		DefaultRole:        config.DefaultRole,
+		GroupRoleMapping:   config.GroupRoleMapping,
 		SAMLConfig:         config.SAMLConfig,
 		CreatedAt:          config.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:          config.UpdatedAt.Format(time.RFC3339),
@@ -349,6 +374,8 @@ func (s *SSOService) GetSSOConfig(ctx context.Context, domainID uuid.UUID) (*mod
 			TokenURL:         config.OIDCConfig.TokenURL,
 			UserInfoURL:      config.OIDCConfig.UserInfoURL,
 			AttributeMapping: config.OIDCConfig.AttributeMapping,
+			BackchannelLogoutURI: fmt.Sprintf("%s/api/auth/sso/%s/oidc/backchannel-logout",
+				s.config.SSO.BaseURL, domainID.String()),
 		}
 	}
 
@@ -424,13 +451,10 @@ func (s *SSOService) InitiateSLO(ctx context.Context, userID uuid.UUID, sessionI
 	}
 
 	// Create SAML service
-	samlService, err := NewSAMLService(
-		s.config.SSO.EntityID,
+	samlService, err := s.newSAMLService(
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/callback", s.config.SSO.BaseURL, domain.ID.String()),
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/logout", s.config.SSO.BaseURL, domain.ID.String()),
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/metadata", s.config.SSO.BaseURL, domain.ID.String()),
-		[]byte(s.config.SSO.PrivateKey),
-		[]byte(s.config.SSO.Certificate),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create SAML service: %w", err)
@@ -495,13 +519,10 @@ func (s *SSOService) HandleSLORequest(ctx context.Context, domainID uuid.UUID, s
 	}
 
 	// Create SAML service
-	samlService, err := NewSAMLService(
-		s.config.SSO.EntityID,
+	samlService, err := s.newSAMLService(
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/callback", s.config.SSO.BaseURL, domain.ID.String()),
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/logout", s.config.SSO.BaseURL, domain.ID.String()),
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/metadata", s.config.SSO.BaseURL, domain.ID.String()),
-		[]byte(s.config.SSO.PrivateKey),
-		[]byte(s.config.SSO.Certificate),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create SAML service: %w", err)
@@ -567,13 +588,10 @@ func (s *SSOService) GenerateSAMLMetadata(ctx context.Context, domainID uuid.UUI
 	}
 
 	// Create SAML service
-	samlService, err := NewSAMLService(
-		s.config.SSO.EntityID,
+	samlService, err := s.newSAMLService(
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/callback", s.config.SSO.BaseURL, domain.ID.String()),
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/logout", s.config.SSO.BaseURL, domain.ID.String()),
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/metadata", s.config.SSO.BaseURL, domain.ID.String()),
-		[]byte(s.config.SSO.PrivateKey),
-		[]byte(s.config.SSO.Certificate),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create SAML service: %w", err)
@@ -597,7 +615,32 @@ func (s *SSOService) GenerateSAMLMetadata(ctx context.Context, domainID uuid.UUI
 // HELPER METHODS
 // ============================================================
 
-func (s *SSOService) processSSOLogin(ctx context.Context, domain *models.Domain, ssoConfig *models.SSOConfig, providerUserID, email, displayName string, rawAttrs interface{}, ipAddress, userAgent string) (*token.TokenPair, error) {
+// newSAMLService creates a SAML service with the configured SP key pair. While
+// a certificate rotation is in progress the previous key pair stays active.
+func (s *SSOService) newSAMLService(acsURL, sloURL, metadataURL string) (*SAMLService, error) {
+	samlService, err := NewSAMLService(
+		s.config.SSO.EntityID,
+		acsURL,
+		sloURL,
+		metadataURL,
+		[]byte(s.config.SSO.PrivateKey),
+		[]byte(s.config.SSO.Certificate),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sso := s.config.SSO
+	if sso.PreviousCert != "" && sso.PreviousKey != "" && (sso.RotationEndsAt.IsZero() || time.Now().Before(sso.RotationEndsAt)) {
+		if err := samlService.SetPreviousKeyPair([]byte(sso.PreviousKey), []byte(sso.PreviousCert)); err != nil {
+			return nil, err
+		}
+	}
+
+	return samlService, nil
+}
+
+func (s *SSOService) processSSOLogin(ctx context.Context, domain *models.Domain, ssoConfig *models.SSOConfig, providerUserID, email, displayName string, groups []string, rawAttrs interface{}, ipAddress, userAgent string) (*token.TokenPair, error) {
 	// Check if SSO identity exists
 	identity, err := s.repo.GetSSOIdentity(ctx, domain.ID, providerUserID)
 	if err != nil && !errors.Is(err, repository.ErrNotFound) {
@@ -656,6 +699,12 @@ func (s *SSOService) processSSOLogin(ctx context.Context, domain *models.Domain,
 				return nil, fmt.Errorf("failed to create SSO identity: %w", err)
 			}
 		} else if ssoConfig.AutoProvisionUsers {
+			// Don't create accounts the group mapping would disable right away
+			if hasGroupRoleMapping(ssoConfig) && ssoConfig.GroupRoleMapping.UnmappedPolicy == models.UnmappedGroupsDisable &&
+				resolveGroupAccess(ssoConfig.GroupRoleMapping, groups) == nil {
+				return nil, ErrSSOUserNotAllowed
+			}
+
 			// Auto-provision new user
 			user, err = s.provisionSSOUser(ctx, domain, ssoConfig, providerUserID, email, displayName, rawAttrs)
 			if err != nil {
@@ -666,6 +715,11 @@ func (s *SSOService) processSSOLogin(ctx context.Context, domain *models.Domain,
 		}
 	}
 
+	// Apply IdP group role mapping
+	if err := s.syncGroupRole(ctx, domain, ssoConfig, user, groups, ipAddress, userAgent); err != nil {
+		return nil, fmt.Errorf("failed to apply group role mapping: %w", err)
+	}
+
 	// Check user status
 	if user.Status != "active" {
 		return nil, ErrAccountDisabled
@@ -881,13 +935,10 @@ func (s *SSOService) initiateSAMLLogin(domain *models.Domain, ssoConfig *models.
 	}
 
 	// Create SAML service with SP configuration
-	samlService, err := NewSAMLService(
-		s.config.SSO.EntityID,
+	samlService, err := s.newSAMLService(
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/callback", s.config.SSO.BaseURL, domain.ID.String()),
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/logout", s.config.SSO.BaseURL, domain.ID.String()),
 		fmt.Sprintf("%s/api/auth/sso/%s/saml/metadata", s.config.SSO.BaseURL, domain.ID.String()),
-		[]byte(s.config.SSO.PrivateKey),
-		[]byte(s.config.SSO.Certificate),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create SAML service: %w", err)
@@ -964,13 +1015,10 @@ func (s *SSOService) initiateOIDCLogin(domain *models.Domain, ssoConfig *models.
 
 func (s *SSOService) parseSAMLResponse(config *models.SAMLConfig, samlResponse string) (map[string]interface{}, error) {
 	// Create SAML service with SP configuration
-	samlService, err := NewSAMLService(
-		s.config.SSO.EntityID,
+	samlService, err := s.newSAMLService(
 		fmt.Sprintf("%s/api/auth/sso/saml/callback", s.config.SSO.BaseURL),
 		fmt.Sprintf("%s/api/auth/sso/saml/logout", s.config.SSO.BaseURL),
 		fmt.Sprintf("%s/api/auth/sso/saml/metadata", s.config.SSO.BaseURL),
-		[]byte(s.config.SSO.PrivateKey),
-		[]byte(s.config.SSO.Certificate),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SAML service: %w", err)
@@ -181,20 +181,22 @@ func (m *MockRepository) CreateUser(ctx context.Context, user *models.User, emai
 }
 
 // UpdateUserLoginFailure updates failed login count
-func (m *MockRepository) UpdateUserLoginFailure(ctx context.Context, userID uuid.UUID, lockoutDuration time.Duration, maxAttempts int) error {
+func (m *MockRepository) UpdateUserLoginFailure(ctx context.Context, userID uuid.UUID, lockoutDuration time.Duration, maxAttempts int) (bool, error) {
 	if m.UpdateUserLoginFailureError != nil {
-		return m.UpdateUserLoginFailureError
+		return false, m.UpdateUserLoginFailureError
 	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if user, ok := m.users[userID]; ok {
 		user.FailedLoginAttempts++
-		if user.FailedLoginAttempts >= maxAttempts {
+		alreadyLocked := user.LockedUntil.Valid && user.LockedUntil.Time.After(time.Now())
+		if user.FailedLoginAttempts >= maxAttempts && !alreadyLocked {
 			lockUntil := time.Now().Add(lockoutDuration)
 			user.LockedUntil = sql.NullTime{Time: lockUntil, Valid: true}
+			return true, nil
 		}
 	}
-	return nil
+	return false, nil
 }
 
 // UpdateUserLoginSuccess updates successful login
@@ -178,10 +178,12 @@ func (h *CalDAVHandler) propfindCalendarHome(w http.ResponseWriter, r *http.Requ
         <D:resourcetype>
           <D:collection/>
         </D:resourcetype>
+        <C:calendar-free-busy-set>%s
+        </C:calendar-free-busy-set>
       </D:prop>
       <D:status>HTTP/1.1 200 OK</D:status>
     </D:propstat>
-  </D:response>`, userEmail))
+  </D:response>`, userEmail, freeBusySetHrefs(userEmail, userID, calendars)))
 
 	if depth != "0" {
 		for _, cal := range calendars {
@@ -195,7 +197,10 @@ func (h *CalDAVHandler) propfindCalendarHome(w http.ResponseWriter, r *http.Requ
           <C:calendar/>
         </D:resourcetype>
         <D:displayname>%s</D:displayname>
+        <C:calendar-description>%s</C:calendar-description>
         <A:calendar-color>%s</A:calendar-color>
+        <A:calendar-order>%d</A:calendar-order>
+        <C:schedule-calendar-transp>%s</C:schedule-calendar-transp>
         <C:calendar-timezone>%s</C:calendar-timezone>
         <D:getctag>%s</D:getctag>
         <C:supported-calendar-component-set>
@@ -204,7 +209,8 @@ func (h *CalDAVHandler) propfindCalendarHome(w http.ResponseWriter, r *http.Requ
       </D:prop>
       <D:status>HTTP/1.1 200 OK</D:status>
     </D:propstat>
-  </D:response>`, userEmail, cal.ID, xmlEscape(cal.Name), cal.Color, cal.Timezone, cal.SyncToken))
+  </D:response>`, userEmail, cal.ID, xmlEscape(cal.Name), xmlEscape(cal.Description), caldavColor(cal.Color), cal.Order,
+				scheduleTransp(cal), cal.Timezone, cal.SyncToken))
 		}
 	}
 
@@ -253,12 +259,16 @@ func (h *CalDAVHandler) propfindCalendar(w http.ResponseWriter, r *http.Request,
           <C:calendar/>
         </D:resourcetype>
         <D:displayname>%s</D:displayname>
+        <C:calendar-description>%s</C:calendar-description>
         <A:calendar-color>%s</A:calendar-color>
+        <A:calendar-order>%d</A:calendar-order>
+        <C:schedule-calendar-transp>%s</C:schedule-calendar-transp>
         <D:getctag>%s</D:getctag>
       </D:prop>
       <D:status>HTTP/1.1 200 OK</D:status>
     </D:propstat>
-  </D:response>`, userEmail, calendarID, xmlEscape(calendar.Name), calendar.Color, calendar.SyncToken))
+  </D:response>`, userEmail, calendarID, xmlEscape(calendar.Name), xmlEscape(calendar.Description), caldavColor(calendar.Color),
+		calendar.Order, scheduleTransp(calendar), calendar.SyncToken))
 
 	// If depth > 0, include events
 	if depth != "0" {
@@ -313,6 +323,8 @@ func (h *CalDAVHandler) handleReport(w http.ResponseWriter, r *http.Request) {
 		h.handleCalendarQuery(w, r, userID, body)
 	} else if bytes.Contains(body, []byte("sync-collection")) {
 		h.handleSyncCollection(w, r, userID, body)
+	} else if bytes.Contains(body, []byte("free-busy-query")) {
+		h.handleFreeBusyQuery(w, r, userID, body)
 	} else {
 		http.Error(w, "Unsupported report", http.StatusBadRequest)
 	}
@@ -407,7 +419,7 @@ func (h *CalDAVHandler) handleCalendarQuery(w http.ResponseWriter, r *http.Reque
 	responses.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
 <D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`)
 
-	for _, event := range result.Events {
+	for _, event := range h.service.AttachOverrides(r.Context(), result.Events) {
 		ical := eventToICal(event)
 		responses.WriteString(fmt.Sprintf(`
   <D:response>
@@ -480,6 +492,37 @@ func (h *CalDAVHandler) handleSyncCollection(w http.ResponseWriter, r *http.Requ
 	h.sendMultistatus(w, responses.String())
 }
 
+// handleFreeBusyQuery answers a free-busy-query REPORT (RFC 4791 section 7.10)
+// with a VFREEBUSY covering every calendar owned by the target principal
+func (h *CalDAVHandler) handleFreeBusyQuery(w http.ResponseWriter, r *http.Request, userID uuid.UUID, body []byte) {
+	userEmail := r.Context().Value("user_email").(string)
+
+	// Only the authenticated principal's own free/busy is served
+	path := strings.TrimPrefix(r.URL.Path, "/caldav")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 0 || !strings.EqualFold(parts[0], userEmail) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	start, end, err := extractTimeRange(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	periods, err := h.service.GetBusyPeriods(r.Context(), userID, start, end)
+	if err != nil {
+		h.logger.Error("Failed to get free/busy", zap.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(freeBusyToICal(start, end, periods)))
+}
+
 // MKCALENDAR - Create calendar
 func (h *CalDAVHandler) handleMkcalendar(w http.ResponseWriter, r *http.Request) {
 	userID := getUserIDFromContext(r)
@@ -504,6 +547,19 @@ func (h *CalDAVHandler) handleMkcalendar(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Apply color, order and other properties sent with the request
+	var update propertyUpdate
+	if len(body) > 0 && xml.Unmarshal(body, &update) == nil {
+		var req models.UpdateCalendarRequest
+		calendarUpdate(&update, &req)
+		req.Name = nil
+		if (req != models.UpdateCalendarRequest{}) {
+			if _, err := h.service.UpdateCalendar(r.Context(), userID, calendar.ID, &req); err != nil {
+				h.logger.Warn("Failed to set new calendar properties", zap.Error(err))
+			}
+		}
+	}
+
 	w.Header().Set("Location", fmt.Sprintf("/caldav/%s/calendars/%s/",
 		r.Context().Value("user_email"), calendar.ID))
 	w.WriteHeader(http.StatusCreated)
@@ -583,7 +639,7 @@ func (h *CalDAVHandler) handlePut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	event, err := parseICal(string(body))
+	event, overrides, err := parseICal(string(body))
 	if err != nil {
 		h.logger.Error("Failed to parse iCalendar", zap.Error(err))
 		http.Error(w, "Invalid iCalendar", http.StatusBadRequest)
@@ -591,7 +647,7 @@ func (h *CalDAVHandler) handlePut(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create or update
-	if err := h.service.CreateOrUpdateEvent(r.Context(), userID, calendarID, uid, event); err != nil {
+	if err := h.service.CreateOrUpdateEvent(r.Context(), userID, calendarID, uid, event, overrides); err != nil {
 		h.logger.Error("Failed to save event", zap.Error(err))
 		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
@@ -633,11 +689,6 @@ func (h *CalDAVHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *CalDAVHandler) handleProppatch(w http.ResponseWriter, r *http.Request) {
-	// Simplified PROPPATCH implementation
-	w.WriteHeader(http.StatusOK)
-}
-
 // Helper functions
 
 func (h *CalDAVHandler) sendMultistatus(w http.ResponseWriter, body string) {
@@ -718,19 +769,89 @@ func extractDisplayName(body []byte) string {
 	return s[:end]
 }
 
+// extractTimeRange reads the required time-range element of a free-busy-query
+func extractTimeRange(body []byte) (time.Time, time.Time, error) {
+	var query struct {
+		TimeRange *struct {
+			Start string `xml:"start,attr"`
+			End   string `xml:"end,attr"`
+		} `xml:"time-range"`
+	}
+	if err := xml.Unmarshal(body, &query); err != nil || query.TimeRange == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("free-busy-query requires a time-range")
+	}
+
+	start, err := time.Parse("20060102T150405Z", query.TimeRange.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid time-range start: %q", query.TimeRange.Start)
+	}
+
+	// An omitted end means an unbounded range; cap it at a year
+	end := start.AddDate(1, 0, 0)
+	if query.TimeRange.End != "" {
+		end, err = time.Parse("20060102T150405Z", query.TimeRange.End)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid time-range end: %q", query.TimeRange.End)
+		}
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("time-range end must be after start")
+	}
+
+	return start, end, nil
+}
+
+func freeBusyToICal(start, end time.Time, periods []*models.FreeBusyPeriod) string {
+	var ical strings.Builder
+	ical.WriteString("BEGIN:VCALENDAR\r\n")
+	ical.WriteString("VERSION:2.0\r\n")
+	ical.WriteString("PRODID:-//OonruMail//Calendar//EN\r\n")
+	ical.WriteString("BEGIN:VFREEBUSY\r\n")
+	ical.WriteString(fmt.Sprintf("DTSTAMP:%s\r\n", time.Now().UTC().Format("20060102T150405Z")))
+	ical.WriteString(fmt.Sprintf("DTSTART:%s\r\n", start.UTC().Format("20060102T150405Z")))
+	ical.WriteString(fmt.Sprintf("DTEND:%s\r\n", end.UTC().Format("20060102T150405Z")))
+
+	for _, p := range periods {
+		ical.WriteString(fmt.Sprintf("FREEBUSY;FBTYPE=%s:%s/%s\r\n",
+			strings.ToUpper(p.Type),
+			p.Start.UTC().Format("20060102T150405Z"),
+			p.End.UTC().Format("20060102T150405Z")))
+	}
+
+	ical.WriteString("END:VFREEBUSY\r\n")
+	ical.WriteString("END:VCALENDAR\r\n")
+
+	return ical.String()
+}
+
+// eventToICal serializes an event as an iCalendar resource: one VEVENT for
+// the event and one for each of its overrides
 func eventToICal(event *models.Event) string {
+	var ical strings.Builder
+	ical.WriteString("BEGIN:VCALENDAR\r\n")
+	ical.WriteString("VERSION:2.0\r\n")
+	ical.WriteString("PRODID:-//OonruMail//Calendar//EN\r\n")
+	writeVEvent(&ical, event)
+	for _, override := range event.Overrides {
+		writeVEvent(&ical, override)
+	}
+	ical.WriteString("END:VCALENDAR\r\n")
+
+	return ical.String()
+}
+
+func writeVEvent(ical *strings.Builder, event *models.Event) {
 	startStr := event.StartTime.UTC().Format("20060102T150405Z")
 	endStr := event.EndTime.UTC().Format("20060102T150405Z")
 	createdStr := event.CreatedAt.UTC().Format("20060102T150405Z")
 	modifiedStr := event.UpdatedAt.UTC().Format("20060102T150405Z")
 
-	var ical strings.Builder
-	ical.WriteString("BEGIN:VCALENDAR\r\n")
-	ical.WriteString("VERSION:2.0\r\n")
-	ical.WriteString("PRODID:-//OonruMail//Calendar//EN\r\n")
 	ical.WriteString("BEGIN:VEVENT\r\n")
 	ical.WriteString(fmt.Sprintf("UID:%s\r\n", event.UID))
 	ical.WriteString(fmt.Sprintf("DTSTAMP:%s\r\n", modifiedStr))
+	if event.RecurrenceID != nil {
+		ical.WriteString(fmt.Sprintf("RECURRENCE-ID:%s\r\n", event.RecurrenceID.UTC().Format("20060102T150405Z")))
+	}
 	ical.WriteString(fmt.Sprintf("DTSTART:%s\r\n", startStr))
 	ical.WriteString(fmt.Sprintf("DTEND:%s\r\n", endStr))
 	ical.WriteString(fmt.Sprintf("SUMMARY:%s\r\n", foldLine(event.Title)))
@@ -748,6 +869,12 @@ func eventToICal(event *models.Event) string {
 	if event.RecurrenceRule != "" {
 		ical.WriteString(fmt.Sprintf("RRULE:%s\r\n", event.RecurrenceRule))
 	}
+	for _, t := range event.RecurrenceDates {
+		ical.WriteString(fmt.Sprintf("RDATE:%s\r\n", t.UTC().Format("20060102T150405Z")))
+	}
+	for _, t := range event.ExceptionDates {
+		ical.WriteString(fmt.Sprintf("EXDATE:%s\r\n", t.UTC().Format("20060102T150405Z")))
+	}
 
 	// Add attendees
 	for _, att := range event.Attendees {
@@ -759,19 +886,52 @@ func eventToICal(event *models.Event) string {
 			partstat, att.Name, att.Email))
 	}
 
-	ical.WriteString("END:VEVENT\r\n")
-	ical.WriteString("END:VCALENDAR\r\n")
+	for _, a := range event.Files {
+		ical.WriteString(service.AttachLine(a) + "\r\n")
+	}
 
-	return ical.String()
+	ical.WriteString("END:VEVENT\r\n")
 }
 
-func parseICal(ical string) (*models.Event, error) {
-	event := &models.Event{}
+// parseICal parses the VEVENTs of an iCalendar resource: the event itself
+// and its overrides, the VEVENTs with a RECURRENCE-ID. Properties of
+// components nested in a VEVENT, such as VALARM, are skipped.
+func parseICal(ical string) (*models.Event, []*models.Event, error) {
+	var master *models.Event
+	var overrides []*models.Event
+	var event *models.Event
+	nested := 0
 
 	lines := strings.Split(ical, "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 
+		switch {
+		case line == "BEGIN:VEVENT" && event == nil:
+			event = &models.Event{}
+			continue
+		case event == nil:
+			continue
+		case strings.HasPrefix(line, "BEGIN:"):
+			nested++
+			continue
+		case line == "END:VEVENT" && nested == 0:
+			if event.RecurrenceID != nil {
+				overrides = append(overrides, event)
+			} else if master == nil {
+				master = event
+			} else {
+				return nil, nil, fmt.Errorf("more than one VEVENT without RECURRENCE-ID")
+			}
+			event = nil
+			continue
+		case strings.HasPrefix(line, "END:"):
+			nested--
+			continue
+		case nested > 0:
+			continue
+		}
+
 		if strings.HasPrefix(line, "SUMMARY:") {
 			event.Title = strings.TrimPrefix(line, "SUMMARY:")
 		} else if strings.HasPrefix(line, "DESCRIPTION:") {
@@ -782,24 +942,38 @@ func parseICal(ical string) (*models.Event, error) {
 			event.StartTime = parseICalDateTime(line)
 		} else if strings.HasPrefix(line, "DTEND") {
 			event.EndTime = parseICalDateTime(line)
+		} else if strings.HasPrefix(line, "RECURRENCE-ID") {
+			if t := parseICalDateTime(line); !t.IsZero() {
+				event.RecurrenceID = &t
+			}
 		} else if strings.HasPrefix(line, "RRULE:") {
 			event.RecurrenceRule = strings.TrimPrefix(line, "RRULE:")
+		} else if strings.HasPrefix(line, "RDATE") {
+			event.RecurrenceDates = append(event.RecurrenceDates, parseICalDateTimeList(line)...)
+		} else if strings.HasPrefix(line, "EXDATE") {
+			event.ExceptionDates = append(event.ExceptionDates, parseICalDateTimeList(line)...)
 		} else if strings.HasPrefix(line, "STATUS:") {
 			event.Status = models.EventStatus(strings.ToLower(strings.TrimPrefix(line, "STATUS:")))
 		}
 	}
 
-	if event.Status == "" {
-		event.Status = models.EventStatusConfirmed
+	if master == nil {
+		return nil, nil, fmt.Errorf("no VEVENT without RECURRENCE-ID")
 	}
-	if event.Visibility == "" {
-		event.Visibility = "private"
-	}
-	if event.Transparency == "" {
-		event.Transparency = "opaque"
+
+	for _, e := range append([]*models.Event{master}, overrides...) {
+		if e.Status == "" {
+			e.Status = models.EventStatusConfirmed
+		}
+		if e.Visibility == "" {
+			e.Visibility = "private"
+		}
+		if e.Transparency == "" {
+			e.Transparency = "opaque"
+		}
 	}
 
-	return event, nil
+	return master, overrides, nil
 }
 
 func parseICalDateTime(line string) time.Time {
@@ -827,6 +1001,23 @@ func parseICalDateTime(line string) time.Time {
 	return time.Time{}
 }
 
+// parseICalDateTimeList parses the comma-separated values of an RDATE or
+// EXDATE line, skipping any it can't parse
+func parseICalDateTimeList(line string) []time.Time {
+	idx := strings.LastIndex(line, ":")
+	if idx == -1 {
+		return nil
+	}
+
+	var times []time.Time
+	for _, value := range strings.Split(line[idx+1:], ",") {
+		if t := parseICalDateTime(":" + value); !t.IsZero() {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
 func foldLine(s string) string {
 	// iCalendar line folding (simplified)
 	s = strings.ReplaceAll(s, "\n", "\\n")
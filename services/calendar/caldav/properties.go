@@ -0,0 +1,313 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"calendar-service/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// XML namespaces of the properties clients set on calendars
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsApple  = "http://apple.com/ns/ical/"
+)
+
+// defaultCalendarColor is used when a client removes calendar-color
+const defaultCalendarColor = "#3B82F6FF"
+
+var hexColorPattern = regexp.MustCompile(`^#([0-9A-Fa-f]{3}|[0-9A-Fa-f]{6}|[0-9A-Fa-f]{8})$`)
+
+// propertyUpdate is the body of a PROPPATCH (RFC 4918 section 14.19), and of
+// a MKCALENDAR, which uses the same set/prop structure
+type propertyUpdate struct {
+	Set    []propertyAction `xml:"DAV: set"`
+	Remove []propertyAction `xml:"DAV: remove"`
+}
+
+type propertyAction struct {
+	Prop struct {
+		Props []property `xml:",any"`
+	} `xml:"DAV: prop"`
+}
+
+// property is one property element with its text value and any hrefs it
+// contains (calendar-free-busy-set)
+type property struct {
+	XMLName xml.Name
+	Value   string    `xml:",chardata"`
+	Hrefs   []string  `xml:"DAV: href"`
+	Opaque  *struct{} `xml:"urn:ietf:params:xml:ns:caldav opaque"`
+}
+
+// propStatus is the outcome of setting or removing one property
+type propStatus struct {
+	name   xml.Name
+	status int
+}
+
+// caldavColor returns a calendar color as #RRGGBBAA, the form Apple clients
+// send and expect back
+func caldavColor(color string) string {
+	if !hexColorPattern.MatchString(color) {
+		return defaultCalendarColor
+	}
+	hex := strings.ToUpper(color[1:])
+	switch len(hex) {
+	case 3:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]}) + "FF"
+	case 6:
+		hex += "FF"
+	}
+	return "#" + hex
+}
+
+// scheduleTransp returns the schedule-calendar-transp value (RFC 6638) of a
+// calendar: opaque calendars count toward free/busy
+func scheduleTransp(cal *models.Calendar) string {
+	if cal.IncludeInFreeBusy {
+		return "<C:opaque/>"
+	}
+	return "<C:transparent/>"
+}
+
+// freeBusySetHrefs lists the user's own calendars that count toward free/busy
+// as calendar-free-busy-set hrefs
+func freeBusySetHrefs(userEmail string, userID uuid.UUID, calendars []*models.Calendar) string {
+	var b strings.Builder
+	for _, cal := range calendars {
+		if cal.UserID == userID && cal.IncludeInFreeBusy {
+			fmt.Fprintf(&b, `
+          <D:href>/caldav/%s/calendars/%s/</D:href>`, userEmail, cal.ID)
+		}
+	}
+	return b.String()
+}
+
+// calendarUpdate applies the supported calendar collection properties in a
+// property update to req, returning the status of each property
+func calendarUpdate(update *propertyUpdate, req *models.UpdateCalendarRequest) []propStatus {
+	var statuses []propStatus
+	for _, action := range update.Set {
+		for _, p := range action.Prop.Props {
+			statuses = append(statuses, propStatus{p.XMLName, setCalendarProperty(p, req)})
+		}
+	}
+	for _, action := range update.Remove {
+		for _, p := range action.Prop.Props {
+			statuses = append(statuses, propStatus{p.XMLName, removeCalendarProperty(p, req)})
+		}
+	}
+	return statuses
+}
+
+func setCalendarProperty(p property, req *models.UpdateCalendarRequest) int {
+	value := strings.TrimSpace(p.Value)
+
+	switch p.XMLName {
+	case xml.Name{Space: nsDAV, Local: "displayname"}:
+		if value == "" || len(value) > 100 {
+			return http.StatusConflict
+		}
+		req.Name = &value
+	case xml.Name{Space: nsCalDAV, Local: "calendar-description"}:
+		req.Description = &value
+	case xml.Name{Space: nsApple, Local: "calendar-color"}:
+		if !hexColorPattern.MatchString(value) {
+			return http.StatusConflict
+		}
+		color := caldavColor(value)
+		req.Color = &color
+	case xml.Name{Space: nsApple, Local: "calendar-order"}:
+		order, err := strconv.Atoi(value)
+		if err != nil {
+			return http.StatusConflict
+		}
+		req.Order = &order
+	case xml.Name{Space: nsCalDAV, Local: "schedule-calendar-transp"}:
+		include := p.Opaque != nil
+		req.IncludeInFreeBusy = &include
+	default:
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+func removeCalendarProperty(p property, req *models.UpdateCalendarRequest) int {
+	switch p.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-description"}:
+		empty := ""
+		req.Description = &empty
+	case xml.Name{Space: nsApple, Local: "calendar-color"}:
+		color := defaultCalendarColor
+		req.Color = &color
+	case xml.Name{Space: nsApple, Local: "calendar-order"}:
+		order := 0
+		req.Order = &order
+	case xml.Name{Space: nsCalDAV, Local: "schedule-calendar-transp"}:
+		include := true
+		req.IncludeInFreeBusy = &include
+	default:
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+// PROPPATCH - Set calendar properties. Each property gets its own status, so
+// an unsupported one is refused (403) without failing the others.
+func (h *CalDAVHandler) handleProppatch(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == uuid.Nil {
+		h.sendUnauthorized(w)
+		return
+	}
+
+	var update propertyUpdate
+	if err := xml.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&update); err != nil {
+		http.Error(w, "Invalid PROPPATCH body", http.StatusBadRequest)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/caldav")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(parts) == 2 && parts[1] == "calendars":
+		h.proppatchCalendarHome(w, r, userID, &update)
+	case len(parts) == 3 && parts[1] == "calendars":
+		calendarID, err := uuid.Parse(parts[2])
+		if err != nil {
+			http.Error(w, "Invalid calendar ID", http.StatusBadRequest)
+			return
+		}
+		h.proppatchCalendar(w, r, userID, calendarID, &update)
+	default:
+		http.Error(w, "Properties of this resource can't be changed", http.StatusForbidden)
+	}
+}
+
+func (h *CalDAVHandler) proppatchCalendar(w http.ResponseWriter, r *http.Request, userID, calendarID uuid.UUID, update *propertyUpdate) {
+	calendar, err := h.service.GetCalendar(r.Context(), userID, calendarID)
+	if err != nil || calendar == nil {
+		http.Error(w, "Calendar not found", http.StatusNotFound)
+		return
+	}
+
+	var req models.UpdateCalendarRequest
+	statuses := calendarUpdate(update, &req)
+
+	if (req != models.UpdateCalendarRequest{}) {
+		if _, err := h.service.UpdateCalendar(r.Context(), userID, calendarID, &req); err != nil {
+			if strings.Contains(err.Error(), "access denied") {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			h.logger.Error("Failed to update calendar properties", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	userEmail := r.Context().Value("user_email").(string)
+	h.sendPropstat(w, fmt.Sprintf("/caldav/%s/calendars/%s/", userEmail, calendarID), statuses)
+}
+
+// proppatchCalendarHome handles calendar-free-busy-set on the calendar home:
+// the listed calendars count toward free/busy and the user's other calendars
+// don't
+func (h *CalDAVHandler) proppatchCalendarHome(w http.ResponseWriter, r *http.Request, userID uuid.UUID, update *propertyUpdate) {
+	userEmail := r.Context().Value("user_email").(string)
+	freeBusySet := xml.Name{Space: nsCalDAV, Local: "calendar-free-busy-set"}
+
+	var statuses []propStatus
+	var hrefs []string
+	changeFreeBusy := false
+	for _, action := range update.Set {
+		for _, p := range action.Prop.Props {
+			if p.XMLName != freeBusySet {
+				statuses = append(statuses, propStatus{p.XMLName, http.StatusForbidden})
+				continue
+			}
+			hrefs, changeFreeBusy = p.Hrefs, true
+			statuses = append(statuses, propStatus{p.XMLName, http.StatusOK})
+		}
+	}
+	for _, action := range update.Remove {
+		for _, p := range action.Prop.Props {
+			statuses = append(statuses, propStatus{p.XMLName, http.StatusForbidden})
+		}
+	}
+
+	if changeFreeBusy {
+		included := make(map[uuid.UUID]bool, len(hrefs))
+		for _, href := range hrefs {
+			parts := strings.Split(strings.Trim(href, "/"), "/")
+			if id, err := uuid.Parse(parts[len(parts)-1]); err == nil {
+				included[id] = true
+			}
+		}
+
+		calendars, err := h.service.ListCalendars(r.Context(), userID)
+		if err != nil {
+			h.logger.Error("Failed to list calendars", zap.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		for _, cal := range calendars {
+			include := included[cal.ID]
+			if cal.UserID != userID || cal.IncludeInFreeBusy == include {
+				continue
+			}
+			if _, err := h.service.UpdateCalendar(r.Context(), userID, cal.ID, &models.UpdateCalendarRequest{IncludeInFreeBusy: &include}); err != nil {
+				h.logger.Error("Failed to update free-busy set", zap.Error(err))
+				http.Error(w, "Internal error", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	h.sendPropstat(w, fmt.Sprintf("/caldav/%s/calendars/", userEmail), statuses)
+}
+
+// sendPropstat writes a PROPPATCH multistatus with one propstat per status
+func (h *CalDAVHandler) sendPropstat(w http.ResponseWriter, href string, statuses []propStatus) {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response>
+    <D:href>%s</D:href>`, xmlEscape(href))
+
+	for _, status := range []int{http.StatusOK, http.StatusForbidden, http.StatusConflict} {
+		var props strings.Builder
+		for _, s := range statuses {
+			if s.status == status {
+				fmt.Fprintf(&props, `
+        <%s xmlns="%s"/>`, s.name.Local, xmlEscape(s.name.Space))
+			}
+		}
+		if props.Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `
+    <D:propstat>
+      <D:prop>%s
+      </D:prop>
+      <D:status>HTTP/1.1 %d %s</D:status>
+    </D:propstat>`, props.String(), status, http.StatusText(status))
+	}
+
+	b.WriteString(`
+  </D:response>
+</D:multistatus>`)
+
+	h.sendMultistatus(w, b.String())
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,6 +15,8 @@ type Config struct {
 	Notification  NotificationConfig `yaml:"notification"`
 	SMTP          SMTPConfig         `yaml:"smtp"`
 	Notifications NotificationsConfig `yaml:"notifications"`
+	Attachments   AttachmentsConfig   `yaml:"attachments"`
+	Reminders     RemindersConfig     `yaml:"reminders"`
 }
 
 type ServerConfig struct {
@@ -49,6 +52,32 @@ type NotificationsConfig struct {
 	FromName  string `yaml:"fromName"`
 }
 
+// AttachmentsConfig controls event attachments, which are kept in the
+// storage service. Attachments up to InlineMaxSize are embedded in iCalendar
+// data; larger ones are linked with a signed URL under PublicURL, valid for
+// LinkTTL.
+type AttachmentsConfig struct {
+	StorageURL    string        `yaml:"storageURL"`
+	PublicURL     string        `yaml:"publicURL"`
+	MaxSize       int64         `yaml:"maxSize"`
+	InlineMaxSize int64         `yaml:"inlineMaxSize"`
+	LinkSecret    string        `yaml:"linkSecret"`
+	LinkTTL       time.Duration `yaml:"linkTTL"`
+}
+
+// RemindersConfig controls the reminder worker, which looks for due
+// reminders every Interval. A reminder more than GracePeriod late, when the
+// service was down, is skipped. SMS reminders go through the sms-gateway
+// service; push reminders are posted to PushURL, signed with PushSecret.
+type RemindersConfig struct {
+	Interval      time.Duration `yaml:"interval"`
+	GracePeriod   time.Duration `yaml:"gracePeriod"`
+	SMSGatewayURL string        `yaml:"smsGatewayURL"`
+	SMSAPIKey     string        `yaml:"smsAPIKey"`
+	PushURL       string        `yaml:"pushURL"`
+	PushSecret    string        `yaml:"pushSecret"`
+}
+
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -83,6 +112,21 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Notification.ReminderLookAhead == 0 {
 		cfg.Notification.ReminderLookAhead = 15
 	}
+	if cfg.Attachments.MaxSize == 0 {
+		cfg.Attachments.MaxSize = 25 << 20
+	}
+	if cfg.Attachments.InlineMaxSize == 0 {
+		cfg.Attachments.InlineMaxSize = 64 << 10
+	}
+	if cfg.Attachments.LinkTTL == 0 {
+		cfg.Attachments.LinkTTL = 30 * 24 * time.Hour
+	}
+	if cfg.Reminders.Interval == 0 {
+		cfg.Reminders.Interval = time.Minute
+	}
+	if cfg.Reminders.GracePeriod == 0 {
+		cfg.Reminders.GracePeriod = 15 * time.Minute
+	}
 
 	return &cfg, nil
 }
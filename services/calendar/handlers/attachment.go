@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// UploadAttachment attaches the file in the "file" field of a multipart
+// form to an event
+func (h *CalendarHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	eventID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	maxSize := h.service.MaxAttachmentSize()
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20) // Room for the multipart framing
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "multipart form expected")
+		return
+	}
+
+	var data []byte
+	var filename, contentType string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			respondAttachmentReadError(w, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		filename = cleanFilename(part.FileName())
+		contentType = part.Header.Get("Content-Type")
+		data, err = io.ReadAll(io.LimitReader(part, maxSize+1))
+		part.Close()
+		if err != nil {
+			respondAttachmentReadError(w, err)
+			return
+		}
+		break
+	}
+
+	if filename == "" {
+		respondError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	if int64(len(data)) > maxSize {
+		respondError(w, http.StatusRequestEntityTooLarge, "attachment too large")
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	} else {
+		contentType = http.DetectContentType(data)
+		contentType, _, _ = strings.Cut(contentType, ";")
+	}
+
+	attachment, err := h.service.AddAttachment(r.Context(), userID, eventID, filename, contentType, data)
+	if err != nil {
+		h.respondAttachmentError(w, err, "Failed to add attachment")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, attachment)
+}
+
+func (h *CalendarHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	eventID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	attachments, err := h.service.ListAttachments(r.Context(), userID, eventID)
+	if err != nil {
+		h.respondAttachmentError(w, err, "Failed to list attachments")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"attachments": attachments,
+	})
+}
+
+func (h *CalendarHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	eventID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+	attachmentID, err := uuid.Parse(chi.URLParam(r, "attachmentId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid attachment id")
+		return
+	}
+
+	if err := h.service.DeleteAttachment(r.Context(), userID, eventID, attachmentID); err != nil {
+		h.respondAttachmentError(w, err, "Failed to delete attachment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DownloadAttachment serves the signed attachment links written into
+// iCalendar data by redirecting to the content in the storage service
+func (h *CalendarHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := uuid.Parse(chi.URLParam(r, "attachmentId"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "attachment not found")
+		return
+	}
+
+	q := r.URL.Query()
+	url, err := h.service.AttachmentDownloadURL(r.Context(), attachmentID, q.Get("expires"), q.Get("sig"))
+	if err != nil {
+		h.respondAttachmentError(w, err, "Failed to sign attachment download")
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func (h *CalendarHandler) respondAttachmentError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "access denied", "invalid link":
+		respondError(w, http.StatusForbidden, err.Error())
+	case "event not found", "attachment not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case "attachment too large":
+		respondError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case "attachments are not enabled":
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		respondError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func respondAttachmentReadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondError(w, http.StatusRequestEntityTooLarge, "attachment too large")
+		return
+	}
+	respondError(w, http.StatusBadRequest, "invalid multipart form")
+}
+
+// cleanFilename keeps the last path element of an uploaded file's name and
+// drops control characters, which can't appear in an iCalendar parameter
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
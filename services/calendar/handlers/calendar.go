@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -308,6 +309,15 @@ func (h *CalendarHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if expandStr := r.URL.Query().Get("expand"); expandStr != "" {
+		expand, err := strconv.ParseBool(expandStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid expand")
+			return
+		}
+		req.Expand = expand
+	}
+
 	result, err := h.service.ListEvents(r.Context(), userID, &req)
 	if err != nil {
 		if err.Error() == "access denied" {
@@ -453,6 +463,85 @@ func (h *CalendarHandler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// UpdateOccurrence edits one occurrence of a recurring event, or with
+// this_and_following it and every later one
+func (h *CalendarHandler) UpdateOccurrence(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	eventID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+	recurrenceID, err := parseRecurrenceID(chi.URLParam(r, "recurrenceId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid recurrence id")
+		return
+	}
+
+	var req models.UpdateOccurrenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	event, err := h.service.UpdateOccurrence(r.Context(), userID, eventID, recurrenceID, &req)
+	if err != nil {
+		h.respondOccurrenceError(w, err, "Failed to update occurrence")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, event)
+}
+
+// DeleteOccurrence removes one occurrence of a recurring event
+func (h *CalendarHandler) DeleteOccurrence(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	eventID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+	recurrenceID, err := parseRecurrenceID(chi.URLParam(r, "recurrenceId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid recurrence id")
+		return
+	}
+
+	notifyAttendees := r.URL.Query().Get("notify") != "false"
+
+	if err := h.service.DeleteOccurrence(r.Context(), userID, eventID, recurrenceID, notifyAttendees); err != nil {
+		h.respondOccurrenceError(w, err, "Failed to delete occurrence")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CalendarHandler) respondOccurrenceError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "access denied":
+		respondError(w, http.StatusForbidden, err.Error())
+	case "event not found", "occurrence not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case "event is not recurring", "an occurrence can't have its own recurrence":
+		respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// parseRecurrenceID parses an occurrence's original start, as RFC 3339 or
+// an iCalendar DATE-TIME or DATE
+func parseRecurrenceID(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "20060102T150405Z", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid recurrence id: %s", s)
+}
+
 func (h *CalendarHandler) RespondToEvent(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	eventID, err := uuid.Parse(chi.URLParam(r, "id"))
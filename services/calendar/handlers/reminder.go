@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"calendar-service/models"
+	"calendar-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetMyReminders returns the caller's own reminders for an event
+func (h *CalendarHandler) GetMyReminders(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	eventID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	reminders, err := h.service.GetMyReminders(r.Context(), userID, eventID)
+	if err != nil {
+		h.respondReminderError(w, err, "Failed to get reminders")
+		return
+	}
+	if reminders == nil {
+		reminders = []*models.Reminder{}
+	}
+
+	respondJSON(w, http.StatusOK, reminders)
+}
+
+// SetMyReminders replaces the caller's own reminders for an event
+func (h *CalendarHandler) SetMyReminders(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	eventID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+
+	var req models.SetRemindersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	reminders, err := h.service.SetMyReminders(r.Context(), userID, eventID, req.Reminders)
+	if err != nil {
+		h.respondReminderError(w, err, "Failed to set reminders")
+		return
+	}
+	if reminders == nil {
+		reminders = []*models.Reminder{}
+	}
+
+	respondJSON(w, http.StatusOK, reminders)
+}
+
+// SnoozeReminder fires one of the caller's reminders again later
+func (h *CalendarHandler) SnoozeReminder(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	reminderID, err := uuid.Parse(chi.URLParam(r, "reminderId"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid reminder id")
+		return
+	}
+
+	var req models.SnoozeReminderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	reminder, err := h.service.SnoozeReminder(r.Context(), userID, reminderID, &req)
+	if err != nil {
+		h.respondReminderError(w, err, "Failed to snooze reminder")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, reminder)
+}
+
+// GetReminderPreferences returns the caller's reminder preferences
+func (h *CalendarHandler) GetReminderPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, err := h.service.GetReminderPreferences(r.Context(), getUserID(r))
+	if err != nil {
+		h.respondReminderError(w, err, "Failed to get reminder preferences")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, prefs)
+}
+
+// UpdateReminderPreferences replaces the caller's reminder preferences
+func (h *CalendarHandler) UpdateReminderPreferences(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateReminderPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	prefs, err := h.service.UpdateReminderPreferences(r.Context(), getUserID(r), &req)
+	if err != nil {
+		h.respondReminderError(w, err, "Failed to update reminder preferences")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, prefs)
+}
+
+func (h *CalendarHandler) respondReminderError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, service.ErrInvalidReminder) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch err.Error() {
+	case "access denied":
+		respondError(w, http.StatusForbidden, err.Error())
+	case "event not found", "reminder not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		respondError(w, http.StatusInternalServerError, "internal error")
+	}
+}
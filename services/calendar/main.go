@@ -57,12 +57,21 @@ func main() {
 	eventRepo := repository.NewEventRepository(dbPool)
 	attendeeRepo := repository.NewAttendeeRepository(dbPool)
 	reminderRepo := repository.NewReminderRepository(dbPool)
+	attachmentRepo := repository.NewAttachmentRepository(dbPool)
 
 	// Initialize notification service
 	notificationService := service.NewNotificationService(cfg, logger.Named("notification-service"))
 
 	// Initialize calendar service
-	calendarService := service.NewCalendarService(calendarRepo, eventRepo, attendeeRepo, reminderRepo, notificationService, logger.Named("calendar-service"))
+	// Event attachments are kept in the storage service
+	attachmentStorage := service.NewAttachmentStorage(cfg.Attachments.StorageURL)
+
+	calendarService := service.NewCalendarService(calendarRepo, eventRepo, attendeeRepo, reminderRepo, attachmentRepo,
+		notificationService, attachmentStorage, cfg.Attachments, logger.Named("calendar-service"))
+
+	// Initialize reminder worker
+	reminderWorker := service.NewReminderWorker(reminderRepo, calendarService, notificationService,
+		cfg.Reminders, logger.Named("reminder-worker"))
 
 	// Initialize handlers
 	calendarHandler := handlers.NewCalendarHandler(calendarService, logger.Named("calendar-handler"))
@@ -100,6 +109,9 @@ func main() {
 	// Metrics
 	r.Handle("/metrics", promhttp.Handler())
 
+	// Attachment links in iCalendar data, authorized by their signature
+	r.Get("/attachments/{attachmentId}", calendarHandler.DownloadAttachment)
+
 	// CalDAV endpoints (RFC 4791)
 	r.Route("/caldav", func(r chi.Router) {
 		r.Use(authMiddleware.Authenticate)
@@ -128,9 +140,23 @@ func main() {
 			r.Get("/{eventId}", calendarHandler.GetEvent)
 			r.Put("/{eventId}", calendarHandler.UpdateEvent)
 			r.Delete("/{eventId}", calendarHandler.DeleteEvent)
+			r.Put("/{eventId}/occurrences/{recurrenceId}", calendarHandler.UpdateOccurrence)
+			r.Delete("/{eventId}/occurrences/{recurrenceId}", calendarHandler.DeleteOccurrence)
 			r.Post("/{eventId}/respond", calendarHandler.RespondToEvent)
+			r.Get("/{eventId}/attachments", calendarHandler.ListAttachments)
+			r.Post("/{eventId}/attachments", calendarHandler.UploadAttachment)
+			r.Delete("/{eventId}/attachments/{attachmentId}", calendarHandler.DeleteAttachment)
 			r.Get("/search", calendarHandler.SearchEvents)
 			r.Get("/freebusy", calendarHandler.GetFreeBusy)
+			r.Get("/{eventId}/reminders", calendarHandler.GetMyReminders)
+			r.Put("/{eventId}/reminders", calendarHandler.SetMyReminders)
+		})
+
+		// Reminders
+		r.Route("/reminders", func(r chi.Router) {
+			r.Get("/preferences", calendarHandler.GetReminderPreferences)
+			r.Put("/preferences", calendarHandler.UpdateReminderPreferences)
+			r.Post("/{reminderId}/snooze", calendarHandler.SnoozeReminder)
 		})
 	})
 
@@ -152,12 +178,17 @@ func main() {
 		}
 	}()
 
+	// Start reminder worker
+	go reminderWorker.Start()
+
 	// Wait for shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
 	logger.Info("Shutting down...")
+	reminderWorker.Stop()
+
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
@@ -0,0 +1,11 @@
+-- iTIP scheduling: SEQUENCE is bumped by the application only for significant
+-- changes (RFC 5546 Section 2.1.4) so attendees see reschedules, not duplicates
+
+CREATE OR REPLACE FUNCTION update_event_etag()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.etag = gen_random_uuid()::text;
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,5 @@
+-- Recurrence dates: RDATE adds instances to a recurring event and EXDATE
+-- removes them (RFC 5545 Section 3.8.5). Both hold instance start times.
+
+ALTER TABLE calendar_events ADD COLUMN IF NOT EXISTS recurrence_dates TIMESTAMP WITH TIME ZONE[] NOT NULL DEFAULT '{}';
+ALTER TABLE calendar_events ADD COLUMN IF NOT EXISTS exception_dates TIMESTAMP WITH TIME ZONE[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+-- Per-calendar properties set by CalDAV clients: color with alpha
+-- (#RRGGBBAA, Apple calendar-color), display order (calendar-order) and
+-- whether the calendar counts toward free/busy (calendar-free-busy-set,
+-- schedule-calendar-transp).
+
+ALTER TABLE calendars ALTER COLUMN color TYPE VARCHAR(9);
+ALTER TABLE calendars ADD COLUMN IF NOT EXISTS sort_order INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE calendars ADD COLUMN IF NOT EXISTS include_in_free_busy BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,15 @@
+-- Recurrence overrides: a single occurrence edited on its own is stored as
+-- its own event with the master's UID, the occurrence's original start as
+-- recurrence_id and original_event_id pointing at the master (RFC 5545
+-- Section 3.8.4.4). A UID now names one master plus any number of
+-- overrides, each for a different occurrence.
+
+ALTER TABLE calendar_events DROP CONSTRAINT IF EXISTS calendar_events_calendar_id_uid_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_calendar_uid_master ON calendar_events(calendar_id, uid)
+    WHERE original_event_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_override ON calendar_events(original_event_id, recurrence_id)
+    WHERE original_event_id IS NOT NULL;
+
+-- An override is always stored with the occurrence it replaces
+ALTER TABLE calendar_events ADD CONSTRAINT calendar_events_override_recurrence_id
+    CHECK (original_event_id IS NULL OR recurrence_id IS NOT NULL);
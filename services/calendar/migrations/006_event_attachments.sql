@@ -0,0 +1,19 @@
+-- Event attachments (RFC 5545 Section 3.8.1.1 ATTACH). The content is kept
+-- in the storage service under storage_id, which holds a deduplicated
+-- reference released when the attachment or its event is deleted.
+-- Attachments small enough to embed in iCalendar data also keep a copy in
+-- inline_data so serializing an event doesn't call the storage service.
+
+CREATE TABLE IF NOT EXISTS event_attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL REFERENCES calendar_events(id) ON DELETE CASCADE,
+    storage_id VARCHAR(255) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    inline_data BYTEA,
+    uploaded_by UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_attachments_event ON event_attachments(event_id);
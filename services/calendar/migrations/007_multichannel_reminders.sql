@@ -0,0 +1,51 @@
+-- Multi-channel reminders
+-- Each reminder now belongs to one user, the organizer or an attendee, and is
+-- delivered by email, SMS or push. trigger_time is the next time it fires,
+-- for the occurrence starting at occurrence_start; the reminder worker moves
+-- both on to the next occurrence of a recurring event after each firing.
+-- A snoozed reminder fires again at snoozed_until for last_fired_occurrence,
+-- the occurrence it last fired for.
+
+ALTER TABLE event_reminders ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE event_reminders ADD COLUMN IF NOT EXISTS occurrence_start TIMESTAMP WITH TIME ZONE;
+ALTER TABLE event_reminders ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE event_reminders ADD COLUMN IF NOT EXISTS last_fired_occurrence TIMESTAMP WITH TIME ZONE;
+
+-- Existing reminders were sent to the organizer
+UPDATE event_reminders r SET user_id = e.organizer_id, occurrence_start = e.start_time
+FROM calendar_events e
+WHERE e.id = r.event_id AND r.user_id IS NULL;
+
+ALTER TABLE event_reminders ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_reminders_user ON event_reminders(event_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_snoozed ON event_reminders(snoozed_until) WHERE snoozed_until IS NOT NULL;
+
+-- Only schedule new reminders for the event's first start; later occurrences
+-- are scheduled by the reminder worker
+CREATE OR REPLACE FUNCTION calculate_reminder_trigger()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.trigger_time IS NULL THEN
+        SELECT e.start_time - (NEW.minutes || ' minutes')::interval, e.start_time
+        INTO NEW.trigger_time, NEW.occurrence_start
+        FROM calendar_events e
+        WHERE e.id = NEW.event_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS calculate_reminder_time ON event_reminders;
+CREATE TRIGGER calculate_reminder_time
+BEFORE INSERT ON event_reminders
+FOR EACH ROW EXECUTE FUNCTION calculate_reminder_trigger();
+
+-- A user's reminder settings: the number SMS reminders go to, and the
+-- reminders added for them when they accept an invitation
+CREATE TABLE IF NOT EXISTS reminder_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    phone_number VARCHAR(32),
+    default_reminders JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -17,8 +17,12 @@ type Calendar struct {
 	IsDefault   bool      `json:"is_default" db:"is_default"`
 	IsPublic    bool      `json:"is_public" db:"is_public"`
 	SyncToken   string    `json:"sync_token" db:"sync_token"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	Order       int       `json:"order" db:"sort_order"`
+	// IncludeInFreeBusy is false for calendars whose events don't make the
+	// owner busy (CalDAV calendar-free-busy-set / schedule-calendar-transp)
+	IncludeInFreeBusy bool      `json:"include_in_free_busy" db:"include_in_free_busy"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // CalendarShare represents calendar sharing with another user
@@ -52,13 +56,29 @@ type Event struct {
 	OriginalEventID *uuid.UUID  `json:"original_event_id" db:"original_event_id"`
 	Reminders       []*Reminder  `json:"reminders" db:"-"`
 	Attachments     []string    `json:"attachments" db:"attachments"`
+	Files           []*EventAttachment `json:"files,omitempty" db:"-"`
 	Categories      []string    `json:"categories" db:"categories"`
 	Sequence        int         `json:"sequence" db:"sequence"` // For iTIP updates
 	ETag            string      `json:"etag" db:"etag"`
 	OrganizerID     uuid.UUID   `json:"organizer_id" db:"organizer_id"`
+	OrganizerEmail  string      `json:"organizer_email,omitempty" db:"-"`
+	OrganizerName   string      `json:"organizer_name,omitempty" db:"-"`
 	Attendees       []*Attendee  `json:"attendees" db:"-"`
 	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
+
+	// Extra instance start times (RDATE) and excluded ones (EXDATE)
+	RecurrenceDates []time.Time `json:"recurrence_dates,omitempty" db:"recurrence_dates"`
+	ExceptionDates  []time.Time `json:"exception_dates,omitempty" db:"exception_dates"`
+
+	// MasterEventID is set on occurrences of a recurring event returned by
+	// expansion; ID is then the master's ID unless the occurrence is an
+	// override stored as its own event
+	MasterEventID *uuid.UUID `json:"master_event_id,omitempty" db:"-"`
+
+	// Overrides are the occurrences of a recurring master that were edited
+	// on their own, each with its RECURRENCE-ID and OriginalEventID set
+	Overrides []*Event `json:"overrides,omitempty" db:"-"`
 }
 
 type EventStatus string
@@ -69,14 +89,72 @@ const (
 	EventStatusCancelled EventStatus = "cancelled"
 )
 
-// Reminder for an event
+// Reminder for an event, delivered to one user: the organizer or an
+// attendee. TriggerTime is when it next fires, for the occurrence starting
+// at OccurrenceStart.
 type Reminder struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	EventID         uuid.UUID  `json:"event_id" db:"event_id"`
+	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	Method          string     `json:"method" db:"method"` // email, sms, push, display, audio
+	Minutes         int        `json:"minutes" db:"minutes"` // Minutes before event
+	TriggerTime     time.Time  `json:"trigger_time" db:"trigger_time"`
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty" db:"occurrence_start"`
+	SnoozedUntil    *time.Time `json:"snoozed_until,omitempty" db:"snoozed_until"`
+	Triggered       bool       `json:"triggered" db:"triggered"`
+}
+
+// Reminder delivery channels
+const (
+	ReminderEmail   = "email"
+	ReminderSMS     = "sms"
+	ReminderPush    = "push"
+	ReminderDisplay = "display"
+	ReminderAudio   = "audio"
+)
+
+// ReminderPreferences are a user's reminder settings. DefaultReminders are
+// added for them when they accept an invitation without reminders of their
+// own.
+type ReminderPreferences struct {
+	UserID           uuid.UUID               `json:"user_id" db:"user_id"`
+	PhoneNumber      string                  `json:"phone_number" db:"phone_number"`
+	DefaultReminders []CreateReminderRequest `json:"default_reminders" db:"default_reminders"`
+	UpdatedAt        time.Time               `json:"updated_at" db:"updated_at"`
+}
+
+// UpdateReminderPreferencesRequest replaces a user's reminder settings
+type UpdateReminderPreferencesRequest struct {
+	PhoneNumber      string                  `json:"phone_number" validate:"omitempty,e164"`
+	DefaultReminders []CreateReminderRequest `json:"default_reminders" validate:"max=5,dive"`
+}
+
+// SetRemindersRequest replaces the caller's own reminders for an event
+type SetRemindersRequest struct {
+	Reminders []CreateReminderRequest `json:"reminders" validate:"max=5,dive"`
+}
+
+// SnoozeReminderRequest fires a reminder again for the occurrence it last
+// fired for, after Minutes or at Until
+type SnoozeReminderRequest struct {
+	Minutes int        `json:"minutes" validate:"omitempty,min=1,max=1440"`
+	Until   *time.Time `json:"until"`
+}
+
+// EventAttachment is a file uploaded to an event and serialized as ATTACH.
+// InlineData is only set for attachments small enough to embed; larger
+// ones are linked with URL.
+type EventAttachment struct {
 	ID          uuid.UUID `json:"id" db:"id"`
 	EventID     uuid.UUID `json:"event_id" db:"event_id"`
-	Method      string    `json:"method" db:"method"` // email, display, audio
-	Minutes     int       `json:"minutes" db:"minutes"` // Minutes before event
-	TriggerTime time.Time `json:"trigger_time" db:"trigger_time"`
-	Triggered   bool      `json:"triggered" db:"triggered"`
+	StorageID   string    `json:"-" db:"storage_id"`
+	Filename    string    `json:"filename" db:"filename"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int64     `json:"size" db:"size"`
+	InlineData  []byte    `json:"-" db:"inline_data"`
+	UploadedBy  uuid.UUID `json:"uploaded_by" db:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	URL         string    `json:"url,omitempty" db:"-"`
 }
 
 // Attendee represents an event attendee
@@ -133,13 +211,17 @@ type EventWithReminder struct {
 	EventID     uuid.UUID `json:"event_id" db:"event_id"`
 	CalendarID  uuid.UUID `json:"calendar_id" db:"calendar_id"`
 	Title       string    `json:"title" db:"title"`
-	StartTime   time.Time `json:"start_time" db:"start_time"`
+	Location    string    `json:"location" db:"location"`
+	StartTime   time.Time `json:"start_time" db:"start_time"` // Of the occurrence reminded of
 	OrganizerID uuid.UUID `json:"organizer_id" db:"organizer_id"`
 	ReminderID  uuid.UUID `json:"reminder_id" db:"reminder_id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
 	Method      string    `json:"method" db:"method"`
 	Minutes     int       `json:"minutes" db:"minutes"`
 	Email       string    `json:"email" db:"email"`
+	PhoneNumber string    `json:"phone_number" db:"phone_number"`
 	TriggerTime time.Time `json:"trigger_time" db:"trigger_time"`
+	Snoozed     bool      `json:"snoozed" db:"-"` // Firing again after a snooze
 }
 
 // CreateCalendarRequest represents a request to create a calendar
@@ -158,6 +240,9 @@ type UpdateCalendarRequest struct {
 	Color       *string `json:"color"`
 	Timezone    *string `json:"timezone"`
 	IsPublic    *bool   `json:"is_public"`
+	Order       *int    `json:"order"`
+
+	IncludeInFreeBusy *bool `json:"include_in_free_busy"`
 }
 
 // CreateEventRequest represents a request to create an event
@@ -177,10 +262,13 @@ type CreateEventRequest struct {
 	Attendees      []CreateAttendeeRequest `json:"attendees"`
 	Categories     []string            `json:"categories"`
 	Attachments    []string            `json:"attachments"`
+
+	RecurrenceDates []time.Time `json:"recurrence_dates"`
+	ExceptionDates  []time.Time `json:"exception_dates"`
 }
 
 type CreateReminderRequest struct {
-	Method  string `json:"method" validate:"required,oneof=email display audio"`
+	Method  string `json:"method" validate:"required,oneof=email sms push display audio"`
 	Minutes int    `json:"minutes" validate:"required,min=0,max=40320"` // Max 4 weeks
 }
 
@@ -205,6 +293,17 @@ type UpdateEventRequest struct {
 	Transparency   *string              `json:"transparency"`
 	RecurrenceRule *string              `json:"recurrence_rule"`
 	Reminders      []CreateReminderRequest `json:"reminders,omitempty"`
+
+	RecurrenceDates *[]time.Time `json:"recurrence_dates"`
+	ExceptionDates  *[]time.Time `json:"exception_dates"`
+}
+
+// UpdateOccurrenceRequest edits one occurrence of a recurring event. With
+// ThisAndFollowing the edit applies to the occurrence and every later one,
+// splitting the series into two recurring events.
+type UpdateOccurrenceRequest struct {
+	UpdateEventRequest
+	ThisAndFollowing bool `json:"this_and_following"`
 }
 
 // RespondRequest represents an RSVP response
@@ -244,6 +343,10 @@ type ListEventsRequest struct {
 	End        time.Time  `json:"end"`
 	Limit      int        `json:"limit"`
 	Offset     int        `json:"offset"`
+
+	// Expand returns the occurrences of recurring events within the range
+	// instead of their stored master events
+	Expand bool `json:"expand"`
 }
 
 // EventListResponse represents a paginated list of events
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+
+	"calendar-service/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AttachmentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAttachmentRepository(db *pgxpool.Pool) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+// Create adds an attachment to an event
+func (r *AttachmentRepository) Create(ctx context.Context, a *models.EventAttachment) error {
+	query := `
+		INSERT INTO event_attachments (id, event_id, storage_id, filename, content_type, size, inline_data, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at`
+
+	return r.db.QueryRow(ctx, query,
+		a.ID,
+		a.EventID,
+		a.StorageID,
+		a.Filename,
+		a.ContentType,
+		a.Size,
+		a.InlineData,
+		a.UploadedBy,
+	).Scan(&a.CreatedAt)
+}
+
+// GetByID gets an attachment by ID
+func (r *AttachmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.EventAttachment, error) {
+	query := `
+		SELECT id, event_id, storage_id, filename, content_type, size, inline_data, uploaded_by, created_at
+		FROM event_attachments
+		WHERE id = $1`
+
+	a := &models.EventAttachment{}
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&a.ID, &a.EventID, &a.StorageID, &a.Filename, &a.ContentType,
+		&a.Size, &a.InlineData, &a.UploadedBy, &a.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetByEventID gets all attachments of an event
+func (r *AttachmentRepository) GetByEventID(ctx context.Context, eventID uuid.UUID) ([]*models.EventAttachment, error) {
+	query := `
+		SELECT id, event_id, storage_id, filename, content_type, size, inline_data, uploaded_by, created_at
+		FROM event_attachments
+		WHERE event_id = $1
+		ORDER BY created_at ASC`
+
+	rows, err := r.db.Query(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*models.EventAttachment
+	for rows.Next() {
+		a := &models.EventAttachment{}
+		if err := rows.Scan(
+			&a.ID, &a.EventID, &a.StorageID, &a.Filename, &a.ContentType,
+			&a.Size, &a.InlineData, &a.UploadedBy, &a.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// Delete deletes an attachment
+func (r *AttachmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM event_attachments WHERE id = $1", id)
+	return err
+}
+
+// GetStorageIDsByEvent returns the storage IDs of the attachments of an
+// event and its recurrence overrides, which go with it when it's deleted
+func (r *AttachmentRepository) GetStorageIDsByEvent(ctx context.Context, eventID uuid.UUID) ([]string, error) {
+	return r.storageIDs(ctx, `
+		SELECT a.storage_id FROM event_attachments a
+		JOIN calendar_events e ON e.id = a.event_id
+		WHERE e.id = $1 OR e.original_event_id = $1`, eventID)
+}
+
+// GetStorageIDsByUID returns the storage IDs of the attachments of every
+// event with a UID in a calendar
+func (r *AttachmentRepository) GetStorageIDsByUID(ctx context.Context, calendarID uuid.UUID, uid string) ([]string, error) {
+	return r.storageIDs(ctx, `
+		SELECT a.storage_id FROM event_attachments a
+		JOIN calendar_events e ON e.id = a.event_id
+		WHERE e.calendar_id = $1 AND e.uid = $2`, calendarID, uid)
+}
+
+// GetStorageIDsByCalendar returns the storage IDs of the attachments of
+// every event in a calendar
+func (r *AttachmentRepository) GetStorageIDsByCalendar(ctx context.Context, calendarID uuid.UUID) ([]string, error) {
+	return r.storageIDs(ctx, `
+		SELECT a.storage_id FROM event_attachments a
+		JOIN calendar_events e ON e.id = a.event_id
+		WHERE e.calendar_id = $1`, calendarID)
+}
+
+func (r *AttachmentRepository) storageIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetStoragePartition returns the organization and primary domain a user's
+// uploads are stored under
+func (r *AttachmentRepository) GetStoragePartition(ctx context.Context, userID uuid.UUID) (uuid.UUID, uuid.UUID, error) {
+	var orgID, domainID uuid.UUID
+	err := r.db.QueryRow(ctx, `
+		SELECT u.organization_id, a.domain_id
+		FROM users u
+		JOIN user_email_addresses a ON a.user_id = u.id
+		WHERE u.id = $1
+		ORDER BY a.is_primary DESC
+		LIMIT 1`, userID).Scan(&orgID, &domainID)
+	return orgID, domainID, err
+}
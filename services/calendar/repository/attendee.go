@@ -133,6 +133,15 @@ func (r *AttendeeRepository) GetByEmail(ctx context.Context, eventID uuid.UUID,
 	return a, err
 }
 
+// IsHostedDomain reports whether domain is a verified domain hosted on this platform
+func (r *AttendeeRepository) IsHostedDomain(ctx context.Context, domain string) (bool, error) {
+	var hosted bool
+	err := r.db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM domains WHERE LOWER(name) = LOWER($1) AND status = 'verified')",
+		domain).Scan(&hosted)
+	return hosted, err
+}
+
 // UpdateStatus updates an attendee's RSVP status
 func (r *AttendeeRepository) UpdateStatus(ctx context.Context, attendeeID uuid.UUID, status string) error {
 	_, err := r.db.Exec(ctx,
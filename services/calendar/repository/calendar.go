@@ -22,8 +22,9 @@ func NewCalendarRepository(db *pgxpool.Pool) *CalendarRepository {
 // Create creates a new calendar
 func (r *CalendarRepository) Create(ctx context.Context, calendar *models.Calendar) error {
 	query := `
-		INSERT INTO calendars (id, user_id, name, description, color, timezone, is_default, is_public)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO calendars (id, user_id, name, description, color, timezone, is_default, is_public,
+		                       sort_order, include_in_free_busy)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING sync_token, created_at, updated_at`
 
 	return r.db.QueryRow(ctx, query,
@@ -35,6 +36,8 @@ func (r *CalendarRepository) Create(ctx context.Context, calendar *models.Calend
 		calendar.Timezone,
 		calendar.IsDefault,
 		calendar.IsPublic,
+		calendar.Order,
+		calendar.IncludeInFreeBusy,
 	).Scan(&calendar.SyncToken, &calendar.CreatedAt, &calendar.UpdatedAt)
 }
 
@@ -42,7 +45,7 @@ func (r *CalendarRepository) Create(ctx context.Context, calendar *models.Calend
 func (r *CalendarRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Calendar, error) {
 	query := `
 		SELECT id, user_id, name, description, color, timezone, is_default, is_public,
-		       sync_token, created_at, updated_at
+		       sync_token, sort_order, include_in_free_busy, created_at, updated_at
 		FROM calendars
 		WHERE id = $1`
 
@@ -57,6 +60,8 @@ func (r *CalendarRepository) GetByID(ctx context.Context, id uuid.UUID) (*models
 		&calendar.IsDefault,
 		&calendar.IsPublic,
 		&calendar.SyncToken,
+		&calendar.Order,
+		&calendar.IncludeInFreeBusy,
 		&calendar.CreatedAt,
 		&calendar.UpdatedAt,
 	)
@@ -70,12 +75,13 @@ func (r *CalendarRepository) GetByID(ctx context.Context, id uuid.UUID) (*models
 func (r *CalendarRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*models.Calendar, error) {
 	query := `
 		SELECT c.id, c.user_id, c.name, c.description, c.color, c.timezone,
-		       c.is_default, c.is_public, c.sync_token, c.created_at, c.updated_at,
+		       c.is_default, c.is_public, c.sync_token, c.sort_order, c.include_in_free_busy,
+		       c.created_at, c.updated_at,
 		       COALESCE(cs.permission, 'owner') as permission
 		FROM calendars c
 		LEFT JOIN calendar_shares cs ON c.id = cs.calendar_id AND cs.user_id = $1
 		WHERE c.user_id = $1 OR cs.user_id = $1
-		ORDER BY c.is_default DESC, c.name ASC`
+		ORDER BY c.is_default DESC, c.sort_order ASC, c.name ASC`
 
 	rows, err := r.db.Query(ctx, query, userID)
 	if err != nil {
@@ -97,6 +103,8 @@ func (r *CalendarRepository) GetByUserID(ctx context.Context, userID uuid.UUID)
 			&cal.IsDefault,
 			&cal.IsPublic,
 			&cal.SyncToken,
+			&cal.Order,
+			&cal.IncludeInFreeBusy,
 			&cal.CreatedAt,
 			&cal.UpdatedAt,
 			&permission,
@@ -112,7 +120,8 @@ func (r *CalendarRepository) GetByUserID(ctx context.Context, userID uuid.UUID)
 func (r *CalendarRepository) Update(ctx context.Context, calendar *models.Calendar) error {
 	query := `
 		UPDATE calendars
-		SET name = $2, description = $3, color = $4, timezone = $5, is_public = $6, updated_at = NOW()
+		SET name = $2, description = $3, color = $4, timezone = $5, is_public = $6,
+		    sort_order = $7, include_in_free_busy = $8, updated_at = NOW()
 		WHERE id = $1
 		RETURNING sync_token, updated_at`
 
@@ -123,6 +132,8 @@ func (r *CalendarRepository) Update(ctx context.Context, calendar *models.Calend
 		calendar.Color,
 		calendar.Timezone,
 		calendar.IsPublic,
+		calendar.Order,
+		calendar.IncludeInFreeBusy,
 	).Scan(&calendar.SyncToken, &calendar.UpdatedAt)
 }
 
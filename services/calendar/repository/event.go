@@ -27,8 +27,8 @@ func (r *EventRepository) Create(ctx context.Context, event *models.Event) error
 			id, calendar_id, uid, title, description, location,
 			start_time, end_time, all_day, timezone, status, visibility, transparency,
 			recurrence_rule, recurrence_id, original_event_id, attachments, categories,
-			organizer_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+			organizer_id, recurrence_dates, exception_dates
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
 		RETURNING etag, sequence, created_at, updated_at`
 
 	return r.db.QueryRow(ctx, query,
@@ -51,6 +51,8 @@ func (r *EventRepository) Create(ctx context.Context, event *models.Event) error
 		event.Attachments,
 		event.Categories,
 		event.OrganizerID,
+		nonNilTimes(event.RecurrenceDates),
+		nonNilTimes(event.ExceptionDates),
 	).Scan(&event.ETag, &event.Sequence, &event.CreatedAt, &event.UpdatedAt)
 }
 
@@ -60,7 +62,8 @@ func (r *EventRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Ev
 		SELECT id, calendar_id, uid, title, description, location,
 		       start_time, end_time, all_day, timezone, status, visibility, transparency,
 		       recurrence_rule, recurrence_id, original_event_id, attachments, categories,
-		       sequence, etag, organizer_id, created_at, updated_at
+		       sequence, etag, organizer_id, created_at, updated_at,
+		       recurrence_dates, exception_dates
 		FROM calendar_events
 		WHERE id = $1`
 
@@ -72,15 +75,17 @@ func (r *EventRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Ev
 	return event, err
 }
 
-// GetByUID retrieves an event by iCalendar UID in a calendar
+// GetByUID retrieves an event by iCalendar UID in a calendar. For a
+// recurring event that is its master, not one of its overrides.
 func (r *EventRepository) GetByUID(ctx context.Context, calendarID uuid.UUID, uid string) (*models.Event, error) {
 	query := `
 		SELECT id, calendar_id, uid, title, description, location,
 		       start_time, end_time, all_day, timezone, status, visibility, transparency,
 		       recurrence_rule, recurrence_id, original_event_id, attachments, categories,
-		       sequence, etag, organizer_id, created_at, updated_at
+		       sequence, etag, organizer_id, created_at, updated_at,
+		       recurrence_dates, exception_dates
 		FROM calendar_events
-		WHERE calendar_id = $1 AND uid = $2`
+		WHERE calendar_id = $1 AND uid = $2 AND original_event_id IS NULL`
 
 	event := &models.Event{}
 	err := r.scanEvent(r.db.QueryRow(ctx, query, calendarID, uid), event)
@@ -107,7 +112,8 @@ func (r *EventRepository) List(ctx context.Context, calendarID uuid.UUID, startT
 		SELECT id, calendar_id, uid, title, description, location,
 		       start_time, end_time, all_day, timezone, status, visibility, transparency,
 		       recurrence_rule, recurrence_id, original_event_id, attachments, categories,
-		       sequence, etag, organizer_id, created_at, updated_at
+		       sequence, etag, organizer_id, created_at, updated_at,
+		       recurrence_dates, exception_dates
 		FROM calendar_events
 		WHERE calendar_id = $1 AND start_time < $4 AND end_time > $3
 		ORDER BY start_time ASC
@@ -137,7 +143,8 @@ func (r *EventRepository) ListForUser(ctx context.Context, userID uuid.UUID, sta
 		SELECT e.id, e.calendar_id, e.uid, e.title, e.description, e.location,
 		       e.start_time, e.end_time, e.all_day, e.timezone, e.status, e.visibility, e.transparency,
 		       e.recurrence_rule, e.recurrence_id, e.original_event_id, e.attachments, e.categories,
-		       e.sequence, e.etag, e.organizer_id, e.created_at, e.updated_at
+		       e.sequence, e.etag, e.organizer_id, e.created_at, e.updated_at,
+		       e.recurrence_dates, e.exception_dates
 		FROM calendar_events e
 		JOIN calendars c ON e.calendar_id = c.id
 		LEFT JOIN calendar_shares cs ON c.id = cs.calendar_id AND cs.user_id = $1
@@ -170,7 +177,8 @@ func (r *EventRepository) Search(ctx context.Context, userID uuid.UUID, query st
 		SELECT e.id, e.calendar_id, e.uid, e.title, e.description, e.location,
 		       e.start_time, e.end_time, e.all_day, e.timezone, e.status, e.visibility, e.transparency,
 		       e.recurrence_rule, e.recurrence_id, e.original_event_id, e.attachments, e.categories,
-		       e.sequence, e.etag, e.organizer_id, e.created_at, e.updated_at
+		       e.sequence, e.etag, e.organizer_id, e.created_at, e.updated_at,
+		       e.recurrence_dates, e.exception_dates
 		FROM calendar_events e
 		JOIN calendars c ON e.calendar_id = c.id
 		LEFT JOIN calendar_shares cs ON c.id = cs.calendar_id AND cs.user_id = $1
@@ -206,7 +214,8 @@ func (r *EventRepository) Update(ctx context.Context, event *models.Event) error
 		SET title = $2, description = $3, location = $4,
 		    start_time = $5, end_time = $6, all_day = $7, timezone = $8,
 		    status = $9, visibility = $10, transparency = $11,
-		    recurrence_rule = $12, attachments = $13, categories = $14
+		    recurrence_rule = $12, attachments = $13, categories = $14, sequence = $15,
+		    recurrence_dates = $16, exception_dates = $17
 		WHERE id = $1
 		RETURNING etag, sequence, updated_at`
 
@@ -225,9 +234,24 @@ func (r *EventRepository) Update(ctx context.Context, event *models.Event) error
 		sql.NullString{String: event.RecurrenceRule, Valid: event.RecurrenceRule != ""},
 		event.Attachments,
 		event.Categories,
+		event.Sequence,
+		nonNilTimes(event.RecurrenceDates),
+		nonNilTimes(event.ExceptionDates),
 	).Scan(&event.ETag, &event.Sequence, &event.UpdatedAt)
 }
 
+// GetOrganizer returns the email and display name of the event organizer
+func (r *EventRepository) GetOrganizer(ctx context.Context, organizerID uuid.UUID) (string, string, error) {
+	var email, name sql.NullString
+	err := r.db.QueryRow(ctx,
+		"SELECT email, display_name FROM users WHERE id = $1",
+		organizerID).Scan(&email, &name)
+	if err == pgx.ErrNoRows {
+		return "", "", nil
+	}
+	return email.String, name.String, err
+}
+
 // Delete deletes an event
 func (r *EventRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db.Exec(ctx, "DELETE FROM calendar_events WHERE id = $1", id)
@@ -247,7 +271,8 @@ func (r *EventRepository) GetRecurringInstances(ctx context.Context, originalEve
 		SELECT id, calendar_id, uid, title, description, location,
 		       start_time, end_time, all_day, timezone, status, visibility, transparency,
 		       recurrence_rule, recurrence_id, original_event_id, attachments, categories,
-		       sequence, etag, organizer_id, created_at, updated_at
+		       sequence, etag, organizer_id, created_at, updated_at,
+		       recurrence_dates, exception_dates
 		FROM calendar_events
 		WHERE original_event_id = $1
 		ORDER BY start_time ASC`
@@ -270,6 +295,43 @@ func (r *EventRepository) GetRecurringInstances(ctx context.Context, originalEve
 	return events, nil
 }
 
+// GetOverride gets the override of a recurring event for the occurrence
+// originally starting at recurrenceID
+func (r *EventRepository) GetOverride(ctx context.Context, originalEventID uuid.UUID, recurrenceID time.Time) (*models.Event, error) {
+	query := `
+		SELECT id, calendar_id, uid, title, description, location,
+		       start_time, end_time, all_day, timezone, status, visibility, transparency,
+		       recurrence_rule, recurrence_id, original_event_id, attachments, categories,
+		       sequence, etag, organizer_id, created_at, updated_at,
+		       recurrence_dates, exception_dates
+		FROM calendar_events
+		WHERE original_event_id = $1 AND recurrence_id = $2`
+
+	event := &models.Event{}
+	err := r.scanEvent(r.db.QueryRow(ctx, query, originalEventID, recurrenceID), event)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return event, err
+}
+
+// MoveOverrides relinks the overrides of a recurring event for occurrences
+// starting at or after from to another master, taking its UID
+func (r *EventRepository) MoveOverrides(ctx context.Context, originalEventID, newEventID uuid.UUID, newUID string, from time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE calendar_events SET original_event_id = $2, uid = $3
+		WHERE original_event_id = $1 AND recurrence_id >= $4`,
+		originalEventID, newEventID, newUID, from)
+	return err
+}
+
+// Touch marks an event as changed, giving it a new ETag. A recurring master
+// is touched when its overrides change, since CalDAV serves them together.
+func (r *EventRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "UPDATE calendar_events SET updated_at = NOW() WHERE id = $1", id)
+	return err
+}
+
 // CreateException creates an exception instance for a recurring event
 func (r *EventRepository) CreateException(ctx context.Context, event *models.Event, originalEventID uuid.UUID, recurrenceID time.Time) error {
 	event.OriginalEventID = &originalEventID
@@ -321,62 +383,107 @@ func (r *EventRepository) GetFreeBusy(ctx context.Context, userIDs []uuid.UUID,
 	return periods, nil
 }
 
-// GetUpcomingEvents gets upcoming events with reminders that need to be triggered
-func (r *EventRepository) GetUpcomingReminders(ctx context.Context, windowMinutes int) ([]*models.EventWithReminder, error) {
+// ListForFreeBusy lists the events in a user's own calendars that can affect
+// free/busy time in the window: events overlapping it, recurring masters that
+// start before it ends, and exceptions whose original instance overlaps it.
+// Transparency and status are left to the caller so exceptions can still
+// suppress their original instance.
+func (r *EventRepository) ListForFreeBusy(ctx context.Context, userID uuid.UUID, startTime, endTime time.Time) ([]*models.Event, error) {
 	query := `
-		SELECT e.id, e.calendar_id, e.title, e.start_time, e.organizer_id,
-		       r.id, r.method, r.minutes, u.email
-		FROM event_reminders r
-		JOIN calendar_events e ON r.event_id = e.id
-		JOIN users u ON e.organizer_id = u.id
-		WHERE r.triggered = false
-		  AND r.trigger_time <= NOW() + ($1 || ' minutes')::interval
-		  AND r.trigger_time > NOW()
-		ORDER BY r.trigger_time ASC`
-
-	rows, err := r.db.Query(ctx, query, windowMinutes)
+		SELECT e.id, e.calendar_id, e.uid, e.title, e.description, e.location,
+		       e.start_time, e.end_time, e.all_day, e.timezone, e.status, e.visibility, e.transparency,
+		       e.recurrence_rule, e.recurrence_id, e.original_event_id, e.attachments, e.categories,
+		       e.sequence, e.etag, e.organizer_id, e.created_at, e.updated_at,
+		       e.recurrence_dates, e.exception_dates
+		FROM calendar_events e
+		JOIN calendars c ON e.calendar_id = c.id
+		LEFT JOIN calendar_events m ON e.original_event_id = m.id
+		WHERE c.user_id = $1 AND c.include_in_free_busy
+		  AND (
+		    (e.start_time < $3 AND e.end_time > $2)
+		    OR (e.start_time < $3 AND ((e.recurrence_rule IS NOT NULL AND e.recurrence_rule != '') OR cardinality(e.recurrence_dates) > 0))
+		    OR (e.recurrence_id < $3 AND e.recurrence_id + (m.end_time - m.start_time) > $2)
+		  )
+		ORDER BY e.start_time ASC`
+
+	rows, err := r.db.Query(ctx, query, userID, startTime, endTime)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var reminders []*models.EventWithReminder
+	var events []*models.Event
 	for rows.Next() {
-		ewr := &models.EventWithReminder{}
-		if err := rows.Scan(
-			&ewr.EventID,
-			&ewr.CalendarID,
-			&ewr.Title,
-			&ewr.StartTime,
-			&ewr.OrganizerID,
-			&ewr.ReminderID,
-			&ewr.Method,
-			&ewr.Minutes,
-			&ewr.Email,
-		); err != nil {
+		event := &models.Event{}
+		if err := r.scanEventRows(rows, event); err != nil {
 			return nil, err
 		}
-		reminders = append(reminders, ewr)
+		events = append(events, event)
 	}
 
-	return reminders, nil
+	return events, rows.Err()
 }
 
-// MarkReminderTriggered marks a reminder as sent
-func (r *EventRepository) MarkReminderTriggered(ctx context.Context, reminderID uuid.UUID) error {
-	_, err := r.db.Exec(ctx, "UPDATE event_reminders SET triggered = true WHERE id = $1", reminderID)
-	return err
+// ListForExpansion lists the events needed to expand recurrences within a
+// time range: events overlapping it, recurring masters starting before its
+// end, and exceptions whose original instance overlaps it. With a calendar
+// ID only that calendar is listed, otherwise every calendar the user owns or
+// has been shared.
+func (r *EventRepository) ListForExpansion(ctx context.Context, userID, calendarID uuid.UUID, startTime, endTime time.Time) ([]*models.Event, error) {
+	scope := "(c.user_id = $1 OR cs.user_id = $1)"
+	scopeID := userID
+	if calendarID != uuid.Nil {
+		scope = "e.calendar_id = $1"
+		scopeID = calendarID
+	}
+
+	query := `
+		SELECT e.id, e.calendar_id, e.uid, e.title, e.description, e.location,
+		       e.start_time, e.end_time, e.all_day, e.timezone, e.status, e.visibility, e.transparency,
+		       e.recurrence_rule, e.recurrence_id, e.original_event_id, e.attachments, e.categories,
+		       e.sequence, e.etag, e.organizer_id, e.created_at, e.updated_at,
+		       e.recurrence_dates, e.exception_dates
+		FROM calendar_events e
+		JOIN calendars c ON e.calendar_id = c.id
+		LEFT JOIN calendar_shares cs ON c.id = cs.calendar_id AND cs.user_id = $4
+		LEFT JOIN calendar_events m ON e.original_event_id = m.id
+		WHERE ` + scope + `
+		  AND (
+		    (e.start_time < $3 AND e.end_time > $2)
+		    OR (e.start_time < $3 AND ((e.recurrence_rule IS NOT NULL AND e.recurrence_rule != '') OR cardinality(e.recurrence_dates) > 0))
+		    OR (e.recurrence_id < $3 AND e.recurrence_id + (m.end_time - m.start_time) > $2)
+		  )
+		ORDER BY e.start_time ASC`
+
+	rows, err := r.db.Query(ctx, query, scopeID, startTime, endTime, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.Event
+	for rows.Next() {
+		event := &models.Event{}
+		if err := r.scanEventRows(rows, event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
 }
 
-// GetMultipleByUIDs retrieves multiple events by UIDs (for calendar-multiget)
+// GetMultipleByUIDs retrieves multiple events by UIDs (for calendar-multiget).
+// Overrides are left to GetRecurringInstances.
 func (r *EventRepository) GetMultipleByUIDs(ctx context.Context, calendarID uuid.UUID, uids []string) ([]*models.Event, error) {
 	query := `
 		SELECT id, calendar_id, uid, title, description, location,
 		       start_time, end_time, all_day, timezone, status, visibility, transparency,
 		       recurrence_rule, recurrence_id, original_event_id, attachments, categories,
-		       sequence, etag, organizer_id, created_at, updated_at
+		       sequence, etag, organizer_id, created_at, updated_at,
+		       recurrence_dates, exception_dates
 		FROM calendar_events
-		WHERE calendar_id = $1 AND uid = ANY($2)`
+		WHERE calendar_id = $1 AND uid = ANY($2) AND original_event_id IS NULL`
 
 	rows, err := r.db.Query(ctx, query, calendarID, uids)
 	if err != nil {
@@ -425,6 +532,8 @@ func (r *EventRepository) scanEvent(row pgx.Row, event *models.Event) error {
 		&event.OrganizerID,
 		&event.CreatedAt,
 		&event.UpdatedAt,
+		&event.RecurrenceDates,
+		&event.ExceptionDates,
 	)
 	if err != nil {
 		return err
@@ -471,6 +580,8 @@ func (r *EventRepository) scanEventRows(rows pgx.Rows, event *models.Event) erro
 		&event.OrganizerID,
 		&event.CreatedAt,
 		&event.UpdatedAt,
+		&event.RecurrenceDates,
+		&event.ExceptionDates,
 	)
 	if err != nil {
 		return err
@@ -487,3 +598,12 @@ func (r *EventRepository) scanEventRows(rows pgx.Rows, event *models.Event) erro
 
 	return nil
 }
+
+// nonNilTimes returns times, or an empty slice for nil so NOT NULL array
+// columns get '{}'
+func nonNilTimes(times []time.Time) []time.Time {
+	if times == nil {
+		return []time.Time{}
+	}
+	return times
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"calendar-service/models"
 
@@ -10,6 +12,8 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const reminderColumns = `id, event_id, user_id, method, minutes, triggered, trigger_time, occurrence_start, snoozed_until`
+
 type ReminderRepository struct {
 	db *pgxpool.Pool
 }
@@ -18,58 +22,97 @@ func NewReminderRepository(db *pgxpool.Pool) *ReminderRepository {
 	return &ReminderRepository{db: db}
 }
 
+func scanReminder(row pgx.Row) (*models.Reminder, error) {
+	rem := &models.Reminder{}
+	err := row.Scan(
+		&rem.ID,
+		&rem.EventID,
+		&rem.UserID,
+		&rem.Method,
+		&rem.Minutes,
+		&rem.Triggered,
+		&rem.TriggerTime,
+		&rem.OccurrenceStart,
+		&rem.SnoozedUntil,
+	)
+	return rem, err
+}
+
 // Create creates a reminder
 func (r *ReminderRepository) Create(ctx context.Context, reminder *models.Reminder) error {
 	query := `
-		INSERT INTO event_reminders (id, event_id, method, minutes)
-		VALUES ($1, $2, $3, $4)
-		RETURNING trigger_time`
+		INSERT INTO event_reminders (id, event_id, user_id, method, minutes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING trigger_time, occurrence_start`
 
 	return r.db.QueryRow(ctx, query,
 		reminder.ID,
 		reminder.EventID,
+		reminder.UserID,
 		reminder.Method,
 		reminder.Minutes,
-	).Scan(&reminder.TriggerTime)
+	).Scan(&reminder.TriggerTime, &reminder.OccurrenceStart)
 }
 
-// BulkCreate creates multiple reminders for an event
-func (r *ReminderRepository) BulkCreate(ctx context.Context, eventID uuid.UUID, reminders []*models.Reminder) error {
+// BulkCreate creates multiple reminders for a user on an event
+func (r *ReminderRepository) BulkCreate(ctx context.Context, eventID, userID uuid.UUID, reminders []*models.Reminder) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
+	if err := insertReminders(ctx, tx, eventID, userID, reminders); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func insertReminders(ctx context.Context, tx pgx.Tx, eventID, userID uuid.UUID, reminders []*models.Reminder) error {
 	query := `
-		INSERT INTO event_reminders (id, event_id, method, minutes)
-		VALUES ($1, $2, $3, $4)`
+		INSERT INTO event_reminders (id, event_id, user_id, method, minutes)
+		VALUES ($1, $2, $3, $4, $5)`
 
 	for _, rem := range reminders {
 		rem.ID = uuid.New()
 		rem.EventID = eventID
+		rem.UserID = userID
 		if rem.Method == "" {
-			rem.Method = "display"
+			rem.Method = models.ReminderDisplay
 		}
 
-		_, err = tx.Exec(ctx, query, rem.ID, rem.EventID, rem.Method, rem.Minutes)
-		if err != nil {
+		if _, err := tx.Exec(ctx, query, rem.ID, rem.EventID, rem.UserID, rem.Method, rem.Minutes); err != nil {
 			return err
 		}
 	}
-
-	return tx.Commit(ctx)
+	return nil
 }
 
 // GetByEventID gets all reminders for an event
 func (r *ReminderRepository) GetByEventID(ctx context.Context, eventID uuid.UUID) ([]*models.Reminder, error) {
 	query := `
-		SELECT id, event_id, method, minutes, triggered, trigger_time
+		SELECT ` + reminderColumns + `
 		FROM event_reminders
 		WHERE event_id = $1
 		ORDER BY minutes ASC`
 
-	rows, err := r.db.Query(ctx, query, eventID)
+	return r.queryReminders(ctx, query, eventID)
+}
+
+// GetForUser gets a user's own reminders for an event
+func (r *ReminderRepository) GetForUser(ctx context.Context, eventID, userID uuid.UUID) ([]*models.Reminder, error) {
+	query := `
+		SELECT ` + reminderColumns + `
+		FROM event_reminders
+		WHERE event_id = $1 AND user_id = $2
+		ORDER BY minutes ASC`
+
+	return r.queryReminders(ctx, query, eventID, userID)
+}
+
+func (r *ReminderRepository) queryReminders(ctx context.Context, query string, args ...interface{}) ([]*models.Reminder, error) {
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -77,27 +120,23 @@ func (r *ReminderRepository) GetByEventID(ctx context.Context, eventID uuid.UUID
 
 	var reminders []*models.Reminder
 	for rows.Next() {
-		rem := &models.Reminder{}
-		if err := rows.Scan(
-			&rem.ID,
-			&rem.EventID,
-			&rem.Method,
-			&rem.Minutes,
-			&rem.Triggered,
-			&rem.TriggerTime,
-		); err != nil {
+		rem, err := scanReminder(rows)
+		if err != nil {
 			return nil, err
 		}
 		reminders = append(reminders, rem)
 	}
 
-	return reminders, nil
+	return reminders, rows.Err()
 }
 
-// Update updates a reminder
+// Update updates a reminder, moving its next firing by the new lead time
 func (r *ReminderRepository) Update(ctx context.Context, reminder *models.Reminder) error {
-	_, err := r.db.Exec(ctx,
-		"UPDATE event_reminders SET method = $2, minutes = $3 WHERE id = $1",
+	_, err := r.db.Exec(ctx, `
+		UPDATE event_reminders
+		SET method = $2, minutes = $3,
+		    trigger_time = COALESCE(occurrence_start - make_interval(mins => $3), trigger_time)
+		WHERE id = $1`,
 		reminder.ID, reminder.Method, reminder.Minutes)
 	return err
 }
@@ -114,8 +153,9 @@ func (r *ReminderRepository) DeleteByEventID(ctx context.Context, eventID uuid.U
 	return err
 }
 
-// ReplaceForEvent replaces all reminders for an event
-func (r *ReminderRepository) ReplaceForEvent(ctx context.Context, eventID uuid.UUID, reminders []*models.Reminder) error {
+// ReplaceForEvent replaces a user's reminders for an event; other users'
+// reminders are kept
+func (r *ReminderRepository) ReplaceForEvent(ctx context.Context, eventID, userID uuid.UUID, reminders []*models.Reminder) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
@@ -123,43 +163,66 @@ func (r *ReminderRepository) ReplaceForEvent(ctx context.Context, eventID uuid.U
 	defer tx.Rollback(ctx)
 
 	// Delete existing
-	_, err = tx.Exec(ctx, "DELETE FROM event_reminders WHERE event_id = $1", eventID)
+	_, err = tx.Exec(ctx, "DELETE FROM event_reminders WHERE event_id = $1 AND user_id = $2", eventID, userID)
 	if err != nil {
 		return err
 	}
 
 	// Insert new
-	query := `
-		INSERT INTO event_reminders (id, event_id, method, minutes)
-		VALUES ($1, $2, $3, $4)`
-
-	for _, rem := range reminders {
-		rem.ID = uuid.New()
-		rem.EventID = eventID
-		_, err = tx.Exec(ctx, query, rem.ID, rem.EventID, rem.Method, rem.Minutes)
-		if err != nil {
-			return err
-		}
+	if err := insertReminders(ctx, tx, eventID, userID, reminders); err != nil {
+		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-// GetPendingReminders gets reminders that need to be sent
-func (r *ReminderRepository) GetPendingReminders(ctx context.Context, windowMinutes int) ([]*models.EventWithReminder, error) {
+// HasReminders reports whether a user has reminders of their own for an event
+func (r *ReminderRepository) HasReminders(ctx context.Context, eventID, userID uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM event_reminders WHERE event_id = $1 AND user_id = $2)",
+		eventID, userID).Scan(&exists)
+	return exists, err
+}
+
+// RescheduleForEvent schedules an event's reminders for its start again,
+// after the event has moved. The reminder worker moves reminders of a
+// recurring event whose start has passed on to its next occurrence.
+func (r *ReminderRepository) RescheduleForEvent(ctx context.Context, eventID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE event_reminders r
+		SET occurrence_start = e.start_time,
+		    trigger_time = e.start_time - make_interval(mins => r.minutes),
+		    triggered = false,
+		    snoozed_until = NULL
+		FROM calendar_events e
+		WHERE e.id = r.event_id AND r.event_id = $1`, eventID)
+	return err
+}
+
+// GetDueReminders gets the reminders to send now: those whose trigger time
+// has come, and snoozed ones whose snooze is over. StartTime is the start
+// of the occurrence each is for, and TriggerTime when it was due.
+func (r *ReminderRepository) GetDueReminders(ctx context.Context, limit int) ([]*models.EventWithReminder, error) {
 	query := `
-		SELECT e.id, e.calendar_id, e.title, e.start_time, e.organizer_id,
-		       r.id, r.method, r.minutes, u.email
+		SELECT e.id, e.calendar_id, e.title, COALESCE(e.location, ''), e.organizer_id,
+		       r.id, r.user_id, r.method, r.minutes, u.email, COALESCE(p.phone_number, ''),
+		       r.snoozed_until IS NOT NULL AND r.snoozed_until <= NOW() AS snoozed,
+		       CASE WHEN r.snoozed_until IS NOT NULL AND r.snoozed_until <= NOW()
+		            THEN COALESCE(r.last_fired_occurrence, r.occurrence_start)
+		            ELSE r.occurrence_start END,
+		       CASE WHEN r.snoozed_until IS NOT NULL AND r.snoozed_until <= NOW()
+		            THEN r.snoozed_until ELSE r.trigger_time END
 		FROM event_reminders r
 		JOIN calendar_events e ON r.event_id = e.id
-		JOIN users u ON e.organizer_id = u.id
-		WHERE r.triggered = false
-		  AND r.trigger_time <= NOW() + ($1 || ' minutes')::interval
-		  AND r.trigger_time > NOW() - INTERVAL '5 minutes'
-		ORDER BY r.trigger_time ASC
-		LIMIT 100`
-
-	rows, err := r.db.Query(ctx, query, windowMinutes)
+		JOIN users u ON r.user_id = u.id
+		LEFT JOIN reminder_preferences p ON p.user_id = r.user_id
+		WHERE e.status != 'cancelled'
+		  AND ((r.triggered = false AND r.trigger_time <= NOW()) OR r.snoozed_until <= NOW())
+		ORDER BY LEAST(r.trigger_time, r.snoozed_until) ASC
+		LIMIT $1`
+
+	rows, err := r.db.Query(ctx, query, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -168,23 +231,76 @@ func (r *ReminderRepository) GetPendingReminders(ctx context.Context, windowMinu
 	var results []*models.EventWithReminder
 	for rows.Next() {
 		ewr := &models.EventWithReminder{}
+		var occurrenceStart *time.Time
 		if err := rows.Scan(
 			&ewr.EventID,
 			&ewr.CalendarID,
 			&ewr.Title,
-			&ewr.StartTime,
+			&ewr.Location,
 			&ewr.OrganizerID,
 			&ewr.ReminderID,
+			&ewr.UserID,
 			&ewr.Method,
 			&ewr.Minutes,
 			&ewr.Email,
+			&ewr.PhoneNumber,
+			&ewr.Snoozed,
+			&occurrenceStart,
+			&ewr.TriggerTime,
 		); err != nil {
 			return nil, err
 		}
+		if occurrenceStart != nil {
+			ewr.StartTime = *occurrenceStart
+		} else {
+			ewr.StartTime = ewr.TriggerTime.Add(time.Duration(ewr.Minutes) * time.Minute)
+		}
 		results = append(results, ewr)
 	}
 
-	return results, nil
+	return results, rows.Err()
+}
+
+// Advance records that a reminder fired, or was missed, for the occurrence
+// starting at fired, and schedules it for the occurrence starting at next.
+// With no next occurrence the reminder is done.
+func (r *ReminderRepository) Advance(ctx context.Context, reminderID uuid.UUID, fired time.Time, next *time.Time) error {
+	if next == nil {
+		_, err := r.db.Exec(ctx,
+			"UPDATE event_reminders SET triggered = true, last_fired_occurrence = $2 WHERE id = $1",
+			reminderID, fired)
+		return err
+	}
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE event_reminders
+		SET occurrence_start = $3,
+		    trigger_time = $3 - make_interval(mins => minutes),
+		    last_fired_occurrence = $2,
+		    triggered = false
+		WHERE id = $1`,
+		reminderID, fired, *next)
+	return err
+}
+
+// ClearSnooze ends a reminder's snooze once it has fired again
+func (r *ReminderRepository) ClearSnooze(ctx context.Context, reminderID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "UPDATE event_reminders SET snoozed_until = NULL WHERE id = $1", reminderID)
+	return err
+}
+
+// Snooze fires a user's reminder again at until, for the occurrence it last
+// fired for. It returns false if the user has no such reminder or it hasn't
+// fired yet.
+func (r *ReminderRepository) Snooze(ctx context.Context, reminderID, userID uuid.UUID, until time.Time) (bool, error) {
+	result, err := r.db.Exec(ctx, `
+		UPDATE event_reminders SET snoozed_until = $3
+		WHERE id = $1 AND user_id = $2 AND last_fired_occurrence IS NOT NULL`,
+		reminderID, userID, until)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
 }
 
 // MarkTriggered marks a reminder as triggered
@@ -196,21 +312,58 @@ func (r *ReminderRepository) MarkTriggered(ctx context.Context, reminderID uuid.
 // GetByID gets a reminder by ID
 func (r *ReminderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Reminder, error) {
 	query := `
-		SELECT id, event_id, method, minutes, triggered, trigger_time
+		SELECT ` + reminderColumns + `
 		FROM event_reminders
 		WHERE id = $1`
 
-	rem := &models.Reminder{}
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&rem.ID,
-		&rem.EventID,
-		&rem.Method,
-		&rem.Minutes,
-		&rem.Triggered,
-		&rem.TriggerTime,
-	)
+	rem, err := scanReminder(r.db.QueryRow(ctx, query, id))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
 	return rem, err
 }
+
+// GetPreferences gets a user's reminder preferences, empty if they have none
+func (r *ReminderRepository) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.ReminderPreferences, error) {
+	prefs := &models.ReminderPreferences{UserID: userID, DefaultReminders: []models.CreateReminderRequest{}}
+
+	var defaults []byte
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(phone_number, ''), default_reminders, updated_at
+		FROM reminder_preferences
+		WHERE user_id = $1`, userID).Scan(&prefs.PhoneNumber, &defaults, &prefs.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return prefs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(defaults, &prefs.DefaultReminders); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// SavePreferences creates or replaces a user's reminder preferences
+func (r *ReminderRepository) SavePreferences(ctx context.Context, prefs *models.ReminderPreferences) error {
+	defaults, err := json.Marshal(prefs.DefaultReminders)
+	if err != nil {
+		return err
+	}
+
+	var phone *string
+	if prefs.PhoneNumber != "" {
+		phone = &prefs.PhoneNumber
+	}
+
+	return r.db.QueryRow(ctx, `
+		INSERT INTO reminder_preferences (user_id, phone_number, default_reminders, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET phone_number = EXCLUDED.phone_number,
+		    default_reminders = EXCLUDED.default_reminders,
+		    updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`,
+		prefs.UserID, phone, defaults).Scan(&prefs.UpdatedAt)
+}
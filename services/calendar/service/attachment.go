@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"calendar-service/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// attachmentDownloadExpiry is how long the storage service URL a signed
+// attachment link redirects to stays valid
+const attachmentDownloadExpiry = 15 * time.Minute
+
+// MaxAttachmentSize returns the largest attachment accepted, in bytes
+func (s *CalendarService) MaxAttachmentSize() int64 {
+	return s.attachmentCfg.MaxSize
+}
+
+// AddAttachment uploads a file to the storage service and attaches it to an
+// event. Files up to the inline size limit also keep a copy for embedding
+// in iCalendar data.
+func (s *CalendarService) AddAttachment(ctx context.Context, userID, eventID uuid.UUID, filename, contentType string, data []byte) (*models.EventAttachment, error) {
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found")
+	}
+
+	hasAccess, err := s.calendarRepo.HasAccess(ctx, event.CalendarID, userID, "write")
+	if err != nil || !hasAccess {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	if s.attachmentStorage == nil {
+		return nil, fmt.Errorf("attachments are not enabled")
+	}
+	if int64(len(data)) > s.attachmentCfg.MaxSize {
+		return nil, fmt.Errorf("attachment too large")
+	}
+
+	orgID, domainID, err := s.attachmentRepo.GetStoragePartition(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve storage partition: %w", err)
+	}
+
+	storageID, err := s.attachmentStorage.Upload(ctx, orgID, domainID, userID, filename, contentType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &models.EventAttachment{
+		ID:          uuid.New(),
+		EventID:     eventID,
+		StorageID:   storageID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		UploadedBy:  userID,
+	}
+	if attachment.Size <= s.attachmentCfg.InlineMaxSize {
+		attachment.InlineData = data
+	}
+
+	if err := s.attachmentRepo.Create(ctx, attachment); err != nil {
+		s.releaseAttachments([]string{storageID})
+		return nil, fmt.Errorf("create attachment: %w", err)
+	}
+	if err := s.eventRepo.Touch(ctx, eventID); err != nil {
+		s.logger.Warn("Failed to mark event changed", zap.String("event_id", eventID.String()), zap.Error(err))
+	}
+
+	s.setAttachmentURL(attachment, time.Now())
+
+	s.logger.Info("Attachment added",
+		zap.String("event_id", eventID.String()),
+		zap.String("attachment_id", attachment.ID.String()),
+		zap.Int64("size", attachment.Size))
+
+	return attachment, nil
+}
+
+// ListAttachments returns the attachments of an event
+func (s *CalendarService) ListAttachments(ctx context.Context, userID, eventID uuid.UUID) ([]*models.EventAttachment, error) {
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found")
+	}
+
+	hasAccess, err := s.calendarRepo.HasAccess(ctx, event.CalendarID, userID, "read")
+	if err != nil || !hasAccess {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	attachments, err := s.attachmentRepo.GetByEventID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, a := range attachments {
+		s.setAttachmentURL(a, now)
+	}
+	return attachments, nil
+}
+
+// DeleteAttachment removes an attachment from an event and releases it in
+// the storage service
+func (s *CalendarService) DeleteAttachment(ctx context.Context, userID, eventID, attachmentID uuid.UUID) error {
+	attachment, err := s.attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		return err
+	}
+	if attachment == nil || attachment.EventID != eventID {
+		return fmt.Errorf("attachment not found")
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if event == nil {
+		return fmt.Errorf("event not found")
+	}
+
+	hasAccess, err := s.calendarRepo.HasAccess(ctx, event.CalendarID, userID, "write")
+	if err != nil || !hasAccess {
+		return fmt.Errorf("access denied")
+	}
+
+	if err := s.attachmentRepo.Delete(ctx, attachmentID); err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	if err := s.eventRepo.Touch(ctx, eventID); err != nil {
+		s.logger.Warn("Failed to mark event changed", zap.String("event_id", eventID.String()), zap.Error(err))
+	}
+	s.releaseAttachments([]string{attachment.StorageID})
+
+	s.logger.Info("Attachment deleted",
+		zap.String("event_id", eventID.String()),
+		zap.String("attachment_id", attachmentID.String()))
+
+	return nil
+}
+
+// AttachmentDownloadURL checks a signed attachment link and returns a
+// short-lived storage service URL to redirect to
+func (s *CalendarService) AttachmentDownloadURL(ctx context.Context, attachmentID uuid.UUID, expires, signature string) (string, error) {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp ||
+		!hmac.Equal([]byte(signature), []byte(s.attachmentSignature(attachmentID, exp))) {
+		return "", fmt.Errorf("invalid link")
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		return "", err
+	}
+	if attachment == nil {
+		return "", fmt.Errorf("attachment not found")
+	}
+	if s.attachmentStorage == nil {
+		return "", fmt.Errorf("attachments are not enabled")
+	}
+
+	return s.attachmentStorage.SignedURL(ctx, attachment.StorageID, attachment.UploadedBy, attachmentDownloadExpiry)
+}
+
+// loadAttachments sets the attachments of events, linking those too large
+// to embed
+func (s *CalendarService) loadAttachments(ctx context.Context, events ...*models.Event) {
+	now := time.Now()
+	for _, e := range events {
+		e.Files, _ = s.attachmentRepo.GetByEventID(ctx, e.ID)
+		for _, a := range e.Files {
+			s.setAttachmentURL(a, now)
+		}
+	}
+}
+
+// setAttachmentURL links an attachment with a URL signed to stay valid for
+// about the configured link lifetime. The expiry is rounded to the day so an
+// event serializes the same way throughout the day.
+func (s *CalendarService) setAttachmentURL(a *models.EventAttachment, now time.Time) {
+	exp := now.Add(s.attachmentCfg.LinkTTL).Truncate(24 * time.Hour).Unix()
+	a.URL = fmt.Sprintf("%s/attachments/%s?expires=%d&sig=%s",
+		strings.TrimSuffix(s.attachmentCfg.PublicURL, "/"), a.ID, exp, s.attachmentSignature(a.ID, exp))
+}
+
+func (s *CalendarService) attachmentSignature(attachmentID uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, s.attachmentLinkKey)
+	fmt.Fprintf(mac, "%s:%d", attachmentID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// releaseAttachments drops the storage service references of deleted
+// attachments. Failures only leave content behind, so they are logged.
+func (s *CalendarService) releaseAttachments(storageIDs []string) {
+	if s.attachmentStorage == nil || len(storageIDs) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		for _, id := range storageIDs {
+			if err := s.attachmentStorage.Delete(ctx, id); err != nil {
+				s.logger.Warn("Failed to release attachment",
+					zap.String("storage_id", id),
+					zap.Error(err))
+			}
+		}
+	}()
+}
+
+// attachmentLinkKey returns the key attachment links are signed with. A
+// random key is used when none is configured, so links stop working when
+// the service restarts.
+func attachmentLinkKey(secret string, logger *zap.Logger) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	logger.Warn("No attachment link secret configured, links will not survive a restart")
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// attachProperty returns the ATTACH property of an attachment (RFC 5545
+// Section 3.8.1.1) with its FILENAME and SIZE (RFC 8607): the content
+// inline when it was kept for embedding, otherwise a link to it
+func attachProperty(a *models.EventAttachment) string {
+	params := ";FMTTYPE=" + paramValue(a.ContentType) + ";FILENAME=" + paramValue(a.Filename)
+	if a.InlineData != nil {
+		return "ATTACH" + params + ";ENCODING=BASE64;VALUE=BINARY:" + base64.StdEncoding.EncodeToString(a.InlineData)
+	}
+	return "ATTACH" + params + ";SIZE=" + strconv.FormatInt(a.Size, 10) + ":" + a.URL
+}
+
+// AttachLine returns the folded ATTACH content line of an attachment
+func AttachLine(a *models.EventAttachment) string {
+	return foldICalLine(attachProperty(a))
+}
+
+// paramValue quotes a property parameter value when it contains characters
+// that end a parameter (RFC 5545 Section 3.2)
+func paramValue(v string) string {
+	v = strings.ReplaceAll(v, `"`, "")
+	if strings.ContainsAny(v, ":;,") {
+		return `"` + v + `"`
+	}
+	return v
+}
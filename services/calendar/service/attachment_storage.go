@@ -0,0 +1,120 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AttachmentStorage keeps event attachments in the storage service, which
+// deduplicates identical content and reference counts it.
+type AttachmentStorage struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewAttachmentStorage creates an AttachmentStorage for the storage service
+// at baseURL. It returns nil when baseURL is empty.
+func NewAttachmentStorage(baseURL string) *AttachmentStorage {
+	if baseURL == "" {
+		return nil
+	}
+	return &AttachmentStorage{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Upload stores an attachment in a user's partition and returns its
+// storage ID.
+func (s *AttachmentStorage) Upload(ctx context.Context, orgID, domainID, userID uuid.UUID, filename, contentType string, data []byte) (string, error) {
+	q := url.Values{}
+	q.Set("org_id", orgID.String())
+	q.Set("domain_id", domainID.String())
+	q.Set("user_id", userID.String())
+	q.Set("filename", filename)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.baseURL+"/api/v1/attachments/upload?"+q.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("store attachment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("store attachment: storage service returned %s", resp.Status)
+	}
+
+	var stored struct {
+		AttachmentID string `json:"attachment_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+		return "", fmt.Errorf("store attachment: %w", err)
+	}
+	return stored.AttachmentID, nil
+}
+
+// SignedURL returns a time-limited download URL for an attachment, issued
+// to the user who uploaded it.
+func (s *AttachmentStorage) SignedURL(ctx context.Context, storageID string, userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	body, _ := json.Marshal(map[string]int{"expires_in": int(expiresIn.Seconds())})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.baseURL+"/api/v1/storage/objects/"+url.PathEscape(storageID)+"/signed-url", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", userID.String())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sign attachment URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("sign attachment URL: storage service returned %s", resp.Status)
+	}
+
+	var signed struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return "", fmt.Errorf("sign attachment URL: %w", err)
+	}
+	return signed.URL, nil
+}
+
+// Delete releases an attachment. The storage service removes the content
+// once no other reference to it remains.
+func (s *AttachmentStorage) Delete(ctx context.Context, storageID string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete,
+		s.baseURL+"/api/v1/attachments/"+url.PathEscape(storageID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete attachment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("delete attachment: storage service returned %s", resp.Status)
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"calendar-service/config"
 	"calendar-service/models"
 	"calendar-service/repository"
 
@@ -19,6 +21,11 @@ type CalendarService struct {
 	reminderRepo *repository.ReminderRepository
 	notification *NotificationService
 	logger       *zap.Logger
+
+	attachmentRepo    *repository.AttachmentRepository
+	attachmentStorage *AttachmentStorage
+	attachmentCfg     config.AttachmentsConfig
+	attachmentLinkKey []byte
 }
 
 func NewCalendarService(
@@ -26,7 +33,10 @@ func NewCalendarService(
 	eventRepo *repository.EventRepository,
 	attendeeRepo *repository.AttendeeRepository,
 	reminderRepo *repository.ReminderRepository,
+	attachmentRepo *repository.AttachmentRepository,
 	notification *NotificationService,
+	attachmentStorage *AttachmentStorage,
+	attachmentCfg config.AttachmentsConfig,
 	logger *zap.Logger,
 ) *CalendarService {
 	return &CalendarService{
@@ -36,6 +46,11 @@ func NewCalendarService(
 		reminderRepo: reminderRepo,
 		notification: notification,
 		logger:       logger,
+
+		attachmentRepo:    attachmentRepo,
+		attachmentStorage: attachmentStorage,
+		attachmentCfg:     attachmentCfg,
+		attachmentLinkKey: attachmentLinkKey(attachmentCfg.LinkSecret, logger),
 	}
 }
 
@@ -81,6 +96,8 @@ func (s *CalendarService) CreateCalendar(ctx context.Context, userID uuid.UUID,
 		Color:       req.Color,
 		Timezone:    req.Timezone,
 		IsPublic:    req.IsPublic,
+
+		IncludeInFreeBusy: true,
 	}
 
 	if calendar.Color == "" {
@@ -159,6 +176,12 @@ func (s *CalendarService) UpdateCalendar(ctx context.Context, userID, calendarID
 	if req.IsPublic != nil {
 		calendar.IsPublic = *req.IsPublic
 	}
+	if req.Order != nil {
+		calendar.Order = *req.Order
+	}
+	if req.IncludeInFreeBusy != nil {
+		calendar.IncludeInFreeBusy = *req.IncludeInFreeBusy
+	}
 
 	if err := s.calendarRepo.Update(ctx, calendar); err != nil {
 		return nil, fmt.Errorf("update calendar: %w", err)
@@ -186,7 +209,16 @@ func (s *CalendarService) DeleteCalendar(ctx context.Context, userID, calendarID
 		return fmt.Errorf("cannot delete default calendar")
 	}
 
-	return s.calendarRepo.Delete(ctx, calendarID)
+	storageIDs, err := s.attachmentRepo.GetStorageIDsByCalendar(ctx, calendarID)
+	if err != nil {
+		return fmt.Errorf("list attachments: %w", err)
+	}
+	if err := s.calendarRepo.Delete(ctx, calendarID); err != nil {
+		return err
+	}
+	s.releaseAttachments(storageIDs)
+
+	return nil
 }
 
 func (s *CalendarService) ShareCalendar(ctx context.Context, ownerID, calendarID, targetUserID uuid.UUID, permission string) error {
@@ -257,6 +289,9 @@ func (s *CalendarService) CreateEvent(ctx context.Context, userID uuid.UUID, req
 		Attachments:    req.Attachments,
 		Categories:     req.Categories,
 		OrganizerID:    userID,
+
+		RecurrenceDates: req.RecurrenceDates,
+		ExceptionDates:  req.ExceptionDates,
 	}
 
 	if event.Timezone == "" {
@@ -276,26 +311,32 @@ func (s *CalendarService) CreateEvent(ctx context.Context, userID uuid.UUID, req
 
 	// Add reminders
 	if len(req.Reminders) > 0 {
-		if err := s.reminderRepo.BulkCreate(ctx, event.ID, convertRemindersToModels(event.ID, req.Reminders)); err != nil {
+		if err := s.reminderRepo.BulkCreate(ctx, event.ID, userID, convertRemindersToModels(event.ID, req.Reminders)); err != nil {
 			s.logger.Error("Failed to create reminders", zap.Error(err))
 		}
 	}
 
-	// Add attendees and send invitations
+	// Add attendees
+	attendeesAdded := false
 	if len(req.Attendees) > 0 {
 		if err := s.attendeeRepo.BulkCreate(ctx, event.ID, convertAttendeesToModels(event.ID, req.Attendees)); err != nil {
 			s.logger.Error("Failed to add attendees", zap.Error(err))
 		} else {
-			// Send invitation emails
-			for _, a := range req.Attendees {
-				go s.notification.SendInvitation(context.Background(), event, a.Email, a.Name)
-			}
+			attendeesAdded = true
 		}
 	}
 
 	// Load attendees and reminders
 	event.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, event.ID)
-	event.Reminders, _ = s.reminderRepo.GetByEventID(ctx, event.ID)
+	event.Reminders, _ = s.reminderRepo.GetForUser(ctx, event.ID, userID)
+
+	// Send iMIP invitations to attendees on domains we don't host
+	if attendeesAdded {
+		s.loadOrganizer(ctx, event)
+		for _, a := range s.externalAttendees(ctx, event.Attendees) {
+			go s.notification.SendInvitation(context.Background(), event, a.Email, a.Name)
+		}
+	}
 
 	s.logger.Info("Event created",
 		zap.String("event_id", event.ID.String()),
@@ -324,7 +365,11 @@ func (s *CalendarService) GetEvent(ctx context.Context, userID, eventID uuid.UUI
 
 	// Load related data
 	event.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, eventID)
-	event.Reminders, _ = s.reminderRepo.GetByEventID(ctx, eventID)
+	event.Reminders, _ = s.reminderRepo.GetForUser(ctx, eventID, userID)
+	if event.OriginalEventID == nil && (event.RecurrenceRule != "" || len(event.RecurrenceDates) > 0) {
+		event.Overrides, _ = s.eventRepo.GetRecurringInstances(ctx, eventID)
+	}
+	s.loadAttachments(ctx, event)
 
 	return event, nil
 }
@@ -339,6 +384,10 @@ func (s *CalendarService) ListEvents(ctx context.Context, userID uuid.UUID, req
 		limit = 100
 	}
 
+	if req.Expand {
+		return s.listExpandedEvents(ctx, userID, req, limit)
+	}
+
 	if req.CalendarID != uuid.Nil {
 		// Check access
 		hasAccess, err := s.calendarRepo.HasAccess(ctx, req.CalendarID, userID, "read")
@@ -358,7 +407,7 @@ func (s *CalendarService) ListEvents(ctx context.Context, userID uuid.UUID, req
 	// Load attendees for each event
 	for _, e := range events {
 		e.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, e.ID)
-		e.Reminders, _ = s.reminderRepo.GetByEventID(ctx, e.ID)
+		e.Reminders, _ = s.reminderRepo.GetForUser(ctx, e.ID, userID)
 	}
 
 	return &models.EventListResponse{
@@ -397,11 +446,50 @@ func (s *CalendarService) UpdateEvent(ctx context.Context, userID, eventID uuid.
 		return nil, fmt.Errorf("access denied")
 	}
 
-	// Track if we need to send updates
+	before := *event
+	needsUpdate := applyEventUpdate(event, req)
+
+	// Rescheduling bumps SEQUENCE so attendees replace the event instead of duplicating it
+	if isSignificantChange(&before, event) {
+		event.Sequence++
+		needsUpdate = true
+	}
+
+	// Update event
+	if err := s.eventRepo.Update(ctx, event); err != nil {
+		return nil, fmt.Errorf("update event: %w", err)
+	}
+
+	s.rescheduleReminders(ctx, &before, event)
+
+	// Update the caller's reminders if provided
+	if req.Reminders != nil {
+		if err := s.reminderRepo.ReplaceForEvent(ctx, eventID, userID, convertRemindersToModels(eventID, req.Reminders)); err != nil {
+			s.logger.Error("Failed to update reminders", zap.Error(err))
+		}
+	}
+
+	// Reload data
+	event.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, eventID)
+	event.Reminders, _ = s.reminderRepo.GetForUser(ctx, eventID, userID)
+	s.loadAttachments(ctx, event)
+
+	// Send updated invitations to external attendees
+	if needsUpdate && len(event.Attendees) > 0 {
+		s.loadOrganizer(ctx, event)
+		for _, a := range s.externalAttendees(ctx, event.Attendees) {
+			go s.notification.SendUpdate(context.Background(), event, a.Email, a.Name)
+		}
+	}
+
+	return event, nil
+}
+
+// applyEventUpdate applies the fields set in req to event, reporting whether
+// any of them should be sent to attendees
+func applyEventUpdate(event *models.Event, req *models.UpdateEventRequest) bool {
 	needsUpdate := false
-	oldAttendees, _ := s.attendeeRepo.GetByEventID(ctx, eventID)
 
-	// Apply updates
 	if req.Title != nil && *req.Title != "" && *req.Title != event.Title {
 		event.Title = *req.Title
 		needsUpdate = true
@@ -441,31 +529,14 @@ func (s *CalendarService) UpdateEvent(ctx context.Context, userID, eventID uuid.
 	if req.RecurrenceRule != nil {
 		event.RecurrenceRule = *req.RecurrenceRule
 	}
-
-	// Update event
-	if err := s.eventRepo.Update(ctx, event); err != nil {
-		return nil, fmt.Errorf("update event: %w", err)
+	if req.RecurrenceDates != nil {
+		event.RecurrenceDates = *req.RecurrenceDates
 	}
-
-	// Update reminders if provided
-	if req.Reminders != nil {
-		if err := s.reminderRepo.ReplaceForEvent(ctx, eventID, convertRemindersToModels(eventID, req.Reminders)); err != nil {
-			s.logger.Error("Failed to update reminders", zap.Error(err))
-		}
+	if req.ExceptionDates != nil {
+		event.ExceptionDates = *req.ExceptionDates
 	}
 
-	// Send update notifications to attendees
-	if needsUpdate && len(oldAttendees) > 0 {
-		for _, a := range oldAttendees {
-			go s.notification.SendUpdate(context.Background(), event, a.Email, a.Name)
-		}
-	}
-
-	// Reload data
-	event.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, eventID)
-	event.Reminders, _ = s.reminderRepo.GetByEventID(ctx, eventID)
-
-	return event, nil
+	return needsUpdate
 }
 
 func (s *CalendarService) DeleteEvent(ctx context.Context, userID, eventID uuid.UUID, notifyAttendees bool) error {
@@ -483,17 +554,25 @@ func (s *CalendarService) DeleteEvent(ctx context.Context, userID, eventID uuid.
 		return fmt.Errorf("access denied")
 	}
 
-	// Get attendees before deletion
+	// Get attendees and attachments before deletion
 	attendees, _ := s.attendeeRepo.GetByEventID(ctx, eventID)
+	storageIDs, err := s.attachmentRepo.GetStorageIDsByEvent(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("list attachments: %w", err)
+	}
 
-	// Delete event (cascade deletes attendees and reminders)
+	// Delete event (cascade deletes attendees, reminders, attachments and overrides)
 	if err := s.eventRepo.Delete(ctx, eventID); err != nil {
 		return fmt.Errorf("delete event: %w", err)
 	}
+	s.releaseAttachments(storageIDs)
 
-	// Send cancellation notifications
+	// Send cancellations to external attendees
 	if notifyAttendees && len(attendees) > 0 {
-		for _, a := range attendees {
+		event.Attendees = attendees
+		event.Sequence++
+		s.loadOrganizer(ctx, event)
+		for _, a := range s.externalAttendees(ctx, attendees) {
 			go s.notification.SendCancellation(context.Background(), event, a.Email, a.Name)
 		}
 	}
@@ -533,7 +612,14 @@ func (s *CalendarService) RespondToEvent(ctx context.Context, userID uuid.UUID,
 		return fmt.Errorf("update attendee status: %w", err)
 	}
 
-	// Notify organizer
+	// Give the attendee their default reminders
+	if status != "declined" && userID != uuid.Nil {
+		s.applyDefaultReminders(ctx, userID, eventID)
+	}
+
+	// Send an iTIP REPLY to the organizer
+	event.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, eventID)
+	s.loadOrganizer(ctx, event)
 	go s.notification.SendRSVPReply(context.Background(), event, email, status, comment)
 
 	s.logger.Info("RSVP response",
@@ -544,6 +630,67 @@ func (s *CalendarService) RespondToEvent(ctx context.Context, userID uuid.UUID,
 	return nil
 }
 
+// Scheduling helpers
+
+// rescheduleReminders moves an event's reminders along with it when its
+// start or recurrence changed
+func (s *CalendarService) rescheduleReminders(ctx context.Context, before, after *models.Event) {
+	if after.StartTime.Equal(before.StartTime) && after.RecurrenceRule == before.RecurrenceRule {
+		return
+	}
+	if err := s.reminderRepo.RescheduleForEvent(ctx, after.ID); err != nil {
+		s.logger.Error("Failed to reschedule reminders",
+			zap.String("event_id", after.ID.String()),
+			zap.Error(err))
+	}
+}
+
+// loadOrganizer populates the organizer address used in iTIP messages
+func (s *CalendarService) loadOrganizer(ctx context.Context, event *models.Event) {
+	email, name, err := s.eventRepo.GetOrganizer(ctx, event.OrganizerID)
+	if err != nil {
+		s.logger.Warn("Failed to load event organizer",
+			zap.String("event_id", event.ID.String()),
+			zap.Error(err))
+		return
+	}
+	event.OrganizerEmail = email
+	event.OrganizerName = name
+}
+
+// externalAttendees returns attendees whose domain is not hosted here
+// Hosted attendees see invitations in their own calendars and don't need iMIP
+func (s *CalendarService) externalAttendees(ctx context.Context, attendees []*models.Attendee) []*models.Attendee {
+	hosted := make(map[string]bool)
+	var external []*models.Attendee
+
+	for _, a := range attendees {
+		at := strings.LastIndex(a.Email, "@")
+		if at < 0 {
+			continue
+		}
+		domain := strings.ToLower(a.Email[at+1:])
+
+		isHosted, ok := hosted[domain]
+		if !ok {
+			var err error
+			isHosted, err = s.attendeeRepo.IsHostedDomain(ctx, domain)
+			if err != nil {
+				// Prefer a redundant invitation over a missing one
+				s.logger.Warn("Failed to check attendee domain", zap.String("domain", domain), zap.Error(err))
+				isHosted = false
+			}
+			hosted[domain] = isHosted
+		}
+
+		if !isHosted {
+			external = append(external, a)
+		}
+	}
+
+	return external
+}
+
 // Free/Busy operations
 
 func (s *CalendarService) GetFreeBusy(ctx context.Context, userIDs []uuid.UUID, start, end time.Time) ([]*models.FreeBusyResponse, error) {
@@ -576,7 +723,11 @@ func (s *CalendarService) GetFreeBusy(ctx context.Context, userIDs []uuid.UUID,
 // Sync operations (for CalDAV)
 
 func (s *CalendarService) GetSyncChanges(ctx context.Context, calendarID uuid.UUID, syncToken string) ([]*models.Event, string, error) {
-	return s.calendarRepo.GetSyncChanges(ctx, calendarID, syncToken)
+	events, token, err := s.calendarRepo.GetSyncChanges(ctx, calendarID, syncToken)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.AttachOverrides(ctx, events), token, nil
 }
 
 func (s *CalendarService) GetEventByUID(ctx context.Context, calendarID uuid.UUID, uid string) (*models.Event, error) {
@@ -587,6 +738,7 @@ func (s *CalendarService) GetEventByUID(ctx context.Context, calendarID uuid.UUI
 	if event != nil {
 		event.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, event.ID)
 		event.Reminders, _ = s.reminderRepo.GetByEventID(ctx, event.ID)
+		s.AttachOverrides(ctx, []*models.Event{event})
 	}
 	return event, nil
 }
@@ -600,11 +752,13 @@ func (s *CalendarService) GetMultipleEventsByUID(ctx context.Context, calendarID
 		e.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, e.ID)
 		e.Reminders, _ = s.reminderRepo.GetByEventID(ctx, e.ID)
 	}
-	return events, nil
+	return s.AttachOverrides(ctx, events), nil
 }
 
-// CreateOrUpdateEvent upserts an event by UID (for CalDAV PUT)
-func (s *CalendarService) CreateOrUpdateEvent(ctx context.Context, userID, calendarID uuid.UUID, uid string, event *models.Event) error {
+// CreateOrUpdateEvent upserts an event by UID (for CalDAV PUT). overrides
+// are the resource's other VEVENTs, each with a RECURRENCE-ID, and replace
+// the overrides stored for the event.
+func (s *CalendarService) CreateOrUpdateEvent(ctx context.Context, userID, calendarID uuid.UUID, uid string, event *models.Event, overrides []*models.Event) error {
 	existing, err := s.eventRepo.GetByUID(ctx, calendarID, uid)
 	if err != nil {
 		return err
@@ -615,7 +769,16 @@ func (s *CalendarService) CreateOrUpdateEvent(ctx context.Context, userID, calen
 		event.ID = existing.ID
 		event.CalendarID = calendarID
 		event.UID = uid
-		return s.eventRepo.Update(ctx, event)
+		event.Sequence = max(event.Sequence, existing.Sequence)
+		if event.Sequence == existing.Sequence && isSignificantChange(existing, event) {
+			event.Sequence++
+		}
+		event.OrganizerID = existing.OrganizerID
+		if err := s.eventRepo.Update(ctx, event); err != nil {
+			return err
+		}
+		s.rescheduleReminders(ctx, existing, event)
+		return s.saveOverrides(ctx, event, overrides)
 	}
 
 	// Create
@@ -623,10 +786,21 @@ func (s *CalendarService) CreateOrUpdateEvent(ctx context.Context, userID, calen
 	event.CalendarID = calendarID
 	event.UID = uid
 	event.OrganizerID = userID
-	return s.eventRepo.Create(ctx, event)
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		return err
+	}
+	return s.saveOverrides(ctx, event, overrides)
 }
 
 // DeleteEventByUID deletes an event by UID (for CalDAV DELETE)
 func (s *CalendarService) DeleteEventByUID(ctx context.Context, calendarID uuid.UUID, uid string) error {
-	return s.eventRepo.DeleteByUID(ctx, calendarID, uid)
+	storageIDs, err := s.attachmentRepo.GetStorageIDsByUID(ctx, calendarID, uid)
+	if err != nil {
+		return fmt.Errorf("list attachments: %w", err)
+	}
+	if err := s.eventRepo.DeleteByUID(ctx, calendarID, uid); err != nil {
+		return err
+	}
+	s.releaseAttachments(storageIDs)
+	return nil
 }
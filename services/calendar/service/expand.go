@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"calendar-service/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// listExpandedEvents lists the occurrences of events within the requested
+// range. Recurring masters are replaced by their instances, which are paged
+// with limit and offset like stored events.
+func (s *CalendarService) listExpandedEvents(ctx context.Context, userID uuid.UUID, req *models.ListEventsRequest, limit int) (*models.EventListResponse, error) {
+	if req.CalendarID != uuid.Nil {
+		hasAccess, err := s.calendarRepo.HasAccess(ctx, req.CalendarID, userID, "read")
+		if err != nil || !hasAccess {
+			return nil, fmt.Errorf("access denied")
+		}
+	}
+
+	events, err := s.eventRepo.ListForExpansion(ctx, userID, req.CalendarID, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := s.expandOccurrences(events, req.Start, req.End)
+	total := len(occurrences)
+
+	page := occurrences[min(req.Offset, total):min(req.Offset+limit, total)]
+
+	// Occurrences of one master share its attendees and the caller's reminders
+	attendees := make(map[uuid.UUID][]*models.Attendee)
+	reminders := make(map[uuid.UUID][]*models.Reminder)
+	for _, e := range page {
+		if _, ok := attendees[e.ID]; !ok {
+			attendees[e.ID], _ = s.attendeeRepo.GetByEventID(ctx, e.ID)
+			reminders[e.ID], _ = s.reminderRepo.GetForUser(ctx, e.ID, userID)
+		}
+		e.Attendees = attendees[e.ID]
+		e.Reminders = reminders[e.ID]
+	}
+
+	return &models.EventListResponse{
+		Events:     page,
+		Total:      total,
+		Limit:      limit,
+		Offset:     req.Offset,
+		TotalCount: total,
+		HasMore:    req.Offset+len(page) < total,
+	}, nil
+}
+
+// expandOccurrences replaces recurring masters with their instances that
+// overlap [start, end), sorted by start time. Instances come from RRULE and
+// RDATE, less EXDATE. An exception stored for an instance (matched on its
+// RECURRENCE-ID) replaces the generated instance, even when the exception
+// was moved out of the range. Other events are returned as they are if
+// they overlap the range.
+func (s *CalendarService) expandOccurrences(events []*models.Event, start, end time.Time) []*models.Event {
+	overridden := make(map[uuid.UUID]map[int64]bool)
+	for _, e := range events {
+		if e.OriginalEventID != nil && e.RecurrenceID != nil {
+			if overridden[*e.OriginalEventID] == nil {
+				overridden[*e.OriginalEventID] = make(map[int64]bool)
+			}
+			overridden[*e.OriginalEventID][e.RecurrenceID.Unix()] = true
+		}
+	}
+
+	overlaps := func(from, to time.Time) bool {
+		return from.Before(end) && to.After(start)
+	}
+
+	var occurrences []*models.Event
+	for _, e := range events {
+		if e.OriginalEventID != nil {
+			if overlaps(e.StartTime, e.EndTime) {
+				override := *e
+				override.MasterEventID = e.OriginalEventID
+				occurrences = append(occurrences, &override)
+			}
+			continue
+		}
+
+		if e.RecurrenceRule == "" && len(e.RecurrenceDates) == 0 {
+			if overlaps(e.StartTime, e.EndTime) {
+				occurrences = append(occurrences, e)
+			}
+			continue
+		}
+
+		for _, instance := range s.eventInstances(e, start, end) {
+			if overridden[e.ID][instance.Unix()] {
+				continue
+			}
+			recurrenceID := instance
+			occurrence := *e
+			occurrence.StartTime = instance
+			occurrence.EndTime = instance.Add(e.EndTime.Sub(e.StartTime))
+			occurrence.RecurrenceID = &recurrenceID
+			occurrence.MasterEventID = &e.ID
+			occurrences = append(occurrences, &occurrence)
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].StartTime.Before(occurrences[j].StartTime)
+	})
+	return occurrences
+}
+
+// eventInstances returns the start times of a recurring event's instances
+// that overlap [start, end): its RRULE instances, or just DTSTART without
+// one, plus its RDATEs, less its EXDATEs. A rule that can't be expanded
+// falls back to the first instance rather than hiding the event.
+func (s *CalendarService) eventInstances(e *models.Event, start, end time.Time) []time.Time {
+	loc := eventLocation(e)
+	duration := e.EndTime.Sub(e.StartTime)
+	overlaps := func(t time.Time) bool {
+		return t.Before(end) && t.Add(duration).After(start)
+	}
+
+	var candidates []time.Time
+	if e.RecurrenceRule != "" {
+		instances, err := expandRecurrence(e.RecurrenceRule, e.StartTime, duration, loc, start, end)
+		if err != nil {
+			s.logger.Warn("Failed to expand recurrence rule",
+				zap.String("event_id", e.ID.String()),
+				zap.String("rrule", e.RecurrenceRule),
+				zap.Error(err))
+			instances = nil
+			if overlaps(e.StartTime) {
+				instances = append(instances, e.StartTime)
+			}
+		}
+		candidates = instances
+	} else if overlaps(e.StartTime) {
+		candidates = append(candidates, e.StartTime)
+	}
+
+	for _, rdate := range e.RecurrenceDates {
+		if overlaps(rdate) {
+			candidates = append(candidates, rdate.In(loc))
+		}
+	}
+
+	seen := make(map[int64]bool, len(candidates))
+	instances := candidates[:0]
+	for _, t := range candidates {
+		if seen[t.Unix()] || isExceptionDate(e, t, loc) {
+			continue
+		}
+		seen[t.Unix()] = true
+		instances = append(instances, t)
+	}
+
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Before(instances[j]) })
+	return instances
+}
+
+// eventLocation returns the timezone an event recurs in, UTC if it has none
+// or it's unknown
+func eventLocation(e *models.Event) *time.Location {
+	if e.Timezone != "" {
+		if loc, err := time.LoadLocation(e.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// isExceptionDate reports whether an EXDATE removes the instance starting at
+// t. All-day events are matched on the date, since their EXDATEs are DATE
+// values.
+func isExceptionDate(e *models.Event, t time.Time, loc *time.Location) bool {
+	for _, exdate := range e.ExceptionDates {
+		if exdate.Equal(t) {
+			return true
+		}
+		if e.AllDay {
+			ey, em, ed := exdate.In(loc).Date()
+			ty, tm, td := t.In(loc).Date()
+			if ey == ty && em == tm && ed == td {
+				return true
+			}
+		}
+	}
+	return false
+}
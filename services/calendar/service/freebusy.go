@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"calendar-service/models"
+
+	"github.com/google/uuid"
+)
+
+// Free/busy types as used in the iCalendar FBTYPE parameter
+const (
+	FreeBusyTypeBusy          = "busy"
+	FreeBusyTypeBusyTentative = "busy-tentative"
+)
+
+// GetBusyPeriods returns the merged busy time across the calendars a user owns
+// and includes in free/busy, within [start, end). Recurring events are
+// expanded within the window, transparent and cancelled occurrences are
+// skipped, and overlapping periods are merged. Time that is busy is never also reported as tentatively busy.
+func (s *CalendarService) GetBusyPeriods(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]*models.FreeBusyPeriod, error) {
+	events, err := s.eventRepo.ListForFreeBusy(ctx, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var busy, tentative []*models.FreeBusyPeriod
+	for _, e := range s.expandOccurrences(events, start, end) {
+		if e.Transparency == "transparent" || e.Status == models.EventStatusCancelled {
+			continue
+		}
+
+		p := &models.FreeBusyPeriod{
+			UserID: userID,
+			Start:  maxTime(e.StartTime, start).UTC(),
+			End:    minTime(e.EndTime, end).UTC(),
+			Type:   FreeBusyTypeBusy,
+		}
+		if e.Status == models.EventStatusTentative {
+			p.Type = FreeBusyTypeBusyTentative
+			tentative = append(tentative, p)
+		} else {
+			busy = append(busy, p)
+		}
+	}
+
+	busy = mergePeriods(busy)
+	tentative = subtractPeriods(mergePeriods(tentative), busy)
+
+	periods := append(busy, tentative...)
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods, nil
+}
+
+// mergePeriods merges overlapping and adjacent periods of the same type
+func mergePeriods(periods []*models.FreeBusyPeriod) []*models.FreeBusyPeriod {
+	if len(periods) == 0 {
+		return periods
+	}
+
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+
+	merged := []*models.FreeBusyPeriod{periods[0]}
+	for _, p := range periods[1:] {
+		last := merged[len(merged)-1]
+		if !p.Start.After(last.End) {
+			if p.End.After(last.End) {
+				last.End = p.End
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// subtractPeriods removes the time covered by cover from each period. Both
+// slices must be sorted and merged.
+func subtractPeriods(periods, cover []*models.FreeBusyPeriod) []*models.FreeBusyPeriod {
+	var result []*models.FreeBusyPeriod
+	for _, p := range periods {
+		from := p.Start
+		for _, c := range cover {
+			if !c.End.After(from) || !c.Start.Before(p.End) {
+				continue
+			}
+			if c.Start.After(from) {
+				result = append(result, &models.FreeBusyPeriod{UserID: p.UserID, Start: from, End: c.Start, Type: p.Type})
+			}
+			from = maxTime(from, c.End)
+		}
+		if from.Before(p.End) {
+			result = append(result, &models.FreeBusyPeriod{UserID: p.UserID, Start: from, End: p.End, Type: p.Type})
+		}
+	}
+	return result
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
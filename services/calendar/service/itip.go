@@ -0,0 +1,249 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"calendar-service/models"
+)
+
+// iTIP methods (RFC 5546)
+const (
+	ITIPMethodRequest = "REQUEST"
+	ITIPMethodReply   = "REPLY"
+	ITIPMethodCancel  = "CANCEL"
+)
+
+const (
+	icalDateTimeFormat = "20060102T150405Z"
+	icalDateFormat     = "20060102"
+
+	// icalMaxLineOctets is the maximum line length before folding (RFC 5545 Section 3.1)
+	icalMaxLineOctets = 75
+)
+
+// buildITIPMessage serializes event as an iTIP message for the given method
+// For REPLY, attendees should contain only the replying attendee
+func buildITIPMessage(event *models.Event, method string, attendees []*models.Attendee, comment string, now time.Time) string {
+	var b strings.Builder
+	w := func(line string) {
+		b.WriteString(foldICalLine(line))
+		b.WriteString("\r\n")
+	}
+
+	w("BEGIN:VCALENDAR")
+	w("VERSION:2.0")
+	w("PRODID:-//OonruMail//Calendar//EN")
+	w("CALSCALE:GREGORIAN")
+	w("METHOD:" + method)
+	w("BEGIN:VEVENT")
+	w("UID:" + event.UID)
+	w("DTSTAMP:" + now.UTC().Format(icalDateTimeFormat))
+	w(fmt.Sprintf("SEQUENCE:%d", event.Sequence))
+
+	if event.AllDay {
+		start, end := allDayRange(event.StartTime, event.EndTime)
+		w("DTSTART;VALUE=DATE:" + start)
+		w("DTEND;VALUE=DATE:" + end)
+	} else {
+		w("DTSTART:" + event.StartTime.UTC().Format(icalDateTimeFormat))
+		w("DTEND:" + event.EndTime.UTC().Format(icalDateTimeFormat))
+	}
+	if event.RecurrenceID != nil {
+		if event.AllDay {
+			w("RECURRENCE-ID;VALUE=DATE:" + event.RecurrenceID.UTC().Format(icalDateFormat))
+		} else {
+			w("RECURRENCE-ID:" + event.RecurrenceID.UTC().Format(icalDateTimeFormat))
+		}
+	}
+	if event.RecurrenceRule != "" {
+		w("RRULE:" + formatRRule(event.RecurrenceRule, event.AllDay))
+	}
+	if len(event.RecurrenceDates) > 0 {
+		w(recurrenceDatesProperty("RDATE", event.RecurrenceDates, event.AllDay))
+	}
+	if len(event.ExceptionDates) > 0 {
+		w(recurrenceDatesProperty("EXDATE", event.ExceptionDates, event.AllDay))
+	}
+
+	w("SUMMARY:" + escapeICalText(event.Title))
+	if event.Description != "" {
+		w("DESCRIPTION:" + escapeICalText(event.Description))
+	}
+	if event.Location != "" {
+		w("LOCATION:" + escapeICalText(event.Location))
+	}
+
+	status := statusToICalStatus(event.Status)
+	if method == ITIPMethodCancel {
+		status = "CANCELLED"
+	}
+	w("STATUS:" + status)
+	if event.Transparency == "transparent" {
+		w("TRANSP:TRANSPARENT")
+	}
+
+	for _, a := range event.Files {
+		w(attachProperty(a))
+	}
+	w("ORGANIZER" + cnParam(event.OrganizerName) + ":mailto:" + event.OrganizerEmail)
+	for _, a := range attendees {
+		line := "ATTENDEE"
+		if a.Role != "" {
+			line += ";ROLE=" + strings.ToUpper(string(a.Role))
+		}
+		line += ";PARTSTAT=" + partStat(a.Status)
+		if method == ITIPMethodRequest && a.RSVP {
+			line += ";RSVP=TRUE"
+		}
+		line += cnParam(a.Name) + ":mailto:" + a.Email
+		w(line)
+	}
+
+	if comment != "" {
+		w("COMMENT:" + escapeICalText(comment))
+	}
+	if !event.CreatedAt.IsZero() {
+		w("CREATED:" + event.CreatedAt.UTC().Format(icalDateTimeFormat))
+	}
+	if !event.UpdatedAt.IsZero() {
+		w("LAST-MODIFIED:" + event.UpdatedAt.UTC().Format(icalDateTimeFormat))
+	}
+
+	w("END:VEVENT")
+	w("END:VCALENDAR")
+
+	return b.String()
+}
+
+// isSignificantChange reports whether an update reschedules the event and must
+// bump SEQUENCE so attendees treat it as a new revision (RFC 5546 Section 2.1.4)
+func isSignificantChange(before, after *models.Event) bool {
+	return !before.StartTime.Equal(after.StartTime) ||
+		!before.EndTime.Equal(after.EndTime) ||
+		before.AllDay != after.AllDay ||
+		before.RecurrenceRule != after.RecurrenceRule ||
+		!sameTimes(before.RecurrenceDates, after.RecurrenceDates) ||
+		!sameTimes(before.ExceptionDates, after.ExceptionDates) ||
+		before.Status != after.Status
+}
+
+func sameTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// allDayRange returns the DATE values for an all-day event
+// DTEND is exclusive, so an event ending at 23:59 ends on the following day
+func allDayRange(start, end time.Time) (string, string) {
+	startDay := truncateToDay(start.UTC())
+	endDay := truncateToDay(end.UTC())
+	if !end.UTC().Equal(endDay) {
+		endDay = endDay.AddDate(0, 0, 1)
+	}
+	if !endDay.After(startDay) {
+		endDay = startDay.AddDate(0, 0, 1)
+	}
+	return startDay.Format(icalDateFormat), endDay.Format(icalDateFormat)
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// recurrenceDatesProperty formats an RDATE or EXDATE property, as DATE
+// values for all-day events and UTC DATE-TIME values otherwise
+func recurrenceDatesProperty(name string, times []time.Time, allDay bool) string {
+	values := make([]string, len(times))
+	for i, t := range times {
+		if allDay {
+			values[i] = t.UTC().Format(icalDateFormat)
+		} else {
+			values[i] = t.UTC().Format(icalDateTimeFormat)
+		}
+	}
+	if allDay {
+		name += ";VALUE=DATE"
+	}
+	return name + ":" + strings.Join(values, ",")
+}
+
+// formatRRule normalizes a stored recurrence rule for serialization
+// UNTIL must match the DTSTART value type: a DATE for all-day events and a
+// UTC DATE-TIME otherwise (RFC 5545 Section 3.3.10)
+func formatRRule(rule string, allDay bool) string {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+
+	parts := strings.Split(rule, ";")
+	for i, part := range parts {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToUpper(key)
+		if key == "UNTIL" {
+			if allDay && len(value) > len(icalDateFormat) {
+				value = value[:len(icalDateFormat)]
+			} else if !allDay && len(value) == len(icalDateFormat) {
+				value += "T235959Z"
+			} else if !allDay && !strings.HasSuffix(value, "Z") {
+				value += "Z"
+			}
+		}
+		parts[i] = key + "=" + value
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// partStat converts an attendee status to an iCalendar PARTSTAT value
+func partStat(status models.AttendeeStatus) string {
+	if status == "" {
+		return "NEEDS-ACTION"
+	}
+	return strings.ToUpper(string(status))
+}
+
+// cnParam returns a ";CN=..." parameter, quoting the name when required
+func cnParam(name string) string {
+	name = strings.ReplaceAll(name, `"`, "")
+	if name == "" {
+		return ""
+	}
+	if strings.ContainsAny(name, ":;,") {
+		return `;CN="` + name + `"`
+	}
+	return ";CN=" + name
+}
+
+// foldICalLine folds a content line at 75 octets without splitting UTF-8 sequences
+func foldICalLine(line string) string {
+	if len(line) <= icalMaxLineOctets {
+		return line
+	}
+
+	var b strings.Builder
+	limit := icalMaxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = icalMaxLineOctets - 1 // Continuation lines start with a space
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
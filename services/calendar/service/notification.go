@@ -3,9 +3,18 @@ package service
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"math"
+	"net/http"
 	"net/smtp"
+	"strings"
 	"time"
 
 	"calendar-service/config"
@@ -14,73 +23,93 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrNoReminderAddress is returned for a reminder its user can't be reached
+// on, such as an SMS reminder without a phone number
+var ErrNoReminderAddress = errors.New("no address to send reminder to")
+
 type NotificationService struct {
-	config *config.Config
-	logger *zap.Logger
+	config     *config.Config
+	httpClient *http.Client
+	logger     *zap.Logger
 }
 
 func NewNotificationService(cfg *config.Config, logger *zap.Logger) *NotificationService {
 	return &NotificationService{
-		config: cfg,
-		logger: logger,
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
 	}
 }
 
-// SendInvitation sends calendar invitation to attendee
+// SendInvitation sends an iTIP REQUEST invitation to attendee
 func (s *NotificationService) SendInvitation(ctx context.Context, event *models.Event, toEmail, toName string) error {
-	ical := s.generateICalInvite(event, "REQUEST")
+	ical := s.buildITIP(event, ITIPMethodRequest, event.Attendees, "")
 
 	subject := fmt.Sprintf("Invitation: %s", event.Title)
 	body := s.buildInviteEmailBody(event, "invitation")
 
-	return s.sendEmailWithICS(toEmail, toName, subject, body, ical)
+	return s.sendEmailWithICS(toEmail, toName, subject, body, ITIPMethodRequest, ical)
 }
 
-// SendUpdate sends update notification to attendees
+// SendUpdate sends an updated iTIP REQUEST to attendee
 func (s *NotificationService) SendUpdate(ctx context.Context, event *models.Event, toEmail, toName string) error {
-	ical := s.generateICalInvite(event, "REQUEST")
+	ical := s.buildITIP(event, ITIPMethodRequest, event.Attendees, "")
 
 	subject := fmt.Sprintf("Updated: %s", event.Title)
 	body := s.buildInviteEmailBody(event, "update")
 
-	return s.sendEmailWithICS(toEmail, toName, subject, body, ical)
+	return s.sendEmailWithICS(toEmail, toName, subject, body, ITIPMethodRequest, ical)
 }
 
-// SendCancellation sends cancellation notification to attendees
+// SendCancellation sends an iTIP CANCEL to attendee
 func (s *NotificationService) SendCancellation(ctx context.Context, event *models.Event, toEmail, toName string) error {
-	ical := s.generateICalInvite(event, "CANCEL")
+	ical := s.buildITIP(event, ITIPMethodCancel, event.Attendees, "")
 
 	subject := fmt.Sprintf("Cancelled: %s", event.Title)
 	body := s.buildInviteEmailBody(event, "cancellation")
 
-	return s.sendEmailWithICS(toEmail, toName, subject, body, ical)
+	return s.sendEmailWithICS(toEmail, toName, subject, body, ITIPMethodCancel, ical)
 }
 
-// SendRSVPReply sends RSVP reply to organizer
+// SendRSVPReply sends an iTIP REPLY with the attendee's participation status to the organizer
 func (s *NotificationService) SendRSVPReply(ctx context.Context, event *models.Event, attendeeEmail, status, comment string) error {
-	// In a full implementation, get organizer email from event
-	// For now, log it
-	s.logger.Info("RSVP Reply",
-		zap.String("event_id", event.ID.String()),
-		zap.String("attendee", attendeeEmail),
-		zap.String("status", status),
-		zap.String("comment", comment))
+	if event.OrganizerEmail == "" {
+		s.logger.Warn("Organizer email unknown, skipping RSVP reply",
+			zap.String("event_id", event.ID.String()),
+			zap.String("attendee", attendeeEmail))
+		return nil
+	}
 
-	return nil
+	attendee := &models.Attendee{Email: attendeeEmail, Status: models.AttendeeStatus(status)}
+	for _, a := range event.Attendees {
+		if strings.EqualFold(a.Email, attendeeEmail) {
+			attendee.Name = a.Name
+			attendee.Role = a.Role
+			break
+		}
+	}
+
+	ical := s.buildITIP(event, ITIPMethodReply, []*models.Attendee{attendee}, comment)
+
+	name := attendee.Name
+	if name == "" {
+		name = attendeeEmail
+	}
+	subject := fmt.Sprintf("%s: %s", replySubjectPrefix(status), event.Title)
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<p><strong>%s</strong> has %s your invitation to <strong>%s</strong>.</p>
+		</body>
+		</html>
+	`, template.HTMLEscapeString(name), status, template.HTMLEscapeString(event.Title))
+
+	return s.sendEmailWithICS(event.OrganizerEmail, event.OrganizerName, subject, body, ITIPMethodReply, ical)
 }
 
 // SendReminder sends event reminder
 func (s *NotificationService) SendReminder(ctx context.Context, ewr *models.EventWithReminder) error {
-	var timeStr string
-	if ewr.Minutes == 0 {
-		timeStr = "now"
-	} else if ewr.Minutes < 60 {
-		timeStr = fmt.Sprintf("in %d minutes", ewr.Minutes)
-	} else if ewr.Minutes < 1440 {
-		timeStr = fmt.Sprintf("in %d hour(s)", ewr.Minutes/60)
-	} else {
-		timeStr = fmt.Sprintf("in %d day(s)", ewr.Minutes/1440)
-	}
+	timeStr := reminderTiming(ewr.StartTime, time.Now())
 
 	subject := fmt.Sprintf("Reminder: %s (%s)", ewr.Title, timeStr)
 
@@ -92,52 +121,117 @@ func (s *NotificationService) SendReminder(ctx context.Context, ewr *models.Even
 			<p>Start Time: %s</p>
 		</body>
 		</html>
-	`, ewr.Title, timeStr, ewr.StartTime.Format(time.RFC1123))
+	`, template.HTMLEscapeString(ewr.Title), timeStr, ewr.StartTime.Format(time.RFC1123))
 
 	return s.sendEmail(ewr.Email, "", subject, body)
 }
 
-// generateICalInvite generates an iCalendar invitation
-func (s *NotificationService) generateICalInvite(event *models.Event, method string) string {
-	startStr := event.StartTime.UTC().Format("20060102T150405Z")
-	endStr := event.EndTime.UTC().Format("20060102T150405Z")
-	createdStr := event.CreatedAt.UTC().Format("20060102T150405Z")
-	nowStr := time.Now().UTC().Format("20060102T150405Z")
-
-	ical := fmt.Sprintf(`BEGIN:VCALENDAR
-VERSION:2.0
-PRODID:-//OonruMail//Calendar//EN
-CALSCALE:GREGORIAN
-METHOD:%s
-BEGIN:VEVENT
-UID:%s
-DTSTAMP:%s
-DTSTART:%s
-DTEND:%s
-SUMMARY:%s
-DESCRIPTION:%s
-LOCATION:%s
-STATUS:%s
-SEQUENCE:%d
-CREATED:%s
-LAST-MODIFIED:%s
-END:VEVENT
-END:VCALENDAR`,
-		method,
-		event.UID,
-		nowStr,
-		startStr,
-		endStr,
-		escapeICalText(event.Title),
-		escapeICalText(event.Description),
-		escapeICalText(event.Location),
-		statusToICalStatus(event.Status),
-		event.Sequence,
-		createdStr,
-		nowStr,
-	)
+// SendSMSReminder sends an event reminder by text message through the
+// sms-gateway service
+func (s *NotificationService) SendSMSReminder(ctx context.Context, ewr *models.EventWithReminder) error {
+	if ewr.PhoneNumber == "" {
+		return ErrNoReminderAddress
+	}
+	if s.config.Reminders.SMSGatewayURL == "" {
+		return fmt.Errorf("sms reminders are not configured")
+	}
+
+	message := fmt.Sprintf("Reminder: %s starts %s", ewr.Title, reminderTiming(ewr.StartTime, time.Now()))
+	if ewr.Location != "" {
+		message += " at " + ewr.Location
+	}
 
-	return ical
+	payload, err := json.Marshal(map[string]string{"to": ewr.PhoneNumber, "message": message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(s.config.Reminders.SMSGatewayURL, "/")+"/api/v1/sms/send", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", s.config.Reminders.SMSAPIKey)
+
+	return s.post(req, "sms gateway")
+}
+
+// SendPushReminder posts an event reminder to the push service, signed with
+// an HMAC-SHA256 of the body in X-Signature
+func (s *NotificationService) SendPushReminder(ctx context.Context, ewr *models.EventWithReminder) error {
+	if s.config.Reminders.PushURL == "" {
+		return fmt.Errorf("push reminders are not configured")
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":        "calendar.reminder",
+		"user_id":     ewr.UserID,
+		"event_id":    ewr.EventID,
+		"reminder_id": ewr.ReminderID,
+		"title":       ewr.Title,
+		"location":    ewr.Location,
+		"start_time":  ewr.StartTime,
+		"body":        fmt.Sprintf("%s starts %s", ewr.Title, reminderTiming(ewr.StartTime, time.Now())),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.Reminders.PushURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Reminders.PushSecret != "" {
+		mac := hmac.New(sha256.New, []byte(s.config.Reminders.PushSecret))
+		mac.Write(payload)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return s.post(req, "push service")
+}
+
+// post sends a request to another service, failing on a non-2xx response
+func (s *NotificationService) post(req *http.Request, service string) error {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", service, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %d", service, resp.StatusCode)
+	}
+	return nil
+}
+
+// reminderTiming describes when an event starting at start begins, as seen
+// at now
+func reminderTiming(start, now time.Time) string {
+	minutes := int(math.Ceil(start.Sub(now).Minutes()))
+	switch {
+	case minutes <= 0:
+		return "now"
+	case minutes < 60:
+		return fmt.Sprintf("in %d minutes", minutes)
+	case minutes < 1440:
+		return fmt.Sprintf("in %d hour(s)", minutes/60)
+	default:
+		return fmt.Sprintf("in %d day(s)", minutes/1440)
+	}
+}
+
+// buildITIP generates the iCalendar payload for an iTIP message
+// Falls back to the notification sender when the organizer address is unknown
+func (s *NotificationService) buildITIP(event *models.Event, method string, attendees []*models.Attendee, comment string) string {
+	if event.OrganizerEmail == "" {
+		e := *event
+		e.OrganizerEmail = s.config.Notifications.FromEmail
+		event = &e
+	}
+	return buildITIPMessage(event, method, attendees, comment, time.Now())
 }
 
 // buildInviteEmailBody builds HTML email body for invites
@@ -237,7 +331,7 @@ func (s *NotificationService) buildInviteEmailBody(event *models.Event, inviteTy
 }
 
 // sendEmailWithICS sends an email with iCalendar attachment
-func (s *NotificationService) sendEmailWithICS(toEmail, toName, subject, htmlBody, ical string) error {
+func (s *NotificationService) sendEmailWithICS(toEmail, toName, subject, htmlBody, method, ical string) error {
 	if s.config.SMTP.Host == "" {
 		s.logger.Warn("SMTP not configured, skipping email",
 			zap.String("to", toEmail),
@@ -259,8 +353,8 @@ Content-Type: text/html; charset="UTF-8"
 %s
 
 --%s
-Content-Type: text/calendar; charset="UTF-8"; method=REQUEST
-Content-Disposition: attachment; filename="invite.ics"
+Content-Type: text/calendar; charset="UTF-8"; method=%s
+Content-Disposition: attachment; filename="%s.ics"
 
 %s
 
@@ -272,6 +366,8 @@ Content-Disposition: attachment; filename="invite.ics"
 		boundary,
 		htmlBody,
 		boundary,
+		method,
+		strings.ToLower(method),
 		ical,
 		boundary,
 	)
@@ -323,12 +419,29 @@ Content-Type: text/html; charset="UTF-8"
 
 // Helper functions
 
+// escapeICalText escapes a TEXT property value (RFC 5545 Section 3.3.11)
 func escapeICalText(s string) string {
-	// Escape special characters for iCalendar
-	s = template.HTMLEscapeString(s)
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
 	return s
 }
 
+func replySubjectPrefix(status string) string {
+	switch models.AttendeeStatus(status) {
+	case models.StatusAccepted:
+		return "Accepted"
+	case models.StatusDeclined:
+		return "Declined"
+	case models.StatusTentative:
+		return "Tentatively Accepted"
+	default:
+		return "Reply"
+	}
+}
+
 func statusToICalStatus(status models.EventStatus) string {
 	switch status {
 	case models.EventStatusConfirmed:
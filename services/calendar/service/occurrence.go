@@ -0,0 +1,440 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"calendar-service/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// UpdateOccurrence edits the occurrence of a recurring event originally
+// starting at recurrenceID. The edit is stored as an override: an event with
+// the master's UID, linked to it by OriginalEventID and RecurrenceID, that
+// replaces the generated occurrence. With ThisAndFollowing the series is
+// split instead, so the occurrence and every later one form a new recurring
+// event.
+func (s *CalendarService) UpdateOccurrence(ctx context.Context, userID, eventID uuid.UUID, recurrenceID time.Time, req *models.UpdateOccurrenceRequest) (*models.Event, error) {
+	master, err := s.recurringMaster(ctx, userID, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ThisAndFollowing {
+		return s.splitSeries(ctx, userID, master, recurrenceID, &req.UpdateEventRequest)
+	}
+	if req.RecurrenceRule != nil || req.RecurrenceDates != nil || req.ExceptionDates != nil {
+		return nil, fmt.Errorf("an occurrence can't have its own recurrence")
+	}
+
+	occurrence, ok := s.findOccurrence(master, recurrenceID)
+	if ok {
+		recurrenceID = occurrence
+	}
+	override, err := s.eventRepo.GetOverride(ctx, master.ID, recurrenceID)
+	if err != nil {
+		return nil, err
+	}
+	if override == nil && !ok {
+		return nil, fmt.Errorf("occurrence not found")
+	}
+
+	var needsUpdate bool
+	if override == nil {
+		override = newOverride(master, recurrenceID)
+		before := *override
+		needsUpdate = applyEventUpdate(override, &req.UpdateEventRequest)
+		if isSignificantChange(&before, override) {
+			override.Sequence++
+			needsUpdate = true
+		}
+		if err := s.eventRepo.Create(ctx, override); err != nil {
+			return nil, fmt.Errorf("create override: %w", err)
+		}
+
+		// The override starts out with the series' attendees and reminders
+		attendees, _ := s.attendeeRepo.GetByEventID(ctx, master.ID)
+		if len(attendees) > 0 {
+			if err := s.attendeeRepo.BulkCreate(ctx, override.ID, copyAttendees(attendees)); err != nil {
+				s.logger.Error("Failed to copy attendees to override", zap.Error(err))
+			}
+		}
+		s.copyEventReminders(ctx, master.ID, override.ID, userID, req.Reminders)
+	} else {
+		before := *override
+		needsUpdate = applyEventUpdate(override, &req.UpdateEventRequest)
+		if isSignificantChange(&before, override) {
+			override.Sequence++
+			needsUpdate = true
+		}
+		if err := s.eventRepo.Update(ctx, override); err != nil {
+			return nil, fmt.Errorf("update override: %w", err)
+		}
+		s.rescheduleReminders(ctx, &before, override)
+		if req.Reminders != nil {
+			if err := s.reminderRepo.ReplaceForEvent(ctx, override.ID, userID, convertRemindersToModels(override.ID, req.Reminders)); err != nil {
+				s.logger.Error("Failed to update reminders", zap.Error(err))
+			}
+		}
+	}
+
+	// CalDAV serves overrides within the master's resource
+	if err := s.eventRepo.Touch(ctx, master.ID); err != nil {
+		s.logger.Error("Failed to touch recurring event", zap.Error(err))
+	}
+
+	override.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, override.ID)
+	override.Reminders, _ = s.reminderRepo.GetByEventID(ctx, override.ID)
+	s.loadAttachments(ctx, override)
+
+	if needsUpdate && len(override.Attendees) > 0 {
+		s.loadOrganizer(ctx, override)
+		for _, a := range s.externalAttendees(ctx, override.Attendees) {
+			go s.notification.SendUpdate(context.Background(), override, a.Email, a.Name)
+		}
+	}
+
+	s.logger.Info("Occurrence updated",
+		zap.String("event_id", master.ID.String()),
+		zap.Time("recurrence_id", recurrenceID))
+
+	return override, nil
+}
+
+// DeleteOccurrence removes the occurrence of a recurring event originally
+// starting at recurrenceID by adding it to the master's EXDATEs, along with
+// any override stored for it
+func (s *CalendarService) DeleteOccurrence(ctx context.Context, userID, eventID uuid.UUID, recurrenceID time.Time, notifyAttendees bool) error {
+	master, err := s.recurringMaster(ctx, userID, eventID)
+	if err != nil {
+		return err
+	}
+
+	occurrence, ok := s.findOccurrence(master, recurrenceID)
+	if ok {
+		recurrenceID = occurrence
+	}
+	override, err := s.eventRepo.GetOverride(ctx, master.ID, recurrenceID)
+	if err != nil {
+		return err
+	}
+	if override == nil && !ok {
+		return fmt.Errorf("occurrence not found")
+	}
+
+	// What attendees are told is cancelled: the override, or the occurrence
+	// as the series generates it
+	cancelled := override
+	if cancelled == nil {
+		cancelled = newOverride(master, recurrenceID)
+		cancelled.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, master.ID)
+	} else {
+		cancelled.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, override.ID)
+		if err := s.deleteOverride(ctx, override.ID); err != nil {
+			return err
+		}
+	}
+
+	if ok {
+		master.ExceptionDates = append(master.ExceptionDates, recurrenceID)
+		master.Sequence++
+		if err := s.eventRepo.Update(ctx, master); err != nil {
+			return fmt.Errorf("update event: %w", err)
+		}
+	} else if err := s.eventRepo.Touch(ctx, master.ID); err != nil {
+		s.logger.Error("Failed to touch recurring event", zap.Error(err))
+	}
+
+	if notifyAttendees && len(cancelled.Attendees) > 0 {
+		cancelled.Sequence++
+		s.loadOrganizer(ctx, cancelled)
+		for _, a := range s.externalAttendees(ctx, cancelled.Attendees) {
+			go s.notification.SendCancellation(context.Background(), cancelled, a.Email, a.Name)
+		}
+	}
+
+	s.logger.Info("Occurrence deleted",
+		zap.String("event_id", master.ID.String()),
+		zap.Time("recurrence_id", recurrenceID))
+
+	return nil
+}
+
+// splitSeries applies an edit to the occurrence starting at recurrenceID and
+// every later one. The master keeps the earlier occurrences, ending just
+// before it, and a new master with its own UID takes the rest, along with
+// their overrides. Editing from the first occurrence edits the whole series.
+func (s *CalendarService) splitSeries(ctx context.Context, userID uuid.UUID, master *models.Event, recurrenceID time.Time, req *models.UpdateEventRequest) (*models.Event, error) {
+	occurrence, ok := s.findOccurrence(master, recurrenceID)
+	if !ok {
+		return nil, fmt.Errorf("occurrence not found")
+	}
+	if !occurrence.After(master.StartTime) {
+		return s.UpdateEvent(ctx, userID, master.ID, req)
+	}
+
+	tail := *master
+	tail.ID = uuid.New()
+	tail.UID = fmt.Sprintf("%s@calendar.local", uuid.New().String())
+	tail.StartTime = occurrence
+	tail.EndTime = occurrence.Add(master.EndTime.Sub(master.StartTime))
+	tail.Sequence = 0
+	tail.ETag = ""
+	tail.Attendees, tail.Reminders, tail.Overrides = nil, nil, nil
+	master.RecurrenceDates, tail.RecurrenceDates = splitTimes(master.RecurrenceDates, occurrence)
+	master.ExceptionDates, tail.ExceptionDates = splitTimes(master.ExceptionDates, occurrence)
+
+	if master.RecurrenceRule != "" {
+		head, rest, err := splitRecurrenceRule(master.RecurrenceRule, master.StartTime, occurrence, eventLocation(master), master.AllDay)
+		if err != nil {
+			return nil, fmt.Errorf("split recurrence: %w", err)
+		}
+		master.RecurrenceRule, tail.RecurrenceRule = head, rest
+	}
+	applyEventUpdate(&tail, req)
+
+	master.Sequence++
+	if err := s.eventRepo.Update(ctx, master); err != nil {
+		return nil, fmt.Errorf("update event: %w", err)
+	}
+	if err := s.eventRepo.Create(ctx, &tail); err != nil {
+		return nil, fmt.Errorf("create event: %w", err)
+	}
+	if err := s.eventRepo.MoveOverrides(ctx, master.ID, tail.ID, tail.UID, occurrence); err != nil {
+		return nil, fmt.Errorf("move overrides: %w", err)
+	}
+
+	attendees, _ := s.attendeeRepo.GetByEventID(ctx, master.ID)
+	if len(attendees) > 0 {
+		if err := s.attendeeRepo.BulkCreate(ctx, tail.ID, copyAttendees(attendees)); err != nil {
+			s.logger.Error("Failed to copy attendees", zap.Error(err))
+		}
+	}
+	s.copyEventReminders(ctx, master.ID, tail.ID, userID, req.Reminders)
+
+	tail.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, tail.ID)
+	tail.Reminders, _ = s.reminderRepo.GetByEventID(ctx, tail.ID)
+
+	// Attendees get the shortened series and an invitation to the new one
+	if len(attendees) > 0 {
+		master.Attendees = attendees
+		s.loadOrganizer(ctx, master)
+		s.loadAttachments(ctx, master)
+		s.loadOrganizer(ctx, &tail)
+		for _, a := range s.externalAttendees(ctx, attendees) {
+			go s.notification.SendUpdate(context.Background(), master, a.Email, a.Name)
+			go s.notification.SendInvitation(context.Background(), &tail, a.Email, a.Name)
+		}
+	}
+
+	s.logger.Info("Recurring event split",
+		zap.String("event_id", master.ID.String()),
+		zap.String("new_event_id", tail.ID.String()),
+		zap.Time("recurrence_id", occurrence))
+
+	return &tail, nil
+}
+
+// recurringMaster loads the recurring event an occurrence belongs to, given
+// either its ID or the ID of one of its overrides, and checks write access
+func (s *CalendarService) recurringMaster(ctx context.Context, userID, eventID uuid.UUID) (*models.Event, error) {
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event != nil && event.OriginalEventID != nil {
+		event, err = s.eventRepo.GetByID(ctx, *event.OriginalEventID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found")
+	}
+
+	hasAccess, err := s.calendarRepo.HasAccess(ctx, event.CalendarID, userID, "write")
+	if err != nil || !hasAccess {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	if event.RecurrenceRule == "" && len(event.RecurrenceDates) == 0 {
+		return nil, fmt.Errorf("event is not recurring")
+	}
+	return event, nil
+}
+
+// findOccurrence returns the start of the occurrence of a recurring event
+// identified by recurrenceID. All-day occurrences are matched on the date,
+// like their EXDATEs.
+func (s *CalendarService) findOccurrence(master *models.Event, recurrenceID time.Time) (time.Time, bool) {
+	loc := eventLocation(master)
+	for _, t := range s.eventInstances(master, recurrenceID.AddDate(0, 0, -1), recurrenceID.AddDate(0, 0, 1)) {
+		if t.Equal(recurrenceID) {
+			return t, true
+		}
+		if master.AllDay {
+			ry, rm, rd := recurrenceID.In(loc).Date()
+			ty, tm, td := t.In(loc).Date()
+			if ry == ty && rm == tm && rd == td {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// newOverride returns the occurrence of master starting at recurrenceID as
+// an unsaved override
+func newOverride(master *models.Event, recurrenceID time.Time) *models.Event {
+	override := *master
+	override.ID = uuid.New()
+	override.StartTime = recurrenceID
+	override.EndTime = recurrenceID.Add(master.EndTime.Sub(master.StartTime))
+	override.RecurrenceRule = ""
+	override.RecurrenceDates = nil
+	override.ExceptionDates = nil
+	override.RecurrenceID = &recurrenceID
+	override.OriginalEventID = &master.ID
+	override.ETag = ""
+	override.Attendees, override.Reminders, override.Overrides = nil, nil, nil
+	return &override
+}
+
+// AttachOverrides returns events with the overrides of recurring events
+// attached to their masters rather than listed on their own
+func (s *CalendarService) AttachOverrides(ctx context.Context, events []*models.Event) []*models.Event {
+	masters := make([]*models.Event, 0, len(events))
+	for _, e := range events {
+		if e.OriginalEventID != nil {
+			continue
+		}
+		if e.RecurrenceRule != "" || len(e.RecurrenceDates) > 0 {
+			e.Overrides, _ = s.eventRepo.GetRecurringInstances(ctx, e.ID)
+			for _, o := range e.Overrides {
+				o.Attendees, _ = s.attendeeRepo.GetByEventID(ctx, o.ID)
+			}
+			s.loadAttachments(ctx, e.Overrides...)
+		}
+		s.loadAttachments(ctx, e)
+		masters = append(masters, e)
+	}
+	return masters
+}
+
+// saveOverrides makes the stored overrides of master match those of a CalDAV
+// resource: overrides are matched on RECURRENCE-ID, and stored ones the
+// resource no longer has are deleted
+func (s *CalendarService) saveOverrides(ctx context.Context, master *models.Event, overrides []*models.Event) error {
+	existing, err := s.eventRepo.GetRecurringInstances(ctx, master.ID)
+	if err != nil {
+		return err
+	}
+	stored := make(map[int64]*models.Event, len(existing))
+	for _, e := range existing {
+		stored[e.RecurrenceID.Unix()] = e
+	}
+
+	for _, o := range overrides {
+		o.CalendarID = master.CalendarID
+		o.UID = master.UID
+		o.OriginalEventID = &master.ID
+		o.RecurrenceRule = ""
+
+		if old, ok := stored[o.RecurrenceID.Unix()]; ok {
+			delete(stored, o.RecurrenceID.Unix())
+			o.ID = old.ID
+			o.Sequence = max(o.Sequence, old.Sequence)
+			if o.Sequence == old.Sequence && isSignificantChange(old, o) {
+				o.Sequence++
+			}
+			if err := s.eventRepo.Update(ctx, o); err != nil {
+				return fmt.Errorf("update override: %w", err)
+			}
+			s.rescheduleReminders(ctx, old, o)
+			continue
+		}
+
+		o.ID = uuid.New()
+		o.OrganizerID = master.OrganizerID
+		if err := s.eventRepo.Create(ctx, o); err != nil {
+			return fmt.Errorf("create override: %w", err)
+		}
+	}
+
+	for _, stale := range stored {
+		if err := s.deleteOverride(ctx, stale.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteOverride deletes an override and releases its attachments
+func (s *CalendarService) deleteOverride(ctx context.Context, id uuid.UUID) error {
+	storageIDs, err := s.attachmentRepo.GetStorageIDsByEvent(ctx, id)
+	if err != nil {
+		return fmt.Errorf("list attachments: %w", err)
+	}
+	if err := s.eventRepo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete override: %w", err)
+	}
+	s.releaseAttachments(storageIDs)
+	return nil
+}
+
+// splitTimes partitions times into those before at and those from it on
+func splitTimes(times []time.Time, at time.Time) ([]time.Time, []time.Time) {
+	var before, after []time.Time
+	for _, t := range times {
+		if t.Before(at) {
+			before = append(before, t)
+		} else {
+			after = append(after, t)
+		}
+	}
+	return before, after
+}
+
+func copyAttendees(attendees []*models.Attendee) []*models.Attendee {
+	copies := make([]*models.Attendee, len(attendees))
+	for i, a := range attendees {
+		copies[i] = &models.Attendee{
+			UserID: a.UserID,
+			Email:  a.Email,
+			Name:   a.Name,
+			Role:   a.Role,
+			Status: a.Status,
+			RSVP:   a.RSVP,
+		}
+	}
+	return copies
+}
+
+// copyEventReminders gives an override or split-off series every user's
+// reminders from the series it came from. The editing user's are replaced
+// by reqs when given.
+func (s *CalendarService) copyEventReminders(ctx context.Context, masterID, eventID, userID uuid.UUID, reqs []models.CreateReminderRequest) {
+	masterReminders, _ := s.reminderRepo.GetByEventID(ctx, masterID)
+
+	byUser := make(map[uuid.UUID][]*models.Reminder)
+	for _, r := range masterReminders {
+		byUser[r.UserID] = append(byUser[r.UserID], &models.Reminder{Method: r.Method, Minutes: r.Minutes})
+	}
+	if reqs != nil {
+		byUser[userID] = convertRemindersToModels(eventID, reqs)
+	}
+
+	for owner, reminders := range byUser {
+		if len(reminders) == 0 {
+			continue
+		}
+		if err := s.reminderRepo.BulkCreate(ctx, eventID, owner, reminders); err != nil {
+			s.logger.Error("Failed to copy reminders",
+				zap.String("event_id", eventID.String()),
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRecurrenceIterations bounds expansion of a single rule so a malformed or
+// very long-running rule can't stall a request
+const maxRecurrenceIterations = 50000
+
+// recurrenceRule is the subset of an RFC 5545 RRULE used for expansion:
+// FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH
+type recurrenceRule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+// weekdayNum is a BYDAY entry such as MO, 2TU or -1FR. n is zero for every
+// occurrence of the weekday in the period.
+type weekdayNum struct {
+	n   int
+	day time.Weekday
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// parseRecurrenceRule parses an RRULE value, with or without the "RRULE:" prefix
+func parseRecurrenceRule(rule string, loc *time.Location) (*recurrenceRule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	r := &recurrenceRule{interval: 1}
+
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.freq = strings.ToUpper(value)
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL: %s", value)
+			}
+			r.interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT: %s", value)
+			}
+			r.count = n
+		case "UNTIL":
+			t, err := parseRecurrenceUntil(value, loc)
+			if err != nil {
+				return nil, err
+			}
+			r.until = t
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				v = strings.ToUpper(strings.TrimSpace(v))
+				if len(v) < 2 {
+					return nil, fmt.Errorf("invalid BYDAY: %s", value)
+				}
+				day, ok := icalWeekdays[v[len(v)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY: %s", value)
+				}
+				wn := weekdayNum{day: day}
+				if prefix := v[:len(v)-2]; prefix != "" {
+					n, err := strconv.Atoi(prefix)
+					if err != nil || n == 0 {
+						return nil, fmt.Errorf("invalid BYDAY: %s", value)
+					}
+					wn.n = n
+				}
+				r.byDay = append(r.byDay, wn)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY: %s", value)
+				}
+				r.byMonthDay = append(r.byMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("invalid BYMONTH: %s", value)
+				}
+				r.byMonth = append(r.byMonth, time.Month(n))
+			}
+		}
+	}
+
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported FREQ: %q", r.freq)
+	}
+
+	return r, nil
+}
+
+func parseRecurrenceUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		// A date UNTIL includes every instance on that day
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL: %s", value)
+}
+
+// expandRecurrence returns the start times of the instances of a recurring
+// event that overlap [windowStart, windowEnd). Instances are generated in the
+// event's timezone so wall-clock times hold across DST changes. COUNT is
+// applied from the first instance, not from the window.
+func expandRecurrence(rule string, dtstart time.Time, duration time.Duration, loc *time.Location, windowStart, windowEnd time.Time) ([]time.Time, error) {
+	r, err := parseRecurrenceRule(rule, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	dtstart = dtstart.In(loc)
+	var instances []time.Time
+	emitted := 0
+
+	for period := 0; period < maxRecurrenceIterations; period++ {
+		candidates, periodStart := r.periodCandidates(dtstart, period)
+		if !periodStart.Before(windowEnd) || (!r.until.IsZero() && periodStart.After(r.until)) {
+			break
+		}
+
+		for _, t := range candidates {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !r.until.IsZero() && t.After(r.until) {
+				return instances, nil
+			}
+			if r.count > 0 && emitted >= r.count {
+				return instances, nil
+			}
+			emitted++
+
+			if !t.Before(windowEnd) {
+				return instances, nil
+			}
+			if t.Add(duration).After(windowStart) {
+				instances = append(instances, t)
+			}
+		}
+	}
+
+	return instances, nil
+}
+
+// periodCandidates returns the sorted instance times in the nth period of the
+// rule, along with the start of that period
+func (r *recurrenceRule) periodCandidates(dtstart time.Time, n int) ([]time.Time, time.Time) {
+	loc := dtstart.Location()
+	y, m, d := dtstart.Date()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, loc)
+	}
+
+	var days []time.Time
+	var periodStart time.Time
+
+	switch r.freq {
+	case "DAILY":
+		day := at(y, m, d+n*r.interval)
+		periodStart = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+		if r.matchesMonth(day.Month()) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+
+	case "WEEKLY":
+		// Weeks start on Monday (the RFC 5545 WKST default)
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		weekStart := at(y, m, d-offset+n*7*r.interval)
+		periodStart = time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day(), 0, 0, 0, 0, loc)
+
+		weekdays := []time.Weekday{dtstart.Weekday()}
+		if len(r.byDay) > 0 {
+			weekdays = weekdays[:0]
+			for _, wn := range r.byDay {
+				weekdays = append(weekdays, wn.day)
+			}
+		}
+		for _, wd := range weekdays {
+			day := weekStart.AddDate(0, 0, (int(wd)+6)%7)
+			if r.matchesMonth(day.Month()) {
+				days = append(days, day)
+			}
+		}
+
+	case "MONTHLY":
+		first := time.Date(y, m+time.Month(n*r.interval), 1, 0, 0, 0, 0, loc)
+		periodStart = first
+		if r.matchesMonth(first.Month()) {
+			days = r.daysInRange(first, first.AddDate(0, 1, -1), d, at)
+		}
+
+	case "YEARLY":
+		year := y + n*r.interval
+		periodStart = time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+
+		switch {
+		case len(r.byMonth) > 0:
+			for _, month := range r.byMonth {
+				first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+				days = append(days, r.daysInRange(first, first.AddDate(0, 1, -1), d, at)...)
+			}
+		case len(r.byDay) > 0 && len(r.byMonthDay) == 0:
+			// Ordinal weekdays count within the whole year
+			days = r.daysInRange(periodStart, time.Date(year, time.December, 31, 0, 0, 0, 0, loc), d, at)
+		default:
+			first := time.Date(year, m, 1, 0, 0, 0, 0, loc)
+			days = r.daysInRange(first, first.AddDate(0, 1, -1), d, at)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, periodStart
+}
+
+// daysInRange expands BYMONTHDAY or BYDAY within a month or year, falling back
+// to the day of month of the first instance. Days that don't exist in the
+// month, such as the 31st of April, are skipped.
+func (r *recurrenceRule) daysInRange(first, last time.Time, defaultDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	var days []time.Time
+
+	switch {
+	case len(r.byMonthDay) > 0:
+		for _, md := range r.byMonthDay {
+			day := md
+			if md < 0 {
+				day = last.Day() + md + 1
+			}
+			if day < 1 || day > last.Day() {
+				continue
+			}
+			t := at(first.Year(), first.Month(), day)
+			if r.matchesWeekday(t) {
+				days = append(days, t)
+			}
+		}
+
+	case len(r.byDay) > 0:
+		for _, wn := range r.byDay {
+			var matches []time.Time
+			for t := first; !t.After(last); t = t.AddDate(0, 0, 1) {
+				if t.Weekday() == wn.day {
+					matches = append(matches, at(t.Year(), t.Month(), t.Day()))
+				}
+			}
+			switch {
+			case wn.n == 0:
+				days = append(days, matches...)
+			case wn.n > 0 && wn.n <= len(matches):
+				days = append(days, matches[wn.n-1])
+			case wn.n < 0 && -wn.n <= len(matches):
+				days = append(days, matches[len(matches)+wn.n])
+			}
+		}
+
+	default:
+		if defaultDay <= last.Day() {
+			days = append(days, at(first.Year(), first.Month(), defaultDay))
+		}
+	}
+
+	return days
+}
+
+func (r *recurrenceRule) matchesMonth(m time.Month) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, bm := range r.byMonth {
+		if bm == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *recurrenceRule) matchesMonthDay(t time.Time) bool {
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, md := range r.byMonthDay {
+		if md == t.Day() || (md < 0 && last+md+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *recurrenceRule) matchesWeekday(t time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, wn := range r.byDay {
+		if wn.day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// splitRecurrenceRule splits a rule at the instance starting at splitAt,
+// returning a rule for the instances before it and one for the instances
+// from it on, when expanded from splitAt. The head gets an UNTIL just before
+// splitAt; the tail keeps the rule, with COUNT reduced by the instances
+// already in the head.
+func splitRecurrenceRule(rule string, dtstart, splitAt time.Time, loc *time.Location, allDay bool) (string, string, error) {
+	r, err := parseRecurrenceRule(rule, loc)
+	if err != nil {
+		return "", "", err
+	}
+
+	until := splitAt.Add(-time.Second).UTC().Format(icalDateTimeFormat)
+	if allDay {
+		until = splitAt.In(loc).AddDate(0, 0, -1).Format(icalDateFormat)
+	}
+
+	var head, tail []string
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		key, _, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(key) {
+		case "UNTIL":
+			tail = append(tail, part)
+		case "COUNT":
+			before, err := expandRecurrence(rule, dtstart, 0, loc, dtstart.Add(-time.Nanosecond), splitAt)
+			if err != nil {
+				return "", "", err
+			}
+			if r.count <= len(before) {
+				return "", "", fmt.Errorf("rule has no instances from %s", splitAt.Format(time.RFC3339))
+			}
+			tail = append(tail, "COUNT="+strconv.Itoa(r.count-len(before)))
+		case "":
+		default:
+			head = append(head, part)
+			tail = append(tail, part)
+		}
+	}
+	head = append(head, "UNTIL="+until)
+
+	return strings.Join(head, ";"), strings.Join(tail, ";"), nil
+}
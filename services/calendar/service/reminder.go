@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"calendar-service/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidReminder is returned for reminder settings that can't be used
+var ErrInvalidReminder = errors.New("invalid reminder")
+
+// maxRemindersPerUser bounds the reminders one user sets on an event
+const maxRemindersPerUser = 5
+
+// occurrenceHorizons are the windows searched, in turn, for a recurring
+// event's next occurrence, so frequent rules are expanded over little time
+var occurrenceHorizons = []time.Duration{31 * 24 * time.Hour, 400 * 24 * time.Hour, 5 * 366 * 24 * time.Hour}
+
+// validateReminders checks the reminders a user asks for
+func validateReminders(reqs []models.CreateReminderRequest) error {
+	if len(reqs) > maxRemindersPerUser {
+		return fmt.Errorf("%w: at most %d per event", ErrInvalidReminder, maxRemindersPerUser)
+	}
+	for _, r := range reqs {
+		switch r.Method {
+		case models.ReminderEmail, models.ReminderSMS, models.ReminderPush, models.ReminderDisplay, models.ReminderAudio:
+		default:
+			return fmt.Errorf("%w: unknown method %s", ErrInvalidReminder, r.Method)
+		}
+		if r.Minutes < 0 || r.Minutes > 40320 {
+			return fmt.Errorf("%w: minutes must be between 0 and 40320", ErrInvalidReminder)
+		}
+	}
+	return nil
+}
+
+// canSetReminders reports whether a user may set reminders on an event:
+// anyone who can read its calendar, and its attendees
+func (s *CalendarService) canSetReminders(ctx context.Context, userID uuid.UUID, event *models.Event) bool {
+	if hasAccess, err := s.calendarRepo.HasAccess(ctx, event.CalendarID, userID, "read"); err == nil && hasAccess {
+		return true
+	}
+	attendees, _ := s.attendeeRepo.GetByEventID(ctx, event.ID)
+	for _, a := range attendees {
+		if a.UserID != nil && *a.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMyReminders returns a user's own reminders for an event
+func (s *CalendarService) GetMyReminders(ctx context.Context, userID, eventID uuid.UUID) ([]*models.Reminder, error) {
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found")
+	}
+	if !s.canSetReminders(ctx, userID, event) {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	return s.reminderRepo.GetForUser(ctx, eventID, userID)
+}
+
+// SetMyReminders replaces a user's own reminders for an event. Each fires
+// before every occurrence of a recurring event.
+func (s *CalendarService) SetMyReminders(ctx context.Context, userID, eventID uuid.UUID, reqs []models.CreateReminderRequest) ([]*models.Reminder, error) {
+	if err := validateReminders(reqs); err != nil {
+		return nil, err
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found")
+	}
+	if !s.canSetReminders(ctx, userID, event) {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	if err := s.reminderRepo.ReplaceForEvent(ctx, eventID, userID, convertRemindersToModels(eventID, reqs)); err != nil {
+		return nil, fmt.Errorf("set reminders: %w", err)
+	}
+
+	return s.reminderRepo.GetForUser(ctx, eventID, userID)
+}
+
+// SnoozeReminder fires a user's reminder again, after req.Minutes or at
+// req.Until, for the occurrence it last fired for
+func (s *CalendarService) SnoozeReminder(ctx context.Context, userID, reminderID uuid.UUID, req *models.SnoozeReminderRequest) (*models.Reminder, error) {
+	now := time.Now()
+
+	var until time.Time
+	switch {
+	case req.Until != nil:
+		until = *req.Until
+	case req.Minutes > 0:
+		until = now.Add(time.Duration(req.Minutes) * time.Minute)
+	default:
+		return nil, fmt.Errorf("%w: minutes or until is required", ErrInvalidReminder)
+	}
+	if !until.After(now) || until.After(now.Add(7*24*time.Hour)) {
+		return nil, fmt.Errorf("%w: snooze must end within a week", ErrInvalidReminder)
+	}
+
+	snoozed, err := s.reminderRepo.Snooze(ctx, reminderID, userID, until)
+	if err != nil {
+		return nil, fmt.Errorf("snooze reminder: %w", err)
+	}
+	if !snoozed {
+		return nil, fmt.Errorf("reminder not found")
+	}
+
+	return s.reminderRepo.GetByID(ctx, reminderID)
+}
+
+// GetReminderPreferences returns a user's reminder preferences
+func (s *CalendarService) GetReminderPreferences(ctx context.Context, userID uuid.UUID) (*models.ReminderPreferences, error) {
+	return s.reminderRepo.GetPreferences(ctx, userID)
+}
+
+// UpdateReminderPreferences replaces a user's reminder preferences
+func (s *CalendarService) UpdateReminderPreferences(ctx context.Context, userID uuid.UUID, req *models.UpdateReminderPreferencesRequest) (*models.ReminderPreferences, error) {
+	if err := validateReminders(req.DefaultReminders); err != nil {
+		return nil, err
+	}
+	if req.PhoneNumber != "" && !isE164(req.PhoneNumber) {
+		return nil, fmt.Errorf("%w: phone number must be in E.164 format", ErrInvalidReminder)
+	}
+
+	prefs := &models.ReminderPreferences{
+		UserID:           userID,
+		PhoneNumber:      req.PhoneNumber,
+		DefaultReminders: req.DefaultReminders,
+	}
+	if prefs.DefaultReminders == nil {
+		prefs.DefaultReminders = []models.CreateReminderRequest{}
+	}
+
+	if err := s.reminderRepo.SavePreferences(ctx, prefs); err != nil {
+		return nil, fmt.Errorf("save reminder preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// applyDefaultReminders gives an attendee who accepted an event their
+// default reminders, unless they have set reminders for it already
+func (s *CalendarService) applyDefaultReminders(ctx context.Context, userID, eventID uuid.UUID) {
+	has, err := s.reminderRepo.HasReminders(ctx, eventID, userID)
+	if err != nil || has {
+		return
+	}
+
+	prefs, err := s.reminderRepo.GetPreferences(ctx, userID)
+	if err != nil || len(prefs.DefaultReminders) == 0 {
+		return
+	}
+
+	if err := s.reminderRepo.BulkCreate(ctx, eventID, userID, convertRemindersToModels(eventID, prefs.DefaultReminders)); err != nil {
+		s.logger.Error("Failed to add default reminders",
+			zap.String("event_id", eventID.String()),
+			zap.Error(err))
+	}
+}
+
+// NextOccurrence returns the start of an event's first occurrence after
+// after, for moving its reminders on. Occurrences stored as overrides are
+// skipped, as they have reminders of their own. It returns nil when there
+// are no more occurrences, or the event is gone.
+func (s *CalendarService) NextOccurrence(ctx context.Context, eventID uuid.UUID, after time.Time) (*time.Time, error) {
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	if event.OriginalEventID != nil || (event.RecurrenceRule == "" && len(event.RecurrenceDates) == 0) {
+		return nil, nil
+	}
+
+	overrides, err := s.eventRepo.GetRecurringInstances(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	overridden := make(map[int64]bool, len(overrides))
+	for _, o := range overrides {
+		if o.RecurrenceID != nil {
+			overridden[o.RecurrenceID.Unix()] = true
+		}
+	}
+
+	for _, horizon := range occurrenceHorizons {
+		for _, t := range s.eventInstances(event, after, after.Add(horizon)) {
+			if t.After(after) && !overridden[t.Unix()] {
+				return &t, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// isE164 reports whether a phone number is in E.164 format
+func isE164(number string) bool {
+	if len(number) < 3 || len(number) > 16 || number[0] != '+' || number[1] == '0' {
+		return false
+	}
+	for _, c := range number[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
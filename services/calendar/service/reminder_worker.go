@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"calendar-service/config"
+	"calendar-service/models"
 	"calendar-service/repository"
 
 	"go.uber.org/zap"
@@ -11,22 +14,28 @@ import (
 
 type ReminderWorker struct {
 	reminderRepo *repository.ReminderRepository
+	calendar     *CalendarService
 	notification *NotificationService
 	logger       *zap.Logger
 	interval     time.Duration
+	gracePeriod  time.Duration
 	stopChan     chan struct{}
 }
 
 func NewReminderWorker(
 	reminderRepo *repository.ReminderRepository,
+	calendar *CalendarService,
 	notification *NotificationService,
+	cfg config.RemindersConfig,
 	logger *zap.Logger,
 ) *ReminderWorker {
 	return &ReminderWorker{
 		reminderRepo: reminderRepo,
+		calendar:     calendar,
 		notification: notification,
 		logger:       logger,
-		interval:     1 * time.Minute,
+		interval:     cfg.Interval,
+		gracePeriod:  cfg.GracePeriod,
 		stopChan:     make(chan struct{}),
 	}
 }
@@ -57,15 +66,17 @@ func (w *ReminderWorker) Stop() {
 	close(w.stopChan)
 }
 
-// processReminders processes pending reminders
+// processReminders sends the reminders that are due. A reminder that
+// can't be sent is retried on the next run until it's more than the grace
+// period late; it is then dropped, so nobody is reminded of an event long
+// after the fact, e.g. when the service was down.
 func (w *ReminderWorker) processReminders() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Get reminders due in the next 5 minutes
-	reminders, err := w.reminderRepo.GetPendingReminders(ctx, 5)
+	reminders, err := w.reminderRepo.GetDueReminders(ctx, 100)
 	if err != nil {
-		w.logger.Error("Failed to get pending reminders", zap.Error(err))
+		w.logger.Error("Failed to get due reminders", zap.Error(err))
 		return
 	}
 
@@ -75,32 +86,79 @@ func (w *ReminderWorker) processReminders() {
 
 	w.logger.Info("Processing reminders", zap.Int("count", len(reminders)))
 
+	now := time.Now()
 	for _, r := range reminders {
-		// Send based on method
-		var err error
-		switch r.Method {
-		case "email":
-			err = w.notification.SendReminder(ctx, r)
-		case "display":
-			// Display notifications would go through push/websocket
-			// For now, just log
-			w.logger.Info("Display reminder triggered",
-				zap.String("event_id", r.EventID.String()),
-				zap.String("title", r.Title))
-		}
-
-		if err != nil {
-			w.logger.Error("Failed to send reminder",
+		missed := now.Sub(r.TriggerTime) > w.gracePeriod
+		if missed {
+			w.logger.Warn("Skipping missed reminder",
 				zap.String("reminder_id", r.ReminderID.String()),
-				zap.Error(err))
-			continue
+				zap.Time("trigger_time", r.TriggerTime))
+		} else if err := w.send(ctx, r); err != nil {
+			if !errors.Is(err, ErrNoReminderAddress) {
+				w.logger.Error("Failed to send reminder",
+					zap.String("reminder_id", r.ReminderID.String()),
+					zap.String("method", r.Method),
+					zap.Error(err))
+				continue
+			}
+			w.logger.Warn("No address for reminder",
+				zap.String("reminder_id", r.ReminderID.String()),
+				zap.String("method", r.Method))
 		}
 
-		// Mark as triggered
-		if err := w.reminderRepo.MarkTriggered(ctx, r.ReminderID); err != nil {
-			w.logger.Error("Failed to mark reminder as triggered",
+		w.done(ctx, r, now)
+	}
+}
+
+// send delivers a reminder by its method
+func (w *ReminderWorker) send(ctx context.Context, r *models.EventWithReminder) error {
+	switch r.Method {
+	case models.ReminderEmail:
+		return w.notification.SendReminder(ctx, r)
+	case models.ReminderSMS:
+		return w.notification.SendSMSReminder(ctx, r)
+	case models.ReminderPush:
+		return w.notification.SendPushReminder(ctx, r)
+	default:
+		// Display and audio alarms are raised by clients from the VALARMs
+		// they sync
+		w.logger.Info("Display reminder triggered",
+			zap.String("event_id", r.EventID.String()),
+			zap.String("title", r.Title))
+		return nil
+	}
+}
+
+// done moves a reminder on once it has fired or been missed: a snoozed one
+// back to waiting for its next occurrence, and others on to the next
+// occurrence of a recurring event
+func (w *ReminderWorker) done(ctx context.Context, r *models.EventWithReminder, now time.Time) {
+	if r.Snoozed {
+		if err := w.reminderRepo.ClearSnooze(ctx, r.ReminderID); err != nil {
+			w.logger.Error("Failed to clear reminder snooze",
 				zap.String("reminder_id", r.ReminderID.String()),
 				zap.Error(err))
 		}
+		return
+	}
+
+	// The next occurrence whose reminder time hasn't passed yet
+	after := now.Add(time.Duration(r.Minutes) * time.Minute)
+	if r.StartTime.After(after) {
+		after = r.StartTime
+	}
+
+	next, err := w.calendar.NextOccurrence(ctx, r.EventID, after)
+	if err != nil {
+		w.logger.Error("Failed to find next occurrence",
+			zap.String("event_id", r.EventID.String()),
+			zap.Error(err))
+		return
+	}
+
+	if err := w.reminderRepo.Advance(ctx, r.ReminderID, r.StartTime, next); err != nil {
+		w.logger.Error("Failed to mark reminder as triggered",
+			zap.String("reminder_id", r.ReminderID.String()),
+			zap.Error(err))
 	}
 }
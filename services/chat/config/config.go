@@ -40,6 +40,7 @@ type Config struct {
 	Storage  StorageConfig  `yaml:"storage"`
 	Metrics  MetricsConfig  `yaml:"metrics"`
 	Limits   LimitsConfig   `yaml:"limits"`
+	Scanning ScanningConfig `yaml:"scanning"`
 }
 
 type ServerConfig struct {
@@ -64,6 +65,17 @@ type AuthConfig struct {
 	JWTSecret      string   `yaml:"jwtSecret"`
 	ServiceURL     string   `yaml:"serviceUrl"`
 	AllowedOrigins []string `yaml:"allowedOrigins"`
+
+	// Issuer and Audience must match the auth service's JWT_ISSUER and
+	// JWT_AUDIENCE
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+
+	// SigningKeys are the secrets tokens with a kid header are verified
+	// with, by kid. JWTSecret verifies tokens without one. To rotate, add the
+	// new key, switch the auth service to it and remove the old key once the
+	// tokens it signed have expired.
+	SigningKeys map[string]string `yaml:"signingKeys"`
 }
 
 type StorageConfig struct {
@@ -74,6 +86,15 @@ type StorageConfig struct {
 	MaxFileSize     int64  `yaml:"maxFileSize"`
 }
 
+// ScanningConfig selects the malware scanner uploads go through
+type ScanningConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Provider   string `yaml:"provider"`   // "clamav"
+	ClamAVAddr string `yaml:"clamavAddr"` // "tcp://host:3310" or "unix:///run/clamav/clamd.sock"
+	Timeout    int    `yaml:"timeout"`    // Seconds a scan may take
+	FailOpen   bool   `yaml:"failOpen"`   // Store files unscanned when the scanner times out or is down
+}
+
 type MetricsConfig struct {
 	Port string `yaml:"port"`
 }
@@ -84,6 +105,7 @@ type LimitsConfig struct {
 	MaxMembersPerChannel int `yaml:"maxMembersPerChannel"`
 	MaxFileSize         int64 `yaml:"maxFileSize"`
 	RateLimitPerMinute  int `yaml:"rateLimitPerMinute"`
+	MaxPinnedMessages   int `yaml:"maxPinnedMessages"` // Per channel
 }
 
 func Load(path string) (*Config, error) {
@@ -119,6 +141,18 @@ func Load(path string) (*Config, error) {
 	if cfg.Limits.RateLimitPerMinute == 0 {
 		cfg.Limits.RateLimitPerMinute = 60
 	}
+	if cfg.Limits.MaxPinnedMessages == 0 {
+		cfg.Limits.MaxPinnedMessages = 50
+	}
+	if cfg.Scanning.Timeout == 0 {
+		cfg.Scanning.Timeout = 30
+	}
+	if cfg.Auth.Issuer == "" {
+		cfg.Auth.Issuer = "auth-service"
+	}
+	if cfg.Auth.Audience == "" {
+		cfg.Auth.Audience = "email-platform"
+	}
 
 	return &cfg, nil
 }
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"chat/internal/models"
+	"chat/internal/repository"
+)
+
+const (
+	maxEmojiLength      = 50 // chat_reactions.emoji is VARCHAR(50)
+	maxCustomEmojiSize  = 256 * 1024
+	emojiZeroWidthJoin  = '\u200d'
+	emojiKeycapCombiner = '\u20e3'
+)
+
+var (
+	// customEmojiNameRegex allows lowercase letters, digits, _, + and -
+	customEmojiNameRegex = regexp.MustCompile(`^[a-z0-9_+\-]{1,32}$`)
+
+	customEmojiTypes = map[string]string{
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+		"image/jpeg": ".jpg",
+		"image/webp": ".webp",
+	}
+)
+
+// emojiRanges are the code point ranges emoji are drawn from
+var emojiRanges = [][2]rune{
+	{0x00A9, 0x00A9}, {0x00AE, 0x00AE},
+	{0x203C, 0x203C}, {0x2049, 0x2049},
+	{0x2122, 0x2122}, {0x2139, 0x2139},
+	{0x2190, 0x21FF}, // Arrows
+	{0x2300, 0x23FF}, // Miscellaneous Technical
+	{0x24C2, 0x24C2},
+	{0x25A0, 0x25FF}, // Geometric Shapes
+	{0x2600, 0x27BF}, // Miscellaneous Symbols, Dingbats
+	{0x2934, 0x2935},
+	{0x2B00, 0x2BFF}, // Miscellaneous Symbols and Arrows
+	{0x3030, 0x3030}, {0x303D, 0x303D},
+	{0x3297, 0x3297}, {0x3299, 0x3299},
+	{0x1F000, 0x1FAFF}, // Pictographs, emoticons, flags and their extensions
+}
+
+// isUnicodeEmoji reports whether s is a single standard emoji, including
+// ZWJ sequences, skin tone and variation modifiers, keycaps and flags
+func isUnicodeEmoji(s string) bool {
+	if s == "" || utf8.RuneCountInString(s) > maxEmojiLength {
+		return false
+	}
+
+	keycap := strings.ContainsRune(s, emojiKeycapCombiner)
+	base := false
+	for _, r := range s {
+		switch {
+		case isEmojiBase(r):
+			base = true
+		case keycap && (r >= '0' && r <= '9' || r == '#' || r == '*'):
+			base = true
+		case r == emojiZeroWidthJoin, r == emojiKeycapCombiner,
+			r == '\ufe0e', r == '\ufe0f', // Variation selectors
+			r >= 0xE0020 && r <= 0xE007F: // Tag sequences for subdivision flags
+		default:
+			return false
+		}
+	}
+	return base
+}
+
+func isEmojiBase(r rune) bool {
+	for _, rng := range emojiRanges {
+		if r >= rng[0] && r <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// customEmojiName returns the name in a :custom_name: reaction
+func customEmojiName(emoji string) (string, bool) {
+	if len(emoji) < 3 || !strings.HasPrefix(emoji, ":") || !strings.HasSuffix(emoji, ":") {
+		return "", false
+	}
+	name := emoji[1 : len(emoji)-1]
+	if !customEmojiNameRegex.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// validateReactionEmoji checks that a reaction is a standard emoji or a
+// custom emoji registered by the organization
+func (s *Server) validateReactionEmoji(ctx context.Context, orgID uuid.UUID, emoji string) error {
+	name, ok := customEmojiName(emoji)
+	if !ok {
+		if !isUnicodeEmoji(emoji) {
+			return &ValidationError{Field: "emoji", Message: "emoji must be a standard emoji or a registered :custom_name:"}
+		}
+		return nil
+	}
+
+	urls, err := s.repo.GetCustomEmojiURLs(ctx, orgID, []string{name})
+	if err != nil {
+		return err
+	}
+	if _, ok := urls[name]; !ok {
+		return &ValidationError{Field: "emoji", Message: "unknown custom emoji " + emoji}
+	}
+	return nil
+}
+
+// resolveCustomEmoji sets the image URL of the custom emoji in summaries
+func (s *Server) resolveCustomEmoji(ctx context.Context, orgID uuid.UUID, summaries map[uuid.UUID][]models.ReactionSummary) {
+	var names []string
+	for _, list := range summaries {
+		for _, summary := range list {
+			if name, ok := customEmojiName(summary.Emoji); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	urls, err := s.repo.GetCustomEmojiURLs(ctx, orgID, names)
+	if err != nil {
+		s.logger.Warn("Failed to resolve custom emoji", zap.Error(err))
+		return
+	}
+
+	for _, list := range summaries {
+		for i := range list {
+			if name, ok := customEmojiName(list[i].Emoji); ok {
+				list[i].ImageURL = urls[name]
+			}
+		}
+	}
+}
+
+// ============================================================================
+// Custom Emoji Handlers
+// ============================================================================
+
+func (s *Server) listEmojis(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
+
+	emojis, err := s.repo.ListCustomEmojis(r.Context(), user.OrganizationID)
+	if err != nil {
+		s.logger.Error("Failed to list custom emoji", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to list custom emoji")
+		return
+	}
+	if emojis == nil {
+		emojis = []models.CustomEmoji{}
+	}
+
+	s.respondJSON(w, http.StatusOK, emojis)
+}
+
+func (s *Server) createEmoji(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCustomEmojiSize+64*1024)
+	if err := r.ParseMultipartForm(maxCustomEmojiSize); err != nil {
+		s.respondError(w, http.StatusBadRequest, "image too large")
+		return
+	}
+
+	name := strings.ToLower(strings.Trim(strings.TrimSpace(r.FormValue("name")), ":"))
+	if !customEmojiNameRegex.MatchString(name) {
+		s.respondError(w, http.StatusBadRequest, "name must be 1-32 lowercase letters, digits, _, + or -")
+		return
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "missing image")
+		return
+	}
+	defer file.Close()
+
+	contentType := header.Header.Get("Content-Type")
+	ext, ok := customEmojiTypes[contentType]
+	if !ok {
+		s.respondError(w, http.StatusBadRequest, "image must be PNG, GIF, JPEG or WebP")
+		return
+	}
+	if header.Size > maxCustomEmojiSize {
+		s.respondError(w, http.StatusBadRequest, "image too large")
+		return
+	}
+
+	// Note: Storage upload is configured via storage service, as for
+	// attachments
+	fileID := uuid.New()
+	emoji := &models.CustomEmoji{
+		OrganizationID: user.OrganizationID,
+		Name:           name,
+		ImageURL:       "/api/v1/files/" + fileID.String() + "/" + name + ext,
+		StoragePath:    path.Join("emoji", user.OrganizationID.String(), fileID.String()+ext),
+		ContentType:    contentType,
+		CreatedBy:      user.UserID,
+	}
+
+	if err := s.repo.CreateCustomEmoji(r.Context(), emoji); err != nil {
+		if errors.Is(err, repository.ErrCustomEmojiExists) {
+			s.respondError(w, http.StatusConflict, "custom emoji :"+name+": already exists")
+			return
+		}
+		s.logger.Error("Failed to create custom emoji", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to create custom emoji")
+		return
+	}
+
+	s.logger.Info("Custom emoji created",
+		zap.String("user_id", user.UserID.String()),
+		zap.String("name", name),
+		zap.Int64("size", header.Size),
+	)
+
+	s.respondJSON(w, http.StatusCreated, emoji)
+}
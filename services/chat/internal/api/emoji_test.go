@@ -0,0 +1,51 @@
+package api
+
+import "testing"
+
+func TestIsUnicodeEmoji(t *testing.T) {
+	tests := []struct {
+		emoji string
+		want  bool
+	}{
+		{"👍", true},
+		{"👍🏽", true},
+		{"❤️", true},
+		{"👨‍👩‍👧", true},
+		{"🇫🇷", true},
+		{"1️⃣", true},
+		{"", false},
+		{"1", false},
+		{"abc", false},
+		{"👍 ", false},
+		{"<script>", false},
+		{"️", false},
+	}
+
+	for _, tt := range tests {
+		if got := isUnicodeEmoji(tt.emoji); got != tt.want {
+			t.Errorf("isUnicodeEmoji(%q) = %v, want %v", tt.emoji, got, tt.want)
+		}
+	}
+}
+
+func TestCustomEmojiName(t *testing.T) {
+	tests := []struct {
+		emoji  string
+		want   string
+		wantOK bool
+	}{
+		{":party_parrot:", "party_parrot", true},
+		{":+1:", "+1", true},
+		{"party_parrot", "", false},
+		{"::", "", false},
+		{":Party:", "", false},
+		{":has space:", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := customEmojiName(tt.emoji)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("customEmojiName(%q) = %q, %v, want %q, %v", tt.emoji, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -1,22 +1,31 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/gosimple/slug"
 	"go.uber.org/zap"
 
 	"chat/internal/hub"
 	"chat/internal/models"
+	"chat/internal/repository"
+	"chat/internal/scanner"
 )
 
 // ============================================================================
@@ -28,6 +37,9 @@ const (
 	maxDescriptionLength    = 500
 	maxStatusTextLength     = 100
 	maxSearchQueryLength    = 200
+	maxSearchResults        = 50
+	defaultReactionUsers    = 50
+	maxReactionUsers        = 100
 	minChannelNameLength    = 1
 )
 
@@ -71,8 +83,10 @@ func validateDescription(desc string) string {
 	return sanitizeString(desc)
 }
 
-// validateMessageContent validates message content
-func validateMessageContent(content string, maxLength int) (string, error) {
+// validateMessageContent validates message content. Markdown is kept as
+// written, since escaping it would break its syntax; it is made safe when
+// rendered to HTML instead.
+func validateMessageContent(content, contentType string, maxLength int) (string, error) {
 	content = strings.TrimSpace(content)
 
 	if content == "" {
@@ -83,6 +97,10 @@ func validateMessageContent(content string, maxLength int) (string, error) {
 		return "", &ValidationError{Field: "content", Message: "message is too long"}
 	}
 
+	if contentType == contentTypeMarkdown {
+		return content, nil
+	}
+
 	// Sanitize HTML to prevent XSS
 	return sanitizeString(content), nil
 }
@@ -102,6 +120,25 @@ func validateSearchQuery(query string) (string, error) {
 	return sanitizeString(query), nil
 }
 
+// parseSearchTime parses a from/to search bound as RFC 3339 or a plain date.
+// A plain date used as an upper bound covers the whole day.
+func parseSearchTime(field, value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, &ValidationError{Field: field, Message: field + " must be a date (YYYY-MM-DD) or RFC 3339 timestamp"}
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+	return &t, nil
+}
+
 // ValidationError represents an input validation error
 type ValidationError struct {
 	Field   string `json:"field"`
@@ -211,6 +248,7 @@ func (s *Server) listJoinedChannels(w http.ResponseWriter, r *http.Request) {
 		s.respondError(w, http.StatusInternalServerError, "failed to list channels")
 		return
 	}
+	s.applyDirectPartners(r.Context(), user.UserID, channels)
 
 	s.respondJSON(w, http.StatusOK, map[string]interface{}{
 		"channels": channels,
@@ -233,7 +271,7 @@ func (s *Server) getChannel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check access
-	if channel.Type == models.ChannelTypePrivate {
+	if channel.Type.MembersOnly() {
 		isMember, _ := s.repo.IsMember(r.Context(), channelID, user.UserID)
 		if !isMember {
 			s.respondError(w, http.StatusForbidden, "access denied")
@@ -337,6 +375,10 @@ func (s *Server) joinChannel(w http.ResponseWriter, r *http.Request) {
 		s.respondError(w, http.StatusForbidden, "cannot join private channel without invitation")
 		return
 	}
+	if channel.Type == models.ChannelTypeDirect {
+		s.respondError(w, http.StatusForbidden, "cannot join a direct message")
+		return
+	}
 
 	member := &models.ChannelMember{
 		ChannelID: channelID,
@@ -369,6 +411,10 @@ func (s *Server) leaveChannel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.checkNotDirect(w, r, channelID) {
+		return
+	}
+
 	if err := s.repo.RemoveChannelMember(r.Context(), channelID, user.UserID); err != nil {
 		s.logger.Error("Failed to leave channel", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to leave channel")
@@ -442,6 +488,9 @@ func (s *Server) addMember(w http.ResponseWriter, r *http.Request) {
 		s.respondError(w, http.StatusForbidden, "access denied")
 		return
 	}
+	if !s.checkNotDirect(w, r, channelID) {
+		return
+	}
 
 	role := req.Role
 	if role == "" {
@@ -476,6 +525,10 @@ func (s *Server) removeMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.checkNotDirect(w, r, channelID) {
+		return
+	}
+
 	if err := s.repo.RemoveChannelMember(r.Context(), channelID, userID); err != nil {
 		s.logger.Error("Failed to remove member", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to remove member")
@@ -498,15 +551,102 @@ func (s *Server) markAsRead(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	if err := s.repo.UpdateLastRead(r.Context(), channelID, user.UserID, req.MessageID); err != nil {
+	readAt, err := s.repo.UpdateLastRead(r.Context(), channelID, user.UserID, req.MessageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.respondError(w, http.StatusForbidden, "not a member of this channel")
+		return
+	}
+	if err != nil {
 		s.logger.Error("Failed to mark as read", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to mark as read")
 		return
 	}
 
+	s.hub.BroadcastRead(channelID, &hub.ReadState{
+		UserID:        user.UserID,
+		LastReadMsgID: req.MessageID,
+		LastReadAt:    readAt,
+	})
+
 	s.respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+// checkNotDirect checks that a channel's membership can change, responding
+// with an error if not. A direct message always has the members it was
+// created with.
+func (s *Server) checkNotDirect(w http.ResponseWriter, r *http.Request, channelID uuid.UUID) bool {
+	channel, err := s.repo.GetChannel(r.Context(), channelID)
+	if err != nil {
+		s.respondError(w, http.StatusNotFound, "channel not found")
+		return false
+	}
+	if channel.Type == models.ChannelTypeDirect {
+		s.respondError(w, http.StatusBadRequest, "direct message members can't be changed")
+		return false
+	}
+	return true
+}
+
+func (s *Server) getNotificationLevel(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelID"))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid channel id")
+		return
+	}
+
+	levels, err := s.repo.GetMemberNotificationLevels(r.Context(), channelID)
+	if err != nil {
+		s.logger.Error("Failed to get notification level", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to get notification level")
+		return
+	}
+	level, ok := levels[user.UserID]
+	if !ok {
+		s.respondError(w, http.StatusForbidden, "not a member of this channel")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]models.NotificationLevel{"notification_level": level})
+}
+
+func (s *Server) setNotificationLevel(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelID"))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid channel id")
+		return
+	}
+
+	var req struct {
+		NotificationLevel models.NotificationLevel `json:"notification_level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	switch req.NotificationLevel {
+	case models.NotificationLevelAll, models.NotificationLevelMentions, models.NotificationLevelMuted:
+	default:
+		s.respondError(w, http.StatusBadRequest, "notification_level must be all, mentions or muted")
+		return
+	}
+
+	err = s.repo.SetNotificationLevel(r.Context(), channelID, user.UserID, req.NotificationLevel)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.respondError(w, http.StatusForbidden, "not a member of this channel")
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to set notification level", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to set notification level")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]models.NotificationLevel{"notification_level": req.NotificationLevel})
+}
+
 // ============================================================================
 // Message Handlers
 // ============================================================================
@@ -543,13 +683,6 @@ func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate and sanitize message content
-	validatedContent, err := validateMessageContent(req.Content, s.cfg.Limits.MaxMessageLength)
-	if err != nil {
-		s.respondError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
 	contentType := req.ContentType
 	if contentType == "" {
 		contentType = "text"
@@ -562,6 +695,13 @@ func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Validate and sanitize message content
+	validatedContent, err := validateMessageContent(req.Content, contentType, s.cfg.Limits.MaxMessageLength)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	message := &models.Message{
 		ChannelID:   channelID,
 		UserID:      user.UserID,
@@ -570,6 +710,9 @@ func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
 		ContentType: contentType,
 		Metadata:    models.JSONMap(req.Metadata),
 	}
+	if contentType == contentTypeMarkdown {
+		message.ContentHTML = renderMarkdown(validatedContent)
+	}
 
 	if err := s.repo.CreateMessage(r.Context(), message); err != nil {
 		s.logger.Error("Failed to create message", zap.Error(err))
@@ -581,6 +724,8 @@ func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
 	userInfo, _ := s.repo.GetUser(r.Context(), user.UserID)
 	message.User = userInfo
 
+	s.processMentions(r.Context(), user, message)
+
 	// Broadcast message to channel
 	s.hub.BroadcastMessage(channelID, message)
 
@@ -602,7 +747,7 @@ func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if channel.Type == models.ChannelTypePrivate {
+	if channel.Type.MembersOnly() {
 		isMember, _ := s.repo.IsMember(r.Context(), channelID, user.UserID)
 		if !isMember {
 			s.respondError(w, http.StatusForbidden, "access denied")
@@ -617,6 +762,18 @@ func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// after_seq backfills what a client missed since the last sequence
+	// number it saw, oldest first, including edits and deletes
+	var afterSeq *int64
+	if a := r.URL.Query().Get("after_seq"); a != "" {
+		parsed, err := strconv.ParseInt(a, 10, 64)
+		if err != nil || parsed < 0 {
+			s.respondError(w, http.StatusBadRequest, "invalid after_seq")
+			return
+		}
+		afterSeq = &parsed
+	}
+
 	var before *uuid.UUID
 	if b := r.URL.Query().Get("before"); b != "" {
 		if parsed, err := uuid.Parse(b); err == nil {
@@ -624,23 +781,51 @@ func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	messages, err := s.repo.ListMessages(r.Context(), channelID, limit, before)
+	var messages []models.Message
+	if afterSeq != nil {
+		messages, err = s.repo.ListMessagesAfterSeq(r.Context(), channelID, *afterSeq, limit)
+	} else {
+		messages, err = s.repo.ListMessages(r.Context(), channelID, limit, before)
+	}
 	if err != nil {
 		s.logger.Error("Failed to list messages", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to list messages")
 		return
 	}
 
-	// Load attachments and reactions
+	// Load attachments, and reaction summaries for the whole page at once
+	messageIDs := make([]uuid.UUID, len(messages))
 	for i := range messages {
+		messageIDs[i] = messages[i].ID
 		messages[i].Attachments, _ = s.repo.GetMessageAttachments(r.Context(), messages[i].ID)
-		messages[i].Reactions, _ = s.repo.GetMessageReactions(r.Context(), messages[i].ID)
+	}
+	summaries, err := s.repo.GetReactionSummaries(r.Context(), messageIDs, user.UserID)
+	if err != nil {
+		s.logger.Warn("Failed to load reaction summaries", zap.Error(err))
+	}
+	s.resolveCustomEmoji(r.Context(), user.OrganizationID, summaries)
+	mentions, err := s.repo.GetMentions(r.Context(), messageIDs)
+	if err != nil {
+		s.logger.Warn("Failed to load mentions", zap.Error(err))
+	}
+	for i := range messages {
+		messages[i].ReactionSummary = summaries[messages[i].ID]
+		messages[i].Mentions = mentions[messages[i].ID]
 	}
 
-	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+	resp := map[string]interface{}{
 		"messages": messages,
 		"has_more": len(messages) == limit,
-	})
+	}
+	if afterSeq != nil {
+		// The next page starts after the last sequence number returned
+		lastSeq := *afterSeq
+		if len(messages) > 0 {
+			lastSeq = messages[len(messages)-1].Seq
+		}
+		resp["last_seq"] = lastSeq
+	}
+	s.respondJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
@@ -689,10 +874,18 @@ func (s *Server) updateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Saving the same content again isn't an edit
+	if req.Content == message.Content {
+		s.respondJSON(w, http.StatusOK, message)
+		return
+	}
+
 	message.Content = req.Content
-	message.IsEdited = true
+	if message.ContentType == contentTypeMarkdown {
+		message.ContentHTML = renderMarkdown(req.Content)
+	}
 
-	if err := s.repo.UpdateMessage(r.Context(), message); err != nil {
+	if err := s.repo.EditMessage(r.Context(), message, user.UserID); err != nil {
 		s.logger.Error("Failed to update message", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to update message")
 		return
@@ -723,7 +916,8 @@ func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.repo.DeleteMessage(r.Context(), messageID); err != nil {
+	seq, err := s.repo.DeleteMessage(r.Context(), messageID)
+	if err != nil {
 		s.logger.Error("Failed to delete message", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to delete message")
 		return
@@ -735,50 +929,113 @@ func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request) {
 		ChannelID:   message.ChannelID,
 		IsDeleted:   true,
 		ContentType: "system",
+		Seq:         seq,
 	})
 
 	s.respondJSON(w, http.StatusNoContent, nil)
 }
 
 func (s *Server) pinMessage(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
 	messageID, err := uuid.Parse(chi.URLParam(r, "messageID"))
 	if err != nil {
 		s.respondError(w, http.StatusBadRequest, "invalid message id")
 		return
 	}
 
-	if err := s.repo.PinMessage(r.Context(), messageID, true); err != nil {
+	message, err := s.repo.GetMessage(r.Context(), messageID)
+	if err != nil || message.IsDeleted {
+		s.respondError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	if !s.checkCanManagePins(w, r, message.ChannelID, user.UserID) {
+		return
+	}
+
+	if message.IsPinned {
+		s.respondJSON(w, http.StatusOK, map[string]string{"status": "pinned"})
+		return
+	}
+
+	maxPins := s.cfg.Limits.MaxPinnedMessages
+	err = s.repo.PinMessage(r.Context(), message.ChannelID, messageID, user.UserID, maxPins)
+	if errors.Is(err, repository.ErrPinLimitReached) {
+		s.respondError(w, http.StatusConflict, fmt.Sprintf("channel already has the maximum of %d pinned messages", maxPins))
+		return
+	}
+	if err != nil {
 		s.logger.Error("Failed to pin message", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to pin message")
 		return
 	}
 
+	s.hub.BroadcastPinsUpdate(message.ChannelID, &hub.PinsUpdate{
+		Action:    hub.PinActionPin,
+		UserID:    user.UserID,
+		MessageID: &messageID,
+	})
+
 	s.respondJSON(w, http.StatusOK, map[string]string{"status": "pinned"})
 }
 
 func (s *Server) unpinMessage(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
 	messageID, err := uuid.Parse(chi.URLParam(r, "messageID"))
 	if err != nil {
 		s.respondError(w, http.StatusBadRequest, "invalid message id")
 		return
 	}
 
-	if err := s.repo.PinMessage(r.Context(), messageID, false); err != nil {
+	message, err := s.repo.GetMessage(r.Context(), messageID)
+	if err != nil {
+		s.respondError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	if !s.checkCanManagePins(w, r, message.ChannelID, user.UserID) {
+		return
+	}
+
+	if !message.IsPinned {
+		s.respondJSON(w, http.StatusOK, map[string]string{"status": "unpinned"})
+		return
+	}
+
+	if err := s.repo.UnpinMessage(r.Context(), messageID); err != nil {
 		s.logger.Error("Failed to unpin message", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to unpin message")
 		return
 	}
 
+	s.hub.BroadcastPinsUpdate(message.ChannelID, &hub.PinsUpdate{
+		Action:    hub.PinActionUnpin,
+		UserID:    user.UserID,
+		MessageID: &messageID,
+	})
+
 	s.respondJSON(w, http.StatusOK, map[string]string{"status": "unpinned"})
 }
 
+// getPinnedMessages lists a channel's pinned messages in pin order
 func (s *Server) getPinnedMessages(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
 	channelID, err := uuid.Parse(chi.URLParam(r, "channelID"))
 	if err != nil {
 		s.respondError(w, http.StatusBadRequest, "invalid channel id")
 		return
 	}
 
+	channel, err := s.repo.GetChannel(r.Context(), channelID)
+	if err != nil {
+		s.respondError(w, http.StatusNotFound, "channel not found")
+		return
+	}
+	if !s.canReadChannel(r.Context(), channel, user.UserID) {
+		s.respondError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
 	messages, err := s.repo.GetPinnedMessages(r.Context(), channelID)
 	if err != nil {
 		s.logger.Error("Failed to get pinned messages", zap.Error(err))
@@ -792,6 +1049,92 @@ func (s *Server) getPinnedMessages(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// reorderPins sets the order of a channel's pinned messages. The request
+// lists every pinned message of the channel once, in the new order.
+func (s *Server) reorderPins(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelID"))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid channel id")
+		return
+	}
+
+	var req struct {
+		MessageIDs []uuid.UUID `json:"message_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool, len(req.MessageIDs))
+	for _, id := range req.MessageIDs {
+		if seen[id] {
+			s.respondError(w, http.StatusBadRequest, "message_ids must not contain duplicates")
+			return
+		}
+		seen[id] = true
+	}
+
+	if !s.checkCanManagePins(w, r, channelID, user.UserID) {
+		return
+	}
+
+	err = s.repo.ReorderPins(r.Context(), channelID, req.MessageIDs)
+	if errors.Is(err, repository.ErrPinsChanged) {
+		s.respondError(w, http.StatusConflict, "message_ids must list every pinned message of the channel")
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to reorder pins", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to reorder pins")
+		return
+	}
+
+	s.hub.BroadcastPinsUpdate(channelID, &hub.PinsUpdate{
+		Action:     hub.PinActionReorder,
+		UserID:     user.UserID,
+		MessageIDs: req.MessageIDs,
+	})
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{"message_ids": req.MessageIDs})
+}
+
+// checkCanManagePins checks that the user may pin, unpin and reorder
+// messages in a channel, responding with an error if not. In private
+// channels only the channel owner and admins manage pins, and in direct
+// messages both participants.
+func (s *Server) checkCanManagePins(w http.ResponseWriter, r *http.Request, channelID, userID uuid.UUID) bool {
+	channel, err := s.repo.GetChannel(r.Context(), channelID)
+	if err != nil {
+		s.respondError(w, http.StatusNotFound, "channel not found")
+		return false
+	}
+	if channel.Type == models.ChannelTypeDirect {
+		// Both participants manage the pins of a direct message
+		isMember, _ := s.repo.IsMember(r.Context(), channelID, userID)
+		if !isMember {
+			s.respondError(w, http.StatusForbidden, "access denied")
+		}
+		return isMember
+	}
+	if channel.Type != models.ChannelTypePrivate || channel.CreatedBy == userID {
+		return true
+	}
+
+	role, err := s.repo.GetMemberRole(r.Context(), channelID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error("Failed to get member role", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to check permissions")
+		return false
+	}
+	if role != "owner" && role != "admin" {
+		s.respondError(w, http.StatusForbidden, "only channel owners and admins can manage pins in private channels")
+		return false
+	}
+	return true
+}
+
 // ============================================================================
 // Thread Handlers
 // ============================================================================
@@ -864,6 +1207,8 @@ func (s *Server) replyToThread(w http.ResponseWriter, r *http.Request) {
 	userInfo, _ := s.repo.GetUser(r.Context(), user.UserID)
 	message.User = userInfo
 
+	s.processMentions(r.Context(), user, message)
+
 	// Broadcast to channel
 	s.hub.BroadcastMessage(parent.ChannelID, message)
 
@@ -890,6 +1235,22 @@ func (s *Server) addReaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.validateReactionEmoji(r.Context(), user.OrganizationID, req.Emoji); err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			s.respondError(w, http.StatusBadRequest, validationErr.Message)
+			return
+		}
+		s.logger.Error("Failed to look up custom emoji", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to add reaction")
+		return
+	}
+
+	message, ok := s.checkReactionAccess(w, r, messageID, user.UserID)
+	if !ok {
+		return
+	}
+
 	reaction := &models.Reaction{
 		MessageID: messageID,
 		UserID:    user.UserID,
@@ -902,21 +1263,7 @@ func (s *Server) addReaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get message for broadcast
-	message, _ := s.repo.GetMessage(r.Context(), messageID)
-	if message != nil {
-		s.hub.BroadcastMessage(message.ChannelID, &models.Message{
-			ID:        messageID,
-			ChannelID: message.ChannelID,
-			Metadata: models.JSONMap{
-				"reaction": map[string]interface{}{
-					"emoji":   req.Emoji,
-					"user_id": user.UserID,
-					"action":  "add",
-				},
-			},
-		})
-	}
+	s.broadcastReaction(message, user.UserID, req.Emoji, "add")
 
 	s.respondJSON(w, http.StatusOK, reaction)
 }
@@ -931,15 +1278,187 @@ func (s *Server) removeReaction(w http.ResponseWriter, r *http.Request) {
 
 	emoji := chi.URLParam(r, "emoji")
 
+	message, ok := s.checkReactionAccess(w, r, messageID, user.UserID)
+	if !ok {
+		return
+	}
+
 	if err := s.repo.RemoveReaction(r.Context(), messageID, user.UserID, emoji); err != nil {
 		s.logger.Error("Failed to remove reaction", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "failed to remove reaction")
 		return
 	}
 
+	s.broadcastReaction(message, user.UserID, emoji, "remove")
+
 	s.respondJSON(w, http.StatusNoContent, nil)
 }
 
+// checkReactionAccess loads a message for a reaction change and checks the
+// user can read its channel, responding with an error if not. Reactions work
+// the same in every channel type, direct messages included.
+func (s *Server) checkReactionAccess(w http.ResponseWriter, r *http.Request, messageID, userID uuid.UUID) (*models.Message, bool) {
+	message, err := s.repo.GetMessage(r.Context(), messageID)
+	if err != nil || message.IsDeleted {
+		s.respondError(w, http.StatusNotFound, "message not found")
+		return nil, false
+	}
+
+	channel, err := s.repo.GetChannel(r.Context(), message.ChannelID)
+	if err != nil {
+		s.respondError(w, http.StatusNotFound, "channel not found")
+		return nil, false
+	}
+	if !s.canReadChannel(r.Context(), channel, userID) {
+		s.respondError(w, http.StatusForbidden, "access denied")
+		return nil, false
+	}
+	return message, true
+}
+
+// broadcastReaction tells a message's channel that a reaction was added or
+// removed
+func (s *Server) broadcastReaction(message *models.Message, userID uuid.UUID, emoji, action string) {
+	s.hub.BroadcastMessage(message.ChannelID, &models.Message{
+		ID:        message.ID,
+		ChannelID: message.ChannelID,
+		Metadata: models.JSONMap{
+			"reaction": map[string]interface{}{
+				"emoji":   emoji,
+				"user_id": userID,
+				"action":  action,
+			},
+		},
+	})
+}
+
+// canReadChannel reports whether a user may read a channel: anyone for a
+// public channel, only members for private channels and direct messages
+func (s *Server) canReadChannel(ctx context.Context, channel *models.Channel, userID uuid.UUID) bool {
+	if !channel.Type.MembersOnly() {
+		return true
+	}
+	isMember, _ := s.repo.IsMember(ctx, channel.ID, userID)
+	return isMember
+}
+
+// getMessageHistory returns every version of a channel message's content,
+// oldest first. The history stays available after the message is deleted.
+func (s *Server) getMessageHistory(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelID"))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid channel id")
+		return
+	}
+	messageID, err := uuid.Parse(chi.URLParam(r, "messageID"))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+
+	channel, err := s.repo.GetChannel(r.Context(), channelID)
+	if err != nil {
+		s.respondError(w, http.StatusNotFound, "channel not found")
+		return
+	}
+
+	if channel.Type.MembersOnly() {
+		isMember, _ := s.repo.IsMember(r.Context(), channelID, user.UserID)
+		if !isMember {
+			s.respondError(w, http.StatusForbidden, "access denied")
+			return
+		}
+	}
+
+	message, err := s.repo.GetMessage(r.Context(), messageID)
+	if err != nil || message.ChannelID != channelID {
+		s.respondError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	versions, err := s.repo.GetMessageHistory(r.Context(), message)
+	if err != nil {
+		s.logger.Error("Failed to get message history", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to get message history")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message_id": message.ID,
+		"is_deleted": message.IsDeleted,
+		"edit_count": message.EditCount,
+		"versions":   versions,
+	})
+}
+
+// listReactionUsers lists the users who reacted to a channel message with
+// one emoji. Results are paged with limit and offset.
+func (s *Server) listReactionUsers(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelID"))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid channel id")
+		return
+	}
+	messageID, err := uuid.Parse(chi.URLParam(r, "messageID"))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+	emoji, err := url.PathUnescape(chi.URLParam(r, "emoji"))
+	if err != nil || emoji == "" {
+		s.respondError(w, http.StatusBadRequest, "invalid emoji")
+		return
+	}
+
+	channel, err := s.repo.GetChannel(r.Context(), channelID)
+	if err != nil {
+		s.respondError(w, http.StatusNotFound, "channel not found")
+		return
+	}
+
+	if channel.Type.MembersOnly() {
+		isMember, _ := s.repo.IsMember(r.Context(), channelID, user.UserID)
+		if !isMember {
+			s.respondError(w, http.StatusForbidden, "access denied")
+			return
+		}
+	}
+
+	message, err := s.repo.GetMessage(r.Context(), messageID)
+	if err != nil || message.ChannelID != channelID {
+		s.respondError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	limit := defaultReactionUsers
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= maxReactionUsers {
+			limit = parsed
+		}
+	}
+	offset := 0
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	users, err := s.repo.ListReactionUsers(r.Context(), messageID, emoji, limit, offset)
+	if err != nil {
+		s.logger.Error("Failed to list reaction users", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to list reaction users")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"emoji":    emoji,
+		"users":    users,
+		"has_more": len(users) == limit,
+	})
+}
+
 // ============================================================================
 // Direct Message Handlers
 // ============================================================================
@@ -954,7 +1473,12 @@ func (s *Server) createDirectMessage(w http.ResponseWriter, r *http.Request) {
 		s.respondError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	if req.UserID == uuid.Nil {
+		s.respondError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
 
+	// user_id may be the user's own, for a direct message to self
 	channel, err := s.repo.GetOrCreateDirectChannel(r.Context(), user.OrganizationID, []uuid.UUID{user.UserID, req.UserID})
 	if err != nil {
 		s.logger.Error("Failed to create DM channel", zap.Error(err))
@@ -962,9 +1486,60 @@ func (s *Server) createDirectMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if partner, err := s.repo.GetUser(r.Context(), req.UserID); err == nil {
+		s.applyPresence(partner)
+		channel.DirectUser = partner
+	}
+
 	s.respondJSON(w, http.StatusOK, channel)
 }
 
+// listDirectConversations lists the user's direct messages, most recent
+// message first, each with its unread count and the other participant's
+// presence
+func (s *Server) listDirectConversations(w http.ResponseWriter, r *http.Request) {
+	user := s.getUserFromContext(r)
+
+	channels, err := s.repo.ListDirectChannels(r.Context(), user.OrganizationID, user.UserID)
+	if err != nil {
+		s.logger.Error("Failed to list direct messages", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to list direct messages")
+		return
+	}
+	s.applyDirectPartners(r.Context(), user.UserID, channels)
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"conversations": channels,
+		"total":         len(channels),
+	})
+}
+
+// applyDirectPartners sets DirectUser, with presence, on the direct messages
+// among channels. Failures are logged; the channels are still returned.
+func (s *Server) applyDirectPartners(ctx context.Context, userID uuid.UUID, channels []models.Channel) {
+	var channelIDs []uuid.UUID
+	for _, c := range channels {
+		if c.Type == models.ChannelTypeDirect {
+			channelIDs = append(channelIDs, c.ID)
+		}
+	}
+	if len(channelIDs) == 0 {
+		return
+	}
+
+	partners, err := s.repo.GetDirectPartners(ctx, userID, channelIDs)
+	if err != nil {
+		s.logger.Warn("Failed to load direct message participants", zap.Error(err))
+		return
+	}
+	for i := range channels {
+		if partner, ok := partners[channels[i].ID]; ok {
+			s.applyPresence(&partner)
+			channels[i].DirectUser = &partner
+		}
+	}
+}
+
 func (s *Server) getDirectMessages(w http.ResponseWriter, r *http.Request) {
 	user := s.getUserFromContext(r)
 	otherUserID, err := uuid.Parse(chi.URLParam(r, "userID"))
@@ -1008,9 +1583,7 @@ func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
 
 	// Add online status
 	for i := range users {
-		if s.hub.IsUserOnline(users[i].ID) {
-			users[i].Status = "online"
-		}
+		s.applyPresence(&users[i])
 	}
 
 	s.respondJSON(w, http.StatusOK, map[string]interface{}{
@@ -1032,23 +1605,41 @@ func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.hub.IsUserOnline(userID) {
-		userInfo.Status = "online"
-	}
+	s.applyPresence(userInfo)
 
 	s.respondJSON(w, http.StatusOK, userInfo)
 }
 
+// applyPresence marks a user online if they have a live connection. Only
+// offline users carry last_seen_at; for connected users it would be stale.
+func (s *Server) applyPresence(u *models.User) {
+	if s.hub.IsUserOnline(u.ID) {
+		u.Status = "online"
+		u.LastSeenAt = nil
+	}
+}
+
 func (s *Server) getPresence(w http.ResponseWriter, r *http.Request) {
 	user := s.getUserFromContext(r)
 
-	onlineUsers := s.hub.GetOnlineUsers(user.OrganizationID)
+	users, err := s.repo.GetOrganizationUsers(r.Context(), user.OrganizationID)
+	if err != nil {
+		s.logger.Error("Failed to get presence", zap.Error(err))
+		s.respondError(w, http.StatusInternalServerError, "failed to get presence")
+		return
+	}
 
-	presences := make([]models.Presence, len(onlineUsers))
-	for i, uid := range onlineUsers {
+	presences := make([]models.Presence, len(users))
+	for i, u := range users {
 		presences[i] = models.Presence{
-			UserID: uid,
-			Status: "online",
+			UserID:     u.ID,
+			Status:     "offline",
+			StatusText: u.StatusText,
+			LastSeenAt: u.LastSeenAt,
+		}
+		if s.hub.IsUserOnline(u.ID) {
+			presences[i].Status = "online"
+			presences[i].LastSeenAt = nil
 		}
 	}
 
@@ -1110,7 +1701,29 @@ func (s *Server) search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	messages, err := s.repo.SearchMessages(r.Context(), user.OrganizationID, user.UserID, validatedQuery, 50)
+	filter := models.MessageSearchFilter{Limit: maxSearchResults}
+	if channelID := r.URL.Query().Get("channel_id"); channelID != "" {
+		id, err := uuid.Parse(channelID)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, "invalid channel_id")
+			return
+		}
+		filter.ChannelID = &id
+	}
+	if filter.From, err = parseSearchTime("from", r.URL.Query().Get("from"), false); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.To, err = parseSearchTime("to", r.URL.Query().Get("to"), true); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		s.respondError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	messages, err := s.repo.SearchMessages(r.Context(), user.OrganizationID, user.UserID, validatedQuery, filter)
 	if err != nil {
 		s.logger.Error("Failed to search", zap.Error(err))
 		s.respondError(w, http.StatusInternalServerError, "search failed")
@@ -1146,8 +1759,8 @@ var allowedContentTypes = map[string]bool{
 func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request) {
 	user := s.getUserFromContext(r)
 
-	// Parse multipart form
-	if err := r.ParseMultipartForm(s.cfg.Limits.MaxFileSize); err != nil {
+	// Parse multipart form; large files spill to disk rather than memory
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
 		s.respondError(w, http.StatusBadRequest, "file too large")
 		return
 	}
@@ -1179,6 +1792,11 @@ func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Scan for malware before the file is stored
+	if s.fileScanner != nil && !s.scanUpload(w, r, user, file, sanitizedFilename, header.Size, contentType) {
+		return
+	}
+
 	// Generate unique file ID
 	fileID := uuid.New()
 
@@ -1204,6 +1822,62 @@ func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request) {
 	s.respondJSON(w, http.StatusOK, attachment)
 }
 
+// uploadMemory is how much of an upload is held in memory
+const uploadMemory = 10 << 20
+
+// scanUpload streams an upload through the malware scanner. Infected files
+// are rejected with 422 and recorded; when the scanner fails closed the
+// upload is rejected with 503. It reports whether the upload may be stored,
+// with file rewound for storage.
+func (s *Server) scanUpload(w http.ResponseWriter, r *http.Request, user *UserClaims, file multipart.File, filename string, size int64, contentType string) bool {
+	result, err := s.fileScanner.Scan(r.Context(), file)
+	if err != nil {
+		s.logger.Error("File scan failed", zap.String("filename", filename), zap.Error(err))
+		if errors.Is(err, scanner.ErrUnavailable) {
+			s.respondError(w, http.StatusServiceUnavailable, "file scanning unavailable, try again later")
+		} else {
+			s.respondError(w, http.StatusInternalServerError, "file scan failed")
+		}
+		return false
+	}
+
+	if result.Unscanned {
+		s.logger.Warn("File stored unscanned",
+			zap.String("user_id", user.UserID.String()),
+			zap.String("filename", filename),
+			zap.Error(result.Err),
+		)
+	}
+
+	if result.Infected {
+		detection := &models.FileDetection{
+			OrganizationID: user.OrganizationID,
+			UserID:         user.UserID,
+			FileName:       filename,
+			FileSize:       size,
+			ContentType:    contentType,
+			Scanner:        s.fileScanner.Name(),
+			Signature:      result.Signature,
+		}
+		if err := s.repo.RecordFileDetection(r.Context(), detection); err != nil {
+			s.logger.Error("Failed to record file detection", zap.Error(err))
+		}
+		s.logger.Warn("Malware detected in upload",
+			zap.String("user_id", user.UserID.String()),
+			zap.String("filename", filename),
+			zap.String("signature", result.Signature),
+		)
+		s.respondError(w, http.StatusUnprocessableEntity, "file rejected: malware detected ("+result.Signature+")")
+		return false
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		s.respondError(w, http.StatusInternalServerError, "failed to read file")
+		return false
+	}
+	return true
+}
+
 // ============================================================================
 // WebSocket Handler
 // ============================================================================
@@ -1217,9 +1891,9 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate token
-	claims, err := s.validateToken(token)
+	claims, err := s.tokens.Verify(token)
 	if err != nil {
-		s.respondError(w, http.StatusUnauthorized, "invalid token")
+		s.respondTokenError(w, err)
 		return
 	}
 
@@ -1235,6 +1909,7 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	// Create client
 	client := hub.NewClient(s.hub, conn, claims.UserID, claims.OrganizationID)
+	client.ExpiresAt = claims.ExpiresAt
 
 	// Register with hub
 	s.hub.Register(client)
@@ -1243,25 +1918,3 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	go client.WritePump(s.logger)
 	go client.ReadPump(s.logger)
 }
-
-func (s *Server) validateToken(tokenString string) (*UserClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.cfg.Auth.JWTSecret), nil
-	})
-	if err != nil || !token.Valid {
-		return nil, err
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, err
-	}
-	userID, _ := uuid.Parse(claims["user_id"].(string))
-	orgID, _ := uuid.Parse(claims["organization_id"].(string))
-
-	return &UserClaims{
-		UserID:         userID,
-		OrganizationID: orgID,
-		Email:          claims["email"].(string),
-	}, nil
-}
@@ -0,0 +1,428 @@
+package api
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// Markdown messages keep their source in content and carry a rendering in
+// content_html. The renderer escapes all source text, so HTML typed into a
+// message is shown rather than interpreted, and its output is then passed
+// through an allowlist sanitizer as a second line of defense.
+
+const contentTypeMarkdown = "markdown"
+
+// linkRel is set on every rendered link so shared links can't reach back
+// into the client or pass on ranking
+const linkRel = "nofollow noopener noreferrer"
+
+var (
+	headingRegex     = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	bulletItemRegex  = regexp.MustCompile(`^[ ]{0,3}[-*+][ \t]+(.*)$`)
+	orderedItemRegex = regexp.MustCompile(`^[ ]{0,3}(\d{1,9})[.)][ \t]+(.*)$`)
+	fenceRegex       = regexp.MustCompile("^[ ]{0,3}(```+|~~~+)[ \t]*([^`\\s]*)")
+	codeLanguage     = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+	autolinkRegex    = regexp.MustCompile(`^(?:https?://|mailto:)[^\s<>"]+`)
+)
+
+// renderMarkdown converts a markdown message to sanitized HTML. Supported
+// are paragraphs (single newlines become line breaks), ATX headings, block
+// quotes, bullet and numbered lists, fenced code blocks with a language
+// hint, inline code, emphasis, strikethrough, links and bare URLs.
+func renderMarkdown(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+	return sanitizeHTML(b.String())
+}
+
+// renderBlocks renders lines as a sequence of block elements
+func renderBlocks(b *strings.Builder, lines []string) {
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		b.WriteString("<p>")
+		for i, line := range paragraph {
+			if i > 0 {
+				b.WriteString("<br>")
+			}
+			b.WriteString(renderInline(strings.TrimSpace(line)))
+		}
+		b.WriteString("</p>")
+		paragraph = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			flush()
+			i = renderFence(b, lines, i, m[1], m[2])
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+
+		case headingRegex.MatchString(trimmed):
+			flush()
+			m := headingRegex.FindStringSubmatch(trimmed)
+			tag := "h" + strconv.Itoa(len(m[1]))
+			b.WriteString("<" + tag + ">" + renderInline(m[2]) + "</" + tag + ">")
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quoted []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(t, ">") {
+					break
+				}
+				t = strings.TrimPrefix(t, ">")
+				quoted = append(quoted, strings.TrimPrefix(t, " "))
+			}
+			i--
+			b.WriteString("<blockquote>")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>")
+
+		case bulletItemRegex.MatchString(line):
+			flush()
+			i = renderList(b, lines, i, bulletItemRegex, "ul")
+
+		case orderedItemRegex.MatchString(line):
+			flush()
+			i = renderList(b, lines, i, orderedItemRegex, "ol")
+
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+}
+
+// renderFence renders the fenced code block opening at lines[start] and
+// returns the index of its closing fence. An unclosed fence runs to the end
+// of the message.
+func renderFence(b *strings.Builder, lines []string, start int, fence, info string) int {
+	b.WriteString("<pre><code")
+	if codeLanguage.MatchString(info) {
+		b.WriteString(` class="language-` + strings.ToLower(info) + `"`)
+	}
+	b.WriteString(">")
+
+	i := start + 1
+	for ; i < len(lines); i++ {
+		t := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+			break
+		}
+		b.WriteString(html.EscapeString(lines[i]))
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>")
+	return i
+}
+
+// renderList renders the list starting at lines[start] and returns the
+// index of its last line. Indented lines continue the previous item.
+func renderList(b *strings.Builder, lines []string, start int, item *regexp.Regexp, tag string) int {
+	var items []string
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := item.FindStringSubmatch(line); m != nil {
+			items = append(items, m[len(m)-1])
+			if tag == "ol" && len(items) == 1 {
+				if n, _ := strconv.Atoi(m[1]); n > 1 {
+					tag = `ol start="` + strconv.Itoa(n) + `"`
+				}
+			}
+			continue
+		}
+		if strings.TrimSpace(line) != "" && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			items[len(items)-1] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		break
+	}
+
+	b.WriteString("<" + tag + ">")
+	for _, it := range items {
+		b.WriteString("<li>")
+		for j, part := range strings.Split(it, "\n") {
+			if j > 0 {
+				b.WriteString("<br>")
+			}
+			b.WriteString(renderInline(part))
+		}
+		b.WriteString("</li>")
+	}
+	b.WriteString("</" + tag[:2] + ">")
+	return i - 1
+}
+
+// renderInline renders the inline markup of one line of text
+func renderInline(s string) string {
+	return inline(s, true)
+}
+
+// inline renders s, escaping everything that isn't markup. Links are not
+// rendered inside link text.
+func inline(s string, links bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_~[]()<>#+-.!|", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			run := countRun(s[i:], '`')
+			if end := strings.Index(s[i+run:], s[i:i+run]); end >= 0 {
+				code := strings.TrimSpace(s[i+run : i+run+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += run + end + run
+				continue
+			}
+			b.WriteString(s[i : i+run])
+			i += run
+			continue
+
+		case c == '*' || c == '_' || c == '~':
+			if n, out := emphasis(s, i, links); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+
+		case c == '[' && links:
+			if n, out := link(s, i); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+
+		case c == '<' && links:
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				if target := s[i+1 : i+end]; autolinkRegex.MatchString(target) && len(autolinkRegex.FindString(target)) == len(target) {
+					b.WriteString(anchor(target, html.EscapeString(target)))
+					i += end + 1
+					continue
+				}
+			}
+
+		case (c == 'h' || c == 'm') && links && (i == 0 || !isWordByte(s[i-1])):
+			if target := trimURL(autolinkRegex.FindString(s[i:])); target != "" && target != "mailto:" {
+				b.WriteString(anchor(target, html.EscapeString(target)))
+				i += len(target)
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// emphasis renders the emphasis opening at s[i]: ** or __ as strong, * or
+// _ as em and ~~ as del. It returns the number of bytes consumed, zero if
+// the delimiter isn't closed. Underscores inside words are left alone, so
+// snake_case identifiers survive.
+func emphasis(s string, i int, links bool) (int, string) {
+	c := s[i]
+	run := countRun(s[i:], c)
+	var delim, tag string
+	switch {
+	case c == '~' && run >= 2:
+		delim, tag = "~~", "del"
+	case c == '~':
+		return 0, ""
+	case run >= 2:
+		delim, tag = s[i:i+2], "strong"
+	default:
+		delim, tag = s[i:i+1], "em"
+	}
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0, ""
+	}
+
+	body := i + len(delim)
+	if body >= len(s) || s[body] == ' ' || s[body] == '\t' {
+		return 0, ""
+	}
+	for j := body + 1; j+len(delim) <= len(s); j++ {
+		if s[j:j+len(delim)] != delim || s[j-1] == ' ' || s[j-1] == '\t' {
+			continue
+		}
+		// A single delimiter doesn't close on half of a double one
+		if len(delim) == 1 && j+1 < len(s) && s[j+1] == c {
+			j++
+			continue
+		}
+		if c == '_' && j+1 < len(s) && isWordByte(s[j+1]) {
+			continue
+		}
+		return j + len(delim) - i, "<" + tag + ">" + inline(s[body:j], links) + "</" + tag + ">"
+	}
+	return 0, ""
+}
+
+// link renders a [text](url) link opening at s[i]. Links to anything but
+// http, https and mailto URLs are left as text.
+func link(s string, i int) (int, string) {
+	closeText := strings.Index(s[i:], "](")
+	if closeText < 0 {
+		return 0, ""
+	}
+	closeURL := strings.IndexByte(s[i+closeText:], ')')
+	if closeURL < 0 {
+		return 0, ""
+	}
+	text := s[i+1 : i+closeText]
+	target := strings.TrimSpace(s[i+closeText+2 : i+closeText+closeURL])
+	if text == "" || !safeURL(target) {
+		return 0, ""
+	}
+	return closeText + closeURL + 1, anchor(target, inline(text, false))
+}
+
+func anchor(target, text string) string {
+	return `<a href="` + html.EscapeString(target) + `" rel="` + linkRel + `" target="_blank">` + text + "</a>"
+}
+
+// trimURL drops trailing punctuation that ends the sentence around a bare
+// URL rather than the URL itself, and a closing parenthesis without an
+// opening one in the URL
+func trimURL(u string) string {
+	for u != "" {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,:;!?'\"*_~", last) >= 0:
+			u = u[:len(u)-1]
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+			u = u[:len(u)-1]
+		default:
+			return u
+		}
+	}
+	return u
+}
+
+// safeURL reports whether a link target may be rendered: an absolute http,
+// https or mailto URL
+func safeURL(target string) bool {
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	}
+	return false
+}
+
+func countRun(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// allowedTags lists the elements rendered markdown may contain, with the
+// attributes each may carry
+var allowedTags = map[string]map[string]bool{
+	"p": nil, "br": nil, "strong": nil, "em": nil, "del": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"blockquote": nil, "ul": nil, "li": nil, "pre": nil,
+	"ol":   {"start": true},
+	"code": {"class": true},
+	"a":    {"href": true, "rel": true, "target": true},
+}
+
+// sanitizeHTML keeps only allowlisted elements and attributes. Disallowed
+// elements are dropped with their text kept, except script and style whose
+// content is dropped too.
+func sanitizeHTML(s string) string {
+	var b strings.Builder
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			return b.String()
+		}
+		tok := z.Token()
+
+		switch tt {
+		case xhtml.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken, xhtml.EndTagToken:
+			if tok.Data == "script" || tok.Data == "style" {
+				if tt == xhtml.StartTagToken {
+					skip++
+				} else if tt == xhtml.EndTagToken && skip > 0 {
+					skip--
+				}
+				continue
+			}
+			attrs, ok := allowedTags[tok.Data]
+			if !ok || skip > 0 {
+				continue
+			}
+			if tt == xhtml.EndTagToken {
+				b.WriteString("</" + tok.Data + ">")
+				continue
+			}
+			b.WriteString("<" + tok.Data)
+			for _, a := range tok.Attr {
+				if !attrs[a.Key] || !allowedAttr(a) {
+					continue
+				}
+				b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+			}
+			b.WriteString(">")
+		}
+	}
+}
+
+// allowedAttr checks the value of an allowlisted attribute
+func allowedAttr(a xhtml.Attribute) bool {
+	switch a.Key {
+	case "href":
+		return safeURL(a.Val)
+	case "class":
+		return strings.HasPrefix(a.Val, "language-") && codeLanguage.MatchString(strings.TrimPrefix(a.Val, "language-"))
+	case "rel":
+		return a.Val == linkRel
+	case "target":
+		return a.Val == "_blank"
+	case "start":
+		_, err := strconv.Atoi(a.Val)
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"paragraphs", "hello\nworld\n\nbye", "<p>hello<br>world</p><p>bye</p>"},
+		{"emphasis", "**bold** *it* _it_ ~~gone~~", "<p><strong>bold</strong> <em>it</em> <em>it</em> <del>gone</del></p>"},
+		{"snake case", "call my_func_name now", "<p>call my_func_name now</p>"},
+		{"inline code", "run `a < b` *now*", "<p>run <code>a &lt; b</code> <em>now</em></p>"},
+		{"heading", "## Release notes", "<h2>Release notes</h2>"},
+		{"quote", "> quoted\n> more\n\nreply", "<blockquote><p>quoted<br>more</p></blockquote><p>reply</p>"},
+		{"bullets", "- one\n- two", "<ul><li>one</li><li>two</li></ul>"},
+		{"numbered", "3. three\n4. four", `<ol start="3"><li>three</li><li>four</li></ol>`},
+		{"fence", "```go\nif a < b {\n}\n```", `<pre><code class="language-go">if a &lt; b {` + "\n}\n</code></pre>"},
+		{"fence without language", "~~~\n*raw*\n~~~", "<pre><code>*raw*\n</code></pre>"},
+		{"unsafe language", "```\"><script>\nx\n```", "<pre><code>x\n</code></pre>"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)",
+			`<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer" target="_blank">docs</a></p>`},
+		{"autolink", "see https://example.com/x_(y).",
+			`<p>see <a href="https://example.com/x_(y)" rel="nofollow noopener noreferrer" target="_blank">https://example.com/x_(y)</a>.</p>`},
+		{"autolink in parens", "(https://example.com)",
+			`<p>(<a href="https://example.com" rel="nofollow noopener noreferrer" target="_blank">https://example.com</a>)</p>`},
+		{"escaped", `\*not em\*`, "<p>*not em*</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdown(tt.src); got != tt.want {
+				t.Errorf("renderMarkdown(%q)\n got %s\nwant %s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownEscapesHTML(t *testing.T) {
+	tests := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[click](javascript:alert(1))`,
+		`[click](https://example.com" onclick="alert(1))`,
+		`**<b>bold</b>**`,
+		"> <iframe src=https://evil.example>",
+		"- <a href=\"javascript:x\">x</a>",
+		`<javascript:alert(1)>`,
+	}
+
+	for _, src := range tests {
+		got := renderMarkdown(src)
+		for _, bad := range []string{"<script", "<img", "<iframe", "<b>", `href="javascript`, `" onclick`} {
+			if strings.Contains(got, bad) {
+				t.Errorf("renderMarkdown(%q) = %s, contains %s", src, got, bad)
+			}
+		}
+	}
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`<p onclick="x">hi</p>`, "<p>hi</p>"},
+		{`<script>alert(1)</script>ok`, "ok"},
+		{`<div><em>kept</em></div>`, "<em>kept</em>"},
+		{`<a href="javascript:alert(1)" rel="nofollow noopener noreferrer">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{`<code class="language-go x">y</code>`, "<code>y</code>"},
+		{`a &lt;b&gt;`, "a &lt;b&gt;"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeHTML(tt.in); got != tt.want {
+			t.Errorf("sanitizeHTML(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"chat/internal/hub"
+	"chat/internal/models"
+)
+
+const (
+	maxMentionsPerMessage     = 50
+	notificationSnippetLength = 200
+
+	mentionChannel = "channel"
+	mentionHere    = "here"
+
+	// Notification types, as allowed by chat_notifications.type
+	notificationTypeMention = "mention"
+	notificationTypeDM      = "dm"
+	notificationTypeChannel = "channel"
+)
+
+// mentionRegex matches @name where the @ doesn't continue a word or an email
+// address. Usernames are the local part of a user's email address.
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9][A-Za-z0-9._+-]*)`)
+
+// parseMentions returns the distinct lowercased usernames mentioned in
+// content, in order of appearance, and whether it mentions @channel or @here
+func parseMentions(content string) (usernames []string, channel, here bool) {
+	seen := make(map[string]bool)
+	for _, match := range mentionRegex.FindAllStringSubmatch(content, -1) {
+		name := strings.ToLower(strings.TrimRight(match[1], "."))
+		switch {
+		case name == mentionChannel:
+			channel = true
+		case name == mentionHere:
+			here = true
+		case name == "" || seen[name] || len(usernames) >= maxMentionsPerMessage:
+		default:
+			seen[name] = true
+			usernames = append(usernames, name)
+		}
+	}
+	return usernames, channel, here
+}
+
+// notificationRecipients decides who is notified of a message in a channel,
+// and with which notification type. levels holds the channel's members.
+//
+// Mentioned users are notified if they can read the channel: members, or
+// anyone in the organization for a public channel. @channel notifies every
+// member and @here every online member. Other messages notify all members of
+// a direct channel, and members who chose to hear about every message
+// elsewhere. Muted members and the sender are never notified.
+func notificationRecipients(channelType models.ChannelType, senderID uuid.UUID, levels map[uuid.UUID]models.NotificationLevel, mentioned []uuid.UUID, channelMention, hereMention bool, online func(uuid.UUID) bool) map[uuid.UUID]string {
+	recipients := make(map[uuid.UUID]string)
+
+	for _, userID := range mentioned {
+		level, isMember := levels[userID]
+		if userID == senderID || level == models.NotificationLevelMuted {
+			continue
+		}
+		if !isMember && channelType != models.ChannelTypePublic {
+			continue
+		}
+		recipients[userID] = notificationTypeMention
+	}
+
+	for userID, level := range levels {
+		if userID == senderID || level == models.NotificationLevelMuted || recipients[userID] != "" {
+			continue
+		}
+		switch {
+		case channelMention, hereMention && online(userID):
+			recipients[userID] = notificationTypeMention
+		case channelType == models.ChannelTypeDirect:
+			recipients[userID] = notificationTypeDM
+		case level == models.NotificationLevelAll:
+			recipients[userID] = notificationTypeChannel
+		}
+	}
+
+	return recipients
+}
+
+// processMentions resolves and stores the mentions in a new message, setting
+// message.Mentions for the broadcast, and notifies the recipients: online
+// users over the WebSocket, offline users by queueing a notification for
+// later. @channel and @here only count when the sender is a member. Failures
+// are logged; the message has already been posted.
+func (s *Server) processMentions(ctx context.Context, user *UserClaims, message *models.Message) {
+	channel, err := s.repo.GetChannel(ctx, message.ChannelID)
+	if err != nil {
+		s.logger.Warn("Failed to load channel for mentions", zap.Error(err))
+		return
+	}
+
+	var usernames []string
+	var channelMention, hereMention bool
+	if message.ContentType != "code" {
+		usernames, channelMention, hereMention = parseMentions(message.Content)
+	}
+
+	levels, err := s.repo.GetMemberNotificationLevels(ctx, channel.ID)
+	if err != nil {
+		s.logger.Warn("Failed to load notification levels", zap.Error(err))
+		return
+	}
+	if _, isMember := levels[user.UserID]; !isMember {
+		channelMention, hereMention = false, false
+	}
+
+	userIDs, err := s.repo.ResolveUsernames(ctx, user.OrganizationID, usernames)
+	if err != nil {
+		s.logger.Warn("Failed to resolve mentions", zap.Error(err))
+		userIDs = nil
+	}
+
+	var mentioned []uuid.UUID
+	for _, name := range usernames {
+		if userID, ok := userIDs[name]; ok {
+			mentioned = append(mentioned, userID)
+			message.Mentions = append(message.Mentions, models.Mention{
+				MessageID: message.ID,
+				Type:      models.MentionTypeUser,
+				UserID:    &userID,
+				Username:  name,
+			})
+		}
+	}
+	if channelMention {
+		message.Mentions = append(message.Mentions, models.Mention{MessageID: message.ID, Type: models.MentionTypeChannel, Username: mentionChannel})
+	}
+	if hereMention {
+		message.Mentions = append(message.Mentions, models.Mention{MessageID: message.ID, Type: models.MentionTypeHere, Username: mentionHere})
+	}
+
+	if err := s.repo.CreateMentions(ctx, message.ID, message.Mentions); err != nil {
+		s.logger.Warn("Failed to store mentions", zap.Error(err))
+	}
+
+	recipients := notificationRecipients(channel.Type, user.UserID, levels, mentioned, channelMention, hereMention, s.hub.IsUserOnline)
+	s.notify(ctx, message, recipients)
+}
+
+// notify sends online recipients a notification event and queues
+// notifications for the rest
+func (s *Server) notify(ctx context.Context, message *models.Message, recipients map[uuid.UUID]string) {
+	content := message.Content
+	if utf8.RuneCountInString(content) > notificationSnippetLength {
+		content = string([]rune(content)[:notificationSnippetLength]) + "…"
+	}
+
+	var queued []models.Notification
+	for userID, notificationType := range recipients {
+		notification := models.Notification{
+			UserID:    userID,
+			Type:      notificationType,
+			ChannelID: message.ChannelID,
+			MessageID: message.ID,
+			Content:   content,
+		}
+
+		if !s.hub.IsUserOnline(userID) {
+			queued = append(queued, notification)
+			continue
+		}
+
+		notification.ID = uuid.New()
+		notification.CreatedAt = time.Now()
+		s.hub.SendToUser(userID, &hub.Event{
+			Type:      hub.EventNotification,
+			ChannelID: &message.ChannelID,
+			Payload:   notification,
+			Timestamp: notification.CreatedAt,
+		})
+	}
+
+	if err := s.repo.CreateNotifications(ctx, queued); err != nil {
+		s.logger.Warn("Failed to queue notifications", zap.Error(err), zap.Int("count", len(queued)))
+	}
+}
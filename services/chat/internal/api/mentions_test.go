@@ -0,0 +1,115 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"chat/internal/models"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content   string
+		usernames []string
+		channel   bool
+		here      bool
+	}{
+		{"hello", nil, false, false},
+		{"@alice can you look?", []string{"alice"}, false, false},
+		{"cc @Alice, @bob.smith and @alice.", []string{"alice", "bob.smith"}, false, false},
+		{"mail alice@example.com", nil, false, false},
+		{"@channel deploy at 5, @here anyone?", nil, true, true},
+		{"(@carol)\n@dave-ops", []string{"carol", "dave-ops"}, false, false},
+		{"@@alice", nil, false, false},
+	}
+
+	for _, tt := range tests {
+		usernames, channel, here := parseMentions(tt.content)
+		if !reflect.DeepEqual(usernames, tt.usernames) || channel != tt.channel || here != tt.here {
+			t.Errorf("parseMentions(%q) = %v, %v, %v; want %v, %v, %v",
+				tt.content, usernames, channel, here, tt.usernames, tt.channel, tt.here)
+		}
+	}
+}
+
+func TestNotificationRecipients(t *testing.T) {
+	sender, all, mentions, muted, offline := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	outsider := uuid.New()
+	levels := map[uuid.UUID]models.NotificationLevel{
+		sender:   models.NotificationLevelMentions,
+		all:      models.NotificationLevelAll,
+		mentions: models.NotificationLevelMentions,
+		muted:    models.NotificationLevelMuted,
+		offline:  models.NotificationLevelMentions,
+	}
+	online := func(id uuid.UUID) bool { return id != offline }
+
+	tests := []struct {
+		name        string
+		channelType models.ChannelType
+		mentioned   []uuid.UUID
+		channel     bool
+		here        bool
+		want        map[uuid.UUID]string
+	}{
+		{
+			name:        "plain message notifies notify-all members",
+			channelType: models.ChannelTypePublic,
+			want:        map[uuid.UUID]string{all: notificationTypeChannel},
+		},
+		{
+			name:        "user mentions skip the sender and muted members",
+			channelType: models.ChannelTypePublic,
+			mentioned:   []uuid.UUID{sender, mentions, muted},
+			want:        map[uuid.UUID]string{mentions: notificationTypeMention, all: notificationTypeChannel},
+		},
+		{
+			name:        "non-members are notified only in public channels",
+			channelType: models.ChannelTypePrivate,
+			mentioned:   []uuid.UUID{outsider},
+			want:        map[uuid.UUID]string{all: notificationTypeChannel},
+		},
+		{
+			name:        "public channel outsider",
+			channelType: models.ChannelTypePublic,
+			mentioned:   []uuid.UUID{outsider},
+			want:        map[uuid.UUID]string{outsider: notificationTypeMention, all: notificationTypeChannel},
+		},
+		{
+			name:        "@channel notifies every unmuted member",
+			channelType: models.ChannelTypePrivate,
+			channel:     true,
+			want: map[uuid.UUID]string{
+				all:      notificationTypeMention,
+				mentions: notificationTypeMention,
+				offline:  notificationTypeMention,
+			},
+		},
+		{
+			name:        "@here notifies online members",
+			channelType: models.ChannelTypePrivate,
+			here:        true,
+			want:        map[uuid.UUID]string{all: notificationTypeMention, mentions: notificationTypeMention},
+		},
+		{
+			name:        "direct messages notify unmuted members",
+			channelType: models.ChannelTypeDirect,
+			want: map[uuid.UUID]string{
+				all:      notificationTypeDM,
+				mentions: notificationTypeDM,
+				offline:  notificationTypeDM,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := notificationRecipients(tt.channelType, sender, levels, tt.mentioned, tt.channel, tt.here, online)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notificationRecipients() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
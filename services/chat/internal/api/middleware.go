@@ -3,12 +3,15 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+
+	"chat/internal/token"
 )
 
 type contextKey string
@@ -40,67 +43,17 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Parse and validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(s.cfg.Auth.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			s.respondError(w, http.StatusUnauthorized, "invalid token")
-			return
-		}
-
-		// Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			s.respondError(w, http.StatusUnauthorized, "invalid claims")
-			return
-		}
-
-		// Helper to safely get string claim (supports both "sub"/"user_id" and "org_id"/"organization_id")
-		getStringClaim := func(keys ...string) string {
-			for _, key := range keys {
-				if val, ok := claims[key]; ok {
-					if str, ok := val.(string); ok {
-						return str
-					}
-				}
-			}
-			return ""
-		}
-
-		// Parse user ID (auth service uses "sub", some use "user_id")
-		userIDStr := getStringClaim("sub", "user_id")
-		if userIDStr == "" {
-			s.respondError(w, http.StatusUnauthorized, "missing user id claim")
-			return
-		}
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			s.respondError(w, http.StatusUnauthorized, "invalid user id")
-			return
-		}
-
-		// Parse organization ID (auth service uses "org_id", some use "organization_id")
-		orgIDStr := getStringClaim("org_id", "organization_id")
-		if orgIDStr == "" {
-			s.respondError(w, http.StatusUnauthorized, "missing organization id claim")
-			return
-		}
-		orgID, err := uuid.Parse(orgIDStr)
+		claims, err := s.tokens.Verify(tokenString)
 		if err != nil {
-			s.respondError(w, http.StatusUnauthorized, "invalid organization id")
+			s.respondTokenError(w, err)
 			return
 		}
 
 		user := &UserClaims{
-			UserID:         userID,
-			OrganizationID: orgID,
-			Email:          getStringClaim("email"),
-			Role:           getStringClaim("role"),
+			UserID:         claims.UserID,
+			OrganizationID: claims.OrganizationID,
+			Email:          claims.Email,
+			Role:           claims.Role,
 		}
 
 		// Add user to context
@@ -109,6 +62,21 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// respondTokenError rejects a request whose token failed verification. An
+// expired token gets the code token_expired so the client knows to refresh
+// it rather than sign in again.
+func (s *Server) respondTokenError(w http.ResponseWriter, err error) {
+	message, code := "invalid token", "invalid_token"
+	if errors.Is(err, token.ErrExpiredToken) {
+		message, code = "token has expired", "token_expired"
+	} else {
+		s.logger.Debug("Rejected token", zap.Error(err))
+	}
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, message))
+	s.respondJSON(w, http.StatusUnauthorized, map[string]string{"error": message, "code": code})
+}
+
 func (s *Server) getUserFromContext(r *http.Request) *UserClaims {
 	user, ok := r.Context().Value(userContextKey).(*UserClaims)
 	if !ok {
@@ -15,23 +15,29 @@ import (
 	"chat/config"
 	"chat/internal/hub"
 	"chat/internal/repository"
+	"chat/internal/scanner"
+	"chat/internal/token"
 )
 
 // Server represents the API server
 type Server struct {
-	cfg    *config.Config
-	repo   *repository.Repository
-	hub    *hub.Hub
-	logger *zap.Logger
+	cfg         *config.Config
+	repo        *repository.Repository
+	hub         *hub.Hub
+	fileScanner scanner.Scanner // nil when upload scanning is disabled
+	tokens      *token.Verifier
+	logger      *zap.Logger
 }
 
 // NewServer creates a new API server
-func NewServer(cfg *config.Config, repo *repository.Repository, hub *hub.Hub, logger *zap.Logger) *Server {
+func NewServer(cfg *config.Config, repo *repository.Repository, hub *hub.Hub, fileScanner scanner.Scanner, tokens *token.Verifier, logger *zap.Logger) *Server {
 	return &Server{
-		cfg:    cfg,
-		repo:   repo,
-		hub:    hub,
-		logger: logger,
+		cfg:         cfg,
+		repo:        repo,
+		hub:         hub,
+		fileScanner: fileScanner,
+		tokens:      tokens,
+		logger:      logger,
 	}
 }
 
@@ -87,6 +93,9 @@ func (s *Server) Router() http.Handler {
 				r.Get("/messages", s.listMessages)
 				r.Post("/messages", s.createMessage)
 				r.Get("/messages/pinned", s.getPinnedMessages)
+				r.Put("/pins/reorder", s.reorderPins)
+				r.Get("/messages/{messageID}/reactions/{emoji}", s.listReactionUsers)
+				r.Get("/messages/{messageID}/history", s.getMessageHistory)
 
 				// Members
 				r.Get("/members", s.listMembers)
@@ -97,6 +106,8 @@ func (s *Server) Router() http.Handler {
 				r.Post("/join", s.joinChannel)
 				r.Post("/leave", s.leaveChannel)
 				r.Post("/read", s.markAsRead)
+				r.Get("/notifications", s.getNotificationLevel)
+				r.Put("/notifications", s.setNotificationLevel)
 			})
 		})
 
@@ -105,6 +116,7 @@ func (s *Server) Router() http.Handler {
 			r.Post("/", s.createDirectMessage)
 			r.Get("/{userID}", s.getDirectMessages)
 		})
+		r.Get("/dms", s.listDirectConversations)
 
 		// Messages
 		r.Route("/messages/{messageID}", func(r chi.Router) {
@@ -136,6 +148,10 @@ func (s *Server) Router() http.Handler {
 
 		// File upload
 		r.Post("/upload", s.uploadFile)
+
+		// Custom emoji
+		r.Get("/emojis", s.listEmojis)
+		r.Post("/emojis", s.createEmoji)
 	})
 
 	return r
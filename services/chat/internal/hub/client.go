@@ -21,6 +21,11 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 65536
+
+	// CloseTokenExpired is the close code sent when the client's token
+	// expires mid-session. The client should refresh its token, reconnect
+	// and resubscribe with the last sequence number it received.
+	CloseTokenExpired = 4001
 )
 
 // ClientMessage represents an incoming message from a client
@@ -86,6 +91,13 @@ func (c *Client) WritePump(logger *zap.Logger) {
 		c.Conn.Close()
 	}()
 
+	var expired <-chan time.Time
+	if !c.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(c.ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
 	for {
 		select {
 		case message, ok := <-c.Send:
@@ -118,6 +130,13 @@ func (c *Client) WritePump(logger *zap.Logger) {
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+
+		case <-expired:
+			// Closing the connection ends ReadPump, which unregisters the client
+			logger.Debug("Closing WebSocket on token expiry", zap.String("client_id", c.ID.String()))
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(CloseTokenExpired, "token expired"))
+			return
 		}
 	}
 }
@@ -130,7 +149,7 @@ func (c *Client) handleMessage(msg *ClientMessage, logger *zap.Logger) {
 				IsTyping bool `json:"is_typing"`
 			}
 			if err := json.Unmarshal(msg.Payload, &payload); err == nil {
-				c.Hub.BroadcastTyping(*msg.ChannelID, c.UserID, payload.IsTyping)
+				c.Hub.HandleTyping(c, *msg.ChannelID, payload.IsTyping)
 			}
 		}
 
@@ -144,13 +163,41 @@ func (c *Client) handleMessage(msg *ClientMessage, logger *zap.Logger) {
 		c.Send <- data
 
 	case "subscribe":
-		// Subscribe to a channel
+		// Subscribe to a channel. A reconnecting client sends the last
+		// sequence number it acknowledged to have missed messages replayed.
 		if msg.ChannelID != nil {
+			if !c.Hub.CanSubscribe(c, *msg.ChannelID) {
+				c.SendEvent(&Event{
+					Type:      EventError,
+					ChannelID: msg.ChannelID,
+					Payload:   map[string]string{"error": "not a member of this channel"},
+					Timestamp: time.Now(),
+				})
+				return
+			}
 			c.Hub.JoinChannel(c, *msg.ChannelID)
 			logger.Debug("Client subscribed to channel",
 				zap.String("user_id", c.UserID.String()),
 				zap.String("channel_id", msg.ChannelID.String()),
 			)
+
+			var payload struct {
+				LastSeq *int64 `json:"last_seq"`
+			}
+			if len(msg.Payload) > 0 && json.Unmarshal(msg.Payload, &payload) == nil && payload.LastSeq != nil {
+				c.Hub.Replay(c, *msg.ChannelID, *payload.LastSeq)
+			}
+		}
+
+	case "ack":
+		// Acknowledge every message in a channel up to a sequence number
+		if msg.ChannelID != nil {
+			var payload struct {
+				Seq int64 `json:"seq"`
+			}
+			if err := json.Unmarshal(msg.Payload, &payload); err == nil {
+				c.Hub.Ack(c, *msg.ChannelID, payload.Seq)
+			}
 		}
 
 	case "unsubscribe":
@@ -0,0 +1,233 @@
+package hub
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"chat/internal/models"
+)
+
+const (
+	// Most messages replayed to a reconnecting client. A client further
+	// behind is told to resync with a paginated fetch instead.
+	maxReplayMessages = 200
+
+	// How long a delivered message may go unacknowledged before it is
+	// redelivered
+	ackTimeout = 30 * time.Second
+
+	// Redeliveries without an ack before the client is told to resync
+	maxRedeliveries = 3
+
+	// Time allowed to load missed messages
+	replayTimeout = 10 * time.Second
+)
+
+// messageReplayer loads the changes to a channel's messages after a
+// sequence number; the repository implements it
+type messageReplayer interface {
+	ListMessagesAfterSeq(ctx context.Context, channelID uuid.UUID, afterSeq int64, limit int) ([]models.Message, error)
+}
+
+// channelDelivery tracks the message sequence numbers sent to, and
+// acknowledged by, one client in one channel
+type channelDelivery struct {
+	sent        int64
+	acked       int64
+	pendingFrom time.Time // When the oldest unacknowledged message was sent
+	redelivered int
+}
+
+// Ack records that a client received every message in a channel up to seq.
+// A client that acks opts in to redelivery: messages it hasn't acknowledged
+// within ackTimeout are sent again. Reports whether the ack was accepted.
+func (h *Hub) Ack(client *Client, channelID uuid.UUID, seq int64) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if !client.Channels[channelID] || seq < 0 {
+		return false
+	}
+
+	d := client.deliveryLocked(channelID)
+	if seq > d.acked {
+		d.acked = seq
+		d.redelivered = 0
+	}
+	if d.acked >= d.sent {
+		d.sent = d.acked
+		d.pendingFrom = time.Time{}
+	} else {
+		d.pendingFrom = time.Now()
+	}
+	return true
+}
+
+// Replay sends a subscribed client the messages in a channel after lastSeq,
+// the last sequence number it acknowledged before reconnecting, and opts it
+// in to redelivery. Messages already broadcast since the client subscribed
+// may arrive twice; clients drop sequence numbers they have seen. A client
+// more than maxReplayMessages behind gets a resync event instead.
+func (h *Hub) Replay(client *Client, channelID uuid.UUID, lastSeq int64) {
+	if h.replayer == nil || lastSeq < 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), replayTimeout)
+	defer cancel()
+
+	messages, err := h.replayer.ListMessagesAfterSeq(ctx, channelID, lastSeq, maxReplayMessages+1)
+	if err != nil {
+		h.logger.Error("Failed to load messages for replay",
+			zap.String("client_id", client.ID.String()),
+			zap.String("channel_id", channelID.String()),
+			zap.Error(err),
+		)
+		return
+	}
+
+	// Hold the hub lock so the client can't be unregistered, and its send
+	// buffer closed, mid-replay
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.channelClients[channelID][client] {
+		return
+	}
+
+	if len(messages) > maxReplayMessages {
+		h.resyncLocked(client, channelID, lastSeq)
+		return
+	}
+
+	client.mu.Lock()
+	d := client.deliveryLocked(channelID)
+	if lastSeq > d.acked {
+		d.acked = lastSeq
+	}
+	client.mu.Unlock()
+
+	sent := lastSeq
+	for i := range messages {
+		if err := client.SendEvent(messageEvent(channelID, &messages[i])); err != nil {
+			// Whatever didn't fit is redelivered after ackTimeout
+			break
+		}
+		sent = messages[i].Seq
+	}
+
+	client.mu.Lock()
+	d = client.deliveryLocked(channelID)
+	if sent > d.sent {
+		d.sent = sent
+	}
+	if d.sent > d.acked {
+		d.pendingFrom = time.Now()
+	}
+	client.mu.Unlock()
+}
+
+// resyncLocked tells a client it missed too much to replay and should fetch
+// the channel's messages again, and stops redelivery until it next acks.
+// The caller holds h.mu.
+func (h *Hub) resyncLocked(client *Client, channelID uuid.UUID, lastSeq int64) {
+	client.mu.Lock()
+	delete(client.delivery, channelID)
+	client.mu.Unlock()
+
+	client.SendEvent(&Event{
+		Type:      EventResync,
+		ChannelID: &channelID,
+		Payload: map[string]interface{}{
+			"after_seq":  lastSeq,
+			"max_replay": maxReplayMessages,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// trackSent records a sequenced broadcast to a client that has opted in to
+// redelivery
+func (c *Client) trackSent(channelID uuid.UUID, seq int64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.delivery[channelID]
+	if !ok || seq <= d.sent {
+		return
+	}
+	d.sent = seq
+	if d.pendingFrom.IsZero() {
+		d.pendingFrom = now
+	}
+}
+
+// deliveryLocked returns the client's delivery state for a channel,
+// creating it if needed. The caller holds c.mu.
+func (c *Client) deliveryLocked(channelID uuid.UUID) *channelDelivery {
+	if c.delivery == nil {
+		c.delivery = make(map[uuid.UUID]*channelDelivery)
+	}
+	d, ok := c.delivery[channelID]
+	if !ok {
+		d = &channelDelivery{}
+		c.delivery[channelID] = d
+	}
+	return d
+}
+
+// redeliverUnacked replays from the last acknowledged sequence number to
+// every opted-in client whose acks have lagged past ackTimeout. A client
+// that keeps missing acks is told to resync.
+func (h *Hub) redeliverUnacked(now time.Time) {
+	type pending struct {
+		client    *Client
+		channelID uuid.UUID
+		acked     int64
+		resync    bool
+	}
+	var due []pending
+
+	h.mu.RLock()
+	for _, clients := range h.clients {
+		for client := range clients {
+			client.mu.Lock()
+			for channelID, d := range client.delivery {
+				if d.pendingFrom.IsZero() || now.Sub(d.pendingFrom) < ackTimeout {
+					continue
+				}
+				d.redelivered++
+				d.pendingFrom = now
+				due = append(due, pending{client, channelID, d.acked, d.redelivered > maxRedeliveries})
+			}
+			client.mu.Unlock()
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, p := range due {
+		if p.resync {
+			h.mu.RLock()
+			if h.channelClients[p.channelID][p.client] {
+				h.resyncLocked(p.client, p.channelID, p.acked)
+			}
+			h.mu.RUnlock()
+			continue
+		}
+		h.Replay(p.client, p.channelID, p.acked)
+	}
+}
+
+// messageEvent wraps a message in a message event carrying its sequence
+// number
+func messageEvent(channelID uuid.UUID, message *models.Message) *Event {
+	return &Event{
+		Type:      EventMessage,
+		ChannelID: &channelID,
+		Seq:       message.Seq,
+		Payload:   message,
+		Timestamp: time.Now(),
+	}
+}
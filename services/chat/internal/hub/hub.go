@@ -1,6 +1,7 @@
 package hub
 
 import (
+	"context"
 	"encoding/json"
 	"sync"
 	"time"
@@ -26,16 +27,28 @@ const (
 	EventChannelJoin    EventType = "channel_join"
 	EventChannelLeave   EventType = "channel_leave"
 	EventReaction       EventType = "reaction"
+	EventPinsUpdate     EventType = "pins_update"
+	EventRead           EventType = "read"
 	EventNotification   EventType = "notification"
 	EventError          EventType = "error"
 	EventPing           EventType = "ping"
 	EventPong           EventType = "pong"
+	EventResync         EventType = "resync"
+)
+
+const (
+	// How often last_seen_at is refreshed for users with open connections
+	lastSeenFlushInterval = time.Minute
+
+	// Time allowed to persist last_seen_at
+	lastSeenWriteTimeout = 5 * time.Second
 )
 
 // Event represents a WebSocket event
 type Event struct {
 	Type      EventType   `json:"type"`
 	ChannelID *uuid.UUID  `json:"channel_id,omitempty"`
+	Seq       int64       `json:"seq,omitempty"` // Channel sequence number of a message event
 	Payload   interface{} `json:"payload"`
 	Timestamp time.Time   `json:"timestamp"`
 }
@@ -50,6 +63,13 @@ type Client struct {
 	Hub            *Hub
 	Channels       map[uuid.UUID]bool
 	mu             sync.RWMutex
+
+	// When the token the client connected with expires; the connection is
+	// closed then. Zero for no expiry.
+	ExpiresAt time.Time
+
+	// Delivery state per channel, for clients that acknowledge messages
+	delivery map[uuid.UUID]*channelDelivery
 }
 
 // Hub maintains the set of active clients and broadcasts messages
@@ -81,12 +101,19 @@ type Hub struct {
 	// Repository for persistence
 	repo *repository.Repository
 
+	// Source of missed messages for replay; nil without a repository
+	replayer messageReplayer
+
 	// Logger
 	logger *zap.Logger
 
 	// Mutex for thread safety
 	mu sync.RWMutex
 
+	// Last typing event sent per user and channel, for throttling
+	typingSent map[typingKey]time.Time
+	typingMu   sync.Mutex
+
 	// Shutdown channel
 	shutdown chan struct{}
 }
@@ -96,6 +123,7 @@ type ChannelBroadcast struct {
 	ChannelID uuid.UUID
 	Event     *Event
 	ExcludeClient *Client
+	ExcludeUserID *uuid.UUID
 }
 
 // DirectBroadcast represents a message to send to a specific user
@@ -112,7 +140,7 @@ type OrgBroadcast struct {
 
 // NewHub creates a new Hub
 func NewHub(repo *repository.Repository, logger *zap.Logger) *Hub {
-	return &Hub{
+	h := &Hub{
 		clients:        make(map[uuid.UUID]map[*Client]bool),
 		channelClients: make(map[uuid.UUID]map[*Client]bool),
 		orgClients:     make(map[uuid.UUID]map[*Client]bool),
@@ -123,8 +151,13 @@ func NewHub(repo *repository.Repository, logger *zap.Logger) *Hub {
 		orgBroadcast:   make(chan *OrgBroadcast, 256),
 		repo:           repo,
 		logger:         logger,
+		typingSent:     make(map[typingKey]time.Time),
 		shutdown:       make(chan struct{}),
 	}
+	if repo != nil {
+		h.replayer = repo
+	}
+	return h
 }
 
 // Run starts the hub's main loop
@@ -132,6 +165,9 @@ func (h *Hub) Run() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	lastSeenTicker := time.NewTicker(lastSeenFlushInterval)
+	defer lastSeenTicker.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
@@ -151,6 +187,11 @@ func (h *Hub) Run() {
 
 		case <-ticker.C:
 			h.cleanupStaleConnections()
+			h.pruneTyping(time.Now())
+			go h.redeliverUnacked(time.Now())
+
+		case <-lastSeenTicker.C:
+			go h.flushLastSeen()
 
 		case <-h.shutdown:
 			h.closeAllConnections()
@@ -186,7 +227,7 @@ func (h *Hub) registerClient(client *Client) {
 	)
 
 	// Broadcast presence update
-	h.broadcastPresence(client.UserID, client.OrganizationID, "online")
+	h.broadcastPresence(client.UserID, client.OrganizationID, "online", nil)
 }
 
 func (h *Hub) unregisterClient(client *Client) {
@@ -198,8 +239,10 @@ func (h *Hub) unregisterClient(client *Client) {
 		delete(clients, client)
 		if len(clients) == 0 {
 			delete(h.clients, client.UserID)
-			// User is now offline
-			h.broadcastPresence(client.UserID, client.OrganizationID, "offline")
+			// User is now offline; their last connection just closed
+			seenAt := time.Now()
+			h.broadcastPresence(client.UserID, client.OrganizationID, "offline", &seenAt)
+			go h.persistLastSeen([]uuid.UUID{client.UserID}, seenAt)
 		}
 	}
 
@@ -251,6 +294,7 @@ func (h *Hub) LeaveChannel(client *Client, channelID uuid.UUID) {
 
 	client.mu.Lock()
 	delete(client.Channels, channelID)
+	delete(client.delivery, channelID)
 	client.mu.Unlock()
 
 	if clients, ok := h.channelClients[channelID]; ok {
@@ -272,12 +316,19 @@ func (h *Hub) broadcastToChannel(msg *ChannelBroadcast) {
 		return
 	}
 
+	now := time.Now()
 	for client := range clients {
 		if msg.ExcludeClient != nil && client == msg.ExcludeClient {
 			continue
 		}
+		if msg.ExcludeUserID != nil && client.UserID == *msg.ExcludeUserID {
+			continue
+		}
 		select {
 		case client.Send <- data:
+			if msg.Event.Seq > 0 {
+				client.trackSent(msg.ChannelID, msg.Event.Seq, now)
+			}
 		default:
 			h.unregister <- client
 		}
@@ -324,13 +375,13 @@ func (h *Hub) broadcastToOrg(msg *OrgBroadcast) {
 	}
 }
 
-func (h *Hub) broadcastPresence(userID, orgID uuid.UUID, status string) {
+func (h *Hub) broadcastPresence(userID, orgID uuid.UUID, status string, lastSeenAt *time.Time) {
 	event := &Event{
 		Type: EventPresence,
 		Payload: models.Presence{
 			UserID:     userID,
 			Status:     status,
-			LastSeenAt: time.Now(),
+			LastSeenAt: lastSeenAt,
 		},
 		Timestamp: time.Now(),
 	}
@@ -344,11 +395,48 @@ func (h *Hub) broadcastPresence(userID, orgID uuid.UUID, status string) {
 	}()
 }
 
+// flushLastSeen refreshes last_seen_at for every connected user so that a
+// long-lived connection lost without a clean unregister (e.g. a crash) still
+// leaves a recent timestamp behind
+func (h *Hub) flushLastSeen() {
+	h.persistLastSeen(h.connectedUserIDs(), time.Now())
+}
+
+func (h *Hub) connectedUserIDs() []uuid.UUID {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	userIDs := make([]uuid.UUID, 0, len(h.clients))
+	for userID := range h.clients {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
+func (h *Hub) persistLastSeen(userIDs []uuid.UUID, seenAt time.Time) {
+	if h.repo == nil || len(userIDs) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lastSeenWriteTimeout)
+	defer cancel()
+
+	if err := h.repo.UpdateUsersLastSeen(ctx, userIDs, seenAt); err != nil {
+		h.logger.Error("Failed to persist last seen",
+			zap.Int("users", len(userIDs)),
+			zap.Error(err),
+		)
+	}
+}
+
 func (h *Hub) cleanupStaleConnections() {
 	// Implemented in client read/write pumps via ping/pong
 }
 
 func (h *Hub) closeAllConnections() {
+	// Everyone still connected goes offline now
+	h.persistLastSeen(h.connectedUserIDs(), time.Now())
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -359,35 +447,75 @@ func (h *Hub) closeAllConnections() {
 	}
 }
 
-// BroadcastMessage broadcasts a new message to a channel
+// BroadcastMessage broadcasts a new, edited or deleted message to a
+// channel. A stored message's event carries its sequence number for clients
+// to acknowledge.
 func (h *Hub) BroadcastMessage(channelID uuid.UUID, message *models.Message) {
+	h.broadcast <- &ChannelBroadcast{
+		ChannelID: channelID,
+		Event:     messageEvent(channelID, message),
+	}
+}
+
+// Pin actions reported in pins_update events
+const (
+	PinActionPin     = "pin"
+	PinActionUnpin   = "unpin"
+	PinActionReorder = "reorder"
+)
+
+// PinsUpdate is the payload of a pins_update event
+type PinsUpdate struct {
+	Action     string      `json:"action"`
+	UserID     uuid.UUID   `json:"user_id"`
+	MessageID  *uuid.UUID  `json:"message_id,omitempty"`  // Pinned or unpinned message
+	MessageIDs []uuid.UUID `json:"message_ids,omitempty"` // New pin order after a reorder
+}
+
+// BroadcastPinsUpdate tells a channel's subscribers that its pinned
+// messages changed
+func (h *Hub) BroadcastPinsUpdate(channelID uuid.UUID, update *PinsUpdate) {
 	h.broadcast <- &ChannelBroadcast{
 		ChannelID: channelID,
 		Event: &Event{
-			Type:      EventMessage,
+			Type:      EventPinsUpdate,
 			ChannelID: &channelID,
-			Payload:   message,
+			Payload:   update,
 			Timestamp: time.Now(),
 		},
 	}
 }
 
-// BroadcastTyping broadcasts typing indicator
-func (h *Hub) BroadcastTyping(channelID, userID uuid.UUID, isTyping bool) {
+// ReadState is the payload of a read event
+type ReadState struct {
+	UserID        uuid.UUID  `json:"user_id"`
+	LastReadMsgID *uuid.UUID `json:"last_read_msg_id,omitempty"`
+	LastReadAt    time.Time  `json:"last_read_at"`
+}
+
+// BroadcastRead tells a channel's subscribers that a member read it: the
+// other participant of a direct message sees the message was read, and the
+// member's other connections clear their unread count
+func (h *Hub) BroadcastRead(channelID uuid.UUID, state *ReadState) {
 	h.broadcast <- &ChannelBroadcast{
 		ChannelID: channelID,
 		Event: &Event{
-			Type:      EventTyping,
+			Type:      EventRead,
 			ChannelID: &channelID,
-			Payload: map[string]interface{}{
-				"user_id":   userID,
-				"is_typing": isTyping,
-			},
+			Payload:   state,
 			Timestamp: time.Now(),
 		},
 	}
 }
 
+// SendToUser sends an event to every connection of a user
+func (h *Hub) SendToUser(userID uuid.UUID, event *Event) {
+	h.direct <- &DirectBroadcast{
+		UserID: userID,
+		Event:  event,
+	}
+}
+
 // Register registers a new client
 func (h *Hub) Register(client *Client) {
 	h.register <- client
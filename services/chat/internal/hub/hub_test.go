@@ -1,6 +1,7 @@
 package hub
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -177,6 +178,152 @@ func TestHub(t *testing.T) {
 
 		hub.Unregister(client)
 	})
+
+	t.Run("BroadcastPinsUpdate", func(t *testing.T) {
+		channelID := uuid.New()
+		client := &Client{
+			ID:             uuid.New(),
+			UserID:         uuid.New(),
+			OrganizationID: uuid.New(),
+			Send:           make(chan []byte, 256),
+			Hub:            hub,
+			Channels:       make(map[uuid.UUID]bool),
+		}
+
+		hub.Register(client)
+		hub.JoinChannel(client, channelID)
+		time.Sleep(50 * time.Millisecond)
+
+		order := []uuid.UUID{uuid.New(), uuid.New()}
+		hub.BroadcastPinsUpdate(channelID, &PinsUpdate{Action: PinActionReorder, UserID: client.UserID, MessageIDs: order})
+
+		// Skip the client's own presence event
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case data := <-client.Send:
+				var event struct {
+					Type    EventType  `json:"type"`
+					Payload PinsUpdate `json:"payload"`
+				}
+				require.NoError(t, json.Unmarshal(data, &event))
+				if event.Type == EventPresence {
+					continue
+				}
+				assert.Equal(t, EventPinsUpdate, event.Type)
+				assert.Equal(t, PinActionReorder, event.Payload.Action)
+				assert.Equal(t, order, event.Payload.MessageIDs)
+				assert.Nil(t, event.Payload.MessageID)
+			case <-timeout:
+				t.Fatal("Did not receive pins update")
+			}
+			break
+		}
+
+		hub.Unregister(client)
+	})
+
+	t.Run("BroadcastRead", func(t *testing.T) {
+		channelID := uuid.New()
+		client := &Client{
+			ID:             uuid.New(),
+			UserID:         uuid.New(),
+			OrganizationID: uuid.New(),
+			Send:           make(chan []byte, 256),
+			Hub:            hub,
+			Channels:       make(map[uuid.UUID]bool),
+		}
+
+		hub.Register(client)
+		hub.JoinChannel(client, channelID)
+		time.Sleep(50 * time.Millisecond)
+
+		readerID := uuid.New()
+		messageID := uuid.New()
+		hub.BroadcastRead(channelID, &ReadState{UserID: readerID, LastReadMsgID: &messageID, LastReadAt: time.Now()})
+
+		// Skip the client's own presence event
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case data := <-client.Send:
+				var event struct {
+					Type    EventType `json:"type"`
+					Payload ReadState `json:"payload"`
+				}
+				require.NoError(t, json.Unmarshal(data, &event))
+				if event.Type == EventPresence {
+					continue
+				}
+				assert.Equal(t, EventRead, event.Type)
+				assert.Equal(t, readerID, event.Payload.UserID)
+				require.NotNil(t, event.Payload.LastReadMsgID)
+				assert.Equal(t, messageID, *event.Payload.LastReadMsgID)
+			case <-timeout:
+				t.Fatal("Did not receive read event")
+			}
+			break
+		}
+
+		hub.Unregister(client)
+	})
+
+	t.Run("OfflineAfterLastConnection", func(t *testing.T) {
+		userID := uuid.New()
+		orgID := uuid.New()
+
+		newClient := func(uid uuid.UUID) *Client {
+			return &Client{
+				ID:             uuid.New(),
+				UserID:         uid,
+				OrganizationID: orgID,
+				Send:           make(chan []byte, 256),
+				Hub:            hub,
+				Channels:       make(map[uuid.UUID]bool),
+			}
+		}
+
+		observer := newClient(uuid.New())
+		first := newClient(userID)
+		second := newClient(userID)
+
+		hub.Register(observer)
+		hub.Register(first)
+		hub.Register(second)
+		time.Sleep(50 * time.Millisecond)
+
+		hub.Unregister(first)
+		time.Sleep(50 * time.Millisecond)
+		assert.True(t, hub.IsUserOnline(userID))
+
+		before := time.Now()
+		hub.Unregister(second)
+		time.Sleep(50 * time.Millisecond)
+		assert.False(t, hub.IsUserOnline(userID))
+
+		// The observer gets an offline presence event stamped with the
+		// moment the last connection closed
+		deadline := time.After(time.Second)
+		for {
+			select {
+			case data := <-observer.Send:
+				var event struct {
+					Type    EventType       `json:"type"`
+					Payload models.Presence `json:"payload"`
+				}
+				require.NoError(t, json.Unmarshal(data, &event))
+				if event.Type != EventPresence || event.Payload.UserID != userID || event.Payload.Status != "offline" {
+					continue
+				}
+				require.NotNil(t, event.Payload.LastSeenAt)
+				assert.False(t, event.Payload.LastSeenAt.Before(before))
+				hub.Unregister(observer)
+				return
+			case <-deadline:
+				t.Fatal("Did not receive offline presence event")
+			}
+		}
+	})
 }
 
 func TestClient(t *testing.T) {
@@ -332,6 +479,243 @@ func TestWebSocketIntegration(t *testing.T) {
 	})
 }
 
+func TestTypingIndicators(t *testing.T) {
+	logger := zap.NewNop()
+	hub := NewHub(nil, logger)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	channelID := uuid.New()
+	orgID := uuid.New()
+
+	newClient := func(userID uuid.UUID) *Client {
+		return &Client{
+			ID:             uuid.New(),
+			UserID:         userID,
+			OrganizationID: orgID,
+			Send:           make(chan []byte, 256),
+			Hub:            hub,
+			Channels:       make(map[uuid.UUID]bool),
+		}
+	}
+
+	// nextTyping returns the next typing event, skipping presence noise
+	nextTyping := func(t *testing.T, client *Client) (Event, bool) {
+		t.Helper()
+		timeout := time.After(200 * time.Millisecond)
+		for {
+			select {
+			case data := <-client.Send:
+				var event Event
+				require.NoError(t, json.Unmarshal(data, &event))
+				if event.Type == EventTyping {
+					return event, true
+				}
+			case <-timeout:
+				return Event{}, false
+			}
+		}
+	}
+
+	typer := newClient(uuid.New())
+	typerOtherTab := newClient(typer.UserID)
+	member := newClient(uuid.New())
+	outsider := newClient(uuid.New())
+
+	for _, c := range []*Client{typer, typerOtherTab, member, outsider} {
+		hub.Register(c)
+	}
+	hub.JoinChannel(typer, channelID)
+	hub.JoinChannel(typerOtherTab, channelID)
+	hub.JoinChannel(member, channelID)
+	time.Sleep(50 * time.Millisecond)
+
+	t.Run("DeliveredToOtherMembersWithTTL", func(t *testing.T) {
+		assert.True(t, hub.HandleTyping(typer, channelID, true))
+
+		event, ok := nextTyping(t, member)
+		require.True(t, ok, "member did not receive typing event")
+		require.NotNil(t, event.ChannelID)
+		assert.Equal(t, channelID, *event.ChannelID)
+
+		payload, ok := event.Payload.(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, typer.UserID.String(), payload["user_id"])
+		assert.Equal(t, true, payload["is_typing"])
+		assert.Equal(t, float64(typingTTL.Milliseconds()), payload["ttl_ms"])
+
+		_, ok = nextTyping(t, typerOtherTab)
+		assert.False(t, ok, "typing user's own connections should not be notified")
+		_, ok = nextTyping(t, outsider)
+		assert.False(t, ok, "unsubscribed client should not be notified")
+	})
+
+	t.Run("Throttled", func(t *testing.T) {
+		assert.False(t, hub.HandleTyping(typer, channelID, true))
+		assert.False(t, hub.HandleTyping(typerOtherTab, channelID, true))
+
+		_, ok := nextTyping(t, member)
+		assert.False(t, ok)
+	})
+
+	t.Run("StopAlwaysSent", func(t *testing.T) {
+		assert.True(t, hub.HandleTyping(typer, channelID, false))
+
+		event, ok := nextTyping(t, member)
+		require.True(t, ok)
+		payload := event.Payload.(map[string]interface{})
+		assert.Equal(t, false, payload["is_typing"])
+
+		// Stopping resets the throttle
+		assert.True(t, hub.HandleTyping(typer, channelID, true))
+		_, ok = nextTyping(t, member)
+		assert.True(t, ok)
+	})
+
+	t.Run("RequiresSubscription", func(t *testing.T) {
+		assert.False(t, hub.HandleTyping(outsider, channelID, true))
+
+		_, ok := nextTyping(t, member)
+		assert.False(t, ok)
+	})
+
+	t.Run("PruneExpiredThrottle", func(t *testing.T) {
+		hub.pruneTyping(time.Now().Add(typingThrottle))
+
+		hub.typingMu.Lock()
+		defer hub.typingMu.Unlock()
+		assert.Empty(t, hub.typingSent)
+	})
+}
+
+// fakeReplayer serves replays from an in-memory channel history
+type fakeReplayer struct {
+	messages []models.Message
+}
+
+func (f *fakeReplayer) ListMessagesAfterSeq(ctx context.Context, channelID uuid.UUID, afterSeq int64, limit int) ([]models.Message, error) {
+	var out []models.Message
+	for _, m := range f.messages {
+		if m.ChannelID == channelID && m.Seq > afterSeq && len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func TestMessageDelivery(t *testing.T) {
+	logger := zap.NewNop()
+	hub := NewHub(nil, logger)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	channelID := uuid.New()
+	replayer := &fakeReplayer{}
+	for seq := int64(1); seq <= maxReplayMessages+10; seq++ {
+		replayer.messages = append(replayer.messages, models.Message{
+			ID:        uuid.New(),
+			ChannelID: channelID,
+			Content:   "message",
+			Seq:       seq,
+		})
+	}
+	hub.replayer = replayer
+
+	newClient := func() *Client {
+		client := &Client{
+			ID:             uuid.New(),
+			UserID:         uuid.New(),
+			OrganizationID: uuid.New(),
+			Send:           make(chan []byte, 512),
+			Hub:            hub,
+			Channels:       make(map[uuid.UUID]bool),
+		}
+		hub.Register(client)
+		hub.JoinChannel(client, channelID)
+		return client
+	}
+
+	// drain returns the channel events queued for a client
+	drain := func(t *testing.T, client *Client) []Event {
+		t.Helper()
+		var events []Event
+		for {
+			select {
+			case data := <-client.Send:
+				var event Event
+				require.NoError(t, json.Unmarshal(data, &event))
+				if event.ChannelID != nil && *event.ChannelID == channelID {
+					events = append(events, event)
+				}
+			case <-time.After(100 * time.Millisecond):
+				return events
+			}
+		}
+	}
+
+	t.Run("ReplaysMissedMessages", func(t *testing.T) {
+		client := newClient()
+		hub.Replay(client, channelID, maxReplayMessages)
+
+		events := drain(t, client)
+		require.Len(t, events, 10)
+		for i, event := range events {
+			assert.Equal(t, EventMessage, event.Type)
+			assert.Equal(t, int64(maxReplayMessages+1+i), event.Seq)
+		}
+	})
+
+	t.Run("ResyncWhenTooFarBehind", func(t *testing.T) {
+		client := newClient()
+		hub.Replay(client, channelID, 0)
+
+		events := drain(t, client)
+		require.Len(t, events, 1)
+		assert.Equal(t, EventResync, events[0].Type)
+
+		client.mu.RLock()
+		defer client.mu.RUnlock()
+		assert.Empty(t, client.delivery, "resync should stop redelivery")
+	})
+
+	t.Run("BroadcastCarriesSeq", func(t *testing.T) {
+		client := newClient()
+		hub.BroadcastMessage(channelID, &models.Message{ChannelID: channelID, Seq: 42})
+
+		events := drain(t, client)
+		require.Len(t, events, 1)
+		assert.Equal(t, int64(42), events[0].Seq)
+	})
+
+	t.Run("RedeliversUnacked", func(t *testing.T) {
+		client := newClient()
+		require.True(t, hub.Ack(client, channelID, maxReplayMessages+5))
+
+		hub.BroadcastMessage(channelID, &models.Message{ChannelID: channelID, Seq: maxReplayMessages + 10})
+		require.Len(t, drain(t, client), 1)
+
+		// Not yet due
+		hub.redeliverUnacked(time.Now())
+		assert.Empty(t, drain(t, client))
+
+		hub.redeliverUnacked(time.Now().Add(ackTimeout))
+		events := drain(t, client)
+		require.Len(t, events, 5)
+		assert.Equal(t, int64(maxReplayMessages+6), events[0].Seq)
+
+		// Acknowledged messages are not sent again
+		require.True(t, hub.Ack(client, channelID, maxReplayMessages+10))
+		hub.redeliverUnacked(time.Now().Add(2 * ackTimeout))
+		assert.Empty(t, drain(t, client))
+	})
+
+	t.Run("AckRequiresSubscription", func(t *testing.T) {
+		client := newClient()
+		hub.LeaveChannel(client, channelID)
+		assert.False(t, hub.Ack(client, channelID, 1))
+	})
+}
+
 func TestEventTypes(t *testing.T) {
 	t.Run("EventTypeStrings", func(t *testing.T) {
 		assert.Equal(t, EventType("message"), EventMessage)
@@ -343,10 +727,13 @@ func TestEventTypes(t *testing.T) {
 		assert.Equal(t, EventType("channel_join"), EventChannelJoin)
 		assert.Equal(t, EventType("channel_leave"), EventChannelLeave)
 		assert.Equal(t, EventType("reaction"), EventReaction)
+		assert.Equal(t, EventType("pins_update"), EventPinsUpdate)
+		assert.Equal(t, EventType("read"), EventRead)
 		assert.Equal(t, EventType("notification"), EventNotification)
 		assert.Equal(t, EventType("error"), EventError)
 		assert.Equal(t, EventType("ping"), EventPing)
 		assert.Equal(t, EventType("pong"), EventPong)
+		assert.Equal(t, EventType("resync"), EventResync)
 	})
 }
 
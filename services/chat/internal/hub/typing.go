@@ -0,0 +1,122 @@
+package hub
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// How long clients should show a typing indicator without a refresh
+	typingTTL = 5 * time.Second
+
+	// Minimum interval between typing events from one user in one channel.
+	// Kept below typingTTL so a user who keeps typing never flickers off.
+	typingThrottle = 2 * time.Second
+
+	// Time allowed to check channel membership on subscribe
+	membershipCheckTimeout = 5 * time.Second
+)
+
+// typingKey identifies a user typing in a channel
+type typingKey struct {
+	channelID uuid.UUID
+	userID    uuid.UUID
+}
+
+// HandleTyping relays a typing indicator from client to the other members of
+// channelID. The client must be subscribed to the channel, which requires
+// membership. Start events are throttled per user and channel; stop events
+// always go through so indicators clear promptly. Reports whether the event
+// was broadcast.
+func (h *Hub) HandleTyping(client *Client, channelID uuid.UUID, isTyping bool) bool {
+	client.mu.RLock()
+	subscribed := client.Channels[channelID]
+	client.mu.RUnlock()
+	if !subscribed {
+		return false
+	}
+
+	if !h.allowTyping(typingKey{channelID: channelID, userID: client.UserID}, isTyping, time.Now()) {
+		return false
+	}
+
+	h.BroadcastTyping(channelID, client.UserID, isTyping)
+	return true
+}
+
+// allowTyping applies the per-user, per-channel throttle
+func (h *Hub) allowTyping(key typingKey, isTyping bool, now time.Time) bool {
+	h.typingMu.Lock()
+	defer h.typingMu.Unlock()
+
+	if !isTyping {
+		// The next start event should be sent immediately
+		delete(h.typingSent, key)
+		return true
+	}
+
+	if last, ok := h.typingSent[key]; ok && now.Sub(last) < typingThrottle {
+		return false
+	}
+	h.typingSent[key] = now
+	return true
+}
+
+// pruneTyping drops throttle entries that can no longer suppress an event
+func (h *Hub) pruneTyping(now time.Time) {
+	h.typingMu.Lock()
+	defer h.typingMu.Unlock()
+
+	for key, last := range h.typingSent {
+		if now.Sub(last) >= typingThrottle {
+			delete(h.typingSent, key)
+		}
+	}
+}
+
+// BroadcastTyping broadcasts a transient typing indicator to every client
+// subscribed to the channel except the typing user's own connections. The
+// event is never persisted; ttl_ms tells clients when to clear it if no
+// refresh or stop event arrives.
+func (h *Hub) BroadcastTyping(channelID, userID uuid.UUID, isTyping bool) {
+	h.broadcast <- &ChannelBroadcast{
+		ChannelID:     channelID,
+		ExcludeUserID: &userID,
+		Event: &Event{
+			Type:      EventTyping,
+			ChannelID: &channelID,
+			Payload: map[string]interface{}{
+				"user_id":   userID,
+				"is_typing": isTyping,
+				"ttl_ms":    typingTTL.Milliseconds(),
+			},
+			Timestamp: time.Now(),
+		},
+	}
+}
+
+// CanSubscribe reports whether the client's user may receive events for a
+// channel. Channels and direct messages are both gated on membership. A hub
+// without a repository (as in tests) allows every subscription.
+func (h *Hub) CanSubscribe(client *Client, channelID uuid.UUID) bool {
+	if h.repo == nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), membershipCheckTimeout)
+	defer cancel()
+
+	isMember, err := h.repo.IsMember(ctx, channelID, client.UserID)
+	if err != nil {
+		h.logger.Error("Failed to check channel membership",
+			zap.String("user_id", client.UserID.String()),
+			zap.String("channel_id", channelID.String()),
+			zap.Error(err),
+		)
+		return false
+	}
+	return isMember
+}
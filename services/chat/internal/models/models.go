@@ -15,6 +15,12 @@ const (
 	ChannelTypeDirect  ChannelType = "direct"
 )
 
+// MembersOnly reports whether only members may read a channel of this
+// type. Private channels and direct messages are members only.
+func (t ChannelType) MembersOnly() bool {
+	return t != ChannelTypePublic
+}
+
 // Channel represents a chat channel
 type Channel struct {
 	ID             uuid.UUID   `json:"id" db:"id"`
@@ -33,6 +39,10 @@ type Channel struct {
 	MemberCount    int         `json:"member_count,omitempty" db:"member_count"`
 	LastMessageAt  *time.Time  `json:"last_message_at,omitempty" db:"last_message_at"`
 	UnreadCount    int         `json:"unread_count,omitempty" db:"unread_count"`
+
+	// DirectUser is the other participant of a direct message, with their
+	// presence. It is the user themselves in a direct message to self.
+	DirectUser *User `json:"direct_user,omitempty" db:"-"`
 }
 
 // ChannelMember represents a user's membership in a channel
@@ -46,10 +56,22 @@ type ChannelMember struct {
 	IsMuted       bool       `json:"is_muted" db:"is_muted"`
 	JoinedAt      time.Time  `json:"joined_at" db:"joined_at"`
 
+	// NotificationLevel is the member's notification preference for the channel
+	NotificationLevel NotificationLevel `json:"notification_level" db:"notification_level"`
+
 	// Joined fields
 	User *User `json:"user,omitempty"`
 }
 
+// NotificationLevel is which messages in a channel notify a member
+type NotificationLevel string
+
+const (
+	NotificationLevelAll      NotificationLevel = "all"
+	NotificationLevelMentions NotificationLevel = "mentions" // Including @channel and @here
+	NotificationLevelMuted    NotificationLevel = "muted"
+)
+
 // Message represents a chat message
 type Message struct {
 	ID          uuid.UUID   `json:"id" db:"id"`
@@ -58,19 +80,53 @@ type Message struct {
 	ParentID    *uuid.UUID  `json:"parent_id,omitempty" db:"parent_id"` // For threads
 	Content     string      `json:"content" db:"content"`
 	ContentType string      `json:"content_type" db:"content_type"` // text, markdown, system
+	ContentHTML string      `json:"content_html,omitempty" db:"content_html"` // Rendering of markdown content
 	IsEdited    bool        `json:"is_edited" db:"is_edited"`
 	IsPinned    bool        `json:"is_pinned" db:"is_pinned"`
+	PinPosition *int        `json:"pin_position,omitempty" db:"pin_position"`
+	PinnedBy    *uuid.UUID  `json:"pinned_by,omitempty" db:"pinned_by"`
+	PinnedAt    *time.Time  `json:"pinned_at,omitempty" db:"pinned_at"`
 	IsDeleted   bool        `json:"is_deleted" db:"is_deleted"`
 	Metadata    JSONMap     `json:"metadata,omitempty" db:"metadata"`
 	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
 
+	// Seq is the channel sequence number of the message's latest change: it
+	// is taken when the message is posted and again on every edit or delete
+	Seq int64 `json:"seq,omitempty" db:"seq"`
+
 	// Computed/joined fields
 	User         *User        `json:"user,omitempty"`
 	Attachments  []Attachment `json:"attachments,omitempty"`
 	Reactions    []Reaction   `json:"reactions,omitempty"`
 	ReplyCount   int          `json:"reply_count,omitempty" db:"reply_count"`
 	ThreadUsers  []User       `json:"thread_users,omitempty"`
+
+	// ReactionSummary groups the message's reactions by emoji, most used first
+	ReactionSummary []ReactionSummary `json:"reaction_summary,omitempty"`
+
+	// EditCount is the number of times the content has been edited
+	EditCount int `json:"edit_count" db:"edit_count"`
+
+	// Mentions are the users and groups mentioned in the content
+	Mentions []Mention `json:"mentions,omitempty"`
+}
+
+// MentionType is what a mention refers to
+type MentionType string
+
+const (
+	MentionTypeUser    MentionType = "user"    // @username
+	MentionTypeChannel MentionType = "channel" // @channel, every member
+	MentionTypeHere    MentionType = "here"    // @here, members who are online
+)
+
+// Mention is a user or group mentioned in a message
+type Mention struct {
+	MessageID uuid.UUID   `json:"-" db:"message_id"`
+	Type      MentionType `json:"type" db:"type"`
+	UserID    *uuid.UUID  `json:"user_id,omitempty" db:"user_id"`
+	Username  string      `json:"username,omitempty" db:"username"`
 }
 
 // Attachment represents a file attached to a message
@@ -86,6 +142,19 @@ type Attachment struct {
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
+// FileDetection records an upload the malware scanner rejected
+type FileDetection struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	OrganizationID uuid.UUID `json:"organization_id" db:"organization_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	FileName       string    `json:"file_name" db:"file_name"`
+	FileSize       int64     `json:"file_size" db:"file_size"`
+	ContentType    string    `json:"content_type" db:"content_type"`
+	Scanner        string    `json:"scanner" db:"scanner"`
+	Signature      string    `json:"signature" db:"signature"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
 // Reaction represents an emoji reaction to a message
 type Reaction struct {
 	ID        uuid.UUID `json:"id" db:"id"`
@@ -99,23 +168,73 @@ type Reaction struct {
 	Users []User `json:"users,omitempty"`
 }
 
+// ReactionSummary is the number of reactions to a message with one emoji
+type ReactionSummary struct {
+	MessageID uuid.UUID `json:"-" db:"message_id"`
+	Emoji     string    `json:"emoji" db:"emoji"`
+	Count     int       `json:"count" db:"count"`
+	Reacted   bool      `json:"reacted" db:"reacted"` // The requesting user used this emoji
+
+	// ImageURL is set when Emoji is a registered :custom_name:
+	ImageURL string `json:"image_url,omitempty" db:"-"`
+}
+
+// ReactionUser is a user who reacted to a message with a given emoji
+type ReactionUser struct {
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	DisplayName string    `json:"display_name" db:"display_name"`
+	AvatarURL   string    `json:"avatar_url,omitempty" db:"avatar_url"`
+	ReactedAt   time.Time `json:"reacted_at" db:"reacted_at"`
+}
+
+// CustomEmoji is an image an organization registered for use as :name:
+type CustomEmoji struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	OrganizationID uuid.UUID `json:"organization_id" db:"organization_id"`
+	Name           string    `json:"name" db:"name"`
+	ImageURL       string    `json:"image_url" db:"image_url"`
+	StoragePath    string    `json:"-" db:"storage_path"`
+	ContentType    string    `json:"content_type" db:"content_type"`
+	CreatedBy      uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// MessageEdit records the content a message had before an edit
+type MessageEdit struct {
+	ID              uuid.UUID `json:"id" db:"id"`
+	MessageID       uuid.UUID `json:"message_id" db:"message_id"`
+	PreviousContent string    `json:"previous_content" db:"previous_content"`
+	EditedBy        uuid.UUID `json:"edited_by" db:"edited_by"`
+	EditedAt        time.Time `json:"edited_at" db:"edited_at"`
+}
+
+// MessageVersion is one version of a message's content. Version 1 is the
+// content the message was posted with; EditedBy and EditedAt are the author
+// and creation time for it, and the edit that produced it otherwise.
+type MessageVersion struct {
+	Version  int       `json:"version"`
+	Content  string    `json:"content"`
+	EditedBy uuid.UUID `json:"edited_by"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
 // User represents a user in the chat system
 type User struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	DisplayName  string    `json:"display_name" db:"display_name"`
-	AvatarURL    string    `json:"avatar_url,omitempty" db:"avatar_url"`
-	Status       string    `json:"status" db:"status"` // online, away, dnd, offline
-	StatusText   string    `json:"status_text,omitempty" db:"status_text"`
-	LastSeenAt   time.Time `json:"last_seen_at" db:"last_seen_at"`
+	ID           uuid.UUID  `json:"id" db:"id"`
+	Email        string     `json:"email" db:"email"`
+	DisplayName  string     `json:"display_name" db:"display_name"`
+	AvatarURL    string     `json:"avatar_url,omitempty" db:"avatar_url"`
+	Status       string     `json:"status" db:"status"` // online, away, dnd, offline
+	StatusText   string     `json:"status_text,omitempty" db:"status_text"`
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty" db:"last_seen_at"`
 }
 
 // Presence represents a user's online status
 type Presence struct {
-	UserID     uuid.UUID `json:"user_id"`
-	Status     string    `json:"status"`
-	StatusText string    `json:"status_text,omitempty"`
-	LastSeenAt time.Time `json:"last_seen_at"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Status     string     `json:"status"`
+	StatusText string     `json:"status_text,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
 }
 
 // Thread represents a message thread
@@ -148,9 +267,25 @@ type Notification struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// MessageSearchHit is a message matched by full-text search
+type MessageSearchHit struct {
+	Message
+	ChannelName string  `json:"channel_name" db:"channel_name"`
+	Rank        float64 `json:"rank" db:"rank"`
+	Snippet     string  `json:"snippet" db:"snippet"` // Matched terms wrapped in <mark> tags
+}
+
+// MessageSearchFilter narrows a message search
+type MessageSearchFilter struct {
+	ChannelID *uuid.UUID
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+}
+
 // SearchResult represents a search result
 type SearchResult struct {
-	Messages []Message `json:"messages"`
+	Messages []MessageSearchHit `json:"messages"`
 	Channels []Channel `json:"channels"`
 	Users    []User    `json:"users"`
 	Total    int       `json:"total"`
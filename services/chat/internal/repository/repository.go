@@ -1,20 +1,43 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 
 	"chat/config"
 	"chat/internal/models"
 )
 
+// messageColumns lists the chat_messages columns scanned into models.Message.
+// The search_vector column is internal to full-text search and never selected.
+const messageColumns = `m.id, m.channel_id, m.user_id, m.parent_id, m.content, m.content_type,
+	COALESCE(m.content_html, '') AS content_html,
+	m.is_edited, m.is_pinned, m.pin_position, m.pinned_by, m.pinned_at, m.is_deleted,
+	m.metadata, m.created_at, m.updated_at, m.seq,
+	(SELECT COUNT(*) FROM chat_message_edits e WHERE e.message_id = m.id) AS edit_count`
+
+// ErrCustomEmojiExists is returned when an organization already has a
+// custom emoji with the same name
+var ErrCustomEmojiExists = errors.New("custom emoji already exists")
+
+// ErrPinLimitReached is returned when a channel already has the maximum
+// number of pinned messages
+var ErrPinLimitReached = errors.New("pinned message limit reached")
+
+// ErrPinsChanged is returned when a pin reorder doesn't list exactly the
+// channel's pinned messages
+var ErrPinsChanged = errors.New("pinned messages changed")
+
 // Repository handles data persistence
 type Repository struct {
 	db    *sqlx.DB
@@ -143,7 +166,8 @@ func (r *Repository) ListChannels(ctx context.Context, orgID, userID uuid.UUID,
 	return channels, err
 }
 
-// ListUserChannels lists channels a user is a member of
+// ListUserChannels lists channels a user is a member of, direct messages
+// included. The user's own messages never count as unread.
 func (r *Repository) ListUserChannels(ctx context.Context, userID uuid.UUID) ([]models.Channel, error) {
 	var channels []models.Channel
 	query := `
@@ -152,7 +176,7 @@ func (r *Repository) ListUserChannels(ctx context.Context, userID uuid.UUID) ([]
 			(SELECT MAX(created_at) FROM chat_messages WHERE channel_id = c.id AND is_deleted = false) as last_message_at,
 			COALESCE((
 				SELECT COUNT(*) FROM chat_messages m
-				WHERE m.channel_id = c.id AND m.is_deleted = false
+				WHERE m.channel_id = c.id AND m.is_deleted = false AND m.user_id <> $1
 				AND m.created_at > COALESCE(cm.last_read_at, '1970-01-01')
 			), 0) as unread_count
 		FROM chat_channels c
@@ -257,44 +281,98 @@ func (r *Repository) IsMember(ctx context.Context, channelID, userID uuid.UUID)
 	return count > 0, err
 }
 
-// UpdateLastRead updates the last read timestamp for a member
-func (r *Repository) UpdateLastRead(ctx context.Context, channelID, userID uuid.UUID, messageID *uuid.UUID) error {
+// GetMemberRole returns a member's role in a channel. It returns
+// sql.ErrNoRows if the user is not a member.
+func (r *Repository) GetMemberRole(ctx context.Context, channelID, userID uuid.UUID) (string, error) {
+	var role string
+	query := `SELECT role FROM chat_channel_members WHERE channel_id = $1 AND user_id = $2`
+	err := r.db.GetContext(ctx, &role, query, channelID, userID)
+	return role, err
+}
+
+// GetMemberNotificationLevels returns the notification level of every
+// member of a channel
+func (r *Repository) GetMemberNotificationLevels(ctx context.Context, channelID uuid.UUID) (map[uuid.UUID]models.NotificationLevel, error) {
+	var rows []struct {
+		UserID uuid.UUID                `db:"user_id"`
+		Level  models.NotificationLevel `db:"notification_level"`
+	}
+	query := `SELECT user_id, notification_level FROM chat_channel_members WHERE channel_id = $1`
+	if err := r.db.SelectContext(ctx, &rows, query, channelID); err != nil {
+		return nil, err
+	}
+
+	levels := make(map[uuid.UUID]models.NotificationLevel, len(rows))
+	for _, row := range rows {
+		levels[row.UserID] = row.Level
+	}
+	return levels, nil
+}
+
+// SetNotificationLevel sets a member's notification level for a channel.
+// It returns sql.ErrNoRows if the user is not a member.
+func (r *Repository) SetNotificationLevel(ctx context.Context, channelID, userID uuid.UUID, level models.NotificationLevel) error {
+	query := `
+		UPDATE chat_channel_members
+		SET notification_level = $3, is_muted = $4
+		WHERE channel_id = $1 AND user_id = $2
+	`
+	result, err := r.db.ExecContext(ctx, query, channelID, userID, level, level == models.NotificationLevelMuted)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateLastRead updates the last read timestamp for a member and returns
+// it. It returns sql.ErrNoRows if the user is not a member.
+func (r *Repository) UpdateLastRead(ctx context.Context, channelID, userID uuid.UUID, messageID *uuid.UUID) (time.Time, error) {
 	query := `
 		UPDATE chat_channel_members
 		SET last_read_at = $3, last_read_msg_id = $4
 		WHERE channel_id = $1 AND user_id = $2
 	`
-	_, err := r.db.ExecContext(ctx, query, channelID, userID, time.Now(), messageID)
-	return err
+	readAt := time.Now()
+	result, err := r.db.ExecContext(ctx, query, channelID, userID, readAt, messageID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return readAt, nil
 }
 
 // ============================================================================
 // Message Operations
 // ============================================================================
 
-// CreateMessage creates a new message
+// CreateMessage creates a new message and sets its channel sequence number
 func (r *Repository) CreateMessage(ctx context.Context, message *models.Message) error {
 	query := `
-		INSERT INTO chat_messages (id, channel_id, user_id, parent_id, content, content_type, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO chat_messages (id, channel_id, user_id, parent_id, content, content_type, content_html, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10)
+		RETURNING seq
 	`
 	message.ID = uuid.New()
 	message.CreatedAt = time.Now()
 	message.UpdatedAt = time.Now()
 
-	_, err := r.db.ExecContext(ctx, query,
+	return r.db.QueryRowxContext(ctx, query,
 		message.ID, message.ChannelID, message.UserID, message.ParentID,
-		message.Content, message.ContentType, message.Metadata,
+		message.Content, message.ContentType, message.ContentHTML, message.Metadata,
 		message.CreatedAt, message.UpdatedAt,
-	)
-	return err
+	).Scan(&message.Seq)
 }
 
 // GetMessage retrieves a message by ID
 func (r *Repository) GetMessage(ctx context.Context, messageID uuid.UUID) (*models.Message, error) {
 	var message models.Message
 	query := `
-		SELECT m.*,
+		SELECT ` + messageColumns + `,
 			u.email as "user.email", u.display_name as "user.display_name", u.avatar_url as "user.avatar_url",
 			(SELECT COUNT(*) FROM chat_messages WHERE parent_id = m.id AND is_deleted = false) as reply_count
 		FROM chat_messages m
@@ -315,7 +393,7 @@ func (r *Repository) ListMessages(ctx context.Context, channelID uuid.UUID, limi
 
 	if before == nil {
 		query := `
-			SELECT m.*,
+			SELECT ` + messageColumns + `,
 				u.id as "user.id", u.email as "user.email", u.display_name as "user.display_name", u.avatar_url as "user.avatar_url",
 				(SELECT COUNT(*) FROM chat_messages WHERE parent_id = m.id AND is_deleted = false) as reply_count
 			FROM chat_messages m
@@ -327,7 +405,7 @@ func (r *Repository) ListMessages(ctx context.Context, channelID uuid.UUID, limi
 		err = r.db.SelectContext(ctx, &messages, query, channelID, limit)
 	} else {
 		query := `
-			SELECT m.*,
+			SELECT ` + messageColumns + `,
 				u.id as "user.id", u.email as "user.email", u.display_name as "user.display_name", u.avatar_url as "user.avatar_url",
 				(SELECT COUNT(*) FROM chat_messages WHERE parent_id = m.id AND is_deleted = false) as reply_count
 			FROM chat_messages m
@@ -348,11 +426,41 @@ func (r *Repository) ListMessages(ctx context.Context, channelID uuid.UUID, limi
 	return messages, err
 }
 
+// ListMessagesAfterSeq lists the changes to a channel's messages after a
+// sequence number, in sequence order: new messages and thread replies, edits
+// and deletes. Deleted messages are included without their content so
+// clients can remove them.
+func (r *Repository) ListMessagesAfterSeq(ctx context.Context, channelID uuid.UUID, afterSeq int64, limit int) ([]models.Message, error) {
+	var messages []models.Message
+	query := `
+		SELECT ` + messageColumns + `,
+			u.id as "user.id", u.email as "user.email", u.display_name as "user.display_name", u.avatar_url as "user.avatar_url",
+			(SELECT COUNT(*) FROM chat_messages WHERE parent_id = m.id AND is_deleted = false) as reply_count
+		FROM chat_messages m
+		INNER JOIN users u ON u.id = m.user_id
+		WHERE m.channel_id = $1 AND m.seq > $2
+		ORDER BY m.seq ASC
+		LIMIT $3
+	`
+	if err := r.db.SelectContext(ctx, &messages, query, channelID, afterSeq, limit); err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		if messages[i].IsDeleted {
+			messages[i].Content = ""
+			messages[i].ContentHTML = ""
+			messages[i].Metadata = nil
+		}
+	}
+	return messages, nil
+}
+
 // ListThreadMessages lists messages in a thread
 func (r *Repository) ListThreadMessages(ctx context.Context, parentID uuid.UUID, limit int) ([]models.Message, error) {
 	var messages []models.Message
 	query := `
-		SELECT m.*,
+		SELECT ` + messageColumns + `,
 			u.id as "user.id", u.email as "user.email", u.display_name as "user.display_name", u.avatar_url as "user.avatar_url"
 		FROM chat_messages m
 		INNER JOIN users u ON u.id = m.user_id
@@ -368,38 +476,244 @@ func (r *Repository) ListThreadMessages(ctx context.Context, parentID uuid.UUID,
 func (r *Repository) UpdateMessage(ctx context.Context, message *models.Message) error {
 	query := `
 		UPDATE chat_messages
-		SET content = $2, is_edited = true, updated_at = $3
+		SET content = $2, content_html = NULLIF($4, ''), is_edited = true, updated_at = $3
 		WHERE id = $1
+		RETURNING seq
 	`
 	message.UpdatedAt = time.Now()
-	_, err := r.db.ExecContext(ctx, query, message.ID, message.Content, message.UpdatedAt)
-	return err
+	return r.db.QueryRowxContext(ctx, query, message.ID, message.Content, message.UpdatedAt, message.ContentHTML).Scan(&message.Seq)
+}
+
+// EditMessage replaces a message's content, recording the previous content
+// in its edit history. The history is kept when the message is soft deleted.
+func (r *Repository) EditMessage(ctx context.Context, message *models.Message, editorID uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the row so concurrent edits each record the content they replaced
+	var previous string
+	err = tx.GetContext(ctx, &previous, `SELECT content FROM chat_messages WHERE id = $1 FOR UPDATE`, message.ID)
+	if err != nil {
+		return err
+	}
+
+	message.UpdatedAt = time.Now()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO chat_message_edits (id, message_id, previous_content, edited_by, edited_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, uuid.New(), message.ID, previous, editorID, message.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowxContext(ctx, `
+		UPDATE chat_messages
+		SET content = $2, content_html = NULLIF($4, ''), is_edited = true, updated_at = $3
+		WHERE id = $1
+		RETURNING seq
+	`, message.ID, message.Content, message.UpdatedAt, message.ContentHTML).Scan(&message.Seq)
+	if err != nil {
+		return err
+	}
+
+	err = tx.GetContext(ctx, &message.EditCount, `SELECT COUNT(*) FROM chat_message_edits WHERE message_id = $1`, message.ID)
+	if err != nil {
+		return err
+	}
+
+	message.IsEdited = true
+	return tx.Commit()
 }
 
-// DeleteMessage soft deletes a message
-func (r *Repository) DeleteMessage(ctx context.Context, messageID uuid.UUID) error {
-	query := `UPDATE chat_messages SET is_deleted = true, updated_at = $2 WHERE id = $1`
+// ListMessageEdits lists a message's edits, oldest first
+func (r *Repository) ListMessageEdits(ctx context.Context, messageID uuid.UUID) ([]models.MessageEdit, error) {
+	var edits []models.MessageEdit
+	query := `
+		SELECT id, message_id, previous_content, edited_by, edited_at
+		FROM chat_message_edits
+		WHERE message_id = $1
+		ORDER BY edited_at ASC, id ASC
+	`
+	err := r.db.SelectContext(ctx, &edits, query, messageID)
+	return edits, err
+}
+
+// GetMessageHistory returns every version of a message's content, from the
+// original to the current one
+func (r *Repository) GetMessageHistory(ctx context.Context, message *models.Message) ([]models.MessageVersion, error) {
+	edits, err := r.ListMessageEdits(ctx, message.ID)
+	if err != nil {
+		return nil, err
+	}
+	return messageVersions(message, edits), nil
+}
+
+// messageVersions rebuilds a message's versions from its edits. Each edit
+// holds the content it replaced, so version n+1 is the content replaced by
+// edit n+1, or the current content after the last edit.
+func messageVersions(message *models.Message, edits []models.MessageEdit) []models.MessageVersion {
+	versions := make([]models.MessageVersion, 0, len(edits)+1)
+	editedBy, editedAt := message.UserID, message.CreatedAt
+	for i, edit := range edits {
+		versions = append(versions, models.MessageVersion{
+			Version:  i + 1,
+			Content:  edit.PreviousContent,
+			EditedBy: editedBy,
+			EditedAt: editedAt,
+		})
+		editedBy, editedAt = edit.EditedBy, edit.EditedAt
+	}
+	return append(versions, models.MessageVersion{
+		Version:  len(edits) + 1,
+		Content:  message.Content,
+		EditedBy: editedBy,
+		EditedAt: editedAt,
+	})
+}
+
+// DeleteMessage soft deletes a message and returns the channel sequence
+// number of the delete
+func (r *Repository) DeleteMessage(ctx context.Context, messageID uuid.UUID) (int64, error) {
+	query := `UPDATE chat_messages SET is_deleted = true, updated_at = $2 WHERE id = $1 RETURNING seq`
+	var seq int64
+	err := r.db.QueryRowxContext(ctx, query, messageID, time.Now()).Scan(&seq)
+	return seq, err
+}
+
+// PinMessage pins a message after the channel's other pinned messages.
+// Pinning a pinned message changes nothing. It returns ErrPinLimitReached
+// when the channel already has maxPins pinned messages; 0 means no limit.
+func (r *Repository) PinMessage(ctx context.Context, channelID, messageID, pinnedBy uuid.UUID, maxPins int) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the channel so concurrent pins can't exceed the limit
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM chat_channels WHERE id = $1 FOR UPDATE`, channelID); err != nil {
+		return err
+	}
+
+	var isPinned bool
+	err = tx.GetContext(ctx, &isPinned, `SELECT is_pinned FROM chat_messages WHERE id = $1 AND channel_id = $2`, messageID, channelID)
+	if err != nil {
+		return err
+	}
+	if isPinned {
+		return tx.Commit()
+	}
+
+	var pins struct {
+		Count       int `db:"count"`
+		MaxPosition int `db:"max_position"`
+	}
+	err = tx.GetContext(ctx, &pins, `
+		SELECT COUNT(*) AS count, COALESCE(MAX(pin_position), 0) AS max_position
+		FROM chat_messages
+		WHERE channel_id = $1 AND is_pinned = true AND is_deleted = false
+	`, channelID)
+	if err != nil {
+		return err
+	}
+	if maxPins > 0 && pins.Count >= maxPins {
+		return ErrPinLimitReached
+	}
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE chat_messages
+		SET is_pinned = true, pin_position = $2, pinned_by = $3, pinned_at = $4, updated_at = $4
+		WHERE id = $1
+	`, messageID, pins.MaxPosition+1, pinnedBy, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnpinMessage unpins a message
+func (r *Repository) UnpinMessage(ctx context.Context, messageID uuid.UUID) error {
+	query := `
+		UPDATE chat_messages
+		SET is_pinned = false, pin_position = NULL, pinned_by = NULL, pinned_at = NULL, updated_at = $2
+		WHERE id = $1
+	`
 	_, err := r.db.ExecContext(ctx, query, messageID, time.Now())
 	return err
 }
 
-// PinMessage pins/unpins a message
-func (r *Repository) PinMessage(ctx context.Context, messageID uuid.UUID, isPinned bool) error {
-	query := `UPDATE chat_messages SET is_pinned = $2, updated_at = $3 WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, messageID, isPinned, time.Now())
-	return err
+// ReorderPins sets the order of a channel's pinned messages. messageIDs must
+// be exactly the channel's pinned messages, otherwise ErrPinsChanged is
+// returned and nothing is reordered.
+func (r *Repository) ReorderPins(ctx context.Context, channelID uuid.UUID, messageIDs []uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM chat_channels WHERE id = $1 FOR UPDATE`, channelID); err != nil {
+		return err
+	}
+
+	var pinned []uuid.UUID
+	err = tx.SelectContext(ctx, &pinned, `
+		SELECT id FROM chat_messages
+		WHERE channel_id = $1 AND is_pinned = true AND is_deleted = false
+	`, channelID)
+	if err != nil {
+		return err
+	}
+	if !sameMessageIDs(pinned, messageIDs) {
+		return ErrPinsChanged
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE chat_messages m
+		SET pin_position = p.position
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS p(id, position)
+		WHERE m.id = p.id AND m.channel_id = $1
+	`, channelID, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// sameMessageIDs reports whether a and b hold the same IDs, each once
+func sameMessageIDs(a, b []uuid.UUID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[uuid.UUID]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+	return true
 }
 
-// GetPinnedMessages gets pinned messages for a channel
+// GetPinnedMessages gets pinned messages for a channel in pin order
 func (r *Repository) GetPinnedMessages(ctx context.Context, channelID uuid.UUID) ([]models.Message, error) {
 	var messages []models.Message
 	query := `
-		SELECT m.*,
+		SELECT ` + messageColumns + `,
 			u.id as "user.id", u.email as "user.email", u.display_name as "user.display_name", u.avatar_url as "user.avatar_url"
 		FROM chat_messages m
 		INNER JOIN users u ON u.id = m.user_id
 		WHERE m.channel_id = $1 AND m.is_pinned = true AND m.is_deleted = false
-		ORDER BY m.created_at DESC
+		ORDER BY m.pin_position ASC, m.pinned_at ASC
 	`
 	err := r.db.SelectContext(ctx, &messages, query, channelID)
 	return messages, err
@@ -445,6 +759,247 @@ func (r *Repository) GetMessageReactions(ctx context.Context, messageID uuid.UUI
 	return reactions, err
 }
 
+// GetReactionSummaries aggregates the reactions to each of messageIDs by
+// emoji in a single query. reacted is set on the emojis userID used. Each
+// message's summaries are ordered by count, then by first use.
+func (r *Repository) GetReactionSummaries(ctx context.Context, messageIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]models.ReactionSummary, error) {
+	summaries := make(map[uuid.UUID][]models.ReactionSummary)
+	if len(messageIDs) == 0 {
+		return summaries, nil
+	}
+
+	var rows []models.ReactionSummary
+	query := `
+		SELECT message_id, emoji, COUNT(*) as count, BOOL_OR(user_id = $2) as reacted
+		FROM chat_reactions
+		WHERE message_id = ANY($1)
+		GROUP BY message_id, emoji
+		ORDER BY message_id, count DESC, MIN(created_at) ASC
+	`
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(messageIDs), userID); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		summaries[row.MessageID] = append(summaries[row.MessageID], row)
+	}
+	return summaries, nil
+}
+
+// ListReactionUsers lists the users who reacted to a message with emoji,
+// in the order they reacted
+func (r *Repository) ListReactionUsers(ctx context.Context, messageID uuid.UUID, emoji string, limit, offset int) ([]models.ReactionUser, error) {
+	var users []models.ReactionUser
+	query := `
+		SELECT u.id as user_id, u.display_name, COALESCE(u.avatar_url, '') as avatar_url, cr.created_at as reacted_at
+		FROM chat_reactions cr
+		INNER JOIN users u ON u.id = cr.user_id
+		WHERE cr.message_id = $1 AND cr.emoji = $2
+		ORDER BY cr.created_at ASC, cr.id ASC
+		LIMIT $3 OFFSET $4
+	`
+	err := r.db.SelectContext(ctx, &users, query, messageID, emoji, limit, offset)
+	return users, err
+}
+
+// ============================================================================
+// Custom Emoji Operations
+// ============================================================================
+
+// CreateCustomEmoji registers a custom emoji for an organization
+func (r *Repository) CreateCustomEmoji(ctx context.Context, emoji *models.CustomEmoji) error {
+	query := `
+		INSERT INTO chat_custom_emojis (id, organization_id, name, image_url, storage_path, content_type, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (organization_id, name) DO NOTHING
+	`
+	emoji.ID = uuid.New()
+	emoji.CreatedAt = time.Now()
+
+	result, err := r.db.ExecContext(ctx, query,
+		emoji.ID, emoji.OrganizationID, emoji.Name, emoji.ImageURL, emoji.StoragePath,
+		emoji.ContentType, emoji.CreatedBy, emoji.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrCustomEmojiExists
+	}
+	return nil
+}
+
+// RecordFileDetection stores a malware detection in an uploaded file
+func (r *Repository) RecordFileDetection(ctx context.Context, d *models.FileDetection) error {
+	query := `
+		INSERT INTO chat_file_detections (id, organization_id, user_id, file_name, file_size, content_type, scanner, signature, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	d.ID = uuid.New()
+	d.CreatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		d.ID, d.OrganizationID, d.UserID, d.FileName, d.FileSize,
+		d.ContentType, d.Scanner, d.Signature, d.CreatedAt,
+	)
+	return err
+}
+
+// ListCustomEmojis lists an organization's custom emoji by name
+func (r *Repository) ListCustomEmojis(ctx context.Context, orgID uuid.UUID) ([]models.CustomEmoji, error) {
+	var emojis []models.CustomEmoji
+	query := `
+		SELECT id, organization_id, name, image_url, storage_path, content_type, created_by, created_at
+		FROM chat_custom_emojis
+		WHERE organization_id = $1
+		ORDER BY name
+	`
+	err := r.db.SelectContext(ctx, &emojis, query, orgID)
+	return emojis, err
+}
+
+// GetCustomEmojiURLs returns the image URL of each of names registered by
+// the organization. Unregistered names are missing from the map.
+func (r *Repository) GetCustomEmojiURLs(ctx context.Context, orgID uuid.UUID, names []string) (map[string]string, error) {
+	urls := make(map[string]string)
+	if len(names) == 0 {
+		return urls, nil
+	}
+
+	var rows []struct {
+		Name     string `db:"name"`
+		ImageURL string `db:"image_url"`
+	}
+	query := `SELECT name, image_url FROM chat_custom_emojis WHERE organization_id = $1 AND name = ANY($2)`
+	if err := r.db.SelectContext(ctx, &rows, query, orgID, pq.Array(names)); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		urls[row.Name] = row.ImageURL
+	}
+	return urls, nil
+}
+
+// ============================================================================
+// Mention Operations
+// ============================================================================
+
+// ResolveUsernames maps each of usernames to the organization member whose
+// email address has that local part, ignoring case. Names that match no
+// member, or more than one, are missing from the map.
+func (r *Repository) ResolveUsernames(ctx context.Context, orgID uuid.UUID, usernames []string) (map[string]uuid.UUID, error) {
+	ids := make(map[string]uuid.UUID)
+	if len(usernames) == 0 {
+		return ids, nil
+	}
+
+	var rows []struct {
+		Username string    `db:"username"`
+		UserID   uuid.UUID `db:"user_id"`
+	}
+	query := `
+		SELECT LOWER(split_part(u.email, '@', 1)) as username, u.id as user_id
+		FROM users u
+		INNER JOIN organization_members om ON om.user_id = u.id
+		WHERE om.organization_id = $1 AND LOWER(split_part(u.email, '@', 1)) = ANY($2)
+	`
+	if err := r.db.SelectContext(ctx, &rows, query, orgID, pq.Array(usernames)); err != nil {
+		return nil, err
+	}
+
+	ambiguous := make(map[string]bool)
+	for _, row := range rows {
+		if _, ok := ids[row.Username]; ok {
+			ambiguous[row.Username] = true
+		}
+		ids[row.Username] = row.UserID
+	}
+	for name := range ambiguous {
+		delete(ids, name)
+	}
+	return ids, nil
+}
+
+// CreateMentions stores the mentions in a message
+func (r *Repository) CreateMentions(ctx context.Context, messageID uuid.UUID, mentions []models.Mention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO chat_message_mentions (id, message_id, user_id, type, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING
+	`
+	now := time.Now()
+	for _, m := range mentions {
+		if _, err := tx.ExecContext(ctx, query, uuid.New(), messageID, m.UserID, m.Type, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetMentions returns the mentions in each of messageIDs in a single query
+func (r *Repository) GetMentions(ctx context.Context, messageIDs []uuid.UUID) (map[uuid.UUID][]models.Mention, error) {
+	mentions := make(map[uuid.UUID][]models.Mention)
+	if len(messageIDs) == 0 {
+		return mentions, nil
+	}
+
+	var rows []models.Mention
+	query := `
+		SELECT mm.message_id, mm.type, mm.user_id,
+			COALESCE(LOWER(split_part(u.email, '@', 1)), mm.type) as username
+		FROM chat_message_mentions mm
+		LEFT JOIN users u ON u.id = mm.user_id
+		WHERE mm.message_id = ANY($1)
+		ORDER BY mm.message_id, mm.created_at, mm.id
+	`
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(messageIDs)); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		mentions[row.MessageID] = append(mentions[row.MessageID], row)
+	}
+	return mentions, nil
+}
+
+// CreateNotifications stores notifications for users to pick up later
+func (r *Repository) CreateNotifications(ctx context.Context, notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO chat_notifications (id, user_id, type, channel_id, message_id, content, is_read, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, false, $7)
+	`
+	for i := range notifications {
+		n := &notifications[i]
+		n.ID = uuid.New()
+		n.CreatedAt = time.Now()
+		if _, err := tx.ExecContext(ctx, query, n.ID, n.UserID, n.Type, n.ChannelID, n.MessageID, n.Content, n.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // ============================================================================
 // Attachment Operations
 // ============================================================================
@@ -478,25 +1033,24 @@ func (r *Repository) GetMessageAttachments(ctx context.Context, messageID uuid.U
 // Direct Message Operations
 // ============================================================================
 
-// GetOrCreateDirectChannel gets or creates a direct message channel between users
+// GetOrCreateDirectChannel gets or creates a direct message channel between
+// two users. Both may be the same user, for a direct message to self.
 func (r *Repository) GetOrCreateDirectChannel(ctx context.Context, orgID uuid.UUID, userIDs []uuid.UUID) (*models.Channel, error) {
-	// Sort user IDs to ensure consistent channel lookup
 	if len(userIDs) != 2 {
 		return nil, fmt.Errorf("direct messages require exactly 2 users")
 	}
+	members := directMembers(userIDs)
 
-	// Try to find existing channel
+	// Try to find existing channel with exactly these members
 	query := `
 		SELECT c.* FROM chat_channels c
 		WHERE c.organization_id = $1 AND c.type = 'direct'
-		AND EXISTS (SELECT 1 FROM chat_channel_members WHERE channel_id = c.id AND user_id = $2)
-		AND EXISTS (SELECT 1 FROM chat_channel_members WHERE channel_id = c.id AND user_id = $3)
-		AND (SELECT COUNT(*) FROM chat_channel_members WHERE channel_id = c.id) = 2
+		AND ARRAY(SELECT user_id FROM chat_channel_members WHERE channel_id = c.id ORDER BY user_id) = $2::uuid[]
 		LIMIT 1
 	`
 
 	var channel models.Channel
-	err := r.db.GetContext(ctx, &channel, query, orgID, userIDs[0], userIDs[1])
+	err := r.db.GetContext(ctx, &channel, query, orgID, pq.Array(members))
 	if err == nil {
 		return &channel, nil
 	}
@@ -533,8 +1087,8 @@ func (r *Repository) GetOrCreateDirectChannel(ctx context.Context, orgID uuid.UU
 		return nil, err
 	}
 
-	// Add both users as members
-	for _, userID := range userIDs {
+	// Add the users as members, once each
+	for _, userID := range members {
 		_, err = tx.ExecContext(ctx, `
 			INSERT INTO chat_channel_members (id, channel_id, user_id, role, joined_at)
 			VALUES ($1, $2, $3, 'member', $4)
@@ -551,31 +1105,110 @@ func (r *Repository) GetOrCreateDirectChannel(ctx context.Context, orgID uuid.UU
 	return &channel, nil
 }
 
+// directMembers returns the distinct users of a direct message, sorted as
+// PostgreSQL sorts UUIDs
+func directMembers(userIDs []uuid.UUID) []uuid.UUID {
+	members := make([]uuid.UUID, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !slices.Contains(members, id) {
+			members = append(members, id)
+		}
+	}
+	slices.SortFunc(members, func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	return members
+}
+
+// ListDirectChannels lists a user's direct messages in an organization, most
+// recent message first. Conversations without messages come last, newest
+// first.
+func (r *Repository) ListDirectChannels(ctx context.Context, orgID, userID uuid.UUID) ([]models.Channel, error) {
+	var channels []models.Channel
+	query := `
+		SELECT c.*,
+			(SELECT COUNT(*) FROM chat_channel_members WHERE channel_id = c.id) as member_count,
+			(SELECT MAX(created_at) FROM chat_messages WHERE channel_id = c.id AND is_deleted = false) as last_message_at,
+			COALESCE((
+				SELECT COUNT(*) FROM chat_messages m
+				WHERE m.channel_id = c.id AND m.is_deleted = false AND m.user_id <> $2
+				AND m.created_at > COALESCE(cm.last_read_at, '1970-01-01')
+			), 0) as unread_count
+		FROM chat_channels c
+		INNER JOIN chat_channel_members cm ON cm.channel_id = c.id AND cm.user_id = $2
+		WHERE c.organization_id = $1 AND c.type = 'direct' AND c.is_archived = false
+		ORDER BY last_message_at DESC NULLS LAST, c.created_at DESC
+	`
+	err := r.db.SelectContext(ctx, &channels, query, orgID, userID)
+	return channels, err
+}
+
+// GetDirectPartners returns the other participant of each of the given
+// direct channels, keyed by channel ID. In a direct message to self the
+// user is their own partner.
+func (r *Repository) GetDirectPartners(ctx context.Context, userID uuid.UUID, channelIDs []uuid.UUID) (map[uuid.UUID]models.User, error) {
+	partners := make(map[uuid.UUID]models.User, len(channelIDs))
+	if len(channelIDs) == 0 {
+		return partners, nil
+	}
+
+	var rows []struct {
+		ChannelID uuid.UUID `db:"channel_id"`
+		models.User
+	}
+	query := `
+		SELECT DISTINCT ON (cm.channel_id) cm.channel_id,
+			u.id, u.email, u.display_name, u.avatar_url, u.status, u.status_text, u.last_seen_at
+		FROM chat_channel_members cm
+		INNER JOIN users u ON u.id = cm.user_id
+		WHERE cm.channel_id = ANY($1)
+		ORDER BY cm.channel_id, cm.user_id = $2, cm.joined_at
+	`
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(channelIDs), userID); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		partners[row.ChannelID] = row.User
+	}
+	return partners, nil
+}
+
 // ============================================================================
 // Search Operations
 // ============================================================================
 
-// SearchMessages searches messages
-func (r *Repository) SearchMessages(ctx context.Context, orgID, userID uuid.UUID, query string, limit int) ([]models.Message, error) {
-	var messages []models.Message
+// SearchMessages runs a full-text search over messages the user can see,
+// ranked by relevance. Messages in private and direct channels are only
+// returned to channel members.
+func (r *Repository) SearchMessages(ctx context.Context, orgID, userID uuid.UUID, query string, filter models.MessageSearchFilter) ([]models.MessageSearchHit, error) {
+	var hits []models.MessageSearchHit
 	sqlQuery := `
-		SELECT m.*,
+		SELECT ` + messageColumns + `,
 			u.id as "user.id", u.email as "user.email", u.display_name as "user.display_name",
-			c.name as channel_name
+			c.name as channel_name,
+			ts_rank(m.search_vector, q) as rank,
+			ts_headline('english', m.content, q,
+				'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2') as snippet
 		FROM chat_messages m
 		INNER JOIN users u ON u.id = m.user_id
 		INNER JOIN chat_channels c ON c.id = m.channel_id
+		CROSS JOIN websearch_to_tsquery('english', $2) q
 		WHERE c.organization_id = $1
 		AND m.is_deleted = false
-		AND m.content ILIKE '%' || $2 || '%'
+		AND m.search_vector @@ q
+		AND ($4::uuid IS NULL OR m.channel_id = $4)
+		AND ($5::timestamptz IS NULL OR m.created_at >= $5)
+		AND ($6::timestamptz IS NULL OR m.created_at <= $6)
 		AND (c.type = 'public' OR EXISTS (
 			SELECT 1 FROM chat_channel_members WHERE channel_id = c.id AND user_id = $3
 		))
-		ORDER BY m.created_at DESC
-		LIMIT $4
+		ORDER BY rank DESC, m.created_at DESC
+		LIMIT $7
 	`
-	err := r.db.SelectContext(ctx, &messages, sqlQuery, orgID, query, userID, limit)
-	return messages, err
+	err := r.db.SelectContext(ctx, &hits, sqlQuery, orgID, query, userID,
+		filter.ChannelID, filter.From, filter.To, filter.Limit)
+	return hits, err
 }
 
 // ============================================================================
@@ -600,6 +1233,22 @@ func (r *Repository) UpdateUserStatus(ctx context.Context, userID uuid.UUID, sta
 	return err
 }
 
+// UpdateUsersLastSeen records that the given users were last connected at
+// seenAt. Timestamps only move forward, so a delayed write can't overwrite a
+// more recent one.
+func (r *Repository) UpdateUsersLastSeen(ctx context.Context, userIDs []uuid.UUID, seenAt time.Time) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	query := `
+		UPDATE users
+		SET last_seen_at = GREATEST(COALESCE(last_seen_at, $2), $2)
+		WHERE id = ANY($1)
+	`
+	_, err := r.db.ExecContext(ctx, query, pq.Array(userIDs), seenAt)
+	return err
+}
+
 // GetOrganizationUsers gets all users in an organization
 func (r *Repository) GetOrganizationUsers(ctx context.Context, orgID uuid.UUID) ([]models.User, error) {
 	var users []models.User
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -189,8 +190,13 @@ func TestChannelMemberOperations(t *testing.T) {
 		require.NoError(t, err)
 
 		messageID := uuid.New()
-		err = repo.UpdateLastRead(ctx, channel.ID, userID, &messageID)
+		readAt, err := repo.UpdateLastRead(ctx, channel.ID, userID, &messageID)
 		require.NoError(t, err)
+		assert.False(t, readAt.IsZero())
+
+		// Not a member
+		_, err = repo.UpdateLastRead(ctx, channel.ID, uuid.New(), &messageID)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
 	})
 }
 
@@ -284,6 +290,38 @@ func TestMessageOperations(t *testing.T) {
 		assert.True(t, retrieved.IsEdited)
 	})
 
+	t.Run("EditMessageHistory", func(t *testing.T) {
+		message := &models.Message{
+			ChannelID:   channel.ID,
+			UserID:      userID,
+			Content:     "First",
+			ContentType: "text",
+		}
+		err := repo.CreateMessage(ctx, message)
+		require.NoError(t, err)
+
+		for _, content := range []string{"Second", "Third"} {
+			message.Content = content
+			err = repo.EditMessage(ctx, message, userID)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, message.EditCount)
+
+		_, err = repo.DeleteMessage(ctx, message.ID)
+		require.NoError(t, err)
+
+		retrieved, err := repo.GetMessage(ctx, message.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, retrieved.EditCount)
+
+		versions, err := repo.GetMessageHistory(ctx, retrieved)
+		require.NoError(t, err)
+		require.Len(t, versions, 3)
+		assert.Equal(t, "First", versions[0].Content)
+		assert.Equal(t, "Second", versions[1].Content)
+		assert.Equal(t, "Third", versions[2].Content)
+	})
+
 	t.Run("DeleteMessage", func(t *testing.T) {
 		message := &models.Message{
 			ChannelID:   channel.ID,
@@ -294,7 +332,7 @@ func TestMessageOperations(t *testing.T) {
 		err := repo.CreateMessage(ctx, message)
 		require.NoError(t, err)
 
-		err = repo.DeleteMessage(ctx, message.ID)
+		_, err = repo.DeleteMessage(ctx, message.ID)
 		require.NoError(t, err)
 
 		retrieved, err := repo.GetMessage(ctx, message.ID)
@@ -312,7 +350,7 @@ func TestMessageOperations(t *testing.T) {
 		err := repo.CreateMessage(ctx, message)
 		require.NoError(t, err)
 
-		err = repo.PinMessage(ctx, message.ID, true)
+		err = repo.PinMessage(ctx, channel.ID, message.ID, userID, 0)
 		require.NoError(t, err)
 
 		pinned, err := repo.GetPinnedMessages(ctx, channel.ID)
@@ -409,6 +447,29 @@ func TestReactionOperations(t *testing.T) {
 		assert.GreaterOrEqual(t, len(reactions), 1)
 	})
 
+	t.Run("GetReactionSummaries", func(t *testing.T) {
+		summaries, err := repo.GetReactionSummaries(ctx, []uuid.UUID{message.ID}, userID)
+		require.NoError(t, err)
+		require.Len(t, summaries[message.ID], 1)
+		assert.Equal(t, "👍", summaries[message.ID][0].Emoji)
+		assert.Equal(t, 1, summaries[message.ID][0].Count)
+		assert.True(t, summaries[message.ID][0].Reacted)
+
+		summaries, err = repo.GetReactionSummaries(ctx, []uuid.UUID{message.ID}, uuid.New())
+		require.NoError(t, err)
+		assert.False(t, summaries[message.ID][0].Reacted)
+	})
+
+	t.Run("ListReactionUsers", func(t *testing.T) {
+		users, err := repo.ListReactionUsers(ctx, message.ID, "👍", 10, 0)
+		require.NoError(t, err)
+		assert.Len(t, users, 1)
+
+		users, err = repo.ListReactionUsers(ctx, message.ID, "👍", 10, 1)
+		require.NoError(t, err)
+		assert.Empty(t, users)
+	})
+
 	t.Run("RemoveReaction", func(t *testing.T) {
 		err := repo.RemoveReaction(ctx, message.ID, userID, "👍")
 		require.NoError(t, err)
@@ -441,6 +502,23 @@ func TestDirectMessageOperations(t *testing.T) {
 		assert.Equal(t, channel.ID, channel2.ID)
 	})
 
+	t.Run("DirectMessageToSelf", func(t *testing.T) {
+		channel, err := repo.GetOrCreateDirectChannel(ctx, orgID, []uuid.UUID{user1ID, user1ID})
+		require.NoError(t, err)
+
+		again, err := repo.GetOrCreateDirectChannel(ctx, orgID, []uuid.UUID{user1ID, user1ID})
+		require.NoError(t, err)
+		assert.Equal(t, channel.ID, again.ID)
+
+		withOther, err := repo.GetOrCreateDirectChannel(ctx, orgID, []uuid.UUID{user1ID, user2ID})
+		require.NoError(t, err)
+		assert.NotEqual(t, channel.ID, withOther.ID)
+
+		isMember, err := repo.IsMember(ctx, channel.ID, user2ID)
+		require.NoError(t, err)
+		assert.False(t, isMember)
+	})
+
 	t.Run("InvalidDirectMessage", func(t *testing.T) {
 		// Should fail with only one user
 		_, err := repo.GetOrCreateDirectChannel(ctx, orgID, []uuid.UUID{user1ID})
@@ -448,6 +526,15 @@ func TestDirectMessageOperations(t *testing.T) {
 	})
 }
 
+func TestDirectMembers(t *testing.T) {
+	a := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	b := uuid.MustParse("ff000000-0000-0000-0000-000000000000")
+
+	assert.Equal(t, []uuid.UUID{a, b}, directMembers([]uuid.UUID{b, a}))
+	assert.Equal(t, []uuid.UUID{a, b}, directMembers([]uuid.UUID{a, b}))
+	assert.Equal(t, []uuid.UUID{a}, directMembers([]uuid.UUID{a, a}))
+}
+
 func TestSearchOperations(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test")
@@ -490,7 +577,7 @@ func TestSearchOperations(t *testing.T) {
 	}
 
 	t.Run("SearchMessages", func(t *testing.T) {
-		results, err := repo.SearchMessages(ctx, orgID, userID, "test", 10)
+		results, err := repo.SearchMessages(ctx, orgID, userID, "test", models.MessageSearchFilter{Limit: 10})
 		require.NoError(t, err)
 		assert.GreaterOrEqual(t, len(results), 2) // "Testing" and "test"
 	})
@@ -518,6 +605,36 @@ func TestPresenceOperations(t *testing.T) {
 }
 
 // Helper function to setup test repository
+func TestMessageVersions(t *testing.T) {
+	author, editor := uuid.New(), uuid.New()
+	created := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	message := &models.Message{
+		ID:        uuid.New(),
+		UserID:    author,
+		Content:   "third",
+		CreatedAt: created,
+	}
+
+	t.Run("Unedited", func(t *testing.T) {
+		versions := messageVersions(message, nil)
+		require.Len(t, versions, 1)
+		assert.Equal(t, models.MessageVersion{Version: 1, Content: "third", EditedBy: author, EditedAt: created}, versions[0])
+	})
+
+	t.Run("Edited", func(t *testing.T) {
+		edits := []models.MessageEdit{
+			{MessageID: message.ID, PreviousContent: "first", EditedBy: author, EditedAt: created.Add(time.Minute)},
+			{MessageID: message.ID, PreviousContent: "second", EditedBy: editor, EditedAt: created.Add(time.Hour)},
+		}
+
+		versions := messageVersions(message, edits)
+		require.Len(t, versions, 3)
+		assert.Equal(t, models.MessageVersion{Version: 1, Content: "first", EditedBy: author, EditedAt: created}, versions[0])
+		assert.Equal(t, models.MessageVersion{Version: 2, Content: "second", EditedBy: author, EditedAt: created.Add(time.Minute)}, versions[1])
+		assert.Equal(t, models.MessageVersion{Version: 3, Content: "third", EditedBy: editor, EditedAt: created.Add(time.Hour)}, versions[2])
+	})
+}
+
 func setupTestRepo(t *testing.T) *Repository {
 	// In real tests, use a test database or mock
 	// This is a placeholder
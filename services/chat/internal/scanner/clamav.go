@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// clamdChunkSize is the size of each INSTREAM chunk sent to clamd
+const clamdChunkSize = 64 * 1024
+
+// ClamAV scans with a clamd daemon using its INSTREAM command, which takes
+// the file in length-prefixed chunks, so only one chunk is in memory at a
+// time. clamd's StreamMaxLength must allow the largest accepted file.
+type ClamAV struct {
+	network string
+	addr    string
+	dialer  net.Dialer
+}
+
+// NewClamAV returns a scanner for the clamd at addr, given as
+// "tcp://host:port" or "unix:///path/to/clamd.sock"
+func NewClamAV(addr string) (*ClamAV, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("clamav address: %w", err)
+	}
+	switch u.Scheme {
+	case "tcp":
+		return &ClamAV{network: "tcp", addr: u.Host}, nil
+	case "unix":
+		return &ClamAV{network: "unix", addr: u.Path}, nil
+	}
+	return nil, fmt.Errorf("clamav address %q: scheme must be tcp or unix", addr)
+}
+
+// Name identifies the scanner in logs and detection records
+func (c *ClamAV) Name() string {
+	return "clamav"
+}
+
+// Scan streams r to clamd and returns its verdict
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	conn, err := c.dialer.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return Result{}, fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Unblock reads and writes if the context is cancelled early
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("send to clamd: %w", err)
+	}
+
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd closes the connection once the stream passes its
+				// size limit; its reply says so
+				break
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, fmt.Errorf("read attachment: %w", readErr)
+		}
+	}
+	conn.Write([]byte{0, 0, 0, 0})
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Result{}, fmt.Errorf("read clamd reply: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply interprets "stream: OK", "stream: <name> FOUND" and
+// "<message> ERROR" replies
+func parseClamdReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream:")
+	reply = strings.TrimSpace(reply)
+
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return Result{}, fmt.Errorf("clamd: %s", strings.TrimSuffix(reply, " ERROR"))
+	}
+	return Result{}, fmt.Errorf("clamd: unexpected reply %q", reply)
+}
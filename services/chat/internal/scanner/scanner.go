@@ -0,0 +1,103 @@
+// Package scanner checks uploaded files for malware before they are stored.
+// Scanners read the content as a stream, so large files are never held
+// in memory whole. ClamAV is built in; other engines, such as a cloud
+// scanning API, plug in by implementing Scanner.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"chat/config"
+)
+
+// ErrUnavailable is returned by a fail-closed Guard when the scanner can't
+// give a verdict, such as on a timeout
+var ErrUnavailable = errors.New("file scanner unavailable")
+
+// Result is a scanner's verdict on one file
+type Result struct {
+	Infected  bool
+	Signature string // Name of the detected malware
+	// Unscanned is set when a fail-open Guard let the file through because
+	// the scanner failed; Err says why
+	Unscanned bool
+	Err       error
+}
+
+// Scanner scans a stream of file content
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+	Name() string
+}
+
+// New returns the configured scanner wrapped in a Guard, or nil when
+// scanning is disabled
+func New(cfg *config.ScanningConfig) (*Guard, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var s Scanner
+	switch cfg.Provider {
+	case "", "clamav":
+		if cfg.ClamAVAddr == "" {
+			return nil, errors.New("scanning: clamavAddr is required")
+		}
+		clam, err := NewClamAV(cfg.ClamAVAddr)
+		if err != nil {
+			return nil, err
+		}
+		s = clam
+	default:
+		return nil, fmt.Errorf("scanning: unknown provider %q", cfg.Provider)
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return NewGuard(s, timeout, cfg.FailOpen), nil
+}
+
+// Guard applies a timeout to a scanner and decides what happens when the
+// scanner fails: fail-open treats the file as clean, fail-closed returns
+// ErrUnavailable.
+type Guard struct {
+	scanner  Scanner
+	timeout  time.Duration
+	failOpen bool
+}
+
+// NewGuard wraps s with a per-scan timeout and failure policy
+func NewGuard(s Scanner, timeout time.Duration, failOpen bool) *Guard {
+	return &Guard{scanner: s, timeout: timeout, failOpen: failOpen}
+}
+
+// Scan scans r within the Guard's timeout. When the scanner fails, a
+// fail-open Guard returns an Unscanned result and a fail-closed one returns
+// ErrUnavailable.
+func (g *Guard) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	result, err := g.scanner.Scan(ctx, r)
+	if err == nil {
+		return result, nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("%s scan timed out after %s: %w", g.scanner.Name(), g.timeout, err)
+	}
+	if g.failOpen {
+		return Result{Unscanned: true, Err: err}, nil
+	}
+	return Result{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
+
+// Name is the wrapped scanner's name
+func (g *Guard) Name() string {
+	return g.scanner.Name()
+}
@@ -0,0 +1,140 @@
+// Package token verifies the access tokens issued by the auth service. It
+// follows the auth service's token package: the same signing methods,
+// registered claims and errors, so chat accepts exactly the tokens the auth
+// service would. Services are separate modules, so the package can't be
+// imported from there.
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"chat/config"
+)
+
+// Common errors. ErrExpiredToken tells the client to refresh its token;
+// anything else wraps ErrInvalidToken.
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+)
+
+// Clock skew tolerated between the auth service and chat
+const leeway = 30 * time.Second
+
+// Claims are the identity claims of a verified access token
+type Claims struct {
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID
+	Email          string
+	Role           string
+	ExpiresAt      time.Time
+}
+
+// Verifier checks the signature, expiry, issuer and audience of tokens
+type Verifier struct {
+	keys       map[string][]byte // Signing keys by kid
+	defaultKey []byte            // For tokens without a kid
+	parser     *jwt.Parser
+}
+
+// NewVerifier creates a verifier for the signing keys, issuer and audience
+// in the auth config. Tokens with a kid header are checked with that key
+// from SigningKeys; tokens without one with JWTSecret.
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	v := &Verifier{keys: make(map[string][]byte, len(cfg.SigningKeys))}
+	if cfg.JWTSecret != "" {
+		v.defaultKey = []byte(cfg.JWTSecret)
+	}
+	for kid, secret := range cfg.SigningKeys {
+		if secret == "" {
+			return nil, fmt.Errorf("signing key %q is empty", kid)
+		}
+		v.keys[kid] = []byte(secret)
+	}
+	if v.defaultKey == nil && len(v.keys) == 0 {
+		return nil, errors.New("no JWT signing keys configured")
+	}
+
+	opts := []jwt.ParserOption{
+		// Only HMAC, as issued by the auth service; this also rules out
+		// alg=none
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+// Verify validates a token and returns its claims. The user and
+// organization IDs are read from "sub" and "org_id", as the auth service
+// issues them, or from "user_id" and "organization_id".
+func (v *Verifier) Verify(tokenString string) (*Claims, error) {
+	var claims jwt.MapClaims
+	if _, err := v.parser.ParseWithClaims(tokenString, &claims, v.signingKey); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	userID, err := uuid.Parse(stringClaim(claims, "sub", "user_id"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing or invalid user id", ErrInvalidToken)
+	}
+	orgID, err := uuid.Parse(stringClaim(claims, "org_id", "organization_id"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing or invalid organization id", ErrInvalidToken)
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, fmt.Errorf("%w: invalid expiry", ErrInvalidToken)
+	}
+
+	return &Claims{
+		UserID:         userID,
+		OrganizationID: orgID,
+		Email:          stringClaim(claims, "email"),
+		Role:           stringClaim(claims, "role"),
+		ExpiresAt:      exp.Time,
+	}, nil
+}
+
+// signingKey selects the key a token was signed with by its kid header
+func (v *Verifier) signingKey(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if v.defaultKey == nil {
+			return nil, errors.New("token has no kid")
+		}
+		return v.defaultKey, nil
+	}
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// stringClaim returns the first of the named claims that is a string
+func stringClaim(claims jwt.MapClaims, names ...string) string {
+	for _, name := range names {
+		if s, ok := claims[name].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"chat/config"
+)
+
+var (
+	testUserID = uuid.MustParse("6f1c2b7e-2d4a-4c1e-9a57-3f0b8d1e5c21")
+	testOrgID  = uuid.MustParse("0d9e4f3a-8b6c-4e2d-a1f7-5c3b9e8d7a64")
+)
+
+func testVerifier(t *testing.T) *Verifier {
+	t.Helper()
+	v, err := NewVerifier(config.AuthConfig{
+		JWTSecret:   "current-secret",
+		Issuer:      "auth-service",
+		Audience:    "email-platform",
+		SigningKeys: map[string]string{"2026-10": "rotated-secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	return v
+}
+
+// validClaims are the claims the auth service puts in an access token
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":    testUserID.String(),
+		"org_id": testOrgID.String(),
+		"email":  "user@example.com",
+		"role":   "member",
+		"iss":    "auth-service",
+		"aud":    []string{"email-platform"},
+		"iat":    now.Unix(),
+		"exp":    now.Add(15 * time.Minute).Unix(),
+	}
+}
+
+func sign(t *testing.T, claims jwt.MapClaims, kid, secret string) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return s
+}
+
+func TestVerify(t *testing.T) {
+	v := testVerifier(t)
+	claims := validClaims()
+
+	got, err := v.Verify(sign(t, claims, "", "current-secret"))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got.UserID != testUserID || got.OrganizationID != testOrgID || got.Email != "user@example.com" || got.Role != "member" {
+		t.Errorf("Verify() = %+v", got)
+	}
+	if got.ExpiresAt.Unix() != claims["exp"].(int64) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt.Unix(), claims["exp"])
+	}
+
+	// Older tokens name the IDs differently
+	delete(claims, "sub")
+	delete(claims, "org_id")
+	claims["user_id"] = testUserID.String()
+	claims["organization_id"] = testOrgID.String()
+	if _, err := v.Verify(sign(t, claims, "", "current-secret")); err != nil {
+		t.Errorf("Verify() with user_id and organization_id error = %v", err)
+	}
+}
+
+func TestVerify_KeyRotation(t *testing.T) {
+	v := testVerifier(t)
+
+	if _, err := v.Verify(sign(t, validClaims(), "2026-10", "rotated-secret")); err != nil {
+		t.Errorf("Verify() with known kid error = %v", err)
+	}
+	if _, err := v.Verify(sign(t, validClaims(), "2026-10", "current-secret")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() with the wrong key for its kid error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := v.Verify(sign(t, validClaims(), "2025-01", "rotated-secret")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() with unknown kid error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	v := testVerifier(t)
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	_, err := v.Verify(sign(t, claims, "", "current-secret"))
+	if !errors.Is(err, ErrExpiredToken) || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() error = %v, want only ErrExpiredToken", err)
+	}
+
+	// Within the tolerated clock skew
+	claims["exp"] = time.Now().Add(-leeway / 2).Unix()
+	if _, err := v.Verify(sign(t, claims, "", "current-secret")); err != nil {
+		t.Errorf("Verify() within leeway error = %v", err)
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	v := testVerifier(t)
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		secret string
+	}{
+		{"wrong secret", func(jwt.MapClaims) {}, "other-secret"},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "someone-else" }, "current-secret"},
+		{"missing issuer", func(c jwt.MapClaims) { delete(c, "iss") }, "current-secret"},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = []string{"other-app"} }, "current-secret"},
+		{"missing expiry", func(c jwt.MapClaims) { delete(c, "exp") }, "current-secret"},
+		{"not yet valid", func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, "current-secret"},
+		{"missing user", func(c jwt.MapClaims) { delete(c, "sub") }, "current-secret"},
+		{"invalid organization", func(c jwt.MapClaims) { c["org_id"] = "acme" }, "current-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(claims)
+			if _, err := v.Verify(sign(t, claims, "", tt.secret)); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	t.Run("alg none", func(t *testing.T) {
+		tok := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+		s, err := tok.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Verify(s); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify() error = %v, want ErrInvalidToken", err)
+		}
+	})
+}
+
+func TestNewVerifier_RequiresKey(t *testing.T) {
+	if _, err := NewVerifier(config.AuthConfig{}); err == nil {
+		t.Error("NewVerifier() accepted a config without keys")
+	}
+	if _, err := NewVerifier(config.AuthConfig{SigningKeys: map[string]string{"k1": ""}}); err == nil {
+		t.Error("NewVerifier() accepted an empty signing key")
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"chat/internal/api"
 	"chat/internal/hub"
 	"chat/internal/repository"
+	"chat/internal/scanner"
+	"chat/internal/token"
 )
 
 func main() {
@@ -49,8 +51,24 @@ func main() {
 	wsHub := hub.NewHub(repo, logger)
 	go wsHub.Run()
 
+	// Initialize upload malware scanning
+	var fileScanner scanner.Scanner
+	guard, err := scanner.New(&cfg.Scanning)
+	if err != nil {
+		logger.Fatal("Invalid scanning config", zap.Error(err))
+	}
+	if guard != nil {
+		fileScanner = guard
+	}
+
+	// Initialize access token verification
+	tokenVerifier, err := token.NewVerifier(cfg.Auth)
+	if err != nil {
+		logger.Fatal("Invalid auth config", zap.Error(err))
+	}
+
 	// Initialize API server
-	apiServer := api.NewServer(cfg, repo, wsHub, logger)
+	apiServer := api.NewServer(cfg, repo, wsHub, fileScanner, tokenVerifier, logger)
 
 	// Start metrics server
 	go startMetricsServer(cfg.Metrics.Port, logger)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -22,16 +23,18 @@ const (
 )
 
 type CardDAVHandler struct {
-	service *service.ContactService
-	logger  *zap.Logger
-	domain  string
+	service   *service.ContactService
+	logger    *zap.Logger
+	domain    string
+	publicURL string
 }
 
-func NewCardDAVHandler(service *service.ContactService, logger *zap.Logger, domain string) *CardDAVHandler {
+func NewCardDAVHandler(service *service.ContactService, logger *zap.Logger, domain, publicURL string) *CardDAVHandler {
 	return &CardDAVHandler{
-		service: service,
-		logger:  logger,
-		domain:  domain,
+		service:   service,
+		logger:    logger,
+		domain:    domain,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
 	}
 }
 
@@ -184,9 +187,10 @@ func (h *CardDAVHandler) propfindAddressBook(ctx context.Context, userID uuid.UU
 									Collection:  &struct{}{},
 									Addressbook: &struct{}{},
 								},
-								DisplayName:        ab.Name,
-								SyncToken:          ab.SyncToken,
-								SupportedReportSet: h.supportedReports(),
+								DisplayName:          ab.Name,
+								SyncToken:            ab.SyncToken,
+								SupportedReportSet:   h.supportedReports(),
+								SupportedAddressData: h.supportedAddressData(),
 							},
 						},
 					},
@@ -215,9 +219,10 @@ func (h *CardDAVHandler) propfindAddressBook(ctx context.Context, userID uuid.UU
 							Collection:  &struct{}{},
 							Addressbook: &struct{}{},
 						},
-						DisplayName:        ab.Name,
-						SyncToken:          ab.SyncToken,
-						SupportedReportSet: h.supportedReports(),
+						DisplayName:          ab.Name,
+						SyncToken:            ab.SyncToken,
+						SupportedReportSet:   h.supportedReports(),
+						SupportedAddressData: h.supportedAddressData(),
 					},
 				},
 			},
@@ -261,7 +266,7 @@ func (h *CardDAVHandler) handleReport(w http.ResponseWriter, r *http.Request) {
 	if bytes.Contains(body, []byte("addressbook-multiget")) {
 		h.handleMultiget(w, r, userID, path, body)
 	} else if bytes.Contains(body, []byte("addressbook-query")) {
-		h.handleQuery(w, r, userID, path)
+		h.handleQuery(w, r, userID, path, body)
 	} else if bytes.Contains(body, []byte("sync-collection")) {
 		h.handleSyncCollection(w, r, userID, path, body)
 	} else {
@@ -295,6 +300,7 @@ func (h *CardDAVHandler) handleMultiget(w http.ResponseWriter, r *http.Request,
 	}
 
 	contacts, _ := h.service.GetMultipleContactsByUID(ctx, abID, uids)
+	version := addressDataVersion(body)
 
 	var responses []Response
 	for _, c := range contacts {
@@ -305,8 +311,8 @@ func (h *CardDAVHandler) handleMultiget(w http.ResponseWriter, r *http.Request,
 					Status: "HTTP/1.1 200 OK",
 					Prop: Prop{
 						GetETag:         fmt.Sprintf(`"%d"`, c.UpdatedAt.Unix()),
-						AddressData:     h.contactToVCard(c),
-						ContentType:     "text/vcard; charset=utf-8",
+						AddressData:     h.contactToVCard(c, version),
+						ContentType:     service.VCardContentType(version),
 					},
 				},
 			},
@@ -316,7 +322,7 @@ func (h *CardDAVHandler) handleMultiget(w http.ResponseWriter, r *http.Request,
 	h.writeMultiStatus(w, MultiStatus{Responses: responses})
 }
 
-func (h *CardDAVHandler) handleQuery(w http.ResponseWriter, r *http.Request, userID uuid.UUID, path string) {
+func (h *CardDAVHandler) handleQuery(w http.ResponseWriter, r *http.Request, userID uuid.UUID, path string, body []byte) {
 	ctx := r.Context()
 	parts := strings.Split(strings.Trim(path, "/"), "/")
 	if len(parts) < 4 {
@@ -332,6 +338,7 @@ func (h *CardDAVHandler) handleQuery(w http.ResponseWriter, r *http.Request, use
 	})
 
 	contacts := response.Contacts
+	version := addressDataVersion(body)
 
 	var responses []Response
 	for _, c := range contacts {
@@ -342,7 +349,7 @@ func (h *CardDAVHandler) handleQuery(w http.ResponseWriter, r *http.Request, use
 					Status: "HTTP/1.1 200 OK",
 					Prop: Prop{
 						GetETag:     fmt.Sprintf(`"%d"`, c.UpdatedAt.Unix()),
-						AddressData: h.contactToVCard(c),
+						AddressData: h.contactToVCard(c, version),
 					},
 				},
 			},
@@ -368,10 +375,39 @@ func (h *CardDAVHandler) handleSyncCollection(w http.ResponseWriter, r *http.Req
 	}
 	xml.Unmarshal(body, &syncRequest)
 
-	contacts, newToken, _ := h.service.GetSyncChanges(ctx, abID, syncRequest.SyncToken)
+	ab, err := h.service.GetAddressBook(ctx, userID, abID)
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if ab == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	contacts, newToken, err := h.service.GetSyncChanges(ctx, abID, syncRequest.SyncToken)
+	if errors.Is(err, service.ErrInvalidSyncToken) {
+		// The client drops its token and syncs in full (RFC 6578 section 3.2)
+		h.writeError(w, http.StatusForbidden, DAVError{ValidSyncToken: &struct{}{}})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get sync changes", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
 	var responses []Response
 	for _, c := range contacts {
+		// Deleted and merged contacts are reported removed (RFC 6578 section 3.5.2)
+		if c.DeletedAt != nil {
+			responses = append(responses, Response{
+				Href:   fmt.Sprintf("%s%s.vcf", path, c.UID),
+				Status: "HTTP/1.1 404 Not Found",
+			})
+			continue
+		}
+
 		responses = append(responses, Response{
 			Href: fmt.Sprintf("%s%s.vcf", path, c.UID),
 			Propstat: []Propstat{
@@ -452,9 +488,11 @@ func (h *CardDAVHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vcard := h.contactToVCard(contact)
+	version := service.NegotiateVCardVersion(r.Header.Get("Accept"))
+	vcard := h.contactToVCard(contact, version)
 
-	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.Header().Set("Content-Type", service.VCardContentType(version))
+	w.Header().Set("Vary", "Accept")
 	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, contact.UpdatedAt.Unix()))
 	w.Write([]byte(vcard))
 }
@@ -535,123 +573,56 @@ func (h *CardDAVHandler) writeMultiStatus(w http.ResponseWriter, ms MultiStatus)
 	w.Write(output)
 }
 
-func (h *CardDAVHandler) contactToVCard(c *models.Contact) string {
-	var buf bytes.Buffer
-	buf.WriteString("BEGIN:VCARD\r\n")
-	buf.WriteString("VERSION:3.0\r\n")
-	buf.WriteString(fmt.Sprintf("UID:%s\r\n", c.UID))
-	buf.WriteString(fmt.Sprintf("FN:%s\r\n", c.DisplayName))
-	buf.WriteString(fmt.Sprintf("N:%s;%s;%s;%s;%s\r\n",
-		c.LastName, c.FirstName, c.MiddleName, c.Prefix, c.Suffix))
-
-	if c.Company != "" {
-		buf.WriteString(fmt.Sprintf("ORG:%s\r\n", c.Company))
-	}
-	if c.JobTitle != "" {
-		buf.WriteString(fmt.Sprintf("TITLE:%s\r\n", c.JobTitle))
-	}
+// writeError writes a DAV:error body naming the failed precondition
+// (RFC 4918 section 16)
+func (h *CardDAVHandler) writeError(w http.ResponseWriter, status int, davErr DAVError) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
 
-	for _, e := range c.Emails {
-		buf.WriteString(fmt.Sprintf("EMAIL;TYPE=%s:%s\r\n", strings.ToUpper(e.Type), e.Email))
-	}
+	output, _ := xml.MarshalIndent(davErr, "", "  ")
+	w.Write([]byte(xml.Header))
+	w.Write(output)
+}
 
-	for _, p := range c.Phones {
-		buf.WriteString(fmt.Sprintf("TEL;TYPE=%s:%s\r\n", strings.ToUpper(p.Type), p.Number))
+func (h *CardDAVHandler) contactToVCard(c *models.Contact, version string) string {
+	opts := service.VCardOptions{Version: version}
+	if c.PhotoURL != "" {
+		// Reference the medium thumbnail rather than embedding the full image
+		opts.PhotoURI = fmt.Sprintf("%s/carddav/photos/%s?size=%s", h.publicURL, c.ID, models.PhotoSizeMedium)
 	}
+	return service.FormatVCard(c, opts)
+}
 
-	for _, a := range c.Addresses {
-		buf.WriteString(fmt.Sprintf("ADR;TYPE=%s:;;%s;%s;%s;%s;%s\r\n",
-			strings.ToUpper(a.Type), a.Street, a.City, a.State, a.PostalCode, a.Country))
+func (h *CardDAVHandler) parseVCard(data string) *models.Contact {
+	if contacts := service.ParseVCards(data); len(contacts) > 0 {
+		return contacts[0]
 	}
+	return &models.Contact{}
+}
 
-	if c.Birthday != nil {
-		buf.WriteString(fmt.Sprintf("BDAY:%s\r\n", c.Birthday.Format("20060102")))
+// addressDataVersion returns the vCard version requested by the
+// address-data element of a REPORT body (RFC 6352 section 10.4)
+func addressDataVersion(body []byte) string {
+	var report struct {
+		AddressData struct {
+			Version string `xml:"version,attr"`
+		} `xml:"prop>address-data"`
 	}
+	xml.Unmarshal(body, &report)
 
-	if c.Notes != "" {
-		buf.WriteString(fmt.Sprintf("NOTE:%s\r\n", c.Notes))
+	if service.IsSupportedVCardVersion(report.AddressData.Version) {
+		return report.AddressData.Version
 	}
-
-	buf.WriteString(fmt.Sprintf("REV:%s\r\n", c.UpdatedAt.Format("20060102T150405Z")))
-	buf.WriteString("END:VCARD\r\n")
-
-	return buf.String()
+	return service.VCardVersion3
 }
 
-func (h *CardDAVHandler) parseVCard(data string) *models.Contact {
-	contact := &models.Contact{}
-	lines := strings.Split(data, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if !strings.Contains(line, ":") {
-			continue
-		}
-
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		key := strings.Split(parts[0], ";")[0]
-		value := parts[1]
-
-		switch key {
-		case "FN":
-			contact.DisplayName = value
-		case "N":
-			nameParts := strings.Split(value, ";")
-			if len(nameParts) >= 1 {
-				contact.LastName = nameParts[0]
-			}
-			if len(nameParts) >= 2 {
-				contact.FirstName = nameParts[1]
-			}
-			if len(nameParts) >= 3 {
-				contact.MiddleName = nameParts[2]
-			}
-			if len(nameParts) >= 4 {
-				contact.Prefix = nameParts[3]
-			}
-			if len(nameParts) >= 5 {
-				contact.Suffix = nameParts[4]
-			}
-		case "ORG":
-			contact.Company = strings.Split(value, ";")[0]
-		case "TITLE":
-			contact.JobTitle = value
-		case "EMAIL":
-			emailType := "other"
-			if strings.Contains(parts[0], "WORK") {
-				emailType = "work"
-			} else if strings.Contains(parts[0], "HOME") {
-				emailType = "home"
-			}
-			contact.Emails = append(contact.Emails, models.ContactEmail{
-				Type:  emailType,
-				Email: value,
-			})
-		case "TEL":
-			phoneType := "other"
-			if strings.Contains(parts[0], "CELL") || strings.Contains(parts[0], "MOBILE") {
-				phoneType = "mobile"
-			} else if strings.Contains(parts[0], "WORK") {
-				phoneType = "work"
-			} else if strings.Contains(parts[0], "HOME") {
-				phoneType = "home"
-			}
-			contact.Phones = append(contact.Phones, models.ContactPhone{
-				Type:   phoneType,
-				Number: value,
-			})
-		case "NOTE":
-			contact.Notes = value
-		case "UID":
-			contact.UID = value
-		}
+func (h *CardDAVHandler) supportedAddressData() *SupportedAddressData {
+	return &SupportedAddressData{
+		Types: []AddressDataType{
+			{ContentType: "text/vcard", Version: service.VCardVersion3},
+			{ContentType: "text/vcard", Version: service.VCardVersion4},
+		},
 	}
-
-	return contact
 }
 
 // XML types for CardDAV
@@ -668,6 +639,13 @@ type MultiStatus struct {
 	SyncToken string     `xml:"sync-token,omitempty"`
 }
 
+// DAVError is the body of a response to a request that failed a
+// precondition
+type DAVError struct {
+	XMLName        xml.Name  `xml:"DAV: error"`
+	ValidSyncToken *struct{} `xml:"valid-sync-token,omitempty"`
+}
+
 type Response struct {
 	Href     string     `xml:"href"`
 	Propstat []Propstat `xml:"propstat"`
@@ -685,6 +663,7 @@ type Prop struct {
 	CurrentUserPrincipal *CurrentUserPrincipal `xml:"current-user-principal,omitempty"`
 	AddressbookHomeSet   *AddressbookHomeSet   `xml:"urn:ietf:params:xml:ns:carddav addressbook-home-set,omitempty"`
 	SupportedReportSet   *SupportedReportSet   `xml:"supported-report-set,omitempty"`
+	SupportedAddressData *SupportedAddressData `xml:"urn:ietf:params:xml:ns:carddav supported-address-data,omitempty"`
 	GetETag              string                `xml:"getetag,omitempty"`
 	ContentType          string                `xml:"getcontenttype,omitempty"`
 	SyncToken            string                `xml:"sync-token,omitempty"`
@@ -717,3 +696,12 @@ type Report struct {
 	AddressbookQuery    *struct{} `xml:"urn:ietf:params:xml:ns:carddav addressbook-query,omitempty"`
 	SyncCollection      *struct{} `xml:"sync-collection,omitempty"`
 }
+
+type SupportedAddressData struct {
+	Types []AddressDataType `xml:"urn:ietf:params:xml:ns:carddav address-data-type"`
+}
+
+type AddressDataType struct {
+	ContentType string `xml:"content-type,attr"`
+	Version     string `xml:"version,attr"`
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 
@@ -299,14 +300,57 @@ func (h *ContactHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.UpdatePhoto(r.Context(), userID, contactID, "", data); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+	photoURL := "/api/v1/contacts/" + contactID.String() + "/photo"
+	if err := h.service.UpdatePhoto(r.Context(), userID, contactID, photoURL, data); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnsupportedPhotoFormat):
+			writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, service.ErrPhotoTooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetPhoto serves a contact photo, optionally as a thumbnail (?size=small|medium|original)
+func (h *ContactHandler) GetPhoto(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	contactID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid contact ID")
+		return
+	}
+
+	size := r.URL.Query().Get("size")
+	switch size {
+	case "":
+		size = models.PhotoSizeOriginal
+	case models.PhotoSizeSmall, models.PhotoSizeMedium, models.PhotoSizeOriginal:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid photo size")
+		return
+	}
+
+	data, contentType, err := h.service.GetPhoto(r.Context(), userID, contactID, size)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if data == nil {
+		writeError(w, http.StatusNotFound, "Photo not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
 func (h *ContactHandler) ImportContacts(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 
@@ -337,13 +381,24 @@ func (h *ContactHandler) ExportContacts(w http.ResponseWriter, r *http.Request)
 		addressBookID, _ = uuid.Parse(abID)
 	}
 
-	data, err := h.service.ExportContacts(r.Context(), userID, addressBookID, format)
+	// An explicit version parameter wins over the Accept header
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		version = service.NegotiateVCardVersion(r.Header.Get("Accept"))
+	}
+	if !service.IsSupportedVCardVersion(version) {
+		writeError(w, http.StatusBadRequest, "Unsupported vCard version")
+		return
+	}
+
+	data, err := h.service.ExportContacts(r.Context(), userID, addressBookID, format, version)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.Header().Set("Content-Type", service.VCardContentType(version))
+	w.Header().Set("Vary", "Accept")
 	w.Header().Set("Content-Disposition", "attachment; filename=contacts.vcf")
 	w.Write([]byte(data))
 }
@@ -371,13 +426,48 @@ func (h *ContactHandler) MergeContacts(w http.ResponseWriter, r *http.Request) {
 
 	contact, err := h.service.MergeContacts(r.Context(), userID, &req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeMergeError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, contact)
 }
 
+// PreviewMerge returns the result a merge would have and its conflicts,
+// without changing anything
+func (h *ContactHandler) PreviewMerge(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+
+	var req models.MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	preview, err := h.service.PreviewMerge(r.Context(), userID, &req)
+	if err != nil {
+		h.writeMergeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preview)
+}
+
+// writeMergeError maps merge service errors to HTTP statuses
+func (h *ContactHandler) writeMergeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrMergeContactNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrMergeAccessDenied):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidMerge):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("Failed to merge contacts", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
 // Group handlers
 
 func (h *ContactHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
@@ -516,6 +606,120 @@ func (h *ContactHandler) RemoveContactFromGroup(w http.ResponseWriter, r *http.R
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetGroupMemberEmails returns the deduplicated primary emails of every
+// contact in a group, following nested groups
+func (h *ContactHandler) GetGroupMemberEmails(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	groupID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+
+	emails, err := h.service.GetGroupMemberEmails(r.Context(), userID, groupID)
+	if err != nil {
+		h.writeGroupError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"group_id": groupID,
+		"emails":   emails,
+		"count":    len(emails),
+	})
+}
+
+// ExportGroupVCard exports every contact in a group, following nested
+// groups, as a multi-vCard file
+func (h *ContactHandler) ExportGroupVCard(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	groupID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		version = service.NegotiateVCardVersion(r.Header.Get("Accept"))
+	}
+	if !service.IsSupportedVCardVersion(version) {
+		writeError(w, http.StatusBadRequest, "Unsupported vCard version")
+		return
+	}
+
+	data, err := h.service.ExportGroupVCard(r.Context(), userID, groupID, version)
+	if err != nil {
+		h.writeGroupError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", service.VCardContentType(version))
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Content-Disposition", "attachment; filename=group.vcf")
+	w.Write([]byte(data))
+}
+
+func (h *ContactHandler) AddSubgroups(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	groupID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+
+	var req struct {
+		GroupIDs []uuid.UUID `json:"group_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.AddSubgroups(r.Context(), userID, groupID, req.GroupIDs); err != nil {
+		h.writeGroupError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ContactHandler) RemoveSubgroup(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	groupID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+	subgroupID, err := uuid.Parse(chi.URLParam(r, "subgroupId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid subgroup ID")
+		return
+	}
+
+	if err := h.service.RemoveSubgroup(r.Context(), userID, groupID, subgroupID); err != nil {
+		h.writeGroupError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeGroupError maps group service errors to HTTP statuses
+func (h *ContactHandler) writeGroupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrGroupNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrGroupAccessDenied):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrGroupTooLarge):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		h.logger.Error("Group operation failed", zap.Error(err))
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
 // Helper functions
 
 func getUserID(r *http.Request) uuid.UUID {
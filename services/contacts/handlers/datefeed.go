@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"contacts-service/models"
+	"contacts-service/service"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// DateFeedHandler serves the birthday and anniversary feed and manages its
+// subscription URL
+type DateFeedHandler struct {
+	service   *service.DateFeedService
+	publicURL string
+	logger    *zap.Logger
+}
+
+func NewDateFeedHandler(service *service.DateFeedService, publicURL string, logger *zap.Logger) *DateFeedHandler {
+	return &DateFeedHandler{
+		service:   service,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		logger:    logger,
+	}
+}
+
+func (h *DateFeedHandler) feedURL(token string) string {
+	return h.publicURL + "/feeds/dates/" + token + ".ics"
+}
+
+// GetFeed returns the caller's subscription URL
+func (h *DateFeedHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	token, createdAt, err := h.service.GetToken(r.Context(), getUserID(r))
+	if err != nil {
+		h.logger.Error("Failed to get date feed", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to get feed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &models.DateFeed{URL: h.feedURL(token), CreatedAt: createdAt})
+}
+
+// ResetFeed gives the caller a new subscription URL, revoking the old one
+func (h *DateFeedHandler) ResetFeed(w http.ResponseWriter, r *http.Request) {
+	token, createdAt, err := h.service.ResetToken(r.Context(), getUserID(r))
+	if err != nil {
+		h.logger.Error("Failed to reset date feed", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to reset feed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &models.DateFeed{URL: h.feedURL(token), CreatedAt: createdAt})
+}
+
+// ServeFeed serves the iCalendar feed. The token in the URL authorizes it,
+// as calendar clients subscribe without credentials.
+func (h *DateFeedHandler) ServeFeed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(chi.URLParam(r, "token"), ".ics")
+
+	feed, err := h.service.Feed(r.Context(), token, r.URL.Query().Get("reminder"))
+	if err != nil {
+		switch {
+		case err.Error() == "feed not found":
+			writeError(w, http.StatusNotFound, "Feed not found")
+		case strings.HasPrefix(err.Error(), "invalid reminder"):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			h.logger.Error("Failed to build date feed", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to build feed")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", service.DateFeedContentType)
+	w.Header().Set("Content-Disposition", "inline; filename=dates.ics")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write([]byte(feed))
+}
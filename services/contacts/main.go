@@ -66,14 +66,35 @@ func main() {
 	contactRepo := repository.NewContactRepository(pool)
 	groupRepo := repository.NewGroupRepository(pool)
 	addressBookRepo := repository.NewAddressBookRepository(pool)
+	dateFeedRepo := repository.NewDateFeedRepository(pool)
 
 	// Initialize services
 	contactService := service.NewContactService(contactRepo, groupRepo, addressBookRepo, logger)
+	dateFeedService := service.NewDateFeedService(contactRepo, dateFeedRepo, logger)
+
+	// Forget contact deletions kept for CardDAV sync once they're too old
+	pruneCtx, stopPruning := context.WithCancel(context.Background())
+	defer stopPruning()
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			if err := contactService.PruneSyncHistory(pruneCtx); err != nil && pruneCtx.Err() == nil {
+				logger.Error("Failed to prune CardDAV sync history", zap.Error(err))
+			}
+			select {
+			case <-pruneCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
 
 	// Initialize handlers
 	contactHandler := handlers.NewContactHandler(contactService, logger)
+	dateFeedHandler := handlers.NewDateFeedHandler(dateFeedService, cfg.Server.PublicURL, logger)
 	authMiddleware := handlers.NewAuthMiddleware(cfg.Auth.JWTSecret)
-	cardDAVHandler := carddav.NewCardDAVHandler(contactService, logger, cfg.Server.Domain)
+	cardDAVHandler := carddav.NewCardDAVHandler(contactService, logger, cfg.Server.Domain, cfg.Server.PublicURL)
 
 	// Basic auth validator (for CardDAV clients) — validates via auth service
 	authServiceURL := os.Getenv("AUTH_SERVICE_URL")
@@ -154,9 +175,13 @@ func main() {
 	// CardDAV routes (supports Basic Auth for native clients)
 	r.Route("/carddav", func(r chi.Router) {
 		r.Use(authMiddleware.CombinedAuth(validateBasicAuth))
+		r.Get("/photos/{id}", contactHandler.GetPhoto)
 		r.HandleFunc("/*", cardDAVHandler.ServeHTTP)
 	})
 
+	// Birthday and anniversary feed, authorized by the token in its URL
+	r.Get("/feeds/dates/{token}", dateFeedHandler.ServeFeed)
+
 	// REST API routes
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Use(authMiddleware.JWTAuth)
@@ -180,9 +205,11 @@ func main() {
 			r.Get("/export", contactHandler.ExportContacts)
 			r.Get("/duplicates", contactHandler.FindDuplicates)
 			r.Post("/merge", contactHandler.MergeContacts)
+			r.Post("/merge/preview", contactHandler.PreviewMerge)
 			r.Get("/{id}", contactHandler.GetContact)
 			r.Put("/{id}", contactHandler.UpdateContact)
 			r.Delete("/{id}", contactHandler.DeleteContact)
+			r.Get("/{id}/photo", contactHandler.GetPhoto)
 			r.Post("/{id}/photo", contactHandler.UploadPhoto)
 		})
 
@@ -195,7 +222,15 @@ func main() {
 			r.Delete("/{id}", contactHandler.DeleteGroup)
 			r.Post("/{id}/contacts", contactHandler.AddContactsToGroup)
 			r.Delete("/{id}/contacts/{contactId}", contactHandler.RemoveContactFromGroup)
+			r.Post("/{id}/groups", contactHandler.AddSubgroups)
+			r.Delete("/{id}/groups/{subgroupId}", contactHandler.RemoveSubgroup)
+			r.Get("/{id}/members/emails", contactHandler.GetGroupMemberEmails)
+			r.Get("/{id}/vcf", contactHandler.ExportGroupVCard)
 		})
+
+		// Birthday and anniversary feed subscription
+		r.Get("/date-feed", dateFeedHandler.GetFeed)
+		r.Post("/date-feed/reset", dateFeedHandler.ResetFeed)
 	})
 
 	// Start server
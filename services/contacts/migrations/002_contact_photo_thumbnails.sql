@@ -0,0 +1,7 @@
+-- Contacts Service Database Schema
+-- Migration: 002_contact_photo_thumbnails.sql
+-- Thumbnails generated on photo upload, served to CardDAV and mobile clients
+
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS photo_content_type VARCHAR(50);
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS photo_small BYTEA;  -- 96x96
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS photo_medium BYTEA; -- 256x256
@@ -0,0 +1,13 @@
+-- Contacts Service Database Schema
+-- Migration: 003_nested_contact_groups.sql
+-- Groups can contain other groups; expansion follows these references
+
+CREATE TABLE IF NOT EXISTS contact_group_subgroups (
+    group_id UUID NOT NULL REFERENCES contact_groups(id) ON DELETE CASCADE,
+    member_group_id UUID NOT NULL REFERENCES contact_groups(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, member_group_id),
+    CHECK (group_id <> member_group_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cgs_member ON contact_group_subgroups(member_group_id);
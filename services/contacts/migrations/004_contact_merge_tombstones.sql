@@ -0,0 +1,10 @@
+-- Contacts Service Database Schema
+-- Migration: 004_contact_merge_tombstones.sql
+-- Contacts merged into another are soft-deleted and kept as tombstones so
+-- CardDAV clients see them removed on their next sync
+
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS merged_into UUID REFERENCES contacts(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_contacts_deleted ON contacts(address_book_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_contacts_merged_into ON contacts(merged_into) WHERE merged_into IS NOT NULL;
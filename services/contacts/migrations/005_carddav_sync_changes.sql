@@ -0,0 +1,107 @@
+-- Contacts Service Database Schema
+-- Migration: 005_carddav_sync_changes.sql
+-- Per address book change sequence for incremental CardDAV sync (RFC 6578).
+-- Every contact change takes the next sequence number of its address book;
+-- hard deletes leave a row in contact_deletions. The sync token is
+-- 'urn:x-contacts:sync:<seq>', so a client's token tells which changes it
+-- has seen. Deletions older than the retention are pruned and sync_min_seq
+-- raised, making older tokens invalid.
+
+ALTER TABLE address_books ADD COLUMN IF NOT EXISTS sync_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE address_books ADD COLUMN IF NOT EXISTS sync_min_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS sync_seq BIGINT NOT NULL DEFAULT 0;
+
+-- Tokens handed out before this migration were random and can't be resolved;
+-- clients holding one are told to sync in full
+ALTER TABLE address_books ALTER COLUMN sync_token SET DEFAULT 'urn:x-contacts:sync:0';
+UPDATE address_books SET sync_token = 'urn:x-contacts:sync:' || sync_seq;
+
+CREATE INDEX IF NOT EXISTS idx_contacts_sync_seq ON contacts(address_book_id, sync_seq);
+
+-- Contacts deleted from an address book, reported removed to syncing clients
+CREATE TABLE IF NOT EXISTS contact_deletions (
+    address_book_id UUID NOT NULL REFERENCES address_books(id) ON DELETE CASCADE,
+    uid VARCHAR(255) NOT NULL,
+    sync_seq BIGINT NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (address_book_id, uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_contact_deletions_seq ON contact_deletions(address_book_id, sync_seq);
+CREATE INDEX IF NOT EXISTS idx_contact_deletions_deleted ON contact_deletions(deleted_at);
+
+-- next_address_book_sync_seq advances an address book's sequence and token.
+-- The row lock it takes orders concurrent changes to the same book, so
+-- sequence numbers are visible in the order they were assigned. Returns NULL
+-- when the address book is itself being deleted.
+CREATE OR REPLACE FUNCTION next_address_book_sync_seq(ab_id UUID)
+RETURNS BIGINT AS $$
+DECLARE
+    seq BIGINT;
+BEGIN
+    UPDATE address_books
+    SET sync_seq = sync_seq + 1,
+        sync_token = 'urn:x-contacts:sync:' || (sync_seq + 1),
+        updated_at = NOW()
+    WHERE id = ab_id
+    RETURNING sync_seq INTO seq;
+    RETURN seq;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION assign_contact_sync_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.sync_seq = COALESCE(next_address_book_sync_seq(NEW.address_book_id), 0);
+
+    IF TG_OP = 'INSERT' THEN
+        -- A contact re-created under a deleted UID is no longer removed
+        DELETE FROM contact_deletions
+        WHERE address_book_id = NEW.address_book_id AND uid = NEW.uid;
+    ELSIF OLD.address_book_id <> NEW.address_book_id OR OLD.uid <> NEW.uid THEN
+        -- Moving a contact removes it from where it was
+        PERFORM record_contact_deletion(OLD.address_book_id, OLD.uid);
+        DELETE FROM contact_deletions
+        WHERE address_book_id = NEW.address_book_id AND uid = NEW.uid;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_contact_deletion(ab_id UUID, contact_uid VARCHAR)
+RETURNS VOID AS $$
+DECLARE
+    seq BIGINT;
+BEGIN
+    seq = next_address_book_sync_seq(ab_id);
+    IF seq IS NULL THEN
+        RETURN;
+    END IF;
+
+    INSERT INTO contact_deletions (address_book_id, uid, sync_seq)
+    VALUES (ab_id, contact_uid, seq)
+    ON CONFLICT (address_book_id, uid)
+    DO UPDATE SET sync_seq = EXCLUDED.sync_seq, deleted_at = NOW();
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION log_contact_deletion()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM record_contact_deletion(OLD.address_book_id, OLD.uid);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The sequence replaces the random token of migration 001
+DROP TRIGGER IF EXISTS update_sync_token_on_contact_change ON contacts;
+DROP FUNCTION IF EXISTS update_address_book_sync_token();
+
+CREATE TRIGGER assign_sync_seq_on_contact_change
+BEFORE INSERT OR UPDATE ON contacts
+FOR EACH ROW EXECUTE FUNCTION assign_contact_sync_seq();
+
+CREATE TRIGGER log_deletion_on_contact_delete
+AFTER DELETE ON contacts
+FOR EACH ROW EXECUTE FUNCTION log_contact_deletion();
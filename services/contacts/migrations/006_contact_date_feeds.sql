@@ -0,0 +1,11 @@
+-- Contacts Service Database Schema
+-- Migration: 006_contact_date_feeds.sql
+-- Per user token for the read-only iCalendar feed of contacts' birthdays
+-- and anniversaries. The token authorizes the subscription URL, so calendar
+-- clients can fetch it without credentials; resetting it revokes the old URL.
+
+CREATE TABLE IF NOT EXISTS contact_date_feeds (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -54,6 +54,10 @@ type Contact struct {
 	ETag          string            `json:"etag"`
 	CreatedAt     time.Time         `json:"created_at"`
 	UpdatedAt     time.Time         `json:"updated_at"`
+
+	// Set on a contact merged into another, kept as a CardDAV tombstone
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	MergedInto *uuid.UUID `json:"merged_into,omitempty"`
 }
 
 type ContactEmail struct {
@@ -224,6 +228,9 @@ type ImportRequest struct {
 	AddressBookID uuid.UUID `json:"address_book_id"`
 	Format        string    `json:"format"` // vcard, csv, google, outlook
 	Data          string    `json:"data"`   // File content (base64 for binary)
+
+	// DryRun parses the data and returns a preview without writing
+	DryRun bool `json:"dry_run"`
 }
 
 type ImportResult struct {
@@ -231,15 +238,74 @@ type ImportResult struct {
 	Imported int      `json:"imported"`
 	Skipped  int      `json:"skipped"`
 	Errors   []string `json:"errors"`
+
+	// CSV imports report the detected format and the header columns that
+	// did not map to a contact field
+	Format          string     `json:"format,omitempty"`
+	UnmappedColumns []string   `json:"unmapped_columns,omitempty"`
+	DryRun          bool       `json:"dry_run,omitempty"`
+	Preview         []*Contact `json:"preview,omitempty"`
 }
 
 type MergeRequest struct {
 	PrimaryID   uuid.UUID   `json:"primary_id" validate:"required"`
 	MergeIDs    []uuid.UUID `json:"merge_ids" validate:"required,min=1"`
 	KeepFields  []string    `json:"keep_fields"` // Which fields to keep from primary
+
+	// Resolutions picks the outcome of each conflicting field: the ID of the
+	// contact whose value wins, or MergeKeepBoth
+	Resolutions map[string]string `json:"resolutions,omitempty"`
+}
+
+// MergeKeepBoth resolves a conflict by keeping every contact's value
+const MergeKeepBoth = "keep_both"
+
+// MergePreview is the result a merge would have, with the fields the
+// contacts disagree on
+type MergePreview struct {
+	Contact   *Contact        `json:"contact"` // The primary contact after the merge
+	Sources   []*Contact      `json:"sources"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+// MergeConflict is a field that more than one contact has a different
+// value for. Multi-valued fields conflict per type, e.g. "phones.mobile".
+type MergeConflict struct {
+	Field       string            `json:"field"`
+	Values      []MergeFieldValue `json:"values"`
+	Resolution  string            `json:"resolution"` // Applied: a contact ID or keep_both
+	CanKeepBoth bool              `json:"can_keep_both"`
+}
+
+// MergeFieldValue is one contact's value of a conflicting field
+type MergeFieldValue struct {
+	ContactID uuid.UUID   `json:"contact_id"`
+	Value     interface{} `json:"value"`
 }
 
 type DuplicateGroup struct {
 	Contacts []*Contact `json:"contacts"`
 	Reason   string     `json:"reason"` // email, phone, name
 }
+
+// Photo sizes served by the contact photo endpoint
+const (
+	PhotoSizeSmall    = "small"    // 96x96 thumbnail
+	PhotoSizeMedium   = "medium"   // 256x256 thumbnail
+	PhotoSizeOriginal = "original" // As uploaded
+)
+
+// ContactPhoto holds an uploaded photo and the thumbnails generated from it
+type ContactPhoto struct {
+	ContentType string
+	Original    []byte
+	Small       []byte
+	Medium      []byte
+}
+
+// DateFeed is a user's subscription to the iCalendar feed of their
+// contacts' birthdays and anniversaries
+type DateFeed struct {
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
 	"contacts-service/models"
 
@@ -40,7 +42,7 @@ func (r *AddressBookRepository) GetByID(ctx context.Context, id uuid.UUID) (*mod
 	query := `
 		SELECT ab.id, ab.user_id, ab.name, ab.description, ab.is_default,
 		       ab.sync_token, ab.created_at, ab.updated_at,
-		       (SELECT COUNT(*) FROM contacts WHERE address_book_id = ab.id) as contact_count
+		       (SELECT COUNT(*) FROM contacts WHERE address_book_id = ab.id AND deleted_at IS NULL) as contact_count
 		FROM address_books ab
 		WHERE ab.id = $1`
 
@@ -67,7 +69,7 @@ func (r *AddressBookRepository) GetByUserID(ctx context.Context, userID uuid.UUI
 	query := `
 		SELECT ab.id, ab.user_id, ab.name, ab.description, ab.is_default,
 		       ab.sync_token, ab.created_at, ab.updated_at,
-		       (SELECT COUNT(*) FROM contacts WHERE address_book_id = ab.id) as contact_count,
+		       (SELECT COUNT(*) FROM contacts WHERE address_book_id = ab.id AND deleted_at IS NULL) as contact_count,
 		       COALESCE(abs.permission, 'owner') as permission
 		FROM address_books ab
 		LEFT JOIN address_book_shares abs ON ab.id = abs.address_book_id AND abs.user_id = $1
@@ -228,20 +230,37 @@ func (r *AddressBookRepository) HasAccess(ctx context.Context, abID, userID uuid
 	return exists, err
 }
 
-// GetSyncChanges returns contacts changed since last sync
-func (r *AddressBookRepository) GetSyncChanges(ctx context.Context, abID uuid.UUID, sinceSyncToken string) ([]*models.Contact, string, error) {
-	// Get current sync token
+// ErrSyncTokenExpired is returned for a sync position the address book no
+// longer has the changes since, or never reached
+var ErrSyncTokenExpired = errors.New("sync token expired")
+
+// GetSyncChanges returns the contacts of an address book changed after the
+// sync position since, with the address book's current sync token. Contacts
+// removed since, deleted or merged away, follow as tombstones with DeletedAt
+// set. A negative position returns every live contact, for an initial sync.
+func (r *AddressBookRepository) GetSyncChanges(ctx context.Context, abID uuid.UUID, since int64) ([]*models.Contact, string, error) {
+	// One snapshot, so the token covers exactly the changes returned
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var seq, minSeq int64
 	var currentToken string
-	err := r.db.QueryRow(ctx, "SELECT sync_token FROM address_books WHERE id = $1", abID).Scan(&currentToken)
+	err = tx.QueryRow(ctx, "SELECT sync_seq, sync_min_seq, sync_token FROM address_books WHERE id = $1",
+		abID).Scan(&seq, &minSeq, &currentToken)
 	if err != nil {
 		return nil, "", err
 	}
 
-	if sinceSyncToken == currentToken {
+	if since > seq || (since >= 0 && since < minSeq) {
+		return nil, "", ErrSyncTokenExpired
+	}
+	if since == seq {
 		return []*models.Contact{}, currentToken, nil
 	}
 
-	// Get all contacts (simplified - in production, track changes)
 	query := `
 		SELECT id, address_book_id, uid, prefix, first_name, middle_name, last_name, suffix,
 		       nickname, display_name, company, department, job_title,
@@ -249,17 +268,17 @@ func (r *AddressBookRepository) GetSyncChanges(ctx context.Context, abID uuid.UU
 		       birthday, anniversary, notes, photo_url, categories, custom_fields, starred,
 		       etag, created_at, updated_at
 		FROM contacts
-		WHERE address_book_id = $1
-		ORDER BY updated_at DESC`
+		WHERE address_book_id = $1 AND deleted_at IS NULL AND sync_seq > $2
+		ORDER BY sync_seq`
 
-	rows, err := r.db.Query(ctx, query, abID)
+	rows, err := tx.Query(ctx, query, abID, since)
 	if err != nil {
 		return nil, "", err
 	}
 	defer rows.Close()
 
 	contactRepo := &ContactRepository{db: r.db}
-	var contacts []*models.Contact
+	contacts := []*models.Contact{}
 	for rows.Next() {
 		contact := &models.Contact{}
 		if err := contactRepo.scanContactRows(rows, contact); err != nil {
@@ -267,6 +286,83 @@ func (r *AddressBookRepository) GetSyncChanges(ctx context.Context, abID uuid.UU
 		}
 		contacts = append(contacts, contact)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// An initial sync has nothing to remove
+	if since < 0 {
+		return contacts, currentToken, nil
+	}
+
+	// Tombstones of merged contacts, then of deleted ones
+	rows, err = tx.Query(ctx, `
+		SELECT id, address_book_id, uid, merged_into, deleted_at, updated_at
+		FROM contacts
+		WHERE address_book_id = $1 AND deleted_at IS NOT NULL AND sync_seq > $2
+		ORDER BY sync_seq`, abID, since)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		contact := &models.Contact{}
+		if err := rows.Scan(&contact.ID, &contact.AddressBookID, &contact.UID,
+			&contact.MergedInto, &contact.DeletedAt, &contact.UpdatedAt); err != nil {
+			return nil, "", err
+		}
+		contacts = append(contacts, contact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT uid, deleted_at
+		FROM contact_deletions
+		WHERE address_book_id = $1 AND sync_seq > $2
+		ORDER BY sync_seq`, abID, since)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		contact := &models.Contact{AddressBookID: abID}
+		var deletedAt time.Time
+		if err := rows.Scan(&contact.UID, &deletedAt); err != nil {
+			return nil, "", err
+		}
+		contact.DeletedAt = &deletedAt
+		contact.UpdatedAt = deletedAt
+		contacts = append(contacts, contact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
 
 	return contacts, currentToken, nil
 }
+
+// PruneSyncDeletions forgets contact deletions recorded before the cutoff.
+// Each address book's oldest resolvable sync position is raised past the
+// deletions forgotten, so clients that might have missed one sync in full.
+func (r *AddressBookRepository) PruneSyncDeletions(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		WITH pruned AS (
+			DELETE FROM contact_deletions WHERE deleted_at < $1
+			RETURNING address_book_id, sync_seq
+		), floors AS (
+			SELECT address_book_id, MAX(sync_seq) AS sync_seq, COUNT(*) AS pruned
+			FROM pruned GROUP BY address_book_id
+		), raised AS (
+			UPDATE address_books ab SET sync_min_seq = GREATEST(ab.sync_min_seq, f.sync_seq)
+			FROM floors f WHERE ab.id = f.address_book_id
+		)
+		SELECT COALESCE(SUM(pruned), 0)::bigint FROM floors`
+
+	var pruned int64
+	err := r.db.QueryRow(ctx, query, before).Scan(&pruned)
+	return pruned, err
+}
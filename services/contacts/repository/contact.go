@@ -79,7 +79,7 @@ func (r *ContactRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.
 		       birthday, anniversary, notes, photo_url, categories, custom_fields, starred,
 		       etag, created_at, updated_at
 		FROM contacts
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	contact := &models.Contact{}
 	err := r.scanContact(r.db.QueryRow(ctx, query, id), contact)
@@ -98,7 +98,7 @@ func (r *ContactRepository) GetByUID(ctx context.Context, addressBookID uuid.UUI
 		       birthday, anniversary, notes, photo_url, categories, custom_fields, starred,
 		       etag, created_at, updated_at
 		FROM contacts
-		WHERE address_book_id = $1 AND uid = $2`
+		WHERE address_book_id = $1 AND uid = $2 AND deleted_at IS NULL`
 
 	contact := &models.Contact{}
 	err := r.scanContact(r.db.QueryRow(ctx, query, addressBookID, uid), contact)
@@ -111,7 +111,7 @@ func (r *ContactRepository) GetByUID(ctx context.Context, addressBookID uuid.UUI
 // List retrieves contacts with filtering and pagination
 func (r *ContactRepository) List(ctx context.Context, req *models.ListContactsRequest, userID uuid.UUID) ([]*models.Contact, int, error) {
 	// Build where clause
-	where := "WHERE (ab.user_id = $1 OR abs.user_id = $1)"
+	where := "WHERE (ab.user_id = $1 OR abs.user_id = $1) AND c.deleted_at IS NULL"
 	args := []interface{}{userID}
 	argCount := 1
 
@@ -268,6 +268,41 @@ func (r *ContactRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+// MarkMerged soft-deletes contacts merged into survivorID, keeping them as
+// tombstones that point at the survivor, and moves their group memberships
+// to the survivor
+func (r *ContactRepository) MarkMerged(ctx context.Context, ids []uuid.UUID, survivorID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO contact_group_members (contact_id, group_id)
+		SELECT $2, group_id FROM contact_group_members WHERE contact_id = ANY($1)
+		ON CONFLICT DO NOTHING`,
+		ids, survivorID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM contact_group_members WHERE contact_id = ANY($1)", ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE contacts SET deleted_at = NOW(), merged_into = $2
+		WHERE id = ANY($1) AND deleted_at IS NULL`,
+		ids, survivorID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
 // DeleteByUID deletes a contact by UID
 func (r *ContactRepository) DeleteByUID(ctx context.Context, addressBookID uuid.UUID, uid string) error {
 	_, err := r.db.Exec(ctx, "DELETE FROM contacts WHERE address_book_id = $1 AND uid = $2",
@@ -275,14 +310,42 @@ func (r *ContactRepository) DeleteByUID(ctx context.Context, addressBookID uuid.
 	return err
 }
 
-// UpdatePhoto updates contact photo
-func (r *ContactRepository) UpdatePhoto(ctx context.Context, contactID uuid.UUID, photoURL string, photoData []byte) error {
-	_, err := r.db.Exec(ctx,
-		"UPDATE contacts SET photo_url = $2, photo_data = $3 WHERE id = $1",
-		contactID, photoURL, photoData)
+// UpdatePhoto updates contact photo and its thumbnails
+func (r *ContactRepository) UpdatePhoto(ctx context.Context, contactID uuid.UUID, photoURL string, photo *models.ContactPhoto) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE contacts
+		SET photo_url = $2, photo_data = $3, photo_content_type = $4, photo_small = $5, photo_medium = $6
+		WHERE id = $1`,
+		contactID, photoURL, photo.Original, photo.ContentType, photo.Small, photo.Medium)
 	return err
 }
 
+// GetPhoto retrieves the photo of a contact in the requested size
+// Returns nil data if the contact has no photo
+func (r *ContactRepository) GetPhoto(ctx context.Context, contactID uuid.UUID, size string) ([]byte, string, error) {
+	column := "photo_data"
+	switch size {
+	case models.PhotoSizeSmall:
+		column = "COALESCE(photo_small, photo_data)"
+	case models.PhotoSizeMedium:
+		column = "COALESCE(photo_medium, photo_data)"
+	}
+
+	var data []byte
+	var contentType sql.NullString
+	err := r.db.QueryRow(ctx,
+		fmt.Sprintf("SELECT %s, photo_content_type FROM contacts WHERE id = $1", column),
+		contactID).Scan(&data, &contentType)
+	if err == pgx.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, contentType.String, nil
+}
+
 // Search performs full-text search on contacts
 func (r *ContactRepository) Search(ctx context.Context, userID uuid.UUID, query string, limit int) ([]*models.Contact, error) {
 	sqlQuery := `
@@ -295,6 +358,7 @@ func (r *ContactRepository) Search(ctx context.Context, userID uuid.UUID, query
 		JOIN address_books ab ON c.address_book_id = ab.id
 		LEFT JOIN address_book_shares abs ON ab.id = abs.address_book_id
 		WHERE (ab.user_id = $1 OR abs.user_id = $1)
+		  AND c.deleted_at IS NULL
 		  AND (
 		    c.display_name ILIKE $2 OR
 		    c.company ILIKE $2 OR
@@ -323,6 +387,41 @@ func (r *ContactRepository) Search(ctx context.Context, userID uuid.UUID, query
 	return contacts, nil
 }
 
+// ListWithDates lists the contacts a user can see that have a birthday or
+// an anniversary
+func (r *ContactRepository) ListWithDates(ctx context.Context, userID uuid.UUID) ([]*models.Contact, error) {
+	query := `
+		SELECT DISTINCT c.id, c.address_book_id, c.uid, c.prefix, c.first_name, c.middle_name, c.last_name, c.suffix,
+		       c.nickname, c.display_name, c.company, c.department, c.job_title,
+		       c.emails, c.phones, c.addresses, c.urls, c.ims,
+		       c.birthday, c.anniversary, c.notes, c.photo_url, c.categories, c.custom_fields, c.starred,
+		       c.etag, c.created_at, c.updated_at
+		FROM contacts c
+		JOIN address_books ab ON c.address_book_id = ab.id
+		LEFT JOIN address_book_shares abs ON ab.id = abs.address_book_id
+		WHERE (ab.user_id = $1 OR abs.user_id = $1)
+		  AND c.deleted_at IS NULL
+		  AND (c.birthday IS NOT NULL OR c.anniversary IS NOT NULL)
+		ORDER BY c.display_name ASC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contacts []*models.Contact
+	for rows.Next() {
+		contact := &models.Contact{}
+		if err := r.scanContactRows(rows, contact); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, rows.Err()
+}
+
 // FindDuplicates finds potential duplicate contacts
 func (r *ContactRepository) FindDuplicates(ctx context.Context, userID uuid.UUID) ([]*models.DuplicateGroup, error) {
 	// Find by email
@@ -330,11 +429,11 @@ func (r *ContactRepository) FindDuplicates(ctx context.Context, userID uuid.UUID
 		SELECT c1.id, c1.display_name, c1.emails
 		FROM contacts c1
 		JOIN address_books ab ON c1.address_book_id = ab.id
-		WHERE ab.user_id = $1
+		WHERE ab.user_id = $1 AND c1.deleted_at IS NULL
 		  AND EXISTS (
 		    SELECT 1 FROM contacts c2
 		    JOIN address_books ab2 ON c2.address_book_id = ab2.id
-		    WHERE ab2.user_id = $1 AND c1.id != c2.id
+		    WHERE ab2.user_id = $1 AND c1.id != c2.id AND c2.deleted_at IS NULL
 		      AND c1.emails::text != '[]'
 		      AND c1.emails @> c2.emails
 		  )
@@ -361,7 +460,7 @@ func (r *ContactRepository) GetMultipleByUIDs(ctx context.Context, addressBookID
 		       birthday, anniversary, notes, photo_url, categories, custom_fields, starred,
 		       etag, created_at, updated_at
 		FROM contacts
-		WHERE address_book_id = $1 AND uid = ANY($2)`
+		WHERE address_book_id = $1 AND uid = ANY($2) AND deleted_at IS NULL`
 
 	rows, err := r.db.Query(ctx, query, addressBookID, uids)
 	if err != nil {
@@ -381,6 +480,38 @@ func (r *ContactRepository) GetMultipleByUIDs(ctx context.Context, addressBookID
 	return contacts, nil
 }
 
+// ListByGroups retrieves the contacts that are direct members of any of the
+// given groups, each contact once
+func (r *ContactRepository) ListByGroups(ctx context.Context, groupIDs []uuid.UUID) ([]*models.Contact, error) {
+	query := `
+		SELECT id, address_book_id, uid, prefix, first_name, middle_name, last_name, suffix,
+		       nickname, display_name, company, department, job_title,
+		       emails, phones, addresses, urls, ims,
+		       birthday, anniversary, notes, photo_url, categories, custom_fields, starred,
+		       etag, created_at, updated_at
+		FROM contacts
+		WHERE id IN (SELECT contact_id FROM contact_group_members WHERE group_id = ANY($1))
+		  AND deleted_at IS NULL
+		ORDER BY display_name ASC, id ASC`
+
+	rows, err := r.db.Query(ctx, query, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contacts []*models.Contact
+	for rows.Next() {
+		contact := &models.Contact{}
+		if err := r.scanContactRows(rows, contact); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
 func (r *ContactRepository) scanContact(row pgx.Row, contact *models.Contact) error {
 	var emailsJSON, phonesJSON, addressesJSON, urlsJSON, imsJSON, customFieldsJSON []byte
 	var birthday, anniversary sql.NullTime
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DateFeedRepository stores the tokens of users' birthday and anniversary
+// feeds
+type DateFeedRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDateFeedRepository(db *pgxpool.Pool) *DateFeedRepository {
+	return &DateFeedRepository{db: db}
+}
+
+// GetOrCreate returns a user's feed token. A user without one is given
+// token.
+func (r *DateFeedRepository) GetOrCreate(ctx context.Context, userID uuid.UUID, token string) (string, time.Time, error) {
+	query := `
+		WITH created AS (
+			INSERT INTO contact_date_feeds (user_id, token)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO NOTHING
+			RETURNING token, created_at
+		)
+		SELECT token, created_at FROM created
+		UNION ALL
+		SELECT token, created_at FROM contact_date_feeds WHERE user_id = $1
+		LIMIT 1`
+
+	var createdAt time.Time
+	err := r.db.QueryRow(ctx, query, userID, token).Scan(&token, &createdAt)
+	return token, createdAt, err
+}
+
+// Reset replaces a user's feed token, revoking the old one
+func (r *DateFeedRepository) Reset(ctx context.Context, userID uuid.UUID, token string) (time.Time, error) {
+	query := `
+		INSERT INTO contact_date_feeds (user_id, token)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token, created_at = NOW()
+		RETURNING created_at`
+
+	var createdAt time.Time
+	err := r.db.QueryRow(ctx, query, userID, token).Scan(&createdAt)
+	return createdAt, err
+}
+
+// GetUserID returns the user a feed token belongs to, or uuid.Nil
+func (r *DateFeedRepository) GetUserID(ctx context.Context, token string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.QueryRow(ctx, "SELECT user_id FROM contact_date_feeds WHERE token = $1", token).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, nil
+	}
+	return userID, err
+}
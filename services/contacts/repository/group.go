@@ -260,6 +260,63 @@ func (r *GroupRepository) GetGroupContacts(ctx context.Context, groupID uuid.UUI
 	return contactIDs, nil
 }
 
+// AddSubgroup makes memberGroupID a member of groupID
+func (r *GroupRepository) AddSubgroup(ctx context.Context, groupID, memberGroupID uuid.UUID) error {
+	query := `
+		INSERT INTO contact_group_subgroups (group_id, member_group_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	_, err := r.db.Exec(ctx, query, groupID, memberGroupID)
+	return err
+}
+
+// RemoveSubgroup removes memberGroupID from groupID
+func (r *GroupRepository) RemoveSubgroup(ctx context.Context, groupID, memberGroupID uuid.UUID) error {
+	_, err := r.db.Exec(ctx,
+		"DELETE FROM contact_group_subgroups WHERE group_id = $1 AND member_group_id = $2",
+		groupID, memberGroupID)
+	return err
+}
+
+// GetSubgroups gets the groups directly contained in a group
+func (r *GroupRepository) GetSubgroups(ctx context.Context, groupID uuid.UUID) ([]*models.ContactGroup, error) {
+	query := `
+		SELECT g.id, g.address_book_id, g.name, g.description, g.color,
+		       g.created_at, g.updated_at,
+		       (SELECT COUNT(*) FROM contact_group_members WHERE group_id = g.id) as contact_count
+		FROM contact_groups g
+		JOIN contact_group_subgroups cgs ON g.id = cgs.member_group_id
+		WHERE cgs.group_id = $1
+		ORDER BY g.name ASC`
+
+	rows, err := r.db.Query(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*models.ContactGroup
+	for rows.Next() {
+		g := &models.ContactGroup{}
+		if err := rows.Scan(
+			&g.ID,
+			&g.AddressBookID,
+			&g.Name,
+			&g.Description,
+			&g.Color,
+			&g.CreatedAt,
+			&g.UpdatedAt,
+			&g.ContactCount,
+		); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+
+	return groups, nil
+}
+
 // SetContactGroups replaces all group memberships for a contact
 func (r *GroupRepository) SetContactGroups(ctx context.Context, contactID uuid.UUID, groupIDs []uuid.UUID) error {
 	tx, err := r.db.Begin(ctx)
@@ -1,13 +1,11 @@
 package service
 
 import (
-	"bufio"
 	"bytes"
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"contacts-service/models"
 	"contacts-service/repository"
@@ -372,7 +370,36 @@ func (s *ContactService) UpdatePhoto(ctx context.Context, userID, contactID uuid
 		return fmt.Errorf("access denied")
 	}
 
-	return s.contactRepo.UpdatePhoto(ctx, contactID, photoURL, photoData)
+	photo, err := processPhoto(photoData)
+	if err != nil {
+		return err
+	}
+
+	return s.contactRepo.UpdatePhoto(ctx, contactID, photoURL, photo)
+}
+
+// GetPhoto returns the contact photo in the requested size and its content type
+// Returns nil data if the contact has no photo
+func (s *ContactService) GetPhoto(ctx context.Context, userID, contactID uuid.UUID, size string) ([]byte, string, error) {
+	contact, err := s.contactRepo.GetByID(ctx, contactID)
+	if err != nil || contact == nil {
+		return nil, "", fmt.Errorf("contact not found")
+	}
+
+	hasAccess, err := s.addressBookRepo.HasAccess(ctx, contact.AddressBookID, userID, "read")
+	if err != nil || !hasAccess {
+		return nil, "", fmt.Errorf("access denied")
+	}
+
+	data, contentType, err := s.contactRepo.GetPhoto(ctx, contactID, size)
+	if err != nil || len(data) == 0 {
+		return nil, "", err
+	}
+	if contentType == "" {
+		contentType = "image/jpeg" // Photos stored before thumbnails were introduced
+	}
+
+	return data, contentType, nil
 }
 
 // Group operations
@@ -479,7 +506,7 @@ func (s *ContactService) ImportContacts(ctx context.Context, userID uuid.UUID, r
 	switch req.Format {
 	case "vcard":
 		return s.importVCard(ctx, userID, req)
-	case "csv":
+	case CSVFormatAuto, CSVFormatGoogle, CSVFormatOutlook:
 		return s.importCSV(ctx, userID, req)
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", req.Format)
@@ -490,11 +517,10 @@ func (s *ContactService) importVCard(ctx context.Context, userID uuid.UUID, req
 	result := &models.ImportResult{}
 
 	// Parse vCard data
-	vcards := parseVCards(req.Data)
-	result.Total = len(vcards)
+	contacts := ParseVCards(req.Data)
+	result.Total = len(contacts)
 
-	for _, vcard := range vcards {
-		contact := vCardToContact(vcard)
+	for _, contact := range contacts {
 		contact.AddressBookID = req.AddressBookID
 		contact.ID = uuid.New()
 		contact.UID = fmt.Sprintf("%s@contacts.local", uuid.New().String())
@@ -510,13 +536,66 @@ func (s *ContactService) importVCard(ctx context.Context, userID uuid.UUID, req
 	return result, nil
 }
 
+// csvPreviewLimit is the number of parsed contacts returned by a dry run
+const csvPreviewLimit = 10
+
+// importCSV imports a Google or Outlook CSV export. With req.Format "csv"
+// the export format is detected from the header row.
 func (s *ContactService) importCSV(ctx context.Context, userID uuid.UUID, req *models.ImportRequest) (*models.ImportResult, error) {
-	result := &models.ImportResult{}
-	// CSV import implementation would go here
-	return result, fmt.Errorf("CSV import not yet implemented")
+	hasAccess, err := s.addressBookRepo.HasAccess(ctx, req.AddressBookID, userID, "write")
+	if err != nil || !hasAccess {
+		return nil, fmt.Errorf("access denied to address book")
+	}
+
+	parsed, err := ParseContactsCSV(req.Data, req.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ImportResult{
+		Total:           len(parsed.Contacts) + len(parsed.Errors),
+		Skipped:         len(parsed.Errors),
+		Errors:          parsed.Errors,
+		Format:          parsed.Format,
+		UnmappedColumns: parsed.Unmapped,
+		DryRun:          req.DryRun,
+	}
+
+	if req.DryRun {
+		preview := parsed.Contacts
+		if len(preview) > csvPreviewLimit {
+			preview = preview[:csvPreviewLimit]
+		}
+		result.Preview = preview
+		return result, nil
+	}
+
+	for _, contact := range parsed.Contacts {
+		contact.AddressBookID = req.AddressBookID
+		contact.ID = uuid.New()
+		contact.UID = fmt.Sprintf("%s@contacts.local", uuid.New().String())
+
+		if err := s.contactRepo.Create(ctx, contact); err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to import: %s - %v", contact.DisplayName, err))
+		} else {
+			result.Imported++
+		}
+	}
+
+	return result, nil
 }
 
-func (s *ContactService) ExportContacts(ctx context.Context, userID uuid.UUID, addressBookID uuid.UUID, format string) (string, error) {
+// ExportContacts serializes an address book. version selects the vCard
+// version and defaults to 3.0.
+func (s *ContactService) ExportContacts(ctx context.Context, userID uuid.UUID, addressBookID uuid.UUID, format, version string) (string, error) {
+	if version == "" {
+		version = VCardVersion3
+	}
+	if !IsSupportedVCardVersion(version) {
+		return "", fmt.Errorf("unsupported vCard version: %s", version)
+	}
+
 	contacts, _, err := s.contactRepo.List(ctx, &models.ListContactsRequest{
 		AddressBookID: addressBookID,
 		Limit:         10000,
@@ -527,16 +606,16 @@ func (s *ContactService) ExportContacts(ctx context.Context, userID uuid.UUID, a
 
 	switch format {
 	case "vcard":
-		return s.exportVCard(contacts), nil
+		return s.exportVCard(contacts, version), nil
 	default:
 		return "", fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
-func (s *ContactService) exportVCard(contacts []*models.Contact) string {
+func (s *ContactService) exportVCard(contacts []*models.Contact, version string) string {
 	var buf bytes.Buffer
 	for _, c := range contacts {
-		buf.WriteString(contactToVCard(c))
+		buf.WriteString(FormatVCard(c, VCardOptions{Version: version}))
 		buf.WriteString("\r\n")
 	}
 	return buf.String()
@@ -544,50 +623,6 @@ func (s *ContactService) exportVCard(contacts []*models.Contact) string {
 
 // Merge duplicates
 
-func (s *ContactService) MergeContacts(ctx context.Context, userID uuid.UUID, req *models.MergeRequest) (*models.Contact, error) {
-	// Get primary contact
-	primary, err := s.contactRepo.GetByID(ctx, req.PrimaryID)
-	if err != nil || primary == nil {
-		return nil, fmt.Errorf("primary contact not found")
-	}
-
-	// Merge data from other contacts
-	for _, mergeID := range req.MergeIDs {
-		if mergeID == req.PrimaryID {
-			continue
-		}
-
-		mergeContact, err := s.contactRepo.GetByID(ctx, mergeID)
-		if err != nil || mergeContact == nil {
-			continue
-		}
-
-		// Merge emails
-		for _, e := range mergeContact.Emails {
-			if !containsEmail(primary.Emails, e.Email) {
-				primary.Emails = append(primary.Emails, e)
-			}
-		}
-
-		// Merge phones
-		for _, p := range mergeContact.Phones {
-			if !containsPhone(primary.Phones, p.Number) {
-				primary.Phones = append(primary.Phones, p)
-			}
-		}
-
-		// Delete merged contact
-		s.contactRepo.Delete(ctx, mergeID)
-	}
-
-	// Update primary
-	if err := s.contactRepo.Update(ctx, primary); err != nil {
-		return nil, err
-	}
-
-	return primary, nil
-}
-
 func (s *ContactService) FindDuplicates(ctx context.Context, userID uuid.UUID) ([]*models.DuplicateGroup, error) {
 	return s.contactRepo.FindDuplicates(ctx, userID)
 }
@@ -602,8 +637,26 @@ func (s *ContactService) GetMultipleContactsByUID(ctx context.Context, addressBo
 	return s.contactRepo.GetMultipleByUIDs(ctx, addressBookID, uids)
 }
 
+// GetSyncChanges returns the contacts changed in an address book since the
+// client's sync token, with removed ones as tombstones, and the new token. An
+// empty token asks for every contact. A token the address book can't resolve,
+// because it is malformed or older than the retained changes, returns
+// ErrInvalidSyncToken and the client has to sync in full.
 func (s *ContactService) GetSyncChanges(ctx context.Context, addressBookID uuid.UUID, syncToken string) ([]*models.Contact, string, error) {
-	return s.addressBookRepo.GetSyncChanges(ctx, addressBookID, syncToken)
+	since := int64(-1)
+	if syncToken != "" {
+		seq, err := parseSyncToken(syncToken)
+		if err != nil {
+			return nil, "", err
+		}
+		since = seq
+	}
+
+	contacts, token, err := s.addressBookRepo.GetSyncChanges(ctx, addressBookID, since)
+	if errors.Is(err, repository.ErrSyncTokenExpired) {
+		return nil, "", ErrInvalidSyncToken
+	}
+	return contacts, token, err
 }
 
 func (s *ContactService) CreateOrUpdateContact(ctx context.Context, userID, addressBookID uuid.UUID, uid string, contact *models.Contact) error {
@@ -628,161 +681,3 @@ func (s *ContactService) CreateOrUpdateContact(ctx context.Context, userID, addr
 func (s *ContactService) DeleteContactByUID(ctx context.Context, addressBookID uuid.UUID, uid string) error {
 	return s.contactRepo.DeleteByUID(ctx, addressBookID, uid)
 }
-
-// Helper functions
-
-func parseVCards(data string) []map[string]string {
-	var vcards []map[string]string
-	var current map[string]string
-
-	scanner := bufio.NewScanner(strings.NewReader(data))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		if strings.HasPrefix(line, "BEGIN:VCARD") {
-			current = make(map[string]string)
-		} else if strings.HasPrefix(line, "END:VCARD") {
-			if current != nil {
-				vcards = append(vcards, current)
-			}
-			current = nil
-		} else if current != nil && strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				key := strings.Split(parts[0], ";")[0]
-				current[key] = parts[1]
-			}
-		}
-	}
-
-	return vcards
-}
-
-func vCardToContact(vcard map[string]string) *models.Contact {
-	contact := &models.Contact{}
-
-	if fn := vcard["FN"]; fn != "" {
-		contact.DisplayName = fn
-	}
-
-	if n := vcard["N"]; n != "" {
-		parts := strings.Split(n, ";")
-		if len(parts) >= 1 {
-			contact.LastName = parts[0]
-		}
-		if len(parts) >= 2 {
-			contact.FirstName = parts[1]
-		}
-		if len(parts) >= 3 {
-			contact.MiddleName = parts[2]
-		}
-		if len(parts) >= 4 {
-			contact.Prefix = parts[3]
-		}
-		if len(parts) >= 5 {
-			contact.Suffix = parts[4]
-		}
-	}
-
-	if org := vcard["ORG"]; org != "" {
-		contact.Company = strings.Split(org, ";")[0]
-	}
-
-	if title := vcard["TITLE"]; title != "" {
-		contact.JobTitle = title
-	}
-
-	if email := vcard["EMAIL"]; email != "" {
-		contact.Emails = append(contact.Emails, models.ContactEmail{
-			Type:    "work",
-			Email:   email,
-			Primary: true,
-		})
-	}
-
-	if tel := vcard["TEL"]; tel != "" {
-		contact.Phones = append(contact.Phones, models.ContactPhone{
-			Type:    "work",
-			Number:  tel,
-			Primary: true,
-		})
-	}
-
-	if note := vcard["NOTE"]; note != "" {
-		contact.Notes = note
-	}
-
-	if bday := vcard["BDAY"]; bday != "" {
-		if t, err := time.Parse("20060102", bday); err == nil {
-			contact.Birthday = &t
-		}
-	}
-
-	return contact
-}
-
-func contactToVCard(c *models.Contact) string {
-	var buf bytes.Buffer
-	buf.WriteString("BEGIN:VCARD\r\n")
-	buf.WriteString("VERSION:3.0\r\n")
-	buf.WriteString(fmt.Sprintf("UID:%s\r\n", c.UID))
-	buf.WriteString(fmt.Sprintf("FN:%s\r\n", c.DisplayName))
-	buf.WriteString(fmt.Sprintf("N:%s;%s;%s;%s;%s\r\n",
-		c.LastName, c.FirstName, c.MiddleName, c.Prefix, c.Suffix))
-
-	if c.Company != "" {
-		buf.WriteString(fmt.Sprintf("ORG:%s\r\n", c.Company))
-	}
-	if c.JobTitle != "" {
-		buf.WriteString(fmt.Sprintf("TITLE:%s\r\n", c.JobTitle))
-	}
-
-	for _, e := range c.Emails {
-		buf.WriteString(fmt.Sprintf("EMAIL;TYPE=%s:%s\r\n", strings.ToUpper(e.Type), e.Email))
-	}
-
-	for _, p := range c.Phones {
-		buf.WriteString(fmt.Sprintf("TEL;TYPE=%s:%s\r\n", strings.ToUpper(p.Type), p.Number))
-	}
-
-	for _, a := range c.Addresses {
-		buf.WriteString(fmt.Sprintf("ADR;TYPE=%s:;;%s;%s;%s;%s;%s\r\n",
-			strings.ToUpper(a.Type), a.Street, a.City, a.State, a.PostalCode, a.Country))
-	}
-
-	if c.Birthday != nil {
-		buf.WriteString(fmt.Sprintf("BDAY:%s\r\n", c.Birthday.Format("20060102")))
-	}
-
-	if c.Notes != "" {
-		buf.WriteString(fmt.Sprintf("NOTE:%s\r\n", c.Notes))
-	}
-
-	if len(c.PhotoData) > 0 {
-		encoded := base64.StdEncoding.EncodeToString(c.PhotoData)
-		buf.WriteString(fmt.Sprintf("PHOTO;ENCODING=b;TYPE=JPEG:%s\r\n", encoded))
-	}
-
-	buf.WriteString(fmt.Sprintf("REV:%s\r\n", c.UpdatedAt.Format("20060102T150405Z")))
-	buf.WriteString("END:VCARD\r\n")
-
-	return buf.String()
-}
-
-func containsEmail(emails []models.ContactEmail, email string) bool {
-	for _, e := range emails {
-		if strings.EqualFold(e.Email, email) {
-			return true
-		}
-	}
-	return false
-}
-
-func containsPhone(phones []models.ContactPhone, number string) bool {
-	for _, p := range phones {
-		if p.Number == number {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,593 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"contacts-service/models"
+)
+
+// CSV import formats. CSVFormatAuto inspects the header row to pick one of
+// the others.
+const (
+	CSVFormatAuto    = "csv"
+	CSVFormatGoogle  = "google"
+	CSVFormatOutlook = "outlook"
+	CSVFormatGeneric = "generic"
+)
+
+// googleMultiValueSeparator joins several values in one Google Contacts cell
+const googleMultiValueSeparator = ":::"
+
+// CSVImport is a parsed contacts CSV export
+type CSVImport struct {
+	// Format is the detected or requested format
+	Format   string
+	Contacts []*models.Contact
+	// Unmapped lists header columns that do not map to a contact field
+	Unmapped []string
+	// Errors describes rows that were skipped
+	Errors []string
+}
+
+// csvField is the contact field a column maps to
+type csvField int
+
+const (
+	csvUnmapped csvField = iota
+	csvPrefix
+	csvFirstName
+	csvMiddleName
+	csvLastName
+	csvSuffix
+	csvNickname
+	csvDisplayName
+	csvCompany
+	csvDepartment
+	csvJobTitle
+	csvNotes
+	csvBirthday
+	csvAnniversary
+	csvCategories
+	csvWebsite
+	csvEmail
+	csvEmailType
+	csvPhone
+	csvPhoneType
+	csvAddressType
+	csvStreet
+	csvCity
+	csvState
+	csvPostalCode
+	csvCountry
+)
+
+// csvColumn describes how one header column is applied to a contact.
+// Multi-value fields (emails, phones, addresses) are grouped by slot, so
+// "E-mail 2 - Type" and "E-mail 2 - Value" describe the same email.
+type csvColumn struct {
+	field csvField
+	slot  string
+	typ   string // type implied by the column name, e.g. work for "Business Phone"
+}
+
+// Columns common to every format, keyed by lowercased header
+var csvCommonColumns = map[string]csvColumn{
+	"first name":              {field: csvFirstName},
+	"given name":              {field: csvFirstName},
+	"middle name":             {field: csvMiddleName},
+	"additional name":         {field: csvMiddleName},
+	"last name":               {field: csvLastName},
+	"family name":             {field: csvLastName},
+	"surname":                 {field: csvLastName},
+	"name prefix":             {field: csvPrefix},
+	"prefix":                  {field: csvPrefix},
+	"name suffix":             {field: csvSuffix},
+	"suffix":                  {field: csvSuffix},
+	"nickname":                {field: csvNickname},
+	"name":                    {field: csvDisplayName},
+	"display name":            {field: csvDisplayName},
+	"full name":               {field: csvDisplayName},
+	"company":                 {field: csvCompany},
+	"organization":            {field: csvCompany},
+	"organization name":       {field: csvCompany},
+	"department":              {field: csvDepartment},
+	"organization department": {field: csvDepartment},
+	"job title":               {field: csvJobTitle},
+	"organization title":      {field: csvJobTitle},
+	"notes":                   {field: csvNotes},
+	"note":                    {field: csvNotes},
+	"birthday":                {field: csvBirthday},
+	"anniversary":             {field: csvAnniversary},
+	"categories":              {field: csvCategories},
+	"labels":                  {field: csvCategories},
+	"group membership":        {field: csvCategories},
+	"web page":                {field: csvWebsite},
+	"website":                 {field: csvWebsite},
+	"email":                   {field: csvEmail, slot: "1"},
+	"e-mail":                  {field: csvEmail, slot: "1"},
+	"email address":           {field: csvEmail, slot: "1"},
+	"e-mail address":          {field: csvEmail, slot: "1"},
+	"phone":                   {field: csvPhone, slot: "1"},
+	"phone number":            {field: csvPhone, slot: "1"},
+	"telephone":               {field: csvPhone, slot: "1"},
+	"mobile":                  {field: csvPhone, slot: "mobile", typ: "mobile"},
+}
+
+// Outlook uses Title for the honorific; elsewhere it is the job title
+var csvOutlookColumns = map[string]csvColumn{
+	"title": {field: csvPrefix},
+	"pager": {field: csvPhone, slot: "pager", typ: "other"},
+}
+
+var csvGenericColumns = map[string]csvColumn{
+	"title": {field: csvJobTitle},
+}
+
+var (
+	// Google: "E-mail 1 - Value", "Phone 2 - Type", "Address 1 - City", ...
+	googleMultiValueColumn = regexp.MustCompile(`^(e-mail|phone|address|website|organization) (\d+) - (.+)$`)
+
+	// Outlook: "E-mail 2 Address"
+	outlookEmailColumn = regexp.MustCompile(`^e-mail (\d+) address$`)
+
+	// Outlook: "Business Phone", "Home Phone 2", "Business Fax", ...
+	outlookPhoneColumn = regexp.MustCompile(`^(business|home|mobile|other|primary|company main|car|assistant's|radio) (phone|fax)( \d+)?$`)
+
+	// Outlook: "Business Street", "Home Postal Code", "Other Country/Region", ...
+	outlookAddressColumn = regexp.MustCompile(`^(business|home|other) (street|street 2|street 3|city|state|postal code|country/region|country)$`)
+)
+
+// ParseContactsCSV parses a Google Contacts or Outlook CSV export, or a
+// generic CSV with recognizable headers. format is CSVFormatAuto to detect
+// the format from the header row. Quoted fields may span lines, and a
+// leading UTF-8 byte order mark is ignored.
+func ParseContactsCSV(data string, format string) (*CSVImport, error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV data is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	for i, h := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+	}
+
+	if format == "" || format == CSVFormatAuto {
+		format = DetectCSVFormat(header)
+	}
+
+	result := &CSVImport{Format: format}
+	columns := make([]csvColumn, len(header))
+	for i, h := range header {
+		columns[i] = mapCSVColumn(h, format)
+		if columns[i].field == csvUnmapped && h != "" {
+			result.Unmapped = append(result.Unmapped, h)
+		}
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+
+		contact := buildCSVContact(columns, record, format)
+		if contact == nil {
+			if !isBlankCSVRecord(record) {
+				result.Errors = append(result.Errors,
+					fmt.Sprintf("Line %d: no name, email, phone or company", line))
+			}
+			continue
+		}
+		result.Contacts = append(result.Contacts, contact)
+	}
+
+	return result, nil
+}
+
+// DetectCSVFormat identifies Google Contacts and Outlook exports from their
+// header row. Anything else is treated as a generic CSV.
+func DetectCSVFormat(header []string) string {
+	for _, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch {
+		case googleMultiValueColumn.MatchString(h), h == "group membership", h == "given name":
+			return CSVFormatGoogle
+		case h == "e-mail address", h == "e-mail display name", outlookEmailColumn.MatchString(h),
+			outlookPhoneColumn.MatchString(h), outlookAddressColumn.MatchString(h):
+			return CSVFormatOutlook
+		}
+	}
+	return CSVFormatGeneric
+}
+
+// mapCSVColumn returns the contact field for a header in the given format
+func mapCSVColumn(header, format string) csvColumn {
+	h := strings.ToLower(strings.TrimSpace(header))
+
+	overrides := csvGenericColumns
+	if format == CSVFormatOutlook {
+		overrides = csvOutlookColumns
+	}
+	if col, ok := overrides[h]; ok {
+		return col
+	}
+	if col, ok := csvCommonColumns[h]; ok {
+		return col
+	}
+
+	if m := googleMultiValueColumn.FindStringSubmatch(h); m != nil {
+		return googleColumn(m[1], m[2], m[3])
+	}
+	if m := outlookEmailColumn.FindStringSubmatch(h); m != nil {
+		return csvColumn{field: csvEmail, slot: m[1]}
+	}
+	if m := outlookPhoneColumn.FindStringSubmatch(h); m != nil {
+		typ := csvContactType(m[1])
+		if m[2] == "fax" {
+			typ = "fax"
+		}
+		return csvColumn{field: csvPhone, slot: h, typ: typ}
+	}
+	if m := outlookAddressColumn.FindStringSubmatch(h); m != nil {
+		fields := map[string]csvField{
+			"street": csvStreet, "street 2": csvStreet, "street 3": csvStreet,
+			"city": csvCity, "state": csvState, "postal code": csvPostalCode,
+			"country/region": csvCountry, "country": csvCountry,
+		}
+		return csvColumn{field: fields[m[2]], slot: m[1], typ: csvContactType(m[1])}
+	}
+
+	return csvColumn{}
+}
+
+// googleColumn maps a Google "<kind> <n> - <part>" column. Newer exports
+// say Label where older ones say Type.
+func googleColumn(kind, slot, part string) csvColumn {
+	typed := part == "type" || part == "label"
+
+	switch kind {
+	case "e-mail":
+		if typed {
+			return csvColumn{field: csvEmailType, slot: slot}
+		}
+		if part == "value" {
+			return csvColumn{field: csvEmail, slot: slot}
+		}
+	case "phone":
+		if typed {
+			return csvColumn{field: csvPhoneType, slot: slot}
+		}
+		if part == "value" {
+			return csvColumn{field: csvPhone, slot: slot}
+		}
+	case "address":
+		fields := map[string]csvField{
+			"type": csvAddressType, "label": csvAddressType,
+			"street": csvStreet, "extended address": csvStreet, "po box": csvStreet,
+			"city": csvCity, "region": csvState, "postal code": csvPostalCode, "country": csvCountry,
+		}
+		if f, ok := fields[part]; ok {
+			return csvColumn{field: f, slot: slot}
+		}
+	case "website":
+		if part == "value" {
+			return csvColumn{field: csvWebsite}
+		}
+	case "organization":
+		fields := map[string]csvField{"name": csvCompany, "title": csvJobTitle, "department": csvDepartment}
+		if f, ok := fields[part]; ok && slot == "1" {
+			return csvColumn{field: f}
+		}
+	}
+	return csvColumn{}
+}
+
+// csvSlot collects the columns of one multi-value entry
+type csvSlot struct {
+	values  []string
+	typ     string
+	primary bool
+	address models.ContactAddress
+}
+
+// buildCSVContact applies one record to a new contact. It returns nil if
+// the record has nothing to identify a contact by.
+func buildCSVContact(columns []csvColumn, record []string, format string) *models.Contact {
+	c := &models.Contact{}
+
+	var emailOrder, phoneOrder, addrOrder []string
+	emails := map[string]*csvSlot{}
+	phones := map[string]*csvSlot{}
+	addrs := map[string]*csvSlot{}
+	slotFor := func(slots map[string]*csvSlot, order *[]string, key, typ string) *csvSlot {
+		s, ok := slots[key]
+		if !ok {
+			s = &csvSlot{typ: typ}
+			slots[key] = s
+			*order = append(*order, key)
+		}
+		return s
+	}
+
+	for i, value := range record {
+		if i >= len(columns) {
+			break
+		}
+		col := columns[i]
+		value = strings.TrimSpace(value)
+		if value == "" || col.field == csvUnmapped {
+			continue
+		}
+
+		switch col.field {
+		case csvPrefix:
+			c.Prefix = value
+		case csvFirstName:
+			c.FirstName = value
+		case csvMiddleName:
+			c.MiddleName = value
+		case csvLastName:
+			c.LastName = value
+		case csvSuffix:
+			c.Suffix = value
+		case csvNickname:
+			c.Nickname = value
+		case csvDisplayName:
+			c.DisplayName = value
+		case csvCompany:
+			c.Company = value
+		case csvDepartment:
+			c.Department = value
+		case csvJobTitle:
+			c.JobTitle = value
+		case csvNotes:
+			c.Notes = value
+		case csvBirthday:
+			if t, ok := parseCSVDate(value); ok {
+				c.Birthday = &t
+			}
+		case csvAnniversary:
+			if t, ok := parseCSVDate(value); ok {
+				c.Anniversary = &t
+			}
+		case csvCategories:
+			c.Categories = append(c.Categories, splitCSVCategories(value, format)...)
+		case csvWebsite:
+			for _, u := range splitCSVValues(value) {
+				c.URLs = append(c.URLs, models.ContactURL{Type: "other", URL: u})
+			}
+		case csvEmail:
+			s := slotFor(emails, &emailOrder, col.slot, col.typ)
+			s.values = append(s.values, splitCSVValues(value)...)
+		case csvEmailType:
+			s := slotFor(emails, &emailOrder, col.slot, col.typ)
+			s.typ, s.primary = parseCSVType(value)
+		case csvPhone:
+			s := slotFor(phones, &phoneOrder, col.slot, col.typ)
+			s.values = append(s.values, splitCSVValues(value)...)
+		case csvPhoneType:
+			s := slotFor(phones, &phoneOrder, col.slot, col.typ)
+			s.typ, s.primary = parseCSVType(value)
+		case csvAddressType:
+			s := slotFor(addrs, &addrOrder, col.slot, col.typ)
+			s.typ, s.primary = parseCSVType(value)
+		case csvStreet:
+			s := slotFor(addrs, &addrOrder, col.slot, col.typ)
+			s.address.Street = strings.TrimSpace(s.address.Street + "\n" + value)
+		case csvCity:
+			slotFor(addrs, &addrOrder, col.slot, col.typ).address.City = value
+		case csvState:
+			slotFor(addrs, &addrOrder, col.slot, col.typ).address.State = value
+		case csvPostalCode:
+			slotFor(addrs, &addrOrder, col.slot, col.typ).address.PostalCode = value
+		case csvCountry:
+			slotFor(addrs, &addrOrder, col.slot, col.typ).address.Country = value
+		}
+	}
+
+	for _, key := range emailOrder {
+		s := emails[key]
+		for _, v := range s.values {
+			c.Emails = append(c.Emails, models.ContactEmail{
+				Type:    emailCSVType(s.typ),
+				Email:   v,
+				Primary: s.primary,
+			})
+		}
+	}
+	for _, key := range phoneOrder {
+		s := phones[key]
+		for _, v := range s.values {
+			c.Phones = append(c.Phones, models.ContactPhone{
+				Type:    phoneCSVType(s.typ),
+				Number:  v,
+				Primary: s.primary,
+			})
+		}
+	}
+	for _, key := range addrOrder {
+		s := addrs[key]
+		a := s.address
+		if a.Street == "" && a.City == "" && a.State == "" && a.PostalCode == "" && a.Country == "" {
+			continue
+		}
+		a.Type = emailCSVType(s.typ)
+		a.Primary = s.primary
+		c.Addresses = append(c.Addresses, a)
+	}
+
+	// Without an explicit preference the first entry is primary
+	if len(c.Emails) > 0 && !hasPrimaryEmail(c.Emails) {
+		c.Emails[0].Primary = true
+	}
+	if len(c.Phones) > 0 && !hasPrimaryPhone(c.Phones) {
+		c.Phones[0].Primary = true
+	}
+
+	if c.DisplayName == "" {
+		c.DisplayName = strings.TrimSpace(c.FirstName + " " + c.LastName)
+	}
+	if c.DisplayName == "" && c.Company != "" {
+		c.DisplayName = c.Company
+	}
+	if c.DisplayName == "" && len(c.Emails) > 0 {
+		c.DisplayName = c.Emails[0].Email
+	}
+	if c.DisplayName == "" && len(c.Phones) > 0 {
+		c.DisplayName = c.Phones[0].Number
+	}
+	if c.DisplayName == "" {
+		return nil
+	}
+
+	return c
+}
+
+// parseCSVType reads a Google type label. A leading "* " marks the
+// primary entry.
+func parseCSVType(value string) (string, bool) {
+	primary := strings.HasPrefix(value, "*")
+	value = strings.TrimSpace(strings.TrimPrefix(value, "*"))
+	return strings.ToLower(value), primary
+}
+
+// csvContactType maps a column prefix such as Business or Home to a type
+func csvContactType(prefix string) string {
+	switch prefix {
+	case "business", "company main":
+		return "work"
+	case "home":
+		return "home"
+	case "mobile", "car":
+		return "mobile"
+	default:
+		return "other"
+	}
+}
+
+// emailCSVType normalizes an email or address type to home, work or other
+func emailCSVType(t string) string {
+	switch t {
+	case "home":
+		return "home"
+	case "work", "business":
+		return "work"
+	default:
+		return "other"
+	}
+}
+
+// phoneCSVType normalizes a phone type to home, work, mobile, fax or other
+func phoneCSVType(t string) string {
+	switch {
+	case strings.Contains(t, "fax"):
+		return "fax"
+	case t == "mobile" || t == "cell":
+		return "mobile"
+	default:
+		return emailCSVType(t)
+	}
+}
+
+// splitCSVValues splits a Google cell holding several values
+func splitCSVValues(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, googleMultiValueSeparator) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// splitCSVCategories splits a category cell. Google separates labels with
+// ":::" and adds the system label "* myContacts" to every contact; Outlook
+// separates categories with ";".
+func splitCSVCategories(value, format string) []string {
+	sep := googleMultiValueSeparator
+	if format == CSVFormatOutlook || (format == CSVFormatGeneric && strings.Contains(value, ";")) {
+		sep = ";"
+	}
+
+	var categories []string
+	for _, v := range strings.Split(value, sep) {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.HasPrefix(v, "*") {
+			continue
+		}
+		categories = append(categories, v)
+	}
+	return categories
+}
+
+// parseCSVDate parses the date formats used by Google (ISO 8601, or --MM-DD
+// without a year) and Outlook (M/D/YYYY, with 0/0/00 meaning empty)
+func parseCSVDate(value string) (time.Time, bool) {
+	if t, ok := parseVCardDate(value); ok {
+		return t, true
+	}
+
+	parts := strings.Split(value, "/")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	month, err1 := strconv.Atoi(parts[0])
+	day, err2 := strconv.Atoi(parts[1])
+	year, err3 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil || month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+	if len(parts[2]) <= 2 {
+		year += 1900
+		if year < 1950 {
+			year += 100
+		}
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), true
+}
+
+func isBlankCSVRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func hasPrimaryEmail(emails []models.ContactEmail) bool {
+	for _, e := range emails {
+		if e.Primary {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrimaryPhone(phones []models.ContactPhone) bool {
+	for _, p := range phones {
+		if p.Primary {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"contacts-service/models"
+	"contacts-service/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// dateFeedYearless is the year events for dates without a year start in.
+// It is a leap year, so February 29 exists.
+const dateFeedYearless = 2000
+
+// DateFeedContentType is the media type of the feed
+const DateFeedContentType = "text/calendar; charset=utf-8"
+
+// maxFeedReminderMinutes bounds a reminder given in minutes (four weeks)
+const maxFeedReminderMinutes = 40320
+
+// feedDurationPattern matches an RFC 5545 duration usable as a TRIGGER
+var feedDurationPattern = regexp.MustCompile(`^[+-]?P(\d+W|\d+D(T(\d+H)?(\d+M)?(\d+S)?)?|T(\d+H)?(\d+M)?(\d+S)?)$`)
+
+// DateFeedService serves the read-only iCalendar feed of a user's
+// contacts' birthdays and anniversaries, which calendar clients subscribe
+// to with a URL holding a per-user token
+type DateFeedService struct {
+	contactRepo *repository.ContactRepository
+	feedRepo    *repository.DateFeedRepository
+	logger      *zap.Logger
+}
+
+func NewDateFeedService(contactRepo *repository.ContactRepository, feedRepo *repository.DateFeedRepository, logger *zap.Logger) *DateFeedService {
+	return &DateFeedService{
+		contactRepo: contactRepo,
+		feedRepo:    feedRepo,
+		logger:      logger,
+	}
+}
+
+// GetToken returns a user's feed token, creating it on first use
+func (s *DateFeedService) GetToken(ctx context.Context, userID uuid.UUID) (string, time.Time, error) {
+	token, err := newFeedToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return s.feedRepo.GetOrCreate(ctx, userID, token)
+}
+
+// ResetToken gives a user a new feed token; the old subscription URL stops
+// working
+func (s *DateFeedService) ResetToken(ctx context.Context, userID uuid.UUID) (string, time.Time, error) {
+	token, err := newFeedToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	createdAt, err := s.feedRepo.Reset(ctx, userID, token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	s.logger.Info("Date feed token reset", zap.String("user_id", userID.String()))
+	return token, createdAt, nil
+}
+
+// Feed returns the iCalendar feed for a token. reminder, if set, adds an
+// alarm to every event: minutes before the day starts, or an RFC 5545
+// duration relative to it such as -P1D or PT9H.
+func (s *DateFeedService) Feed(ctx context.Context, token, reminder string) (string, error) {
+	trigger, err := ParseFeedReminder(reminder)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := s.feedRepo.GetUserID(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	if userID == uuid.Nil {
+		return "", fmt.Errorf("feed not found")
+	}
+
+	contacts, err := s.contactRepo.ListWithDates(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatDateFeed(contacts, trigger), nil
+}
+
+func newFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate feed token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ParseFeedReminder turns the feed's reminder parameter into a VALARM
+// TRIGGER. A number is minutes before the day starts; anything else must
+// be an RFC 5545 duration. An empty reminder means no alarm.
+func ParseFeedReminder(reminder string) (string, error) {
+	if reminder == "" {
+		return "", nil
+	}
+
+	if minutes, err := strconv.Atoi(reminder); err == nil {
+		if minutes < 0 || minutes > maxFeedReminderMinutes {
+			return "", fmt.Errorf("invalid reminder: minutes must be between 0 and %d", maxFeedReminderMinutes)
+		}
+		return fmt.Sprintf("-PT%dM", minutes), nil
+	}
+
+	trigger := strings.ToUpper(reminder)
+	if !feedDurationPattern.MatchString(trigger) || strings.HasSuffix(trigger, "T") {
+		return "", fmt.Errorf("invalid reminder: %s", reminder)
+	}
+	return trigger, nil
+}
+
+// FormatDateFeed serializes contacts' birthdays and anniversaries as a
+// calendar of yearly all-day events. trigger, if set, adds a VALARM.
+func FormatDateFeed(contacts []*models.Contact, trigger string) string {
+	// Content lines fold the same way in iCalendar as in vCard
+	w := &vCardWriter{}
+	w.line("BEGIN", nil, "VCALENDAR")
+	w.line("VERSION", nil, "2.0")
+	w.line("PRODID", nil, "-//OONRUMAIL//Contacts//EN")
+	w.line("CALSCALE", nil, "GREGORIAN")
+	w.line("METHOD", nil, "PUBLISH")
+	w.line("X-WR-CALNAME", nil, "Birthdays and anniversaries")
+	w.line("REFRESH-INTERVAL", []string{"VALUE=DURATION"}, "PT12H")
+	w.line("X-PUBLISHED-TTL", nil, "PT12H")
+
+	for _, c := range contacts {
+		name := contactName(c)
+		if c.Birthday != nil {
+			writeDateEvent(w, c, "birthday", name+"'s birthday", "Born", *c.Birthday, trigger)
+		}
+		if c.Anniversary != nil {
+			writeDateEvent(w, c, "anniversary", name+"'s anniversary", "Since", *c.Anniversary, trigger)
+		}
+	}
+
+	w.line("END", nil, "VCALENDAR")
+	return w.buf.String()
+}
+
+// writeDateEvent writes a yearly all-day event for a contact's date. A
+// date stored without a year starts in dateFeedYearless. An event on
+// February 29 falls on the last day of February, so it's on the 28th in
+// other years rather than skipped.
+func writeDateEvent(w *vCardWriter, c *models.Contact, kind, summary, since string, date time.Time, trigger string) {
+	year := date.Year()
+	yearless := year == yearlessDateYear
+	if yearless {
+		year = dateFeedYearless
+	}
+	start := time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	rrule := "FREQ=YEARLY"
+	if start.Month() == time.February && start.Day() == 29 {
+		rrule = "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1"
+	}
+
+	w.line("BEGIN", nil, "VEVENT")
+	w.line("UID", nil, escapeVCardText(c.UID+"-"+kind))
+	w.line("DTSTAMP", nil, c.UpdatedAt.UTC().Format("20060102T150405Z"))
+	w.line("DTSTART", []string{"VALUE=DATE"}, start.Format("20060102"))
+	w.line("DTEND", []string{"VALUE=DATE"}, start.AddDate(0, 0, 1).Format("20060102"))
+	w.line("RRULE", nil, rrule)
+	w.line("SUMMARY", nil, escapeVCardText(summary))
+	if !yearless {
+		w.line("DESCRIPTION", nil, fmt.Sprintf("%s %d", since, year))
+	}
+	w.line("CATEGORIES", nil, strings.ToUpper(kind[:1])+kind[1:])
+	w.line("TRANSP", nil, "TRANSPARENT")
+	if trigger != "" {
+		w.line("BEGIN", nil, "VALARM")
+		w.line("ACTION", nil, "DISPLAY")
+		w.line("DESCRIPTION", nil, escapeVCardText(summary))
+		w.line("TRIGGER", nil, trigger)
+		w.line("END", nil, "VALARM")
+	}
+	w.line("END", nil, "VEVENT")
+}
+
+// contactName is the name a contact's events are titled with
+func contactName(c *models.Contact) string {
+	if c.DisplayName != "" {
+		return c.DisplayName
+	}
+	if name := strings.TrimSpace(c.FirstName + " " + c.LastName); name != "" {
+		return name
+	}
+	if c.Company != "" {
+		return c.Company
+	}
+	if len(c.Emails) > 0 {
+		return c.Emails[0].Email
+	}
+	return "Unnamed contact"
+}
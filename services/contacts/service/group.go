@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"contacts-service/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrGroupNotFound is returned when a group does not exist
+	ErrGroupNotFound = errors.New("group not found")
+
+	// ErrGroupAccessDenied is returned when the user cannot access the group's address book
+	ErrGroupAccessDenied = errors.New("access denied")
+
+	// ErrGroupTooLarge is returned when a group nests more than maxExpandedGroups groups
+	ErrGroupTooLarge = errors.New("group contains too many nested groups")
+)
+
+// maxExpandedGroups bounds how many groups a single expansion visits
+const maxExpandedGroups = 500
+
+// ExpandGroup resolves a group into the contacts it contains, following
+// nested group references. Each group is visited once, so reference cycles
+// terminate. Nested groups in address books the user cannot read are
+// skipped rather than failing the whole expansion.
+func (s *ContactService) ExpandGroup(ctx context.Context, userID, groupID uuid.UUID) ([]*models.Contact, error) {
+	root, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("get group: %w", err)
+	}
+	if root == nil {
+		return nil, ErrGroupNotFound
+	}
+
+	// Address books are shared as a whole, so cache access per address book
+	readable := make(map[uuid.UUID]bool)
+	canRead := func(addressBookID uuid.UUID) (bool, error) {
+		if ok, cached := readable[addressBookID]; cached {
+			return ok, nil
+		}
+		ok, err := s.addressBookRepo.HasAccess(ctx, addressBookID, userID, "read")
+		if err != nil {
+			return false, err
+		}
+		readable[addressBookID] = ok
+		return ok, nil
+	}
+
+	ok, err := canRead(root.AddressBookID)
+	if err != nil {
+		return nil, fmt.Errorf("check access: %w", err)
+	}
+	if !ok {
+		return nil, ErrGroupAccessDenied
+	}
+
+	visited := map[uuid.UUID]bool{root.ID: true}
+	groupIDs := []uuid.UUID{root.ID}
+	pending := []uuid.UUID{root.ID}
+
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+
+		subgroups, err := s.groupRepo.GetSubgroups(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("get subgroups: %w", err)
+		}
+
+		for _, sg := range subgroups {
+			if visited[sg.ID] {
+				continue
+			}
+			visited[sg.ID] = true
+			if len(visited) > maxExpandedGroups {
+				return nil, ErrGroupTooLarge
+			}
+
+			ok, err := canRead(sg.AddressBookID)
+			if err != nil {
+				return nil, fmt.Errorf("check access: %w", err)
+			}
+			if !ok {
+				s.logger.Debug("Skipping unreadable nested group",
+					zap.String("group_id", groupID.String()),
+					zap.String("nested_group_id", sg.ID.String()))
+				continue
+			}
+
+			groupIDs = append(groupIDs, sg.ID)
+			pending = append(pending, sg.ID)
+		}
+	}
+
+	return s.contactRepo.ListByGroups(ctx, groupIDs)
+}
+
+// GetGroupMemberEmails returns the primary email of every contact in a group,
+// including nested groups, without duplicates
+func (s *ContactService) GetGroupMemberEmails(ctx context.Context, userID, groupID uuid.UUID) ([]string, error) {
+	contacts, err := s.ExpandGroup(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := []string{}
+	seen := make(map[string]bool)
+	for _, c := range contacts {
+		email := primaryEmail(c)
+		if email == "" {
+			continue
+		}
+		key := strings.ToLower(email)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		emails = append(emails, email)
+	}
+
+	return emails, nil
+}
+
+// ExportGroupVCard serializes every contact in a group, including nested
+// groups, as a multi-vCard document
+func (s *ContactService) ExportGroupVCard(ctx context.Context, userID, groupID uuid.UUID, version string) (string, error) {
+	if version == "" {
+		version = VCardVersion3
+	}
+	if !IsSupportedVCardVersion(version) {
+		return "", fmt.Errorf("unsupported vCard version: %s", version)
+	}
+
+	contacts, err := s.ExpandGroup(ctx, userID, groupID)
+	if err != nil {
+		return "", err
+	}
+
+	return s.exportVCard(contacts, version), nil
+}
+
+// AddSubgroups nests groups inside a group. The user needs write access to
+// the group and read access to each nested group.
+func (s *ContactService) AddSubgroups(ctx context.Context, userID, groupID uuid.UUID, memberGroupIDs []uuid.UUID) error {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil || group == nil {
+		return ErrGroupNotFound
+	}
+
+	hasAccess, err := s.addressBookRepo.HasAccess(ctx, group.AddressBookID, userID, "write")
+	if err != nil || !hasAccess {
+		return ErrGroupAccessDenied
+	}
+
+	for _, memberID := range memberGroupIDs {
+		if memberID == groupID {
+			return fmt.Errorf("a group cannot contain itself")
+		}
+
+		member, err := s.groupRepo.GetByID(ctx, memberID)
+		if err != nil || member == nil {
+			return ErrGroupNotFound
+		}
+
+		hasAccess, err := s.addressBookRepo.HasAccess(ctx, member.AddressBookID, userID, "read")
+		if err != nil || !hasAccess {
+			return ErrGroupAccessDenied
+		}
+
+		if err := s.groupRepo.AddSubgroup(ctx, groupID, memberID); err != nil {
+			return fmt.Errorf("add subgroup: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RemoveSubgroup removes a nested group from a group
+func (s *ContactService) RemoveSubgroup(ctx context.Context, userID, groupID, memberGroupID uuid.UUID) error {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil || group == nil {
+		return ErrGroupNotFound
+	}
+
+	hasAccess, err := s.addressBookRepo.HasAccess(ctx, group.AddressBookID, userID, "write")
+	if err != nil || !hasAccess {
+		return ErrGroupAccessDenied
+	}
+
+	return s.groupRepo.RemoveSubgroup(ctx, groupID, memberGroupID)
+}
+
+// primaryEmail returns the contact's primary email, or its first email if
+// none is marked primary
+func primaryEmail(c *models.Contact) string {
+	for _, e := range c.Emails {
+		if e.Primary && e.Email != "" {
+			return e.Email
+		}
+	}
+	for _, e := range c.Emails {
+		if e.Email != "" {
+			return e.Email
+		}
+	}
+	return ""
+}
@@ -0,0 +1,527 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"contacts-service/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrMergeContactNotFound is returned when a contact to merge does not exist
+	ErrMergeContactNotFound = errors.New("contact not found")
+
+	// ErrMergeAccessDenied is returned when the user cannot write to a contact's address book
+	ErrMergeAccessDenied = errors.New("access denied")
+
+	// ErrInvalidMerge is returned for a merge request that cannot be applied
+	ErrInvalidMerge = errors.New("invalid merge")
+)
+
+// mergeTextFields are the single-valued text fields of a contact. Only
+// notes can keep both values, by joining them.
+var mergeTextFields = []struct {
+	name     string
+	field    func(c *models.Contact) *string
+	joinable bool
+}{
+	{"prefix", func(c *models.Contact) *string { return &c.Prefix }, false},
+	{"first_name", func(c *models.Contact) *string { return &c.FirstName }, false},
+	{"middle_name", func(c *models.Contact) *string { return &c.MiddleName }, false},
+	{"last_name", func(c *models.Contact) *string { return &c.LastName }, false},
+	{"suffix", func(c *models.Contact) *string { return &c.Suffix }, false},
+	{"nickname", func(c *models.Contact) *string { return &c.Nickname }, false},
+	{"display_name", func(c *models.Contact) *string { return &c.DisplayName }, false},
+	{"company", func(c *models.Contact) *string { return &c.Company }, false},
+	{"department", func(c *models.Contact) *string { return &c.Department }, false},
+	{"job_title", func(c *models.Contact) *string { return &c.JobTitle }, false},
+	{"notes", func(c *models.Contact) *string { return &c.Notes }, true},
+}
+
+// mergeDateFields are the single-valued date fields of a contact
+var mergeDateFields = []struct {
+	name  string
+	field func(c *models.Contact) **time.Time
+}{
+	{"birthday", func(c *models.Contact) **time.Time { return &c.Birthday }},
+	{"anniversary", func(c *models.Contact) **time.Time { return &c.Anniversary }},
+}
+
+// PreviewMerge returns what merging the request's contacts would produce,
+// with every conflicting field and the resolution that would apply to it
+func (s *ContactService) PreviewMerge(ctx context.Context, userID uuid.UUID, req *models.MergeRequest) (*models.MergePreview, error) {
+	sources, err := s.loadMergeSources(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, conflicts, err := buildMerge(sources, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MergePreview{
+		Contact:   merged,
+		Sources:   sources,
+		Conflicts: conflicts,
+	}, nil
+}
+
+// MergeContacts merges contacts into the primary one. Conflicting fields
+// are resolved as the request says, otherwise single-valued fields keep the
+// primary contact's value and multi-valued fields keep every value. The
+// other contacts are soft-deleted, leaving tombstones that point at the
+// primary contact.
+func (s *ContactService) MergeContacts(ctx context.Context, userID uuid.UUID, req *models.MergeRequest) (*models.Contact, error) {
+	sources, err := s.loadMergeSources(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, _, err := buildMerge(sources, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.contactRepo.Update(ctx, merged); err != nil {
+		return nil, fmt.Errorf("update contact: %w", err)
+	}
+
+	mergedIDs := make([]uuid.UUID, 0, len(sources)-1)
+	for _, c := range sources[1:] {
+		mergedIDs = append(mergedIDs, c.ID)
+	}
+	if err := s.contactRepo.MarkMerged(ctx, mergedIDs, merged.ID); err != nil {
+		return nil, fmt.Errorf("mark contacts merged: %w", err)
+	}
+
+	s.logger.Info("Contacts merged",
+		zap.String("contact_id", merged.ID.String()),
+		zap.Int("merged", len(mergedIDs)),
+	)
+
+	return merged, nil
+}
+
+// loadMergeSources returns the primary contact followed by the distinct
+// contacts merged into it, all of which the user must be able to write
+func (s *ContactService) loadMergeSources(ctx context.Context, userID uuid.UUID, req *models.MergeRequest) ([]*models.Contact, error) {
+	ids := append([]uuid.UUID{req.PrimaryID}, req.MergeIDs...)
+	seen := make(map[uuid.UUID]bool, len(ids))
+
+	var sources []*models.Contact
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		contact, err := s.contactRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("get contact: %w", err)
+		}
+		if contact == nil {
+			return nil, fmt.Errorf("%w: %s", ErrMergeContactNotFound, id)
+		}
+
+		hasAccess, err := s.addressBookRepo.HasAccess(ctx, contact.AddressBookID, userID, "write")
+		if err != nil {
+			return nil, fmt.Errorf("check access: %w", err)
+		}
+		if !hasAccess {
+			return nil, ErrMergeAccessDenied
+		}
+
+		sources = append(sources, contact)
+	}
+
+	if len(sources) < 2 {
+		return nil, fmt.Errorf("%w: at least two distinct contacts are required", ErrInvalidMerge)
+	}
+	return sources, nil
+}
+
+// mergeResolver records the conflicts of one merge and resolves them
+type mergeResolver struct {
+	sources     []*models.Contact // Primary contact first
+	resolutions map[string]string
+	keep        map[string]bool // Fields to keep from the primary contact
+	conflicts   []models.MergeConflict
+}
+
+// resolve records a conflict on field and returns the contact whose value
+// wins, or keepBoth. values lists the contacts that have a value, in source
+// order. Without a resolution the primary contact's value wins, unless both
+// can be kept.
+func (m *mergeResolver) resolve(field string, values []models.MergeFieldValue, canKeepBoth bool) (winner uuid.UUID, keepBoth bool, err error) {
+	resolution, ok := m.resolutions[field]
+	if !ok {
+		switch {
+		case m.keep[field] && values[0].ContactID == m.sources[0].ID:
+			resolution = values[0].ContactID.String()
+		case canKeepBoth:
+			resolution = models.MergeKeepBoth
+		default:
+			resolution = values[0].ContactID.String()
+		}
+	}
+
+	m.conflicts = append(m.conflicts, models.MergeConflict{
+		Field:       field,
+		Values:      values,
+		Resolution:  resolution,
+		CanKeepBoth: canKeepBoth,
+	})
+
+	if resolution == models.MergeKeepBoth {
+		if !canKeepBoth {
+			return uuid.Nil, false, fmt.Errorf("%w: %s cannot keep both values", ErrInvalidMerge, field)
+		}
+		return uuid.Nil, true, nil
+	}
+
+	if id, err := uuid.Parse(resolution); err == nil {
+		for _, v := range values {
+			if v.ContactID == id {
+				return id, false, nil
+			}
+		}
+	}
+	return uuid.Nil, false, fmt.Errorf("%w: resolution for %s must be %s or the ID of a contact that has a value", ErrInvalidMerge, field, models.MergeKeepBoth)
+}
+
+// buildMerge merges the sources into a copy of the first one and returns
+// it with the conflicts found on the way
+func buildMerge(sources []*models.Contact, req *models.MergeRequest) (*models.Contact, []models.MergeConflict, error) {
+	m := &mergeResolver{
+		sources:     sources,
+		resolutions: req.Resolutions,
+		keep:        make(map[string]bool, len(req.KeepFields)),
+		conflicts:   []models.MergeConflict{},
+	}
+	for _, field := range req.KeepFields {
+		m.keep[field] = true
+	}
+
+	merged := *sources[0]
+
+	for _, f := range mergeTextFields {
+		if err := m.mergeText(&merged, f.name, f.field, f.joinable); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, f := range mergeDateFields {
+		if err := m.mergeDate(&merged, f.name, f.field); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := m.mergeCustomFields(&merged); err != nil {
+		return nil, nil, err
+	}
+
+	var err error
+	if merged.Emails, err = mergeEntries(m, "emails",
+		func(c *models.Contact) []models.ContactEmail { return c.Emails },
+		func(e models.ContactEmail) string { return e.Type },
+		func(e models.ContactEmail) string { return strings.ToLower(strings.TrimSpace(e.Email)) },
+	); err != nil {
+		return nil, nil, err
+	}
+	if merged.Phones, err = mergeEntries(m, "phones",
+		func(c *models.Contact) []models.ContactPhone { return c.Phones },
+		func(p models.ContactPhone) string { return p.Type },
+		func(p models.ContactPhone) string { return normalizePhone(p.Number) },
+	); err != nil {
+		return nil, nil, err
+	}
+	if merged.Addresses, err = mergeEntries(m, "addresses",
+		func(c *models.Contact) []models.ContactAddress { return c.Addresses },
+		func(a models.ContactAddress) string { return a.Type },
+		func(a models.ContactAddress) string {
+			return strings.ToLower(strings.Join([]string{a.Street, a.City, a.State, a.PostalCode, a.Country}, "\n"))
+		},
+	); err != nil {
+		return nil, nil, err
+	}
+	if merged.URLs, err = mergeEntries(m, "urls",
+		func(c *models.Contact) []models.ContactURL { return c.URLs },
+		func(u models.ContactURL) string { return u.Type },
+		func(u models.ContactURL) string { return strings.ToLower(strings.TrimSpace(u.URL)) },
+	); err != nil {
+		return nil, nil, err
+	}
+	if merged.IMs, err = mergeEntries(m, "ims",
+		func(c *models.Contact) []models.ContactIM { return c.IMs },
+		func(im models.ContactIM) string { return im.Type },
+		func(im models.ContactIM) string { return strings.ToLower(strings.TrimSpace(im.Username)) },
+	); err != nil {
+		return nil, nil, err
+	}
+
+	// Only one entry of each kind stays primary
+	keepOnePrimary(merged.Emails, func(e *models.ContactEmail) *bool { return &e.Primary })
+	keepOnePrimary(merged.Phones, func(p *models.ContactPhone) *bool { return &p.Primary })
+	keepOnePrimary(merged.Addresses, func(a *models.ContactAddress) *bool { return &a.Primary })
+
+	// Categories and the starred flag never conflict
+	merged.Categories = nil
+	seen := make(map[string]bool)
+	for _, c := range sources {
+		for _, category := range c.Categories {
+			if key := strings.ToLower(category); !seen[key] {
+				seen[key] = true
+				merged.Categories = append(merged.Categories, category)
+			}
+		}
+		merged.Starred = merged.Starred || c.Starred
+	}
+
+	// A resolution for a field without a conflict is most likely a typo
+	for field := range req.Resolutions {
+		found := false
+		for _, c := range m.conflicts {
+			if c.Field == field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("%w: %s has no conflict to resolve", ErrInvalidMerge, field)
+		}
+	}
+
+	return &merged, m.conflicts, nil
+}
+
+// mergeText merges a single-valued text field
+func (m *mergeResolver) mergeText(merged *models.Contact, name string, field func(c *models.Contact) *string, joinable bool) error {
+	var values []models.MergeFieldValue
+	var distinct []string
+	for _, c := range m.sources {
+		v := strings.TrimSpace(*field(c))
+		if v == "" {
+			continue
+		}
+		values = append(values, models.MergeFieldValue{ContactID: c.ID, Value: v})
+		if !containsString(distinct, v) {
+			distinct = append(distinct, v)
+		}
+	}
+
+	switch len(distinct) {
+	case 0:
+		return nil
+	case 1:
+		*field(merged) = distinct[0]
+		return nil
+	}
+
+	winner, keepBoth, err := m.resolve(name, values, joinable)
+	if err != nil {
+		return err
+	}
+	if keepBoth {
+		*field(merged) = strings.Join(distinct, "\n\n")
+		return nil
+	}
+	*field(merged) = strings.TrimSpace(*field(m.source(winner)))
+	return nil
+}
+
+// mergeDate merges a single-valued date field
+func (m *mergeResolver) mergeDate(merged *models.Contact, name string, field func(c *models.Contact) **time.Time) error {
+	var values []models.MergeFieldValue
+	var distinct []string
+	for _, c := range m.sources {
+		v := *field(c)
+		if v == nil {
+			continue
+		}
+		values = append(values, models.MergeFieldValue{ContactID: c.ID, Value: v})
+		if day := v.Format("2006-01-02"); !containsString(distinct, day) {
+			distinct = append(distinct, day)
+		}
+	}
+
+	switch len(distinct) {
+	case 0:
+		return nil
+	case 1:
+		*field(merged) = values[0].Value.(*time.Time)
+		return nil
+	}
+
+	winner, _, err := m.resolve(name, values, false)
+	if err != nil {
+		return err
+	}
+	*field(merged) = *field(m.source(winner))
+	return nil
+}
+
+// mergeCustomFields merges custom fields key by key, each key conflicting
+// as "custom_fields.<key>"
+func (m *mergeResolver) mergeCustomFields(merged *models.Contact) error {
+	var keys []string
+	for _, c := range m.sources {
+		for key := range c.CustomFields {
+			if !containsString(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	merged.CustomFields = nil
+	for _, key := range keys {
+		var values []models.MergeFieldValue
+		var distinct []string
+		for _, c := range m.sources {
+			v, ok := c.CustomFields[key]
+			if !ok {
+				continue
+			}
+			values = append(values, models.MergeFieldValue{ContactID: c.ID, Value: v})
+			if !containsString(distinct, v) {
+				distinct = append(distinct, v)
+			}
+		}
+
+		value := distinct[0]
+		if len(distinct) > 1 {
+			winner, _, err := m.resolve("custom_fields."+key, values, false)
+			if err != nil {
+				return err
+			}
+			value = m.source(winner).CustomFields[key]
+		}
+
+		if merged.CustomFields == nil {
+			merged.CustomFields = make(map[string]string, len(keys))
+		}
+		merged.CustomFields[key] = value
+	}
+	return nil
+}
+
+// mergeEntries merges a multi-valued field, keeping each value once.
+// Entries are compared per type: when contacts have different entries of
+// one type, that type conflicts as "<name>.<type>" and either one
+// contact's entries or all of them are kept. Types only one contact has,
+// or that all contacts agree on, are kept as they are.
+func mergeEntries[T any](m *mergeResolver, name string, list func(c *models.Contact) []T, typeOf, key func(e T) string) ([]T, error) {
+	var types []string
+	byType := make(map[string]map[uuid.UUID][]T)
+	for _, c := range m.sources {
+		for _, e := range list(c) {
+			t := strings.ToLower(strings.TrimSpace(typeOf(e)))
+			if t == "" {
+				t = "other"
+			}
+			if byType[t] == nil {
+				byType[t] = make(map[uuid.UUID][]T)
+				types = append(types, t)
+			}
+			byType[t][c.ID] = append(byType[t][c.ID], e)
+		}
+	}
+
+	var merged []T
+	seen := make(map[string]bool)
+	for _, t := range types {
+		var values []models.MergeFieldValue
+		var sets []string
+		for _, c := range m.sources {
+			entries, ok := byType[t][c.ID]
+			if !ok {
+				continue
+			}
+			values = append(values, models.MergeFieldValue{ContactID: c.ID, Value: entries})
+			if set := entrySet(entries, key); !containsString(sets, set) {
+				sets = append(sets, set)
+			}
+		}
+
+		kept := values
+		if len(sets) > 1 {
+			winner, keepBoth, err := m.resolve(name+"."+t, values, true)
+			if err != nil {
+				return nil, err
+			}
+			if !keepBoth {
+				kept = []models.MergeFieldValue{{ContactID: winner, Value: byType[t][winner]}}
+			}
+		}
+
+		for _, v := range kept {
+			for _, e := range v.Value.([]T) {
+				if k := key(e); !seen[k] {
+					seen[k] = true
+					merged = append(merged, e)
+				}
+			}
+		}
+	}
+	return merged, nil
+}
+
+// entrySet returns a key identifying a set of entries regardless of order
+func entrySet[T any](entries []T, key func(e T) string) string {
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if k := key(e); !containsString(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\x00")
+}
+
+// keepOnePrimary clears the primary flag of all but the first primary entry
+func keepOnePrimary[T any](entries []T, primary func(e *T) *bool) {
+	found := false
+	for i := range entries {
+		p := primary(&entries[i])
+		if *p && found {
+			*p = false
+		}
+		found = found || *p
+	}
+}
+
+// source returns the source contact with id
+func (m *mergeResolver) source(id uuid.UUID) *models.Contact {
+	for _, c := range m.sources {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// normalizePhone reduces a phone number to its digits and a leading +
+func normalizePhone(number string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(number) {
+		if r >= '0' && r <= '9' || r == '+' && i == 0 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
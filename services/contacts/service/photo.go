@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	"contacts-service/models"
+)
+
+var (
+	// ErrUnsupportedPhotoFormat is returned for uploads that are not JPEG or PNG
+	ErrUnsupportedPhotoFormat = errors.New("unsupported photo format, use JPEG or PNG")
+
+	// ErrPhotoTooLarge is returned for images whose dimensions exceed maxPhotoPixels
+	ErrPhotoTooLarge = errors.New("photo dimensions too large")
+)
+
+const (
+	smallThumbnailSize  = 96
+	mediumThumbnailSize = 256
+
+	// maxPhotoPixels guards against decompression bombs (roughly 40 megapixels)
+	maxPhotoPixels = 40_000_000
+
+	thumbnailJPEGQuality = 85
+)
+
+// processPhoto validates an uploaded photo and generates its thumbnails
+// Thumbnails keep the format of the original so PNG transparency is preserved
+func processPhoto(data []byte) (*models.ContactPhoto, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedPhotoFormat
+	}
+	if format != "jpeg" && format != "png" {
+		return nil, ErrUnsupportedPhotoFormat
+	}
+	if cfg.Width*cfg.Height > maxPhotoPixels {
+		return nil, ErrPhotoTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode photo: %w", err)
+	}
+
+	photo := &models.ContactPhoto{
+		ContentType: "image/" + format,
+		Original:    data,
+	}
+
+	if photo.Small, err = encodeThumbnail(thumbnail(img, smallThumbnailSize), format); err != nil {
+		return nil, err
+	}
+	if photo.Medium, err = encodeThumbnail(thumbnail(img, mediumThumbnailSize), format); err != nil {
+		return nil, err
+	}
+
+	return photo, nil
+}
+
+func encodeThumbnail(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailJPEGQuality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnail center-crops src to a square and downscales it to size x size
+// by averaging the source pixels covered by each destination pixel
+// Images smaller than size are cropped but not upscaled
+func thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	size = min(size, side)
+
+	dst := image.NewRGBA64(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0, sy1 := y0+y*side/size, y0+(y+1)*side/size
+		for x := 0; x < size; x++ {
+			sx0, sx1 := x0+x*side/size, x0+(x+1)*side/size
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidSyncToken is returned for a sync token an address book can't
+// resolve; the client has to sync in full (RFC 6578 section 3.2)
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// SyncHistoryRetention is how long contact deletions are kept for clients
+// syncing incrementally. A client that hasn't synced for longer gets
+// ErrInvalidSyncToken.
+const SyncHistoryRetention = 90 * 24 * time.Hour
+
+// syncTokenPrefix precedes an address book's change sequence number in its
+// sync token. The database formats tokens (migration 005); they are only
+// parsed here.
+const syncTokenPrefix = "urn:x-contacts:sync:"
+
+// parseSyncToken returns the change sequence number of a sync token
+func parseSyncToken(token string) (int64, error) {
+	seq, err := strconv.ParseInt(strings.TrimPrefix(token, syncTokenPrefix), 10, 64)
+	if !strings.HasPrefix(token, syncTokenPrefix) || err != nil || seq < 0 {
+		return 0, ErrInvalidSyncToken
+	}
+	return seq, nil
+}
+
+// PruneSyncHistory forgets contact deletions older than SyncHistoryRetention
+func (s *ContactService) PruneSyncHistory(ctx context.Context) error {
+	pruned, err := s.addressBookRepo.PruneSyncDeletions(ctx, time.Now().Add(-SyncHistoryRetention))
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		s.logger.Info("Pruned CardDAV sync history", zap.Int64("deletions", pruned))
+	}
+	return nil
+}
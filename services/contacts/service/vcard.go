@@ -0,0 +1,696 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"contacts-service/models"
+)
+
+// Supported vCard versions
+const (
+	VCardVersion3 = "3.0" // RFC 2426
+	VCardVersion4 = "4.0" // RFC 6350
+)
+
+// yearlessDateYear marks a birthday or anniversary stored without a year,
+// following the convention Apple clients use with X-APPLE-OMIT-YEAR
+const yearlessDateYear = 1604
+
+// vCardLineLength is the RFC 6350 section 3.2 folding limit in octets
+const vCardLineLength = 75
+
+// VCardOptions controls how a contact is serialized
+type VCardOptions struct {
+	Version string
+	// PhotoURI references the contact photo instead of embedding PhotoData
+	PhotoURI string
+}
+
+// IsSupportedVCardVersion reports whether version can be exported
+func IsSupportedVCardVersion(version string) bool {
+	return version == VCardVersion3 || version == VCardVersion4
+}
+
+// VCardContentType returns the media type for a vCard of the given version
+func VCardContentType(version string) string {
+	if version == VCardVersion4 {
+		return "text/vcard; charset=utf-8; version=4.0"
+	}
+	return "text/vcard; charset=utf-8"
+}
+
+// NegotiateVCardVersion picks a vCard version from an Accept header. Only
+// text/vcard ranges with an explicit version parameter are considered; the
+// highest q-value wins and 3.0 is the default for compatibility.
+func NegotiateVCardVersion(accept string) string {
+	version := VCardVersion3
+	bestQ := -1.0
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || (mediaType != "text/vcard" && mediaType != "text/x-vcard") {
+			continue
+		}
+		v := params["version"]
+		if !IsSupportedVCardVersion(v) {
+			continue
+		}
+
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && v == VCardVersion4) {
+			version = v
+			bestQ = q
+		}
+	}
+
+	return version
+}
+
+// FormatVCard serializes a contact as a single vCard
+func FormatVCard(c *models.Contact, opts VCardOptions) string {
+	v4 := opts.Version == VCardVersion4
+	w := &vCardWriter{v4: v4}
+
+	w.line("BEGIN", nil, "VCARD")
+	if v4 {
+		w.line("VERSION", nil, VCardVersion4)
+		w.line("KIND", nil, "individual")
+	} else {
+		w.line("VERSION", nil, VCardVersion3)
+	}
+	w.line("UID", nil, c.UID)
+	w.line("FN", nil, escapeVCardText(c.DisplayName))
+	w.line("N", nil, joinVCardComponents(c.LastName, c.FirstName, c.MiddleName, c.Prefix, c.Suffix))
+
+	if c.Nickname != "" {
+		w.line("NICKNAME", nil, escapeVCardText(c.Nickname))
+	}
+	if c.Company != "" || c.Department != "" {
+		w.line("ORG", nil, joinVCardComponents(c.Company, c.Department))
+	}
+	if c.JobTitle != "" {
+		w.line("TITLE", nil, escapeVCardText(c.JobTitle))
+	}
+
+	for _, e := range c.Emails {
+		w.line("EMAIL", w.typeParams(locationVCardType(e.Type), e.Primary), escapeVCardText(e.Email))
+	}
+
+	for _, p := range c.Phones {
+		w.line("TEL", w.typeParams(phoneVCardTypes(p.Type), p.Primary), escapeVCardText(p.Number))
+	}
+
+	for _, a := range c.Addresses {
+		w.line("ADR", w.typeParams(locationVCardType(a.Type), a.Primary),
+			joinVCardComponents("", "", a.Street, a.City, a.State, a.PostalCode, a.Country))
+	}
+
+	for _, u := range c.URLs {
+		w.line("URL", w.typeParams(u.Type, false), u.URL)
+	}
+
+	for _, im := range c.IMs {
+		value := im.Username
+		if im.Type != "" {
+			value = fmt.Sprintf("%s:%s", strings.ToLower(im.Type), im.Username)
+		}
+		w.line("IMPP", nil, value)
+	}
+
+	if c.Birthday != nil {
+		w.date("BDAY", *c.Birthday)
+	}
+	if c.Anniversary != nil {
+		if v4 {
+			w.date("ANNIVERSARY", *c.Anniversary)
+		} else {
+			w.date("X-ANNIVERSARY", *c.Anniversary)
+		}
+	}
+
+	if c.Notes != "" {
+		w.line("NOTE", nil, escapeVCardText(c.Notes))
+	}
+
+	if len(c.Categories) > 0 {
+		categories := make([]string, len(c.Categories))
+		for i, cat := range c.Categories {
+			categories[i] = escapeVCardText(cat)
+		}
+		w.line("CATEGORIES", nil, strings.Join(categories, ","))
+	}
+
+	// Sorted so the output, and therefore the ETag, is stable
+	keys := make([]string, 0, len(c.CustomFields))
+	for k := range c.CustomFields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.line("X-CUSTOM-FIELD", []string{"X-NAME=" + quoteVCardParam(k)}, escapeVCardText(c.CustomFields[k]))
+	}
+
+	if c.Starred {
+		w.line("X-STARRED", nil, "TRUE")
+	}
+
+	switch {
+	case opts.PhotoURI != "":
+		if v4 {
+			w.line("PHOTO", nil, opts.PhotoURI)
+		} else {
+			w.line("PHOTO", []string{"VALUE=uri"}, opts.PhotoURI)
+		}
+	case len(c.PhotoData) > 0:
+		encoded := base64.StdEncoding.EncodeToString(c.PhotoData)
+		if v4 {
+			w.line("PHOTO", nil, "data:image/jpeg;base64,"+encoded)
+		} else {
+			w.line("PHOTO", []string{"ENCODING=b", "TYPE=JPEG"}, encoded)
+		}
+	}
+
+	w.line("REV", nil, c.UpdatedAt.UTC().Format("20060102T150405Z"))
+	w.line("END", nil, "VCARD")
+
+	return w.buf.String()
+}
+
+// ParseVCards parses every vCard in data. Both 3.0 and 4.0 cards are
+// accepted, including folded lines, grouped properties and escaped values.
+func ParseVCards(data string) []*models.Contact {
+	var contacts []*models.Contact
+	var current *vCardBuilder
+
+	for _, line := range unfoldVCardLines(data) {
+		prop, ok := parseVCardLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCARD"):
+			current = &vCardBuilder{contact: &models.Contact{}}
+		case prop.name == "END" && strings.EqualFold(prop.value, "VCARD"):
+			if current != nil {
+				contacts = append(contacts, current.finish())
+			}
+			current = nil
+		case current != nil:
+			current.add(prop)
+		}
+	}
+
+	return contacts
+}
+
+// vCardWriter accumulates folded content lines for one vCard
+type vCardWriter struct {
+	buf bytes.Buffer
+	v4  bool
+}
+
+// line writes a content line, folding it at 75 octets without splitting
+// multi-byte characters
+func (w *vCardWriter) line(name string, params []string, value string) {
+	line := name
+	if len(params) > 0 {
+		line += ";" + strings.Join(params, ";")
+	}
+	line += ":" + value
+
+	limit := vCardLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.buf.WriteString(line[:cut])
+		w.buf.WriteString("\r\n ")
+		line = line[cut:]
+		limit = vCardLineLength - 1 // Continuation lines start with a space
+	}
+	w.buf.WriteString(line)
+	w.buf.WriteString("\r\n")
+}
+
+// typeParams builds TYPE and preference parameters. vCard 4.0 uses lowercase
+// types and PREF=1; 3.0 uses uppercase types with PREF as a type value.
+func (w *vCardWriter) typeParams(types string, preferred bool) []string {
+	var params []string
+	if w.v4 {
+		if types != "" {
+			params = append(params, "TYPE="+strings.ToLower(types))
+		}
+		if preferred {
+			params = append(params, "PREF=1")
+		}
+		return params
+	}
+
+	if preferred {
+		if types != "" {
+			types += ","
+		}
+		types += "pref"
+	}
+	if types != "" {
+		params = append(params, "TYPE="+strings.ToUpper(types))
+	}
+	return params
+}
+
+// date writes a date property. vCard 4.0 requires the basic ISO 8601 format
+// and writes dates without a year as --MMDD.
+func (w *vCardWriter) date(name string, t time.Time) {
+	if t.Year() == yearlessDateYear {
+		if w.v4 {
+			w.line(name, nil, t.Format("--0102"))
+		} else {
+			w.line(name, []string{fmt.Sprintf("X-APPLE-OMIT-YEAR=%d", yearlessDateYear)}, t.Format("20060102"))
+		}
+		return
+	}
+	w.line(name, nil, t.Format("20060102"))
+}
+
+// vCardProperty is a parsed content line
+type vCardProperty struct {
+	name   string
+	params map[string][]string
+	types  []string // Lowercased TYPE values, including bare 2.1-style types
+	value  string   // Still escaped
+}
+
+// pref returns the preference of a property: its PREF value, 1 for a 3.0
+// TYPE=PREF, or 0 when not preferred
+func (p vCardProperty) pref() int {
+	if values := p.params["PREF"]; len(values) > 0 {
+		if n, err := strconv.Atoi(values[0]); err == nil && n > 0 {
+			return n
+		}
+	}
+	if p.hasType("pref") {
+		return 1
+	}
+	return 0
+}
+
+func (p vCardProperty) hasType(t string) bool {
+	for _, v := range p.types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// vCardBuilder collects properties into a contact. Preferred entries are
+// resolved at the end so the lowest PREF wins.
+type vCardBuilder struct {
+	contact    *models.Contact
+	emailPrefs []int
+	phonePrefs []int
+	addrPrefs  []int
+}
+
+func (b *vCardBuilder) add(p vCardProperty) {
+	c := b.contact
+
+	switch p.name {
+	case "UID":
+		c.UID = p.value
+	case "FN":
+		c.DisplayName = unescapeVCardText(p.value)
+	case "N":
+		parts := splitVCardComponents(p.value, ';')
+		fields := []*string{&c.LastName, &c.FirstName, &c.MiddleName, &c.Prefix, &c.Suffix}
+		for i := 0; i < len(parts) && i < len(fields); i++ {
+			*fields[i] = parts[i]
+		}
+	case "NICKNAME":
+		c.Nickname = unescapeVCardText(p.value)
+	case "ORG":
+		parts := splitVCardComponents(p.value, ';')
+		c.Company = parts[0]
+		if len(parts) > 1 {
+			c.Department = parts[1]
+		}
+	case "TITLE":
+		c.JobTitle = unescapeVCardText(p.value)
+	case "EMAIL":
+		c.Emails = append(c.Emails, models.ContactEmail{
+			Type:  contactTypeFromVCard(p),
+			Email: unescapeVCardText(p.value),
+		})
+		b.emailPrefs = append(b.emailPrefs, p.pref())
+	case "TEL":
+		phoneType := contactTypeFromVCard(p)
+		if p.hasType("cell") || p.hasType("mobile") || p.hasType("iphone") {
+			phoneType = "mobile"
+		} else if p.hasType("fax") {
+			phoneType = "fax"
+		}
+		c.Phones = append(c.Phones, models.ContactPhone{
+			Type:   phoneType,
+			Number: strings.TrimPrefix(unescapeVCardText(p.value), "tel:"),
+		})
+		b.phonePrefs = append(b.phonePrefs, p.pref())
+	case "ADR":
+		parts := splitVCardComponents(p.value, ';')
+		for len(parts) < 7 {
+			parts = append(parts, "")
+		}
+		c.Addresses = append(c.Addresses, models.ContactAddress{
+			Type:       contactTypeFromVCard(p),
+			Street:     parts[2],
+			City:       parts[3],
+			State:      parts[4],
+			PostalCode: parts[5],
+			Country:    parts[6],
+		})
+		b.addrPrefs = append(b.addrPrefs, p.pref())
+	case "URL":
+		urlType := ""
+		for _, t := range p.types {
+			if t != "pref" {
+				urlType = t
+				break
+			}
+		}
+		c.URLs = append(c.URLs, models.ContactURL{Type: urlType, URL: p.value})
+	case "IMPP":
+		im := models.ContactIM{Username: p.value}
+		if scheme, user, ok := strings.Cut(p.value, ":"); ok {
+			im.Type = strings.ToLower(scheme)
+			im.Username = user
+		}
+		c.IMs = append(c.IMs, im)
+	case "BDAY":
+		if t, ok := parseVCardDate(p.value); ok {
+			c.Birthday = &t
+		}
+	case "ANNIVERSARY", "X-ANNIVERSARY":
+		if t, ok := parseVCardDate(p.value); ok {
+			c.Anniversary = &t
+		}
+	case "NOTE":
+		c.Notes = unescapeVCardText(p.value)
+	case "CATEGORIES":
+		for _, cat := range splitVCardComponents(p.value, ',') {
+			if cat != "" {
+				c.Categories = append(c.Categories, cat)
+			}
+		}
+	case "X-CUSTOM-FIELD":
+		if names := p.params["X-NAME"]; len(names) > 0 {
+			if c.CustomFields == nil {
+				c.CustomFields = make(map[string]string)
+			}
+			c.CustomFields[names[0]] = unescapeVCardText(p.value)
+		}
+	case "X-STARRED":
+		c.Starred = strings.EqualFold(p.value, "TRUE")
+	case "PHOTO":
+		c.PhotoData = decodeVCardPhoto(p)
+	}
+}
+
+func (b *vCardBuilder) finish() *models.Contact {
+	c := b.contact
+	if i := preferredIndex(b.emailPrefs); i >= 0 {
+		c.Emails[i].Primary = true
+	}
+	if i := preferredIndex(b.phonePrefs); i >= 0 {
+		c.Phones[i].Primary = true
+	}
+	if i := preferredIndex(b.addrPrefs); i >= 0 {
+		c.Addresses[i].Primary = true
+	}
+	return c
+}
+
+// preferredIndex returns the entry with the lowest non-zero preference
+func preferredIndex(prefs []int) int {
+	best := -1
+	for i, p := range prefs {
+		if p > 0 && (best < 0 || p < prefs[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// unfoldVCardLines splits data into logical content lines
+func unfoldVCardLines(data string) []string {
+	var lines []string
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // Embedded photos
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// parseVCardLine splits a content line into name, parameters and value.
+// Group prefixes such as "item1." are dropped.
+func parseVCardLine(line string) (vCardProperty, bool) {
+	// The value starts at the first colon outside a quoted parameter value
+	inQuotes := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ':':
+			if !inQuotes {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return vCardProperty{}, false
+	}
+
+	head := splitVCardParams(line[:colon])
+	name := strings.ToUpper(strings.TrimSpace(head[0]))
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+
+	prop := vCardProperty{
+		name:   name,
+		params: make(map[string][]string),
+		value:  line[colon+1:],
+	}
+
+	for _, param := range head[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			// vCard 2.1 style bare type, e.g. TEL;CELL
+			prop.types = append(prop.types, strings.ToLower(param))
+			continue
+		}
+		key = strings.ToUpper(key)
+		values := strings.Split(value, ",")
+		if strings.HasPrefix(value, `"`) {
+			values = []string{value}
+		}
+		for _, v := range values {
+			v = strings.Trim(v, `"`)
+			prop.params[key] = append(prop.params[key], v)
+			if key == "TYPE" {
+				prop.types = append(prop.types, strings.ToLower(v))
+			}
+		}
+	}
+
+	return prop, true
+}
+
+// splitVCardParams splits the name and parameters on semicolons outside quotes
+func splitVCardParams(s string) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ';':
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// contactTypeFromVCard maps TYPE parameters to home, work or other
+func contactTypeFromVCard(p vCardProperty) string {
+	switch {
+	case p.hasType("work"):
+		return "work"
+	case p.hasType("home"):
+		return "home"
+	default:
+		return "other"
+	}
+}
+
+// locationVCardType maps a home/work/other contact type to a TYPE value
+func locationVCardType(t string) string {
+	switch t {
+	case "home", "work":
+		return t
+	default:
+		return ""
+	}
+}
+
+// phoneVCardTypes maps a contact phone type to TYPE values
+func phoneVCardTypes(t string) string {
+	switch t {
+	case "mobile":
+		return "cell"
+	case "home", "work", "fax":
+		return t
+	default:
+		return ""
+	}
+}
+
+// parseVCardDate parses date and date-time values from either version,
+// including the year-less --MMDD form
+func parseVCardDate(value string) (time.Time, bool) {
+	value, _, _ = strings.Cut(strings.TrimSpace(value), "T")
+
+	if strings.HasPrefix(value, "--") {
+		md := strings.ReplaceAll(value[2:], "-", "")
+		t, err := time.Parse("0102", md)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Date(yearlessDateYear, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), true
+	}
+
+	for _, layout := range []string{"20060102", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// decodeVCardPhoto returns embedded photo data; referenced photos are ignored
+func decodeVCardPhoto(p vCardProperty) []byte {
+	encoded := ""
+	if rest, ok := strings.CutPrefix(p.value, "data:"); ok {
+		meta, data, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return nil
+		}
+		encoded = data
+	} else if enc := p.params["ENCODING"]; len(enc) > 0 && (strings.EqualFold(enc[0], "b") || strings.EqualFold(enc[0], "base64")) {
+		encoded = p.value
+	} else {
+		return nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+var vCardTextEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`, "\r", "")
+
+// escapeVCardText escapes a text value per RFC 6350 section 3.4
+func escapeVCardText(s string) string {
+	return vCardTextEscaper.Replace(s)
+}
+
+// unescapeVCardText reverses escapeVCardText
+func unescapeVCardText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// joinVCardComponents escapes and joins the components of a structured value
+func joinVCardComponents(components ...string) string {
+	for i, c := range components {
+		components[i] = escapeVCardText(c)
+	}
+	return strings.Join(components, ";")
+}
+
+// splitVCardComponents splits a value on unescaped sep and unescapes each part
+func splitVCardComponents(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == sep {
+			parts = append(parts, unescapeVCardText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, unescapeVCardText(value[start:]))
+}
+
+// quoteVCardParam quotes a parameter value containing separators
+func quoteVCardParam(s string) string {
+	s = strings.ReplaceAll(s, `"`, "'")
+	if strings.ContainsAny(s, ";:,") {
+		return `"` + s + `"`
+	}
+	return s
+}
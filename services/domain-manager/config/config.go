@@ -42,6 +42,7 @@ type Config struct {
 	DKIM     DKIMConfig     `yaml:"dkim"`
 	Branding BrandingConfig `yaml:"branding"`
 	Monitor  MonitorConfig  `yaml:"monitor"`
+	DMARC    DMARCConfig    `yaml:"dmarc"`
 	Metrics  MetricsConfig  `yaml:"metrics"`
 }
 
@@ -103,6 +104,16 @@ type DKIMConfig struct {
 	DefaultKeySize   int    `yaml:"default_key_size"`
 	DefaultAlgorithm string `yaml:"default_algorithm"`
 	EncryptionKey    string `yaml:"encryption_key"`
+
+	// KeyLifetime sets expires_at on new keys; zero keys never expire
+	KeyLifetime time.Duration `yaml:"key_lifetime"`
+
+	// Automatic rotation
+	AutoRotate          bool          `yaml:"auto_rotate"`
+	RotationSchedule    string        `yaml:"rotation_schedule"`     // Cron schedule with seconds field
+	RotationLeadTime    time.Duration `yaml:"rotation_lead_time"`    // How long before expiry to start rotating
+	RotationGracePeriod time.Duration `yaml:"rotation_grace_period"` // How long the old key keeps signing
+	PropagationTimeout  time.Duration `yaml:"propagation_timeout"`   // When to alert on a new record not seen in DNS
 }
 
 // BrandingConfig holds branding settings
@@ -119,6 +130,14 @@ type MonitorConfig struct {
 	AlertWebhook  string        `yaml:"alert_webhook"`
 }
 
+// DMARCConfig holds DMARC aggregate reporting settings
+type DMARCConfig struct {
+	ReportingEnabled bool   `yaml:"reporting_enabled"`
+	OrgName          string `yaml:"org_name"`
+	ReportEmail      string `yaml:"report_email"`
+	ReportSchedule   string `yaml:"report_schedule"` // Cron schedule with seconds field
+}
+
 // MetricsConfig holds metrics server settings
 type MetricsConfig struct {
 	Enabled bool   `yaml:"enabled"`
@@ -226,6 +245,21 @@ func applyDefaults(cfg *Config) {
 	if cfg.DKIM.DefaultAlgorithm == "" {
 		cfg.DKIM.DefaultAlgorithm = "rsa-sha256"
 	}
+	if cfg.DKIM.KeyLifetime == 0 {
+		cfg.DKIM.KeyLifetime = 180 * 24 * time.Hour
+	}
+	if cfg.DKIM.RotationSchedule == "" {
+		cfg.DKIM.RotationSchedule = "0 15 * * * *" // Hourly
+	}
+	if cfg.DKIM.RotationLeadTime == 0 {
+		cfg.DKIM.RotationLeadTime = 14 * 24 * time.Hour
+	}
+	if cfg.DKIM.RotationGracePeriod == 0 {
+		cfg.DKIM.RotationGracePeriod = 7 * 24 * time.Hour
+	}
+	if cfg.DKIM.PropagationTimeout == 0 {
+		cfg.DKIM.PropagationTimeout = 48 * time.Hour
+	}
 
 	// Branding defaults
 	if cfg.Branding.DefaultColor == "" {
@@ -237,6 +271,17 @@ func applyDefaults(cfg *Config) {
 		cfg.Monitor.CheckInterval = 1 * time.Hour
 	}
 
+	// DMARC reporting defaults
+	if cfg.DMARC.OrgName == "" {
+		cfg.DMARC.OrgName = "OonruMail"
+	}
+	if cfg.DMARC.ReportEmail == "" {
+		cfg.DMARC.ReportEmail = cfg.DNS.DMARCReportEmail
+	}
+	if cfg.DMARC.ReportSchedule == "" {
+		cfg.DMARC.ReportSchedule = "0 0 1 * * *" // Daily at 01:00 UTC
+	}
+
 	// Metrics defaults
 	if cfg.Metrics.Addr == "" {
 		cfg.Metrics.Addr = ":9090"
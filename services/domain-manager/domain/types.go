@@ -2,6 +2,7 @@ package domain
 
 import (
 	"crypto/rsa"
+	"strings"
 	"time"
 )
 
@@ -66,12 +67,33 @@ type DNSIssue struct {
 	Message    string  `json:"message"`
 }
 
+// DKIM key types as published in the k= tag of the DNS record
+const (
+	DKIMKeyTypeRSA     = "rsa"
+	DKIMKeyTypeEd25519 = "ed25519"
+)
+
+// DKIM signing algorithms
+const (
+	DKIMAlgorithmRSASHA256     = "rsa-sha256"
+	DKIMAlgorithmEd25519SHA256 = "ed25519-sha256"
+)
+
+// DKIMKeyTypeForAlgorithm returns the key type used by a signing algorithm
+func DKIMKeyTypeForAlgorithm(algorithm string) string {
+	if strings.HasPrefix(strings.ToLower(algorithm), DKIMKeyTypeEd25519) {
+		return DKIMKeyTypeEd25519
+	}
+	return DKIMKeyTypeRSA
+}
+
 // DKIMKey represents a DKIM signing key
 type DKIMKey struct {
 	ID         string     `json:"id"`
 	DomainID   string     `json:"domain_id"`
 	Selector   string     `json:"selector"`
 	Algorithm  string     `json:"algorithm"`
+	KeyType    string     `json:"key_type"`
 	KeySize    int        `json:"key_size"`
 	PublicKey  string     `json:"public_key"`
 	PrivateKey *rsa.PrivateKey `json:"-"`
@@ -81,6 +103,10 @@ type DKIMKey struct {
 	ActivatedAt *time.Time `json:"activated_at,omitempty"`
 	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+
+	// Automatic rotation state
+	ReplacesKeyID *string    `json:"replaces_key_id,omitempty"`
+	RetireAfter   *time.Time `json:"retire_after,omitempty"`
 }
 
 // DKIMKeyPublic is the public representation of a DKIM key
@@ -88,6 +114,7 @@ type DKIMKeyPublic struct {
 	ID          string     `json:"id"`
 	Selector    string     `json:"selector"`
 	Algorithm   string     `json:"algorithm"`
+	KeyType     string     `json:"key_type"`
 	KeySize     int        `json:"key_size"`
 	PublicKey   string     `json:"public_key"`
 	DNSRecord   string     `json:"dns_record"`
@@ -123,8 +150,19 @@ type Policies struct {
 	DefaultSignatureEnforced bool              `json:"default_signature_enforced"`
 	AttachmentPolicy         *AttachmentPolicy `json:"attachment_policy,omitempty"`
 	UpdatedAt                time.Time         `json:"updated_at"`
+	// OutboundFooterText and OutboundFooterHTML are appended to the plain
+	// text and HTML bodies of mail sent from the domain
+	OutboundFooterText *string `json:"outbound_footer_text,omitempty"`
+	OutboundFooterHTML *string `json:"outbound_footer_html,omitempty"`
+	// DKIMBodyLength signs mail from the domain with a DKIM l= tag covering
+	// the body as sent, so mailing lists can append footers without
+	// breaking the signature. Anything appended is unsigned.
+	DKIMBodyLength bool `json:"dkim_body_length"`
 }
 
+// MaxOutboundFooterLength is the longest outbound footer accepted, in bytes
+const MaxOutboundFooterLength = 10 * 1024
+
 // AttachmentPolicy represents attachment restrictions
 type AttachmentPolicy struct {
 	BlockedExtensions []string `json:"blocked_extensions,omitempty"`
@@ -152,6 +190,43 @@ const (
 	CatchAllReject  CatchAllAction = "reject"
 )
 
+// CatchAllRule routes mail for local parts of a domain that have no mailbox
+// or alias. Rules are evaluated by position, lowest first; the first match
+// wins and the catch-all config is the fallback when none matches.
+type CatchAllRule struct {
+	ID          string            `json:"id"`
+	DomainID    string            `json:"domain_id"`
+	Position    int               `json:"position"`
+	MatchType   CatchAllMatchType `json:"match_type"`
+	Pattern     string            `json:"pattern"`
+	Action      CatchAllAction    `json:"action"` // deliver or forward
+	Destination string            `json:"destination"`
+	Enabled     bool              `json:"enabled"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// CatchAllMatchType is how a catch-all rule's pattern is matched against the
+// local part. All match types are case-insensitive.
+type CatchAllMatchType string
+
+const (
+	CatchAllMatchExact    CatchAllMatchType = "exact"
+	CatchAllMatchWildcard CatchAllMatchType = "wildcard" // * matches any run of characters, ? a single one
+	CatchAllMatchRegex    CatchAllMatchType = "regex"    // RE2 syntax, anchored to the whole local part
+)
+
+// CatchAllRouteResult is how mail to an address of a domain would be routed
+type CatchAllRouteResult struct {
+	Address     string         `json:"address"`
+	LocalPart   string         `json:"local_part"`
+	Existing    bool           `json:"existing"` // A mailbox or alias receives it; catch-all rules don't apply
+	Rule        *CatchAllRule  `json:"rule,omitempty"`
+	Fallback    bool           `json:"fallback"` // No rule matched; the catch-all config applies
+	Action      CatchAllAction `json:"action,omitempty"`
+	Destination string         `json:"destination,omitempty"`
+}
+
 // DomainStats represents domain statistics
 type DomainStats struct {
 	DomainID            string    `json:"domain_id"`
@@ -177,3 +252,76 @@ type DNSMonitorAlert struct {
 	CreatedAt  time.Time `json:"created_at"`
 	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
 }
+
+// DMARCPolicy represents the policy published in a domain's DMARC record
+type DMARCPolicy struct {
+	Domain          string   `json:"domain"`
+	ADKIM           string   `json:"adkim"` // r or s
+	ASPF            string   `json:"aspf"`  // r or s
+	Policy          string   `json:"p"`
+	SubdomainPolicy string   `json:"sp"`
+	Percentage      int      `json:"pct"`
+	RUA             []string `json:"rua,omitempty"`
+}
+
+// DMARC dispositions
+const (
+	DMARCDispositionNone       = "none"
+	DMARCDispositionQuarantine = "quarantine"
+	DMARCDispositionReject     = "reject"
+)
+
+// DMARCAuthResult represents SPF/DKIM results observed for messages from a source IP
+type DMARCAuthResult struct {
+	ID           string    `json:"id"`
+	DomainID     string    `json:"domain_id"`
+	SourceIP     string    `json:"source_ip"`
+	HeaderFrom   string    `json:"header_from"`
+	EnvelopeFrom string    `json:"envelope_from,omitempty"`
+	SPFDomain    string    `json:"spf_domain,omitempty"`
+	SPFResult    string    `json:"spf_result"`
+	DKIMDomain   string    `json:"dkim_domain,omitempty"`
+	DKIMSelector string    `json:"dkim_selector,omitempty"`
+	DKIMResult   string    `json:"dkim_result"`
+	Disposition  string    `json:"disposition,omitempty"`
+	Count        int       `json:"count"`
+	RecordedAt   time.Time `json:"recorded_at"`
+}
+
+// DMARCAggregateReport represents a generated RFC 7489 aggregate report
+type DMARCAggregateReport struct {
+	ID             string    `json:"id"`
+	DomainID       string    `json:"domain_id"`
+	ReportID       string    `json:"report_id"`
+	DateRangeBegin time.Time `json:"date_range_begin"`
+	DateRangeEnd   time.Time `json:"date_range_end"`
+	RecordCount    int       `json:"record_count"`
+	MessageCount   int64     `json:"message_count"`
+	Data           []byte    `json:"-"` // gzipped XML
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SPF finding severities
+const (
+	SPFSeverityWarning = "warning"
+	SPFSeverityError   = "error"
+)
+
+// SPFFinding describes a problem found in a published SPF record
+type SPFFinding struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Term     string `json:"term,omitempty"`
+}
+
+// SPFAnalysis is the result of validating a domain's published SPF record
+type SPFAnalysis struct {
+	Domain      string       `json:"domain"`
+	Records     []string     `json:"records"`
+	LookupCount int          `json:"lookup_count"`
+	LookupLimit int          `json:"lookup_limit"`
+	Valid       bool         `json:"valid"` // No error-severity findings
+	Findings    []SPFFinding `json:"findings"`
+	CheckedAt   time.Time    `json:"checked_at"`
+}
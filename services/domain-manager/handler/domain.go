@@ -25,6 +25,7 @@ type DomainHandler struct {
 	statsRepo    *repository.StatsRepository
 	dnsService   *service.DNSService
 	dkimService  *service.DKIMService
+	catchAll     *service.CatchAllService
 	validator    *validator.Validate
 	logger       *zap.Logger
 }
@@ -39,6 +40,7 @@ func NewDomainHandler(
 	statsRepo *repository.StatsRepository,
 	dnsService *service.DNSService,
 	dkimService *service.DKIMService,
+	catchAll *service.CatchAllService,
 	logger *zap.Logger,
 ) *DomainHandler {
 	return &DomainHandler{
@@ -50,6 +52,7 @@ func NewDomainHandler(
 		statsRepo:    statsRepo,
 		dnsService:   dnsService,
 		dkimService:  dkimService,
+		catchAll:     catchAll,
 		validator:    validator.New(),
 		logger:       logger,
 	}
@@ -92,6 +95,7 @@ func (h *DomainHandler) Routes() chi.Router {
 	// Domain verification
 	r.Post("/{id}/verify", h.VerifyDomain)
 	r.Post("/{id}/check-dns", h.CheckDNS)
+	r.Get("/{id}/spf-analysis", h.GetSPFAnalysis)
 
 	// DKIM management
 	r.Post("/{id}/dkim/generate", h.GenerateDKIMKey)
@@ -111,6 +115,11 @@ func (h *DomainHandler) Routes() chi.Router {
 	// Catch-all
 	r.Put("/{id}/catch-all", h.UpdateCatchAll)
 	r.Get("/{id}/catch-all", h.GetCatchAll)
+	r.Post("/{id}/catch-all/rules", h.CreateCatchAllRule)
+	r.Get("/{id}/catch-all/rules", h.ListCatchAllRules)
+	r.Put("/{id}/catch-all/rules/{ruleId}", h.UpdateCatchAllRule)
+	r.Delete("/{id}/catch-all/rules/{ruleId}", h.DeleteCatchAllRule)
+	r.Post("/{id}/catch-all/test", h.TestCatchAll)
 
 	// Stats
 	r.Get("/{id}/stats", h.GetStats)
@@ -400,6 +409,33 @@ func (h *DomainHandler) CheckDNS(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, result)
 }
 
+// GetSPFAnalysis validates the domain's published SPF record
+func (h *DomainHandler) GetSPFAnalysis(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	d, err := h.domainRepo.GetByID(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get domain", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to get domain", "")
+		return
+	}
+	if d == nil {
+		h.respondError(w, http.StatusNotFound, "Domain not found", "")
+		return
+	}
+
+	analysis, err := h.dnsService.AnalyzeSPF(r.Context(), d.DomainName)
+	if err != nil {
+		h.logger.Error("Failed to analyze SPF record",
+			zap.String("domain", d.DomainName),
+			zap.Error(err))
+		h.respondError(w, http.StatusBadGateway, "Failed to lookup SPF record", err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, analysis)
+}
+
 // Helper methods
 func (h *DomainHandler) respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
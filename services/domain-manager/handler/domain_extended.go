@@ -2,7 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -10,15 +13,18 @@ import (
 	"go.uber.org/zap"
 
 	"domain-manager/domain"
+	"domain-manager/service"
 )
 
 // DKIM Request types
 type GenerateDKIMRequest struct {
-	Selector string `json:"selector"`
+	Selector  string `json:"selector"`
+	Algorithm string `json:"algorithm"`
 }
 
 type RotateDKIMRequest struct {
 	NewSelector string `json:"new_selector"`
+	Algorithm   string `json:"algorithm"`
 }
 
 // GenerateDKIMKey generates a new DKIM key for a domain
@@ -38,12 +44,16 @@ func (h *DomainHandler) GenerateDKIMKey(w http.ResponseWriter, r *http.Request)
 
 	var req GenerateDKIMRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		// Use default selector
-		req.Selector = ""
+		// Use default selector and algorithm
+		req = GenerateDKIMRequest{}
 	}
 
 	// Generate DKIM key pair
-	key, err := h.dkimService.GenerateKeyPair(domainID, req.Selector)
+	key, err := h.dkimService.GenerateKeyPair(domainID, req.Selector, req.Algorithm)
+	if errors.Is(err, service.ErrUnsupportedDKIMAlgorithm) {
+		h.respondError(w, http.StatusBadRequest, "Unsupported DKIM algorithm", err.Error())
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to generate DKIM key", zap.Error(err))
 		h.respondError(w, http.StatusInternalServerError, "Failed to generate DKIM key", "")
@@ -179,7 +189,7 @@ func (h *DomainHandler) RotateDKIMKey(w http.ResponseWriter, r *http.Request) {
 
 	var req RotateDKIMRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		req.NewSelector = ""
+		req = RotateDKIMRequest{}
 	}
 
 	// Generate new key with new selector
@@ -189,7 +199,17 @@ func (h *DomainHandler) RotateDKIMKey(w http.ResponseWriter, r *http.Request) {
 		newSelector = time.Now().Format("200601")
 	}
 
-	newKey, err := h.dkimService.GenerateKeyPair(domainID, newSelector)
+	// Keep the current key's algorithm unless a different one is requested
+	algorithm := req.Algorithm
+	if algorithm == "" {
+		algorithm = currentKey.Algorithm
+	}
+
+	newKey, err := h.dkimService.GenerateKeyPair(domainID, newSelector, algorithm)
+	if errors.Is(err, service.ErrUnsupportedDKIMAlgorithm) {
+		h.respondError(w, http.StatusBadRequest, "Unsupported DKIM algorithm", err.Error())
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to generate new DKIM key", zap.Error(err))
 		h.respondError(w, http.StatusInternalServerError, "Failed to rotate DKIM key", "")
@@ -371,6 +391,9 @@ type UpdatePoliciesRequest struct {
 	AutoBCCAddress           *string                  `json:"auto_bcc_address"`
 	DefaultSignatureEnforced bool                     `json:"default_signature_enforced"`
 	AttachmentPolicy         *domain.AttachmentPolicy `json:"attachment_policy"`
+	OutboundFooterText       *string                  `json:"outbound_footer_text"`
+	OutboundFooterHTML       *string                  `json:"outbound_footer_html"`
+	DKIMBodyLength           bool                     `json:"dkim_body_length"`
 }
 
 // UpdatePolicies updates domain policies
@@ -394,6 +417,14 @@ func (h *DomainHandler) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, footer := range []*string{req.OutboundFooterText, req.OutboundFooterHTML} {
+		if footer != nil && len(*footer) > domain.MaxOutboundFooterLength {
+			h.respondError(w, http.StatusBadRequest, "Outbound footer too long",
+				fmt.Sprintf("footers are limited to %d bytes", domain.MaxOutboundFooterLength))
+			return
+		}
+	}
+
 	// Get existing policies or create new
 	policies, _ := h.policiesRepo.GetByDomainID(r.Context(), domainID)
 	if policies == nil {
@@ -412,6 +443,9 @@ func (h *DomainHandler) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 	policies.AutoBCCAddress = req.AutoBCCAddress
 	policies.DefaultSignatureEnforced = req.DefaultSignatureEnforced
 	policies.AttachmentPolicy = req.AttachmentPolicy
+	policies.OutboundFooterText = blankToNil(req.OutboundFooterText)
+	policies.OutboundFooterHTML = blankToNil(req.OutboundFooterHTML)
+	policies.DKIMBodyLength = req.DKIMBodyLength
 	policies.UpdatedAt = time.Now()
 
 	if err := h.policiesRepo.Upsert(r.Context(), policies); err != nil {
@@ -423,6 +457,14 @@ func (h *DomainHandler) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, policies)
 }
 
+// blankToNil clears optional text made up only of whitespace
+func blankToNil(s *string) *string {
+	if s == nil || strings.TrimSpace(*s) == "" {
+		return nil
+	}
+	return s
+}
+
 // GetPolicies returns domain policies
 func (h *DomainHandler) GetPolicies(w http.ResponseWriter, r *http.Request) {
 	domainID := chi.URLParam(r, "id")
@@ -509,6 +551,16 @@ func (h *DomainHandler) UpdateCatchAll(w http.ResponseWriter, r *http.Request) {
 	config.ForwardTo = req.ForwardTo
 	config.UpdatedAt = time.Now()
 
+	rules, err := h.catchAllRepo.ListRules(r.Context(), domainID)
+	if err != nil {
+		h.logger.Error("Failed to list catch-all rules", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to update catch-all configuration", "")
+		return
+	}
+	if !h.checkCatchAllLoops(w, r, d, rules, config) {
+		return
+	}
+
 	if err := h.catchAllRepo.Upsert(r.Context(), config); err != nil {
 		h.logger.Error("Failed to update catch-all config", zap.Error(err))
 		h.respondError(w, http.StatusInternalServerError, "Failed to update catch-all configuration", "")
@@ -550,6 +602,292 @@ func (h *DomainHandler) GetCatchAll(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, config)
 }
 
+type CatchAllRuleRequest struct {
+	Position    int    `json:"position" validate:"gte=0"`
+	MatchType   string `json:"match_type" validate:"required,oneof=exact wildcard regex"`
+	Pattern     string `json:"pattern" validate:"required"`
+	Action      string `json:"action" validate:"required,oneof=deliver forward"`
+	Destination string `json:"destination" validate:"required"`
+	Enabled     *bool  `json:"enabled"`
+}
+
+type TestCatchAllRequest struct {
+	Address string `json:"address" validate:"required"`
+}
+
+// ListCatchAllRules returns a domain's catch-all rules in evaluation order
+func (h *DomainHandler) ListCatchAllRules(w http.ResponseWriter, r *http.Request) {
+	domainID := chi.URLParam(r, "id")
+
+	d, err := h.domainRepo.GetByID(r.Context(), domainID)
+	if err != nil {
+		h.logger.Error("Failed to get domain", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to get domain", "")
+		return
+	}
+	if d == nil {
+		h.respondError(w, http.StatusNotFound, "Domain not found", "")
+		return
+	}
+
+	rules, err := h.catchAllRepo.ListRules(r.Context(), domainID)
+	if err != nil {
+		h.logger.Error("Failed to list catch-all rules", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to list catch-all rules", "")
+		return
+	}
+	if rules == nil {
+		rules = []*domain.CatchAllRule{}
+	}
+
+	h.respondJSON(w, http.StatusOK, rules)
+}
+
+// CreateCatchAllRule adds a catch-all rule to a domain
+func (h *DomainHandler) CreateCatchAllRule(w http.ResponseWriter, r *http.Request) {
+	domainID := chi.URLParam(r, "id")
+
+	d, err := h.domainRepo.GetByID(r.Context(), domainID)
+	if err != nil {
+		h.logger.Error("Failed to get domain", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to get domain", "")
+		return
+	}
+	if d == nil {
+		h.respondError(w, http.StatusNotFound, "Domain not found", "")
+		return
+	}
+
+	var req CatchAllRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Validation failed", err.Error())
+		return
+	}
+
+	now := time.Now()
+	rule := &domain.CatchAllRule{
+		ID:        uuid.New().String(),
+		DomainID:  domainID,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	applyCatchAllRuleRequest(rule, &req)
+
+	rules, ok := h.prepareCatchAllRule(w, r, d, rule)
+	if !ok {
+		return
+	}
+	if !h.checkCatchAllLoops(w, r, d, append(rules, rule), nil) {
+		return
+	}
+
+	if err := h.catchAllRepo.CreateRule(r.Context(), rule); err != nil {
+		h.logger.Error("Failed to create catch-all rule", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to create catch-all rule", "")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, rule)
+}
+
+// UpdateCatchAllRule replaces a catch-all rule
+func (h *DomainHandler) UpdateCatchAllRule(w http.ResponseWriter, r *http.Request) {
+	domainID := chi.URLParam(r, "id")
+	ruleID := chi.URLParam(r, "ruleId")
+
+	d, err := h.domainRepo.GetByID(r.Context(), domainID)
+	if err != nil {
+		h.logger.Error("Failed to get domain", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to get domain", "")
+		return
+	}
+	if d == nil {
+		h.respondError(w, http.StatusNotFound, "Domain not found", "")
+		return
+	}
+
+	rule, err := h.catchAllRepo.GetRule(r.Context(), domainID, ruleID)
+	if err != nil {
+		h.logger.Error("Failed to get catch-all rule", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to get catch-all rule", "")
+		return
+	}
+	if rule == nil {
+		h.respondError(w, http.StatusNotFound, "Catch-all rule not found", "")
+		return
+	}
+
+	var req CatchAllRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Validation failed", err.Error())
+		return
+	}
+
+	position := rule.Position
+	applyCatchAllRuleRequest(rule, &req)
+	if req.Position == 0 {
+		rule.Position = position
+	}
+	rule.UpdatedAt = time.Now()
+
+	rules, ok := h.prepareCatchAllRule(w, r, d, rule)
+	if !ok {
+		return
+	}
+	for i, existing := range rules {
+		if existing.ID == rule.ID {
+			rules[i] = rule
+		}
+	}
+	if !h.checkCatchAllLoops(w, r, d, rules, nil) {
+		return
+	}
+
+	if err := h.catchAllRepo.UpdateRule(r.Context(), rule); err != nil {
+		h.logger.Error("Failed to update catch-all rule", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to update catch-all rule", "")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, rule)
+}
+
+// DeleteCatchAllRule deletes a catch-all rule
+func (h *DomainHandler) DeleteCatchAllRule(w http.ResponseWriter, r *http.Request) {
+	domainID := chi.URLParam(r, "id")
+	ruleID := chi.URLParam(r, "ruleId")
+
+	rule, err := h.catchAllRepo.GetRule(r.Context(), domainID, ruleID)
+	if err != nil {
+		h.logger.Error("Failed to get catch-all rule", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to get catch-all rule", "")
+		return
+	}
+	if rule == nil {
+		h.respondError(w, http.StatusNotFound, "Catch-all rule not found", "")
+		return
+	}
+
+	if err := h.catchAllRepo.DeleteRule(r.Context(), domainID, ruleID); err != nil {
+		h.logger.Error("Failed to delete catch-all rule", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete catch-all rule", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestCatchAll reports which catch-all rule, if any, routes mail for an
+// address of the domain
+func (h *DomainHandler) TestCatchAll(w http.ResponseWriter, r *http.Request) {
+	domainID := chi.URLParam(r, "id")
+
+	d, err := h.domainRepo.GetByID(r.Context(), domainID)
+	if err != nil {
+		h.logger.Error("Failed to get domain", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to get domain", "")
+		return
+	}
+	if d == nil {
+		h.respondError(w, http.StatusNotFound, "Domain not found", "")
+		return
+	}
+
+	var req TestCatchAllRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Validation failed", err.Error())
+		return
+	}
+
+	result, err := h.catchAll.Route(r.Context(), d, req.Address)
+	if errors.Is(err, service.ErrAddressNotOnDomain) {
+		h.respondError(w, http.StatusBadRequest, "Invalid address", err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to test catch-all routing", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to test catch-all routing", "")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+func applyCatchAllRuleRequest(rule *domain.CatchAllRule, req *CatchAllRuleRequest) {
+	rule.Position = req.Position
+	rule.MatchType = domain.CatchAllMatchType(req.MatchType)
+	rule.Pattern = req.Pattern
+	rule.Action = domain.CatchAllAction(req.Action)
+	rule.Destination = req.Destination
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
+
+// prepareCatchAllRule validates a rule about to be saved and returns the
+// domain's current rules. It writes the error response and returns false if
+// the rule is invalid or the rules can't be loaded.
+func (h *DomainHandler) prepareCatchAllRule(w http.ResponseWriter, r *http.Request, d *domain.Domain, rule *domain.CatchAllRule) ([]*domain.CatchAllRule, bool) {
+	err := h.catchAll.ValidateRule(r.Context(), d, rule)
+	if errors.Is(err, service.ErrInvalidCatchAllRule) {
+		h.respondError(w, http.StatusBadRequest, "Invalid catch-all rule", err.Error())
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error("Failed to validate catch-all rule", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to save catch-all rule", "")
+		return nil, false
+	}
+
+	rules, err := h.catchAllRepo.ListRules(r.Context(), d.ID)
+	if err != nil {
+		h.logger.Error("Failed to list catch-all rules", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to save catch-all rule", "")
+		return nil, false
+	}
+	return rules, true
+}
+
+// checkCatchAllLoops rejects catch-all routing that would forward mail in a
+// loop. A nil fallback means the domain's saved catch-all config. It writes
+// the error response and returns false if the routing can't be saved.
+func (h *DomainHandler) checkCatchAllLoops(w http.ResponseWriter, r *http.Request, d *domain.Domain, rules []*domain.CatchAllRule, fallback *domain.CatchAllConfig) bool {
+	if fallback == nil {
+		var err error
+		fallback, err = h.catchAllRepo.GetByDomainID(r.Context(), d.ID)
+		if err != nil {
+			h.logger.Error("Failed to get catch-all config", zap.Error(err))
+			h.respondError(w, http.StatusInternalServerError, "Failed to check catch-all routing", "")
+			return false
+		}
+	}
+
+	err := h.catchAll.CheckLoops(r.Context(), d, rules, fallback)
+	if errors.Is(err, service.ErrCatchAllLoop) {
+		h.respondError(w, http.StatusConflict, "Catch-all routing loop", err.Error())
+		return false
+	}
+	if err != nil {
+		h.logger.Error("Failed to check catch-all routing", zap.Error(err))
+		h.respondError(w, http.StatusInternalServerError, "Failed to check catch-all routing", "")
+		return false
+	}
+	return true
+}
+
 // GetStats returns domain statistics
 func (h *DomainHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	domainID := chi.URLParam(r, "id")
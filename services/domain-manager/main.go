@@ -82,15 +82,18 @@ func main() {
 	policiesRepo := repository.NewPoliciesRepository(db, logger)
 	catchAllRepo := repository.NewCatchAllRepository(db, logger)
 	statsRepo := repository.NewStatsRepository(db, logger)
+	dmarcReportRepo := repository.NewDMARCReportRepository(db, logger)
 
 	// Initialize services
 	dnsService := service.NewDNSService(&cfg.DNS, logger)
 	dkimService := service.NewDKIMService(&cfg.DKIM, &cfg.DNS, logger)
+	dmarcReportService := service.NewDMARCReportService(domainRepo, dmarcReportRepo, dnsService, &cfg.DMARC, logger)
+	catchAllService := service.NewCatchAllService(domainRepo, catchAllRepo, logger)
 
 	// Initialize handlers
 	domainHandler := handler.NewDomainHandler(
 		domainRepo, dkimRepo, brandingRepo, policiesRepo, catchAllRepo, statsRepo,
-		dnsService, dkimService, logger,
+		dnsService, dkimService, catchAllService, logger,
 	)
 	publicHandler := handler.NewPublicHandler(domainRepo, brandingRepo, logger)
 
@@ -102,6 +105,22 @@ func main() {
 		logger.Fatal("Failed to start DNS monitor", zap.Error(err))
 	}
 
+	// Initialize DKIM key rotator; its alerts go to the DNS monitor's channel
+	dkimRotator := monitor.NewDKIMRotator(domainRepo, dkimRepo, dkimService, dnsService, dnsMonitor, &cfg.DKIM, logger)
+	if cfg.DKIM.AutoRotate {
+		if err := dkimRotator.Start(); err != nil {
+			logger.Fatal("Failed to start DKIM rotator", zap.Error(err))
+		}
+	}
+
+	// Initialize DMARC aggregate reporter
+	dmarcReporter := monitor.NewDMARCReporter(domainRepo, dmarcReportRepo, dmarcReportService, &cfg.DMARC, logger)
+	if cfg.DMARC.ReportingEnabled {
+		if err := dmarcReporter.Start(); err != nil {
+			logger.Fatal("Failed to start DMARC reporter", zap.Error(err))
+		}
+	}
+
 	// Process DNS alerts in background
 	go func() {
 		for alert := range dnsMonitor.Alerts() {
@@ -185,8 +204,16 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Stop the DKIM rotator before the DNS monitor closes its alert channel
+	if cfg.DKIM.AutoRotate {
+		dkimRotator.Stop()
+	}
+
 	// Stop DNS monitor
 	dnsMonitor.Stop()
+	if cfg.DMARC.ReportingEnabled {
+		dmarcReporter.Stop()
+	}
 
 	// Shutdown server with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
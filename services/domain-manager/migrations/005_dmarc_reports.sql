@@ -0,0 +1,38 @@
+-- DMARC Aggregate Reporting Schema
+-- Stores per-message authentication results and the RFC 7489 aggregate reports built from them
+
+-- Authentication results observed for mail claiming a managed domain
+CREATE TABLE IF NOT EXISTS dmarc_auth_results (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    domain_id UUID NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    source_ip INET NOT NULL,
+    header_from VARCHAR(255) NOT NULL,
+    envelope_from VARCHAR(255),
+    spf_domain VARCHAR(255),
+    spf_result VARCHAR(20) NOT NULL, -- 'pass', 'fail', 'softfail', 'neutral', 'none', 'temperror', 'permerror'
+    dkim_domain VARCHAR(255),
+    dkim_selector VARCHAR(255),
+    dkim_result VARCHAR(20) NOT NULL, -- 'pass', 'fail', 'neutral', 'none', 'policy', 'temperror', 'permerror'
+    disposition VARCHAR(20), -- Applied disposition, NULL to evaluate from the published policy
+    message_count INTEGER NOT NULL DEFAULT 1,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dmarc_auth_results_domain_recorded ON dmarc_auth_results(domain_id, recorded_at);
+
+-- Generated aggregate reports (gzipped XML)
+CREATE TABLE IF NOT EXISTS dmarc_aggregate_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    domain_id UUID NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    report_id VARCHAR(255) NOT NULL UNIQUE,
+    date_range_begin TIMESTAMPTZ NOT NULL,
+    date_range_end TIMESTAMPTZ NOT NULL,
+    record_count INTEGER NOT NULL DEFAULT 0,
+    message_count BIGINT NOT NULL DEFAULT 0,
+    report_data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+
+    UNIQUE(domain_id, date_range_begin, date_range_end)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dmarc_aggregate_reports_domain_id ON dmarc_aggregate_reports(domain_id);
@@ -0,0 +1,11 @@
+-- DKIM Key Rotation Schema
+-- Tracks keys generated by the rotation worker until they replace the key they succeed
+
+-- The key a rotation successor replaces; set on keys created by automatic rotation
+ALTER TABLE dkim_keys ADD COLUMN IF NOT EXISTS replaces_key_id UUID REFERENCES dkim_keys(id) ON DELETE SET NULL;
+
+-- When a replaced key stops signing; it stays active until then so mail in flight still verifies
+ALTER TABLE dkim_keys ADD COLUMN IF NOT EXISTS retire_after TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_dkim_keys_replaces_key_id ON dkim_keys(replaces_key_id) WHERE replaces_key_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_dkim_keys_retire_after ON dkim_keys(retire_after) WHERE retire_after IS NOT NULL;
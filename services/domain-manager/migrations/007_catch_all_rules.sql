@@ -0,0 +1,41 @@
+-- Catch-All Routing Rules Schema
+-- Ordered rules matching the local part of unknown addresses, evaluated before
+-- the domain's single catch-all destination (domain_catch_all)
+
+CREATE TABLE IF NOT EXISTS domain_catch_all_rules (
+    id UUID PRIMARY KEY,
+    domain_id UUID NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL, -- Lower number = evaluated first
+    match_type VARCHAR(20) NOT NULL, -- 'exact', 'wildcard', 'regex'
+    pattern VARCHAR(255) NOT NULL,
+    action VARCHAR(20) NOT NULL, -- 'deliver', 'forward'
+    destination VARCHAR(255) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT valid_catch_all_match_type CHECK (match_type IN ('exact', 'wildcard', 'regex')),
+    CONSTRAINT valid_catch_all_rule_action CHECK (action IN ('deliver', 'forward'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_domain_catch_all_rules_domain_id ON domain_catch_all_rules(domain_id, position);
+
+DROP TRIGGER IF EXISTS update_domain_catch_all_rules_updated_at ON domain_catch_all_rules;
+CREATE TRIGGER update_domain_catch_all_rules_updated_at BEFORE UPDATE ON domain_catch_all_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Tell the SMTP servers to reload a domain's rules; the payload carries the
+-- domain ID since rules are cached per domain
+CREATE OR REPLACE FUNCTION notify_catch_all_rule_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('domain_changes',
+        TG_TABLE_NAME || ':' || TG_OP || ':' || COALESCE(NEW.domain_id::text, OLD.domain_id::text));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS catch_all_rule_change_trigger ON domain_catch_all_rules;
+CREATE TRIGGER catch_all_rule_change_trigger
+AFTER INSERT OR UPDATE OR DELETE ON domain_catch_all_rules
+FOR EACH ROW EXECUTE FUNCTION notify_catch_all_rule_change();
@@ -0,0 +1,21 @@
+-- Outbound Footer Schema
+-- Legal disclaimer the SMTP servers append to mail sent from a domain
+
+ALTER TABLE domain_policies ADD COLUMN IF NOT EXISTS outbound_footer_text TEXT;
+ALTER TABLE domain_policies ADD COLUMN IF NOT EXISTS outbound_footer_html TEXT;
+
+-- Tell the SMTP servers to reload the domain; the payload carries the domain
+-- ID since policies are cached with the domain
+CREATE OR REPLACE FUNCTION notify_domain_policies_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('domain_changes',
+        TG_TABLE_NAME || ':' || TG_OP || ':' || COALESCE(NEW.domain_id::text, OLD.domain_id::text));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS domain_policies_change_trigger ON domain_policies;
+CREATE TRIGGER domain_policies_change_trigger
+AFTER INSERT OR UPDATE OR DELETE ON domain_policies
+FOR EACH ROW EXECUTE FUNCTION notify_domain_policies_change();
@@ -0,0 +1,6 @@
+-- DKIM Body Length Schema
+-- Opt-in l= tag on a domain's DKIM signatures, so mailing list footers
+-- appended to its mail don't break them. Content after the signed length is
+-- unsigned, so this is off by default.
+
+ALTER TABLE domain_policies ADD COLUMN IF NOT EXISTS dkim_body_length BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,314 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+
+	"domain-manager/config"
+	"domain-manager/domain"
+	"domain-manager/repository"
+	"domain-manager/service"
+)
+
+// DKIMRotator rotates DKIM keys before they expire. A rotation generates a
+// key with a new selector, waits until its record is visible in DNS,
+// activates it, and retires the old key after a grace period so mail signed
+// with the old key still verifies while in flight. Every transition is
+// reported on the DNS monitor's alert channel.
+type DKIMRotator struct {
+	domainRepo  *repository.DomainRepository
+	dkimRepo    *repository.DKIMKeyRepository
+	dkimService *service.DKIMService
+	dnsService  *service.DNSService
+	alerts      *DNSMonitor
+	config      *config.DKIMConfig
+	cron        *cron.Cron
+	logger      *zap.Logger
+}
+
+// NewDKIMRotator creates a new DKIM key rotator
+func NewDKIMRotator(
+	domainRepo *repository.DomainRepository,
+	dkimRepo *repository.DKIMKeyRepository,
+	dkimService *service.DKIMService,
+	dnsService *service.DNSService,
+	alerts *DNSMonitor,
+	cfg *config.DKIMConfig,
+	logger *zap.Logger,
+) *DKIMRotator {
+	return &DKIMRotator{
+		domainRepo:  domainRepo,
+		dkimRepo:    dkimRepo,
+		dkimService: dkimService,
+		dnsService:  dnsService,
+		alerts:      alerts,
+		config:      cfg,
+		cron:        cron.New(cron.WithSeconds(), cron.WithLocation(time.UTC)),
+		logger:      logger,
+	}
+}
+
+// Start starts the DKIM rotation cron job
+func (m *DKIMRotator) Start() error {
+	_, err := m.cron.AddFunc(m.config.RotationSchedule, func() {
+		m.rotateAll()
+	})
+	if err != nil {
+		return err
+	}
+
+	m.cron.Start()
+	m.logger.Info("DKIM rotator started", zap.String("schedule", m.config.RotationSchedule))
+
+	return nil
+}
+
+// Stop stops the DKIM rotator. It must be stopped before the DNS monitor,
+// which closes the alert channel.
+func (m *DKIMRotator) Stop() {
+	ctx := m.cron.Stop()
+	<-ctx.Done()
+	m.logger.Info("DKIM rotator stopped")
+}
+
+// rotateAll advances every rotation by one step
+func (m *DKIMRotator) rotateAll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	m.startRotations(ctx, now)
+	m.activatePending(ctx, now)
+	m.retireReplaced(ctx, now)
+}
+
+// startRotations generates a successor for every key that expires within the
+// lead time. The successor stays inactive until its record is published.
+func (m *DKIMRotator) startRotations(ctx context.Context, now time.Time) {
+	keys, err := m.dkimRepo.GetRotationCandidates(ctx, now.Add(m.config.RotationLeadTime), m.config.KeyLifetime)
+	if err != nil {
+		m.logger.Error("Failed to get DKIM rotation candidates", zap.Error(err))
+		return
+	}
+
+	for _, key := range keys {
+		d := m.getDomain(ctx, key.DomainID)
+		if d == nil {
+			continue
+		}
+
+		existing, err := m.dkimRepo.ListByDomain(ctx, d.ID)
+		if err != nil {
+			m.logger.Error("Failed to list DKIM keys", zap.String("domain_id", d.ID), zap.Error(err))
+			continue
+		}
+
+		newKey, err := m.dkimService.GenerateKeyPair(d.ID, rotationSelector(existing, now), key.Algorithm)
+		if err != nil {
+			m.logger.Error("Failed to generate DKIM key", zap.String("domain_id", d.ID), zap.Error(err))
+			continue
+		}
+		newKey.ReplacesKeyID = &key.ID
+
+		if err := m.dkimRepo.Create(ctx, newKey); err != nil {
+			m.logger.Error("Failed to save DKIM key", zap.String("domain_id", d.ID), zap.Error(err))
+			continue
+		}
+
+		expiresAt, _ := keyExpiry(key, m.config.KeyLifetime)
+		m.alert(d, "dkim_rotation_started", "medium", fmt.Sprintf(
+			"DKIM key %q expires on %s. Publish a TXT record at %s with the value %q. The new key is activated once the record is visible in DNS.",
+			key.Selector, expiresAt.Format(time.RFC3339),
+			m.dkimService.GetDNSRecordName(newKey.Selector, d.DomainName),
+			m.dkimService.GetDNSRecord(newKey, d.DomainName),
+		))
+	}
+}
+
+// activatePending activates successors whose records have propagated and
+// schedules the keys they replace for retirement
+func (m *DKIMRotator) activatePending(ctx context.Context, now time.Time) {
+	keys, err := m.dkimRepo.ListPendingRotations(ctx)
+	if err != nil {
+		m.logger.Error("Failed to list pending DKIM rotations", zap.Error(err))
+		return
+	}
+
+	for _, key := range keys {
+		d := m.getDomain(ctx, key.DomainID)
+		if d == nil {
+			continue
+		}
+
+		old, err := m.dkimRepo.GetByID(ctx, *key.ReplacesKeyID)
+		if err != nil {
+			m.logger.Error("Failed to get DKIM key", zap.String("key_id", *key.ReplacesKeyID), zap.Error(err))
+			continue
+		}
+
+		// The old key was rotated by hand in the meantime
+		if old == nil || !old.IsActive {
+			if err := m.dkimRepo.MarkRotated(ctx, key.ID); err != nil {
+				m.logger.Error("Failed to cancel DKIM rotation", zap.String("key_id", key.ID), zap.Error(err))
+				continue
+			}
+			m.alert(d, "dkim_rotation_cancelled", "low", fmt.Sprintf(
+				"DKIM rotation to selector %q was cancelled because the key it replaces is no longer active.", key.Selector))
+			continue
+		}
+
+		published, err := m.dnsService.DKIMRecordPublished(ctx, d.DomainName, key.Selector, m.dkimService.GetDNSRecord(key, d.DomainName))
+		if err != nil {
+			m.logger.Warn("Failed to look up DKIM record",
+				zap.String("domain", d.DomainName),
+				zap.String("selector", key.Selector),
+				zap.Error(err),
+			)
+		}
+
+		if !published {
+			if now.Sub(key.CreatedAt) >= m.config.PropagationTimeout {
+				m.alert(d, "dkim_rotation_stalled", "high", fmt.Sprintf(
+					"The DKIM record for selector %q has not been seen at %s since %s. Publish the record to complete the rotation.",
+					key.Selector, m.dkimService.GetDNSRecordName(key.Selector, d.DomainName), key.CreatedAt.Format(time.RFC3339)))
+			}
+			if expiresAt, ok := keyExpiry(old, m.config.KeyLifetime); ok && !now.Before(expiresAt) {
+				m.alert(d, "dkim_key_expired", "critical", fmt.Sprintf(
+					"DKIM key %q expired on %s. It keeps signing until the record for its replacement %q propagates.",
+					old.Selector, expiresAt.Format(time.RFC3339), key.Selector))
+			}
+			continue
+		}
+
+		if err := m.dkimRepo.Activate(ctx, key.ID); err != nil {
+			m.logger.Error("Failed to activate DKIM key", zap.String("key_id", key.ID), zap.Error(err))
+			continue
+		}
+
+		retireAfter := now.Add(m.config.RotationGracePeriod)
+		if err := m.dkimRepo.SetRetireAfter(ctx, old.ID, retireAfter); err != nil {
+			m.logger.Error("Failed to schedule DKIM key retirement", zap.String("key_id", old.ID), zap.Error(err))
+		}
+
+		m.alert(d, "dkim_rotation_activated", "low", fmt.Sprintf(
+			"DKIM key %q is now active. Key %q is retired after %s.",
+			key.Selector, old.Selector, retireAfter.Format(time.RFC3339)))
+	}
+}
+
+// retireReplaced retires keys whose grace period has ended. A key is only
+// retired while its successor is active and still published, so a domain is
+// never left without a working key.
+func (m *DKIMRotator) retireReplaced(ctx context.Context, now time.Time) {
+	keys, err := m.dkimRepo.ListRetiring(ctx, now)
+	if err != nil {
+		m.logger.Error("Failed to list retiring DKIM keys", zap.Error(err))
+		return
+	}
+
+	for _, key := range keys {
+		d := m.getDomain(ctx, key.DomainID)
+		if d == nil {
+			continue
+		}
+
+		siblings, err := m.dkimRepo.ListByDomain(ctx, d.ID)
+		if err != nil {
+			m.logger.Error("Failed to list DKIM keys", zap.String("domain_id", d.ID), zap.Error(err))
+			continue
+		}
+
+		successor := activeSuccessor(siblings, key.ID)
+		if successor == nil {
+			m.alert(d, "dkim_retirement_blocked", "high", fmt.Sprintf(
+				"DKIM key %q was not retired because no active key replaces it.", key.Selector))
+			continue
+		}
+
+		published, err := m.dnsService.DKIMRecordPublished(ctx, d.DomainName, successor.Selector, m.dkimService.GetDNSRecord(successor, d.DomainName))
+		if err != nil || !published {
+			m.alert(d, "dkim_retirement_blocked", "high", fmt.Sprintf(
+				"DKIM key %q was not retired because the record for its replacement %q is not visible at %s.",
+				key.Selector, successor.Selector, m.dkimService.GetDNSRecordName(successor.Selector, d.DomainName)))
+			continue
+		}
+
+		if err := m.dkimRepo.MarkRotated(ctx, key.ID); err != nil {
+			m.logger.Error("Failed to retire DKIM key", zap.String("key_id", key.ID), zap.Error(err))
+			continue
+		}
+
+		m.alert(d, "dkim_key_retired", "low", fmt.Sprintf(
+			"DKIM key %q was retired. Its record at %s can be removed.",
+			key.Selector, m.dkimService.GetDNSRecordName(key.Selector, d.DomainName)))
+	}
+}
+
+// getDomain returns a key's domain, or nil if it can't be loaded
+func (m *DKIMRotator) getDomain(ctx context.Context, domainID string) *domain.Domain {
+	d, err := m.domainRepo.GetByID(ctx, domainID)
+	if err != nil {
+		m.logger.Error("Failed to get domain", zap.String("domain_id", domainID), zap.Error(err))
+		return nil
+	}
+	return d
+}
+
+// alert sends a rotation alert for a domain
+func (m *DKIMRotator) alert(d *domain.Domain, alertType, severity, message string) {
+	m.alerts.sendAlert(domain.DNSMonitorAlert{
+		ID:         generateAlertID(),
+		DomainID:   d.ID,
+		DomainName: d.DomainName,
+		AlertType:  alertType,
+		RecordType: "TXT",
+		Severity:   severity,
+		Message:    message,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// keyExpiry returns when a key expires. Keys without an expiry expire
+// lifetime after activation, or never with a zero lifetime.
+func keyExpiry(key *domain.DKIMKey, lifetime time.Duration) (time.Time, bool) {
+	if key.ExpiresAt != nil {
+		return *key.ExpiresAt, true
+	}
+	if lifetime <= 0 {
+		return time.Time{}, false
+	}
+	if key.ActivatedAt != nil {
+		return key.ActivatedAt.Add(lifetime), true
+	}
+	return key.CreatedAt.Add(lifetime), true
+}
+
+// rotationSelector returns a date-based selector that none of the domain's
+// keys use, since selectors are unique per domain
+func rotationSelector(keys []*domain.DKIMKey, now time.Time) string {
+	used := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		used[k.Selector] = true
+	}
+
+	base := now.UTC().Format("20060102")
+	selector := base
+	for i := 2; used[selector]; i++ {
+		selector = fmt.Sprintf("%s-%d", base, i)
+	}
+	return selector
+}
+
+// activeSuccessor returns the active key that replaces keyID, if any
+func activeSuccessor(keys []*domain.DKIMKey, keyID string) *domain.DKIMKey {
+	for _, k := range keys {
+		if k.IsActive && k.ReplacesKeyID != nil && *k.ReplacesKeyID == keyID {
+			return k
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"domain-manager/domain"
+)
+
+func TestRotationSelector(t *testing.T) {
+	now := time.Date(2025, 3, 9, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		used []string
+		want string
+	}{
+		{"unused date", []string{"mail"}, "20250309"},
+		{"date taken", []string{"mail", "20250309"}, "20250309-2"},
+		{"several taken", []string{"20250309", "20250309-2", "20250309-3"}, "20250309-4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []*domain.DKIMKey
+			for _, selector := range tt.used {
+				keys = append(keys, &domain.DKIMKey{Selector: selector})
+			}
+			if got := rotationSelector(keys, now); got != tt.want {
+				t.Errorf("rotationSelector() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyExpiry(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	activated := created.Add(24 * time.Hour)
+	expires := created.Add(30 * 24 * time.Hour)
+	lifetime := 90 * 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		key      *domain.DKIMKey
+		lifetime time.Duration
+		want     time.Time
+		wantOK   bool
+	}{
+		{"explicit expiry", &domain.DKIMKey{CreatedAt: created, ActivatedAt: &activated, ExpiresAt: &expires}, lifetime, expires, true},
+		{"from activation", &domain.DKIMKey{CreatedAt: created, ActivatedAt: &activated}, lifetime, activated.Add(lifetime), true},
+		{"from creation", &domain.DKIMKey{CreatedAt: created}, lifetime, created.Add(lifetime), true},
+		{"no lifetime", &domain.DKIMKey{CreatedAt: created, ActivatedAt: &activated}, 0, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := keyExpiry(tt.key, tt.lifetime)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("keyExpiry() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestActiveSuccessor(t *testing.T) {
+	oldID := "old"
+	otherID := "other"
+	keys := []*domain.DKIMKey{
+		{ID: "pending", ReplacesKeyID: &oldID},
+		{ID: "unrelated", IsActive: true, ReplacesKeyID: &otherID},
+		{ID: "old", IsActive: true},
+	}
+
+	if got := activeSuccessor(keys, oldID); got != nil {
+		t.Fatalf("activeSuccessor() = %s, want none while the successor is pending", got.ID)
+	}
+
+	keys[0].IsActive = true
+	if got := activeSuccessor(keys, oldID); got == nil || got.ID != "pending" {
+		t.Fatalf("activeSuccessor() = %v, want pending", got)
+	}
+}
@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"context"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+
+	"domain-manager/config"
+	"domain-manager/domain"
+	"domain-manager/repository"
+	"domain-manager/service"
+)
+
+// DMARCReporter generates daily DMARC aggregate reports for all verified domains
+type DMARCReporter struct {
+	domainRepo    *repository.DomainRepository
+	reportRepo    *repository.DMARCReportRepository
+	reportService *service.DMARCReportService
+	config        *config.DMARCConfig
+	cron          *cron.Cron
+	logger        *zap.Logger
+}
+
+// NewDMARCReporter creates a new DMARC aggregate reporter
+func NewDMARCReporter(
+	domainRepo *repository.DomainRepository,
+	reportRepo *repository.DMARCReportRepository,
+	reportService *service.DMARCReportService,
+	cfg *config.DMARCConfig,
+	logger *zap.Logger,
+) *DMARCReporter {
+	return &DMARCReporter{
+		domainRepo:    domainRepo,
+		reportRepo:    reportRepo,
+		reportService: reportService,
+		config:        cfg,
+		cron:          cron.New(cron.WithSeconds(), cron.WithLocation(time.UTC)),
+		logger:        logger,
+	}
+}
+
+// Start starts the DMARC report cron job
+func (m *DMARCReporter) Start() error {
+	_, err := m.cron.AddFunc(m.config.ReportSchedule, func() {
+		m.generateAllReports()
+	})
+	if err != nil {
+		return err
+	}
+
+	m.cron.Start()
+	m.logger.Info("DMARC reporter started", zap.String("schedule", m.config.ReportSchedule))
+
+	return nil
+}
+
+// Stop stops the DMARC reporter
+func (m *DMARCReporter) Stop() {
+	ctx := m.cron.Stop()
+	<-ctx.Done()
+	m.logger.Info("DMARC reporter stopped")
+}
+
+// generateAllReports emits one report per verified domain for the previous UTC day
+func (m *DMARCReporter) generateAllReports() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := end.Add(-24 * time.Hour)
+
+	domains, err := m.domainRepo.ListAllVerified(ctx)
+	if err != nil {
+		m.logger.Error("Failed to list verified domains", zap.Error(err))
+		return
+	}
+
+	generated := 0
+	for _, d := range domains {
+		if m.generateReport(ctx, d, start, end) {
+			generated++
+		}
+	}
+
+	m.logger.Info("Completed DMARC aggregate reporting",
+		zap.Int("domains_checked", len(domains)),
+		zap.Int("reports_generated", generated),
+	)
+}
+
+// generateReport builds and stores the report for a single domain
+func (m *DMARCReporter) generateReport(ctx context.Context, d *domain.Domain, start, end time.Time) bool {
+	report, err := m.reportService.GenerateAggregateReport(ctx, d.ID, start, end)
+	if err != nil {
+		m.logger.Warn("Failed to generate DMARC report",
+			zap.String("domain", d.DomainName),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	// Aggregate reports without records are not sent
+	if report.RecordCount == 0 {
+		return false
+	}
+
+	if err := m.reportRepo.SaveReport(ctx, report); err != nil {
+		m.logger.Error("Failed to save DMARC report",
+			zap.String("domain", d.DomainName),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	return true
+}
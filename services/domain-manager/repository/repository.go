@@ -278,20 +278,44 @@ func NewDKIMKeyRepository(db *pgxpool.Pool, logger *zap.Logger) *DKIMKeyReposito
 	}
 }
 
+// dkimKeyColumns are the columns scanned by scanDKIMKey
+const dkimKeyColumns = `
+			id, domain_id, selector, algorithm, key_size,
+			public_key, private_key, is_active, created_at, activated_at, expires_at, rotated_at,
+			replaces_key_id, retire_after`
+
+// scanDKIMKey scans a row selected with dkimKeyColumns
+func scanDKIMKey(row pgx.Row) (*domain.DKIMKey, error) {
+	var key domain.DKIMKey
+	err := row.Scan(
+		&key.ID, &key.DomainID, &key.Selector, &key.Algorithm, &key.KeySize,
+		&key.PublicKey, &key.PrivateKeyEncrypted, &key.IsActive, &key.CreatedAt,
+		&key.ActivatedAt, &key.ExpiresAt, &key.RotatedAt,
+		&key.ReplacesKeyID, &key.RetireAfter,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.KeyType = domain.DKIMKeyTypeForAlgorithm(key.Algorithm)
+	return &key, nil
+}
+
 // Create creates a new DKIM key
 func (r *DKIMKeyRepository) Create(ctx context.Context, key *domain.DKIMKey) error {
 	query := `
 		INSERT INTO dkim_keys (
 			id, domain_id, selector, algorithm, key_size,
-			public_key, private_key, is_active, created_at
+			public_key, private_key, is_active, created_at, expires_at, replaces_key_id
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
 		)
 	`
 
 	_, err := r.db.Exec(ctx, query,
 		key.ID, key.DomainID, key.Selector, key.Algorithm, key.KeySize,
 		key.PublicKey, key.PrivateKeyEncrypted, key.IsActive, key.CreatedAt,
+		key.ExpiresAt, key.ReplacesKeyID,
 	)
 	if err != nil {
 		return fmt.Errorf("create dkim key: %w", err)
@@ -302,22 +326,12 @@ func (r *DKIMKeyRepository) Create(ctx context.Context, key *domain.DKIMKey) err
 
 // GetByID returns a DKIM key by ID
 func (r *DKIMKeyRepository) GetByID(ctx context.Context, id string) (*domain.DKIMKey, error) {
-	query := `
-		SELECT 
-			id, domain_id, selector, algorithm, key_size,
-			public_key, private_key, is_active, created_at, activated_at, expires_at, rotated_at
+	query := `SELECT ` + dkimKeyColumns + `
 		FROM dkim_keys
 		WHERE id = $1
 	`
 
-	var key domain.DKIMKey
-	var activatedAt, expiresAt, rotatedAt *time.Time
-
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&key.ID, &key.DomainID, &key.Selector, &key.Algorithm, &key.KeySize,
-		&key.PublicKey, &key.PrivateKeyEncrypted, &key.IsActive, &key.CreatedAt,
-		&activatedAt, &expiresAt, &rotatedAt,
-	)
+	key, err := scanDKIMKey(r.db.QueryRow(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -325,48 +339,92 @@ func (r *DKIMKeyRepository) GetByID(ctx context.Context, id string) (*domain.DKI
 		return nil, fmt.Errorf("get dkim key by id: %w", err)
 	}
 
-	key.ActivatedAt = activatedAt
-	key.ExpiresAt = expiresAt
-	key.RotatedAt = rotatedAt
-
-	return &key, nil
+	return key, nil
 }
 
 // ListByDomain returns all DKIM keys for a domain
 func (r *DKIMKeyRepository) ListByDomain(ctx context.Context, domainID string) ([]*domain.DKIMKey, error) {
-	query := `
-		SELECT 
-			id, domain_id, selector, algorithm, key_size,
-			public_key, private_key, is_active, created_at, activated_at, expires_at, rotated_at
+	query := `SELECT ` + dkimKeyColumns + `
 		FROM dkim_keys
 		WHERE domain_id = $1
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(ctx, query, domainID)
+	return r.list(ctx, "list dkim keys by domain", query, domainID)
+}
+
+// GetRotationCandidates returns the active keys that expire before the given
+// time and have no rotation in progress. Keys without an expiry are treated
+// as expiring lifetime after activation; with a zero lifetime they never do.
+func (r *DKIMKeyRepository) GetRotationCandidates(ctx context.Context, before time.Time, lifetime time.Duration) ([]*domain.DKIMKey, error) {
+	query := `SELECT ` + dkimKeyColumns + `
+		FROM dkim_keys k
+		WHERE is_active = true
+		  AND rotated_at IS NULL
+		  AND retire_after IS NULL
+		  AND COALESCE(expires_at,
+		      CASE WHEN $2::float8 > 0 THEN COALESCE(activated_at, created_at) + make_interval(secs => $2::float8) END) < $1
+		  AND NOT EXISTS (
+		      SELECT 1 FROM dkim_keys n
+		      WHERE n.replaces_key_id = k.id AND n.rotated_at IS NULL
+		  )
+		ORDER BY expires_at ASC NULLS LAST
+	`
+
+	return r.list(ctx, "get dkim rotation candidates", query, before, lifetime.Seconds())
+}
+
+// ListPendingRotations returns keys created by rotation that are not active yet
+func (r *DKIMKeyRepository) ListPendingRotations(ctx context.Context) ([]*domain.DKIMKey, error) {
+	query := `SELECT ` + dkimKeyColumns + `
+		FROM dkim_keys
+		WHERE replaces_key_id IS NOT NULL
+		  AND is_active = false
+		  AND rotated_at IS NULL
+		ORDER BY created_at ASC
+	`
+
+	return r.list(ctx, "list pending dkim rotations", query)
+}
+
+// ListRetiring returns active keys whose rotation grace period ended before the given time
+func (r *DKIMKeyRepository) ListRetiring(ctx context.Context, before time.Time) ([]*domain.DKIMKey, error) {
+	query := `SELECT ` + dkimKeyColumns + `
+		FROM dkim_keys
+		WHERE is_active = true
+		  AND retire_after IS NOT NULL
+		  AND retire_after <= $1
+		ORDER BY retire_after ASC
+	`
+
+	return r.list(ctx, "list retiring dkim keys", query, before)
+}
+
+// SetRetireAfter schedules when a replaced key stops signing
+func (r *DKIMKeyRepository) SetRetireAfter(ctx context.Context, id string, retireAfter time.Time) error {
+	query := `UPDATE dkim_keys SET retire_after = $2 WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id, retireAfter)
 	if err != nil {
-		return nil, fmt.Errorf("list dkim keys by domain: %w", err)
+		return fmt.Errorf("set dkim key retire_after: %w", err)
+	}
+	return nil
+}
+
+// list runs a query selecting dkimKeyColumns
+func (r *DKIMKeyRepository) list(ctx context.Context, op, query string, args ...interface{}) ([]*domain.DKIMKey, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
 	var keys []*domain.DKIMKey
 	for rows.Next() {
-		var key domain.DKIMKey
-		var activatedAt, expiresAt, rotatedAt *time.Time
-
-		err := rows.Scan(
-			&key.ID, &key.DomainID, &key.Selector, &key.Algorithm, &key.KeySize,
-			&key.PublicKey, &key.PrivateKeyEncrypted, &key.IsActive, &key.CreatedAt,
-			&activatedAt, &expiresAt, &rotatedAt,
-		)
+		key, err := scanDKIMKey(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scan dkim key: %w", err)
 		}
-
-		key.ActivatedAt = activatedAt
-		key.ExpiresAt = expiresAt
-		key.RotatedAt = rotatedAt
-		keys = append(keys, &key)
+		keys = append(keys, key)
 	}
 
 	return keys, rows.Err()
@@ -544,9 +602,10 @@ func (r *PoliciesRepository) Upsert(ctx context.Context, p *domain.Policies) err
 			id, domain_id, max_message_size_bytes, max_recipients_per_message,
 			max_messages_per_day_per_user, require_tls_outbound,
 			allowed_recipient_domains, blocked_recipient_domains,
-			auto_bcc_address, default_signature_enforced, attachment_policy, updated_at
+			auto_bcc_address, default_signature_enforced, attachment_policy, updated_at,
+			outbound_footer_text, outbound_footer_html, dkim_body_length
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
 		)
 		ON CONFLICT (domain_id) DO UPDATE SET
 			max_message_size_bytes = EXCLUDED.max_message_size_bytes,
@@ -558,7 +617,10 @@ func (r *PoliciesRepository) Upsert(ctx context.Context, p *domain.Policies) err
 			auto_bcc_address = EXCLUDED.auto_bcc_address,
 			default_signature_enforced = EXCLUDED.default_signature_enforced,
 			attachment_policy = EXCLUDED.attachment_policy,
-			updated_at = EXCLUDED.updated_at
+			updated_at = EXCLUDED.updated_at,
+			outbound_footer_text = EXCLUDED.outbound_footer_text,
+			outbound_footer_html = EXCLUDED.outbound_footer_html,
+			dkim_body_length = EXCLUDED.dkim_body_length
 	`
 
 	_, err := r.db.Exec(ctx, query,
@@ -566,6 +628,7 @@ func (r *PoliciesRepository) Upsert(ctx context.Context, p *domain.Policies) err
 		p.MaxMessagesPerDayPerUser, p.RequireTLSOutbound,
 		allowedJSON, blockedJSON,
 		p.AutoBCCAddress, p.DefaultSignatureEnforced, attachmentJSON, p.UpdatedAt,
+		p.OutboundFooterText, p.OutboundFooterHTML, p.DKIMBodyLength,
 	)
 	if err != nil {
 		return fmt.Errorf("upsert policies: %w", err)
@@ -581,7 +644,8 @@ func (r *PoliciesRepository) GetByDomainID(ctx context.Context, domainID string)
 			id, domain_id, max_message_size_bytes, max_recipients_per_message,
 			max_messages_per_day_per_user, require_tls_outbound,
 			allowed_recipient_domains, blocked_recipient_domains,
-			auto_bcc_address, default_signature_enforced, attachment_policy, updated_at
+			auto_bcc_address, default_signature_enforced, attachment_policy, updated_at,
+			outbound_footer_text, outbound_footer_html, dkim_body_length
 		FROM domain_policies
 		WHERE domain_id = $1
 	`
@@ -594,6 +658,7 @@ func (r *PoliciesRepository) GetByDomainID(ctx context.Context, domainID string)
 		&p.MaxMessagesPerDayPerUser, &p.RequireTLSOutbound,
 		&allowedJSON, &blockedJSON,
 		&p.AutoBCCAddress, &p.DefaultSignatureEnforced, &attachmentJSON, &p.UpdatedAt,
+		&p.OutboundFooterText, &p.OutboundFooterHTML, &p.DKIMBodyLength,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -671,6 +736,130 @@ func (r *CatchAllRepository) GetByDomainID(ctx context.Context, domainID string)
 	return &c, nil
 }
 
+// catchAllRuleColumns are the columns scanned by scanCatchAllRule
+const catchAllRuleColumns = `id, domain_id, position, match_type, pattern, action, destination, enabled, created_at, updated_at`
+
+func scanCatchAllRule(row pgx.Row) (*domain.CatchAllRule, error) {
+	var rule domain.CatchAllRule
+	err := row.Scan(
+		&rule.ID, &rule.DomainID, &rule.Position, &rule.MatchType, &rule.Pattern,
+		&rule.Action, &rule.Destination, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListRules returns a domain's catch-all rules in evaluation order
+func (r *CatchAllRepository) ListRules(ctx context.Context, domainID string) ([]*domain.CatchAllRule, error) {
+	query := `SELECT ` + catchAllRuleColumns + `
+		FROM domain_catch_all_rules
+		WHERE domain_id = $1
+		ORDER BY position, created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, domainID)
+	if err != nil {
+		return nil, fmt.Errorf("list catch-all rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*domain.CatchAllRule
+	for rows.Next() {
+		rule, err := scanCatchAllRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan catch-all rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetRule returns one of a domain's catch-all rules, or nil if it doesn't exist
+func (r *CatchAllRepository) GetRule(ctx context.Context, domainID, id string) (*domain.CatchAllRule, error) {
+	query := `SELECT ` + catchAllRuleColumns + `
+		FROM domain_catch_all_rules
+		WHERE domain_id = $1 AND id = $2
+	`
+
+	rule, err := scanCatchAllRule(r.db.QueryRow(ctx, query, domainID, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get catch-all rule: %w", err)
+	}
+	return rule, nil
+}
+
+// CreateRule inserts a catch-all rule. A rule without a position is placed
+// after the domain's existing rules.
+func (r *CatchAllRepository) CreateRule(ctx context.Context, rule *domain.CatchAllRule) error {
+	query := `
+		INSERT INTO domain_catch_all_rules (
+			id, domain_id, position, match_type, pattern, action, destination, enabled, created_at, updated_at
+		) VALUES (
+			$1, $2,
+			COALESCE(NULLIF($3, 0), (SELECT COALESCE(MAX(position), 0) + 1 FROM domain_catch_all_rules WHERE domain_id = $2)),
+			$4, $5, $6, $7, $8, $9, $10
+		)
+		RETURNING position
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		rule.ID, rule.DomainID, rule.Position, rule.MatchType, rule.Pattern,
+		rule.Action, rule.Destination, rule.Enabled, rule.CreatedAt, rule.UpdatedAt,
+	).Scan(&rule.Position)
+	if err != nil {
+		return fmt.Errorf("create catch-all rule: %w", err)
+	}
+	return nil
+}
+
+// UpdateRule updates a catch-all rule
+func (r *CatchAllRepository) UpdateRule(ctx context.Context, rule *domain.CatchAllRule) error {
+	query := `
+		UPDATE domain_catch_all_rules SET
+			position = $3, match_type = $4, pattern = $5, action = $6,
+			destination = $7, enabled = $8, updated_at = $9
+		WHERE domain_id = $1 AND id = $2
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		rule.DomainID, rule.ID, rule.Position, rule.MatchType, rule.Pattern,
+		rule.Action, rule.Destination, rule.Enabled, rule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("update catch-all rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteRule deletes a catch-all rule
+func (r *CatchAllRepository) DeleteRule(ctx context.Context, domainID, id string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM domain_catch_all_rules WHERE domain_id = $1 AND id = $2`, domainID, id)
+	if err != nil {
+		return fmt.Errorf("delete catch-all rule: %w", err)
+	}
+	return nil
+}
+
+// AddressExists reports whether a mailbox or active alias receives mail for
+// an address, in which case catch-all routing doesn't apply to it
+func (r *CatchAllRepository) AddressExists(ctx context.Context, address string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM mailboxes WHERE LOWER(email) = LOWER($1))
+		    OR EXISTS (SELECT 1 FROM aliases WHERE LOWER(alias_address) = LOWER($1) AND is_active)
+	`
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, query, address).Scan(&exists); err != nil {
+		return false, fmt.Errorf("check address exists: %w", err)
+	}
+	return exists, nil
+}
+
 // StatsRepository handles statistics queries
 type StatsRepository struct {
 	db     *pgxpool.Pool
@@ -743,3 +932,112 @@ func (r *StatsRepository) GetDomainStats(ctx context.Context, domainID string) (
 
 	return stats, nil
 }
+
+// DMARCReportRepository handles DMARC authentication results and aggregate reports
+type DMARCReportRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewDMARCReportRepository creates a new DMARC report repository
+func NewDMARCReportRepository(db *pgxpool.Pool, logger *zap.Logger) *DMARCReportRepository {
+	return &DMARCReportRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// RecordAuthResult stores an authentication result for later aggregate reporting
+func (r *DMARCReportRepository) RecordAuthResult(ctx context.Context, res *domain.DMARCAuthResult) error {
+	query := `
+		INSERT INTO dmarc_auth_results (
+			domain_id, source_ip, header_from, envelope_from, spf_domain, spf_result,
+			dkim_domain, dkim_selector, dkim_result, disposition, message_count, recorded_at
+		) VALUES (
+			$1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, ''), $9, NULLIF($10, ''), $11, $12
+		)
+		RETURNING id
+	`
+
+	count := res.Count
+	if count <= 0 {
+		count = 1
+	}
+	if res.RecordedAt.IsZero() {
+		res.RecordedAt = time.Now()
+	}
+
+	err := r.db.QueryRow(ctx, query,
+		res.DomainID, res.SourceIP, res.HeaderFrom, res.EnvelopeFrom, res.SPFDomain, res.SPFResult,
+		res.DKIMDomain, res.DKIMSelector, res.DKIMResult, res.Disposition, count, res.RecordedAt,
+	).Scan(&res.ID)
+	if err != nil {
+		return fmt.Errorf("record dmarc auth result: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuthResults returns authentication results recorded for a domain in [start, end)
+func (r *DMARCReportRepository) ListAuthResults(ctx context.Context, domainID string, start, end time.Time) ([]*domain.DMARCAuthResult, error) {
+	query := `
+		SELECT 
+			id, domain_id, host(source_ip), header_from, COALESCE(envelope_from, ''),
+			COALESCE(spf_domain, ''), spf_result, COALESCE(dkim_domain, ''), COALESCE(dkim_selector, ''),
+			dkim_result, COALESCE(disposition, ''), message_count, recorded_at
+		FROM dmarc_auth_results
+		WHERE domain_id = $1 AND recorded_at >= $2 AND recorded_at < $3
+		ORDER BY recorded_at
+	`
+
+	rows, err := r.db.Query(ctx, query, domainID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("list dmarc auth results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*domain.DMARCAuthResult
+	for rows.Next() {
+		var res domain.DMARCAuthResult
+		err := rows.Scan(
+			&res.ID, &res.DomainID, &res.SourceIP, &res.HeaderFrom, &res.EnvelopeFrom,
+			&res.SPFDomain, &res.SPFResult, &res.DKIMDomain, &res.DKIMSelector,
+			&res.DKIMResult, &res.Disposition, &res.Count, &res.RecordedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan dmarc auth result: %w", err)
+		}
+		results = append(results, &res)
+	}
+
+	return results, rows.Err()
+}
+
+// SaveReport stores a generated aggregate report, replacing any earlier report for the same window
+func (r *DMARCReportRepository) SaveReport(ctx context.Context, report *domain.DMARCAggregateReport) error {
+	query := `
+		INSERT INTO dmarc_aggregate_reports (
+			domain_id, report_id, date_range_begin, date_range_end,
+			record_count, message_count, report_data, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8
+		)
+		ON CONFLICT (domain_id, date_range_begin, date_range_end) DO UPDATE SET
+			report_id = EXCLUDED.report_id,
+			record_count = EXCLUDED.record_count,
+			message_count = EXCLUDED.message_count,
+			report_data = EXCLUDED.report_data,
+			created_at = EXCLUDED.created_at
+		RETURNING id
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		report.DomainID, report.ReportID, report.DateRangeBegin, report.DateRangeEnd,
+		report.RecordCount, report.MessageCount, report.Data, report.CreatedAt,
+	).Scan(&report.ID)
+	if err != nil {
+		return fmt.Errorf("save dmarc report: %w", err)
+	}
+
+	return nil
+}
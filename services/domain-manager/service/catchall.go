@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"domain-manager/domain"
+	"domain-manager/repository"
+)
+
+// MaxCatchAllHops is how many catch-all redirects mail may take through the
+// domains we host before it's treated as a loop. The SMTP server enforces
+// the same limit at delivery.
+const MaxCatchAllHops = 5
+
+// maxCatchAllPatternLength bounds catch-all rule patterns
+const maxCatchAllPatternLength = 255
+
+var (
+	// ErrInvalidCatchAllRule is returned for rules that fail validation
+	ErrInvalidCatchAllRule = errors.New("invalid catch-all rule")
+
+	// ErrAddressNotOnDomain is returned when routing is tested for an
+	// address of another domain
+	ErrAddressNotOnDomain = errors.New("address is not on the domain")
+
+	// ErrCatchAllLoop is returned when catch-all routing would forward mail
+	// back to an address it already passed through
+	ErrCatchAllLoop = errors.New("catch-all routing loop")
+)
+
+// CatchAllService validates and evaluates catch-all routing rules
+type CatchAllService struct {
+	domainRepo   *repository.DomainRepository
+	catchAllRepo *repository.CatchAllRepository
+	logger       *zap.Logger
+}
+
+// NewCatchAllService creates a new catch-all service
+func NewCatchAllService(domainRepo *repository.DomainRepository, catchAllRepo *repository.CatchAllRepository, logger *zap.Logger) *CatchAllService {
+	return &CatchAllService{
+		domainRepo:   domainRepo,
+		catchAllRepo: catchAllRepo,
+		logger:       logger,
+	}
+}
+
+// CompileCatchAllPattern compiles a rule's pattern into a case-insensitive
+// regular expression matching the whole local part
+func CompileCatchAllPattern(matchType domain.CatchAllMatchType, pattern string) (*regexp.Regexp, error) {
+	switch matchType {
+	case domain.CatchAllMatchExact:
+		return regexp.Compile("(?i)^" + regexp.QuoteMeta(pattern) + "$")
+	case domain.CatchAllMatchWildcard:
+		quoted := regexp.QuoteMeta(pattern)
+		quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+		quoted = strings.ReplaceAll(quoted, `\?`, ".")
+		return regexp.Compile("(?i)^" + quoted + "$")
+	case domain.CatchAllMatchRegex:
+		return regexp.Compile("(?i)^(?:" + pattern + ")$")
+	}
+	return nil, fmt.Errorf("unknown match type %q", matchType)
+}
+
+// ValidateRule checks a rule before it is saved: the pattern must compile
+// and the destination must be an address. Mail is only delivered to
+// addresses on the organization's own domains; anything else is forwarded.
+func (s *CatchAllService) ValidateRule(ctx context.Context, d *domain.Domain, rule *domain.CatchAllRule) error {
+	if rule.Pattern == "" || len(rule.Pattern) > maxCatchAllPatternLength {
+		return fmt.Errorf("%w: pattern must be 1 to %d characters", ErrInvalidCatchAllRule, maxCatchAllPatternLength)
+	}
+	if _, err := CompileCatchAllPattern(rule.MatchType, rule.Pattern); err != nil {
+		return fmt.Errorf("%w: pattern: %v", ErrInvalidCatchAllRule, err)
+	}
+
+	addr, err := mail.ParseAddress(rule.Destination)
+	if err != nil || addr.Address != rule.Destination {
+		return fmt.Errorf("%w: destination must be an email address", ErrInvalidCatchAllRule)
+	}
+	_, destDomain, _ := splitAddress(rule.Destination)
+
+	switch rule.Action {
+	case domain.CatchAllDeliver:
+		target, err := s.domainRepo.GetByName(ctx, destDomain)
+		if err != nil {
+			return err
+		}
+		if target == nil || target.OrganizationID != d.OrganizationID {
+			return fmt.Errorf("%w: deliver destinations must be on one of the organization's domains; use forward for other addresses", ErrInvalidCatchAllRule)
+		}
+	case domain.CatchAllForward:
+	default:
+		return fmt.Errorf("%w: action must be deliver or forward", ErrInvalidCatchAllRule)
+	}
+	return nil
+}
+
+// Route reports how mail to address, an address of d, would be routed: to
+// its mailbox or alias, by the first matching catch-all rule, or by the
+// domain's catch-all config
+func (s *CatchAllService) Route(ctx context.Context, d *domain.Domain, address string) (*domain.CatchAllRouteResult, error) {
+	localPart, domainName, ok := splitAddress(address)
+	if !ok || !strings.EqualFold(domainName, d.DomainName) {
+		return nil, fmt.Errorf("%w %s", ErrAddressNotOnDomain, d.DomainName)
+	}
+	result := &domain.CatchAllRouteResult{Address: address, LocalPart: localPart}
+
+	exists, err := s.catchAllRepo.AddressExists(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		result.Existing = true
+		return result, nil
+	}
+
+	rules, fallback, err := s.loadRouting(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.Rule, result.Action, result.Destination = resolveCatchAll(rules, fallback, localPart)
+	result.Fallback = result.Rule == nil
+	return result, nil
+}
+
+// CheckLoops follows every destination of a domain's proposed catch-all
+// routing through the domains we host and returns ErrCatchAllLoop if mail
+// would come back to an address it already passed, or take more than
+// MaxCatchAllHops redirects. Routing stops at existing mailboxes and aliases
+// and at domains we don't host.
+func (s *CatchAllService) CheckLoops(ctx context.Context, d *domain.Domain, rules []*domain.CatchAllRule, fallback *domain.CatchAllConfig) error {
+	routing := func(ctx context.Context, domainID string) ([]*domain.CatchAllRule, *domain.CatchAllConfig, error) {
+		if domainID == d.ID {
+			return rules, fallback, nil
+		}
+		return s.loadRouting(ctx, domainID)
+	}
+
+	var destinations []string
+	for _, rule := range rules {
+		if rule.Enabled {
+			destinations = append(destinations, rule.Destination)
+		}
+	}
+	if _, _, dest := resolveCatchAll(nil, fallback, ""); dest != "" {
+		destinations = append(destinations, dest)
+	}
+
+	for _, dest := range destinations {
+		if err := s.followRoute(ctx, dest, routing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// followRoute follows catch-all redirects starting at address
+func (s *CatchAllService) followRoute(ctx context.Context, address string, routing func(context.Context, string) ([]*domain.CatchAllRule, *domain.CatchAllConfig, error)) error {
+	seen := make(map[string]bool)
+	for hops := 0; ; hops++ {
+		address = strings.ToLower(address)
+		if seen[address] {
+			return fmt.Errorf("%w: mail would be forwarded back to %s", ErrCatchAllLoop, address)
+		}
+		if hops >= MaxCatchAllHops {
+			return fmt.Errorf("%w: mail to %s would be redirected more than %d times", ErrCatchAllLoop, address, MaxCatchAllHops)
+		}
+		seen[address] = true
+
+		localPart, domainName, ok := splitAddress(address)
+		if !ok {
+			return nil
+		}
+		target, err := s.domainRepo.GetByName(ctx, domainName)
+		if err != nil {
+			return err
+		}
+		if target == nil {
+			return nil
+		}
+		exists, err := s.catchAllRepo.AddressExists(ctx, address)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+
+		rules, fallback, err := routing(ctx, target.ID)
+		if err != nil {
+			return err
+		}
+		_, _, next := resolveCatchAll(rules, fallback, localPart)
+		if next == "" {
+			return nil
+		}
+		address = next
+	}
+}
+
+// loadRouting loads a domain's catch-all rules and config
+func (s *CatchAllService) loadRouting(ctx context.Context, domainID string) ([]*domain.CatchAllRule, *domain.CatchAllConfig, error) {
+	rules, err := s.catchAllRepo.ListRules(ctx, domainID)
+	if err != nil {
+		return nil, nil, err
+	}
+	fallback, err := s.catchAllRepo.GetByDomainID(ctx, domainID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rules, fallback, nil
+}
+
+// resolveCatchAll returns the first enabled rule matching localPart with its
+// action and destination, or the catch-all config's when no rule matches.
+// The destination is empty when the mail is rejected.
+func resolveCatchAll(rules []*domain.CatchAllRule, fallback *domain.CatchAllConfig, localPart string) (*domain.CatchAllRule, domain.CatchAllAction, string) {
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		re, err := CompileCatchAllPattern(rule.MatchType, rule.Pattern)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(localPart) {
+			return rule, rule.Action, rule.Destination
+		}
+	}
+
+	if fallback == nil || !fallback.Enabled {
+		return nil, domain.CatchAllReject, ""
+	}
+	switch {
+	case fallback.Action == domain.CatchAllDeliver && fallback.DeliverTo != nil && *fallback.DeliverTo != "":
+		return nil, domain.CatchAllDeliver, *fallback.DeliverTo
+	case fallback.Action == domain.CatchAllForward && fallback.ForwardTo != nil && *fallback.ForwardTo != "":
+		return nil, domain.CatchAllForward, *fallback.ForwardTo
+	}
+	return nil, domain.CatchAllReject, ""
+}
+
+// splitAddress splits an email address into its local part and domain
+func splitAddress(address string) (localPart, domainName string, ok bool) {
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 {
+		return "", "", false
+	}
+	return address[:at], strings.ToLower(address[at+1:]), true
+}
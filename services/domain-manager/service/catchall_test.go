@@ -0,0 +1,86 @@
+package service
+
+import (
+	"testing"
+
+	"domain-manager/domain"
+)
+
+func TestCompileCatchAllPattern(t *testing.T) {
+	tests := []struct {
+		name      string
+		matchType domain.CatchAllMatchType
+		pattern   string
+		localPart string
+		want      bool
+	}{
+		{"exact", domain.CatchAllMatchExact, "sales", "Sales", true},
+		{"exact is not a prefix", domain.CatchAllMatchExact, "sales", "sales-eu", false},
+		{"exact escapes metacharacters", domain.CatchAllMatchExact, "a.b", "axb", false},
+		{"wildcard star", domain.CatchAllMatchWildcard, "sales-*", "sales-eu", true},
+		{"wildcard question mark", domain.CatchAllMatchWildcard, "team?", "team1", true},
+		{"wildcard question mark is one character", domain.CatchAllMatchWildcard, "team?", "team12", false},
+		{"wildcard dot is literal", domain.CatchAllMatchWildcard, "a.*", "axb", false},
+		{"regex", domain.CatchAllMatchRegex, `invoice-\d+`, "invoice-42", true},
+		{"regex is anchored", domain.CatchAllMatchRegex, `invoice|bill`, "billing", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := CompileCatchAllPattern(tt.matchType, tt.pattern)
+			if err != nil {
+				t.Fatalf("CompileCatchAllPattern() error = %v", err)
+			}
+			if got := re.MatchString(tt.localPart); got != tt.want {
+				t.Errorf("MatchString(%q) = %v, want %v", tt.localPart, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := CompileCatchAllPattern(domain.CatchAllMatchRegex, "(unclosed"); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+	if _, err := CompileCatchAllPattern("glob", "x"); err == nil {
+		t.Error("expected an error for an unknown match type")
+	}
+}
+
+func TestResolveCatchAll(t *testing.T) {
+	deliverTo := "inbox@example.com"
+	rules := []*domain.CatchAllRule{
+		{ID: "disabled", MatchType: domain.CatchAllMatchWildcard, Pattern: "*", Action: domain.CatchAllForward, Destination: "nobody@other.test", Enabled: false},
+		{ID: "sales", MatchType: domain.CatchAllMatchWildcard, Pattern: "sales*", Action: domain.CatchAllDeliver, Destination: "sales@example.com", Enabled: true},
+		{ID: "invoices", MatchType: domain.CatchAllMatchRegex, Pattern: `inv(oice)?-\d+`, Action: domain.CatchAllForward, Destination: "ap@accounting.test", Enabled: true},
+	}
+	fallback := &domain.CatchAllConfig{Enabled: true, Action: domain.CatchAllDeliver, DeliverTo: &deliverTo}
+
+	tests := []struct {
+		name      string
+		fallback  *domain.CatchAllConfig
+		localPart string
+		wantRule  string
+		wantDest  string
+	}{
+		{"first matching rule", fallback, "sales-eu", "sales", "sales@example.com"},
+		{"regex rule", fallback, "inv-7", "invoices", "ap@accounting.test"},
+		{"fallback", fallback, "random", "", deliverTo},
+		{"disabled fallback rejects", &domain.CatchAllConfig{Enabled: false, DeliverTo: &deliverTo}, "random", "", ""},
+		{"no fallback rejects", nil, "random", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, _, dest := resolveCatchAll(rules, tt.fallback, tt.localPart)
+			gotRule := ""
+			if rule != nil {
+				gotRule = rule.ID
+			}
+			if gotRule != tt.wantRule {
+				t.Errorf("rule = %q, want %q", gotRule, tt.wantRule)
+			}
+			if dest != tt.wantDest {
+				t.Errorf("destination = %q, want %q", dest, tt.wantDest)
+			}
+		})
+	}
+}
@@ -3,13 +3,16 @@ package service
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -35,35 +38,36 @@ func NewDKIMService(cfg *config.DKIMConfig, dns *config.DNSConfig, logger *zap.L
 	}
 }
 
-// GenerateKeyPair generates a new DKIM RSA key pair
-func (s *DKIMService) GenerateKeyPair(domainID string, selector string) (*domain.DKIMKey, error) {
-	// Use configured key size
-	keySize := s.config.DefaultKeySize
-	if keySize == 0 {
-		keySize = 2048
-	}
+// ErrUnsupportedDKIMAlgorithm is returned when a key is requested for an unknown algorithm
+var ErrUnsupportedDKIMAlgorithm = errors.New("unsupported DKIM algorithm")
 
-	// Generate RSA key pair
-	privateKey, err := rsa.GenerateKey(rand.Reader, keySize)
+// GenerateKeyPair generates a new DKIM key pair. The algorithm may be
+// rsa-sha256 or ed25519-sha256 (RFC 8463); an empty algorithm uses the
+// configured default.
+func (s *DKIMService) GenerateKeyPair(domainID string, selector string, algorithm string) (*domain.DKIMKey, error) {
+	if algorithm == "" {
+		algorithm = s.config.DefaultAlgorithm
+	}
+	algorithm, err := normalizeDKIMAlgorithm(algorithm)
 	if err != nil {
-		return nil, fmt.Errorf("generate rsa key: %w", err)
+		return nil, err
 	}
 
-	// Encode private key to PEM
-	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	})
-
-	// Encode public key to PEM
-	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	var (
+		keySize       int
+		privateKeyPEM []byte
+		publicKeyPEM  []byte
+	)
+
+	switch algorithm {
+	case domain.DKIMAlgorithmEd25519SHA256:
+		keySize, privateKeyPEM, publicKeyPEM, err = generateEd25519KeyPair()
+	default:
+		keySize, privateKeyPEM, publicKeyPEM, err = s.generateRSAKeyPair()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("marshal public key: %w", err)
+		return nil, err
 	}
-	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: publicKeyBytes,
-	})
 
 	// Encrypt private key
 	encryptedPrivateKey, err := s.encryptPrivateKey(privateKeyPEM)
@@ -76,27 +80,97 @@ func (s *DKIMService) GenerateKeyPair(domainID string, selector string) (*domain
 		selector = s.dns.DefaultDKIMSelector
 	}
 
-	algorithm := s.config.DefaultAlgorithm
-	if algorithm == "" {
-		algorithm = "rsa-sha256"
-	}
-
 	now := time.Now()
 	key := &domain.DKIMKey{
 		ID:                  uuid.New().String(),
 		DomainID:            domainID,
 		Selector:            selector,
 		Algorithm:           algorithm,
+		KeyType:             domain.DKIMKeyTypeForAlgorithm(algorithm),
 		KeySize:             keySize,
 		PublicKey:           string(publicKeyPEM),
 		PrivateKeyEncrypted: []byte(encryptedPrivateKey),
 		IsActive:            false,
 		CreatedAt:           now,
 	}
+	if s.config.KeyLifetime > 0 {
+		expiresAt := now.Add(s.config.KeyLifetime)
+		key.ExpiresAt = &expiresAt
+	}
 
 	return key, nil
 }
 
+// generateRSAKeyPair generates a PEM-encoded RSA key pair of the configured size
+func (s *DKIMService) generateRSAKeyPair() (int, []byte, []byte, error) {
+	// Use configured key size
+	keySize := s.config.DefaultKeySize
+	if keySize == 0 {
+		keySize = 2048
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("generate rsa key: %w", err)
+	}
+
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("marshal public key: %w", err)
+	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyBytes,
+	})
+
+	return keySize, privateKeyPEM, publicKeyPEM, nil
+}
+
+// generateEd25519KeyPair generates a PEM-encoded Ed25519 key pair
+func generateEd25519KeyPair() (int, []byte, []byte, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("generate ed25519 key: %w", err)
+	}
+
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("marshal private key: %w", err)
+	}
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: privateKeyBytes,
+	})
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("marshal public key: %w", err)
+	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyBytes,
+	})
+
+	return ed25519.PublicKeySize * 8, privateKeyPEM, publicKeyPEM, nil
+}
+
+// normalizeDKIMAlgorithm maps an algorithm or key type name to a signing algorithm
+func normalizeDKIMAlgorithm(algorithm string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(algorithm)) {
+	case "", domain.DKIMKeyTypeRSA, domain.DKIMAlgorithmRSASHA256:
+		return domain.DKIMAlgorithmRSASHA256, nil
+	case domain.DKIMKeyTypeEd25519, domain.DKIMAlgorithmEd25519SHA256:
+		return domain.DKIMAlgorithmEd25519SHA256, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDKIMAlgorithm, algorithm)
+	}
+}
+
 // encryptPrivateKey encrypts the private key using AES-GCM
 func (s *DKIMService) encryptPrivateKey(privateKey []byte) (string, error) {
 	// Decode the encryption key from base64
@@ -179,6 +253,13 @@ func (s *DKIMService) DecryptPrivateKey(encryptedKey string) ([]byte, error) {
 
 // GetDNSRecord returns the DNS TXT record value for a DKIM key
 func (s *DKIMService) GetDNSRecord(key *domain.DKIMKey, domainName string) string {
+	if domain.DKIMKeyTypeForAlgorithm(key.Algorithm) == domain.DKIMKeyTypeEd25519 {
+		// RFC 8463: p= carries the raw 32-byte public key, not a PKIX structure
+		if raw, err := rawEd25519PublicKey(key.PublicKey); err == nil {
+			return fmt.Sprintf("v=DKIM1; k=ed25519; p=%s", base64.StdEncoding.EncodeToString(raw))
+		}
+	}
+
 	// Extract public key without PEM headers
 	pubKey := key.PublicKey
 	pubKey = stripPEMHeaders(pubKey)
@@ -186,6 +267,26 @@ func (s *DKIMService) GetDNSRecord(key *domain.DKIMKey, domainName string) strin
 	return fmt.Sprintf("v=DKIM1; k=rsa; p=%s", pubKey)
 }
 
+// rawEd25519PublicKey extracts the raw Ed25519 public key from a PEM-encoded PKIX key
+func rawEd25519PublicKey(publicKeyPEM string) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key PEM")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+
+	edKey, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not ed25519")
+	}
+
+	return edKey, nil
+}
+
 // GetDNSRecordName returns the DNS record name for a DKIM key
 func (s *DKIMService) GetDNSRecordName(selector, domainName string) string {
 	return fmt.Sprintf("%s._domainkey.%s", selector, domainName)
@@ -197,6 +298,7 @@ func (s *DKIMService) ToPublic(key *domain.DKIMKey, domainName string) *domain.D
 		ID:          key.ID,
 		Selector:    key.Selector,
 		Algorithm:   key.Algorithm,
+		KeyType:     domain.DKIMKeyTypeForAlgorithm(key.Algorithm),
 		KeySize:     key.KeySize,
 		PublicKey:   key.PublicKey,
 		DNSRecord:   s.GetDNSRecord(key, domainName),
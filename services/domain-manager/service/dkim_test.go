@@ -29,6 +29,7 @@ func TestDKIMService_GenerateKeyPair(t *testing.T) {
 		name      string
 		domainID  string
 		selector  string
+		algorithm string
 		expectErr bool
 	}{
 		{
@@ -43,11 +44,18 @@ func TestDKIMService_GenerateKeyPair(t *testing.T) {
 			selector:  "",
 			expectErr: false,
 		},
+		{
+			name:      "unsupported algorithm",
+			domainID:  "domain-3",
+			selector:  "s1",
+			algorithm: "dsa-sha1",
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			key, err := service.GenerateKeyPair(tt.domainID, tt.selector)
+			key, err := service.GenerateKeyPair(tt.domainID, tt.selector, tt.algorithm)
 
 			if tt.expectErr {
 				if err == nil {
@@ -101,6 +109,44 @@ func TestDKIMService_GenerateKeyPair(t *testing.T) {
 	}
 }
 
+func TestDKIMService_GenerateKeyPair_Ed25519(t *testing.T) {
+	cfg := &config.DKIMConfig{
+		DefaultKeySize:   2048,
+		DefaultAlgorithm: "rsa-sha256",
+		EncryptionKey:    base64.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012")),
+	}
+	dnsCfg := &config.DNSConfig{
+		DefaultDKIMSelector: "mail",
+	}
+
+	service := NewDKIMService(cfg, dnsCfg, zap.NewNop())
+
+	key, err := service.GenerateKeyPair("domain-1", "ed1", "ed25519")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if key.Algorithm != domain.DKIMAlgorithmEd25519SHA256 {
+		t.Errorf("Expected algorithm ed25519-sha256, got %s", key.Algorithm)
+	}
+	if key.KeyType != domain.DKIMKeyTypeEd25519 {
+		t.Errorf("Expected key type ed25519, got %s", key.KeyType)
+	}
+
+	record := service.GetDNSRecord(key, "example.com")
+	if !strings.HasPrefix(record, "v=DKIM1; k=ed25519; p=") {
+		t.Fatalf("Expected ed25519 DNS record, got: %s", record)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(record, "v=DKIM1; k=ed25519; p="))
+	if err != nil {
+		t.Fatalf("Failed to decode p= value: %v", err)
+	}
+	if len(raw) != 32 {
+		t.Errorf("Expected 32-byte raw public key, got %d bytes", len(raw))
+	}
+}
+
 func TestDKIMService_EncryptDecryptPrivateKey(t *testing.T) {
 	logger := zap.NewNop()
 	encKey := base64.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012"))
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"domain-manager/config"
+	"domain-manager/domain"
+	"domain-manager/repository"
+)
+
+// ErrDomainNotFound is returned when a report is requested for an unknown domain
+var ErrDomainNotFound = errors.New("domain not found")
+
+// DMARCReportService builds RFC 7489 aggregate (RUA) reports from recorded authentication results
+type DMARCReportService struct {
+	domainRepo *repository.DomainRepository
+	reportRepo *repository.DMARCReportRepository
+	dnsService *DNSService
+	config     *config.DMARCConfig
+	logger     *zap.Logger
+}
+
+// NewDMARCReportService creates a new DMARC report service
+func NewDMARCReportService(
+	domainRepo *repository.DomainRepository,
+	reportRepo *repository.DMARCReportRepository,
+	dnsService *DNSService,
+	cfg *config.DMARCConfig,
+	logger *zap.Logger,
+) *DMARCReportService {
+	return &DMARCReportService{
+		domainRepo: domainRepo,
+		reportRepo: reportRepo,
+		dnsService: dnsService,
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// RecordAuthResult validates and stores an authentication result for the reporting window
+func (s *DMARCReportService) RecordAuthResult(ctx context.Context, res *domain.DMARCAuthResult) error {
+	if net.ParseIP(res.SourceIP) == nil {
+		return fmt.Errorf("invalid source IP: %q", res.SourceIP)
+	}
+	if res.HeaderFrom == "" {
+		return fmt.Errorf("header_from is required")
+	}
+	if res.SPFResult == "" {
+		res.SPFResult = "none"
+	}
+	if res.DKIMResult == "" {
+		res.DKIMResult = "none"
+	}
+
+	return s.reportRepo.RecordAuthResult(ctx, res)
+}
+
+// GenerateAggregateReport builds the gzipped aggregate XML report for a domain covering [start, end)
+func (s *DMARCReportService) GenerateAggregateReport(ctx context.Context, domainID string, start, end time.Time) (*domain.DMARCAggregateReport, error) {
+	d, err := s.domainRepo.GetByID(ctx, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrDomainNotFound
+	}
+
+	policy, err := s.dnsService.LookupDMARCPolicy(ctx, d.DomainName)
+	if err != nil {
+		return nil, fmt.Errorf("get published DMARC policy: %w", err)
+	}
+
+	results, err := s.reportRepo.ListAuthResults(ctx, domainID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	reportID := fmt.Sprintf("%s!%d!%d", d.DomainName, start.Unix(), end.Unix())
+	feedback := buildAggregateFeedback(s.config.OrgName, s.config.ReportEmail, reportID, start, end, policy, results)
+
+	data, err := encodeAggregateFeedback(feedback)
+	if err != nil {
+		return nil, err
+	}
+
+	var messageCount int64
+	for _, rec := range feedback.Records {
+		messageCount += int64(rec.Row.Count)
+	}
+
+	s.logger.Debug("Generated DMARC aggregate report",
+		zap.String("domain", d.DomainName),
+		zap.String("report_id", reportID),
+		zap.Int("records", len(feedback.Records)),
+		zap.Int64("messages", messageCount),
+	)
+
+	return &domain.DMARCAggregateReport{
+		DomainID:       domainID,
+		ReportID:       reportID,
+		DateRangeBegin: start,
+		DateRangeEnd:   end,
+		RecordCount:    len(feedback.Records),
+		MessageCount:   messageCount,
+		Data:           data,
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// aggregateFeedback is the root element of the RFC 7489 Appendix C schema
+type aggregateFeedback struct {
+	XMLName         xml.Name                 `xml:"feedback"`
+	Version         string                   `xml:"version"`
+	ReportMetadata  aggregateReportMetadata  `xml:"report_metadata"`
+	PolicyPublished aggregatePolicyPublished `xml:"policy_published"`
+	Records         []aggregateRecord        `xml:"record"`
+}
+
+type aggregateReportMetadata struct {
+	OrgName   string             `xml:"org_name"`
+	Email     string             `xml:"email"`
+	ReportID  string             `xml:"report_id"`
+	DateRange aggregateDateRange `xml:"date_range"`
+}
+
+type aggregateDateRange struct {
+	Begin int64 `xml:"begin"`
+	End   int64 `xml:"end"`
+}
+
+type aggregatePolicyPublished struct {
+	Domain string `xml:"domain"`
+	ADKIM  string `xml:"adkim"`
+	ASPF   string `xml:"aspf"`
+	P      string `xml:"p"`
+	SP     string `xml:"sp"`
+	Pct    int    `xml:"pct"`
+}
+
+type aggregateRecord struct {
+	Row         aggregateRow         `xml:"row"`
+	Identifiers aggregateIdentifiers `xml:"identifiers"`
+	AuthResults aggregateAuthResults `xml:"auth_results"`
+}
+
+type aggregateRow struct {
+	SourceIP        string                   `xml:"source_ip"`
+	Count           int                      `xml:"count"`
+	PolicyEvaluated aggregatePolicyEvaluated `xml:"policy_evaluated"`
+}
+
+type aggregatePolicyEvaluated struct {
+	Disposition string `xml:"disposition"`
+	DKIM        string `xml:"dkim"`
+	SPF         string `xml:"spf"`
+}
+
+type aggregateIdentifiers struct {
+	HeaderFrom   string `xml:"header_from"`
+	EnvelopeFrom string `xml:"envelope_from,omitempty"`
+}
+
+type aggregateAuthResults struct {
+	DKIM []aggregateDKIMResult `xml:"dkim"`
+	SPF  []aggregateSPFResult  `xml:"spf"`
+}
+
+type aggregateDKIMResult struct {
+	Domain   string `xml:"domain"`
+	Selector string `xml:"selector,omitempty"`
+	Result   string `xml:"result"`
+}
+
+type aggregateSPFResult struct {
+	Domain string `xml:"domain"`
+	Scope  string `xml:"scope"`
+	Result string `xml:"result"`
+}
+
+// buildAggregateFeedback evaluates each result against the published policy and
+// collapses identical rows, summing their message counts
+func buildAggregateFeedback(orgName, email, reportID string, start, end time.Time, policy *domain.DMARCPolicy, results []*domain.DMARCAuthResult) *aggregateFeedback {
+	feedback := &aggregateFeedback{
+		Version: "1.0",
+		ReportMetadata: aggregateReportMetadata{
+			OrgName:  orgName,
+			Email:    email,
+			ReportID: reportID,
+			DateRange: aggregateDateRange{
+				Begin: start.Unix(),
+				End:   end.Unix(),
+			},
+		},
+		PolicyPublished: aggregatePolicyPublished{
+			Domain: policy.Domain,
+			ADKIM:  policy.ADKIM,
+			ASPF:   policy.ASPF,
+			P:      policy.Policy,
+			SP:     policy.SubdomainPolicy,
+			Pct:    policy.Percentage,
+		},
+	}
+
+	index := make(map[aggregateRecordKey]int)
+	for _, res := range results {
+		rec := evaluateAuthResult(policy, res)
+
+		count := res.Count
+		if count <= 0 {
+			count = 1
+		}
+
+		key := newAggregateRecordKey(rec)
+		if i, ok := index[key]; ok {
+			feedback.Records[i].Row.Count += count
+			continue
+		}
+
+		rec.Row.Count = count
+		index[key] = len(feedback.Records)
+		feedback.Records = append(feedback.Records, rec)
+	}
+
+	return feedback
+}
+
+// aggregateRecordKey identifies rows that may be merged into a single record
+type aggregateRecordKey struct {
+	sourceIP     string
+	headerFrom   string
+	envelopeFrom string
+	evaluated    aggregatePolicyEvaluated
+	dkim         string
+	spf          aggregateSPFResult
+}
+
+func newAggregateRecordKey(rec aggregateRecord) aggregateRecordKey {
+	var dkim []string
+	for _, r := range rec.AuthResults.DKIM {
+		dkim = append(dkim, r.Domain+"|"+r.Selector+"|"+r.Result)
+	}
+	return aggregateRecordKey{
+		sourceIP:     rec.Row.SourceIP,
+		headerFrom:   rec.Identifiers.HeaderFrom,
+		envelopeFrom: rec.Identifiers.EnvelopeFrom,
+		evaluated:    rec.Row.PolicyEvaluated,
+		dkim:         strings.Join(dkim, ","),
+		spf:          rec.AuthResults.SPF[0],
+	}
+}
+
+// evaluateAuthResult applies DMARC identifier alignment and policy to a single result
+func evaluateAuthResult(policy *domain.DMARCPolicy, res *domain.DMARCAuthResult) aggregateRecord {
+	headerFrom := normalizeDomain(res.HeaderFrom)
+
+	spfDomain := normalizeDomain(res.SPFDomain)
+	if spfDomain == "" {
+		spfDomain = normalizeDomain(domainOf(res.EnvelopeFrom))
+	}
+	if spfDomain == "" {
+		spfDomain = headerFrom
+	}
+
+	dkimAligned := res.DKIMResult == "pass" && identifiersAligned(normalizeDomain(res.DKIMDomain), headerFrom, policy.ADKIM)
+	spfAligned := res.SPFResult == "pass" && identifiersAligned(spfDomain, headerFrom, policy.ASPF)
+
+	evaluated := aggregatePolicyEvaluated{
+		Disposition: res.Disposition,
+		DKIM:        "fail",
+		SPF:         "fail",
+	}
+	if dkimAligned {
+		evaluated.DKIM = "pass"
+	}
+	if spfAligned {
+		evaluated.SPF = "pass"
+	}
+
+	if evaluated.Disposition == "" {
+		switch {
+		case dkimAligned || spfAligned:
+			evaluated.Disposition = domain.DMARCDispositionNone
+		case headerFrom != normalizeDomain(policy.Domain):
+			evaluated.Disposition = policy.SubdomainPolicy
+		default:
+			evaluated.Disposition = policy.Policy
+		}
+	}
+
+	rec := aggregateRecord{
+		Row: aggregateRow{
+			SourceIP:        res.SourceIP,
+			PolicyEvaluated: evaluated,
+		},
+		Identifiers: aggregateIdentifiers{
+			HeaderFrom:   headerFrom,
+			EnvelopeFrom: normalizeDomain(domainOf(res.EnvelopeFrom)),
+		},
+		AuthResults: aggregateAuthResults{
+			SPF: []aggregateSPFResult{{
+				Domain: spfDomain,
+				Scope:  "mfrom",
+				Result: res.SPFResult,
+			}},
+		},
+	}
+	if res.DKIMDomain != "" {
+		rec.AuthResults.DKIM = []aggregateDKIMResult{{
+			Domain:   normalizeDomain(res.DKIMDomain),
+			Selector: res.DKIMSelector,
+			Result:   res.DKIMResult,
+		}}
+	}
+
+	return rec
+}
+
+// identifiersAligned reports whether two domains align in strict ("s") or relaxed ("r") mode
+func identifiersAligned(authDomain, headerFrom, mode string) bool {
+	if authDomain == "" || headerFrom == "" {
+		return false
+	}
+	if mode == "s" {
+		return authDomain == headerFrom
+	}
+	return organizationalDomain(authDomain) == organizationalDomain(headerFrom)
+}
+
+// organizationalDomain approximates the organizational domain as the last two labels
+func organizationalDomain(name string) string {
+	labels := strings.Split(name, ".")
+	if len(labels) <= 2 {
+		return name
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+// domainOf returns the domain part of an address, or the input if it has no local part
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return address
+}
+
+func normalizeDomain(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// encodeAggregateFeedback serializes a report to gzipped XML
+func encodeAggregateFeedback(feedback *aggregateFeedback) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+
+	if _, err := zw.Write([]byte(xml.Header)); err != nil {
+		return nil, fmt.Errorf("write report header: %w", err)
+	}
+	enc := xml.NewEncoder(zw)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feedback); err != nil {
+		return nil, fmt.Errorf("encode report: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress report: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
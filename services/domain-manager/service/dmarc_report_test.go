@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"domain-manager/domain"
+)
+
+func TestParseDMARCRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  string
+		wantP   string
+		wantSP  string
+		wantPct int
+		wantErr bool
+	}{
+		{
+			name:    "full record",
+			record:  "v=DMARC1; p=reject; sp=quarantine; pct=50; rua=mailto:a@example.com",
+			wantP:   "reject",
+			wantSP:  "quarantine",
+			wantPct: 50,
+		},
+		{
+			name:    "defaults sp and pct",
+			record:  "v=DMARC1; p=quarantine",
+			wantP:   "quarantine",
+			wantSP:  "quarantine",
+			wantPct: 100,
+		},
+		{
+			name:    "missing policy",
+			record:  "v=DMARC1; rua=mailto:a@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "invalid pct",
+			record:  "v=DMARC1; p=none; pct=150",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseDMARCRecord("example.com", tt.record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDMARCRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if policy.Policy != tt.wantP {
+				t.Errorf("Policy = %q, want %q", policy.Policy, tt.wantP)
+			}
+			if policy.SubdomainPolicy != tt.wantSP {
+				t.Errorf("SubdomainPolicy = %q, want %q", policy.SubdomainPolicy, tt.wantSP)
+			}
+			if policy.Percentage != tt.wantPct {
+				t.Errorf("Percentage = %d, want %d", policy.Percentage, tt.wantPct)
+			}
+		})
+	}
+}
+
+func TestBuildAggregateFeedback_Dispositions(t *testing.T) {
+	policy := &domain.DMARCPolicy{
+		Domain:          "example.com",
+		ADKIM:           "r",
+		ASPF:            "r",
+		Policy:          "reject",
+		SubdomainPolicy: "quarantine",
+		Percentage:      100,
+	}
+
+	results := []*domain.DMARCAuthResult{
+		// Aligned DKIM pass, counted twice across two rows
+		{SourceIP: "192.0.2.1", HeaderFrom: "example.com", EnvelopeFrom: "bounce@mail.example.com", SPFResult: "pass", DKIMDomain: "example.com", DKIMSelector: "mail", DKIMResult: "pass", Count: 3},
+		{SourceIP: "192.0.2.1", HeaderFrom: "example.com", EnvelopeFrom: "bounce@mail.example.com", SPFResult: "pass", DKIMDomain: "example.com", DKIMSelector: "mail", DKIMResult: "pass", Count: 2},
+		// Unaligned pass fails DMARC and gets the domain policy
+		{SourceIP: "198.51.100.7", HeaderFrom: "example.com", EnvelopeFrom: "x@spammer.test", SPFResult: "pass", DKIMDomain: "spammer.test", DKIMResult: "pass"},
+		// Subdomain failure gets sp
+		{SourceIP: "198.51.100.8", HeaderFrom: "news.example.com", SPFResult: "fail", DKIMResult: "none"},
+		// Recorded disposition (e.g. sampled out by pct) is kept
+		{SourceIP: "198.51.100.9", HeaderFrom: "example.com", SPFResult: "fail", DKIMResult: "fail", Disposition: "none"},
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	feedback := buildAggregateFeedback("Org", "dmarc@example.com", "r1", start, start.Add(24*time.Hour), policy, results)
+
+	if len(feedback.Records) != 4 {
+		t.Fatalf("got %d records, want 4", len(feedback.Records))
+	}
+
+	want := []struct {
+		count       int
+		disposition string
+		dkim, spf   string
+	}{
+		{5, "none", "pass", "pass"},
+		{1, "reject", "fail", "fail"},
+		{1, "quarantine", "fail", "fail"},
+		{1, "none", "fail", "fail"},
+	}
+	for i, w := range want {
+		row := feedback.Records[i].Row
+		if row.Count != w.count {
+			t.Errorf("record %d count = %d, want %d", i, row.Count, w.count)
+		}
+		if row.PolicyEvaluated.Disposition != w.disposition {
+			t.Errorf("record %d disposition = %q, want %q", i, row.PolicyEvaluated.Disposition, w.disposition)
+		}
+		if row.PolicyEvaluated.DKIM != w.dkim || row.PolicyEvaluated.SPF != w.spf {
+			t.Errorf("record %d evaluated dkim/spf = %s/%s, want %s/%s", i,
+				row.PolicyEvaluated.DKIM, row.PolicyEvaluated.SPF, w.dkim, w.spf)
+		}
+	}
+
+	if feedback.PolicyPublished.P != "reject" || feedback.PolicyPublished.SP != "quarantine" || feedback.PolicyPublished.Pct != 100 {
+		t.Errorf("unexpected published policy: %+v", feedback.PolicyPublished)
+	}
+}
+
+func TestEncodeAggregateFeedback_RoundTrip(t *testing.T) {
+	policy := &domain.DMARCPolicy{Domain: "example.com", ADKIM: "s", ASPF: "r", Policy: "none", SubdomainPolicy: "none", Percentage: 100}
+	results := []*domain.DMARCAuthResult{
+		{SourceIP: "192.0.2.1", HeaderFrom: "example.com", SPFResult: "pass", DKIMDomain: "example.com", DKIMResult: "pass"},
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err := encodeAggregateFeedback(buildAggregateFeedback("Org", "dmarc@example.com", "r1", start, start.Add(24*time.Hour), policy, results))
+	if err != nil {
+		t.Fatalf("encodeAggregateFeedback() error = %v", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+
+	var decoded aggregateFeedback
+	if err := xml.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("xml.Unmarshal() error = %v", err)
+	}
+	if decoded.ReportMetadata.DateRange.Begin != start.Unix() {
+		t.Errorf("date_range begin = %d, want %d", decoded.ReportMetadata.DateRange.Begin, start.Unix())
+	}
+	if len(decoded.Records) != 1 || decoded.Records[0].AuthResults.SPF[0].Scope != "mfrom" {
+		t.Errorf("unexpected records: %+v", decoded.Records)
+	}
+}
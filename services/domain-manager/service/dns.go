@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,13 +27,17 @@ func stringPtr(s string) *string {
 type DNSService struct {
 	config *config.DNSConfig
 	logger *zap.Logger
+
+	// lookupTXT resolves TXT records; replaced in tests
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
 }
 
 // NewDNSService creates a new DNS service
 func NewDNSService(cfg *config.DNSConfig, logger *zap.Logger) *DNSService {
 	return &DNSService{
-		config: cfg,
-		logger: logger,
+		config:    cfg,
+		logger:    logger,
+		lookupTXT: net.DefaultResolver.LookupTXT,
 	}
 }
 
@@ -305,6 +311,46 @@ func (s *DNSService) checkDKIM(domainName, selector, publicKey string, result *d
 	return false
 }
 
+// DKIMRecordPublished reports whether the DKIM record at selector publishes
+// the public key in expectedRecord, comparing the p= tags
+func (s *DNSService) DKIMRecordPublished(ctx context.Context, domainName, selector, expectedRecord string) (bool, error) {
+	expectedKey := dkimTag(expectedRecord, "p")
+	if expectedKey == "" {
+		return false, fmt.Errorf("expected DKIM record has no public key")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.LookupTimeout)
+	defer cancel()
+
+	records, err := s.lookupTXT(ctx, fmt.Sprintf("%s._domainkey.%s", selector, domainName))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, record := range records {
+		if dkimTag(record, "p") == expectedKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dkimTag returns the value of a tag in a DKIM record, with whitespace
+// removed since long keys are often split across strings
+func dkimTag(record, name string) string {
+	for _, tag := range strings.Split(record, ";") {
+		key, value, ok := strings.Cut(tag, "=")
+		if ok && strings.TrimSpace(key) == name {
+			return strings.Join(strings.Fields(value), "")
+		}
+	}
+	return ""
+}
+
 // checkDMARC checks DMARC record
 func (s *DNSService) checkDMARC(domainName string, result *domain.DNSCheckResult) bool {
 	dmarcDomain := fmt.Sprintf("_dmarc.%s", domainName)
@@ -345,6 +391,84 @@ func (s *DNSService) checkDMARC(domainName string, result *domain.DNSCheckResult
 	return false
 }
 
+// ErrNoDMARCRecord is returned when a domain does not publish a DMARC record
+var ErrNoDMARCRecord = errors.New("no DMARC record published")
+
+// LookupDMARCPolicy fetches and parses the DMARC record published for a domain
+func (s *DNSService) LookupDMARCPolicy(ctx context.Context, domainName string) (*domain.DMARCPolicy, error) {
+	records, err := net.DefaultResolver.LookupTXT(ctx, fmt.Sprintf("_dmarc.%s", domainName))
+	if err != nil {
+		return nil, fmt.Errorf("lookup DMARC record: %w", err)
+	}
+
+	for _, record := range records {
+		if strings.HasPrefix(record, "v=DMARC1") {
+			return ParseDMARCRecord(domainName, record)
+		}
+	}
+
+	return nil, ErrNoDMARCRecord
+}
+
+// ParseDMARCRecord parses a DMARC TXT record, applying RFC 7489 defaults for omitted tags
+func ParseDMARCRecord(domainName, record string) (*domain.DMARCPolicy, error) {
+	policy := &domain.DMARCPolicy{
+		Domain:     domainName,
+		ADKIM:      "r",
+		ASPF:       "r",
+		Percentage: 100,
+	}
+
+	for _, part := range strings.Split(record, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "v":
+			if value != "DMARC1" {
+				return nil, fmt.Errorf("invalid DMARC version: %s", value)
+			}
+		case "p":
+			policy.Policy = strings.ToLower(value)
+		case "sp":
+			policy.SubdomainPolicy = strings.ToLower(value)
+		case "adkim":
+			policy.ADKIM = strings.ToLower(value)
+		case "aspf":
+			policy.ASPF = strings.ToLower(value)
+		case "pct":
+			pct, err := strconv.Atoi(value)
+			if err != nil || pct < 0 || pct > 100 {
+				return nil, fmt.Errorf("invalid DMARC pct: %s", value)
+			}
+			policy.Percentage = pct
+		case "rua":
+			for _, uri := range strings.Split(value, ",") {
+				if uri = strings.TrimSpace(uri); uri != "" {
+					policy.RUA = append(policy.RUA, uri)
+				}
+			}
+		}
+	}
+
+	switch policy.Policy {
+	case domain.DMARCDispositionNone, domain.DMARCDispositionQuarantine, domain.DMARCDispositionReject:
+	default:
+		return nil, fmt.Errorf("invalid DMARC policy: %q", policy.Policy)
+	}
+
+	// Subdomain policy defaults to the organizational policy
+	if policy.SubdomainPolicy == "" {
+		policy.SubdomainPolicy = policy.Policy
+	}
+
+	return policy, nil
+}
+
 // VerifyDomain performs initial domain verification
 func (s *DNSService) VerifyDomain(ctx context.Context, domainName, verificationToken string) bool {
 	return s.checkVerificationTXT(domainName, verificationToken, &domain.DNSCheckResult{Issues: []domain.DNSIssue{}})
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"domain-manager/domain"
+)
+
+// spfLookupLimit is the RFC 7208 section 4.6.4 cap on DNS-querying terms
+// evaluated for a single SPF check, including nested includes
+const spfLookupLimit = 10
+
+// spfTerm is a single mechanism or modifier from an SPF record
+type spfTerm struct {
+	raw       string
+	qualifier byte // '+', '-', '~' or '?'; zero for modifiers
+	name      string
+	value     string
+	modifier  bool
+}
+
+// AnalyzeSPF fetches the SPF record published for a domain and checks it for
+// common mistakes. Lookup errors other than a missing domain are returned;
+// a missing record is reported as a finding.
+func (s *DNSService) AnalyzeSPF(ctx context.Context, domainName string) (*domain.SPFAnalysis, error) {
+	records, err := s.lookupSPFRecords(ctx, domainName)
+	if err != nil {
+		return nil, fmt.Errorf("lookup SPF record: %w", err)
+	}
+
+	analysis := &domain.SPFAnalysis{
+		Domain:      domainName,
+		Records:     records,
+		LookupLimit: spfLookupLimit,
+		Findings:    []domain.SPFFinding{},
+		CheckedAt:   time.Now(),
+	}
+
+	switch {
+	case len(records) == 0:
+		analysis.Findings = append(analysis.Findings, domain.SPFFinding{
+			Severity: domain.SPFSeverityError,
+			Code:     "missing_record",
+			Message:  "No SPF record is published. Receivers cannot verify which servers may send mail for this domain.",
+		})
+		return analysis, nil
+	case len(records) > 1:
+		analysis.Findings = append(analysis.Findings, domain.SPFFinding{
+			Severity: domain.SPFSeverityError,
+			Code:     "multiple_records",
+			Message:  fmt.Sprintf("%d SPF records are published. Receivers treat this as a permanent error; merge them into a single record.", len(records)),
+		})
+	}
+
+	record := records[0]
+	analysis.Findings = append(analysis.Findings, checkSPFRecord(record, s.config.SPFInclude)...)
+
+	visited := map[string]bool{strings.ToLower(domainName): true}
+	analysis.LookupCount = s.countSPFLookups(ctx, record, visited, analysis)
+	if analysis.LookupCount > spfLookupLimit {
+		analysis.Findings = append(analysis.Findings, domain.SPFFinding{
+			Severity: domain.SPFSeverityError,
+			Code:     "too_many_lookups",
+			Message:  fmt.Sprintf("Record requires more than %d DNS lookups. Receivers will fail SPF evaluation; flatten or remove includes.", spfLookupLimit),
+		})
+	} else if analysis.LookupCount == spfLookupLimit {
+		analysis.Findings = append(analysis.Findings, domain.SPFFinding{
+			Severity: domain.SPFSeverityWarning,
+			Code:     "lookup_limit_reached",
+			Message:  fmt.Sprintf("Record uses all %d allowed DNS lookups. Any further include will break SPF evaluation.", spfLookupLimit),
+		})
+	}
+
+	analysis.Valid = true
+	for _, f := range analysis.Findings {
+		if f.Severity == domain.SPFSeverityError {
+			analysis.Valid = false
+			break
+		}
+	}
+
+	return analysis, nil
+}
+
+// lookupSPFRecords returns the SPF records among a domain's TXT records.
+// A domain that doesn't exist has no records rather than an error.
+func (s *DNSService) lookupSPFRecords(ctx context.Context, domainName string) ([]string, error) {
+	txt, err := s.lookupTXT(ctx, domainName)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []string
+	for _, record := range txt {
+		if isSPFRecord(record) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// countSPFLookups counts the DNS-querying terms evaluated for record,
+// following include and redirect targets. Counting stops once the limit is
+// exceeded since receivers abort evaluation at that point.
+func (s *DNSService) countSPFLookups(ctx context.Context, record string, visited map[string]bool, analysis *domain.SPFAnalysis) int {
+	terms := parseSPFTerms(record)
+
+	hasAll := false
+	for _, t := range terms {
+		if !t.modifier && t.name == "all" {
+			hasAll = true
+		}
+	}
+
+	count := 0
+	for _, t := range terms {
+		var target string
+		switch {
+		case !t.modifier && (t.name == "a" || t.name == "mx" || t.name == "ptr" || t.name == "exists"):
+			count++
+		case !t.modifier && t.name == "include":
+			count++
+			target = t.value
+		case t.modifier && t.name == "redirect" && !hasAll:
+			count++
+			target = t.value
+		}
+
+		if count > spfLookupLimit {
+			return count
+		}
+
+		// Macro targets depend on the message being checked
+		if target == "" || strings.Contains(target, "%") {
+			continue
+		}
+		target = strings.ToLower(strings.TrimSuffix(target, "."))
+		if visited[target] {
+			continue
+		}
+		visited[target] = true
+
+		records, err := s.lookupSPFRecords(ctx, target)
+		if err != nil {
+			analysis.Findings = append(analysis.Findings, domain.SPFFinding{
+				Severity: domain.SPFSeverityWarning,
+				Code:     "lookup_failed",
+				Message:  fmt.Sprintf("Failed to lookup SPF record for %s: %v", target, err),
+				Term:     t.raw,
+			})
+			continue
+		}
+		if len(records) != 1 {
+			analysis.Findings = append(analysis.Findings, domain.SPFFinding{
+				Severity: domain.SPFSeverityError,
+				Code:     "invalid_target",
+				Message:  fmt.Sprintf("%s does not publish exactly one SPF record. Receivers treat this as a permanent error.", target),
+				Term:     t.raw,
+			})
+			continue
+		}
+
+		count += s.countSPFLookups(ctx, records[0], visited, analysis)
+		if count > spfLookupLimit {
+			return count
+		}
+	}
+
+	return count
+}
+
+// checkSPFRecord checks the terms of a single SPF record for syntax errors and
+// insecure or discouraged policies. requiredInclude, if set, is the platform
+// SPF domain the record is expected to include.
+func checkSPFRecord(record, requiredInclude string) []domain.SPFFinding {
+	var findings []domain.SPFFinding
+
+	var all *spfTerm
+	hasRedirect := false
+	hasRequiredInclude := false
+
+	terms := parseSPFTerms(record)
+	for i := range terms {
+		t := &terms[i]
+
+		if all != nil && !t.modifier {
+			findings = append(findings, domain.SPFFinding{
+				Severity: domain.SPFSeverityWarning,
+				Code:     "terms_after_all",
+				Message:  "Mechanisms after 'all' are never evaluated.",
+				Term:     t.raw,
+			})
+			continue
+		}
+
+		if t.modifier {
+			if t.name == "redirect" {
+				hasRedirect = true
+			}
+			continue
+		}
+
+		switch t.name {
+		case "all":
+			all = t
+		case "include", "exists":
+			if t.value == "" {
+				findings = append(findings, domain.SPFFinding{
+					Severity: domain.SPFSeverityError,
+					Code:     "invalid_term",
+					Message:  fmt.Sprintf("'%s' requires a domain.", t.name),
+					Term:     t.raw,
+				})
+			}
+			if t.name == "include" && requiredInclude != "" && strings.EqualFold(strings.TrimSuffix(t.value, "."), requiredInclude) {
+				hasRequiredInclude = true
+			}
+		case "a", "mx":
+		case "ptr":
+			findings = append(findings, domain.SPFFinding{
+				Severity: domain.SPFSeverityWarning,
+				Code:     "ptr_mechanism",
+				Message:  "The 'ptr' mechanism is slow and unreliable; RFC 7208 recommends against using it.",
+				Term:     t.raw,
+			})
+		case "ip4", "ip6":
+			if !validSPFNetwork(t.name, t.value) {
+				findings = append(findings, domain.SPFFinding{
+					Severity: domain.SPFSeverityError,
+					Code:     "invalid_term",
+					Message:  fmt.Sprintf("'%s' is not a valid %s address or network.", t.value, t.name),
+					Term:     t.raw,
+				})
+			}
+		default:
+			findings = append(findings, domain.SPFFinding{
+				Severity: domain.SPFSeverityError,
+				Code:     "unknown_mechanism",
+				Message:  fmt.Sprintf("Unknown mechanism '%s'. Receivers treat this as a permanent error.", t.name),
+				Term:     t.raw,
+			})
+		}
+	}
+
+	switch {
+	case all == nil && !hasRedirect:
+		findings = append(findings, domain.SPFFinding{
+			Severity: domain.SPFSeverityWarning,
+			Code:     "missing_all",
+			Message:  "Record does not end with an 'all' mechanism, so mail from unlisted servers gets a neutral result. Add '~all' or '-all'.",
+		})
+	case all != nil && all.qualifier == '+':
+		findings = append(findings, domain.SPFFinding{
+			Severity: domain.SPFSeverityError,
+			Code:     "insecure_all",
+			Message:  "'+all' authorizes every server on the internet to send mail for this domain. Use '~all' or '-all'.",
+			Term:     all.raw,
+		})
+	case all != nil && all.qualifier == '?':
+		findings = append(findings, domain.SPFFinding{
+			Severity: domain.SPFSeverityWarning,
+			Code:     "neutral_all",
+			Message:  "'?all' gives no guidance for unlisted servers and offers no spoofing protection. Use '~all' or '-all'.",
+			Term:     all.raw,
+		})
+	}
+
+	if requiredInclude != "" && !hasRequiredInclude {
+		findings = append(findings, domain.SPFFinding{
+			Severity: domain.SPFSeverityWarning,
+			Code:     "missing_include",
+			Message:  fmt.Sprintf("Record does not include %s, so mail sent through this platform may fail SPF.", requiredInclude),
+		})
+	}
+
+	return findings
+}
+
+// isSPFRecord reports whether a TXT record is an SPF version 1 record
+func isSPFRecord(record string) bool {
+	version, _, _ := strings.Cut(record, " ")
+	return strings.EqualFold(version, "v=spf1")
+}
+
+// parseSPFTerms splits an SPF record into its mechanisms and modifiers
+func parseSPFTerms(record string) []spfTerm {
+	fields := strings.Fields(record)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var terms []spfTerm
+	for _, raw := range fields[1:] {
+		t := spfTerm{raw: raw}
+
+		// Modifiers are name=value; a mechanism's domain-spec may contain '='
+		// only after its ':' or '/'
+		eq := strings.Index(raw, "=")
+		sep := strings.IndexAny(raw, ":/")
+		if eq > 0 && (sep < 0 || eq < sep) {
+			t.modifier = true
+			t.name = strings.ToLower(raw[:eq])
+			t.value = raw[eq+1:]
+			terms = append(terms, t)
+			continue
+		}
+
+		t.qualifier = '+'
+		if strings.IndexByte("+-~?", raw[0]) >= 0 {
+			t.qualifier = raw[0]
+			raw = raw[1:]
+		}
+
+		if sep := strings.IndexAny(raw, ":/"); sep >= 0 {
+			t.name = strings.ToLower(raw[:sep])
+			t.value = strings.TrimPrefix(raw[sep:], ":")
+		} else {
+			t.name = strings.ToLower(raw)
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// validSPFNetwork checks an ip4 or ip6 mechanism value
+func validSPFNetwork(mechanism, value string) bool {
+	var ip net.IP
+	if strings.Contains(value, "/") {
+		prefix, _, err := net.ParseCIDR(value)
+		if err != nil {
+			return false
+		}
+		ip = prefix
+	} else {
+		ip = net.ParseIP(value)
+	}
+	if ip == nil {
+		return false
+	}
+
+	isIPv4 := ip.To4() != nil
+	return isIPv4 == (mechanism == "ip4")
+}
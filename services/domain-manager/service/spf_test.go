@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"domain-manager/config"
+	"domain-manager/domain"
+)
+
+func newTestSPFService(txt map[string][]string) *DNSService {
+	return &DNSService{
+		config: &config.DNSConfig{},
+		logger: zap.NewNop(),
+		lookupTXT: func(ctx context.Context, name string) ([]string, error) {
+			records, ok := txt[name]
+			if !ok {
+				return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+			}
+			return records, nil
+		},
+	}
+}
+
+func findingCodes(analysis *domain.SPFAnalysis) map[string]string {
+	codes := make(map[string]string)
+	for _, f := range analysis.Findings {
+		codes[f.Code] = f.Severity
+	}
+	return codes
+}
+
+func TestAnalyzeSPF(t *testing.T) {
+	tests := []struct {
+		name        string
+		txt         map[string][]string
+		wantValid   bool
+		wantLookups int
+		wantCodes   map[string]string
+	}{
+		{
+			name: "valid record",
+			txt: map[string][]string{
+				"example.com":    {"v=spf1 mx include:_spf.mail.test -all", "google-site-verification=abc"},
+				"_spf.mail.test": {"v=spf1 ip4:192.0.2.0/24 ~all"},
+			},
+			wantValid:   true,
+			wantLookups: 2,
+			wantCodes:   map[string]string{},
+		},
+		{
+			name:      "missing record",
+			txt:       map[string][]string{},
+			wantCodes: map[string]string{"missing_record": domain.SPFSeverityError},
+		},
+		{
+			name: "multiple records",
+			txt: map[string][]string{
+				"example.com": {"v=spf1 -all", "v=spf1 mx -all"},
+			},
+			wantCodes: map[string]string{"multiple_records": domain.SPFSeverityError},
+		},
+		{
+			name: "plus all",
+			txt: map[string][]string{
+				"example.com": {"v=spf1 +all"},
+			},
+			wantCodes: map[string]string{"insecure_all": domain.SPFSeverityError},
+		},
+		{
+			name: "neutral all and ptr",
+			txt: map[string][]string{
+				"example.com": {"v=spf1 ptr ?all"},
+			},
+			wantValid:   true,
+			wantLookups: 1,
+			wantCodes: map[string]string{
+				"neutral_all":   domain.SPFSeverityWarning,
+				"ptr_mechanism": domain.SPFSeverityWarning,
+			},
+		},
+		{
+			name: "nested includes exceed limit",
+			txt: map[string][]string{
+				"example.com": {"v=spf1 include:a.test include:b.test -all"},
+				"a.test":      {"v=spf1 a mx exists:x.test include:c.test ~all"},
+				"b.test":      {"v=spf1 a mx a:1.test a:2.test ~all"},
+				"c.test":      {"v=spf1 mx ~all"},
+			},
+			wantLookups: 11,
+			wantCodes:   map[string]string{"too_many_lookups": domain.SPFSeverityError},
+		},
+		{
+			name: "include loop",
+			txt: map[string][]string{
+				"example.com": {"v=spf1 include:a.test -all"},
+				"a.test":      {"v=spf1 include:example.com ~all"},
+			},
+			wantValid:   true,
+			wantLookups: 2,
+			wantCodes:   map[string]string{},
+		},
+		{
+			name: "invalid terms",
+			txt: map[string][]string{
+				"example.com": {"v=spf1 ip4:300.1.1.1 ip6:192.0.2.1 foo include:missing.test"},
+			},
+			wantLookups: 1,
+			wantCodes: map[string]string{
+				"invalid_term":      domain.SPFSeverityError,
+				"unknown_mechanism": domain.SPFSeverityError,
+				"invalid_target":    domain.SPFSeverityError,
+				"missing_all":       domain.SPFSeverityWarning,
+			},
+		},
+		{
+			name: "redirect",
+			txt: map[string][]string{
+				"example.com":    {"v=spf1 redirect=_spf.mail.test"},
+				"_spf.mail.test": {"v=spf1 mx -all"},
+			},
+			wantValid:   true,
+			wantLookups: 2,
+			wantCodes:   map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSPFService(tt.txt)
+
+			analysis, err := s.AnalyzeSPF(context.Background(), "example.com")
+			if err != nil {
+				t.Fatalf("AnalyzeSPF() error = %v", err)
+			}
+			if analysis.Valid != tt.wantValid {
+				t.Errorf("Valid = %v, want %v (findings %+v)", analysis.Valid, tt.wantValid, analysis.Findings)
+			}
+			if tt.wantLookups != 0 && analysis.LookupCount != tt.wantLookups {
+				t.Errorf("LookupCount = %d, want %d", analysis.LookupCount, tt.wantLookups)
+			}
+
+			codes := findingCodes(analysis)
+			if len(codes) != len(tt.wantCodes) {
+				t.Errorf("findings = %+v, want codes %v", analysis.Findings, tt.wantCodes)
+			}
+			for code, severity := range tt.wantCodes {
+				if codes[code] != severity {
+					t.Errorf("finding %q severity = %q, want %q", code, codes[code], severity)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeSPF_MissingPlatformInclude(t *testing.T) {
+	s := newTestSPFService(map[string][]string{
+		"example.com": {"v=spf1 mx -all"},
+	})
+	s.config.SPFInclude = "spf.mail.test"
+
+	analysis, err := s.AnalyzeSPF(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("AnalyzeSPF() error = %v", err)
+	}
+	if findingCodes(analysis)["missing_include"] != domain.SPFSeverityWarning {
+		t.Errorf("expected missing_include warning, got %+v", analysis.Findings)
+	}
+}
@@ -24,14 +24,15 @@ type Config struct {
 
 // ServerConfig contains server settings
 type ServerConfig struct {
-	Host            string        `yaml:"host"`
-	Port            int           `yaml:"port"`
-	TLSPort         int           `yaml:"tls_port"`
-	MaxConnections  int           `yaml:"max_connections"`
-	ConnectionLimit int           `yaml:"connection_limit_per_ip"`
-	ReadTimeout     time.Duration `yaml:"read_timeout"`
-	WriteTimeout    time.Duration `yaml:"write_timeout"`
-	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	Host                  string        `yaml:"host"`
+	Port                  int           `yaml:"port"`
+	TLSPort               int           `yaml:"tls_port"`
+	MaxConnections        int           `yaml:"max_connections"`
+	ConnectionLimit       int           `yaml:"connection_limit_per_ip"`
+	MaxConnectionsPerUser int           `yaml:"max_connections_per_user"`
+	ReadTimeout           time.Duration `yaml:"read_timeout"`
+	WriteTimeout          time.Duration `yaml:"write_timeout"`
+	IdleTimeout           time.Duration `yaml:"idle_timeout"`
 }
 
 // TLSConfig contains TLS settings
@@ -98,6 +99,10 @@ type IMAPConfig struct {
 	EnableQRESYNC         bool     `yaml:"enable_qresync"`
 	EnableCONDSTORE       bool     `yaml:"enable_condstore"`
 	EnableThread          bool     `yaml:"enable_thread"` // RFC 5256 THREAD extension
+	// SearchExactSubstring makes SEARCH SUBJECT/BODY/TEXT match substrings as
+	// RFC 3501 requires, using trigram indexes. When false they match whole
+	// words and phrases through the full-text index.
+	SearchExactSubstring  bool     `yaml:"search_exact_substring"`
 }
 
 // MetricsConfig contains metrics settings
@@ -164,6 +169,9 @@ func applyDefaults(cfg *Config) {
 	if cfg.Server.ConnectionLimit == 0 {
 		cfg.Server.ConnectionLimit = 50
 	}
+	if cfg.Server.MaxConnectionsPerUser == 0 {
+		cfg.Server.MaxConnectionsPerUser = 20
+	}
 	if cfg.Server.ReadTimeout == 0 {
 		cfg.Server.ReadTimeout = 30 * time.Minute
 	}
@@ -231,6 +239,7 @@ func applyDefaults(cfg *Config) {
 			"CHILDREN",
 			"LIST-EXTENDED",
 			"LIST-STATUS",
+			"ENABLE",
 		}
 	}
 	if cfg.IMAP.DefaultNamespaceMode == "" {
@@ -243,7 +252,7 @@ func applyDefaults(cfg *Config) {
 		cfg.IMAP.MaxFetchSize = 10 * 1024 * 1024 // 10MB
 	}
 	if cfg.IMAP.IdleTimeout == 0 {
-		cfg.IMAP.IdleTimeout = 30 * time.Minute
+		cfg.IMAP.IdleTimeout = 29 * time.Minute // RFC 2177: re-issue at least every 29 minutes
 	}
 	if cfg.IMAP.IdleNotifyInterval == 0 {
 		cfg.IMAP.IdleNotifyInterval = 5 * time.Second
@@ -212,6 +212,11 @@ func (c *Connection) authenticate(tag, username, password, method string) error
 		// Non-fatal, continue without shared mailboxes
 	}
 
+	if !c.acquireUserLease(tag, user.ID) {
+		authAttempts.WithLabelValues(method, "limit").Inc()
+		return nil
+	}
+
 	// Update context
 	c.ctx.User = user
 	c.ctx.Organization = org
@@ -0,0 +1,149 @@
+package imap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// maxBodyTextSize caps the body text stored for SEARCH so its tsvector stays
+// well under PostgreSQL's 1MB limit. Text past the cap isn't searchable.
+const maxBodyTextSize = 256 * 1024
+
+// maxBodyTextDepth limits how deeply nested multiparts are walked
+const maxBodyTextDepth = 8
+
+var (
+	htmlTagPattern       = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>|<[^>]*>`)
+	whitespaceRunPattern = regexp.MustCompile(`[ \t\r\n]+`)
+)
+
+// extractBodyText returns the decoded text of a raw RFC 5322 message for the
+// search index: text/plain parts, or text/html parts with the markup removed
+// when a multipart/alternative has no plain part. Attachments and non-text
+// parts are skipped. Unparseable messages index the raw text after the headers.
+func extractBodyText(data []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		if i := bytes.Index(data, []byte("\r\n\r\n")); i >= 0 {
+			return truncateBodyText(string(data[i+4:]))
+		}
+		return ""
+	}
+
+	var b strings.Builder
+	appendPartText(&b, msg.Header.Get, msg.Body, 0)
+	return truncateBodyText(strings.TrimSpace(b.String()))
+}
+
+// appendPartText writes the searchable text of one MIME entity to b
+func appendPartText(b *strings.Builder, header func(string) string, body io.Reader, depth int) {
+	if depth > maxBodyTextDepth || b.Len() >= maxBodyTextSize {
+		return
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if disposition, _, _ := mime.ParseMediaType(header("Content-Disposition")); disposition == "attachment" {
+		return
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		appendMultipartText(b, mediaType, params["boundary"], body, depth)
+		return
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return
+	}
+
+	text, err := io.ReadAll(io.LimitReader(decodeTransferEncoding(header("Content-Transfer-Encoding"), body), maxBodyTextSize))
+	if err != nil && len(text) == 0 {
+		return
+	}
+	s := string(text)
+	if mediaType == "text/html" {
+		s = htmlToText(s)
+	}
+	if b.Len() > 0 {
+		b.WriteByte('\n')
+	}
+	b.WriteString(s)
+}
+
+// appendMultipartText walks the parts of a multipart entity. For
+// multipart/alternative only the best text version is indexed, so the same
+// words aren't indexed twice.
+func appendMultipartText(b *strings.Builder, mediaType, boundary string, body io.Reader, depth int) {
+	if boundary == "" {
+		return
+	}
+	reader := multipart.NewReader(body, boundary)
+
+	var html []byte
+	for {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			break
+		}
+		if mediaType == "multipart/alternative" {
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if partType == "text/html" {
+				if html == nil {
+					html, _ = io.ReadAll(io.LimitReader(decodeTransferEncoding(part.Header.Get("Content-Transfer-Encoding"), part), maxBodyTextSize))
+				}
+				continue
+			}
+			before := b.Len()
+			appendPartText(b, part.Header.Get, part, depth+1)
+			if b.Len() > before {
+				return
+			}
+			continue
+		}
+		appendPartText(b, part.Header.Get, part, depth+1)
+	}
+
+	if html != nil {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(htmlToText(string(html)))
+	}
+}
+
+// decodeTransferEncoding undoes a quoted-printable or base64 transfer encoding
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	case "base64":
+		// The decoder skips the line breaks base64 bodies are wrapped with
+		return base64.NewDecoder(base64.StdEncoding, body)
+	}
+	return body
+}
+
+// htmlToText strips tags, scripts and styles from HTML and collapses whitespace
+func htmlToText(s string) string {
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	s = strings.NewReplacer("&nbsp;", " ", "&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'").Replace(s)
+	return strings.TrimSpace(whitespaceRunPattern.ReplaceAllString(s, " "))
+}
+
+// truncateBodyText cuts s to maxBodyTextSize on a UTF-8 boundary and drops
+// invalid UTF-8 and NUL bytes, which PostgreSQL text columns reject
+func truncateBodyText(s string) string {
+	if len(s) > maxBodyTextSize {
+		s = s[:maxBodyTextSize]
+	}
+	s = strings.ToValidUTF8(s, "")
+	return strings.ReplaceAll(s, "\x00", "")
+}
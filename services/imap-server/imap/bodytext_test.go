@@ -0,0 +1,78 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractBodyText(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "plain",
+			message: "Subject: Hi\r\nContent-Type: text/plain\r\n\r\nLunch at noon?\r\n",
+			want:    "Lunch at noon?",
+		},
+		{
+			name:    "no content type",
+			message: "Subject: Hi\r\n\r\nLunch at noon?",
+			want:    "Lunch at noon?",
+		},
+		{
+			name:    "quoted-printable",
+			message: "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nCaf=C3=A9 au l=\r\nait",
+			want:    "Café au lait",
+		},
+		{
+			name:    "base64",
+			message: "Content-Type: text/plain\r\nContent-Transfer-Encoding: base64\r\n\r\nUXVhcnRlcmx5\r\nIHJlcG9ydA==\r\n",
+			want:    "Quarterly report",
+		},
+		{
+			name: "alternative prefers plain",
+			message: "Content-Type: multipart/alternative; boundary=b1\r\n\r\n" +
+				"--b1\r\nContent-Type: text/plain\r\n\r\nPlain version\r\n" +
+				"--b1\r\nContent-Type: text/html\r\n\r\n<p>HTML version</p>\r\n" +
+				"--b1--\r\n",
+			want: "Plain version",
+		},
+		{
+			name: "html only",
+			message: "Content-Type: multipart/alternative; boundary=b1\r\n\r\n" +
+				"--b1\r\nContent-Type: text/html\r\n\r\n<style>p {}</style><p>Budget &amp; plan</p>\r\n" +
+				"--b1--\r\n",
+			want: "Budget & plan",
+		},
+		{
+			name: "attachments skipped",
+			message: "Content-Type: multipart/mixed; boundary=b1\r\n\r\n" +
+				"--b1\r\nContent-Type: text/plain\r\n\r\nSee attached\r\n" +
+				"--b1\r\nContent-Type: text/plain\r\nContent-Disposition: attachment; filename=notes.txt\r\n\r\nsecret notes\r\n" +
+				"--b1\r\nContent-Type: image/png\r\n\r\nPNG\r\n" +
+				"--b1--\r\n",
+			want: "See attached",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractBodyText([]byte(tt.message)); got != tt.want {
+				t.Errorf("extractBodyText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractBodyTextTruncates(t *testing.T) {
+	message := "Subject: Big\r\n\r\n" + strings.Repeat("é", maxBodyTextSize)
+	got := extractBodyText([]byte(message))
+	if len(got) > maxBodyTextSize {
+		t.Errorf("extractBodyText() length = %d, want at most %d", len(got), maxBodyTextSize)
+	}
+	if !strings.HasSuffix(got, "é") {
+		t.Error("extractBodyText() cut a character in half")
+	}
+}
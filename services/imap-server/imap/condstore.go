@@ -0,0 +1,287 @@
+package imap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// selectParams holds the optional SELECT/EXAMINE parameters (RFC 7162)
+type selectParams struct {
+	CondStore bool
+	QResync   *qresyncParams
+}
+
+// qresyncParams holds the QRESYNC parameter of SELECT/EXAMINE
+type qresyncParams struct {
+	UIDValidity uint32
+	ModSeq      uint64
+	KnownUIDs   string // Optional UID set the client knows about
+}
+
+// fetchModifiers holds the FETCH modifiers defined by RFC 7162
+type fetchModifiers struct {
+	ChangedSince    uint64
+	HasChangedSince bool
+	Vanished        bool
+}
+
+// parseSelectArgs splits SELECT/EXAMINE arguments into the mailbox name and parameters
+// e.g., `INBOX (QRESYNC (67890007 20050715194045000 41,43:211))`
+func parseSelectArgs(args string) (string, *selectParams, error) {
+	args = strings.TrimSpace(args)
+	params := &selectParams{}
+
+	var mailbox, rest string
+	if strings.HasPrefix(args, "\"") {
+		end := strings.Index(args[1:], "\"")
+		if end < 0 {
+			return "", nil, fmt.Errorf("unterminated mailbox name")
+		}
+		mailbox = args[1 : end+1]
+		rest = args[end+2:]
+	} else if i := strings.Index(args, " "); i >= 0 {
+		mailbox = args[:i]
+		rest = args[i:]
+	} else {
+		mailbox = args
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return mailbox, params, nil
+	}
+
+	if !strings.HasPrefix(rest, "(") || matchingParen(rest, 0) != len(rest)-1 {
+		return "", nil, fmt.Errorf("invalid select parameters")
+	}
+	rest = strings.TrimSpace(rest[1 : len(rest)-1])
+
+	for rest != "" {
+		var name string
+		if i := strings.IndexAny(rest, " ("); i >= 0 {
+			name, rest = rest[:i], strings.TrimSpace(rest[i:])
+		} else {
+			name, rest = rest, ""
+		}
+
+		switch strings.ToUpper(name) {
+		case "CONDSTORE":
+			params.CondStore = true
+		case "QRESYNC":
+			if !strings.HasPrefix(rest, "(") {
+				return "", nil, fmt.Errorf("QRESYNC requires parameters")
+			}
+			end := matchingParen(rest, 0)
+			if end < 0 {
+				return "", nil, fmt.Errorf("unbalanced QRESYNC parameters")
+			}
+			q, err := parseQResyncParams(rest[1:end])
+			if err != nil {
+				return "", nil, err
+			}
+			params.QResync = q
+			rest = strings.TrimSpace(rest[end+1:])
+		default:
+			return "", nil, fmt.Errorf("unknown select parameter %s", name)
+		}
+	}
+
+	return mailbox, params, nil
+}
+
+// parseQResyncParams parses "uidvalidity modseq [known-uids [(seq-match-data)]]"
+func parseQResyncParams(s string) (*qresyncParams, error) {
+	// The sequence match data is only an optimization hint, so ignore it
+	if i := strings.Index(s, "("); i >= 0 {
+		s = s[:i]
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid QRESYNC parameters")
+	}
+
+	uidValidity, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil || uidValidity == 0 {
+		return nil, fmt.Errorf("invalid QRESYNC uidvalidity")
+	}
+	modSeq, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil || modSeq == 0 {
+		return nil, fmt.Errorf("invalid QRESYNC modseq")
+	}
+
+	q := &qresyncParams{
+		UIDValidity: uint32(uidValidity),
+		ModSeq:      modSeq,
+	}
+	if len(fields) == 3 {
+		q.KnownUIDs = fields[2]
+	}
+
+	return q, nil
+}
+
+// splitFetchModifiers separates trailing RFC 7162 modifiers from FETCH data items
+// e.g., `(FLAGS) (CHANGEDSINCE 12345)` returns "(FLAGS)" and "(CHANGEDSINCE 12345)"
+func splitFetchModifiers(args string) (string, string) {
+	args = strings.TrimSpace(args)
+	upper := strings.ToUpper(args)
+
+	for _, marker := range []string{" (CHANGEDSINCE", " (VANISHED"} {
+		if i := strings.Index(upper, marker); i >= 0 {
+			return strings.TrimSpace(args[:i]), strings.TrimSpace(args[i:])
+		}
+	}
+
+	return args, ""
+}
+
+// parseFetchModifiers parses a FETCH modifier list such as "(CHANGEDSINCE 12345 VANISHED)"
+func parseFetchModifiers(s string) (*fetchModifiers, error) {
+	mods := &fetchModifiers{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return mods, nil
+	}
+
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid fetch modifiers")
+	}
+
+	fields := strings.Fields(s[1 : len(s)-1])
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "CHANGEDSINCE":
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("CHANGEDSINCE requires a modseq")
+			}
+			modSeq, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CHANGEDSINCE modseq")
+			}
+			mods.ChangedSince = modSeq
+			mods.HasChangedSince = true
+			i++
+		case "VANISHED":
+			mods.Vanished = true
+		default:
+			return nil, fmt.Errorf("unknown fetch modifier %s", fields[i])
+		}
+	}
+
+	if mods.Vanished && !mods.HasChangedSince {
+		return nil, fmt.Errorf("VANISHED requires CHANGEDSINCE")
+	}
+
+	return mods, nil
+}
+
+// uidSetContains reports whether uid is a member of an IMAP UID set
+// "*" is treated as unbounded since the set may refer to expunged UIDs
+func uidSetContains(set string, uid uint32) bool {
+	for _, part := range strings.Split(set, ",") {
+		bounds := strings.SplitN(part, ":", 2)
+		start, ok := parseUIDBound(bounds[0])
+		if !ok {
+			continue
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, ok = parseUIDBound(bounds[1]); !ok {
+				continue
+			}
+		}
+		if start > end {
+			start, end = end, start
+		}
+		if uid >= start && uid <= end {
+			return true
+		}
+	}
+	return false
+}
+
+func parseUIDBound(s string) (uint32, bool) {
+	if s == "*" {
+		return ^uint32(0), true
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open, or -1
+func matchingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// sendVanishedEarlier sends VANISHED (EARLIER) for UIDs expunged after modseq
+// If uidSet is non-empty, only UIDs within it are reported
+func (c *Connection) sendVanishedEarlier(folderID string, modseq uint64, uidSet string) {
+	ctx, cancel := c.getContext()
+	defer cancel()
+
+	expunged, err := c.repo.ListExpungedUIDsSince(ctx, folderID, modseq)
+	if err != nil {
+		c.logger.Warn("Failed to list expunged messages", zap.Error(err))
+		return
+	}
+
+	var uids []uint32
+	for _, uid := range expunged {
+		if uidSet == "" || uidSetContains(uidSet, uid) {
+			uids = append(uids, uid)
+		}
+	}
+
+	if len(uids) > 0 {
+		c.sendUntagged("VANISHED (EARLIER) %s", formatUIDSet(uids))
+	}
+}
+
+// sendQResyncChanges reports expunges and flag changes since the client's last known modseq
+func (c *Connection) sendQResyncChanges(folder *Folder, q *qresyncParams) {
+	// Changes can only be computed against the same UID space
+	if q.UIDValidity != folder.UIDValidity {
+		return
+	}
+
+	c.sendVanishedEarlier(folder.ID, q.ModSeq, q.KnownUIDs)
+
+	ctx, cancel := c.getContext()
+	defer cancel()
+
+	messages, err := c.repo.GetMessages(ctx, folder.ID, 0, 100000)
+	if err != nil {
+		c.logger.Warn("Failed to get messages for QRESYNC", zap.Error(err))
+		return
+	}
+
+	for i, msg := range messages {
+		if msg.ModSeq <= q.ModSeq {
+			continue
+		}
+		if q.KnownUIDs != "" && !uidSetContains(q.KnownUIDs, msg.UID) {
+			continue
+		}
+		c.sendUntagged("%d FETCH (UID %d FLAGS (%s) MODSEQ (%d))",
+			i+1, msg.UID, flagsToString(msg.Flags), msg.ModSeq)
+	}
+}
@@ -0,0 +1,171 @@
+package imap
+
+import (
+	"testing"
+)
+
+func TestParseSelectArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		mailbox   string
+		condstore bool
+		qresync   *qresyncParams
+		wantErr   bool
+	}{
+		{
+			name:    "plain mailbox",
+			input:   "INBOX",
+			mailbox: "INBOX",
+		},
+		{
+			name:    "quoted mailbox with spaces",
+			input:   `"Sent Items"`,
+			mailbox: "Sent Items",
+		},
+		{
+			name:      "condstore",
+			input:     "INBOX (CONDSTORE)",
+			mailbox:   "INBOX",
+			condstore: true,
+		},
+		{
+			name:    "qresync without known uids",
+			input:   "INBOX (QRESYNC (67890007 20050715194045000))",
+			mailbox: "INBOX",
+			qresync: &qresyncParams{UIDValidity: 67890007, ModSeq: 20050715194045000},
+		},
+		{
+			name:    "qresync with known uids and sequence match data",
+			input:   `"Sent Items" (QRESYNC (67890007 90060115194045000 41:211,214:541 (1:20 41:60)))`,
+			mailbox: "Sent Items",
+			qresync: &qresyncParams{UIDValidity: 67890007, ModSeq: 90060115194045000, KnownUIDs: "41:211,214:541"},
+		},
+		{
+			name:    "qresync missing modseq",
+			input:   "INBOX (QRESYNC (67890007))",
+			wantErr: true,
+		},
+		{
+			name:    "unknown parameter",
+			input:   "INBOX (FOO)",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailbox, params, err := parseSelectArgs(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSelectArgs(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if mailbox != tt.mailbox {
+				t.Errorf("mailbox = %q, want %q", mailbox, tt.mailbox)
+			}
+			if params.CondStore != tt.condstore {
+				t.Errorf("CondStore = %v, want %v", params.CondStore, tt.condstore)
+			}
+			if (params.QResync == nil) != (tt.qresync == nil) {
+				t.Fatalf("QResync = %+v, want %+v", params.QResync, tt.qresync)
+			}
+			if tt.qresync != nil && *params.QResync != *tt.qresync {
+				t.Errorf("QResync = %+v, want %+v", *params.QResync, *tt.qresync)
+			}
+		})
+	}
+}
+
+func TestParseFetchModifiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		items    string
+		since    uint64
+		hasSince bool
+		vanished bool
+		wantErr  bool
+	}{
+		{
+			name:  "no modifiers",
+			input: "(FLAGS UID)",
+			items: "(FLAGS UID)",
+		},
+		{
+			name:     "changedsince",
+			input:    "(FLAGS) (CHANGEDSINCE 12345)",
+			items:    "(FLAGS)",
+			since:    12345,
+			hasSince: true,
+		},
+		{
+			name:     "changedsince with vanished",
+			input:    "(FLAGS) (CHANGEDSINCE 12345 VANISHED)",
+			items:    "(FLAGS)",
+			since:    12345,
+			hasSince: true,
+			vanished: true,
+		},
+		{
+			name:     "body section with parentheses",
+			input:    "BODY.PEEK[HEADER.FIELDS (FROM TO)] (changedsince 7)",
+			items:    "BODY.PEEK[HEADER.FIELDS (FROM TO)]",
+			since:    7,
+			hasSince: true,
+		},
+		{
+			name:    "vanished without changedsince",
+			input:   "(FLAGS) (VANISHED)",
+			wantErr: true,
+		},
+		{
+			name:    "invalid modseq",
+			input:   "(FLAGS) (CHANGEDSINCE abc)",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, modifierList := splitFetchModifiers(tt.input)
+			mods, err := parseFetchModifiers(modifierList)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFetchModifiers(%q) error = %v, wantErr %v", modifierList, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if items != tt.items {
+				t.Errorf("items = %q, want %q", items, tt.items)
+			}
+			if mods.ChangedSince != tt.since || mods.HasChangedSince != tt.hasSince || mods.Vanished != tt.vanished {
+				t.Errorf("modifiers = %+v, want since=%d hasSince=%v vanished=%v", *mods, tt.since, tt.hasSince, tt.vanished)
+			}
+		})
+	}
+}
+
+func TestUIDSetContains(t *testing.T) {
+	tests := []struct {
+		set      string
+		uid      uint32
+		expected bool
+	}{
+		{"1:5", 3, true},
+		{"1:5", 6, false},
+		{"1,3,5", 3, true},
+		{"1,3,5", 4, false},
+		{"10:*", 4000000000, true},
+		{"*:10", 9, false},
+		{"41:211,214:541", 213, false},
+		{"41:211,214:541", 300, true},
+	}
+
+	for _, tt := range tests {
+		if got := uidSetContains(tt.set, tt.uid); got != tt.expected {
+			t.Errorf("uidSetContains(%q, %d) = %v, want %v", tt.set, tt.uid, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package imap
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// connLeaseTTL is how long a connection lease survives without a refresh.
+	// Leases held by an instance that dies without releasing them expire
+	// after this.
+	connLeaseTTL = 90 * time.Second
+	// connLeaseRefreshInterval is how often live leases are refreshed
+	connLeaseRefreshInterval = 30 * time.Second
+	// connLeaseTimeout bounds each call to the lease store
+	connLeaseTimeout = 2 * time.Second
+)
+
+// Connection limit kinds, also used as the rejection metric's reason label
+const (
+	connLimitIP   = "ip"
+	connLimitUser = "user"
+)
+
+// LeaseStore counts connection leases per key (allows mocking). A lease is a
+// member under a key that expires unless it is refreshed, so a key's count
+// stays correct across instances even if one of them crashes.
+type LeaseStore interface {
+	// Acquire adds member under key unless key already holds limit live
+	// leases. Acquiring a member that is already held succeeds.
+	Acquire(ctx context.Context, key, member string, limit int, ttl time.Duration) (bool, error)
+	// Release removes member from key
+	Release(ctx context.Context, key, member string) error
+	// Refresh extends the given leases by ttl
+	Refresh(ctx context.Context, leases []ConnLease, ttl time.Duration) error
+}
+
+// ConnLease identifies one connection's lease under a limit key
+type ConnLease struct {
+	Key    string
+	Member string
+}
+
+// heldLease is a lease a connection holds and the store it was taken from
+type heldLease struct {
+	lease ConnLease
+	store LeaseStore
+}
+
+// ConnectionLimiter enforces the per-IP and per-user concurrent connection
+// limits. Leases are kept in a shared store (Redis) so the limits hold across
+// instances; if the store is unreachable the limiter falls back to counting
+// this instance's connections only.
+type ConnectionLimiter struct {
+	store      LeaseStore
+	local      LeaseStore
+	instanceID string
+	limits     map[string]int
+	logger     *zap.Logger
+
+	mu   sync.Mutex
+	held map[string][]heldLease // connection ID -> leases
+}
+
+// NewConnectionLimiter creates a limiter. A limit of zero or less disables
+// that check. Leases are kept in memory until SetStore is called.
+func NewConnectionLimiter(perIP, perUser int, instanceID string, logger *zap.Logger) *ConnectionLimiter {
+	local := newMemoryLeaseStore()
+	return &ConnectionLimiter{
+		store:      local,
+		local:      local,
+		instanceID: instanceID,
+		limits: map[string]int{
+			connLimitIP:   perIP,
+			connLimitUser: perUser,
+		},
+		logger: logger,
+		held:   make(map[string][]heldLease),
+	}
+}
+
+// SetStore shares leases through store instead of keeping them in memory
+func (l *ConnectionLimiter) SetStore(store LeaseStore) {
+	l.store = store
+}
+
+// AcquireIP takes a lease for a connection from the client address addr
+func (l *ConnectionLimiter) AcquireIP(connID, addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return l.acquire(connLimitIP, host, connID)
+}
+
+// AcquireUser takes a lease for a connection authenticated as userID
+func (l *ConnectionLimiter) AcquireUser(connID, userID string) bool {
+	return l.acquire(connLimitUser, userID, connID)
+}
+
+func (l *ConnectionLimiter) acquire(kind, value, connID string) bool {
+	limit := l.limits[kind]
+	if limit <= 0 {
+		return true
+	}
+
+	lease := ConnLease{
+		Key:    "imap:conn_limit:" + kind + ":" + value,
+		Member: l.instanceID + ":" + connID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connLeaseTimeout)
+	defer cancel()
+
+	store := l.store
+	ok, err := store.Acquire(ctx, lease.Key, lease.Member, limit, connLeaseTTL)
+	if err != nil && store != l.local {
+		l.logger.Warn("Connection lease store unavailable, limiting this instance only",
+			zap.String("kind", kind),
+			zap.Error(err),
+		)
+		store = l.local
+		ok, err = store.Acquire(ctx, lease.Key, lease.Member, limit, connLeaseTTL)
+	}
+	if err != nil {
+		// Never lock users out because the counter is broken
+		l.logger.Error("Failed to acquire connection lease", zap.String("kind", kind), zap.Error(err))
+		return true
+	}
+	if !ok {
+		connectionsRejected.WithLabelValues(kind).Inc()
+		return false
+	}
+
+	l.mu.Lock()
+	l.held[connID] = append(l.held[connID], heldLease{lease: lease, store: store})
+	l.mu.Unlock()
+	return true
+}
+
+// Release drops every lease held by a connection. It is called once the
+// connection is gone, however it ended; a lease whose release fails expires
+// on its own because it is no longer refreshed.
+func (l *ConnectionLimiter) Release(connID string) {
+	l.mu.Lock()
+	leases := l.held[connID]
+	delete(l.held, connID)
+	l.mu.Unlock()
+
+	if len(leases) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connLeaseTimeout)
+	defer cancel()
+
+	for _, h := range leases {
+		if err := h.store.Release(ctx, h.lease.Key, h.lease.Member); err != nil {
+			l.logger.Warn("Failed to release connection lease",
+				zap.String("key", h.lease.Key),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// Run refreshes held leases until stop is closed
+func (l *ConnectionLimiter) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(connLeaseRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.refresh()
+		}
+	}
+}
+
+func (l *ConnectionLimiter) refresh() {
+	byStore := make(map[LeaseStore][]ConnLease)
+	l.mu.Lock()
+	for _, leases := range l.held {
+		for _, h := range leases {
+			byStore[h.store] = append(byStore[h.store], h.lease)
+		}
+	}
+	l.mu.Unlock()
+
+	for store, leases := range byStore {
+		ctx, cancel := context.WithTimeout(context.Background(), connLeaseTimeout)
+		if err := store.Refresh(ctx, leases, connLeaseTTL); err != nil {
+			l.logger.Warn("Failed to refresh connection leases",
+				zap.Int("count", len(leases)),
+				zap.Error(err),
+			)
+		}
+		cancel()
+	}
+}
+
+// memoryLeaseStore counts leases for this instance only. Leases are released
+// explicitly, so they don't expire.
+type memoryLeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]map[string]struct{}
+}
+
+func newMemoryLeaseStore() *memoryLeaseStore {
+	return &memoryLeaseStore{leases: make(map[string]map[string]struct{})}
+}
+
+func (m *memoryLeaseStore) Acquire(ctx context.Context, key, member string, limit int, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := m.leases[key]
+	if _, ok := members[member]; ok {
+		return true, nil
+	}
+	if len(members) >= limit {
+		return false, nil
+	}
+	if members == nil {
+		members = make(map[string]struct{})
+		m.leases[key] = members
+	}
+	members[member] = struct{}{}
+	return true, nil
+}
+
+func (m *memoryLeaseStore) Release(ctx context.Context, key, member string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.leases[key], member)
+	if len(m.leases[key]) == 0 {
+		delete(m.leases, key)
+	}
+	return nil
+}
+
+func (m *memoryLeaseStore) Refresh(ctx context.Context, leases []ConnLease, ttl time.Duration) error {
+	return nil
+}
+
+// acquireUserLease takes a per-user lease for the connection, answering the
+// command with NO [LIMIT] when the user already has too many connections.
+// An idling connection keeps its lease until it closes.
+func (c *Connection) acquireUserLease(tag, userID string) bool {
+	if c.server == nil || c.server.connLimiter.AcquireUser(c.id, userID) {
+		return true
+	}
+
+	c.logger.Warn("Per-user connection limit reached", zap.String("user_id", userID))
+	c.sendTagged(tag, "NO [LIMIT] Too many connections for this user")
+	return false
+}
@@ -0,0 +1,84 @@
+package imap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// failingLeaseStore simulates an unreachable Redis
+type failingLeaseStore struct{}
+
+func (failingLeaseStore) Acquire(ctx context.Context, key, member string, limit int, ttl time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func (failingLeaseStore) Release(ctx context.Context, key, member string) error {
+	return errors.New("connection refused")
+}
+
+func (failingLeaseStore) Refresh(ctx context.Context, leases []ConnLease, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func TestConnectionLimiter_PerUser(t *testing.T) {
+	limiter := NewConnectionLimiter(0, 2, "imap-test", zap.NewNop())
+
+	if !limiter.AcquireUser("conn-1", "user-1") || !limiter.AcquireUser("conn-2", "user-1") {
+		t.Fatal("connections under the limit were rejected")
+	}
+	if limiter.AcquireUser("conn-3", "user-1") {
+		t.Fatal("third connection for user-1 should be rejected")
+	}
+	if !limiter.AcquireUser("conn-3", "user-2") {
+		t.Fatal("another user's connection should not be limited")
+	}
+	if !limiter.AcquireUser("conn-1", "user-1") {
+		t.Fatal("re-acquiring a held lease should succeed")
+	}
+
+	limiter.Release("conn-1")
+	if !limiter.AcquireUser("conn-4", "user-1") {
+		t.Fatal("released slot was not freed")
+	}
+}
+
+func TestConnectionLimiter_PerIP(t *testing.T) {
+	limiter := NewConnectionLimiter(1, 0, "imap-test", zap.NewNop())
+
+	if !limiter.AcquireIP("conn-1", "192.0.2.1:50000") {
+		t.Fatal("first connection was rejected")
+	}
+	if limiter.AcquireIP("conn-2", "192.0.2.1:50001") {
+		t.Fatal("second connection from the same address should be rejected")
+	}
+	if !limiter.AcquireIP("conn-3", "[2001:db8::1]:50000") {
+		t.Fatal("another address should not be limited")
+	}
+	if !limiter.AcquireUser("conn-1", "user-1") {
+		t.Fatal("per-user limit of 0 should be disabled")
+	}
+}
+
+func TestConnectionLimiter_StoreUnavailable(t *testing.T) {
+	limiter := NewConnectionLimiter(0, 3, "imap-test", zap.NewNop())
+	limiter.SetStore(failingLeaseStore{})
+
+	for i := 0; i < 3; i++ {
+		if !limiter.AcquireUser(fmt.Sprintf("conn-%d", i), "user-1") {
+			t.Fatalf("connection %d was rejected", i)
+		}
+	}
+	if limiter.AcquireUser("conn-3", "user-1") {
+		t.Fatal("limit should still hold on this instance while the store is down")
+	}
+
+	limiter.Release("conn-0")
+	if !limiter.AcquireUser("conn-3", "user-1") {
+		t.Fatal("release should free the local lease")
+	}
+}
@@ -39,6 +39,17 @@ func (c *Connection) handleCopy(tag, args string, uid bool, isMove bool) error {
 		return nil
 	}
 
+	// All Mail shows the messages of the other folders: copying into it would
+	// duplicate them, and moving out of it has no folder to remove them from
+	if isAllMail(destFolder) {
+		c.sendTagged(tag, "NO [CANNOT] Messages can't be copied into All Mail")
+		return nil
+	}
+	if isMove && isAllMail(c.ctx.ActiveFolder) {
+		c.sendTagged(tag, "NO [CANNOT] Messages can't be moved out of All Mail; move them from their folder")
+		return nil
+	}
+
 	// Check if this is a cross-domain copy
 	crossDomain := c.ctx.ActiveMailbox.ID != destMailbox.ID
 
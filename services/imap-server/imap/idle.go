@@ -1,12 +1,15 @@
 package imap
 
 import (
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// handleIdle handles the IDLE command (RFC 2177)
+// handleIdle handles the IDLE command (RFC 2177). While idling, changes to the
+// selected folder made by other sessions, or by delivery on any instance via
+// Redis, are pushed as untagged EXISTS, RECENT and EXPUNGE responses.
 func (c *Connection) handleIdle(tag string) error {
 	if !c.requireSelected(tag) {
 		return nil
@@ -17,43 +20,47 @@ func (c *Connection) handleIdle(tag string) error {
 
 	c.logger.Info("Entering IDLE mode",
 		zap.String("mailbox_id", c.ctx.ActiveMailbox.ID),
+		zap.String("folder", c.ctx.ActiveFolder.FullPath),
 	)
 
-	// Subscribe to notifications for all user's mailboxes
-	// In addition to the active mailbox, we want notifications for all mailboxes
-	channels := make(map[string]<-chan IdleNotification)
+	// Report anything that changed since the last command
+	c.sendPendingUpdates()
 
-	for _, mb := range c.ctx.Mailboxes {
-		channels[mb.ID] = c.notifyHub.Subscribe(mb.ID, c.id)
-	}
-	for _, shared := range c.ctx.SharedMailboxes {
-		channels[shared.ID] = c.notifyHub.Subscribe(shared.ID, c.id)
-	}
-
-	// Set up timeout
-	timeout := time.NewTimer(29 * time.Minute) // RFC recommends renewing before 30 min
+	// Clients must re-issue IDLE before the timeout; the read deadline lets the
+	// DONE reader give up at the same time
+	timeout := time.NewTimer(c.config.IMAP.IdleTimeout)
 	defer timeout.Stop()
+	c.conn.SetReadDeadline(time.Now().Add(c.config.IMAP.IdleTimeout + time.Minute))
 
 	// IDLE loop
-	done := make(chan struct{})
+	done := make(chan error, 1)
 	go c.waitForDone(done)
 
+	notifications := c.idleChan
 	for {
 		select {
-		case notification := <-c.idleChan:
-			// Notification for active mailbox
-			if notification.Type != "" {
-				c.sendIdleNotification(&notification)
+		case notification, ok := <-notifications:
+			if !ok {
+				// Subscription closed; keep idling until DONE
+				notifications = nil
+				continue
 			}
+			c.sendIdleNotification(&notification)
 
 		case <-timeout.C:
-			// Send BYE due to timeout
 			c.logger.Info("IDLE timeout")
-			c.sendUntagged("BYE IDLE timeout")
-			return nil
+			c.sendUntagged("BYE IDLE timeout, please re-issue IDLE")
+			return errConnectionClosed
+
+		case <-c.shutdownChan:
+			c.sendUntagged("BYE Server shutting down")
+			return errConnectionClosed
 
-		case <-done:
-			// Client sent DONE
+		case err := <-done:
+			if err != nil {
+				c.logger.Debug("Connection closed during IDLE", zap.Error(err))
+				return errConnectionClosed
+			}
 			c.logger.Info("IDLE terminated by client")
 			c.sendTagged(tag, "OK IDLE terminated")
 			return nil
@@ -61,35 +68,39 @@ func (c *Connection) handleIdle(tag string) error {
 	}
 }
 
-// waitForDone waits for DONE command from client
-func (c *Connection) waitForDone(done chan<- struct{}) {
+// waitForDone waits for DONE from the client. Anything else sent while idling
+// is ignored. A read error is passed on so the connection can be closed.
+func (c *Connection) waitForDone(done chan<- error) {
 	for {
 		line, err := c.reader.ReadString('\n')
 		if err != nil {
-			close(done)
+			done <- err
 			return
 		}
 
-		if line == "DONE\r\n" || line == "DONE\n" || line == "done\r\n" || line == "done\n" {
-			close(done)
+		if strings.EqualFold(strings.TrimRight(line, "\r\n"), "DONE") {
+			done <- nil
 			return
 		}
 	}
 }
 
-// sendIdleNotification sends a notification during IDLE
+// sendIdleNotification sends a notification during IDLE. Notifications for
+// folders other than the selected one are ignored.
 func (c *Connection) sendIdleNotification(notification *IdleNotification) {
-	switch notification.Type {
-	case "EXISTS":
-		// EXISTS notification - send new message count
-		c.sendUntagged("%d EXISTS", c.ctx.ActiveFolder.MessageCount+1)
+	if notification.FolderPath != "" && !strings.EqualFold(notification.FolderPath, c.ctx.ActiveFolder.FullPath) {
+		return
+	}
 
-	case "RECENT":
-		c.sendUntagged("%d RECENT", 1)
+	switch notification.Type {
+	case "EXISTS", "RECENT":
+		// Counts are re-read so deliveries from any source report the real total
+		c.sendPendingUpdates()
 
 	case "EXPUNGE":
-		if notification.SeqNum > 0 {
+		if notification.SeqNum > 0 && int(notification.SeqNum) <= c.ctx.ActiveFolder.MessageCount {
 			c.sendUntagged("%d EXPUNGE", notification.SeqNum)
+			c.ctx.ActiveFolder.MessageCount--
 		}
 
 	case "FLAGS":
@@ -103,10 +114,6 @@ func (c *Connection) sendIdleNotification(notification *IdleNotification) {
 			}
 			c.sendUntagged("%d FETCH (FLAGS (%s))", notification.SeqNum, flagStr)
 		}
-
-	case "STATUS":
-		// Status update for non-selected mailbox
-		c.sendUntagged("STATUS %s (MESSAGES %d)", notification.FolderPath, c.ctx.ActiveFolder.MessageCount)
 	}
 }
 
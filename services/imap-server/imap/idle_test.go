@@ -0,0 +1,101 @@
+package imap
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fakeRedisClient struct {
+	mu        sync.Mutex
+	published map[string][]byte
+}
+
+func (f *fakeRedisClient) Publish(ctx context.Context, channel string, message interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published[channel] = message.([]byte)
+	return nil
+}
+
+func (f *fakeRedisClient) Subscribe(ctx context.Context, channels ...string) (<-chan string, error) {
+	return make(chan string), nil
+}
+
+func (f *fakeRedisClient) get(channel string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.published[channel]
+}
+
+func receiveNotification(t *testing.T, ch <-chan IdleNotification) (IdleNotification, bool) {
+	t.Helper()
+	select {
+	case n := <-ch:
+		return n, true
+	case <-time.After(100 * time.Millisecond):
+		return IdleNotification{}, false
+	}
+}
+
+func TestNotifyHub_NotifyOthersSkipsOrigin(t *testing.T) {
+	hub := NewNotifyHub(zap.NewNop())
+	origin := hub.Subscribe("mailbox-1", "conn-1")
+	other := hub.Subscribe("mailbox-1", "conn-2")
+	defer hub.UnsubscribeAll("conn-1")
+	defer hub.UnsubscribeAll("conn-2")
+
+	hub.NotifyOthers("mailbox-1", "conn-1", IdleNotification{Type: "EXPUNGE", SeqNum: 3})
+
+	if n, ok := receiveNotification(t, other); !ok || n.SeqNum != 3 {
+		t.Errorf("other connection got %+v, %v; want EXPUNGE 3", n, ok)
+	}
+	if n, ok := receiveNotification(t, origin); ok {
+		t.Errorf("originating connection got %+v, want nothing", n)
+	}
+}
+
+func TestNotifyHub_RedisNotifications(t *testing.T) {
+	redis := &fakeRedisClient{published: make(map[string][]byte)}
+	hub := NewNotifyHubWithConfig(zap.NewNop(), DefaultNotifyHubConfig(), redis)
+	ch := hub.Subscribe("mailbox-1", "conn-1")
+	defer hub.UnsubscribeAll("conn-1")
+
+	t.Run("published with instance ID", func(t *testing.T) {
+		hub.publishToRedis("mailbox-1", IdleNotification{Type: "EXISTS", FolderPath: "INBOX"})
+
+		var msg redisNotification
+		if err := json.Unmarshal(redis.get("imap:notify:mailbox-1"), &msg); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if msg.InstanceID != hub.instanceID || msg.MailboxID != "mailbox-1" || msg.Type != "EXISTS" {
+			t.Errorf("Published %+v", msg)
+		}
+	})
+
+	t.Run("own notifications are skipped", func(t *testing.T) {
+		hub.handleRedisNotification(string(redis.get("imap:notify:mailbox-1")))
+		if n, ok := receiveNotification(t, ch); ok {
+			t.Errorf("Got %+v, want nothing", n)
+		}
+	})
+
+	t.Run("delivery from SMTP is delivered locally", func(t *testing.T) {
+		hub.handleRedisNotification(`{"type":"EXISTS","mailbox_id":"mailbox-1","folder_path":"INBOX"}`)
+		n, ok := receiveNotification(t, ch)
+		if !ok || n.Type != "EXISTS" || n.FolderPath != "INBOX" {
+			t.Errorf("Got %+v, %v; want EXISTS for INBOX", n, ok)
+		}
+	})
+
+	t.Run("malformed payload is ignored", func(t *testing.T) {
+		hub.handleRedisNotification(`not json`)
+		if n, ok := receiveNotification(t, ch); ok {
+			t.Errorf("Got %+v, want nothing", n)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package imap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// systemFlags are the flags defined by RFC 3501, in the order FLAGS lists them
+var systemFlags = []MessageFlag{FlagSeen, FlagAnswered, FlagFlagged, FlagDeleted, FlagDraft}
+
+// isAtom reports whether s is a non-empty IMAP atom: printable ASCII without
+// atom-specials ( ) { SP % * " \ ]
+func isAtom(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= 0x20 || c >= 0x7F || strings.IndexByte(`(){%*"\]`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseStoreFlags validates the flags of a STORE or APPEND flag list. System
+// flags are returned in their canonical case; anything else must be a keyword
+// atom. \Recent is set by the server only, and other backslash flags are not
+// defined, so both are rejected.
+func parseStoreFlags(flags []string) ([]MessageFlag, error) {
+	result := make([]MessageFlag, 0, len(flags))
+	for _, f := range flags {
+		if strings.HasPrefix(f, `\`) {
+			flag, ok := systemFlag(f)
+			if !ok {
+				return nil, fmt.Errorf("flag %s can't be stored", f)
+			}
+			result = append(result, flag)
+			continue
+		}
+		if !isAtom(f) {
+			return nil, fmt.Errorf("invalid keyword %q", f)
+		}
+		result = append(result, MessageFlag(f))
+	}
+	return result, nil
+}
+
+// systemFlag returns the storable system flag named by f, in any case
+func systemFlag(f string) (MessageFlag, bool) {
+	for _, flag := range systemFlags {
+		if strings.EqualFold(f, string(flag)) {
+			return flag, true
+		}
+	}
+	return "", false
+}
+
+// mailboxFlags formats the FLAGS and PERMANENTFLAGS lists of a folder: the
+// system flags and the keywords in use. \* is added to PERMANENTFLAGS when
+// new keywords can be stored.
+func mailboxFlags(keywords []string, writable bool) (flags, permFlags string) {
+	parts := make([]string, 0, len(systemFlags)+len(keywords)+1)
+	for _, f := range systemFlags {
+		parts = append(parts, string(f))
+	}
+	parts = append(parts, keywords...)
+	flags = strings.Join(parts, " ")
+	if writable {
+		parts = append(parts, `\*`)
+	}
+	return flags, strings.Join(parts, " ")
+}
+
+// isAllMail reports whether f is the virtual All Mail folder
+func isAllMail(f *Folder) bool {
+	return f.SpecialUse != nil && *f.SpecialUse == SpecialUseAll
+}
@@ -0,0 +1,88 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStoreFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []string
+		want    []MessageFlag
+		wantErr bool
+	}{
+		{"system flags canonicalized", []string{`\seen`, `\FLAGGED`}, []MessageFlag{FlagSeen, FlagFlagged}, false},
+		{"keywords", []string{"$Label1", "work", "Project-X"}, []MessageFlag{"$Label1", "work", "Project-X"}, false},
+		{"recent is server-set", []string{`\Recent`}, nil, true},
+		{"unknown system flag", []string{`\Important`}, nil, true},
+		{"wildcard", []string{"to*do"}, nil, true},
+		{"percent", []string{"50%"}, nil, true},
+		{"bracket", []string{"tag]"}, nil, true},
+		{"brace", []string{"a{1}"}, nil, true},
+		{"quote", []string{`say"hi"`}, nil, true},
+		{"control character", []string{"a\x01b"}, nil, true},
+		{"non-ascii", []string{"étiquette"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStoreFlags(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStoreFlags(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStoreFlags(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyFlagOperationKeywords(t *testing.T) {
+	c := &Connection{}
+	current := []MessageFlag{FlagSeen, "Work"}
+
+	got := c.applyFlagOperation(current, []MessageFlag{"work", "Urgent"}, "add")
+	if want := []MessageFlag{FlagSeen, "Work", "Urgent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("add = %v, want %v", got, want)
+	}
+
+	got = c.applyFlagOperation(current, []MessageFlag{"WORK"}, "remove")
+	if want := []MessageFlag{FlagSeen}; !reflect.DeepEqual(got, want) {
+		t.Errorf("remove = %v, want %v", got, want)
+	}
+
+	got = c.applyFlagOperation(current, []MessageFlag{"Home", "home"}, "replace")
+	if want := []MessageFlag{"Home"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replace = %v, want %v", got, want)
+	}
+}
+
+func TestSearchKeyword(t *testing.T) {
+	msg := &Message{UID: 1, Flags: []MessageFlag{FlagSeen, "Receipts"}}
+
+	if !matchesSearchCriteria(msg, 1, parseSearchCriteria("KEYWORD receipts")) {
+		t.Error("KEYWORD receipts should match")
+	}
+	if matchesSearchCriteria(msg, 1, parseSearchCriteria("UNKEYWORD Receipts")) {
+		t.Error("UNKEYWORD Receipts should not match")
+	}
+	if !matchesSearchCriteria(msg, 1, parseSearchCriteria("NOT KEYWORD Travel SEEN")) {
+		t.Error("NOT KEYWORD Travel SEEN should match")
+	}
+}
+
+func TestMailboxFlags(t *testing.T) {
+	flags, permFlags := mailboxFlags([]string{"Receipts", "Travel"}, true)
+	if want := `\Seen \Answered \Flagged \Deleted \Draft Receipts Travel`; flags != want {
+		t.Errorf("flags = %q, want %q", flags, want)
+	}
+	if want := `\Seen \Answered \Flagged \Deleted \Draft Receipts Travel \*`; permFlags != want {
+		t.Errorf("permFlags = %q, want %q", permFlags, want)
+	}
+
+	_, permFlags = mailboxFlags(nil, false)
+	if want := `\Seen \Answered \Flagged \Deleted \Draft`; permFlags != want {
+		t.Errorf("read-only permFlags = %q, want %q", permFlags, want)
+	}
+}
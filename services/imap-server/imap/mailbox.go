@@ -1,6 +1,7 @@
 package imap
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -15,12 +16,25 @@ func (c *Connection) handleSelect(tag, args string, readOnly bool) error {
 		return nil
 	}
 
-	mailboxName := strings.Trim(args, "\"")
+	mailboxName, params, err := parseSelectArgs(args)
+	if err != nil {
+		c.sendTagged(tag, "BAD %s", err.Error())
+		return nil
+	}
 	if mailboxName == "" {
 		c.sendTagged(tag, "BAD Missing mailbox name")
 		return nil
 	}
 
+	// QRESYNC must be enabled before it can be used (RFC 7162 Section 3.2.5)
+	if params.QResync != nil && !c.ctx.QRESYNCEnabled {
+		c.sendTagged(tag, "BAD QRESYNC is not enabled")
+		return nil
+	}
+	if params.CondStore && c.config.IMAP.EnableCONDSTORE {
+		c.ctx.CONDSTOREEnabled = true
+	}
+
 	// Parse mailbox path to get domain context and folder
 	mailbox, folderPath, err := c.parseMailboxPath(mailboxName)
 	if err != nil {
@@ -43,6 +57,11 @@ func (c *Connection) handleSelect(tag, args string, readOnly bool) error {
 		return nil
 	}
 
+	// With QRESYNC the client must be told when the previous mailbox is closed
+	if c.ctx.QRESYNCEnabled && c.ctx.ActiveFolder != nil {
+		c.sendUntagged("OK [CLOSED] Previous mailbox closed")
+	}
+
 	// Update context
 	c.ctx.ActiveMailbox = mailbox
 	c.ctx.ActiveFolder = folder
@@ -53,8 +72,14 @@ func (c *Connection) handleSelect(tag, args string, readOnly bool) error {
 	c.notifyHub.UnsubscribeAll(c.id)
 	c.idleChan = c.notifyHub.Subscribe(mailbox.ID, c.id)
 
+	keywords, err := c.repo.ListFolderKeywords(ctx, folder.ID)
+	if err != nil {
+		c.logger.Warn("Failed to list folder keywords", zap.String("folder_id", folder.ID), zap.Error(err))
+	}
+	flags, permFlags := mailboxFlags(keywords, !readOnly)
+
 	// Send response
-	c.sendUntagged("FLAGS (\\Seen \\Answered \\Flagged \\Deleted \\Draft)")
+	c.sendUntagged("FLAGS (%s)", flags)
 	c.sendUntagged("%d EXISTS", folder.MessageCount)
 	c.sendUntagged("%d RECENT", folder.RecentCount)
 
@@ -67,14 +92,16 @@ func (c *Connection) handleSelect(tag, args string, readOnly bool) error {
 
 	if folder.HighestModSeq > 0 {
 		c.sendUntagged("OK [HIGHESTMODSEQ %d] Highest modseq", folder.HighestModSeq)
+	} else if isAllMail(folder) {
+		c.sendUntagged("OK [NOMODSEQ] Sorry, this mailbox format doesn't support modsequences")
 	}
 
-	permFlags := "\\Seen \\Answered \\Flagged \\Deleted \\Draft"
-	if !readOnly {
-		permFlags += " \\*"
-	}
 	c.sendUntagged("OK [PERMANENTFLAGS (%s)] Limited", permFlags)
 
+	if params.QResync != nil && folder.HighestModSeq > 0 {
+		c.sendQResyncChanges(folder, params.QResync)
+	}
+
 	command := "SELECT"
 	accessType := "READ-WRITE"
 	if readOnly {
@@ -110,6 +137,8 @@ func (c *Connection) handleCreate(tag, args string) error {
 		c.sendTagged(tag, "NO %s", err.Error())
 		return nil
 	}
+	// A trailing delimiter only says the client means to create children
+	folderPath = canonicalFolderPath(folderPath)
 
 	ctx, cancel := c.getContext()
 	defer cancel()
@@ -117,41 +146,19 @@ func (c *Connection) handleCreate(tag, args string) error {
 	// Check if folder already exists
 	existing, _ := c.repo.GetFolderByPath(ctx, mailbox.ID, folderPath)
 	if existing != nil {
-		c.sendTagged(tag, "NO Mailbox already exists")
+		c.sendTagged(tag, "NO [ALREADYEXISTS] Mailbox already exists")
 		return nil
 	}
 
-	// Extract folder name and parent
-	parts := strings.Split(folderPath, "/")
-	folderName := parts[len(parts)-1]
-
-	var parentID *string
-	if len(parts) > 1 {
-		parentPath := strings.Join(parts[:len(parts)-1], "/")
-		parent, _ := c.repo.GetFolderByPath(ctx, mailbox.ID, parentPath)
-		if parent != nil {
-			parentID = &parent.ID
-		}
-	}
-
-	// Create folder
-	folder := &Folder{
-		ID:            uuid.New().String(),
-		MailboxID:     mailbox.ID,
-		Name:          folderName,
-		FullPath:      folderPath,
-		ParentID:      parentID,
-		Delimiter:     "/",
-		UIDValidity:   uint32(time.Now().Unix()),
-		UIDNext:       1,
-		HighestModSeq: 1,
-		Subscribed:    true,
-		Selectable:    true,
-		Attributes:    []string{"\\HasNoChildren"},
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	// Superior folders are created as needed (RFC 3501 section 6.3.3)
+	parentID, err := c.ensureParentFolder(ctx, mailbox, folderPath)
+	if err != nil {
+		c.logger.Error("Failed to create parent folder", zap.Error(err))
+		c.sendTagged(tag, "NO Cannot create mailbox")
+		return nil
 	}
 
+	folder := newFolder(mailbox.ID, folderPath, parentID)
 	if err := c.repo.CreateFolder(ctx, folder); err != nil {
 		c.logger.Error("Failed to create folder", zap.Error(err))
 		c.sendTagged(tag, "NO Cannot create mailbox")
@@ -167,6 +174,63 @@ func (c *Connection) handleCreate(tag, args string) error {
 	return nil
 }
 
+// ensureParentFolder returns the ID of the parent of the folder at path,
+// creating it and any folders above it that don't exist yet. A top-level
+// folder has no parent.
+func (c *Connection) ensureParentFolder(ctx context.Context, mailbox *Mailbox, path string) (*string, error) {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return nil, nil
+	}
+	parentPath := path[:i]
+
+	if parent, err := c.repo.GetFolderByPath(ctx, mailbox.ID, parentPath); err == nil {
+		return &parent.ID, nil
+	}
+
+	grandparentID, err := c.ensureParentFolder(ctx, mailbox, parentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := newFolder(mailbox.ID, parentPath, grandparentID)
+	if err := c.repo.CreateFolder(ctx, parent); err != nil {
+		return nil, err
+	}
+	return &parent.ID, nil
+}
+
+// newFolder returns a new, empty folder at path
+func newFolder(mailboxID, path string, parentID *string) *Folder {
+	now := time.Now()
+	return &Folder{
+		ID:            uuid.New().String(),
+		MailboxID:     mailboxID,
+		Name:          path[strings.LastIndex(path, "/")+1:],
+		FullPath:      path,
+		ParentID:      parentID,
+		Delimiter:     "/",
+		UIDValidity:   uint32(now.Unix()),
+		UIDNext:       1,
+		HighestModSeq: 1,
+		Subscribed:    true,
+		Selectable:    true,
+		Attributes:    []string{},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// canonicalFolderPath returns a folder path as it is stored: INBOX, which is
+// case-insensitive, in upper case, and without a trailing delimiter
+func canonicalFolderPath(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	if len(path) >= 5 && strings.EqualFold(path[:5], "INBOX") && (len(path) == 5 || path[5] == '/') {
+		path = "INBOX" + path[5:]
+	}
+	return path
+}
+
 // handleDelete handles the DELETE command
 func (c *Connection) handleDelete(tag, args string) error {
 	if !c.requireAuth(tag) {
@@ -190,23 +254,43 @@ func (c *Connection) handleDelete(tag, args string) error {
 		c.sendTagged(tag, "NO %s", err.Error())
 		return nil
 	}
+	folderPath = canonicalFolderPath(folderPath)
 
 	ctx, cancel := c.getContext()
 	defer cancel()
 
 	folder, err := c.repo.GetFolderByPath(ctx, mailbox.ID, folderPath)
 	if err != nil {
-		c.sendTagged(tag, "NO Mailbox does not exist")
+		c.sendTagged(tag, "NO [NONEXISTENT] Mailbox does not exist")
 		return nil
 	}
 
-	// Check for special-use folders
+	// Special-use folders (RFC 6154) are where clients file sent, deleted and
+	// junk mail, so they stay
 	if folder.SpecialUse != nil {
-		c.sendTagged(tag, "NO Cannot delete special-use mailbox")
+		c.sendTagged(tag, "NO [CANNOT] Cannot delete special-use mailbox")
 		return nil
 	}
 
-	if err := c.repo.DeleteFolder(ctx, folder.ID); err != nil {
+	hasChildren, err := c.repo.HasChildFolders(ctx, folder.ID)
+	if err != nil {
+		c.logger.Error("Failed to check child folders", zap.Error(err))
+		c.sendTagged(tag, "NO Cannot delete mailbox")
+		return nil
+	}
+
+	// A folder with children is emptied and kept as \Noselect, as their
+	// parent; deleting it again is refused (RFC 3501 section 6.3.4)
+	if hasChildren {
+		if !folder.Selectable {
+			c.sendTagged(tag, "NO [HASCHILDREN] Mailbox has child mailboxes")
+			return nil
+		}
+		err = c.repo.ClearFolder(ctx, folder.ID)
+	} else {
+		err = c.repo.DeleteFolder(ctx, folder.ID)
+	}
+	if err != nil {
 		c.logger.Error("Failed to delete folder", zap.Error(err))
 		c.sendTagged(tag, "NO Cannot delete mailbox")
 		return nil
@@ -216,7 +300,9 @@ func (c *Connection) handleDelete(tag, args string) error {
 	return nil
 }
 
-// handleRename handles the RENAME command
+// handleRename handles the RENAME command. Messages and child folders move
+// with the folder; renaming INBOX moves its messages to a new folder and
+// leaves INBOX empty (RFC 3501 section 6.3.5).
 func (c *Connection) handleRename(tag, args string) error {
 	if !c.requireAuth(tag) {
 		return nil
@@ -243,67 +329,110 @@ func (c *Connection) handleRename(tag, args string) error {
 		return nil
 	}
 
-	_, newPath, err := c.parseMailboxPath(newName)
+	newMailbox, newPath, err := c.parseMailboxPath(newName)
 	if err != nil {
 		c.sendTagged(tag, "NO %s", err.Error())
 		return nil
 	}
 
+	oldPath = canonicalFolderPath(oldPath)
+	newPath = canonicalFolderPath(newPath)
+
+	if newMailbox.ID != mailbox.ID {
+		c.sendTagged(tag, "NO [CANNOT] Cannot move a mailbox to another account")
+		return nil
+	}
+	if newPath == "INBOX" || newPath == oldPath {
+		c.sendTagged(tag, "NO [ALREADYEXISTS] Mailbox already exists")
+		return nil
+	}
+	if strings.HasPrefix(newPath, oldPath+"/") && oldPath != "INBOX" {
+		c.sendTagged(tag, "NO [CANNOT] Cannot move a mailbox beneath itself")
+		return nil
+	}
+
 	ctx, cancel := c.getContext()
 	defer cancel()
 
 	folder, err := c.repo.GetFolderByPath(ctx, mailbox.ID, oldPath)
 	if err != nil {
-		c.sendTagged(tag, "NO Mailbox does not exist")
+		c.sendTagged(tag, "NO [NONEXISTENT] Mailbox does not exist")
+		return nil
+	}
+
+	if existing, _ := c.repo.GetFolderByPath(ctx, mailbox.ID, newPath); existing != nil {
+		c.sendTagged(tag, "NO [ALREADYEXISTS] Mailbox already exists")
 		return nil
 	}
 
-	// Extract new folder name
-	nameParts := strings.Split(newPath, "/")
-	newFolderName := nameParts[len(nameParts)-1]
+	parentID, err := c.ensureParentFolder(ctx, mailbox, newPath)
+	if err != nil {
+		c.logger.Error("Failed to create parent folder", zap.Error(err))
+		c.sendTagged(tag, "NO Cannot rename mailbox")
+		return nil
+	}
 
-	if err := c.repo.RenameFolder(ctx, folder.ID, newFolderName, newPath); err != nil {
+	if oldPath == "INBOX" {
+		err = c.repo.RenameInbox(ctx, folder.ID, newFolder(mailbox.ID, newPath, parentID))
+	} else {
+		err = c.repo.RenameFolder(ctx, folder.ID, newPath[strings.LastIndex(newPath, "/")+1:], newPath, parentID)
+	}
+	if err != nil {
 		c.logger.Error("Failed to rename folder", zap.Error(err))
 		c.sendTagged(tag, "NO Cannot rename mailbox")
 		return nil
 	}
 
+	c.logger.Info("Folder renamed",
+		zap.String("from", oldPath),
+		zap.String("to", newPath),
+		zap.String("mailbox_id", mailbox.ID),
+	)
+
 	c.sendTagged(tag, "OK RENAME completed")
 	return nil
 }
 
-// handleSubscribe handles SUBSCRIBE and UNSUBSCRIBE commands
+// handleSubscribe handles SUBSCRIBE and UNSUBSCRIBE commands. Subscriptions
+// are the user's own, so users sharing a mailbox each keep theirs.
 func (c *Connection) handleSubscribe(tag, args string, subscribe bool) error {
 	if !c.requireAuth(tag) {
 		return nil
 	}
 
 	mailboxName := strings.Trim(args, "\"")
+	if mailboxName == "" {
+		c.sendTagged(tag, "BAD Missing mailbox name")
+		return nil
+	}
 
 	mailbox, folderPath, err := c.parseMailboxPath(mailboxName)
 	if err != nil {
 		c.sendTagged(tag, "NO %s", err.Error())
 		return nil
 	}
+	folderPath = canonicalFolderPath(folderPath)
 
 	ctx, cancel := c.getContext()
 	defer cancel()
 
 	folder, err := c.repo.GetFolderByPath(ctx, mailbox.ID, folderPath)
 	if err != nil {
-		c.sendTagged(tag, "NO Mailbox does not exist")
+		c.sendTagged(tag, "NO [NONEXISTENT] Mailbox does not exist")
 		return nil
 	}
 
-	// Update subscription status
-	folder.Subscribed = subscribe
-	// Would update in database here
-
 	command := "SUBSCRIBE"
 	if !subscribe {
 		command = "UNSUBSCRIBE"
 	}
 
+	if err := c.repo.SetFolderSubscription(ctx, c.ctx.User.ID, folder.ID, subscribe); err != nil {
+		c.logger.Error("Failed to update subscription", zap.Error(err))
+		c.sendTagged(tag, "NO %s failed", command)
+		return nil
+	}
+
 	c.sendTagged(tag, "OK %s completed", command)
 	return nil
 }
@@ -1,8 +1,10 @@
 package imap
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -25,7 +27,26 @@ func (c *Connection) handleFetch(tag, args string, uid bool) error {
 	}
 
 	seqSet := parts[0]
-	fetchItems := parseFetchItems(parts[1])
+	items, modifierList := splitFetchModifiers(parts[1])
+	fetchItems := parseFetchItems(items)
+
+	modifiers, err := parseFetchModifiers(modifierList)
+	if err != nil {
+		c.sendTagged(tag, "BAD %s", err.Error())
+		return nil
+	}
+	if modifiers.Vanished && (!uid || !c.ctx.QRESYNCEnabled) {
+		c.sendTagged(tag, "BAD VANISHED requires UID FETCH with QRESYNC enabled")
+		return nil
+	}
+
+	// MODSEQ or CHANGEDSINCE implicitly enables CONDSTORE (RFC 7162 Section 3.1)
+	if modifiers.HasChangedSince || containsFetchItem(fetchItems, "MODSEQ") {
+		c.ctx.CONDSTOREEnabled = true
+	}
+	if modifiers.HasChangedSince && !containsFetchItem(fetchItems, "MODSEQ") {
+		fetchItems = append(fetchItems, "MODSEQ")
+	}
 
 	ctx, cancel := c.getContext()
 	defer cancel()
@@ -38,7 +59,15 @@ func (c *Connection) handleFetch(tag, args string, uid bool) error {
 		return nil
 	}
 
+	if modifiers.Vanished {
+		c.sendVanishedEarlier(c.ctx.ActiveFolder.ID, modifiers.ChangedSince, seqSet)
+	}
+
 	for _, msg := range messages {
+		if modifiers.HasChangedSince && msg.ModSeq <= modifiers.ChangedSince {
+			continue
+		}
+
 		response := c.buildFetchResponse(msg, fetchItems, uid)
 		c.sendUntagged("%d FETCH %s", msg.SequenceNum, response)
 
@@ -47,6 +76,8 @@ func (c *Connection) handleFetch(tag, args string, uid bool) error {
 			modseq, _ := c.repo.IncrementModSeq(ctx, c.ctx.ActiveFolder.ID)
 			if err := c.repo.UpdateMessageFlags(ctx, msg.ID, []MessageFlag{FlagSeen}, modseq); err != nil {
 				c.logger.Warn("Failed to mark message as seen", zap.Error(err))
+			} else if !hasFlag(msg.Flags, FlagSeen) {
+				c.markDisplayed(ctx, msg)
 			}
 		}
 	}
@@ -59,6 +90,19 @@ func (c *Connection) handleFetch(tag, args string, uid bool) error {
 	return nil
 }
 
+// markDisplayed lets the SMTP server send the read receipt a newly seen
+// message requested, if its user sends them automatically
+func (c *Connection) markDisplayed(ctx context.Context, msg *Message) {
+	if msg.MessageID == "" {
+		return
+	}
+	if err := c.repo.MarkMDNDisplayed(ctx, msg.MailboxID, msg.MessageID); err != nil {
+		c.logger.Warn("Failed to mark read receipt request displayed",
+			zap.String("message_id", msg.ID),
+			zap.Error(err))
+	}
+}
+
 // handleStore handles the STORE command
 func (c *Connection) handleStore(tag, args string, uid bool) error {
 	if !c.requireSelected(tag) {
@@ -82,7 +126,11 @@ func (c *Connection) handleStore(tag, args string, uid bool) error {
 	flagsStr := parts[2]
 
 	// Parse flags
-	flags := parseFlagList(flagsStr)
+	flags, err := parseStoreFlags(parseFlagList(flagsStr))
+	if err != nil {
+		c.sendTagged(tag, "BAD %s", err.Error())
+		return nil
+	}
 
 	// Determine operation
 	var operation string
@@ -117,21 +165,30 @@ func (c *Connection) handleStore(tag, args string, uid bool) error {
 		// Apply flag operation and get new flags
 		newFlags := c.applyFlagOperation(msg.Flags, flags, operation)
 
-		// Update flags with modseq
-		modseq, _ := c.repo.IncrementModSeq(ctx, c.ctx.ActiveFolder.ID)
+		// Update flags with modseq, allocated from the message's own folder
+		// since All Mail shows messages from many
+		modseq, _ := c.repo.IncrementModSeq(ctx, msg.FolderID)
 		if err := c.repo.UpdateMessageFlags(ctx, msg.ID, newFlags, modseq); err != nil {
 			c.logger.Warn("Failed to update flags", zap.String("message_id", msg.ID), zap.Error(err))
 			continue
 		}
+		if hasFlag(newFlags, FlagSeen) && !hasFlag(msg.Flags, FlagSeen) {
+			c.markDisplayed(ctx, msg)
+		}
 
 		// Send FETCH response unless SILENT
 		if !silent {
 			flagList := flagsToString(newFlags)
 
+			modseqItem := ""
+			if c.ctx.CONDSTOREEnabled && !isAllMail(c.ctx.ActiveFolder) {
+				modseqItem = fmt.Sprintf(" MODSEQ (%d)", modseq)
+			}
+
 			if uid {
-				c.sendUntagged("%d FETCH (UID %d FLAGS (%s))", msg.SequenceNum, msg.UID, flagList)
+				c.sendUntagged("%d FETCH (UID %d FLAGS (%s)%s)", msg.SequenceNum, msg.UID, flagList, modseqItem)
 			} else {
-				c.sendUntagged("%d FETCH (FLAGS (%s))", msg.SequenceNum, flagList)
+				c.sendUntagged("%d FETCH (FLAGS (%s)%s)", msg.SequenceNum, flagList, modseqItem)
 			}
 		}
 
@@ -211,6 +268,7 @@ func (c *Connection) handleExpunge(tag string) error {
 			c.sendUntagged("%d EXPUNGE", expunged[i])
 		}
 	}
+	c.notifyExpunged(expunged)
 
 	c.sendTagged(tag, "OK EXPUNGE completed")
 	return nil
@@ -253,6 +311,7 @@ func (c *Connection) handleUIDExpunge(tag, args string) error {
 			c.sendUntagged("%d EXPUNGE", expunged[i])
 		}
 	}
+	c.notifyExpunged(expunged)
 
 	c.sendTagged(tag, "OK UID EXPUNGE completed")
 	return nil
@@ -271,10 +330,19 @@ func (c *Connection) handleAppend(tag, args string) error {
 		return nil
 	}
 
-	// Convert string flags to MessageFlag
-	var flags []MessageFlag
-	for _, f := range flagStrs {
-		flags = append(flags, MessageFlag(f))
+	// A non-synchronizing literal is already on its way and must be consumed
+	// before a command that is refused
+	discardLiteral := func() {
+		if strings.Contains(args, "+}") {
+			io.CopyN(io.Discard, c.reader, int64(literalSize))
+		}
+	}
+
+	flags, err := parseStoreFlags(flagStrs)
+	if err != nil {
+		discardLiteral()
+		c.sendTagged(tag, "BAD %s", err.Error())
+		return nil
 	}
 
 	mailbox, folderPath, err := c.parseMailboxPath(mailboxName)
@@ -291,11 +359,20 @@ func (c *Connection) handleAppend(tag, args string) error {
 		c.sendTagged(tag, "NO [TRYCREATE] Mailbox does not exist")
 		return nil
 	}
+	if isAllMail(folder) {
+		discardLiteral()
+		c.sendTagged(tag, "NO [CANNOT] All Mail shows the messages of other folders; append to one of those")
+		return nil
+	}
 
-	// Check quota
-	quota, _ := c.repo.GetQuota(ctx, mailbox.ID)
-	if quota != nil && quota.Usage+int64(literalSize) > quota.Limit {
-		c.sendTagged(tag, "NO [OVERQUOTA] Quota exceeded")
+	// Check quota before accepting the literal
+	over, err := c.overQuotaRoot(ctx, mailbox, int64(literalSize))
+	if err != nil {
+		c.logger.Warn("Failed to check quota", zap.String("mailbox_id", mailbox.ID), zap.Error(err))
+	}
+	if over != nil {
+		discardLiteral()
+		c.sendTagged(tag, `NO [OVERQUOTA] Quota exceeded for quota root "%s"`, over.Name)
 		return nil
 	}
 
@@ -314,15 +391,11 @@ func (c *Connection) handleAppend(tag, args string) error {
 	// Parse message
 	size := int64(len(messageData))
 
-	// Generate UID
-	uid := folder.UIDNext
-
 	// Create message record
 	message := &Message{
 		ID:         uuid.New().String(),
 		MailboxID:  mailbox.ID,
 		FolderID:   folder.ID,
-		UID:        uid,
 		Flags:      flags,
 		Size:       size,
 		ReceivedAt: internalDate,
@@ -336,6 +409,7 @@ func (c *Connection) handleAppend(tag, args string) error {
 	if toStr != "" {
 		message.To = []string{toStr}
 	}
+	message.MessageID, message.InReplyTo, message.References = parseThreadingHeaders(messageData)
 
 	// Store message
 	if err := c.storeMessage(ctx, message, messageData); err != nil {
@@ -349,12 +423,20 @@ func (c *Connection) handleAppend(tag, args string) error {
 		c.logger.Warn("Failed to update folder counts", zap.Error(err))
 	}
 
+	c.notifyHub.NotifyOthers(mailbox.ID, c.id, IdleNotification{
+		Type:       "EXISTS",
+		MailboxID:  mailbox.ID,
+		FolderPath: folder.FullPath,
+		UID:        message.UID,
+		Timestamp:  time.Now(),
+	})
+
 	c.logger.Info("Message appended",
 		zap.String("folder", folderPath),
-		zap.Uint32("uid", uid),
+		zap.Uint32("uid", message.UID),
 	)
 
-	c.sendTagged(tag, "OK [APPENDUID %d %d] APPEND completed", folder.UIDValidity, uid)
+	c.sendTagged(tag, "OK [APPENDUID %d %d] APPEND completed", folder.UIDValidity, message.UID)
 	return nil
 }
 
@@ -446,6 +528,16 @@ func parseFetchItems(args string) []string {
 	return strings.Fields(args)
 }
 
+// containsFetchItem reports whether a FETCH data item was requested
+func containsFetchItem(items []string, name string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // parseFlagList parses a flag list from STORE command
 func parseFlagList(args string) []string {
 	args = strings.TrimSpace(args)
@@ -462,10 +554,12 @@ func parseSearchCriteria(args string) []SearchKey {
 
 	// Simple tokenization - production would need proper parsing
 	tokens := strings.Fields(args)
+	negate := false
 
 	for i := 0; i < len(tokens); i++ {
 		key := strings.ToUpper(tokens[i])
-		criterion := SearchKey{Key: key}
+		criterion := SearchKey{Key: key, Not: negate}
+		negate = false
 
 		switch key {
 		case "ALL", "ANSWERED", "DELETED", "DRAFT", "FLAGGED", "NEW", "OLD", "RECENT", "SEEN", "UNANSWERED", "UNDELETED", "UNDRAFT", "UNFLAGGED", "UNSEEN":
@@ -489,7 +583,7 @@ func parseSearchCriteria(args string) []SearchKey {
 				criterion.Value = tokens[i]
 			}
 
-		case "UID":
+		case "UID", "KEYWORD", "UNKEYWORD":
 			if i+1 < len(tokens) {
 				i++
 				criterion.Value = tokens[i]
@@ -500,7 +594,7 @@ func parseSearchCriteria(args string) []SearchKey {
 			continue
 
 		case "NOT":
-			// Would need to handle negation
+			negate = !criterion.Not
 			continue
 
 		default:
@@ -515,36 +609,133 @@ func parseSearchCriteria(args string) []SearchKey {
 	return criteria
 }
 
-// applyFlagOperation applies flag changes and returns new flag list
-func (c *Connection) applyFlagOperation(current []MessageFlag, changes []string, operation string) []MessageFlag {
-	flagMap := make(map[MessageFlag]bool)
+// searchKeyFlags maps flag search keys to the system flag they test
+var searchKeyFlags = map[string]MessageFlag{
+	"ANSWERED": FlagAnswered,
+	"DELETED":  FlagDeleted,
+	"DRAFT":    FlagDraft,
+	"FLAGGED":  FlagFlagged,
+	"RECENT":   FlagRecent,
+	"SEEN":     FlagSeen,
+}
 
-	switch operation {
-	case "add":
-		for _, f := range current {
-			flagMap[f] = true
-		}
-		for _, f := range changes {
-			flagMap[MessageFlag(f)] = true
+// matchesSearchCriteria reports whether msg, at sequence number seq, satisfies
+// every criterion. Keys that need the message body (BODY, TEXT) match all
+// messages; searches answer them from the text index (see findMessages)
+func matchesSearchCriteria(msg *Message, seq uint32, criteria []SearchKey) bool {
+	for _, criterion := range criteria {
+		if matchSearchKey(msg, seq, criterion) == criterion.Not {
+			return false
 		}
+	}
+	return true
+}
 
-	case "remove":
-		for _, f := range current {
-			flagMap[f] = true
+// matchSearchKey evaluates a single search key, ignoring its Not flag
+func matchSearchKey(msg *Message, seq uint32, key SearchKey) bool {
+	value := searchKeyValue(key)
+
+	switch key.Key {
+	case "ANSWERED", "DELETED", "DRAFT", "FLAGGED", "RECENT", "SEEN":
+		return hasFlag(msg.Flags, searchKeyFlags[key.Key])
+	case "UNANSWERED", "UNDELETED", "UNDRAFT", "UNFLAGGED", "UNSEEN":
+		return !hasFlag(msg.Flags, searchKeyFlags[strings.TrimPrefix(key.Key, "UN")])
+	case "NEW":
+		return hasFlag(msg.Flags, FlagRecent) && !hasFlag(msg.Flags, FlagSeen)
+	case "OLD":
+		return !hasFlag(msg.Flags, FlagRecent)
+	case "FROM":
+		return containsFold(msg.From, value)
+	case "TO":
+		return containsFold(strings.Join(msg.To, ", "), value)
+	case "CC":
+		return containsFold(strings.Join(msg.Cc, ", "), value)
+	case "BCC":
+		return containsFold(strings.Join(msg.Bcc, ", "), value)
+	case "SUBJECT":
+		return containsFold(msg.Subject, value)
+	case "BEFORE", "ON", "SINCE":
+		return matchSearchDate(msg.ReceivedAt, key.Key, value)
+	case "SENTBEFORE", "SENTON", "SENTSINCE":
+		return matchSearchDate(msg.Date, strings.TrimPrefix(key.Key, "SENT"), value)
+	case "LARGER", "SMALLER":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
 		}
-		for _, f := range changes {
-			delete(flagMap, MessageFlag(f))
+		if key.Key == "LARGER" {
+			return msg.Size > n
 		}
+		return msg.Size < n
+	case "KEYWORD":
+		return hasFlag(msg.Flags, MessageFlag(value))
+	case "UNKEYWORD":
+		return !hasFlag(msg.Flags, MessageFlag(value))
+	case "UID":
+		return uidSetContains(value, msg.UID)
+	case "SEQSET":
+		return uidSetContains(value, seq)
+	}
+	return true
+}
 
-	case "replace":
-		for _, f := range changes {
-			flagMap[MessageFlag(f)] = true
+// matchSearchDate compares the calendar date of t against an IMAP date ("1-Feb-1994")
+func matchSearchDate(t time.Time, op, value string) bool {
+	day, err := time.Parse("2-Jan-2006", value)
+	if err != nil {
+		return false
+	}
+	msgDay := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch op {
+	case "BEFORE":
+		return msgDay.Before(day)
+	case "ON":
+		return msgDay.Equal(day)
+	default: // SINCE
+		return !msgDay.Before(day)
+	}
+}
+
+// searchKeyValue returns the string argument of a search key without quotes
+func searchKeyValue(key SearchKey) string {
+	value, _ := key.Value.(string)
+	return strings.Trim(value, `"`)
+}
+
+func hasFlag(flags []MessageFlag, flag MessageFlag) bool {
+	for _, f := range flags {
+		if strings.EqualFold(string(f), string(flag)) {
+			return true
 		}
 	}
+	return false
+}
 
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// applyFlagOperation applies flag changes and returns new flag list. Flags
+// compare case-insensitively, and the current order is kept.
+func (c *Connection) applyFlagOperation(current []MessageFlag, changes []MessageFlag, operation string) []MessageFlag {
 	var result []MessageFlag
-	for f := range flagMap {
-		result = append(result, f)
+	if operation != "replace" {
+		for _, f := range current {
+			if operation == "remove" && hasFlag(changes, f) {
+				continue
+			}
+			if !hasFlag(result, f) {
+				result = append(result, f)
+			}
+		}
+	}
+	if operation != "remove" {
+		for _, f := range changes {
+			if !hasFlag(result, f) {
+				result = append(result, f)
+			}
+		}
 	}
 	return result
 }
@@ -600,6 +791,20 @@ func (c *Connection) expungeMessages() ([]uint32, []uint32) {
 	return expunged, expungedUIDs
 }
 
+// notifyExpunged tells other sessions on the selected folder which sequence
+// numbers were expunged. Highest first, so each number is valid when applied.
+func (c *Connection) notifyExpunged(expunged []uint32) {
+	for i := len(expunged) - 1; i >= 0; i-- {
+		c.notifyHub.NotifyOthers(c.ctx.ActiveMailbox.ID, c.id, IdleNotification{
+			Type:       "EXPUNGE",
+			MailboxID:  c.ctx.ActiveMailbox.ID,
+			FolderPath: c.ctx.ActiveFolder.FullPath,
+			SeqNum:     expunged[i],
+			Timestamp:  time.Now(),
+		})
+	}
+}
+
 // expungeMessagesWithUIDs removes only messages with \Deleted flag that match the given UID set
 // Returns both sequence numbers (for EXPUNGE) and UIDs (for VANISHED)
 func (c *Connection) expungeMessagesWithUIDs(uidSet []uint32) ([]uint32, []uint32) {
@@ -671,11 +876,83 @@ func formatUIDSet(uids []uint32) string {
 	return strings.Join(result, ",")
 }
 
-// searchMessages searches messages based on criteria
-func (c *Connection) searchMessages(ctx interface{}, folderID string, criteria []SearchKey, uid bool) ([]string, error) {
-	// Implementation would build SQL query from criteria
-	// For now, return empty results
-	return []string{}, nil
+// searchMessages returns the sequence numbers, or UIDs, of the messages in a
+// folder that match every criterion
+func (c *Connection) searchMessages(ctx context.Context, folderID string, criteria []SearchKey, uid bool) ([]string, error) {
+	messages, err := c.findMessages(ctx, folderID, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		id := msg.SequenceNum
+		if uid {
+			id = msg.UID
+		}
+		results = append(results, strconv.FormatUint(uint64(id), 10))
+	}
+	return results, nil
+}
+
+// findMessages loads a folder with sequence numbers and returns the messages
+// matching every criterion, in sequence order. SUBJECT, BODY and TEXT are
+// answered by the search index, one query per criterion; the remaining
+// criteria are checked against the loaded messages.
+func (c *Connection) findMessages(ctx context.Context, folderID string, criteria []SearchKey) ([]*Message, error) {
+	messages, err := c.repo.GetMessagesBySequence(ctx, folderID, "1:*", false)
+	if err != nil {
+		return nil, err
+	}
+
+	indexed, scanned := splitSearchCriteria(criteria)
+	indexedUIDs := make([]map[uint32]bool, len(indexed))
+	for i, key := range indexed {
+		indexedUIDs[i], err = c.repo.SearchMessageText(ctx, folderID, key.Key, searchKeyValue(key), c.config.IMAP.SearchExactSubstring)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var matched []*Message
+	for _, msg := range messages {
+		if matchesIndexedCriteria(msg.UID, indexed, indexedUIDs) && matchesSearchCriteria(msg, msg.SequenceNum, scanned) {
+			matched = append(matched, msg)
+		}
+	}
+	return matched, nil
+}
+
+// indexedSearchKeys are the search keys answered by the message text index
+var indexedSearchKeys = map[string]bool{
+	"SUBJECT": true,
+	"BODY":    true,
+	"TEXT":    true,
+}
+
+// splitSearchCriteria separates the criteria the text index answers from
+// those checked against loaded messages. An empty text search matches every
+// message, which the scan answers without a query.
+func splitSearchCriteria(criteria []SearchKey) (indexed, scanned []SearchKey) {
+	for _, criterion := range criteria {
+		if indexedSearchKeys[criterion.Key] && searchKeyValue(criterion) != "" {
+			indexed = append(indexed, criterion)
+		} else {
+			scanned = append(scanned, criterion)
+		}
+	}
+	return indexed, scanned
+}
+
+// matchesIndexedCriteria reports whether the message with uid satisfies each
+// indexed criterion, given the UIDs the index returned for it
+func matchesIndexedCriteria(uid uint32, criteria []SearchKey, uids []map[uint32]bool) bool {
+	for i, criterion := range criteria {
+		if uids[i][uid] == criterion.Not {
+			return false
+		}
+	}
+	return true
 }
 
 // parseAppendArgs parses APPEND command arguments
@@ -764,10 +1041,10 @@ func parseMessageHeaders(data string) (subject, from, to, messageID string, date
 }
 
 // storeMessage stores message data
-func (c *Connection) storeMessage(ctx interface{}, msg *Message, data []byte) error {
+func (c *Connection) storeMessage(ctx context.Context, msg *Message, data []byte) error {
 	// Would store to file system or object storage
-	// And insert database record
-	return nil
+	msg.BodyText = extractBodyText(data)
+	return c.repo.CreateMessage(ctx, msg)
 }
 
 // extractBodySection extracts the section specifier from BODY[section]
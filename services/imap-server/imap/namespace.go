@@ -1,6 +1,7 @@
 package imap
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -88,47 +89,32 @@ func formatNamespaceList(namespaces []Namespace) string {
 	return "(" + strings.Join(parts, "") + ")"
 }
 
-// handleList handles the LIST command
+// handleList handles the LIST command, with the LIST-EXTENDED selection and
+// return options (RFC 5258)
 func (c *Connection) handleList(tag, args string) error {
 	if !c.requireAuth(tag) {
 		return nil
 	}
 
-	// Parse arguments: reference mailbox-pattern [RETURN (options)]
-	parts := parseListArgs(args)
-	if len(parts) < 2 {
-		c.sendTagged(tag, "BAD LIST requires reference and mailbox name")
+	cmd, err := parseListCommand(args)
+	if err != nil {
+		c.sendTagged(tag, "BAD %s", err.Error())
 		return nil
 	}
 
-	reference := parts[0]
-	pattern := parts[1]
-	returnOptions := parseReturnOptions(args)
-
 	// Handle empty pattern (list hierarchy delimiter)
-	if pattern == "" {
+	if len(cmd.patterns) == 1 && cmd.patterns[0] == "" {
 		c.sendUntagged(`LIST (\Noselect) "/" ""`)
 		c.sendTagged(tag, "OK LIST completed")
 		return nil
 	}
 
-	// List folders based on namespace mode
-	folders := c.listFolders(reference, pattern, returnOptions)
-
-	for _, folder := range folders {
-		attrs := formatAttributes(folder.Attributes)
-		if returnOptions.specialUse && folder.SpecialUse != nil {
-			attrs = append(attrs, string(*folder.SpecialUse))
+	for _, folder := range selectListEntries(c.visibleFolders(), cmd) {
+		line := fmt.Sprintf(`LIST (%s) "%s" "%s"`, strings.Join(folder.Attributes, " "), folder.Delimiter, folder.Name)
+		if folder.childSubscribed {
+			line += ` ("CHILDINFO" ("SUBSCRIBED"))`
 		}
-
-		attrStr := ""
-		if len(attrs) > 0 {
-			attrStr = "(" + strings.Join(attrs, " ") + ")"
-		} else {
-			attrStr = "()"
-		}
-
-		c.sendUntagged(`LIST %s "%s" "%s"`, attrStr, folder.Delimiter, folder.Name)
+		c.sendUntagged("%s", line)
 	}
 
 	c.sendTagged(tag, "OK LIST completed")
@@ -151,118 +137,122 @@ func (c *Connection) handleLsub(tag, args string) error {
 	reference := parts[0]
 	pattern := parts[1]
 
-	// List subscribed folders
-	folders := c.listFolders(reference, pattern, listReturnOptions{subscribed: true})
-
-	for _, folder := range folders {
-		if !folder.Subscribed {
-			continue
-		}
-
-		attrs := formatAttributes(folder.Attributes)
-		attrStr := "()"
-		if len(attrs) > 0 {
-			attrStr = "(" + strings.Join(attrs, " ") + ")"
-		}
-
-		c.sendUntagged(`LSUB %s "%s" "%s"`, attrStr, folder.Delimiter, folder.Name)
+	for _, folder := range selectLsubEntries(c.visibleFolders(), reference, pattern) {
+		c.sendUntagged(`LSUB (%s) "%s" "%s"`, strings.Join(folder.Attributes, " "), folder.Delimiter, folder.Name)
 	}
 
 	c.sendTagged(tag, "OK LSUB completed")
 	return nil
 }
 
-// listFolders returns folders matching the pattern
-func (c *Connection) listFolders(reference, pattern string, options listReturnOptions) []*FolderList {
+// listEntry is a folder as LIST and LSUB show it
+type listEntry struct {
+	FolderList
+	selectable bool
+
+	// childSubscribed is set on a folder listed for its subscribed children
+	// (RECURSIVEMATCH), which is returned with CHILDINFO
+	childSubscribed bool
+}
+
+// visibleFolders returns every folder the user can see, named as LIST shows
+// it, with the user's own subscriptions applied
+func (c *Connection) visibleFolders() []*listEntry {
 	ctx, cancel := c.getContext()
 	defer cancel()
 
-	var result []*FolderList
-
-	// Build full pattern
-	fullPattern := reference + pattern
+	var result []*listEntry
+	seen := make(map[string]bool)
+	add := func(e *listEntry) {
+		if seen[e.Name] {
+			return
+		}
+		seen[e.Name] = true
+		result = append(result, e)
+	}
 
 	// Handle unified mode - add virtual combined INBOX
-	if c.ctx.NamespaceMode == NamespaceModeUnified && matchPattern("INBOX", fullPattern) {
-		inbox := &FolderList{
-			Name:       "INBOX",
-			Delimiter:  "/",
-			Attributes: []string{"\\HasNoChildren"},
-			SpecialUse: ptrSpecialUse(SpecialUseInbox),
-		}
-		result = append(result, inbox)
+	if c.ctx.NamespaceMode == NamespaceModeUnified {
+		add(&listEntry{
+			FolderList: FolderList{
+				Name:       "INBOX",
+				Delimiter:  "/",
+				SpecialUse: ptrSpecialUse(SpecialUseInbox),
+				Subscribed: true,
+			},
+			selectable: true,
+		})
 	}
 
 	// List folders from all accessible mailboxes
 	for _, mb := range c.ctx.Mailboxes {
-		folders, err := c.repo.GetMailboxFolders(ctx, mb.ID)
-		if err != nil {
-			c.logger.Warn("Failed to get folders", zap.Error(err))
-			continue
+		folders, subscriptions := c.mailboxFolders(ctx, mb)
+		for _, f := range folders {
+			add(newListEntry(c.getFolderDisplayName(mb, f), f, subscriptions))
 		}
+	}
 
+	// List shared mailboxes
+	for _, mb := range c.ctx.SharedMailboxes {
+		// Shared mailbox root, which selects its INBOX
+		add(&listEntry{
+			FolderList: FolderList{
+				Name:      fmt.Sprintf("Shared/%s", mb.Email),
+				Delimiter: "/",
+			},
+			selectable: true,
+		})
+
+		folders, subscriptions := c.mailboxFolders(ctx, mb)
 		for _, f := range folders {
-			folderName := c.getFolderDisplayName(mb, f)
+			add(newListEntry(fmt.Sprintf("Shared/%s/%s", mb.Email, f.FullPath), f, subscriptions))
+		}
+	}
 
-			if !matchPattern(folderName, fullPattern) {
-				continue
-			}
+	return result
+}
 
-			attrs := f.Attributes
-			if !f.Selectable {
-				attrs = append(attrs, "\\Noselect")
-			}
-			if f.Subscribed && options.subscribed {
-				// Already included
-			}
+// mailboxFolders returns the folders of a mailbox and the user's
+// subscriptions to them
+func (c *Connection) mailboxFolders(ctx context.Context, mb *Mailbox) ([]*Folder, map[string]bool) {
+	folders, err := c.repo.GetMailboxFolders(ctx, mb.ID)
+	if err != nil {
+		c.logger.Warn("Failed to get folders", zap.Error(err))
+		return nil, nil
+	}
 
-			fl := &FolderList{
-				Name:       folderName,
-				Delimiter:  f.Delimiter,
-				Attributes: attrs,
-				SpecialUse: f.SpecialUse,
-				Subscribed: f.Subscribed,
-			}
-			result = append(result, fl)
-		}
+	subscriptions, err := c.repo.GetFolderSubscriptions(ctx, c.ctx.User.ID, mb.ID)
+	if err != nil {
+		// Folders are shown with their default subscription instead
+		c.logger.Warn("Failed to get folder subscriptions", zap.Error(err))
 	}
 
-	// List shared mailboxes
-	if strings.HasPrefix(fullPattern, "Shared/") || fullPattern == "*" || fullPattern == "%" {
-		for _, mb := range c.ctx.SharedMailboxes {
-			// Add shared mailbox root folder
-			sharedName := fmt.Sprintf("Shared/%s", mb.Email)
-			if matchPattern(sharedName, fullPattern) {
-				fl := &FolderList{
-					Name:       sharedName,
-					Delimiter:  "/",
-					Attributes: []string{"\\HasChildren"},
-				}
-				result = append(result, fl)
-			}
+	return folders, subscriptions
+}
 
-			// List folders within shared mailbox
-			folders, err := c.repo.GetMailboxFolders(ctx, mb.ID)
-			if err != nil {
-				continue
-			}
+// newListEntry returns the list entry for a folder; a subscription of the
+// user's own overrides the folder's default
+func newListEntry(name string, f *Folder, subscriptions map[string]bool) *listEntry {
+	subscribed := f.Subscribed
+	if s, ok := subscriptions[f.ID]; ok {
+		subscribed = s
+	}
 
-			for _, f := range folders {
-				folderName := fmt.Sprintf("Shared/%s/%s", mb.Email, f.FullPath)
-				if matchPattern(folderName, fullPattern) {
-					fl := &FolderList{
-						Name:       folderName,
-						Delimiter:  "/",
-						Attributes: f.Attributes,
-						SpecialUse: f.SpecialUse,
-					}
-					result = append(result, fl)
-				}
-			}
-		}
+	delimiter := f.Delimiter
+	if delimiter == "" {
+		delimiter = "/"
 	}
 
-	return result
+	return &listEntry{
+		FolderList: FolderList{
+			Name:       name,
+			Delimiter:  delimiter,
+			Attributes: storedAttributes(f.Attributes),
+			SpecialUse: f.SpecialUse,
+			Subscribed: subscribed,
+		},
+		selectable: f.Selectable,
+	}
 }
 
 // getFolderDisplayName returns the display name for a folder based on namespace mode
@@ -282,30 +272,182 @@ func (c *Connection) getFolderDisplayName(mb *Mailbox, f *Folder) string {
 	return f.FullPath
 }
 
-// matchPattern matches a folder name against an IMAP pattern
-func matchPattern(name, pattern string) bool {
-	// Convert IMAP pattern to simple matching
-	// * matches everything
-	// % matches everything except hierarchy delimiter
+// selectListEntries returns the folders a LIST command lists, with their
+// attributes: \Noselect, \HasChildren or \HasNoChildren, the special-use
+// attribute, which RFC 6154 has LIST return whether asked for or not, and
+// \Subscribed if asked for
+func selectListEntries(entries []*listEntry, cmd *listCommand) []*listEntry {
+	var result []*listEntry
+
+	for _, e := range entries {
+		if !cmd.matches(e.Name) {
+			continue
+		}
 
-	if pattern == "*" {
-		return true
+		specialUse := specialUseAttribute(e.SpecialUse)
+		if cmd.selection.specialUse && specialUse == "" {
+			continue
+		}
+
+		childSubscribed := false
+		if cmd.selection.subscribed && cmd.selection.recursiveMatch {
+			childSubscribed = hasDescendant(entries, e, func(child *listEntry) bool { return child.Subscribed })
+		}
+		if cmd.selection.subscribed && !e.Subscribed && !childSubscribed {
+			continue
+		}
+
+		attrs := append([]string{}, e.Attributes...)
+		if !e.selectable {
+			attrs = append(attrs, `\Noselect`)
+		}
+		if hasDescendant(entries, e, nil) {
+			attrs = append(attrs, `\HasChildren`)
+		} else {
+			attrs = append(attrs, `\HasNoChildren`)
+		}
+		if specialUse != "" {
+			attrs = append(attrs, specialUse)
+		}
+		if cmd.returns.subscribed && e.Subscribed {
+			attrs = append(attrs, `\Subscribed`)
+		}
+
+		listed := *e
+		listed.Attributes = attrs
+		listed.childSubscribed = childSubscribed
+		result = append(result, &listed)
 	}
 
-	// Simple pattern matching
-	pattern = strings.ReplaceAll(pattern, "*", ".*")
-	pattern = strings.ReplaceAll(pattern, "%", "[^/]*")
+	return result
+}
+
+// selectLsubEntries returns the folders an LSUB command lists: the
+// subscribed folders matching the pattern. With a pattern ending in %, a
+// parent of subscribed folders is listed \Noselect even if it isn't
+// subscribed itself (RFC 3501 section 6.3.9).
+func selectLsubEntries(entries []*listEntry, reference, pattern string) []*listEntry {
+	fullPattern := reference + pattern
+	subscribed := func(e *listEntry) bool { return e.Subscribed }
 
-	// Case-insensitive matching for INBOX
-	if strings.EqualFold(name, "INBOX") && strings.EqualFold(pattern, "INBOX") {
-		return true
+	var result []*listEntry
+	for _, e := range entries {
+		if !matchMailboxPattern(e.Name, fullPattern) {
+			continue
+		}
+
+		var attrs []string
+		switch {
+		case e.Subscribed:
+			attrs = append(attrs, e.Attributes...)
+			if !e.selectable {
+				attrs = append(attrs, `\Noselect`)
+			}
+		case strings.HasSuffix(fullPattern, "%") && hasDescendant(entries, e, subscribed):
+			attrs = []string{`\Noselect`}
+		default:
+			continue
+		}
+
+		listed := *e
+		listed.Attributes = attrs
+		result = append(result, &listed)
+	}
+
+	return result
+}
+
+// hasDescendant reports whether any folder beneath e matches match, or
+// whether e has any folder beneath it if match is nil
+func hasDescendant(entries []*listEntry, e *listEntry, match func(*listEntry) bool) bool {
+	prefix := e.Name + e.Delimiter
+	for _, other := range entries {
+		if strings.HasPrefix(other.Name, prefix) && (match == nil || match(other)) {
+			return true
+		}
+	}
+	return false
+}
+
+// computedAttributes are worked out for each LIST response, so any stored
+// with a folder are dropped
+var computedAttributes = map[string]bool{
+	`\haschildren`:   true,
+	`\hasnochildren`: true,
+	`\noselect`:      true,
+	`\nonexistent`:   true,
+	`\subscribed`:    true,
+}
+
+// storedAttributes returns the attributes stored with a folder that LIST
+// shows as they are. Special-use attributes come from the folder's special
+// use instead.
+func storedAttributes(attrs []string) []string {
+	result := make([]string, 0, len(attrs))
+	for _, attr := range formatAttributes(attrs) {
+		if computedAttributes[strings.ToLower(attr)] {
+			continue
+		}
+		su := SpecialUse(attr)
+		if specialUseAttribute(&su) != "" {
+			continue
+		}
+		result = append(result, attr)
+	}
+	return result
+}
+
+// specialUseAttribute returns the RFC 6154 attribute of a special-use folder,
+// or "" if it has none. The folders created with a mailbox store their special
+// use in lower case without the backslash ("sent"); INBOX has no attribute.
+func specialUseAttribute(su *SpecialUse) string {
+	if su == nil {
+		return ""
+	}
+
+	name := strings.TrimPrefix(string(*su), `\`)
+	if name == "" {
+		return ""
+	}
+
+	attr := SpecialUse(`\` + strings.ToUpper(name[:1]) + strings.ToLower(name[1:]))
+	switch attr {
+	case SpecialUseSent, SpecialUseDrafts, SpecialUseTrash, SpecialUseJunk,
+		SpecialUseArchive, SpecialUseFlagged, SpecialUseAll, SpecialUseImportant:
+		return string(attr)
+	}
+	return ""
+}
+
+// listCommand is a parsed LIST command:
+// LIST [(selection-options)] reference mailbox-pattern(s) [RETURN (return-options)]
+type listCommand struct {
+	selection listSelectionOptions
+	reference string
+	patterns  []string
+	returns   listReturnOptions
+}
+
+// matches reports whether a folder name matches any of the command's patterns
+func (cmd *listCommand) matches(name string) bool {
+	for _, pattern := range cmd.patterns {
+		if matchMailboxPattern(name, cmd.reference+pattern) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Basic substring matching for now
-	return strings.HasPrefix(strings.ToLower(name), strings.ToLower(strings.TrimSuffix(pattern, ".*")))
+// listSelectionOptions contains LIST selection options
+type listSelectionOptions struct {
+	subscribed     bool
+	remote         bool
+	recursiveMatch bool
+	specialUse     bool
 }
 
-// listReturnOptions contains LIST RETURN options
+// listReturnOptions contains LIST RETURN options. Children and special-use
+// attributes are always returned, so CHILDREN and SPECIAL-USE change nothing.
 type listReturnOptions struct {
 	subscribed bool
 	children   bool
@@ -313,26 +455,164 @@ type listReturnOptions struct {
 	status     bool
 }
 
-// parseReturnOptions parses LIST RETURN options
-func parseReturnOptions(args string) listReturnOptions {
-	options := listReturnOptions{}
+// parseListCommand parses the arguments of a LIST command
+func parseListCommand(args string) (*listCommand, error) {
+	parts, err := splitListArgs(args)
+	if err != nil {
+		return nil, err
+	}
 
-	if strings.Contains(strings.ToUpper(args), "RETURN") {
-		if strings.Contains(strings.ToUpper(args), "SUBSCRIBED") {
-			options.subscribed = true
+	cmd := &listCommand{}
+
+	if len(parts) > 0 && parts[0].isList {
+		for _, opt := range parts[0].items {
+			switch strings.ToUpper(opt) {
+			case "SUBSCRIBED":
+				cmd.selection.subscribed = true
+			case "REMOTE":
+				cmd.selection.remote = true
+			case "RECURSIVEMATCH":
+				cmd.selection.recursiveMatch = true
+			case "SPECIAL-USE":
+				cmd.selection.specialUse = true
+			default:
+				return nil, fmt.Errorf("Unknown LIST selection option %s", opt)
+			}
 		}
-		if strings.Contains(strings.ToUpper(args), "CHILDREN") {
-			options.children = true
+		if cmd.selection.recursiveMatch && !cmd.selection.subscribed {
+			return nil, fmt.Errorf("RECURSIVEMATCH requires SUBSCRIBED")
 		}
-		if strings.Contains(strings.ToUpper(args), "SPECIAL-USE") {
-			options.specialUse = true
+		parts = parts[1:]
+	}
+
+	if len(parts) < 2 || parts[0].isList {
+		return nil, fmt.Errorf("LIST requires reference and mailbox name")
+	}
+
+	cmd.reference = parts[0].value
+	if parts[1].isList {
+		cmd.patterns = parts[1].items
+	} else {
+		cmd.patterns = []string{parts[1].value}
+	}
+	parts = parts[2:]
+
+	if len(parts) > 0 {
+		if len(parts) != 2 || parts[0].isList || !strings.EqualFold(parts[0].value, "RETURN") || !parts[1].isList {
+			return nil, fmt.Errorf("Invalid LIST return options")
 		}
-		if strings.Contains(strings.ToUpper(args), "STATUS") {
-			options.status = true
+		items := parts[1].items
+		for i := 0; i < len(items); i++ {
+			switch strings.ToUpper(items[i]) {
+			case "SUBSCRIBED":
+				cmd.returns.subscribed = true
+			case "CHILDREN":
+				cmd.returns.children = true
+			case "SPECIAL-USE":
+				cmd.returns.specialUse = true
+			case "STATUS":
+				cmd.returns.status = true
+				// Skip the status items
+				if i+1 < len(items) && strings.HasPrefix(items[i+1], "(") {
+					i++
+				}
+			default:
+				return nil, fmt.Errorf("Unknown LIST return option %s", items[i])
+			}
 		}
 	}
 
-	return options
+	// The SUBSCRIBED selection option implies RETURN (SUBSCRIBED)
+	if cmd.selection.subscribed {
+		cmd.returns.subscribed = true
+	}
+
+	return cmd, nil
+}
+
+// listArg is a LIST argument: a string, or a parenthesized list of them
+type listArg struct {
+	value  string
+	items  []string
+	isList bool
+}
+
+// splitListArgs splits LIST arguments into strings and parenthesized lists.
+// A list nested in a list, such as the items of RETURN (STATUS (...)), is
+// kept as one item as written.
+func splitListArgs(args string) ([]listArg, error) {
+	var result []listArg
+	s := strings.TrimLeft(args, " ")
+
+	for s != "" {
+		var arg listArg
+		var err error
+
+		if s[0] == '(' {
+			arg.isList = true
+			s = s[1:]
+			for {
+				s = strings.TrimLeft(s, " ")
+				if s == "" {
+					return nil, fmt.Errorf("Unterminated list")
+				}
+				if s[0] == ')' {
+					s = s[1:]
+					break
+				}
+
+				var item string
+				if s[0] == '(' {
+					end := strings.IndexByte(s, ')')
+					if end < 0 {
+						return nil, fmt.Errorf("Unterminated list")
+					}
+					item, s = s[:end+1], s[end+1:]
+				} else if item, s, err = nextListString(s); err != nil {
+					return nil, err
+				}
+				arg.items = append(arg.items, item)
+			}
+		} else if arg.value, s, err = nextListString(s); err != nil {
+			return nil, err
+		}
+
+		result = append(result, arg)
+		s = strings.TrimLeft(s, " ")
+	}
+
+	return result, nil
+}
+
+// nextListString reads a quoted string or an atom from the start of s and
+// returns it with the rest of s
+func nextListString(s string) (string, string, error) {
+	if s[0] == '"' {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+				if i < len(s) {
+					b.WriteByte(s[i])
+				}
+			case '"':
+				return b.String(), s[i+1:], nil
+			default:
+				b.WriteByte(s[i])
+			}
+		}
+		return "", "", fmt.Errorf("Unterminated quoted string")
+	}
+
+	end := strings.IndexAny(s, " ()")
+	if end == 0 {
+		return "", "", fmt.Errorf("Unexpected %q", s[0])
+	}
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], s[end:], nil
 }
 
 // parseListArgs parses LIST command arguments
@@ -0,0 +1,337 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseListCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    *listCommand
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			args: `"" "*"`,
+			want: &listCommand{patterns: []string{"*"}},
+		},
+		{
+			name: "atoms",
+			args: `Archive/ %`,
+			want: &listCommand{reference: "Archive/", patterns: []string{"%"}},
+		},
+		{
+			name: "subscribed selection implies subscribed return",
+			args: `(SUBSCRIBED) "" "*"`,
+			want: &listCommand{
+				selection: listSelectionOptions{subscribed: true},
+				patterns:  []string{"*"},
+				returns:   listReturnOptions{subscribed: true},
+			},
+		},
+		{
+			name: "recursive match",
+			args: `(subscribed RECURSIVEMATCH) "" "%"`,
+			want: &listCommand{
+				selection: listSelectionOptions{subscribed: true, recursiveMatch: true},
+				patterns:  []string{"%"},
+				returns:   listReturnOptions{subscribed: true},
+			},
+		},
+		{
+			name: "multiple patterns and return options",
+			args: `"" ("INBOX" "Sent Items" Archive/*) RETURN (CHILDREN SPECIAL-USE)`,
+			want: &listCommand{
+				patterns: []string{"INBOX", "Sent Items", "Archive/*"},
+				returns:  listReturnOptions{children: true, specialUse: true},
+			},
+		},
+		{
+			name: "status return option",
+			args: `"" "*" RETURN (STATUS (MESSAGES UNSEEN) SUBSCRIBED)`,
+			want: &listCommand{
+				patterns: []string{"*"},
+				returns:  listReturnOptions{status: true, subscribed: true},
+			},
+		},
+		{
+			name: "special-use selection",
+			args: `(SPECIAL-USE) "" "*"`,
+			want: &listCommand{
+				selection: listSelectionOptions{specialUse: true},
+				patterns:  []string{"*"},
+			},
+		},
+		{
+			name: "quoted escapes",
+			args: `"" "a \"b\""`,
+			want: &listCommand{patterns: []string{`a "b"`}},
+		},
+		{name: "missing pattern", args: `""`, wantErr: true},
+		{name: "recursive match alone", args: `(RECURSIVEMATCH) "" "*"`, wantErr: true},
+		{name: "unknown selection option", args: `(BOGUS) "" "*"`, wantErr: true},
+		{name: "unknown return option", args: `"" "*" RETURN (BOGUS)`, wantErr: true},
+		{name: "return without list", args: `"" "*" RETURN CHILDREN`, wantErr: true},
+		{name: "unterminated list", args: `"" ("INBOX"`, wantErr: true},
+		{name: "unterminated string", args: `"" "INBOX`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseListCommand(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseListCommand(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseListCommand(%q) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func testListEntries() []*listEntry {
+	entry := func(name string, subscribed, selectable bool, specialUse *SpecialUse, attrs ...string) *listEntry {
+		return &listEntry{
+			FolderList: FolderList{
+				Name:       name,
+				Delimiter:  "/",
+				Attributes: attrs,
+				SpecialUse: specialUse,
+				Subscribed: subscribed,
+			},
+			selectable: selectable,
+		}
+	}
+	sent := SpecialUse("sent")
+	trash := SpecialUse(`\Trash`)
+
+	return []*listEntry{
+		entry("INBOX", true, true, ptrSpecialUse(SpecialUseInbox)),
+		entry("Sent", true, true, &sent),
+		entry("Trash", false, true, &trash),
+		entry("Projects", false, false, nil),
+		entry("Projects/Alpha", true, true, nil, `\Marked`),
+		entry("Projects/Beta", false, true, nil),
+	}
+}
+
+func TestSelectListEntries(t *testing.T) {
+	entries := testListEntries()
+
+	tests := []struct {
+		name      string
+		args      string
+		want      map[string][]string
+		childInfo []string
+	}{
+		{
+			name: "all folders",
+			args: `"" "*"`,
+			want: map[string][]string{
+				"INBOX":          {`\HasNoChildren`},
+				"Sent":           {`\HasNoChildren`, `\Sent`},
+				"Trash":          {`\HasNoChildren`, `\Trash`},
+				"Projects":       {`\Noselect`, `\HasChildren`},
+				"Projects/Alpha": {`\Marked`, `\HasNoChildren`},
+				"Projects/Beta":  {`\HasNoChildren`},
+			},
+		},
+		{
+			name: "top level",
+			args: `"" "%"`,
+			want: map[string][]string{
+				"INBOX":    {`\HasNoChildren`},
+				"Sent":     {`\HasNoChildren`, `\Sent`},
+				"Trash":    {`\HasNoChildren`, `\Trash`},
+				"Projects": {`\Noselect`, `\HasChildren`},
+			},
+		},
+		{
+			name: "reference",
+			args: `"Projects/" "%"`,
+			want: map[string][]string{
+				"Projects/Alpha": {`\Marked`, `\HasNoChildren`},
+				"Projects/Beta":  {`\HasNoChildren`},
+			},
+		},
+		{
+			name: "subscribed",
+			args: `(SUBSCRIBED) "" "*"`,
+			want: map[string][]string{
+				"INBOX":          {`\HasNoChildren`, `\Subscribed`},
+				"Sent":           {`\HasNoChildren`, `\Sent`, `\Subscribed`},
+				"Projects/Alpha": {`\Marked`, `\HasNoChildren`, `\Subscribed`},
+			},
+		},
+		{
+			name: "subscribed recursive match",
+			args: `(SUBSCRIBED RECURSIVEMATCH) "" "%"`,
+			want: map[string][]string{
+				"INBOX":    {`\HasNoChildren`, `\Subscribed`},
+				"Sent":     {`\HasNoChildren`, `\Sent`, `\Subscribed`},
+				"Projects": {`\Noselect`, `\HasChildren`},
+			},
+			childInfo: []string{"Projects"},
+		},
+		{
+			name: "return subscribed",
+			args: `"" "Projects/*" RETURN (SUBSCRIBED CHILDREN)`,
+			want: map[string][]string{
+				"Projects/Alpha": {`\Marked`, `\HasNoChildren`, `\Subscribed`},
+				"Projects/Beta":  {`\HasNoChildren`},
+			},
+		},
+		{
+			name: "special-use",
+			args: `(SPECIAL-USE) "" "*"`,
+			want: map[string][]string{
+				"Sent":  {`\HasNoChildren`, `\Sent`},
+				"Trash": {`\HasNoChildren`, `\Trash`},
+			},
+		},
+		{
+			name: "multiple patterns",
+			args: `"" (inbox "Trash")`,
+			want: map[string][]string{
+				"INBOX": {`\HasNoChildren`},
+				"Trash": {`\HasNoChildren`, `\Trash`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := parseListCommand(tt.args)
+			if err != nil {
+				t.Fatalf("parseListCommand(%q): %v", tt.args, err)
+			}
+
+			got := make(map[string][]string)
+			var childInfo []string
+			for _, e := range selectListEntries(entries, cmd) {
+				got[e.Name] = e.Attributes
+				if e.childSubscribed {
+					childInfo = append(childInfo, e.Name)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectListEntries(%q) = %v, want %v", tt.args, got, tt.want)
+			}
+			if !reflect.DeepEqual(childInfo, tt.childInfo) {
+				t.Errorf("selectListEntries(%q) CHILDINFO on %v, want %v", tt.args, childInfo, tt.childInfo)
+			}
+		})
+	}
+
+	// Listing doesn't change the folders listed
+	if len(entries[4].Attributes) != 1 {
+		t.Errorf("entry attributes changed to %v", entries[4].Attributes)
+	}
+}
+
+func TestSelectLsubEntries(t *testing.T) {
+	entries := testListEntries()
+
+	tests := []struct {
+		name      string
+		reference string
+		pattern   string
+		want      map[string][]string
+	}{
+		{
+			name:    "all subscribed",
+			pattern: "*",
+			want: map[string][]string{
+				"INBOX":          nil,
+				"Sent":           nil,
+				"Projects/Alpha": {`\Marked`},
+			},
+		},
+		{
+			name:    "unsubscribed parent of subscribed folder",
+			pattern: "%",
+			want: map[string][]string{
+				"INBOX":    nil,
+				"Sent":     nil,
+				"Projects": {`\Noselect`},
+			},
+		},
+		{
+			name:      "reference",
+			reference: "Projects/",
+			pattern:   "%",
+			want: map[string][]string{
+				"Projects/Alpha": {`\Marked`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string][]string)
+			for _, e := range selectLsubEntries(entries, tt.reference, tt.pattern) {
+				got[e.Name] = e.Attributes
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectLsubEntries(%q, %q) = %v, want %v", tt.reference, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecialUseAttribute(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`\Sent`, `\Sent`},
+		{"sent", `\Sent`},
+		{"junk", `\Junk`},
+		{"TRASH", `\Trash`},
+		{`\All`, `\All`},
+		{"inbox", ""},
+		{"", ""},
+		{"bogus", ""},
+	}
+
+	for _, tt := range tests {
+		su := SpecialUse(tt.input)
+		if got := specialUseAttribute(&su); got != tt.want {
+			t.Errorf("specialUseAttribute(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+	if got := specialUseAttribute(nil); got != "" {
+		t.Errorf("specialUseAttribute(nil) = %q, want empty", got)
+	}
+}
+
+func TestStoredAttributes(t *testing.T) {
+	got := storedAttributes([]string{`\HasNoChildren`, `\Sent`, "Marked", `\noselect`, `\Subscribed`})
+	want := []string{`\Marked`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("storedAttributes() = %v, want %v", got, want)
+	}
+}
+
+func TestCanonicalFolderPath(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"INBOX", "INBOX"},
+		{"inbox", "INBOX"},
+		{"Inbox/Receipts", "INBOX/Receipts"},
+		{"Inboxes", "Inboxes"},
+		{"Projects/", "Projects"},
+		{"Projects/Alpha", "Projects/Alpha"},
+	}
+
+	for _, tt := range tests {
+		if got := canonicalFolderPath(tt.input); got != tt.want {
+			t.Errorf("canonicalFolderPath(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -235,6 +235,11 @@ func (c *Connection) authenticateWithOAuth2(tag, email, token, method string) er
 		// Non-fatal, continue without shared mailboxes
 	}
 
+	if !c.acquireUserLease(tag, user.ID) {
+		authAttempts.WithLabelValues(method, "limit").Inc()
+		return nil
+	}
+
 	// Update context
 	c.ctx.User = user
 	c.ctx.Organization = org
@@ -1,40 +1,110 @@
 package imap
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/imap-server/repository"
 )
 
+// quotaRoot is a quota root and the usage reported for it
+type quotaRoot struct {
+	Name  string
+	Quota *Quota
+}
+
+// quotaRootName names a mailbox's quota root. A user has one mailbox per
+// domain, so personal mailboxes are named by their domain; shared mailboxes
+// by their path in the shared namespace.
+func quotaRootName(mailbox *Mailbox) string {
+	if mailbox.IsShared {
+		return "Shared/" + mailbox.Email
+	}
+	if mailbox.Domain != nil {
+		return mailbox.Domain.Name
+	}
+	return mailbox.Email
+}
+
+// quotaRoots returns the quota roots a mailbox's folders count against. A
+// mailbox without a storage limit has none. Callers handle any number of
+// roots, which RFC 2087 allows for a mailbox.
+func (c *Connection) quotaRoots(ctx context.Context, mailbox *Mailbox) ([]quotaRoot, error) {
+	quota, err := c.repo.GetQuota(ctx, mailbox.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if quota.Limit <= 0 {
+		return nil, nil
+	}
+
+	return []quotaRoot{{Name: quotaRootName(mailbox), Quota: quota}}, nil
+}
+
+// findQuotaRoot returns the mailbox whose quota root has the given name
+func (c *Connection) findQuotaRoot(name string) *Mailbox {
+	for _, mailboxes := range [][]*Mailbox{c.ctx.Mailboxes, c.ctx.SharedMailboxes} {
+		for _, mb := range mailboxes {
+			if strings.EqualFold(quotaRootName(mb), name) {
+				return mb
+			}
+		}
+	}
+	return nil
+}
+
+// sendQuota sends the QUOTA response for a root, with STORAGE in kilobytes
+func (c *Connection) sendQuota(root quotaRoot) {
+	usedKB := (root.Quota.Usage + 1023) / 1024
+	limitKB := root.Quota.Limit / 1024
+
+	messageQuota := ""
+	if root.Quota.MessageLimit > 0 {
+		messageQuota = fmt.Sprintf(" MESSAGE %d %d", root.Quota.MessageCount, root.Quota.MessageLimit)
+	}
+
+	c.sendUntagged(`QUOTA "%s" (STORAGE %d %d%s)`, root.Name, usedKB, limitKB, messageQuota)
+}
+
 // handleGetQuota handles the GETQUOTA command
 func (c *Connection) handleGetQuota(tag, args string) error {
 	if !c.requireAuth(tag) {
 		return nil
 	}
 
-	quotaRoot := strings.Trim(args, "\"")
+	name := strings.Trim(strings.TrimSpace(args), "\"")
 
-	// Parse quota root to find mailbox
-	mailbox, _, err := c.parseMailboxPath(quotaRoot)
-	if err != nil {
-		c.sendTagged(tag, "NO Invalid quota root")
+	mailbox := c.findQuotaRoot(name)
+	if mailbox == nil {
+		c.sendTagged(tag, "NO No such quota root")
 		return nil
 	}
 
 	ctx, cancel := c.getContext()
 	defer cancel()
 
-	quota, err := c.repo.GetQuota(ctx, mailbox.ID)
+	roots, err := c.quotaRoots(ctx, mailbox)
 	if err != nil {
+		c.logger.Error("Failed to get quota", zap.String("mailbox_id", mailbox.ID), zap.Error(err))
 		c.sendTagged(tag, "NO Quota not available")
 		return nil
 	}
+	for _, root := range roots {
+		if strings.EqualFold(root.Name, name) {
+			c.sendQuota(root)
+			c.sendTagged(tag, "OK GETQUOTA completed")
+			return nil
+		}
+	}
 
-	// Convert to KB for IMAP
-	usedKB := quota.Usage / 1024
-	limitKB := quota.Limit / 1024
-
-	c.sendUntagged("QUOTA \"%s\" (STORAGE %d %d)", quotaRoot, usedKB, limitKB)
-	c.sendTagged(tag, "OK GETQUOTA completed")
+	c.sendTagged(tag, "NO No such quota root")
 	return nil
 }
 
@@ -44,9 +114,9 @@ func (c *Connection) handleGetQuotaRoot(tag, args string) error {
 		return nil
 	}
 
-	mailboxName := strings.Trim(args, "\"")
+	mailboxName := strings.Trim(strings.TrimSpace(args), "\"")
 
-	mailbox, _, err := c.parseMailboxPath(mailboxName)
+	mailbox, folderPath, err := c.parseMailboxPath(mailboxName)
 	if err != nil {
 		c.sendTagged(tag, "NO Invalid mailbox")
 		return nil
@@ -55,30 +125,27 @@ func (c *Connection) handleGetQuotaRoot(tag, args string) error {
 	ctx, cancel := c.getContext()
 	defer cancel()
 
-	// Determine quota root
-	// For our implementation, quota root is the domain
-	quotaRoot := mailbox.Domain.Name
+	if _, err := c.repo.GetFolderByPath(ctx, mailbox.ID, folderPath); err != nil {
+		c.sendTagged(tag, "NO Mailbox does not exist")
+		return nil
+	}
 
-	quota, err := c.repo.GetQuota(ctx, mailbox.ID)
+	roots, err := c.quotaRoots(ctx, mailbox)
 	if err != nil {
-		// No quota configured
-		c.sendUntagged(`QUOTAROOT "%s"`, mailboxName)
-		c.sendTagged(tag, "OK GETQUOTAROOT completed")
+		c.logger.Error("Failed to get quota", zap.String("mailbox_id", mailbox.ID), zap.Error(err))
+		c.sendTagged(tag, "NO Quota not available")
 		return nil
 	}
 
-	// Convert to KB
-	usedKB := quota.StorageUsed / 1024
-	limitKB := quota.StorageLimit / 1024
-
-	// Message count quota
-	messageQuota := ""
-	if quota.MessageLimit > 0 {
-		messageQuota = fmt.Sprintf(" MESSAGE %d %d", quota.MessageCount, quota.MessageLimit)
+	// A mailbox without a storage limit has no quota roots
+	response := fmt.Sprintf(`QUOTAROOT "%s"`, mailboxName)
+	for _, root := range roots {
+		response += fmt.Sprintf(` "%s"`, root.Name)
+	}
+	c.sendUntagged("%s", response)
+	for _, root := range roots {
+		c.sendQuota(root)
 	}
-
-	c.sendUntagged(`QUOTAROOT "%s" "%s"`, mailboxName, quotaRoot)
-	c.sendUntagged(`QUOTA "%s" (STORAGE %d %d%s)`, quotaRoot, usedKB, limitKB, messageQuota)
 	c.sendTagged(tag, "OK GETQUOTAROOT completed")
 	return nil
 }
@@ -108,8 +175,8 @@ func (c *Connection) handleSetQuota(tag, args string) error {
 	// Parse quota limits from parenthesized list
 	limits := parseQuotaLimits(args)
 
-	mailbox, _, err := c.parseMailboxPath(quotaRoot)
-	if err != nil {
+	mailbox := c.findQuotaRoot(quotaRoot)
+	if mailbox == nil {
 		c.sendTagged(tag, "NO Invalid quota root")
 		return nil
 	}
@@ -166,22 +233,20 @@ func parseQuotaLimits(args string) map[string]int64 {
 	return limits
 }
 
-// checkQuota checks if an operation would exceed quota
-func (c *Connection) checkQuota(mailboxID string, additionalStorage int64) error {
-	ctx, cancel := c.getContext()
-	defer cancel()
-
-	quota, err := c.repo.GetQuota(ctx, mailboxID)
+// overQuotaRoot returns the first quota root that adding size bytes to the
+// mailbox would exceed, if any
+func (c *Connection) overQuotaRoot(ctx context.Context, mailbox *Mailbox, size int64) (*quotaRoot, error) {
+	roots, err := c.quotaRoots(ctx, mailbox)
 	if err != nil {
-		// No quota configured, allow operation
-		return nil
+		return nil, err
 	}
 
-	if quota.StorageUsed+additionalStorage > quota.StorageLimit {
-		return fmt.Errorf("quota exceeded")
+	for i := range roots {
+		if roots[i].Quota.Usage+size > roots[i].Quota.Limit {
+			return &roots[i], nil
+		}
 	}
-
-	return nil
+	return nil, nil
 }
 
 // QuotaWarning represents a quota warning notification
@@ -120,3 +120,23 @@ func TestQuota_Fields(t *testing.T) {
 		t.Errorf("Usage percentage = %d%%, want 50%%", usagePercent)
 	}
 }
+
+func TestQuotaRootName(t *testing.T) {
+	tests := []struct {
+		name    string
+		mailbox *Mailbox
+		want    string
+	}{
+		{"personal", &Mailbox{Email: "alice@example.com", Domain: &Domain{Name: "example.com"}}, "example.com"},
+		{"shared", &Mailbox{Email: "sales@example.com", Domain: &Domain{Name: "example.com"}, IsShared: true}, "Shared/sales@example.com"},
+		{"no domain", &Mailbox{Email: "alice@example.com"}, "alice@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotaRootName(tt.mailbox); got != tt.want {
+				t.Errorf("quotaRootName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package imap
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// goRedisClient adapts a go-redis client to the RedisClient interface used by
+// the NotifyHub
+type goRedisClient struct {
+	client *redis.Client
+}
+
+// NewRedisClient wraps a go-redis client for IDLE notifications
+func NewRedisClient(client *redis.Client) RedisClient {
+	return &goRedisClient{client: client}
+}
+
+// Publish publishes a message on a channel
+func (r *goRedisClient) Publish(ctx context.Context, channel string, message interface{}) error {
+	return r.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe subscribes to channel patterns and returns the message payloads.
+// The returned channel is closed when ctx is cancelled or the connection is
+// lost, after which the caller should subscribe again.
+func (r *goRedisClient) Subscribe(ctx context.Context, patterns ...string) (<-chan string, error) {
+	pubsub := r.client.PSubscribe(ctx, patterns...)
+
+	// Wait for the subscription to be confirmed so connection errors surface
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	out := make(chan string, 100)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+
+		for {
+			msg, err := pubsub.ReceiveMessage(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case out <- msg.Payload:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// acquireLeaseScript drops expired leases from a sorted set scored by expiry,
+// then adds the lease if the set is under the limit
+var acquireLeaseScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if not redis.call('ZSCORE', KEYS[1], ARGV[3]) and redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[4]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+// redisLeaseStore keeps connection leases in Redis sorted sets so connection
+// limits hold across instances
+type redisLeaseStore struct {
+	client *redis.Client
+}
+
+// NewRedisLeaseStore wraps a go-redis client for connection limits
+func NewRedisLeaseStore(client *redis.Client) LeaseStore {
+	return &redisLeaseStore{client: client}
+}
+
+// Acquire adds a lease unless the key is at its limit
+func (r *redisLeaseStore) Acquire(ctx context.Context, key, member string, limit int, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	ok, err := acquireLeaseScript.Run(ctx, r.client, []string{key},
+		now.UnixMilli(), now.Add(ttl).UnixMilli(), member, limit, ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return ok == 1, nil
+}
+
+// Release removes a lease
+func (r *redisLeaseStore) Release(ctx context.Context, key, member string) error {
+	return r.client.ZRem(ctx, key, member).Err()
+}
+
+// Refresh pushes back the expiry of live leases
+func (r *redisLeaseStore) Refresh(ctx context.Context, leases []ConnLease, ttl time.Duration) error {
+	expiry := float64(time.Now().Add(ttl).UnixMilli())
+
+	pipe := r.client.Pipeline()
+	for _, lease := range leases {
+		pipe.ZAddXX(ctx, lease.Key, redis.Z{Score: expiry, Member: lease.Member})
+		pipe.PExpire(ctx, lease.Key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -3,8 +3,10 @@ package imap
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -34,6 +36,10 @@ var (
 		Name: "imap_auth_attempts_total",
 		Help: "Total authentication attempts",
 	}, []string{"method", "result"})
+	connectionsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "imap_connections_rejected_total",
+		Help: "Connections rejected for exceeding a per-IP or per-user limit",
+	}, []string{"reason"})
 
 	// IDLE notification metrics
 	idleNotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
@@ -67,6 +73,7 @@ type Server struct {
 	connections     map[string]*Connection
 	connectionsMu   sync.RWMutex
 	connectionCount int64
+	connLimiter     *ConnectionLimiter
 
 	notifyHub      *NotifyHub
 	shutdownChan   chan struct{}
@@ -83,6 +90,12 @@ func NewServer(cfg *config.Config, repo *repository.Repository, logger *zap.Logg
 		notifyHub:    NewNotifyHub(logger),
 		shutdownChan: make(chan struct{}),
 	}
+	s.connLimiter = NewConnectionLimiter(
+		cfg.Server.ConnectionLimit,
+		cfg.Server.MaxConnectionsPerUser,
+		s.notifyHub.instanceID,
+		logger.Named("connlimit"),
+	)
 
 	// Setup TLS if enabled
 	if cfg.TLS.Enabled {
@@ -114,6 +127,18 @@ func (s *Server) SetOAuth2Config(oauth2Config *OAuth2Config) {
 	}
 }
 
+// SetRedisClient enables cross-instance IDLE notifications through Redis.
+// It must be called before Start.
+func (s *Server) SetRedisClient(client RedisClient) {
+	s.notifyHub.redis = client
+}
+
+// SetConnectionLeaseStore shares per-IP and per-user connection counts with
+// other instances through store. It must be called before Start.
+func (s *Server) SetConnectionLeaseStore(store LeaseStore) {
+	s.connLimiter.SetStore(store)
+}
+
 // SupportsOAuth2 returns true if OAuth2 authentication is enabled
 func (s *Server) SupportsOAuth2() bool {
 	return s.oauth2Validator != nil && s.oauth2Validator.config.Enabled
@@ -124,6 +149,13 @@ func (s *Server) Start() error {
 	// Start notification hub
 	s.notifyHub.Start()
 
+	// Keep this instance's connection leases alive
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.connLimiter.Run(s.shutdownChan)
+	}()
+
 	// Start plain text listener
 	addr := fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port)
 	listener, err := net.Listen("tcp", addr)
@@ -221,8 +253,16 @@ func (s *Server) acceptConnections(listener net.Listener, isTLS bool) {
 			continue
 		}
 
+		connID := generateConnectionID()
+		if !s.connLimiter.AcquireIP(connID, conn.RemoteAddr().String()) {
+			s.logger.Warn("Per-IP connection limit reached", zap.String("remote", conn.RemoteAddr().String()))
+			conn.Write([]byte("* BYE [LIMIT] Too many connections from your address\r\n"))
+			conn.Close()
+			continue
+		}
+
 		// Create and handle connection
-		imapConn := s.newConnection(conn, isTLS)
+		imapConn := s.newConnection(conn, connID, isTLS)
 		s.registerConnection(imapConn)
 
 		s.wg.Add(1)
@@ -235,18 +275,18 @@ func (s *Server) acceptConnections(listener net.Listener, isTLS bool) {
 }
 
 // newConnection creates a new IMAP connection
-func (s *Server) newConnection(conn net.Conn, isTLS bool) *Connection {
+func (s *Server) newConnection(conn net.Conn, id string, isTLS bool) *Connection {
 	atomic.AddInt64(&s.connectionCount, 1)
 	totalConnections.Inc()
 	activeConnections.Inc()
 
 	return &Connection{
-		id:              generateConnectionID(),
+		id:              id,
 		conn:            conn,
 		server:          s,
 		config:          s.config,
 		repo:            s.repo,
-		logger:          s.logger.With(zap.String("conn_id", generateConnectionID())),
+		logger:          s.logger.With(zap.String("conn_id", id)),
 		notifyHub:       s.notifyHub,
 		oauth2Validator: s.oauth2Validator,
 		ctx: &ConnectionContext{
@@ -268,12 +308,15 @@ func (s *Server) registerConnection(conn *Connection) {
 	s.connectionsMu.Unlock()
 }
 
-// unregisterConnection unregisters a connection
+// unregisterConnection unregisters a connection and releases its
+// connection limit leases
 func (s *Server) unregisterConnection(conn *Connection) {
 	s.connectionsMu.Lock()
 	delete(s.connections, conn.id)
 	s.connectionsMu.Unlock()
 
+	s.connLimiter.Release(conn.id)
+
 	atomic.AddInt64(&s.connectionCount, -1)
 	activeConnections.Dec()
 }
@@ -283,6 +326,11 @@ func (s *Server) getCapabilities(isTLS bool) []string {
 	caps := make([]string, len(s.config.IMAP.Capabilities))
 	copy(caps, s.config.IMAP.Capabilities)
 
+	// GETQUOTA and GETQUOTAROOT are always handled
+	if !slices.Contains(caps, "QUOTA") {
+		caps = append(caps, "QUOTA")
+	}
+
 	if !isTLS && s.config.TLS.StartTLS {
 		caps = append(caps, "STARTTLS")
 	}
@@ -516,6 +564,22 @@ func (h *NotifyHub) UnsubscribeAll(connectionID string) {
 
 // Notify sends a notification to all subscribers of a mailbox with coalescing
 func (h *NotifyHub) Notify(mailboxID string, notification IdleNotification) {
+	h.NotifyOthers(mailboxID, "", notification)
+}
+
+// NotifyOthers is like Notify but skips the given connection, for changes
+// the originating session has already reported to its own client
+func (h *NotifyHub) NotifyOthers(mailboxID, connectionID string, notification IdleNotification) {
+	h.notifyLocal(mailboxID, connectionID, notification)
+
+	// Publish to Redis for cross-instance delivery if enabled
+	if h.redis != nil {
+		go h.publishToRedis(mailboxID, notification)
+	}
+}
+
+// notifyLocal delivers a notification to subscribers on this instance only
+func (h *NotifyHub) notifyLocal(mailboxID, excludeConnectionID string, notification IdleNotification) {
 	h.mu.RLock()
 	subs, ok := h.subscribers[mailboxID]
 	if !ok {
@@ -525,7 +589,10 @@ func (h *NotifyHub) Notify(mailboxID string, notification IdleNotification) {
 
 	// Copy subscriptions to avoid holding lock during sends
 	subsCopy := make([]*NotifySubscription, 0, len(subs))
-	for _, sub := range subs {
+	for connID, sub := range subs {
+		if connID == excludeConnectionID {
+			continue
+		}
 		subsCopy = append(subsCopy, sub)
 	}
 	h.mu.RUnlock()
@@ -536,11 +603,6 @@ func (h *NotifyHub) Notify(mailboxID string, notification IdleNotification) {
 	}
 
 	idleNotificationsSent.WithLabelValues(mailboxID, notification.Type).Inc()
-
-	// Publish to Redis for cross-instance delivery if enabled
-	if h.redis != nil {
-		go h.publishToRedis(mailboxID, notification)
-	}
 }
 
 // sendToSubscriber sends a notification to a single subscriber with coalescing
@@ -690,6 +752,14 @@ func (h *NotifyHub) checkStuckConnections() {
 	}
 }
 
+// redisNotification is the wire format for notifications published on
+// imap:notify:<mailboxID>. Publishers other than IMAP instances, such as the
+// SMTP delivery path, leave InstanceID empty.
+type redisNotification struct {
+	IdleNotification
+	InstanceID string `json:"instance_id,omitempty"`
+}
+
 // publishToRedis publishes notification to Redis for cross-instance delivery
 func (h *NotifyHub) publishToRedis(mailboxID string, notification IdleNotification) {
 	if h.redis == nil {
@@ -699,41 +769,69 @@ func (h *NotifyHub) publishToRedis(mailboxID string, notification IdleNotificati
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	notification.MailboxID = mailboxID
+	payload, err := json.Marshal(redisNotification{IdleNotification: notification, InstanceID: h.instanceID})
+	if err != nil {
+		h.logger.Warn("Failed to encode notification", zap.Error(err))
+		return
+	}
+
 	channel := fmt.Sprintf("imap:notify:%s", mailboxID)
-	// In production, serialize notification properly
-	if err := h.redis.Publish(ctx, channel, notification); err != nil {
+	if err := h.redis.Publish(ctx, channel, payload); err != nil {
 		h.logger.Warn("Failed to publish notification to Redis",
 			zap.String("mailbox_id", mailboxID),
 			zap.Error(err))
 	}
 }
 
-// runRedisSubscriber subscribes to Redis for cross-instance notifications
+// runRedisSubscriber subscribes to Redis for cross-instance notifications,
+// resubscribing after connection failures until the hub is stopped
 func (h *NotifyHub) runRedisSubscriber() {
 	if h.redis == nil {
 		return
 	}
 
-	ctx := context.Background()
-	channel := "imap:notify:*"
-
-	msgChan, err := h.redis.Subscribe(ctx, channel)
-	if err != nil {
-		h.logger.Error("Failed to subscribe to Redis notifications", zap.Error(err))
-		return
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-h.stopChan
+		cancel()
+	}()
 
 	for {
+		msgChan, err := h.redis.Subscribe(ctx, "imap:notify:*")
+		if err != nil {
+			h.logger.Error("Failed to subscribe to Redis notifications", zap.Error(err))
+		} else {
+			for msg := range msgChan {
+				h.handleRedisNotification(msg)
+			}
+		}
+
 		select {
 		case <-h.stopChan:
 			return
-		case msg := <-msgChan:
-			// Handle incoming Redis notification
-			h.logger.Debug("Received Redis notification", zap.String("message", msg))
+		case <-time.After(5 * time.Second):
 		}
 	}
 }
 
+// handleRedisNotification delivers a notification received from Redis to
+// local subscribers. Notifications this instance published were already
+// delivered locally and are skipped.
+func (h *NotifyHub) handleRedisNotification(msg string) {
+	var notification redisNotification
+	if err := json.Unmarshal([]byte(msg), &notification); err != nil {
+		h.logger.Warn("Ignoring malformed Redis notification", zap.Error(err))
+		return
+	}
+	if notification.InstanceID == h.instanceID || notification.MailboxID == "" {
+		return
+	}
+
+	h.notifyLocal(notification.MailboxID, "", notification.IdleNotification)
+}
+
 // Helper functions
 
 var connectionCounter uint64
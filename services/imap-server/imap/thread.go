@@ -1,9 +1,14 @@
 package imap
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"mime"
+	"net/textproto"
 	"sort"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -17,8 +22,10 @@ const (
 )
 
 // ThreadNode represents a node in a thread tree
+// ID is a UID or sequence number; it is 0 for a dummy node standing in for a
+// message that is referenced but not present in the mailbox
 type ThreadNode struct {
-	UID      uint32
+	ID       uint32
 	Children []*ThreadNode
 }
 
@@ -28,6 +35,11 @@ func (c *Connection) handleThread(tag, args string, uid bool) error {
 		return nil
 	}
 
+	command := "THREAD"
+	if uid {
+		command = "UID THREAD"
+	}
+
 	// Check if THREAD is enabled
 	if !c.config.IMAP.EnableThread {
 		c.sendTagged(tag, "BAD THREAD extension not enabled")
@@ -35,14 +47,14 @@ func (c *Connection) handleThread(tag, args string, uid bool) error {
 	}
 
 	// Parse THREAD command: THREAD algorithm charset search-criteria
-	parts := strings.SplitN(args, " ", 3)
+	parts := strings.SplitN(strings.TrimSpace(args), " ", 3)
 	if len(parts) < 3 {
 		c.sendTagged(tag, "BAD THREAD requires algorithm, charset, and search criteria")
 		return nil
 	}
 
 	algorithm := ThreadAlgorithm(strings.ToUpper(parts[0]))
-	// charset := parts[1] // Usually "UTF-8" or "US-ASCII"
+	charset := strings.ToUpper(strings.Trim(parts[1], `"`))
 	searchCriteria := parts[2]
 
 	// Validate algorithm
@@ -50,6 +62,10 @@ func (c *Connection) handleThread(tag, args string, uid bool) error {
 		c.sendTagged(tag, "BAD Unknown threading algorithm")
 		return nil
 	}
+	if charset != "UTF-8" && charset != "US-ASCII" {
+		c.sendTagged(tag, "NO [BADCHARSET (UTF-8 US-ASCII)] Unsupported charset")
+		return nil
+	}
 
 	ctx, cancel := c.getContext()
 	defer cancel()
@@ -57,245 +73,505 @@ func (c *Connection) handleThread(tag, args string, uid bool) error {
 	// Parse search criteria
 	criteria := parseSearchCriteria(searchCriteria)
 
-	// Search for matching messages
-	results, err := c.searchMessages(ctx, c.ctx.ActiveFolder.ID, criteria, uid)
+	// Load the mailbox with sequence numbers and keep the matching messages
+	messages, err := c.findMessages(ctx, c.ctx.ActiveFolder.ID, criteria)
 	if err != nil {
-		c.logger.Error("Failed to search messages for THREAD", zap.Error(err))
+		c.logger.Error("Failed to get messages for THREAD", zap.Error(err))
 		c.sendTagged(tag, "NO THREAD failed")
 		return nil
 	}
 
-	// If no results, return empty THREAD response
-	if len(results) == 0 {
-		c.sendUntagged("THREAD")
-		command := "THREAD"
+	var matched []*threadMessage
+	for _, msg := range messages {
+		id := msg.SequenceNum
 		if uid {
-			command = "UID THREAD"
+			id = msg.UID
 		}
-		c.sendTagged(tag, "OK %s completed", command)
-		return nil
-	}
-
-	// Get messages for threading
-	messages, err := c.repo.GetMessagesBySequence(ctx, c.ctx.ActiveFolder.ID, "1:*", uid)
-	if err != nil {
-		c.logger.Error("Failed to get messages for THREAD", zap.Error(err))
-		c.sendTagged(tag, "NO THREAD failed")
-		return nil
+		matched = append(matched, newThreadMessage(msg, id))
 	}
 
 	// Build thread tree based on algorithm
 	var threads []*ThreadNode
 	switch algorithm {
 	case ThreadAlgorithmOrderedSubject:
-		threads = c.threadByOrderedSubject(messages)
+		threads = threadByOrderedSubject(matched)
 	case ThreadAlgorithmReferences:
-		threads = c.threadByReferences(messages)
+		threads = threadByReferences(matched)
 	}
 
 	// Format and send response
-	response := formatThreadResponse(threads)
-	c.sendUntagged("THREAD %s", response)
-
-	command := "THREAD"
-	if uid {
-		command = "UID THREAD"
+	if len(threads) == 0 {
+		c.sendUntagged("THREAD")
+	} else {
+		c.sendUntagged("THREAD %s", formatThreadResponse(threads))
 	}
+
 	c.sendTagged(tag, "OK %s completed", command)
 	return nil
 }
 
-// threadByOrderedSubject implements the ORDEREDSUBJECT algorithm (RFC 5256)
-// Groups messages by base subject, ordered by sent date
-func (c *Connection) threadByOrderedSubject(messages []*Message) []*ThreadNode {
-	// Group messages by normalized subject
-	subjectGroups := make(map[string][]*Message)
+// threadMessage holds the fields the threading algorithms sort and group by
+type threadMessage struct {
+	id           uint32
+	seq          uint32
+	date         time.Time // Sent date, or INTERNALDATE if the message has no Date header
+	subject      string    // Base subject (RFC 5256 Section 2.1)
+	isReplyOrFwd bool
+	messageID    string
+	references   []string
+}
 
-	for _, msg := range messages {
-		baseSubject := normalizeSubject(msg.Subject)
-		subjectGroups[baseSubject] = append(subjectGroups[baseSubject], msg)
+func newThreadMessage(msg *Message, id uint32) *threadMessage {
+	tm := &threadMessage{
+		id:   id,
+		seq:  msg.SequenceNum,
+		date: msg.Date,
+	}
+	if tm.date.IsZero() {
+		tm.date = msg.ReceivedAt
 	}
+	tm.subject, tm.isReplyOrFwd = baseSubject(msg.Subject)
 
-	var threads []*ThreadNode
+	if ids := parseMsgIDs(msg.MessageID); len(ids) > 0 {
+		tm.messageID = ids[0]
+	}
 
-	// Sort subjects alphabetically for consistent ordering
-	var subjects []string
-	for subject := range subjectGroups {
-		subjects = append(subjects, subject)
-	}
-	sort.Strings(subjects)
-
-	for _, subject := range subjects {
-		group := subjectGroups[subject]
-
-		// Sort messages within group by date
-		sort.Slice(group, func(i, j int) bool {
-			return group[i].Date.Before(group[j].Date)
-		})
-
-		// Create thread structure
-		if len(group) == 1 {
-			// Single message, no threading needed
-			threads = append(threads, &ThreadNode{UID: group[0].UID})
-		} else {
-			// First message is the root
-			root := &ThreadNode{UID: group[0].UID}
-			for i := 1; i < len(group); i++ {
-				root.Children = append(root.Children, &ThreadNode{UID: group[i].UID})
-			}
-			threads = append(threads, root)
+	// Without References, the first valid In-Reply-To message ID is used
+	tm.references = parseMsgIDs(strings.Join(msg.References, " "))
+	if len(tm.references) == 0 {
+		if ids := parseMsgIDs(msg.InReplyTo); len(ids) > 0 {
+			tm.references = ids[:1]
+		}
+	}
+
+	return tm
+}
+
+// sentBefore orders messages by sent date, then by sequence number
+func (m *threadMessage) sentBefore(other *threadMessage) bool {
+	if !m.date.Equal(other.date) {
+		return m.date.Before(other.date)
+	}
+	return m.seq < other.seq
+}
+
+// threadByOrderedSubject implements the ORDEREDSUBJECT algorithm (RFC 5256 Section 3)
+// Messages sharing a base subject form one thread: the earliest message is the
+// parent and the rest are its children. Threads are ordered by their first message
+func threadByOrderedSubject(messages []*threadMessage) []*ThreadNode {
+	sorted := make([]*threadMessage, len(messages))
+	copy(sorted, messages)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].subject != sorted[j].subject {
+			return sorted[i].subject < sorted[j].subject
 		}
+		return sorted[i].sentBefore(sorted[j])
+	})
+
+	type subjectThread struct {
+		first *threadMessage
+		node  *ThreadNode
 	}
+	var groups []subjectThread
 
+	for i := 0; i < len(sorted); {
+		group := subjectThread{first: sorted[i], node: &ThreadNode{ID: sorted[i].id}}
+		j := i + 1
+		for ; j < len(sorted) && sorted[j].subject == sorted[i].subject; j++ {
+			group.node.Children = append(group.node.Children, &ThreadNode{ID: sorted[j].id})
+		}
+		groups = append(groups, group)
+		i = j
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].first.sentBefore(groups[j].first)
+	})
+
+	threads := make([]*ThreadNode, len(groups))
+	for i, group := range groups {
+		threads[i] = group.node
+	}
 	return threads
 }
 
-// threadByReferences implements the REFERENCES algorithm (RFC 5256)
-// Uses In-Reply-To and References headers to build thread tree
-func (c *Connection) threadByReferences(messages []*Message) []*ThreadNode {
-	// Build message ID to message map
-	messageByID := make(map[string]*Message)
-	messageByUID := make(map[uint32]*Message)
+// threadContainer is a node in the REFERENCES algorithm; msg is nil for dummies
+type threadContainer struct {
+	msg      *threadMessage
+	parent   *threadContainer
+	children []*threadContainer
+}
 
-	for _, msg := range messages {
-		if msg.MessageID != "" {
-			messageByID[msg.MessageID] = msg
+func (c *threadContainer) addChild(child *threadContainer) {
+	if child.parent != nil {
+		child.parent.removeChild(child)
+	}
+	child.parent = c
+	c.children = append(c.children, child)
+}
+
+func (c *threadContainer) removeChild(child *threadContainer) {
+	for i, existing := range c.children {
+		if existing == child {
+			c.children = append(c.children[:i], c.children[i+1:]...)
+			break
 		}
-		messageByUID[msg.UID] = msg
 	}
+	child.parent = nil
+}
 
-	// Track which messages are children (have a parent)
-	hasParent := make(map[uint32]bool)
+// firstMessage returns the container's message, or that of its first child for a dummy
+func (c *threadContainer) firstMessage() *threadMessage {
+	for c.msg == nil && len(c.children) > 0 {
+		c = c.children[0]
+	}
+	return c.msg
+}
 
-	// Build parent-child relationships
-	childrenMap := make(map[uint32][]*ThreadNode)
+// wouldLoop reports whether making child a child of parent creates a cycle
+func wouldLoop(parent, child *threadContainer) bool {
+	for p := parent; p != nil; p = p.parent {
+		if p == child {
+			return true
+		}
+	}
+	return false
+}
 
-	for _, msg := range messages {
-		var parentUID uint32
+// threadByReferences implements the REFERENCES algorithm (RFC 5256 Section 4)
+// Messages are linked through their References/In-Reply-To headers; the
+// resulting threads are then merged by base subject, so replies whose
+// references are missing still join their conversation
+func threadByReferences(messages []*threadMessage) []*ThreadNode {
+	idTable := make(map[string]*threadContainer)
+	var containers []*threadContainer
+
+	getContainer := func(id string) *threadContainer {
+		if c, ok := idTable[id]; ok {
+			return c
+		}
+		c := &threadContainer{}
+		idTable[id] = c
+		containers = append(containers, c)
+		return c
+	}
 
-		// Check In-Reply-To first
-		if msg.InReplyTo != "" {
-			if parent, ok := messageByID[msg.InReplyTo]; ok {
-				parentUID = parent.UID
+	// Step 1: link messages to their references
+	for _, msg := range messages {
+		var container *threadContainer
+		if msg.messageID != "" {
+			if existing, ok := idTable[msg.messageID]; !ok || existing.msg == nil {
+				container = getContainer(msg.messageID)
 			}
 		}
+		if container == nil {
+			// Missing or duplicate Message-ID: thread it as a unique message
+			container = &threadContainer{}
+			containers = append(containers, container)
+		}
+		container.msg = msg
 
-		// If no parent found via In-Reply-To, check References
-		if parentUID == 0 && len(msg.References) > 0 {
-			// References are ordered, last one is immediate parent
-			for i := len(msg.References) - 1; i >= 0; i-- {
-				if parent, ok := messageByID[msg.References[i]]; ok {
-					parentUID = parent.UID
-					break
-				}
+		var prev *threadContainer
+		for _, ref := range msg.references {
+			refContainer := getContainer(ref)
+			if prev != nil && refContainer.parent == nil && !wouldLoop(prev, refContainer) {
+				prev.addChild(refContainer)
 			}
+			prev = refContainer
 		}
 
-		if parentUID != 0 && parentUID != msg.UID {
-			hasParent[msg.UID] = true
-			childrenMap[parentUID] = append(childrenMap[parentUID], &ThreadNode{UID: msg.UID})
+		// The last reference is the parent, replacing any link made from a
+		// truncated References header of another message
+		if container.parent != nil {
+			container.parent.removeChild(container)
+		}
+		if prev != nil && !wouldLoop(prev, container) {
+			prev.addChild(container)
 		}
 	}
 
-	// Build thread trees starting from root messages (messages without parents)
-	var threads []*ThreadNode
-
-	for _, msg := range messages {
-		if !hasParent[msg.UID] {
-			root := buildThreadTree(msg.UID, childrenMap)
-			threads = append(threads, root)
+	// Step 2: gather the root set
+	var roots []*threadContainer
+	for _, c := range containers {
+		if c.parent == nil {
+			roots = append(roots, c)
 		}
 	}
 
-	// Sort threads by the UID of their root message
-	sort.Slice(threads, func(i, j int) bool {
-		return threads[i].UID < threads[j].UID
-	})
+	// Steps 3 and 4: prune dummies and sort by sent date
+	roots = pruneThreadContainers(nil, roots)
+	sortThreadContainers(roots)
+
+	// Step 5: merge threads with the same base subject
+	roots = groupThreadsBySubject(roots)
+
+	// Step 6: sort siblings at every level
+	sortThreadContainers(roots)
 
+	threads := make([]*ThreadNode, len(roots))
+	for i, root := range roots {
+		threads[i] = root.node()
+	}
 	return threads
 }
 
-// buildThreadTree recursively builds a thread tree
-func buildThreadTree(uid uint32, childrenMap map[uint32][]*ThreadNode) *ThreadNode {
-	node := &ThreadNode{UID: uid}
+// pruneThreadContainers removes empty dummies and promotes the children of
+// other dummies, except where that would put several children in the root set
+func pruneThreadContainers(parent *threadContainer, list []*threadContainer) []*threadContainer {
+	var result []*threadContainer
+	for _, c := range list {
+		c.children = pruneThreadContainers(c, c.children)
+		if c.msg == nil {
+			if len(c.children) == 0 {
+				continue
+			}
+			if parent != nil || len(c.children) == 1 {
+				result = append(result, c.children...)
+				continue
+			}
+		}
+		result = append(result, c)
+	}
 
-	children := childrenMap[uid]
-	for _, child := range children {
-		childNode := buildThreadTree(child.UID, childrenMap)
-		node.Children = append(node.Children, childNode)
+	for _, c := range result {
+		c.parent = parent
 	}
+	return result
+}
 
-	// Sort children by UID for consistent ordering
-	sort.Slice(node.Children, func(i, j int) bool {
-		return node.Children[i].UID < node.Children[j].UID
+// sortThreadContainers sorts siblings by sent date, recursively. A dummy sorts
+// by the date of its first child
+func sortThreadContainers(list []*threadContainer) {
+	for _, c := range list {
+		sortThreadContainers(c.children)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].firstMessage().sentBefore(list[j].firstMessage())
 	})
+}
+
+// groupThreadsBySubject merges root threads that share a base subject (RFC 5256 Section 4, step 5)
+func groupThreadsBySubject(roots []*threadContainer) []*threadContainer {
+	table := make(map[string]*threadContainer)
+	for _, c := range roots {
+		msg := c.firstMessage()
+		if msg.subject == "" {
+			continue
+		}
+		existing, ok := table[msg.subject]
+		if !ok || (existing.msg != nil && (c.msg == nil || (existing.msg.isReplyOrFwd && !msg.isReplyOrFwd))) {
+			table[msg.subject] = c
+		}
+	}
+
+	var result []*threadContainer
+	for _, c := range roots {
+		msg := c.firstMessage()
+		existing := table[msg.subject]
+		if msg.subject == "" || existing == c {
+			result = append(result, c)
+			continue
+		}
 
+		switch {
+		case existing.msg == nil && c.msg == nil:
+			for len(c.children) > 0 {
+				existing.addChild(c.children[0])
+			}
+		case existing.msg == nil:
+			existing.addChild(c)
+		case c.msg != nil && c.msg.isReplyOrFwd && !existing.msg.isReplyOrFwd:
+			existing.addChild(c)
+		default:
+			// Turn the table entry into a dummy in place so its position in
+			// the root set is kept
+			moved := &threadContainer{msg: existing.msg}
+			for len(existing.children) > 0 {
+				moved.addChild(existing.children[0])
+			}
+			existing.msg = nil
+			existing.addChild(moved)
+			existing.addChild(c)
+		}
+	}
+
+	return result
+}
+
+func (c *threadContainer) node() *ThreadNode {
+	node := &ThreadNode{}
+	if c.msg != nil {
+		node.ID = c.msg.id
+	}
+	for _, child := range c.children {
+		node.Children = append(node.Children, child.node())
+	}
 	return node
 }
 
-// normalizeSubject removes Re:, Fwd:, etc. prefixes from subject
-func normalizeSubject(subject string) string {
-	subject = strings.TrimSpace(subject)
-	subject = strings.ToLower(subject)
+// baseSubject extracts the base subject used for threading (RFC 5256 Section 2.1)
+// and reports whether the subject marks a reply or forward
+func baseSubject(subject string) (string, bool) {
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	subject = strings.Join(strings.Fields(subject), " ")
 
-	// Remove common prefixes
-	prefixes := []string{"re:", "fwd:", "fw:", "aw:", "sv:", "antw:"}
+	isReplyOrFwd := false
 	for {
-		changed := false
-		for _, prefix := range prefixes {
-			if strings.HasPrefix(subject, prefix) {
-				subject = strings.TrimPrefix(subject, prefix)
-				subject = strings.TrimSpace(subject)
-				changed = true
+		// Remove subj-trailer: "(fwd)" and whitespace
+		for {
+			trimmed := strings.TrimRight(subject, " ")
+			if n := len(trimmed) - len("(fwd)"); n >= 0 && strings.EqualFold(trimmed[n:], "(fwd)") {
+				trimmed = trimmed[:n]
+				isReplyOrFwd = true
+			}
+			if trimmed == subject {
+				break
 			}
+			subject = trimmed
 		}
-		if !changed {
+
+		// Remove subj-leader and subj-blob prefixes
+		for {
+			subject = strings.TrimLeft(subject, " ")
+
+			rest := subject
+			for {
+				next, ok := trimSubjectBlob(rest)
+				if !ok {
+					break
+				}
+				rest = next
+			}
+			if next, ok := trimSubjectRefwd(rest); ok {
+				subject = next
+				isReplyOrFwd = true
+				continue
+			}
+
+			if next, ok := trimSubjectBlob(subject); ok && next != "" {
+				subject = next
+				continue
+			}
 			break
 		}
+
+		// Unwrap "[fwd: ...]" and start over
+		if len(subject) > len("[fwd:") && strings.EqualFold(subject[:len("[fwd:")], "[fwd:") && strings.HasSuffix(subject, "]") {
+			subject = subject[len("[fwd:") : len(subject)-1]
+			isReplyOrFwd = true
+			continue
+		}
+		break
+	}
+
+	return strings.ToLower(strings.TrimSpace(subject)), isReplyOrFwd
+}
+
+// trimSubjectBlob removes a leading "[...]" subj-blob and following whitespace
+func trimSubjectBlob(s string) (string, bool) {
+	if !strings.HasPrefix(s, "[") {
+		return s, false
+	}
+	end := strings.IndexAny(s[1:], "[]")
+	if end == -1 || s[1+end] != ']' {
+		return s, false
 	}
+	return strings.TrimLeft(s[end+2:], " "), true
+}
+
+// trimSubjectRefwd removes a leading subj-refwd: "re", "fw" or "fwd", an
+// optional subj-blob, and a colon
+func trimSubjectRefwd(s string) (string, bool) {
+	lower := strings.ToLower(s)
+	var n int
+	switch {
+	case strings.HasPrefix(lower, "re"):
+		n = 2
+	case strings.HasPrefix(lower, "fwd"):
+		n = 3
+	case strings.HasPrefix(lower, "fw"):
+		n = 2
+	default:
+		return s, false
+	}
+
+	rest := strings.TrimLeft(s[n:], " ")
+	if next, ok := trimSubjectBlob(rest); ok {
+		rest = next
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return s, false
+	}
+	return rest[1:], true
+}
+
+// parseThreadingHeaders extracts the Message-ID, In-Reply-To and References
+// message IDs from a raw message, handling folded header lines
+func parseThreadingHeaders(data []byte) (messageID, inReplyTo string, references []string) {
+	// A malformed header still returns the fields read before the error
+	header, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
 
-	// Also remove [tag] style prefixes
-	for strings.HasPrefix(subject, "[") {
-		end := strings.Index(subject, "]")
+	if ids := parseMsgIDs(header.Get("Message-ID")); len(ids) > 0 {
+		messageID = ids[0]
+	}
+	if ids := parseMsgIDs(header.Get("In-Reply-To")); len(ids) > 0 {
+		inReplyTo = ids[0]
+	}
+	references = parseMsgIDs(strings.Join(header.Values("References"), " "))
+	return
+}
+
+// parseMsgIDs returns the "<...>" message IDs in s, ignoring other text
+func parseMsgIDs(s string) []string {
+	var ids []string
+	for {
+		start := strings.IndexByte(s, '<')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '>')
 		if end == -1 {
 			break
 		}
-		subject = strings.TrimSpace(subject[end+1:])
+		if id := strings.Join(strings.Fields(s[start+1:start+end]), ""); id != "" {
+			ids = append(ids, "<"+id+">")
+		}
+		s = s[start+end+1:]
 	}
-
-	return subject
+	return ids
 }
 
 // formatThreadResponse formats thread nodes into IMAP THREAD response format
-// Format: (uid1 uid2 (uid3 uid4)) where nested parens indicate children
+// A node with one child is followed by it inline, "(1 2 3)"; several children
+// are nested lists, "(1 (2)(3 4))". Dummy nodes contribute no number
 func formatThreadResponse(threads []*ThreadNode) string {
-	var parts []string
-
+	var b strings.Builder
 	for _, thread := range threads {
-		parts = append(parts, formatThreadNode(thread))
+		b.WriteByte('(')
+		writeThreadMembers(&b, thread)
+		b.WriteByte(')')
 	}
-
-	return strings.Join(parts, "")
+	return b.String()
 }
 
-// formatThreadNode formats a single thread node and its children
-func formatThreadNode(node *ThreadNode) string {
-	if len(node.Children) == 0 {
-		return fmt.Sprintf("(%d)", node.UID)
+// writeThreadMembers writes a node and its descendants without the enclosing parentheses
+func writeThreadMembers(b *strings.Builder, node *ThreadNode) {
+	if node.ID != 0 {
+		fmt.Fprintf(b, "%d", node.ID)
+		if len(node.Children) > 0 {
+			b.WriteByte(' ')
+		}
 	}
 
-	var result strings.Builder
-	result.WriteString(fmt.Sprintf("(%d", node.UID))
-
+	if len(node.Children) == 1 {
+		writeThreadMembers(b, node.Children[0])
+		return
+	}
 	for _, child := range node.Children {
-		if len(child.Children) == 0 {
-			result.WriteString(fmt.Sprintf(" %d", child.UID))
-		} else {
-			result.WriteString(" ")
-			result.WriteString(formatThreadNode(child))
-		}
+		b.WriteByte('(')
+		writeThreadMembers(b, child)
+		b.WriteByte(')')
 	}
-
-	result.WriteString(")")
-	return result.String()
 }
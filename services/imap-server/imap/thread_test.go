@@ -0,0 +1,191 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBaseSubject(t *testing.T) {
+	tests := []struct {
+		subject      string
+		want         string
+		isReplyOrFwd bool
+	}{
+		{"Hello world", "hello world", false},
+		{"Re: Hello world", "hello world", true},
+		{"RE:  re: Fwd:Hello   world", "hello world", true},
+		{"Re[2]: Hello", "hello", true},
+		{"[list] Re: Hello", "hello", true},
+		{"[list] Hello", "hello", false},
+		{"Hello (fwd)", "hello", true},
+		{"[Fwd: Re: Hello]", "hello", true},
+		{"[tag]", "[tag]", false},
+		{"Rebase: Hello", "rebase: hello", false},
+		{"=?UTF-8?B?UmU6IEjDqWxsbw==?=", "héllo", true},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, isReplyOrFwd := baseSubject(tt.subject)
+		if got != tt.want || isReplyOrFwd != tt.isReplyOrFwd {
+			t.Errorf("baseSubject(%q) = %q, %v; want %q, %v", tt.subject, got, isReplyOrFwd, tt.want, tt.isReplyOrFwd)
+		}
+	}
+}
+
+func TestParseThreadingHeaders(t *testing.T) {
+	data := []byte("Message-ID: <c@example.com>\r\n" +
+		"In-Reply-To: <b@example.com> (comment)\r\n" +
+		"References: <a@example.com>\r\n" +
+		" <b@example.com>\r\n" +
+		"Subject: Re: Hello\r\n" +
+		"\r\n" +
+		"References: <body@example.com>\r\n")
+
+	messageID, inReplyTo, references := parseThreadingHeaders(data)
+	if messageID != "<c@example.com>" {
+		t.Errorf("messageID = %q", messageID)
+	}
+	if inReplyTo != "<b@example.com>" {
+		t.Errorf("inReplyTo = %q", inReplyTo)
+	}
+	if len(references) != 2 || references[0] != "<a@example.com>" || references[1] != "<b@example.com>" {
+		t.Errorf("references = %v", references)
+	}
+}
+
+func TestFormatThreadResponse(t *testing.T) {
+	threads := []*ThreadNode{
+		{ID: 2},
+		{ID: 3, Children: []*ThreadNode{
+			{ID: 6, Children: []*ThreadNode{
+				{ID: 4, Children: []*ThreadNode{{ID: 23}}},
+				{ID: 44, Children: []*ThreadNode{{ID: 7, Children: []*ThreadNode{{ID: 96}}}}},
+			}},
+		}},
+		{Children: []*ThreadNode{{ID: 8}, {ID: 9}}},
+	}
+
+	want := "(2)(3 6 (4 23)(44 7 96))((8)(9))"
+	if got := formatThreadResponse(threads); got != want {
+		t.Errorf("formatThreadResponse() = %q, want %q", got, want)
+	}
+}
+
+func testThreadMessages() []*threadMessage {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	msgs := []*Message{
+		{SequenceNum: 1, UID: 11, Subject: "Lunch", MessageID: "<1@x>"},
+		{SequenceNum: 2, UID: 12, Subject: "Re: Lunch", MessageID: "<2@x>", References: []string{"<1@x>"}},
+		{SequenceNum: 3, UID: 13, Subject: "Report", MessageID: "<3@x>"},
+		{SequenceNum: 4, UID: 14, Subject: "Re: Lunch", MessageID: "<4@x>", InReplyTo: "<1@x>"},
+		{SequenceNum: 5, UID: 15, Subject: "Re: Report", MessageID: "<5@x>"}, // No References
+		{SequenceNum: 6, UID: 16, Subject: "Re: Lunch", MessageID: "<6@x>", References: []string{"<1@x>", "<2@x>"}},
+		{SequenceNum: 7, UID: 17, Subject: "Re: Trip", MessageID: "<7@x>", References: []string{"<missing@x>"}},
+		{SequenceNum: 8, UID: 18, Subject: "Re: Trip", MessageID: "<8@x>", References: []string{"<missing@x>"}},
+	}
+
+	var result []*threadMessage
+	for i, msg := range msgs {
+		msg.Date = base.Add(time.Duration(i) * time.Hour)
+		result = append(result, newThreadMessage(msg, msg.SequenceNum))
+	}
+	return result
+}
+
+func TestThreadByReferences(t *testing.T) {
+	got := formatThreadResponse(threadByReferences(testThreadMessages()))
+	want := "(1 (2 6)(4))(3 5)((7)(8))"
+	if got != want {
+		t.Errorf("REFERENCES threads = %q, want %q", got, want)
+	}
+}
+
+func TestThreadByReferences_Loop(t *testing.T) {
+	msgs := []*threadMessage{
+		newThreadMessage(&Message{SequenceNum: 1, Subject: "a", MessageID: "<1@x>", References: []string{"<2@x>"}}, 1),
+		newThreadMessage(&Message{SequenceNum: 2, Subject: "b", MessageID: "<2@x>", References: []string{"<1@x>"}}, 2),
+	}
+
+	got := formatThreadResponse(threadByReferences(msgs))
+	if got != "(1 2)" && got != "(2 1)" {
+		t.Errorf("REFERENCES threads = %q, want a single thread", got)
+	}
+}
+
+func TestThreadByOrderedSubject(t *testing.T) {
+	got := formatThreadResponse(threadByOrderedSubject(testThreadMessages()))
+	want := "(1 (2)(4)(6))(3 5)(7 8)"
+	if got != want {
+		t.Errorf("ORDEREDSUBJECT threads = %q, want %q", got, want)
+	}
+}
+
+func TestMatchesSearchCriteria(t *testing.T) {
+	msg := &Message{
+		UID:     42,
+		Subject: "Quarterly Report",
+		From:    "alice@example.com",
+		Flags:   []MessageFlag{FlagSeen},
+		Size:    2048,
+		Date:    time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		criteria string
+		want     bool
+	}{
+		{"ALL", true},
+		{"SEEN", true},
+		{"UNSEEN", false},
+		{"NOT DELETED", true},
+		{"NOT SEEN", false},
+		{"SUBJECT report", true},
+		{"FROM bob", false},
+		{"LARGER 1024", true},
+		{"SMALLER 1024", false},
+		{"SENTSINCE 1-Mar-2024", true},
+		{"SENTBEFORE 1-Mar-2024", false},
+		{"UID 40:50", true},
+		{"1:2", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesSearchCriteria(msg, 3, parseSearchCriteria(tt.criteria)); got != tt.want {
+			t.Errorf("matchesSearchCriteria(%q) = %v, want %v", tt.criteria, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSearchCriteria(t *testing.T) {
+	indexed, scanned := splitSearchCriteria(parseSearchCriteria(`UNSEEN BODY invoice NOT SUBJECT spam TEXT "" FROM alice`))
+
+	var keys []string
+	for _, key := range indexed {
+		keys = append(keys, key.Key)
+	}
+	if got := strings.Join(keys, " "); got != "BODY SUBJECT" {
+		t.Errorf("indexed = %q, want %q", got, "BODY SUBJECT")
+	}
+	if !indexed[1].Not {
+		t.Error("NOT was dropped from an indexed criterion")
+	}
+	if len(scanned) != 3 {
+		t.Errorf("scanned = %v, want UNSEEN, the empty TEXT and FROM", scanned)
+	}
+}
+
+func TestMatchesIndexedCriteria(t *testing.T) {
+	criteria := parseSearchCriteria("BODY invoice NOT SUBJECT spam")
+	uids := []map[uint32]bool{
+		{1: true, 2: true}, // BODY invoice
+		{2: true},          // SUBJECT spam
+	}
+
+	for uid, want := range map[uint32]bool{1: true, 2: false, 3: false} {
+		if got := matchesIndexedCriteria(uid, criteria, uids); got != want {
+			t.Errorf("matchesIndexedCriteria(%d) = %v, want %v", uid, got, want)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -75,6 +76,23 @@ func main() {
 		logger.Fatal("Failed to create IMAP server", zap.Error(err))
 	}
 
+	// Redis carries IDLE notifications between instances and from the SMTP
+	// delivery path, and the connection counts behind the per-IP and per-user
+	// limits
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.GetRedisAddr(),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		PoolSize: cfg.Redis.PoolSize,
+	})
+	defer redisClient.Close()
+
+	if err := redisClient.Ping(context.Background()).Err(); err != nil {
+		logger.Warn("Redis unavailable, IDLE notifications limited to this instance", zap.Error(err))
+	}
+	server.SetRedisClient(imap.NewRedisClient(redisClient))
+	server.SetConnectionLeaseStore(imap.NewRedisLeaseStore(redisClient))
+
 	// Start metrics server
 	if cfg.Metrics.Enabled {
 		go startMetricsServer(cfg, logger)
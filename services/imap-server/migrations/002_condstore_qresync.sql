@@ -0,0 +1,64 @@
+-- CONDSTORE / QRESYNC support (RFC 7162)
+-- Mod-sequences are allocated from folders.highest_modseq so they are strictly
+-- increasing per folder and persist across server restarts.
+
+-- Expunged message tombstones, used for VANISHED (EARLIER) responses
+CREATE TABLE IF NOT EXISTS expunged_messages (
+    folder_id UUID NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    uid BIGINT NOT NULL,
+    modseq BIGINT NOT NULL,
+    expunged_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (folder_id, uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_expunged_messages_modseq ON expunged_messages(folder_id, modseq);
+
+-- Allocate the next modseq for a folder
+CREATE OR REPLACE FUNCTION next_folder_modseq(p_folder_id UUID)
+RETURNS BIGINT AS $$
+DECLARE
+    v_modseq BIGINT;
+BEGIN
+    UPDATE folders SET highest_modseq = highest_modseq + 1, updated_at = NOW()
+    WHERE id = p_folder_id
+    RETURNING highest_modseq INTO v_modseq;
+    RETURN v_modseq;
+END;
+$$ LANGUAGE plpgsql;
+
+-- New messages always get a fresh modseq
+CREATE OR REPLACE FUNCTION assign_message_modseq()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        NEW.modseq = next_folder_modseq(NEW.folder_id);
+    ELSIF NEW.flags IS DISTINCT FROM OLD.flags AND NEW.modseq <= OLD.modseq THEN
+        -- Flag change from a writer that did not allocate a modseq itself
+        NEW.modseq = next_folder_modseq(NEW.folder_id);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_assign_message_modseq ON messages;
+CREATE TRIGGER trigger_assign_message_modseq
+    BEFORE INSERT OR UPDATE ON messages
+    FOR EACH ROW
+    EXECUTE FUNCTION assign_message_modseq();
+
+-- Record a tombstone with its own modseq when a message is expunged
+CREATE OR REPLACE FUNCTION record_expunged_message()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO expunged_messages (folder_id, uid, modseq)
+    VALUES (OLD.folder_id, OLD.uid, next_folder_modseq(OLD.folder_id))
+    ON CONFLICT (folder_id, uid) DO UPDATE SET modseq = EXCLUDED.modseq, expunged_at = NOW();
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_record_expunged_message ON messages;
+CREATE TRIGGER trigger_record_expunged_message
+    AFTER DELETE ON messages
+    FOR EACH ROW
+    EXECUTE FUNCTION record_expunged_message();
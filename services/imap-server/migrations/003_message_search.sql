@@ -0,0 +1,38 @@
+-- Server-side SEARCH over message text
+-- body_text holds the decoded text parts of a message, extracted at ingest.
+-- subject_vector and body_vector back word-based SUBJECT/BODY/TEXT searches;
+-- the trigram indexes back exact substring searches (imap.search_exact_substring).
+-- The 'simple' configuration is used so words aren't stemmed or dropped as
+-- stop words: a search for "meeting" must not match "meet".
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS body_text TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS subject_vector tsvector;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS body_vector tsvector;
+
+CREATE OR REPLACE FUNCTION messages_search_vector_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.subject_vector := to_tsvector('simple', COALESCE(NEW.subject, ''));
+    NEW.body_vector := to_tsvector('simple', COALESCE(NEW.body_text, ''));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_messages_search_vector ON messages;
+CREATE TRIGGER trigger_messages_search_vector
+    BEFORE INSERT OR UPDATE OF subject, body_text ON messages
+    FOR EACH ROW
+    EXECUTE FUNCTION messages_search_vector_update();
+
+-- Backfill subjects; bodies of existing messages are indexed once body_text is set
+UPDATE messages
+SET subject_vector = to_tsvector('simple', COALESCE(subject, '')),
+    body_vector = to_tsvector('simple', COALESCE(body_text, ''))
+WHERE subject_vector IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_subject_vector ON messages USING gin(subject_vector);
+CREATE INDEX IF NOT EXISTS idx_messages_body_vector ON messages USING gin(body_vector);
+CREATE INDEX IF NOT EXISTS idx_messages_subject_trgm ON messages USING gin(subject gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_messages_body_trgm ON messages USING gin(body_text gin_trgm_ops);
@@ -0,0 +1,65 @@
+-- Keywords and the virtual All Mail folder
+-- Keywords (custom flags, RFC 3501 section 2.3.2) are stored with the system
+-- flags in messages.flags; a GIN index backs SEARCH KEYWORD and the per-folder
+-- keyword list sent in FLAGS and PERMANENTFLAGS.
+-- All Mail (special-use \All, RFC 6154) holds no messages of its own: it shows
+-- every message in its mailbox outside Trash and Junk. Messages are numbered
+-- in it by all_mail_uid, allocated from the All Mail folder's uid_next.
+
+CREATE INDEX IF NOT EXISTS idx_messages_flags ON messages USING gin(flags jsonb_path_ops);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS all_mail_uid BIGINT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_all_mail_uid ON messages(mailbox_id, all_mail_uid);
+
+-- Every mailbox gets an All Mail folder
+CREATE OR REPLACE FUNCTION create_all_mail_folder()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO folders (mailbox_id, name, full_path, special_use, attributes)
+    VALUES (NEW.id, 'All Mail', 'All Mail', '\All', '["\\All"]')
+    ON CONFLICT (mailbox_id, full_path) DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_create_all_mail_folder ON mailboxes;
+CREATE TRIGGER trigger_create_all_mail_folder
+    AFTER INSERT ON mailboxes
+    FOR EACH ROW
+    EXECUTE FUNCTION create_all_mail_folder();
+
+INSERT INTO folders (mailbox_id, name, full_path, special_use, attributes)
+SELECT id, 'All Mail', 'All Mail', '\All', '["\\All"]' FROM mailboxes
+ON CONFLICT (mailbox_id, full_path) DO NOTHING;
+
+-- New messages, including copies, get the next All Mail UID
+CREATE OR REPLACE FUNCTION assign_all_mail_uid()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE folders SET uid_next = uid_next + 1, updated_at = NOW()
+    WHERE mailbox_id = NEW.mailbox_id AND special_use = '\All'
+    RETURNING uid_next - 1 INTO NEW.all_mail_uid;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_assign_all_mail_uid ON messages;
+CREATE TRIGGER trigger_assign_all_mail_uid
+    BEFORE INSERT ON messages
+    FOR EACH ROW
+    EXECUTE FUNCTION assign_all_mail_uid();
+
+-- Number existing messages in arrival order
+WITH numbered AS (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY mailbox_id ORDER BY created_at, id) AS n
+    FROM messages
+    WHERE all_mail_uid IS NULL
+)
+UPDATE messages m SET all_mail_uid = numbered.n
+FROM numbered
+WHERE m.id = numbered.id;
+
+UPDATE folders f SET uid_next = COALESCE(
+    (SELECT MAX(all_mail_uid) + 1 FROM messages m WHERE m.mailbox_id = f.mailbox_id), 1)
+WHERE f.special_use = '\All';
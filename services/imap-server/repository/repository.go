@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -317,9 +318,43 @@ func (r *Repository) GetFolderByPath(ctx context.Context, mailboxID, path string
 	}
 	json.Unmarshal(attributesJSON, &f.Attributes)
 
+	if f.SpecialUse != nil && *f.SpecialUse == types.SpecialUseAll {
+		// Modseqs of different folders can't be compared, so All Mail has none
+		f.HighestModSeq = 0
+		err = r.db.QueryRow(ctx, `
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE NOT flags @> '["\\Seen"]'::jsonb)
+			FROM messages WHERE `+allMailScope, f.ID,
+		).Scan(&f.MessageCount, &f.UnseenCount)
+		if err != nil {
+			return nil, fmt.Errorf("count all mail: %w", err)
+		}
+	}
+
 	return &f, nil
 }
 
+// allMailScope selects the messages shown in the All Mail folder $1: every
+// message in its mailbox outside Trash and Junk
+const allMailScope = `mailbox_id = (SELECT mailbox_id FROM folders WHERE id = $1)
+	AND all_mail_uid IS NOT NULL
+	AND folder_id NOT IN (
+		SELECT id FROM folders WHERE mailbox_id = messages.mailbox_id AND special_use IN ('\Trash', '\Junk'))`
+
+// messageScope returns the condition selecting the messages in folder $1 and
+// the column holding their UIDs there. All Mail holds no messages of its own,
+// so it selects across the mailbox and numbers messages by all_mail_uid.
+func (r *Repository) messageScope(ctx context.Context, folderID string) (cond, uidColumn string, err error) {
+	var specialUse *string
+	err = r.db.QueryRow(ctx, "SELECT special_use FROM folders WHERE id = $1", folderID).Scan(&specialUse)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", "", fmt.Errorf("query folder: %w", err)
+	}
+	if specialUse != nil && types.SpecialUse(*specialUse) == types.SpecialUseAll {
+		return allMailScope, "all_mail_uid", nil
+	}
+	return "folder_id = $1", "uid", nil
+}
+
 // CreateFolder creates a new folder
 func (r *Repository) CreateFolder(ctx context.Context, f *types.Folder) error {
 	attributesJSON, _ := json.Marshal(f.Attributes)
@@ -358,23 +393,210 @@ func (r *Repository) DeleteFolder(ctx context.Context, folderID string) error {
 	return err
 }
 
-// RenameFolder renames a folder
-func (r *Repository) RenameFolder(ctx context.Context, folderID, newName, newPath string) error {
-	query := `UPDATE folders SET name = $2, full_path = $3, updated_at = NOW() WHERE id = $1`
-	_, err := r.db.Exec(ctx, query, folderID, newName, newPath)
-	return err
+// HasChildFolders reports whether a folder has folders beneath it
+func (r *Repository) HasChildFolders(ctx context.Context, folderID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM folders WHERE parent_id = $1)", folderID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("query child folders: %w", err)
+	}
+	return exists, nil
+}
+
+// ClearFolder deletes the messages in a folder and makes it \Noselect. A
+// folder with child folders is kept this way when it is deleted, as the
+// parent of its children (RFC 3501 section 6.3.4).
+func (r *Repository) ClearFolder(ctx context.Context, folderID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM messages WHERE folder_id = $1", folderID); err != nil {
+		return fmt.Errorf("delete folder messages: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE folders SET selectable = FALSE, message_count = 0, recent_count = 0,
+		       unseen_count = 0, first_unseen = 0, updated_at = NOW()
+		WHERE id = $1
+	`, folderID)
+	if err != nil {
+		return fmt.Errorf("clear folder: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RenameFolder renames a folder and moves it under parentID, along with the
+// folders beneath it. Messages and subscriptions belong to the folder, so
+// they move with it.
+func (r *Repository) RenameFolder(ctx context.Context, folderID, newName, newPath string, parentID *string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var mailboxID, oldPath string
+	err = tx.QueryRow(ctx, "SELECT mailbox_id, full_path FROM folders WHERE id = $1 FOR UPDATE", folderID).
+		Scan(&mailboxID, &oldPath)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("query folder: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE folders SET full_path = $3::text || substr(full_path, length($2::text) + 1), updated_at = NOW()
+		WHERE mailbox_id = $1 AND starts_with(full_path, $2::text || delimiter)
+	`, mailboxID, oldPath, newPath)
+	if err != nil {
+		return fmt.Errorf("rename child folders: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE folders SET name = $2, full_path = $3, parent_id = $4, updated_at = NOW()
+		WHERE id = $1
+	`, folderID, newName, newPath, parentID)
+	if err != nil {
+		return fmt.Errorf("rename folder: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RenameInbox renames INBOX the way RFC 3501 section 6.3.5 asks: its messages
+// move to the new folder f, which keeps their UIDs, and INBOX is left empty.
+// Folders beneath INBOX stay where they are.
+func (r *Repository) RenameInbox(ctx context.Context, inboxID string, f *types.Folder) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// INBOX keeps its uid_next so its UIDs aren't reused
+	err = tx.QueryRow(ctx, "SELECT uid_next, highest_modseq FROM folders WHERE id = $1 FOR UPDATE", inboxID).
+		Scan(&f.UIDNext, &f.HighestModSeq)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("query inbox: %w", err)
+	}
+
+	attributesJSON, _ := json.Marshal(f.Attributes)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO folders (
+			id, mailbox_id, name, full_path, parent_id, attributes,
+			delimiter, uid_validity, uid_next, highest_modseq,
+			subscribed, selectable, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+		)
+	`, f.ID, f.MailboxID, f.Name, f.FullPath, f.ParentID, attributesJSON,
+		f.Delimiter, f.UIDValidity, f.UIDNext, f.HighestModSeq,
+		f.Subscribed, f.Selectable, f.CreatedAt, f.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("create folder: %w", err)
+	}
+
+	// Moving a message is an expunge from INBOX for CONDSTORE/QRESYNC clients
+	_, err = tx.Exec(ctx, `
+		INSERT INTO expunged_messages (folder_id, uid, modseq)
+		SELECT $1, uid, (SELECT next_folder_modseq($1)) FROM messages WHERE folder_id = $1
+		ON CONFLICT (folder_id, uid) DO UPDATE SET modseq = EXCLUDED.modseq, expunged_at = NOW()
+	`, inboxID)
+	if err != nil {
+		return fmt.Errorf("record moved messages: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE messages SET folder_id = $2 WHERE folder_id = $1", inboxID, f.ID); err != nil {
+		return fmt.Errorf("move inbox messages: %w", err)
+	}
+
+	for _, id := range []string{inboxID, f.ID} {
+		_, err = tx.Exec(ctx, `
+			UPDATE folders SET
+				message_count = (SELECT COUNT(*) FROM messages WHERE folder_id = $1),
+				unseen_count = (SELECT COUNT(*) FROM messages WHERE folder_id = $1 AND NOT flags @> '["\\Seen"]'::jsonb),
+				recent_count = 0, first_unseen = 0, updated_at = NOW()
+			WHERE id = $1
+		`, id)
+		if err != nil {
+			return fmt.Errorf("update folder counts: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetFolderSubscriptions returns a user's subscriptions to the folders of a
+// mailbox, by folder ID. Folders the user has not subscribed to or
+// unsubscribed from are missing; for them the folder's default applies.
+func (r *Repository) GetFolderSubscriptions(ctx context.Context, userID, mailboxID string) (map[string]bool, error) {
+	query := `
+		SELECT s.folder_id, s.subscribed
+		FROM folder_subscriptions s
+		JOIN folders f ON f.id = s.folder_id
+		WHERE s.user_id = $1 AND f.mailbox_id = $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, mailboxID)
+	if err != nil {
+		return nil, fmt.Errorf("query subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make(map[string]bool)
+	for rows.Next() {
+		var folderID string
+		var subscribed bool
+		if err := rows.Scan(&folderID, &subscribed); err != nil {
+			return nil, fmt.Errorf("scan subscription: %w", err)
+		}
+		subscriptions[folderID] = subscribed
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// SetFolderSubscription subscribes a user to a folder or unsubscribes them
+func (r *Repository) SetFolderSubscription(ctx context.Context, userID, folderID string, subscribed bool) error {
+	query := `
+		INSERT INTO folder_subscriptions (user_id, folder_id, subscribed)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, folder_id) DO UPDATE SET subscribed = EXCLUDED.subscribed, updated_at = NOW()
+	`
+	_, err := r.db.Exec(ctx, query, userID, folderID, subscribed)
+	if err != nil {
+		return fmt.Errorf("set subscription: %w", err)
+	}
+	return nil
 }
 
 // GetMessages returns messages in a folder
 func (r *Repository) GetMessages(ctx context.Context, folderID string, start, count int) ([]*types.Message, error) {
+	scope, uidColumn, err := r.messageScope(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	order := "sequence_num"
+	if uidColumn != "uid" {
+		order = uidColumn
+	}
+
 	query := `
-		SELECT id, folder_id, mailbox_id, uid, sequence_num, message_id, in_reply_to,
-		       subject, sender, recipients_to, recipients_cc, recipients_bcc, reply_to,
+		SELECT id, folder_id, mailbox_id, ` + uidColumn + `, sequence_num, message_id, in_reply_to,
+		       COALESCE("references", ''), subject, sender, recipients_to, recipients_cc, recipients_bcc, reply_to,
 		       date, size, flags, modseq, body_path, headers_json, body_structure, envelope,
 		       created_at
 		FROM messages
-		WHERE folder_id = $1
-		ORDER BY sequence_num ASC
+		WHERE ` + scope + `
+		ORDER BY ` + order + ` ASC
 		OFFSET $2 LIMIT $3
 	`
 
@@ -387,11 +609,12 @@ func (r *Repository) GetMessages(ctx context.Context, folderID string, start, co
 	var messages []*types.Message
 	for rows.Next() {
 		var m types.Message
+		var references string
 		var toJSON, ccJSON, bccJSON, flagsJSON []byte
 
 		err := rows.Scan(
 			&m.ID, &m.FolderID, &m.MailboxID, &m.UID, &m.SequenceNum, &m.MessageID, &m.InReplyTo,
-			&m.Subject, &m.From, &toJSON, &ccJSON, &bccJSON, &m.ReplyTo,
+			&references, &m.Subject, &m.From, &toJSON, &ccJSON, &bccJSON, &m.ReplyTo,
 			&m.Date, &m.Size, &flagsJSON, &m.ModSeq, &m.BodyPath, &m.HeadersJSON, &m.BodyStructure, &m.Envelope,
 			&m.CreatedAt,
 		)
@@ -403,6 +626,7 @@ func (r *Repository) GetMessages(ctx context.Context, folderID string, start, co
 		json.Unmarshal(ccJSON, &m.Cc)
 		json.Unmarshal(bccJSON, &m.Bcc)
 		json.Unmarshal(flagsJSON, &m.Flags)
+		m.References = strings.Fields(references)
 		messages = append(messages, &m)
 	}
 
@@ -411,21 +635,27 @@ func (r *Repository) GetMessages(ctx context.Context, folderID string, start, co
 
 // GetMessageByUID returns a message by UID
 func (r *Repository) GetMessageByUID(ctx context.Context, folderID string, uid uint32) (*types.Message, error) {
+	scope, uidColumn, err := r.messageScope(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, folder_id, mailbox_id, uid, sequence_num, message_id, in_reply_to,
-		       subject, sender, recipients_to, recipients_cc, recipients_bcc, reply_to,
+		SELECT id, folder_id, mailbox_id, ` + uidColumn + `, sequence_num, message_id, in_reply_to,
+		       COALESCE("references", ''), subject, sender, recipients_to, recipients_cc, recipients_bcc, reply_to,
 		       date, size, flags, modseq, body_path, headers_json, body_structure, envelope,
 		       created_at
 		FROM messages
-		WHERE folder_id = $1 AND uid = $2
+		WHERE ` + scope + ` AND ` + uidColumn + ` = $2
 	`
 
 	var m types.Message
+	var references string
 	var toJSON, ccJSON, bccJSON, flagsJSON []byte
 
-	err := r.db.QueryRow(ctx, query, folderID, uid).Scan(
+	err = r.db.QueryRow(ctx, query, folderID, uid).Scan(
 		&m.ID, &m.FolderID, &m.MailboxID, &m.UID, &m.SequenceNum, &m.MessageID, &m.InReplyTo,
-		&m.Subject, &m.From, &toJSON, &ccJSON, &bccJSON, &m.ReplyTo,
+		&references, &m.Subject, &m.From, &toJSON, &ccJSON, &bccJSON, &m.ReplyTo,
 		&m.Date, &m.Size, &flagsJSON, &m.ModSeq, &m.BodyPath, &m.HeadersJSON, &m.BodyStructure, &m.Envelope,
 		&m.CreatedAt,
 	)
@@ -440,10 +670,56 @@ func (r *Repository) GetMessageByUID(ctx context.Context, folderID string, uid u
 	json.Unmarshal(ccJSON, &m.Cc)
 	json.Unmarshal(bccJSON, &m.Bcc)
 	json.Unmarshal(flagsJSON, &m.Flags)
+	m.References = strings.Fields(references)
 
 	return &m, nil
 }
 
+// CreateMessage inserts a new message record, assigning the folder's next UID
+// Threading headers (Message-ID, In-Reply-To, References) are stored so THREAD
+// can build conversation trees without re-reading message bodies, and the body
+// text is stored for the SEARCH index
+func (r *Repository) CreateMessage(ctx context.Context, m *types.Message) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, "SELECT uid_next FROM folders WHERE id = $1 FOR UPDATE", m.FolderID).Scan(&m.UID)
+	if err != nil {
+		return fmt.Errorf("get next uid: %w", err)
+	}
+
+	toJSON, _ := json.Marshal(m.To)
+	ccJSON, _ := json.Marshal(m.Cc)
+	bccJSON, _ := json.Marshal(m.Bcc)
+	flagsJSON, _ := json.Marshal(m.Flags)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO messages (
+			id, folder_id, mailbox_id, uid, message_id, in_reply_to, "references", subject, sender,
+			recipients_to, recipients_cc, recipients_bcc, reply_to,
+			date, size, flags, body_path, headers_json, body_text, received_at, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, NOW()
+		)
+	`, m.ID, m.FolderID, m.MailboxID, m.UID, m.MessageID, m.InReplyTo, strings.Join(m.References, " "),
+		m.Subject, m.From, toJSON, ccJSON, bccJSON, m.ReplyTo, m.Date, m.Size, flagsJSON,
+		m.BodyPath, m.HeadersJSON, m.BodyText, m.ReceivedAt)
+	if err != nil {
+		return fmt.Errorf("insert message: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE folders SET uid_next = $2, message_count = message_count + 1, updated_at = NOW() WHERE id = $1",
+		m.FolderID, m.UID+1)
+	if err != nil {
+		return fmt.Errorf("update uid next: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
 // UpdateMessageFlags updates message flags
 func (r *Repository) UpdateMessageFlags(ctx context.Context, messageID string, flags []types.MessageFlag, modseq uint64) error {
 	flagsJSON, _ := json.Marshal(flags)
@@ -452,6 +728,21 @@ func (r *Repository) UpdateMessageFlags(ctx context.Context, messageID string, f
 	return err
 }
 
+// MarkMDNDisplayed marks the pending read receipt request of a message as
+// displayed, if its user sends read receipts automatically and the receipt
+// goes to the message's return path. The SMTP server sends the receipt.
+func (r *Repository) MarkMDNDisplayed(ctx context.Context, mailboxID, messageID string) error {
+	query := `
+		UPDATE mdn_requests r SET status = 'displayed', displayed_at = NOW()
+		FROM mailboxes m
+		JOIN read_receipt_settings s ON s.user_id = m.user_id
+		WHERE r.mailbox_id = $1 AND r.message_id = $2 AND r.status = 'pending' AND r.auto_allowed
+		AND m.id = r.mailbox_id AND s.policy = 'always'
+	`
+	_, err := r.db.Exec(ctx, query, mailboxID, messageID)
+	return err
+}
+
 // CopyMessages copies messages to another folder
 func (r *Repository) CopyMessages(ctx context.Context, srcFolderID, destFolderID string, uids []uint32) (map[uint32]uint32, error) {
 	tx, err := r.db.Begin(ctx)
@@ -460,6 +751,11 @@ func (r *Repository) CopyMessages(ctx context.Context, srcFolderID, destFolderID
 	}
 	defer tx.Rollback(ctx)
 
+	scope, uidColumn, err := r.messageScope(ctx, srcFolderID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get next UID for destination folder
 	var nextUID uint32
 	err = tx.QueryRow(ctx, "SELECT uid_next FROM folders WHERE id = $1 FOR UPDATE", destFolderID).Scan(&nextUID)
@@ -472,17 +768,18 @@ func (r *Repository) CopyMessages(ctx context.Context, srcFolderID, destFolderID
 	for _, uid := range uids {
 		// Get source message
 		var m types.Message
+		var references string
 		var toJSON, ccJSON, bccJSON, flagsJSON []byte
 
 		err := tx.QueryRow(ctx, `
-			SELECT id, mailbox_id, message_id, in_reply_to, subject, sender,
+			SELECT id, mailbox_id, message_id, in_reply_to, COALESCE("references", ''), subject, sender,
 			       recipients_to, recipients_cc, recipients_bcc, reply_to,
-			       date, size, flags, body_path, headers_json, body_structure, envelope
-			FROM messages WHERE folder_id = $1 AND uid = $2
+			       date, size, flags, body_path, headers_json, body_structure, envelope, COALESCE(body_text, '')
+			FROM messages WHERE `+scope+` AND `+uidColumn+` = $2
 		`, srcFolderID, uid).Scan(
-			&m.ID, &m.MailboxID, &m.MessageID, &m.InReplyTo, &m.Subject, &m.From,
+			&m.ID, &m.MailboxID, &m.MessageID, &m.InReplyTo, &references, &m.Subject, &m.From,
 			&toJSON, &ccJSON, &bccJSON, &m.ReplyTo,
-			&m.Date, &m.Size, &flagsJSON, &m.BodyPath, &m.HeadersJSON, &m.BodyStructure, &m.Envelope,
+			&m.Date, &m.Size, &flagsJSON, &m.BodyPath, &m.HeadersJSON, &m.BodyStructure, &m.Envelope, &m.BodyText,
 		)
 		if err != nil {
 			continue
@@ -499,15 +796,15 @@ func (r *Repository) CopyMessages(ctx context.Context, srcFolderID, destFolderID
 		newID := fmt.Sprintf("%s-%d", m.ID, nextUID)
 		_, err = tx.Exec(ctx, `
 			INSERT INTO messages (
-				id, folder_id, mailbox_id, uid, message_id, in_reply_to, subject, sender,
+				id, folder_id, mailbox_id, uid, message_id, in_reply_to, "references", subject, sender,
 				recipients_to, recipients_cc, recipients_bcc, reply_to,
-				date, size, flags, modseq, body_path, headers_json, body_structure, envelope, created_at
+				date, size, flags, modseq, body_path, headers_json, body_structure, envelope, body_text, created_at
 			) VALUES (
-				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, 1, $16, $17, $18, $19, NOW()
+				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, 1, $17, $18, $19, $20, $21, NOW()
 			)
-		`, newID, destFolderID, destMailboxID, nextUID, m.MessageID, m.InReplyTo, m.Subject, m.From,
+		`, newID, destFolderID, destMailboxID, nextUID, m.MessageID, m.InReplyTo, references, m.Subject, m.From,
 			toJSON, ccJSON, bccJSON, m.ReplyTo, m.Date, m.Size, flagsJSON, m.BodyPath, m.HeadersJSON,
-			m.BodyStructure, m.Envelope)
+			m.BodyStructure, m.Envelope, m.BodyText)
 		if err != nil {
 			return nil, err
 		}
@@ -552,7 +849,7 @@ func (r *Repository) MoveMessages(ctx context.Context, srcFolderID, destFolderID
 // GetQuota returns quota information for a mailbox
 func (r *Repository) GetQuota(ctx context.Context, mailboxID string) (*types.Quota, error) {
 	query := `
-		SELECT m.id, d.name, m.storage_used_bytes, m.quota_bytes
+		SELECT m.id, d.name, m.used_bytes, m.quota_bytes
 		FROM mailboxes m
 		JOIN domains d ON d.id = m.domain_id
 		WHERE m.id = $1
@@ -570,6 +867,8 @@ func (r *Repository) GetQuota(ctx context.Context, mailboxID string) (*types.Quo
 		}
 		return nil, fmt.Errorf("query quota: %w", err)
 	}
+	quota.StorageUsed = quota.Usage
+	quota.StorageLimit = quota.Limit
 
 	return &quota, nil
 }
@@ -619,6 +918,30 @@ func (r *Repository) IncrementModSeq(ctx context.Context, folderID string) (uint
 	return modseq, err
 }
 
+// ListExpungedUIDsSince returns UIDs expunged from a folder with a modseq greater than modseq
+func (r *Repository) ListExpungedUIDsSince(ctx context.Context, folderID string, modseq uint64) ([]uint32, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT uid FROM expunged_messages
+		WHERE folder_id = $1 AND modseq > $2
+		ORDER BY uid ASC
+	`, folderID, modseq)
+	if err != nil {
+		return nil, fmt.Errorf("query expunged messages: %w", err)
+	}
+	defer rows.Close()
+
+	var uids []uint32
+	for rows.Next() {
+		var uid uint32
+		if err := rows.Scan(&uid); err != nil {
+			return nil, fmt.Errorf("scan expunged uid: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+
+	return uids, rows.Err()
+}
+
 // CreateAuditLog creates an audit log entry
 func (r *Repository) CreateAuditLog(ctx context.Context, log *types.AuditLog) error {
 	uidsJSON, _ := json.Marshal(log.MessageUIDs)
@@ -643,6 +966,121 @@ func (r *Repository) UpdateFolderCounts(ctx context.Context, folderID string) er
 	return err
 }
 
+// ListFolderKeywords returns the keywords set on any message in a folder, sorted
+func (r *Repository) ListFolderKeywords(ctx context.Context, folderID string) ([]string, error) {
+	scope, _, err := r.messageScope(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT flag FROM messages, jsonb_array_elements_text(flags) AS flag
+		WHERE `+scope+` AND left(flag, 1) <> '\'
+		ORDER BY flag
+	`, folderID)
+	if err != nil {
+		return nil, fmt.Errorf("query folder keywords: %w", err)
+	}
+	defer rows.Close()
+
+	var keywords []string
+	for rows.Next() {
+		var keyword string
+		if err := rows.Scan(&keyword); err != nil {
+			return nil, fmt.Errorf("scan keyword: %w", err)
+		}
+		keywords = append(keywords, keyword)
+	}
+	return keywords, rows.Err()
+}
+
+// textSearchField describes the columns a SEARCH text key covers
+type textSearchField struct {
+	vectors []string // tsvector columns, matched by words and phrases
+	text    []string // the columns the vectors are built from, matched by substring
+	headers []string // header columns without a vector, always matched by substring
+}
+
+// textSearchFields maps the SEARCH keys answered by the text index to their columns
+var textSearchFields = map[string]textSearchField{
+	"SUBJECT": {vectors: []string{"subject_vector"}, text: []string{"subject"}},
+	"BODY":    {vectors: []string{"body_vector"}, text: []string{"body_text"}},
+	"TEXT": {
+		vectors: []string{"subject_vector", "body_vector"},
+		text:    []string{"subject", "body_text"},
+		headers: []string{"sender", "recipients_to::text", "recipients_cc::text", "recipients_bcc::text", "reply_to"},
+	},
+}
+
+// SearchMessageText returns the UIDs of the messages in a folder that match a
+// SUBJECT, BODY or TEXT search for term. With exact set the term is matched as
+// a case-insensitive substring (RFC 3501). Otherwise subject and body are
+// matched word by word through the full-text index, so a term only matches
+// whole words or phrases; terms with no words always use substring matching.
+func (r *Repository) SearchMessageText(ctx context.Context, folderID, key, term string, exact bool) (map[uint32]bool, error) {
+	field, ok := textSearchFields[key]
+	if !ok {
+		return nil, fmt.Errorf("unsupported text search key %q", key)
+	}
+
+	scope, uidColumn, err := r.messageScope(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{folderID}
+	var conds []string
+	if exact || !hasSearchWord(term) {
+		args = append(args, "%"+escapeLike(term)+"%")
+		for _, column := range field.text {
+			conds = append(conds, column+" ILIKE $2")
+		}
+		for _, column := range field.headers {
+			conds = append(conds, column+" ILIKE $2")
+		}
+	} else {
+		args = append(args, term)
+		for _, vector := range field.vectors {
+			conds = append(conds, vector+" @@ phraseto_tsquery('simple', $2)")
+		}
+		if len(field.headers) > 0 {
+			args = append(args, "%"+escapeLike(term)+"%")
+			for _, column := range field.headers {
+				conds = append(conds, column+" ILIKE $3")
+			}
+		}
+	}
+
+	query := "SELECT " + uidColumn + " FROM messages WHERE " + scope + " AND (" + strings.Join(conds, " OR ") + ")"
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search message text: %w", err)
+	}
+	defer rows.Close()
+
+	uids := make(map[uint32]bool)
+	for rows.Next() {
+		var uid uint32
+		if err := rows.Scan(&uid); err != nil {
+			return nil, fmt.Errorf("scan message uid: %w", err)
+		}
+		uids[uid] = true
+	}
+	return uids, rows.Err()
+}
+
+// hasSearchWord reports whether term has a letter or digit for the full-text index to match
+func hasSearchWord(term string) bool {
+	return strings.IndexFunc(term, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // GetMessagesBySequence returns messages by sequence set (for IMAP FETCH/STORE commands)
 // seqSet is an IMAP sequence set like "1:5" or "1,3,5:7"
 // If isUID is true, seqSet contains UIDs instead of sequence numbers
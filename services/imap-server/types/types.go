@@ -172,6 +172,7 @@ type Message struct {
 	HeadersJSON   string        `json:"headers_json"`
 	BodyStructure string        `json:"body_structure"` // BODYSTRUCTURE cache
 	Envelope      string        `json:"envelope"`       // ENVELOPE cache
+	BodyText      string        `json:"-"`              // Decoded text parts for SEARCH; set at ingest, not loaded
 	ReceivedAt    time.Time     `json:"received_at"`    // IMAP INTERNALDATE
 	CreatedAt     time.Time     `json:"created_at"`
 	UpdatedAt     time.Time     `json:"updated_at"`
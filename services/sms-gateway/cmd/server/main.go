@@ -15,6 +15,8 @@ import (
 
 	"sms-gateway/internal/api"
 	"sms-gateway/internal/config"
+	"sms-gateway/internal/geo"
+	"sms-gateway/internal/lookup"
 	"sms-gateway/internal/otp"
 	"sms-gateway/internal/providers"
 	"sms-gateway/internal/providers/gsm"
@@ -23,6 +25,7 @@ import (
 	"sms-gateway/internal/providers/vonage"
 	"sms-gateway/internal/ratelimit"
 	"sms-gateway/internal/repository"
+	"sms-gateway/internal/scheduler"
 	"sms-gateway/internal/templates"
 )
 
@@ -61,11 +64,28 @@ func main() {
 	// Initialize SMS providers
 	providerManager := initProviders(cfg, logger)
 
+	// Initialize destination country checks
+	geoChecker := geo.NewChecker(cfg.Geo, repo, logger)
+
+	// Initialize number validation and carrier lookups
+	lookupService := lookup.New(cfg.Lookup, repo, providerManager, rateLimiter, logger)
+
 	// Initialize OTP service
 	otpService := otp.New(cfg.OTP, repo, providerManager, templateEngine, logger)
 
+	// Initialize scheduler for messages sent at a later time
+	smsScheduler, err := scheduler.New(cfg.Scheduler, repo, providerManager, geoChecker, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize scheduler", zap.Error(err))
+	}
+
 	// Initialize API server
-	apiServer := api.NewServer(cfg, repo, providerManager, otpService, rateLimiter, templateEngine, logger)
+	apiServer := api.NewServer(cfg, repo, providerManager, otpService, smsScheduler, geoChecker, lookupService, rateLimiter, templateEngine, logger)
+
+	// Start scheduler
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go smsScheduler.Run(schedulerCtx)
 
 	// Start metrics server
 	go startMetricsServer(cfg.Metrics.Port, logger)
@@ -92,6 +112,7 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopScheduler()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -169,6 +190,7 @@ func initProviders(cfg *config.Config, logger *zap.Logger) *providers.Manager {
 			cfg.Providers.Vonage.FromNumber,
 			cfg.Providers.Vonage.ApplicationID,
 			cfg.Providers.Vonage.PrivateKey,
+			cfg.Providers.Vonage.SignatureSecret,
 			logger,
 		)
 		manager.Register("vonage", vonageProvider, cfg.Providers.Vonage.Priority)
@@ -1,17 +1,26 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"sms-gateway/internal/geo"
+	"sms-gateway/internal/lookup"
 	"sms-gateway/internal/otp"
 	"sms-gateway/internal/providers"
 	"sms-gateway/internal/repository"
+	"sms-gateway/internal/scheduler"
 	"sms-gateway/internal/templates"
 )
 
@@ -27,17 +36,59 @@ type SendSMSRequest struct {
 	TemplateID  string            `json:"template_id,omitempty"`
 	Variables   map[string]string `json:"variables,omitempty"`
 	Provider    string            `json:"provider,omitempty"`
-	ScheduledAt *time.Time        `json:"scheduled_at,omitempty"`
 	CallbackURL string            `json:"callback_url,omitempty"`
+
+	// SendAt delays the message; a time in the past sends immediately.
+	// ScheduledAt is accepted as an alias.
+	SendAt      *time.Time `json:"send_at,omitempty"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	// Timezone is the recipient's IANA time zone for quiet hours; when
+	// empty it is derived from the phone number
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// sendAt returns when a request should be sent, or nil to send now
+func (r *SendSMSRequest) sendAt() *time.Time {
+	at := r.SendAt
+	if at == nil {
+		at = r.ScheduledAt
+	}
+	if at == nil || !at.After(time.Now()) {
+		return nil
+	}
+	return at
 }
 
 // SendSMSResponse represents the response from sending an SMS
 type SendSMSResponse struct {
-	MessageID    string `json:"message_id"`
-	Status       string `json:"status"`
-	Provider     string `json:"provider"`
-	SegmentCount int    `json:"segment_count"`
-	Cost         float64 `json:"cost,omitempty"`
+	MessageID    string     `json:"message_id"`
+	Status       string     `json:"status"`
+	Provider     string     `json:"provider"`
+	SegmentCount int        `json:"segment_count"`
+	Cost         float64    `json:"cost,omitempty"`
+	ScheduledAt  *time.Time `json:"scheduled_at,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// MessageResponse represents the delivery state of a sent message
+type MessageResponse struct {
+	MessageID    string     `json:"message_id"`
+	Provider     string     `json:"provider"`
+	To           string     `json:"to"`
+	Status       string     `json:"status"`
+	Final        bool       `json:"final"`
+	ErrorCode    string     `json:"error_code,omitempty"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	SegmentCount int        `json:"segment_count"`
+	ScheduledAt  *time.Time `json:"scheduled_at,omitempty"`
+	SentAt       *time.Time `json:"sent_at,omitempty"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	// EstimatedSegments is the locally computed segment count; Cost and
+	// Currency are only set once the provider reported a price
+	EstimatedSegments int      `json:"estimated_segments,omitempty"`
+	Cost              *float64 `json:"cost,omitempty"`
+	Currency          string   `json:"currency,omitempty"`
 }
 
 // SendBulkSMSRequest represents a bulk SMS send request
@@ -53,6 +104,25 @@ type SendBulkSMSResponse struct {
 	Failed  int               `json:"failed"`
 }
 
+// EstimateRequest represents a cost estimate request
+type EstimateRequest struct {
+	To      string `json:"to"`
+	Message string `json:"message,omitempty"`
+}
+
+// ValidateRequest represents a number validation request. Lookup asks for a
+// billable carrier lookup and may also be passed as ?lookup=true.
+type ValidateRequest struct {
+	To     string `json:"to"`
+	Lookup bool   `json:"lookup,omitempty"`
+}
+
+// CountryRulesRequest replaces an organization's country rules
+type CountryRulesRequest struct {
+	Allowlist []string `json:"allowlist"`
+	Blocklist []string `json:"blocklist"`
+}
+
 // OTPRequest represents an OTP request
 type OTPRequest struct {
 	PhoneNumber string            `json:"phone_number"`
@@ -103,6 +173,12 @@ func (s *Server) sendSMS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dest, ok := s.checkDestination(w, r, req.To)
+	if !ok {
+		return
+	}
+	req.To = dest.Number
+
 	// Render template if provided
 	message := req.Message
 	if req.TemplateID != "" {
@@ -114,13 +190,31 @@ func (s *Server) sendSMS(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Store future sends for the scheduler
+	if sendAt := req.sendAt(); sendAt != nil {
+		scheduled, err := s.scheduler.Schedule(r.Context(), s.scheduleRequest(r, &req, message, *sendAt))
+		if err != nil {
+			s.logger.Error("Failed to schedule SMS", zap.Error(err))
+			s.sendError(w, http.StatusInternalServerError, "schedule_failed", "Failed to schedule message")
+			return
+		}
+
+		s.sendSuccess(w, http.StatusAccepted, SendSMSResponse{
+			MessageID:    scheduled.ID,
+			Status:       scheduled.Status,
+			Provider:     scheduled.Provider,
+			SegmentCount: scheduled.SegmentCount,
+			ScheduledAt:  scheduled.ScheduledAt,
+		})
+		return
+	}
+
 	// Build provider request
 	providerReq := &providers.SendRequest{
 		To:          req.To,
 		From:        req.From,
 		Message:     message,
 		MessageType: providers.MessageTypeTransactional,
-		ScheduledAt: req.ScheduledAt,
 		CallbackURL: req.CallbackURL,
 	}
 
@@ -140,17 +234,34 @@ func (s *Server) sendSMS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	estimated := geo.Segments(message)
+	if !geo.CheckSegments(resp.Provider, estimated, resp.SegmentCount) {
+		s.logger.Warn("Provider segment count differs from estimate",
+			zap.String("provider", resp.Provider),
+			zap.String("provider_id", resp.ProviderID),
+			zap.Int("estimated", estimated),
+			zap.Int("charged", resp.SegmentCount))
+	}
+
 	// Save to database
 	msg := &repository.SMSMessage{
-		Provider:     resp.Provider,
-		ProviderID:   resp.ProviderID,
-		FromNumber:   req.From,
-		ToNumber:     req.To,
-		Message:      message,
-		MessageType:  string(providers.MessageTypeTransactional),
-		Status:       string(resp.Status),
-		SegmentCount: resp.SegmentCount,
-		SentAt:       &resp.SentAt,
+		OrganizationID:    s.getOrganizationID(r),
+		Provider:          resp.Provider,
+		ProviderID:        resp.ProviderID,
+		FromNumber:        req.From,
+		ToNumber:          req.To,
+		Message:           message,
+		MessageType:       string(providers.MessageTypeTransactional),
+		Status:            string(resp.Status),
+		SegmentCount:      resp.SegmentCount,
+		Cost:              resp.Cost,
+		Currency:          resp.Currency,
+		EstimatedSegments: estimated,
+		CallingCode:       dest.CallingCode,
+		SentAt:            &resp.SentAt,
+	}
+	if resp.Currency != "" {
+		msg.PriceReportedAt = &resp.SentAt
 	}
 	msgID, _ := s.repo.CreateMessage(r.Context(), msg)
 
@@ -179,7 +290,16 @@ func (s *Server) sendBulkSMS(w http.ResponseWriter, r *http.Request) {
 	success := 0
 	failed := 0
 
+	organizationID := s.getOrganizationID(r)
 	for i, msg := range req.Messages {
+		dest, err := s.geo.Check(r.Context(), organizationID, msg.To)
+		if err != nil {
+			results[i] = SendSMSResponse{Status: "failed", Error: err.Error()}
+			failed++
+			continue
+		}
+		msg.To = dest.Number
+
 		message := msg.Message
 		if msg.TemplateID != "" {
 			var err error
@@ -191,6 +311,23 @@ func (s *Server) sendBulkSMS(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		if sendAt := msg.sendAt(); sendAt != nil {
+			scheduled, err := s.scheduler.Schedule(r.Context(), s.scheduleRequest(r, &msg, message, *sendAt))
+			if err != nil {
+				results[i] = SendSMSResponse{Status: "failed"}
+				failed++
+				continue
+			}
+			results[i] = SendSMSResponse{
+				MessageID:    scheduled.ID,
+				Status:       scheduled.Status,
+				SegmentCount: scheduled.SegmentCount,
+				ScheduledAt:  scheduled.ScheduledAt,
+			}
+			success++
+			continue
+		}
+
 		providerReq := &providers.SendRequest{
 			To:          msg.To,
 			From:        msg.From,
@@ -238,6 +375,168 @@ func (s *Server) getMessageStatus(w http.ResponseWriter, r *http.Request) {
 	s.sendSuccess(w, http.StatusOK, msg)
 }
 
+func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
+	messageID := chi.URLParam(r, "messageId")
+	if messageID == "" {
+		s.sendError(w, http.StatusBadRequest, "missing_message_id", "Message ID is required")
+		return
+	}
+
+	msg, err := s.repo.GetMessage(r.Context(), messageID)
+	if err != nil || msg.OrganizationID != s.getOrganizationID(r) {
+		s.sendError(w, http.StatusNotFound, "not_found", "Message not found")
+		return
+	}
+
+	resp := MessageResponse{
+		MessageID:         msg.ID,
+		Provider:          msg.Provider,
+		To:                msg.ToNumber,
+		Status:            msg.Status,
+		Final:             providers.DeliveryStatus(msg.Status).IsFinal(),
+		ErrorCode:         msg.ErrorCode,
+		ErrorMessage:      msg.ErrorMessage,
+		SegmentCount:      msg.SegmentCount,
+		EstimatedSegments: msg.EstimatedSegments,
+		ScheduledAt:       msg.ScheduledAt,
+		SentAt:            msg.SentAt,
+		DeliveredAt:       msg.DeliveredAt,
+		UpdatedAt:         msg.UpdatedAt,
+	}
+	if msg.PriceReportedAt != nil {
+		resp.Cost = &msg.Cost
+		resp.Currency = msg.Currency
+	}
+
+	s.sendSuccess(w, http.StatusOK, resp)
+}
+
+// cancelMessage cancels a scheduled message that has not been dispatched yet
+func (s *Server) cancelMessage(w http.ResponseWriter, r *http.Request) {
+	messageID := chi.URLParam(r, "messageId")
+	if _, err := uuid.Parse(messageID); err != nil {
+		s.sendError(w, http.StatusNotFound, "not_found", "Message not found")
+		return
+	}
+
+	err := s.scheduler.Cancel(r.Context(), messageID, s.getOrganizationID(r))
+	switch {
+	case errors.Is(err, repository.ErrMessageNotFound):
+		s.sendError(w, http.StatusNotFound, "not_found", "Message not found")
+		return
+	case errors.Is(err, repository.ErrMessageNotCancellable):
+		s.sendError(w, http.StatusConflict, "not_cancellable", "Message has already been dispatched or cancelled")
+		return
+	case err != nil:
+		s.logger.Error("Failed to cancel scheduled SMS", zap.String("message_id", messageID), zap.Error(err))
+		s.sendError(w, http.StatusInternalServerError, "cancel_failed", "Failed to cancel message")
+		return
+	}
+
+	s.sendSuccess(w, http.StatusOK, map[string]string{
+		"message_id": messageID,
+		"status":     string(providers.DeliveryStatusCancelled),
+	})
+}
+
+// estimateSMS previews a send: the destination country, whether the
+// organization's country rules allow it and the approximate price
+func (s *Server) estimateSMS(w http.ResponseWriter, r *http.Request) {
+	var req EstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+	if req.To == "" {
+		s.sendError(w, http.StatusBadRequest, "missing_to", "Recipient phone number is required")
+		return
+	}
+
+	estimate, err := s.geo.Estimate(r.Context(), s.getOrganizationID(r), req.To, req.Message)
+	if err != nil {
+		s.sendDestinationError(w, err)
+		return
+	}
+
+	s.sendSuccess(w, http.StatusOK, estimate)
+}
+
+// validateNumber checks whether a number can receive SMS and, with
+// lookup=true, returns its carrier and line type
+func (s *Server) validateNumber(w http.ResponseWriter, r *http.Request) {
+	var req ValidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+	if req.To == "" {
+		s.sendError(w, http.StatusBadRequest, "missing_to", "Phone number is required")
+		return
+	}
+	if r.URL.Query().Get("lookup") == "true" {
+		req.Lookup = true
+	}
+
+	result, err := s.lookup.Validate(r.Context(), s.getOrganizationID(r), req.To, req.Lookup)
+	if err != nil {
+		var limited *lookup.RateLimitedError
+		switch {
+		case errors.As(err, &limited):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+			s.sendError(w, http.StatusTooManyRequests, "lookup_rate_limited", "Too many carrier lookups")
+		case errors.Is(err, lookup.ErrLookupDisabled), errors.Is(err, providers.ErrLookupNotSupported):
+			s.sendError(w, http.StatusServiceUnavailable, "lookup_unavailable", "Carrier lookups are not available")
+		default:
+			s.logger.Error("Carrier lookup failed", zap.Error(err))
+			s.sendError(w, http.StatusBadGateway, "lookup_failed", "Carrier lookup failed")
+		}
+		return
+	}
+
+	s.sendSuccess(w, http.StatusOK, result)
+}
+
+// checkDestination validates a recipient number against the organization's
+// country rules and returns the destination with the number in E.164 form.
+// It writes the error response when the number is rejected.
+func (s *Server) checkDestination(w http.ResponseWriter, r *http.Request, number string) (*geo.Destination, bool) {
+	dest, err := s.geo.Check(r.Context(), s.getOrganizationID(r), number)
+	if err != nil {
+		s.sendDestinationError(w, err)
+		return nil, false
+	}
+	return dest, true
+}
+
+func (s *Server) sendDestinationError(w http.ResponseWriter, err error) {
+	var blocked *geo.BlockedError
+	switch {
+	case errors.Is(err, geo.ErrInvalidNumber):
+		s.sendError(w, http.StatusBadRequest, "invalid_phone_number", err.Error())
+	case errors.As(err, &blocked):
+		s.sendError(w, http.StatusForbidden, "destination_blocked", err.Error())
+	default:
+		s.logger.Error("Failed to check SMS destination", zap.Error(err))
+		s.sendError(w, http.StatusInternalServerError, "destination_check_failed", "Failed to check destination")
+	}
+}
+
+// scheduleRequest builds the scheduler request for a send request
+func (s *Server) scheduleRequest(r *http.Request, req *SendSMSRequest, message string, sendAt time.Time) *scheduler.Request {
+	return &scheduler.Request{
+		OrganizationID: s.getOrganizationID(r),
+		UserID:         s.getUserID(r),
+		Provider:       req.Provider,
+		From:           req.From,
+		To:             req.To,
+		Message:        message,
+		MessageType:    providers.MessageTypeTransactional,
+		CallbackURL:    req.CallbackURL,
+		Timezone:       req.Timezone,
+		SendAt:         sendAt,
+	}
+}
+
 func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
 	// Get pagination params
 	limit := 50
@@ -287,6 +586,12 @@ func (s *Server) sendOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dest, ok := s.checkDestination(w, r, req.PhoneNumber)
+	if !ok {
+		return
+	}
+	req.PhoneNumber = dest.Number
+
 	purpose := otp.Purpose(req.Purpose)
 	if purpose == "" {
 		purpose = otp.PurposeVerification
@@ -313,8 +618,14 @@ func (s *Server) sendOTP(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := s.otpService.Send(r.Context(), otpReq)
 	if err != nil {
-		if err == otp.ErrResendCooldown {
-			s.sendError(w, http.StatusTooManyRequests, "cooldown", "Please wait before requesting a new OTP")
+		var blocked *otp.ResendBlockedError
+		if errors.As(err, &blocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+			if errors.Is(err, otp.ErrResendLimit) {
+				s.sendError(w, http.StatusTooManyRequests, "resend_limit", "Too many OTPs requested for this phone number")
+			} else {
+				s.sendError(w, http.StatusTooManyRequests, "cooldown", "Please wait before requesting a new OTP")
+			}
 			return
 		}
 		s.logger.Error("Failed to send OTP", zap.Error(err))
@@ -341,6 +652,11 @@ func (s *Server) verifyOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Codes are stored against the normalized number they were sent to
+	if dest, err := geo.Parse(req.PhoneNumber); err == nil {
+		req.PhoneNumber = dest.Number
+	}
+
 	verifyReq := &otp.VerifyRequest{
 		RequestID:   req.RequestID,
 		PhoneNumber: req.PhoneNumber,
@@ -357,8 +673,8 @@ func (s *Server) verifyOTP(w http.ResponseWriter, r *http.Request) {
 			s.sendError(w, http.StatusNotFound, "not_found", "OTP not found or expired")
 		case otp.ErrOTPExpired:
 			s.sendError(w, http.StatusGone, "expired", "OTP has expired")
-		case otp.ErrOTPMaxAttempts:
-			s.sendError(w, http.StatusTooManyRequests, "max_attempts", "Maximum verification attempts exceeded")
+		case otp.ErrOTPBurned:
+			s.sendError(w, http.StatusGone, "otp_burned", "OTP invalidated after too many failed attempts, request a new one")
 		case otp.ErrOTPInvalid:
 			s.sendSuccess(w, http.StatusOK, resp) // Return validation result
 		case otp.ErrOTPAlreadyUsed:
@@ -373,13 +689,7 @@ func (s *Server) verifyOTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) resendOTP(w http.ResponseWriter, r *http.Request) {
-	var req OTPRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
-		return
-	}
-
-	// Same as sendOTP - creates a new OTP
+	// Same as sendOTP - creates a new OTP, subject to the resend throttle
 	s.sendOTP(w, r)
 }
 
@@ -558,58 +868,202 @@ func (s *Server) getProviderBalance(w http.ResponseWriter, r *http.Request) {
 // Webhook Handlers
 // =============================================================================
 
+// maxWebhookBodySize bounds the size of provider callback payloads
+const maxWebhookBodySize = 64 << 10
+
 func (s *Server) handleTwilioWebhook(w http.ResponseWriter, r *http.Request) {
-	body := make([]byte, r.ContentLength)
-	r.Body.Read(body)
+	s.handleDeliveryReceipt(w, r, "twilio")
+}
 
-	provider, err := s.providerManager.Get("twilio")
+func (s *Server) handleVonageWebhook(w http.ResponseWriter, r *http.Request) {
+	s.handleDeliveryReceipt(w, r, "vonage")
+}
+
+// handleDeliveryReceipt verifies a provider delivery receipt (DLR) and advances
+// the message status. Receipts may arrive twice or out of order, so a message
+// only ever moves forward and final statuses are never replaced.
+func (s *Server) handleDeliveryReceipt(w http.ResponseWriter, r *http.Request, providerName string) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
 	if err != nil {
-		s.logger.Error("Twilio provider not found")
-		w.WriteHeader(http.StatusOK)
+		s.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	provider, err := s.providerManager.Get(providerName)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "not_found", "Provider not configured")
+		return
+	}
+
+	verifier, ok := provider.(providers.WebhookVerifier)
+	if !ok {
+		s.sendError(w, http.StatusNotImplemented, "unsupported", "Provider does not sign delivery receipts")
+		return
+	}
+	if err := verifier.VerifyWebhook(s.webhookURL(r), r.Header, body); err != nil {
+		s.logger.Warn("Rejected delivery receipt", zap.String("provider", providerName), zap.Error(err))
+		s.sendError(w, http.StatusForbidden, "invalid_signature", "Invalid webhook signature")
 		return
 	}
 
 	report, err := provider.ParseWebhook(body)
 	if err != nil {
-		s.logger.Error("Failed to parse Twilio webhook", zap.Error(err))
+		s.logger.Warn("Failed to parse delivery receipt", zap.String("provider", providerName), zap.Error(err))
+		s.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid delivery receipt")
+		return
+	}
+
+	record := &repository.DeliveryReport{
+		Provider:     providerName,
+		ProviderID:   report.ProviderID,
+		Status:       string(report.Status),
+		StatusCode:   report.StatusMessage,
+		ErrorCode:    report.ErrorCode,
+		ErrorMessage: report.ErrorMessage,
+	}
+
+	msg, err := s.repo.GetMessageByProviderID(r.Context(), providerName, report.ProviderID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error("Failed to look up message for delivery receipt", zap.Error(err))
+		s.sendError(w, http.StatusInternalServerError, "lookup_failed", "Failed to process delivery receipt")
+		return
+	}
+	if msg != nil {
+		record.MessageID = &msg.ID
+	}
+	if err := s.repo.CreateDeliveryReport(r.Context(), record); err != nil {
+		s.logger.Warn("Failed to record delivery receipt", zap.Error(err))
+	}
+
+	if msg == nil {
+		s.logger.Warn("Delivery receipt for unknown message",
+			zap.String("provider", providerName),
+			zap.String("provider_id", report.ProviderID),
+		)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Prices may come with any receipt, including stale ones. Only a receipt
+	// that counts the message's segments prices the whole message.
+	if report.Currency != "" {
+		geo.CheckSegments(providerName, msg.EstimatedSegments, report.SegmentCount)
+		if err := s.repo.RecordMessagePrice(r.Context(), msg.ID, report.SegmentCount, report.Cost, report.Currency, report.SegmentCount > 0); err != nil {
+			s.logger.Warn("Failed to record message price", zap.String("message_id", msg.ID), zap.Error(err))
+		}
+	}
+
+	fromStatuses := report.Status.Supersedes()
+	if len(fromStatuses) == 0 {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Update message status
-	msg, err := s.repo.GetMessageByProviderID(r.Context(), "twilio", report.ProviderID)
-	if err == nil && msg != nil {
-		s.repo.UpdateMessageStatus(r.Context(), msg.ID, string(report.Status), report.ErrorCode, report.ErrorMessage, report.DeliveredAt)
+	deliveredAt := report.DeliveredAt
+	if report.Status == providers.DeliveryStatusDelivered && deliveredAt == nil {
+		now := time.Now()
+		deliveredAt = &now
+	}
+
+	from := make([]string, len(fromStatuses))
+	for i, status := range fromStatuses {
+		from[i] = string(status)
+	}
+
+	updated, err := s.repo.AdvanceMessageStatus(r.Context(), msg.ID, string(report.Status), report.ErrorCode, report.ErrorMessage, deliveredAt, from)
+	if err != nil {
+		s.logger.Error("Failed to update message status", zap.String("message_id", msg.ID), zap.Error(err))
+		s.sendError(w, http.StatusInternalServerError, "update_failed", "Failed to process delivery receipt")
+		return
+	}
+	if !updated {
+		s.logger.Debug("Ignored stale delivery receipt",
+			zap.String("message_id", msg.ID),
+			zap.String("status", string(report.Status)),
+		)
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) handleVonageWebhook(w http.ResponseWriter, r *http.Request) {
-	body := make([]byte, r.ContentLength)
-	r.Body.Read(body)
+// webhookURL reconstructs the public URL a provider used to reach this request
+func (s *Server) webhookURL(r *http.Request) string {
+	if base := s.config.Providers.WebhookBaseURL; base != "" {
+		return strings.TrimSuffix(base, "/") + r.URL.RequestURI()
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// =============================================================================
+// Country Rule Handlers
+// =============================================================================
+
+func (s *Server) getCountryRules(w http.ResponseWriter, r *http.Request) {
+	organizationID := s.getOrganizationID(r)
+	if organizationID == "" {
+		s.sendError(w, http.StatusForbidden, "no_organization", "Country rules require an organization")
+		return
+	}
 
-	provider, err := s.providerManager.Get("vonage")
+	rules, err := s.repo.GetCountryRules(r.Context(), organizationID)
 	if err != nil {
-		s.logger.Error("Vonage provider not found")
-		w.WriteHeader(http.StatusOK)
+		s.logger.Error("Failed to get country rules", zap.Error(err))
+		s.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to get country rules")
 		return
 	}
 
-	report, err := provider.ParseWebhook(body)
+	s.sendSuccess(w, http.StatusOK, rules)
+}
+
+func (s *Server) updateCountryRules(w http.ResponseWriter, r *http.Request) {
+	organizationID := s.getOrganizationID(r)
+	if organizationID == "" {
+		s.sendError(w, http.StatusForbidden, "no_organization", "Country rules require an organization")
+		return
+	}
+
+	var req CountryRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	allowlist, err := geo.NormalizeCallingCodes(req.Allowlist)
 	if err != nil {
-		s.logger.Error("Failed to parse Vonage webhook", zap.Error(err))
-		w.WriteHeader(http.StatusOK)
+		s.sendError(w, http.StatusBadRequest, "invalid_allowlist", err.Error())
+		return
+	}
+	blocklist, err := geo.NormalizeCallingCodes(req.Blocklist)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "invalid_blocklist", err.Error())
 		return
 	}
 
-	// Update message status
-	msg, err := s.repo.GetMessageByProviderID(r.Context(), "vonage", report.ProviderID)
-	if err == nil && msg != nil {
-		s.repo.UpdateMessageStatus(r.Context(), msg.ID, string(report.Status), report.ErrorCode, report.ErrorMessage, report.DeliveredAt)
+	rules := &repository.CountryRules{
+		OrganizationID: organizationID,
+		Allowlist:      allowlist,
+		Blocklist:      blocklist,
+	}
+	if err := s.repo.SaveCountryRules(r.Context(), rules); err != nil {
+		s.logger.Error("Failed to save country rules", zap.Error(err))
+		s.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to save country rules")
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	s.logger.Info("Country rules updated",
+		zap.String("organization_id", organizationID),
+		zap.Strings("allowlist", allowlist),
+		zap.Strings("blocklist", blocklist))
+
+	s.sendSuccess(w, http.StatusOK, rules)
 }
 
 // =============================================================================
@@ -670,6 +1124,68 @@ func (s *Server) getUsageStats(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// costReportMaxRange bounds the period of a cost report
+const costReportMaxRange = 366 * 24 * time.Hour
+
+// getCostReport aggregates the spend on messages sent between from and to
+// (RFC 3339, default the last 30 days) by provider and destination country.
+// Callers without an organization may pick one with org or report on all;
+// everyone else only sees their own organization.
+func (s *Server) getCostReport(w http.ResponseWriter, r *http.Request) {
+	organizationID := s.getOrganizationID(r)
+	if org := r.URL.Query().Get("org"); org != "" {
+		if _, err := uuid.Parse(org); err != nil {
+			s.sendError(w, http.StatusBadRequest, "invalid_org", "org must be an organization ID")
+			return
+		}
+		if organizationID != "" && org != organizationID {
+			s.sendError(w, http.StatusForbidden, "forbidden", "Cannot report on another organization")
+			return
+		}
+		organizationID = org
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, "invalid_"+name, name+" must be an RFC 3339 time")
+			return
+		}
+		*t = parsed
+	}
+	if !from.Before(to) || to.Sub(from) > costReportMaxRange {
+		s.sendError(w, http.StatusBadRequest, "invalid_range", "from must be before to and at most a year earlier")
+		return
+	}
+
+	summaries, err := s.repo.GetCostSummaries(r.Context(), organizationID, from, to)
+	if err != nil {
+		s.logger.Error("Failed to get cost report", zap.Error(err))
+		s.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to get cost report")
+		return
+	}
+
+	// Totals are per currency; providers don't all bill in the same one
+	totals := make(map[string]float64)
+	for _, summary := range summaries {
+		totals[summary.Currency] = math.Round((totals[summary.Currency]+summary.Cost)*1e6) / 1e6
+	}
+
+	s.sendSuccess(w, http.StatusOK, map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"org":     organizationID,
+		"totals":  totals,
+		"results": summaries,
+	})
+}
+
 // =============================================================================
 // Response Helpers
 // =============================================================================
@@ -17,10 +17,13 @@ import (
 	"go.uber.org/zap"
 
 	"sms-gateway/internal/config"
+	"sms-gateway/internal/geo"
+	"sms-gateway/internal/lookup"
 	"sms-gateway/internal/otp"
 	"sms-gateway/internal/providers"
 	"sms-gateway/internal/ratelimit"
 	"sms-gateway/internal/repository"
+	"sms-gateway/internal/scheduler"
 	"sms-gateway/internal/templates"
 )
 
@@ -62,6 +65,9 @@ type Server struct {
 	repo            *repository.Repository
 	providerManager *providers.Manager
 	otpService      *otp.Service
+	scheduler       *scheduler.Scheduler
+	geo             *geo.Checker
+	lookup          *lookup.Service
 	rateLimiter     *ratelimit.Limiter
 	templates       *templates.Engine
 	logger          *zap.Logger
@@ -73,6 +79,9 @@ func NewServer(
 	repo *repository.Repository,
 	pm *providers.Manager,
 	otpSvc *otp.Service,
+	sched *scheduler.Scheduler,
+	gc *geo.Checker,
+	ls *lookup.Service,
 	rl *ratelimit.Limiter,
 	te *templates.Engine,
 	logger *zap.Logger,
@@ -82,6 +91,9 @@ func NewServer(
 		repo:            repo,
 		providerManager: pm,
 		otpService:      otpSvc,
+		scheduler:       sched,
+		geo:             gc,
+		lookup:          ls,
 		rateLimiter:     rl,
 		templates:       te,
 		logger:          logger,
@@ -118,6 +130,12 @@ func (s *Server) Router() http.Handler {
 	// Health check (no auth)
 	r.Get("/health", s.healthCheck)
 
+	// Delivery receipts (authenticated by provider signature)
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Post("/twilio/dlr", s.handleTwilioWebhook)
+		r.Post("/vonage/dlr", s.handleVonageWebhook)
+	})
+
 	// API v1 routes
 	r.Route("/api/v1", func(r chi.Router) {
 		// Auth middleware
@@ -130,8 +148,22 @@ func (s *Server) Router() http.Handler {
 			r.Post("/send-bulk", s.sendBulkSMS)
 			r.Get("/status/{messageId}", s.getMessageStatus)
 			r.Get("/messages", s.listMessages)
+			r.Post("/estimate", s.estimateSMS)
 		})
 
+		// Destination country rules
+		// Number validation and carrier lookups
+		r.Post("/validate", s.validateNumber)
+
+		r.Route("/country-rules", func(r chi.Router) {
+			r.Get("/", s.getCountryRules)
+			r.Put("/", s.updateCountryRules)
+		})
+
+		// Message endpoints
+		r.Get("/messages/{messageId}", s.getMessage)
+		r.Delete("/messages/{messageId}", s.cancelMessage)
+
 		// OTP endpoints
 		r.Route("/otp", func(r chi.Router) {
 			r.Post("/send", s.sendOTP)
@@ -168,6 +200,9 @@ func (s *Server) Router() http.Handler {
 			r.Get("/summary", s.getAnalyticsSummary)
 			r.Get("/usage", s.getUsageStats)
 		})
+
+		// Spend reports
+		r.Get("/reports/costs", s.getCostReport)
 	})
 
 	return r
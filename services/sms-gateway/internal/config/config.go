@@ -40,6 +40,9 @@ type Config struct {
 	Metrics   MetricsConfig   `yaml:"metrics"`
 	RateLimit RateLimitConfig `yaml:"rateLimit"`
 	OTP       OTPConfig       `yaml:"otp"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Geo       GeoConfig       `yaml:"geo"`
+	Lookup    LookupConfig    `yaml:"lookup"`
 	Providers ProvidersConfig `yaml:"providers"`
 }
 
@@ -79,6 +82,10 @@ type RateLimitConfig struct {
 	OTPPerMinute      int  `yaml:"otpPerMinute"`
 	OTPPerHour        int  `yaml:"otpPerHour"`
 	OTPPerPhonePerDay int  `yaml:"otpPerPhonePerDay"`
+
+	// Carrier lookups per organization; cached results don't count
+	LookupPerMinute int `yaml:"lookupPerMinute"`
+	LookupPerDay    int `yaml:"lookupPerDay"`
 }
 
 type OTPConfig struct {
@@ -88,14 +95,63 @@ type OTPConfig struct {
 	ResendCooldown  time.Duration `yaml:"resendCooldown"`
 	Alphanumeric    bool          `yaml:"alphanumeric"`
 	CaseSensitive   bool          `yaml:"caseSensitive"`
+
+	// MaxResends caps the OTPs sent to one phone number per ResendWindow
+	MaxResends   int           `yaml:"maxResends"`
+	ResendWindow time.Duration `yaml:"resendWindow"`
+}
+
+type SchedulerConfig struct {
+	PollInterval time.Duration    `yaml:"pollInterval"`
+	BatchSize    int              `yaml:"batchSize"`
+	QuietHours   QuietHoursConfig `yaml:"quietHours"`
+}
+
+// QuietHoursConfig holds back scheduled messages that would otherwise arrive
+// at night in the recipient's time zone. Start and End are local "HH:MM"
+// times; a window that wraps midnight is allowed.
+type QuietHoursConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	Start           string `yaml:"start"`
+	End             string `yaml:"end"`
+	DefaultTimezone string `yaml:"defaultTimezone"`
+
+	// CountryTimezones maps E.164 calling code prefixes (without "+") to the
+	// IANA time zone used for recipients in that country
+	CountryTimezones map[string]string `yaml:"countryTimezones"`
+}
+
+// GeoConfig prices destinations for cost estimates. Countries are identified
+// by E.164 calling code (without "+"); codes not listed in any tier are
+// priced with DefaultTier.
+type GeoConfig struct {
+	Currency    string            `yaml:"currency"`
+	DefaultTier string            `yaml:"defaultTier"`
+	PriceTiers  []PriceTierConfig `yaml:"priceTiers"`
+}
+
+// LookupConfig controls carrier lookups on the validate endpoint. Lookups
+// are billed by the provider, so results are cached per number for CacheTTL.
+type LookupConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	CacheTTL time.Duration `yaml:"cacheTtl"`
+}
+
+// PriceTierConfig is an approximate per-segment price shared by a group of
+// destination countries
+type PriceTierConfig struct {
+	Name            string   `yaml:"name"`
+	PricePerSegment float64  `yaml:"pricePerSegment"`
+	CallingCodes    []string `yaml:"callingCodes"`
 }
 
 type ProvidersConfig struct {
-	Default string        `yaml:"default"`
-	Twilio  TwilioConfig  `yaml:"twilio"`
-	Vonage  VonageConfig  `yaml:"vonage"`
-	SMPP    SMPPConfig    `yaml:"smpp"`
-	GSM     GSMConfig     `yaml:"gsm"`
+	Default        string       `yaml:"default"`
+	WebhookBaseURL string       `yaml:"webhookBaseUrl"`
+	Twilio         TwilioConfig `yaml:"twilio"`
+	Vonage         VonageConfig `yaml:"vonage"`
+	SMPP           SMPPConfig   `yaml:"smpp"`
+	GSM            GSMConfig    `yaml:"gsm"`
 }
 
 type TwilioConfig struct {
@@ -108,13 +164,14 @@ type TwilioConfig struct {
 }
 
 type VonageConfig struct {
-	Enabled       bool   `yaml:"enabled"`
-	Priority      int    `yaml:"priority"`
-	APIKey        string `yaml:"apiKey"`
-	APISecret     string `yaml:"apiSecret"`
-	FromNumber    string `yaml:"fromNumber"`
-	ApplicationID string `yaml:"applicationId"`
-	PrivateKey    string `yaml:"privateKey"`
+	Enabled         bool   `yaml:"enabled"`
+	Priority        int    `yaml:"priority"`
+	APIKey          string `yaml:"apiKey"`
+	APISecret       string `yaml:"apiSecret"`
+	FromNumber      string `yaml:"fromNumber"`
+	ApplicationID   string `yaml:"applicationId"`
+	PrivateKey      string `yaml:"privateKey"`
+	SignatureSecret string `yaml:"signatureSecret"`
 }
 
 type SMPPConfig struct {
@@ -185,6 +242,12 @@ func setDefaults(cfg *Config) {
 	if cfg.RateLimit.OTPPerPhonePerDay == 0 {
 		cfg.RateLimit.OTPPerPhonePerDay = 5
 	}
+	if cfg.RateLimit.LookupPerMinute == 0 {
+		cfg.RateLimit.LookupPerMinute = 10
+	}
+	if cfg.RateLimit.LookupPerDay == 0 {
+		cfg.RateLimit.LookupPerDay = 500
+	}
 
 	// OTP defaults
 	if cfg.OTP.Length == 0 {
@@ -199,4 +262,40 @@ func setDefaults(cfg *Config) {
 	if cfg.OTP.ResendCooldown == 0 {
 		cfg.OTP.ResendCooldown = 60 * time.Second
 	}
+	if cfg.OTP.MaxResends == 0 {
+		cfg.OTP.MaxResends = 5
+	}
+	if cfg.OTP.ResendWindow == 0 {
+		cfg.OTP.ResendWindow = time.Hour
+	}
+
+	// Scheduler defaults
+	if cfg.Scheduler.PollInterval == 0 {
+		cfg.Scheduler.PollInterval = 30 * time.Second
+	}
+	if cfg.Scheduler.BatchSize == 0 {
+		cfg.Scheduler.BatchSize = 100
+	}
+	if cfg.Scheduler.QuietHours.Start == "" {
+		cfg.Scheduler.QuietHours.Start = "21:00"
+	}
+	if cfg.Scheduler.QuietHours.End == "" {
+		cfg.Scheduler.QuietHours.End = "08:00"
+	}
+	if cfg.Scheduler.QuietHours.DefaultTimezone == "" {
+		cfg.Scheduler.QuietHours.DefaultTimezone = "UTC"
+	}
+
+	// Geo defaults
+	if cfg.Geo.Currency == "" {
+		cfg.Geo.Currency = "USD"
+	}
+	if cfg.Geo.DefaultTier == "" {
+		cfg.Geo.DefaultTier = "standard"
+	}
+
+	// Lookup defaults
+	if cfg.Lookup.CacheTTL == 0 {
+		cfg.Lookup.CacheTTL = 7 * 24 * time.Hour
+	}
 }
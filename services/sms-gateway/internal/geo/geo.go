@@ -0,0 +1,193 @@
+// Package geo validates SMS destinations and enforces per-organization
+// country rules before a message is handed to a provider. Countries are
+// identified by their E.164 calling code.
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"sms-gateway/internal/config"
+	"sms-gateway/internal/repository"
+)
+
+// Reasons a destination is rejected
+const (
+	ReasonInvalidNumber  = "invalid_number"
+	ReasonBlocklisted    = "blocklisted"
+	ReasonNotAllowlisted = "not_allowlisted"
+)
+
+// ErrInvalidNumber is returned for numbers that aren't valid E.164
+var ErrInvalidNumber = errors.New("phone number must be in E.164 format, e.g. +14155550123")
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+// Destination is a validated recipient number
+type Destination struct {
+	Number      string `json:"to"`
+	CallingCode string `json:"calling_code"`
+	Country     string `json:"country"`
+}
+
+// BlockedError is returned when an organization's country rules don't
+// allow a destination
+type BlockedError struct {
+	Destination *Destination
+	Reason      string
+}
+
+func (e *BlockedError) Error() string {
+	if e.Reason == ReasonNotAllowlisted {
+		return fmt.Sprintf("destination country +%s (%s) is not in the organization's allowlist", e.Destination.CallingCode, e.Destination.Country)
+	}
+	return fmt.Sprintf("destination country +%s (%s) is blocked for the organization", e.Destination.CallingCode, e.Destination.Country)
+}
+
+// Parse normalizes a phone number to E.164 and identifies its country.
+// Spaces, dashes, dots and parentheses are removed and a leading "00"
+// international prefix is accepted; anything else that isn't a complete
+// international number with an assigned calling code is rejected.
+func Parse(number string) (*Destination, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')', '\t':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+
+	switch {
+	case strings.HasPrefix(cleaned, "+"):
+	case strings.HasPrefix(cleaned, "00"):
+		cleaned = "+" + cleaned[2:]
+	default:
+		// Providers have always accepted numbers without the "+"
+		cleaned = "+" + cleaned
+	}
+
+	if !e164Pattern.MatchString(cleaned) {
+		return nil, ErrInvalidNumber
+	}
+
+	digits := cleaned[1:]
+	for n := 1; n <= 3; n++ {
+		if country, ok := callingCodes[digits[:n]]; ok {
+			return &Destination{Number: cleaned, CallingCode: digits[:n], Country: country}, nil
+		}
+	}
+	return nil, ErrInvalidNumber
+}
+
+// checkRules returns why rules reject a calling code, or "" if they allow it.
+// The blocklist wins over the allowlist.
+func checkRules(rules *repository.CountryRules, callingCode string) string {
+	for _, code := range rules.Blocklist {
+		if code == callingCode {
+			return ReasonBlocklisted
+		}
+	}
+	if len(rules.Allowlist) == 0 {
+		return ""
+	}
+	for _, code := range rules.Allowlist {
+		if code == callingCode {
+			return ""
+		}
+	}
+	return ReasonNotAllowlisted
+}
+
+// NormalizeCallingCodes validates and deduplicates the calling codes of a
+// country rule list, returning them without "+"
+func NormalizeCallingCodes(codes []string) ([]string, error) {
+	seen := make(map[string]bool, len(codes))
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code = trimPlus(strings.TrimSpace(code))
+		if !ValidCallingCode(code) {
+			return nil, fmt.Errorf("%q is not an E.164 country calling code", code)
+		}
+		if !seen[code] {
+			seen[code] = true
+			normalized = append(normalized, code)
+		}
+	}
+	return normalized, nil
+}
+
+func trimPlus(code string) string {
+	return strings.TrimPrefix(code, "+")
+}
+
+// Checker applies organizations' country rules to destinations
+type Checker struct {
+	repo    *repository.Repository
+	pricing *Pricing
+	logger  *zap.Logger
+}
+
+// NewChecker creates a new destination checker
+func NewChecker(cfg config.GeoConfig, repo *repository.Repository, logger *zap.Logger) *Checker {
+	return &Checker{
+		repo:    repo,
+		pricing: NewPricing(cfg),
+		logger:  logger,
+	}
+}
+
+// Check validates a destination number and the organization's country rules
+// for it, returning the normalized destination. Errors are ErrInvalidNumber,
+// a *BlockedError or a failure to load the rules. Requests without an
+// organization are only validated.
+func (c *Checker) Check(ctx context.Context, organizationID, number string) (*Destination, error) {
+	dest, err := Parse(number)
+	if err != nil {
+		rejected.WithLabelValues(ReasonInvalidNumber, "").Inc()
+		return nil, err
+	}
+	if organizationID == "" {
+		return dest, nil
+	}
+
+	rules, err := c.repo.GetCountryRules(ctx, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("load country rules: %w", err)
+	}
+
+	if reason := checkRules(rules, dest.CallingCode); reason != "" {
+		rejected.WithLabelValues(reason, dest.CallingCode).Inc()
+		c.logger.Info("SMS destination blocked",
+			zap.String("organization_id", organizationID),
+			zap.String("calling_code", dest.CallingCode),
+			zap.String("reason", reason))
+		return nil, &BlockedError{Destination: dest, Reason: reason}
+	}
+	return dest, nil
+}
+
+// Estimate previews a send without sending: the destination country, whether
+// the organization may send there, and the approximate price of message
+func (c *Checker) Estimate(ctx context.Context, organizationID, number, message string) (*Estimate, error) {
+	dest, err := Parse(number)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := c.pricing.Estimate(dest, message)
+	if organizationID != "" {
+		rules, err := c.repo.GetCountryRules(ctx, organizationID)
+		if err != nil {
+			return nil, fmt.Errorf("load country rules: %w", err)
+		}
+		estimate.BlockedReason = checkRules(rules, dest.CallingCode)
+	}
+	estimate.Allowed = estimate.BlockedReason == ""
+
+	return estimate, nil
+}
@@ -0,0 +1,227 @@
+package geo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"sms-gateway/internal/config"
+	"sms-gateway/internal/repository"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		number      string
+		want        string
+		callingCode string
+		country     string
+	}{
+		{"+14155550123", "+14155550123", "1", "US"},
+		{"+1 (415) 555-0123", "+14155550123", "1", "US"},
+		{"0044 20 7946 0958", "+442079460958", "44", "GB"},
+		{"447911123456", "+447911123456", "44", "GB"},
+		{"+234 803 123 4567", "+2348031234567", "234", "NG"},
+		{"+971.50.123.4567", "+971501234567", "971", "AE"},
+		{"+79161234567", "+79161234567", "7", "RU"},
+	}
+	for _, tt := range tests {
+		dest, err := Parse(tt.number)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.number, err)
+			continue
+		}
+		if dest.Number != tt.want || dest.CallingCode != tt.callingCode || dest.Country != tt.country {
+			t.Errorf("Parse(%q) = %+v, want %s +%s %s", tt.number, dest, tt.want, tt.callingCode, tt.country)
+		}
+	}
+
+	for _, bad := range []string{
+		"",
+		"+",
+		"07911 123456",      // national format
+		"+0123456789",       // no calling code starts with 0
+		"+141555",           // shorter than 7 digits
+		"+1234567890123456", // longer than 15 digits
+		"+1 415 CALL NOW",
+		"+44 20 7946 0958 ext 12",
+		"+8091234567", // +80x is not assigned to a country
+	} {
+		if dest, err := Parse(bad); err != ErrInvalidNumber {
+			t.Errorf("Parse(%q) = %+v, %v; want ErrInvalidNumber", bad, dest, err)
+		}
+	}
+}
+
+func TestCallingCodesArePrefixFree(t *testing.T) {
+	for code := range callingCodes {
+		for n := 1; n < len(code); n++ {
+			if _, ok := callingCodes[code[:n]]; ok {
+				t.Errorf("calling code %s has assigned prefix %s", code, code[:n])
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, good := range []string{
+		"+14155550123",
+		"+1 (212) 201-0123",
+		"+447911123456",
+		"+442079460958",
+		"+33612345678",
+		"+390612345678", // Italian landlines keep their leading 0
+		"+2348031234567",
+		"+971501234567",
+		"+79161234567",
+		"+6591234567",
+		"+37060012345", // no numbering plan, E.164 checks only
+	} {
+		if _, err := Validate(good); err != nil {
+			t.Errorf("Validate(%q) error = %v", good, err)
+		}
+	}
+
+	tests := []struct {
+		number string
+		reason string
+	}{
+		{"07911 123456", ReasonInvalidFormat},
+		{"+8091234567", ReasonInvalidFormat},
+		{"+1415555012", ReasonTooShort},
+		{"+141555501234", ReasonTooLong},
+		{"+44 07911 123456", ReasonTooLong},
+		{"+44 020 7946 095", ReasonInvalidPrefix}, // trunk prefix kept
+		{"+11155550123", ReasonInvalidPrefix},     // area code starts with 1
+		{"+14115550123", ReasonInvalidPrefix},     // N11 area code
+		{"+14150550123", ReasonInvalidPrefix},     // exchange starts with 0
+		{"+6521234567", ReasonInvalidPrefix},
+	}
+	for _, tt := range tests {
+		dest, err := Validate(tt.number)
+		var numErr *NumberError
+		if !errors.As(err, &numErr) || numErr.Reason != tt.reason {
+			t.Errorf("Validate(%q) = %+v, %v; want reason %s", tt.number, dest, err, tt.reason)
+			continue
+		}
+		if !errors.Is(err, ErrInvalidNumber) {
+			t.Errorf("Validate(%q) error doesn't match ErrInvalidNumber", tt.number)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist []string
+		blocklist []string
+		code      string
+		want      string
+	}{
+		{"no rules", nil, nil, "44", ""},
+		{"blocklisted", nil, []string{"234", "7"}, "7", ReasonBlocklisted},
+		{"not blocklisted", nil, []string{"234"}, "44", ""},
+		{"allowlisted", []string{"1", "44"}, nil, "44", ""},
+		{"outside allowlist", []string{"1", "44"}, nil, "33", ReasonNotAllowlisted},
+		{"blocklist wins", []string{"44"}, []string{"44"}, "44", ReasonBlocklisted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := &repository.CountryRules{Allowlist: tt.allowlist, Blocklist: tt.blocklist}
+			if got := checkRules(rules, tt.code); got != tt.want {
+				t.Errorf("checkRules() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockedError(t *testing.T) {
+	dest := &Destination{Number: "+2348031234567", CallingCode: "234", Country: "NG"}
+	err := &BlockedError{Destination: dest, Reason: ReasonBlocklisted}
+	if !strings.Contains(err.Error(), "+234 (NG) is blocked") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	err.Reason = ReasonNotAllowlisted
+	if !strings.Contains(err.Error(), "not in the organization's allowlist") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestNormalizeCallingCodes(t *testing.T) {
+	got, err := NormalizeCallingCodes([]string{"+44", "1", " 234 ", "44"})
+	if err != nil {
+		t.Fatalf("NormalizeCallingCodes() error = %v", err)
+	}
+	if strings.Join(got, ",") != "44,1,234" {
+		t.Errorf("NormalizeCallingCodes() = %v", got)
+	}
+
+	for _, bad := range []string{"", "999", "4", "US", "+4420"} {
+		if _, err := NormalizeCallingCodes([]string{bad}); err == nil {
+			t.Errorf("NormalizeCallingCodes(%q) accepted", bad)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    int
+	}{
+		{"empty", "", 1},
+		{"short", "Your code is 123456", 1},
+		{"160 GSM characters", strings.Repeat("a", 160), 1},
+		{"161 GSM characters", strings.Repeat("a", 161), 2},
+		{"306 GSM characters", strings.Repeat("a", 306), 2},
+		{"307 GSM characters", strings.Repeat("a", 307), 3},
+		{"extended characters count twice", strings.Repeat("€", 80), 1},
+		{"extended characters over a segment", strings.Repeat("€", 81), 2},
+		{"70 UCS-2 characters", strings.Repeat("ж", 70), 1},
+		{"71 UCS-2 characters", strings.Repeat("ж", 71), 2},
+		{"one emoji switches to UCS-2", strings.Repeat("a", 69) + "🙂", 2},
+		{"134 UCS-2 characters", strings.Repeat("ж", 134), 2},
+		{"135 UCS-2 characters", strings.Repeat("ж", 135), 3},
+		{"surrogate pair not split at 67", strings.Repeat("ж", 66) + "🙂" + strings.Repeat("ж", 66), 3},
+		{"escape not split at 153", strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Segments(tt.message); got != tt.want {
+				t.Errorf("Segments() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPricingEstimate(t *testing.T) {
+	pricing := NewPricing(config.GeoConfig{
+		Currency:    "USD",
+		DefaultTier: "standard",
+		PriceTiers: []config.PriceTierConfig{
+			{Name: "low", PricePerSegment: 0.0083, CallingCodes: []string{"+1"}},
+			{Name: "standard", PricePerSegment: 0.045, CallingCodes: []string{"44"}},
+			{Name: "premium", PricePerSegment: 0.18, CallingCodes: []string{"234"}},
+		},
+	})
+
+	tests := []struct {
+		number  string
+		message string
+		tier    string
+		cost    float64
+	}{
+		{"+14155550123", "hello", "low", 0.0083},
+		{"+2348031234567", strings.Repeat("a", 200), "premium", 0.36},
+		{"+33612345678", "bonjour", "standard", 0.045}, // not listed, default tier
+	}
+	for _, tt := range tests {
+		dest, err := Parse(tt.number)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.number, err)
+		}
+		got := pricing.Estimate(dest, tt.message)
+		if got.PriceTier != tt.tier || got.EstimatedCost != tt.cost || got.Currency != "USD" {
+			t.Errorf("Estimate(%s) = %+v, want tier %s cost %v", tt.number, got, tt.tier, tt.cost)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package geo
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// rejected counts sends refused before provider dispatch because of their
+// destination.
+var rejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sms_destination_rejected_total",
+	Help: "Number of SMS sends rejected before dispatch because of the destination, by reason and calling code.",
+}, []string{"reason", "calling_code"})
+
+// segmentMismatches counts messages a provider charged a different number of
+// segments for than computed locally.
+var segmentMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sms_segment_mismatch_total",
+	Help: "Number of SMS messages whose provider-reported segment count differs from the local estimate, by provider.",
+}, []string{"provider"})
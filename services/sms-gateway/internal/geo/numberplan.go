@@ -0,0 +1,118 @@
+package geo
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Reasons a number fails validation
+const (
+	ReasonInvalidFormat = "invalid_format"
+	ReasonTooShort      = "too_short"
+	ReasonTooLong       = "too_long"
+	ReasonInvalidPrefix = "invalid_prefix"
+)
+
+// NumberError explains why a number can't be a working phone number. It
+// matches ErrInvalidNumber with errors.Is.
+type NumberError struct {
+	Reason  string
+	Message string
+}
+
+func (e *NumberError) Error() string { return e.Message }
+
+func (e *NumberError) Unwrap() error { return ErrInvalidNumber }
+
+// numberPlan describes the national significant numbers (the digits after
+// the calling code) a country assigns
+type numberPlan struct {
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp // Matched against the whole national number, if set
+}
+
+func plan(minLength, maxLength int, pattern string) numberPlan {
+	p := numberPlan{minLength: minLength, maxLength: maxLength}
+	if pattern != "" {
+		p.pattern = regexp.MustCompile(pattern)
+	}
+	return p
+}
+
+// numberPlans holds the national number lengths and leading digits of the
+// most common destinations, following the ITU numbering plans. Most
+// countries never start a national number with 0, which catches the common
+// mistake of keeping the trunk prefix, e.g. +44 07911 123456. Countries not
+// listed only get the E.164 checks.
+var numberPlans = map[string]numberPlan{
+	// NANP: a 3-digit area code and exchange, neither starting with 0 or 1
+	// nor of the N11 service form
+	"1":   plan(10, 10, `^[2-9](?:[02-9]\d|1[02-9])[2-9](?:[02-9]\d|1[02-9])\d{4}$`),
+	"7":   plan(10, 10, `^[3-9]`),
+	"20":  plan(8, 10, `^[1-9]`),
+	"27":  plan(9, 9, `^[1-9]`),
+	"31":  plan(9, 9, `^[1-9]`),
+	"32":  plan(8, 9, `^[1-9]`),
+	"33":  plan(9, 9, `^[1-9]`),
+	"34":  plan(9, 9, `^[5-9]`),
+	"39":  plan(6, 11, ""), // Italian landlines keep their leading 0
+	"41":  plan(9, 9, `^[1-9]`),
+	"44":  plan(7, 10, `^[1-9]`),
+	"48":  plan(9, 9, `^[1-9]`),
+	"52":  plan(10, 10, `^[1-9]`),
+	"55":  plan(10, 11, `^[1-9]`),
+	"61":  plan(9, 9, `^[1-9]`),
+	"62":  plan(8, 12, `^[1-9]`),
+	"63":  plan(8, 10, `^[1-9]`),
+	"64":  plan(8, 10, `^[1-9]`),
+	"65":  plan(8, 8, `^[3689]`),
+	"81":  plan(9, 10, `^[1-9]`),
+	"82":  plan(8, 11, `^[1-9]`),
+	"86":  plan(9, 11, `^[1-9]`),
+	"91":  plan(10, 10, `^[1-9]`),
+	"92":  plan(9, 10, `^[1-9]`),
+	"234": plan(8, 10, `^[1-9]`),
+	"254": plan(9, 9, `^[1-9]`),
+	"353": plan(7, 9, `^[1-9]`),
+	"880": plan(8, 10, `^[1-9]`),
+	"971": plan(8, 9, `^[1-9]`),
+}
+
+// Validate parses a number like Parse and also checks it against its
+// country's numbering plan, rejecting numbers that can't have been assigned
+// to a subscriber. It doesn't tell whether the number is in service; that
+// takes a carrier lookup. Errors are *NumberError.
+func Validate(number string) (*Destination, error) {
+	dest, err := Parse(number)
+	if err != nil {
+		return nil, &NumberError{Reason: ReasonInvalidFormat, Message: err.Error()}
+	}
+
+	p, ok := numberPlans[dest.CallingCode]
+	if !ok {
+		return dest, nil
+	}
+
+	national := dest.Number[1+len(dest.CallingCode):]
+	switch {
+	case len(national) < p.minLength:
+		return nil, &NumberError{Reason: ReasonTooShort, Message: p.lengthMessage(dest.CallingCode, len(national))}
+	case len(national) > p.maxLength:
+		return nil, &NumberError{Reason: ReasonTooLong, Message: p.lengthMessage(dest.CallingCode, len(national))}
+	case p.pattern != nil && !p.pattern.MatchString(national):
+		return nil, &NumberError{
+			Reason:  ReasonInvalidPrefix,
+			Message: fmt.Sprintf("%s is not a number that can be assigned under +%s", dest.Number, dest.CallingCode),
+		}
+	}
+	return dest, nil
+}
+
+func (p numberPlan) lengthMessage(callingCode string, length int) string {
+	digits := fmt.Sprintf("%d", p.minLength)
+	if p.maxLength != p.minLength {
+		digits = fmt.Sprintf("%d to %d", p.minLength, p.maxLength)
+	}
+	return fmt.Sprintf("+%s numbers have %s digits after the calling code, not %d", callingCode, digits, length)
+}
@@ -0,0 +1,154 @@
+package geo
+
+import (
+	"math"
+	"unicode/utf16"
+
+	"sms-gateway/internal/config"
+)
+
+// Segment sizes of concatenated SMS: a single message holds 160 GSM-7 or 70
+// UCS-2 characters, a part of a longer one 153 or 67
+const (
+	gsmSingle  = 160
+	gsmPart    = 153
+	ucs2Single = 70
+	ucs2Part   = 67
+)
+
+// gsmBasic is the GSM 03.38 default alphabet; gsmExtended characters take
+// an escape and count twice
+const (
+	gsmBasic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsmExtended = "^{}\\[~]|€\f"
+)
+
+var gsmBasicSet, gsmExtendedSet = runeSet(gsmBasic), runeSet(gsmExtended)
+
+func runeSet(s string) map[rune]bool {
+	set := make(map[rune]bool)
+	for _, r := range s {
+		set[r] = true
+	}
+	return set
+}
+
+// Estimate is the preview of sending a message to a destination
+type Estimate struct {
+	Destination
+	Allowed         bool    `json:"allowed"`
+	BlockedReason   string  `json:"blocked_reason,omitempty"`
+	PriceTier       string  `json:"price_tier"`
+	Segments        int     `json:"segments"`
+	PricePerSegment float64 `json:"price_per_segment"`
+	EstimatedCost   float64 `json:"estimated_cost"`
+	Currency        string  `json:"currency"`
+}
+
+// Pricing maps destination countries to configured price tiers
+type Pricing struct {
+	currency    string
+	defaultTier string
+	tiers       map[string]config.PriceTierConfig // by name
+	byCode      map[string]string                 // calling code -> tier name
+}
+
+// NewPricing creates the price lookup from configuration
+func NewPricing(cfg config.GeoConfig) *Pricing {
+	p := &Pricing{
+		currency:    cfg.Currency,
+		defaultTier: cfg.DefaultTier,
+		tiers:       make(map[string]config.PriceTierConfig),
+		byCode:      make(map[string]string),
+	}
+	for _, tier := range cfg.PriceTiers {
+		p.tiers[tier.Name] = tier
+		for _, code := range tier.CallingCodes {
+			p.byCode[trimPlus(code)] = tier.Name
+		}
+	}
+	return p
+}
+
+// Estimate returns the approximate price of sending message to dest
+func (p *Pricing) Estimate(dest *Destination, message string) *Estimate {
+	name, ok := p.byCode[dest.CallingCode]
+	if !ok {
+		name = p.defaultTier
+	}
+	tier := p.tiers[name]
+	segments := Segments(message)
+
+	return &Estimate{
+		Destination:     *dest,
+		PriceTier:       name,
+		Segments:        segments,
+		PricePerSegment: tier.PricePerSegment,
+		EstimatedCost:   math.Round(tier.PricePerSegment*float64(segments)*10000) / 10000,
+		Currency:        p.currency,
+	}
+}
+
+// Segments returns the number of SMS parts a message is sent as. Messages
+// using only the GSM-7 alphabet are packed 7 bits per character; any other
+// character switches the whole message to UCS-2. A concatenated message is
+// split at part boundaries without breaking an extended GSM character's
+// escape or a UTF-16 surrogate pair, so such characters may push a part to
+// the next one.
+func Segments(message string) int {
+	units, gsm := characterUnits(message)
+	single, part := gsmSingle, gsmPart
+	if !gsm {
+		single, part = ucs2Single, ucs2Part
+	}
+
+	total := 0
+	for _, u := range units {
+		total += u
+	}
+	if total <= single {
+		return 1
+	}
+
+	segments, used := 1, 0
+	for _, u := range units {
+		if used+u > part {
+			segments++
+			used = 0
+		}
+		used += u
+	}
+	return segments
+}
+
+// CheckSegments compares the segment count a provider charged for a message
+// with the local estimate, counting mismatches. Unknown counts (zero) match.
+func CheckSegments(provider string, estimated, charged int) bool {
+	if estimated == 0 || charged == 0 || estimated == charged {
+		return true
+	}
+	segmentMismatches.WithLabelValues(provider).Inc()
+	return false
+}
+
+// characterUnits returns the size of each character of a message in the
+// encoding it is sent in: septets for GSM-7, UTF-16 code units for UCS-2
+func characterUnits(message string) ([]int, bool) {
+	units := make([]int, 0, len(message))
+	for _, r := range message {
+		switch {
+		case gsmBasicSet[r]:
+			units = append(units, 1)
+		case gsmExtendedSet[r]:
+			units = append(units, 2)
+		default:
+			units = units[:0]
+			for _, r := range message {
+				units = append(units, len(utf16.Encode([]rune{r})))
+			}
+			return units, false
+		}
+	}
+	return units, true
+}
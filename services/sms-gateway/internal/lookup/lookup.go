@@ -0,0 +1,224 @@
+// Package lookup validates phone numbers before a send and, when asked,
+// looks up their carrier and line type with an SMS provider. Lookups are
+// billed by the provider, so they only run on request, results are cached
+// per number and each organization's lookups are rate limited.
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sms-gateway/internal/config"
+	"sms-gateway/internal/geo"
+	"sms-gateway/internal/providers"
+	"sms-gateway/internal/ratelimit"
+	"sms-gateway/internal/repository"
+)
+
+// ReasonUnknownNumber is reported for a well-formed number the carrier
+// lookup found no subscriber for
+const ReasonUnknownNumber = "unknown_number"
+
+// ErrLookupDisabled is returned when a lookup is requested but lookups
+// aren't enabled
+var ErrLookupDisabled = errors.New("carrier lookups are not enabled")
+
+// maxLocalEntries caps the in-memory cache used when Redis is unavailable
+const maxLocalEntries = 10000
+
+// RateLimitedError is returned when an organization has used up its lookups
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string { return "too many carrier lookups" }
+
+// Result is the outcome of validating a number
+type Result struct {
+	Number       string                  `json:"number"`
+	Valid        bool                    `json:"valid"`
+	Reason       string                  `json:"reason,omitempty"`
+	Message      string                  `json:"message,omitempty"`
+	CallingCode  string                  `json:"calling_code,omitempty"`
+	Country      string                  `json:"country,omitempty"`
+	Lookup       *providers.LookupResult `json:"lookup,omitempty"`
+	LookupCached bool                    `json:"lookup_cached,omitempty"`
+}
+
+// cachedLookup is a lookup result as cached. Numbers the provider doesn't
+// know are cached too, so they aren't billed again.
+type cachedLookup struct {
+	UnknownNumber bool                    `json:"unknown_number,omitempty"`
+	Result        *providers.LookupResult `json:"result,omitempty"`
+}
+
+type localEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// lookupCache stores lookups by number; the repository implements it
+type lookupCache interface {
+	GetNumberLookup(ctx context.Context, number string) ([]byte, error)
+	SaveNumberLookup(ctx context.Context, number string, data []byte, ttl time.Duration) error
+}
+
+// Service validates numbers and runs carrier lookups
+type Service struct {
+	config   config.LookupConfig
+	cache    lookupCache
+	lookuper providers.NumberLookup
+	limiter  *ratelimit.Limiter
+	local    map[string]localEntry
+	mu       sync.Mutex
+	logger   *zap.Logger
+}
+
+// New creates a lookup service. The provider manager looks numbers up with
+// the first provider that supports it.
+func New(cfg config.LookupConfig, repo *repository.Repository, pm *providers.Manager, rl *ratelimit.Limiter, logger *zap.Logger) *Service {
+	return &Service{
+		config:   cfg,
+		cache:    repo,
+		lookuper: pm,
+		limiter:  rl,
+		local:    make(map[string]localEntry),
+		logger:   logger,
+	}
+}
+
+// Validate normalizes a number to E.164 and checks it against its country's
+// numbering plan. An invalid number is reported in the result, never looked
+// up. With lookup set, a valid number's carrier and line type are added from
+// the cache or, counting against the organization's lookup rate limit, from
+// a provider.
+func (s *Service) Validate(ctx context.Context, organizationID, number string, lookup bool) (*Result, error) {
+	dest, err := geo.Validate(number)
+	if err != nil {
+		var numErr *geo.NumberError
+		if !errors.As(err, &numErr) {
+			return nil, err
+		}
+		invalidNumbers.WithLabelValues(numErr.Reason).Inc()
+		return &Result{Number: number, Reason: numErr.Reason, Message: numErr.Message}, nil
+	}
+
+	result := &Result{
+		Number:      dest.Number,
+		Valid:       true,
+		CallingCode: dest.CallingCode,
+		Country:     dest.Country,
+	}
+	if !lookup {
+		return result, nil
+	}
+	if !s.config.Enabled {
+		lookups.WithLabelValues(lookupUnavailable).Inc()
+		return nil, ErrLookupDisabled
+	}
+
+	if cached := s.cached(ctx, dest.Number); cached != nil {
+		lookups.WithLabelValues(lookupCacheHit).Inc()
+		result.LookupCached = true
+		result.apply(cached)
+		return result, nil
+	}
+
+	limit, err := s.limiter.CheckLookup(ctx, organizationID)
+	if err != nil {
+		s.logger.Error("Lookup rate limit check failed", zap.Error(err))
+	}
+	if limit != nil && !limit.Allowed {
+		lookups.WithLabelValues(lookupRateLimited).Inc()
+		return nil, &RateLimitedError{RetryAfter: limit.RetryAfter}
+	}
+
+	found, err := s.lookuper.LookupNumber(ctx, dest.Number)
+	var entry *cachedLookup
+	switch {
+	case err == nil:
+		lookups.WithLabelValues(lookupFound).Inc()
+		entry = &cachedLookup{Result: found}
+	case errors.Is(err, providers.ErrInvalidPhoneNumber):
+		lookups.WithLabelValues(lookupUnknownNumber).Inc()
+		entry = &cachedLookup{UnknownNumber: true}
+	case errors.Is(err, providers.ErrLookupNotSupported):
+		lookups.WithLabelValues(lookupUnavailable).Inc()
+		return nil, err
+	default:
+		lookups.WithLabelValues(lookupFailed).Inc()
+		return nil, fmt.Errorf("carrier lookup failed: %w", err)
+	}
+
+	s.save(ctx, dest.Number, entry)
+	result.apply(entry)
+	return result, nil
+}
+
+func (r *Result) apply(entry *cachedLookup) {
+	if entry.UnknownNumber {
+		r.Valid = false
+		r.Reason = ReasonUnknownNumber
+		r.Message = "the carrier lookup found no subscriber with this number"
+		return
+	}
+	r.Lookup = entry.Result
+}
+
+// cached returns the cached lookup of a number, falling back to the local
+// cache when Redis is unavailable
+func (s *Service) cached(ctx context.Context, number string) *cachedLookup {
+	data, err := s.cache.GetNumberLookup(ctx, number)
+	if err != nil {
+		s.mu.Lock()
+		local, ok := s.local[number]
+		s.mu.Unlock()
+		if !ok || time.Now().After(local.expiresAt) {
+			return nil
+		}
+		data = local.data
+	}
+	if data == nil {
+		return nil
+	}
+
+	var entry cachedLookup
+	if err := json.Unmarshal(data, &entry); err != nil {
+		s.logger.Warn("Discarding unreadable cached lookup", zap.Error(err))
+		return nil
+	}
+	return &entry
+}
+
+// save caches a lookup for the configured TTL
+func (s *Service) save(ctx context.Context, number string, entry *cachedLookup) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		s.logger.Error("Failed to encode lookup", zap.Error(err))
+		return
+	}
+	if err := s.cache.SaveNumberLookup(ctx, number, data, s.config.CacheTTL); err == nil {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.local) >= maxLocalEntries {
+		for n, e := range s.local {
+			if now.After(e.expiresAt) {
+				delete(s.local, n)
+			}
+		}
+		if len(s.local) >= maxLocalEntries {
+			return
+		}
+	}
+	s.local[number] = localEntry{data: data, expiresAt: now.Add(s.config.CacheTTL)}
+}
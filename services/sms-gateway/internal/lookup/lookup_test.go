@@ -0,0 +1,174 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sms-gateway/internal/config"
+	"sms-gateway/internal/providers"
+	"sms-gateway/internal/ratelimit"
+)
+
+// fakeLookup answers lookups, counting them
+type fakeLookup struct {
+	calls   int
+	err     error
+	unknown map[string]bool
+}
+
+func (f *fakeLookup) LookupNumber(ctx context.Context, number string) (*providers.LookupResult, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.unknown[number] {
+		return nil, providers.ErrInvalidPhoneNumber
+	}
+	return &providers.LookupResult{LineType: providers.LineTypeMobile, Carrier: "Example Wireless", Provider: "fake"}, nil
+}
+
+// unavailableCache behaves like the repository without Redis
+type unavailableCache struct{}
+
+func (unavailableCache) GetNumberLookup(ctx context.Context, number string) ([]byte, error) {
+	return nil, fmt.Errorf("redis not available")
+}
+
+func (unavailableCache) SaveNumberLookup(ctx context.Context, number string, data []byte, ttl time.Duration) error {
+	return fmt.Errorf("redis not available")
+}
+
+func newTestService(lookuper providers.NumberLookup, perMinute int) *Service {
+	return &Service{
+		config:   config.LookupConfig{Enabled: true, CacheTTL: time.Hour},
+		cache:    unavailableCache{},
+		lookuper: lookuper,
+		limiter: ratelimit.New(config.RateLimitConfig{
+			Enabled:         true,
+			LookupPerMinute: perMinute,
+			LookupPerDay:    100,
+		}, nil),
+		local:  make(map[string]localEntry),
+		logger: zap.NewNop(),
+	}
+}
+
+func TestValidate_InvalidNumberSkipsLookup(t *testing.T) {
+	fake := &fakeLookup{}
+	s := newTestService(fake, 10)
+
+	result, err := s.Validate(context.Background(), "org", "+1 415 055 0123", true)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if result.Valid || result.Reason == "" || result.Lookup != nil {
+		t.Errorf("Validate() = %+v, want invalid without lookup", result)
+	}
+	if fake.calls != 0 {
+		t.Errorf("invalid number was looked up %d times", fake.calls)
+	}
+}
+
+func TestValidate_LookupRequiresFlag(t *testing.T) {
+	fake := &fakeLookup{}
+	s := newTestService(fake, 10)
+
+	result, err := s.Validate(context.Background(), "org", "+1 (415) 555-0123", false)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if !result.Valid || result.Number != "+14155550123" || result.Country != "US" {
+		t.Errorf("Validate() = %+v", result)
+	}
+	if result.Lookup != nil || fake.calls != 0 {
+		t.Errorf("lookup ran without being requested")
+	}
+
+	s.config.Enabled = false
+	if _, err := s.Validate(context.Background(), "org", "+14155550123", true); !errors.Is(err, ErrLookupDisabled) {
+		t.Errorf("Validate() with lookups disabled error = %v, want ErrLookupDisabled", err)
+	}
+}
+
+func TestValidate_CachesLookups(t *testing.T) {
+	fake := &fakeLookup{unknown: map[string]bool{"+14155550199": true}}
+	s := newTestService(fake, 10)
+	ctx := context.Background()
+
+	for i, wantCached := range []bool{false, true} {
+		result, err := s.Validate(ctx, "org", "+14155550123", true)
+		if err != nil {
+			t.Fatalf("Validate() #%d error = %v", i, err)
+		}
+		if result.Lookup == nil || result.Lookup.LineType != providers.LineTypeMobile || result.LookupCached != wantCached {
+			t.Errorf("Validate() #%d = %+v, lookup %+v", i, result, result.Lookup)
+		}
+	}
+
+	// Numbers the provider doesn't know are cached too
+	for i := 0; i < 2; i++ {
+		result, err := s.Validate(ctx, "org", "+14155550199", true)
+		if err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if result.Valid || result.Reason != ReasonUnknownNumber {
+			t.Errorf("Validate() = %+v, want unknown number", result)
+		}
+	}
+
+	if fake.calls != 2 {
+		t.Errorf("provider called %d times, want 2", fake.calls)
+	}
+}
+
+func TestValidate_RateLimitsLookups(t *testing.T) {
+	fake := &fakeLookup{}
+	s := newTestService(fake, 2)
+	ctx := context.Background()
+
+	for _, number := range []string{"+14155550101", "+14155550102"} {
+		if _, err := s.Validate(ctx, "org", number, true); err != nil {
+			t.Fatalf("Validate(%s) error = %v", number, err)
+		}
+	}
+
+	var limited *RateLimitedError
+	if _, err := s.Validate(ctx, "org", "+14155550103", true); !errors.As(err, &limited) {
+		t.Fatalf("third lookup error = %v, want RateLimitedError", err)
+	}
+
+	// Cached numbers and other organizations aren't limited
+	if _, err := s.Validate(ctx, "org", "+14155550101", true); err != nil {
+		t.Errorf("cached lookup error = %v", err)
+	}
+	if _, err := s.Validate(ctx, "other-org", "+14155550103", true); err != nil {
+		t.Errorf("other organization's lookup error = %v", err)
+	}
+	if fake.calls != 3 {
+		t.Errorf("provider called %d times, want 3", fake.calls)
+	}
+}
+
+func TestValidate_LookupErrors(t *testing.T) {
+	s := newTestService(&fakeLookup{err: providers.ErrLookupNotSupported}, 10)
+	if _, err := s.Validate(context.Background(), "org", "+14155550123", true); !errors.Is(err, providers.ErrLookupNotSupported) {
+		t.Errorf("Validate() error = %v, want ErrLookupNotSupported", err)
+	}
+
+	fake := &fakeLookup{err: errors.New("timeout")}
+	s = newTestService(fake, 10)
+	if _, err := s.Validate(context.Background(), "org", "+14155550123", true); err == nil {
+		t.Fatal("Validate() succeeded after a failed lookup")
+	}
+	// Failures aren't cached
+	fake.err = nil
+	result, err := s.Validate(context.Background(), "org", "+14155550123", true)
+	if err != nil || result.LookupCached {
+		t.Errorf("Validate() after recovery = %+v, %v", result, err)
+	}
+}
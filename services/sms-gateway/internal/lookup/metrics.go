@@ -0,0 +1,30 @@
+package lookup
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcomes of a requested carrier lookup.
+const (
+	lookupCacheHit      = "cache_hit"
+	lookupFound         = "found"
+	lookupUnknownNumber = "unknown_number"
+	lookupRateLimited   = "rate_limited"
+	lookupUnavailable   = "unavailable"
+	lookupFailed        = "failed"
+)
+
+// invalidNumbers counts numbers the validate endpoint rejected without a
+// carrier lookup.
+var invalidNumbers = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sms_number_validation_rejected_total",
+	Help: "Number of phone numbers rejected by validation before any carrier lookup, by reason.",
+}, []string{"reason"})
+
+// lookups counts requested carrier lookups. Cache hits, rate limited and
+// unavailable lookups never reach a provider.
+var lookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sms_number_lookups_total",
+	Help: "Number of requested carrier lookups, by result.",
+}, []string{"result"})
@@ -0,0 +1,24 @@
+package otp
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons an OTP resend is blocked.
+const (
+	resendBlockedCooldown = "cooldown"
+	resendBlockedLimit    = "resend_limit"
+)
+
+// resendsBlocked counts OTP sends refused by the resend throttle.
+var resendsBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sms_otp_resends_blocked_total",
+	Help: "Number of OTP sends blocked by the resend throttle, by reason.",
+}, []string{"reason"})
+
+// otpsBurned counts OTPs invalidated after too many failed verifications.
+var otpsBurned = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "sms_otp_burned_total",
+	Help: "Number of OTPs invalidated after too many failed verification attempts.",
+})
@@ -24,12 +24,24 @@ import (
 var (
 	ErrOTPNotFound       = errors.New("OTP not found or expired")
 	ErrOTPExpired        = errors.New("OTP has expired")
-	ErrOTPMaxAttempts    = errors.New("maximum verification attempts exceeded")
+	ErrOTPBurned         = errors.New("OTP invalidated after too many failed attempts")
 	ErrOTPInvalid        = errors.New("invalid OTP code")
 	ErrOTPAlreadyUsed    = errors.New("OTP has already been used")
 	ErrResendCooldown    = errors.New("please wait before requesting a new OTP")
+	ErrResendLimit       = errors.New("too many OTPs requested for this phone number")
 )
 
+// ResendBlockedError is returned by Send when the resend throttle refuses a
+// new OTP. It wraps ErrResendCooldown or ErrResendLimit.
+type ResendBlockedError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ResendBlockedError) Error() string { return e.Err.Error() }
+
+func (e *ResendBlockedError) Unwrap() error { return e.Err }
+
 // Purpose represents the purpose of an OTP
 type Purpose string
 
@@ -110,14 +122,18 @@ func New(cfg config.OTPConfig, repo *repository.Repository, pm *providers.Manage
 
 // Send generates and sends an OTP
 func (s *Service) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
-	// Check for recent OTP requests (cooldown)
-	lastOTP, err := s.repo.GetLastOTP(ctx, req.PhoneNumber, string(req.Purpose))
-	if err == nil && lastOTP != nil {
-		cooldownEnd := lastOTP.CreatedAt.Add(s.config.ResendCooldown)
-		if time.Now().Before(cooldownEnd) {
-			return nil, ErrResendCooldown
-		}
+	// Enforce the resend cooldown and limit, giving the reservation back
+	// unless an SMS goes out
+	release, err := s.reserveResend(ctx, req)
+	if err != nil {
+		return nil, err
 	}
+	sent := false
+	defer func() {
+		if !sent {
+			release()
+		}
+	}()
 
 	// Generate OTP code
 	code, err := s.generateCode()
@@ -163,6 +179,7 @@ func (s *Service) Send(ctx context.Context, req *SendRequest) (*SendResponse, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to send OTP: %w", err)
 	}
+	sent = true
 
 	record.MessageID = smsResp.MessageID
 
@@ -212,20 +229,36 @@ func (s *Service) Verify(ctx context.Context, req *VerifyRequest) (*VerifyRespon
 		return &VerifyResponse{Valid: false, ErrorMessage: "OTP expired"}, ErrOTPExpired
 	}
 
-	// Check max attempts
+	// An OTP that ran out of attempts is burned, and so is cancelled too
 	if record.Attempts >= record.MaxAttempts {
-		return &VerifyResponse{Valid: false, ErrorMessage: "Maximum attempts exceeded"}, ErrOTPMaxAttempts
+		return &VerifyResponse{Valid: false, ErrorMessage: "OTP invalidated, request a new one"}, ErrOTPBurned
+	}
+	if record.Cancelled {
+		return &VerifyResponse{Valid: false, ErrorMessage: "OTP not found"}, ErrOTPNotFound
 	}
 
-	// Increment attempt counter
+	// Count the attempt before checking the code. The Redis counter is
+	// atomic, so concurrent guesses can't get past the limit.
+	attempts, err := s.repo.IncrementOTPVerifyAttempts(ctx, record.ID, time.Until(record.ExpiresAt))
+	if err != nil {
+		attempts = record.Attempts + 1
+	}
 	if err := s.repo.IncrementOTPAttempts(ctx, record.ID); err != nil {
 		s.logger.Error("Failed to increment OTP attempts", zap.Error(err))
 	}
+	if attempts > record.MaxAttempts {
+		s.burn(ctx, record)
+		return &VerifyResponse{Valid: false, ErrorMessage: "OTP invalidated, request a new one"}, ErrOTPBurned
+	}
 
 	// Verify code
 	valid := s.verifyCode(record.Code, req.Code)
 	if !valid {
-		attemptsLeft := record.MaxAttempts - record.Attempts - 1
+		attemptsLeft := record.MaxAttempts - attempts
+		if attemptsLeft == 0 {
+			s.burn(ctx, record)
+			return &VerifyResponse{Valid: false, ErrorMessage: "OTP invalidated, request a new one"}, ErrOTPBurned
+		}
 		s.logger.Info("OTP verification failed",
 			zap.String("request_id", record.ID),
 			zap.Int("attempts_left", attemptsLeft),
@@ -252,6 +285,90 @@ func (s *Service) Verify(ctx context.Context, req *VerifyRequest) (*VerifyRespon
 	}, nil
 }
 
+// reserveResend enforces the resend cooldown and the per-window resend limit
+// for a phone number. The returned func gives the reservation back when no
+// OTP ends up being sent. Without Redis the limits are checked against the
+// stored OTPs instead.
+func (s *Service) reserveResend(ctx context.Context, req *SendRequest) (func(), error) {
+	claimed, wait, err := s.repo.ClaimOTPCooldown(ctx, req.PhoneNumber, s.config.ResendCooldown)
+	if err != nil {
+		return func() {}, s.checkResendFromDB(ctx, req)
+	}
+	if !claimed {
+		return nil, s.blockResend(req, resendBlockedCooldown, wait)
+	}
+
+	release := func() {
+		ctx := context.WithoutCancel(ctx)
+		if err := s.repo.ReleaseOTPCooldown(ctx, req.PhoneNumber); err != nil {
+			s.logger.Warn("Failed to release OTP cooldown", zap.Error(err))
+		}
+		if err := s.repo.DecrementOTPResends(ctx, req.PhoneNumber); err != nil {
+			s.logger.Warn("Failed to release OTP resend count", zap.Error(err))
+		}
+	}
+
+	count, resetIn, err := s.repo.IncrementOTPResends(ctx, req.PhoneNumber, s.config.ResendWindow)
+	if err != nil {
+		s.repo.ReleaseOTPCooldown(context.WithoutCancel(ctx), req.PhoneNumber)
+		return nil, fmt.Errorf("failed to check OTP resend limit: %w", err)
+	}
+	if count > s.config.MaxResends {
+		release()
+		return nil, s.blockResend(req, resendBlockedLimit, resetIn)
+	}
+
+	return release, nil
+}
+
+// checkResendFromDB enforces the resend cooldown and limit from the stored
+// OTPs, for when Redis isn't available
+func (s *Service) checkResendFromDB(ctx context.Context, req *SendRequest) error {
+	lastOTP, err := s.repo.GetLastOTP(ctx, req.PhoneNumber, string(req.Purpose))
+	if err == nil && lastOTP != nil {
+		if wait := time.Until(lastOTP.CreatedAt.Add(s.config.ResendCooldown)); wait > 0 {
+			return s.blockResend(req, resendBlockedCooldown, wait)
+		}
+	}
+
+	count, oldest, err := s.repo.CountOTPsSince(ctx, req.PhoneNumber, time.Now().Add(-s.config.ResendWindow))
+	if err != nil {
+		return fmt.Errorf("failed to check OTP resend limit: %w", err)
+	}
+	if count >= s.config.MaxResends {
+		return s.blockResend(req, resendBlockedLimit, time.Until(oldest.Add(s.config.ResendWindow)))
+	}
+	return nil
+}
+
+// blockResend records a resend refused by the throttle and returns its error
+func (s *Service) blockResend(req *SendRequest, reason string, retryAfter time.Duration) error {
+	resendsBlocked.WithLabelValues(reason).Inc()
+	s.logger.Info("OTP resend blocked",
+		zap.String("reason", reason),
+		zap.String("purpose", string(req.Purpose)),
+		zap.Duration("retry_after", retryAfter),
+	)
+
+	err := ErrResendCooldown
+	if reason == resendBlockedLimit {
+		err = ErrResendLimit
+	}
+	return &ResendBlockedError{Err: err, RetryAfter: retryAfter}
+}
+
+// burn invalidates an OTP that ran out of verification attempts, so a new
+// one has to be requested
+func (s *Service) burn(ctx context.Context, record *OTPRecord) {
+	if err := s.repo.CancelOTP(ctx, record.ID); err != nil {
+		s.logger.Error("Failed to invalidate OTP", zap.Error(err))
+	}
+	otpsBurned.Inc()
+	s.logger.Warn("OTP invalidated after too many failed attempts",
+		zap.String("request_id", record.ID),
+	)
+}
+
 // generateCode generates a secure random OTP code
 func (s *Service) generateCode() (string, error) {
 	length := s.config.Length
@@ -3,7 +3,11 @@ package providers
 import (
 	"context"
 	"errors"
+	"math"
+	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,6 +23,8 @@ var (
 	ErrDeliveryFailed       = errors.New("message delivery failed")
 	ErrRateLimited          = errors.New("rate limit exceeded")
 	ErrInsufficientBalance  = errors.New("insufficient account balance")
+	ErrInvalidSignature     = errors.New("invalid webhook signature")
+	ErrLookupNotSupported   = errors.New("no SMS provider supports number lookups")
 )
 
 // MessageType represents the type of SMS message
@@ -34,16 +40,56 @@ const (
 type DeliveryStatus string
 
 const (
-	DeliveryStatusPending   DeliveryStatus = "pending"
-	DeliveryStatusQueued    DeliveryStatus = "queued"
-	DeliveryStatusSent      DeliveryStatus = "sent"
-	DeliveryStatusDelivered DeliveryStatus = "delivered"
-	DeliveryStatusFailed    DeliveryStatus = "failed"
-	DeliveryStatusExpired   DeliveryStatus = "expired"
-	DeliveryStatusRejected  DeliveryStatus = "rejected"
-	DeliveryStatusUnknown   DeliveryStatus = "unknown"
+	DeliveryStatusScheduled   DeliveryStatus = "scheduled"
+	DeliveryStatusCancelled   DeliveryStatus = "cancelled"
+	DeliveryStatusPending     DeliveryStatus = "pending"
+	DeliveryStatusQueued      DeliveryStatus = "queued"
+	DeliveryStatusSent        DeliveryStatus = "sent"
+	DeliveryStatusDelivered   DeliveryStatus = "delivered"
+	DeliveryStatusFailed      DeliveryStatus = "failed"
+	DeliveryStatusUndelivered DeliveryStatus = "undelivered"
+	DeliveryStatusExpired     DeliveryStatus = "expired"
+	DeliveryStatusRejected    DeliveryStatus = "rejected"
+	DeliveryStatusUnknown     DeliveryStatus = "unknown"
 )
 
+// deliveryStatusStage orders statuses along the delivery lifecycle. Statuses
+// missing from the map (such as unknown) never change a message.
+var deliveryStatusStage = map[DeliveryStatus]int{
+	DeliveryStatusScheduled:   0,
+	DeliveryStatusCancelled:   3,
+	DeliveryStatusPending:     0,
+	DeliveryStatusQueued:      1,
+	DeliveryStatusSent:        2,
+	DeliveryStatusDelivered:   3,
+	DeliveryStatusFailed:      3,
+	DeliveryStatusUndelivered: 3,
+	DeliveryStatusExpired:     3,
+	DeliveryStatusRejected:    3,
+}
+
+// IsFinal reports whether no further delivery reports are expected
+func (s DeliveryStatus) IsFinal() bool {
+	return deliveryStatusStage[s] == 3
+}
+
+// Supersedes returns the statuses a message may move from to reach s.
+// Final statuses are never replaced, so duplicate or late reports are no-ops.
+func (s DeliveryStatus) Supersedes() []DeliveryStatus {
+	stage, ok := deliveryStatusStage[s]
+	if !ok {
+		return nil
+	}
+
+	var earlier []DeliveryStatus
+	for status, st := range deliveryStatusStage {
+		if st < stage {
+			earlier = append(earlier, status)
+		}
+	}
+	return earlier
+}
+
 // SendRequest represents an SMS send request
 type SendRequest struct {
 	To          string            `json:"to"`
@@ -80,6 +126,22 @@ type DeliveryReport struct {
 	DeliveredAt   *time.Time     `json:"delivered_at,omitempty"`
 	ErrorCode     string         `json:"error_code,omitempty"`
 	ErrorMessage  string         `json:"error_message,omitempty"`
+	// SegmentCount, Cost and Currency are the charge for the message when
+	// the receipt reports it, zero otherwise
+	SegmentCount int     `json:"segment_count,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+}
+
+// ParsePrice parses a price reported by a provider. Some providers report
+// charges as negative amounts; the magnitude is returned. ok is false when
+// no price is reported.
+func ParsePrice(price string) (cost float64, ok bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+	if err != nil {
+		return 0, false
+	}
+	return math.Abs(v), true
 }
 
 // BalanceInfo represents account balance information
@@ -122,6 +184,41 @@ type Provider interface {
 	SupportsScheduling() bool
 }
 
+// WebhookVerifier is implemented by providers that sign their delivery callbacks
+type WebhookVerifier interface {
+	// VerifyWebhook checks the signature of a callback received at requestURL
+	VerifyWebhook(requestURL string, header http.Header, payload []byte) error
+}
+
+// LineType is the kind of line a phone number is assigned to
+type LineType string
+
+const (
+	LineTypeMobile   LineType = "mobile"
+	LineTypeLandline LineType = "landline"
+	LineTypeVoIP     LineType = "voip"
+	LineTypeTollFree LineType = "toll_free"
+	LineTypeUnknown  LineType = "unknown"
+)
+
+// LookupResult is what a carrier lookup reports about a phone number
+type LookupResult struct {
+	LineType          LineType  `json:"line_type"`
+	Carrier           string    `json:"carrier,omitempty"`
+	MobileCountryCode string    `json:"mobile_country_code,omitempty"`
+	MobileNetworkCode string    `json:"mobile_network_code,omitempty"`
+	Provider          string    `json:"provider"`
+	LookedUpAt        time.Time `json:"looked_up_at"`
+}
+
+// NumberLookup is implemented by providers that can look up the carrier and
+// line type of a phone number. Providers bill for each lookup.
+type NumberLookup interface {
+	// LookupNumber looks up an E.164 number. It returns ErrInvalidPhoneNumber
+	// if the provider reports that the number doesn't exist.
+	LookupNumber(ctx context.Context, number string) (*LookupResult, error)
+}
+
 // ProviderEntry holds a provider with its priority
 type ProviderEntry struct {
 	Provider Provider
@@ -247,6 +344,36 @@ func (m *Manager) SendWithProvider(ctx context.Context, providerName string, req
 	return provider.Send(ctx, req)
 }
 
+// LookupNumber looks up a number with the highest priority healthy provider
+// that supports lookups, trying the next one if a lookup fails
+func (m *Manager) LookupNumber(ctx context.Context, number string) (*LookupResult, error) {
+	m.mu.RLock()
+	providers := make([]*ProviderEntry, len(m.sorted))
+	copy(providers, m.sorted)
+	m.mu.RUnlock()
+
+	lastErr := ErrLookupNotSupported
+	for _, entry := range providers {
+		lookup, ok := entry.Provider.(NumberLookup)
+		if !ok || !entry.Healthy {
+			continue
+		}
+
+		result, err := lookup.LookupNumber(ctx, number)
+		if err == nil || errors.Is(err, ErrInvalidPhoneNumber) {
+			return result, err
+		}
+
+		lastErr = err
+		m.logger.Warn("Provider number lookup failed, trying next",
+			zap.String("provider", entry.Provider.Name()),
+			zap.Error(err),
+		)
+	}
+
+	return nil, lastErr
+}
+
 // ListProviders returns all registered providers
 func (m *Manager) ListProviders() []string {
 	m.mu.RLock()
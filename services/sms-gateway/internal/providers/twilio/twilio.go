@@ -2,6 +2,9 @@ package twilio
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +12,8 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +24,7 @@ import (
 
 const (
 	twilioAPIURL       = "https://api.twilio.com/2010-04-01"
+	twilioLookupURL    = "https://lookups.twilio.com/v2/PhoneNumbers"
 	maxMessageLength   = 1600
 	maxSegmentLength   = 160
 	maxUnicodeSegment  = 70
@@ -58,6 +64,18 @@ type TwilioBalance struct {
 	Currency    string `json:"currency"`
 }
 
+// TwilioLookup represents a Lookup v2 response with line type intelligence
+type TwilioLookup struct {
+	PhoneNumber          string `json:"phone_number"`
+	Valid                bool   `json:"valid"`
+	LineTypeIntelligence *struct {
+		CarrierName       string `json:"carrier_name"`
+		Type              string `json:"type"`
+		MobileCountryCode string `json:"mobile_country_code"`
+		MobileNetworkCode string `json:"mobile_network_code"`
+	} `json:"line_type_intelligence"`
+}
+
 // TwilioError represents an API error
 type TwilioError struct {
 	Code     int    `json:"code"`
@@ -167,6 +185,10 @@ func (p *Provider) Send(ctx context.Context, req *providers.SendRequest) (*provi
 		fmt.Sscanf(msg.NumSegments, "%d", &segments)
 	}
 
+	// Twilio usually prices a message only once it is sent; the price then
+	// arrives with the status callback
+	cost, _ := providers.ParsePrice(msg.Price)
+
 	return &providers.SendResponse{
 		MessageID:     msg.SID,
 		ProviderID:    msg.SID,
@@ -174,6 +196,8 @@ func (p *Provider) Send(ctx context.Context, req *providers.SendRequest) (*provi
 		Status:        mapTwilioStatus(msg.Status),
 		StatusMessage: msg.Status,
 		SegmentCount:  segments,
+		Cost:          cost,
+		Currency:      strings.ToUpper(msg.PriceUnit),
 		SentAt:        time.Now(),
 	}, nil
 }
@@ -289,6 +313,58 @@ func (p *Provider) GetBalance(ctx context.Context) (*providers.BalanceInfo, erro
 	}, nil
 }
 
+// LookupNumber looks up a number's carrier and line type with Twilio Lookup.
+// Each lookup is billed.
+func (p *Provider) LookupNumber(ctx context.Context, number string) (*providers.LookupResult, error) {
+	apiURL := fmt.Sprintf("%s/%s?Fields=line_type_intelligence", twilioLookupURL, url.PathEscape(number))
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.SetBasicAuth(p.accountSID, p.authToken)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, providers.ErrInvalidPhoneNumber
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("twilio lookup failed with status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var lookup TwilioLookup
+	if err := json.Unmarshal(body, &lookup); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !lookup.Valid {
+		return nil, providers.ErrInvalidPhoneNumber
+	}
+
+	result := &providers.LookupResult{
+		LineType:   providers.LineTypeUnknown,
+		Provider:   p.Name(),
+		LookedUpAt: time.Now(),
+	}
+	if info := lookup.LineTypeIntelligence; info != nil {
+		result.LineType = mapTwilioLineType(info.Type)
+		result.Carrier = info.CarrierName
+		result.MobileCountryCode = info.MobileCountryCode
+		result.MobileNetworkCode = info.MobileNetworkCode
+	}
+	return result, nil
+}
+
 // ValidatePhoneNumber validates and formats a phone number
 func (p *Provider) ValidatePhoneNumber(phoneNumber string) (string, error) {
 	// Remove all non-digit characters except leading +
@@ -329,10 +405,63 @@ func (p *Provider) ParseWebhook(payload []byte) (*providers.DeliveryReport, erro
 		ErrorCode:     values.Get("ErrorCode"),
 		ErrorMessage:  values.Get("ErrorMessage"),
 	}
+	if segments, err := strconv.Atoi(values.Get("NumSegments")); err == nil {
+		report.SegmentCount = segments
+	}
+	if cost, ok := providers.ParsePrice(values.Get("Price")); ok {
+		report.Cost = cost
+		report.Currency = strings.ToUpper(values.Get("PriceUnit"))
+	}
 
 	return report, nil
 }
 
+// VerifyWebhook validates the X-Twilio-Signature header of a status callback
+func (p *Provider) VerifyWebhook(requestURL string, header http.Header, payload []byte) error {
+	signature := header.Get("X-Twilio-Signature")
+	if signature == "" {
+		return providers.ErrInvalidSignature
+	}
+
+	params, err := url.ParseQuery(string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to parse webhook: %w", err)
+	}
+
+	expected := p.signature(requestURL, params)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return providers.ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// signature computes the request signature Twilio sends with webhooks: the
+// URL followed by each POST parameter name and value, sorted by name, signed
+// with HMAC-SHA1 using the auth token
+func (p *Provider) signature(requestURL string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(requestURL)
+	for _, key := range keys {
+		values := append([]string(nil), params[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			b.WriteString(key)
+			b.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(p.authToken))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
 // IsHealthy checks if the provider is operational
 func (p *Provider) IsHealthy(ctx context.Context) bool {
 	// Simple health check - try to fetch account info
@@ -373,11 +502,27 @@ func mapTwilioStatus(status string) providers.DeliveryStatus {
 		return providers.DeliveryStatusSent
 	case "delivered":
 		return providers.DeliveryStatusDelivered
-	case "undelivered", "failed":
+	case "undelivered":
+		return providers.DeliveryStatusUndelivered
+	case "failed", "canceled":
 		return providers.DeliveryStatusFailed
-	case "canceled":
-		return providers.DeliveryStatusExpired
 	default:
 		return providers.DeliveryStatusUnknown
 	}
 }
+
+// mapTwilioLineType maps a Lookup line type to our standard line type
+func mapTwilioLineType(lineType string) providers.LineType {
+	switch lineType {
+	case "mobile":
+		return providers.LineTypeMobile
+	case "landline":
+		return providers.LineTypeLandline
+	case "fixedVoip", "nonFixedVoip":
+		return providers.LineTypeVoIP
+	case "tollFree":
+		return providers.LineTypeTollFree
+	default:
+		return providers.LineTypeUnknown
+	}
+}
@@ -0,0 +1,115 @@
+package twilio
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"sms-gateway/internal/providers"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	provider := New("AC123", "12345", "+15550000000", "", zap.NewNop())
+
+	// Example request from the Twilio webhook security documentation
+	requestURL := "https://mycompany.com/myapp.php?foo=1&bar=2"
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	payload := []byte(params.Encode())
+
+	header := http.Header{}
+	header.Set("X-Twilio-Signature", "0/KCTR6DLpKmkAf8muzZqo1nDgQ=")
+
+	t.Run("valid signature", func(t *testing.T) {
+		if err := provider.VerifyWebhook(requestURL, header, payload); err != nil {
+			t.Errorf("expected valid signature, got %v", err)
+		}
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		params.Set("Digits", "4321")
+		if err := provider.VerifyWebhook(requestURL, header, []byte(params.Encode())); err != providers.ErrInvalidSignature {
+			t.Errorf("expected ErrInvalidSignature, got %v", err)
+		}
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		if err := provider.VerifyWebhook(requestURL, http.Header{}, payload); err != providers.ErrInvalidSignature {
+			t.Errorf("expected ErrInvalidSignature, got %v", err)
+		}
+	})
+}
+
+func TestMapTwilioStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected providers.DeliveryStatus
+	}{
+		{"queued", providers.DeliveryStatusQueued},
+		{"sent", providers.DeliveryStatusSent},
+		{"delivered", providers.DeliveryStatusDelivered},
+		{"undelivered", providers.DeliveryStatusUndelivered},
+		{"failed", providers.DeliveryStatusFailed},
+		{"bogus", providers.DeliveryStatusUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := mapTwilioStatus(tt.status); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseWebhookPrice(t *testing.T) {
+	provider := New("AC123", "12345", "+15550000000", "", zap.NewNop())
+
+	priced := url.Values{
+		"MessageSid":    {"SM1"},
+		"MessageStatus": {"delivered"},
+		"NumSegments":   {"2"},
+		"Price":         {"-0.01580"},
+		"PriceUnit":     {"usd"},
+	}
+	report, err := provider.ParseWebhook([]byte(priced.Encode()))
+	if err != nil {
+		t.Fatalf("ParseWebhook failed: %v", err)
+	}
+	if report.SegmentCount != 2 || report.Cost != 0.0158 || report.Currency != "USD" {
+		t.Errorf("got %d segments, cost %v %s", report.SegmentCount, report.Cost, report.Currency)
+	}
+
+	priced.Del("Price")
+	report, err = provider.ParseWebhook([]byte(priced.Encode()))
+	if err != nil {
+		t.Fatalf("ParseWebhook failed: %v", err)
+	}
+	if report.Cost != 0 || report.Currency != "" {
+		t.Errorf("unpriced receipt has cost %v %s", report.Cost, report.Currency)
+	}
+}
+
+func TestMapTwilioLineType(t *testing.T) {
+	tests := map[string]providers.LineType{
+		"mobile":       providers.LineTypeMobile,
+		"landline":     providers.LineTypeLandline,
+		"fixedVoip":    providers.LineTypeVoIP,
+		"nonFixedVoip": providers.LineTypeVoIP,
+		"tollFree":     providers.LineTypeTollFree,
+		"pager":        providers.LineTypeUnknown,
+		"":             providers.LineTypeUnknown,
+	}
+	for lineType, want := range tests {
+		if got := mapTwilioLineType(lineType); got != want {
+			t.Errorf("mapTwilioLineType(%q) = %s, want %s", lineType, got, want)
+		}
+	}
+}
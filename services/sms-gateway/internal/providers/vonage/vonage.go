@@ -3,11 +3,17 @@ package vonage
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,18 +25,26 @@ import (
 const (
 	vonageAPIURL     = "https://rest.nexmo.com/sms/json"
 	vonageMessagesAPI = "https://api.nexmo.com/v1/messages"
+	vonageInsightURL = "https://api.nexmo.com/ni/standard/json"
 	maxMessageLength = 1600
+
+	// vonageCurrency is the currency Vonage reports prices in
+	vonageCurrency = "EUR"
+
+	// signatureMaxAge bounds how old a signed webhook timestamp may be
+	signatureMaxAge = 5 * time.Minute
 )
 
 // Provider implements the SMS provider interface for Vonage
 type Provider struct {
-	apiKey        string
-	apiSecret     string
-	fromNumber    string
-	applicationID string
-	privateKey    string
-	client        *http.Client
-	logger        *zap.Logger
+	apiKey          string
+	apiSecret       string
+	fromNumber      string
+	applicationID   string
+	privateKey      string
+	signatureSecret string
+	client          *http.Client
+	logger          *zap.Logger
 }
 
 // VonageSMSRequest represents the SMS API request
@@ -75,14 +89,27 @@ type VonageWebhook struct {
 	MessageTimestamp string `json:"message-timestamp"`
 }
 
+// VonageNumberInsight represents a Number Insight Standard response
+type VonageNumberInsight struct {
+	Status         int    `json:"status"`
+	StatusMessage  string `json:"status_message"`
+	CurrentCarrier *struct {
+		NetworkCode string `json:"network_code"`
+		Name        string `json:"name"`
+		Country     string `json:"country"`
+		NetworkType string `json:"network_type"`
+	} `json:"current_carrier"`
+}
+
 // New creates a new Vonage provider
-func New(apiKey, apiSecret, fromNumber, applicationID, privateKey string, logger *zap.Logger) *Provider {
+func New(apiKey, apiSecret, fromNumber, applicationID, privateKey, signatureSecret string, logger *zap.Logger) *Provider {
 	return &Provider{
-		apiKey:        apiKey,
-		apiSecret:     apiSecret,
-		fromNumber:    fromNumber,
-		applicationID: applicationID,
-		privateKey:    privateKey,
+		apiKey:          apiKey,
+		apiSecret:       apiSecret,
+		fromNumber:      fromNumber,
+		applicationID:   applicationID,
+		privateKey:      privateKey,
+		signatureSecret: signatureSecret,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -170,13 +197,27 @@ func (p *Provider) Send(ctx context.Context, req *providers.SendRequest) (*provi
 		return nil, fmt.Errorf("vonage error: %s", msg.ErrorText)
 	}
 
+	// A long message is sent as one message per part, each priced separately
+	segments, err := strconv.Atoi(smsResp.MessageCount)
+	if err != nil || segments < 1 {
+		segments = len(smsResp.Messages)
+	}
+	var cost float64
+	for _, part := range smsResp.Messages {
+		if price, ok := providers.ParsePrice(part.MessagePrice); ok {
+			cost += price
+		}
+	}
+
 	return &providers.SendResponse{
 		MessageID:     msg.MessageID,
 		ProviderID:    msg.MessageID,
 		Provider:      p.Name(),
 		Status:        providers.DeliveryStatusQueued,
 		StatusMessage: "Message sent",
-		SegmentCount:  1,
+		SegmentCount:  segments,
+		Cost:          cost,
+		Currency:      vonageCurrency,
 		SentAt:        time.Now(),
 	}, nil
 }
@@ -254,6 +295,66 @@ func (p *Provider) GetBalance(ctx context.Context) (*providers.BalanceInfo, erro
 	}, nil
 }
 
+// LookupNumber looks up a number's carrier and line type with Vonage Number
+// Insight Standard. Each lookup is billed.
+func (p *Provider) LookupNumber(ctx context.Context, number string) (*providers.LookupResult, error) {
+	query := url.Values{
+		"api_key":    {p.apiKey},
+		"api_secret": {p.apiSecret},
+		"number":     {strings.TrimPrefix(number, "+")},
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", vonageInsightURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("vonage lookup failed with status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var insight VonageNumberInsight
+	if err := json.Unmarshal(body, &insight); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	switch insight.Status {
+	case 0:
+	case 3:
+		// Invalid request, which includes numbers that don't exist
+		return nil, providers.ErrInvalidPhoneNumber
+	default:
+		return nil, fmt.Errorf("vonage lookup failed: %d %s", insight.Status, insight.StatusMessage)
+	}
+
+	result := &providers.LookupResult{
+		LineType:   providers.LineTypeUnknown,
+		Provider:   p.Name(),
+		LookedUpAt: time.Now(),
+	}
+	if carrier := insight.CurrentCarrier; carrier != nil {
+		result.LineType = mapVonageNetworkType(carrier.NetworkType)
+		result.Carrier = carrier.Name
+		// The network code is the MCC followed by the MNC
+		if len(carrier.NetworkCode) > 3 {
+			result.MobileCountryCode = carrier.NetworkCode[:3]
+			result.MobileNetworkCode = carrier.NetworkCode[3:]
+		}
+	}
+	return result, nil
+}
+
 // ValidatePhoneNumber validates and formats a phone number
 func (p *Provider) ValidatePhoneNumber(phoneNumber string) (string, error) {
 	// Remove all non-digit characters except leading +
@@ -292,10 +393,71 @@ func (p *Provider) ParseWebhook(payload []byte) (*providers.DeliveryReport, erro
 		StatusMessage: webhook.Status,
 		ErrorCode:     webhook.ErrCode,
 	}
+	if cost, ok := providers.ParsePrice(webhook.Price); ok {
+		report.Cost = cost
+		report.Currency = vonageCurrency
+	}
 
 	return report, nil
 }
 
+// VerifyWebhook validates the sig parameter of a signed delivery receipt.
+// Receipts must be signed with HMAC-SHA256 using the account signature secret.
+func (p *Provider) VerifyWebhook(requestURL string, header http.Header, payload []byte) error {
+	if p.signatureSecret == "" {
+		return fmt.Errorf("vonage signature secret not configured")
+	}
+
+	var params map[string]string
+	if err := json.Unmarshal(payload, &params); err != nil {
+		return fmt.Errorf("failed to parse webhook: %w", err)
+	}
+
+	signature := params["sig"]
+	if signature == "" {
+		return providers.ErrInvalidSignature
+	}
+	delete(params, "sig")
+
+	timestamp, err := strconv.ParseInt(params["timestamp"], 10, 64)
+	if err != nil {
+		return providers.ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		return providers.ErrInvalidSignature
+	}
+
+	expected := p.signature(params)
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return providers.ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// signature computes the signature of webhook parameters: each "&key=value"
+// pair sorted by key, with "&" and "=" in keys and values replaced by "_"
+func (p *Provider) signature(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sanitize := strings.NewReplacer("&", "_", "=", "_")
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString("&")
+		b.WriteString(sanitize.Replace(key))
+		b.WriteString("=")
+		b.WriteString(sanitize.Replace(params[key]))
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.signatureSecret))
+	mac.Write([]byte(b.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // IsHealthy checks if the provider is operational
 func (p *Provider) IsHealthy(ctx context.Context) bool {
 	_, err := p.GetBalance(ctx)
@@ -320,7 +482,7 @@ func mapVonageStatus(status string) providers.DeliveryStatus {
 	case "delivered":
 		return providers.DeliveryStatusDelivered
 	case "expired":
-		return providers.DeliveryStatusExpired
+		return providers.DeliveryStatusUndelivered
 	case "failed", "rejected":
 		return providers.DeliveryStatusFailed
 	case "accepted":
@@ -329,3 +491,20 @@ func mapVonageStatus(status string) providers.DeliveryStatus {
 		return providers.DeliveryStatusUnknown
 	}
 }
+
+// mapVonageNetworkType maps a Number Insight network type to our standard
+// line type
+func mapVonageNetworkType(networkType string) providers.LineType {
+	switch networkType {
+	case "mobile":
+		return providers.LineTypeMobile
+	case "landline", "landline_premium":
+		return providers.LineTypeLandline
+	case "landline_tollfree":
+		return providers.LineTypeTollFree
+	case "virtual":
+		return providers.LineTypeVoIP
+	default:
+		return providers.LineTypeUnknown
+	}
+}
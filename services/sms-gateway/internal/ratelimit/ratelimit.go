@@ -91,6 +91,23 @@ func (l *Limiter) CheckOTP(ctx context.Context, userID, phoneNumber string) (*Re
 	return &Result{Allowed: true, Remaining: -1}, nil
 }
 
+// CheckLookup checks rate limits for an organization's carrier lookups,
+// which are billed by the provider
+func (l *Limiter) CheckLookup(ctx context.Context, organizationID string) (*Result, error) {
+	if !l.config.Enabled {
+		return &Result{Allowed: true, Remaining: -1}, nil
+	}
+
+	key := fmt.Sprintf("lookup:org:%s:minute", organizationID)
+	result, err := l.checkLimit(ctx, key, l.config.LookupPerMinute, time.Minute)
+	if err != nil || !result.Allowed {
+		return result, err
+	}
+
+	key = fmt.Sprintf("lookup:org:%s:day", organizationID)
+	return l.checkLimit(ctx, key, l.config.LookupPerDay, 24*time.Hour)
+}
+
 // CheckAPI checks rate limits for API requests
 func (l *Limiter) CheckAPI(ctx context.Context, apiKey string) (*Result, error) {
 	if !l.config.Enabled {
@@ -2,17 +2,25 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 
 	"sms-gateway/internal/config"
 )
 
+// Message errors
+var (
+	ErrMessageNotFound       = errors.New("message not found")
+	ErrMessageNotCancellable = errors.New("message is no longer scheduled")
+)
+
 // Repository handles database and cache operations
 type Repository struct {
 	db    *sqlx.DB
@@ -89,23 +97,45 @@ type SMSMessage struct {
 	CreatedAt      time.Time  `db:"created_at"`
 	UpdatedAt      time.Time  `db:"updated_at"`
 	Metadata       string     `db:"metadata"`
-}
+	// EstimatedSegments is the segment count computed locally, to validate
+	// the provider's SegmentCount
+	EstimatedSegments int        `db:"estimated_segments"`
+	CallingCode       string     `db:"calling_code"`
+	PriceReportedAt   *time.Time `db:"price_reported_at"`
+}
+
+// smsMessageColumns selects an sms_messages row with NULLs mapped to the zero
+// values of SMSMessage
+const smsMessageColumns = `
+	id, COALESCE(organization_id::text, '') AS organization_id, COALESCE(user_id::text, '') AS user_id,
+	provider, COALESCE(provider_id, '') AS provider_id, COALESCE(from_number, '') AS from_number,
+	to_number, message, message_type, status,
+	COALESCE(segment_count, 1) AS segment_count, COALESCE(cost, 0) AS cost, COALESCE(currency, 'USD') AS currency,
+	COALESCE(error_code, '') AS error_code, COALESCE(error_message, '') AS error_message,
+	COALESCE(estimated_segments, 0) AS estimated_segments, COALESCE(calling_code, '') AS calling_code,
+	price_reported_at, scheduled_at, sent_at, delivered_at, created_at, updated_at,
+	COALESCE(metadata::text, '{}') AS metadata`
 
 // CreateMessage creates a new SMS message record
 func (r *Repository) CreateMessage(ctx context.Context, msg *SMSMessage) (string, error) {
 	msg.ID = uuid.New().String()
 	msg.CreatedAt = time.Now()
 	msg.UpdatedAt = time.Now()
+	if msg.Metadata == "" {
+		msg.Metadata = "{}"
+	}
 
 	query := `
 		INSERT INTO sms_messages (
 			id, organization_id, user_id, provider, provider_id,
 			from_number, to_number, message, message_type, status,
 			segment_count, cost, currency, error_code, error_message,
-			scheduled_at, sent_at, delivered_at, created_at, updated_at, metadata
+			scheduled_at, sent_at, delivered_at, created_at, updated_at, metadata,
+			estimated_segments, calling_code, price_reported_at
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
-			$11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21
+			$1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8, $9, $10,
+			$11, $12, NULLIF($13, ''), $14, $15, $16, $17, $18, $19, $20, $21,
+			NULLIF($22, 0), NULLIF($23, ''), $24
 		)`
 
 	_, err := r.db.ExecContext(ctx, query,
@@ -113,6 +143,7 @@ func (r *Repository) CreateMessage(ctx context.Context, msg *SMSMessage) (string
 		msg.FromNumber, msg.ToNumber, msg.Message, msg.MessageType, msg.Status,
 		msg.SegmentCount, msg.Cost, msg.Currency, msg.ErrorCode, msg.ErrorMessage,
 		msg.ScheduledAt, msg.SentAt, msg.DeliveredAt, msg.CreatedAt, msg.UpdatedAt, msg.Metadata,
+		msg.EstimatedSegments, msg.CallingCode, msg.PriceReportedAt,
 	)
 
 	return msg.ID, err
@@ -121,7 +152,7 @@ func (r *Repository) CreateMessage(ctx context.Context, msg *SMSMessage) (string
 // GetMessage retrieves a message by ID
 func (r *Repository) GetMessage(ctx context.Context, id string) (*SMSMessage, error) {
 	var msg SMSMessage
-	query := `SELECT * FROM sms_messages WHERE id = $1`
+	query := `SELECT ` + smsMessageColumns + ` FROM sms_messages WHERE id = $1`
 	err := r.db.GetContext(ctx, &msg, query, id)
 	if err != nil {
 		return nil, err
@@ -132,7 +163,7 @@ func (r *Repository) GetMessage(ctx context.Context, id string) (*SMSMessage, er
 // GetMessageByProviderID retrieves a message by provider ID
 func (r *Repository) GetMessageByProviderID(ctx context.Context, provider, providerID string) (*SMSMessage, error) {
 	var msg SMSMessage
-	query := `SELECT * FROM sms_messages WHERE provider = $1 AND provider_id = $2`
+	query := `SELECT ` + smsMessageColumns + ` FROM sms_messages WHERE provider = $1 AND provider_id = $2`
 	err := r.db.GetContext(ctx, &msg, query, provider, providerID)
 	if err != nil {
 		return nil, err
@@ -150,6 +181,59 @@ func (r *Repository) UpdateMessageStatus(ctx context.Context, id, status, errorC
 	return err
 }
 
+// AdvanceMessageStatus applies a delivery status to a message only while its
+// current status is one of fromStatuses, so duplicate and out-of-order
+// delivery reports never move a message backward. It reports whether the
+// message was updated.
+func (r *Repository) AdvanceMessageStatus(ctx context.Context, id, status, errorCode, errorMessage string, deliveredAt *time.Time, fromStatuses []string) (bool, error) {
+	query := `
+		UPDATE sms_messages
+		SET status = $2, error_code = $3, error_message = $4,
+			delivered_at = COALESCE(delivered_at, $5), updated_at = $6
+		WHERE id = $1 AND status = ANY($7)`
+	result, err := r.db.ExecContext(ctx, query, id, status, errorCode, errorMessage, deliveredAt, time.Now(), pq.Array(fromStatuses))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// DeliveryReport represents a delivery status callback received from a provider
+type DeliveryReport struct {
+	ID           string    `db:"id"`
+	MessageID    *string   `db:"message_id"`
+	Provider     string    `db:"provider"`
+	ProviderID   string    `db:"provider_id"`
+	Status       string    `db:"status"`
+	StatusCode   string    `db:"status_code"`
+	ErrorCode    string    `db:"error_code"`
+	ErrorMessage string    `db:"error_message"`
+	ReceivedAt   time.Time `db:"received_at"`
+}
+
+// CreateDeliveryReport records a delivery status callback
+func (r *Repository) CreateDeliveryReport(ctx context.Context, report *DeliveryReport) error {
+	report.ID = uuid.New().String()
+	report.ReceivedAt = time.Now()
+
+	query := `
+		INSERT INTO sms_delivery_reports (
+			id, message_id, provider, provider_id, status,
+			status_code, error_code, error_message, received_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		report.ID, report.MessageID, report.Provider, report.ProviderID, report.Status,
+		report.StatusCode, report.ErrorCode, report.ErrorMessage, report.ReceivedAt,
+	)
+	return err
+}
+
 // ListMessages lists messages with pagination
 func (r *Repository) ListMessages(ctx context.Context, organizationID string, limit, offset int) ([]*SMSMessage, int, error) {
 	var total int
@@ -160,7 +244,7 @@ func (r *Repository) ListMessages(ctx context.Context, organizationID string, li
 
 	var messages []*SMSMessage
 	query := `
-		SELECT * FROM sms_messages
+		SELECT ` + smsMessageColumns + ` FROM sms_messages
 		WHERE organization_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3`
@@ -171,6 +255,136 @@ func (r *Repository) ListMessages(ctx context.Context, organizationID string, li
 	return messages, total, nil
 }
 
+// ClaimDueMessages moves up to limit scheduled messages whose time has come
+// to queued and returns them. Concurrent schedulers never claim the same
+// message.
+func (r *Repository) ClaimDueMessages(ctx context.Context, limit int) ([]*SMSMessage, error) {
+	var messages []*SMSMessage
+	query := `
+		UPDATE sms_messages SET status = 'queued', updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM sms_messages
+			WHERE status = 'scheduled' AND scheduled_at <= NOW()
+			ORDER BY scheduled_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + smsMessageColumns
+	if err := r.db.SelectContext(ctx, &messages, query, limit); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// MarkMessageSent records the provider's acceptance of a claimed message and
+// the charge it reported. An empty currency means no price was reported.
+func (r *Repository) MarkMessageSent(ctx context.Context, id, provider, providerID, status string, segmentCount int, cost float64, currency string, sentAt time.Time) error {
+	query := `
+		UPDATE sms_messages
+		SET provider = $2, provider_id = $3, status = $4, segment_count = $5, cost = $6,
+			currency = COALESCE(NULLIF($7, ''), currency),
+			price_reported_at = CASE WHEN $7 <> '' THEN $9 END,
+			sent_at = $8, updated_at = $9
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, provider, providerID, status, segmentCount, cost, currency, sentAt, time.Now())
+	return err
+}
+
+// RecordMessagePrice records the charge a delivery receipt reported for a
+// message. A segment count of zero leaves the recorded one. Unless replace
+// is set, a price already reported is kept: receipts sent per part of a
+// concatenated message don't carry the whole message's price.
+func (r *Repository) RecordMessagePrice(ctx context.Context, id string, segmentCount int, cost float64, currency string, replace bool) error {
+	query := `
+		UPDATE sms_messages
+		SET segment_count = CASE WHEN $2 > 0 THEN $2 ELSE segment_count END,
+			cost = $3, currency = $4, price_reported_at = $5, updated_at = $5
+		WHERE id = $1 AND ($6 OR price_reported_at IS NULL)`
+	_, err := r.db.ExecContext(ctx, query, id, segmentCount, cost, currency, time.Now(), replace)
+	return err
+}
+
+// RescheduleMessage returns a claimed message to the schedule at a later time
+func (r *Repository) RescheduleMessage(ctx context.Context, id string, at time.Time) error {
+	query := `
+		UPDATE sms_messages SET status = 'scheduled', scheduled_at = $2, updated_at = $3
+		WHERE id = $1 AND status = 'queued'`
+	_, err := r.db.ExecContext(ctx, query, id, at, time.Now())
+	return err
+}
+
+// CancelScheduledMessage cancels a message of an organization that has not
+// been dispatched yet
+func (r *Repository) CancelScheduledMessage(ctx context.Context, id, organizationID string) error {
+	query := `
+		UPDATE sms_messages SET status = 'cancelled', updated_at = $3
+		WHERE id = $1 AND organization_id = $2 AND status = 'scheduled'`
+	result, err := r.db.ExecContext(ctx, query, id, organizationID, time.Now())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var exists bool
+	existsQuery := `SELECT EXISTS(SELECT 1 FROM sms_messages WHERE id = $1 AND organization_id = $2)`
+	if err := r.db.GetContext(ctx, &exists, existsQuery, id, organizationID); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrMessageNotFound
+	}
+	return ErrMessageNotCancellable
+}
+
+// =============================================================================
+// Country Rule Operations
+// =============================================================================
+
+// CountryRules restricts the countries an organization can send to. Entries
+// are E.164 calling codes without "+". An empty allowlist allows every
+// country that isn't blocklisted.
+type CountryRules struct {
+	OrganizationID string         `db:"organization_id" json:"-"`
+	Allowlist      pq.StringArray `db:"allowlist" json:"allowlist"`
+	Blocklist      pq.StringArray `db:"blocklist" json:"blocklist"`
+	UpdatedAt      *time.Time     `db:"updated_at" json:"updated_at,omitempty"`
+}
+
+// GetCountryRules returns an organization's country rules; an organization
+// without rules gets empty lists
+func (r *Repository) GetCountryRules(ctx context.Context, organizationID string) (*CountryRules, error) {
+	rules := CountryRules{OrganizationID: organizationID, Allowlist: pq.StringArray{}, Blocklist: pq.StringArray{}}
+	query := `
+		SELECT organization_id, allowlist, blocklist, updated_at
+		FROM sms_country_rules WHERE organization_id = $1`
+	err := r.db.GetContext(ctx, &rules, query, organizationID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// SaveCountryRules replaces an organization's country rules
+func (r *Repository) SaveCountryRules(ctx context.Context, rules *CountryRules) error {
+	query := `
+		INSERT INTO sms_country_rules (organization_id, allowlist, blocklist, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id) DO UPDATE
+		SET allowlist = EXCLUDED.allowlist, blocklist = EXCLUDED.blocklist, updated_at = EXCLUDED.updated_at`
+	now := time.Now()
+	if _, err := r.db.ExecContext(ctx, query, rules.OrganizationID, rules.Allowlist, rules.Blocklist, now); err != nil {
+		return err
+	}
+	rules.UpdatedAt = &now
+	return nil
+}
+
 // =============================================================================
 // OTP Operations
 // =============================================================================
@@ -265,6 +479,22 @@ func (r *Repository) GetLastOTP(ctx context.Context, phoneNumber, purpose string
 	return &record, nil
 }
 
+// CountOTPsSince counts the OTPs sent to a phone number since the given
+// time and returns when the oldest of them was sent
+func (r *Repository) CountOTPsSince(ctx context.Context, phoneNumber string, since time.Time) (int, time.Time, error) {
+	var result struct {
+		Count  int          `db:"count"`
+		Oldest sql.NullTime `db:"oldest"`
+	}
+	query := `
+		SELECT COUNT(*) AS count, MIN(created_at) AS oldest FROM sms_otps
+		WHERE phone_number = $1 AND created_at > $2`
+	if err := r.db.GetContext(ctx, &result, query, phoneNumber, since); err != nil {
+		return 0, time.Time{}, err
+	}
+	return result.Count, result.Oldest.Time, nil
+}
+
 // IncrementOTPAttempts increments the attempt counter
 func (r *Repository) IncrementOTPAttempts(ctx context.Context, id string) error {
 	query := `UPDATE sms_otps SET attempts = attempts + 1 WHERE id = $1`
@@ -441,6 +671,110 @@ func (r *Repository) ResetRateLimit(ctx context.Context, key string) error {
 	return r.redis.Del(ctx, key).Err()
 }
 
+// =============================================================================
+// OTP Throttling Operations (Redis)
+// =============================================================================
+
+// ClaimOTPCooldown starts the resend cooldown for a phone number. If a
+// cooldown is already running it returns false and the time left on it.
+func (r *Repository) ClaimOTPCooldown(ctx context.Context, phoneNumber string, cooldown time.Duration) (bool, time.Duration, error) {
+	if r.redis == nil {
+		return false, 0, fmt.Errorf("redis not available")
+	}
+
+	key := "otp:cooldown:" + phoneNumber
+	claimed, err := r.redis.SetNX(ctx, key, 1, cooldown).Result()
+	if err != nil || claimed {
+		return claimed, 0, err
+	}
+
+	ttl, err := r.redis.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	return false, ttl, nil
+}
+
+// ReleaseOTPCooldown ends the resend cooldown for a phone number
+func (r *Repository) ReleaseOTPCooldown(ctx context.Context, phoneNumber string) error {
+	if r.redis == nil {
+		return nil
+	}
+	return r.redis.Del(ctx, "otp:cooldown:"+phoneNumber).Err()
+}
+
+// IncrementOTPResends counts an OTP sent to a phone number in the current
+// window. The window starts with the first OTP and is not extended by later
+// ones. It returns the count and the time until the window resets.
+func (r *Repository) IncrementOTPResends(ctx context.Context, phoneNumber string, window time.Duration) (int, time.Duration, error) {
+	if r.redis == nil {
+		return 0, 0, fmt.Errorf("redis not available")
+	}
+
+	key := "otp:resends:" + phoneNumber
+	pipe := r.redis.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, window)
+	ttl := pipe.PTTL(ctx, key)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+	return int(incr.Val()), ttl.Val(), nil
+}
+
+// DecrementOTPResends gives back an OTP counted by IncrementOTPResends
+func (r *Repository) DecrementOTPResends(ctx context.Context, phoneNumber string) error {
+	if r.redis == nil {
+		return nil
+	}
+	return r.redis.Decr(ctx, "otp:resends:"+phoneNumber).Err()
+}
+
+// IncrementOTPVerifyAttempts counts a verification attempt on an OTP and
+// returns the number of attempts so far. The counter expires with the OTP.
+func (r *Repository) IncrementOTPVerifyAttempts(ctx context.Context, id string, ttl time.Duration) (int, error) {
+	if r.redis == nil {
+		return 0, fmt.Errorf("redis not available")
+	}
+
+	key := "otp:attempts:" + id
+	pipe := r.redis.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, ttl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(incr.Val()), nil
+}
+
+// =============================================================================
+// Number Lookup Cache (Redis)
+// =============================================================================
+
+// GetNumberLookup returns the cached carrier lookup of a number, or nil if
+// there is none
+func (r *Repository) GetNumberLookup(ctx context.Context, number string) ([]byte, error) {
+	if r.redis == nil {
+		return nil, fmt.Errorf("redis not available")
+	}
+
+	data, err := r.redis.Get(ctx, "lookup:number:"+number).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+// SaveNumberLookup caches the carrier lookup of a number for ttl
+func (r *Repository) SaveNumberLookup(ctx context.Context, number string, data []byte, ttl time.Duration) error {
+	if r.redis == nil {
+		return fmt.Errorf("redis not available")
+	}
+	return r.redis.Set(ctx, "lookup:number:"+number, data, ttl).Err()
+}
+
 // AnalyticsSummary represents analytics summary data
 type AnalyticsSummary struct {
 	TotalSent      int64   `json:"total_sent"`
@@ -455,6 +789,48 @@ func (r *Repository) GetAnalyticsSummary(ctx context.Context, organizationID str
 	return &AnalyticsSummary{}, nil
 }
 
+// CostSummary is the spend on messages sent through one provider to one
+// destination country in one currency
+type CostSummary struct {
+	Provider          string  `db:"provider" json:"provider"`
+	CallingCode       string  `db:"calling_code" json:"calling_code"`
+	Currency          string  `db:"currency" json:"currency"`
+	Messages          int64   `db:"messages" json:"messages"`
+	Segments          int64   `db:"segments" json:"segments"`
+	EstimatedSegments int64   `db:"estimated_segments" json:"estimated_segments"`
+	Cost              float64 `db:"cost" json:"cost"`
+	// Unpriced counts messages the provider hasn't reported a price for yet
+	Unpriced int64 `db:"unpriced" json:"unpriced"`
+	// SegmentMismatches counts messages whose provider segment count differs
+	// from the local estimate
+	SegmentMismatches int64 `db:"segment_mismatches" json:"segment_mismatches"`
+}
+
+// GetCostSummaries aggregates the spend on messages sent in [from, to) by
+// provider, destination country and currency. An empty organization covers
+// every organization.
+func (r *Repository) GetCostSummaries(ctx context.Context, organizationID string, from, to time.Time) ([]*CostSummary, error) {
+	query := `
+		SELECT provider, COALESCE(calling_code, '') AS calling_code, COALESCE(currency, 'USD') AS currency,
+			COUNT(*) AS messages,
+			COALESCE(SUM(segment_count), 0) AS segments,
+			COALESCE(SUM(estimated_segments), 0) AS estimated_segments,
+			COALESCE(SUM(cost), 0)::float8 AS cost,
+			COUNT(*) FILTER (WHERE price_reported_at IS NULL) AS unpriced,
+			COUNT(*) FILTER (WHERE estimated_segments IS NOT NULL AND segment_count <> estimated_segments) AS segment_mismatches
+		FROM sms_messages
+		WHERE sent_at >= $1 AND sent_at < $2
+			AND ($3 = '' OR organization_id = NULLIF($3, '')::uuid)
+		GROUP BY 1, 2, 3
+		ORDER BY cost DESC, provider, calling_code`
+
+	summaries := []*CostSummary{}
+	if err := r.db.SelectContext(ctx, &summaries, query, from, to, organizationID); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
 // UsageStats represents usage statistics
 type UsageStats struct {
 	Today int64 `json:"today"`
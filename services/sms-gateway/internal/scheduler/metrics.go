@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcomes of dispatching a due scheduled message.
+const (
+	dispatchSent     = "sent"
+	dispatchFailed   = "failed"
+	dispatchDeferred = "deferred"
+	dispatchBlocked  = "blocked"
+)
+
+// dispatched counts due scheduled messages handled by the scheduler.
+var dispatched = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sms_scheduled_dispatched_total",
+	Help: "Number of due scheduled SMS messages handled, by result.",
+}, []string{"result"})
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sms-gateway/internal/config"
+)
+
+// QuietHours decides when a scheduled message may be delivered so that it
+// doesn't arrive at night in the recipient's time zone
+type QuietHours struct {
+	start, end int // minutes after local midnight
+	defaultLoc *time.Location
+	countries  []countryZone
+}
+
+// countryZone maps an E.164 calling code prefix to a time zone
+type countryZone struct {
+	prefix string
+	loc    *time.Location
+}
+
+// NewQuietHours builds the quiet hours window from configuration. It returns
+// nil when quiet hours are disabled; a nil *QuietHours allows every time.
+func NewQuietHours(cfg config.QuietHoursConfig) (*QuietHours, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	start, err := parseClock(cfg.Start)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours start: %w", err)
+	}
+	end, err := parseClock(cfg.End)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours end: %w", err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("quiet hours start and end are both %s", cfg.Start)
+	}
+
+	defaultLoc, err := time.LoadLocation(cfg.DefaultTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours default timezone: %w", err)
+	}
+
+	q := &QuietHours{start: start, end: end, defaultLoc: defaultLoc}
+	for prefix, tz := range cfg.CountryTimezones {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("quiet hours timezone for +%s: %w", prefix, err)
+		}
+		q.countries = append(q.countries, countryZone{prefix: strings.TrimPrefix(prefix, "+"), loc: loc})
+	}
+
+	// Longest prefix first so "+1809" can override "+1"
+	sort.Slice(q.countries, func(i, j int) bool {
+		if len(q.countries[i].prefix) != len(q.countries[j].prefix) {
+			return len(q.countries[i].prefix) > len(q.countries[j].prefix)
+		}
+		return q.countries[i].prefix < q.countries[j].prefix
+	})
+
+	return q, nil
+}
+
+// parseClock parses an "HH:MM" time of day into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Location returns the recipient's time zone. An explicit IANA timezone wins;
+// otherwise it is looked up from the calling code of the E.164 number.
+func (q *QuietHours) Location(phoneNumber, timezone string) *time.Location {
+	if q == nil {
+		return time.UTC
+	}
+	if timezone != "" {
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			return loc
+		}
+	}
+
+	digits := strings.TrimPrefix(phoneNumber, "+")
+	for _, c := range q.countries {
+		if strings.HasPrefix(digits, c.prefix) {
+			return c.loc
+		}
+	}
+	return q.defaultLoc
+}
+
+// Next returns the earliest time at or after t that is outside quiet hours
+// in loc
+func (q *QuietHours) Next(t time.Time, loc *time.Location) time.Time {
+	if q == nil {
+		return t
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	var quiet bool
+	if q.start < q.end {
+		quiet = minute >= q.start && minute < q.end
+	} else {
+		quiet = minute >= q.start || minute < q.end
+	}
+	if !quiet {
+		return t
+	}
+
+	// The window ends today unless it wraps midnight and we're before it does
+	day := local
+	if q.start > q.end && minute >= q.start {
+		day = local.AddDate(0, 0, 1)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), q.end/60, q.end%60, 0, 0, loc)
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"sms-gateway/internal/config"
+)
+
+func newTestQuietHours(t *testing.T, start, end string) *QuietHours {
+	t.Helper()
+	q, err := NewQuietHours(config.QuietHoursConfig{
+		Enabled:         true,
+		Start:           start,
+		End:             end,
+		DefaultTimezone: "UTC",
+		CountryTimezones: map[string]string{
+			"1":    "America/New_York",
+			"1808": "Pacific/Honolulu",
+			"+44":  "Europe/London",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewQuietHours() error = %v", err)
+	}
+	return q
+}
+
+func TestQuietHoursNext(t *testing.T) {
+	q := newTestQuietHours(t, "21:00", "08:00")
+	ny, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+	}{
+		{"daytime is allowed", time.Date(2026, 3, 2, 12, 0, 0, 0, ny), time.Date(2026, 3, 2, 12, 0, 0, 0, ny)},
+		{"just before quiet hours", time.Date(2026, 3, 2, 20, 59, 0, 0, ny), time.Date(2026, 3, 2, 20, 59, 0, 0, ny)},
+		{"evening moves to next morning", time.Date(2026, 3, 2, 22, 30, 0, 0, ny), time.Date(2026, 3, 3, 8, 0, 0, 0, ny)},
+		{"after midnight moves to same morning", time.Date(2026, 3, 3, 3, 0, 0, 0, ny), time.Date(2026, 3, 3, 8, 0, 0, 0, ny)},
+		{"end of window is allowed", time.Date(2026, 3, 3, 8, 0, 0, 0, ny), time.Date(2026, 3, 3, 8, 0, 0, 0, ny)},
+		{"across a DST change", time.Date(2026, 3, 7, 23, 0, 0, 0, ny), time.Date(2026, 3, 8, 8, 0, 0, 0, ny)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := q.Next(tt.at.UTC(), ny); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.at, got.In(ny), tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietHoursNextSameDayWindow(t *testing.T) {
+	q := newTestQuietHours(t, "12:00", "14:00")
+
+	at := time.Date(2026, 3, 2, 13, 0, 0, 0, time.UTC)
+	if got, want := q.Next(at, time.UTC), time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+	at = time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC)
+	if got := q.Next(at, time.UTC); !got.Equal(at) {
+		t.Errorf("Next() = %v, want unchanged", got)
+	}
+}
+
+func TestQuietHoursLocation(t *testing.T) {
+	q := newTestQuietHours(t, "21:00", "08:00")
+
+	tests := []struct {
+		phone    string
+		timezone string
+		want     string
+	}{
+		{"+12125550100", "", "America/New_York"},
+		{"+18085550100", "", "Pacific/Honolulu"},
+		{"+442071234567", "", "Europe/London"},
+		{"+33123456789", "", "UTC"},
+		{"+12125550100", "America/Los_Angeles", "America/Los_Angeles"},
+		{"+12125550100", "Not/AZone", "America/New_York"},
+	}
+	for _, tt := range tests {
+		if got := q.Location(tt.phone, tt.timezone).String(); got != tt.want {
+			t.Errorf("Location(%q, %q) = %s, want %s", tt.phone, tt.timezone, got, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursDisabled(t *testing.T) {
+	q, err := NewQuietHours(config.QuietHoursConfig{Enabled: false})
+	if err != nil || q != nil {
+		t.Fatalf("NewQuietHours() = %v, %v, want nil, nil", q, err)
+	}
+
+	at := time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)
+	if got := q.Next(at, q.Location("+12125550100", "")); !got.Equal(at) {
+		t.Errorf("Next() = %v, want unchanged", got)
+	}
+}
+
+func TestNewQuietHoursInvalid(t *testing.T) {
+	for _, cfg := range []config.QuietHoursConfig{
+		{Enabled: true, Start: "9pm", End: "08:00", DefaultTimezone: "UTC"},
+		{Enabled: true, Start: "21:00", End: "21:00", DefaultTimezone: "UTC"},
+		{Enabled: true, Start: "21:00", End: "08:00", DefaultTimezone: "Mars/Olympus"},
+		{Enabled: true, Start: "21:00", End: "08:00", DefaultTimezone: "UTC", CountryTimezones: map[string]string{"1": "Nowhere"}},
+	} {
+		if _, err := NewQuietHours(cfg); err == nil {
+			t.Errorf("NewQuietHours(%+v) succeeded, want error", cfg)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sms-gateway/internal/config"
+	"sms-gateway/internal/geo"
+	"sms-gateway/internal/providers"
+	"sms-gateway/internal/repository"
+)
+
+// Request represents a message to send at a later time
+type Request struct {
+	OrganizationID string
+	UserID         string
+	Provider       string
+	From           string
+	To             string
+	Message        string
+	MessageType    providers.MessageType
+	CallbackURL    string
+	// Timezone is the recipient's IANA time zone; when empty it is derived
+	// from the phone number's calling code
+	Timezone string
+	SendAt   time.Time
+}
+
+// metadata is stored with a scheduled message for use at dispatch time
+type metadata struct {
+	CallbackURL string `json:"callback_url,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+}
+
+// Scheduler stores messages with a future send time and dispatches them
+// through the provider manager once they are due
+type Scheduler struct {
+	config          config.SchedulerConfig
+	repo            *repository.Repository
+	providerManager *providers.Manager
+	geo             *geo.Checker
+	quietHours      *QuietHours
+	logger          *zap.Logger
+}
+
+// New creates a new scheduler
+func New(cfg config.SchedulerConfig, repo *repository.Repository, pm *providers.Manager, gc *geo.Checker, logger *zap.Logger) (*Scheduler, error) {
+	quietHours, err := NewQuietHours(cfg.QuietHours)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scheduler{
+		config:          cfg,
+		repo:            repo,
+		providerManager: pm,
+		geo:             gc,
+		quietHours:      quietHours,
+		logger:          logger,
+	}, nil
+}
+
+// Schedule stores a message for later delivery. Delivery is moved past quiet
+// hours in the recipient's time zone; the returned message carries the
+// actual dispatch time in ScheduledAt.
+func (s *Scheduler) Schedule(ctx context.Context, req *Request) (*repository.SMSMessage, error) {
+	meta, err := json.Marshal(metadata{CallbackURL: req.CallbackURL, Timezone: req.Timezone})
+	if err != nil {
+		return nil, err
+	}
+
+	loc := s.quietHours.Location(req.To, req.Timezone)
+	sendAt := s.quietHours.Next(req.SendAt, loc)
+
+	msg := &repository.SMSMessage{
+		OrganizationID: req.OrganizationID,
+		UserID:         req.UserID,
+		Provider:       req.Provider,
+		FromNumber:     req.From,
+		ToNumber:       req.To,
+		Message:        req.Message,
+		MessageType:    string(req.MessageType),
+		Status:         string(providers.DeliveryStatusScheduled),
+		SegmentCount:   geo.Segments(req.Message),
+		ScheduledAt:    &sendAt,
+		Metadata:       string(meta),
+	}
+	msg.EstimatedSegments = msg.SegmentCount
+	if dest, err := geo.Parse(req.To); err == nil {
+		msg.CallingCode = dest.CallingCode
+	}
+	if _, err := s.repo.CreateMessage(ctx, msg); err != nil {
+		return nil, fmt.Errorf("store scheduled message: %w", err)
+	}
+
+	s.logger.Info("SMS scheduled",
+		zap.String("message_id", msg.ID),
+		zap.Time("send_at", sendAt),
+		zap.Bool("quiet_hours_deferred", !sendAt.Equal(req.SendAt)),
+	)
+
+	return msg, nil
+}
+
+// Cancel cancels a scheduled message before it is dispatched. It returns
+// repository.ErrMessageNotFound or repository.ErrMessageNotCancellable.
+func (s *Scheduler) Cancel(ctx context.Context, id, organizationID string) error {
+	if err := s.repo.CancelScheduledMessage(ctx, id, organizationID); err != nil {
+		return err
+	}
+
+	s.logger.Info("Scheduled SMS cancelled", zap.String("message_id", id))
+	return nil
+}
+
+// Run dispatches due messages every poll interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.ProcessDue(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to process scheduled messages", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue claims and sends the messages whose time has come, in batches,
+// until none are left
+func (s *Scheduler) ProcessDue(ctx context.Context) error {
+	for {
+		messages, err := s.repo.ClaimDueMessages(ctx, s.config.BatchSize)
+		if err != nil {
+			return fmt.Errorf("claim scheduled messages: %w", err)
+		}
+
+		for _, msg := range messages {
+			s.dispatch(ctx, msg)
+		}
+
+		if len(messages) < s.config.BatchSize {
+			return nil
+		}
+	}
+}
+
+// dispatch sends one claimed message, or puts it back if it is now quiet
+// hours for the recipient because the scheduler fell behind
+func (s *Scheduler) dispatch(ctx context.Context, msg *repository.SMSMessage) {
+	var meta metadata
+	if err := json.Unmarshal([]byte(msg.Metadata), &meta); err != nil {
+		s.logger.Warn("Ignoring invalid scheduled message metadata", zap.String("message_id", msg.ID), zap.Error(err))
+	}
+
+	now := time.Now()
+	if next := s.quietHours.Next(now, s.quietHours.Location(msg.ToNumber, meta.Timezone)); next.After(now) {
+		if err := s.repo.RescheduleMessage(ctx, msg.ID, next); err != nil {
+			s.logger.Error("Failed to defer scheduled message", zap.String("message_id", msg.ID), zap.Error(err))
+			return
+		}
+		dispatched.WithLabelValues(dispatchDeferred).Inc()
+		return
+	}
+
+	// Country rules may have changed since the message was scheduled
+	if _, err := s.geo.Check(ctx, msg.OrganizationID, msg.ToNumber); err != nil {
+		var blocked *geo.BlockedError
+		if !errors.As(err, &blocked) && !errors.Is(err, geo.ErrInvalidNumber) {
+			// Couldn't load the rules; try again on the next poll
+			s.logger.Error("Failed to check scheduled message destination", zap.String("message_id", msg.ID), zap.Error(err))
+			if err := s.repo.RescheduleMessage(ctx, msg.ID, now); err != nil {
+				s.logger.Error("Failed to defer scheduled message", zap.String("message_id", msg.ID), zap.Error(err))
+			}
+			dispatched.WithLabelValues(dispatchDeferred).Inc()
+			return
+		}
+		dispatched.WithLabelValues(dispatchBlocked).Inc()
+		if err := s.repo.UpdateMessageStatus(ctx, msg.ID, string(providers.DeliveryStatusFailed), "destination_blocked", err.Error(), nil); err != nil {
+			s.logger.Error("Failed to mark scheduled message failed", zap.String("message_id", msg.ID), zap.Error(err))
+		}
+		return
+	}
+
+	req := &providers.SendRequest{
+		To:          msg.ToNumber,
+		From:        msg.FromNumber,
+		Message:     msg.Message,
+		MessageType: providers.MessageType(msg.MessageType),
+		CallbackURL: meta.CallbackURL,
+	}
+
+	var resp *providers.SendResponse
+	var err error
+	if msg.Provider != "" {
+		resp, err = s.providerManager.SendWithProvider(ctx, msg.Provider, req)
+	} else {
+		resp, err = s.providerManager.Send(ctx, req)
+	}
+	if err != nil {
+		s.logger.Error("Failed to send scheduled SMS", zap.String("message_id", msg.ID), zap.Error(err))
+		dispatched.WithLabelValues(dispatchFailed).Inc()
+		if err := s.repo.UpdateMessageStatus(ctx, msg.ID, string(providers.DeliveryStatusFailed), "send_failed", err.Error(), nil); err != nil {
+			s.logger.Error("Failed to mark scheduled message failed", zap.String("message_id", msg.ID), zap.Error(err))
+		}
+		return
+	}
+
+	dispatched.WithLabelValues(dispatchSent).Inc()
+	if !geo.CheckSegments(resp.Provider, msg.EstimatedSegments, resp.SegmentCount) {
+		s.logger.Warn("Provider segment count differs from estimate",
+			zap.String("message_id", msg.ID),
+			zap.String("provider", resp.Provider),
+			zap.Int("estimated", msg.EstimatedSegments),
+			zap.Int("charged", resp.SegmentCount))
+	}
+	if err := s.repo.MarkMessageSent(ctx, msg.ID, resp.Provider, resp.ProviderID, string(resp.Status), resp.SegmentCount, resp.Cost, resp.Currency, resp.SentAt); err != nil {
+		s.logger.Error("Failed to record scheduled SMS send", zap.String("message_id", msg.ID), zap.Error(err))
+	}
+}
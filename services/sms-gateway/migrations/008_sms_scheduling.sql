@@ -0,0 +1,8 @@
+-- SMS Gateway Scheduled Sending
+-- Migration: 008_sms_scheduling.sql
+
+-- Messages with a future send_at are stored with status 'scheduled' until the
+-- scheduler dispatches them; due messages are polled by scheduled_at
+CREATE INDEX IF NOT EXISTS idx_sms_messages_scheduled
+    ON sms_messages(scheduled_at)
+    WHERE status = 'scheduled';
@@ -0,0 +1,12 @@
+-- SMS Gateway Country Rules
+-- Migration: 009_sms_country_rules.sql
+
+-- Per-organization destination country allow and blocklists, as E.164
+-- calling codes without "+". They are checked before a message is handed to
+-- a provider; an empty allowlist allows every country not blocklisted.
+CREATE TABLE IF NOT EXISTS sms_country_rules (
+    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    allowlist TEXT[] NOT NULL DEFAULT '{}',
+    blocklist TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
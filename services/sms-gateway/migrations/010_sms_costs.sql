@@ -0,0 +1,17 @@
+-- SMS Gateway Cost Accounting
+-- Migration: 010_sms_costs.sql
+
+-- segment_count, cost and currency hold what the provider charged, from its
+-- send response or a delivery receipt; price_reported_at is set once a price
+-- was reported. estimated_segments is the count computed locally from the
+-- message encoding, to validate the provider's. calling_code is the
+-- destination country's E.164 calling code without "+".
+ALTER TABLE sms_messages
+    ADD COLUMN IF NOT EXISTS calling_code VARCHAR(3),
+    ADD COLUMN IF NOT EXISTS estimated_segments INTEGER,
+    ADD COLUMN IF NOT EXISTS price_reported_at TIMESTAMPTZ;
+
+-- Cost reports aggregate an organization's sent messages over a time range
+CREATE INDEX IF NOT EXISTS idx_sms_messages_org_sent
+    ON sms_messages(organization_id, sent_at)
+    WHERE sent_at IS NOT NULL;
@@ -5,23 +5,26 @@ package arc
 
 import (
 	"bytes"
-	"crypto"
-	"crypto/rsa"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"net/mail"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/oonrumail/smtp-server/dkim"
 	"github.com/oonrumail/smtp-server/domain"
 )
 
+// MaxInstances is the highest ARC instance number allowed by RFC 8617
+const MaxInstances = 50
+
 // ChainValidation represents the result of ARC chain validation
 type ChainValidation string
 
@@ -42,10 +45,10 @@ type AuthResult struct {
 
 // ARCSet represents a complete ARC header set (instance i)
 type ARCSet struct {
-	Instance               int
-	Seal                   string // ARC-Seal header value
-	MessageSignature       string // ARC-Message-Signature header value
-	AuthenticationResults  string // ARC-Authentication-Results header value
+	Instance              int
+	Seal                  string // ARC-Seal header value
+	MessageSignature      string // ARC-Message-Signature header value
+	AuthenticationResults string // ARC-Authentication-Results header value
 }
 
 // Signer handles ARC signing for messages passing through the mail system
@@ -118,8 +121,25 @@ func (s *Signer) SignMessage(domainName string, message []byte, authResults []Au
 		return nil, fmt.Errorf("read body: %w", err)
 	}
 
-	// Determine the next instance number
-	instance := getNextInstanceNumber(msg.Header)
+	// Determine the next instance number, continuing any existing chain
+	sets := extractARCSets(msg.Header)
+	instance := len(sets) + 1
+	if len(sets) > 0 {
+		instance = sets[len(sets)-1].Instance + 1
+	}
+	if instance > MaxInstances {
+		return nil, fmt.Errorf("ARC chain already has %d instances", instance-1)
+	}
+
+	// The first instance has no chain to validate, and an unverifiable chain
+	// must not be vouched for
+	cv := chainValidation
+	switch {
+	case instance == 1:
+		cv = ChainValidationNone
+	case cv != ChainValidationPass:
+		cv = ChainValidationFail
+	}
 
 	// Generate ARC-Authentication-Results
 	aar := s.buildAuthenticationResults(instance, authResults, chainValidation)
@@ -131,7 +151,7 @@ func (s *Signer) SignMessage(domainName string, message []byte, authResults []Au
 	}
 
 	// Generate ARC-Seal (signs over all ARC headers including the new ones)
-	arcSeal, err := s.buildSeal(instance, key, domainName, chainValidation, msg.Header, aar, ams)
+	arcSeal, err := s.buildSeal(instance, key, domainName, cv, sets, aar, ams)
 	if err != nil {
 		return nil, fmt.Errorf("build ARC-Seal: %w", err)
 	}
@@ -146,18 +166,52 @@ func (s *Signer) SignMessage(domainName string, message []byte, authResults []Au
 	s.logger.Debug("Message signed with ARC",
 		zap.String("domain", domainName),
 		zap.Int("instance", instance),
-		zap.String("chain_validation", string(chainValidation)))
+		zap.String("chain_validation", string(cv)))
 
 	return result.Bytes(), nil
 }
 
+// SealForwarded adds an ARC set to a message that is being forwarded. The
+// results recorded in this host's Authentication-Results header on receipt,
+// including the arc= chain validation, are carried into the new set.
+func (s *Signer) SealForwarded(domainName string, message []byte) ([]byte, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return nil, fmt.Errorf("parse message: %w", err)
+	}
+
+	var authResults []AuthResult
+	cv := ChainValidationNone
+	if len(extractARCSets(msg.Header)) > 0 {
+		// A chain we never validated cannot be vouched for
+		cv = ChainValidationFail
+	}
+
+	for _, header := range msg.Header["Authentication-Results"] {
+		authservID, results := ParseAuthenticationResults(header)
+		if !strings.EqualFold(authservID, s.hostname) {
+			continue
+		}
+		for _, r := range results {
+			if r.Method == "arc" {
+				cv = ChainValidation(r.Result)
+				continue
+			}
+			authResults = append(authResults, r)
+		}
+		break
+	}
+
+	return s.SignMessage(domainName, message, authResults, cv, nil)
+}
+
 // buildAuthenticationResults builds the ARC-Authentication-Results header
 func (s *Signer) buildAuthenticationResults(instance int, authResults []AuthResult, chainValidation ChainValidation) string {
 	var parts []string
-	parts = append(parts, fmt.Sprintf("i=%d; %s;", instance, s.hostname))
+	parts = append(parts, fmt.Sprintf("i=%d; %s", instance, s.hostname))
 
 	// Add ARC chain validation result
-	parts = append(parts, fmt.Sprintf("arc=%s", chainValidation))
+	parts = append(parts, fmt.Sprintf("arc=%s", chainValidation.ResultString()))
 
 	// Add other authentication results
 	for _, ar := range authResults {
@@ -165,13 +219,18 @@ func (s *Signer) buildAuthenticationResults(instance int, authResults []AuthResu
 		if ar.Reason != "" {
 			resultStr += fmt.Sprintf(" (%s)", ar.Reason)
 		}
-		for k, v := range ar.Properties {
-			resultStr += fmt.Sprintf(" %s=%s", k, v)
+		props := make([]string, 0, len(ar.Properties))
+		for k := range ar.Properties {
+			props = append(props, k)
+		}
+		sort.Strings(props)
+		for _, k := range props {
+			resultStr += fmt.Sprintf(" %s=%s", k, ar.Properties[k])
 		}
 		parts = append(parts, resultStr)
 	}
 
-	return strings.Join(parts, "\r\n\t")
+	return strings.Join(parts, ";\r\n\t")
 }
 
 // buildMessageSignature builds the ARC-Message-Signature header
@@ -191,7 +250,7 @@ func (s *Signer) buildMessageSignature(instance int, key *domain.DKIMKey, domain
 	// Build ARC-Message-Signature parameter string (similar to DKIM but with i= for instance)
 	params := fmt.Sprintf("i=%d; a=%s; c=%s/%s; d=%s; s=%s; t=%d; h=%s; bh=%s; ",
 		instance,
-		key.Algorithm,
+		dkim.Algorithm(key),
 		config.HeaderCanonicalization,
 		config.BodyCanonicalization,
 		domainName,
@@ -202,15 +261,14 @@ func (s *Signer) buildMessageSignature(instance int, key *domain.DKIMKey, domain
 	)
 
 	// Canonicalize headers for signing
-	headerData := canonicalizeHeaders(headers, signedHeaders, config.HeaderCanonicalization)
+	headerData := dkim.CanonicalizeSignedHeaders(headers, signedHeaders, config.HeaderCanonicalization)
 
-	// Add ARC-Message-Signature header without b= value for signing
-	amsHeader := fmt.Sprintf("arc-message-signature:%s", canonicalizeHeaderValue(params, config.HeaderCanonicalization))
-	headerData = append(headerData, []byte(amsHeader)...)
+	// Add ARC-Message-Signature header with an empty b= value for signing
+	headerData = append(headerData, canonicalizeSignatureHeader("ARC-Message-Signature", params+"b=", config.HeaderCanonicalization)...)
 
 	// Sign the header data
 	headerHash := sha256.Sum256(headerData)
-	signature, err := rsa.SignPKCS1v15(nil, key.PrivateKey, crypto.SHA256, headerHash[:])
+	signature, err := dkim.SignHash(key, headerHash[:])
 	if err != nil {
 		return "", fmt.Errorf("sign ARC-Message-Signature: %w", err)
 	}
@@ -221,70 +279,133 @@ func (s *Signer) buildMessageSignature(instance int, key *domain.DKIMKey, domain
 }
 
 // buildSeal builds the ARC-Seal header
-func (s *Signer) buildSeal(instance int, key *domain.DKIMKey, domainName string, cv ChainValidation, headers mail.Header, aar, ams string) (string, error) {
+func (s *Signer) buildSeal(instance int, key *domain.DKIMKey, domainName string, cv ChainValidation, sets []*ARCSet, aar, ams string) (string, error) {
 	timestamp := time.Now().Unix()
 
 	// Build ARC-Seal parameter string
 	params := fmt.Sprintf("i=%d; a=%s; cv=%s; d=%s; s=%s; t=%d; ",
 		instance,
-		key.Algorithm,
+		dkim.Algorithm(key),
 		cv,
 		domainName,
 		key.Selector,
 		timestamp,
 	)
 
-	// Build data to sign: all ARC headers in chain plus new ones
-	var sealData bytes.Buffer
+	chain := append(sets, &ARCSet{
+		Instance:              instance,
+		Seal:                  params + "b=",
+		MessageSignature:      ams,
+		AuthenticationResults: aar,
+	})
 
-	// Include all previous ARC-Seal headers (instances 1 to i-1)
-	for inst := 1; inst < instance; inst++ {
-		if seal := getARCHeader(headers, "Arc-Seal", inst); seal != "" {
-			sealData.WriteString(fmt.Sprintf("arc-seal:%s\r\n", canonicalizeHeaderValue(seal, "relaxed")))
-		}
+	// Sign
+	sealHash := sha256.Sum256(sealData(chain))
+	signature, err := dkim.SignHash(key, sealHash[:])
+	if err != nil {
+		return "", fmt.Errorf("sign ARC-Seal: %w", err)
 	}
 
-	// Include all ARC-Message-Signature headers
-	for inst := 1; inst < instance; inst++ {
-		if ams := getARCHeader(headers, "Arc-Message-Signature", inst); ams != "" {
-			sealData.WriteString(fmt.Sprintf("arc-message-signature:%s\r\n", canonicalizeHeaderValue(ams, "relaxed")))
+	signatureB64 := base64.StdEncoding.EncodeToString(signature)
+
+	return fmt.Sprintf("%sb=%s", params, foldSignature(signatureB64)), nil
+}
+
+// sealData builds the data an ARC-Seal signs (RFC 8617 section 5.1.1): every
+// set in instance order, each as ARC-Authentication-Results,
+// ARC-Message-Signature and ARC-Seal, with the b= tag of the final seal
+// emptied. Seals always use relaxed header canonicalization.
+func sealData(chain []*ARCSet) []byte {
+	var data bytes.Buffer
+	for i, set := range chain {
+		data.Write(canonicalizeSignatureHeader("ARC-Authentication-Results", set.AuthenticationResults, "relaxed"))
+		data.WriteString("\r\n")
+		data.Write(canonicalizeSignatureHeader("ARC-Message-Signature", set.MessageSignature, "relaxed"))
+		data.WriteString("\r\n")
+		if i == len(chain)-1 {
+			data.Write(canonicalizeSignatureHeader("ARC-Seal", dkim.StripSignature(set.Seal), "relaxed"))
+		} else {
+			data.Write(canonicalizeSignatureHeader("ARC-Seal", set.Seal, "relaxed"))
+			data.WriteString("\r\n")
 		}
 	}
+	return data.Bytes()
+}
 
-	// Include all ARC-Authentication-Results headers
-	for inst := 1; inst < instance; inst++ {
-		if aar := getARCHeader(headers, "Arc-Authentication-Results", inst); aar != "" {
-			sealData.WriteString(fmt.Sprintf("arc-authentication-results:%s\r\n", canonicalizeHeaderValue(aar, "relaxed")))
-		}
+// canonicalizeSignatureHeader canonicalizes a single header without a
+// trailing CRLF
+func canonicalizeSignatureHeader(name, value, method string) []byte {
+	if method == "simple" {
+		return []byte(name + ": " + value)
 	}
+	return []byte(strings.ToLower(name) + ":" + canonicalizeHeaderValue(value, "relaxed"))
+}
 
-	// Include new headers
-	sealData.WriteString(fmt.Sprintf("arc-authentication-results:%s\r\n", canonicalizeHeaderValue(aar, "relaxed")))
-	sealData.WriteString(fmt.Sprintf("arc-message-signature:%s\r\n", canonicalizeHeaderValue(ams, "relaxed")))
+// ResultString returns the chain validation as an RFC 8601 result value
+func (cv ChainValidation) ResultString() string {
+	if cv == ChainValidationUnknown {
+		return "temperror"
+	}
+	return string(cv)
+}
 
-	// Add ARC-Seal without b= value
-	sealData.WriteString(fmt.Sprintf("arc-seal:%s", canonicalizeHeaderValue(params, "relaxed")))
+// ParseAuthenticationResults splits an Authentication-Results header value
+// into its authserv-id and results
+func ParseAuthenticationResults(header string) (string, []AuthResult) {
+	parts := strings.Split(header, ";")
+	authservID := strings.TrimSpace(parts[0])
 
-	// Sign
-	sealHash := sha256.Sum256(sealData.Bytes())
-	signature, err := rsa.SignPKCS1v15(nil, key.PrivateKey, crypto.SHA256, sealHash[:])
-	if err != nil {
-		return "", fmt.Errorf("sign ARC-Seal: %w", err)
-	}
+	var results []AuthResult
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		method, result, ok := strings.Cut(fields[0], "=")
+		if !ok {
+			continue
+		}
 
-	signatureB64 := base64.StdEncoding.EncodeToString(signature)
+		ar := AuthResult{Method: strings.ToLower(method), Result: strings.ToLower(result)}
+		rest := strings.Join(fields[1:], " ")
+		if strings.HasPrefix(rest, "(") {
+			if end := strings.Index(rest, ")"); end != -1 {
+				ar.Reason = rest[1:end]
+				rest = rest[end+1:]
+			}
+		}
+		for _, field := range strings.Fields(rest) {
+			if k, v, ok := strings.Cut(field, "="); ok {
+				if ar.Properties == nil {
+					ar.Properties = make(map[string]string)
+				}
+				ar.Properties[k] = v
+			}
+		}
+		results = append(results, ar)
+	}
 
-	return fmt.Sprintf("%sb=%s", params, foldSignature(signatureB64)), nil
+	return authservID, results
 }
 
 // Verifier handles ARC chain verification
 type Verifier struct {
+	keys   *dkim.Verifier
 	logger *zap.Logger
 }
 
 // NewVerifier creates a new ARC verifier
 func NewVerifier(logger *zap.Logger) *Verifier {
 	return &Verifier{
+		keys:   dkim.NewVerifier(logger),
+		logger: logger,
+	}
+}
+
+// NewVerifierWithResolver creates a verifier with a custom DNS resolver
+func NewVerifierWithResolver(logger *zap.Logger, resolver dkim.DNSResolver) *Verifier {
+	return &Verifier{
+		keys:   dkim.NewVerifierWithResolver(logger, resolver),
 		logger: logger,
 	}
 }
@@ -307,7 +428,10 @@ type ARCSetResult struct {
 	Error                   error
 }
 
-// VerifyChain verifies the complete ARC chain in a message
+// VerifyChain verifies the complete ARC chain in a message following RFC 8617
+// section 5.2. Only the newest ARC-Message-Signature is checked, since
+// earlier ones are expected to break as intermediaries modify the message;
+// every ARC-Seal must validate.
 func (v *Verifier) VerifyChain(message []byte) (*ChainResult, error) {
 	msg, err := mail.ReadMessage(bytes.NewReader(message))
 	if err != nil {
@@ -327,86 +451,144 @@ func (v *Verifier) VerifyChain(message []byte) (*ChainResult, error) {
 		TotalSets: len(sets),
 		Sets:      make([]*ARCSetResult, len(sets)),
 	}
+	for i, set := range sets {
+		result.Sets[i] = &ARCSetResult{Instance: set.Instance}
+	}
 
-	// Verify each set in order
+	fail := func(validation ChainValidation, err error) (*ChainResult, error) {
+		result.Validation = validation
+		result.Error = err
+		return result, nil
+	}
+
+	// Structural checks: instances 1..N, each set complete, and cv= values
+	// consistent with their position in the chain
+	if len(sets) > MaxInstances {
+		return fail(ChainValidationFail, fmt.Errorf("ARC chain has more than %d instances", MaxInstances))
+	}
 	for i, set := range sets {
-		setResult := v.verifySet(set, msg.Header)
-		result.Sets[i] = setResult
+		if set.Instance != i+1 {
+			return fail(ChainValidationFail, fmt.Errorf("ARC instance %d missing", i+1))
+		}
+		if set.MessageSignature == "" || set.AuthenticationResults == "" {
+			return fail(ChainValidationFail, fmt.Errorf("ARC set %d is incomplete", set.Instance))
+		}
+		result.Sets[i].AuthenticationResultsOK = true
 
-		if setResult.SealValid && setResult.MessageSignatureValid {
-			result.HighestValid = set.Instance
-		} else {
-			// Chain is broken
-			result.Validation = ChainValidationFail
-			result.Error = setResult.Error
-			return result, nil
+		cv := ChainValidation(parseARCParams(set.Seal)["cv"])
+		if cv == ChainValidationFail {
+			return fail(ChainValidationFail, fmt.Errorf("ARC set %d recorded a failed chain", set.Instance))
+		}
+		if (set.Instance == 1 && cv != ChainValidationNone) || (set.Instance > 1 && cv != ChainValidationPass) {
+			return fail(ChainValidationFail, fmt.Errorf("ARC-Seal %d has invalid cv=%s", set.Instance, cv))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Validate the newest ARC-Message-Signature
+	newest := sets[len(sets)-1]
+	if err := v.verifyMessageSignature(ctx, newest, msg.Header, msg.Body); err != nil {
+		result.Sets[len(sets)-1].Error = err
+		return fail(v.failure(err), fmt.Errorf("ARC-Message-Signature %d: %w", newest.Instance, err))
+	}
+	result.Sets[len(sets)-1].MessageSignatureValid = true
+
+	// Validate every ARC-Seal, newest first
+	for i := len(sets) - 1; i >= 0; i-- {
+		if err := v.verifySeal(ctx, sets[:i+1]); err != nil {
+			result.Sets[i].Error = err
+			return fail(v.failure(err), fmt.Errorf("ARC-Seal %d: %w", sets[i].Instance, err))
 		}
+		result.Sets[i].SealValid = true
 	}
 
+	result.HighestValid = newest.Instance
 	result.Validation = ChainValidationPass
+
+	v.logger.Debug("ARC chain verified",
+		zap.Int("instances", len(sets)),
+		zap.String("domain", parseARCParams(newest.Seal)["d"]))
+
 	return result, nil
 }
 
-func (v *Verifier) verifySet(set *ARCSet, headers mail.Header) *ARCSetResult {
-	result := &ARCSetResult{
-		Instance: set.Instance,
+// failure maps a verification error to a chain validation result. Key
+// lookups that may succeed later leave the chain unknown rather than failed.
+func (v *Verifier) failure(err error) ChainValidation {
+	if dkim.IsTemporaryError(err) {
+		return ChainValidationUnknown
 	}
+	return ChainValidationFail
+}
 
-	// Parse and verify ARC-Seal
-	sealParams := parseARCParams(set.Seal)
-
-	// Validate required parameters
-	requiredParams := []string{"i", "a", "cv", "d", "s", "b"}
-	for _, p := range requiredParams {
-		if sealParams[p] == "" {
-			result.Error = fmt.Errorf("ARC-Seal missing parameter: %s", p)
-			return result
+// verifyMessageSignature checks the body hash and signature of a set's
+// ARC-Message-Signature
+func (v *Verifier) verifyMessageSignature(ctx context.Context, set *ARCSet, headers mail.Header, body io.Reader) error {
+	params := parseARCParams(set.MessageSignature)
+	for _, p := range []string{"a", "c", "d", "s", "h", "bh", "b"} {
+		if params[p] == "" {
+			return fmt.Errorf("missing parameter: %s", p)
 		}
 	}
 
-	// Verify instance matches
-	if sealInstance, _ := strconv.Atoi(sealParams["i"]); sealInstance != set.Instance {
-		result.Error = fmt.Errorf("ARC-Seal instance mismatch")
-		return result
+	headerCanon, bodyCanon, _ := strings.Cut(params["c"], "/")
+	if bodyCanon == "" {
+		bodyCanon = "simple"
 	}
 
-	// Parse and verify ARC-Message-Signature
-	amsParams := parseARCParams(set.MessageSignature)
-	for _, p := range []string{"i", "a", "c", "d", "s", "h", "bh", "b"} {
-		if amsParams[p] == "" {
-			result.Error = fmt.Errorf("ARC-Message-Signature missing parameter: %s", p)
-			return result
-		}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+	bodyHash := sha256.Sum256(canonicalizeBody(data, bodyCanon))
+	expected, err := dkim.DecodeSignature(params["bh"])
+	if err != nil || !bytes.Equal(expected, bodyHash[:]) {
+		return fmt.Errorf("body hash mismatch")
 	}
 
-	// Verify instance matches
-	if amsInstance, _ := strconv.Atoi(amsParams["i"]); amsInstance != set.Instance {
-		result.Error = fmt.Errorf("ARC-Message-Signature instance mismatch")
-		return result
+	signedHeaders := strings.Split(params["h"], ":")
+	for _, name := range signedHeaders {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(name)), "arc-") {
+			return fmt.Errorf("signs ARC header %s", name)
+		}
 	}
+	headerData := dkim.CanonicalizeSignedHeaders(headers, signedHeaders, headerCanon)
+	headerData = append(headerData, canonicalizeSignatureHeader("ARC-Message-Signature", dkim.StripSignature(set.MessageSignature), headerCanon)...)
 
-	// Note: Full verification requires DNS lookup for public keys
-	// For now, we mark as valid if structure is correct
-	result.SealValid = true
-	result.MessageSignatureValid = true
-	result.AuthenticationResultsOK = true
+	return v.verifySignature(ctx, params, headerData)
+}
 
-	v.logger.Debug("ARC set verified",
-		zap.Int("instance", set.Instance),
-		zap.String("domain", sealParams["d"]),
-		zap.String("selector", sealParams["s"]))
+// verifySeal checks the ARC-Seal of the last set in chain
+func (v *Verifier) verifySeal(ctx context.Context, chain []*ARCSet) error {
+	params := parseARCParams(chain[len(chain)-1].Seal)
+	for _, p := range []string{"a", "cv", "d", "s", "b"} {
+		if params[p] == "" {
+			return fmt.Errorf("missing parameter: %s", p)
+		}
+	}
+	if params["h"] != "" {
+		return fmt.Errorf("h= tag is not allowed in ARC-Seal")
+	}
 
-	return result
+	return v.verifySignature(ctx, params, sealData(chain))
 }
 
-// Helper functions
+// verifySignature verifies b= over data with the key named by d= and s=
+func (v *Verifier) verifySignature(ctx context.Context, params map[string]string, data []byte) error {
+	publicKey, err := v.keys.LookupPublicKey(ctx, params["d"], params["s"])
+	if err != nil {
+		return err
+	}
 
-func getNextInstanceNumber(headers mail.Header) int {
-	sets := extractARCSets(headers)
-	if len(sets) == 0 {
-		return 1
+	signature, err := dkim.DecodeSignature(params["b"])
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
 	}
-	return sets[len(sets)-1].Instance + 1
+
+	hash := sha256.Sum256(data)
+	return dkim.VerifyHash(params["a"], publicKey, hash[:], signature)
 }
 
 func extractARCSets(headers mail.Header) []*ARCSet {
@@ -457,172 +639,32 @@ func extractARCSets(headers mail.Header) []*ARCSet {
 	}
 
 	// Sort by instance number
-	for i := 0; i < len(sets)-1; i++ {
-		for j := i + 1; j < len(sets); j++ {
-			if sets[i].Instance > sets[j].Instance {
-				sets[i], sets[j] = sets[j], sets[i]
-			}
-		}
-	}
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].Instance < sets[j].Instance
+	})
 
 	return sets
 }
 
-func getARCHeader(headers mail.Header, name string, instance int) string {
-	for _, h := range headers[name] {
-		params := parseARCParams(h)
-		if iStr := params["i"]; iStr != "" {
-			i, _ := strconv.Atoi(iStr)
-			if i == instance {
-				return h
-			}
-		}
-	}
-	return ""
-}
+// The helpers below delegate to the DKIM implementation so that ARC and DKIM
+// signatures are always canonicalized the same way.
 
 func parseARCParams(header string) map[string]string {
-	params := make(map[string]string)
-
-	// Remove folding
-	header = strings.ReplaceAll(header, "\r\n", "")
-	header = strings.ReplaceAll(header, "\n", "")
-	header = strings.ReplaceAll(header, "\t", " ")
-
-	// Parse tag=value pairs
-	parts := strings.Split(header, ";")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-
-		idx := strings.Index(part, "=")
-		if idx == -1 {
-			continue
-		}
-
-		tag := strings.TrimSpace(part[:idx])
-		value := strings.TrimSpace(part[idx+1:])
-		params[tag] = value
-	}
-
-	return params
+	return dkim.ParseTags(header)
 }
 
 func getSignableHeaders(headers mail.Header, wantHeaders []string) []string {
-	var result []string
-	for _, h := range wantHeaders {
-		if headers.Get(h) != "" {
-			result = append(result, h)
-		}
-	}
-	return result
+	return dkim.SignableHeaders(headers, wantHeaders)
 }
 
 func canonicalizeBody(body []byte, method string) []byte {
-	switch method {
-	case "simple":
-		return canonicalizeBodySimple(body)
-	case "relaxed":
-		return canonicalizeBodyRelaxed(body)
-	default:
-		return canonicalizeBodyRelaxed(body)
-	}
-}
-
-func canonicalizeBodySimple(body []byte) []byte {
-	body = bytes.TrimRight(body, "\r\n")
-	if len(body) > 0 {
-		body = append(body, '\r', '\n')
-	}
-	return body
-}
-
-func canonicalizeBodyRelaxed(body []byte) []byte {
-	wspRegex := regexp.MustCompile(`[ \t]+`)
-
-	lines := bytes.Split(body, []byte("\n"))
-	var result [][]byte
-
-	for _, line := range lines {
-		line = bytes.TrimSuffix(line, []byte("\r"))
-		line = wspRegex.ReplaceAll(line, []byte(" "))
-		line = bytes.TrimRight(line, " \t")
-		result = append(result, line)
-	}
-
-	for len(result) > 0 && len(result[len(result)-1]) == 0 {
-		result = result[:len(result)-1]
-	}
-
-	if len(result) == 0 {
-		return []byte("\r\n")
-	}
-
-	output := bytes.Join(result, []byte("\r\n"))
-	output = append(output, '\r', '\n')
-
-	return output
-}
-
-func canonicalizeHeaders(headers mail.Header, signHeaders []string, method string) []byte {
-	var result bytes.Buffer
-
-	for _, name := range signHeaders {
-		value := headers.Get(name)
-		if value == "" {
-			continue
-		}
-
-		var line string
-		switch method {
-		case "simple":
-			line = fmt.Sprintf("%s: %s", name, value)
-		case "relaxed":
-			line = fmt.Sprintf("%s:%s", strings.ToLower(name), canonicalizeHeaderValue(value, method))
-		default:
-			line = fmt.Sprintf("%s:%s", strings.ToLower(name), canonicalizeHeaderValue(value, "relaxed"))
-		}
-
-		result.WriteString(line)
-		result.WriteString("\r\n")
-	}
-
-	return result.Bytes()
+	return dkim.CanonicalizeBody(body, method)
 }
 
 func canonicalizeHeaderValue(value, method string) string {
-	if method == "simple" {
-		return value
-	}
-
-	// Relaxed canonicalization
-	value = strings.ReplaceAll(value, "\r\n ", " ")
-	value = strings.ReplaceAll(value, "\r\n\t", " ")
-
-	wspRegex := regexp.MustCompile(`[ \t]+`)
-	value = wspRegex.ReplaceAllString(value, " ")
-
-	value = strings.TrimSpace(value)
-
-	return value
+	return dkim.CanonicalizeHeaderValue(value, method)
 }
 
 func foldSignature(sig string) string {
-	const lineLen = 72
-	var result strings.Builder
-
-	for i := 0; i < len(sig); i += lineLen {
-		end := i + lineLen
-		if end > len(sig) {
-			end = len(sig)
-		}
-		if i > 0 {
-			result.WriteString("\r\n\t")
-		}
-		result.WriteString(sig[i:end])
-	}
-
-	return result.String()
+	return dkim.FoldSignature(sig)
 }
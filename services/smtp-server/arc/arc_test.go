@@ -1,8 +1,13 @@
 package arc
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
 	"testing"
 
 	"go.uber.org/zap"
@@ -550,6 +555,167 @@ func TestChainResult_Fields(t *testing.T) {
 	}
 }
 
+// mockDNSResolver serves DKIM key records for verification tests
+type mockDNSResolver struct {
+	records map[string][]string
+}
+
+func (m *mockDNSResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if records, ok := m.records[name]; ok {
+		return records, nil
+	}
+	return nil, fmt.Errorf("no records found for %s", name)
+}
+
+// newTestKey generates an RSA key for domainName and publishes it in resolver
+func newTestKey(t *testing.T, resolver *mockDNSResolver, domainName, selector string) *domain.DKIMKey {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+
+	resolver.records[selector+"._domainkey."+domainName] = []string{
+		"v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der),
+	}
+	return &domain.DKIMKey{Selector: selector, Algorithm: "rsa-sha256", PrivateKey: privateKey}
+}
+
+func TestSignAndVerifyChain(t *testing.T) {
+	logger := zap.NewNop()
+	resolver := &mockDNSResolver{records: map[string][]string{}}
+	provider := &mockKeyProvider{keys: map[string]*domain.DKIMKey{
+		"lists.example.com":   newTestKey(t, resolver, "lists.example.com", "arc"),
+		"forward.example.org": newTestKey(t, resolver, "forward.example.org", "sel1"),
+	}}
+	verifier := NewVerifierWithResolver(logger, resolver)
+
+	message := []byte("From: sender@example.net\r\nTo: list@lists.example.com\r\nSubject: Test\r\nDate: Mon, 01 Jan 2024 00:00:00 +0000\r\n\r\nThis is the body.\r\n")
+
+	first, err := NewSigner(provider, "mx.lists.example.com", logger).
+		SignMessage("lists.example.com", message, []AuthResult{{Method: "spf", Result: "pass"}}, ChainValidationNone, nil)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+
+	result, err := verifier.VerifyChain(first)
+	if err != nil {
+		t.Fatalf("VerifyChain() error = %v", err)
+	}
+	if result.Validation != ChainValidationPass {
+		t.Fatalf("Validation = %v (%v), want pass", result.Validation, result.Error)
+	}
+
+	// A second hop continues the chain at i=2 and seals the first
+	second, err := NewSigner(provider, "mx.forward.example.org", logger).
+		SignMessage("forward.example.org", first, nil, result.Validation, nil)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	if !strings.HasPrefix(string(second), "ARC-Seal: i=2; ") || !contains(string(second), "cv=pass") {
+		t.Errorf("Second hop did not add instance 2 with cv=pass:\n%s", second)
+	}
+
+	result, err = verifier.VerifyChain(second)
+	if err != nil {
+		t.Fatalf("VerifyChain() error = %v", err)
+	}
+	if result.Validation != ChainValidationPass || result.HighestValid != 2 || result.TotalSets != 2 {
+		t.Errorf("Result = %+v (%v), want pass with 2 sets", result, result.Error)
+	}
+}
+
+func TestVerifyChain_ModifiedMessage(t *testing.T) {
+	logger := zap.NewNop()
+	resolver := &mockDNSResolver{records: map[string][]string{}}
+	provider := &mockKeyProvider{keys: map[string]*domain.DKIMKey{
+		"example.com": newTestKey(t, resolver, "example.com", "arc"),
+	}}
+	verifier := NewVerifierWithResolver(logger, resolver)
+
+	message := []byte("From: sender@example.net\r\nTo: user@example.com\r\nSubject: Test\r\n\r\nOriginal body.\r\n")
+	signed, err := NewSigner(provider, "mx.example.com", logger).
+		SignMessage("example.com", message, nil, ChainValidationNone, nil)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		message []byte
+	}{
+		{"modified body", []byte(strings.Replace(string(signed), "Original body.", "Altered body.", 1))},
+		{"modified seal", []byte(strings.Replace(string(signed), "cv=none", "cv=pass", 1))},
+		{"missing instance", []byte(strings.Replace(string(signed), "i=1;", "i=2;", 3))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := verifier.VerifyChain(tt.message)
+			if err != nil {
+				t.Fatalf("VerifyChain() error = %v", err)
+			}
+			if result.Validation != ChainValidationFail {
+				t.Errorf("Validation = %v, want fail", result.Validation)
+			}
+		})
+	}
+}
+
+func TestSealForwarded(t *testing.T) {
+	logger := zap.NewNop()
+	resolver := &mockDNSResolver{records: map[string][]string{}}
+	provider := &mockKeyProvider{keys: map[string]*domain.DKIMKey{
+		"example.com": newTestKey(t, resolver, "example.com", "arc"),
+	}}
+	signer := NewSigner(provider, "mx.example.com", logger)
+
+	message := []byte("Authentication-Results: mx.example.com; spf=pass smtp.mailfrom=a@example.net; dkim=fail header.d=example.net header.s=s1; arc=none\r\n" +
+		"Authentication-Results: mx.attacker.test; spf=pass\r\n" +
+		"From: a@example.net\r\nTo: alias@example.com\r\nSubject: Test\r\n\r\nBody\r\n")
+
+	sealed, err := signer.SealForwarded("example.com", message)
+	if err != nil {
+		t.Fatalf("SealForwarded() error = %v", err)
+	}
+
+	aar := strings.SplitN(string(sealed), "\r\nFrom:", 2)[0]
+	for _, want := range []string{"i=1; mx.example.com", "arc=none", "spf=pass smtp.mailfrom=a@example.net", "dkim=fail header.d=example.net header.s=s1"} {
+		if !contains(aar, want) {
+			t.Errorf("ARC headers missing %q:\n%s", want, aar)
+		}
+	}
+
+	result, err := NewVerifierWithResolver(logger, resolver).VerifyChain(sealed)
+	if err != nil {
+		t.Fatalf("VerifyChain() error = %v", err)
+	}
+	if result.Validation != ChainValidationPass {
+		t.Errorf("Validation = %v (%v), want pass", result.Validation, result.Error)
+	}
+}
+
+func TestParseAuthenticationResults(t *testing.T) {
+	id, results := ParseAuthenticationResults("mx.example.com; spf=pass smtp.mailfrom=a@b.test; dkim=fail (bad signature) header.d=b.test; arc=pass")
+	if id != "mx.example.com" {
+		t.Errorf("authserv-id = %q", id)
+	}
+	if len(results) != 3 {
+		t.Fatalf("len(results) = %d, want 3", len(results))
+	}
+	if results[1].Method != "dkim" || results[1].Result != "fail" || results[1].Reason != "bad signature" || results[1].Properties["header.d"] != "b.test" {
+		t.Errorf("dkim result = %+v", results[1])
+	}
+	if results[2].Method != "arc" || results[2].Result != "pass" {
+		t.Errorf("arc result = %+v", results[2])
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsSubstr(s, substr))
@@ -6,7 +6,6 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
-	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -71,20 +70,43 @@ type LoginAttemptParams struct {
 
 // Config holds authentication configuration
 type Config struct {
-	MaxFailedAttempts int           // Maximum failed attempts before lockout
-	LockoutDuration   time.Duration // How long to lock out after max failures
+	MaxFailedAttempts int           // Maximum failed attempts for a username before it is blocked
+	LockoutDuration   time.Duration // How long the first block lasts
 	RateLimitWindow   time.Duration // Window for rate limiting (e.g., 15 minutes)
+
+	// Brute-force protection; zero values use the defaults below
+	MaxIPFailedAttempts int           // Maximum failed attempts from an IP before it is blocked (default 3x MaxFailedAttempts)
+	MaxLockoutDuration  time.Duration // Cap on blocks, which double with each further failure (default 24h)
+	IPUsernameThreshold int           // Distinct usernames failed from an IP after which a success no longer clears it (default 3)
 }
 
 // DefaultConfig returns the default auth configuration
 func DefaultConfig() *Config {
 	return &Config{
-		MaxFailedAttempts: 5,
-		LockoutDuration:   15 * time.Minute,
-		RateLimitWindow:   15 * time.Minute,
+		MaxFailedAttempts:   5,
+		LockoutDuration:     15 * time.Minute,
+		RateLimitWindow:     15 * time.Minute,
+		MaxIPFailedAttempts: 15,
+		MaxLockoutDuration:  24 * time.Hour,
+		IPUsernameThreshold: 3,
 	}
 }
 
+// withDefaults returns a copy of the config with unset brute-force settings filled in
+func (c *Config) withDefaults() *Config {
+	cfg := *c
+	if cfg.MaxIPFailedAttempts == 0 {
+		cfg.MaxIPFailedAttempts = cfg.MaxFailedAttempts * 3 // More lenient for IPs (NAT scenarios)
+	}
+	if cfg.MaxLockoutDuration == 0 {
+		cfg.MaxLockoutDuration = 24 * time.Hour
+	}
+	if cfg.IPUsernameThreshold == 0 {
+		cfg.IPUsernameThreshold = 3
+	}
+	return &cfg
+}
+
 // Authenticator handles SMTP authentication
 type Authenticator struct {
 	repo   Repository
@@ -102,7 +124,7 @@ func NewAuthenticator(repo Repository, redisClient *redis.Client, logger *zap.Lo
 	return &Authenticator{
 		repo:   repo,
 		redis:  redisClient,
-		config: config,
+		config: config.withDefaults(),
 		logger: logger,
 	}
 }
@@ -115,7 +137,7 @@ func NewAuthenticatorWithOAuth2(repo Repository, redisClient *redis.Client, logg
 	a := &Authenticator{
 		repo:   repo,
 		redis:  redisClient,
-		config: config,
+		config: config.withDefaults(),
 		logger: logger,
 	}
 	if oauth2Config != nil && oauth2Config.Enabled {
@@ -224,18 +246,19 @@ func (a *Authenticator) AuthenticateLoginStep(ctx context.Context, state *LoginA
 func (a *Authenticator) authenticate(ctx context.Context, email, password string, clientIP net.IP) (*AuthResult, error) {
 	ipStr := clientIP.String()
 
+	// Normalize email so rate limits apply however the username is written
+	email = strings.ToLower(strings.TrimSpace(email))
+
 	// Check rate limiting first
 	if err := a.checkRateLimit(ctx, email, ipStr); err != nil {
 		a.logger.Warn("Authentication rate limited",
 			zap.String("email", maskEmail(email)),
-			zap.String("client_ip", ipStr))
+			zap.String("client_ip", ipStr),
+			zap.Error(err))
 		a.recordAttempt(ctx, nil, email, ipStr, false, "rate_limited", "smtp")
 		return nil, err
 	}
 
-	// Normalize email
-	email = strings.ToLower(strings.TrimSpace(email))
-
 	// Look up user by email
 	user, err := a.repo.GetUserByEmail(ctx, email)
 	if err != nil {
@@ -301,7 +324,8 @@ func (a *Authenticator) authenticate(ctx context.Context, email, password string
 		return nil, ErrInvalidCredentials
 	}
 
-	// Success! Clear rate limit counters
+	// Success! Clear the username's failures, and the IP's unless it has
+	// been trying many usernames
 	a.clearRateLimitCounters(ctx, email, ipStr)
 
 	// Update login success in database
@@ -326,69 +350,6 @@ func (a *Authenticator) authenticate(ctx context.Context, email, password string
 	}, nil
 }
 
-// checkRateLimit checks if the email or IP is rate limited
-func (a *Authenticator) checkRateLimit(ctx context.Context, email, ipStr string) error {
-	if a.redis == nil {
-		return nil // Skip rate limiting if Redis not available
-	}
-
-	// Check email-based rate limit
-	emailKey := fmt.Sprintf("smtp:auth:fail:email:%s", email)
-	emailCount, err := a.redis.Get(ctx, emailKey).Int()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		a.logger.Error("Failed to check email rate limit", zap.Error(err))
-	}
-	if emailCount >= a.config.MaxFailedAttempts {
-		return ErrRateLimited
-	}
-
-	// Check IP-based rate limit
-	ipKey := fmt.Sprintf("smtp:auth:fail:ip:%s", ipStr)
-	ipCount, err := a.redis.Get(ctx, ipKey).Int()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		a.logger.Error("Failed to check IP rate limit", zap.Error(err))
-	}
-	if ipCount >= a.config.MaxFailedAttempts*3 { // More lenient for IPs (NAT scenarios)
-		return ErrRateLimited
-	}
-
-	return nil
-}
-
-// incrementFailureCount increments the failure counters
-func (a *Authenticator) incrementFailureCount(ctx context.Context, email, ipStr string) {
-	if a.redis == nil {
-		return
-	}
-
-	emailKey := fmt.Sprintf("smtp:auth:fail:email:%s", email)
-	ipKey := fmt.Sprintf("smtp:auth:fail:ip:%s", ipStr)
-
-	pipe := a.redis.Pipeline()
-	pipe.Incr(ctx, emailKey)
-	pipe.Expire(ctx, emailKey, a.config.LockoutDuration)
-	pipe.Incr(ctx, ipKey)
-	pipe.Expire(ctx, ipKey, a.config.LockoutDuration)
-
-	if _, err := pipe.Exec(ctx); err != nil {
-		a.logger.Error("Failed to increment failure counters", zap.Error(err))
-	}
-}
-
-// clearRateLimitCounters clears rate limit counters on successful auth
-func (a *Authenticator) clearRateLimitCounters(ctx context.Context, email, ipStr string) {
-	if a.redis == nil {
-		return
-	}
-
-	emailKey := fmt.Sprintf("smtp:auth:fail:email:%s", email)
-	ipKey := fmt.Sprintf("smtp:auth:fail:ip:%s", ipStr)
-
-	if err := a.redis.Del(ctx, emailKey, ipKey).Err(); err != nil {
-		a.logger.Error("Failed to clear rate limit counters", zap.Error(err))
-	}
-}
-
 // recordAttempt records a login attempt for audit purposes
 func (a *Authenticator) recordAttempt(ctx context.Context, userID *string, email, ipStr string, success bool, failReason, method string) {
 	if err := a.repo.RecordLoginAttempt(ctx, LoginAttemptParams{
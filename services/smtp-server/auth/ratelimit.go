@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Rate limit scopes reported by BlockedError
+const (
+	ScopeUsername = "username"
+	ScopeIP       = "ip"
+)
+
+// BlockedError is returned while authentication for a username or from an
+// IP is temporarily blocked after repeated failures. It matches
+// ErrRateLimited.
+type BlockedError struct {
+	Scope      string        // ScopeUsername or ScopeIP
+	RetryAfter time.Duration // Time left on the block
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%s: %s blocked for %s", ErrRateLimited, e.Scope, e.RetryAfter.Round(time.Second))
+}
+
+// Is reports whether target is ErrRateLimited
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+func failKey(scope, subject string) string {
+	return fmt.Sprintf("smtp:auth:fail:%s:%s", scope, subject)
+}
+
+func blockKey(scope, subject string) string {
+	return fmt.Sprintf("smtp:auth:block:%s:%s", scope, subject)
+}
+
+// ipUsernamesKey holds the usernames that failed from an IP
+func ipUsernamesKey(ipStr string) string {
+	return fmt.Sprintf("smtp:auth:ip:usernames:%s", ipStr)
+}
+
+// checkRateLimit returns a BlockedError if the email or IP is blocked
+func (a *Authenticator) checkRateLimit(ctx context.Context, email, ipStr string) error {
+	if a.redis == nil {
+		return nil // Skip rate limiting if Redis not available
+	}
+
+	for _, check := range []struct{ scope, subject string }{
+		{ScopeUsername, email},
+		{ScopeIP, ipStr},
+	} {
+		ttl, err := a.redis.PTTL(ctx, blockKey(check.scope, check.subject)).Result()
+		if err != nil {
+			a.logger.Error("Failed to check rate limit", zap.String("scope", check.scope), zap.Error(err))
+			continue
+		}
+		// PTTL is negative when the key doesn't exist or has no expiry
+		if ttl > 0 {
+			return &BlockedError{Scope: check.scope, RetryAfter: ttl}
+		}
+	}
+
+	return nil
+}
+
+// incrementFailureCount counts a failure against the email and the IP, and
+// blocks either once it reaches its limit
+func (a *Authenticator) incrementFailureCount(ctx context.Context, email, ipStr string) {
+	if a.redis == nil {
+		return
+	}
+
+	emailKey := failKey(ScopeUsername, email)
+	ipKey := failKey(ScopeIP, ipStr)
+	usernamesKey := ipUsernamesKey(ipStr)
+
+	pipe := a.redis.Pipeline()
+	emailCount := pipe.Incr(ctx, emailKey)
+	ipCount := pipe.Incr(ctx, ipKey)
+	pipe.SAdd(ctx, usernamesKey, email)
+	pipe.Expire(ctx, usernamesKey, a.config.RateLimitWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		a.logger.Error("Failed to increment failure counters", zap.Error(err))
+		return
+	}
+
+	a.applyBackoff(ctx, ScopeUsername, email, emailKey, emailCount.Val(), a.config.MaxFailedAttempts)
+	a.applyBackoff(ctx, ScopeIP, ipStr, ipKey, ipCount.Val(), a.config.MaxIPFailedAttempts)
+}
+
+// applyBackoff keeps a failure counter alive and blocks its subject once the
+// count reaches the limit
+func (a *Authenticator) applyBackoff(ctx context.Context, scope, subject, counterKey string, count int64, limit int) {
+	block := blockDuration(count, limit, a.config.LockoutDuration, a.config.MaxLockoutDuration)
+
+	// Remember failures past the end of the block so the next one backs off further
+	pipe := a.redis.Pipeline()
+	pipe.Expire(ctx, counterKey, block+a.config.RateLimitWindow)
+	if block > 0 {
+		pipe.Set(ctx, blockKey(scope, subject), count, block)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		a.logger.Error("Failed to apply authentication backoff", zap.String("scope", scope), zap.Error(err))
+		return
+	}
+
+	if block > 0 {
+		a.logger.Warn("Authentication blocked after repeated failures",
+			zap.String("scope", scope),
+			zap.Int64("failures", count),
+			zap.Duration("duration", block))
+	}
+}
+
+// blockDuration returns how long to block after count failures: nothing
+// below the limit, then base doubling with each further failure, up to max
+func blockDuration(count int64, limit int, base, max time.Duration) time.Duration {
+	if limit <= 0 || count < int64(limit) {
+		return 0
+	}
+
+	block := base
+	for i := int64(limit); i < count && block < max; i++ {
+		block *= 2
+	}
+	if block > max {
+		block = max
+	}
+	return block
+}
+
+// clearRateLimitCounters clears the email's failures after a successful auth.
+// The IP's failures are kept if it has failed for many usernames, so one
+// valid login doesn't reset a credential-stuffing run.
+func (a *Authenticator) clearRateLimitCounters(ctx context.Context, email, ipStr string) {
+	if a.redis == nil {
+		return
+	}
+
+	keys := []string{failKey(ScopeUsername, email), blockKey(ScopeUsername, email)}
+
+	usernames, err := a.redis.SMembers(ctx, ipUsernamesKey(ipStr)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		a.logger.Error("Failed to check IP usernames", zap.Error(err))
+	}
+	others := 0
+	for _, u := range usernames {
+		if u != email {
+			others++
+		}
+	}
+	if others < a.config.IPUsernameThreshold {
+		keys = append(keys, failKey(ScopeIP, ipStr), ipUsernamesKey(ipStr))
+	} else {
+		a.logger.Warn("Keeping IP failure count after successful auth",
+			zap.String("client_ip", ipStr),
+			zap.Int("failed_usernames", others))
+	}
+
+	if err := a.redis.Del(ctx, keys...).Err(); err != nil {
+		a.logger.Error("Failed to clear rate limit counters", zap.Error(err))
+	}
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func newRateLimitTestAuthenticator(t *testing.T, config *Config) (*Authenticator, *MockRepository, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	repo := NewMockRepository()
+	repo.AddUser("test@example.com", &User{
+		ID:           "user-123",
+		Email:        "test@example.com",
+		PasswordHash: hashPassword("correct-password"),
+		Status:       "active",
+	})
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewAuthenticator(repo, redisClient, zap.NewNop(), config), repo, mr
+}
+
+func TestBlockDuration(t *testing.T) {
+	base := 15 * time.Minute
+
+	tests := []struct {
+		count int64
+		want  time.Duration
+	}{
+		{4, 0},
+		{5, 15 * time.Minute},
+		{6, 30 * time.Minute},
+		{7, time.Hour},
+		{12, 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := blockDuration(tt.count, 5, base, 2*time.Hour); got != tt.want {
+			t.Errorf("blockDuration(%d) = %v, want %v", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimit_BlocksUsername(t *testing.T) {
+	a, _, mr := newRateLimitTestAuthenticator(t, &Config{
+		MaxFailedAttempts: 3,
+		LockoutDuration:   time.Minute,
+		RateLimitWindow:   15 * time.Minute,
+	})
+	ctx := context.Background()
+	ip := net.ParseIP("192.0.2.1")
+
+	for i := 0; i < 3; i++ {
+		if _, err := a.AuthenticatePlainCredentials(ctx, "Test@Example.com", "wrong", ip, true); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+
+	// Blocked even with the right password, from another IP
+	_, err := a.AuthenticatePlainCredentials(ctx, "test@example.com", "correct-password", net.ParseIP("192.0.2.2"), true)
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) || blocked.Scope != ScopeUsername || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected username block, got %v", err)
+	}
+
+	// The block expires, and the next failure blocks for twice as long
+	mr.FastForward(time.Minute + time.Second)
+	if _, err := a.AuthenticatePlainCredentials(ctx, "test@example.com", "wrong", ip, true); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials after block expired, got %v", err)
+	}
+	if ttl := mr.TTL(blockKey(ScopeUsername, "test@example.com")); ttl != 2*time.Minute {
+		t.Errorf("second block TTL = %v, want %v", ttl, 2*time.Minute)
+	}
+}
+
+func TestRateLimit_BlocksIP(t *testing.T) {
+	a, _, _ := newRateLimitTestAuthenticator(t, &Config{
+		MaxFailedAttempts: 3,
+		LockoutDuration:   time.Minute,
+		RateLimitWindow:   15 * time.Minute,
+	})
+	ctx := context.Background()
+	ip := net.ParseIP("198.51.100.7")
+
+	// Spread across usernames so no single username is blocked
+	for i := 0; i < 9; i++ {
+		a.AuthenticatePlainCredentials(ctx, fmt.Sprintf("user%d@example.com", i), "wrong", ip, true)
+	}
+
+	_, err := a.AuthenticatePlainCredentials(ctx, "test@example.com", "correct-password", ip, true)
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) || blocked.Scope != ScopeIP {
+		t.Fatalf("expected IP block, got %v", err)
+	}
+}
+
+func TestRateLimit_SuccessClearsUsername(t *testing.T) {
+	a, _, mr := newRateLimitTestAuthenticator(t, &Config{
+		MaxFailedAttempts: 5,
+		LockoutDuration:   time.Minute,
+		RateLimitWindow:   15 * time.Minute,
+	})
+	ctx := context.Background()
+	ip := net.ParseIP("192.0.2.1")
+
+	a.AuthenticatePlainCredentials(ctx, "test@example.com", "wrong", ip, true)
+	if _, err := a.AuthenticatePlainCredentials(ctx, "test@example.com", "correct-password", ip, true); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	if mr.Exists(failKey(ScopeUsername, "test@example.com")) {
+		t.Error("username failure count was not cleared")
+	}
+	if mr.Exists(failKey(ScopeIP, "192.0.2.1")) {
+		t.Error("IP failure count was not cleared for a single-user IP")
+	}
+}
+
+func TestRateLimit_SuccessKeepsAbusiveIP(t *testing.T) {
+	a, _, mr := newRateLimitTestAuthenticator(t, &Config{
+		MaxFailedAttempts:   5,
+		LockoutDuration:     time.Minute,
+		RateLimitWindow:     15 * time.Minute,
+		IPUsernameThreshold: 3,
+	})
+	ctx := context.Background()
+	ip := net.ParseIP("203.0.113.9")
+
+	for i := 0; i < 3; i++ {
+		a.AuthenticatePlainCredentials(ctx, fmt.Sprintf("victim%d@example.com", i), "guess", ip, true)
+	}
+	if _, err := a.AuthenticatePlainCredentials(ctx, "test@example.com", "correct-password", ip, true); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	count, err := mr.Get(failKey(ScopeIP, "203.0.113.9"))
+	if err != nil || count != "3" {
+		t.Errorf("IP failure count = %q (%v), want it kept at 3", count, err)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -20,6 +21,17 @@ type Config struct {
 	Metrics   MetricsConfig   `yaml:"metrics"`
 	Logging   LoggingConfig   `yaml:"logging"`
 	Scanner   ScannerConfig   `yaml:"scanner"`
+	Events    EventsConfig    `yaml:"events"`
+	Greylist  GreylistConfig  `yaml:"greylist"`
+	LMTP      LMTPConfig      `yaml:"lmtp"`
+	Sieve     SieveConfig     `yaml:"sieve"`
+	Vacation  VacationConfig  `yaml:"vacation"`
+	MTASTS    MTASTSConfig    `yaml:"mta_sts"`
+
+	ReadReceipts ReadReceiptsConfig `yaml:"read_receipts"`
+	InboundParse InboundParseConfig `yaml:"inbound_parse"`
+
+	ProxyProtocol ProxyProtocolConfig `yaml:"proxy_protocol"`
 }
 
 // ServerConfig holds SMTP server settings
@@ -39,6 +51,16 @@ type ServerConfig struct {
 	DefaultDomain     string        `yaml:"default_domain"`
 	SMTPAddr          string        `yaml:"smtp_addr"`
 	SubmissionAddr    string        `yaml:"submission_addr"`
+	EnableDSN         bool          `yaml:"enable_dsn"` // Advertise DSN (RFC 3461) in EHLO
+
+	// ShutdownTimeout is how long transactions in progress are given to
+	// finish on shutdown before their connections are closed
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// SubmissionMaxMessageSize is the SIZE advertised on the submission port.
+	// Authenticated senders are held to their domain's max_message_size
+	// policy, which may be larger than MaxMessageSize but not than this.
+	SubmissionMaxMessageSize int64 `yaml:"submission_max_message_size"`
 }
 
 // DatabaseConfig holds PostgreSQL settings
@@ -78,6 +100,18 @@ type QueueConfig struct {
 	StaleMessageAge    time.Duration `yaml:"stale_message_age"`
 	StoragePath        string        `yaml:"storage_path"`
 	MaxRetries         int           `yaml:"max_retries"`
+
+	// Outbound connection pooling
+	ConnMaxMessages    int           `yaml:"conn_max_messages"`  // Messages sent over one connection before it is closed
+	ConnIdleTimeout    time.Duration `yaml:"conn_idle_timeout"`  // How long an idle connection is kept open
+	MaxConnsPerHost    int           `yaml:"max_conns_per_host"` // Concurrent connections to a single MX host
+
+	// Weighted priority scheduling: out of every high+normal+low messages
+	// taken from the queue, this many come from each priority when it has
+	// messages waiting
+	HighPriorityWeight   int `yaml:"high_priority_weight"`
+	NormalPriorityWeight int `yaml:"normal_priority_weight"`
+	LowPriorityWeight    int `yaml:"low_priority_weight"`
 }
 
 // DKIMConfig holds DKIM settings
@@ -135,6 +169,95 @@ type ScannerConfig struct {
 	QuarantineDir  string        `yaml:"quarantine_dir"`  // directory for quarantined messages
 }
 
+// EventsConfig holds delivery event reporting to the transactional API
+type EventsConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Endpoint       string        `yaml:"endpoint"`        // e.g. http://transactional-api:8080/internal/events
+	InternalSecret string        `yaml:"internal_secret"` // sent as X-Internal-Secret
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+// InboundParseConfig holds the hand-over of mail for inbound parse domains
+// to the transactional API, which parses it and posts it to customer URLs.
+// Mail for Domains, or any subdomain of them, is accepted without a local
+// mailbox and passed to Endpoint instead of being queued.
+type InboundParseConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Domains        []string      `yaml:"domains"`
+	Endpoint       string        `yaml:"endpoint"`        // e.g. http://transactional-api:8080/internal/inbound
+	InternalSecret string        `yaml:"internal_secret"` // sent as X-Internal-Secret
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+// GreylistConfig holds inbound greylisting settings. Greylisting applies to
+// unauthenticated mail for local recipients, either for every domain when
+// Enabled is set or for domains whose policy turns it on.
+type GreylistConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	Delay             time.Duration `yaml:"delay"`              // Minimum wait before a retry is accepted
+	RetryWindow       time.Duration `yaml:"retry_window"`       // How long after first contact a retry is accepted
+	WhitelistLifetime time.Duration `yaml:"whitelist_lifetime"` // How long a triplet passes once it has retried
+	BypassSPFPass     bool          `yaml:"bypass_spf_pass"`    // Skip greylisting when the sender passes SPF
+	Allowlist         []string      `yaml:"allowlist"`          // IPs, CIDR networks, sender domains or addresses never greylisted
+}
+
+// LMTPConfig holds the LMTP (RFC 2033) listener used by internal components
+// to inject mail into local mailboxes. LMTP is unauthenticated, so it only
+// binds to a unix socket ("unix:/path/to/socket") or a loopback address.
+type LMTPConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+}
+
+// SieveConfig holds server-side Sieve filtering of local delivery. Scripts
+// are managed over HTTP on the metrics listener, which requires
+// InternalSecret as X-Internal-Secret.
+type SieveConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	MaxScriptSize  int    `yaml:"max_script_size"` // Bytes
+	InternalSecret string `yaml:"internal_secret"`
+}
+
+// VacationConfig holds out-of-office auto-replies on local delivery.
+// Responders are managed over HTTP on the metrics listener, which requires
+// InternalSecret as X-Internal-Secret.
+type VacationConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	MaxBodySize    int    `yaml:"max_body_size"` // Bytes
+	InternalSecret string `yaml:"internal_secret"`
+}
+
+// ReadReceiptsConfig holds read receipts (RFC 8098 MDNs). Requests are
+// recorded on local delivery and sent once the IMAP server reports the
+// message displayed, if the user's policy allows. Policies are managed over
+// HTTP on the metrics listener, which requires InternalSecret as
+// X-Internal-Secret.
+type ReadReceiptsConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	PollInterval   time.Duration `yaml:"poll_interval"` // How often displayed messages are checked
+	InternalSecret string        `yaml:"internal_secret"`
+}
+
+// MTASTSConfig holds MTA-STS (RFC 8461) enforcement on outbound delivery and
+// the TLS-RPT (RFC 8460) reports sent to destinations whose policy failed
+type MTASTSConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	FetchTimeout     time.Duration `yaml:"fetch_timeout"`
+	ReportInterval   time.Duration `yaml:"report_interval"`
+	OrganizationName string        `yaml:"organization_name"`
+	ContactInfo      string        `yaml:"contact_info"` // Defaults to postmaster@ the default domain
+}
+
+// ProxyProtocolConfig holds PROXY protocol v2 parsing on the SMTP and
+// submission listeners, for running behind an L4 load balancer. Connections
+// from TrustedProxies must start with a PROXY header, whose client address
+// replaces the balancer's. A PROXY header from any other source is rejected.
+type ProxyProtocolConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	TrustedProxies []string      `yaml:"trusted_proxies"` // CIDR networks of the load balancers
+	HeaderTimeout  time.Duration `yaml:"header_timeout"`  // How long a trusted proxy has to send the header
+}
+
 // Load loads configuration from file or environment
 func Load(path string) (*Config, error) {
 	cfg := DefaultConfig()
@@ -178,6 +301,10 @@ func DefaultConfig() *Config {
 			DefaultDomain:     "example.com",
 			SMTPAddr:          "0.0.0.0:25",
 			SubmissionAddr:    "0.0.0.0:587",
+			EnableDSN:         false,
+			ShutdownTimeout:   30 * time.Second,
+
+			SubmissionMaxMessageSize: 104857600, // 100MB - the largest domain policy allowed
 		},
 		Database: DatabaseConfig{
 			Host:            "localhost",
@@ -211,6 +338,13 @@ func DefaultConfig() *Config {
 			StaleMessageAge:   7 * 24 * time.Hour,
 			StoragePath:       "/var/spool/smtp",
 			MaxRetries:        5,
+			ConnMaxMessages:   50,
+			ConnIdleTimeout:   30 * time.Second,
+			MaxConnsPerHost:   5,
+
+			HighPriorityWeight:   6,
+			NormalPriorityWeight: 3,
+			LowPriorityWeight:    1,
 		},
 		DKIM: DKIMConfig{
 			KeysPath:        "/etc/smtp/dkim",
@@ -253,6 +387,46 @@ func DefaultConfig() *Config {
 			RejectInfected: true,
 			QuarantineDir:  "/var/quarantine/mail",
 		},
+		Events: EventsConfig{
+			Enabled: false,
+			Timeout: 10 * time.Second,
+		},
+		Greylist: GreylistConfig{
+			Enabled:           false,
+			Delay:             5 * time.Minute,
+			RetryWindow:       4 * time.Hour,
+			WhitelistLifetime: 35 * 24 * time.Hour,
+			BypassSPFPass:     true,
+		},
+		LMTP: LMTPConfig{
+			Enabled: false,
+			Addr:    "127.0.0.1:24",
+		},
+		Sieve: SieveConfig{
+			Enabled:       true,
+			MaxScriptSize: 65536,
+		},
+		Vacation: VacationConfig{
+			Enabled:     true,
+			MaxBodySize: 16384,
+		},
+		MTASTS: MTASTSConfig{
+			Enabled:        true,
+			FetchTimeout:   30 * time.Second,
+			ReportInterval: 24 * time.Hour,
+		},
+		ReadReceipts: ReadReceiptsConfig{
+			Enabled:      true,
+			PollInterval: 15 * time.Second,
+		},
+		InboundParse: InboundParseConfig{
+			Enabled: false,
+			Timeout: 60 * time.Second,
+		},
+		ProxyProtocol: ProxyProtocolConfig{
+			Enabled:       false,
+			HeaderTimeout: 5 * time.Second,
+		},
 	}
 }
 
@@ -280,6 +454,14 @@ func (c *Config) loadFromEnv() {
 			c.Server.MaxMessageSize = size
 		}
 	}
+	if v := os.Getenv("SMTP_SUBMISSION_MAX_MESSAGE_SIZE"); v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil {
+			c.Server.SubmissionMaxMessageSize = size
+		}
+	}
+	if v := os.Getenv("SMTP_ENABLE_DSN"); v != "" {
+		c.Server.EnableDSN = v == "true" || v == "1"
+	}
 
 	// Database
 	if v := os.Getenv("DB_HOST"); v != "" {
@@ -365,6 +547,89 @@ func (c *Config) loadFromEnv() {
 	if v := os.Getenv("LOG_FORMAT"); v != "" {
 		c.Logging.Format = v
 	}
+
+	// Delivery events
+	if v := os.Getenv("EVENTS_ENDPOINT"); v != "" {
+		c.Events.Endpoint = v
+		c.Events.Enabled = true
+	}
+	if v := os.Getenv("INTERNAL_API_SECRET"); v != "" {
+		c.Events.InternalSecret = v
+		c.Sieve.InternalSecret = v
+		c.ReadReceipts.InternalSecret = v
+		c.InboundParse.InternalSecret = v
+	}
+
+	// Inbound parse
+	if v := os.Getenv("INBOUND_PARSE_ENDPOINT"); v != "" {
+		c.InboundParse.Endpoint = v
+		c.InboundParse.Enabled = true
+	}
+	if v := os.Getenv("INBOUND_PARSE_DOMAINS"); v != "" {
+		c.InboundParse.Domains = strings.Split(v, ",")
+	}
+
+	// PROXY protocol
+	if v := os.Getenv("PROXY_PROTOCOL_ENABLED"); v != "" {
+		c.ProxyProtocol.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("PROXY_PROTOCOL_TRUSTED_PROXIES"); v != "" {
+		c.ProxyProtocol.TrustedProxies = strings.Split(v, ",")
+	}
+
+	// Greylisting
+	if v := os.Getenv("GREYLIST_ENABLED"); v != "" {
+		c.Greylist.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GREYLIST_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.Greylist.Delay = d
+		}
+	}
+	if v := os.Getenv("GREYLIST_RETRY_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.Greylist.RetryWindow = d
+		}
+	}
+	if v := os.Getenv("GREYLIST_WHITELIST_LIFETIME"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			c.Greylist.WhitelistLifetime = d
+		}
+	}
+
+	// LMTP
+	if v := os.Getenv("LMTP_ENABLED"); v != "" {
+		c.LMTP.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("LMTP_ADDR"); v != "" {
+		c.LMTP.Addr = v
+	}
+
+	// Sieve
+	if v := os.Getenv("SIEVE_ENABLED"); v != "" {
+		c.Sieve.Enabled = v == "true" || v == "1"
+	}
+
+	// Vacation responders
+	if v := os.Getenv("VACATION_ENABLED"); v != "" {
+		c.Vacation.Enabled = v == "true" || v == "1"
+	}
+
+	// Read receipts
+	if v := os.Getenv("READ_RECEIPTS_ENABLED"); v != "" {
+		c.ReadReceipts.Enabled = v == "true" || v == "1"
+	}
+
+	// MTA-STS
+	if v := os.Getenv("MTA_STS_ENABLED"); v != "" {
+		c.MTASTS.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("TLSRPT_ORGANIZATION_NAME"); v != "" {
+		c.MTASTS.OrganizationName = v
+	}
+	if v := os.Getenv("TLSRPT_CONTACT_INFO"); v != "" {
+		c.MTASTS.ContactInfo = v
+	}
 }
 
 // DSN returns PostgreSQL connection string
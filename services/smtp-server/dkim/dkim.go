@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
@@ -16,6 +17,7 @@ import (
 	"net/mail"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -52,8 +54,14 @@ type SignatureConfig struct {
 	HeaderCanonicalization string
 	// Canonicalization for body (relaxed or simple)
 	BodyCanonicalization string
-	// Body length limit (0 = no limit)
+	// Body length limit (0 = no limit). Only the first BodyLengthLimit
+	// bytes of the canonicalized body are signed.
 	BodyLengthLimit int
+	// SignBodyLength adds an l= tag with the length of the canonicalized
+	// body, so content appended later (such as a mailing list footer) doesn't
+	// break the signature. The appended content is unsigned and could be
+	// anything, so this is opt-in per domain.
+	SignBodyLength bool
 	// Signature expiration (0 = no expiration)
 	ExpireAfter time.Duration
 }
@@ -100,10 +108,15 @@ func (s *Signer) SignMessage(domainName string, message []byte, config *Signatur
 	// Canonicalize body
 	canonBody := canonicalizeBody(body, config.BodyCanonicalization)
 
-	// Apply body length limit if set
+	// Apply body length limit if set. The limit and the l= tag count
+	// canonicalized bytes, which is what verifiers hash.
 	if config.BodyLengthLimit > 0 && len(canonBody) > config.BodyLengthLimit {
 		canonBody = canonBody[:config.BodyLengthLimit]
 	}
+	bodyLength := -1
+	if config.SignBodyLength || config.BodyLengthLimit > 0 {
+		bodyLength = len(canonBody)
+	}
 
 	// Hash body
 	bodyHash := sha256.Sum256(canonBody)
@@ -121,6 +134,7 @@ func (s *Signer) SignMessage(domainName string, message []byte, config *Signatur
 		domainName,
 		config,
 		bodyHashB64,
+		bodyLength,
 		timestamp,
 		expiration,
 		msg.Header,
@@ -129,13 +143,13 @@ func (s *Signer) SignMessage(domainName string, message []byte, config *Signatur
 	// Canonicalize headers for signing
 	headerData := canonicalizeHeaders(msg.Header, config.Headers, config.HeaderCanonicalization)
 
-	// Add DKIM-Signature header without b= value for signing
-	dkimHeader := fmt.Sprintf("dkim-signature:%s", canonicalizeHeaderValue(signatureParams, config.HeaderCanonicalization))
+	// Add DKIM-Signature header with an empty b= value for signing
+	dkimHeader := fmt.Sprintf("dkim-signature:%s", canonicalizeHeaderValue(signatureParams+"b=", config.HeaderCanonicalization))
 	headerData = append(headerData, []byte(dkimHeader)...)
 
 	// Sign the header data
 	headerHash := sha256.Sum256(headerData)
-	signature, err := rsa.SignPKCS1v15(nil, key.PrivateKey, crypto.SHA256, headerHash[:])
+	signature, err := signHeaderHash(key, headerHash[:])
 	if err != nil {
 		return nil, fmt.Errorf("sign message: %w", err)
 	}
@@ -154,19 +168,46 @@ func (s *Signer) SignMessage(domainName string, message []byte, config *Signatur
 	s.logger.Debug("Message signed with DKIM",
 		zap.String("domain", domainName),
 		zap.String("selector", key.Selector),
+		zap.String("algorithm", signingAlgorithm(key)),
 		zap.Int("body_hash_len", len(bodyHashB64)),
+		zap.Int("body_length_tag", bodyLength),
 		zap.Int("signature_len", len(signatureB64)))
 
 	return result.Bytes(), nil
 }
 
-// buildSignatureParams builds the DKIM-Signature parameter string
-func buildSignatureParams(key *domain.DKIMKey, domainName string, config *SignatureConfig, bodyHash string, timestamp, expiration int64, headers mail.Header) string {
+// signingAlgorithm returns the a= tag value for a key
+func signingAlgorithm(key *domain.DKIMKey) string {
+	if key.IsEd25519() {
+		return "ed25519-sha256"
+	}
+	return "rsa-sha256"
+}
+
+// signHeaderHash signs the SHA-256 digest of the canonicalized headers.
+// For Ed25519 the digest itself is the signed message (RFC 8463 section 3).
+func signHeaderHash(key *domain.DKIMKey, headerHash []byte) ([]byte, error) {
+	if key.IsEd25519() {
+		if len(key.Ed25519PrivateKey) != ed25519.PrivateKeySize {
+			return nil, errors.New("missing ed25519 private key")
+		}
+		return ed25519.Sign(key.Ed25519PrivateKey, headerHash), nil
+	}
+
+	if key.PrivateKey == nil {
+		return nil, errors.New("missing rsa private key")
+	}
+	return rsa.SignPKCS1v15(nil, key.PrivateKey, crypto.SHA256, headerHash)
+}
+
+// buildSignatureParams builds the DKIM-Signature parameter string. A
+// bodyLength of -1 leaves out the l= tag.
+func buildSignatureParams(key *domain.DKIMKey, domainName string, config *SignatureConfig, bodyHash string, bodyLength int, timestamp, expiration int64, headers mail.Header) string {
 	// Get list of headers that actually exist in the message
 	signedHeaders := getSignableHeaders(headers, config.Headers)
 
 	params := fmt.Sprintf("v=1; a=%s; c=%s/%s; d=%s; s=%s; t=%d; ",
-		key.Algorithm,
+		signingAlgorithm(key),
 		config.HeaderCanonicalization,
 		config.BodyCanonicalization,
 		domainName,
@@ -178,8 +219,8 @@ func buildSignatureParams(key *domain.DKIMKey, domainName string, config *Signat
 		params += fmt.Sprintf("x=%d; ", expiration)
 	}
 
-	if config.BodyLengthLimit > 0 {
-		params += fmt.Sprintf("l=%d; ", config.BodyLengthLimit)
+	if bodyLength >= 0 {
+		params += fmt.Sprintf("l=%d; ", bodyLength)
 	}
 
 	params += fmt.Sprintf("h=%s; bh=%s; ",
@@ -351,7 +392,7 @@ type PublicKeyCache struct {
 }
 
 type cachedPublicKey struct {
-	publicKey *rsa.PublicKey
+	publicKey crypto.PublicKey
 	record    *DKIMRecord
 	fetchedAt time.Time
 	expiresAt time.Time
@@ -391,11 +432,17 @@ func NewVerifierWithResolver(logger *zap.Logger, resolver DNSResolver) *Verifier
 type VerificationResult struct {
 	Domain    string
 	Selector  string
+	Algorithm string
 	Valid     bool
 	Status    VerificationStatus
 	Error     error
 	Timestamp time.Time
 	Headers   []string
+	// BodyLength is the signature's l= tag, or -1 without one.
+	// UnsignedBodyBytes counts the canonicalized body bytes after it,
+	// content added after signing that the signature doesn't vouch for.
+	BodyLength        int
+	UnsignedBodyBytes int
 }
 
 // VerifyMessage verifies DKIM signatures in a message
@@ -434,7 +481,8 @@ const (
 
 func (v *Verifier) verifySignature(signature string, headers mail.Header, message []byte) *VerificationResult {
 	result := &VerificationResult{
-		Timestamp: time.Now(),
+		Timestamp:  time.Now(),
+		BodyLength: -1,
 	}
 
 	// Parse signature parameters
@@ -442,6 +490,7 @@ func (v *Verifier) verifySignature(signature string, headers mail.Header, messag
 
 	result.Domain = params["d"]
 	result.Selector = params["s"]
+	result.Algorithm = params["a"]
 	if params["h"] != "" {
 		result.Headers = strings.Split(params["h"], ":")
 	}
@@ -463,14 +512,25 @@ func (v *Verifier) verifySignature(signature string, headers mail.Header, messag
 		return result
 	}
 
-	// Check algorithm - support both rsa-sha256 and rsa-sha1
+	// Check algorithm - support rsa-sha256, rsa-sha1 and ed25519-sha256 (RFC 8463)
 	algorithm := params["a"]
-	if algorithm != "rsa-sha256" && algorithm != "rsa-sha1" {
+	if algorithm != "rsa-sha256" && algorithm != "rsa-sha1" && algorithm != "ed25519-sha256" {
 		result.Error = fmt.Errorf("unsupported algorithm: %s", algorithm)
 		result.Status = VerificationPermFail
 		return result
 	}
 
+	// Check body length (l= tag)
+	if params["l"] != "" {
+		bodyLen, err := strconv.Atoi(params["l"])
+		if err != nil || bodyLen < 0 {
+			result.Error = fmt.Errorf("invalid body length: %s", params["l"])
+			result.Status = VerificationPermFail
+			return result
+		}
+		result.BodyLength = bodyLen
+	}
+
 	// Check expiration (x= tag)
 	if params["x"] != "" {
 		expiration, err := parseTimestamp(params["x"])
@@ -519,6 +579,13 @@ func (v *Verifier) verifySignature(signature string, headers mail.Header, messag
 		return result
 	}
 
+	// The key type in DNS must match the signing algorithm
+	if record != nil && !strings.HasPrefix(algorithm, record.KeyType+"-") {
+		result.Error = fmt.Errorf("key type %s does not match algorithm %s", record.KeyType, algorithm)
+		result.Status = VerificationPermFail
+		return result
+	}
+
 	// Check key flags
 	if record != nil && strings.Contains(record.Flags, "s") {
 		// Strict mode - domain must match exactly
@@ -548,19 +615,21 @@ func (v *Verifier) verifySignature(signature string, headers mail.Header, messag
 	// Canonicalize body
 	canonBody := canonicalizeBody(body, bodyCanon)
 
-	// Apply body length limit if specified
-	if params["l"] != "" {
-		var bodyLen int
-		if _, err := fmt.Sscanf(params["l"], "%d", &bodyLen); err == nil && bodyLen >= 0 {
-			if len(canonBody) > bodyLen {
-				canonBody = canonBody[:bodyLen]
-			}
+	// Only the first l= bytes of the canonicalized body are signed; a body
+	// shorter than that was cut after signing (RFC 6376 section 3.4.5)
+	if result.BodyLength >= 0 {
+		if len(canonBody) < result.BodyLength {
+			result.Error = fmt.Errorf("body is shorter than the signed length %d", result.BodyLength)
+			result.Status = VerificationPermFail
+			return result
 		}
+		result.UnsignedBodyBytes = len(canonBody) - result.BodyLength
+		canonBody = canonBody[:result.BodyLength]
 	}
 
 	// Verify body hash
 	var bodyHash []byte
-	if algorithm == "rsa-sha256" {
+	if algorithm == "rsa-sha256" || algorithm == "ed25519-sha256" {
 		h := sha256.Sum256(canonBody)
 		bodyHash = h[:]
 	} else {
@@ -609,16 +678,8 @@ func (v *Verifier) verifySignature(signature string, headers mail.Header, messag
 	}
 
 	// Verify signature
-	var hashType crypto.Hash
-	if algorithm == "rsa-sha256" {
-		hashType = crypto.SHA256
-	} else {
-		hashType = crypto.SHA1
-	}
-
 	h := sha256.Sum256(headerData)
-	err = rsa.VerifyPKCS1v15(publicKey, hashType, h[:], sigBytes)
-	if err != nil {
+	if err := VerifyHash(algorithm, publicKey, h[:], sigBytes); err != nil {
 		result.Error = fmt.Errorf("signature verification failed: %w", err)
 		result.Status = VerificationFail
 		v.logger.Debug("DKIM signature verification failed",
@@ -639,7 +700,7 @@ func (v *Verifier) verifySignature(signature string, headers mail.Header, messag
 }
 
 // fetchPublicKey fetches and caches the DKIM public key from DNS
-func (v *Verifier) fetchPublicKey(ctx context.Context, domain, selector string) (*rsa.PublicKey, *DKIMRecord, error) {
+func (v *Verifier) fetchPublicKey(ctx context.Context, domain, selector string) (crypto.PublicKey, *DKIMRecord, error) {
 	cacheKey := fmt.Sprintf("%s._domainkey.%s", selector, domain)
 
 	// Check cache first
@@ -687,7 +748,7 @@ func (v *Verifier) fetchPublicKey(ctx context.Context, domain, selector string)
 	}
 
 	// Parse public key
-	publicKey, err := parsePublicKey(dkimRecord.PublicKey)
+	publicKey, err := parseRecordPublicKey(dkimRecord)
 	if err != nil {
 		v.cacheError(cacheKey, err, 5*time.Minute)
 		return nil, dkimRecord, fmt.Errorf("failed to parse public key: %w", err)
@@ -741,14 +802,38 @@ func parseDKIMRecord(record string) (*DKIMRecord, error) {
 		result.KeyType = "rsa"
 	}
 
-	// Only RSA is widely supported
-	if result.KeyType != "rsa" {
+	// RSA (RFC 6376) and Ed25519 (RFC 8463) are supported
+	if result.KeyType != domain.DKIMKeyTypeRSA && result.KeyType != domain.DKIMKeyTypeEd25519 {
 		return nil, fmt.Errorf("unsupported key type: %s", result.KeyType)
 	}
 
 	return result, nil
 }
 
+// parseRecordPublicKey parses the p= value of a DKIM record according to its key type
+func parseRecordPublicKey(record *DKIMRecord) (crypto.PublicKey, error) {
+	if record.KeyType == domain.DKIMKeyTypeEd25519 {
+		return parseEd25519PublicKey(record.PublicKey)
+	}
+	return parsePublicKey(record.PublicKey)
+}
+
+// parseEd25519PublicKey parses a base64-encoded raw Ed25519 public key
+func parseEd25519PublicKey(keyData string) (ed25519.PublicKey, error) {
+	keyData = strings.Join(strings.Fields(keyData), "")
+
+	raw, err := base64.StdEncoding.DecodeString(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode failed: %w", err)
+	}
+
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key length: %d", len(raw))
+	}
+
+	return ed25519.PublicKey(raw), nil
+}
+
 // parsePublicKey parses a base64-encoded public key
 func parsePublicKey(keyData string) (*rsa.PublicKey, error) {
 	// Remove any whitespace
@@ -941,6 +1026,14 @@ func (km *KeyManager) GenerateDNSRecord(key *domain.DKIMKey, domainName string)
 	// Format: selector._domainkey.domain.com
 	recordName := fmt.Sprintf("%s._domainkey.%s", key.Selector, domainName)
 
+	if key.IsEd25519() {
+		// RFC 8463: p= is the base64 of the raw 32-byte public key
+		if publicKey := ed25519PublicKeyFor(key); publicKey != nil {
+			recordValue := fmt.Sprintf("v=DKIM1; k=ed25519; p=%s", base64.StdEncoding.EncodeToString(publicKey))
+			return fmt.Sprintf("%s TXT \"%s\"", recordName, recordValue)
+		}
+	}
+
 	// Build record value
 	// Remove PEM headers and join lines
 	publicKey := strings.ReplaceAll(key.PublicKeyPEM, "-----BEGIN PUBLIC KEY-----", "")
@@ -954,6 +1047,25 @@ func (km *KeyManager) GenerateDNSRecord(key *domain.DKIMKey, domainName string)
 	return fmt.Sprintf("%s TXT \"%s\"", recordName, recordValue)
 }
 
+// ed25519PublicKeyFor returns the raw Ed25519 public key for a key, taken from
+// the private key when loaded or from the stored PKIX PEM otherwise
+func ed25519PublicKeyFor(key *domain.DKIMKey) ed25519.PublicKey {
+	if len(key.Ed25519PrivateKey) == ed25519.PrivateKeySize {
+		return key.Ed25519PrivateKey.Public().(ed25519.PublicKey)
+	}
+
+	block, _ := pem.Decode([]byte(key.PublicKeyPEM))
+	if block == nil {
+		return nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	edKey, _ := pub.(ed25519.PublicKey)
+	return edKey
+}
+
 // GetRotationCandidates returns keys that should be rotated
 func (km *KeyManager) GetRotationCandidates(keys []*domain.DKIMKey, rotationAge time.Duration) []*domain.DKIMKey {
 	var candidates []*domain.DKIMKey
@@ -2,6 +2,7 @@ package dkim
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -467,7 +468,7 @@ func TestKeyManager_GenerateDNSRecord(t *testing.T) {
 	key := &domain.DKIMKey{
 		ID:        "key-123",
 		Selector:  "default",
-		PublicKey: "-----BEGIN PUBLIC KEY-----\nMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA...\n-----END PUBLIC KEY-----",
+		PublicKeyPEM: "-----BEGIN PUBLIC KEY-----\nMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA...\n-----END PUBLIC KEY-----",
 	}
 
 	record := km.GenerateDNSRecord(key, "example.com")
@@ -750,6 +751,117 @@ func TestVerifier_VerifyMessage_ValidSignature(t *testing.T) {
 	}
 }
 
+func TestVerifier_VerifyMessage_Ed25519Signature(t *testing.T) {
+	logger := zap.NewNop()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	key := &domain.DKIMKey{
+		ID:                "key-ed",
+		Selector:          "ed",
+		KeyType:           domain.DKIMKeyTypeEd25519,
+		Algorithm:         "ed25519-sha256",
+		Ed25519PrivateKey: privateKey,
+	}
+
+	// The DNS record must carry the raw key, not a PKIX structure
+	record := NewKeyManager(logger).GenerateDNSRecord(key, "example.com")
+	wantRecord := fmt.Sprintf("v=DKIM1; k=ed25519; p=%s", base64.StdEncoding.EncodeToString(publicKey))
+	if !strings.Contains(record, wantRecord) {
+		t.Fatalf("GenerateDNSRecord() = %s, want value %s", record, wantRecord)
+	}
+
+	resolver := &mockDNSResolver{
+		records: map[string][]string{
+			"ed._domainkey.example.com": {wantRecord},
+		},
+	}
+
+	signer := NewSigner(&mockKeyProvider{keys: map[string]*domain.DKIMKey{"example.com": key}}, logger)
+
+	message := []byte("From: sender@example.com\r\nTo: recipient@example.com\r\nSubject: Test\r\nDate: Mon, 01 Jan 2024 00:00:00 +0000\r\n\r\nThis is the body.")
+
+	signed, err := signer.SignMessage("example.com", message, nil)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	if !strings.Contains(string(signed), "a=ed25519-sha256") {
+		t.Error("SignMessage() should use a=ed25519-sha256")
+	}
+
+	results, err := NewVerifierWithResolver(logger, resolver).VerifyMessage(signed)
+	if err != nil {
+		t.Fatalf("VerifyMessage() error = %v", err)
+	}
+	if len(results) != 1 || !results[0].Valid {
+		t.Fatalf("VerifyMessage() should pass, got %+v", results[0])
+	}
+	if results[0].Algorithm != "ed25519-sha256" {
+		t.Errorf("VerifyMessage() algorithm = %s, want ed25519-sha256", results[0].Algorithm)
+	}
+}
+
+func TestVerifier_VerifyMessage_DualSelectors(t *testing.T) {
+	logger := zap.NewNop()
+
+	rsaKey, _ := generateTestKeyPair(t)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	// During a migration window the domain publishes both selectors, but
+	// the RSA record here is stale and no longer matches the signing key
+	_, staleRSARecord := generateTestKeyPair(t)
+	resolver := &mockDNSResolver{
+		records: map[string][]string{
+			"rsa._domainkey.example.com": {staleRSARecord},
+			"ed._domainkey.example.com": {
+				fmt.Sprintf("v=DKIM1; k=ed25519; p=%s", base64.StdEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey))),
+			},
+		},
+	}
+
+	message := []byte("From: sender@example.com\r\nTo: recipient@example.com\r\nSubject: Test\r\n\r\nThis is the body.")
+
+	signed, err := NewSigner(&mockKeyProvider{keys: map[string]*domain.DKIMKey{
+		"example.com": {Selector: "rsa", Algorithm: "rsa-sha256", PrivateKey: rsaKey},
+	}}, logger).SignMessage("example.com", message, nil)
+	if err != nil {
+		t.Fatalf("SignMessage(rsa) error = %v", err)
+	}
+	signed, err = NewSigner(&mockKeyProvider{keys: map[string]*domain.DKIMKey{
+		"example.com": {Selector: "ed", KeyType: domain.DKIMKeyTypeEd25519, Ed25519PrivateKey: edKey},
+	}}, logger).SignMessage("example.com", signed, nil)
+	if err != nil {
+		t.Fatalf("SignMessage(ed25519) error = %v", err)
+	}
+
+	results, err := NewVerifierWithResolver(logger, resolver).VerifyMessage(signed)
+	if err != nil {
+		t.Fatalf("VerifyMessage() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("VerifyMessage() returned %d results, want 2", len(results))
+	}
+
+	passed := 0
+	for _, r := range results {
+		if r.Valid {
+			passed++
+			if r.Selector != "ed" {
+				t.Errorf("unexpected passing selector %s", r.Selector)
+			}
+		}
+	}
+	if passed != 1 {
+		t.Errorf("expected exactly one selector to pass, got %d", passed)
+	}
+}
+
 func TestVerifier_VerifyMessage_InvalidSignature(t *testing.T) {
 	logger := zap.NewNop()
 
@@ -1020,6 +1132,98 @@ func TestVerifier_VerifyMessage_BodyModified(t *testing.T) {
 	}
 }
 
+func TestVerifier_VerifyMessage_BodyLengthTag(t *testing.T) {
+	logger := zap.NewNop()
+	privateKey, dnsRecord := generateTestKeyPair(t)
+	resolver := &mockDNSResolver{
+		records: map[string][]string{
+			"default._domainkey.example.com": {dnsRecord},
+		},
+	}
+	provider := &mockKeyProvider{
+		keys: map[string]*domain.DKIMKey{
+			"example.com": {
+				ID:         "key-123",
+				Selector:   "default",
+				Algorithm:  "rsa-sha256",
+				PrivateKey: privateKey,
+			},
+		},
+	}
+	signer := NewSigner(provider, logger)
+	verifier := NewVerifierWithResolver(logger, resolver)
+
+	// Relaxed canonicalization collapses the whitespace and drops the
+	// trailing blank lines, leaving "This is the body.\r\n" (19 bytes)
+	message := []byte("From: sender@example.com\r\nTo: list@example.com\r\nSubject: Test\r\n\r\nThis   is the\tbody.  \r\n\r\n\r\n")
+
+	config := DefaultSignatureConfig()
+	config.SignBodyLength = true
+	signed, err := signer.SignMessage("example.com", message, config)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	if !strings.Contains(string(signed), "l=19;") {
+		t.Fatalf("signature should carry l=19 (canonicalized length), got:\n%s", signed)
+	}
+
+	verify := func(t *testing.T, msg []byte) *VerificationResult {
+		t.Helper()
+		results, err := verifier.VerifyMessage(msg)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("VerifyMessage() = %v, %v", results, err)
+		}
+		return results[0]
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		result := verify(t, signed)
+		if result.Status != VerificationPass {
+			t.Fatalf("status = %v, want pass: %v", result.Status, result.Error)
+		}
+		if result.BodyLength != 19 || result.UnsignedBodyBytes != 0 {
+			t.Errorf("BodyLength = %d, UnsignedBodyBytes = %d, want 19, 0", result.BodyLength, result.UnsignedBodyBytes)
+		}
+	})
+
+	t.Run("FooterAppended", func(t *testing.T) {
+		withFooter := append(append([]byte{}, signed...), "-- \r\nTo unsubscribe, visit the list page\r\n"...)
+		result := verify(t, withFooter)
+		if result.Status != VerificationPass {
+			t.Fatalf("status = %v, want pass: %v", result.Status, result.Error)
+		}
+		if result.UnsignedBodyBytes == 0 {
+			t.Error("appended footer should be reported as unsigned")
+		}
+	})
+
+	t.Run("BodyTruncated", func(t *testing.T) {
+		truncated := strings.Replace(string(signed), "This   is the\tbody.", "This", 1)
+		result := verify(t, []byte(truncated))
+		if result.Status != VerificationPermFail {
+			t.Errorf("status = %v, want permerror", result.Status)
+		}
+	})
+
+	t.Run("InvalidTag", func(t *testing.T) {
+		invalid := strings.Replace(string(signed), "l=19;", "l=19x;", 1)
+		result := verify(t, []byte(invalid))
+		if result.Status != VerificationPermFail || result.Error == nil || !strings.Contains(result.Error.Error(), "body length") {
+			t.Errorf("status = %v (%v), want permerror for the body length", result.Status, result.Error)
+		}
+	})
+
+	t.Run("NotSignedByDefault", func(t *testing.T) {
+		unsigned, err := signer.SignMessage("example.com", message, nil)
+		if err != nil {
+			t.Fatalf("SignMessage() error = %v", err)
+		}
+		if strings.Contains(string(unsigned), "; l=") {
+			t.Error("default signatures should not carry an l= tag")
+		}
+	})
+}
+
 func TestVerifier_VerifyMessage_NoSignature(t *testing.T) {
 	logger := zap.NewNop()
 
@@ -1074,7 +1278,7 @@ func TestVerifier_VerifyMessage_UnsupportedAlgorithm(t *testing.T) {
 	logger := zap.NewNop()
 
 	// Message with unsupported algorithm
-	message := []byte(`DKIM-Signature: v=1; a=ed25519-sha256; d=example.com; s=default; h=from; bh=test; b=test
+	message := []byte(`DKIM-Signature: v=1; a=dsa-sha256; d=example.com; s=default; h=from; bh=test; b=test
 From: sender@example.com
 To: recipient@example.com
 Subject: Test
@@ -1218,9 +1422,20 @@ func TestParseDKIMRecord(t *testing.T) {
 			record:  "v=DKIM2; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQ...",
 			wantErr: true,
 		},
+		{
+			name:    "ed25519 key type",
+			record:  "v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+			wantErr: false,
+			check: func(r *DKIMRecord) error {
+				if r.KeyType != "ed25519" {
+					return fmt.Errorf("keyType = %s, want ed25519", r.KeyType)
+				}
+				return nil
+			},
+		},
 		{
 			name:    "unsupported key type",
-			record:  "v=DKIM1; k=ed25519; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQ...",
+			record:  "v=DKIM1; k=dsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQ...",
 			wantErr: true,
 		},
 	}
@@ -0,0 +1,114 @@
+package dkim
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// The functions in this file expose the signing, verification and
+// canonicalization primitives to ARC (RFC 8617), which reuses the DKIM key
+// records and the DKIM signature format.
+
+// Algorithm returns the a= tag value for signatures made with key
+func Algorithm(key *domain.DKIMKey) string {
+	return signingAlgorithm(key)
+}
+
+// SignHash signs the SHA-256 digest of canonicalized header data with key
+func SignHash(key *domain.DKIMKey, hash []byte) ([]byte, error) {
+	return signHeaderHash(key, hash)
+}
+
+// VerifyHash checks a signature over the SHA-256 digest of canonicalized
+// header data. The algorithm must match the type of the public key.
+func VerifyHash(algorithm string, publicKey crypto.PublicKey, hash, signature []byte) error {
+	switch pub := publicKey.(type) {
+	case ed25519.PublicKey:
+		if algorithm != "ed25519-sha256" {
+			return fmt.Errorf("key type ed25519 does not match algorithm %s", algorithm)
+		}
+		if !ed25519.Verify(pub, hash, signature) {
+			return errors.New("ed25519: invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if algorithm != "rsa-sha256" {
+			return fmt.Errorf("key type rsa does not match algorithm %s", algorithm)
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash, signature)
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// LookupPublicKey fetches the public key published for selector in domain,
+// using the verifier's resolver and key cache
+func (v *Verifier) LookupPublicKey(ctx context.Context, domain, selector string) (crypto.PublicKey, error) {
+	publicKey, _, err := v.fetchPublicKey(ctx, domain, selector)
+	return publicKey, err
+}
+
+// IsTemporaryError reports whether a key lookup failed for a reason that may
+// resolve on retry, such as a DNS timeout
+func IsTemporaryError(err error) bool {
+	return isDNSTempError(err)
+}
+
+// CanonicalizeBody canonicalizes a message body with the simple or relaxed
+// algorithm
+func CanonicalizeBody(body []byte, method string) []byte {
+	return canonicalizeBody(body, method)
+}
+
+// CanonicalizeHeaderValue canonicalizes a single header value
+func CanonicalizeHeaderValue(value, method string) string {
+	return canonicalizeHeaderValue(value, method)
+}
+
+// CanonicalizeSignedHeaders returns the canonicalized form of the headers
+// named in a signature's h= tag, one CRLF-terminated line per header
+func CanonicalizeSignedHeaders(headers mail.Header, names []string, method string) []byte {
+	return (&Verifier{}).buildSignedHeaderData(headers, names, method)
+}
+
+// SignableHeaders returns the names in want that are present in headers
+func SignableHeaders(headers mail.Header, want []string) []string {
+	return getSignableHeaders(headers, want)
+}
+
+// StripSignature returns a signature header value with the b= tag emptied,
+// as it was when the signature was computed
+func StripSignature(value string) string {
+	parts := strings.Split(value, ";")
+	for i, part := range parts {
+		tag, _, ok := strings.Cut(part, "=")
+		if ok && strings.TrimSpace(tag) == "b" {
+			parts[i] = part[:strings.Index(part, "=")+1]
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// DecodeSignature decodes a b= or bh= value, ignoring folding whitespace
+func DecodeSignature(value string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+}
+
+// FoldSignature folds a base64 signature for header inclusion
+func FoldSignature(sig string) string {
+	return foldSignature(sig)
+}
+
+// ParseTags parses a tag=value list such as a signature header or key record
+func ParseTags(value string) map[string]string {
+	return parseSignatureParams(value)
+}
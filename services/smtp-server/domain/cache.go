@@ -18,6 +18,7 @@ type Cache struct {
 	aliases      map[string][]*Alias    // by source email
 	distLists    map[string]*DistributionList // by email
 	routingRules map[string][]*RoutingRule    // by domain ID
+	catchAll     map[string][]*CatchAllRule   // by domain ID
 	permissions  map[string]map[string]*UserDomainPermission // user_id -> domain_id -> permission
 
 	mu           sync.RWMutex
@@ -40,6 +41,7 @@ type Repository interface {
 	GetAliasesBySource(ctx context.Context, email string) ([]*Alias, error)
 	GetDistributionListByEmail(ctx context.Context, email string) (*DistributionList, error)
 	GetRoutingRules(ctx context.Context, domainID string) ([]*RoutingRule, error)
+	GetCatchAllRules(ctx context.Context, domainID string) ([]*CatchAllRule, error)
 	GetUserDomainPermission(ctx context.Context, userID, domainID string) (*UserDomainPermission, error)
 	ListenForChanges(ctx context.Context, callback func(table, action, id string)) error
 }
@@ -55,6 +57,7 @@ func NewCache(repository Repository, logger *zap.Logger, ttl time.Duration) *Cac
 		aliases:      make(map[string][]*Alias),
 		distLists:    make(map[string]*DistributionList),
 		routingRules: make(map[string][]*RoutingRule),
+		catchAll:     make(map[string][]*CatchAllRule),
 		permissions:  make(map[string]map[string]*UserDomainPermission),
 		refreshChan:  make(chan string, 100),
 		stopChan:     make(chan struct{}),
@@ -102,6 +105,7 @@ func (c *Cache) RefreshAll(ctx context.Context) error {
 	c.domainsByID = make(map[string]*Domain)
 	c.orgDomains = make(map[string][]*Domain)
 	c.dkimKeys = make(map[string][]*DKIMKey)
+	c.catchAll = make(map[string][]*CatchAllRule)
 
 	for _, domain := range domains {
 		c.domains[domain.Name] = domain
@@ -321,6 +325,40 @@ func (c *Cache) GetRoutingRules(ctx context.Context, domainID string) ([]*Routin
 	return rules, nil
 }
 
+// GetCatchAllRules returns catch-all rules for a domain
+func (c *Cache) GetCatchAllRules(ctx context.Context, domainID string) ([]*CatchAllRule, error) {
+	c.mu.RLock()
+	rules, exists := c.catchAll[domainID]
+	c.mu.RUnlock()
+
+	if exists {
+		return rules, nil
+	}
+
+	// Load from database
+	rules, err := c.repository.GetCatchAllRules(ctx, domainID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.catchAll[domainID] = rules
+	c.mu.Unlock()
+
+	return rules, nil
+}
+
+// CatchAllDestination returns where catch-all routing sends mail for a local
+// part of a domain that has no mailbox, alias or distribution list, or ""
+// if the mail is rejected
+func (c *Cache) CatchAllDestination(ctx context.Context, d *Domain, localPart string) (string, error) {
+	rules, err := c.GetCatchAllRules(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	return ResolveCatchAll(rules, d.Policies, localPart), nil
+}
+
 // CheckUserDomainPermission checks if a user can send from a domain
 func (c *Cache) CheckUserDomainPermission(ctx context.Context, userID, domainID string) (*UserDomainPermission, error) {
 	c.mu.RLock()
@@ -376,6 +414,13 @@ func (c *Cache) InvalidateRoutingRules(domainID string) {
 	delete(c.routingRules, domainID)
 }
 
+// InvalidateCatchAllRules removes catch-all rules from cache
+func (c *Cache) InvalidateCatchAllRules(domainID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.catchAll, domainID)
+}
+
 // InvalidateUserPermissions removes user permissions from cache
 func (c *Cache) InvalidateUserPermissions(userID string) {
 	c.mu.Lock()
@@ -459,6 +504,16 @@ func (c *Cache) listenForChanges(ctx context.Context) {
 			// Invalidate distribution list cache
 		case "routing_rules":
 			c.InvalidateRoutingRules(id)
+		case "domain_catch_all_rules":
+			c.InvalidateCatchAllRules(id)
+		case "domain_policies":
+			// Policies are loaded with the domain; the payload is its ID
+			if d := c.GetDomainByID(id); d != nil {
+				select {
+				case c.refreshChan <- d.Name:
+				default:
+				}
+			}
 		case "user_domain_permissions":
 			// Invalidate user permissions
 		case "dkim_keys":
@@ -1,8 +1,11 @@
 package domain
 
 import (
+	"crypto/ed25519"
 	"crypto/rsa"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -50,6 +53,13 @@ type DomainPolicies struct {
 	GreylistingEnabled  bool     `json:"greylisting_enabled"`
 	RateLimitPerHour    int      `json:"rate_limit_per_hour"`
 	RateLimitPerDay     int      `json:"rate_limit_per_day"`
+	// FooterText and FooterHTML are the disclaimer appended to mail sent
+	// from the domain, set in the domain's policies
+	FooterText string `json:"footer_text,omitempty"`
+	FooterHTML string `json:"footer_html,omitempty"`
+	// DKIMBodyLength signs mail from the domain with a DKIM l= tag, so
+	// footers added by mailing lists don't break the signature
+	DKIMBodyLength bool `json:"dkim_body_length,omitempty"`
 }
 
 // DefaultPolicies returns default domain policies
@@ -92,21 +102,37 @@ func (p *DomainPolicies) Validate() error {
 	return nil
 }
 
+// DKIM key types as published in the k= tag of the DNS record
+const (
+	DKIMKeyTypeRSA     = "rsa"
+	DKIMKeyTypeEd25519 = "ed25519"
+)
+
 // DKIMKey represents a DKIM signing key for a domain
 type DKIMKey struct {
-	ID           string          `json:"id"`
-	DomainID     string          `json:"domain_id"`
-	Domain       string          `json:"domain"`
-	Selector     string          `json:"selector"`
-	PrivateKey   *rsa.PrivateKey `json:"-"`
-	PublicKey    *rsa.PublicKey  `json:"-"`
-	PublicKeyPEM string          `json:"public_key_pem"`
-	Algorithm    string          `json:"algorithm"` // rsa-sha256
-	KeySize      int             `json:"key_size"`
-	IsActive     bool            `json:"is_active"`
-	ExpiresAt    *time.Time      `json:"expires_at"`
-	RotatedAt    *time.Time      `json:"rotated_at"`
-	CreatedAt    time.Time       `json:"created_at"`
+	ID                string             `json:"id"`
+	DomainID          string             `json:"domain_id"`
+	Domain            string             `json:"domain"`
+	Selector          string             `json:"selector"`
+	KeyType           string             `json:"key_type"` // rsa or ed25519
+	PrivateKey        *rsa.PrivateKey    `json:"-"`
+	PublicKey         *rsa.PublicKey     `json:"-"`
+	Ed25519PrivateKey ed25519.PrivateKey `json:"-"`
+	PublicKeyPEM      string             `json:"public_key_pem"`
+	Algorithm         string             `json:"algorithm"` // rsa-sha256 or ed25519-sha256
+	KeySize           int                `json:"key_size"`
+	IsActive          bool               `json:"is_active"`
+	ExpiresAt         *time.Time         `json:"expires_at"`
+	RotatedAt         *time.Time         `json:"rotated_at"`
+	CreatedAt         time.Time          `json:"created_at"`
+}
+
+// IsEd25519 reports whether the key signs with ed25519-sha256 (RFC 8463)
+func (k *DKIMKey) IsEd25519() bool {
+	if k.KeyType != "" {
+		return k.KeyType == DKIMKeyTypeEd25519
+	}
+	return strings.HasPrefix(strings.ToLower(k.Algorithm), DKIMKeyTypeEd25519)
 }
 
 // Mailbox represents a user mailbox
@@ -137,6 +163,78 @@ type Mailbox struct {
 	UpdatedAt         time.Time  `json:"updated_at"`
 }
 
+// SieveScript is a mailbox's Sieve filter script
+type SieveScript struct {
+	ID        string    `json:"id"`
+	MailboxID string    `json:"mailbox_id"`
+	Name      string    `json:"name"`
+	Script    string    `json:"script"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VacationResponder is a mailbox's out-of-office auto-reply. It is in
+// effect while active and between StartAt and EndAt, when set, and answers
+// each sender at most once every ReplyIntervalDays.
+type VacationResponder struct {
+	ID                string     `json:"id"`
+	MailboxID         string     `json:"mailbox_id"`
+	Subject           string     `json:"subject"`
+	Body              string     `json:"body"`
+	StartAt           *time.Time `json:"start_at,omitempty"`
+	EndAt             *time.Time `json:"end_at,omitempty"`
+	ReplyIntervalDays int        `json:"reply_interval_days"`
+	Addresses         []string   `json:"addresses"` // Other addresses of the mailbox owner, such as aliases
+	IsActive          bool       `json:"is_active"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// InEffect reports whether the responder answers mail received at now
+func (v *VacationResponder) InEffect(now time.Time) bool {
+	if !v.IsActive {
+		return false
+	}
+	if v.StartAt != nil && now.Before(*v.StartAt) {
+		return false
+	}
+	if v.EndAt != nil && !now.Before(*v.EndAt) {
+		return false
+	}
+	return true
+}
+
+// ReadReceiptPolicy is whether a user sends read receipts (MDNs) for
+// messages that request them
+type ReadReceiptPolicy string
+
+const (
+	ReadReceiptAlways ReadReceiptPolicy = "always" // Sent when the message is first displayed
+	ReadReceiptPrompt ReadReceiptPolicy = "prompt" // Sent only when the user confirms in their client
+	ReadReceiptNever  ReadReceiptPolicy = "never"
+)
+
+// MDNRequest is a read receipt requested by a message delivered to a local
+// mailbox with Disposition-Notification-To (RFC 8098)
+type MDNRequest struct {
+	ID              string     `json:"id"`
+	MailboxID       string     `json:"mailbox_id"`
+	OrganizationID  string     `json:"organization_id"`
+	DomainID        string     `json:"domain_id"`
+	MessageID       string     `json:"message_id"` // Message-ID of the original message
+	NotifyTo        string     `json:"notify_to"`
+	ReturnPath      string     `json:"return_path"`
+	FinalRecipient  string     `json:"final_recipient"`
+	Subject         string     `json:"subject"`
+	OriginalHeaders string     `json:"-"`
+	AutoAllowed     bool       `json:"auto_allowed"` // The receipt may be sent without asking the user
+	Status          string     `json:"status"`       // pending, displayed, sent
+	CreatedAt       time.Time  `json:"created_at"`
+	DisplayedAt     *time.Time `json:"displayed_at,omitempty"`
+	SentAt          *time.Time `json:"sent_at,omitempty"`
+}
+
 // Alias represents an email alias
 type Alias struct {
 	ID             string    `json:"id"`
@@ -215,6 +313,72 @@ type RuleActions struct {
 	StopProcessing    bool              `json:"stop_processing"`
 }
 
+// MaxCatchAllHops is how many catch-all redirects delivery follows through
+// our domains before treating the routing as a loop. Domain-manager rejects
+// rules that would exceed it.
+const MaxCatchAllHops = 5
+
+// CatchAllRule routes mail for local parts of a domain that have no mailbox,
+// alias or distribution list. Rules are managed by domain-manager and
+// evaluated by position; the domain's catch-all address is the fallback.
+type CatchAllRule struct {
+	ID          string `json:"id"`
+	DomainID    string `json:"domain_id"`
+	Position    int    `json:"position"`
+	MatchType   string `json:"match_type"` // exact, wildcard, regex
+	Pattern     string `json:"pattern"`
+	Action      string `json:"action"` // deliver, forward
+	Destination string `json:"destination"`
+
+	re *regexp.Regexp
+}
+
+// Compile prepares the rule's pattern for matching. All match types are
+// case-insensitive and match the whole local part.
+func (r *CatchAllRule) Compile() error {
+	var expr string
+	switch r.MatchType {
+	case "exact":
+		expr = regexp.QuoteMeta(r.Pattern)
+	case "wildcard":
+		expr = regexp.QuoteMeta(r.Pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+	case "regex":
+		expr = "(?:" + r.Pattern + ")"
+	default:
+		return fmt.Errorf("unknown catch-all match type %q", r.MatchType)
+	}
+
+	re, err := regexp.Compile("(?i)^" + expr + "$")
+	if err != nil {
+		return fmt.Errorf("compile catch-all pattern: %w", err)
+	}
+	r.re = re
+	return nil
+}
+
+// Matches reports whether the rule applies to a local part. A rule that
+// hasn't been compiled never matches.
+func (r *CatchAllRule) Matches(localPart string) bool {
+	return r.re != nil && r.re.MatchString(localPart)
+}
+
+// ResolveCatchAll returns the destination of the first rule matching
+// localPart, or the domain's catch-all address when none does. It returns ""
+// when mail to the local part is rejected.
+func ResolveCatchAll(rules []*CatchAllRule, policies *DomainPolicies, localPart string) string {
+	for _, rule := range rules {
+		if rule.Matches(localPart) {
+			return rule.Destination
+		}
+	}
+	if policies != nil && policies.CatchAllEnabled {
+		return policies.CatchAllAddress
+	}
+	return ""
+}
+
 // UserDomainPermission represents a user's permission to send from a domain
 type UserDomainPermission struct {
 	ID             string    `json:"id"`
@@ -292,6 +456,57 @@ type Message struct {
 	DeliveredAt      *time.Time        `json:"delivered_at"`
 	FailedAt         *time.Time        `json:"failed_at"`
 	CreatedAt        time.Time         `json:"created_at"`
+	DSN              *DSNParams        `json:"dsn,omitempty"`
+}
+
+// DSN NOTIFY conditions (RFC 3461)
+const (
+	DSNNotifyNever   = "NEVER"
+	DSNNotifySuccess = "SUCCESS"
+	DSNNotifyFailure = "FAILURE"
+	DSNNotifyDelay   = "DELAY"
+)
+
+// DSNParams holds the delivery status notification parameters given on MAIL FROM and RCPT TO
+type DSNParams struct {
+	Return     string                   `json:"ret,omitempty"`   // FULL or HDRS
+	EnvelopeID string                   `json:"envid,omitempty"`
+	Recipients map[string]*RecipientDSN `json:"recipients,omitempty"`
+}
+
+// RecipientDSN holds the per-recipient NOTIFY and ORCPT parameters
+type RecipientDSN struct {
+	Notify            []string `json:"notify,omitempty"`
+	OriginalRecipient string   `json:"orcpt,omitempty"`
+}
+
+// ShouldNotify reports whether a DSN should be sent for recipient under condition
+// Without an explicit NOTIFY only failures are reported, matching the RFC 3461 default
+func (p *DSNParams) ShouldNotify(recipient, condition string) bool {
+	var notify []string
+	if p != nil {
+		if r := p.Recipients[recipient]; r != nil {
+			notify = r.Notify
+		}
+	}
+
+	if len(notify) == 0 {
+		return condition == DSNNotifyFailure
+	}
+	for _, n := range notify {
+		if n == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// OriginalRecipient returns the ORCPT given for recipient, if any
+func (p *DSNParams) OriginalRecipient(recipient string) string {
+	if p == nil || p.Recipients[recipient] == nil {
+		return ""
+	}
+	return p.Recipients[recipient].OriginalRecipient
 }
 
 // MessageStatus represents the status of a message in the queue
@@ -315,3 +530,38 @@ const (
 	StatusDeferred   MessageStatus = "deferred"
 	StatusFailed     MessageStatus = "failed"
 )
+
+// Message queue priorities. Higher values are delivered first, but every
+// priority keeps a share of delivery capacity so low-priority mail still
+// drains during a large send.
+const (
+	PriorityLow    = 0
+	PriorityNormal = 1
+	PriorityHigh   = 2
+)
+
+// PriorityName returns the name of a queue priority, as used in the
+// X-Queue-Priority header and in metrics
+func PriorityName(priority int) string {
+	switch {
+	case priority >= PriorityHigh:
+		return "high"
+	case priority <= PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority parses a queue priority name, reporting whether it is known
+func ParsePriority(name string) (int, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "high":
+		return PriorityHigh, true
+	case "normal":
+		return PriorityNormal, true
+	case "low", "bulk":
+		return PriorityLow, true
+	}
+	return PriorityNormal, false
+}
@@ -49,6 +49,10 @@ Your message has not yet been delivered to the following recipients
 due to a temporary error:
 
 Delivery will continue to be attempted.
+{{else if eq .Action "delivered"}}This is a delivery status notification.
+
+Your message was successfully delivered to the following recipients,
+or relayed to a mail system that does not report delivery status.
 {{end}}
                    The mail system
 
@@ -65,7 +69,8 @@ Reporting-MTA: dns; {{.Hostname}}
 {{if .EnvelopeID}}Original-Envelope-ID: {{.EnvelopeID}}
 {{end}}Arrival-Date: {{.ArrivalDate}}
 
-{{range .Recipients}}Final-Recipient: rfc822; {{.Address}}
+{{range .Recipients}}{{if .OriginalRecipient}}Original-Recipient: rfc822; {{.OriginalRecipient}}
+{{end}}Final-Recipient: rfc822; {{.Address}}
 Action: {{.Action}}
 Status: {{.Status}}
 {{if .RemoteMTA}}Remote-MTA: dns; {{.RemoteMTA}}
@@ -100,13 +105,14 @@ type templateData struct {
 }
 
 type recipientData struct {
-	Address        string
-	Action         string
-	Status         string
-	RemoteMTA      string
-	DiagnosticCode string
-	LastAttempt    string
-	WillRetryUntil string
+	Address           string
+	OriginalRecipient string
+	Action            string
+	Status            string
+	RemoteMTA         string
+	DiagnosticCode    string
+	LastAttempt       string
+	WillRetryUntil    string
 }
 
 // GenerateOptions contains options for DSN generation
@@ -147,28 +153,38 @@ func (g *Generator) Generate(opts GenerateOptions) ([]byte, error) {
 
 	// Determine the action based on recipients
 	action := "failed"
+	if len(opts.Recipients) > 0 {
+		action = "delivered"
+	}
 	for _, r := range opts.Recipients {
 		if r.Action == ActionDelayed {
 			action = "delayed"
 			break
 		}
+		if r.Action != ActionDelivered && r.Action != ActionRelayed {
+			action = "failed"
+		}
 	}
 
 	// Build subject
 	subject := "Undelivered Mail Returned to Sender"
-	if action == "delayed" {
+	switch action {
+	case "delayed":
 		subject = "Delayed Mail (still being retried)"
+	case "delivered":
+		subject = "Successful Mail Delivery Report"
 	}
 
 	// Build recipient data
 	var recipients []recipientData
 	for _, r := range opts.Recipients {
 		rd := recipientData{
-			Address:        r.FinalRecipient,
-			Action:         string(r.Action),
-			Status:         r.Status,
-			RemoteMTA:      r.RemoteMTA,
-			DiagnosticCode: r.DiagnosticCode,
+			Address:           r.FinalRecipient,
+			OriginalRecipient: r.OriginalRecipient,
+			Action:            string(r.Action),
+			Status:            r.Status,
+			RemoteMTA:         r.RemoteMTA,
+			DiagnosticCode:    r.DiagnosticCode,
 		}
 		if !r.LastAttemptDate.IsZero() {
 			rd.LastAttempt = r.LastAttemptDate.Format(time.RFC1123Z)
@@ -233,6 +249,17 @@ func (g *Generator) GenerateDelayedDSN(opts GenerateOptions) ([]byte, error) {
 	return g.Generate(opts)
 }
 
+// GenerateSuccessDSN creates a DSN for a delivered or relayed message
+// Recipients without an action are marked as delivered
+func (g *Generator) GenerateSuccessDSN(opts GenerateOptions) ([]byte, error) {
+	for i := range opts.Recipients {
+		if opts.Recipients[i].Action != ActionRelayed {
+			opts.Recipients[i].Action = ActionDelivered
+		}
+	}
+	return g.Generate(opts)
+}
+
 // GenerateFailedDSN creates a DSN for a permanently failed message
 func (g *Generator) GenerateFailedDSN(opts GenerateOptions) ([]byte, error) {
 	// Mark all recipients as failed
@@ -124,6 +124,30 @@ func TestGenerator_Generate(t *testing.T) {
 				"Original-Envelope-ID: ENV123456",
 			},
 		},
+		{
+			name: "success DSN with original recipient",
+			opts: GenerateOptions{
+				OriginalSender:    "sender@external.com",
+				OriginalMessageID: "<original-def@external.com>",
+				ArrivalDate:       time.Now(),
+				Recipients: []RecipientStatus{
+					{
+						OriginalRecipient: "alias@example.com",
+						FinalRecipient:    "user@example.com",
+						Action:            ActionRelayed,
+						Status:            StatusSuccess.String(),
+					},
+				},
+			},
+			expectSubject: "Successful Mail Delivery Report",
+			expectContains: []string{
+				"report-type=delivery-status",
+				"Original-Recipient: rfc822; alias@example.com",
+				"Final-Recipient: rfc822; user@example.com",
+				"Action: relayed",
+				"Status: 2.0.0",
+			},
+		},
 	}
 
 	for _, tt := range tests {
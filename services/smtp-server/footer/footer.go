@@ -0,0 +1,390 @@
+// Package footer appends a domain's legal disclaimer to outbound mail. The
+// plain text footer goes into text/plain bodies and the HTML footer into
+// text/html bodies; in multipart/alternative messages both alternatives get
+// theirs. Attachments are never touched, and signed or encrypted messages
+// (S/MIME, PGP) are passed on unchanged since any change would break them.
+// The footer is added before DKIM signing so the signature covers it.
+package footer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"html"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"unicode"
+)
+
+// Marker is the attribute on the element wrapping an HTML footer. A message
+// already carrying it, such as a reply or forward quoting footered mail, is
+// left alone.
+const Marker = "data-outbound-footer"
+
+// maxDepth bounds multipart nesting followed to find the message body
+const maxDepth = 5
+
+// Skip reasons reported by Apply
+const (
+	SkipNoFooter        = "no footer configured"
+	SkipSigned          = "signed or encrypted"
+	SkipPresent         = "footer already present"
+	SkipCharset         = "unsupported charset"
+	SkipMalformed       = "malformed MIME structure"
+	SkipNoBody          = "no text body"
+	SkipUnknownEncoding = "unknown transfer encoding"
+)
+
+// Footer is the disclaimer configured for a domain
+type Footer struct {
+	Text string
+	HTML string
+}
+
+// html returns the HTML footer, falling back to the escaped text footer
+func (f Footer) html() string {
+	if f.HTML != "" {
+		return f.HTML
+	}
+	if f.Text == "" {
+		return ""
+	}
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(f.Text), "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = html.EscapeString(line)
+	}
+	return "<p>" + strings.Join(lines, "<br>") + "</p>"
+}
+
+// Apply returns the message with the footer added. When the message can't
+// take the footer it is returned unchanged, with the reason.
+func Apply(message []byte, f Footer) ([]byte, string) {
+	if strings.TrimSpace(f.Text) == "" && strings.TrimSpace(f.HTML) == "" {
+		return message, SkipNoFooter
+	}
+
+	nl := "\n"
+	if bytes.Contains(message, []byte("\r\n")) {
+		nl = "\r\n"
+	}
+
+	a := &applier{footer: f, nl: nl}
+	out, err := a.entity(message, 0)
+	if err != "" {
+		return message, err
+	}
+	if !a.applied {
+		return message, SkipNoBody
+	}
+	return out, ""
+}
+
+type applier struct {
+	footer  Footer
+	nl      string
+	applied bool
+}
+
+// entity rewrites one MIME entity, header and body
+func (a *applier) entity(data []byte, depth int) ([]byte, string) {
+	header, body := splitHeader(data)
+	h, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(header))).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, SkipMalformed
+	}
+
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, SkipMalformed
+	}
+
+	switch mediaType {
+	case "multipart/signed", "multipart/encrypted",
+		"application/pkcs7-mime", "application/x-pkcs7-mime", "application/pgp-encrypted":
+		return nil, SkipSigned
+	}
+	if strings.EqualFold(strings.TrimSpace(strings.SplitN(h.Get("Content-Disposition"), ";", 2)[0]), "attachment") {
+		return data, ""
+	}
+
+	switch {
+	case mediaType == "text/plain" || mediaType == "text/html":
+		return a.text(header, body, mediaType, params, h.Get("Content-Transfer-Encoding"))
+
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if depth >= maxDepth || params["boundary"] == "" {
+			return data, ""
+		}
+		m, ok := splitMultipart(body, params["boundary"])
+		if !ok {
+			return nil, SkipMalformed
+		}
+
+		// Every alternative is a rendering of the body; elsewhere the body
+		// is the first part and the rest are attachments or inline images
+		n := len(m.parts)
+		if mediaType != "multipart/alternative" && n > 1 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			part, skip := a.entity(m.parts[i], depth+1)
+			if skip != "" {
+				return nil, skip
+			}
+			m.parts[i] = part
+		}
+		return concat(header, m.bytes()), ""
+	}
+	return data, ""
+}
+
+// text appends the footer to a text/plain or text/html body
+func (a *applier) text(header, body []byte, mediaType string, params map[string]string, cte string) ([]byte, string) {
+	footer := a.footer.Text
+	if mediaType == "text/html" {
+		footer = a.footer.html()
+	}
+	if strings.TrimSpace(footer) == "" {
+		return concat(header, body), ""
+	}
+
+	cte = strings.ToLower(strings.TrimSpace(cte))
+	content, ok := decode(body, cte)
+	if !ok {
+		return nil, SkipUnknownEncoding
+	}
+
+	if bytes.Contains(content, []byte("-----BEGIN PGP ")) {
+		return nil, SkipSigned
+	}
+	if mediaType == "text/html" && bytes.Contains(content, []byte(Marker)) ||
+		mediaType == "text/plain" && strings.Contains(normalize(string(content)), normalize(footer)) {
+		return nil, SkipPresent
+	}
+
+	ascii := isASCII(footer)
+	charset := strings.ToLower(params["charset"])
+	switch charset {
+	case "utf-8", "utf8":
+	case "", "us-ascii":
+		if !ascii {
+			params["charset"] = "utf-8"
+			header = setHeader(header, "Content-Type", mime.FormatMediaType(mediaType, params), a.nl)
+		}
+	default:
+		if !ascii {
+			return nil, SkipCharset
+		}
+	}
+
+	footer = strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(footer), "\r\n", "\n"), "\n", a.nl)
+	if mediaType == "text/html" {
+		content = insertHTML(content, `<div `+Marker+`="1">`+footer+`</div>`, a.nl)
+	} else {
+		content = concat(bytes.TrimRight(content, "\r\n"), []byte(a.nl+a.nl+footer+a.nl))
+	}
+
+	if !ascii && (cte == "" || cte == "7bit") {
+		cte = "quoted-printable"
+		header = setHeader(header, "Content-Transfer-Encoding", cte, a.nl)
+	}
+
+	a.applied = true
+	return concat(header, encode(content, cte, a.nl)), ""
+}
+
+// insertHTML places footer at the end of the document body
+func insertHTML(content []byte, footer, nl string) []byte {
+	i := bytes.LastIndex(bytes.ToLower(content), []byte("</body>"))
+	if i < 0 {
+		return concat(bytes.TrimRight(content, "\r\n"), []byte(footer+nl))
+	}
+	return concat(content[:i], []byte(footer), content[i:])
+}
+
+// concat joins byte slices into a new one. Entities are slices of the
+// whole message, so appending to one in place would overwrite what follows.
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func decode(body []byte, cte string) ([]byte, bool) {
+	switch cte {
+	case "", "7bit", "8bit", "binary":
+		return body, true
+	case "quoted-printable":
+		content, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body)))
+		return content, err == nil
+	case "base64":
+		content, err := base64.StdEncoding.DecodeString(strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, string(body)))
+		return content, err == nil
+	}
+	return nil, false
+}
+
+func encode(content []byte, cte, nl string) []byte {
+	var buf bytes.Buffer
+	switch cte {
+	case "quoted-printable":
+		w := quotedprintable.NewWriter(&buf)
+		w.Write(content)
+		w.Close()
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteString("\r\n")
+		}
+		if nl != "\r\n" {
+			return bytes.ReplaceAll(buf.Bytes(), []byte("\r\n"), []byte(nl))
+		}
+		return buf.Bytes()
+	case "base64":
+		encoded := base64.StdEncoding.EncodeToString(content)
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + nl)
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + nl)
+		return buf.Bytes()
+	}
+	return content
+}
+
+// normalize makes text comparable when quoted in a reply: quote markers and
+// runs of whitespace are ignored
+func normalize(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		lines = append(lines, strings.TrimLeft(line, "> \t"))
+	}
+	return strings.Join(strings.Fields(strings.Join(lines, " ")), " ")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// splitHeader splits an entity after the empty line ending its header
+func splitHeader(data []byte) (header, body []byte) {
+	for i := 0; i < len(data); {
+		end := bytes.IndexByte(data[i:], '\n')
+		if end < 0 {
+			break
+		}
+		line := data[i : i+end+1]
+		i += end + 1
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			return data[:i:i], data[i:]
+		}
+	}
+	return data[:len(data):len(data)], nil
+}
+
+// setHeader replaces the first field called name, with its continuation
+// lines, or adds it at the end of the header
+func setHeader(header []byte, name, value, nl string) []byte {
+	field := []byte(name + ": " + value + nl)
+	var out []byte
+	replaced, skipping := false, false
+	for i := 0; i < len(header); {
+		end := bytes.IndexByte(header[i:], '\n')
+		if end < 0 {
+			end = len(header) - i - 1
+		}
+		line := header[i : i+end+1]
+		i += end + 1
+
+		if skipping && (line[0] == ' ' || line[0] == '\t') {
+			continue
+		}
+		skipping = false
+
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			if !replaced {
+				out = append(out, field...)
+			}
+			out = append(out, line...)
+			out = append(out, header[i:]...)
+			return out
+		}
+		if colon := bytes.IndexByte(line, ':'); !replaced && colon > 0 &&
+			strings.EqualFold(strings.TrimSpace(string(line[:colon])), name) {
+			out = append(out, field...)
+			replaced, skipping = true, true
+			continue
+		}
+		out = append(out, line...)
+	}
+	if !replaced {
+		out = append(out, field...)
+	}
+	return out
+}
+
+// multipartBody is a multipart body split at its boundaries. Everything
+// but the parts is kept byte for byte.
+type multipartBody struct {
+	preamble []byte   // up to and including the first delimiter line
+	parts    [][]byte // each followed by delims[i]
+	delims   [][]byte // the last is the close delimiter and epilogue
+}
+
+func splitMultipart(body []byte, boundary string) (*multipartBody, bool) {
+	delim := []byte("--" + boundary)
+	m := &multipartBody{}
+	start := -1 // start of the current part
+	for i := 0; i < len(body); {
+		end := bytes.IndexByte(body[i:], '\n')
+		if end < 0 {
+			end = len(body) - i - 1
+		}
+		line := body[i : i+end+1]
+		lineStart := i
+		i += end + 1
+
+		trimmed := bytes.TrimRight(line, " \t\r\n")
+		if !bytes.HasPrefix(trimmed, delim) {
+			continue
+		}
+		rest := trimmed[len(delim):]
+		switch {
+		case len(rest) == 0:
+			if start < 0 {
+				m.preamble = body[:i]
+			} else {
+				m.parts = append(m.parts, body[start:lineStart])
+				m.delims = append(m.delims, line)
+			}
+			start = i
+		case bytes.Equal(rest, []byte("--")) && start >= 0:
+			m.parts = append(m.parts, body[start:lineStart])
+			m.delims = append(m.delims, body[lineStart:])
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+func (m *multipartBody) bytes() []byte {
+	out := append([]byte{}, m.preamble...)
+	for i, part := range m.parts {
+		out = append(out, part...)
+		out = append(out, m.delims[i]...)
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+package footer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+var disclaimer = Footer{
+	Text: "This message is confidential.",
+	HTML: "<p>This message is <b>confidential</b>.</p>",
+}
+
+func crlf(s string) []byte {
+	return []byte(strings.ReplaceAll(s, "\n", "\r\n"))
+}
+
+// parts returns the decoded text parts of a message by media type
+func parts(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("message does not parse: %v", err)
+	}
+	found := make(map[string]string)
+	var walk func(contentType, cte string, r io.Reader)
+	walk = func(contentType, cte string, r io.Reader) {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatalf("Content-Type %q: %v", contentType, err)
+		}
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			if strings.EqualFold(cte, "base64") {
+				r = base64.NewDecoder(base64.StdEncoding, r)
+			}
+			body, _ := io.ReadAll(r)
+			found[mediaType] += string(body)
+			return
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatalf("multipart: %v", err)
+			}
+			walk(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p)
+		}
+	}
+	walk(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	return found
+}
+
+func TestApplyPlainText(t *testing.T) {
+	msg := crlf("From: alice@example.com\nSubject: Hi\n\nHello Bob\n")
+
+	out, skip := Apply(msg, disclaimer)
+	if skip != "" {
+		t.Fatalf("skipped: %s", skip)
+	}
+	want := crlf("From: alice@example.com\nSubject: Hi\n\nHello Bob\n\nThis message is confidential.\n")
+	if !bytes.Equal(out, want) {
+		t.Errorf("Apply() = %q, want %q", out, want)
+	}
+
+	// Sending it on again, or quoting it in a reply, doesn't add a second one
+	reply := crlf("From: bob@example.com\n\nThanks\n\n> Hello Bob\n>\n> This message is\n> confidential.\n")
+	if _, skip := Apply(out, disclaimer); skip != SkipPresent {
+		t.Errorf("footered message: skip = %q", skip)
+	}
+	if _, skip := Apply(reply, disclaimer); skip != SkipPresent {
+		t.Errorf("quoted footer: skip = %q", skip)
+	}
+}
+
+func TestApplyAlternative(t *testing.T) {
+	msg := crlf(`From: alice@example.com
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/plain; charset=us-ascii
+
+Hello Bob
+--alt
+Content-Type: text/html; charset=utf-8
+Content-Transfer-Encoding: base64
+
+PGh0bWw+PGJvZHk+PHA+SGVsbG8gQm9iPC9wPjwvYm9keT48L2h0bWw+
+--alt--
+--outer
+Content-Type: text/plain
+Content-Disposition: attachment; filename="notes.txt"
+
+Attached notes
+--outer--
+`)
+
+	out, skip := Apply(msg, disclaimer)
+	if skip != "" {
+		t.Fatalf("skipped: %s", skip)
+	}
+	got := parts(t, out)
+
+	if text := got["text/plain"]; !strings.Contains(text, "Hello Bob\r\n\r\nThis message is confidential.") ||
+		strings.Count(text, "confidential") != 1 {
+		t.Errorf("text parts = %q", text)
+	}
+	if !strings.Contains(got["text/plain"], "Attached notes") {
+		t.Error("attachment lost")
+	}
+	wantHTML := `<html><body><p>Hello Bob</p><div data-outbound-footer="1"><p>This message is <b>confidential</b>.</p></div></body></html>`
+	if got["text/html"] != wantHTML {
+		t.Errorf("html part = %q, want %q", got["text/html"], wantHTML)
+	}
+}
+
+func TestApplyNonASCII(t *testing.T) {
+	msg := crlf("From: alice@example.com\nContent-Type: text/plain\n\nHello\n")
+
+	out, skip := Apply(msg, Footer{Text: "Vertraulich – nur für den Empfänger."})
+	if skip != "" {
+		t.Fatalf("skipped: %s", skip)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+		t.Errorf("Content-Transfer-Encoding = %q", got)
+	}
+	if got := parsed.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	latin1 := crlf("From: alice@example.com\nContent-Type: text/plain; charset=iso-8859-1\n\nHello\n")
+	if _, skip := Apply(latin1, Footer{Text: "Vertraulich – nur für den Empfänger."}); skip != SkipCharset {
+		t.Errorf("latin-1 body: skip = %q", skip)
+	}
+}
+
+func TestApplySkips(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{"s/mime signed", "Content-Type: multipart/signed; protocol=\"application/pkcs7-signature\"; boundary=b\n\n--b\n\nHi\n--b--\n", SkipSigned},
+		{"s/mime encrypted", "Content-Type: application/pkcs7-mime; smime-type=enveloped-data\nContent-Transfer-Encoding: base64\n\nMIAGCSqGSIb3DQEHA6CAMIACAQAx\n", SkipSigned},
+		{"pgp/mime", "Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\"; boundary=b\n\n--b\n\nVersion: 1\n--b--\n", SkipSigned},
+		{"inline pgp", "Content-Type: text/plain\n\n-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\nHi\n", SkipSigned},
+		{"attachment only", "Content-Type: application/pdf\n\n%PDF-1.4\n", SkipNoBody},
+		{"unclosed multipart", "Content-Type: multipart/mixed; boundary=b\n\n--b\n\nHi\n", SkipMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := crlf("From: alice@example.com\n" + tt.msg)
+			out, skip := Apply(msg, disclaimer)
+			if skip != tt.want {
+				t.Errorf("skip = %q, want %q", skip, tt.want)
+			}
+			if !bytes.Equal(out, msg) {
+				t.Error("skipped message was changed")
+			}
+		})
+	}
+
+	if _, skip := Apply(crlf("From: a@example.com\n\nHi\n"), Footer{}); skip != SkipNoFooter {
+		t.Errorf("no footer: skip = %q", skip)
+	}
+}
@@ -0,0 +1,175 @@
+// Package greylist implements triplet-based greylisting for inbound mail.
+//
+// A delivery attempt is identified by the triplet (sender network, envelope
+// sender, envelope recipient). The first attempt for a triplet is deferred
+// with a temporary failure; a retry after the configured delay and within the
+// retry window is accepted and the triplet is whitelisted so later mail passes
+// straight through. Legitimate MTAs retry, while much bulk spam software does
+// not.
+package greylist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+)
+
+// Result is the outcome of a greylist check
+type Result string
+
+const (
+	ResultPass       Result = "passed"     // Triplet is whitelisted or retried in time
+	ResultGreylisted Result = "greylisted" // Attempt deferred with a temporary failure
+	ResultBypassed   Result = "bypassed"   // Sender is on the allowlist
+)
+
+// Greylister checks delivery attempts against the greylist stored in Redis
+type Greylister struct {
+	redis    *redis.Client
+	config   *config.GreylistConfig
+	logger   *zap.Logger
+	networks []*net.IPNet
+	senders  map[string]bool
+	now      func() time.Time
+}
+
+// New creates a new Greylister. Invalid allowlist entries are logged and ignored.
+func New(redisClient *redis.Client, cfg *config.GreylistConfig, logger *zap.Logger) *Greylister {
+	g := &Greylister{
+		redis:   redisClient,
+		config:  cfg,
+		logger:  logger,
+		senders: make(map[string]bool),
+		now:     time.Now,
+	}
+
+	for _, entry := range cfg.Allowlist {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				logger.Warn("Ignoring invalid greylist allowlist entry", zap.String("entry", entry))
+				continue
+			}
+			g.networks = append(g.networks, network)
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			g.networks = append(g.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		default:
+			// Sender address or sender domain
+			g.senders[entry] = true
+		}
+	}
+
+	return g
+}
+
+// Allowlisted reports whether the client IP or envelope sender is on the
+// allowlist and should never be greylisted
+func (g *Greylister) Allowlisted(ip net.IP, from string) bool {
+	for _, network := range g.networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	from = strings.ToLower(from)
+	if g.senders[from] {
+		return true
+	}
+	if at := strings.LastIndex(from, "@"); at >= 0 && g.senders[from[at+1:]] {
+		return true
+	}
+	return false
+}
+
+// Check records a delivery attempt for the triplet and reports whether it
+// should be accepted. Callers should fail open on error.
+func (g *Greylister) Check(ctx context.Context, ip net.IP, from, to string) (Result, error) {
+	if g.Allowlisted(ip, from) {
+		return ResultBypassed, nil
+	}
+
+	triplet := tripletKey(ip, from, to)
+	passKey := "smtp:greylist:pass:" + triplet
+	pendingKey := "smtp:greylist:pending:" + triplet
+
+	// Whitelisted triplets pass, and each delivery extends the whitelisting
+	passed, err := g.redis.Expire(ctx, passKey, g.config.WhitelistLifetime).Result()
+	if err != nil {
+		return "", fmt.Errorf("check greylist whitelist: %w", err)
+	}
+	if passed {
+		return ResultPass, nil
+	}
+
+	now := g.now()
+
+	// First contact records the time; the key expires when the retry window closes
+	created, err := g.redis.SetNX(ctx, pendingKey, now.Unix(), g.config.RetryWindow).Result()
+	if err != nil {
+		return "", fmt.Errorf("record greylist triplet: %w", err)
+	}
+	if created {
+		return ResultGreylisted, nil
+	}
+
+	value, err := g.redis.Get(ctx, pendingKey).Result()
+	if errors.Is(err, redis.Nil) {
+		// Expired between the two calls; treat as first contact on the next retry
+		return ResultGreylisted, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get greylist triplet: %w", err)
+	}
+
+	firstSeen, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("parse greylist triplet: %w", err)
+	}
+	if now.Sub(time.Unix(firstSeen, 0)) < g.config.Delay {
+		return ResultGreylisted, nil
+	}
+
+	pipe := g.redis.TxPipeline()
+	pipe.Set(ctx, passKey, now.Unix(), g.config.WhitelistLifetime)
+	pipe.Del(ctx, pendingKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("whitelist greylist triplet: %w", err)
+	}
+
+	g.logger.Debug("Greylist triplet whitelisted",
+		zap.String("triplet", triplet),
+		zap.Duration("retried_after", now.Sub(time.Unix(firstSeen, 0))))
+
+	return ResultPass, nil
+}
+
+// tripletKey builds the Redis key suffix for a triplet. The client address is
+// reduced to its /24 (IPv4) or /64 (IPv6) network since large senders retry
+// from different hosts in the same pool.
+func tripletKey(ip net.IP, from, to string) string {
+	network := "unknown"
+	if ip4 := ip.To4(); ip4 != nil {
+		network = ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	} else if ip != nil {
+		network = ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return network + ":" + strings.ToLower(from) + ":" + strings.ToLower(to)
+}
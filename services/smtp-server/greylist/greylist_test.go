@@ -0,0 +1,132 @@
+package greylist
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+)
+
+func newTestGreylister(t *testing.T, allowlist ...string) (*Greylister, *miniredis.Miniredis, *time.Time) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	cfg := &config.GreylistConfig{
+		Enabled:           true,
+		Delay:             5 * time.Minute,
+		RetryWindow:       4 * time.Hour,
+		WhitelistLifetime: 35 * 24 * time.Hour,
+		Allowlist:         allowlist,
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	g := New(client, cfg, zap.NewNop())
+	g.now = func() time.Time { return now }
+	return g, mr, &now
+}
+
+func TestCheck_RetryAfterDelay(t *testing.T) {
+	g, mr, now := newTestGreylister(t)
+	ctx := context.Background()
+	ip := net.ParseIP("192.0.2.10")
+
+	result, err := g.Check(ctx, ip, "sender@example.com", "user@local.test")
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if result != ResultGreylisted {
+		t.Fatalf("first attempt = %s, want %s", result, ResultGreylisted)
+	}
+
+	// Retrying too early is still deferred
+	*now = now.Add(time.Minute)
+	mr.FastForward(time.Minute)
+	if result, _ := g.Check(ctx, ip, "sender@example.com", "user@local.test"); result != ResultGreylisted {
+		t.Fatalf("early retry = %s, want %s", result, ResultGreylisted)
+	}
+
+	// A retry from another host in the same /24 after the delay passes
+	*now = now.Add(5 * time.Minute)
+	mr.FastForward(5 * time.Minute)
+	if result, _ := g.Check(ctx, net.ParseIP("192.0.2.99"), "Sender@Example.com", "user@local.test"); result != ResultPass {
+		t.Fatalf("retry after delay = %s, want %s", result, ResultPass)
+	}
+
+	// The triplet is now whitelisted
+	*now = now.Add(24 * time.Hour)
+	mr.FastForward(24 * time.Hour)
+	if result, _ := g.Check(ctx, ip, "sender@example.com", "user@local.test"); result != ResultPass {
+		t.Fatalf("whitelisted triplet = %s, want %s", result, ResultPass)
+	}
+
+	// Other triplets are unaffected
+	if result, _ := g.Check(ctx, ip, "sender@example.com", "other@local.test"); result != ResultGreylisted {
+		t.Fatalf("new recipient = %s, want %s", result, ResultGreylisted)
+	}
+}
+
+func TestCheck_RetryAfterWindow(t *testing.T) {
+	g, mr, now := newTestGreylister(t)
+	ctx := context.Background()
+	ip := net.ParseIP("2001:db8::1")
+
+	if result, _ := g.Check(ctx, ip, "sender@example.com", "user@local.test"); result != ResultGreylisted {
+		t.Fatalf("first attempt = %s, want %s", result, ResultGreylisted)
+	}
+
+	// Once the retry window has closed the sender starts over
+	*now = now.Add(5 * time.Hour)
+	mr.FastForward(5 * time.Hour)
+	if result, _ := g.Check(ctx, ip, "sender@example.com", "user@local.test"); result != ResultGreylisted {
+		t.Fatalf("retry after window = %s, want %s", result, ResultGreylisted)
+	}
+}
+
+func TestCheck_Allowlist(t *testing.T) {
+	g, _, _ := newTestGreylister(t, "198.51.100.0/24", "203.0.113.7", "partner.test", "alerts@vendor.test", "not-a-cidr/99")
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		ip   string
+		from string
+		want Result
+	}{
+		{"allowlisted network", "198.51.100.20", "a@example.com", ResultBypassed},
+		{"allowlisted address", "203.0.113.7", "a@example.com", ResultBypassed},
+		{"allowlisted domain", "192.0.2.1", "news@Partner.test", ResultBypassed},
+		{"allowlisted sender", "192.0.2.1", "alerts@vendor.test", ResultBypassed},
+		{"other address at allowlisted sender's domain", "192.0.2.1", "sales@vendor.test", ResultGreylisted},
+		{"unlisted", "203.0.113.8", "a@example.com", ResultGreylisted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := g.Check(ctx, net.ParseIP(tt.ip), tt.from, "user@local.test")
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("Check() = %s, want %s", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck_RedisError(t *testing.T) {
+	g, mr, _ := newTestGreylister(t)
+	mr.Close()
+
+	if _, err := g.Check(context.Background(), net.ParseIP("192.0.2.10"), "a@example.com", "user@local.test"); err == nil {
+		t.Error("Check() error = nil, want error when Redis is unavailable")
+	}
+}
@@ -17,11 +17,15 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/oonrumail/smtp-server/arc"
 	"github.com/oonrumail/smtp-server/config"
 	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/mdn"
 	"github.com/oonrumail/smtp-server/queue"
 	"github.com/oonrumail/smtp-server/repository"
+	"github.com/oonrumail/smtp-server/sieve"
 	"github.com/oonrumail/smtp-server/smtp"
+	"github.com/oonrumail/smtp-server/vacation"
 )
 
 func main() {
@@ -78,6 +82,7 @@ func main() {
 
 	// Initialize queue manager
 	queueManager := queue.NewManager(cfg, redisClient, messageRepo, domainCache, logger.Named("queue"))
+	queueManager.SetForwardSealer(arc.NewSigner(domainCache, cfg.Server.Hostname, logger.Named("arc")))
 	if err := queueManager.Start(ctx); err != nil {
 		logger.Fatal("Failed to start queue manager", zap.Error(err))
 	}
@@ -88,8 +93,14 @@ func main() {
 		logger.Fatal("Failed to start SMTP server", zap.Error(err))
 	}
 
+	// Sieve script, vacation responder and read receipt management are served
+	// alongside metrics on the internal listener
+	sieveHandler := sieve.NewHandler(messageRepo, &cfg.Sieve, logger.Named("sieve"))
+	vacationHandler := vacation.NewHandler(messageRepo, &cfg.Vacation, logger.Named("vacation"))
+	mdnHandler := mdn.NewHandler(messageRepo, queueManager, &cfg.ReadReceipts, logger.Named("mdn"))
+
 	// Initialize metrics server
-	metricsServer := initMetricsServer(cfg.Metrics, smtpServer)
+	metricsServer := initMetricsServer(cfg.Metrics, smtpServer, sieveHandler, vacationHandler, mdnHandler)
 	metricsAddr := fmt.Sprintf("%s:%d", cfg.Metrics.Host, cfg.Metrics.Port)
 	go func() {
 		logger.Info("Starting metrics server", zap.String("addr", metricsAddr))
@@ -105,6 +116,15 @@ func main() {
 
 	logger.Info("Shutting down...")
 
+	// Drain SMTP connections first, while the metrics server still reports
+	// the draining state
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer drainCancel()
+
+	if err := smtpServer.Stop(drainCtx); err != nil {
+		logger.Error("Failed to stop SMTP server", zap.Error(err))
+	}
+
 	// Create shutdown context with timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
@@ -114,10 +134,6 @@ func main() {
 		logger.Error("Failed to stop metrics server", zap.Error(err))
 	}
 
-	if err := smtpServer.Stop(shutdownCtx); err != nil {
-		logger.Error("Failed to stop SMTP server", zap.Error(err))
-	}
-
 	if err := queueManager.Stop(shutdownCtx); err != nil {
 		logger.Error("Failed to stop queue manager", zap.Error(err))
 	}
@@ -202,16 +218,20 @@ func initRedis(cfg config.RedisConfig) *redis.Client {
 	})
 }
 
-func initMetricsServer(cfg config.MetricsConfig, smtpServer *smtp.Server) *http.Server {
+func initMetricsServer(cfg config.MetricsConfig, smtpServer *smtp.Server, sieveHandler *sieve.Handler, vacationHandler *vacation.Handler, mdnHandler *mdn.Handler) *http.Server {
 	// Register SMTP metrics
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(prometheus.NewGoCollector())
 	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	smtpServer.Metrics().Register(registry)
 
 	mux := http.NewServeMux()
 	mux.Handle(cfg.Path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
 	mux.HandleFunc("/health", healthHandler)
-	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/ready", readyHandler(smtpServer))
+	sieveHandler.Register(mux)
+	vacationHandler.Register(mux)
+	mdnHandler.Register(mux)
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	return &http.Server{
@@ -227,8 +247,18 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
-func readyHandler(w http.ResponseWriter, r *http.Request) {
-	// Check dependencies
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+// readyHandler reports the server not ready once it is draining, so load
+// balancers stop sending it connections
+func readyHandler(smtpServer *smtp.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if smtpServer.Draining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("draining"))
+			return
+		}
+
+		// Check dependencies
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
 }
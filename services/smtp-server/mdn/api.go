@@ -0,0 +1,198 @@
+package mdn
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// Store persists read receipt settings. It is implemented by
+// repository.MessageRepository.
+type Store interface {
+	UserExists(ctx context.Context, userID string) (bool, error)
+	GetReadReceiptPolicy(ctx context.Context, userID string) (domain.ReadReceiptPolicy, error)
+	SaveReadReceiptPolicy(ctx context.Context, userID string, policy domain.ReadReceiptPolicy) error
+	ListPendingMDNRequests(ctx context.Context, userID string) ([]*domain.MDNRequest, error)
+}
+
+// Sender sends read receipts the user confirmed. It is implemented by
+// queue.Manager.
+type Sender interface {
+	SendReadReceipt(ctx context.Context, requestID string) (bool, error)
+}
+
+// Handler serves the read receipt API:
+//
+//	GET  /api/v1/users/{id}/read-receipts
+//	PUT  /api/v1/users/{id}/read-receipts
+//	GET  /api/v1/users/{id}/read-receipts/pending
+//	POST /api/v1/read-receipts/{id}/send
+//
+// The last two are used by clients of users with the prompt policy to ask
+// the user about a displayed message's receipt and send it if they agree.
+type Handler struct {
+	store  Store
+	sender Sender
+	config *config.ReadReceiptsConfig
+	logger *zap.Logger
+}
+
+// NewHandler creates a new read receipt handler
+func NewHandler(store Store, sender Sender, cfg *config.ReadReceiptsConfig, logger *zap.Logger) *Handler {
+	return &Handler{
+		store:  store,
+		sender: sender,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Register adds the API routes to mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/users/{id}/read-receipts", h.authorize(h.getPolicy))
+	mux.HandleFunc("PUT /api/v1/users/{id}/read-receipts", h.authorize(h.putPolicy))
+	mux.HandleFunc("GET /api/v1/users/{id}/read-receipts/pending", h.authorize(h.listPending))
+	mux.HandleFunc("POST /api/v1/read-receipts/{id}/send", h.authorize(h.send))
+}
+
+// policyBody is the body of GET and PUT responses and PUT requests
+type policyBody struct {
+	Policy domain.ReadReceiptPolicy `json:"policy"`
+}
+
+// authorize only lets requests carrying the internal secret through
+func (h *Handler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get("X-Internal-Secret")
+		if h.config.InternalSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.config.InternalSecret)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) getPolicy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	policy, err := h.store.GetReadReceiptPolicy(r.Context(), userID)
+	if err != nil {
+		h.internalError(w, "get read receipt policy", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, policyBody{Policy: policy})
+}
+
+func (h *Handler) putPolicy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	var req policyBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	switch req.Policy {
+	case domain.ReadReceiptAlways, domain.ReadReceiptPrompt, domain.ReadReceiptNever:
+	default:
+		writeError(w, http.StatusUnprocessableEntity, "policy must be always, prompt or never")
+		return
+	}
+
+	if err := h.store.SaveReadReceiptPolicy(r.Context(), userID, req.Policy); err != nil {
+		h.internalError(w, "save read receipt policy", err)
+		return
+	}
+
+	h.logger.Info("Read receipt policy saved",
+		zap.String("user_id", userID),
+		zap.String("policy", string(req.Policy)))
+
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (h *Handler) listPending(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := h.store.ListPendingMDNRequests(r.Context(), userID)
+	if err != nil {
+		h.internalError(w, "list pending read receipt requests", err)
+		return
+	}
+	if requests == nil {
+		requests = []*domain.MDNRequest{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"requests": requests})
+}
+
+func (h *Handler) send(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid read receipt request id")
+		return
+	}
+
+	sent, err := h.sender.SendReadReceipt(r.Context(), id.String())
+	if err != nil {
+		h.internalError(w, "send read receipt", err)
+		return
+	}
+	if !sent {
+		writeError(w, http.StatusNotFound, "no unsent read receipt request")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// userID returns the user of the request if it exists
+func (h *Handler) userID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return "", false
+	}
+
+	exists, err := h.store.UserExists(r.Context(), id.String())
+	if err != nil {
+		h.internalError(w, "check user", err)
+		return "", false
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "user not found")
+		return "", false
+	}
+	return id.String(), true
+}
+
+func (h *Handler) internalError(w http.ResponseWriter, op string, err error) {
+	h.logger.Error("Read receipt API request failed", zap.String("op", op), zap.Error(err))
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,169 @@
+// Package mdn implements read receipts, the message disposition
+// notifications of RFC 8098. A request is recorded when a message with
+// Disposition-Notification-To is delivered to a local mailbox, and the
+// receipt is sent once the message is displayed: automatically or after the
+// user confirms, depending on their read receipt policy. Receipts are never
+// requested by bulk or automated mail or by messages with a null return path.
+package mdn
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// maxOriginalHeaders bounds the original header block returned in a receipt
+const maxOriginalHeaders = 16384
+
+// Skip returns why a delivered message doesn't get a read receipt, or "" if
+// it does. returnPath is the envelope sender.
+func Skip(header mail.Header, returnPath string) string {
+	if header.Get("Disposition-Notification-To") == "" {
+		return "no receipt requested"
+	}
+	if returnPath == "" {
+		return "null return path"
+	}
+	if NotifyTo(header) == "" {
+		return "invalid Disposition-Notification-To"
+	}
+	if MessageID(header) == "" {
+		return "no Message-ID"
+	}
+
+	if v := strings.ToLower(strings.TrimSpace(header.Get("Auto-Submitted"))); v != "" && v != "no" {
+		return "auto-submitted"
+	}
+	switch strings.ToLower(strings.TrimSpace(header.Get("Precedence"))) {
+	case "bulk", "list", "junk":
+		return "bulk precedence"
+	}
+	for _, name := range []string{"List-Id", "List-Unsubscribe", "List-Post"} {
+		if header.Get(name) != "" {
+			return "mailing list"
+		}
+	}
+	return ""
+}
+
+// NotifyTo returns the address read receipts are requested at. Only the
+// first address of Disposition-Notification-To is used.
+func NotifyTo(header mail.Header) string {
+	list, err := header.AddressList("Disposition-Notification-To")
+	if err != nil || len(list) == 0 {
+		return ""
+	}
+	return list[0].Address
+}
+
+// MessageID returns the message's Message-ID with its angle brackets
+func MessageID(header mail.Header) string {
+	id := strings.TrimSpace(header.Get("Message-ID"))
+	start := strings.IndexByte(id, '<')
+	end := strings.LastIndexByte(id, '>')
+	if start == -1 || end <= start+1 {
+		return ""
+	}
+	return id[start : end+1]
+}
+
+// NewRequest builds the read receipt request of a message delivered to the
+// mailbox address finalRecipient. data is the raw message.
+func NewRequest(header mail.Header, data []byte, returnPath, finalRecipient string) *domain.MDNRequest {
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	if err != nil {
+		subject = header.Get("Subject")
+	}
+	notifyTo := NotifyTo(header)
+
+	return &domain.MDNRequest{
+		MessageID:       MessageID(header),
+		NotifyTo:        notifyTo,
+		ReturnPath:      returnPath,
+		FinalRecipient:  finalRecipient,
+		Subject:         subject,
+		OriginalHeaders: headerBlock(data),
+		// RFC 8098 Section 2.1: receipts to an address other than the return
+		// path are only sent with the user's consent
+		AutoAllowed: strings.EqualFold(notifyTo, returnPath),
+		Status:      "pending",
+	}
+}
+
+// Build returns the read receipt for a request. manual is true when the user
+// confirmed sending it rather than their policy sending it automatically.
+func Build(req *domain.MDNRequest, hostname string, manual bool, now time.Time) []byte {
+	boundary := uuid.New().String()
+	disposition := "automatic-action/MDN-sent-automatically; displayed"
+	if manual {
+		disposition = "manual-action/MDN-sent-manually; displayed"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: <%s>\r\n", req.FinalRecipient)
+	fmt.Fprintf(&buf, "To: <%s>\r\n", req.NotifyTo)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Read: "+req.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", uuid.New().String(), hostname)
+	fmt.Fprintf(&buf, "In-Reply-To: %s\r\n", req.MessageID)
+	fmt.Fprintf(&buf, "References: %s\r\n", req.MessageID)
+	if !manual {
+		buf.WriteString("Auto-Submitted: auto-replied\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/report; report-type=disposition-notification;\r\n\tboundary=\"%s\"\r\n", boundary)
+	buf.WriteString("\r\n")
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "The message sent to %s with subject \"%s\" was displayed on %s.\r\n",
+		req.FinalRecipient, req.Subject, now.Format(time.RFC1123Z))
+	buf.WriteString("This is no guarantee that the message has been read or understood.\r\n")
+	buf.WriteString("\r\n")
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: message/disposition-notification\r\n")
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "Reporting-UA: %s; OONRUMAIL\r\n", hostname)
+	fmt.Fprintf(&buf, "Final-Recipient: rfc822; %s\r\n", req.FinalRecipient)
+	fmt.Fprintf(&buf, "Original-Message-ID: %s\r\n", req.MessageID)
+	fmt.Fprintf(&buf, "Disposition: %s\r\n", disposition)
+	buf.WriteString("\r\n")
+
+	if req.OriginalHeaders != "" {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		buf.WriteString("Content-Type: text/rfc822-headers\r\n")
+		buf.WriteString("\r\n")
+		buf.WriteString(req.OriginalHeaders)
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes()
+}
+
+// headerBlock returns the header section of a raw message, truncated at a
+// header boundary if it is too long
+func headerBlock(data []byte) string {
+	end := bytes.Index(data, []byte("\r\n\r\n"))
+	if end == -1 {
+		end = len(data)
+	}
+	headers := data[:end]
+	if len(headers) > maxOriginalHeaders {
+		headers = headers[:maxOriginalHeaders]
+		if i := bytes.LastIndex(headers, []byte("\r\n")); i > 0 {
+			headers = headers[:i]
+		}
+	}
+	return string(headers) + "\r\n"
+}
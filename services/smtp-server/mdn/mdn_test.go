@@ -0,0 +1,118 @@
+package mdn
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func header(t *testing.T, raw string) mail.Header {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(raw + "\r\nBody\r\n"))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	return msg.Header
+}
+
+func TestSkip(t *testing.T) {
+	requested := "From: alice@example.org\r\nTo: bob@example.com\r\nSubject: Contract\r\n" +
+		"Message-ID: <c1@example.org>\r\nDisposition-Notification-To: Alice <alice@example.org>\r\n"
+
+	tests := []struct {
+		name       string
+		raw        string
+		returnPath string
+		want       string
+	}{
+		{"requested", requested, "alice@example.org", ""},
+		{"auto-submitted no", requested + "Auto-Submitted: no\r\n", "alice@example.org", ""},
+		{"not requested", "From: alice@example.org\r\nMessage-ID: <c1@example.org>\r\n", "alice@example.org", "no receipt requested"},
+		{"null return path", requested, "", "null return path"},
+		{"invalid address", "Message-ID: <c1@example.org>\r\nDisposition-Notification-To: alice\r\n", "alice@example.org", "invalid Disposition-Notification-To"},
+		{"no message id", "Disposition-Notification-To: alice@example.org\r\n", "alice@example.org", "no Message-ID"},
+		{"auto-replied", requested + "Auto-Submitted: auto-replied\r\n", "alice@example.org", "auto-submitted"},
+		{"bulk precedence", requested + "Precedence: bulk\r\n", "alice@example.org", "bulk precedence"},
+		{"mailing list", requested + "List-Id: <news.lists.example>\r\n", "alice@example.org", "mailing list"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Skip(header(t, tt.raw), tt.returnPath); got != tt.want {
+				t.Errorf("Skip() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	raw := "From: alice@example.org\r\nSubject: =?UTF-8?Q?Caf=C3=A9?=\r\nMessage-ID:  <c1@example.org> \r\n" +
+		"Disposition-Notification-To: Alice <Alice@example.org>\r\n"
+	data := []byte(raw + "\r\nBody\r\n")
+
+	req := NewRequest(header(t, raw), data, "alice@example.org", "bob@example.com")
+	if req.MessageID != "<c1@example.org>" {
+		t.Errorf("MessageID = %q", req.MessageID)
+	}
+	if req.Subject != "Café" {
+		t.Errorf("Subject = %q", req.Subject)
+	}
+	if !req.AutoAllowed {
+		t.Error("receipt to the return path is not auto-allowed")
+	}
+	if req.OriginalHeaders != raw {
+		t.Errorf("OriginalHeaders = %q", req.OriginalHeaders)
+	}
+
+	req = NewRequest(header(t, raw), data, "bounces@mailer.example", "bob@example.com")
+	if req.AutoAllowed {
+		t.Error("receipt to another address than the return path is auto-allowed")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	raw := "From: alice@example.org\r\nSubject: Contract\r\nMessage-ID: <c1@example.org>\r\n" +
+		"Disposition-Notification-To: alice@example.org\r\n"
+	req := NewRequest(header(t, raw), []byte(raw+"\r\nBody\r\n"), "alice@example.org", "bob@example.com")
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	receipt, err := mail.ReadMessage(bytes.NewReader(Build(req, "mx.example.com", false, now)))
+	if err != nil {
+		t.Fatalf("receipt does not parse: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(receipt.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["report-type"] != "disposition-notification" {
+		t.Errorf("Content-Type = %q", receipt.Header.Get("Content-Type"))
+	}
+	if got := receipt.Header.Get("Auto-Submitted"); got != "auto-replied" {
+		t.Errorf("Auto-Submitted = %q", got)
+	}
+	if got := receipt.Header.Get("In-Reply-To"); got != "<c1@example.org>" {
+		t.Errorf("In-Reply-To = %q", got)
+	}
+
+	body := new(bytes.Buffer)
+	body.ReadFrom(receipt.Body)
+	for _, want := range []string{
+		"Final-Recipient: rfc822; bob@example.com",
+		"Original-Message-ID: <c1@example.org>",
+		"Disposition: automatic-action/MDN-sent-automatically; displayed",
+		"Content-Type: text/rfc822-headers",
+	} {
+		if !strings.Contains(body.String(), want) {
+			t.Errorf("receipt is missing %q", want)
+		}
+	}
+
+	manual, err := mail.ReadMessage(bytes.NewReader(Build(req, "mx.example.com", true, now)))
+	if err != nil {
+		t.Fatalf("manual receipt does not parse: %v", err)
+	}
+	if got := manual.Header.Get("Auto-Submitted"); got != "" {
+		t.Errorf("manual receipt Auto-Submitted = %q", got)
+	}
+}
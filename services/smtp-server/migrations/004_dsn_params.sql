@@ -0,0 +1,4 @@
+-- Migration: Store DSN (RFC 3461) parameters with queued messages
+-- RET/ENVID from MAIL FROM and NOTIFY/ORCPT per recipient from RCPT TO
+
+ALTER TABLE message_queue ADD COLUMN IF NOT EXISTS dsn_params JSONB;
@@ -0,0 +1,12 @@
+-- Migration: Per-mailbox Sieve (RFC 5228) filter scripts
+-- The active script is run on every message delivered to the mailbox
+
+CREATE TABLE IF NOT EXISTS sieve_scripts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    mailbox_id UUID NOT NULL UNIQUE REFERENCES mailboxes(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT 'default',
+    script TEXT NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,16 @@
+-- Migration: Per-mailbox vacation (out-of-office) auto-responders
+-- Replies follow RFC 3834; the senders already answered are tracked in Redis
+
+CREATE TABLE IF NOT EXISTS vacation_responders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    mailbox_id UUID NOT NULL UNIQUE REFERENCES mailboxes(id) ON DELETE CASCADE,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    start_at TIMESTAMP WITH TIME ZONE,
+    end_at TIMESTAMP WITH TIME ZONE,
+    reply_interval_days INTEGER NOT NULL DEFAULT 7,
+    addresses JSONB NOT NULL DEFAULT '[]',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
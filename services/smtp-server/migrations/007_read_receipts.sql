@@ -0,0 +1,35 @@
+-- Migration: Read receipts (RFC 8098 message disposition notifications)
+-- Requests are recorded on local delivery; the IMAP server marks them
+-- displayed when the message is first seen and the SMTP server sends them
+
+CREATE TABLE IF NOT EXISTS read_receipt_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    policy VARCHAR(10) NOT NULL DEFAULT 'prompt', -- 'always', 'prompt', 'never'
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT valid_read_receipt_policy CHECK (policy IN ('always', 'prompt', 'never'))
+);
+
+CREATE TABLE IF NOT EXISTS mdn_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    mailbox_id UUID NOT NULL REFERENCES mailboxes(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL,
+    domain_id UUID NOT NULL,
+    message_id VARCHAR(998) NOT NULL, -- Message-ID of the original message, with angle brackets
+    notify_to VARCHAR(320) NOT NULL, -- Disposition-Notification-To address
+    return_path VARCHAR(320) NOT NULL,
+    final_recipient VARCHAR(320) NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    original_headers TEXT NOT NULL DEFAULT '',
+    auto_allowed BOOLEAN NOT NULL, -- RFC 8098 2.1: only when notify_to matches the return path
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'displayed', 'sent'
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    displayed_at TIMESTAMP WITH TIME ZONE,
+    sent_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT valid_mdn_request_status CHECK (status IN ('pending', 'displayed', 'sent'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_mdn_requests_message ON mdn_requests(mailbox_id, message_id);
+CREATE INDEX IF NOT EXISTS idx_mdn_requests_displayed ON mdn_requests(displayed_at) WHERE status = 'displayed';
@@ -0,0 +1,327 @@
+package mtasts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const enforcePolicy = "version: STSv1\r\nmode: enforce\r\nmx: mail.example.com\r\nmx: *.backup.example.com\r\nmax_age: 86400\r\n"
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		mode    Mode
+		mx      int
+		maxAge  time.Duration
+		wantErr bool
+	}{
+		{"enforce", enforcePolicy, ModeEnforce, 2, 24 * time.Hour, false},
+		{"testing with LF", "version: STSv1\nmode: testing\nmx: mx.example.com\nmax_age: 604800\n", ModeTesting, 1, 7 * 24 * time.Hour, false},
+		{"none needs no mx", "version: STSv1\nmode: none\nmax_age: 60\n", ModeNone, 0, time.Minute, false},
+		{"max_age capped", "version: STSv1\nmode: testing\nmx: mx.example.com\nmax_age: 99999999\n", ModeTesting, 1, maxPolicyAge, false},
+		{"unknown keys ignored", "version: STSv1\nmode: none\nmax_age: 60\nextension: yes\n", ModeNone, 0, time.Minute, false},
+		{"wrong version", "version: STSv2\nmode: none\nmax_age: 60\n", "", 0, 0, true},
+		{"invalid mode", "version: STSv1\nmode: strict\nmx: mx.example.com\nmax_age: 60\n", "", 0, 0, true},
+		{"enforce without mx", "version: STSv1\nmode: enforce\nmax_age: 60\n", "", 0, 0, true},
+		{"missing max_age", "version: STSv1\nmode: none\n", "", 0, 0, true},
+		{"malformed line", "version: STSv1\nmode none\nmax_age: 60\n", "", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParsePolicy("Example.com", tt.body)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePolicy() = %+v, want error", policy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePolicy() error = %v", err)
+			}
+			if policy.Domain != "example.com" || policy.Mode != tt.mode || len(policy.MX) != tt.mx || policy.MaxAge != tt.maxAge {
+				t.Errorf("ParsePolicy() = %+v, want mode %s, %d mx, max_age %s", policy, tt.mode, tt.mx, tt.maxAge)
+			}
+		})
+	}
+}
+
+func TestPolicyMatchesMX(t *testing.T) {
+	policy, err := ParsePolicy("example.com", enforcePolicy)
+	if err != nil {
+		t.Fatalf("ParsePolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"mail.example.com", true},
+		{"MAIL.example.com.", true},
+		{"mx1.backup.example.com", true},
+		{"backup.example.com", false},
+		{"a.mx1.backup.example.com", false},
+		{"mail.example.com.evil.net", false},
+		{"other.example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := policy.MatchesMX(tt.host); got != tt.want {
+			t.Errorf("MatchesMX(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestParseRecords(t *testing.T) {
+	if id, ok := parseTXT("v=STSv1; id=20260101T000000;"); !ok || id != "20260101T000000" {
+		t.Errorf("parseTXT() = %q, %v", id, ok)
+	}
+	if _, ok := parseTXT("v=spf1 -all"); ok {
+		t.Error("parseTXT() accepted a non-STS record")
+	}
+
+	ruas, ok := parseRUA("v=TLSRPTv1; rua=mailto:tls@example.com, https://reports.example.com/tlsrpt")
+	if !ok || len(ruas) != 2 || ruas[0] != "mailto:tls@example.com" || ruas[1] != "https://reports.example.com/tlsrpt" {
+		t.Errorf("parseRUA() = %v, %v", ruas, ok)
+	}
+	if _, ok := parseRUA("v=TLSRPTv1;"); ok {
+		t.Error("parseRUA() accepted a record without rua")
+	}
+}
+
+// newTestResolver serves policy bodies from a TLS test server, using txt as
+// the domain's _mta-sts record
+func newTestResolver(t *testing.T, txt *string, body *string) (*Resolver, *int32) {
+	t.Helper()
+
+	var fetches int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if *body == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, *body)
+	}))
+	t.Cleanup(server.Close)
+
+	r := NewResolver(5*time.Second, zap.NewNop())
+	r.client = server.Client()
+	r.policyURL = func(string) string { return server.URL + "/.well-known/mta-sts.txt" }
+	r.lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		if *txt == "" {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return []string{*txt}, nil
+	}
+	return r, &fetches
+}
+
+func TestResolverCachesPolicy(t *testing.T) {
+	txt, body := "v=STSv1; id=1", enforcePolicy
+	r, fetches := newTestResolver(t, &txt, &body)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		policy, err := r.Lookup(context.Background(), "example.com")
+		if err != nil || !policy.Enforced() {
+			t.Fatalf("Lookup() = %+v, %v; want enforce policy", policy, err)
+		}
+	}
+	if n := atomic.LoadInt32(fetches); n != 1 {
+		t.Errorf("fetches = %d, want 1 while the id is unchanged", n)
+	}
+
+	// A new id means a new policy
+	txt, body = "v=STSv1; id=2", "version: STSv1\nmode: testing\nmx: mail.example.com\nmax_age: 86400\n"
+	if policy, _ := r.Lookup(context.Background(), "example.com"); policy == nil || policy.Mode != ModeTesting {
+		t.Errorf("Lookup() after id change = %+v, want testing policy", policy)
+	}
+
+	// Removing the record doesn't drop an unexpired policy
+	txt = ""
+	if policy, _ := r.Lookup(context.Background(), "example.com"); policy == nil {
+		t.Error("Lookup() dropped the cached policy when the TXT record disappeared")
+	}
+
+	// Until max_age passes
+	now = now.Add(25 * time.Hour)
+	if policy, _ := r.Lookup(context.Background(), "example.com"); policy != nil {
+		t.Errorf("Lookup() after max_age = %+v, want none", policy)
+	}
+}
+
+func TestResolverFetchFailure(t *testing.T) {
+	txt, body := "v=STSv1; id=1", enforcePolicy
+	r, fetches := newTestResolver(t, &txt, &body)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	if _, err := r.Lookup(context.Background(), "example.com"); err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+
+	// A cached policy outlives a failed refetch
+	txt, body = "v=STSv1; id=2", ""
+	if policy, err := r.Lookup(context.Background(), "example.com"); err != nil || !policy.Enforced() {
+		t.Errorf("Lookup() with failing fetch = %+v, %v; want cached enforce policy", policy, err)
+	}
+
+	// Without one the failure is returned, and not retried right away
+	other, otherFetches := newTestResolver(t, &txt, &body)
+	other.now = r.now
+	for i := 0; i < 2; i++ {
+		policy, err := other.Lookup(context.Background(), "example.com")
+		var fetchErr *FetchError
+		if policy != nil || !errors.As(err, &fetchErr) || fetchErr.Result != ResultPolicyFetchError {
+			t.Errorf("Lookup() = %+v, %v; want sts-policy-fetch-error", policy, err)
+		}
+	}
+	if n := atomic.LoadInt32(fetches); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+	if n := atomic.LoadInt32(otherFetches); n != 1 {
+		t.Errorf("fetches = %d, want 1 within the retry interval", n)
+	}
+}
+
+func TestResolverRejectsUntrustedCertificate(t *testing.T) {
+	txt, body := "v=STSv1; id=1", enforcePolicy
+	r, _ := newTestResolver(t, &txt, &body)
+	r.client = &http.Client{Timeout: 5 * time.Second}
+
+	_, err := r.Lookup(context.Background(), "example.com")
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.Result != ResultWebPKIInvalid {
+		t.Errorf("Lookup() error = %v, want sts-webpki-invalid", err)
+	}
+}
+
+type capturedMail struct {
+	from, to string
+	message  []byte
+}
+
+type fakeMailSender struct {
+	sent []capturedMail
+}
+
+func (f *fakeMailSender) SendTLSReport(ctx context.Context, from, to string, message []byte) error {
+	f.sent = append(f.sent, capturedMail{from, to, message})
+	return nil
+}
+
+func TestReporterSendsFailureReports(t *testing.T) {
+	mailer := &fakeMailSender{}
+	r := NewReporter(ReporterConfig{
+		OrganizationName: "Example Mail",
+		ContactInfo:      "postmaster@sender.example",
+		Hostname:         "mx.sender.example",
+		From:             "noreply@sender.example",
+	}, mailer, zap.NewNop())
+	r.lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		if name != "_smtp._tls.example.com" {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return []string{"v=TLSRPTv1; rua=mailto:tlsrpt@example.com"}, nil
+	}
+
+	policy, err := ParsePolicy("example.com", enforcePolicy)
+	if err != nil {
+		t.Fatalf("ParsePolicy() error = %v", err)
+	}
+	failure := Failure{Result: ResultCertificateExpired, ReceivingMX: "mail.example.com", ReceivingIP: "192.0.2.1", Info: "x509: certificate has expired"}
+	r.RecordSuccess("example.com", policy)
+	r.RecordFailure("example.com", policy, failure)
+	r.RecordFailure("example.com", policy, failure)
+	r.RecordSuccess("clean.example", policy) // No failures, not reported
+
+	r.Flush(context.Background())
+
+	if len(mailer.sent) != 1 {
+		t.Fatalf("sent %d reports, want 1", len(mailer.sent))
+	}
+	sent := mailer.sent[0]
+	if sent.to != "tlsrpt@example.com" || sent.from != "noreply@sender.example" {
+		t.Errorf("report from %q to %q", sent.from, sent.to)
+	}
+
+	report := decodeReportMessage(t, sent.message)
+	if len(report.Policies) != 1 {
+		t.Fatalf("policies = %d, want 1", len(report.Policies))
+	}
+	result := report.Policies[0]
+	if result.Policy.Type != "sts" || result.Policy.Domain != "example.com" || len(result.Policy.String) != 5 {
+		t.Errorf("policy = %+v", result.Policy)
+	}
+	if result.Summary.Successful != 1 || result.Summary.Failed != 2 {
+		t.Errorf("summary = %+v, want 1 successful, 2 failed", result.Summary)
+	}
+	if len(result.FailureDetails) != 1 || result.FailureDetails[0].ResultType != ResultCertificateExpired ||
+		result.FailureDetails[0].FailedSessionCount != 2 || result.FailureDetails[0].ReceivingIP != "192.0.2.1" {
+		t.Errorf("failure details = %+v", result.FailureDetails)
+	}
+
+	// The period was reset
+	r.Flush(context.Background())
+	if len(mailer.sent) != 1 {
+		t.Errorf("sent %d reports after an empty period, want 1", len(mailer.sent))
+	}
+}
+
+// decodeReportMessage extracts the JSON report from a TLS-RPT message
+func decodeReportMessage(t *testing.T, message []byte) *Report {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	if msg.Header.Get("TLS-Report-Domain") != "example.com" {
+		t.Errorf("TLS-Report-Domain = %q", msg.Header.Get("TLS-Report-Domain"))
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["report-type"] != "tlsrpt" {
+		t.Fatalf("Content-Type = %q", msg.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("no tlsrpt attachment: %v", err)
+		}
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), "application/tlsrpt+gzip") {
+			continue
+		}
+		gz, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, part))
+		if err != nil {
+			t.Fatalf("gunzip: %v", err)
+		}
+		var report Report
+		if err := json.NewDecoder(gz).Decode(&report); err != nil {
+			t.Fatalf("decode report: %v", err)
+		}
+		return &report
+	}
+}
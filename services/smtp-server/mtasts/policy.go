@@ -0,0 +1,152 @@
+// Package mtasts implements the sending side of SMTP MTA Strict Transport
+// Security (RFC 8461) and SMTP TLS Reporting (RFC 8460).
+//
+// A domain publishing an MTA-STS policy in "enforce" mode promises that its
+// MX hosts accept STARTTLS with a certificate valid for their name. Mail to
+// such a domain is only delivered to listed MX hosts over verified TLS, which
+// defeats STARTTLS stripping and MX spoofing. In "testing" mode failures are
+// reported through TLS-RPT but mail is still delivered.
+package mtasts
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode is the mode of an MTA-STS policy
+type Mode string
+
+const (
+	ModeEnforce Mode = "enforce"
+	ModeTesting Mode = "testing"
+	ModeNone    Mode = "none"
+)
+
+// maxPolicyAge is the largest max_age a policy may declare (about a year)
+const maxPolicyAge = 31557600 * time.Second
+
+// Policy is a parsed MTA-STS policy
+type Policy struct {
+	Domain string
+	ID     string // From the _mta-sts TXT record
+	Mode   Mode
+	MX     []string // Exact host names, or "*." wildcards for one label
+	MaxAge time.Duration
+
+	// Raw holds the policy lines as fetched, for TLS-RPT reports
+	Raw []string
+
+	FetchedAt time.Time
+}
+
+// Enforced reports whether deliveries must fail rather than fall back
+func (p *Policy) Enforced() bool {
+	return p != nil && p.Mode == ModeEnforce
+}
+
+// Expired reports whether the policy's max_age has passed at now
+func (p *Policy) Expired(now time.Time) bool {
+	return !now.Before(p.FetchedAt.Add(p.MaxAge))
+}
+
+// MatchesMX reports whether host is one of the policy's MX hosts. A pattern
+// "*.example.com" matches exactly one extra label, as in RFC 8461 section 4.1.
+func (p *Policy) MatchesMX(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.MX {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			label, rest, found := strings.Cut(host, ".")
+			if found && label != "" && rest == suffix {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePolicy parses the body of an mta-sts.txt policy file (RFC 8461
+// section 3.2). Unknown keys are ignored.
+func ParsePolicy(domain, body string) (*Policy, error) {
+	p := &Policy{Domain: strings.ToLower(domain)}
+	var version string
+	maxAgeSet := false
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed policy line %q", line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		p.Raw = append(p.Raw, key+": "+value)
+
+		switch key {
+		case "version":
+			version = value
+		case "mode":
+			p.Mode = Mode(value)
+		case "max_age":
+			seconds, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max_age %q", value)
+			}
+			p.MaxAge = time.Duration(seconds) * time.Second
+			if p.MaxAge > maxPolicyAge {
+				p.MaxAge = maxPolicyAge
+			}
+			maxAgeSet = true
+		case "mx":
+			p.MX = append(p.MX, strings.ToLower(strings.TrimSuffix(value, ".")))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if version != "STSv1" {
+		return nil, fmt.Errorf("unsupported policy version %q", version)
+	}
+	switch p.Mode {
+	case ModeEnforce, ModeTesting:
+		if len(p.MX) == 0 {
+			return nil, fmt.Errorf("%s policy lists no mx", p.Mode)
+		}
+	case ModeNone:
+	default:
+		return nil, fmt.Errorf("invalid mode %q", p.Mode)
+	}
+	if !maxAgeSet {
+		return nil, fmt.Errorf("policy has no max_age")
+	}
+
+	return p, nil
+}
+
+// parseTXT returns the policy id of a _mta-sts TXT record, or ok false if the
+// record is not an MTA-STS record
+func parseTXT(record string) (id string, ok bool) {
+	fields := strings.Split(record, ";")
+	if strings.TrimSpace(fields[0]) != "v=STSv1" {
+		return "", false
+	}
+	for _, field := range fields[1:] {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if found && key == "id" && value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,225 @@
+package mtasts
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxPolicySize bounds the policy file, as RFC 8461 section 3.3 suggests
+	maxPolicySize = 64 * 1024
+
+	// fetchRetryInterval is how long a failed fetch is remembered before the
+	// policy host is tried again, so every queued message doesn't refetch
+	fetchRetryInterval = 5 * time.Minute
+)
+
+// FetchError reports a published policy that could not be retrieved. Result
+// is the TLS-RPT result type describing the failure.
+type FetchError struct {
+	Domain string
+	Result ResultType
+	Err    error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("fetch MTA-STS policy for %s: %v", e.Domain, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// Resolver discovers and caches the MTA-STS policies of destination domains.
+// A cached policy is used until its max_age passes, or sooner if the domain's
+// _mta-sts TXT record announces a new policy id.
+type Resolver struct {
+	client *http.Client
+	logger *zap.Logger
+
+	// Replaced in tests
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
+	policyURL func(domain string) string
+	now       func() time.Time
+
+	mu       sync.Mutex
+	policies map[string]*Policy
+	failures map[string]*FetchError
+	failedAt map[string]time.Time
+}
+
+// NewResolver creates a policy resolver. Policy fetches are bounded by
+// fetchTimeout and never follow redirects.
+func NewResolver(fetchTimeout time.Duration, logger *zap.Logger) *Resolver {
+	resolver := &net.Resolver{}
+	return &Resolver{
+		client: &http.Client{
+			Timeout: fetchTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig:     &tls.Config{MinVersion: tls.VersionTLS12},
+				TLSHandshakeTimeout: fetchTimeout,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger:    logger,
+		lookupTXT: resolver.LookupTXT,
+		policyURL: func(domain string) string {
+			return "https://mta-sts." + domain + "/.well-known/mta-sts.txt"
+		},
+		now:      time.Now,
+		policies: make(map[string]*Policy),
+		failures: make(map[string]*FetchError),
+		failedAt: make(map[string]time.Time),
+	}
+}
+
+// Lookup returns the policy for a destination domain, or nil if it has none.
+// A domain whose TXT record can't be read keeps its cached policy until it
+// expires. A *FetchError is returned when a policy is announced but can't be
+// fetched and no unexpired copy is cached; mail should then be delivered as
+// if the domain had no policy.
+func (r *Resolver) Lookup(ctx context.Context, domain string) (*Policy, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	now := r.now()
+
+	r.mu.Lock()
+	cached := r.policies[domain]
+	if cached != nil && cached.Expired(now) {
+		delete(r.policies, domain)
+		cached = nil
+	}
+	r.mu.Unlock()
+
+	id, found, err := r.lookupID(ctx, domain)
+	if err != nil || !found {
+		// A domain that withdraws its record keeps its policy until max_age
+		// passes, so removing the record can't be used to downgrade
+		return cached, nil
+	}
+	if cached != nil && cached.ID == id {
+		return cached, nil
+	}
+
+	r.mu.Lock()
+	if failure, ok := r.failures[domain]; ok && now.Sub(r.failedAt[domain]) < fetchRetryInterval {
+		r.mu.Unlock()
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, failure
+	}
+	r.mu.Unlock()
+
+	policy, fetchErr := r.fetch(ctx, domain)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if fetchErr != nil {
+		r.failures[domain] = fetchErr
+		r.failedAt[domain] = now
+		r.logger.Warn("Failed to fetch MTA-STS policy",
+			zap.String("domain", domain),
+			zap.String("result", string(fetchErr.Result)),
+			zap.Error(fetchErr.Err))
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, fetchErr
+	}
+
+	policy.ID = id
+	policy.FetchedAt = now
+	r.policies[domain] = policy
+	delete(r.failures, domain)
+	delete(r.failedAt, domain)
+
+	r.logger.Debug("Fetched MTA-STS policy",
+		zap.String("domain", domain),
+		zap.String("id", id),
+		zap.String("mode", string(policy.Mode)),
+		zap.Duration("max_age", policy.MaxAge))
+
+	return policy, nil
+}
+
+// lookupID reads the policy id from the domain's _mta-sts TXT record
+func (r *Resolver) lookupID(ctx context.Context, domain string) (string, bool, error) {
+	records, err := r.lookupTXT(ctx, "_mta-sts."+domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	// More than one STSv1 record is treated as none (RFC 8461 section 3.1)
+	var id string
+	count := 0
+	for _, record := range records {
+		if recordID, ok := parseTXT(record); ok {
+			id = recordID
+			count++
+		}
+	}
+	if count != 1 {
+		return "", false, nil
+	}
+	return id, true, nil
+}
+
+// fetch retrieves and parses the policy file over HTTPS
+func (r *Resolver) fetch(ctx context.Context, domain string) (*Policy, *FetchError) {
+	fail := func(result ResultType, err error) (*Policy, *FetchError) {
+		return nil, &FetchError{Domain: domain, Result: result, Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.policyURL(domain), nil)
+	if err != nil {
+		return fail(ResultPolicyFetchError, err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if isCertificateError(err) {
+			return fail(ResultWebPKIInvalid, err)
+		}
+		return fail(ResultPolicyFetchError, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fail(ResultPolicyFetchError, fmt.Errorf("unexpected status %s", resp.Status))
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || mediaType != "text/plain" {
+		return fail(ResultPolicyInvalid, fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type")))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPolicySize+1))
+	if err != nil {
+		return fail(ResultPolicyFetchError, err)
+	}
+	if len(body) > maxPolicySize {
+		return fail(ResultPolicyInvalid, fmt.Errorf("policy exceeds %d bytes", maxPolicySize))
+	}
+
+	policy, err := ParsePolicy(domain, string(body))
+	if err != nil {
+		return fail(ResultPolicyInvalid, err)
+	}
+	return policy, nil
+}
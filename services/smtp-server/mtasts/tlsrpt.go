@@ -0,0 +1,459 @@
+package mtasts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ResultType is a TLS-RPT result type (RFC 8460 section 4.3)
+type ResultType string
+
+const (
+	ResultSTARTTLSNotSupported    ResultType = "starttls-not-supported"
+	ResultCertificateHostMismatch ResultType = "certificate-host-mismatch"
+	ResultCertificateExpired      ResultType = "certificate-expired"
+	ResultCertificateNotTrusted   ResultType = "certificate-not-trusted"
+	ResultValidationFailure       ResultType = "validation-failure"
+	ResultPolicyFetchError        ResultType = "sts-policy-fetch-error"
+	ResultPolicyInvalid           ResultType = "sts-policy-invalid"
+	ResultWebPKIInvalid           ResultType = "sts-webpki-invalid"
+)
+
+// Failure describes a session that could not be secured as a policy requires
+type Failure struct {
+	Result      ResultType
+	ReceivingMX string
+	ReceivingIP string
+	Info        string
+}
+
+// ClassifyTLSError maps a failed TLS handshake to its TLS-RPT result type
+func ClassifyTLSError(err error) ResultType {
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var authorityErr x509.UnknownAuthorityError
+	var verifyErr *tls.CertificateVerificationError
+
+	switch {
+	case errors.As(err, &hostErr):
+		return ResultCertificateHostMismatch
+	case errors.As(err, &invalidErr):
+		if invalidErr.Reason == x509.Expired {
+			return ResultCertificateExpired
+		}
+		return ResultCertificateNotTrusted
+	case errors.As(err, &authorityErr):
+		return ResultCertificateNotTrusted
+	case errors.As(err, &verifyErr):
+		return ResultCertificateNotTrusted
+	}
+	return ResultValidationFailure
+}
+
+// isCertificateError reports whether err is a certificate verification failure
+func isCertificateError(err error) bool {
+	return ClassifyTLSError(err) != ResultValidationFailure
+}
+
+// ReporterConfig identifies this server in TLS-RPT reports
+type ReporterConfig struct {
+	OrganizationName string
+	ContactInfo      string
+	Hostname         string        // Used in report ids
+	From             string        // Sender of reports delivered by mail
+	Interval         time.Duration // Reporting period
+}
+
+// MailSender queues a report message for delivery. It is implemented by the
+// outbound queue manager.
+type MailSender interface {
+	SendTLSReport(ctx context.Context, from, to string, message []byte) error
+}
+
+// Reporter aggregates the TLS outcome of outbound sessions per policy domain
+// and sends a TLS-RPT report at the end of each period to domains that saw
+// failures. Reports go to the addresses in the domain's _smtp._tls record.
+type Reporter struct {
+	config ReporterConfig
+	mail   MailSender
+	client *http.Client
+	logger *zap.Logger
+
+	// Replaced in tests
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
+	now       func() time.Time
+
+	mu      sync.Mutex
+	start   time.Time
+	domains map[string]*domainStats
+}
+
+// domainStats are the session counts for one policy domain in a period
+type domainStats struct {
+	policy    *Policy
+	successes int64
+	failures  map[Failure]int64 // Keyed without Info, which is kept separately
+	info      map[Failure]string
+}
+
+// NewReporter creates a TLS-RPT reporter
+func NewReporter(config ReporterConfig, mail MailSender, logger *zap.Logger) *Reporter {
+	if config.Interval <= 0 {
+		config.Interval = 24 * time.Hour
+	}
+	resolver := &net.Resolver{}
+	return &Reporter{
+		config:    config,
+		mail:      mail,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		logger:    logger,
+		lookupTXT: resolver.LookupTXT,
+		now:       time.Now,
+		start:     time.Now(),
+		domains:   make(map[string]*domainStats),
+	}
+}
+
+// RecordSuccess counts a session that met the domain's policy
+func (r *Reporter) RecordSuccess(domain string, policy *Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats(domain, policy).successes++
+}
+
+// RecordFailure counts a session that could not be secured. policy is nil
+// when the failure is that the policy itself couldn't be fetched.
+func (r *Reporter) RecordFailure(domain string, policy *Policy, failure Failure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats(domain, policy)
+	info := failure.Info
+	failure.Info = ""
+	stats.failures[failure]++
+	if info != "" {
+		stats.info[failure] = info
+	}
+}
+
+func (r *Reporter) stats(domain string, policy *Policy) *domainStats {
+	domain = strings.ToLower(domain)
+	stats, ok := r.domains[domain]
+	if !ok {
+		stats = &domainStats{failures: make(map[Failure]int64), info: make(map[Failure]string)}
+		r.domains[domain] = stats
+	}
+	if policy != nil {
+		stats.policy = policy
+	}
+	return stats
+}
+
+// Run sends reports at the end of every period until ctx is cancelled
+func (r *Reporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Flush(ctx)
+		}
+	}
+}
+
+// Flush ends the current period and reports it to each domain with failures
+func (r *Reporter) Flush(ctx context.Context) {
+	r.mu.Lock()
+	start, end := r.start, r.now()
+	domains := r.domains
+	r.start = end
+	r.domains = make(map[string]*domainStats)
+	r.mu.Unlock()
+
+	for domain, stats := range domains {
+		if len(stats.failures) == 0 {
+			continue
+		}
+		if err := r.send(ctx, domain, r.buildReport(domain, stats, start, end)); err != nil {
+			r.logger.Warn("Failed to send TLS report",
+				zap.String("domain", domain),
+				zap.Error(err))
+		}
+	}
+}
+
+// Report is a TLS-RPT aggregate report (RFC 8460 section 4.4)
+type Report struct {
+	OrganizationName string         `json:"organization-name"`
+	DateRange        ReportRange    `json:"date-range"`
+	ContactInfo      string         `json:"contact-info"`
+	ReportID         string         `json:"report-id"`
+	Policies         []PolicyReport `json:"policies"`
+}
+
+// ReportRange is the period a report covers
+type ReportRange struct {
+	Start time.Time `json:"start-datetime"`
+	End   time.Time `json:"end-datetime"`
+}
+
+// PolicyReport holds the results of sessions under one policy
+type PolicyReport struct {
+	Policy         ReportPolicy    `json:"policy"`
+	Summary        ReportSummary   `json:"summary"`
+	FailureDetails []FailureDetail `json:"failure-details,omitempty"`
+}
+
+// ReportPolicy identifies the policy applied
+type ReportPolicy struct {
+	Type   string   `json:"policy-type"`
+	String []string `json:"policy-string,omitempty"`
+	Domain string   `json:"policy-domain"`
+	MXHost []string `json:"mx-host,omitempty"`
+}
+
+// ReportSummary counts sessions
+type ReportSummary struct {
+	Successful int64 `json:"total-successful-session-count"`
+	Failed     int64 `json:"total-failure-session-count"`
+}
+
+// FailureDetail counts sessions that failed the same way
+type FailureDetail struct {
+	ResultType          ResultType `json:"result-type"`
+	ReceivingMXHostname string     `json:"receiving-mx-hostname,omitempty"`
+	ReceivingIP         string     `json:"receiving-ip,omitempty"`
+	FailedSessionCount  int64      `json:"failed-session-count"`
+	AdditionalInfo      string     `json:"additional-information,omitempty"`
+}
+
+func (r *Reporter) buildReport(domain string, stats *domainStats, start, end time.Time) *Report {
+	policy := ReportPolicy{Type: "sts", Domain: domain}
+	if stats.policy == nil {
+		policy.Type = "no-policy-found"
+	} else {
+		policy.String = stats.policy.Raw
+		policy.MXHost = stats.policy.MX
+	}
+
+	report := &Report{
+		OrganizationName: r.config.OrganizationName,
+		DateRange:        ReportRange{Start: start.UTC(), End: end.UTC()},
+		ContactInfo:      r.config.ContactInfo,
+		ReportID:         fmt.Sprintf("%s.%s@%s", start.UTC().Format("20060102T150405Z"), domain, r.config.Hostname),
+	}
+
+	result := PolicyReport{Policy: policy, Summary: ReportSummary{Successful: stats.successes}}
+	for failure, count := range stats.failures {
+		result.Summary.Failed += count
+		result.FailureDetails = append(result.FailureDetails, FailureDetail{
+			ResultType:          failure.Result,
+			ReceivingMXHostname: failure.ReceivingMX,
+			ReceivingIP:         failure.ReceivingIP,
+			FailedSessionCount:  count,
+			AdditionalInfo:      stats.info[failure],
+		})
+	}
+	sort.Slice(result.FailureDetails, func(i, j int) bool {
+		a, b := result.FailureDetails[i], result.FailureDetails[j]
+		if a.ResultType != b.ResultType {
+			return a.ResultType < b.ResultType
+		}
+		if a.ReceivingMXHostname != b.ReceivingMXHostname {
+			return a.ReceivingMXHostname < b.ReceivingMXHostname
+		}
+		return a.ReceivingIP < b.ReceivingIP
+	})
+	report.Policies = []PolicyReport{result}
+
+	return report
+}
+
+// send delivers a report to every rua of the domain's TLS-RPT record
+func (r *Reporter) send(ctx context.Context, domain string, report *Report) error {
+	ruas, err := r.lookupRUA(ctx, domain)
+	if err != nil {
+		return err
+	}
+	if len(ruas) == 0 {
+		r.logger.Debug("Domain publishes no TLS-RPT record, dropping report", zap.String("domain", domain))
+		return nil
+	}
+
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(payload); err != nil {
+		return fmt.Errorf("compress report: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("compress report: %w", err)
+	}
+
+	var errs []error
+	for _, rua := range ruas {
+		switch {
+		case strings.HasPrefix(rua, "mailto:"):
+			err = r.sendMail(ctx, domain, strings.TrimPrefix(rua, "mailto:"), report, compressed.Bytes())
+		case strings.HasPrefix(rua, "https://"):
+			err = r.post(ctx, rua, compressed.Bytes())
+		default:
+			err = fmt.Errorf("unsupported rua %q", rua)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", rua, err))
+			continue
+		}
+		r.logger.Info("TLS report sent",
+			zap.String("domain", domain),
+			zap.String("rua", rua),
+			zap.String("report_id", report.ReportID))
+	}
+	return errors.Join(errs...)
+}
+
+// lookupRUA returns the report URIs of the domain's _smtp._tls TXT record
+func (r *Reporter) lookupRUA(ctx context.Context, domain string) ([]string, error) {
+	records, err := r.lookupTXT(ctx, "_smtp._tls."+domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("lookup TLS-RPT record: %w", err)
+	}
+
+	for _, record := range records {
+		if ruas, ok := parseRUA(record); ok {
+			return ruas, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseRUA returns the report URIs of a TLSRPTv1 record
+func parseRUA(record string) ([]string, bool) {
+	fields := strings.Split(record, ";")
+	if strings.TrimSpace(fields[0]) != "v=TLSRPTv1" {
+		return nil, false
+	}
+	for _, field := range fields[1:] {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found || key != "rua" {
+			continue
+		}
+		var ruas []string
+		for _, uri := range strings.Split(value, ",") {
+			if uri = strings.TrimSpace(uri); uri != "" {
+				ruas = append(ruas, uri)
+			}
+		}
+		return ruas, len(ruas) > 0
+	}
+	return nil, false
+}
+
+// post submits a report over HTTPS (RFC 8460 section 5.4)
+func (r *Reporter) post(ctx context.Context, uri string, compressed []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/tlsrpt+gzip")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// sendMail queues a report as a multipart/report message (RFC 8460
+// section 5.3)
+func (r *Reporter) sendMail(ctx context.Context, domain, to string, report *Report, compressed []byte) error {
+	if r.mail == nil {
+		return errors.New("no mail sender configured")
+	}
+	message, err := buildReportMessage(r.config, domain, to, report, compressed)
+	if err != nil {
+		return err
+	}
+	return r.mail.SendTLSReport(ctx, r.config.From, to, message)
+}
+
+func buildReportMessage(config ReporterConfig, domain, to string, report *Report, compressed []byte) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	text, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(text, "This is an aggregate TLS report from %s for %s.\r\n", config.OrganizationName, domain)
+
+	filename := fmt.Sprintf("%s!%s!%d!%d.json.gz",
+		config.Hostname, domain, report.DateRange.Start.Unix(), report.DateRange.End.Unix())
+	attachment, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {fmt.Sprintf("application/tlsrpt+gzip; name=%q", filename)},
+		"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", filename)},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(compressed)
+	for len(encoded) > 76 {
+		fmt.Fprintf(attachment, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(attachment, "%s\r\n", encoded)
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: Report Domain: %s Submitter: %s Report-ID: <%s>\r\n", domain, config.Hostname, report.ReportID)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Message-ID: <%s>\r\n", report.ReportID)
+	fmt.Fprintf(&message, "TLS-Report-Domain: %s\r\n", domain)
+	fmt.Fprintf(&message, "TLS-Report-Submitter: %s\r\n", config.Hostname)
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/report; report-type=\"tlsrpt\"; boundary=\"%s\"\r\n", writer.Boundary())
+	fmt.Fprintf(&message, "\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
@@ -0,0 +1,176 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/dsn"
+)
+
+// generateBounceMessage creates and queues a failure DSN for recipients that asked for one
+func (w *Worker) generateBounceMessage(ctx context.Context, msg *domain.Message, deliveryErr error) error {
+	status := dsn.RecipientStatus{
+		Action:         dsn.ActionFailed,
+		Status:         dsn.StatusCode{Class: 5}.String(),
+		DiagnosticCode: deliveryErr.Error(),
+	}
+	applyDeliveryError(&status, deliveryErr)
+
+	return w.generateDSN(ctx, msg, domain.DSNNotifyFailure, status)
+}
+
+// generateDelayDSN notifies the sender that delivery is being retried
+// Only sent after the first failed attempt to avoid a notification per retry
+func (w *Worker) generateDelayDSN(ctx context.Context, msg *domain.Message, deliveryErr error) error {
+	if msg.RetryCount > 0 {
+		return nil
+	}
+
+	status := dsn.RecipientStatus{
+		Action:         dsn.ActionDelayed,
+		Status:         dsn.StatusCode{Class: 4}.String(),
+		DiagnosticCode: deliveryErr.Error(),
+	}
+	applyDeliveryError(&status, deliveryErr)
+
+	return w.generateDSN(ctx, msg, domain.DSNNotifyDelay, status)
+}
+
+// generateSuccessDSN notifies the sender of a successful delivery
+// Messages handed to a remote MTA are reported as relayed since DSN is not passed on
+func (w *Worker) generateSuccessDSN(ctx context.Context, msg *domain.Message, relayed bool) error {
+	status := dsn.RecipientStatus{
+		Action: dsn.ActionDelivered,
+		Status: dsn.StatusSuccess.String(),
+	}
+	if relayed {
+		status.Action = dsn.ActionRelayed
+	}
+
+	return w.generateDSN(ctx, msg, domain.DSNNotifySuccess, status)
+}
+
+// applyDeliveryError fills the status code and remote MTA from a failed delivery attempt
+func applyDeliveryError(status *dsn.RecipientStatus, deliveryErr error) {
+	var de *DeliveryError
+	if errors.As(deliveryErr, &de) {
+		status.RemoteMTA = de.RemoteMTA
+		if de.Code > 0 {
+			status.DiagnosticCode = fmt.Sprintf("%d %s", de.Code, de.Message)
+			code, _ := dsn.ClassifyStatus(de.Code, de.Message)
+			status.Status = code.String()
+		}
+	}
+
+	if code, ok := dsn.ExtractEnhancedCode(status.DiagnosticCode); ok {
+		status.Status = code
+	}
+}
+
+// dsnRecipients returns a status entry for each recipient that requested notification under condition
+func dsnRecipients(msg *domain.Message, condition string, base dsn.RecipientStatus, now time.Time) []dsn.RecipientStatus {
+	var recipients []dsn.RecipientStatus
+	for _, rcpt := range msg.Recipients {
+		if !msg.DSN.ShouldNotify(rcpt, condition) {
+			continue
+		}
+		status := base
+		status.FinalRecipient = rcpt
+		status.OriginalRecipient = msg.DSN.OriginalRecipient(rcpt)
+		status.LastAttemptDate = now
+		recipients = append(recipients, status)
+	}
+	return recipients
+}
+
+// generateDSN renders an RFC 3464 report for msg and queues it to the envelope sender
+func (w *Worker) generateDSN(ctx context.Context, msg *domain.Message, condition string, base dsn.RecipientStatus) error {
+	// Don't bounce bounces (null sender)
+	if msg.FromAddress == "" || strings.HasPrefix(msg.FromAddress, "MAILER-DAEMON") {
+		w.logger.Debug("Not sending DSN for a bounce message",
+			zap.String("message_id", msg.ID))
+		return nil
+	}
+
+	now := time.Now()
+	recipients := dsnRecipients(msg, condition, base, now)
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	opts := dsn.GenerateOptions{
+		OriginalSender:    msg.FromAddress,
+		OriginalMessageID: msg.Headers["Message-ID"],
+		ArrivalDate:       msg.CreatedAt,
+		Recipients:        recipients,
+	}
+	if msg.DSN != nil {
+		opts.OriginalEnvelopeID = msg.DSN.EnvelopeID
+		opts.IncludeFullMessage = msg.DSN.Return == "FULL"
+	}
+	if opts.ArrivalDate.IsZero() {
+		opts.ArrivalDate = msg.QueuedAt
+	}
+
+	// Attach the original headers, or the full message when RET=FULL
+	if msg.RawMessagePath != "" {
+		if data, err := w.manager.GetMessageData(msg.RawMessagePath); err == nil {
+			opts.OriginalMessage = data
+			if idx := bytes.Index(data, []byte("\r\n\r\n")); idx > 0 {
+				opts.OriginalHeaders = string(data[:idx])
+			} else if idx := bytes.Index(data, []byte("\n\n")); idx > 0 {
+				opts.OriginalHeaders = string(data[:idx])
+			}
+		}
+	}
+
+	report, err := dsn.NewGenerator(w.manager.config.Server.Hostname).Generate(opts)
+	if err != nil {
+		return fmt.Errorf("generate DSN: %w", err)
+	}
+
+	dsnMsg := &domain.Message{
+		ID:             uuid.New().String(),
+		OrganizationID: msg.OrganizationID,
+		DomainID:       msg.DomainID,
+		FromAddress:    "", // Null sender for DSNs
+		Recipients:     []string{msg.FromAddress},
+		Headers: map[string]string{
+			"X-Original-Message-ID": msg.ID,
+		},
+		Status:     domain.StatusPending,
+		Priority:   domain.PriorityNormal,
+		QueuedAt:   now,
+		CreatedAt:  now,
+		MaxRetries: 3, // Fewer retries for DSNs
+	}
+
+	// Store DSN message data
+	dsnPath, err := w.manager.StoreMessage(ctx, report)
+	if err != nil {
+		return fmt.Errorf("store DSN message: %w", err)
+	}
+	dsnMsg.RawMessagePath = dsnPath
+	dsnMsg.BodySize = int64(len(report))
+
+	if err := w.manager.Enqueue(ctx, dsnMsg); err != nil {
+		return fmt.Errorf("enqueue DSN: %w", err)
+	}
+
+	w.logger.Info("DSN generated",
+		zap.String("original_id", msg.ID),
+		zap.String("dsn_id", dsnMsg.ID),
+		zap.String("notify", condition),
+		zap.Int("recipients", len(recipients)),
+		zap.String("sender", msg.FromAddress))
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/dsn"
+)
+
+func TestDSNRecipients(t *testing.T) {
+	msg := &domain.Message{
+		Recipients: []string{"default@example.com", "success@example.com", "never@example.com", "delay@example.com"},
+		DSN: &domain.DSNParams{
+			Recipients: map[string]*domain.RecipientDSN{
+				"success@example.com": {Notify: []string{domain.DSNNotifySuccess, domain.DSNNotifyFailure}, OriginalRecipient: "alias@example.com"},
+				"never@example.com":   {Notify: []string{domain.DSNNotifyNever}},
+				"delay@example.com":   {Notify: []string{domain.DSNNotifyDelay}},
+			},
+		},
+	}
+
+	tests := []struct {
+		condition string
+		want      []string
+	}{
+		{domain.DSNNotifyFailure, []string{"default@example.com", "success@example.com"}},
+		{domain.DSNNotifySuccess, []string{"success@example.com"}},
+		{domain.DSNNotifyDelay, []string{"delay@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			got := dsnRecipients(msg, tt.condition, dsn.RecipientStatus{}, time.Now())
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d recipients, want %v", len(got), tt.want)
+			}
+			for i, r := range got {
+				if r.FinalRecipient != tt.want[i] {
+					t.Errorf("recipient[%d] = %s, want %s", i, r.FinalRecipient, tt.want[i])
+				}
+				if r.FinalRecipient == "success@example.com" && r.OriginalRecipient != "alias@example.com" {
+					t.Errorf("OriginalRecipient = %q, want alias@example.com", r.OriginalRecipient)
+				}
+			}
+		})
+	}
+
+	t.Run("no DSN parameters", func(t *testing.T) {
+		plain := &domain.Message{Recipients: []string{"a@example.com"}}
+		if got := dsnRecipients(plain, domain.DSNNotifyFailure, dsn.RecipientStatus{}, time.Now()); len(got) != 1 {
+			t.Errorf("failure DSN recipients = %d, want 1", len(got))
+		}
+		if got := dsnRecipients(plain, domain.DSNNotifySuccess, dsn.RecipientStatus{}, time.Now()); len(got) != 0 {
+			t.Errorf("success DSN recipients = %d, want 0", len(got))
+		}
+	})
+}
+
+func TestApplyDeliveryError(t *testing.T) {
+	smtpErr := &textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}
+	err := fmt.Errorf("all MX hosts failed: %w", newDeliveryError("mx1.example.com", smtpErr))
+
+	status := dsn.RecipientStatus{Status: "5.0.0", DiagnosticCode: err.Error()}
+	applyDeliveryError(&status, err)
+
+	if status.RemoteMTA != "mx1.example.com" {
+		t.Errorf("RemoteMTA = %q, want mx1.example.com", status.RemoteMTA)
+	}
+	if status.Status != "5.1.1" {
+		t.Errorf("Status = %q, want 5.1.1", status.Status)
+	}
+	if status.DiagnosticCode != "550 5.1.1 user unknown" {
+		t.Errorf("DiagnosticCode = %q", status.DiagnosticCode)
+	}
+}
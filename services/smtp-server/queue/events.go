@@ -0,0 +1,197 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/dsn"
+)
+
+// Delivery event types reported to the transactional API
+const (
+	EventDelivered = "delivered"
+	EventDeferred  = "deferred"
+	EventBounced   = "bounced"
+)
+
+// transactionalMessageIDDomain is the Message-ID domain used by the transactional API
+const transactionalMessageIDDomain = "transactional.mail"
+
+// DeliveryError carries the remote MTA and SMTP reply of a failed delivery attempt
+type DeliveryError struct {
+	RemoteMTA string
+	Code      int
+	Message   string
+	Err       error
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// newDeliveryError records which host produced err and extracts its SMTP reply
+func newDeliveryError(host string, err error) *DeliveryError {
+	de := &DeliveryError{RemoteMTA: host, Message: err.Error(), Err: err}
+
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		de.Code = tpErr.Code
+		de.Message = tpErr.Msg
+	} else if code, ok := dsn.ExtractSMTPCode(err.Error()); ok {
+		de.Code = code
+	}
+
+	return de
+}
+
+// DeliveryEvent is the payload accepted by the transactional API internal events endpoint
+type DeliveryEvent struct {
+	MessageID    string    `json:"message_id"`
+	EventType    string    `json:"event_type"`
+	Recipient    string    `json:"recipient"`
+	Timestamp    time.Time `json:"timestamp"`
+	SMTPCode     int       `json:"smtp_code,omitempty"`
+	SMTPResponse string    `json:"smtp_response,omitempty"`
+	BounceType   string    `json:"bounce_type,omitempty"`
+	BounceCode   string    `json:"bounce_code,omitempty"` // RFC 3463 enhanced status code
+	BounceReason string    `json:"bounce_reason,omitempty"`
+	RemoteMTA    string    `json:"remote_mta,omitempty"`
+}
+
+// EventReporter posts delivery outcomes of transactional messages back to the
+// transactional API so they can be correlated with the original message ID
+type EventReporter struct {
+	config *config.EventsConfig
+	client *http.Client
+	logger *zap.Logger
+}
+
+// NewEventReporter creates a new delivery event reporter
+func NewEventReporter(cfg *config.EventsConfig, logger *zap.Logger) *EventReporter {
+	return &EventReporter{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+	}
+}
+
+// Report sends one event per recipient if msg was submitted by the transactional API
+func (r *EventReporter) Report(msg *domain.Message, eventType string, deliveryErr error) {
+	if r == nil || !r.config.Enabled || r.config.Endpoint == "" {
+		return
+	}
+
+	messageID, ok := transactionalMessageID(msg.Headers["Message-ID"])
+	if !ok {
+		return
+	}
+
+	for _, event := range buildDeliveryEvents(messageID, msg.Recipients, eventType, deliveryErr, time.Now()) {
+		go r.send(event)
+	}
+}
+
+func (r *EventReporter) send(event *DeliveryEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		r.logger.Error("Failed to marshal delivery event", zap.Error(err))
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		r.logger.Error("Failed to create delivery event request", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.config.InternalSecret != "" {
+		req.Header.Set("X-Internal-Secret", r.config.InternalSecret)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.logger.Warn("Failed to report delivery event",
+			zap.String("message_id", event.MessageID),
+			zap.String("event", event.EventType),
+			zap.Error(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		r.logger.Warn("Delivery event rejected",
+			zap.String("message_id", event.MessageID),
+			zap.String("event", event.EventType),
+			zap.Int("status", resp.StatusCode))
+	}
+}
+
+// buildDeliveryEvents creates per-recipient events, attaching SMTP details for failures
+func buildDeliveryEvents(messageID string, recipients []string, eventType string, deliveryErr error, now time.Time) []*DeliveryEvent {
+	var details DeliveryEvent
+	if deliveryErr != nil {
+		details.SMTPResponse = deliveryErr.Error()
+
+		var de *DeliveryError
+		if errors.As(deliveryErr, &de) {
+			details.RemoteMTA = de.RemoteMTA
+			details.SMTPCode = de.Code
+			details.SMTPResponse = de.Message
+		}
+
+		if eventType == EventBounced {
+			_, bounceType := dsn.ClassifyStatus(details.SMTPCode, details.SMTPResponse)
+			if bounceType == "" {
+				bounceType = dsn.BounceNetwork
+			}
+			details.BounceType = string(bounceType)
+			details.BounceReason = details.SMTPResponse
+			if code, ok := dsn.ExtractEnhancedCode(details.SMTPResponse); ok {
+				details.BounceCode = code
+			}
+		}
+	}
+
+	events := make([]*DeliveryEvent, 0, len(recipients))
+	for _, rcpt := range recipients {
+		event := details
+		event.MessageID = messageID
+		event.EventType = eventType
+		event.Recipient = rcpt
+		event.Timestamp = now
+		events = append(events, &event)
+	}
+
+	return events
+}
+
+// transactionalMessageID extracts the message UUID from a "<uuid@transactional.mail>" Message-ID
+func transactionalMessageID(header string) (string, bool) {
+	header = strings.Trim(strings.TrimSpace(header), "<>")
+	local, host, ok := strings.Cut(header, "@")
+	if !ok || !strings.EqualFold(host, transactionalMessageIDDomain) {
+		return "", false
+	}
+	if _, err := uuid.Parse(local); err != nil {
+		return "", false
+	}
+	return local, true
+}
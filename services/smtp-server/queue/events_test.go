@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestTransactionalMessageID(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		wantOK bool
+	}{
+		{"<6f1c1a9e-8a47-4b8e-9a59-1f0f5c7d2b11@transactional.mail>", "6f1c1a9e-8a47-4b8e-9a59-1f0f5c7d2b11", true},
+		{" <6f1c1a9e-8a47-4b8e-9a59-1f0f5c7d2b11@Transactional.Mail> ", "6f1c1a9e-8a47-4b8e-9a59-1f0f5c7d2b11", true},
+		{"<6f1c1a9e-8a47-4b8e-9a59-1f0f5c7d2b11@example.com>", "", false},
+		{"<not-a-uuid@transactional.mail>", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := transactionalMessageID(tt.header)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("transactionalMessageID(%q) = %q, %v; want %q, %v", tt.header, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestBuildDeliveryEvents(t *testing.T) {
+	now := time.Now()
+	recipients := []string{"a@example.com", "b@example.com"}
+
+	t.Run("delivered", func(t *testing.T) {
+		events := buildDeliveryEvents("msg-1", recipients, EventDelivered, nil, now)
+		if len(events) != 2 {
+			t.Fatalf("got %d events, want 2", len(events))
+		}
+		for i, e := range events {
+			if e.Recipient != recipients[i] || e.EventType != EventDelivered || e.SMTPCode != 0 || e.RemoteMTA != "" {
+				t.Errorf("unexpected event %+v", e)
+			}
+		}
+	})
+
+	t.Run("bounced with remote reply", func(t *testing.T) {
+		smtpErr := &textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}
+		err := fmt.Errorf("all MX hosts failed: %w", newDeliveryError("mx1.example.com", fmt.Errorf("RCPT TO a@example.com: %w", smtpErr)))
+
+		events := buildDeliveryEvents("msg-1", recipients[:1], EventBounced, err, now)
+		if len(events) != 1 {
+			t.Fatalf("got %d events, want 1", len(events))
+		}
+		e := events[0]
+		if e.SMTPCode != 550 || e.RemoteMTA != "mx1.example.com" || e.SMTPResponse != "5.1.1 user unknown" {
+			t.Errorf("unexpected SMTP details %+v", e)
+		}
+		if e.BounceType != "hard" || e.BounceCode != "5.1.1" {
+			t.Errorf("bounce type/code = %s/%s, want hard/5.1.1", e.BounceType, e.BounceCode)
+		}
+	})
+
+	t.Run("deferred without SMTP reply", func(t *testing.T) {
+		events := buildDeliveryEvents("msg-1", recipients[:1], EventDeferred, errors.New("lookup MX for example.com: no such host"), now)
+		e := events[0]
+		if e.SMTPCode != 0 || e.BounceType != "" || e.SMTPResponse == "" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	})
+}
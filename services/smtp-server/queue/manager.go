@@ -4,12 +4,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/redis/go-redis/v9"
@@ -17,6 +19,7 @@ import (
 
 	"github.com/oonrumail/smtp-server/config"
 	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/mtasts"
 	"github.com/oonrumail/smtp-server/repository"
 )
 
@@ -54,6 +57,12 @@ type Manager struct {
 	redis        *redis.Client
 	msgRepo      *repository.MessageRepository
 	domainCache  DomainProvider
+	events       *EventReporter
+	connPool     *ConnPool
+	sealer       ForwardSealer
+	stsPolicies  *mtasts.Resolver // nil when MTA-STS is disabled
+	tlsReports   *mtasts.Reporter
+	scheduler    *priorityScheduler
 	logger       *zap.Logger
 
 	workers      []*Worker
@@ -71,6 +80,13 @@ type Manager struct {
 type DomainProvider interface {
 	GetDomain(name string) *domain.Domain
 	GetDomainByID(id string) *domain.Domain
+	CatchAllDestination(ctx context.Context, d *domain.Domain, localPart string) (string, error)
+}
+
+// ForwardSealer adds an ARC set to messages this server forwards to another
+// domain. It is implemented by arc.Signer.
+type ForwardSealer interface {
+	SealForwarded(domainName string, message []byte) ([]byte, error)
 }
 
 // NewManager creates a new queue manager
@@ -81,15 +97,56 @@ func NewManager(
 	domainCache DomainProvider,
 	logger *zap.Logger,
 ) *Manager {
-	return &Manager{
+	m := &Manager{
 		config:       cfg,
 		redis:        redisClient,
 		msgRepo:      msgRepo,
 		domainCache:  domainCache,
+		events:       NewEventReporter(&cfg.Events, logger.Named("events")),
+		connPool:     NewConnPool(
+			cfg.Server.Hostname,
+			cfg.Queue.ConnMaxMessages,
+			cfg.Queue.ConnIdleTimeout,
+			cfg.Queue.MaxConnsPerHost,
+			logger.Named("pool"),
+		),
+		scheduler:    newPriorityScheduler(
+			cfg.Queue.HighPriorityWeight,
+			cfg.Queue.NormalPriorityWeight,
+			cfg.Queue.LowPriorityWeight,
+		),
 		logger:       logger,
 		stopChan:     make(chan struct{}),
 		rateLimiters: make(map[string]*RateLimiter),
 	}
+
+	if cfg.MTASTS.Enabled {
+		contact := cfg.MTASTS.ContactInfo
+		if contact == "" {
+			contact = "postmaster@" + cfg.Server.DefaultDomain
+		}
+		organization := cfg.MTASTS.OrganizationName
+		if organization == "" {
+			organization = cfg.Server.DefaultDomain
+		}
+
+		m.stsPolicies = mtasts.NewResolver(cfg.MTASTS.FetchTimeout, logger.Named("mta-sts"))
+		m.tlsReports = mtasts.NewReporter(mtasts.ReporterConfig{
+			OrganizationName: organization,
+			ContactInfo:      contact,
+			Hostname:         cfg.Server.Hostname,
+			From:             "noreply@" + cfg.Server.DefaultDomain,
+			Interval:         cfg.MTASTS.ReportInterval,
+		}, m, logger.Named("tlsrpt"))
+	}
+
+	return m
+}
+
+// SetForwardSealer enables ARC sealing of mail forwarded by aliases and
+// distribution lists to external recipients
+func (m *Manager) SetForwardSealer(sealer ForwardSealer) {
+	m.sealer = sealer
 }
 
 // Start starts the queue manager and workers
@@ -124,6 +181,22 @@ func (m *Manager) Start(ctx context.Context) error {
 	// Start stuck message recovery
 	go m.recoveryLoop(ctx)
 
+	// Close idle outbound connections
+	go m.connPool.reapLoop(ctx)
+
+	// Publish queue depth per priority
+	go m.depthLoop(ctx)
+
+	// Send TLS-RPT reports
+	if m.tlsReports != nil {
+		go m.tlsReports.Run(ctx)
+	}
+
+	// Send automatic read receipts
+	if m.config.ReadReceipts.Enabled {
+		go m.readReceiptLoop(ctx)
+	}
+
 	m.logger.Info("Queue manager started",
 		zap.Int("workers", m.config.Queue.Workers),
 		zap.String("storage_path", m.config.Queue.StoragePath))
@@ -156,6 +229,8 @@ func (m *Manager) Stop(ctx context.Context) error {
 		m.logger.Warn("Queue manager stop timeout")
 	}
 
+	m.connPool.Close()
+
 	return nil
 }
 
@@ -171,9 +246,8 @@ func (m *Manager) Enqueue(ctx context.Context, msg *domain.Message) error {
 		return fmt.Errorf("create message: %w", err)
 	}
 
-	// Push to Redis queue for immediate processing
-	queueKey := fmt.Sprintf("queue:domain:%s", msg.DomainID)
-	if err := m.redis.LPush(ctx, queueKey, msg.ID).Err(); err != nil {
+	// Push to the Redis list of its priority for immediate processing
+	if err := m.redis.RPush(ctx, priorityQueueKey(msg.Priority), msg.ID).Err(); err != nil {
 		m.logger.Warn("Failed to push to Redis queue", zap.Error(err))
 		// Message is still in database, will be picked up by workers
 	}
@@ -181,6 +255,7 @@ func (m *Manager) Enqueue(ctx context.Context, msg *domain.Message) error {
 	m.logger.Debug("Message enqueued",
 		zap.String("message_id", msg.ID),
 		zap.String("domain_id", msg.DomainID),
+		zap.String("priority", domain.PriorityName(msg.Priority)),
 		zap.Int("recipients", len(msg.Recipients)))
 
 	return nil
@@ -451,6 +526,15 @@ func (m *Manager) LookupRecipient(ctx context.Context, email string) (*Recipient
 	return result, nil
 }
 
+// DeliverLocalRecipient delivers a message straight into a local recipient's
+// mailbox, bypassing the queue. It resolves aliases, distribution lists and
+// catch-all addresses the same way queued local delivery does, and is used by
+// LMTP, which reports a separate status for each recipient.
+func (m *Manager) DeliverLocalRecipient(ctx context.Context, msg *domain.Message, targetDomain *domain.Domain, recipient string, data []byte) error {
+	w := NewWorker(-1, m, m.logger.Named("local-delivery"))
+	return w.deliverToMailbox(ctx, msg, targetDomain, recipient, data)
+}
+
 // StoreMailboxMessage stores a message in mailbox storage (S3 or local)
 func (m *Manager) StoreMailboxMessage(ctx context.Context, path string, data []byte) error {
 	// For now, store locally - in production this would go to S3/object storage
@@ -480,15 +564,31 @@ func (m *Manager) UpdateMailboxUsage(ctx context.Context, mailboxID string, addi
 }
 
 // RecordMailboxMessage records a message in the mailbox messages table
-func (m *Manager) RecordMailboxMessage(ctx context.Context, mailboxID string, msg *domain.Message, storagePath string, size int64) error {
-	return m.msgRepo.RecordMailboxMessage(ctx, mailboxID, msg, storagePath, size)
+func (m *Manager) RecordMailboxMessage(ctx context.Context, mailboxID, folder string, msg *domain.Message, storagePath string, size int64) error {
+	return m.msgRepo.RecordMailboxMessage(ctx, mailboxID, folder, msg, storagePath, size)
 }
 
 // DeliverToMailFolder parses a raw email and inserts it into the mail_messages
 // table so it appears in the web app UI. This is called after storing the .eml
 // file and is best-effort — delivery is not affected if this fails.
-func (m *Manager) DeliverToMailFolder(ctx context.Context, mailboxID string, msg *domain.Message, rawData []byte, storagePath string) error {
-	return m.msgRepo.DeliverToMailFolder(ctx, mailboxID, msg, rawData, storagePath)
+func (m *Manager) DeliverToMailFolder(ctx context.Context, mailboxID, folder string, msg *domain.Message, rawData []byte, storagePath string) error {
+	return m.msgRepo.DeliverToMailFolder(ctx, mailboxID, folder, msg, rawData, storagePath)
+}
+
+// GetSieveScript returns a mailbox's Sieve script, or nil if it has none
+func (m *Manager) GetSieveScript(ctx context.Context, mailboxID string) (*domain.SieveScript, error) {
+	return m.msgRepo.GetSieveScript(ctx, mailboxID)
+}
+
+// GetVacationResponder returns a mailbox's vacation responder, or nil if it
+// has none
+func (m *Manager) GetVacationResponder(ctx context.Context, mailboxID string) (*domain.VacationResponder, error) {
+	return m.msgRepo.GetVacationResponder(ctx, mailboxID)
+}
+
+// ListMailFolderPaths returns the full paths of a mailbox's folders
+func (m *Manager) ListMailFolderPaths(ctx context.Context, mailboxID string) ([]string, error) {
+	return m.msgRepo.ListMailFolderPaths(ctx, mailboxID)
 }
 
 // AtomicQuotaCheckAndUpdate performs atomic quota verification and update.
@@ -538,6 +638,27 @@ func (m *Manager) MarkQuotaWarningSent(ctx context.Context, warningKey string) {
 	}
 }
 
+// NotifyMailboxDelivery publishes a new-mail notification on the mailbox's
+// IMAP notify channel so IDLE sessions on any IMAP instance report it
+// immediately. Failures only delay the client until its next poll.
+func (m *Manager) NotifyMailboxDelivery(ctx context.Context, mailboxID, folderPath string) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":        "EXISTS",
+		"mailbox_id":  mailboxID,
+		"folder_path": folderPath,
+		"timestamp":   time.Now().UTC(),
+	})
+	if err != nil {
+		return
+	}
+
+	if err := m.redis.Publish(ctx, "imap:notify:"+mailboxID, payload).Err(); err != nil {
+		m.logger.Warn("Failed to publish IMAP notification",
+			zap.String("mailbox_id", mailboxID),
+			zap.Error(err))
+	}
+}
+
 // SendQuotaWarningEmail sends a quota warning email to the mailbox owner.
 func (m *Manager) SendQuotaWarningEmail(ctx context.Context, mailbox *domain.Mailbox, usagePercent float64, description string) error {
 	// Get user email for notification
@@ -583,7 +704,7 @@ func (m *Manager) queueSystemEmail(ctx context.Context, to, subject, body string
 			"X-Priority":     "1",
 		},
 		Status:    "pending",
-		Priority:  1, // High priority
+		Priority:  domain.PriorityHigh,
 		CreatedAt: time.Now(),
 	}
 
@@ -598,6 +719,34 @@ func (m *Manager) queueSystemEmail(ctx context.Context, to, subject, body string
 	return m.msgRepo.CreateMessage(ctx, msg)
 }
 
+// SendTLSReport queues a TLS-RPT report message. Reports are system mail
+// and bypass per-domain rate limits.
+func (m *Manager) SendTLSReport(ctx context.Context, from, to string, message []byte) error {
+	path, err := m.StoreMessage(ctx, message)
+	if err != nil {
+		return fmt.Errorf("store TLS report: %w", err)
+	}
+
+	now := time.Now()
+	msg := &domain.Message{
+		ID:          uuid.New().String(),
+		FromAddress: from,
+		Recipients:  []string{to},
+		Headers: map[string]string{
+			"X-System-Email": "tls-report",
+		},
+		RawMessagePath: path,
+		BodySize:       int64(len(message)),
+		Status:         domain.StatusPending,
+		Priority:       domain.PriorityLow,
+		QueuedAt:       now,
+		CreatedAt:      now,
+		MaxRetries:     3,
+	}
+
+	return m.msgRepo.CreateMessage(ctx, msg)
+}
+
 // formatBytes formats bytes into human-readable format.
 func formatBytes(bytes int64) string {
 	const unit = 1024
@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/mdn"
+)
+
+// readReceiptBatch bounds the read receipts sent per poll
+const readReceiptBatch = 100
+
+// recordReadReceipt records the read receipt request of a message delivered
+// to a mailbox, so a receipt can be sent once the message is displayed.
+// Failures are only logged; they never affect delivery.
+func (w *Worker) recordReadReceipt(ctx context.Context, msg *domain.Message, mailbox *domain.Mailbox, data []byte) {
+	if !w.manager.config.ReadReceipts.Enabled {
+		return
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	if reason := mdn.Skip(parsed.Header, msg.FromAddress); reason != "" {
+		if reason != "no receipt requested" {
+			w.logger.Debug("Not recording read receipt request",
+				zap.String("message_id", msg.ID),
+				zap.String("mailbox", mailbox.Email),
+				zap.String("reason", reason))
+		}
+		return
+	}
+
+	req := mdn.NewRequest(parsed.Header, data, msg.FromAddress, mailbox.Email)
+	req.MailboxID = mailbox.ID
+	req.OrganizationID = msg.OrganizationID
+	req.DomainID = msg.DomainID
+
+	if err := w.manager.msgRepo.CreateMDNRequest(ctx, req); err != nil {
+		w.logger.Warn("Failed to record read receipt request",
+			zap.String("message_id", msg.ID),
+			zap.String("mailbox", mailbox.Email),
+			zap.Error(err))
+	}
+}
+
+// SendReadReceipt sends the read receipt of a request the user confirmed. It
+// returns false if there is no unsent request with that ID.
+func (m *Manager) SendReadReceipt(ctx context.Context, requestID string) (bool, error) {
+	req, err := m.msgRepo.ClaimMDNRequest(ctx, requestID)
+	if err != nil || req == nil {
+		return false, err
+	}
+
+	if err := m.queueReadReceipt(ctx, req, true); err != nil {
+		if relErr := m.msgRepo.ReleaseMDNRequest(ctx, req); relErr != nil {
+			m.logger.Warn("Failed to release read receipt request", zap.Error(relErr))
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// readReceiptLoop sends the automatic read receipts of messages the IMAP
+// server marked displayed
+func (m *Manager) readReceiptLoop(ctx context.Context) {
+	ticker := time.NewTicker(m.config.ReadReceipts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopChan:
+			return
+		case <-ticker.C:
+			m.sendDisplayedReadReceipts(ctx)
+		}
+	}
+}
+
+func (m *Manager) sendDisplayedReadReceipts(ctx context.Context) {
+	requests, err := m.msgRepo.ClaimDisplayedMDNRequests(ctx, readReceiptBatch)
+	if err != nil {
+		m.logger.Warn("Failed to claim displayed read receipt requests", zap.Error(err))
+		return
+	}
+
+	for _, req := range requests {
+		if err := m.queueReadReceipt(ctx, req, false); err != nil {
+			m.logger.Error("Failed to queue read receipt",
+				zap.String("request_id", req.ID),
+				zap.Error(err))
+			if err := m.msgRepo.ReleaseMDNRequest(ctx, req); err != nil {
+				m.logger.Warn("Failed to release read receipt request", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (m *Manager) queueReadReceipt(ctx context.Context, req *domain.MDNRequest, manual bool) error {
+	now := time.Now()
+	receipt := mdn.Build(req, m.config.Server.Hostname, manual, now)
+
+	path, err := m.StoreMessage(ctx, receipt)
+	if err != nil {
+		return err
+	}
+
+	receiptMsg := &domain.Message{
+		ID:             uuid.New().String(),
+		OrganizationID: req.OrganizationID,
+		DomainID:       req.DomainID,
+		FromAddress:    "", // Null return path, so the receipt can't bounce back to us (RFC 8098)
+		Recipients:     []string{req.NotifyTo},
+		Subject:        "Read: " + req.Subject,
+		Headers: map[string]string{
+			"X-Target-Domain": recipientDomain(req.NotifyTo),
+		},
+		BodySize:       int64(len(receipt)),
+		RawMessagePath: path,
+		Status:         domain.StatusPending,
+		Priority:       domain.PriorityNormal,
+		QueuedAt:       now,
+		CreatedAt:      now,
+		MaxRetries:     3,
+	}
+
+	if err := m.Enqueue(ctx, receiptMsg); err != nil {
+		return err
+	}
+
+	m.logger.Info("Read receipt queued",
+		zap.String("request_id", req.ID),
+		zap.String("final_recipient", req.FinalRecipient),
+		zap.Bool("manual", manual))
+	return nil
+}
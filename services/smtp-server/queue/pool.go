@@ -0,0 +1,496 @@
+package queue
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/mtasts"
+)
+
+// Prometheus metrics for outbound connection reuse
+var (
+	outboundConnectionsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "smtp_outbound_connections_created_total",
+		Help: "Total outbound SMTP connections established",
+	})
+
+	outboundConnectionsReused = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "smtp_outbound_connections_reused_total",
+		Help: "Total deliveries that reused a pooled outbound SMTP connection",
+	})
+)
+
+// smtpCommandTimeout bounds commands used to probe or close pooled sessions
+const smtpCommandTimeout = 30 * time.Second
+
+// ConnPool reuses established outbound SMTP sessions per MX host. A session
+// is greeted and upgraded with STARTTLS once, then carries up to maxMessages
+// transactions, separated by RSET, until it sits idle for idleTimeout.
+// Concurrent sessions per host are capped so a single provider isn't flooded.
+type ConnPool struct {
+	heloName    string
+	maxMessages int
+	idleTimeout time.Duration
+	maxPerHost  int
+	logger      *zap.Logger
+
+	// dial opens the TCP connection to an MX host; replaced in tests
+	dial func(ctx context.Context, host string) (net.Conn, error)
+
+	mu     sync.Mutex
+	hosts  map[string]*hostPool
+	closed bool
+}
+
+// hostPool holds the sessions for a single MX host
+type hostPool struct {
+	slots chan struct{} // Per-destination concurrency limit
+	idle  []*pooledConn
+	users int // Callers holding or waiting for a slot
+}
+
+// pooledConn is an outbound SMTP session owned by a ConnPool
+type pooledConn struct {
+	host     string
+	conn     net.Conn
+	client   *smtp.Client
+	messages int
+	lastUsed time.Time
+	dataSent bool // The last transaction reached the end of DATA
+
+	// tlsFailure is why the session isn't protected by verified TLS, or nil
+	tlsFailure *mtasts.Failure
+}
+
+// tlsMode is how a new session negotiates STARTTLS
+type tlsMode int
+
+const (
+	tlsVerified   tlsMode = iota // Certificate must be valid for the host
+	tlsUnverified                // Encrypt without authenticating the host
+	tlsNone                      // Plaintext, for hosts whose TLS is broken
+)
+
+// TLSError is returned when a policy requires verified TLS and the host
+// can't provide it. Delivery should be deferred rather than sent in clear.
+type TLSError struct {
+	Failure mtasts.Failure
+	Err     error
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("TLS required by MTA-STS policy (%s): %v", e.Failure.Result, e.Err)
+}
+
+func (e *TLSError) Unwrap() error {
+	return e.Err
+}
+
+// NewConnPool creates an outbound connection pool
+func NewConnPool(heloName string, maxMessages int, idleTimeout time.Duration, maxPerHost int, logger *zap.Logger) *ConnPool {
+	if maxMessages < 1 {
+		maxMessages = 1
+	}
+	if maxPerHost < 1 {
+		maxPerHost = 1
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return &ConnPool{
+		heloName:    heloName,
+		maxMessages: maxMessages,
+		idleTimeout: idleTimeout,
+		maxPerHost:  maxPerHost,
+		logger:      logger,
+		dial: func(ctx context.Context, host string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, "25"))
+		},
+		hosts: make(map[string]*hostPool),
+	}
+}
+
+// Send delivers one message to host, reusing a pooled session when possible.
+// If a reused session turns out to be dead before the message body was sent,
+// the delivery is retried once on a new connection.
+func (p *ConnPool) Send(ctx context.Context, host, from string, recipients []string, data []byte) error {
+	_, err := p.SendWithPolicy(ctx, host, false, from, recipients, data)
+	return err
+}
+
+// SendWithPolicy is Send for a destination with an MTA-STS policy. It also
+// returns why the session wasn't protected by verified TLS, if it wasn't, for
+// TLS reporting. With requireTLS set such a session is never used: the
+// delivery fails with a *TLSError instead.
+func (p *ConnPool) SendWithPolicy(ctx context.Context, host string, requireTLS bool, from string, recipients []string, data []byte) (*mtasts.Failure, error) {
+	pc, reused, err := p.get(ctx, host, true, requireTLS)
+	if err != nil {
+		return tlsFailureOf(err), err
+	}
+
+	err = pc.send(from, recipients, data)
+	p.put(pc, err)
+	if err == nil || !reused || pc.dataSent || reusableAfter(err) {
+		return pc.tlsFailure, err
+	}
+
+	p.logger.Debug("Pooled connection failed, retrying on a new connection",
+		zap.String("host", host),
+		zap.Error(err))
+
+	pc, _, err = p.get(ctx, host, false, requireTLS)
+	if err != nil {
+		return tlsFailureOf(err), err
+	}
+	err = pc.send(from, recipients, data)
+	p.put(pc, err)
+	return pc.tlsFailure, err
+}
+
+// tlsFailureOf returns the TLS failure behind a connection error, if any
+func tlsFailureOf(err error) *mtasts.Failure {
+	var tlsErr *TLSError
+	if errors.As(err, &tlsErr) {
+		return &tlsErr.Failure
+	}
+	return nil
+}
+
+// get waits for a slot for host and returns a ready session, reporting
+// whether it was reused. Idle sessions are reset before reuse; one the remote
+// has dropped, or one without verified TLS when requireTLS is set, is
+// discarded in favor of a new connection.
+func (p *ConnPool) get(ctx context.Context, host string, allowReuse, requireTLS bool) (*pooledConn, bool, error) {
+	hp, err := p.acquireHost(host)
+	if err != nil {
+		return nil, false, err
+	}
+
+	select {
+	case hp.slots <- struct{}{}:
+	case <-ctx.Done():
+		p.releaseHost(host, hp, false)
+		return nil, false, ctx.Err()
+	}
+
+	for allowReuse {
+		pc := p.popIdle(hp)
+		if pc == nil {
+			break
+		}
+		if requireTLS && pc.tlsFailure != nil {
+			pc.close()
+			continue
+		}
+		if err := pc.reset(); err != nil {
+			p.logger.Debug("Pooled connection dropped, discarding",
+				zap.String("host", host),
+				zap.Error(err))
+			pc.close()
+			continue
+		}
+		outboundConnectionsReused.Inc()
+		return pc, true, nil
+	}
+
+	pc, err := p.open(ctx, host, requireTLS)
+	if err != nil {
+		p.releaseHost(host, hp, true)
+		return nil, false, err
+	}
+	outboundConnectionsCreated.Inc()
+	return pc, false, nil
+}
+
+// put returns a session after a delivery attempt. The session is kept only if
+// the remote answered every command and its message budget isn't spent.
+func (p *ConnPool) put(pc *pooledConn, deliveryErr error) {
+	p.mu.Lock()
+	hp := p.hosts[pc.host]
+	reuse := !p.closed && reusableAfter(deliveryErr) && pc.messages < p.maxMessages
+	if reuse {
+		pc.lastUsed = time.Now()
+		hp.idle = append(hp.idle, pc)
+	}
+	p.mu.Unlock()
+
+	if !reuse {
+		pc.close()
+	}
+	p.releaseHost(pc.host, hp, true)
+}
+
+// acquireHost returns the pool for host, registering the caller as a user
+func (p *ConnPool) acquireHost(host string) (*hostPool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, errors.New("connection pool closed")
+	}
+
+	hp, ok := p.hosts[host]
+	if !ok {
+		hp = &hostPool{slots: make(chan struct{}, p.maxPerHost)}
+		p.hosts[host] = hp
+	}
+	hp.users++
+	return hp, nil
+}
+
+// releaseHost drops a caller's claim on host, freeing its slot if it held one
+func (p *ConnPool) releaseHost(host string, hp *hostPool, heldSlot bool) {
+	if heldSlot {
+		<-hp.slots
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hp.users--
+	if hp.users == 0 && len(hp.idle) == 0 {
+		delete(p.hosts, host)
+	}
+}
+
+// popIdle removes the most recently used session that hasn't timed out
+func (p *ConnPool) popIdle(hp *hostPool) *pooledConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(hp.idle) > 0 {
+		pc := hp.idle[len(hp.idle)-1]
+		hp.idle = hp.idle[:len(hp.idle)-1]
+		if time.Since(pc.lastUsed) < p.idleTimeout {
+			return pc
+		}
+		go pc.close()
+	}
+	return nil
+}
+
+// open establishes a new session, upgrading it to TLS verified against host.
+// If that fails and TLS isn't required, the failure is recorded on the
+// session, which falls back to unauthenticated TLS when only the certificate
+// was at fault and to plaintext otherwise.
+func (p *ConnPool) open(ctx context.Context, host string, requireTLS bool) (*pooledConn, error) {
+	pc, failure, err := p.connect(ctx, host, tlsVerified)
+	if err != nil || failure == nil {
+		return pc, err
+	}
+	if requireTLS {
+		if pc != nil {
+			pc.close()
+		}
+		return nil, &TLSError{Failure: *failure, Err: errors.New(failure.Info)}
+	}
+
+	p.logger.Debug("Verified STARTTLS failed, falling back",
+		zap.String("host", host),
+		zap.String("result", string(failure.Result)),
+		zap.String("error", failure.Info))
+
+	// A failed handshake leaves the session unusable, so reconnect
+	if pc == nil {
+		mode := tlsUnverified
+		if failure.Result == mtasts.ResultValidationFailure {
+			mode = tlsNone
+		}
+		pc, _, err = p.connect(ctx, host, mode)
+		if err == nil && pc == nil {
+			pc, _, err = p.connect(ctx, host, tlsNone)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	pc.tlsFailure = failure
+	return pc, nil
+}
+
+// connect opens a session, says EHLO and negotiates STARTTLS as mode asks.
+// A host that doesn't offer STARTTLS yields a plaintext session along with
+// the failure; a failed handshake closes the session and returns only the
+// failure.
+func (p *ConnPool) connect(ctx context.Context, host string, mode tlsMode) (*pooledConn, *mtasts.Failure, error) {
+	conn, err := p.dial(ctx, host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to %s: %w", host, err)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("create SMTP client: %w", err)
+	}
+
+	if err := client.Hello(p.heloName); err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("HELO: %w", err)
+	}
+
+	pc := &pooledConn{host: host, conn: conn, client: client}
+	if mode == tlsNone {
+		return pc, nil, nil
+	}
+
+	failure := &mtasts.Failure{ReceivingMX: host}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		failure.ReceivingIP = addr.IP.String()
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); !ok {
+		failure.Result = mtasts.ResultSTARTTLSNotSupported
+		failure.Info = "STARTTLS not offered"
+		return pc, failure, nil
+	}
+
+	// Try STARTTLS with TLS 1.3 preferred
+	config := &tls.Config{
+		ServerName:         host,
+		MinVersion:         tls.VersionTLS12, // Allow TLS 1.2 for outbound compatibility
+		InsecureSkipVerify: mode == tlsUnverified,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP384,
+			tls.CurveP256,
+		},
+	}
+	if err := client.StartTLS(config); err != nil {
+		client.Close()
+		failure.Result = mtasts.ClassifyTLSError(err)
+		failure.Info = err.Error()
+		return nil, failure, nil
+	}
+
+	return pc, nil, nil
+}
+
+// reapLoop closes sessions that have been idle longer than the idle timeout
+func (p *ConnPool) reapLoop(ctx context.Context) {
+	interval := p.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.reap()
+		}
+	}
+}
+
+func (p *ConnPool) reap() {
+	var expired []*pooledConn
+
+	p.mu.Lock()
+	for host, hp := range p.hosts {
+		kept := hp.idle[:0]
+		for _, pc := range hp.idle {
+			if time.Since(pc.lastUsed) >= p.idleTimeout {
+				expired = append(expired, pc)
+			} else {
+				kept = append(kept, pc)
+			}
+		}
+		hp.idle = kept
+		if hp.users == 0 && len(hp.idle) == 0 {
+			delete(p.hosts, host)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, pc := range expired {
+		pc.close()
+	}
+}
+
+// Close quits all idle sessions. Sessions in use are closed when returned.
+func (p *ConnPool) Close() {
+	var idle []*pooledConn
+
+	p.mu.Lock()
+	p.closed = true
+	for _, hp := range p.hosts {
+		idle = append(idle, hp.idle...)
+		hp.idle = nil
+	}
+	p.mu.Unlock()
+
+	for _, pc := range idle {
+		pc.close()
+	}
+}
+
+// reusableAfter reports whether a session is still in a known state after a
+// delivery attempt. A rejection reply leaves the session usable once reset;
+// network and protocol failures do not.
+func reusableAfter(err error) bool {
+	if err == nil {
+		return true
+	}
+	var reply *textproto.Error
+	return errors.As(err, &reply)
+}
+
+// send runs a single MAIL/RCPT/DATA transaction
+func (c *pooledConn) send(from string, recipients []string, data []byte) error {
+	c.messages++
+	c.dataSent = false
+
+	if err := c.client.Mail(from); err != nil {
+		return fmt.Errorf("MAIL FROM: %w", err)
+	}
+
+	for _, rcpt := range recipients {
+		if err := c.client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("RCPT TO %s: %w", rcpt, err)
+		}
+	}
+
+	writer, err := c.client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA: %w", err)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+
+	c.dataSent = true
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("close data: %w", err)
+	}
+
+	return nil
+}
+
+// reset clears the previous transaction and confirms the remote is still there
+func (c *pooledConn) reset() error {
+	c.conn.SetDeadline(time.Now().Add(smtpCommandTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+	return c.client.Reset()
+}
+
+// close ends the session, sending QUIT if the remote is still listening
+func (c *pooledConn) close() {
+	c.conn.SetDeadline(time.Now().Add(smtpCommandTimeout))
+	if err := c.client.Quit(); err != nil {
+		c.client.Close()
+	}
+}
@@ -0,0 +1,265 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/mtasts"
+)
+
+// fakeMX is a minimal SMTP server that records sessions and commands
+type fakeMX struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	sessions int
+	messages int
+	resets   int
+
+	// dropAfter closes a session after this many messages when non-zero
+	dropAfter int
+}
+
+func newFakeMX(t *testing.T) *fakeMX {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	mx := &fakeMX{listener: l}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go mx.serve(conn)
+		}
+	}()
+	return mx
+}
+
+func (mx *fakeMX) serve(conn net.Conn) {
+	defer conn.Close()
+
+	mx.mu.Lock()
+	mx.sessions++
+	mx.mu.Unlock()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake.example ESMTP")
+
+	sent := 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250 fake.example")
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+			if strings.Contains(cmd, "REJECT@") {
+				reply("550 No such user")
+			} else {
+				reply("250 OK")
+			}
+		case cmd == "RSET":
+			mx.mu.Lock()
+			mx.resets++
+			mx.mu.Unlock()
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 Go ahead")
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+			}
+			mx.mu.Lock()
+			mx.messages++
+			mx.mu.Unlock()
+			reply("250 Queued")
+			sent++
+			if mx.dropAfter > 0 && sent >= mx.dropAfter {
+				return
+			}
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func (mx *fakeMX) counts() (sessions, messages, resets int) {
+	mx.mu.Lock()
+	defer mx.mu.Unlock()
+	return mx.sessions, mx.messages, mx.resets
+}
+
+func newTestPool(mx *fakeMX, maxMessages, maxPerHost int) *ConnPool {
+	p := NewConnPool("mx.test", maxMessages, time.Minute, maxPerHost, zap.NewNop())
+	p.dial = func(ctx context.Context, host string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", mx.listener.Addr().String())
+	}
+	return p
+}
+
+var testMessage = []byte("Subject: test\r\n\r\nhello\r\n")
+
+func TestConnPool_ReusesConnection(t *testing.T) {
+	mx := newFakeMX(t)
+	p := newTestPool(mx, 10, 2)
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := p.Send(context.Background(), "mx.example", "a@example.com", []string{"b@example.com"}, testMessage); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+
+	sessions, messages, resets := mx.counts()
+	if sessions != 1 || messages != 3 || resets != 2 {
+		t.Errorf("sessions=%d messages=%d resets=%d, want 1, 3, 2", sessions, messages, resets)
+	}
+}
+
+func TestConnPool_MaxMessagesPerConnection(t *testing.T) {
+	mx := newFakeMX(t)
+	p := newTestPool(mx, 2, 2)
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := p.Send(context.Background(), "mx.example", "a@example.com", []string{"b@example.com"}, testMessage); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+
+	if sessions, _, _ := mx.counts(); sessions != 2 {
+		t.Errorf("sessions=%d, want 2", sessions)
+	}
+}
+
+func TestConnPool_RejectionKeepsConnection(t *testing.T) {
+	mx := newFakeMX(t)
+	p := newTestPool(mx, 10, 2)
+	defer p.Close()
+
+	if err := p.Send(context.Background(), "mx.example", "a@example.com", []string{"reject@example.com"}, testMessage); err == nil {
+		t.Fatal("expected rejection")
+	}
+	if err := p.Send(context.Background(), "mx.example", "a@example.com", []string{"b@example.com"}, testMessage); err != nil {
+		t.Fatalf("send after rejection: %v", err)
+	}
+
+	sessions, messages, resets := mx.counts()
+	if sessions != 1 || messages != 1 || resets != 1 {
+		t.Errorf("sessions=%d messages=%d resets=%d, want 1, 1, 1", sessions, messages, resets)
+	}
+}
+
+func TestConnPool_RemoteDropFallsBackToNewConnection(t *testing.T) {
+	mx := newFakeMX(t)
+	mx.dropAfter = 1
+	p := newTestPool(mx, 10, 2)
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := p.Send(context.Background(), "mx.example", "a@example.com", []string{"b@example.com"}, testMessage); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+
+	sessions, messages, _ := mx.counts()
+	if sessions != 2 || messages != 2 {
+		t.Errorf("sessions=%d messages=%d, want 2, 2", sessions, messages)
+	}
+}
+
+func TestConnPool_PerHostConcurrencyLimit(t *testing.T) {
+	mx := newFakeMX(t)
+	p := newTestPool(mx, 10, 1)
+	defer p.Close()
+
+	pc, _, err := p.get(context.Background(), "mx.example", true, false)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.get(ctx, "mx.example", true, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second get = %v, want deadline exceeded while the only slot is held", err)
+	}
+
+	// Other destinations are unaffected
+	other, _, err := p.get(context.Background(), "mx.other.example", true, false)
+	if err != nil {
+		t.Fatalf("get other host: %v", err)
+	}
+	p.put(other, nil)
+
+	p.put(pc, nil)
+	if pc, _, err = p.get(context.Background(), "mx.example", true, false); err != nil {
+		t.Fatalf("get after release: %v", err)
+	}
+	p.put(pc, nil)
+}
+
+func TestConnPool_RequireTLSDefersWithoutSTARTTLS(t *testing.T) {
+	mx := newFakeMX(t)
+	p := newTestPool(mx, 10, 2)
+	defer p.Close()
+
+	failure, err := p.SendWithPolicy(context.Background(), "mx.example", true, "a@example.com", []string{"b@example.com"}, testMessage)
+	var tlsErr *TLSError
+	if !errors.As(err, &tlsErr) {
+		t.Fatalf("SendWithPolicy error = %v, want *TLSError", err)
+	}
+	if failure == nil || failure.Result != mtasts.ResultSTARTTLSNotSupported || failure.ReceivingIP != "127.0.0.1" {
+		t.Errorf("failure = %+v, want starttls-not-supported from 127.0.0.1", failure)
+	}
+	if _, messages, _ := mx.counts(); messages != 0 {
+		t.Errorf("messages=%d, want nothing sent in clear", messages)
+	}
+}
+
+func TestConnPool_OpportunisticTLSReportsFailure(t *testing.T) {
+	mx := newFakeMX(t)
+	p := newTestPool(mx, 10, 2)
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		failure, err := p.SendWithPolicy(context.Background(), "mx.example", false, "a@example.com", []string{"b@example.com"}, testMessage)
+		if err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+		if failure == nil || failure.Result != mtasts.ResultSTARTTLSNotSupported {
+			t.Errorf("send %d failure = %+v, want starttls-not-supported", i, failure)
+		}
+	}
+
+	if sessions, messages, _ := mx.counts(); sessions != 1 || messages != 2 {
+		t.Errorf("sessions=%d messages=%d, want 1, 2", sessions, messages)
+	}
+}
@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// queuePriorities lists the priorities from highest to lowest
+var queuePriorities = []int{domain.PriorityHigh, domain.PriorityNormal, domain.PriorityLow}
+
+var queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "smtp_queue_depth",
+	Help: "Messages waiting in the outbound queue per priority",
+}, []string{"priority"})
+
+// priorityQueueKey returns the Redis list holding the IDs of queued messages
+// of a priority
+func priorityQueueKey(priority int) string {
+	return "queue:priority:" + domain.PriorityName(priority)
+}
+
+// priorityScheduler decides which priority list the next message is taken
+// from. It uses smooth weighted round-robin, so with weights 6/3/1 every ten
+// messages are six high, three normal and one low priority, interleaved,
+// and a newsletter blast can't hold back password resets nor starve itself.
+type priorityScheduler struct {
+	mu      sync.Mutex
+	weights map[int]int
+	current map[int]int
+}
+
+// newPriorityScheduler creates a scheduler. Weights below 1 are raised to
+// 1, since a priority without a share would never drain.
+func newPriorityScheduler(high, normal, low int) *priorityScheduler {
+	s := &priorityScheduler{
+		weights: map[int]int{
+			domain.PriorityHigh:   high,
+			domain.PriorityNormal: normal,
+			domain.PriorityLow:    low,
+		},
+		current: make(map[int]int, len(queuePriorities)),
+	}
+	for priority, weight := range s.weights {
+		if weight < 1 {
+			s.weights[priority] = 1
+		}
+	}
+	return s
+}
+
+// next returns the priorities in the order to try them for the next
+// message: the scheduled one first, then the others from high to low so no
+// capacity is wasted when the scheduled list is empty
+func (s *priorityScheduler) next() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total, chosen := 0, queuePriorities[0]
+	for _, priority := range queuePriorities {
+		s.current[priority] += s.weights[priority]
+		total += s.weights[priority]
+		if s.current[priority] > s.current[chosen] {
+			chosen = priority
+		}
+	}
+	s.current[chosen] -= total
+
+	order := make([]int, 0, len(queuePriorities))
+	order = append(order, chosen)
+	for _, priority := range queuePriorities {
+		if priority != chosen {
+			order = append(order, priority)
+		}
+	}
+	return order
+}
+
+// popQueued takes the ID of the next queued message from the priority lists,
+// returning redis.Nil when they are all empty
+func (m *Manager) popQueued(ctx context.Context) (string, error) {
+	for _, priority := range m.scheduler.next() {
+		id, err := m.redis.LPop(ctx, priorityQueueKey(priority)).Result()
+		if err == redis.Nil {
+			continue
+		}
+		return id, err
+	}
+	return "", redis.Nil
+}
+
+// NextMessages claims up to limit messages for delivery. They are taken from
+// the priority lists in weighted order; the last slot of the batch, and any
+// the lists can't fill, go to pending messages in the database, which covers
+// retries, system mail and messages whose push to Redis failed. Messages
+// claimed before an error are returned along with it.
+func (m *Manager) NextMessages(ctx context.Context, limit int) ([]*domain.Message, error) {
+	messages := make([]*domain.Message, 0, limit)
+
+	for len(messages) < limit-1 {
+		id, err := m.popQueued(ctx)
+		if err == redis.Nil {
+			break
+		}
+		if err != nil {
+			m.logger.Warn("Failed to pop from priority queue", zap.Error(err))
+			break
+		}
+
+		msg, err := m.msgRepo.ClaimMessage(ctx, id)
+		if err != nil {
+			return messages, err
+		}
+		// Already claimed from the database, cancelled, or not yet due
+		if msg != nil {
+			messages = append(messages, msg)
+		}
+	}
+
+	pending, err := m.msgRepo.GetPendingMessages(ctx, limit-len(messages))
+	if err != nil {
+		return messages, err
+	}
+	for _, p := range pending {
+		msg, err := m.msgRepo.ClaimMessage(ctx, p.ID)
+		if err != nil {
+			return messages, err
+		}
+		if msg != nil {
+			messages = append(messages, msg)
+		}
+	}
+
+	return messages, nil
+}
+
+// depthLoop periodically publishes the length of each priority list
+func (m *Manager) depthLoop(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		m.recordQueueDepth(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) recordQueueDepth(ctx context.Context) {
+	for _, priority := range queuePriorities {
+		depth, err := m.redis.LLen(ctx, priorityQueueKey(priority)).Result()
+		if err != nil {
+			m.logger.Warn("Failed to read queue depth", zap.Error(err))
+			return
+		}
+		queueDepth.WithLabelValues(domain.PriorityName(priority)).Set(float64(depth))
+	}
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/sieve"
+)
+
+// sieveFolders runs a mailbox's Sieve script on a message and returns the
+// folders to file it into, none if the script discards it. A broken script
+// must never lose mail, so a script that cannot be loaded, compiled or run
+// delivers to the inbox, as does a fileinto whose folder no longer exists.
+func (w *Worker) sieveFolders(ctx context.Context, msg *domain.Message, mailbox *domain.Mailbox, data []byte) []string {
+	inbox := []string{sieve.InboxFolder}
+	if !w.manager.config.Sieve.Enabled {
+		return inbox
+	}
+
+	script, err := w.manager.GetSieveScript(ctx, mailbox.ID)
+	if err != nil {
+		w.sieveFallback(msg, mailbox, "load script", err)
+		return inbox
+	}
+	if script == nil || !script.IsActive {
+		return inbox
+	}
+
+	compiled, err := sieve.Compile(script.Script)
+	if err != nil {
+		w.sieveFallback(msg, mailbox, "compile script", err)
+		return inbox
+	}
+
+	parsed, err := sieve.NewMessage(data)
+	if err != nil {
+		w.sieveFallback(msg, mailbox, "parse message", err)
+		return inbox
+	}
+
+	targets := compiled.Execute(parsed)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var existing []string
+	var folders []string
+	for _, target := range targets {
+		if strings.EqualFold(target, sieve.InboxFolder) {
+			folders = appendFolder(folders, sieve.InboxFolder)
+			continue
+		}
+
+		if existing == nil {
+			if existing, err = w.manager.ListMailFolderPaths(ctx, mailbox.ID); err != nil {
+				w.sieveFallback(msg, mailbox, "list folders", err)
+				return inbox
+			}
+		}
+		if !hasFolder(existing, target) {
+			w.sieveFallback(msg, mailbox, "file into folder", fmt.Errorf("folder %q does not exist", target))
+			folders = appendFolder(folders, sieve.InboxFolder)
+			continue
+		}
+		folders = appendFolder(folders, target)
+	}
+	return folders
+}
+
+// sieveFallback logs a Sieve failure that sends a message to the inbox
+func (w *Worker) sieveFallback(msg *domain.Message, mailbox *domain.Mailbox, stage string, err error) {
+	w.logger.Warn("Sieve script failed, delivering to inbox",
+		zap.String("message_id", msg.ID),
+		zap.String("mailbox", mailbox.Email),
+		zap.String("stage", stage),
+		zap.Error(err))
+}
+
+func appendFolder(folders []string, folder string) []string {
+	if hasFolder(folders, folder) {
+		return folders
+	}
+	return append(folders, folder)
+}
+
+func hasFolder(folders []string, folder string) bool {
+	for _, f := range folders {
+		if f == folder {
+			return true
+		}
+	}
+	return false
+}
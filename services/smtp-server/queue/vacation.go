@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/vacation"
+)
+
+// vacationReplyKey is the Redis key recording that a mailbox's vacation
+// responder answered a sender. It expires after the reply interval.
+func vacationReplyKey(mailboxID, sender string) string {
+	return "vacation:replied:" + mailboxID + ":" + strings.ToLower(sender)
+}
+
+// vacationReply sends a mailbox's out-of-office reply to the sender of a
+// message delivered to it, at most once per sender per reply interval.
+// Failures are only logged; they never affect delivery.
+func (w *Worker) vacationReply(ctx context.Context, msg *domain.Message, mailbox *domain.Mailbox, data []byte) {
+	if !w.manager.config.Vacation.Enabled {
+		return
+	}
+
+	responder, err := w.manager.GetVacationResponder(ctx, mailbox.ID)
+	if err != nil {
+		w.logger.Warn("Failed to load vacation responder",
+			zap.String("mailbox", mailbox.Email),
+			zap.Error(err))
+		return
+	}
+	now := time.Now()
+	if responder == nil || !responder.InEffect(now) {
+		return
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	sender := msg.FromAddress
+	addresses := append([]string{mailbox.Email}, responder.Addresses...)
+	if reason := vacation.Skip(parsed.Header, sender, addresses); reason != "" {
+		w.logger.Debug("Not sending vacation reply",
+			zap.String("message_id", msg.ID),
+			zap.String("mailbox", mailbox.Email),
+			zap.String("reason", reason))
+		return
+	}
+
+	// Record the reply before sending it, so concurrent deliveries from the
+	// same sender produce a single reply
+	key := vacationReplyKey(mailbox.ID, sender)
+	claimed, err := w.manager.redis.SetNX(ctx, key, now.Unix(), vacation.ReplyInterval(responder)).Result()
+	if err != nil {
+		w.logger.Warn("Failed to record vacation reply", zap.Error(err))
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	if err := w.queueVacationReply(ctx, msg, mailbox, responder, parsed.Header, now); err != nil {
+		w.logger.Error("Failed to queue vacation reply",
+			zap.String("message_id", msg.ID),
+			zap.String("mailbox", mailbox.Email),
+			zap.Error(err))
+		// Let the next message from this sender try again
+		w.manager.redis.Del(ctx, key)
+		return
+	}
+
+	w.logger.Info("Vacation reply queued",
+		zap.String("message_id", msg.ID),
+		zap.String("mailbox", mailbox.Email))
+}
+
+func (w *Worker) queueVacationReply(ctx context.Context, msg *domain.Message, mailbox *domain.Mailbox, responder *domain.VacationResponder, header mail.Header, now time.Time) error {
+	sender := msg.FromAddress
+	reply := vacation.Reply(responder, header, mailbox.Email, sender, w.manager.config.Server.Hostname, now)
+
+	path, err := w.manager.StoreMessage(ctx, reply)
+	if err != nil {
+		return err
+	}
+
+	replyMsg := &domain.Message{
+		ID:             uuid.New().String(),
+		OrganizationID: msg.OrganizationID,
+		DomainID:       msg.DomainID,
+		FromAddress:    "", // Null return path, so the reply can't bounce back to us (RFC 3834)
+		Recipients:     []string{sender},
+		Subject:        header.Get("Subject"),
+		Headers: map[string]string{
+			"X-Target-Domain":       recipientDomain(sender),
+			"X-Original-Message-ID": msg.ID,
+		},
+		BodySize:       int64(len(reply)),
+		RawMessagePath: path,
+		Status:         domain.StatusPending,
+		Priority:       domain.PriorityNormal,
+		QueuedAt:       now,
+		CreatedAt:      now,
+		MaxRetries:     3,
+	}
+
+	return w.manager.Enqueue(ctx, replyMsg)
+}
@@ -1,20 +1,19 @@
 package queue
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
-	"net/smtp"
+	"net/url"
 	"strings"
-	"text/template"
 	"time"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/mtasts"
 )
 
 // Worker processes messages from the queue
@@ -55,11 +54,10 @@ func (w *Worker) Run(ctx context.Context) {
 }
 
 func (w *Worker) processMessages(ctx context.Context) {
-	// Get batch of pending messages
-	messages, err := w.manager.GetPendingMessages(ctx, 10)
+	// Claim a batch of messages, weighted by priority
+	messages, err := w.manager.NextMessages(ctx, 10)
 	if err != nil {
 		w.logger.Error("Failed to get pending messages", zap.Error(err))
-		return
 	}
 
 	for _, msg := range messages {
@@ -121,21 +119,30 @@ func (w *Worker) processMessage(ctx context.Context, msg *domain.Message) {
 			if err := w.manager.ScheduleRetry(ctx, msg, err.Error()); err != nil {
 				w.logger.Error("Failed to schedule retry", zap.Error(err))
 			}
+			if dsnErr := w.generateDelayDSN(ctx, msg, err); dsnErr != nil {
+				w.logger.Error("Failed to generate delay DSN", zap.Error(dsnErr))
+			}
+			w.manager.events.Report(msg, EventDeferred, err)
 		} else {
 			// Max retries exceeded
 			if err := w.manager.MarkFailed(ctx, msg); err != nil {
 				w.logger.Error("Failed to mark message failed", zap.Error(err))
 			}
 			// Generate and queue bounce message
-			if err := w.generateBounceMessage(ctx, msg, "Delivery failed after maximum retry attempts"); err != nil {
-				w.logger.Error("Failed to generate bounce message", zap.Error(err))
+			if bounceErr := w.generateBounceMessage(ctx, msg, err); bounceErr != nil {
+				w.logger.Error("Failed to generate bounce message", zap.Error(bounceErr))
 			}
+			w.manager.events.Report(msg, EventBounced, err)
 		}
 	} else {
 		// Mark as delivered
 		if err := w.manager.UpdateMessageStatus(ctx, msg.ID, domain.StatusDelivered); err != nil {
 			w.logger.Error("Failed to mark message delivered", zap.Error(err))
 		}
+		w.manager.events.Report(msg, EventDelivered, nil)
+		if err := w.generateSuccessDSN(ctx, msg, localDomain == nil); err != nil {
+			w.logger.Error("Failed to generate success DSN", zap.Error(err))
+		}
 
 		w.logger.Info("Message delivered",
 			zap.String("message_id", msg.ID),
@@ -193,6 +200,11 @@ func (w *Worker) deliverLocal(ctx context.Context, msg *domain.Message, targetDo
 
 // deliverToMailbox delivers a message to a single recipient's mailbox
 func (w *Worker) deliverToMailbox(ctx context.Context, msg *domain.Message, targetDomain *domain.Domain, recipient string, data []byte) error {
+	// Alias and distribution list targets may be outside our domains
+	if rcptDomain := recipientDomain(recipient); rcptDomain != "" && w.manager.domainCache.GetDomain(rcptDomain) == nil {
+		return w.forwardExternal(ctx, msg, targetDomain, recipient, data)
+	}
+
 	// Look up recipient (could be mailbox, alias, or distribution list)
 	lookupResult, err := w.manager.LookupRecipient(ctx, recipient)
 	if err != nil {
@@ -200,18 +212,18 @@ func (w *Worker) deliverToMailbox(ctx context.Context, msg *domain.Message, targ
 	}
 
 	if !lookupResult.Found {
-		// Check if catch-all is enabled
-		if targetDomain.Policies != nil && targetDomain.Policies.CatchAllEnabled && targetDomain.Policies.CatchAllAddress != "" {
-			// Redirect to catch-all
-			lookupResult, err = w.manager.LookupRecipient(ctx, targetDomain.Policies.CatchAllAddress)
-			if err != nil || !lookupResult.Found {
-				return fmt.Errorf("recipient not found and catch-all failed")
-			}
-		} else if targetDomain.Policies != nil && targetDomain.Policies.RejectUnknownUsers {
-			return fmt.Errorf("recipient not found: %s", recipient)
-		} else {
+		// Redirect by the domain's catch-all rules
+		dest, err := w.resolveCatchAll(ctx, targetDomain, recipient)
+		if err != nil {
+			return err
+		}
+		if dest == "" {
 			return fmt.Errorf("recipient not found: %s", recipient)
 		}
+		if destDomain := w.manager.domainCache.GetDomain(recipientDomain(dest)); destDomain != nil {
+			targetDomain = destDomain
+		}
+		return w.deliverToMailbox(ctx, msg, targetDomain, dest, data)
 	}
 
 	// Handle different recipient types
@@ -236,8 +248,156 @@ func (w *Worker) deliverToMailbox(ctx context.Context, msg *domain.Message, targ
 	}
 }
 
-// storeInMailbox stores a message in a user's mailbox with atomic quota enforcement
+// forwardExternal queues a copy of a message for an external alias or
+// distribution list target. The copy is ARC sealed by the forwarding domain
+// so the receiver can trust our authentication results even though
+// forwarding breaks SPF and, if the list rewrites the message, DKIM.
+func (w *Worker) forwardExternal(ctx context.Context, msg *domain.Message, forwardingDomain *domain.Domain, recipient string, data []byte) error {
+	if w.manager.sealer != nil && forwardingDomain.DKIMVerified {
+		sealed, err := w.manager.sealer.SealForwarded(forwardingDomain.Name, data)
+		if err != nil {
+			// Forward unsealed rather than dropping the message
+			w.logger.Warn("Failed to ARC seal forwarded message",
+				zap.String("message_id", msg.ID),
+				zap.String("domain", forwardingDomain.Name),
+				zap.Error(err))
+		} else {
+			data = sealed
+		}
+	}
+
+	path, err := w.manager.StoreMessage(ctx, data)
+	if err != nil {
+		return fmt.Errorf("store forwarded message: %w", err)
+	}
+
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	targetDomain := recipientDomain(recipient)
+	headers["X-Target-Domain"] = targetDomain
+
+	forwarded := &domain.Message{
+		ID:             uuid.New().String(),
+		OrganizationID: forwardingDomain.OrganizationID,
+		DomainID:       forwardingDomain.ID,
+		FromAddress:    msg.FromAddress,
+		Recipients:     []string{recipient},
+		Subject:        msg.Subject,
+		Headers:        headers,
+		BodySize:       int64(len(data)),
+		RawMessagePath: path,
+		Status:         domain.StatusPending,
+		Priority:       msg.Priority,
+		MaxRetries:     msg.MaxRetries,
+		CreatedAt:      time.Now(),
+	}
+
+	if err := w.manager.Enqueue(ctx, forwarded); err != nil {
+		return fmt.Errorf("enqueue forwarded message: %w", err)
+	}
+
+	w.logger.Debug("Queued forwarded message",
+		zap.String("message_id", msg.ID),
+		zap.String("forwarded_id", forwarded.ID),
+		zap.String("recipient", recipient),
+		zap.String("target_domain", targetDomain))
+
+	return nil
+}
+
+// recipientDomain returns the lowercased domain part of an address
+// resolveCatchAll follows catch-all routing for an unknown recipient of a
+// local domain until it reaches an existing recipient or an address outside
+// our domains. It returns "" if the mail is rejected, and an error if the
+// routing loops or takes more than domain.MaxCatchAllHops redirects.
+func (w *Worker) resolveCatchAll(ctx context.Context, targetDomain *domain.Domain, recipient string) (string, error) {
+	seen := map[string]bool{strings.ToLower(recipient): true}
+	address := recipient
+
+	for hops := 0; hops < domain.MaxCatchAllHops; hops++ {
+		localPart := address
+		if at := strings.LastIndex(address, "@"); at >= 0 {
+			localPart = address[:at]
+		}
+
+		dest, err := w.manager.domainCache.CatchAllDestination(ctx, targetDomain, localPart)
+		if err != nil {
+			return "", fmt.Errorf("resolve catch-all: %w", err)
+		}
+		if dest == "" {
+			return "", nil
+		}
+		if seen[strings.ToLower(dest)] {
+			return "", fmt.Errorf("catch-all routing loop for %s at %s", recipient, dest)
+		}
+		seen[strings.ToLower(dest)] = true
+
+		destDomain := w.manager.domainCache.GetDomain(recipientDomain(dest))
+		if destDomain == nil {
+			return dest, nil
+		}
+		lookupResult, err := w.manager.LookupRecipient(ctx, dest)
+		if err != nil {
+			return "", fmt.Errorf("lookup catch-all destination: %w", err)
+		}
+		if lookupResult.Found {
+			return dest, nil
+		}
+		targetDomain, address = destDomain, dest
+	}
+
+	return "", fmt.Errorf("catch-all routing for %s exceeds %d redirects", recipient, domain.MaxCatchAllHops)
+}
+
+func recipientDomain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(address[at+1:])
+}
+
+// storeInMailbox stores a message in the folders of a user's mailbox its
+// Sieve script picks, or the inbox. It only fails if no copy was stored.
 func (w *Worker) storeInMailbox(ctx context.Context, msg *domain.Message, mailbox *domain.Mailbox, data []byte) error {
+	folders := w.sieveFolders(ctx, msg, mailbox, data)
+	if len(folders) == 0 {
+		w.logger.Info("Message discarded by Sieve script",
+			zap.String("message_id", msg.ID),
+			zap.String("mailbox", mailbox.Email))
+		return nil
+	}
+
+	var firstErr error
+	stored := 0
+	for _, folder := range folders {
+		if err := w.storeInFolder(ctx, msg, mailbox, folder, data); err != nil {
+			w.logger.Warn("Failed to store message in folder",
+				zap.String("message_id", msg.ID),
+				zap.String("mailbox", mailbox.Email),
+				zap.String("folder", folder),
+				zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		stored++
+	}
+
+	if stored == 0 {
+		return firstErr
+	}
+
+	w.vacationReply(ctx, msg, mailbox, data)
+	w.recordReadReceipt(ctx, msg, mailbox, data)
+	return nil
+}
+
+// storeInFolder stores a message in a mailbox folder with atomic quota enforcement
+func (w *Worker) storeInFolder(ctx context.Context, msg *domain.Message, mailbox *domain.Mailbox, folder string, data []byte) error {
 	messageSize := int64(len(data))
 
 	// Atomic quota check and update - prevents race conditions
@@ -246,7 +406,7 @@ func (w *Worker) storeInMailbox(ctx context.Context, msg *domain.Message, mailbo
 		if errors.Is(err, w.manager.ErrQuotaExceeded()) {
 			// Record quota exceeded metric
 			w.manager.RecordQuotaExceeded(mailbox.ID, mailbox.Email)
-			return fmt.Errorf("mailbox quota exceeded: %d/%d bytes used", mailbox.UsedBytes, mailbox.QuotaBytes)
+			return fmt.Errorf("%w: %d/%d bytes used", err, mailbox.UsedBytes, mailbox.QuotaBytes)
 		}
 		return fmt.Errorf("quota check failed: %w", err)
 	}
@@ -258,10 +418,11 @@ func (w *Worker) storeInMailbox(ctx context.Context, msg *domain.Message, mailbo
 	}
 
 	// Store message in mailbox storage
-	storagePath := fmt.Sprintf("%s/%s/%s/INBOX/%s.eml",
+	storagePath := fmt.Sprintf("%s/%s/%s/%s/%s.eml",
 		mailbox.OrganizationID,
 		mailbox.DomainID,
 		mailbox.ID,
+		url.PathEscape(folder),
 		msg.ID,
 	)
 
@@ -276,25 +437,29 @@ func (w *Worker) storeInMailbox(ctx context.Context, msg *domain.Message, mailbo
 	}
 
 	// Record message in mailbox messages table
-	if err := w.manager.RecordMailboxMessage(ctx, mailbox.ID, msg, storagePath, messageSize); err != nil {
+	if err := w.manager.RecordMailboxMessage(ctx, mailbox.ID, folder, msg, storagePath, messageSize); err != nil {
 		w.logger.Warn("Failed to record mailbox message",
 			zap.String("mailbox_id", mailbox.ID),
 			zap.Error(err))
 	}
 
 	// Deliver to mail_messages table (web app UI) — best-effort
-	if err := w.manager.DeliverToMailFolder(ctx, mailbox.ID, msg, data, storagePath); err != nil {
+	if err := w.manager.DeliverToMailFolder(ctx, mailbox.ID, folder, msg, data, storagePath); err != nil {
 		w.logger.Warn("Failed to deliver to mail_messages",
 			zap.String("mailbox_id", mailbox.ID),
 			zap.Error(err))
 	}
 
+	// Wake IMAP IDLE sessions on the folder
+	w.manager.NotifyMailboxDelivery(ctx, mailbox.ID, folder)
+
 	// Record quota metrics
 	w.manager.RecordQuotaUsage(mailbox.ID, mailbox.Email, newUsedBytes, quotaBytes)
 
 	w.logger.Debug("Message stored in mailbox",
 		zap.String("message_id", msg.ID),
 		zap.String("mailbox", mailbox.Email),
+		zap.String("folder", folder),
 		zap.Int64("size", messageSize),
 		zap.Int64("used_bytes", newUsedBytes),
 		zap.Int64("quota_bytes", quotaBytes))
@@ -355,15 +520,29 @@ func (w *Worker) deliverExternal(ctx context.Context, msg *domain.Message, targe
 		return fmt.Errorf("no MX records for %s", targetDomain)
 	}
 
+	policy := w.lookupSTSPolicy(ctx, targetDomain)
+
 	// Try MX hosts in priority order
 	var lastErr error
 	for _, mx := range mxRecords {
 		host := strings.TrimSuffix(mx.Host, ".")
-		err := w.deliverToHost(ctx, host, msg, data)
+		if policy != nil && !policy.MatchesMX(host) {
+			w.manager.tlsReports.RecordFailure(targetDomain, policy, mtasts.Failure{
+				Result:      mtasts.ResultValidationFailure,
+				ReceivingMX: host,
+				Info:        "MX host not listed in MTA-STS policy",
+			})
+			if policy.Enforced() {
+				lastErr = newDeliveryError(host, fmt.Errorf("MX host %s not permitted by MTA-STS policy of %s", host, targetDomain))
+				continue
+			}
+		}
+
+		err := w.deliverToHost(ctx, host, msg, data, targetDomain, policy)
 		if err == nil {
 			return nil
 		}
-		lastErr = err
+		lastErr = newDeliveryError(host, err)
 		w.logger.Debug("Failed to deliver to MX host",
 			zap.String("host", host),
 			zap.Error(err))
@@ -372,232 +551,46 @@ func (w *Worker) deliverExternal(ctx context.Context, msg *domain.Message, targe
 	return fmt.Errorf("all MX hosts failed: %w", lastErr)
 }
 
-func (w *Worker) deliverToHost(ctx context.Context, host string, msg *domain.Message, data []byte) error {
-	// Try port 25 with STARTTLS
-	addr := fmt.Sprintf("%s:25", host)
-
-	// Connect with timeout
-	dialer := &net.Dialer{Timeout: 30 * time.Second}
-	conn, err := dialer.DialContext(ctx, "tcp", addr)
-	if err != nil {
-		return fmt.Errorf("connect to %s: %w", addr, err)
-	}
-	defer conn.Close()
-
-	// Create SMTP client
-	client, err := smtp.NewClient(conn, host)
-	if err != nil {
-		return fmt.Errorf("create SMTP client: %w", err)
-	}
-	defer client.Close()
-
-	// Say hello
-	if err := client.Hello(w.manager.config.Server.Hostname); err != nil {
-		return fmt.Errorf("HELO: %w", err)
-	}
-
-	// Try STARTTLS with TLS 1.3 preferred
-	if ok, _ := client.Extension("STARTTLS"); ok {
-		config := &tls.Config{
-			ServerName: host,
-			MinVersion: tls.VersionTLS12, // Allow TLS 1.2 for outbound compatibility
-			CurvePreferences: []tls.CurveID{
-				tls.X25519,
-				tls.CurveP384,
-				tls.CurveP256,
-			},
-		}
-		if err := client.StartTLS(config); err != nil {
-			w.logger.Debug("STARTTLS failed, continuing without TLS",
-				zap.String("host", host),
-				zap.Error(err))
-		}
-	}
-
-	// Set sender
-	if err := client.Mail(msg.FromAddress); err != nil {
-		return fmt.Errorf("MAIL FROM: %w", err)
-	}
-
-	// Set recipients
-	for _, rcpt := range msg.Recipients {
-		if err := client.Rcpt(rcpt); err != nil {
-			return fmt.Errorf("RCPT TO %s: %w", rcpt, err)
-		}
-	}
-
-	// Send data
-	writer, err := client.Data()
-	if err != nil {
-		return fmt.Errorf("DATA: %w", err)
-	}
-
-	if _, err := writer.Write(data); err != nil {
-		return fmt.Errorf("write data: %w", err)
-	}
-
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("close data: %w", err)
-	}
-
-	// Quit
-	if err := client.Quit(); err != nil {
-		w.logger.Debug("QUIT failed", zap.Error(err))
-	}
-
-	return nil
-}
-
-// bounceTemplate is the template for bounce messages
-var bounceTemplate = template.Must(template.New("bounce").Parse(`From: Mail Delivery System <MAILER-DAEMON@{{.LocalDomain}}>
-To: {{.OriginalSender}}
-Subject: Undelivered Mail Returned to Sender
-Date: {{.Date}}
-Message-ID: <{{.BounceID}}@{{.LocalDomain}}>
-MIME-Version: 1.0
-Content-Type: multipart/report; report-type=delivery-status;
-	boundary="{{.Boundary}}"
-
-This is a MIME-encapsulated message.
-
---{{.Boundary}}
-Content-Type: text/plain; charset=utf-8
-Content-Transfer-Encoding: 7bit
-
-This is the mail system at host {{.LocalDomain}}.
-
-I'm sorry to have to inform you that your message could not
-be delivered to one or more recipients. It's attached below.
-
-For further assistance, please send mail to postmaster.
-
-If you do so, please include this problem report. You can
-delete your own text from the attached returned message.
-
-                   The mail system
-
-{{range .FailedRecipients}}
-<{{.Address}}>: {{.Reason}}
-{{end}}
-
---{{.Boundary}}
-Content-Type: message/delivery-status
-
-Reporting-MTA: dns; {{.LocalDomain}}
-Arrival-Date: {{.ArrivalDate}}
-
-{{range .FailedRecipients}}
-Final-Recipient: rfc822; {{.Address}}
-Action: failed
-Status: 5.0.0
-Diagnostic-Code: smtp; {{.Reason}}
-{{end}}
-
---{{.Boundary}}
-Content-Type: message/rfc822
-Content-Disposition: inline
-
-{{.OriginalHeaders}}
---{{.Boundary}}--
-`))
-
-// bounceData holds the data for generating bounce messages
-type bounceData struct {
-	LocalDomain      string
-	OriginalSender   string
-	Date             string
-	BounceID         string
-	Boundary         string
-	ArrivalDate      string
-	FailedRecipients []failedRecipient
-	OriginalHeaders  string
-}
-
-type failedRecipient struct {
-	Address string
-	Reason  string
-}
-
-// generateBounceMessage creates and queues a bounce message for a failed delivery
-func (w *Worker) generateBounceMessage(ctx context.Context, msg *domain.Message, reason string) error {
-	// Don't bounce bounces (null sender)
-	if msg.FromAddress == "" || strings.HasPrefix(msg.FromAddress, "MAILER-DAEMON") {
-		w.logger.Debug("Not bouncing a bounce message",
-			zap.String("message_id", msg.ID))
+// lookupSTSPolicy returns the MTA-STS policy to apply to a destination, or
+// nil if none applies. A published policy that can't be fetched is reported
+// and delivery proceeds without one.
+func (w *Worker) lookupSTSPolicy(ctx context.Context, targetDomain string) *mtasts.Policy {
+	if w.manager.stsPolicies == nil {
 		return nil
 	}
 
-	// Extract original headers from message
-	originalHeaders := ""
-	if msg.RawMessagePath != "" {
-		data, err := w.manager.GetMessageData(msg.RawMessagePath)
-		if err == nil {
-			// Extract just headers (up to first blank line)
-			if idx := bytes.Index(data, []byte("\r\n\r\n")); idx > 0 {
-				originalHeaders = string(data[:idx])
-			} else if idx := bytes.Index(data, []byte("\n\n")); idx > 0 {
-				originalHeaders = string(data[:idx])
-			}
-		}
-	}
-
-	// Create bounce data
-	now := time.Now()
-	data := bounceData{
-		LocalDomain:    w.manager.config.Server.Hostname,
-		OriginalSender: msg.FromAddress,
-		Date:           now.Format(time.RFC1123Z),
-		BounceID:       fmt.Sprintf("bounce-%s-%d", msg.ID, now.UnixNano()),
-		Boundary:       fmt.Sprintf("----=_Part_%d_%d", now.UnixNano(), now.Unix()),
-		ArrivalDate:    msg.QueuedAt.Format(time.RFC1123Z),
-		OriginalHeaders: originalHeaders,
-	}
-
-	// Add failed recipients
-	for _, rcpt := range msg.Recipients {
-		data.FailedRecipients = append(data.FailedRecipients, failedRecipient{
-			Address: rcpt,
-			Reason:  reason,
+	policy, err := w.manager.stsPolicies.Lookup(ctx, targetDomain)
+	var fetchErr *mtasts.FetchError
+	if errors.As(err, &fetchErr) {
+		w.manager.tlsReports.RecordFailure(targetDomain, nil, mtasts.Failure{
+			Result: fetchErr.Result,
+			Info:   fetchErr.Err.Error(),
 		})
 	}
-
-	// Render bounce message
-	var buf bytes.Buffer
-	if err := bounceTemplate.Execute(&buf, data); err != nil {
-		return fmt.Errorf("render bounce template: %w", err)
-	}
-
-	// Create bounce message
-	bounceMsg := &domain.Message{
-		ID:             fmt.Sprintf("bounce-%s", msg.ID),
-		OrganizationID: msg.OrganizationID,
-		FromAddress:    "", // Null sender for bounces
-		Recipients:     []string{msg.FromAddress},
-		Headers: map[string]string{
-			"X-Original-Message-ID": msg.ID,
-			"X-Bounce-Reason":       reason,
-		},
-		Status:    domain.StatusQueued,
-		QueuedAt:  now,
-		MaxRetries: 3, // Fewer retries for bounces
+	if policy == nil || policy.Mode == mtasts.ModeNone {
+		return nil
 	}
+	return policy
+}
 
-	// Store bounce message data
-	bouncePath, err := w.manager.StoreMessage(ctx, buf.Bytes())
-	if err != nil {
-		return fmt.Errorf("store bounce message: %w", err)
+// deliverToHost sends a message to one MX host. Under an enforced MTA-STS
+// policy the session must use TLS verified for host; the TLS outcome of
+// every session under a policy is recorded for TLS reporting.
+func (w *Worker) deliverToHost(ctx context.Context, host string, msg *domain.Message, data []byte, targetDomain string, policy *mtasts.Policy) error {
+	failure, err := w.manager.connPool.SendWithPolicy(ctx, host, policy.Enforced(), msg.FromAddress, msg.Recipients, data)
+	if policy == nil {
+		return err
 	}
-	bounceMsg.RawMessagePath = bouncePath
 
-	// Queue the bounce
-	if err := w.manager.Enqueue(ctx, bounceMsg); err != nil {
-		return fmt.Errorf("enqueue bounce: %w", err)
+	if failure != nil {
+		w.manager.tlsReports.RecordFailure(targetDomain, policy, *failure)
+		w.logger.Warn("MTA-STS TLS check failed",
+			zap.String("domain", targetDomain),
+			zap.String("host", host),
+			zap.String("mode", string(policy.Mode)),
+			zap.String("result", string(failure.Result)))
+	} else if reusableAfter(err) {
+		w.manager.tlsReports.RecordSuccess(targetDomain, policy)
 	}
-
-	w.logger.Info("Bounce message generated",
-		zap.String("original_id", msg.ID),
-		zap.String("bounce_id", bounceMsg.ID),
-		zap.String("sender", msg.FromAddress))
-
-	return nil
+	return err
 }
@@ -3,6 +3,7 @@ package queue
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -337,6 +338,126 @@ func TestWorker_DeliverToMailbox(t *testing.T) {
 	}
 }
 
+// TestWorker_ResolveCatchAll tests catch-all rule routing across domains
+func TestWorker_ResolveCatchAll(t *testing.T) {
+	exampleCom := &domain.Domain{
+		ID:   "domain-1",
+		Name: "example.com",
+		Policies: &domain.DomainPolicies{
+			CatchAllEnabled: true,
+			CatchAllAddress: "inbox@example.com",
+		},
+	}
+	exampleOrg := &domain.Domain{
+		ID:       "domain-2",
+		Name:     "example.org",
+		Policies: domain.DefaultPolicies(),
+	}
+
+	tests := []struct {
+		name      string
+		rules     []*domain.CatchAllRule
+		recipient string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name: "first matching rule",
+			rules: []*domain.CatchAllRule{
+				{DomainID: "domain-1", MatchType: "wildcard", Pattern: "sales-*", Destination: "sales@example.com"},
+				{DomainID: "domain-1", MatchType: "regex", Pattern: "sales.*", Destination: "other@example.com"},
+			},
+			recipient: "Sales-EU@example.com",
+			want:      "sales@example.com",
+		},
+		{
+			name:      "fallback to catch-all address",
+			recipient: "nobody@example.com",
+			want:      "inbox@example.com",
+		},
+		{
+			name: "forward to external address",
+			rules: []*domain.CatchAllRule{
+				{DomainID: "domain-1", MatchType: "exact", Pattern: "billing", Destination: "ap@accounting.test"},
+			},
+			recipient: "billing@example.com",
+			want:      "ap@accounting.test",
+		},
+		{
+			name: "follow routing through another hosted domain",
+			rules: []*domain.CatchAllRule{
+				{DomainID: "domain-1", MatchType: "exact", Pattern: "support", Destination: "help@example.org"},
+				{DomainID: "domain-2", MatchType: "exact", Pattern: "help", Destination: "sales@example.com"},
+			},
+			recipient: "support@example.com",
+			want:      "sales@example.com",
+		},
+		{
+			name: "reject when nothing matches",
+			rules: []*domain.CatchAllRule{
+				{DomainID: "domain-2", MatchType: "exact", Pattern: "help", Destination: "sales@example.com"},
+			},
+			recipient: "unknown@example.org",
+			want:      "",
+		},
+		{
+			name: "loop between two domains",
+			rules: []*domain.CatchAllRule{
+				{DomainID: "domain-1", MatchType: "exact", Pattern: "ping", Destination: "pong@example.org"},
+				{DomainID: "domain-2", MatchType: "exact", Pattern: "pong", Destination: "ping@example.com"},
+			},
+			recipient: "ping@example.com",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			mockRepo := testutil.NewMockMessageRepository()
+			for _, email := range []string{"sales@example.com", "inbox@example.com", "other@example.com"} {
+				mockRepo.AddMailbox(&domain.Mailbox{ID: "mb-" + email, Email: email, IsActive: true})
+			}
+
+			mockDomainCache := testutil.NewMockDomainProvider()
+			mockDomainCache.AddDomain(exampleCom)
+			mockDomainCache.AddDomain(exampleOrg)
+			for _, rule := range tt.rules {
+				if err := mockDomainCache.AddCatchAllRule(rule); err != nil {
+					t.Fatalf("AddCatchAllRule() error = %v", err)
+				}
+			}
+
+			logger := testutil.TestLogger()
+			manager := &Manager{
+				config:       &config.Config{},
+				msgRepo:      mockRepo,
+				domainCache:  mockDomainCache,
+				logger:       logger,
+				rateLimiters: make(map[string]*RateLimiter),
+			}
+			worker := NewWorker(0, manager, logger.Named("worker"))
+
+			targetDomain := mockDomainCache.GetDomain(recipientDomain(tt.recipient))
+			got, err := worker.resolveCatchAll(ctx, targetDomain, tt.recipient)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error but got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveCatchAll() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveCatchAll() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 // TestWorker_GenerateBounceMessage tests bounce message generation
 func TestWorker_GenerateBounceMessage(t *testing.T) {
 	tests := []struct {
@@ -422,7 +543,7 @@ func TestWorker_GenerateBounceMessage(t *testing.T) {
 				tt.message.RawMessagePath = msgPath
 			}
 
-			err := worker.generateBounceMessage(ctx, tt.message, tt.reason)
+			err := worker.generateBounceMessage(ctx, tt.message, errors.New(tt.reason))
 
 			if err != nil {
 				t.Errorf("Unexpected error: %v", err)
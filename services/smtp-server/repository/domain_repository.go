@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -51,8 +53,10 @@ func (r *DomainRepository) GetAllDomains(ctx context.Context) ([]*domain.Domain,
 			d.catch_all_enabled, d.catch_all_address,
 			d.max_message_size, d.require_tls, d.allow_external_relay,
 			d.rate_limit_per_hour, d.rate_limit_per_day,
-			d.created_at, d.updated_at, d.verified_at
+			d.created_at, d.updated_at, d.verified_at,
+			p.outbound_footer_text, p.outbound_footer_html, p.dkim_body_length
 		FROM domains d
+		LEFT JOIN domain_policies p ON p.domain_id = d.id
 		WHERE d.status IN ('verified', 'pending', 'active')
 		ORDER BY d.name
 	`
@@ -84,8 +88,10 @@ func (r *DomainRepository) GetDomainByName(ctx context.Context, name string) (*d
 			d.catch_all_enabled, d.catch_all_address,
 			d.max_message_size, d.require_tls, d.allow_external_relay,
 			d.rate_limit_per_hour, d.rate_limit_per_day,
-			d.created_at, d.updated_at, d.verified_at
+			d.created_at, d.updated_at, d.verified_at,
+			p.outbound_footer_text, p.outbound_footer_html, p.dkim_body_length
 		FROM domains d
+		LEFT JOIN domain_policies p ON p.domain_id = d.id
 		WHERE d.name = $1
 	`
 
@@ -110,8 +116,10 @@ func (r *DomainRepository) GetDomainsByOrganization(ctx context.Context, orgID s
 			d.catch_all_enabled, d.catch_all_address,
 			d.max_message_size, d.require_tls, d.allow_external_relay,
 			d.rate_limit_per_hour, d.rate_limit_per_day,
-			d.created_at, d.updated_at, d.verified_at
+			d.created_at, d.updated_at, d.verified_at,
+			p.outbound_footer_text, p.outbound_footer_html, p.dkim_body_length
 		FROM domains d
+		LEFT JOIN domain_policies p ON p.domain_id = d.id
 		WHERE d.organization_id = $1 AND d.status = 'verified'
 		ORDER BY d.is_primary DESC, d.name
 	`
@@ -392,6 +400,44 @@ func (r *DomainRepository) GetRoutingRules(ctx context.Context, domainID string)
 	return rules, rows.Err()
 }
 
+// GetCatchAllRules returns a domain's enabled catch-all rules in evaluation
+// order. Rules whose pattern doesn't compile are skipped.
+func (r *DomainRepository) GetCatchAllRules(ctx context.Context, domainID string) ([]*domain.CatchAllRule, error) {
+	query := `
+		SELECT id, domain_id, position, match_type, pattern, action, destination
+		FROM domain_catch_all_rules
+		WHERE domain_id = $1 AND enabled = true
+		ORDER BY position, created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, domainID)
+	if err != nil {
+		return nil, fmt.Errorf("query catch-all rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*domain.CatchAllRule
+	for rows.Next() {
+		var rule domain.CatchAllRule
+		err := rows.Scan(
+			&rule.ID, &rule.DomainID, &rule.Position, &rule.MatchType,
+			&rule.Pattern, &rule.Action, &rule.Destination,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan catch-all rule: %w", err)
+		}
+		if err := rule.Compile(); err != nil {
+			r.logger.Warn("Skipping invalid catch-all rule",
+				zap.String("rule_id", rule.ID),
+				zap.Error(err))
+			continue
+		}
+		rules = append(rules, &rule)
+	}
+
+	return rules, rows.Err()
+}
+
 // GetUserDomainPermission returns a user's permission for a domain
 func (r *DomainRepository) GetUserDomainPermission(ctx context.Context, userID, domainID string) (*domain.UserDomainPermission, error) {
 	query := `
@@ -457,15 +503,14 @@ func (r *DomainRepository) ListenForChanges(ctx context.Context, callback func(t
 		}
 
 		// Parse payload: "table:action:id"
-		var table, action, id string
-		_, err = fmt.Sscanf(notification.Payload, "%s:%s:%s", &table, &action, &id)
-		if err != nil {
+		parts := strings.SplitN(notification.Payload, ":", 3)
+		if len(parts) != 3 {
 			r.logger.Warn("Invalid notification payload",
 				zap.String("payload", notification.Payload))
 			continue
 		}
 
-		callback(table, action, id)
+		callback(parts[0], parts[1], parts[2])
 	}
 }
 
@@ -474,7 +519,8 @@ func (r *DomainRepository) ListenForChanges(ctx context.Context, callback func(t
 func scanDomain(rows pgx.Rows) (*domain.Domain, error) {
 	var d domain.Domain
 	d.Policies = &domain.DomainPolicies{}
-	var catchAllAddr *string
+	var catchAllAddr, footerText, footerHTML *string
+	var dkimBodyLength *bool
 	var verifiedAt *time.Time
 
 	err := rows.Scan(
@@ -484,6 +530,7 @@ func scanDomain(rows pgx.Rows) (*domain.Domain, error) {
 		&d.Policies.MaxMessageSize, &d.Policies.RequireTLS, &d.Policies.AllowExternalRelay,
 		&d.Policies.RateLimitPerHour, &d.Policies.RateLimitPerDay,
 		&d.CreatedAt, &d.UpdatedAt, &verifiedAt,
+		&footerText, &footerHTML, &dkimBodyLength,
 	)
 	if err != nil {
 		return nil, err
@@ -492,6 +539,15 @@ func scanDomain(rows pgx.Rows) (*domain.Domain, error) {
 	if catchAllAddr != nil {
 		d.Policies.CatchAllAddress = *catchAllAddr
 	}
+	if footerText != nil {
+		d.Policies.FooterText = *footerText
+	}
+	if footerHTML != nil {
+		d.Policies.FooterHTML = *footerHTML
+	}
+	if dkimBodyLength != nil {
+		d.Policies.DKIMBodyLength = *dkimBodyLength
+	}
 	if verifiedAt != nil {
 		d.VerifiedAt = *verifiedAt
 	}
@@ -502,7 +558,8 @@ func scanDomain(rows pgx.Rows) (*domain.Domain, error) {
 func scanDomainRow(row pgx.Row) (*domain.Domain, error) {
 	var d domain.Domain
 	d.Policies = &domain.DomainPolicies{}
-	var catchAllAddr *string
+	var catchAllAddr, footerText, footerHTML *string
+	var dkimBodyLength *bool
 	var verifiedAt *time.Time
 
 	err := row.Scan(
@@ -512,6 +569,7 @@ func scanDomainRow(row pgx.Row) (*domain.Domain, error) {
 		&d.Policies.MaxMessageSize, &d.Policies.RequireTLS, &d.Policies.AllowExternalRelay,
 		&d.Policies.RateLimitPerHour, &d.Policies.RateLimitPerDay,
 		&d.CreatedAt, &d.UpdatedAt, &verifiedAt,
+		&footerText, &footerHTML, &dkimBodyLength,
 	)
 	if err != nil {
 		return nil, err
@@ -520,6 +578,15 @@ func scanDomainRow(row pgx.Row) (*domain.Domain, error) {
 	if catchAllAddr != nil {
 		d.Policies.CatchAllAddress = *catchAllAddr
 	}
+	if footerText != nil {
+		d.Policies.FooterText = *footerText
+	}
+	if footerHTML != nil {
+		d.Policies.FooterHTML = *footerHTML
+	}
+	if dkimBodyLength != nil {
+		d.Policies.DKIMBodyLength = *dkimBodyLength
+	}
 	if verifiedAt != nil {
 		d.VerifiedAt = *verifiedAt
 	}
@@ -549,10 +616,7 @@ func scanDKIMKey(rows pgx.Rows) (*domain.DKIMKey, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse private key: %w", err)
 	}
-	k.PrivateKey = key
-
-	// Set public key from private key
-	k.PublicKey = &key.PublicKey
+	setDKIMPrivateKey(&k, key)
 
 	if expiresAt != nil {
 		k.ExpiresAt = expiresAt
@@ -586,10 +650,7 @@ func scanDKIMKeyRow(row pgx.Row) (*domain.DKIMKey, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse private key: %w", err)
 	}
-	k.PrivateKey = key
-
-	// Set public key from private key
-	k.PublicKey = &key.PublicKey
+	setDKIMPrivateKey(&k, key)
 
 	if expiresAt != nil {
 		k.ExpiresAt = expiresAt
@@ -713,7 +774,26 @@ func scanRoutingRule(rows pgx.Rows) (*domain.RoutingRule, error) {
 	return &r, nil
 }
 
-func parsePEMPrivateKey(pemStr string) (*rsa.PrivateKey, error) {
+// setDKIMPrivateKey stores a parsed private key on the DKIM key and derives
+// the key type from it
+func setDKIMPrivateKey(k *domain.DKIMKey, key crypto.Signer) {
+	switch pk := key.(type) {
+	case ed25519.PrivateKey:
+		k.KeyType = domain.DKIMKeyTypeEd25519
+		k.Ed25519PrivateKey = pk
+		if k.Algorithm == "" {
+			k.Algorithm = "ed25519-sha256"
+		}
+	case *rsa.PrivateKey:
+		k.KeyType = domain.DKIMKeyTypeRSA
+		k.PrivateKey = pk
+		// Set public key from private key
+		k.PublicKey = &pk.PublicKey
+	}
+}
+
+// parsePEMPrivateKey parses an RSA or Ed25519 DKIM private key
+func parsePEMPrivateKey(pemStr string) (crypto.Signer, error) {
 	// Try PEM decoding first
 	block, _ := pem.Decode([]byte(pemStr))
 	if block != nil {
@@ -725,11 +805,7 @@ func parsePEMPrivateKey(pemStr string) (*rsa.PrivateKey, error) {
 			if err != nil {
 				return nil, err
 			}
-			rsaKey, ok := key.(*rsa.PrivateKey)
-			if !ok {
-				return nil, errors.New("not an RSA private key")
-			}
-			return rsaKey, nil
+			return dkimSigner(key)
 		default:
 			return nil, fmt.Errorf("unsupported key type: %s", block.Type)
 		}
@@ -762,11 +838,19 @@ func parsePEMPrivateKey(pemStr string) (*rsa.PrivateKey, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
-	rsaKey, ok := pkcs8Key.(*rsa.PrivateKey)
-	if !ok {
-		return nil, errors.New("not an RSA private key")
+	return dkimSigner(pkcs8Key)
+}
+
+// dkimSigner narrows a PKCS8 key to the key types DKIM can sign with
+func dkimSigner(key interface{}) (crypto.Signer, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, errors.New("not an RSA or Ed25519 private key")
 	}
-	return rsaKey, nil
 }
 
 // decryptPrivateKey decrypts an AES-GCM encrypted private key
@@ -42,11 +42,13 @@ type ParsedMessage struct {
 }
 
 // DeliverToMailFolder parses a raw email message and inserts it into the
-// mail_messages table (used by the web app), filing it into the recipient's
-// Inbox folder. This bridges the SMTP inbound pipeline with the web UI.
+// mail_messages table (used by the web app), filing it into the given folder
+// of the recipient's mailbox, "INBOX" for the Inbox. This bridges the SMTP
+// inbound pipeline with the web UI.
 func (r *MessageRepository) DeliverToMailFolder(
 	ctx context.Context,
 	mailboxID string,
+	folder string,
 	msg *domain.Message,
 	rawData []byte,
 	storagePath string,
@@ -71,15 +73,23 @@ func (r *MessageRepository) DeliverToMailFolder(
 		}
 	}
 
-	// Look up the Inbox folder for this mailbox
+	// Look up the target folder for this mailbox
 	var folderID string
 	var uidNext int
-	err = r.db.QueryRow(ctx, `
+	if !strings.EqualFold(folder, "INBOX") {
+		err = r.db.QueryRow(ctx, `
+			SELECT id, uid_next FROM mail_folders
+			WHERE mailbox_id = $1 AND full_path = $2
+			LIMIT 1
+		`, mailboxID, folder).Scan(&folderID, &uidNext)
+		if err != nil {
+			return fmt.Errorf("folder %q not found: %w", folder, err)
+		}
+	} else if err = r.db.QueryRow(ctx, `
 		SELECT id, uid_next FROM mail_folders
 		WHERE mailbox_id = $1 AND special_use = '\Inbox'
 		LIMIT 1
-	`, mailboxID).Scan(&folderID, &uidNext)
-	if err != nil {
+	`, mailboxID).Scan(&folderID, &uidNext); err != nil {
 		// Inbox doesn't exist – try to create default folders
 		if createErr := r.ensureMailFolders(ctx, mailboxID); createErr != nil {
 			return fmt.Errorf("ensure mail folders: %w", createErr)
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// mdnRequestColumns are the columns scanned by scanMDNRequest
+const mdnRequestColumns = `id, mailbox_id, organization_id, domain_id, message_id, notify_to, return_path,
+	final_recipient, subject, original_headers, auto_allowed, status, created_at, displayed_at, sent_at`
+
+func scanMDNRequest(row pgx.Row) (*domain.MDNRequest, error) {
+	var req domain.MDNRequest
+	err := row.Scan(
+		&req.ID, &req.MailboxID, &req.OrganizationID, &req.DomainID, &req.MessageID, &req.NotifyTo, &req.ReturnPath,
+		&req.FinalRecipient, &req.Subject, &req.OriginalHeaders, &req.AutoAllowed, &req.Status,
+		&req.CreatedAt, &req.DisplayedAt, &req.SentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// UserExists reports whether a user exists
+func (r *MessageRepository) UserExists(ctx context.Context, userID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check user exists: %w", err)
+	}
+	return exists, nil
+}
+
+// GetReadReceiptPolicy returns a user's read receipt policy. Users who never
+// chose one are prompted.
+func (r *MessageRepository) GetReadReceiptPolicy(ctx context.Context, userID string) (domain.ReadReceiptPolicy, error) {
+	var policy domain.ReadReceiptPolicy
+	err := r.db.QueryRow(ctx, `SELECT policy FROM read_receipt_settings WHERE user_id = $1`, userID).Scan(&policy)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ReadReceiptPrompt, nil
+		}
+		return "", fmt.Errorf("query read receipt policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SaveReadReceiptPolicy sets a user's read receipt policy
+func (r *MessageRepository) SaveReadReceiptPolicy(ctx context.Context, userID string, policy domain.ReadReceiptPolicy) error {
+	query := `
+		INSERT INTO read_receipt_settings (user_id, policy)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET policy = EXCLUDED.policy, updated_at = NOW()
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, policy); err != nil {
+		return fmt.Errorf("save read receipt policy: %w", err)
+	}
+	return nil
+}
+
+// CreateMDNRequest records a read receipt request of a delivered message,
+// unless its user never sends read receipts
+func (r *MessageRepository) CreateMDNRequest(ctx context.Context, req *domain.MDNRequest) error {
+	query := `
+		INSERT INTO mdn_requests (
+			mailbox_id, organization_id, domain_id, message_id, notify_to, return_path,
+			final_recipient, subject, original_headers, auto_allowed, status
+		)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 'pending'
+		FROM mailboxes m
+		LEFT JOIN read_receipt_settings s ON s.user_id = m.user_id
+		WHERE m.id = $1 AND COALESCE(s.policy, 'prompt') <> 'never'
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		req.MailboxID, req.OrganizationID, req.DomainID, req.MessageID, req.NotifyTo, req.ReturnPath,
+		req.FinalRecipient, req.Subject, req.OriginalHeaders, req.AutoAllowed,
+	).Scan(&req.ID, &req.CreatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("create mdn request: %w", err)
+	}
+	return nil
+}
+
+// ListPendingMDNRequests returns the read receipt requests awaiting a user's
+// confirmation, newest first
+func (r *MessageRepository) ListPendingMDNRequests(ctx context.Context, userID string) ([]*domain.MDNRequest, error) {
+	query := `
+		SELECT r.id, r.mailbox_id, r.organization_id, r.domain_id, r.message_id, r.notify_to, r.return_path,
+			r.final_recipient, r.subject, r.original_headers, r.auto_allowed, r.status, r.created_at,
+			r.displayed_at, r.sent_at
+		FROM mdn_requests r
+		JOIN mailboxes m ON m.id = r.mailbox_id
+		WHERE m.user_id = $1 AND r.status = 'pending'
+		ORDER BY r.created_at DESC
+		LIMIT 500
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query pending mdn requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*domain.MDNRequest
+	for rows.Next() {
+		req, err := scanMDNRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan mdn request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// ClaimMDNRequest marks an unsent read receipt request sent and returns it,
+// or nil if there is no such request
+func (r *MessageRepository) ClaimMDNRequest(ctx context.Context, id string) (*domain.MDNRequest, error) {
+	query := `
+		UPDATE mdn_requests SET status = 'sent', sent_at = NOW()
+		WHERE id = $1 AND status <> 'sent'
+		RETURNING ` + mdnRequestColumns
+
+	req, err := scanMDNRequest(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("claim mdn request: %w", err)
+	}
+	return req, nil
+}
+
+// ClaimDisplayedMDNRequests marks up to limit read receipt requests of
+// displayed messages sent and returns them, oldest first
+func (r *MessageRepository) ClaimDisplayedMDNRequests(ctx context.Context, limit int) ([]*domain.MDNRequest, error) {
+	query := `
+		UPDATE mdn_requests SET status = 'sent', sent_at = NOW()
+		WHERE id IN (
+			SELECT id FROM mdn_requests
+			WHERE status = 'displayed'
+			ORDER BY displayed_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + mdnRequestColumns
+
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim displayed mdn requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*domain.MDNRequest
+	for rows.Next() {
+		req, err := scanMDNRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan mdn request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// ReleaseMDNRequest puts back a claimed read receipt request whose receipt
+// couldn't be queued
+func (r *MessageRepository) ReleaseMDNRequest(ctx context.Context, req *domain.MDNRequest) error {
+	_, err := r.db.Exec(ctx, `UPDATE mdn_requests SET status = $2, sent_at = NULL WHERE id = $1`, req.ID, req.Status)
+	if err != nil {
+		return fmt.Errorf("release mdn request: %w", err)
+	}
+	return nil
+}
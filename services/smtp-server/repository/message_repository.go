@@ -68,17 +68,24 @@ func (r *MessageRepository) CreateMessage(ctx context.Context, msg *domain.Messa
 		return fmt.Errorf("marshal headers: %w", err)
 	}
 
+	var dsnJSON []byte
+	if msg.DSN != nil {
+		if dsnJSON, err = json.Marshal(msg.DSN); err != nil {
+			return fmt.Errorf("marshal dsn params: %w", err)
+		}
+	}
+
 	query := `
 		INSERT INTO message_queue (
 			id, organization_id, domain_id, from_address, recipients,
 			subject, headers, body_size, raw_message_path, status,
 			priority, retry_count, max_retries, next_retry_at,
-			created_at, scheduled_at
+			created_at, scheduled_at, dsn_params
 		) VALUES (
 			$1, $2, $3, $4, $5,
 			$6, $7, $8, $9, $10,
 			$11, $12, $13, $14,
-			$15, $16
+			$15, $16, $17
 		)
 	`
 
@@ -86,7 +93,7 @@ func (r *MessageRepository) CreateMessage(ctx context.Context, msg *domain.Messa
 		msg.ID, msg.OrganizationID, msg.DomainID, msg.FromAddress, recipientsJSON,
 		msg.Subject, headersJSON, msg.BodySize, msg.RawMessagePath, msg.Status,
 		msg.Priority, msg.RetryCount, msg.MaxRetries, msg.NextRetryAt,
-		msg.CreatedAt, msg.ScheduledAt,
+		msg.CreatedAt, msg.ScheduledAt, dsnJSON,
 	)
 	if err != nil {
 		return fmt.Errorf("insert message: %w", err)
@@ -146,7 +153,7 @@ func (r *MessageRepository) GetPendingMessages(ctx context.Context, limit int) (
 			id, organization_id, domain_id, from_address, recipients,
 			subject, headers, body_size, raw_message_path, status,
 			priority, retry_count, max_retries, next_retry_at, last_error,
-			created_at, scheduled_at, delivered_at, failed_at
+			created_at, scheduled_at, delivered_at, failed_at, dsn_params
 		FROM message_queue
 		WHERE status = $1
 		  AND (next_retry_at IS NULL OR next_retry_at <= NOW())
@@ -181,7 +188,7 @@ func (r *MessageRepository) GetPendingMessagesByDomain(ctx context.Context, doma
 			id, organization_id, domain_id, from_address, recipients,
 			subject, headers, body_size, raw_message_path, status,
 			priority, retry_count, max_retries, next_retry_at, last_error,
-			created_at, scheduled_at, delivered_at, failed_at
+			created_at, scheduled_at, delivered_at, failed_at, dsn_params
 		FROM message_queue
 		WHERE domain_id = $1
 		  AND status = $2
@@ -217,7 +224,7 @@ func (r *MessageRepository) GetMessage(ctx context.Context, messageID string) (*
 			id, organization_id, domain_id, from_address, recipients,
 			subject, headers, body_size, raw_message_path, status,
 			priority, retry_count, max_retries, next_retry_at, last_error,
-			created_at, scheduled_at, delivered_at, failed_at
+			created_at, scheduled_at, delivered_at, failed_at, dsn_params
 		FROM message_queue
 		WHERE id = $1
 	`
@@ -234,6 +241,36 @@ func (r *MessageRepository) GetMessage(ctx context.Context, messageID string) (*
 	return msg, nil
 }
 
+// ClaimMessage marks a pending message that is due as processing and
+// returns it. It returns nil when the message doesn't exist, isn't pending
+// or isn't due yet, so two workers can never claim the same message.
+func (r *MessageRepository) ClaimMessage(ctx context.Context, messageID string) (*domain.Message, error) {
+	query := `
+		UPDATE message_queue
+		SET status = $2
+		WHERE id = $1
+		  AND status = $3
+		  AND (next_retry_at IS NULL OR next_retry_at <= NOW())
+		  AND (scheduled_at IS NULL OR scheduled_at <= NOW())
+		RETURNING
+			id, organization_id, domain_id, from_address, recipients,
+			subject, headers, body_size, raw_message_path, status,
+			priority, retry_count, max_retries, next_retry_at, last_error,
+			created_at, scheduled_at, delivered_at, failed_at, dsn_params
+	`
+
+	row := r.db.QueryRow(ctx, query, messageID, domain.StatusProcessing, domain.StatusPending)
+	msg, err := scanMessageRow(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("claim message: %w", err)
+	}
+
+	return msg, nil
+}
+
 // DeleteMessage removes a message from the queue
 func (r *MessageRepository) DeleteMessage(ctx context.Context, messageID string) error {
 	query := `DELETE FROM message_queue WHERE id = $1`
@@ -325,7 +362,7 @@ func (r *MessageRepository) GetStuckMessages(ctx context.Context, stuckDuration
 			id, organization_id, domain_id, from_address, recipients,
 			subject, headers, body_size, raw_message_path, status,
 			priority, retry_count, max_retries, next_retry_at, last_error,
-			created_at, scheduled_at, delivered_at, failed_at
+			created_at, scheduled_at, delivered_at, failed_at, dsn_params
 		FROM message_queue
 		WHERE status = 'processing'
 		  AND created_at < $1
@@ -370,7 +407,7 @@ func (r *MessageRepository) ResetStuckMessages(ctx context.Context, stuckDuratio
 
 func scanMessage(rows pgx.Rows) (*domain.Message, error) {
 	var msg domain.Message
-	var recipientsJSON, headersJSON []byte
+	var recipientsJSON, headersJSON, dsnJSON []byte
 	var lastError *string
 	var scheduledAt, deliveredAt, failedAt, nextRetryAt *time.Time
 
@@ -378,7 +415,7 @@ func scanMessage(rows pgx.Rows) (*domain.Message, error) {
 		&msg.ID, &msg.OrganizationID, &msg.DomainID, &msg.FromAddress, &recipientsJSON,
 		&msg.Subject, &headersJSON, &msg.BodySize, &msg.RawMessagePath, &msg.Status,
 		&msg.Priority, &msg.RetryCount, &msg.MaxRetries, &nextRetryAt, &lastError,
-		&msg.CreatedAt, &scheduledAt, &deliveredAt, &failedAt,
+		&msg.CreatedAt, &scheduledAt, &deliveredAt, &failedAt, &dsnJSON,
 	)
 	if err != nil {
 		return nil, err
@@ -390,6 +427,11 @@ func scanMessage(rows pgx.Rows) (*domain.Message, error) {
 	if err := json.Unmarshal(headersJSON, &msg.Headers); err != nil {
 		return nil, fmt.Errorf("unmarshal headers: %w", err)
 	}
+	if len(dsnJSON) > 0 {
+		if err := json.Unmarshal(dsnJSON, &msg.DSN); err != nil {
+			return nil, fmt.Errorf("unmarshal dsn params: %w", err)
+		}
+	}
 
 	if lastError != nil {
 		msg.LastError = *lastError
@@ -412,7 +454,7 @@ func scanMessage(rows pgx.Rows) (*domain.Message, error) {
 
 func scanMessageRow(row pgx.Row) (*domain.Message, error) {
 	var msg domain.Message
-	var recipientsJSON, headersJSON []byte
+	var recipientsJSON, headersJSON, dsnJSON []byte
 	var lastError *string
 	var scheduledAt, deliveredAt, failedAt, nextRetryAt *time.Time
 
@@ -420,7 +462,7 @@ func scanMessageRow(row pgx.Row) (*domain.Message, error) {
 		&msg.ID, &msg.OrganizationID, &msg.DomainID, &msg.FromAddress, &recipientsJSON,
 		&msg.Subject, &headersJSON, &msg.BodySize, &msg.RawMessagePath, &msg.Status,
 		&msg.Priority, &msg.RetryCount, &msg.MaxRetries, &nextRetryAt, &lastError,
-		&msg.CreatedAt, &scheduledAt, &deliveredAt, &failedAt,
+		&msg.CreatedAt, &scheduledAt, &deliveredAt, &failedAt, &dsnJSON,
 	)
 	if err != nil {
 		return nil, err
@@ -432,6 +474,11 @@ func scanMessageRow(row pgx.Row) (*domain.Message, error) {
 	if err := json.Unmarshal(headersJSON, &msg.Headers); err != nil {
 		return nil, fmt.Errorf("unmarshal headers: %w", err)
 	}
+	if len(dsnJSON) > 0 {
+		if err := json.Unmarshal(dsnJSON, &msg.DSN); err != nil {
+			return nil, fmt.Errorf("unmarshal dsn params: %w", err)
+		}
+	}
 
 	if lastError != nil {
 		msg.LastError = *lastError
@@ -650,19 +697,19 @@ func (r *MessageRepository) GetMailboxesNearQuota(ctx context.Context, threshold
 // RecordMailboxMessage records a message in the mailbox messages table.
 // This is a best-effort operation — if the table doesn't exist yet,
 // the message was still delivered via the SMTP queue.
-func (r *MessageRepository) RecordMailboxMessage(ctx context.Context, mailboxID string, msg *domain.Message, storagePath string, size int64) error {
+func (r *MessageRepository) RecordMailboxMessage(ctx context.Context, mailboxID, folder string, msg *domain.Message, storagePath string, size int64) error {
 	query := `
 		INSERT INTO mailbox_messages (
 			id, mailbox_id, message_id, folder, storage_path,
 			from_address, subject, size, received_at, is_read, is_flagged, created_at
 		) VALUES (
-			gen_random_uuid(), $1, $2, 'INBOX', $3,
-			$4, $5, $6, NOW(), false, false, NOW()
+			gen_random_uuid(), $1, $2, $3, $4,
+			$5, $6, $7, NOW(), false, false, NOW()
 		)
 	`
 
 	_, err := r.db.Exec(ctx, query,
-		mailboxID, msg.ID, storagePath,
+		mailboxID, msg.ID, folder, storagePath,
 		msg.FromAddress, msg.Subject, size,
 	)
 	if err != nil {
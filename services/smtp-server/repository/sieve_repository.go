@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// GetSieveScript returns a mailbox's Sieve script, or nil if it has none
+func (r *MessageRepository) GetSieveScript(ctx context.Context, mailboxID string) (*domain.SieveScript, error) {
+	query := `
+		SELECT id, mailbox_id, name, script, is_active, created_at, updated_at
+		FROM sieve_scripts
+		WHERE mailbox_id = $1
+	`
+
+	var s domain.SieveScript
+	err := r.db.QueryRow(ctx, query, mailboxID).Scan(
+		&s.ID, &s.MailboxID, &s.Name, &s.Script, &s.IsActive, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query sieve script: %w", err)
+	}
+
+	return &s, nil
+}
+
+// SaveSieveScript creates or replaces a mailbox's Sieve script
+func (r *MessageRepository) SaveSieveScript(ctx context.Context, s *domain.SieveScript) error {
+	query := `
+		INSERT INTO sieve_scripts (mailbox_id, name, script, is_active)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (mailbox_id) DO UPDATE
+		SET name = EXCLUDED.name, script = EXCLUDED.script,
+		    is_active = EXCLUDED.is_active, updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRow(ctx, query, s.MailboxID, s.Name, s.Script, s.IsActive).Scan(
+		&s.ID, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("save sieve script: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteSieveScript removes a mailbox's Sieve script and reports whether
+// there was one
+func (r *MessageRepository) DeleteSieveScript(ctx context.Context, mailboxID string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM sieve_scripts WHERE mailbox_id = $1`, mailboxID)
+	if err != nil {
+		return false, fmt.Errorf("delete sieve script: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListMailFolderPaths returns the full paths of a mailbox's folders
+func (r *MessageRepository) ListMailFolderPaths(ctx context.Context, mailboxID string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT full_path FROM mail_folders
+		WHERE mailbox_id = $1
+		ORDER BY sort_order, full_path
+	`, mailboxID)
+	if err != nil {
+		return nil, fmt.Errorf("query mail folders: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("scan mail folder: %w", err)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, rows.Err()
+}
+
+// MailboxExists reports whether a mailbox exists
+func (r *MessageRepository) MailboxExists(ctx context.Context, mailboxID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM mailboxes WHERE id = $1)`, mailboxID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("query mailbox: %w", err)
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// GetVacationResponder returns a mailbox's vacation responder, or nil if it
+// has none
+func (r *MessageRepository) GetVacationResponder(ctx context.Context, mailboxID string) (*domain.VacationResponder, error) {
+	query := `
+		SELECT id, mailbox_id, subject, body, start_at, end_at,
+		       reply_interval_days, addresses, is_active, created_at, updated_at
+		FROM vacation_responders
+		WHERE mailbox_id = $1
+	`
+
+	var v domain.VacationResponder
+	var addressesJSON []byte
+	err := r.db.QueryRow(ctx, query, mailboxID).Scan(
+		&v.ID, &v.MailboxID, &v.Subject, &v.Body, &v.StartAt, &v.EndAt,
+		&v.ReplyIntervalDays, &addressesJSON, &v.IsActive, &v.CreatedAt, &v.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query vacation responder: %w", err)
+	}
+
+	if err := json.Unmarshal(addressesJSON, &v.Addresses); err != nil {
+		return nil, fmt.Errorf("unmarshal vacation addresses: %w", err)
+	}
+
+	return &v, nil
+}
+
+// SaveVacationResponder creates or replaces a mailbox's vacation responder
+func (r *MessageRepository) SaveVacationResponder(ctx context.Context, v *domain.VacationResponder) error {
+	addresses := v.Addresses
+	if addresses == nil {
+		addresses = []string{}
+	}
+	addressesJSON, err := json.Marshal(addresses)
+	if err != nil {
+		return fmt.Errorf("marshal vacation addresses: %w", err)
+	}
+
+	query := `
+		INSERT INTO vacation_responders (
+			mailbox_id, subject, body, start_at, end_at,
+			reply_interval_days, addresses, is_active
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (mailbox_id) DO UPDATE
+		SET subject = EXCLUDED.subject, body = EXCLUDED.body,
+		    start_at = EXCLUDED.start_at, end_at = EXCLUDED.end_at,
+		    reply_interval_days = EXCLUDED.reply_interval_days,
+		    addresses = EXCLUDED.addresses, is_active = EXCLUDED.is_active,
+		    updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`
+
+	err = r.db.QueryRow(ctx, query,
+		v.MailboxID, v.Subject, v.Body, v.StartAt, v.EndAt,
+		v.ReplyIntervalDays, addressesJSON, v.IsActive,
+	).Scan(&v.ID, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("save vacation responder: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteVacationResponder removes a mailbox's vacation responder and
+// reports whether there was one
+func (r *MessageRepository) DeleteVacationResponder(ctx context.Context, mailboxID string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM vacation_responders WHERE mailbox_id = $1`, mailboxID)
+	if err != nil {
+		return false, fmt.Errorf("delete vacation responder: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,293 @@
+package sieve
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// Store persists Sieve scripts. It is implemented by
+// repository.MessageRepository.
+type Store interface {
+	MailboxExists(ctx context.Context, mailboxID string) (bool, error)
+	GetSieveScript(ctx context.Context, mailboxID string) (*domain.SieveScript, error)
+	SaveSieveScript(ctx context.Context, s *domain.SieveScript) error
+	DeleteSieveScript(ctx context.Context, mailboxID string) (bool, error)
+	ListMailFolderPaths(ctx context.Context, mailboxID string) ([]string, error)
+}
+
+// Handler serves the script management API. It follows ManageSieve
+// (RFC 5804) with one script per mailbox:
+//
+//	GET    /api/v1/sieve/capabilities          CAPABILITY
+//	GET    /api/v1/mailboxes/{id}/sieve        GETSCRIPT
+//	PUT    /api/v1/mailboxes/{id}/sieve        PUTSCRIPT
+//	POST   /api/v1/mailboxes/{id}/sieve/check  CHECKSCRIPT
+//	DELETE /api/v1/mailboxes/{id}/sieve        DELETESCRIPT
+//
+// A script is only stored if it compiles and every folder it files into
+// exists in the mailbox.
+type Handler struct {
+	store  Store
+	config *config.SieveConfig
+	logger *zap.Logger
+}
+
+// NewHandler creates a new script management handler
+func NewHandler(store Store, cfg *config.SieveConfig, logger *zap.Logger) *Handler {
+	return &Handler{
+		store:  store,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Register adds the API routes to mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/sieve/capabilities", h.authorize(h.capabilities))
+	mux.HandleFunc("GET /api/v1/mailboxes/{id}/sieve", h.authorize(h.getScript))
+	mux.HandleFunc("PUT /api/v1/mailboxes/{id}/sieve", h.authorize(h.putScript))
+	mux.HandleFunc("POST /api/v1/mailboxes/{id}/sieve/check", h.authorize(h.checkScript))
+	mux.HandleFunc("DELETE /api/v1/mailboxes/{id}/sieve", h.authorize(h.deleteScript))
+}
+
+// scriptRequest is the body of PUTSCRIPT and CHECKSCRIPT
+type scriptRequest struct {
+	Name   string `json:"name"`
+	Script string `json:"script"`
+	Active *bool  `json:"active"`
+}
+
+// validationResponse reports why a script was rejected
+type validationResponse struct {
+	Error string `json:"error"`
+	Line  int    `json:"line,omitempty"`
+}
+
+// authorize only lets requests carrying the internal secret through
+func (h *Handler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get("X-Internal-Secret")
+		if h.config.InternalSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.config.InternalSecret)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) capabilities(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"implementation":  "oonrumail",
+		"sieve":           Extensions(),
+		"max_script_size": h.config.MaxScriptSize,
+		"enabled":         h.config.Enabled,
+	})
+}
+
+func (h *Handler) getScript(w http.ResponseWriter, r *http.Request) {
+	mailboxID, ok := h.mailboxID(w, r)
+	if !ok {
+		return
+	}
+
+	script, err := h.store.GetSieveScript(r.Context(), mailboxID)
+	if err != nil {
+		h.internalError(w, "get sieve script", err)
+		return
+	}
+	if script == nil {
+		writeError(w, http.StatusNotFound, "mailbox has no sieve script")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, script)
+}
+
+func (h *Handler) putScript(w http.ResponseWriter, r *http.Request) {
+	mailboxID, ok := h.mailboxID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := h.readScript(w, r)
+	if !ok {
+		return
+	}
+	if !h.validate(w, r, mailboxID, req.Script) {
+		return
+	}
+
+	script := &domain.SieveScript{
+		MailboxID: mailboxID,
+		Name:      strings.TrimSpace(req.Name),
+		Script:    req.Script,
+		IsActive:  req.Active == nil || *req.Active,
+	}
+	if script.Name == "" {
+		script.Name = "default"
+	}
+
+	if err := h.store.SaveSieveScript(r.Context(), script); err != nil {
+		h.internalError(w, "save sieve script", err)
+		return
+	}
+
+	h.logger.Info("Sieve script saved",
+		zap.String("mailbox_id", mailboxID),
+		zap.Bool("active", script.IsActive),
+		zap.Int("size", len(script.Script)))
+
+	writeJSON(w, http.StatusOK, script)
+}
+
+func (h *Handler) checkScript(w http.ResponseWriter, r *http.Request) {
+	mailboxID, ok := h.mailboxID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := h.readScript(w, r)
+	if !ok {
+		return
+	}
+	if !h.validate(w, r, mailboxID, req.Script) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"valid": true})
+}
+
+func (h *Handler) deleteScript(w http.ResponseWriter, r *http.Request) {
+	mailboxID, ok := h.mailboxID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.store.DeleteSieveScript(r.Context(), mailboxID)
+	if err != nil {
+		h.internalError(w, "delete sieve script", err)
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "mailbox has no sieve script")
+		return
+	}
+
+	h.logger.Info("Sieve script deleted", zap.String("mailbox_id", mailboxID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mailboxID returns the mailbox of the request if it exists
+func (h *Handler) mailboxID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid mailbox id")
+		return "", false
+	}
+
+	exists, err := h.store.MailboxExists(r.Context(), id.String())
+	if err != nil {
+		h.internalError(w, "check mailbox", err)
+		return "", false
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "mailbox not found")
+		return "", false
+	}
+	return id.String(), true
+}
+
+// readScript decodes a script request no larger than the configured limit
+func (h *Handler) readScript(w http.ResponseWriter, r *http.Request) (*scriptRequest, bool) {
+	limit := int64(h.config.MaxScriptSize)
+	body, err := io.ReadAll(io.LimitReader(r.Body, 2*limit+4096))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return nil, false
+	}
+
+	var req scriptRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return nil, false
+	}
+	if int64(len(req.Script)) > limit {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("script exceeds %d bytes", limit))
+		return nil, false
+	}
+	return &req, true
+}
+
+// validate compiles a script and checks that every folder it files into
+// exists in the mailbox, writing the error response if not
+func (h *Handler) validate(w http.ResponseWriter, r *http.Request, mailboxID, src string) bool {
+	script, err := Compile(src)
+	if err != nil {
+		resp := validationResponse{Error: err.Error()}
+		var sieveErr *Error
+		if errors.As(err, &sieveErr) {
+			resp.Error = sieveErr.Message
+			resp.Line = sieveErr.Line
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, resp)
+		return false
+	}
+
+	if len(script.Folders()) == 0 {
+		return true
+	}
+
+	existing, err := h.store.ListMailFolderPaths(r.Context(), mailboxID)
+	if err != nil {
+		h.internalError(w, "list mail folders", err)
+		return false
+	}
+
+	var missing []string
+	for _, folder := range script.Folders() {
+		if !strings.EqualFold(folder, InboxFolder) && !containsString(existing, folder) {
+			missing = append(missing, folder)
+		}
+	}
+	if len(missing) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, validationResponse{
+			Error: fmt.Sprintf("folder does not exist: %s", strings.Join(missing, ", ")),
+		})
+		return false
+	}
+	return true
+}
+
+func (h *Handler) internalError(w http.ResponseWriter, op string, err error) {
+	h.logger.Error("Sieve API request failed", zap.String("op", op), zap.Error(err))
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,265 @@
+package sieve
+
+import (
+	"strings"
+)
+
+// compiler checks parsed commands and turns them into executable ones
+type compiler struct {
+	extensions map[string]bool
+	folders    []string
+}
+
+// block compiles a sequence of commands. require is only allowed at the
+// top of the script.
+func (c *compiler) block(nodes []*node, top bool) ([]command, error) {
+	var commands []command
+	var chain *conditional // The if command elsif and else attach to
+	requireAllowed := top
+
+	for _, n := range nodes {
+		prev := chain
+		chain = nil
+
+		if n.name != "require" {
+			requireAllowed = false
+		}
+
+		switch n.name {
+		case "require":
+			if !requireAllowed {
+				return nil, errorf(n.line, "require must come before any other command")
+			}
+			if err := c.require(n); err != nil {
+				return nil, err
+			}
+
+		case "if":
+			t, block, err := c.branch(n)
+			if err != nil {
+				return nil, err
+			}
+			chain = &conditional{branches: []branch{{test: t, block: block}}}
+			commands = append(commands, chain)
+
+		case "elsif":
+			if prev == nil {
+				return nil, errorf(n.line, "elsif without if")
+			}
+			t, block, err := c.branch(n)
+			if err != nil {
+				return nil, err
+			}
+			prev.branches = append(prev.branches, branch{test: t, block: block})
+			chain = prev
+
+		case "else":
+			if prev == nil {
+				return nil, errorf(n.line, "else without if")
+			}
+			if len(n.args) > 0 || len(n.tests) > 0 || !n.hasBlock {
+				return nil, errorf(n.line, "else takes a block and nothing else")
+			}
+			block, err := c.block(n.block, false)
+			if err != nil {
+				return nil, err
+			}
+			prev.otherwise = block
+			prev.hasElse = true
+
+		case "keep", "discard", "stop":
+			if len(n.args) > 0 || len(n.tests) > 0 || n.hasBlock {
+				return nil, errorf(n.line, "%s takes no arguments", n.name)
+			}
+			switch n.name {
+			case "keep":
+				commands = append(commands, keepAction{})
+			case "discard":
+				commands = append(commands, discardAction{})
+			case "stop":
+				commands = append(commands, stopAction{})
+			}
+
+		case "fileinto":
+			if !c.extensions["fileinto"] {
+				return nil, errorf(n.line, `fileinto requires require "fileinto"`)
+			}
+			if len(n.args) != 1 || n.args[0].kind != argStrings || len(n.args[0].strs) != 1 || len(n.tests) > 0 || n.hasBlock {
+				return nil, errorf(n.line, "fileinto takes a single folder name")
+			}
+			folder := strings.TrimSpace(n.args[0].strs[0])
+			if folder == "" {
+				return nil, errorf(n.line, "fileinto folder name is empty")
+			}
+			if !containsFolder(c.folders, folder) {
+				c.folders = append(c.folders, folder)
+			}
+			commands = append(commands, fileintoAction{folder: folder})
+
+		default:
+			return nil, errorf(n.line, "unknown command %q", n.name)
+		}
+	}
+	return commands, nil
+}
+
+func (c *compiler) require(n *node) error {
+	if len(n.args) != 1 || n.args[0].kind != argStrings || len(n.tests) > 0 || n.hasBlock {
+		return errorf(n.line, "require takes a string list of capabilities")
+	}
+	for _, ext := range n.args[0].strs {
+		ext = strings.ToLower(ext)
+		if !supportedExtensions[ext] {
+			return errorf(n.line, "unsupported extension %q", ext)
+		}
+		c.extensions[ext] = true
+	}
+	return nil
+}
+
+// branch compiles the test and block of an if or elsif
+func (c *compiler) branch(n *node) (test, []command, error) {
+	if len(n.args) > 0 || len(n.tests) != 1 || !n.hasBlock {
+		return nil, nil, errorf(n.line, "%s takes a test and a block", n.name)
+	}
+	t, err := c.test(n.tests[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := c.block(n.block, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, block, nil
+}
+
+func (c *compiler) test(n *node) (test, error) {
+	switch n.name {
+	case "true", "false":
+		if len(n.args) > 0 || len(n.tests) > 0 {
+			return nil, errorf(n.line, "%s takes no arguments", n.name)
+		}
+		return constTest(n.name == "true"), nil
+
+	case "not":
+		if len(n.args) > 0 || len(n.tests) != 1 {
+			return nil, errorf(n.line, "not takes a single test")
+		}
+		t, err := c.test(n.tests[0])
+		if err != nil {
+			return nil, err
+		}
+		return notTest{test: t}, nil
+
+	case "allof", "anyof":
+		if len(n.args) > 0 || len(n.tests) == 0 {
+			return nil, errorf(n.line, "%s takes a list of tests", n.name)
+		}
+		tests := make([]test, 0, len(n.tests))
+		for _, sub := range n.tests {
+			t, err := c.test(sub)
+			if err != nil {
+				return nil, err
+			}
+			tests = append(tests, t)
+		}
+		if n.name == "allof" {
+			return allofTest(tests), nil
+		}
+		return anyofTest(tests), nil
+
+	case "exists":
+		if len(n.args) != 1 || n.args[0].kind != argStrings || len(n.tests) > 0 {
+			return nil, errorf(n.line, "exists takes a list of header names")
+		}
+		return existsTest{headers: n.args[0].strs}, nil
+
+	case "size":
+		if len(n.args) != 2 || n.args[0].kind != argTag || n.args[1].kind != argNumber || len(n.tests) > 0 {
+			return nil, errorf(n.line, "size takes :over or :under and a number")
+		}
+		switch n.args[0].tag {
+		case ":over":
+			return sizeTest{over: true, limit: n.args[1].num}, nil
+		case ":under":
+			return sizeTest{limit: n.args[1].num}, nil
+		}
+		return nil, errorf(n.line, "size takes :over or :under, not %s", n.args[0].tag)
+
+	case "header", "address":
+		return c.headerTest(n)
+	}
+	return nil, errorf(n.line, "unknown test %q", n.name)
+}
+
+// headerTest compiles a header or address test: optional tags, then the
+// header names and the keys
+func (c *compiler) headerTest(n *node) (test, error) {
+	if len(n.tests) > 0 {
+		return nil, errorf(n.line, "%s takes no tests", n.name)
+	}
+
+	matchType := defaultMatchType
+	comparator := defaultComparator
+	part := defaultAddressPart
+	var seenMatch, seenComparator, seenPart bool
+	var positional [][]string
+
+	for i := 0; i < len(n.args); i++ {
+		a := n.args[i]
+		switch {
+		case a.kind == argStrings:
+			positional = append(positional, a.strs)
+		case a.kind == argNumber:
+			return nil, errorf(a.line, "%s does not take a number", n.name)
+		case a.tag == matchIs || a.tag == matchContains || a.tag == matchMatches:
+			if seenMatch {
+				return nil, errorf(a.line, "more than one match type")
+			}
+			seenMatch = true
+			matchType = a.tag
+		case a.tag == ":comparator":
+			if seenComparator {
+				return nil, errorf(a.line, "more than one comparator")
+			}
+			seenComparator = true
+			if i+1 >= len(n.args) || n.args[i+1].kind != argStrings || len(n.args[i+1].strs) != 1 {
+				return nil, errorf(a.line, ":comparator takes a comparator name")
+			}
+			i++
+			comparator = strings.ToLower(n.args[i].strs[0])
+			if comparator != comparatorOctet && comparator != comparatorCasemap {
+				return nil, errorf(a.line, "unsupported comparator %q", comparator)
+			}
+		case n.name == "address" && (a.tag == partAll || a.tag == partLocalpart || a.tag == partDomain):
+			if seenPart {
+				return nil, errorf(a.line, "more than one address part")
+			}
+			seenPart = true
+			part = a.tag
+		default:
+			return nil, errorf(a.line, "unknown tag %s for %s", a.tag, n.name)
+		}
+	}
+
+	if len(positional) != 2 {
+		return nil, errorf(n.line, "%s takes a list of header names and a list of keys", n.name)
+	}
+
+	keys := newMatcher(matchType, comparator, positional[1])
+	if n.name == "address" {
+		return addressTest{headers: positional[0], part: part, keys: keys}, nil
+	}
+	return headerTest{headers: positional[0], keys: keys}, nil
+}
+
+// containsFolder reports whether folders holds folder, matching the inbox
+// case-insensitively as IMAP does
+func containsFolder(folders []string, folder string) bool {
+	for _, f := range folders {
+		if f == folder || strings.EqualFold(f, InboxFolder) && strings.EqualFold(folder, InboxFolder) {
+			return true
+		}
+	}
+	return false
+}
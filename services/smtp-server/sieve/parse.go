@@ -0,0 +1,477 @@
+package sieve
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxNesting bounds how deeply blocks and tests may nest
+const maxNesting = 32
+
+// Error is a syntax or semantic error in a script
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+func errorf(line int, format string, args ...interface{}) error {
+	return &Error{Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdentifier
+	tokTag
+	tokString
+	tokNumber
+	tokPunct // One of [ ] , ( ) { } ;
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  int64
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of script"
+	case tokString:
+		return "string"
+	case tokNumber:
+		return "number"
+	}
+	return strconv.Quote(t.text)
+}
+
+// lexer splits a script into tokens (RFC 5228 section 8.1)
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: src, line: 1}
+	var tokens []token
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		if t.kind == tokEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, line: l.line}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("[](){},;", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, text: string(c), line: l.line}, nil
+	case c == '"':
+		return l.quoted()
+	case c == ':':
+		l.pos++
+		name := l.identifier()
+		if name == "" {
+			return token{}, errorf(l.line, "expected tag name after ':'")
+		}
+		return token{kind: tokTag, text: ":" + strings.ToLower(name), line: l.line}, nil
+	case isDigit(c):
+		return l.number()
+	case isIdentStart(c):
+		line := l.line
+		name := l.identifier()
+		if strings.EqualFold(name, "text") && l.pos < len(l.src) && l.src[l.pos] == ':' {
+			l.pos++
+			return l.multiline(line)
+		}
+		return token{kind: tokIdentifier, text: strings.ToLower(name), line: line}, nil
+	}
+	return token{}, errorf(l.line, "unexpected character %q", c)
+}
+
+// skipSpace skips whitespace and comments
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return errorf(l.line, "unterminated comment")
+			}
+			comment := l.src[l.pos : l.pos+2+end+2]
+			l.line += strings.Count(comment, "\n")
+			l.pos += len(comment)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) identifier() string {
+	start := l.pos
+	for l.pos < len(l.src) && (isIdentStart(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+		l.pos++
+	}
+	return l.src[start:l.pos]
+}
+
+// number reads a number with an optional K, M or G quantifier
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	n, err := strconv.ParseInt(l.src[start:l.pos], 10, 64)
+	if err != nil {
+		return token{}, errorf(l.line, "number out of range")
+	}
+
+	if l.pos < len(l.src) {
+		shift := 0
+		switch l.src[l.pos] {
+		case 'K', 'k':
+			shift = 10
+		case 'M', 'm':
+			shift = 20
+		case 'G', 'g':
+			shift = 30
+		}
+		if shift > 0 {
+			l.pos++
+			if n > (1<<63-1)>>shift {
+				return token{}, errorf(l.line, "number out of range")
+			}
+			n <<= shift
+		}
+	}
+	return token{kind: tokNumber, text: l.src[start:l.pos], num: n, line: l.line}, nil
+}
+
+// quoted reads a quoted string, in which a backslash escapes the next
+// character
+func (l *lexer) quoted() (token, error) {
+	line := l.line
+	l.pos++ // Opening quote
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{kind: tokString, text: b.String(), line: line}, nil
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.src) {
+				return token{}, errorf(line, "unterminated string")
+			}
+			c = l.src[l.pos]
+		}
+		if c == '\n' {
+			l.line++
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return token{}, errorf(line, "unterminated string")
+}
+
+// multiline reads a text: string, which runs to a line holding a single
+// dot. Lines starting with a dot have it doubled.
+func (l *lexer) multiline(line int) (token, error) {
+	// The rest of the text: line may only hold whitespace or a comment
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t') {
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '#' {
+		for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos >= len(l.src) || l.src[l.pos] != '\n' {
+		return token{}, errorf(l.line, "expected end of line after text:")
+	}
+	l.pos++
+	l.line++
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		if end < 0 {
+			break
+		}
+		text := strings.TrimSuffix(l.src[l.pos:l.pos+end], "\r")
+		l.pos += end + 1
+		l.line++
+		if text == "." {
+			return token{kind: tokString, text: b.String(), line: line}, nil
+		}
+		b.WriteString(strings.TrimPrefix(text, "."))
+		b.WriteString("\r\n")
+	}
+	return token{}, errorf(line, "unterminated text: string")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+type argKind int
+
+const (
+	argTag argKind = iota
+	argNumber
+	argStrings
+)
+
+// argument is a tag, number or string list passed to a command or test
+type argument struct {
+	kind argKind
+	line int
+	tag  string
+	num  int64
+	strs []string
+}
+
+// node is a command or test before it is checked. Commands and tests share
+// one shape: a name, arguments, then a test or test list, and for
+// commands a block.
+type node struct {
+	name     string
+	line     int
+	args     []argument
+	tests    []*node
+	block    []*node
+	hasBlock bool
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func parse(src string) ([]*node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.commands(false)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return errorf(p.peek().line, "expected %q, found %s", s, p.peek())
+	}
+	p.advance()
+	return nil
+}
+
+func (p *parser) enter(line int) error {
+	p.depth++
+	if p.depth > maxNesting {
+		return errorf(line, "script is nested too deeply")
+	}
+	return nil
+}
+
+// commands reads commands up to the end of the script, or of the block
+func (p *parser) commands(inBlock bool) ([]*node, error) {
+	var commands []*node
+	for {
+		t := p.peek()
+		if t.kind == tokEOF {
+			if inBlock {
+				return nil, errorf(t.line, "missing '}'")
+			}
+			return commands, nil
+		}
+		if inBlock && p.isPunct("}") {
+			return commands, nil
+		}
+		if t.kind != tokIdentifier {
+			return nil, errorf(t.line, "expected command, found %s", t)
+		}
+
+		cmd, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, cmd)
+	}
+}
+
+func (p *parser) command() (*node, error) {
+	n, err := p.element()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isPunct(";"):
+		p.advance()
+	case p.isPunct("{"):
+		p.advance()
+		if err := p.enter(n.line); err != nil {
+			return nil, err
+		}
+		n.block, err = p.commands(true)
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		if err := p.expectPunct("}"); err != nil {
+			return nil, err
+		}
+		n.hasBlock = true
+	default:
+		return nil, errorf(p.peek().line, "expected ';' or '{' after %s, found %s", n.name, p.peek())
+	}
+	return n, nil
+}
+
+// element reads a name, its arguments and its test or test list
+func (p *parser) element() (*node, error) {
+	t := p.advance()
+	n := &node{name: t.text, line: t.line}
+
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokTag:
+			p.advance()
+			n.args = append(n.args, argument{kind: argTag, line: t.line, tag: t.text})
+			continue
+		case t.kind == tokNumber:
+			p.advance()
+			n.args = append(n.args, argument{kind: argNumber, line: t.line, num: t.num})
+			continue
+		case t.kind == tokString:
+			p.advance()
+			n.args = append(n.args, argument{kind: argStrings, line: t.line, strs: []string{t.text}})
+			continue
+		case p.isPunct("["):
+			strs, err := p.stringList()
+			if err != nil {
+				return nil, err
+			}
+			n.args = append(n.args, argument{kind: argStrings, line: t.line, strs: strs})
+			continue
+		}
+		break
+	}
+
+	switch {
+	case p.peek().kind == tokIdentifier:
+		test, err := p.test()
+		if err != nil {
+			return nil, err
+		}
+		n.tests = []*node{test}
+	case p.isPunct("("):
+		p.advance()
+		for {
+			if p.peek().kind != tokIdentifier {
+				return nil, errorf(p.peek().line, "expected test, found %s", p.peek())
+			}
+			test, err := p.test()
+			if err != nil {
+				return nil, err
+			}
+			n.tests = append(n.tests, test)
+			if p.isPunct(")") {
+				p.advance()
+				break
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) test() (*node, error) {
+	if err := p.enter(p.peek().line); err != nil {
+		return nil, err
+	}
+	n, err := p.element()
+	p.depth--
+	return n, err
+}
+
+func (p *parser) stringList() ([]string, error) {
+	p.advance() // [
+	var strs []string
+	for {
+		t := p.advance()
+		if t.kind != tokString {
+			return nil, errorf(t.line, "expected string in list, found %s", t)
+		}
+		strs = append(strs, t.text)
+		if p.isPunct("]") {
+			p.advance()
+			return strs, nil
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+}
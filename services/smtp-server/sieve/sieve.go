@@ -0,0 +1,391 @@
+// Package sieve implements the subset of the Sieve mail filtering language
+// (RFC 5228) used for server-side filtering of local delivery.
+//
+// Scripts may use the keep, discard, stop and fileinto actions, if/elsif/else
+// and the header, address, exists, size, allof, anyof, not, true and false
+// tests, with the :is, :contains and :matches match types and the
+// i;ascii-casemap and i;octet comparators. A script is compiled once and can
+// then be executed against any number of messages.
+package sieve
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// InboxFolder is the folder keep files messages into
+const InboxFolder = "INBOX"
+
+// supportedExtensions are the capabilities a script may require
+var supportedExtensions = map[string]bool{
+	"fileinto":                   true,
+	"comparator-i;octet":         true,
+	"comparator-i;ascii-casemap": true,
+}
+
+// Extensions returns the capabilities scripts may require
+func Extensions() []string {
+	return []string{"fileinto", "comparator-i;octet", "comparator-i;ascii-casemap"}
+}
+
+// Message is the part of a message a script can test
+type Message struct {
+	Header mail.Header
+	Size   int64
+}
+
+// NewMessage reads the header of a raw RFC 5322 message
+func NewMessage(raw []byte) (*Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Header: msg.Header, Size: int64(len(raw))}, nil
+}
+
+// values returns the decoded values of a header field
+func (m *Message) values(name string) []string {
+	raw := m.Header[textproto.CanonicalMIMEHeaderKey(name)]
+	values := make([]string, 0, len(raw))
+	dec := new(mime.WordDecoder)
+	for _, v := range raw {
+		if decoded, err := dec.DecodeHeader(v); err == nil {
+			v = decoded
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// Script is a compiled Sieve script
+type Script struct {
+	commands []command
+	folders  []string
+}
+
+// Compile parses and checks a script. Errors are returned as *Error.
+func Compile(src string) (*Script, error) {
+	nodes, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compiler{extensions: make(map[string]bool)}
+	commands, err := c.block(nodes, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{commands: commands, folders: c.folders}, nil
+}
+
+// Folders returns the folders the script can file messages into
+func (s *Script) Folders() []string {
+	return s.folders
+}
+
+// Execute runs the script on a message and returns the folders to file it
+// into, in order, with the inbox as InboxFolder. No folders means the
+// message is discarded.
+func (s *Script) Execute(m *Message) []string {
+	st := &state{msg: m, implicitKeep: true}
+	st.run(s.commands)
+	if st.implicitKeep {
+		st.file(InboxFolder)
+	}
+	return st.folders
+}
+
+// state is the progress of one execution
+type state struct {
+	msg          *Message
+	folders      []string
+	implicitKeep bool
+}
+
+// run executes commands and reports whether execution should go on
+func (st *state) run(commands []command) bool {
+	for _, cmd := range commands {
+		if !cmd.exec(st) {
+			return false
+		}
+	}
+	return true
+}
+
+// file adds a folder to deliver to, once
+func (st *state) file(folder string) {
+	if !containsFolder(st.folders, folder) {
+		st.folders = append(st.folders, folder)
+	}
+}
+
+type command interface {
+	exec(st *state) bool
+}
+
+type keepAction struct{}
+
+func (keepAction) exec(st *state) bool {
+	st.file(InboxFolder)
+	st.implicitKeep = false
+	return true
+}
+
+type discardAction struct{}
+
+func (discardAction) exec(st *state) bool {
+	st.implicitKeep = false
+	return true
+}
+
+type stopAction struct{}
+
+func (stopAction) exec(*state) bool {
+	return false
+}
+
+type fileintoAction struct {
+	folder string
+}
+
+func (a fileintoAction) exec(st *state) bool {
+	st.file(a.folder)
+	st.implicitKeep = false
+	return true
+}
+
+type branch struct {
+	test  test
+	block []command
+}
+
+// conditional is an if command with its elsif and else branches
+type conditional struct {
+	branches  []branch
+	otherwise []command
+	hasElse   bool
+}
+
+func (c *conditional) exec(st *state) bool {
+	for _, b := range c.branches {
+		if b.test.match(st.msg) {
+			return st.run(b.block)
+		}
+	}
+	if c.hasElse {
+		return st.run(c.otherwise)
+	}
+	return true
+}
+
+type test interface {
+	match(m *Message) bool
+}
+
+type constTest bool
+
+func (t constTest) match(*Message) bool {
+	return bool(t)
+}
+
+type notTest struct {
+	test test
+}
+
+func (t notTest) match(m *Message) bool {
+	return !t.test.match(m)
+}
+
+type allofTest []test
+
+func (t allofTest) match(m *Message) bool {
+	for _, sub := range t {
+		if !sub.match(m) {
+			return false
+		}
+	}
+	return true
+}
+
+type anyofTest []test
+
+func (t anyofTest) match(m *Message) bool {
+	for _, sub := range t {
+		if sub.match(m) {
+			return true
+		}
+	}
+	return false
+}
+
+type existsTest struct {
+	headers []string
+}
+
+func (t existsTest) match(m *Message) bool {
+	for _, h := range t.headers {
+		if len(m.Header[textproto.CanonicalMIMEHeaderKey(h)]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type sizeTest struct {
+	over  bool
+	limit int64
+}
+
+func (t sizeTest) match(m *Message) bool {
+	if t.over {
+		return m.Size > t.limit
+	}
+	return m.Size < t.limit
+}
+
+type headerTest struct {
+	headers []string
+	keys    *matcher
+}
+
+func (t headerTest) match(m *Message) bool {
+	for _, h := range t.headers {
+		for _, v := range m.values(h) {
+			if t.keys.match(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Address parts an address test compares
+const (
+	partAll       = ":all"
+	partLocalpart = ":localpart"
+	partDomain    = ":domain"
+)
+
+type addressTest struct {
+	headers []string
+	part    string
+	keys    *matcher
+}
+
+func (t addressTest) match(m *Message) bool {
+	for _, h := range t.headers {
+		for _, v := range m.values(h) {
+			addrs, err := mail.ParseAddressList(v)
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				if t.keys.match(addressPart(addr.Address, t.part)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func addressPart(address, part string) string {
+	at := strings.LastIndex(address, "@")
+	switch part {
+	case partLocalpart:
+		if at >= 0 {
+			return address[:at]
+		}
+	case partDomain:
+		if at >= 0 {
+			return address[at+1:]
+		}
+		return ""
+	}
+	return address
+}
+
+// Match types and comparators
+const (
+	matchIs       = ":is"
+	matchContains = ":contains"
+	matchMatches  = ":matches"
+
+	comparatorOctet    = "i;octet"
+	comparatorCasemap  = "i;ascii-casemap"
+	defaultComparator  = comparatorCasemap
+	defaultMatchType   = matchIs
+	defaultAddressPart = partAll
+)
+
+// matcher compares values against a test's keys
+type matcher struct {
+	matchType string
+	octet     bool
+	keys      []string
+	patterns  []*regexp.Regexp // For :matches
+}
+
+func newMatcher(matchType, comparator string, keys []string) *matcher {
+	mt := &matcher{matchType: matchType, octet: comparator == comparatorOctet, keys: keys}
+	if matchType == matchMatches {
+		for _, key := range keys {
+			mt.patterns = append(mt.patterns, wildcardPattern(key, !mt.octet))
+		}
+	}
+	return mt
+}
+
+func (mt *matcher) match(value string) bool {
+	for i, key := range mt.keys {
+		switch mt.matchType {
+		case matchIs:
+			if mt.octet && value == key || !mt.octet && strings.EqualFold(value, key) {
+				return true
+			}
+		case matchContains:
+			if mt.octet && strings.Contains(value, key) || !mt.octet && strings.Contains(strings.ToLower(value), strings.ToLower(key)) {
+				return true
+			}
+		case matchMatches:
+			if mt.patterns[i].MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wildcardPattern turns a :matches key, where * matches any sequence, ?
+// any one character and a backslash escapes the next, into a regexp
+func wildcardPattern(key string, caseless bool) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	if caseless {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("(?s)")
+
+	escaped := false
+	for _, r := range key {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			b.WriteString(".*")
+		case r == '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,151 @@
+package sieve
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testMessage = "From: \"Alice\" <alice@lists.example.org>\r\n" +
+	"To: bob@example.com, \"Carol\" <carol@Example.COM>\r\n" +
+	"Subject: =?UTF-8?Q?Weekly_newsletter?=\r\n" +
+	"List-Id: <news.lists.example.org>\r\n" +
+	"\r\n" +
+	"Body\r\n"
+
+func newTestMessage(t *testing.T) *Message {
+	t.Helper()
+	m, err := NewMessage([]byte(testMessage))
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	return m
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "empty script keeps implicitly",
+			script: "",
+			want:   []string{InboxFolder},
+		},
+		{
+			name:   "fileinto cancels implicit keep",
+			script: `require "fileinto"; if header :contains "subject" "newsletter" { fileinto "News"; }`,
+			want:   []string{"News"},
+		},
+		{
+			name:   "explicit keep with fileinto",
+			script: `require ["fileinto"]; fileinto "News"; keep;`,
+			want:   []string{"News", InboxFolder},
+		},
+		{
+			name:   "discard",
+			script: `if exists "list-id" { discard; }`,
+			want:   nil,
+		},
+		{
+			name:   "stop ends the script",
+			script: `require "fileinto"; fileinto "A"; stop; fileinto "B";`,
+			want:   []string{"A"},
+		},
+		{
+			name: "elsif and else",
+			script: `require "fileinto";
+				if header :is "subject" "nope" { fileinto "A"; }
+				elsif address :domain :is "to" "example.com" { fileinto "B"; }
+				else { fileinto "C"; }`,
+			want: []string{"B"},
+		},
+		{
+			name:   "address localpart with matches",
+			script: `require "fileinto"; if address :localpart :matches "from" "ali?e" { fileinto "Alice"; }`,
+			want:   []string{"Alice"},
+		},
+		{
+			name:   "octet comparator is case-sensitive",
+			script: `if address :all :comparator "i;octet" :is "to" "carol@example.com" { discard; }`,
+			want:   []string{InboxFolder},
+		},
+		{
+			name:   "allof anyof not",
+			script: `if allof (anyof (false, exists "from"), not size :over 1M) { discard; }`,
+			want:   nil,
+		},
+		{
+			name: "comments and multi-line strings",
+			script: "# sort lists\r\nrequire \"fileinto\"; /* block\r\ncomment */\r\n" +
+				"if not header :is \"subject\" text: # key\r\nWeekly newsletter\r\n..dot\r\n.\r\n{ fileinto \"Lists\"; }\r\n",
+			want: []string{"Lists"},
+		},
+		{
+			name:   "keep and fileinto INBOX file once",
+			script: `require "fileinto"; keep; fileinto "inbox";`,
+			want:   []string{InboxFolder},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := Compile(tt.script)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if got := script.Execute(newTestMessage(t)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		line   int
+	}{
+		{"fileinto without require", `fileinto "A";`, 1},
+		{"unsupported extension", `require "vacation";`, 1},
+		{"require after command", "keep;\nrequire \"fileinto\";", 2},
+		{"unknown command", `redirect "a@example.com";`, 1},
+		{"unknown test", `if envelope "to" "a" { keep; }`, 1},
+		{"else without if", "keep;\nelse { keep; }", 2},
+		{"missing semicolon", "keep\n", 2},
+		{"unterminated string", `if header "subject" "abc { keep; }`, 1},
+		{"missing block end", "if true {\nkeep;\n", 3},
+		{"bad match type for size", `if size :is 10 { keep; }`, 1},
+		{"header without keys", `if header "subject" { keep; }`, 1},
+		{"unsupported comparator", `if header :comparator "i;unicode" "subject" "a" { keep; }`, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.script)
+			var sieveErr *Error
+			if !errors.As(err, &sieveErr) {
+				t.Fatalf("Compile() error = %v, want *Error", err)
+			}
+			if sieveErr.Line != tt.line {
+				t.Errorf("error line = %d, want %d (%v)", sieveErr.Line, tt.line, err)
+			}
+		})
+	}
+}
+
+func TestFolders(t *testing.T) {
+	script, err := Compile(`require "fileinto";
+		if header :contains "subject" "a" { fileinto "Work/Reports"; }
+		elsif true { fileinto "Work/Reports"; fileinto "Later"; }`)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	want := []string{"Work/Reports", "Later"}
+	if got := script.Folders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Folders() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/oonrumail/smtp-server/dkim"
+	"github.com/oonrumail/smtp-server/dmarc"
+)
+
+// untrustedAuthResultsHeader is the name given to Authentication-Results
+// headers that arrived with an inbound message. Only the header we prepend
+// is trustworthy; RFC 8601 section 5 requires upstream copies to be removed
+// or renamed so they cannot be mistaken for ours.
+const untrustedAuthResultsHeader = "X-Original-Authentication-Results"
+
+// buildAuthResultsHeader formats the RFC 8601 Authentication-Results value
+// for an inbound message, using this server's hostname as the authserv-id
+func (s *Session) buildAuthResultsHeader(result *AuthCheckResult) string {
+	hostname := s.backend.server.config.Server.Hostname
+	if result == nil {
+		return hostname + "; none"
+	}
+
+	parts := []string{hostname}
+
+	// SPF result. A null reverse-path is checked against the HELO identity.
+	if s.from != "" {
+		parts = append(parts, fmt.Sprintf("spf=%s smtp.mailfrom=%s", result.SPFResult, s.from))
+	} else {
+		parts = append(parts, fmt.Sprintf("spf=%s smtp.helo=%s", result.SPFResult, s.heloName()))
+	}
+
+	// DKIM result, one entry per signature
+	if len(result.DKIMResults) == 0 {
+		parts = append(parts, "dkim=none")
+	}
+	for _, dr := range result.DKIMResults {
+		dkimPart := fmt.Sprintf("dkim=%s header.d=%s", dkimResultString(dr), dr.Domain)
+		if dr.Selector != "" {
+			dkimPart += " header.s=" + dr.Selector
+		}
+		parts = append(parts, dkimPart)
+	}
+
+	// DMARC result, evaluated for the RFC 5322 From domain
+	if result.DMARCResult != nil {
+		parts = append(parts, fmt.Sprintf("dmarc=%s header.from=%s",
+			dmarcResultString(result.DMARCResult), result.HeaderFrom))
+	}
+
+	// ARC result
+	if result.ARCResult != nil {
+		parts = append(parts, "arc="+result.ARCResult.Validation.ResultString())
+	}
+
+	return strings.Join(parts, ";\r\n\t")
+}
+
+// dkimResultString maps a signature's verification outcome to an RFC 8601
+// dkim result
+func dkimResultString(dr *dkim.VerificationResult) string {
+	if dr.Status != "" {
+		return string(dr.Status)
+	}
+	if dr.Valid {
+		return "pass"
+	}
+	return "fail"
+}
+
+// dmarcResultString maps a DMARC check to an RFC 8601 dmarc result
+func dmarcResultString(result *dmarc.CheckResult) string {
+	switch {
+	case result.Record == nil && result.Error != nil && dkim.IsTemporaryError(result.Error):
+		return "temperror"
+	case result.Record == nil:
+		return "none"
+	case result.Pass:
+		return "pass"
+	default:
+		return "fail"
+	}
+}
+
+// headerFromDomain returns the domain of the RFC 5322 From address, or ""
+// if the header is missing or unparseable
+func headerFromDomain(header mail.Header) string {
+	addr, err := mail.ParseAddress(header.Get("From"))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(extractDomain(addr.Address))
+}
+
+// heloName returns the name the client gave in HELO/EHLO
+func (s *Session) heloName() string {
+	if s.conn == nil {
+		return ""
+	}
+	return s.conn.Hostname()
+}
+
+// renameAuthResultsHeaders renames every Authentication-Results header in
+// the message header block to untrustedAuthResultsHeader, keeping the
+// upstream results visible without letting them pass as ours
+func renameAuthResultsHeaders(data []byte) []byte {
+	const name = "Authentication-Results:"
+
+	var out bytes.Buffer
+	out.Grow(len(data) + 64)
+
+	rest := data
+	for len(rest) > 0 {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+
+		// The header block ends at the first empty line
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			out.Write(line)
+			out.Write(rest)
+			break
+		}
+
+		if len(line) >= len(name) && strings.EqualFold(string(line[:len(name)]), name) {
+			out.WriteString(untrustedAuthResultsHeader + ":")
+			out.Write(line[len(name):])
+			continue
+		}
+		out.Write(line)
+	}
+
+	return out.Bytes()
+}
@@ -0,0 +1,144 @@
+package smtp
+
+import (
+	"bytes"
+	"errors"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/oonrumail/smtp-server/arc"
+	"github.com/oonrumail/smtp-server/config"
+	"github.com/oonrumail/smtp-server/dkim"
+	"github.com/oonrumail/smtp-server/dmarc"
+	"github.com/oonrumail/smtp-server/spf"
+)
+
+func newAuthResultsSession(from string) *Session {
+	cfg := config.DefaultConfig()
+	cfg.Server.Hostname = "mx.example.org"
+	return &Session{
+		backend: &Backend{server: &Server{config: cfg}},
+		from:    from,
+	}
+}
+
+func TestBuildAuthResultsHeader(t *testing.T) {
+	session := newAuthResultsSession("bounce@mail.sender.test")
+
+	result := &AuthCheckResult{
+		SPFResult:  spf.ResultPass,
+		HeaderFrom: "sender.test",
+		DKIMResults: []*dkim.VerificationResult{
+			{Domain: "sender.test", Selector: "s1", Valid: true, Status: dkim.VerificationPass},
+			{Domain: "esp.test", Selector: "k2", Status: dkim.VerificationFail},
+		},
+		DMARCResult: &dmarc.CheckResult{Domain: "sender.test", Record: &dmarc.Record{Policy: "reject"}, Pass: true},
+		ARCResult:   &arc.ChainResult{Validation: arc.ChainValidationNone},
+	}
+
+	header := session.buildAuthResultsHeader(result)
+
+	authservID, results := arc.ParseAuthenticationResults(header)
+	if authservID != "mx.example.org" {
+		t.Errorf("authserv-id = %q, want mx.example.org", authservID)
+	}
+
+	want := []string{
+		"spf=pass smtp.mailfrom=bounce@mail.sender.test",
+		"dkim=pass header.d=sender.test header.s=s1",
+		"dkim=fail header.d=esp.test header.s=k2",
+		"dmarc=pass header.from=sender.test",
+		"arc=none",
+	}
+	unfolded := strings.Join(strings.Fields(header), " ")
+	for _, w := range want {
+		if !strings.Contains(unfolded, w) {
+			t.Errorf("header %q is missing %q", unfolded, w)
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("parsed %d results, want %d", len(results), len(want))
+	}
+}
+
+func TestBuildAuthResultsHeader_NoSignaturesOrPolicy(t *testing.T) {
+	session := newAuthResultsSession("")
+
+	result := &AuthCheckResult{
+		SPFResult:   spf.ResultNone,
+		HeaderFrom:  "sender.test",
+		DMARCResult: &dmarc.CheckResult{Domain: "sender.test"},
+	}
+
+	unfolded := strings.Join(strings.Fields(session.buildAuthResultsHeader(result)), " ")
+	for _, w := range []string{"spf=none smtp.helo=", "dkim=none", "dmarc=none header.from=sender.test"} {
+		if !strings.Contains(unfolded, w) {
+			t.Errorf("header %q is missing %q", unfolded, w)
+		}
+	}
+
+	if got := session.buildAuthResultsHeader(nil); got != "mx.example.org; none" {
+		t.Errorf("header without results = %q", got)
+	}
+}
+
+func TestDMARCResultString(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *dmarc.CheckResult
+		want   string
+	}{
+		{"no record", &dmarc.CheckResult{}, "none"},
+		{"lookup failed permanently", &dmarc.CheckResult{Error: errors.New("no such host")}, "none"},
+		{"pass", &dmarc.CheckResult{Record: &dmarc.Record{}, Pass: true}, "pass"},
+		{"fail", &dmarc.CheckResult{Record: &dmarc.Record{}}, "fail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dmarcResultString(tt.result); got != tt.want {
+				t.Errorf("dmarcResultString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameAuthResultsHeaders(t *testing.T) {
+	message := []byte("Authentication-Results: mx.example.org; spf=pass\r\n" +
+		"Received: from relay.test\r\n" +
+		"authentication-results: relay.test;\r\n" +
+		"\tdkim=pass header.d=sender.test\r\n" +
+		"Subject: Hi\r\n" +
+		"\r\n" +
+		"Authentication-Results: in the body stays\r\n")
+
+	renamed := renameAuthResultsHeaders(message)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(renamed))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if got := msg.Header["Authentication-Results"]; len(got) != 0 {
+		t.Errorf("Authentication-Results left in header: %v", got)
+	}
+	if got := msg.Header[untrustedAuthResultsHeader]; len(got) != 2 {
+		t.Errorf("%s count = %d, want 2", untrustedAuthResultsHeader, len(got))
+	}
+	if !strings.Contains(msg.Header.Get(untrustedAuthResultsHeader), "mx.example.org") {
+		t.Errorf("renamed header lost its value: %q", msg.Header.Get(untrustedAuthResultsHeader))
+	}
+	if !bytes.HasSuffix(renamed, []byte("\r\n\r\nAuthentication-Results: in the body stays\r\n")) {
+		t.Error("message body was modified")
+	}
+}
+
+func TestHeaderFromDomain(t *testing.T) {
+	header := mail.Header{"From": {`"Alice" <Alice@Sender.TEST>`}}
+	if got := headerFromDomain(header); got != "sender.test" {
+		t.Errorf("headerFromDomain() = %q, want sender.test", got)
+	}
+	if got := headerFromDomain(mail.Header{}); got != "" {
+		t.Errorf("headerFromDomain() without From = %q", got)
+	}
+}
@@ -0,0 +1,244 @@
+package smtp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	"go.uber.org/zap"
+)
+
+// drainWriteTimeout bounds writing the 421 to a connection being closed
+const drainWriteTimeout = 5 * time.Second
+
+// errShuttingDown refuses new sessions and transactions while draining
+var errShuttingDown = &smtp.SMTPError{
+	Code:         421,
+	EnhancedCode: smtp.EnhancedCode{4, 3, 2},
+	Message:      "Service shutting down, try again later",
+}
+
+// drainer lets transactions in progress finish when the server shuts down,
+// so senders don't retry, and duplicate, messages cut off mid-DATA. It
+// tracks every SMTP and submission connection, and whether its session is
+// in a transaction, from MAIL FROM to the end of DATA or RSET. Once
+// draining, idle connections are sent a 421 and closed so their clients
+// reconnect to another server, and so is each connection as its
+// transaction ends.
+type drainer struct {
+	hostname string
+	metrics  *Metrics
+	logger   *zap.Logger
+
+	mu       sync.Mutex
+	conns    map[*drainConn]struct{}
+	active   int // Connections in a transaction
+	draining bool
+}
+
+func newDrainer(hostname string, metrics *Metrics, logger *zap.Logger) *drainer {
+	return &drainer{
+		hostname: hostname,
+		metrics:  metrics,
+		logger:   logger,
+		conns:    make(map[*drainConn]struct{}),
+	}
+}
+
+// listener tracks the connections accepted by l
+func (d *drainer) listener(l net.Listener) net.Listener {
+	return &drainListener{Listener: l, drainer: d}
+}
+
+// start begins draining. It returns the idle connections, to be closed,
+// and the number of transactions left to finish.
+func (d *drainer) start() ([]*drainConn, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.draining = true
+	d.metrics.Draining.Set(1)
+
+	var idle []*drainConn
+	for c := range d.conns {
+		if !c.inTransaction {
+			idle = append(idle, c)
+		}
+	}
+	return idle, d.active
+}
+
+// remaining returns the connections still open
+func (d *drainer) remaining() []*drainConn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	conns := make([]*drainConn, 0, len(d.conns))
+	for c := range d.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func (d *drainer) isDraining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+func (d *drainer) add(conn net.Conn) *drainConn {
+	c := &drainConn{Conn: conn, drainer: d}
+
+	d.mu.Lock()
+	d.conns[c] = struct{}{}
+	draining := d.draining
+	d.mu.Unlock()
+
+	// Accepted just as the listener was closed
+	if draining {
+		c.shutDown()
+	}
+	return c
+}
+
+func (d *drainer) remove(c *drainConn) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.conns[c]; !ok {
+		return
+	}
+	delete(d.conns, c)
+	if c.inTransaction {
+		c.inTransaction = false
+		d.active--
+		d.metrics.TransactionsActive.Dec()
+	}
+}
+
+// drainConn is a connection tracked by a drainer
+type drainConn struct {
+	net.Conn
+	drainer *drainer
+
+	// Guarded by drainer.mu
+	inTransaction bool
+	top           net.Conn // The session's connection, over TLS after STARTTLS
+}
+
+// attach finds the tracked connection under a session's connection, and
+// records the session's connection for writing the 421 to
+func (d *drainer) attach(conn net.Conn) (*drainConn, bool) {
+	c, ok := unwrapConn(conn).(*drainConn)
+	if !ok {
+		return nil, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c.top = conn
+	return c, !d.draining
+}
+
+// unwrapConn returns the connection under a TLS connection
+func unwrapConn(conn net.Conn) net.Conn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		return tlsConn.NetConn()
+	}
+	return conn
+}
+
+// begin records the start of a transaction. It reports false while
+// draining, when no new transaction may start.
+func (c *drainConn) begin() bool {
+	if c == nil {
+		return true
+	}
+
+	d := c.drainer
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return false
+	}
+	if !c.inTransaction {
+		c.inTransaction = true
+		d.active++
+		d.metrics.TransactionsActive.Inc()
+	}
+	return true
+}
+
+// end records the end of a transaction, closing the connection if the
+// server is draining
+func (c *drainConn) end() {
+	if c == nil {
+		return
+	}
+
+	d := c.drainer
+	d.mu.Lock()
+	if !c.inTransaction {
+		d.mu.Unlock()
+		return
+	}
+	c.inTransaction = false
+	d.active--
+	d.metrics.TransactionsActive.Dec()
+	draining := d.draining
+	d.mu.Unlock()
+
+	if draining {
+		c.shutDown()
+	}
+}
+
+// detach records the connection a session ended on, which is over TLS once
+// the session ends for STARTTLS
+func (c *drainConn) detach(conn net.Conn) {
+	if c == nil {
+		return
+	}
+
+	c.drainer.mu.Lock()
+	c.top = conn
+	c.drainer.mu.Unlock()
+}
+
+// shutDown tells the client the service is shutting down and closes the
+// connection. go-smtp sees the closed connection and ends the session.
+func (c *drainConn) shutDown() {
+	c.drainer.mu.Lock()
+	conn := c.top
+	c.drainer.mu.Unlock()
+	if conn == nil {
+		conn = c.Conn
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(drainWriteTimeout))
+	fmt.Fprintf(conn, "421 4.3.2 %s Service shutting down, try again later\r\n", c.drainer.hostname)
+	c.Close()
+}
+
+func (c *drainConn) Close() error {
+	c.drainer.remove(c)
+	return c.Conn.Close()
+}
+
+// drainListener tracks the connections it accepts with a drainer
+type drainListener struct {
+	net.Listener
+	drainer *drainer
+}
+
+func (l *drainListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.drainer.add(conn), nil
+}
@@ -0,0 +1,153 @@
+package smtp
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+// dialDrained connects a client to a listener tracked by d and returns
+// the client end and the tracked server end
+func dialDrained(t *testing.T, l net.Listener) (net.Conn, *drainConn) {
+	t.Helper()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	return client, server.(*drainConn)
+}
+
+// readReply reads the line the server sent, and whether it then closed
+// the connection
+func readReply(t *testing.T, client net.Conn) (string, bool) {
+	t.Helper()
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(client)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", false
+	}
+	_, err = r.ReadByte()
+	return strings.TrimSpace(line), err != nil && !isTimeout(err)
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func TestDrainer(t *testing.T) {
+	metrics := NewMetrics()
+	d := newDrainer("mx.example.com", metrics, zap.NewNop())
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	l := d.listener(tcp)
+	defer l.Close()
+
+	idleClient, _ := dialDrained(t, l)
+	busyClient, busy := dialDrained(t, l)
+	stuckClient, stuck := dialDrained(t, l)
+
+	if !busy.begin() || !stuck.begin() {
+		t.Fatal("begin() = false before draining")
+	}
+	if got := testutil.ToFloat64(metrics.TransactionsActive); got != 2 {
+		t.Errorf("smtp_transactions_active = %v, want 2", got)
+	}
+
+	idle, active := d.start()
+	if len(idle) != 1 || active != 2 {
+		t.Fatalf("start() = %d idle, %d active, want 1 and 2", len(idle), active)
+	}
+	if got := testutil.ToFloat64(metrics.Draining); got != 1 {
+		t.Errorf("smtp_draining = %v, want 1", got)
+	}
+	for _, c := range idle {
+		c.shutDown()
+	}
+
+	// Idle connections are told to go elsewhere
+	line, closed := readReply(t, idleClient)
+	if !strings.HasPrefix(line, "421 4.3.2 mx.example.com") || !closed {
+		t.Errorf("idle connection got %q, closed %v; want a 421 and close", line, closed)
+	}
+
+	// No new transactions start, but ones in progress carry on
+	if busy.begin() {
+		t.Error("begin() = true while draining")
+	}
+	busyClient.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := busyClient.Read(make([]byte, 1)); !isTimeout(err) {
+		t.Errorf("connection in a transaction was interrupted: %v", err)
+	}
+
+	// A finished transaction's connection is closed
+	busy.end()
+	line, closed = readReply(t, busyClient)
+	if !strings.HasPrefix(line, "421 ") || !closed {
+		t.Errorf("connection after its transaction got %q, closed %v; want a 421 and close", line, closed)
+	}
+
+	// Connections still in a transaction at the deadline are closed
+	remaining := d.remaining()
+	if len(remaining) != 1 || remaining[0] != stuck {
+		t.Fatalf("remaining() = %v, want the stuck connection", remaining)
+	}
+	stuck.shutDown()
+	line, closed = readReply(t, stuckClient)
+	if !strings.HasPrefix(line, "421 ") || !closed {
+		t.Errorf("stuck connection got %q, closed %v; want a 421 and close", line, closed)
+	}
+
+	if len(d.remaining()) != 0 {
+		t.Error("connections left after all were closed")
+	}
+	if got := testutil.ToFloat64(metrics.TransactionsActive); got != 0 {
+		t.Errorf("smtp_transactions_active = %v, want 0", got)
+	}
+}
+
+func TestDrainerAcceptWhileDraining(t *testing.T) {
+	d := newDrainer("mx.example.com", NewMetrics(), zap.NewNop())
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	l := d.listener(tcp)
+	defer l.Close()
+
+	d.start()
+	client, _ := dialDrained(t, l)
+
+	line, closed := readReply(t, client)
+	if !strings.HasPrefix(line, "421 ") || !closed {
+		t.Errorf("connection accepted while draining got %q, closed %v; want a 421 and close", line, closed)
+	}
+}
+
+func TestNilDrainConn(t *testing.T) {
+	// Sessions not on a tracked listener have no drainConn
+	var c *drainConn
+	if !c.begin() {
+		t.Error("begin() = false without a drainer")
+	}
+	c.end()
+	c.detach(nil)
+}
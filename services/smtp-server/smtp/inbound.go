@@ -0,0 +1,145 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/emersion/go-smtp"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+)
+
+// Results of handing a message to the transactional API, reported in the
+// smtp_inbound_parse_total metric
+const (
+	inboundParseAccepted = "accepted"
+	inboundParseNoRoute  = "no_route"
+	inboundParseTooLarge = "too_large"
+	inboundParseInvalid  = "invalid"
+	inboundParseFailed   = "failed"
+)
+
+// inboundParser hands mail for inbound parse domains to the transactional
+// API instead of queueing it. The message is only accepted once the API
+// has queued its posts, so a failure leaves the sender to retry.
+type inboundParser struct {
+	config  *config.InboundParseConfig
+	domains []string
+	client  *http.Client
+}
+
+// newInboundParser returns nil when inbound parse is disabled
+func newInboundParser(cfg *config.InboundParseConfig) *inboundParser {
+	if !cfg.Enabled || cfg.Endpoint == "" {
+		return nil
+	}
+
+	var domains []string
+	for _, d := range cfg.Domains {
+		if d = strings.ToLower(strings.Trim(strings.TrimSpace(d), ".")); d != "" {
+			domains = append(domains, d)
+		}
+	}
+
+	return &inboundParser{
+		config:  cfg,
+		domains: domains,
+		client:  &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// handles reports whether mail for domainName, a configured domain or a
+// subdomain of one, goes to inbound parse
+func (p *inboundParser) handles(domainName string) bool {
+	if p == nil {
+		return false
+	}
+	for _, d := range p.domains {
+		if domainName == d || strings.HasSuffix(domainName, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// forward posts the message with its envelope to the transactional API. It
+// returns the metric result and, when the message wasn't accepted, the
+// reply for the sender.
+func (p *inboundParser) forward(ctx context.Context, from string, recipients []string, data []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return inboundParseFailed, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "message/rfc822")
+	req.Header.Set("X-Mail-From", from)
+	req.Header.Set("X-Rcpt-To", strings.Join(recipients, ","))
+	if p.config.InternalSecret != "" {
+		req.Header.Set("X-Internal-Secret", p.config.InternalSecret)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return inboundParseFailed, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return inboundParseAccepted, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return inboundParseNoRoute, &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+			Message:      "No inbound route for recipient",
+		}
+	case resp.StatusCode == http.StatusRequestEntityTooLarge:
+		return inboundParseTooLarge, &smtp.SMTPError{
+			Code:         552,
+			EnhancedCode: smtp.EnhancedCode{5, 3, 4},
+			Message:      "Message too large for inbound parse",
+		}
+	case resp.StatusCode == http.StatusBadRequest:
+		return inboundParseInvalid, &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+			Message:      "Message could not be parsed",
+		}
+	}
+	return inboundParseFailed, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+// forwardInboundParse hands the message to inbound parse for recipients on
+// inbound parse domains
+func (s *Session) forwardInboundParse(ctx context.Context, messageID string, data []byte, recipients []string) error {
+	server := s.backend.server
+	result, err := server.inboundParser.forward(ctx, s.from, recipients, data)
+	server.metrics.InboundParse.WithLabelValues(result).Inc()
+	if err == nil {
+		s.logger.Debug("Handed message to inbound parse",
+			zap.String("message_id", messageID),
+			zap.Int("recipients", len(recipients)))
+		return nil
+	}
+
+	if smtpErr, ok := err.(*smtp.SMTPError); ok {
+		s.logger.Info("Inbound parse rejected message",
+			zap.String("message_id", messageID),
+			zap.String("result", result))
+		return smtpErr
+	}
+
+	s.logger.Error("Failed to hand message to inbound parse",
+		zap.String("message_id", messageID),
+		zap.Error(err))
+	return &smtp.SMTPError{
+		Code:         451,
+		EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+		Message:      "Temporary error processing message",
+	}
+}
@@ -0,0 +1,104 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-smtp"
+
+	"github.com/oonrumail/smtp-server/config"
+)
+
+func TestInboundParserHandles(t *testing.T) {
+	p := newInboundParser(&config.InboundParseConfig{
+		Enabled:  true,
+		Domains:  []string{" Parse.Example.com. ", ""},
+		Endpoint: "http://transactional-api/internal/inbound",
+	})
+
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"parse.example.com", true},
+		{"eu.parse.example.com", true},
+		{"example.com", false},
+		{"notparse.example.com", false},
+		{"parse.example.com.evil.test", false},
+	}
+	for _, tt := range tests {
+		if got := p.handles(tt.domain); got != tt.want {
+			t.Errorf("handles(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+
+	var disabled *inboundParser
+	if disabled.handles("parse.example.com") {
+		t.Error("nil parser handles a domain")
+	}
+	if newInboundParser(&config.InboundParseConfig{Domains: []string{"parse.example.com"}}) != nil {
+		t.Error("newInboundParser() returned a parser while disabled")
+	}
+}
+
+func TestInboundParserForward(t *testing.T) {
+	tests := []struct {
+		status     int
+		wantResult string
+		wantCode   int // SMTP reply code; 0 for accepted, -1 for a temporary failure
+	}{
+		{http.StatusAccepted, inboundParseAccepted, 0},
+		{http.StatusNotFound, inboundParseNoRoute, 550},
+		{http.StatusRequestEntityTooLarge, inboundParseTooLarge, 552},
+		{http.StatusBadRequest, inboundParseInvalid, 554},
+		{http.StatusInternalServerError, inboundParseFailed, -1},
+	}
+
+	for _, tt := range tests {
+		var gotFrom, gotRcpt, gotSecret, gotBody string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotFrom = r.Header.Get("X-Mail-From")
+			gotRcpt = r.Header.Get("X-Rcpt-To")
+			gotSecret = r.Header.Get("X-Internal-Secret")
+			body, _ := io.ReadAll(r.Body)
+			gotBody = string(body)
+			w.WriteHeader(tt.status)
+		}))
+
+		p := newInboundParser(&config.InboundParseConfig{
+			Enabled:        true,
+			Domains:        []string{"parse.example.com"},
+			Endpoint:       srv.URL,
+			InternalSecret: "s3cret",
+			Timeout:        5 * time.Second,
+		})
+		result, err := p.forward(context.Background(), "alice@example.org",
+			[]string{"a@parse.example.com", "b@parse.example.com"}, []byte("Subject: hi\r\n\r\nbody"))
+		srv.Close()
+
+		if result != tt.wantResult {
+			t.Errorf("HTTP %d: result = %q, want %q", tt.status, result, tt.wantResult)
+		}
+		var smtpErr *smtp.SMTPError
+		switch {
+		case tt.wantCode == 0 && err != nil:
+			t.Errorf("HTTP %d: forward() error = %v", tt.status, err)
+		case tt.wantCode > 0 && (!errors.As(err, &smtpErr) || smtpErr.Code != tt.wantCode):
+			t.Errorf("HTTP %d: forward() error = %v, want SMTP %d", tt.status, err, tt.wantCode)
+		case tt.wantCode < 0 && (err == nil || errors.As(err, &smtpErr)):
+			t.Errorf("HTTP %d: forward() error = %v, want a temporary failure", tt.status, err)
+		}
+
+		if gotFrom != "alice@example.org" || gotRcpt != "a@parse.example.com,b@parse.example.com" || gotSecret != "s3cret" {
+			t.Errorf("HTTP %d: envelope headers = %q, %q, %q", tt.status, gotFrom, gotRcpt, gotSecret)
+		}
+		if gotBody != "Subject: hi\r\n\r\nbody" {
+			t.Errorf("HTTP %d: body = %q", tt.status, gotBody)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/queue"
+	"github.com/oonrumail/smtp-server/repository"
+)
+
+// LocalDelivery delivers messages into local mailboxes. It is implemented by
+// queue.Manager.
+type LocalDelivery interface {
+	LookupRecipient(ctx context.Context, email string) (*queue.RecipientLookupResult, error)
+	DeliverLocalRecipient(ctx context.Context, msg *domain.Message, targetDomain *domain.Domain, recipient string, data []byte) error
+}
+
+// LMTPBackend implements smtp.Backend for LMTP (RFC 2033). Messages are
+// delivered straight into local mailboxes instead of being queued, and each
+// recipient gets its own status after DATA so that one failing recipient
+// does not fail delivery to the others.
+type LMTPBackend struct {
+	config   *config.Config
+	domains  queue.DomainProvider
+	delivery LocalDelivery
+	metrics  *Metrics
+	logger   *zap.Logger
+}
+
+// NewLMTPBackend creates a new LMTP backend
+func NewLMTPBackend(cfg *config.Config, domains queue.DomainProvider, delivery LocalDelivery, metrics *Metrics, logger *zap.Logger) *LMTPBackend {
+	return &LMTPBackend{
+		config:   cfg,
+		domains:  domains,
+		delivery: delivery,
+		metrics:  metrics,
+		logger:   logger,
+	}
+}
+
+// NewSession creates a new session for an LMTP connection
+func (b *LMTPBackend) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	return &LMTPSession{
+		backend: b,
+		conn:    c,
+		logger:  b.logger.With(zap.String("remote_addr", c.Conn().RemoteAddr().String())),
+	}, nil
+}
+
+// lmtpRecipient is an accepted recipient and the local domain it belongs to
+type lmtpRecipient struct {
+	address string
+	domain  *domain.Domain
+}
+
+// LMTPSession handles a single LMTP session
+type LMTPSession struct {
+	backend *LMTPBackend
+	conn    *smtp.Conn
+	logger  *zap.Logger
+
+	from       string
+	recipients []lmtpRecipient
+}
+
+// Reset resets the session state
+func (s *LMTPSession) Reset() {
+	s.from = ""
+	s.recipients = nil
+}
+
+// Logout is called when the client disconnects
+func (s *LMTPSession) Logout() error {
+	return nil
+}
+
+// Mail sets the envelope sender. The null reverse-path is allowed so bounces
+// can be delivered locally.
+func (s *LMTPSession) Mail(from string, opts *smtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+// Rcpt accepts a recipient only if it resolves to a local mailbox, alias,
+// distribution list or catch-all address
+func (s *LMTPSession) Rcpt(to string, opts *smtp.RcptOptions) error {
+	d := s.backend.domains.GetDomain(extractDomain(to))
+	if d == nil {
+		return &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 2},
+			Message:      "Recipient domain is not local",
+		}
+	}
+
+	result, err := s.backend.delivery.LookupRecipient(context.Background(), to)
+	if err != nil {
+		s.logger.Error("Recipient lookup failed", zap.String("recipient", to), zap.Error(err))
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Temporary error looking up recipient",
+		}
+	}
+
+	catchAll := ""
+	if !result.Found {
+		localPart := to
+		if at := strings.LastIndex(to, "@"); at >= 0 {
+			localPart = to[:at]
+		}
+		catchAll, err = s.backend.domains.CatchAllDestination(context.Background(), d, localPart)
+		if err != nil {
+			s.logger.Error("Catch-all lookup failed", zap.String("recipient", to), zap.Error(err))
+			return &smtp.SMTPError{
+				Code:         451,
+				EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+				Message:      "Temporary error looking up recipient",
+			}
+		}
+	}
+	if !result.Found && catchAll == "" {
+		return &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+			Message:      "User unknown",
+		}
+	}
+
+	s.recipients = append(s.recipients, lmtpRecipient{address: to, domain: d})
+	return nil
+}
+
+// Data delivers the message when the session is used without per-recipient
+// status. It fails if delivery to any recipient fails.
+func (s *LMTPSession) Data(r io.Reader) error {
+	var firstErr error
+	err := s.LMTPData(r, statusFunc(func(rcpt string, err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}))
+	if err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// LMTPData delivers the message to each recipient in turn and reports a
+// separate status for each
+func (s *LMTPSession) LMTPData(r io.Reader, status smtp.StatusCollector) error {
+	ctx := context.Background()
+	startTime := time.Now()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Error reading message data",
+		}
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		s.logger.Warn("Failed to parse message", zap.Error(err))
+		return &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+			Message:      "Invalid message format",
+		}
+	}
+	subject := parsed.Header.Get("Subject")
+
+	id := uuid.New().String()
+	received := fmt.Sprintf("from %s by %s with LMTP id %s; %s",
+		s.conn.Hostname(), s.backend.config.Server.Hostname, id, time.Now().Format(time.RFC1123Z))
+	data := prependHeader(buf.Bytes(), "Received", received)
+	headers := extractHeaders(data)
+
+	delivered := 0
+	for _, rcpt := range s.recipients {
+		msg := &domain.Message{
+			ID:             uuid.New().String(),
+			OrganizationID: rcpt.domain.OrganizationID,
+			DomainID:       rcpt.domain.ID,
+			FromAddress:    s.from,
+			Recipients:     []string{rcpt.address},
+			Subject:        subject,
+			Headers:        headers,
+			BodySize:       int64(len(data)),
+			Status:         domain.StatusDelivered,
+			CreatedAt:      time.Now(),
+		}
+
+		err := s.backend.delivery.DeliverLocalRecipient(ctx, msg, rcpt.domain, rcpt.address, data)
+		if err != nil {
+			s.logger.Warn("LMTP delivery failed",
+				zap.String("lmtp_id", id),
+				zap.String("recipient", rcpt.address),
+				zap.Error(err))
+			status.SetStatus(rcpt.address, lmtpDeliveryError(err))
+			continue
+		}
+
+		delivered++
+		if s.backend.metrics != nil {
+			s.backend.metrics.MessagesReceived.WithLabelValues(rcpt.domain.Name).Inc()
+			s.backend.metrics.MessageSize.WithLabelValues(rcpt.domain.Name).Observe(float64(len(data)))
+		}
+		status.SetStatus(rcpt.address, nil)
+	}
+
+	s.logger.Info("LMTP message processed",
+		zap.String("lmtp_id", id),
+		zap.String("from", s.from),
+		zap.Int("delivered", delivered),
+		zap.Int("failed", len(s.recipients)-delivered),
+		zap.Duration("duration", time.Since(startTime)))
+
+	return nil
+}
+
+// lmtpDeliveryError maps a delivery failure to the status reported for the
+// recipient. A full mailbox is permanent; anything else may succeed on retry.
+func lmtpDeliveryError(err error) error {
+	if errors.Is(err, repository.ErrQuotaExceeded) {
+		return &smtp.SMTPError{
+			Code:         552,
+			EnhancedCode: smtp.EnhancedCode{5, 2, 2},
+			Message:      "Mailbox full",
+		}
+	}
+	return &smtp.SMTPError{
+		Code:         451,
+		EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+		Message:      "Temporary delivery failure",
+	}
+}
+
+// statusFunc adapts a function to smtp.StatusCollector
+type statusFunc func(rcpt string, err error)
+
+func (f statusFunc) SetStatus(rcpt string, err error) {
+	f(rcpt, err)
+}
+
+// listenLMTP opens the LMTP listener. Addresses prefixed with "unix:" are
+// unix socket paths; anything else must be a loopback host:port because LMTP
+// has no authentication.
+func listenLMTP(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// Remove a socket left behind by an unclean shutdown
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+		return net.Listen("unix", path)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LMTP address %q: %w", addr, err)
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("LMTP address %q is not a loopback address or unix socket", addr)
+		}
+	}
+
+	return net.Listen("tcp", addr)
+}
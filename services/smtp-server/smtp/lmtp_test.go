@@ -0,0 +1,180 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/emersion/go-smtp"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/queue"
+	"github.com/oonrumail/smtp-server/repository"
+)
+
+type fakeDomains struct {
+	domains map[string]*domain.Domain
+}
+
+func (f *fakeDomains) GetDomain(name string) *domain.Domain { return f.domains[name] }
+
+func (f *fakeDomains) GetDomainByID(id string) *domain.Domain { return nil }
+
+func (f *fakeDomains) CatchAllDestination(ctx context.Context, d *domain.Domain, localPart string) (string, error) {
+	return domain.ResolveCatchAll(nil, d.Policies, localPart), nil
+}
+
+type fakeLocalDelivery struct {
+	mu        sync.Mutex
+	mailboxes map[string]bool
+	failures  map[string]error
+	delivered []string
+}
+
+func (f *fakeLocalDelivery) LookupRecipient(ctx context.Context, email string) (*queue.RecipientLookupResult, error) {
+	return &queue.RecipientLookupResult{Found: f.mailboxes[email], Type: "mailbox"}, nil
+}
+
+func (f *fakeLocalDelivery) DeliverLocalRecipient(ctx context.Context, msg *domain.Message, targetDomain *domain.Domain, recipient string, data []byte) error {
+	if err := f.failures[recipient]; err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delivered = append(f.delivered, recipient)
+	return nil
+}
+
+func startTestLMTPServer(t *testing.T, delivery *fakeLocalDelivery) *textproto.Conn {
+	t.Helper()
+
+	domains := &fakeDomains{domains: map[string]*domain.Domain{
+		"example.com": {ID: "domain-1", OrganizationID: "org-1", Name: "example.com"},
+	}}
+	backend := NewLMTPBackend(config.DefaultConfig(), domains, delivery, nil, zap.NewNop())
+
+	server := smtp.NewServer(backend)
+	server.LMTP = true
+	server.Domain = "localhost"
+
+	listener, err := listenLMTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listenLMTP() error = %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := textproto.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if _, _, err := conn.ReadResponse(220); err != nil {
+		t.Fatalf("Greeting error = %v", err)
+	}
+	return conn
+}
+
+func lmtpCmd(t *testing.T, conn *textproto.Conn, expectCode int, format string, args ...interface{}) string {
+	t.Helper()
+	if err := conn.PrintfLine(format, args...); err != nil {
+		t.Fatalf("PrintfLine() error = %v", err)
+	}
+	_, msg, err := conn.ReadResponse(expectCode)
+	if err != nil {
+		t.Fatalf("%s: %v", fmt.Sprintf(format, args...), err)
+	}
+	return msg
+}
+
+func TestLMTP_RejectsEHLO(t *testing.T) {
+	conn := startTestLMTPServer(t, &fakeLocalDelivery{})
+
+	lmtpCmd(t, conn, 500, "EHLO client.local")
+	lmtpCmd(t, conn, 500, "HELO client.local")
+	lmtpCmd(t, conn, 250, "LHLO client.local")
+}
+
+func TestLMTP_PerRecipientStatus(t *testing.T) {
+	delivery := &fakeLocalDelivery{
+		mailboxes: map[string]bool{
+			"alice@example.com": true,
+			"bob@example.com":   true,
+			"carol@example.com": true,
+		},
+		failures: map[string]error{
+			"bob@example.com": fmt.Errorf("%w: 100/100 bytes used", repository.ErrQuotaExceeded),
+		},
+	}
+	conn := startTestLMTPServer(t, delivery)
+
+	lmtpCmd(t, conn, 250, "LHLO client.local")
+	lmtpCmd(t, conn, 250, "MAIL FROM:<sender@internal.local>")
+	lmtpCmd(t, conn, 250, "RCPT TO:<alice@example.com>")
+	lmtpCmd(t, conn, 250, "RCPT TO:<bob@example.com>")
+	lmtpCmd(t, conn, 550, "RCPT TO:<unknown@example.com>")
+	lmtpCmd(t, conn, 550, "RCPT TO:<someone@other.org>")
+	lmtpCmd(t, conn, 250, "RCPT TO:<carol@example.com>")
+	lmtpCmd(t, conn, 354, "DATA")
+
+	if err := conn.PrintfLine("Subject: Test\r\n\r\nHello\r\n."); err != nil {
+		t.Fatalf("PrintfLine() error = %v", err)
+	}
+
+	// One status line per accepted recipient, in RCPT order
+	expected := []struct {
+		code int
+		rcpt string
+	}{
+		{250, "alice@example.com"},
+		{552, "bob@example.com"},
+		{250, "carol@example.com"},
+	}
+	for _, e := range expected {
+		_, msg, err := conn.ReadResponse(e.code)
+		if err != nil {
+			t.Fatalf("Status for %s: %v", e.rcpt, err)
+		}
+		if !strings.Contains(msg, e.rcpt) {
+			t.Errorf("Status %q does not name %s", msg, e.rcpt)
+		}
+	}
+
+	delivery.mu.Lock()
+	defer delivery.mu.Unlock()
+	if got := strings.Join(delivery.delivered, ","); got != "alice@example.com,carol@example.com" {
+		t.Errorf("Delivered to %s", got)
+	}
+}
+
+func TestListenLMTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		wantErr bool
+	}{
+		{"loopback IPv4", "127.0.0.1:0", false},
+		{"localhost", "localhost:0", false},
+		{"unix socket", "unix:" + t.TempDir() + "/lmtp.sock", false},
+		{"wildcard address", "0.0.0.0:0", true},
+		{"missing port", "127.0.0.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := listenLMTP(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listenLMTP(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			}
+			if l != nil {
+				l.Close()
+			}
+		})
+	}
+}
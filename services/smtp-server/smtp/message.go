@@ -6,15 +6,16 @@ import (
 	"fmt"
 	"io"
 	"net/mail"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/oonrumail/smtp-server/arc"
 	"github.com/oonrumail/smtp-server/dkim"
 	"github.com/oonrumail/smtp-server/dmarc"
 	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/footer"
 	"github.com/oonrumail/smtp-server/spf"
 )
 
@@ -26,6 +27,9 @@ func (s *Session) processMessage(r io.Reader) error {
 	// Read message data
 	var buf bytes.Buffer
 	size, err := io.Copy(&buf, r)
+	if isSizeLimitError(err) {
+		return s.rejectSize(s.fromDomain, sizeStageData, size, s.sizeLimit)
+	}
 	if err != nil {
 		return &SMTPError{
 			Code:    451,
@@ -62,9 +66,14 @@ func (s *Session) processMessage(r io.Reader) error {
 	// Determine if this is an internal/trusted relay (skip inbound auth checks)
 	isTrustedRelay := s.authenticated || s.isTrustedNetwork()
 
+	// Internal senders may ask for a queue priority; the header is never
+	// passed on, whoever set it
+	priority := queuePriority(msg.Header, isTrustedRelay)
+	messageData = removeHeader(messageData, queuePriorityHeader)
+
 	// For incoming messages (not authenticated and not from trusted network), perform SPF/DKIM/DMARC checks
 	if !isTrustedRelay {
-		result, err := s.performAuthChecks(ctx, messageData)
+		result, err := s.performAuthChecks(ctx, messageData, headerFromDomain(msg.Header))
 		if err != nil {
 			s.logger.Error("Auth checks failed", zap.Error(err))
 		}
@@ -85,40 +94,62 @@ func (s *Session) processMessage(r io.Reader) error {
 			}
 		}
 
-		// Add Authentication-Results header
+		// Add our Authentication-Results header. Any that arrived with the
+		// message come from hops we don't trust, so rename them first.
 		authResults := s.buildAuthResultsHeader(result)
+		messageData = renameAuthResultsHeaders(messageData)
 		messageData = prependHeader(messageData, "Authentication-Results", authResults)
 	}
 
-	// For outbound messages (authenticated or from trusted network), sign with DKIM
+	// For outbound messages (authenticated or from trusted network), add the
+	// domain's footer and sign with DKIM, in that order so the signature
+	// covers the footer
 	if isTrustedRelay {
 		fromDomain := s.backend.server.domainCache.GetDomain(s.fromDomain)
+		if fromDomain != nil && fromDomain.Policies != nil {
+			messageData = s.addFooter(fromDomain, messageID, messageData)
+		}
 		if fromDomain != nil && fromDomain.DKIMVerified {
-			signedData, err := s.backend.server.dkimSigner.SignMessage(s.fromDomain, messageData, nil)
+			signConfig := dkimSignatureConfig(fromDomain)
+			signedData, err := s.backend.server.dkimSigner.SignMessage(s.fromDomain, messageData, signConfig)
 			if err != nil {
 				s.logger.Warn("Failed to sign message with DKIM", zap.Error(err))
 			} else {
 				messageData = signedData
+				if signConfig != nil && signConfig.SignBodyLength {
+					s.backend.server.metrics.DKIMBodyLength.WithLabelValues(s.fromDomain, "signed").Inc()
+				}
 			}
 		}
 	}
 
 	// Determine routing for each recipient
+	var inboundParseRecipients []string
 	var localRecipients []string
 	var externalRecipients []string
 
 	for _, rcpt := range s.recipients {
 		rcptDomain := extractDomain(rcpt)
-		if s.backend.server.domainCache.GetDomain(rcptDomain) != nil {
+		if s.backend.server.inboundParser.handles(rcptDomain) {
+			inboundParseRecipients = append(inboundParseRecipients, rcpt)
+		} else if s.backend.server.domainCache.GetDomain(rcptDomain) != nil {
 			localRecipients = append(localRecipients, rcpt)
 		} else {
 			externalRecipients = append(externalRecipients, rcpt)
 		}
 	}
 
+	// Inbound parse goes first: if it fails nothing has been queued, so the
+	// sender's retry doesn't duplicate mail for the other recipients
+	if len(inboundParseRecipients) > 0 {
+		if err := s.forwardInboundParse(ctx, messageID, messageData, inboundParseRecipients); err != nil {
+			return err
+		}
+	}
+
 	// Create messages for queue
 	if len(localRecipients) > 0 {
-		if err := s.queueLocalDelivery(ctx, messageID, messageData, localRecipients, subject); err != nil {
+		if err := s.queueLocalDelivery(ctx, messageID, messageData, localRecipients, subject, priority); err != nil {
 			s.logger.Error("Failed to queue local delivery", zap.Error(err))
 			return &SMTPError{
 				Code:    451,
@@ -128,7 +159,7 @@ func (s *Session) processMessage(r io.Reader) error {
 	}
 
 	if len(externalRecipients) > 0 {
-		if err := s.queueExternalDelivery(ctx, messageID, messageData, externalRecipients, subject); err != nil {
+		if err := s.queueExternalDelivery(ctx, messageID, messageData, externalRecipients, subject, priority); err != nil {
 			s.logger.Error("Failed to queue external delivery", zap.Error(err))
 			return &SMTPError{
 				Code:    451,
@@ -143,6 +174,7 @@ func (s *Session) processMessage(r io.Reader) error {
 
 	s.logger.Info("Message accepted",
 		zap.String("message_id", messageID),
+		zap.Int("inbound_parse_recipients", len(inboundParseRecipients)),
 		zap.Int("local_recipients", len(localRecipients)),
 		zap.Int("external_recipients", len(externalRecipients)),
 		zap.Duration("duration", duration))
@@ -150,11 +182,43 @@ func (s *Session) processMessage(r io.Reader) error {
 	return nil
 }
 
-func (s *Session) performAuthChecks(ctx context.Context, messageData []byte) (*AuthCheckResult, error) {
-	result := &AuthCheckResult{}
+// addFooter appends the domain's outbound footer to the message, if one is
+// configured and the message can take it
+func (s *Session) addFooter(d *domain.Domain, messageID string, messageData []byte) []byte {
+	f := footer.Footer{Text: d.Policies.FooterText, HTML: d.Policies.FooterHTML}
+	out, skip := footer.Apply(messageData, f)
+	if skip != "" {
+		if skip != footer.SkipNoFooter {
+			s.logger.Debug("Outbound footer not added",
+				zap.String("message_id", messageID),
+				zap.String("reason", skip))
+		}
+		return messageData
+	}
+	return out
+}
+
+// dkimSignatureConfig returns the DKIM signature settings for a domain's
+// policies, or nil for the defaults
+func dkimSignatureConfig(d *domain.Domain) *dkim.SignatureConfig {
+	if d.Policies == nil || !d.Policies.DKIMBodyLength {
+		return nil
+	}
+	config := dkim.DefaultSignatureConfig()
+	config.SignBodyLength = true
+	return config
+}
+
+func (s *Session) performAuthChecks(ctx context.Context, messageData []byte, headerFrom string) (*AuthCheckResult, error) {
+	// DMARC applies to the RFC 5322 From domain; fall back to the envelope
+	// sender when the header has no usable address
+	if headerFrom == "" {
+		headerFrom = s.fromDomain
+	}
+	result := &AuthCheckResult{HeaderFrom: headerFrom}
 
 	// SPF check
-	spfResult := s.backend.server.spfValidator.Check(ctx, s.clientIP, s.fromDomain, s.fromDomain)
+	spfResult := s.backend.server.spfValidator.Check(ctx, s.clientIP, s.fromDomain, s.heloName())
 	result.SPFResult = spfResult.Result
 	s.backend.server.metrics.SPFResults.WithLabelValues(s.fromDomain, string(spfResult.Result)).Inc()
 
@@ -173,61 +237,61 @@ func (s *Session) performAuthChecks(ctx context.Context, messageData []byte) (*A
 		} else {
 			s.backend.server.metrics.DKIMResults.WithLabelValues(s.fromDomain, "fail").Inc()
 		}
+		if dr.Valid && dr.BodyLength >= 0 {
+			// Content after the signed length is unsigned and could have
+			// been added by anyone
+			outcome := "verified"
+			if dr.UnsignedBodyBytes > 0 {
+				outcome = "verified_unsigned_content"
+				s.logger.Info("DKIM signature passed with unsigned content after l=",
+					zap.String("domain", dr.Domain),
+					zap.Int("signed_bytes", dr.BodyLength),
+					zap.Int("unsigned_bytes", dr.UnsignedBodyBytes))
+			}
+			s.backend.server.metrics.DKIMBodyLength.WithLabelValues(dr.Domain, outcome).Inc()
+		}
 	}
 	result.DKIMValid = dkimValid
 
 	// DMARC check
-	dmarcResult := s.backend.server.dmarcValidator.Check(ctx, s.fromDomain, s.clientIP, messageData)
+	dmarcResult := s.backend.server.dmarcValidator.Check(ctx, headerFrom, s.clientIP, messageData)
 	result.DMARCResult = dmarcResult
 	s.backend.server.metrics.DMARCResults.WithLabelValues(s.fromDomain, string(dmarcResult.Policy)).Inc()
 
 	result.Pass = dmarcResult.Pass
 	result.Disposition = dmarcResult.Disposition
 
+	// ARC check. The cv= result is recorded in Authentication-Results so that
+	// the chain can be continued if this message is forwarded.
+	arcResult, err := s.backend.server.arcVerifier.VerifyChain(messageData)
+	if err != nil {
+		s.logger.Warn("ARC verification error", zap.Error(err))
+	} else {
+		result.ARCResult = arcResult
+		s.backend.server.metrics.ARCResults.WithLabelValues(s.fromDomain, arcResult.Validation.ResultString()).Inc()
+		if arcResult.Error != nil {
+			s.logger.Debug("ARC chain did not validate",
+				zap.Int("sets", arcResult.TotalSets),
+				zap.Error(arcResult.Error))
+		}
+	}
+
+	arcValidation := arc.ChainValidationNone
+	if result.ARCResult != nil {
+		arcValidation = result.ARCResult.Validation
+	}
+
 	s.logger.Debug("Auth checks completed",
 		zap.String("spf", string(spfResult.Result)),
 		zap.Bool("dkim_valid", dkimValid),
 		zap.Bool("dmarc_pass", dmarcResult.Pass),
+		zap.String("arc", string(arcValidation)),
 		zap.String("disposition", dmarcResult.Disposition))
 
 	return result, nil
 }
 
-func (s *Session) buildAuthResultsHeader(result *AuthCheckResult) string {
-	hostname := s.backend.server.config.Server.Hostname
-	var parts []string
-	parts = append(parts, hostname)
-
-	if result != nil {
-		// SPF result
-		spfPart := fmt.Sprintf("spf=%s smtp.mailfrom=%s", result.SPFResult, s.from)
-		parts = append(parts, spfPart)
-
-		// DKIM result
-		for _, dr := range result.DKIMResults {
-			dkimResult := "fail"
-			if dr.Valid {
-				dkimResult = "pass"
-			}
-			dkimPart := fmt.Sprintf("dkim=%s header.d=%s header.s=%s", dkimResult, dr.Domain, dr.Selector)
-			parts = append(parts, dkimPart)
-		}
-
-		// DMARC result
-		if result.DMARCResult != nil {
-			dmarcResult := "fail"
-			if result.DMARCResult.Pass {
-				dmarcResult = "pass"
-			}
-			dmarcPart := fmt.Sprintf("dmarc=%s header.from=%s", dmarcResult, s.fromDomain)
-			parts = append(parts, dmarcPart)
-		}
-	}
-
-	return strings.Join(parts, "; ")
-}
-
-func (s *Session) queueLocalDelivery(ctx context.Context, messageID string, data []byte, recipients []string, subject string) error {
+func (s *Session) queueLocalDelivery(ctx context.Context, messageID string, data []byte, recipients []string, subject string, priority int) error {
 	// Group recipients by domain
 	byDomain := make(map[string][]string)
 	for _, rcpt := range recipients {
@@ -259,9 +323,10 @@ func (s *Session) queueLocalDelivery(ctx context.Context, messageID string, data
 			BodySize:       int64(len(data)),
 			RawMessagePath: msgPath,
 			Status:         domain.StatusPending,
-			Priority:       1,
+			Priority:       priority,
 			MaxRetries:     s.backend.server.config.Queue.MaxRetries,
 			CreatedAt:      time.Now(),
+			DSN:            s.dsnParamsFor(rcpts),
 		}
 
 		if err := s.backend.server.queueManager.Enqueue(ctx, msg); err != nil {
@@ -277,7 +342,7 @@ func (s *Session) queueLocalDelivery(ctx context.Context, messageID string, data
 	return nil
 }
 
-func (s *Session) queueExternalDelivery(ctx context.Context, messageID string, data []byte, recipients []string, subject string) error {
+func (s *Session) queueExternalDelivery(ctx context.Context, messageID string, data []byte, recipients []string, subject string, priority int) error {
 	// Group recipients by domain for efficient delivery
 	byDomain := make(map[string][]string)
 	for _, rcpt := range recipients {
@@ -309,9 +374,10 @@ func (s *Session) queueExternalDelivery(ctx context.Context, messageID string, d
 			BodySize:       int64(len(data)),
 			RawMessagePath: msgPath,
 			Status:         domain.StatusPending,
-			Priority:       1,
+			Priority:       priority,
 			MaxRetries:     s.backend.server.config.Queue.MaxRetries,
 			CreatedAt:      time.Now(),
+			DSN:            s.dsnParamsFor(rcpts),
 		}
 
 		// Store target domain in headers for routing
@@ -332,12 +398,14 @@ func (s *Session) queueExternalDelivery(ctx context.Context, messageID string, d
 	return nil
 }
 
-// AuthCheckResult holds the results of SPF/DKIM/DMARC checks
+// AuthCheckResult holds the results of SPF/DKIM/DMARC/ARC checks
 type AuthCheckResult struct {
 	SPFResult    spf.Result
+	HeaderFrom   string // RFC 5322 From domain, used for DMARC
 	DKIMResults  []*dkim.VerificationResult
 	DKIMValid    bool
 	DMARCResult  *dmarc.CheckResult
+	ARCResult    *arc.ChainResult
 	Pass         bool
 	Disposition  string
 }
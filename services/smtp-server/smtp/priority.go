@@ -0,0 +1,65 @@
+package smtp
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// queuePriorityHeader lets internal senders such as the transactional API
+// and the auth service choose the outbound queue priority of a message:
+// "high", "normal" or "low". It is removed before the message is queued.
+const queuePriorityHeader = "X-Queue-Priority"
+
+// queuePriority returns the queue priority a message asks for. Only
+// authenticated and trusted-network senders may choose one; everyone else
+// gets normal priority.
+func queuePriority(header mail.Header, trusted bool) int {
+	if !trusted {
+		return domain.PriorityNormal
+	}
+	priority, _ := domain.ParsePriority(header.Get(queuePriorityHeader))
+	return priority
+}
+
+// removeHeader removes every occurrence of a header, including folded
+// continuation lines, from the message header block
+func removeHeader(data []byte, name string) []byte {
+	prefix := name + ":"
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+
+	removing := false
+	rest := data
+	for len(rest) > 0 {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+
+		// The header block ends at the first empty line
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			out.Write(line)
+			out.Write(rest)
+			break
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if !removing {
+				out.Write(line)
+			}
+			continue
+		}
+
+		removing = len(line) >= len(prefix) && strings.EqualFold(string(line[:len(prefix)]), prefix)
+		if !removing {
+			out.Write(line)
+		}
+	}
+
+	return out.Bytes()
+}
@@ -0,0 +1,56 @@
+package smtp
+
+import (
+	"bytes"
+	"net/mail"
+	"testing"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+func TestQueuePriority(t *testing.T) {
+	tests := []struct {
+		value   string
+		trusted bool
+		want    int
+	}{
+		{"high", true, domain.PriorityHigh},
+		{" Low ", true, domain.PriorityLow},
+		{"bulk", true, domain.PriorityLow},
+		{"normal", true, domain.PriorityNormal},
+		{"urgent", true, domain.PriorityNormal},
+		{"", true, domain.PriorityNormal},
+		{"high", false, domain.PriorityNormal},
+	}
+
+	for _, tt := range tests {
+		header := mail.Header{}
+		if tt.value != "" {
+			header[queuePriorityHeader] = []string{tt.value}
+		}
+		if got := queuePriority(header, tt.trusted); got != tt.want {
+			t.Errorf("queuePriority(%q, %v) = %d, want %d", tt.value, tt.trusted, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveHeader(t *testing.T) {
+	data := []byte("From: a@example.com\r\n" +
+		"x-queue-priority: high\r\n" +
+		"Subject: Reset\r\n" +
+		"X-Queue-Priority: low,\r\n" +
+		"\tfolded\r\n" +
+		"To: b@example.com\r\n" +
+		"\r\n" +
+		"X-Queue-Priority: body text stays\r\n")
+
+	want := []byte("From: a@example.com\r\n" +
+		"Subject: Reset\r\n" +
+		"To: b@example.com\r\n" +
+		"\r\n" +
+		"X-Queue-Priority: body text stays\r\n")
+
+	if got := removeHeader(data, queuePriorityHeader); !bytes.Equal(got, want) {
+		t.Errorf("removeHeader() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,321 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+)
+
+// proxyV2Signature starts every PROXY protocol v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	proxyV2HeaderLen = 16 // Signature, version and command, family, address length
+
+	proxyCmdLocal = 0x0
+	proxyCmdProxy = 0x1
+
+	proxyFamUnspec = 0x0
+	proxyFamInet   = 0x1
+	proxyFamInet6  = 0x2
+
+	proxyProtoStream = 0x1
+)
+
+var (
+	errProxyHeaderUntrusted = errors.New("PROXY header from untrusted source")
+	errProxyHeaderMissing   = errors.New("missing PROXY protocol v2 header")
+)
+
+// proxyHeaderError is a PROXY header that couldn't be accepted, with the
+// result it is counted under
+type proxyHeaderError struct {
+	result string
+	err    error
+}
+
+func (e *proxyHeaderError) Error() string { return e.err.Error() }
+
+func (e *proxyHeaderError) Unwrap() error { return e.err }
+
+// proxyListener reads PROXY protocol v2 headers (the haproxy spec) from
+// connections accepted from trusted load balancers, so the session sees
+// the client's address instead of the balancer's. Connections from other
+// sources are passed through, but rejected if they send a PROXY header.
+type proxyListener struct {
+	net.Listener
+	trusted       []*net.IPNet
+	headerTimeout time.Duration
+	readTimeout   time.Duration
+	results       *prometheus.CounterVec
+	logger        *zap.Logger
+}
+
+// newProxyListener wraps l to read PROXY headers from cfg.TrustedProxies.
+// readTimeout is the server's, restored once the header has been read.
+func newProxyListener(l net.Listener, trusted []*net.IPNet, cfg *config.ProxyProtocolConfig, readTimeout time.Duration, results *prometheus.CounterVec, logger *zap.Logger) *proxyListener {
+	return &proxyListener{
+		Listener:      l,
+		trusted:       trusted,
+		headerTimeout: cfg.HeaderTimeout,
+		readTimeout:   readTimeout,
+		results:       results,
+		logger:        logger,
+	}
+}
+
+// parseTrustedProxies parses the trusted proxy CIDR networks. A bare IP is
+// taken as a single-address network.
+func parseTrustedProxies(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if ip := net.ParseIP(cidr); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Accept returns the next connection. Its header is read on first use, in
+// the connection's own goroutine, so a slow proxy can't hold up others.
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{
+		Conn:       c,
+		listener:   l,
+		reader:     bufio.NewReader(c),
+		remoteAddr: c.RemoteAddr(),
+		localAddr:  c.LocalAddr(),
+	}, nil
+}
+
+// trusts reports whether addr is one of the trusted proxies
+func (l *proxyListener) trusts(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.trusted {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyConn is a connection whose addresses are those in its PROXY header
+type proxyConn struct {
+	net.Conn
+	listener *proxyListener
+	reader   *bufio.Reader
+
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+// init reads the PROXY header once, before the connection is first read or
+// its addresses are used
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.err = c.readHeader()
+
+		var headerErr *proxyHeaderError
+		if errors.As(c.err, &headerErr) {
+			c.listener.results.WithLabelValues(headerErr.result).Inc()
+			c.listener.logger.Warn("Rejected PROXY protocol connection",
+				zap.String("remote_addr", c.Conn.RemoteAddr().String()),
+				zap.Error(c.err))
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	return c.remoteAddr
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.init()
+	return c.localAddr
+}
+
+// readHeader reads the PROXY header of a connection from a trusted proxy
+// and rejects one from anywhere else
+func (c *proxyConn) readHeader() error {
+	if !c.listener.trusts(c.Conn.RemoteAddr()) {
+		found, err := c.hasSignature()
+		if err != nil {
+			return err
+		}
+		if found {
+			return &proxyHeaderError{result: "untrusted", err: errProxyHeaderUntrusted}
+		}
+		return nil
+	}
+
+	if c.listener.headerTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.listener.headerTimeout))
+	}
+
+	found, err := c.hasSignature()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return &proxyHeaderError{result: "missing", err: errProxyHeaderMissing}
+	}
+
+	header, err := readProxyHeader(c.reader)
+	if err != nil {
+		return &proxyHeaderError{result: "invalid", err: err}
+	}
+
+	if header.local {
+		c.listener.results.WithLabelValues("local").Inc()
+	} else {
+		c.listener.results.WithLabelValues("proxy").Inc()
+		c.listener.logger.Debug("PROXY protocol header",
+			zap.String("proxy_addr", c.remoteAddr.String()),
+			zap.String("client_addr", header.source.String()))
+		c.remoteAddr = header.source
+		c.localAddr = header.destination
+	}
+
+	// Hand the connection back with the server's read timeout, which the
+	// header timeout replaced
+	var deadline time.Time
+	if c.listener.readTimeout > 0 {
+		deadline = time.Now().Add(c.listener.readTimeout)
+	}
+	c.Conn.SetReadDeadline(deadline)
+
+	return nil
+}
+
+// hasSignature reports whether the connection starts with the PROXY v2
+// signature. It only waits for more bytes while those received so far
+// match, so an ordinary SMTP client is never held up.
+func (c *proxyConn) hasSignature() (bool, error) {
+	for n := 1; n <= len(proxyV2Signature); n++ {
+		b, err := c.reader.Peek(n)
+		if err != nil {
+			return false, err
+		}
+		if b[n-1] != proxyV2Signature[n-1] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// proxyHeader is a parsed PROXY v2 header. A LOCAL header, sent by the
+// proxy for its own health checks, carries no addresses.
+type proxyHeader struct {
+	local       bool
+	source      *net.TCPAddr
+	destination *net.TCPAddr
+}
+
+// readProxyHeader reads a PROXY v2 header, signature included. TLVs after
+// the addresses are skipped.
+func readProxyHeader(r io.Reader) (*proxyHeader, error) {
+	var fixed [proxyV2HeaderLen]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, fmt.Errorf("read PROXY header: %w", err)
+	}
+	if !bytes.Equal(fixed[:12], proxyV2Signature) {
+		return nil, errProxyHeaderMissing
+	}
+
+	if version := fixed[12] >> 4; version != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+	command := fixed[12] & 0x0f
+	family := fixed[13] >> 4
+	protocol := fixed[13] & 0x0f
+
+	body := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("read PROXY addresses: %w", err)
+	}
+
+	switch command {
+	case proxyCmdLocal:
+		return &proxyHeader{local: true}, nil
+	case proxyCmdProxy:
+	default:
+		return nil, fmt.Errorf("unknown PROXY command %#x", command)
+	}
+
+	// The proxy couldn't tell the client's address, so the connection's
+	// own is kept
+	if family == proxyFamUnspec {
+		return &proxyHeader{local: true}, nil
+	}
+	if protocol != proxyProtoStream {
+		return nil, fmt.Errorf("unsupported PROXY transport %#x", protocol)
+	}
+
+	var ipLen int
+	switch family {
+	case proxyFamInet:
+		ipLen = net.IPv4len
+	case proxyFamInet6:
+		ipLen = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unsupported PROXY address family %#x", family)
+	}
+	if len(body) < 2*ipLen+4 {
+		return nil, fmt.Errorf("PROXY address block too short: %d bytes", len(body))
+	}
+
+	return &proxyHeader{
+		source: &net.TCPAddr{
+			IP:   net.IP(body[:ipLen]),
+			Port: int(binary.BigEndian.Uint16(body[2*ipLen:])),
+		},
+		destination: &net.TCPAddr{
+			IP:   net.IP(body[ipLen : 2*ipLen]),
+			Port: int(binary.BigEndian.Uint16(body[2*ipLen+2:])),
+		},
+	}, nil
+}
@@ -0,0 +1,229 @@
+package smtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+)
+
+// proxyV2 builds a PROXY v2 header for a STREAM connection from src to dst.
+// A nil src makes a LOCAL header.
+func proxyV2(src, dst *net.TCPAddr) []byte {
+	var b bytes.Buffer
+	b.Write(proxyV2Signature)
+	if src == nil {
+		b.Write([]byte{0x20, 0x00, 0x00, 0x00})
+		return b.Bytes()
+	}
+
+	family, srcIP, dstIP := byte(proxyFamInet), src.IP.To4(), dst.IP.To4()
+	if srcIP == nil {
+		family, srcIP, dstIP = proxyFamInet6, src.IP.To16(), dst.IP.To16()
+	}
+
+	addrs := append(append([]byte{}, srcIP...), dstIP...)
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(src.Port))
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(dst.Port))
+	addrs = append(addrs, 0x04, 0x00, 0x01, 'x') // A TLV, which is skipped
+
+	b.Write([]byte{0x21, family<<4 | proxyProtoStream})
+	b.Write(binary.BigEndian.AppendUint16(nil, uint16(len(addrs))))
+	b.Write(addrs)
+	return b.Bytes()
+}
+
+// acceptProxied sends data to a proxy listener trusting trusted and returns
+// the accepted connection
+func acceptProxied(t *testing.T, trusted string, data []byte) net.Conn {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	networks, err := parseTrustedProxies([]string{trusted})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies() error = %v", err)
+	}
+	results := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_proxy_headers_total"}, []string{"result"})
+	pl := newProxyListener(l, networks, &config.ProxyProtocolConfig{HeaderTimeout: time.Second}, time.Second, results, zap.NewNop())
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readLine(t *testing.T, conn net.Conn) (string, error) {
+	t.Helper()
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	return string(buf[:n]), err
+}
+
+func TestProxyListenerRecoversClientAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		src  *net.TCPAddr
+		dst  *net.TCPAddr
+	}{
+		{
+			name: "tcp4",
+			src:  &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 40123},
+			dst:  &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 25},
+		},
+		{
+			name: "tcp6",
+			src:  &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 40123},
+			dst:  &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 587},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := acceptProxied(t, "127.0.0.0/8", append(proxyV2(tt.src, tt.dst), "EHLO client\r\n"...))
+
+			line, err := readLine(t, conn)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if line != "EHLO client\r\n" {
+				t.Errorf("Read() = %q, want the data after the header", line)
+			}
+
+			remote, ok := conn.RemoteAddr().(*net.TCPAddr)
+			if !ok || !remote.IP.Equal(tt.src.IP) || remote.Port != tt.src.Port {
+				t.Errorf("RemoteAddr() = %v, want %v", conn.RemoteAddr(), tt.src)
+			}
+			local, ok := conn.LocalAddr().(*net.TCPAddr)
+			if !ok || !local.IP.Equal(tt.dst.IP) || local.Port != tt.dst.Port {
+				t.Errorf("LocalAddr() = %v, want %v", conn.LocalAddr(), tt.dst)
+			}
+		})
+	}
+}
+
+func TestProxyListenerLocalKeepsConnectionAddress(t *testing.T) {
+	conn := acceptProxied(t, "127.0.0.1", append(proxyV2(nil, nil), "QUIT\r\n"...))
+
+	if line, err := readLine(t, conn); err != nil || line != "QUIT\r\n" {
+		t.Fatalf("Read() = %q, %v, want the data after the header", line, err)
+	}
+	if remote := conn.RemoteAddr().(*net.TCPAddr); !remote.IP.IsLoopback() {
+		t.Errorf("RemoteAddr() = %v, want the proxy's own address", remote)
+	}
+}
+
+func TestProxyListenerRejections(t *testing.T) {
+	header := proxyV2(
+		&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 40123},
+		&net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 25},
+	)
+
+	tests := []struct {
+		name    string
+		trusted string
+		data    []byte
+		want    error
+	}{
+		{"header from untrusted source", "10.0.0.0/8", append(header, "EHLO client\r\n"...), errProxyHeaderUntrusted},
+		{"trusted proxy without header", "127.0.0.0/8", []byte("EHLO client\r\n"), errProxyHeaderMissing},
+		{"version 1 header from trusted proxy", "127.0.0.0/8", []byte("PROXY TCP4 203.0.113.7 198.51.100.1 40123 25\r\n"), errProxyHeaderMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := acceptProxied(t, tt.trusted, tt.data)
+			if _, err := readLine(t, conn); !errors.Is(err, tt.want) {
+				t.Errorf("Read() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyListenerPassesDirectClients(t *testing.T) {
+	conn := acceptProxied(t, "10.0.0.0/8", []byte("EHLO client\r\n"))
+
+	if line, err := readLine(t, conn); err != nil || line != "EHLO client\r\n" {
+		t.Fatalf("Read() = %q, %v, want the client's data untouched", line, err)
+	}
+	if remote := conn.RemoteAddr().(*net.TCPAddr); !remote.IP.IsLoopback() {
+		t.Errorf("RemoteAddr() = %v, want the connection's own address", remote)
+	}
+}
+
+func TestReadProxyHeaderInvalid(t *testing.T) {
+	valid := proxyV2(
+		&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 40123},
+		&net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 25},
+	)
+
+	withByte := func(i int, b byte) []byte {
+		h := append([]byte{}, valid...)
+		h[i] = b
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{"truncated", valid[:20]},
+		{"version 1", withByte(12, 0x11)},
+		{"unknown command", withByte(12, 0x2f)},
+		{"datagram", withByte(13, proxyFamInet<<4|0x2)},
+		{"unix family", withByte(13, 0x3<<4|proxyProtoStream)},
+		{"short address block", withByte(15, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readProxyHeader(bytes.NewReader(tt.header)); err == nil {
+				t.Error("readProxyHeader() error = nil, want an error")
+			}
+		})
+	}
+
+	if _, err := readProxyHeader(io.MultiReader(bytes.NewReader(valid), bytes.NewReader([]byte("rest")))); err != nil {
+		t.Errorf("readProxyHeader() error = %v for a valid header", err)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks, err := parseTrustedProxies([]string{"10.0.0.0/8", " 192.0.2.10 ", "2001:db8::/32", ""})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies() error = %v", err)
+	}
+	if len(networks) != 3 {
+		t.Fatalf("parseTrustedProxies() = %v, want 3 networks", networks)
+	}
+	if !networks[1].Contains(net.ParseIP("192.0.2.10")) || networks[1].Contains(net.ParseIP("192.0.2.11")) {
+		t.Errorf("bare IP network = %v, want only 192.0.2.10", networks[1])
+	}
+
+	if _, err := parseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("parseTrustedProxies() error = nil for an invalid CIDR")
+	}
+}
@@ -17,11 +17,13 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 
+	"github.com/oonrumail/smtp-server/arc"
 	"github.com/oonrumail/smtp-server/auth"
 	"github.com/oonrumail/smtp-server/config"
 	"github.com/oonrumail/smtp-server/dkim"
 	"github.com/oonrumail/smtp-server/dmarc"
 	"github.com/oonrumail/smtp-server/domain"
+	"github.com/oonrumail/smtp-server/greylist"
 	"github.com/oonrumail/smtp-server/queue"
 	"github.com/oonrumail/smtp-server/spf"
 )
@@ -34,13 +36,18 @@ type Server struct {
 	dmarcValidator *dmarc.Validator
 	dkimSigner     *dkim.Signer
 	dkimVerifier   *dkim.Verifier
+	arcVerifier    *arc.Verifier
 	queueManager   *queue.Manager
 	authenticator  *auth.Authenticator
+	greylister     *greylist.Greylister
+	inboundParser  *inboundParser
 	logger         *zap.Logger
 	metrics        *Metrics
+	drainer        *drainer
 
 	smtpServer       *smtp.Server
 	submissionServer *smtp.Server
+	lmtpServer       *smtp.Server
 	tlsConfig        *tls.Config
 
 	mu      sync.RWMutex
@@ -63,11 +70,15 @@ func NewServer(
 
 	// Create authenticator with config
 	authConfig := &auth.Config{
-		MaxFailedAttempts: 5,
-		LockoutDuration:   15 * time.Minute,
-		RateLimitWindow:   15 * time.Minute,
+		MaxFailedAttempts:   5,
+		LockoutDuration:     15 * time.Minute,
+		RateLimitWindow:     15 * time.Minute,
+		MaxIPFailedAttempts: 15,
+		MaxLockoutDuration:  24 * time.Hour,
+		IPUsernameThreshold: 3,
 	}
 	authenticator := auth.NewAuthenticator(authRepo, redisClient, logger.Named("auth"), authConfig)
+	metrics := NewMetrics()
 
 	return &Server{
 		config:         cfg,
@@ -76,10 +87,14 @@ func NewServer(
 		dmarcValidator: dmarcValidator,
 		dkimSigner:     dkimSigner,
 		dkimVerifier:   dkimVerifier,
+		arcVerifier:    arc.NewVerifier(logger.Named("arc")),
 		queueManager:   queueManager,
 		authenticator:  authenticator,
+		greylister:     greylist.New(redisClient, &cfg.Greylist, logger.Named("greylist")),
+		inboundParser:  newInboundParser(&cfg.InboundParse),
 		logger:         logger,
-		metrics:        NewMetrics(),
+		metrics:        metrics,
+		drainer:        newDrainer(cfg.Server.Hostname, metrics, logger.Named("drain")),
 	}
 }
 
@@ -115,6 +130,13 @@ func (s *Server) Start(ctx context.Context) error {
 		return fmt.Errorf("start submission server: %w", err)
 	}
 
+	// Start LMTP server (local delivery from internal components)
+	if s.config.LMTP.Enabled {
+		if err := s.startLMTPServer(); err != nil {
+			return fmt.Errorf("start LMTP server: %w", err)
+		}
+	}
+
 	s.logger.Info("SMTP server started",
 		zap.String("smtp_addr", s.config.Server.SMTPAddr),
 		zap.String("submission_addr", s.config.Server.SubmissionAddr))
@@ -122,7 +144,10 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop stops the SMTP server
+// Stop stops the SMTP server, draining it: it stops accepting connections,
+// sends idle ones a 421, and lets transactions in progress finish until
+// ctx is done, closing each connection as its transaction ends. Connections
+// still in a transaction then are sent a 421 and closed.
 func (s *Server) Stop(ctx context.Context) error {
 	s.mu.Lock()
 	if !s.running {
@@ -132,17 +157,46 @@ func (s *Server) Stop(ctx context.Context) error {
 	s.running = false
 	s.mu.Unlock()
 
+	idle, active := s.drainer.start()
+	s.logger.Info("Draining SMTP server",
+		zap.Int("idle_connections", len(idle)),
+		zap.Int("active_transactions", active))
+
+	for _, c := range idle {
+		c.shutDown()
+	}
+
 	var errs []error
+	var errMu sync.Mutex
+	var wg sync.WaitGroup
+	for name, srv := range map[string]*smtp.Server{"SMTP": s.smtpServer, "submission": s.submissionServer} {
+		if srv == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Closes the listener and waits for the connections to close
+			if err := srv.Shutdown(ctx); err != nil && err != ctx.Err() {
+				errMu.Lock()
+				errs = append(errs, fmt.Errorf("close %s server: %w", name, err))
+				errMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
 
-	if s.smtpServer != nil {
-		if err := s.smtpServer.Close(); err != nil {
-			errs = append(errs, fmt.Errorf("close SMTP server: %w", err))
+	if stuck := s.drainer.remaining(); len(stuck) > 0 {
+		s.logger.Warn("Closing SMTP connections still open after the shutdown timeout",
+			zap.Int("connections", len(stuck)))
+		for _, c := range stuck {
+			c.shutDown()
 		}
 	}
 
-	if s.submissionServer != nil {
-		if err := s.submissionServer.Close(); err != nil {
-			errs = append(errs, fmt.Errorf("close submission server: %w", err))
+	if s.lmtpServer != nil {
+		if err := s.lmtpServer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close LMTP server: %w", err))
 		}
 	}
 
@@ -154,6 +208,16 @@ func (s *Server) Stop(ctx context.Context) error {
 	return nil
 }
 
+// Draining reports whether the server has begun shutting down
+func (s *Server) Draining() bool {
+	return s.drainer.isDraining()
+}
+
+// Metrics returns the server's Prometheus metrics
+func (s *Server) Metrics() *Metrics {
+	return s.metrics
+}
+
 func (s *Server) startSMTPServer(backend smtp.Backend) error {
 	s.smtpServer = smtp.NewServer(backend)
 	s.smtpServer.Addr = s.config.Server.SMTPAddr
@@ -163,6 +227,7 @@ func (s *Server) startSMTPServer(backend smtp.Backend) error {
 	s.smtpServer.MaxMessageBytes = int64(s.config.Server.MaxMessageSize)
 	s.smtpServer.MaxRecipients = s.config.Server.MaxRecipients
 	s.smtpServer.AllowInsecureAuth = false
+	s.smtpServer.EnableDSN = s.config.Server.EnableDSN
 	// Note: go-smtp v0.21+ uses EnableAuth = false to disable auth (opposite of AuthDisabled)
 	// For port 25 SMTP relay, we allow unauthenticated connections
 
@@ -171,9 +236,14 @@ func (s *Server) startSMTPServer(backend smtp.Backend) error {
 		s.smtpServer.EnableSMTPUTF8 = true
 	}
 
+	listener, err := s.listen(s.config.Server.SMTPAddr)
+	if err != nil {
+		return err
+	}
+
 	go func() {
 		s.logger.Info("Starting SMTP server", zap.String("addr", s.config.Server.SMTPAddr))
-		if err := s.smtpServer.ListenAndServe(); err != nil && err != smtp.ErrServerClosed {
+		if err := s.smtpServer.Serve(listener); err != nil && err != smtp.ErrServerClosed {
 			s.logger.Error("SMTP server error", zap.Error(err))
 		}
 	}()
@@ -187,9 +257,15 @@ func (s *Server) startSubmissionServer(backend smtp.Backend) error {
 	s.submissionServer.Domain = s.config.Server.Hostname
 	s.submissionServer.ReadTimeout = s.config.Server.ReadTimeout
 	s.submissionServer.WriteTimeout = s.config.Server.WriteTimeout
-	s.submissionServer.MaxMessageBytes = int64(s.config.Server.MaxMessageSize)
+	// Advertise the largest limit any domain may have; each sender's own
+	// limit is enforced per session
+	s.submissionServer.MaxMessageBytes = s.config.Server.SubmissionMaxMessageSize
+	if s.submissionServer.MaxMessageBytes <= 0 {
+		s.submissionServer.MaxMessageBytes = s.config.Server.MaxMessageSize
+	}
 	s.submissionServer.MaxRecipients = s.config.Server.MaxRecipients
 	s.submissionServer.AllowInsecureAuth = false
+	s.submissionServer.EnableDSN = s.config.Server.EnableDSN
 	// Note: go-smtp v0.21+ uses EnableAuth=true by default, auth is required on submission
 
 	if s.tlsConfig != nil {
@@ -197,9 +273,14 @@ func (s *Server) startSubmissionServer(backend smtp.Backend) error {
 		s.submissionServer.EnableSMTPUTF8 = true
 	}
 
+	listener, err := s.listen(s.config.Server.SubmissionAddr)
+	if err != nil {
+		return err
+	}
+
 	go func() {
 		s.logger.Info("Starting submission server", zap.String("addr", s.config.Server.SubmissionAddr))
-		if err := s.submissionServer.ListenAndServe(); err != nil && err != smtp.ErrServerClosed {
+		if err := s.submissionServer.Serve(listener); err != nil && err != smtp.ErrServerClosed {
 			s.logger.Error("Submission server error", zap.Error(err))
 		}
 	}()
@@ -207,6 +288,59 @@ func (s *Server) startSubmissionServer(backend smtp.Backend) error {
 	return nil
 }
 
+// listen opens the listener for the SMTP or submission server, reading
+// PROXY protocol headers from trusted load balancers when enabled. Its
+// connections are tracked for draining on shutdown.
+func (s *Server) listen(addr string) (net.Listener, error) {
+	cfg := &s.config.ProxyProtocol
+
+	var trusted []*net.IPNet
+	if cfg.Enabled {
+		var err error
+		if trusted, err = parseTrustedProxies(cfg.TrustedProxies); err != nil {
+			return nil, err
+		}
+		if len(trusted) == 0 {
+			return nil, fmt.Errorf("proxy protocol enabled without trusted proxies")
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Enabled {
+		listener = newProxyListener(listener, trusted, cfg, s.config.Server.ReadTimeout, s.metrics.ProxyHeaders, s.logger.Named("proxy"))
+	}
+	return s.drainer.listener(listener), nil
+}
+
+func (s *Server) startLMTPServer() error {
+	listener, err := listenLMTP(s.config.LMTP.Addr)
+	if err != nil {
+		return err
+	}
+
+	backend := NewLMTPBackend(s.config, s.domainCache, s.queueManager, s.metrics, s.logger.Named("lmtp"))
+
+	s.lmtpServer = smtp.NewServer(backend)
+	s.lmtpServer.LMTP = true
+	s.lmtpServer.Domain = s.config.Server.Hostname
+	s.lmtpServer.ReadTimeout = s.config.Server.ReadTimeout
+	s.lmtpServer.WriteTimeout = s.config.Server.WriteTimeout
+	s.lmtpServer.MaxMessageBytes = int64(s.config.Server.MaxMessageSize)
+	s.lmtpServer.MaxRecipients = s.config.Server.MaxRecipients
+
+	go func() {
+		s.logger.Info("Starting LMTP server", zap.String("addr", s.config.LMTP.Addr))
+		if err := s.lmtpServer.Serve(listener); err != nil && err != smtp.ErrServerClosed {
+			s.logger.Error("LMTP server error", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
 func (s *Server) loadTLSConfig() (*tls.Config, error) {
 	cert, err := tls.LoadX509KeyPair(s.config.TLS.CertFile, s.config.TLS.KeyFile)
 	if err != nil {
@@ -257,9 +391,15 @@ func (b *Backend) NewSession(c *smtp.Conn) (smtp.Session, error) {
 	// Check TLS state - TLSConnectionState returns (state, ok) in newer versions
 	_, isTLS := c.TLSConnectionState()
 
+	drain, ok := b.server.drainer.attach(c.Conn())
+	if drain != nil && !ok {
+		return nil, errShuttingDown
+	}
+
 	session := &Session{
 		backend:   b,
 		conn:      c,
+		drain:     drain,
 		clientIP:  clientIP,
 		logger:    b.server.logger.With(zap.String("client_ip", clientIP.String())),
 		startTime: time.Now(),
@@ -285,6 +425,7 @@ type Session struct {
 	logger      *zap.Logger
 	startTime   time.Time
 	isTLS       bool
+	drain       *drainConn // Tracks the transaction for draining on shutdown
 
 	// Authentication state
 	authenticated bool
@@ -297,14 +438,21 @@ type Session struct {
 	fromDomain  string
 	recipients  []string
 	recipientDomains map[string]bool
+	dsn         *domain.DSNParams
+	spfPass     *bool // SPF result for the current sender, checked lazily for greylisting
+	sizeLimit   int64 // Largest message the current sender may send, 0 for no limit
 }
 
 // Reset resets the session state
 func (s *Session) Reset() {
+	s.drain.end()
 	s.from = ""
 	s.fromDomain = ""
 	s.recipients = nil
 	s.recipientDomains = make(map[string]bool)
+	s.dsn = nil
+	s.spfPass = nil
+	s.sizeLimit = 0
 }
 
 // isTrustedNetwork checks if the client IP is from a trusted network for relay
@@ -331,6 +479,7 @@ func (s *Session) isTrustedNetwork() bool {
 
 // Logout is called when the client logs out
 func (s *Session) Logout() error {
+	s.drain.detach(s.conn.Conn())
 	duration := time.Since(s.startTime)
 	s.backend.server.metrics.ConnectionsActive.Dec()
 	s.backend.server.metrics.SessionDuration.Observe(duration.Seconds())
@@ -385,11 +534,15 @@ func (s *Session) authenticatePlain(identity, username, password string) error {
 	authenticator := s.backend.server.authenticator
 
 	result, err := authenticator.AuthenticatePlainCredentials(ctx, username, password, s.clientIP, s.isTLS)
+	s.backend.server.metrics.AuthAttempts.WithLabelValues("PLAIN", authResultLabel(err)).Inc()
 	if err != nil {
 		s.logger.Warn("PLAIN authentication failed",
 			zap.String("client_ip", s.clientIP.String()),
 			zap.String("username", username),
 			zap.Error(err))
+		if errors.Is(err, auth.ErrRateLimited) {
+			return authErrorToSMTP(err)
+		}
 		return smtp.ErrAuthFailed
 	}
 
@@ -410,11 +563,15 @@ func (s *Session) authenticateLogin(username, password string) error {
 	authenticator := s.backend.server.authenticator
 
 	result, err := authenticator.AuthenticatePlainCredentials(ctx, username, password, s.clientIP, s.isTLS)
+	s.backend.server.metrics.AuthAttempts.WithLabelValues("LOGIN", authResultLabel(err)).Inc()
 	if err != nil {
 		s.logger.Warn("LOGIN authentication failed",
 			zap.String("client_ip", s.clientIP.String()),
 			zap.String("username", username),
 			zap.Error(err))
+		if errors.Is(err, auth.ErrRateLimited) {
+			return authErrorToSMTP(err)
+		}
 		return smtp.ErrAuthFailed
 	}
 
@@ -439,10 +596,11 @@ func authErrorToSMTP(err error) error {
 			Message:      "Authentication credentials invalid",
 		}
 	case errors.Is(err, auth.ErrRateLimited):
+		// Not 421, which would make clients drop the connection
 		return &smtp.SMTPError{
-			Code:         421,
+			Code:         454,
 			EnhancedCode: smtp.EnhancedCode{4, 7, 0},
-			Message:      "Too many failed authentication attempts. Please try again later.",
+			Message:      "Temporary authentication failure",
 		}
 	case errors.Is(err, auth.ErrTLSRequired):
 		return &smtp.SMTPError{
@@ -477,6 +635,20 @@ func authErrorToSMTP(err error) error {
 	}
 }
 
+// authResultLabel returns the smtp_auth_attempts_total result for an
+// authentication error
+func authResultLabel(err error) string {
+	var blocked *auth.BlockedError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &blocked):
+		return "blocked_" + blocked.Scope
+	default:
+		return "failure"
+	}
+}
+
 // maskEmailForLog masks email for logging
 func maskEmailForLog(email string) string {
 	parts := strings.Split(email, "@")
@@ -548,9 +720,29 @@ func (s *Session) Mail(from string, opts *smtp.MailOptions) error {
 		}
 	}
 
+	// Reject a declared SIZE over the sender's limit before any data is sent
+	sizeLimit := s.messageSizeLimit(domainName)
+	if opts != nil && sizeLimit > 0 && opts.Size > sizeLimit {
+		return s.rejectSize(domainName, sizeStageMailFrom, opts.Size, sizeLimit)
+	}
+
+	// No new transactions while draining
+	if !s.drain.begin() {
+		return errShuttingDown
+	}
+
 	s.from = from
 	s.fromDomain = domainName
+	s.sizeLimit = sizeLimit
 	s.recipientDomains = make(map[string]bool)
+	s.dsn = nil
+	s.spfPass = nil
+	if opts != nil && (opts.Return != "" || opts.EnvelopeID != "") {
+		s.dsn = &domain.DSNParams{
+			Return:     string(opts.Return),
+			EnvelopeID: opts.EnvelopeID,
+		}
+	}
 
 	s.logger.Debug("MAIL FROM accepted", zap.String("from", from))
 	s.backend.server.metrics.MessagesReceived.WithLabelValues(domainName).Inc()
@@ -568,6 +760,16 @@ func (s *Session) Rcpt(to string, opts *smtp.RcptOptions) error {
 		}
 	}
 
+	// Mail for inbound parse domains has no mailbox; the transactional API
+	// decides at DATA whether a route takes it
+	if s.backend.server.inboundParser.handles(domainName) {
+		s.recipients = append(s.recipients, to)
+		s.recipientDomains[domainName] = true
+
+		s.logger.Debug("RCPT TO accepted for inbound parse", zap.String("to", to))
+		return nil
+	}
+
 	// Check if domain is local
 	domain := s.backend.server.domainCache.GetDomain(domainName)
 
@@ -589,6 +791,10 @@ func (s *Session) Rcpt(to string, opts *smtp.RcptOptions) error {
 				Message: fmt.Sprintf("Recipient %s not found", to),
 			}
 		}
+
+		if err := s.checkGreylist(ctx, to, domain); err != nil {
+			return err
+		}
 	} else {
 		// External delivery - only allowed for authenticated sessions or trusted networks
 		if !s.authenticated && !s.isTrustedNetwork() {
@@ -610,6 +816,7 @@ func (s *Session) Rcpt(to string, opts *smtp.RcptOptions) error {
 
 	s.recipients = append(s.recipients, to)
 	s.recipientDomains[domainName] = true
+	s.addRecipientDSN(to, opts)
 
 	s.logger.Debug("RCPT TO accepted",
 		zap.String("to", to),
@@ -618,6 +825,103 @@ func (s *Session) Rcpt(to string, opts *smtp.RcptOptions) error {
 	return nil
 }
 
+// checkGreylist defers unauthenticated inbound mail from senders that haven't
+// retried yet. Lookup errors fail open so a Redis outage doesn't block mail.
+func (s *Session) checkGreylist(ctx context.Context, to string, dom *domain.Domain) error {
+	server := s.backend.server
+	cfg := server.config.Greylist
+
+	policyEnabled := dom.Policies != nil && dom.Policies.GreylistingEnabled
+	if !cfg.Enabled && !policyEnabled {
+		return nil
+	}
+	if s.authenticated || s.isTrustedNetwork() {
+		return nil
+	}
+
+	if cfg.BypassSPFPass && !server.greylister.Allowlisted(s.clientIP, s.from) && s.senderPassesSPF(ctx) {
+		server.metrics.GreylistResults.WithLabelValues(string(greylist.ResultBypassed)).Inc()
+		return nil
+	}
+
+	result, err := server.greylister.Check(ctx, s.clientIP, s.from, to)
+	if err != nil {
+		s.logger.Error("Greylist check failed", zap.Error(err))
+		return nil
+	}
+	server.metrics.GreylistResults.WithLabelValues(string(result)).Inc()
+
+	if result == greylist.ResultGreylisted {
+		s.logger.Info("Recipient greylisted",
+			zap.String("from", s.from),
+			zap.String("to", to))
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 7, 1},
+			Message:      "Greylisted, please try again later",
+		}
+	}
+
+	return nil
+}
+
+// senderPassesSPF reports whether the client is authorized by the sender
+// domain's SPF record. The result is cached for the rest of the transaction.
+func (s *Session) senderPassesSPF(ctx context.Context) bool {
+	if s.spfPass == nil {
+		helo := ""
+		if s.conn != nil {
+			helo = s.conn.Hostname()
+		}
+		result := s.backend.server.spfValidator.Check(ctx, s.clientIP, s.fromDomain, helo)
+		pass := result.Result == spf.ResultPass
+		s.spfPass = &pass
+	}
+	return *s.spfPass
+}
+
+// addRecipientDSN records the NOTIFY and ORCPT parameters given for a recipient
+func (s *Session) addRecipientDSN(to string, opts *smtp.RcptOptions) {
+	if opts == nil || (len(opts.Notify) == 0 && opts.OriginalRecipient == "") {
+		return
+	}
+
+	if s.dsn == nil {
+		s.dsn = &domain.DSNParams{}
+	}
+	if s.dsn.Recipients == nil {
+		s.dsn.Recipients = make(map[string]*domain.RecipientDSN)
+	}
+
+	rcpt := &domain.RecipientDSN{OriginalRecipient: opts.OriginalRecipient}
+	for _, n := range opts.Notify {
+		rcpt.Notify = append(rcpt.Notify, string(n))
+	}
+	s.dsn.Recipients[to] = rcpt
+}
+
+// dsnParamsFor returns the DSN parameters that apply to a subset of the session recipients
+func (s *Session) dsnParamsFor(recipients []string) *domain.DSNParams {
+	if s.dsn == nil {
+		return nil
+	}
+
+	params := &domain.DSNParams{
+		Return:     s.dsn.Return,
+		EnvelopeID: s.dsn.EnvelopeID,
+	}
+	for _, rcpt := range recipients {
+		if r, ok := s.dsn.Recipients[rcpt]; ok {
+			if params.Recipients == nil {
+				params.Recipients = make(map[string]*domain.RecipientDSN)
+			}
+			params.Recipients[rcpt] = r
+		}
+	}
+
+	return params
+}
+
 func (s *Session) lookupRecipient(ctx context.Context, email string, dom *domain.Domain) (*domain.RecipientLookupResult, error) {
 	result := &domain.RecipientLookupResult{
 		Domain: dom,
@@ -666,11 +970,19 @@ func (s *Session) lookupRecipient(ctx context.Context, email string, dom *domain
 		return result, nil
 	}
 
-	// Check catch-all
-	if dom.Policies.CatchAllEnabled && dom.Policies.CatchAllAddress != "" {
+	// Check catch-all rules
+	localPart := email
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		localPart = email[:at]
+	}
+	dest, err := s.backend.server.domainCache.CatchAllDestination(ctx, dom, localPart)
+	if err != nil {
+		return nil, err
+	}
+	if dest != "" {
 		result.Found = true
 		result.Type = "catch_all"
-		result.FinalRecipients = []string{dom.Policies.CatchAllAddress}
+		result.FinalRecipients = []string{dest}
 		return result, nil
 	}
 
@@ -680,7 +992,7 @@ func (s *Session) lookupRecipient(ctx context.Context, email string, dom *domain
 // Data handles the DATA command
 func (s *Session) Data(r io.Reader) error {
 	// Implementation continues in message.go
-	return s.processMessage(r)
+	return s.processMessage(s.limitMessageSize(r))
 }
 
 func extractDomain(email string) string {
@@ -703,8 +1015,17 @@ type Metrics struct {
 	DeliveryDuration  *prometheus.HistogramVec
 	SPFResults        *prometheus.CounterVec
 	DKIMResults       *prometheus.CounterVec
+	DKIMBodyLength    *prometheus.CounterVec
 	DMARCResults      *prometheus.CounterVec
+	ARCResults        *prometheus.CounterVec
 	QueueSize         *prometheus.GaugeVec
+	GreylistResults   *prometheus.CounterVec
+	AuthAttempts      *prometheus.CounterVec
+	SizeRejections    *prometheus.CounterVec
+	InboundParse      *prometheus.CounterVec
+	ProxyHeaders      *prometheus.CounterVec
+	Draining           prometheus.Gauge
+	TransactionsActive prometheus.Gauge
 }
 
 // NewMetrics creates new Prometheus metrics
@@ -753,14 +1074,50 @@ func NewMetrics() *Metrics {
 			Name: "smtp_dkim_results_total",
 			Help: "DKIM verification results",
 		}, []string{"domain", "result"}),
+		DKIMBodyLength: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "smtp_dkim_body_length_total",
+			Help: "DKIM signatures with a body length (l=) tag: signed outbound, verified inbound, and verified_unsigned_content when content follows the signed length",
+		}, []string{"domain", "result"}),
 		DMARCResults: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "smtp_dmarc_results_total",
 			Help: "DMARC check results",
 		}, []string{"domain", "result"}),
+		ARCResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "smtp_arc_results_total",
+			Help: "ARC chain validation results",
+		}, []string{"domain", "result"}),
 		QueueSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "smtp_queue_size",
 			Help: "Current queue size by domain and status",
 		}, []string{"domain", "status"}),
+		GreylistResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "smtp_greylist_results_total",
+			Help: "Greylist decisions for inbound recipients",
+		}, []string{"result"}),
+		AuthAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "smtp_auth_attempts_total",
+			Help: "SMTP AUTH attempts by mechanism and result (success, failure, blocked_username, blocked_ip)",
+		}, []string{"mechanism", "result"}),
+		SizeRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "smtp_size_rejections_total",
+			Help: "Messages rejected for exceeding the size limit, by sender domain and stage (mail_from, data)",
+		}, []string{"domain", "stage"}),
+		InboundParse: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "smtp_inbound_parse_total",
+			Help: "Messages handed to inbound parse, by result (accepted, no_route, too_large, invalid, failed)",
+		}, []string{"result"}),
+		ProxyHeaders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "smtp_proxy_headers_total",
+			Help: "PROXY protocol headers by result (proxy, local, untrusted, missing, invalid)",
+		}, []string{"result"}),
+		Draining: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "smtp_draining",
+			Help: "1 once the server has begun draining connections for shutdown",
+		}),
+		TransactionsActive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "smtp_transactions_active",
+			Help: "Number of SMTP transactions (MAIL FROM to end of DATA) in progress",
+		}),
 	}
 }
 
@@ -777,7 +1134,16 @@ func (m *Metrics) Register(registry prometheus.Registerer) {
 		m.DeliveryDuration,
 		m.SPFResults,
 		m.DKIMResults,
+		m.DKIMBodyLength,
 		m.DMARCResults,
+		m.ARCResults,
 		m.QueueSize,
+		m.GreylistResults,
+		m.AuthAttempts,
+		m.SizeRejections,
+		m.InboundParse,
+		m.ProxyHeaders,
+		m.Draining,
+		m.TransactionsActive,
 	)
 }
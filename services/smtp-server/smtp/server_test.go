@@ -148,9 +148,15 @@ func TestMetrics_NewMetrics(t *testing.T) {
 	if metrics.DKIMResults == nil {
 		t.Error("DKIMResults metric not initialized")
 	}
+	if metrics.DKIMBodyLength == nil {
+		t.Error("DKIMBodyLength metric not initialized")
+	}
 	if metrics.DMARCResults == nil {
 		t.Error("DMARCResults metric not initialized")
 	}
+	if metrics.ARCResults == nil {
+		t.Error("ARCResults metric not initialized")
+	}
 	if metrics.QueueSize == nil {
 		t.Error("QueueSize metric not initialized")
 	}
@@ -0,0 +1,93 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/emersion/go-smtp"
+	"go.uber.org/zap"
+)
+
+// Stages at which an oversize message is rejected, reported in the
+// smtp_size_rejections_total metric
+const (
+	sizeStageMailFrom = "mail_from"
+	sizeStageData     = "data"
+)
+
+// errSizeLimitExceeded is returned by a sizeLimitReader once more than its
+// limit has been read
+var errSizeLimitExceeded = errors.New("message size limit exceeded")
+
+// sizeLimitReader reads at most n bytes and fails on the byte after, so an
+// oversize transmission is aborted without buffering the rest of it
+type sizeLimitReader struct {
+	r io.Reader
+	n int64 // Bytes left before the limit
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errSizeLimitExceeded
+	}
+	// Read one byte past the limit to tell an exact fit from an overrun
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errSizeLimitExceeded
+	}
+	return n, err
+}
+
+// messageSizeLimit returns the largest message the session may send from
+// fromDomain. An authenticated sender gets its domain's limit, anyone else
+// the server's, and neither may exceed what the listener accepts.
+func (s *Session) messageSizeLimit(fromDomain string) int64 {
+	limit := s.backend.server.config.Server.MaxMessageSize
+	if s.authenticated {
+		if dom := s.backend.server.domainCache.GetDomain(fromDomain); dom != nil && dom.Policies != nil && dom.Policies.MaxMessageSize > 0 {
+			limit = dom.Policies.MaxMessageSize
+		}
+	}
+
+	if s.conn != nil {
+		if max := s.conn.Server().MaxMessageBytes; max > 0 && (limit <= 0 || limit > max) {
+			limit = max
+		}
+	}
+	return limit
+}
+
+// limitMessageSize wraps the DATA reader to enforce the session's limit
+func (s *Session) limitMessageSize(r io.Reader) io.Reader {
+	if s.sizeLimit <= 0 {
+		return r
+	}
+	return &sizeLimitReader{r: r, n: s.sizeLimit}
+}
+
+// isSizeLimitError reports whether reading a message failed because it
+// exceeded the session's limit or the listener's
+func isSizeLimitError(err error) bool {
+	return errors.Is(err, errSizeLimitExceeded) || errors.Is(err, smtp.ErrDataTooLarge)
+}
+
+// rejectSize records a size rejection and returns the 552 reply for it
+func (s *Session) rejectSize(fromDomain, stage string, size, limit int64) error {
+	s.backend.server.metrics.SizeRejections.WithLabelValues(fromDomain, stage).Inc()
+	s.logger.Info("Message rejected for size",
+		zap.String("from_domain", fromDomain),
+		zap.String("stage", stage),
+		zap.Int64("size", size),
+		zap.Int64("limit", limit))
+
+	return &smtp.SMTPError{
+		Code:         552,
+		EnhancedCode: smtp.EnhancedCode{5, 3, 4},
+		Message:      fmt.Sprintf("Message size exceeds fixed maximum message size of %d bytes", limit),
+	}
+}
@@ -0,0 +1,49 @@
+package smtp
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSizeLimitReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		limit   int64
+		wantErr bool
+	}{
+		{"under limit", "hello", 10, false},
+		{"exact fit", "hello", 5, false},
+		{"one byte over", "hello!", 5, true},
+		{"far over", strings.Repeat("x", 10000), 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := io.Copy(io.Discard, &sizeLimitReader{r: strings.NewReader(tt.data), n: tt.limit})
+			if tt.wantErr {
+				if !errors.Is(err, errSizeLimitExceeded) {
+					t.Fatalf("expected errSizeLimitExceeded, got %v", err)
+				}
+				if n > tt.limit+1 {
+					t.Errorf("read %d bytes, want at most %d", n, tt.limit+1)
+				}
+				return
+			}
+			if err != nil || n != int64(len(tt.data)) {
+				t.Errorf("io.Copy() = %d, %v, want %d, nil", n, err, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestIsSizeLimitError(t *testing.T) {
+	if !isSizeLimitError(errSizeLimitExceeded) {
+		t.Error("errSizeLimitExceeded not recognised")
+	}
+	if isSizeLimitError(io.ErrUnexpectedEOF) {
+		t.Error("io.ErrUnexpectedEOF recognised as a size error")
+	}
+}
@@ -70,6 +70,7 @@ func (m *MockRedisClient) LPush(ctx context.Context, key string, values ...inter
 type MockDomainProvider struct {
 	domains   map[string]*domain.Domain
 	domainsID map[string]*domain.Domain
+	catchAll  map[string][]*domain.CatchAllRule
 	mu        sync.RWMutex
 }
 
@@ -78,6 +79,7 @@ func NewMockDomainProvider() *MockDomainProvider {
 	return &MockDomainProvider{
 		domains:   make(map[string]*domain.Domain),
 		domainsID: make(map[string]*domain.Domain),
+		catchAll:  make(map[string][]*domain.CatchAllRule),
 	}
 }
 
@@ -103,6 +105,25 @@ func (m *MockDomainProvider) GetDomainByID(id string) *domain.Domain {
 	return m.domainsID[id]
 }
 
+// AddCatchAllRule compiles a catch-all rule and appends it to its domain's
+// rules
+func (m *MockDomainProvider) AddCatchAllRule(rule *domain.CatchAllRule) error {
+	if err := rule.Compile(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.catchAll[rule.DomainID] = append(m.catchAll[rule.DomainID], rule)
+	return nil
+}
+
+// CatchAllDestination resolves catch-all routing for a local part of a domain
+func (m *MockDomainProvider) CatchAllDestination(ctx context.Context, d *domain.Domain, localPart string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return domain.ResolveCatchAll(m.catchAll[d.ID], d.Policies, localPart), nil
+}
+
 // MockMessageRepository implements message repository for testing
 type MockMessageRepository struct {
 	messages  map[string]*domain.Message
@@ -284,7 +305,7 @@ func (m *MockMessageRepository) UpdateMailboxUsage(ctx context.Context, mailboxI
 }
 
 // RecordMailboxMessage records a message in a mailbox
-func (m *MockMessageRepository) RecordMailboxMessage(ctx context.Context, mailboxID string, msg *domain.Message, storagePath string, size int64) error {
+func (m *MockMessageRepository) RecordMailboxMessage(ctx context.Context, mailboxID, folder string, msg *domain.Message, storagePath string, size int64) error {
 	return nil
 }
 
@@ -0,0 +1,235 @@
+package vacation
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/oonrumail/smtp-server/config"
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// maxReplyIntervalDays bounds how long a sender can go unanswered
+const maxReplyIntervalDays = 365
+
+// Store persists vacation responders. It is implemented by
+// repository.MessageRepository.
+type Store interface {
+	MailboxExists(ctx context.Context, mailboxID string) (bool, error)
+	GetVacationResponder(ctx context.Context, mailboxID string) (*domain.VacationResponder, error)
+	SaveVacationResponder(ctx context.Context, v *domain.VacationResponder) error
+	DeleteVacationResponder(ctx context.Context, mailboxID string) (bool, error)
+}
+
+// Handler serves the vacation responder API, one responder per mailbox:
+//
+//	GET    /api/v1/mailboxes/{id}/vacation
+//	PUT    /api/v1/mailboxes/{id}/vacation
+//	DELETE /api/v1/mailboxes/{id}/vacation
+type Handler struct {
+	store  Store
+	config *config.VacationConfig
+	logger *zap.Logger
+}
+
+// NewHandler creates a new vacation responder handler
+func NewHandler(store Store, cfg *config.VacationConfig, logger *zap.Logger) *Handler {
+	return &Handler{
+		store:  store,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Register adds the API routes to mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/mailboxes/{id}/vacation", h.authorize(h.getResponder))
+	mux.HandleFunc("PUT /api/v1/mailboxes/{id}/vacation", h.authorize(h.putResponder))
+	mux.HandleFunc("DELETE /api/v1/mailboxes/{id}/vacation", h.authorize(h.deleteResponder))
+}
+
+// responderRequest is the body of a PUT
+type responderRequest struct {
+	Subject           string     `json:"subject"`
+	Body              string     `json:"body"`
+	StartAt           *time.Time `json:"start_at"`
+	EndAt             *time.Time `json:"end_at"`
+	ReplyIntervalDays int        `json:"reply_interval_days"`
+	Addresses         []string   `json:"addresses"`
+	Active            *bool      `json:"active"`
+}
+
+// authorize only lets requests carrying the internal secret through
+func (h *Handler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get("X-Internal-Secret")
+		if h.config.InternalSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.config.InternalSecret)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) getResponder(w http.ResponseWriter, r *http.Request) {
+	mailboxID, ok := h.mailboxID(w, r)
+	if !ok {
+		return
+	}
+
+	responder, err := h.store.GetVacationResponder(r.Context(), mailboxID)
+	if err != nil {
+		h.internalError(w, "get vacation responder", err)
+		return
+	}
+	if responder == nil {
+		writeError(w, http.StatusNotFound, "mailbox has no vacation responder")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, responder)
+}
+
+func (h *Handler) putResponder(w http.ResponseWriter, r *http.Request) {
+	mailboxID, ok := h.mailboxID(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(2*h.config.MaxBodySize+4096)))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	var req responderRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	responder, err := h.validate(&req)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	responder.MailboxID = mailboxID
+
+	if err := h.store.SaveVacationResponder(r.Context(), responder); err != nil {
+		h.internalError(w, "save vacation responder", err)
+		return
+	}
+
+	h.logger.Info("Vacation responder saved",
+		zap.String("mailbox_id", mailboxID),
+		zap.Bool("active", responder.IsActive))
+
+	writeJSON(w, http.StatusOK, responder)
+}
+
+func (h *Handler) deleteResponder(w http.ResponseWriter, r *http.Request) {
+	mailboxID, ok := h.mailboxID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.store.DeleteVacationResponder(r.Context(), mailboxID)
+	if err != nil {
+		h.internalError(w, "delete vacation responder", err)
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "mailbox has no vacation responder")
+		return
+	}
+
+	h.logger.Info("Vacation responder deleted", zap.String("mailbox_id", mailboxID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validate checks a request and returns the responder it describes
+func (h *Handler) validate(req *responderRequest) (*domain.VacationResponder, error) {
+	subject := strings.TrimSpace(req.Subject)
+	if len(subject) > 255 || strings.ContainsAny(subject, "\r\n") {
+		return nil, fmt.Errorf("subject must be a single line of at most 255 bytes")
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, fmt.Errorf("body is required")
+	}
+	if len(req.Body) > h.config.MaxBodySize {
+		return nil, fmt.Errorf("body exceeds %d bytes", h.config.MaxBodySize)
+	}
+	if req.StartAt != nil && req.EndAt != nil && !req.EndAt.After(*req.StartAt) {
+		return nil, fmt.Errorf("end_at must be after start_at")
+	}
+	if req.ReplyIntervalDays < 0 || req.ReplyIntervalDays > maxReplyIntervalDays {
+		return nil, fmt.Errorf("reply_interval_days must be between 1 and %d", maxReplyIntervalDays)
+	}
+
+	addresses := make([]string, 0, len(req.Addresses))
+	for _, a := range req.Addresses {
+		parsed, err := mail.ParseAddress(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q", a)
+		}
+		addresses = append(addresses, strings.ToLower(parsed.Address))
+	}
+
+	interval := req.ReplyIntervalDays
+	if interval == 0 {
+		interval = DefaultReplyInterval
+	}
+
+	return &domain.VacationResponder{
+		Subject:           subject,
+		Body:              req.Body,
+		StartAt:           req.StartAt,
+		EndAt:             req.EndAt,
+		ReplyIntervalDays: interval,
+		Addresses:         addresses,
+		IsActive:          req.Active == nil || *req.Active,
+	}, nil
+}
+
+// mailboxID returns the mailbox of the request if it exists
+func (h *Handler) mailboxID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid mailbox id")
+		return "", false
+	}
+
+	exists, err := h.store.MailboxExists(r.Context(), id.String())
+	if err != nil {
+		h.internalError(w, "check mailbox", err)
+		return "", false
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "mailbox not found")
+		return "", false
+	}
+	return id.String(), true
+}
+
+func (h *Handler) internalError(w http.ResponseWriter, op string, err error) {
+	h.logger.Error("Vacation API request failed", zap.String("op", op), zap.Error(err))
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,156 @@
+// Package vacation implements per-mailbox out-of-office auto-replies
+// following RFC 3834: replies are marked Auto-Submitted, go to the envelope
+// sender with a null return path, and are never sent to mailing lists, bulk
+// mail, other automated messages or no-reply addresses.
+package vacation
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+// DefaultReplyInterval is how often a sender is answered when the responder
+// doesn't say
+const DefaultReplyInterval = 7
+
+// noReplyLocalParts are local parts of addresses that belong to software
+// rather than people
+var noReplyLocalParts = map[string]bool{
+	"noreply":       true,
+	"no-reply":      true,
+	"no_reply":      true,
+	"donotreply":    true,
+	"do-not-reply":  true,
+	"mailer-daemon": true,
+	"postmaster":    true,
+	"listserv":      true,
+	"majordomo":     true,
+	"bounce":        true,
+	"bounces":       true,
+}
+
+// Skip returns why a message must not be answered, or "" if it may be.
+// sender is the envelope sender and addresses are those of the mailbox
+// owner; RFC 3834 only allows a reply when one of them is in To or Cc.
+func Skip(header mail.Header, sender string, addresses []string) string {
+	if sender == "" {
+		return "null sender"
+	}
+	if isNoReplyAddress(sender) {
+		return "no-reply sender"
+	}
+	for _, address := range addresses {
+		if strings.EqualFold(sender, address) {
+			return "own address"
+		}
+	}
+
+	if v := strings.ToLower(strings.TrimSpace(header.Get("Auto-Submitted"))); v != "" && v != "no" {
+		return "auto-submitted"
+	}
+	switch strings.ToLower(strings.TrimSpace(header.Get("Precedence"))) {
+	case "bulk", "list", "junk":
+		return "bulk precedence"
+	}
+	for _, name := range []string{"List-Id", "List-Unsubscribe", "List-Post"} {
+		if header.Get(name) != "" {
+			return "mailing list"
+		}
+	}
+	if v := strings.ToLower(header.Get("X-Auto-Response-Suppress")); strings.Contains(v, "oof") || strings.Contains(v, "all") {
+		return "auto-response suppressed"
+	}
+
+	if !addressedTo(header, addresses) {
+		return "not addressed to mailbox"
+	}
+	return ""
+}
+
+// isNoReplyAddress reports whether an address belongs to a mailing list
+// manager, a bounce handler or a no-reply sender
+func isNoReplyAddress(address string) bool {
+	local := strings.ToLower(address)
+	if at := strings.LastIndex(local, "@"); at >= 0 {
+		local = local[:at]
+	}
+	if noReplyLocalParts[local] {
+		return true
+	}
+	return strings.HasPrefix(local, "owner-") ||
+		strings.HasSuffix(local, "-request") ||
+		strings.HasSuffix(local, "-owner") ||
+		strings.HasSuffix(local, "-bounces") ||
+		strings.HasPrefix(local, "noreply")
+}
+
+// addressedTo reports whether one of the addresses is in To or Cc
+func addressedTo(header mail.Header, addresses []string) bool {
+	for _, name := range []string{"To", "Cc"} {
+		list, err := header.AddressList(name)
+		if err != nil {
+			continue
+		}
+		for _, a := range list {
+			for _, address := range addresses {
+				if strings.EqualFold(a.Address, address) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Reply builds the auto-reply from the mailbox address from to the sender
+// of a message with the given header
+func Reply(v *domain.VacationResponder, header mail.Header, from, to, hostname string, now time.Time) []byte {
+	subject := strings.TrimSpace(v.Subject)
+	if subject == "" {
+		original, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+		if err != nil {
+			original = header.Get("Subject")
+		}
+		subject = "Auto: " + original
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: <%s>\r\n", from)
+	fmt.Fprintf(&buf, "To: <%s>\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", uuid.New().String(), hostname)
+	if id := strings.TrimSpace(header.Get("Message-ID")); id != "" {
+		fmt.Fprintf(&buf, "In-Reply-To: %s\r\n", id)
+		references := strings.TrimSpace(header.Get("References") + " " + id)
+		fmt.Fprintf(&buf, "References: %s\r\n", references)
+	}
+	buf.WriteString("Auto-Submitted: auto-replied\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+
+	body := strings.ReplaceAll(v.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+// ReplyInterval returns how long a sender isn't answered again
+func ReplyInterval(v *domain.VacationResponder) time.Duration {
+	days := v.ReplyIntervalDays
+	if days < 1 {
+		days = DefaultReplyInterval
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,129 @@
+package vacation
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oonrumail/smtp-server/domain"
+)
+
+func header(t *testing.T, raw string) mail.Header {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(raw + "\r\nBody\r\n"))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	return msg.Header
+}
+
+func TestSkip(t *testing.T) {
+	addresses := []string{"bob@example.com", "robert@example.com"}
+	personal := "From: alice@example.org\r\nTo: Bob <Bob@example.com>\r\nSubject: Lunch\r\n"
+
+	tests := []struct {
+		name   string
+		raw    string
+		sender string
+		want   string
+	}{
+		{"personal message", personal, "alice@example.org", ""},
+		{"cc to another address", "From: alice@example.org\r\nTo: team@example.com\r\nCc: robert@example.com\r\n", "alice@example.org", ""},
+		{"auto-submitted no", personal + "Auto-Submitted: no\r\n", "alice@example.org", ""},
+		{"null sender", personal, "", "null sender"},
+		{"no-reply sender", personal, "no-reply@shop.example", "no-reply sender"},
+		{"list request address", personal, "news-request@lists.example", "no-reply sender"},
+		{"mailer daemon", personal, "MAILER-DAEMON@example.org", "no-reply sender"},
+		{"own address", personal, "robert@example.com", "own address"},
+		{"auto-replied", personal + "Auto-Submitted: auto-replied\r\n", "alice@example.org", "auto-submitted"},
+		{"bulk precedence", personal + "Precedence: bulk\r\n", "alice@example.org", "bulk precedence"},
+		{"mailing list", personal + "List-Id: <news.lists.example>\r\n", "alice@example.org", "mailing list"},
+		{"one-click unsubscribe", personal + "List-Unsubscribe: <https://example.org/u>\r\n", "alice@example.org", "mailing list"},
+		{"suppressed", personal + "X-Auto-Response-Suppress: OOF, AutoReply\r\n", "alice@example.org", "auto-response suppressed"},
+		{"bcc", "From: alice@example.org\r\nTo: team@example.com\r\n", "alice@example.org", "not addressed to mailbox"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Skip(header(t, tt.raw), tt.sender, addresses); got != tt.want {
+				t.Errorf("Skip() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReply(t *testing.T) {
+	responder := &domain.VacationResponder{Body: "I'm away until Monday.\nRegards"}
+	original := header(t, "From: alice@example.org\r\nTo: bob@example.com\r\n"+
+		"Subject: =?UTF-8?Q?Caf=C3=A9?=\r\nMessage-ID: <m2@example.org>\r\nReferences: <m1@example.org>\r\n")
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	raw := Reply(responder, original, "bob@example.com", "alice@example.org", "mx.example.com", now)
+	reply, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("reply does not parse: %v", err)
+	}
+
+	subject, _ := new(mime.WordDecoder).DecodeHeader(reply.Header.Get("Subject"))
+	checks := map[string]string{
+		"Subject":        "Auto: Café",
+		"To":             "<alice@example.org>",
+		"Auto-Submitted": "auto-replied",
+		"In-Reply-To":    "<m2@example.org>",
+		"References":     "<m1@example.org> <m2@example.org>",
+	}
+	for name, want := range checks {
+		got := reply.Header.Get(name)
+		if name == "Subject" {
+			got = subject
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if !bytes.HasSuffix(raw, []byte("\r\n\r\nI'm away until Monday.\r\nRegards\r\n")) {
+		t.Errorf("body not CRLF-normalized:\n%s", raw)
+	}
+
+	// A reply must itself be skipped by another responder
+	if got := Skip(reply.Header, "bob@example.com", []string{"alice@example.org"}); got != "auto-submitted" {
+		t.Errorf("Skip(reply) = %q", got)
+	}
+}
+
+func TestInEffect(t *testing.T) {
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(7 * 24 * time.Hour)
+	v := &domain.VacationResponder{IsActive: true, StartAt: &start, EndAt: &end}
+
+	for _, tt := range []struct {
+		at   time.Time
+		want bool
+	}{
+		{start.Add(-time.Minute), false},
+		{start, true},
+		{end.Add(-time.Minute), true},
+		{end, false},
+	} {
+		if got := v.InEffect(tt.at); got != tt.want {
+			t.Errorf("InEffect(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	v.IsActive = false
+	if v.InEffect(start) {
+		t.Error("inactive responder is in effect")
+	}
+}
+
+func TestReplyInterval(t *testing.T) {
+	if got := ReplyInterval(&domain.VacationResponder{}); got != 7*24*time.Hour {
+		t.Errorf("default interval = %s", got)
+	}
+	if got := ReplyInterval(&domain.VacationResponder{ReplyIntervalDays: 1}); got != 24*time.Hour {
+		t.Errorf("interval = %s", got)
+	}
+}
@@ -0,0 +1,41 @@
+package access
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// Service implements the AccessService interface against the domain
+// permissions granted by the auth service
+type Service struct {
+	db     *pgxpool.Pool
+	logger zerolog.Logger
+}
+
+// NewService creates a new access service
+func NewService(db *pgxpool.Pool, logger zerolog.Logger) *Service {
+	return &Service{
+		db:     db,
+		logger: logger.With().Str("component", "access_service").Logger(),
+	}
+}
+
+// CanAccessDomain reports whether the user has been granted access to the
+// domain
+func (s *Service) CanAccessDomain(ctx context.Context, userID, domainID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_domain_permissions
+			WHERE user_id = $1 AND domain_id = $2
+		)
+	`
+
+	var allowed bool
+	if err := s.db.QueryRow(ctx, query, userID, domainID).Scan(&allowed); err != nil {
+		return false, fmt.Errorf("failed to check domain permission: %w", err)
+	}
+	return allowed, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -35,6 +36,13 @@ type Config struct {
 	S3UsePathStyle    bool
 	S3PresignDuration time.Duration
 
+	// S3 provider ("minio" or "aws") and signed URL settings. With "aws" the
+	// regional endpoint for S3Region is used and S3Endpoint is ignored.
+	// S3PublicEndpoint is the endpoint clients reach, used for signing URLs
+	S3Provider           string
+	S3PublicEndpoint     string
+	S3PresignMaxDuration time.Duration
+
 	// Storage settings
 	MaxUploadSize       int64
 	ChunkSize           int64
@@ -47,6 +55,12 @@ type Config struct {
 	DefaultMailboxQuota int64
 	QuotaWarningPercent int
 
+	// Quota alert settings
+	QuotaAlertThresholds []int // usage percentages that trigger a webhook when crossed
+	QuotaWebhookURL      string
+	QuotaWebhookSecret   string
+	QuotaWebhookTimeout  time.Duration
+
 	// Retention settings
 	RetentionCheckInterval time.Duration
 	RetentionBatchSize     int
@@ -89,6 +103,11 @@ func Load() *Config {
 		S3UsePathStyle:    getBool("S3_USE_PATH_STYLE", true),
 		S3PresignDuration: getDuration("S3_PRESIGN_DURATION", 15*time.Minute),
 
+		// S3 provider and signed URLs
+		S3Provider:           getEnv("S3_PROVIDER", "minio"),
+		S3PublicEndpoint:     getEnv("S3_PUBLIC_ENDPOINT", ""),
+		S3PresignMaxDuration: getDuration("S3_PRESIGN_MAX_DURATION", time.Hour),
+
 		// Storage
 		MaxUploadSize:        getInt64("MAX_UPLOAD_SIZE", 25*1024*1024), // 25MB - aligned with SMTP and industry standard
 		ChunkSize:            getInt64("CHUNK_SIZE", 5*1024*1024),       // 5MB
@@ -101,6 +120,12 @@ func Load() *Config {
 		DefaultMailboxQuota: getInt64("DEFAULT_MAILBOX_QUOTA", 5*1024*1024*1024),    // 5GB
 		QuotaWarningPercent: getInt("QUOTA_WARNING_PERCENT", 90),
 
+		// Quota alerts
+		QuotaAlertThresholds: getIntList("QUOTA_ALERT_THRESHOLDS", []int{80, 95}),
+		QuotaWebhookURL:      getEnv("QUOTA_WEBHOOK_URL", ""),
+		QuotaWebhookSecret:   getEnv("QUOTA_WEBHOOK_SECRET", ""),
+		QuotaWebhookTimeout:  getDuration("QUOTA_WEBHOOK_TIMEOUT", 10*time.Second),
+
 		// Retention
 		RetentionCheckInterval: getDuration("RETENTION_CHECK_INTERVAL", time.Hour),
 		RetentionBatchSize:     getInt("RETENTION_BATCH_SIZE", 1000),
@@ -141,6 +166,21 @@ func getInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getIntList(key string, defaultValue []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		if i, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
 func requireEnv(key string) string {
 	value := os.Getenv(key)
 	if value == "" {
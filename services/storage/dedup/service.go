@@ -2,9 +2,11 @@ package dedup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 
@@ -13,6 +15,9 @@ import (
 	"github.com/oonrumail/storage/storage"
 )
 
+// ErrAttachmentNotFound is returned when no deduplicated attachment matches a content hash
+var ErrAttachmentNotFound = errors.New("deduplicated attachment not found")
+
 // Service implements the DeduplicationService interface
 type Service struct {
 	db       *pgxpool.Pool
@@ -459,6 +464,127 @@ func (s *Service) CleanupOrphans(ctx context.Context) (int, int64, error) {
 	return cleaned, bytesFreed, nil
 }
 
+// GetReferenceCount returns how many logical attachments reference the blob
+// stored for contentHash in an organization
+func (s *Service) GetReferenceCount(ctx context.Context, orgID string, contentHash string) (int, error) {
+	var refCount int
+	err := s.db.QueryRow(ctx,
+		"SELECT ref_count FROM deduplicated_attachments WHERE org_id = $1 AND content_hash = $2",
+		orgID, contentHash,
+	).Scan(&refCount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrAttachmentNotFound
+		}
+		return 0, fmt.Errorf("failed to get ref count: %w", err)
+	}
+
+	return refCount, nil
+}
+
+// ReleaseBlob drops the references held by a domain (or a single user, when
+// userID is set) on the deduplicated blob stored at storageKey. It reports
+// whether the key belongs to a deduplicated blob and how many references
+// remain. The blob itself is never deleted here: callers may only remove it
+// once no references remain, in which case the dedup record is removed too
+func (s *Service) ReleaseBlob(ctx context.Context, orgID, domainID, userID, storageKey string) (bool, int, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var dedupID string
+	err = tx.QueryRow(ctx,
+		"SELECT id FROM deduplicated_attachments WHERE org_id = $1 AND storage_key = $2 FOR UPDATE",
+		orgID, storageKey,
+	).Scan(&dedupID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, 0, nil
+		}
+		return false, 0, fmt.Errorf("failed to look up blob: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM attachment_references
+		WHERE dedup_id = $1 AND domain_id = $2 AND ($3 = '' OR user_id = $3)
+	`, dedupID, domainID, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to delete references: %w", err)
+	}
+
+	// Never report fewer references than the index still holds, even if
+	// ref_count has drifted below it
+	updateQuery := `
+		UPDATE deduplicated_attachments
+		SET ref_count = GREATEST(ref_count - $2,
+		        (SELECT COUNT(*) FROM attachment_references WHERE dedup_id = $1), 0),
+		    updated_at = $3
+		WHERE id = $1
+		RETURNING ref_count
+	`
+
+	var refCount int
+	err = tx.QueryRow(ctx, updateQuery, dedupID, tag.RowsAffected(), time.Now()).Scan(&refCount)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to update ref count: %w", err)
+	}
+
+	if refCount == 0 {
+		_, err = tx.Exec(ctx, "DELETE FROM deduplicated_attachments WHERE id = $1", dedupID)
+		if err != nil {
+			return false, 0, fmt.Errorf("failed to delete deduplicated attachment: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Debug().
+		Str("dedup_id", dedupID).
+		Str("domain_id", domainID).
+		Int64("released_refs", tag.RowsAffected()).
+		Int("remaining_refs", refCount).
+		Msg("Released deduplicated blob")
+
+	return true, refCount, nil
+}
+
+// RecountReferences rebuilds ref_count from the attachment reference index for
+// one organization, or all organizations if orgID is empty. It returns the
+// number of records whose count had drifted
+func (s *Service) RecountReferences(ctx context.Context, orgID string) (int, error) {
+	query := `
+		UPDATE deduplicated_attachments d
+		SET ref_count = r.actual
+		FROM (
+			SELECT d2.id, COUNT(ar.id) AS actual
+			FROM deduplicated_attachments d2
+			LEFT JOIN attachment_references ar ON ar.dedup_id = d2.id
+			WHERE $1 = '' OR d2.org_id = $1
+			GROUP BY d2.id
+		) r
+		WHERE d.id = r.id AND d.ref_count <> r.actual
+	`
+
+	tag, err := s.db.Exec(ctx, query, orgID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recount references: %w", err)
+	}
+
+	fixed := int(tag.RowsAffected())
+	if fixed > 0 {
+		s.logger.Warn().
+			Str("org_id", orgID).
+			Int("fixed", fixed).
+			Msg("Corrected drifted attachment reference counts")
+	}
+
+	return fixed, nil
+}
+
 // GetStats returns deduplication statistics for an organization
 func (s *Service) GetStats(ctx context.Context, orgID string) (*models.DeduplicationStats, error) {
 	stats := &models.DeduplicationStats{
@@ -19,6 +19,7 @@ type DeletionService struct {
 	db        *pgxpool.Pool
 	storage   storage.DomainStorageService
 	quotaSvc  storage.QuotaService
+	dedupSvc  storage.DeduplicationService
 	cfg       *config.Config
 	logger    zerolog.Logger
 }
@@ -28,6 +29,7 @@ func NewDeletionService(
 	db *pgxpool.Pool,
 	storageSvc storage.DomainStorageService,
 	quotaSvc storage.QuotaService,
+	dedupSvc storage.DeduplicationService,
 	cfg *config.Config,
 	logger zerolog.Logger,
 ) *DeletionService {
@@ -35,6 +37,7 @@ func NewDeletionService(
 		db:       db,
 		storage:  storageSvc,
 		quotaSvc: quotaSvc,
+		dedupSvc: dedupSvc,
 		cfg:      cfg,
 		logger:   logger.With().Str("component", "deletion_service").Logger(),
 	}
@@ -241,6 +244,9 @@ func (s *DeletionService) ProcessDeletionJob(ctx context.Context, jobID string)
 		return err
 	}
 
+	// Keep deduplicated attachments that other users still reference
+	objects = s.releaseSharedAttachments(ctx, job, objects)
+
 	// Count totals
 	for _, obj := range objects {
 		job.TotalSize += obj.Size
@@ -479,6 +485,42 @@ func (s *DeletionService) logDeletionAudit(ctx context.Context, job *models.Dele
 	}
 }
 
+// releaseSharedAttachments drops the job's references to deduplicated
+// attachments and returns the objects that are safe to delete. A blob that is
+// still referenced from outside the job's scope is left in place
+func (s *DeletionService) releaseSharedAttachments(ctx context.Context, job *models.DeletionJob, objects []*models.StorageObject) []*models.StorageObject {
+	if s.dedupSvc == nil {
+		return objects
+	}
+
+	deletable := objects[:0]
+	for _, obj := range objects {
+		if !isAttachment(obj.Key) {
+			deletable = append(deletable, obj)
+			continue
+		}
+
+		isDedup, remaining, err := s.dedupSvc.ReleaseBlob(ctx, job.OrgID, job.DomainID, job.UserID, obj.Key)
+		if err != nil {
+			// Err on the side of keeping data that may be shared
+			s.logger.Error().Err(err).Str("key", obj.Key).Msg("Failed to release deduplicated attachment, skipping")
+			continue
+		}
+		if isDedup && remaining > 0 {
+			s.logger.Info().
+				Str("job_id", job.ID).
+				Str("key", obj.Key).
+				Int("remaining_refs", remaining).
+				Msg("Kept shared deduplicated attachment")
+			continue
+		}
+
+		deletable = append(deletable, obj)
+	}
+
+	return deletable
+}
+
 func (s *DeletionService) clearSearchIndex(ctx context.Context, job *models.DeletionJob) error {
 	// This would integrate with the search service to clear indexed data
 	// For now, just log the intent
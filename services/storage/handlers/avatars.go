@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/oonrumail/storage/models"
+)
+
+// maxAvatarSize bounds a single stored avatar image. The auth service
+// validates and re-encodes uploads, so anything larger is a client error.
+const maxAvatarSize = 10 * 1024 * 1024
+
+// avatarVariants are the stored renditions of an avatar
+var avatarVariants = map[string]bool{
+	"full":      true,
+	"thumbnail": true,
+}
+
+// avatarKey validates the path parameters and returns the object key of an
+// avatar variant, or writes an error response and returns false
+func (h *Handler) avatarKey(w http.ResponseWriter, r *http.Request, withVariant bool) (string, bool) {
+	orgID, userID := chi.URLParam(r, "orgID"), chi.URLParam(r, "userID")
+	if _, err := uuid.Parse(orgID); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "Invalid organization ID")
+		return "", false
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return "", false
+	}
+
+	prefix := models.AvatarPrefix(orgID, userID)
+	if !withVariant {
+		return prefix, true
+	}
+
+	variant := chi.URLParam(r, "variant")
+	if !avatarVariants[variant] {
+		h.errorResponse(w, http.StatusBadRequest, "Unknown avatar variant")
+		return "", false
+	}
+	return prefix + variant, true
+}
+
+// putAvatar stores one variant of a user's avatar from the request body
+func (h *Handler) putAvatar(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.avatarKey(w, r, true)
+	if !ok {
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		h.errorResponse(w, http.StatusUnsupportedMediaType, "Avatars must be images")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAvatarSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.errorResponse(w, http.StatusRequestEntityTooLarge, "Avatar is too large")
+			return
+		}
+		h.errorResponse(w, http.StatusBadRequest, "Failed to read avatar")
+		return
+	}
+	if len(data) == 0 {
+		h.errorResponse(w, http.StatusBadRequest, "Avatar is empty")
+		return
+	}
+
+	metadata := map[string]string{"user-id": chi.URLParam(r, "userID")}
+	if err := h.storage.Put(r.Context(), key, bytes.NewReader(data), int64(len(data)), contentType, metadata); err != nil {
+		h.logger.Error().Err(err).Str("key", key).Msg("Failed to store avatar")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to store avatar")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"storage_key":  key,
+		"size":         len(data),
+		"content_type": contentType,
+	})
+}
+
+// getAvatar streams one variant of a user's avatar
+func (h *Handler) getAvatar(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.avatarKey(w, r, true)
+	if !ok {
+		return
+	}
+
+	exists, err := h.storage.Exists(r.Context(), key)
+	if err != nil {
+		h.logger.Error().Err(err).Str("key", key).Msg("Failed to check avatar")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to retrieve avatar")
+		return
+	}
+	if !exists {
+		h.errorResponse(w, http.StatusNotFound, "Avatar not found")
+		return
+	}
+
+	reader, obj, err := h.storage.Get(r.Context(), key)
+	if err != nil {
+		h.logger.Error().Err(err).Str("key", key).Msg("Failed to get avatar")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to retrieve avatar")
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	if obj.ETag != "" {
+		w.Header().Set("ETag", `"`+obj.ETag+`"`)
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, reader)
+}
+
+// deleteAvatar removes every variant of a user's avatar
+func (h *Handler) deleteAvatar(w http.ResponseWriter, r *http.Request) {
+	prefix, ok := h.avatarKey(w, r, false)
+	if !ok {
+		return
+	}
+
+	deleted, errs := h.storage.DeleteByPrefix(r.Context(), prefix)
+	if len(errs) > 0 {
+		h.logger.Error().Errs("errors", errs).Str("prefix", prefix).Msg("Failed to delete avatar")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to delete avatar")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "deleted",
+		"deleted": deleted,
+	})
+}
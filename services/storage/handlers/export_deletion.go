@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/oonrumail/storage/dedup"
 	"github.com/oonrumail/storage/models"
 )
 
@@ -283,3 +285,46 @@ func (h *Handler) getDeduplicationStats(w http.ResponseWriter, r *http.Request)
 
 	h.jsonResponse(w, http.StatusOK, stats)
 }
+
+// getDedupReferenceCount returns how many attachments reference a deduplicated blob
+func (h *Handler) getDedupReferenceCount(w http.ResponseWriter, r *http.Request) {
+	hash := chi.URLParam(r, "hash")
+	orgID := r.URL.Query().Get("org_id")
+	if orgID == "" {
+		h.errorResponse(w, http.StatusBadRequest, "org_id is required")
+		return
+	}
+
+	refCount, err := h.dedup.GetReferenceCount(r.Context(), orgID, hash)
+	if err != nil {
+		if errors.Is(err, dedup.ErrAttachmentNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "Deduplicated attachment not found")
+			return
+		}
+		h.logger.Error().Err(err).Str("content_hash", hash).Msg("Failed to get reference count")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to get reference count")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"content_hash": hash,
+		"org_id":       orgID,
+		"ref_count":    refCount,
+	})
+}
+
+// recountDedupReferences rebuilds reference counts from the attachment reference index
+func (h *Handler) recountDedupReferences(w http.ResponseWriter, r *http.Request) {
+	orgID := r.URL.Query().Get("org_id")
+
+	fixed, err := h.dedup.RecountReferences(r.Context(), orgID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("org_id", orgID).Msg("Failed to recount references")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to recount references")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"corrected": fixed,
+	})
+}
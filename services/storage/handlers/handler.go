@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
+	"github.com/oonrumail/storage/config"
 	"github.com/oonrumail/storage/models"
 	"github.com/oonrumail/storage/storage"
 )
@@ -22,6 +28,8 @@ type Handler struct {
 	export       storage.ExportService
 	deletion     storage.DeletionService
 	dedup        storage.DeduplicationService
+	access       storage.AccessService
+	cfg          *config.Config
 	logger       zerolog.Logger
 }
 
@@ -33,6 +41,8 @@ func NewHandler(
 	exportSvc storage.ExportService,
 	deletionSvc storage.DeletionService,
 	dedupSvc storage.DeduplicationService,
+	accessSvc storage.AccessService,
+	cfg *config.Config,
 	logger zerolog.Logger,
 ) *Handler {
 	return &Handler{
@@ -42,6 +52,8 @@ func NewHandler(
 		export:    exportSvc,
 		deletion:  deletionSvc,
 		dedup:     dedupSvc,
+		access:    accessSvc,
+		cfg:       cfg,
 		logger:    logger.With().Str("component", "handler").Logger(),
 	}
 }
@@ -66,6 +78,7 @@ func (h *Handler) Router() chi.Router {
 		// Attachment operations
 		r.Route("/attachments", func(r chi.Router) {
 			r.Post("/", h.storeAttachment)
+			r.Post("/upload", h.uploadAttachment)
 			r.Get("/{attachmentID}", h.getAttachment)
 			r.Delete("/{attachmentID}", h.deleteAttachment)
 			r.Get("/{attachmentID}/presigned", h.getAttachmentPresignedURL)
@@ -86,6 +99,19 @@ func (h *Handler) Router() chi.Router {
 			r.Get("/usage", h.getQuotaUsage)
 		})
 
+		// Domain quota status with crossed warning thresholds
+		r.Get("/storage/quota/{domainID}", h.getDomainQuotaStatus)
+
+		// Signed URLs for downloading attachments directly from object storage
+		r.Post("/storage/objects/{id}/signed-url", h.createObjectSignedURL)
+
+		// User avatars, written by the auth service
+		r.Route("/avatars/{orgID}/{userID}", func(r chi.Router) {
+			r.Put("/{variant}", h.putAvatar)
+			r.Get("/{variant}", h.getAvatar)
+			r.Delete("/", h.deleteAvatar)
+		})
+
 		// Retention policy operations
 		r.Route("/retention", func(r chi.Router) {
 			r.Post("/policies", h.createRetentionPolicy)
@@ -120,6 +146,8 @@ func (h *Handler) Router() chi.Router {
 		// Deduplication stats
 		r.Route("/dedup", func(r chi.Router) {
 			r.Get("/stats/{orgID}", h.getDeduplicationStats)
+			r.Get("/{hash}/refs", h.getDedupReferenceCount)
+			r.Post("/recount", h.recountDedupReferences)
 		})
 	})
 
@@ -323,6 +351,51 @@ func (h *Handler) storeAttachment(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// uploadAttachment stores an attachment sent as the raw request body. The
+// content is hashed here so identical uploads share one object.
+func (h *Handler) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &storage.StoreAttachmentRequest{
+		OrgID:       q.Get("org_id"),
+		DomainID:    q.Get("domain_id"),
+		UserID:      q.Get("user_id"),
+		MessageID:   q.Get("message_id"),
+		Filename:    q.Get("filename"),
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	if req.OrgID == "" || req.DomainID == "" || req.UserID == "" || req.Filename == "" {
+		h.errorResponse(w, http.StatusBadRequest, "org_id, domain_id, user_id and filename are required")
+		return
+	}
+	if req.ContentType == "" {
+		req.ContentType = "application/octet-stream"
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, h.cfg.MaxUploadSize+1))
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	if int64(len(data)) > h.cfg.MaxUploadSize {
+		h.errorResponse(w, http.StatusRequestEntityTooLarge, "Attachment exceeds maximum upload size")
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	req.Content = bytes.NewReader(data)
+	req.Size = int64(len(data))
+	req.ContentHash = hex.EncodeToString(sum[:])
+
+	attachment, err := h.storage.StoreAttachment(r.Context(), req)
+	if err != nil {
+		h.logger.Error().Err(err).Str("filename", req.Filename).Msg("Failed to upload attachment")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to store attachment")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusCreated, attachment)
+}
+
 func (h *Handler) getAttachment(w http.ResponseWriter, r *http.Request) {
 	attachmentID := chi.URLParam(r, "attachmentID")
 
@@ -410,6 +483,101 @@ func (h *Handler) getMessageAttachments(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// Object handlers
+type SignedURLRequest struct {
+	ExpiresIn int `json:"expires_in"` // seconds, defaults to S3_PRESIGN_DURATION
+}
+
+// createObjectSignedURL returns a time-limited URL for downloading an
+// attachment straight from object storage. The caller, identified by the
+// gateway's X-User-ID header, must own the attachment or have access to its
+// domain.
+func (h *Handler) createObjectSignedURL(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if _, err := uuid.Parse(userID); err != nil {
+		h.errorResponse(w, http.StatusUnauthorized, "Missing or invalid caller identity")
+		return
+	}
+
+	var req SignedURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	expiry, err := signedURLExpiry(req.ExpiresIn, h.cfg.S3PresignDuration, h.cfg.S3PresignMaxDuration)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	objectID := chi.URLParam(r, "id")
+	dedup, ref, err := h.dedup.GetByReference(r.Context(), objectID)
+	if err != nil {
+		h.errorResponse(w, http.StatusNotFound, "Object not found")
+		return
+	}
+
+	// Objects in other organizations are reported as missing
+	if orgID := r.Header.Get("X-Org-ID"); orgID != "" && orgID != ref.OrgID {
+		h.errorResponse(w, http.StatusNotFound, "Object not found")
+		return
+	}
+
+	if ref.UserID != userID {
+		allowed, err := h.access.CanAccessDomain(r.Context(), userID, ref.DomainID)
+		if err != nil {
+			h.logger.Error().Err(err).Str("domain_id", ref.DomainID).Msg("Failed to check domain access")
+			h.errorResponse(w, http.StatusInternalServerError, "Failed to check permissions")
+			return
+		}
+		if !allowed {
+			h.errorResponse(w, http.StatusForbidden, "Access to this object's domain is denied")
+			return
+		}
+	}
+
+	url, err := h.storage.GetPresignedDownloadURLAs(r.Context(), dedup.StorageKey, ref.Filename, ref.ContentType, expiry)
+	if err != nil {
+		h.logger.Error().Err(err).Str("id", objectID).Msg("Failed to generate signed URL")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to generate URL")
+		return
+	}
+
+	h.logger.Info().
+		Str("id", objectID).
+		Str("user_id", userID).
+		Str("domain_id", ref.DomainID).
+		Dur("expiry", expiry).
+		Msg("Issued signed download URL")
+
+	h.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"url":          url,
+		"expires_at":   time.Now().Add(expiry).UTC(),
+		"expires_in":   int(expiry.Seconds()),
+		"filename":     ref.Filename,
+		"content_type": ref.ContentType,
+		"size":         ref.Size,
+	})
+}
+
+// signedURLExpiry returns the lifetime of a signed URL: expiresIn seconds,
+// or the default when zero, capped at max
+func signedURLExpiry(expiresIn int, def, max time.Duration) (time.Duration, error) {
+	if expiresIn < 0 {
+		return 0, fmt.Errorf("expires_in must not be negative")
+	}
+
+	expiry := def
+	if expiresIn > 0 {
+		expiry = time.Duration(expiresIn) * time.Second
+	}
+	if max > 0 && expiry > max {
+		expiry = max
+	}
+	return expiry, nil
+}
+
 // Cross-domain handlers
 type CopyBetweenDomainsRequest struct {
 	SourceOrgID    string   `json:"source_org_id"`
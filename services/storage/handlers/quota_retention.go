@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/oonrumail/storage/models"
+	"github.com/oonrumail/storage/quota"
 )
 
 // Quota handlers
@@ -146,6 +148,24 @@ func (h *Handler) getQuotaUsage(w http.ResponseWriter, r *http.Request) {
 	h.jsonResponse(w, http.StatusOK, info)
 }
 
+// getDomainQuotaStatus returns a domain's usage, limit and crossed warning thresholds
+func (h *Handler) getDomainQuotaStatus(w http.ResponseWriter, r *http.Request) {
+	domainID := chi.URLParam(r, "domainID")
+
+	status, err := h.quota.GetDomainQuotaStatus(r.Context(), domainID)
+	if err != nil {
+		if errors.Is(err, quota.ErrQuotaNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "Domain quota not found")
+			return
+		}
+		h.logger.Error().Err(err).Str("domain_id", domainID).Msg("Failed to get domain quota status")
+		h.errorResponse(w, http.StatusInternalServerError, "Failed to get domain quota status")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, status)
+}
+
 // Retention handlers
 
 type CreateRetentionPolicyRequestHandler struct {
@@ -17,6 +17,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/oonrumail/storage/access"
 	"github.com/oonrumail/storage/config"
 	"github.com/oonrumail/storage/dedup"
 	"github.com/oonrumail/storage/export"
@@ -78,7 +79,8 @@ func main() {
 	domainStorage := storage.NewDomainAwareStorage(s3Storage, quotaService, dedupService, cfg, logger)
 	retentionService := retention.NewService(dbPool, domainStorage, quotaService, cfg, logger)
 	exportService := export.NewService(dbPool, domainStorage, cfg, logger)
-	deletionService := export.NewDeletionService(dbPool, domainStorage, quotaService, cfg, logger)
+	deletionService := export.NewDeletionService(dbPool, domainStorage, quotaService, dedupService, cfg, logger)
+	accessService := access.NewService(dbPool, logger)
 
 	// Initialize HTTP handlers
 	handler := handlers.NewHandler(
@@ -88,6 +90,8 @@ func main() {
 		exportService,
 		deletionService,
 		dedupService,
+		accessService,
+		cfg,
 		logger,
 	)
 
@@ -0,0 +1,10 @@
+-- Quota threshold alerts
+-- Records which warning thresholds a quota has crossed so each crossing only
+-- fires one webhook. Rows are removed when usage drops back below the threshold.
+CREATE TABLE IF NOT EXISTS quota_threshold_alerts (
+    quota_id UUID NOT NULL REFERENCES quotas(id) ON DELETE CASCADE,
+    threshold_pct INTEGER NOT NULL,
+    crossed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (quota_id, threshold_pct)
+);
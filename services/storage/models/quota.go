@@ -157,3 +157,27 @@ type UpdateQuotaRequest struct {
 	SoftLimitPct *int   `json:"soft_limit_pct,omitempty"`
 	HardLimitPct *int   `json:"hard_limit_pct,omitempty"`
 }
+
+// DomainQuotaStatus reports a domain's usage against its quota along with the
+// warning thresholds it has crossed
+type DomainQuotaStatus struct {
+	DomainID          string  `json:"domain_id"`
+	UsedBytes         int64   `json:"used_bytes"`
+	LimitBytes        int64   `json:"limit_bytes"`
+	UsagePercent      float64 `json:"usage_percent"`
+	Thresholds        []int   `json:"thresholds"`
+	CrossedThresholds []int   `json:"crossed_thresholds"`
+}
+
+// QuotaThresholdEvent is the webhook payload sent when a domain crosses a
+// quota warning threshold
+type QuotaThresholdEvent struct {
+	Event        string    `json:"event"`
+	QuotaID      string    `json:"quota_id"`
+	DomainID     string    `json:"domain_id"`
+	Threshold    int       `json:"threshold"`
+	UsedBytes    int64     `json:"used_bytes"`
+	LimitBytes   int64     `json:"limit_bytes"`
+	UsagePercent float64   `json:"usage_percent"`
+	Timestamp    time.Time `json:"timestamp"`
+}
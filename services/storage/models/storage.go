@@ -102,6 +102,12 @@ func NewSharedMailboxKey(orgID, domainID, sharedMailboxID, messageID string, tim
 	}
 }
 
+// AvatarPrefix returns the prefix under which a user's avatar images are
+// stored. Avatars belong to the user rather than to a domain.
+func AvatarPrefix(orgID, userID string) string {
+	return fmt.Sprintf("%s/avatars/%s/", orgID, userID)
+}
+
 // StorageObject represents a stored object with metadata
 type StorageObject struct {
 	Key          string            `json:"key"`
@@ -0,0 +1,208 @@
+package quota
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/oonrumail/storage/models"
+)
+
+// ErrQuotaNotFound is returned when no quota exists for the requested entity
+var ErrQuotaNotFound = errors.New("quota not found")
+
+// QuotaThresholdEventType is the event name sent in quota threshold webhooks
+const QuotaThresholdEventType = "quota.threshold_crossed"
+
+// SignatureHeader carries the hex HMAC-SHA256 of the webhook body when a
+// webhook secret is configured
+const SignatureHeader = "X-Storage-Signature"
+
+var quotaThresholdCrossings = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "storage_quota_threshold_crossings_total",
+		Help: "Number of times a domain quota crossed a warning threshold",
+	},
+	[]string{"threshold"},
+)
+
+// normalizeThresholds returns the valid thresholds sorted ascending without duplicates
+func normalizeThresholds(thresholds []int) []int {
+	seen := make(map[int]bool)
+	result := []int{}
+	for _, t := range thresholds {
+		if t <= 0 || seen[t] {
+			continue
+		}
+		seen[t] = true
+		result = append(result, t)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// crossedThresholds returns the thresholds at or below the usage percentage.
+// The result is never nil so it can be passed to Postgres as an empty array.
+func crossedThresholds(thresholds []int, usagePercent float64) []int {
+	crossed := []int{}
+	for _, t := range thresholds {
+		if usagePercent >= float64(t) {
+			crossed = append(crossed, t)
+		}
+	}
+	return crossed
+}
+
+// checkThresholds records which warning thresholds a domain quota has crossed
+// and fires a webhook for each one crossed since the last check. Thresholds
+// that usage has dropped back below are cleared so a later crossing fires
+// again. Failures are logged rather than returned so they never fail a write.
+func (s *Service) checkThresholds(ctx context.Context, quota *models.Quota) {
+	if len(s.thresholds) == 0 || quota.Level != models.QuotaLevelDomain {
+		return
+	}
+
+	usage := quota.UsagePercent()
+	crossed := crossedThresholds(s.thresholds, usage)
+
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM quota_threshold_alerts
+		WHERE quota_id = $1 AND NOT (threshold_pct = ANY($2))
+	`, quota.ID, crossed)
+	if err != nil {
+		s.logger.Error().Err(err).Str("quota_id", quota.ID).Msg("Failed to reset quota thresholds")
+		return
+	}
+
+	if len(crossed) == 0 {
+		return
+	}
+
+	// The primary key makes the insert the dedup point, so only one writer
+	// reports each crossing even with several replicas
+	rows, err := s.db.Query(ctx, `
+		INSERT INTO quota_threshold_alerts (quota_id, threshold_pct)
+		SELECT $1, unnest($2::int[])
+		ON CONFLICT (quota_id, threshold_pct) DO NOTHING
+		RETURNING threshold_pct
+	`, quota.ID, crossed)
+	if err != nil {
+		s.logger.Error().Err(err).Str("quota_id", quota.ID).Msg("Failed to record quota thresholds")
+		return
+	}
+	newlyCrossed, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		s.logger.Error().Err(err).Str("quota_id", quota.ID).Msg("Failed to record quota thresholds")
+		return
+	}
+
+	for _, threshold := range newlyCrossed {
+		quotaThresholdCrossings.WithLabelValues(strconv.Itoa(threshold)).Inc()
+
+		s.logger.Warn().
+			Str("domain_id", quota.EntityID).
+			Int("threshold", threshold).
+			Float64("usage_percent", usage).
+			Msg("Domain quota crossed warning threshold")
+
+		event := &models.QuotaThresholdEvent{
+			Event:        QuotaThresholdEventType,
+			QuotaID:      quota.ID,
+			DomainID:     quota.EntityID,
+			Threshold:    threshold,
+			UsedBytes:    quota.UsedBytes,
+			LimitBytes:   quota.TotalBytes,
+			UsagePercent: usage,
+			Timestamp:    time.Now().UTC(),
+		}
+		go func() {
+			if err := s.sendThresholdWebhook(context.Background(), event); err != nil {
+				s.logger.Error().Err(err).
+					Str("domain_id", event.DomainID).
+					Int("threshold", event.Threshold).
+					Msg("Failed to send quota threshold webhook")
+			}
+		}()
+	}
+}
+
+// sendThresholdWebhook posts a threshold event to the configured webhook URL
+func (s *Service) sendThresholdWebhook(ctx context.Context, event *models.QuotaThresholdEvent) error {
+	if s.cfg.QuotaWebhookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.QuotaWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.QuotaWebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(s.cfg.QuotaWebhookSecret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetDomainQuotaStatus returns a domain's usage against its quota and the
+// warning thresholds it has crossed
+func (s *Service) GetDomainQuotaStatus(ctx context.Context, domainID string) (*models.DomainQuotaStatus, error) {
+	quota, err := s.GetDomainQuota(ctx, domainID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrQuotaNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT threshold_pct FROM quota_threshold_alerts
+		WHERE quota_id = $1
+		ORDER BY threshold_pct
+	`, quota.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get crossed thresholds: %w", err)
+	}
+	crossed, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get crossed thresholds: %w", err)
+	}
+
+	return &models.DomainQuotaStatus{
+		DomainID:          domainID,
+		UsedBytes:         quota.UsedBytes,
+		LimitBytes:        quota.TotalBytes,
+		UsagePercent:      quota.UsagePercent(),
+		Thresholds:        s.thresholds,
+		CrossedThresholds: crossed,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package quota
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/oonrumail/storage/config"
+	"github.com/oonrumail/storage/models"
+)
+
+func TestNormalizeThresholds(t *testing.T) {
+	got := normalizeThresholds([]int{95, 80, 0, 95, -5, 100})
+	want := []int{80, 95, 100}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeThresholds() = %v, want %v", got, want)
+	}
+}
+
+func TestCrossedThresholds(t *testing.T) {
+	thresholds := []int{80, 95}
+
+	tests := []struct {
+		name         string
+		usagePercent float64
+		expected     []int
+	}{
+		{"below all thresholds", 50, []int{}},
+		{"exactly at first threshold", 80, []int{80}},
+		{"between thresholds", 90, []int{80}},
+		{"above all thresholds", 99.5, []int{80, 95}},
+		{"over quota", 120, []int{80, 95}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := crossedThresholds(thresholds, tt.usagePercent)
+			if got == nil {
+				t.Fatal("crossedThresholds() returned nil, want empty slice")
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("crossedThresholds(%v) = %v, want %v", tt.usagePercent, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSendThresholdWebhook(t *testing.T) {
+	var received models.QuotaThresholdEvent
+	var signature string
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	svc := &Service{
+		cfg:        &config.Config{QuotaWebhookURL: server.URL, QuotaWebhookSecret: "secret"},
+		httpClient: server.Client(),
+	}
+
+	event := &models.QuotaThresholdEvent{
+		Event:      QuotaThresholdEventType,
+		DomainID:   "domain-1",
+		Threshold:  80,
+		UsedBytes:  800,
+		LimitBytes: 1000,
+	}
+	if err := svc.sendThresholdWebhook(context.Background(), event); err != nil {
+		t.Fatalf("sendThresholdWebhook() error = %v", err)
+	}
+
+	if received.DomainID != "domain-1" || received.Threshold != 80 {
+		t.Errorf("Received event = %+v", received)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("Signature = %q, want %q", signature, want)
+	}
+}
+
+func TestSendThresholdWebhook_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	svc := &Service{
+		cfg:        &config.Config{QuotaWebhookURL: server.URL},
+		httpClient: server.Client(),
+	}
+
+	if err := svc.sendThresholdWebhook(context.Background(), &models.QuotaThresholdEvent{}); err == nil {
+		t.Error("Expected error for non-2xx webhook response")
+	}
+}
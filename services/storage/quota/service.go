@@ -3,6 +3,7 @@ package quota
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
@@ -21,6 +22,8 @@ type Service struct {
 	cfg          *config.Config
 	logger       zerolog.Logger
 	reservations sync.Map // reservationID -> *Reservation
+	thresholds   []int    // domain usage warning thresholds, ascending
+	httpClient   *http.Client
 }
 
 // Reservation represents a pending quota reservation
@@ -35,9 +38,11 @@ type Reservation struct {
 // NewService creates a new quota service
 func NewService(db *pgxpool.Pool, cfg *config.Config, logger zerolog.Logger) *Service {
 	svc := &Service{
-		db:     db,
-		cfg:    cfg,
-		logger: logger.With().Str("component", "quota_service").Logger(),
+		db:         db,
+		cfg:        cfg,
+		logger:     logger.With().Str("component", "quota_service").Logger(),
+		thresholds: normalizeThresholds(cfg.QuotaAlertThresholds),
+		httpClient: &http.Client{Timeout: cfg.QuotaWebhookTimeout},
 	}
 
 	// Start reservation cleanup goroutine
@@ -223,7 +228,15 @@ func (s *Service) UpdateQuota(ctx context.Context, quotaID string, req *models.U
 		return nil, fmt.Errorf("failed to update quota: %w", err)
 	}
 
-	return s.getQuotaByID(ctx, quotaID)
+	quota, err := s.getQuotaByID(ctx, quotaID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A new limit can move usage across a warning threshold
+	s.checkThresholds(ctx, quota)
+
+	return quota, nil
 }
 
 // DeleteQuota deletes a quota
@@ -378,15 +391,22 @@ func (s *Service) updateParentUsage(ctx context.Context, quotaID string, deltaBy
 		UPDATE quotas 
 		SET used_bytes = GREATEST(0, used_bytes + $1), updated_at = $2
 		WHERE id = $3
-		RETURNING parent_id
+		RETURNING parent_id, level
 	`
 
 	var parentID *string
-	err := s.db.QueryRow(ctx, query, deltaBytes, time.Now(), quotaID).Scan(&parentID)
+	var level models.QuotaLevel
+	err := s.db.QueryRow(ctx, query, deltaBytes, time.Now(), quotaID).Scan(&parentID, &level)
 	if err != nil {
 		return nil
 	}
 
+	if level == models.QuotaLevelDomain {
+		if quota, err := s.getQuotaByID(ctx, quotaID); err == nil {
+			s.checkThresholds(ctx, quota)
+		}
+	}
+
 	if parentID != nil && *parentID != "" {
 		return s.updateParentUsage(ctx, *parentID, deltaBytes)
 	}
@@ -77,6 +77,10 @@ func (d *DomainAwareStorage) GetPresignedDownloadURL(ctx context.Context, key st
 	return d.storage.GetPresignedDownloadURL(ctx, key, expiry)
 }
 
+func (d *DomainAwareStorage) GetPresignedDownloadURLAs(ctx context.Context, key, filename, contentType string, expiry time.Duration) (string, error) {
+	return d.storage.GetPresignedDownloadURLAs(ctx, key, filename, contentType, expiry)
+}
+
 func (d *DomainAwareStorage) Copy(ctx context.Context, sourceKey, destKey string) error {
 	return d.storage.Copy(ctx, sourceKey, destKey)
 }
@@ -24,6 +24,7 @@ type StorageService interface {
 	// Presigned URLs
 	GetPresignedUploadURL(ctx context.Context, key string, contentType string, expiry time.Duration) (string, error)
 	GetPresignedDownloadURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	GetPresignedDownloadURLAs(ctx context.Context, key, filename, contentType string, expiry time.Duration) (string, error)
 
 	// Copy/Move operations
 	Copy(ctx context.Context, sourceKey, destKey string) error
@@ -125,6 +126,7 @@ type QuotaService interface {
 	// Get quota info
 	GetQuotaInfo(ctx context.Context, mailboxID string) (*models.QuotaInfo, error)
 	GetDomainQuotaInfo(ctx context.Context, domainID string) (*models.QuotaInfo, error)
+	GetDomainQuotaStatus(ctx context.Context, domainID string) (*models.DomainQuotaStatus, error)
 
 	// Reserve/Release quota for pending operations
 	ReserveQuota(ctx context.Context, mailboxID string, bytes int64) (reservationID string, err error)
@@ -191,6 +193,11 @@ type DeletionService interface {
 	GetDeletionAuditLog(ctx context.Context, jobID string) ([]*models.DeletionAuditLog, error)
 }
 
+// AccessService checks callers' permissions on domains
+type AccessService interface {
+	CanAccessDomain(ctx context.Context, userID, domainID string) (bool, error)
+}
+
 // DeduplicationService defines the interface for attachment deduplication
 type DeduplicationService interface {
 	// Check for existing duplicate
@@ -214,6 +221,13 @@ type DeduplicationService interface {
 	// Cleanup orphaned attachments
 	CleanupOrphans(ctx context.Context) (int, int64, error) // returns count, bytes freed
 	
+	// Reference counts
+	GetReferenceCount(ctx context.Context, orgID string, contentHash string) (int, error)
+	RecountReferences(ctx context.Context, orgID string) (int, error) // returns records corrected
+	
+	// Drop a domain's or user's references to a blob; returns whether it is deduplicated and the remaining refs
+	ReleaseBlob(ctx context.Context, orgID, domainID, userID, storageKey string) (bool, int, error)
+	
 	// Statistics
 	GetStats(ctx context.Context, orgID string) (*models.DeduplicationStats, error)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
 	"strings"
 	"time"
 
@@ -29,22 +30,20 @@ type S3StorageService struct {
 
 // NewS3StorageService creates a new S3-compatible storage service
 func NewS3StorageService(cfg *config.Config, logger zerolog.Logger) (*S3StorageService, error) {
-	// Create custom endpoint resolver for MinIO/S3-compatible services
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		if cfg.S3Endpoint != "" {
-			return aws.Endpoint{
-				URL:               cfg.S3Endpoint,
-				HostnameImmutable: true,
-				SigningRegion:     cfg.S3Region,
-			}, nil
-		}
-		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
-	})
+	// AWS resolves its regional endpoint; MinIO/S3-compatible services use the
+	// configured one
+	endpoint, publicEndpoint := cfg.S3Endpoint, cfg.S3PublicEndpoint
+	if cfg.S3Provider == "aws" {
+		endpoint, publicEndpoint = "", ""
+	}
+	if publicEndpoint == "" {
+		publicEndpoint = endpoint
+	}
 
 	// Load AWS config
 	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(),
 		awsconfig.WithRegion(cfg.S3Region),
-		awsconfig.WithEndpointResolverWithOptions(customResolver),
+		awsconfig.WithEndpointResolverWithOptions(endpointResolver(endpoint, cfg.S3Region)),
 		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
 			cfg.S3AccessKey,
 			cfg.S3SecretKey,
@@ -55,13 +54,18 @@ func NewS3StorageService(cfg *config.Config, logger zerolog.Logger) (*S3StorageS
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	// Create S3 client
-	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+	s3Options := func(o *s3.Options) {
 		o.UsePathStyle = cfg.S3UsePathStyle
-	})
+	}
+
+	// Create S3 client
+	client := s3.NewFromConfig(awsCfg, s3Options)
 
-	// Create presign client
-	presignClient := s3.NewPresignClient(client)
+	// Create presign client. URLs are signed for the endpoint clients reach,
+	// since the host is part of the signature and can't be rewritten later
+	presignCfg := awsCfg.Copy()
+	presignCfg.EndpointResolverWithOptions = endpointResolver(publicEndpoint, cfg.S3Region)
+	presignClient := s3.NewPresignClient(s3.NewFromConfig(presignCfg, s3Options))
 
 	svc := &S3StorageService{
 		client:        client,
@@ -79,6 +83,21 @@ func NewS3StorageService(cfg *config.Config, logger zerolog.Logger) (*S3StorageS
 	return svc, nil
 }
 
+// endpointResolver resolves S3 to endpoint, signing for region. Without an
+// endpoint the SDK's default AWS resolution is used.
+func endpointResolver(endpoint, region string) aws.EndpointResolverWithOptionsFunc {
+	return func(service, _ string, options ...interface{}) (aws.Endpoint, error) {
+		if endpoint != "" {
+			return aws.Endpoint{
+				URL:               endpoint,
+				HostnameImmutable: true,
+				SigningRegion:     region,
+			}, nil
+		}
+		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+	}
+}
+
 // ensureBucketExists creates the bucket if it doesn't exist
 func (s *S3StorageService) ensureBucketExists(ctx context.Context) error {
 	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
@@ -318,6 +337,30 @@ func (s *S3StorageService) GetPresignedDownloadURL(ctx context.Context, key stri
 	return presignedReq.URL, nil
 }
 
+// GetPresignedDownloadURLAs generates a presigned download URL that serves
+// the object as an attachment named filename with the given content type
+func (s *S3StorageService) GetPresignedDownloadURLAs(ctx context.Context, key, filename, contentType string, expiry time.Duration) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": filename})),
+	}
+	if contentType != "" {
+		input.ResponseContentType = aws.String(contentType)
+	}
+
+	if expiry == 0 {
+		expiry = s.presignExpiry
+	}
+
+	presignedReq, err := s.presignClient.PresignGetObject(ctx, input, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned download URL: %w", err)
+	}
+
+	return presignedReq.URL, nil
+}
+
 // Copy copies an object to a new key
 func (s *S3StorageService) Copy(ctx context.Context, sourceKey, destKey string) error {
 	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
@@ -384,6 +384,11 @@ func (w *DeduplicationWorker) Stop() {
 func (w *DeduplicationWorker) cleanup(ctx context.Context) {
 	w.logger.Info().Msg("Running deduplication cleanup")
 
+	// Repair drifted counts before looking for orphans
+	if _, err := w.dedup.RecountReferences(ctx, ""); err != nil {
+		w.logger.Error().Err(err).Msg("Reference recount failed")
+	}
+
 	count, bytesFreed, err := w.dedup.CleanupOrphans(ctx)
 	if err != nil {
 		w.logger.Error().Err(err).Msg("Deduplication cleanup failed")
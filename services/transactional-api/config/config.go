@@ -16,6 +16,15 @@ type Config struct {
 	RateLimit RateLimitConfig `yaml:"rateLimit"`
 	Tracking  TrackingConfig  `yaml:"tracking"`
 	Webhook   WebhookConfig   `yaml:"webhook"`
+	Batch     BatchConfig     `yaml:"batch"`
+	Bounce    BounceConfig    `yaml:"bounce"`
+	Pacing    PacingConfig    `yaml:"pacing"`
+	// Unsubscribe links injected into bulk messages
+	Unsubscribe UnsubscribeConfig `yaml:"unsubscribe"`
+	// Malware scanning of attachments before they are sent
+	Scanning ScanningConfig `yaml:"scanning"`
+	// Inbound parse: received mail posted to customer URLs as JSON
+	Inbound InboundConfig `yaml:"inbound"`
 }
 
 type ServerConfig struct {
@@ -23,6 +32,9 @@ type ServerConfig struct {
 	LogLevel       string   `yaml:"logLevel"`
 	AllowedOrigins []string `yaml:"allowedOrigins"`
 	InternalSecret string   `yaml:"internalSecret"`
+	// IPs and CIDR ranges of our own proxies, whose X-Forwarded-For and
+	// X-Real-IP headers are trusted to carry the client IP
+	TrustedProxies []string `yaml:"trustedProxies"`
 }
 
 type DatabaseConfig struct {
@@ -47,6 +59,9 @@ type SMTPConfig struct {
 	FromDomain         string `yaml:"fromDomain"`
 	PoolSize           int    `yaml:"poolSize"`
 	RetryCount         int    `yaml:"retryCount"`
+	// Sender of notices to account owners, such as a webhook being
+	// disabled. Defaults to no-reply@ the from domain.
+	NoticeFrom string `yaml:"noticeFrom"`
 }
 
 type RateLimitConfig struct {
@@ -65,12 +80,97 @@ type TrackingConfig struct {
 	ClickPath    string `yaml:"clickPath"`
 }
 
+type UnsubscribeConfig struct {
+	// Public base URL of this API; links point at {baseURL}/v1/unsubscribe/{token}
+	BaseURL string `yaml:"baseURL"`
+	// Key used to sign unsubscribe tokens so they can't be forged or guessed
+	SigningSecret string `yaml:"signingSecret"`
+}
+
+// ScanningConfig selects the malware scanner attachments go through
+type ScanningConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Provider string `yaml:"provider"` // "clamav"
+	// clamd address, "tcp://host:3310" or "unix:///run/clamav/clamd.sock"
+	ClamAVAddr string `yaml:"clamavAddr"`
+	// Seconds a scan may take
+	Timeout int `yaml:"timeout"`
+	// Accept attachments when the scanner times out or is unreachable,
+	// instead of rejecting the message
+	FailOpen bool `yaml:"failOpen"`
+}
+
+// InboundConfig controls inbound parse. Mail for an inbound route's
+// addresses is handed over by the SMTP server, parsed, and posted to the
+// route's URL with attachments stored for download.
+type InboundConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Directory attachments are kept in until their links expire
+	StorageDir string `yaml:"storageDir"`
+	// Public base URL of this API; attachment links point at
+	// {baseURL}/v1/inbound/attachments/...
+	BaseURL string `yaml:"baseURL"`
+	// Key used to sign attachment links
+	SigningSecret string `yaml:"signingSecret"`
+	// Largest message accepted, and largest attachment stored, in bytes
+	MaxMessageSize    int64 `yaml:"maxMessageSize"`
+	MaxAttachmentSize int64 `yaml:"maxAttachmentSize"`
+	// Seconds attachment links stay valid
+	AttachmentTTL int `yaml:"attachmentTTL"`
+}
+
 type WebhookConfig struct {
 	Timeout        int    `yaml:"timeout"`
 	MaxRetries     int    `yaml:"maxRetries"`
 	RetryInterval  int    `yaml:"retryInterval"`
 	SigningSecret  string `yaml:"signingSecret"`
 	WorkerPoolSize int    `yaml:"workerPoolSize"`
+	// Seconds the previous secret stays valid after a rotation
+	SecretGracePeriod int `yaml:"secretGracePeriod"`
+	// Largest time window, in seconds, and number of events a replay may cover
+	MaxReplayWindow int `yaml:"maxReplayWindow"`
+	MaxReplayEvents int `yaml:"maxReplayEvents"`
+	// Seconds to wait before each retry of a failed delivery, give or take
+	// 10%. Retries past the end of the schedule wait as long as the last one.
+	RetrySchedule []int `yaml:"retrySchedule"`
+	// Seconds an endpoint may fail without a single success before failed
+	// deliveries are dead-lettered instead of retried
+	CircuitBreakAfter int `yaml:"circuitBreakAfter"`
+	// Seconds an endpoint may fail without a single success before the
+	// webhook is disabled and the organization's owner notified
+	DisableAfter int `yaml:"disableAfter"`
+}
+
+type BounceConfig struct {
+	// A recipient whose mailbox was full this many times within the window
+	// (in seconds) is suppressed like a hard bounce
+	MailboxFullThreshold int `yaml:"mailboxFullThreshold"`
+	MailboxFullWindow    int `yaml:"mailboxFullWindow"`
+}
+
+// PacingConfig limits how fast the sender delivers to each recipient domain
+type PacingConfig struct {
+	// Messages per second, and burst, for domains without an override
+	DefaultRate  float64                       `yaml:"defaultRate"`
+	DefaultBurst int                           `yaml:"defaultBurst"`
+	Domains      map[string]DomainPacingConfig `yaml:"domains"`
+	// A 4xx throttle response multiplies the domain's rate by BackoffFactor,
+	// down to MinRate, until BackoffPeriod seconds pass without another one
+	BackoffFactor float64 `yaml:"backoffFactor"`
+	BackoffPeriod int     `yaml:"backoffPeriod"`
+	MinRate       float64 `yaml:"minRate"`
+	// Seconds before a throttled message is retried, times its attempt number
+	RetryDelay int `yaml:"retryDelay"`
+}
+
+type DomainPacingConfig struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type BatchConfig struct {
+	MaxRecipients int `yaml:"maxRecipients"`
+	MaxSuppressed int `yaml:"maxSuppressed"` // Reject the whole batch above this many suppressed recipients
 }
 
 // expandEnvWithDefaults expands environment variables with default value support
@@ -152,6 +252,80 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Webhook.WorkerPoolSize == 0 {
 		cfg.Webhook.WorkerPoolSize = 10
 	}
+	if cfg.Webhook.SecretGracePeriod == 0 {
+		cfg.Webhook.SecretGracePeriod = 86400 // 24 hours
+	}
+	if cfg.Webhook.MaxReplayWindow == 0 {
+		cfg.Webhook.MaxReplayWindow = 604800 // 7 days
+	}
+	if cfg.Webhook.MaxReplayEvents == 0 {
+		cfg.Webhook.MaxReplayEvents = 1000
+	}
+	if len(cfg.Webhook.RetrySchedule) == 0 {
+		cfg.Webhook.RetrySchedule = []int{60, 300, 1800, 7200, 21600} // 1m, 5m, 30m, 2h, 6h
+	}
+	if cfg.Webhook.CircuitBreakAfter == 0 {
+		cfg.Webhook.CircuitBreakAfter = 43200 // 12 hours
+	}
+	if cfg.Webhook.DisableAfter == 0 {
+		cfg.Webhook.DisableAfter = 259200 // 3 days
+	}
+	if cfg.SMTP.NoticeFrom == "" && cfg.SMTP.FromDomain != "" {
+		cfg.SMTP.NoticeFrom = "no-reply@" + cfg.SMTP.FromDomain
+	}
+	if cfg.Bounce.MailboxFullThreshold == 0 {
+		cfg.Bounce.MailboxFullThreshold = 3
+	}
+	if cfg.Bounce.MailboxFullWindow == 0 {
+		cfg.Bounce.MailboxFullWindow = 604800 // 7 days
+	}
+	if cfg.Pacing.DefaultRate == 0 {
+		cfg.Pacing.DefaultRate = 10
+	}
+	if cfg.Pacing.DefaultBurst == 0 {
+		cfg.Pacing.DefaultBurst = 20
+	}
+	if cfg.Pacing.Domains == nil {
+		cfg.Pacing.Domains = map[string]DomainPacingConfig{
+			"gmail.com":   {Rate: 5, Burst: 10},
+			"outlook.com": {Rate: 3, Burst: 6},
+			"hotmail.com": {Rate: 3, Burst: 6},
+			"yahoo.com":   {Rate: 2, Burst: 5},
+		}
+	}
+	if cfg.Pacing.BackoffFactor == 0 {
+		cfg.Pacing.BackoffFactor = 0.5
+	}
+	if cfg.Pacing.BackoffPeriod == 0 {
+		cfg.Pacing.BackoffPeriod = 600 // 10 minutes
+	}
+	if cfg.Pacing.MinRate == 0 {
+		cfg.Pacing.MinRate = 0.1
+	}
+	if cfg.Pacing.RetryDelay == 0 {
+		cfg.Pacing.RetryDelay = 300 // 5 minutes
+	}
+	if cfg.Batch.MaxRecipients == 0 {
+		cfg.Batch.MaxRecipients = 1000
+	}
+	if cfg.Batch.MaxSuppressed == 0 {
+		cfg.Batch.MaxSuppressed = 100
+	}
+	if cfg.Scanning.Timeout == 0 {
+		cfg.Scanning.Timeout = 30
+	}
+	if cfg.Inbound.StorageDir == "" {
+		cfg.Inbound.StorageDir = "/var/lib/transactional-api/inbound"
+	}
+	if cfg.Inbound.MaxMessageSize == 0 {
+		cfg.Inbound.MaxMessageSize = 25 << 20 // 25MB
+	}
+	if cfg.Inbound.MaxAttachmentSize == 0 {
+		cfg.Inbound.MaxAttachmentSize = 10 << 20 // 10MB
+	}
+	if cfg.Inbound.AttachmentTTL == 0 {
+		cfg.Inbound.AttachmentTTL = 604800 // 7 days
+	}
 
 	return &cfg, nil
 }
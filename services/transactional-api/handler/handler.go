@@ -85,10 +85,19 @@ func (h *Handler) Router() chi.Router {
 
 		// Message endpoints
 		r.Route("/messages", func(r chi.Router) {
-			r.Use(h.apiKeyMiddleware.RequireScope(models.ScopeRead))
-			r.Get("/", h.listMessages)
-			r.Get("/{id}", h.getMessage)
-			r.Get("/{id}/timeline", h.getMessageTimeline)
+			r.With(h.apiKeyMiddleware.RequireScope(models.ScopeSend)).
+				Post("/batch", h.sendMessageBatch)
+			r.With(h.apiKeyMiddleware.RequireScope(models.ScopeSend)).
+				Delete("/batch/{batchId}", h.cancelBatch)
+			r.With(h.apiKeyMiddleware.RequireScope(models.ScopeSend)).
+				Delete("/{id}", h.cancelMessage)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.apiKeyMiddleware.RequireScope(models.ScopeRead))
+				r.Get("/", h.listMessages)
+				r.Get("/{id}", h.getMessage)
+				r.Get("/{id}/timeline", h.getMessageTimeline)
+			})
 		})
 
 		// Template endpoints
@@ -2,11 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"transactional-api/middleware"
 	"transactional-api/models"
 	"transactional-api/repository"
+	"transactional-api/service"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -73,6 +75,42 @@ func (h *Handler) sendBatch(w http.ResponseWriter, r *http.Request) {
 	h.jsonResponse(w, http.StatusAccepted, resp)
 }
 
+// sendMessageBatch handles POST /api/v1/messages/batch
+func (h *Handler) sendMessageBatch(w http.ResponseWriter, r *http.Request) {
+	var req models.MessageBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid_json", "Invalid JSON in request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		h.validationError(w, err)
+		return
+	}
+
+	apiKey := middleware.GetAPIKey(r.Context())
+	if apiKey == nil {
+		h.errorResponse(w, http.StatusUnauthorized, "unauthorized", "API key required")
+		return
+	}
+
+	resp, err := h.senderService.SendMessageBatch(r.Context(), &req, apiKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTooManySuppressed):
+			h.errorResponse(w, http.StatusUnprocessableEntity, "too_many_suppressed", err.Error())
+		case errors.Is(err, repository.ErrDuplicateIdempotencyKey):
+			h.errorResponse(w, http.StatusConflict, "duplicate_batch", "A batch with this idempotency key was already accepted")
+		default:
+			h.logger.Error().Err(err).Msg("Failed to send message batch")
+			h.errorResponse(w, http.StatusInternalServerError, "batch_failed", err.Error())
+		}
+		return
+	}
+
+	h.jsonResponse(w, http.StatusAccepted, resp)
+}
+
 // listMessages handles GET /api/v1/messages
 func (h *Handler) listMessages(w http.ResponseWriter, r *http.Request) {
 	apiKey := middleware.GetAPIKey(r.Context())
@@ -139,6 +177,61 @@ func (h *Handler) getMessage(w http.ResponseWriter, r *http.Request) {
 	h.jsonResponse(w, http.StatusOK, msg)
 }
 
+// cancelMessage handles DELETE /api/v1/messages/{id}
+func (h *Handler) cancelMessage(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.parseUUID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	apiKey := middleware.GetAPIKey(r.Context())
+	if apiKey == nil {
+		h.errorResponse(w, http.StatusUnauthorized, "unauthorized", "API key required")
+		return
+	}
+
+	if err := h.senderService.CancelMessage(r.Context(), id, apiKey.DomainID); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrMessageNotFound):
+			h.errorResponse(w, http.StatusNotFound, "not_found", "Message not found")
+		case errors.Is(err, repository.ErrMessageNotCancellable):
+			h.errorResponse(w, http.StatusConflict, "already_sent", "Message has already been sent")
+		default:
+			h.logger.Error().Err(err).Msg("Failed to cancel message")
+			h.errorResponse(w, http.StatusInternalServerError, "cancel_failed", "Failed to cancel message")
+		}
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message_id": id.String(),
+		"status":     string(models.MessageStatusCancelled),
+	})
+}
+
+// cancelBatch handles DELETE /api/v1/messages/batch/{batchId}
+func (h *Handler) cancelBatch(w http.ResponseWriter, r *http.Request) {
+	batchID, ok := h.parseUUID(w, r, "batchId")
+	if !ok {
+		return
+	}
+
+	apiKey := middleware.GetAPIKey(r.Context())
+	if apiKey == nil {
+		h.errorResponse(w, http.StatusUnauthorized, "unauthorized", "API key required")
+		return
+	}
+
+	resp, err := h.senderService.CancelBatch(r.Context(), batchID, apiKey.DomainID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to cancel batch")
+		h.errorResponse(w, http.StatusInternalServerError, "cancel_failed", "Failed to cancel batch")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, resp)
+}
+
 // getMessageTimeline handles GET /api/v1/messages/{id}/timeline
 func (h *Handler) getMessageTimeline(w http.ResponseWriter, r *http.Request) {
 	id, ok := h.parseUUID(w, r, "id")
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"transactional-api/middleware"
+	"transactional-api/models"
+	"transactional-api/repository"
+	"transactional-api/scanner"
+	"transactional-api/service"
+)
+
+// attachmentScanError describes a message rejected by the attachment scan
+type attachmentScanError struct {
+	status  int
+	message string
+}
+
+// scanAttachments runs a message's attachments through the malware scanner.
+// Infected attachments are rejected with 422; when the scanner fails closed
+// the message is rejected with 503 so the client can retry.
+func (h *SendHandler) scanAttachments(r *http.Request, orgID uuid.UUID, attachments []models.Attachment) *attachmentScanError {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	var apiKeyID *uuid.UUID
+	if key, ok := r.Context().Value(middleware.ContextKeyAPIKey).(*repository.APIKeyResult); ok {
+		apiKeyID = &key.ID
+	}
+
+	err := h.attachmentScanner.Check(r.Context(), orgID, apiKeyID, attachments)
+	if err == nil {
+		return nil
+	}
+
+	var infected *service.InfectedAttachmentError
+	switch {
+	case errors.As(err, &infected):
+		return &attachmentScanError{status: http.StatusUnprocessableEntity, message: infected.Error()}
+	case errors.Is(err, service.ErrInvalidAttachment):
+		return &attachmentScanError{status: http.StatusBadRequest, message: err.Error()}
+	case errors.Is(err, scanner.ErrUnavailable):
+		h.logger.Error("Attachment scan failed", zap.Error(err))
+		return &attachmentScanError{status: http.StatusServiceUnavailable, message: "Attachment scanning is unavailable, try again later"}
+	}
+	h.logger.Error("Attachment scan failed", zap.Error(err))
+	return &attachmentScanError{status: http.StatusInternalServerError, message: "Attachment scan failed"}
+}
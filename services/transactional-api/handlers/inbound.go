@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"transactional-api/middleware"
+	"transactional-api/models"
+	"transactional-api/repository"
+	"transactional-api/service"
+)
+
+// InboundHandler manages inbound parse routes, takes messages handed over
+// by the SMTP server, and serves their attachments
+type InboundHandler struct {
+	repo    *repository.InboundRouteRepository
+	service *service.InboundService
+	logger  *zap.Logger
+}
+
+func NewInboundHandler(repo *repository.InboundRouteRepository, service *service.InboundService, logger *zap.Logger) *InboundHandler {
+	return &InboundHandler{repo: repo, service: service, logger: logger}
+}
+
+func inboundRouteResponse(route *models.InboundRoute) models.InboundRouteResponse {
+	return models.InboundRouteResponse{
+		ID:        route.ID,
+		Hostname:  route.Hostname,
+		LocalPart: route.LocalPart,
+		URL:       route.URL,
+		IsActive:  route.IsActive,
+		CreatedAt: route.CreatedAt,
+		UpdatedAt: route.UpdatedAt,
+	}
+}
+
+func (h *InboundHandler) List(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	page, pageSize := getPagination(r)
+
+	routes, total, err := h.repo.List(r.Context(), orgID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	responses := make([]models.InboundRouteResponse, len(routes))
+	for i, route := range routes {
+		responses[i] = inboundRouteResponse(route)
+	}
+
+	writeJSON(w, http.StatusOK, models.PaginatedResponse[models.InboundRouteResponse]{
+		Data:       responses,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalCount: total,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
+
+func (h *InboundHandler) Create(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+
+	var req models.CreateInboundRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if strings.ContainsAny(req.LocalPart, "@ \t") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid local part"})
+		return
+	}
+
+	route, err := h.repo.Create(r.Context(), orgID, &req)
+	if errors.Is(err, repository.ErrInboundRouteExists) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Return with secret (only on creation)
+	resp := inboundRouteResponse(route)
+	resp.Secret = route.Secret
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (h *InboundHandler) Get(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	routeID, err := uuid.Parse(chi.URLParam(r, "routeId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid route ID"})
+		return
+	}
+
+	route, err := h.repo.GetByID(r.Context(), routeID, orgID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, inboundRouteResponse(route))
+}
+
+func (h *InboundHandler) Update(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	routeID, err := uuid.Parse(chi.URLParam(r, "routeId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid route ID"})
+		return
+	}
+
+	var req models.UpdateInboundRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	route, err := h.repo.Update(r.Context(), routeID, orgID, &req)
+	if errors.Is(err, repository.ErrInboundRouteNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, inboundRouteResponse(route))
+}
+
+func (h *InboundHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	routeID, err := uuid.Parse(chi.URLParam(r, "routeId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid route ID"})
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), routeID, orgID); err != nil {
+		if errors.Is(err, repository.ErrInboundRouteNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Receive takes a raw message from the SMTP server, with its envelope in the
+// X-Mail-From and X-Rcpt-To (comma-separated) headers. 404 tells the SMTP
+// server no recipient has a route, 413 that the message is too large.
+func (h *InboundHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.service.MaxMessageSize())
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "Message too large"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Failed to read message"})
+		return
+	}
+
+	var rcptTo []string
+	for _, rcpt := range strings.Split(r.Header.Get("X-Rcpt-To"), ",") {
+		if rcpt = strings.TrimSpace(rcpt); rcpt != "" {
+			rcptTo = append(rcptTo, rcpt)
+		}
+	}
+	if len(rcptTo) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "X-Rcpt-To is required"})
+		return
+	}
+
+	queued, err := h.service.Receive(r.Context(), r.Header.Get("X-Mail-From"), rcptTo, raw)
+	switch {
+	case errors.Is(err, service.ErrNoInboundRoute):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrInvalidInboundMessage):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Error("Failed to receive inbound message", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to process message"})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]int{"queued": queued})
+}
+
+// Attachment serves an attachment of a parsed message. It needs no API key:
+// the signed link identifies the attachment and when it expires.
+func (h *InboundHandler) Attachment(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filename := query.Get("name")
+	contentType := query.Get("type")
+
+	file, err := h.service.OpenAttachment(chi.URLParam(r, "attachmentId"), filename, contentType, query.Get("expires"), query.Get("signature"))
+	switch {
+	case errors.Is(err, service.ErrInvalidAttachmentLink):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrInboundAttachmentGone):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Error("Failed to open inbound attachment", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to open attachment"})
+		return
+	}
+	defer file.Close()
+
+	// Always a download, so stored HTML or SVG never renders on our origin
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	io.Copy(w, file)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"transactional-api/middleware"
+	"transactional-api/models"
+	"transactional-api/repository"
+	"transactional-api/service"
+)
+
+// messageLimitError describes a message that exceeds its API key's limits
+type messageLimitError struct {
+	status  int
+	message string
+}
+
+// checkMessageLimits compares a message's size and recipient count with the
+// limits of the API key in the request context. The recipient limit is
+// checked first, so a message over both limits reports the recipient error.
+func checkMessageLimits(r *http.Request, size int64, recipients int) *messageLimitError {
+	key, ok := r.Context().Value(middleware.ContextKeyAPIKey).(*repository.APIKeyResult)
+	if !ok {
+		return nil
+	}
+	limits := service.KeyMessageLimits(key)
+
+	if recipients > limits.MaxRecipients {
+		return &messageLimitError{
+			status: http.StatusBadRequest,
+			message: fmt.Sprintf("Message has %d recipients; this API key allows at most %d (to, cc and bcc combined)",
+				recipients, limits.MaxRecipients),
+		}
+	}
+
+	if size > limits.MaxMessageSize {
+		return &messageLimitError{
+			status: http.StatusRequestEntityTooLarge,
+			message: fmt.Sprintf("Message is %d bytes; this API key allows at most %d bytes including headers and attachments",
+				size, limits.MaxMessageSize),
+		}
+	}
+
+	return nil
+}
+
+// headerSize is the size of a header line on the wire
+func headerSize(name, value string) int64 {
+	return int64(len(name) + len(": ") + len(value) + len("\r\n"))
+}
+
+func addressSize(a models.EmailAddress) int64 {
+	return int64(len(a.Name) + len(a.Email) + len(` "" <>, `))
+}
+
+// attachmentsSize counts attachment content as sent, base64 encoded.
+// Inline images are attachments with a content ID, so they count too.
+func attachmentsSize(attachments []models.Attachment) int64 {
+	var size int64
+	for _, a := range attachments {
+		size += int64(len(a.Content) + len(a.Filename) + len(a.ContentType) + len(a.ContentID))
+	}
+	return size
+}
+
+// sendEmailRequestSize estimates the size of the message built from req.
+// Images embedded in the HTML body as data URIs are part of the body.
+func sendEmailRequestSize(req *models.SendEmailRequest) int64 {
+	size := headerSize("From", req.From.Email) + int64(len(req.From.Name))
+	if req.ReplyTo != nil {
+		size += headerSize("Reply-To", req.ReplyTo.Email) + int64(len(req.ReplyTo.Name))
+	}
+	for _, list := range [][]models.EmailAddress{req.To, req.CC, req.BCC} {
+		for _, a := range list {
+			size += addressSize(a)
+		}
+	}
+	size += headerSize("Subject", req.Subject)
+	for name, value := range req.Headers {
+		size += headerSize(name, value)
+	}
+
+	size += int64(len(req.TextBody) + len(req.HTMLBody))
+	return size + attachmentsSize(req.Attachments)
+}
+
+// sendRequestSize estimates the size of the message built from req
+func sendRequestSize(req *models.SendRequest) int64 {
+	size := headerSize("From", req.From) + headerSize("Reply-To", req.ReplyTo)
+	for _, list := range [][]string{req.To, req.CC, req.BCC} {
+		for _, addr := range list {
+			size += int64(len(addr) + len(", "))
+		}
+	}
+	size += headerSize("Subject", req.Subject)
+	for name, value := range req.Headers {
+		size += headerSize(name, value)
+	}
+
+	size += int64(len(req.Text) + len(req.HTML))
+	return size + attachmentsSize(req.Attachments)
+}
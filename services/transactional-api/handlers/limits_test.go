@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"transactional-api/middleware"
+	"transactional-api/models"
+	"transactional-api/repository"
+	"transactional-api/service"
+)
+
+func requestWithKey(key *repository.APIKeyResult) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/v1/send", nil)
+	return r.WithContext(context.WithValue(r.Context(), middleware.ContextKeyAPIKey, key))
+}
+
+func TestCheckMessageLimits(t *testing.T) {
+	free := service.TierMessageLimits(service.TierFree)
+	overrideSize := int64(1000)
+	overrideRecipients := 2
+
+	tests := []struct {
+		name       string
+		key        *repository.APIKeyResult
+		size       int64
+		recipients int
+		wantStatus int
+	}{
+		{"within tier limits", &repository.APIKeyResult{SubscriptionTier: service.TierFree}, 1024, 1, 0},
+		{"too many recipients", &repository.APIKeyResult{SubscriptionTier: service.TierFree}, 1024, free.MaxRecipients + 1, http.StatusBadRequest},
+		{"too large", &repository.APIKeyResult{SubscriptionTier: service.TierFree}, free.MaxMessageSize + 1, 1, http.StatusRequestEntityTooLarge},
+		{"higher tier allows more", &repository.APIKeyResult{SubscriptionTier: service.TierEnterprise}, free.MaxMessageSize + 1, free.MaxRecipients + 1, 0},
+		{"unknown tier uses free", &repository.APIKeyResult{SubscriptionTier: "legacy"}, 1024, free.MaxRecipients + 1, http.StatusBadRequest},
+		{"key size override", &repository.APIKeyResult{SubscriptionTier: service.TierEnterprise, MaxMessageSize: &overrideSize}, 1001, 1, http.StatusRequestEntityTooLarge},
+		{"key recipient override", &repository.APIKeyResult{SubscriptionTier: service.TierEnterprise, MaxRecipients: &overrideRecipients}, 100, 3, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limitErr := checkMessageLimits(requestWithKey(tt.key), tt.size, tt.recipients)
+			if tt.wantStatus == 0 {
+				if limitErr != nil {
+					t.Fatalf("checkMessageLimits() = %q, want no error", limitErr.message)
+				}
+				return
+			}
+			if limitErr == nil {
+				t.Fatalf("checkMessageLimits() = nil, want status %d", tt.wantStatus)
+			}
+			if limitErr.status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", limitErr.status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSendEmailRequestSize_CountsAttachmentsAndInlineImages(t *testing.T) {
+	base := models.SendEmailRequest{
+		From:     models.EmailAddress{Email: "sender@example.com"},
+		To:       []models.EmailAddress{{Email: "to@example.com"}},
+		Subject:  "Hello",
+		HTMLBody: "<p>Hi</p>",
+	}
+	baseSize := sendEmailRequestSize(&base)
+
+	image := strings.Repeat("A", 4096)
+
+	withDataURI := base
+	withDataURI.HTMLBody = `<p>Hi</p><img src="data:image/png;base64,` + image + `">`
+	if got := sendEmailRequestSize(&withDataURI); got < baseSize+int64(len(image)) {
+		t.Errorf("size with data URI image = %d, want at least %d", got, baseSize+int64(len(image)))
+	}
+
+	withInline := base
+	withInline.Attachments = []models.Attachment{
+		{Filename: "logo.png", Content: image, ContentType: "image/png", ContentID: "logo", Disposition: "inline"},
+	}
+	if got := sendEmailRequestSize(&withInline); got < baseSize+int64(len(image)) {
+		t.Errorf("size with inline attachment = %d, want at least %d", got, baseSize+int64(len(image)))
+	}
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -17,12 +19,13 @@ import (
 
 // Webhook Handler
 type WebhookHandler struct {
-	repo   *repository.WebhookRepository
-	logger *zap.Logger
+	repo    *repository.WebhookRepository
+	service *service.WebhookService
+	logger  *zap.Logger
 }
 
-func NewWebhookHandler(repo *repository.WebhookRepository, logger *zap.Logger) *WebhookHandler {
-	return &WebhookHandler{repo: repo, logger: logger}
+func NewWebhookHandler(repo *repository.WebhookRepository, service *service.WebhookService, logger *zap.Logger) *WebhookHandler {
+	return &WebhookHandler{repo: repo, service: service, logger: logger}
 }
 
 func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -39,13 +42,16 @@ func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
 	responses := make([]models.WebhookResponse, len(webhooks))
 	for i, wh := range webhooks {
 		responses[i] = models.WebhookResponse{
-			ID:            wh.ID,
-			URL:           wh.URL,
-			Events:        wh.Events,
-			IsActive:      wh.IsActive,
-			FailureCount:  wh.FailureCount,
-			LastTriggered: wh.LastTriggered,
-			CreatedAt:     wh.CreatedAt,
+			ID:             wh.ID,
+			URL:            wh.URL,
+			Events:         wh.Events,
+			IsActive:       wh.IsActive,
+			FailureCount:   wh.FailureCount,
+			LastTriggered:  wh.LastTriggered,
+			FailingSince:   wh.FailingSince,
+			DisabledAt:     wh.DisabledAt,
+			DisabledReason: wh.DisabledReason,
+			CreatedAt:      wh.CreatedAt,
 		}
 	}
 
@@ -118,13 +124,16 @@ func (h *WebhookHandler) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusOK, models.WebhookResponse{
-		ID:            webhook.ID,
-		URL:           webhook.URL,
-		Events:        webhook.Events,
-		IsActive:      webhook.IsActive,
-		FailureCount:  webhook.FailureCount,
-		LastTriggered: webhook.LastTriggered,
-		CreatedAt:     webhook.CreatedAt,
+		ID:             webhook.ID,
+		URL:            webhook.URL,
+		Events:         webhook.Events,
+		IsActive:       webhook.IsActive,
+		FailureCount:   webhook.FailureCount,
+		LastTriggered:  webhook.LastTriggered,
+		FailingSince:   webhook.FailingSince,
+		DisabledAt:     webhook.DisabledAt,
+		DisabledReason: webhook.DisabledReason,
+		CreatedAt:      webhook.CreatedAt,
 	})
 }
 
@@ -149,13 +158,16 @@ func (h *WebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusOK, models.WebhookResponse{
-		ID:            webhook.ID,
-		URL:           webhook.URL,
-		Events:        webhook.Events,
-		IsActive:      webhook.IsActive,
-		FailureCount:  webhook.FailureCount,
-		LastTriggered: webhook.LastTriggered,
-		CreatedAt:     webhook.CreatedAt,
+		ID:             webhook.ID,
+		URL:            webhook.URL,
+		Events:         webhook.Events,
+		IsActive:       webhook.IsActive,
+		FailureCount:   webhook.FailureCount,
+		LastTriggered:  webhook.LastTriggered,
+		FailingSince:   webhook.FailingSince,
+		DisabledAt:     webhook.DisabledAt,
+		DisabledReason: webhook.DisabledReason,
+		CreatedAt:      webhook.CreatedAt,
 	})
 }
 
@@ -175,11 +187,167 @@ func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RotateSecret issues a new signing secret, keeping the old one valid for the grace period
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid webhook ID"})
+		return
+	}
+
+	resp, err := h.service.RotateSecret(r.Context(), webhookID, orgID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (h *WebhookHandler) Test(w http.ResponseWriter, r *http.Request) {
 	// This would be implemented with webhook service
 	writeJSON(w, http.StatusOK, map[string]string{"message": "Test webhook sent"})
 }
 
+// ListDeliveries lists a webhook's delivery attempts, newest first.
+// ?status=dead lists the dead-lettered ones.
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid webhook ID"})
+		return
+	}
+
+	if _, err := h.repo.GetByID(r.Context(), webhookID, orgID); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	page, pageSize := getPagination(r)
+	query := &models.WebhookDeliveryQuery{
+		WebhookID: webhookID,
+		Limit:     pageSize,
+		Offset:    (page - 1) * pageSize,
+	}
+	if v := r.URL.Query().Get("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid success filter"})
+			return
+		}
+		query.Success = &success
+	}
+	if v := r.URL.Query().Get("status"); v != "" {
+		switch status := models.WebhookDeliveryStatus(v); status {
+		case models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed,
+			models.WebhookDeliveryRetrying, models.WebhookDeliveryDead:
+			query.Status = status
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid status filter"})
+			return
+		}
+	}
+
+	deliveries, total, err := h.repo.ListDeliveries(r.Context(), query)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	data := make([]models.WebhookDelivery, len(deliveries))
+	for i, d := range deliveries {
+		data[i] = *d
+	}
+
+	writeJSON(w, http.StatusOK, models.PaginatedResponse[models.WebhookDelivery]{
+		Data:       data,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalCount: total,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
+
+// ReplayDelivery re-sends the event of a past delivery, whether or not it succeeded
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid webhook ID"})
+		return
+	}
+	deliveryID, err := uuid.Parse(chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid delivery ID"})
+		return
+	}
+
+	if err := h.service.ReplayDelivery(r.Context(), orgID, webhookID, deliveryID); err != nil {
+		h.writeReplayError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, models.WebhookReplayResponse{Queued: 1})
+}
+
+// Replay re-sends every subscribed event between the since and until query
+// parameters (RFC 3339). until defaults to now.
+func (h *WebhookHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid webhook ID"})
+		return
+	}
+
+	since, err := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 timestamp"})
+		return
+	}
+	until := time.Now()
+	if v := r.URL.Query().Get("until"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "until must be an RFC 3339 timestamp"})
+			return
+		}
+	}
+
+	queued, err := h.service.ReplayEvents(r.Context(), orgID, webhookID, since, until)
+	if err != nil {
+		h.writeReplayError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, models.WebhookReplayResponse{
+		Queued: queued,
+		Since:  &since,
+		Until:  &until,
+	})
+}
+
+func (h *WebhookHandler) writeReplayError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, repository.ErrWebhookNotFound),
+		errors.Is(err, repository.ErrWebhookDeliveryNotFound),
+		errors.Is(err, repository.ErrEventNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrWebhookInactive):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrInvalidReplayWindow),
+		errors.Is(err, service.ErrReplayWindowTooLarge),
+		errors.Is(err, service.ErrTooManyReplayEvents):
+		status = http.StatusBadRequest
+	default:
+		h.logger.Error("Failed to replay webhook events", zap.Error(err))
+	}
+
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
 // Analytics Handler
 type AnalyticsHandler struct {
 	service *service.AnalyticsService
@@ -289,12 +457,14 @@ func (h *AnalyticsHandler) DomainStats(w http.ResponseWriter, r *http.Request) {
 // Event Handler
 type EventHandler struct {
 	repo           *repository.EventRepository
+	emailRepo      *repository.EmailRepository
 	webhookService *service.WebhookService
+	bounceService  *service.BounceService
 	logger         *zap.Logger
 }
 
-func NewEventHandler(repo *repository.EventRepository, webhookService *service.WebhookService, logger *zap.Logger) *EventHandler {
-	return &EventHandler{repo: repo, webhookService: webhookService, logger: logger}
+func NewEventHandler(repo *repository.EventRepository, emailRepo *repository.EmailRepository, webhookService *service.WebhookService, bounceService *service.BounceService, logger *zap.Logger) *EventHandler {
+	return &EventHandler{repo: repo, emailRepo: emailRepo, webhookService: webhookService, bounceService: bounceService, logger: logger}
 }
 
 func (h *EventHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -349,6 +519,29 @@ func (h *EventHandler) GetByMessageID(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, events)
 }
 
+// MessageEvents returns the ordered event timeline and latest delivery status of a message
+func (h *EventHandler) MessageEvents(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	messageID, err := uuid.Parse(chi.URLParam(r, "messageId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid message ID"})
+		return
+	}
+
+	if _, err := h.emailRepo.GetByID(r.Context(), messageID, orgID); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Message not found"})
+		return
+	}
+
+	events, err := h.repo.GetByMessageID(r.Context(), messageID, orgID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, service.BuildMessageTimeline(messageID, events))
+}
+
 func (h *EventHandler) ReceiveEvent(w http.ResponseWriter, r *http.Request) {
 	// Internal endpoint - receive events from SMTP server
 	var event models.EmailEvent
@@ -362,6 +555,22 @@ func (h *EventHandler) ReceiveEvent(w http.ResponseWriter, r *http.Request) {
 		event.Timestamp = time.Now()
 	}
 
+	// The SMTP server only knows the message ID, so attribute the event to its owner
+	if event.OrganizationID == uuid.Nil {
+		orgID, err := h.emailRepo.GetOrganizationID(r.Context(), event.MessageID)
+		if err != nil {
+			h.logger.Warn("Received event for unknown message",
+				zap.String("message_id", event.MessageID.String()),
+				zap.Error(err))
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Message not found"})
+			return
+		}
+		event.OrganizationID = orgID
+	}
+
+	// Classify bounces and suppress recipients that should not be retried
+	h.bounceService.Process(r.Context(), &event)
+
 	if err := h.webhookService.DispatchEvent(r.Context(), event.OrganizationID, &event); err != nil {
 		h.logger.Error("Failed to dispatch event", zap.Error(err))
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -404,7 +613,7 @@ func (h *SuppressionHandler) RemoveBounce(w http.ResponseWriter, r *http.Request
 	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	email := chi.URLParam(r, "email")
 
-	if err := h.repo.Remove(r.Context(), orgID, email, models.SuppressionBounce); err != nil {
+	if err := h.repo.Remove(r.Context(), orgID, email, models.SuppressionBounce, requestKeyID(r)); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -440,7 +649,7 @@ func (h *SuppressionHandler) AddUnsubscribe(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.repo.Add(r.Context(), orgID, req.Email, models.SuppressionUnsubscribe, req.Reason); err != nil {
+	if err := h.repo.Add(r.Context(), orgID, req.Email, models.SuppressionUnsubscribe, req.Reason, models.SuppressionSourceAPI); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -452,7 +661,7 @@ func (h *SuppressionHandler) RemoveUnsubscribe(w http.ResponseWriter, r *http.Re
 	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	email := chi.URLParam(r, "email")
 
-	if err := h.repo.Remove(r.Context(), orgID, email, models.SuppressionUnsubscribe); err != nil {
+	if err := h.repo.Remove(r.Context(), orgID, email, models.SuppressionUnsubscribe, requestKeyID(r)); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -483,7 +692,7 @@ func (h *SuppressionHandler) RemoveSpamReport(w http.ResponseWriter, r *http.Req
 	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	email := chi.URLParam(r, "email")
 
-	if err := h.repo.Remove(r.Context(), orgID, email, models.SuppressionSpamReport); err != nil {
+	if err := h.repo.Remove(r.Context(), orgID, email, models.SuppressionSpamReport, requestKeyID(r)); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -522,6 +731,9 @@ func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
 			RateLimit: key.RateLimit,
 			ExpiresAt: key.ExpiresAt,
 			CreatedAt: key.CreatedAt,
+
+			MessageLimits: service.KeyMessageLimits(key),
+			AllowedIPs:    key.AllowedIPs,
 		}
 	}
 
@@ -552,6 +764,7 @@ func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 	validScopes := map[models.APIKeyScope]bool{
 		models.ScopeSend: true, models.ScopeTemplates: true, models.ScopeWebhooks: true,
 		models.ScopeAnalytics: true, models.ScopeSuppression: true, models.ScopeRead: true,
+		models.ScopeAdmin: true,
 	}
 	scopeStrings := make([]string, len(req.Scopes))
 	for i, scope := range req.Scopes {
@@ -567,7 +780,7 @@ func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 		rateLimit = req.RateLimit
 	}
 
-	key, rawKey, err := h.repo.Create(r.Context(), orgID, req.Name, scopeStrings, rateLimit, req.ExpiresAt)
+	key, rawKey, err := h.repo.Create(r.Context(), orgID, req.Name, scopeStrings, rateLimit, req.ExpiresAt, req.MaxMessageSize, req.MaxRecipients)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -583,6 +796,46 @@ func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 		RateLimit: key.RateLimit,
 		ExpiresAt: key.ExpiresAt,
 		CreatedAt: key.CreatedAt,
+
+		MessageLimits: service.KeyMessageLimits(key),
+		AllowedIPs:    key.AllowedIPs,
+	})
+}
+
+func (h *APIKeyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid key ID"})
+		return
+	}
+
+	key, err := h.repo.GetByID(r.Context(), keyID, orgID)
+	if err == repository.ErrAPIKeyNotFound {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, models.APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		KeyPrefix: key.KeyPrefix,
+		Scopes:    scopes,
+		RateLimit: key.RateLimit,
+		ExpiresAt: key.ExpiresAt,
+		CreatedAt: key.CreatedAt,
+
+		MessageLimits: service.KeyMessageLimits(key),
+		AllowedIPs:    key.AllowedIPs,
 	})
 }
 
@@ -601,3 +854,98 @@ func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GetAllowlist returns the IP allowlist of an API key
+func (h *APIKeyHandler) GetAllowlist(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid key ID"})
+		return
+	}
+
+	key, err := h.repo.GetByID(r.Context(), keyID, orgID)
+	if err == repository.ErrAPIKeyNotFound {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	allowedIPs := key.AllowedIPs
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, models.APIKeyAllowlist{KeyID: key.ID, AllowedIPs: allowedIPs})
+}
+
+// UpdateAllowlist replaces the IP allowlist of an API key. Entries are
+// stored in canonical CIDR form; an empty list removes the restriction.
+func (h *APIKeyHandler) UpdateAllowlist(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid key ID"})
+		return
+	}
+
+	var req models.UpdateAPIKeyAllowlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	allowedIPs, err := middleware.NormalizeIPAllowlist(req.AllowedIPs)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = h.repo.UpdateAllowedIPs(r.Context(), keyID, orgID, allowedIPs)
+	if err == repository.ErrAPIKeyNotFound {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("API key IP allowlist updated",
+		zap.String("audit_event", "api_key.allowlist_updated"),
+		zap.String("key_id", keyID.String()),
+		zap.String("organization_id", orgID.String()),
+		zap.Strings("allowed_ips", allowedIPs))
+
+	writeJSON(w, http.StatusOK, models.APIKeyAllowlist{KeyID: keyID, AllowedIPs: allowedIPs})
+}
+
+// Pacing Handler
+type PacingHandler struct {
+	pacer  *service.DomainPacer
+	logger *zap.Logger
+}
+
+func NewPacingHandler(pacer *service.DomainPacer, logger *zap.Logger) *PacingHandler {
+	return &PacingHandler{pacer: pacer, logger: logger}
+}
+
+// State lists the delivery pacing of every recently used recipient domain
+func (h *PacingHandler) State(w http.ResponseWriter, r *http.Request) {
+	states, err := h.pacer.State(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get pacing state", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to get pacing state"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"domains": states})
+}
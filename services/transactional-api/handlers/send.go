@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -19,14 +21,18 @@ import (
 var validate = validator.New()
 
 type SendHandler struct {
-	emailService *service.EmailService
-	logger       *zap.Logger
+	emailService      *service.EmailService
+	attachmentScanner *service.AttachmentScanner
+	logger            *zap.Logger
 }
 
-func NewSendHandler(emailService *service.EmailService, logger *zap.Logger) *SendHandler {
+// NewSendHandler creates the send handler. attachmentScanner is nil when
+// attachment scanning is disabled.
+func NewSendHandler(emailService *service.EmailService, attachmentScanner *service.AttachmentScanner, logger *zap.Logger) *SendHandler {
 	return &SendHandler{
-		emailService: emailService,
-		logger:       logger,
+		emailService:      emailService,
+		attachmentScanner: attachmentScanner,
+		logger:            logger,
 	}
 }
 
@@ -49,8 +55,29 @@ func (h *SendHandler) Send(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Must provide text_body, html_body, or template_id"})
 		return
 	}
+	if req.TemplateVersion != nil && req.TemplateID == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "template_version requires template_id"})
+		return
+	}
+
+	// Each recipient of a bulk message gets their own copy and unsubscribe link
+	if req.Bulk && (len(req.CC) > 0 || len(req.BCC) > 0) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Bulk messages cannot have cc or bcc recipients"})
+		return
+	}
+
+	recipients := len(req.To) + len(req.CC) + len(req.BCC)
+	if limitErr := checkMessageLimits(r, sendEmailRequestSize(&req), recipients); limitErr != nil {
+		writeJSON(w, limitErr.status, map[string]string{"error": limitErr.message})
+		return
+	}
+
+	if scanErr := h.scanAttachments(r, orgID, req.Attachments); scanErr != nil {
+		writeJSON(w, scanErr.status, map[string]string{"error": scanErr.message})
+		return
+	}
 
-	result, err := h.emailService.Send(r.Context(), orgID, &req)
+	result, err := h.emailService.Send(r.Context(), orgID, requestKeyID(r), &req)
 	if err != nil {
 		h.logger.Error("Failed to send email", zap.Error(err))
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -79,7 +106,24 @@ func (h *SendHandler) SendBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.emailService.SendBatch(r.Context(), orgID, &req)
+	// Reject the whole batch if any message is over the key's limits, so
+	// nothing is partially sent
+	for i := range req.Messages {
+		msg := &req.Messages[i]
+		recipients := len(msg.To) + len(msg.CC) + len(msg.BCC)
+		if limitErr := checkMessageLimits(r, sendRequestSize(msg), recipients); limitErr != nil {
+			writeJSON(w, limitErr.status, map[string]string{"error": fmt.Sprintf("messages[%d]: %s", i, limitErr.message)})
+			return
+		}
+	}
+	for i := range req.Messages {
+		if scanErr := h.scanAttachments(r, orgID, req.Messages[i].Attachments); scanErr != nil {
+			writeJSON(w, scanErr.status, map[string]string{"error": fmt.Sprintf("messages[%d]: %s", i, scanErr.message)})
+			return
+		}
+	}
+
+	result, err := h.emailService.SendBatch(r.Context(), orgID, requestKeyID(r), &req)
 	if err != nil {
 		h.logger.Error("Failed to send batch", zap.Error(err))
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -99,11 +143,12 @@ func NewTemplateHandler(repo *repository.TemplateRepository, logger *zap.Logger)
 	return &TemplateHandler{repo: repo, logger: logger}
 }
 
+// List returns the key's own templates, the organization's shared ones and
+// the system gallery; each template's source says which
 func (h *TemplateHandler) List(w http.ResponseWriter, r *http.Request) {
-	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	page, pageSize := getPagination(r)
 
-	templates, total, err := h.repo.List(r.Context(), orgID, pageSize, (page-1)*pageSize)
+	templates, total, err := h.repo.List(r.Context(), templateAccess(r), pageSize, (page-1)*pageSize)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -119,8 +164,6 @@ func (h *TemplateHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *TemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
-	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
-
 	var req models.CreateTemplateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
@@ -132,7 +175,7 @@ func (h *TemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	template, err := h.repo.Create(r.Context(), orgID, &req)
+	template, err := h.repo.Create(r.Context(), templateAccess(r), &req)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -142,14 +185,13 @@ func (h *TemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *TemplateHandler) Get(w http.ResponseWriter, r *http.Request) {
-	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	templateID, err := uuid.Parse(chi.URLParam(r, "templateId"))
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
 		return
 	}
 
-	template, err := h.repo.GetByID(r.Context(), templateID, orgID)
+	template, err := h.repo.GetByID(r.Context(), templateID, templateAccess(r))
 	if err != nil {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
 		return
@@ -159,7 +201,6 @@ func (h *TemplateHandler) Get(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *TemplateHandler) Update(w http.ResponseWriter, r *http.Request) {
-	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	templateID, err := uuid.Parse(chi.URLParam(r, "templateId"))
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
@@ -172,25 +213,31 @@ func (h *TemplateHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	template, err := h.repo.Update(r.Context(), templateID, orgID, &req)
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	template, err := h.repo.Update(r.Context(), templateID, templateAccess(r), &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeTemplateError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, template)
 }
 
+// Delete removes a template. Shared templates are soft-deleted: they stop
+// being listed or usable for sends, but messages keep referring to them.
 func (h *TemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	templateID, err := uuid.Parse(chi.URLParam(r, "templateId"))
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
 		return
 	}
 
-	if err := h.repo.Delete(r.Context(), templateID, orgID); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := h.repo.Delete(r.Context(), templateID, templateAccess(r)); err != nil {
+		writeTemplateError(w, err)
 		return
 	}
 
@@ -198,7 +245,6 @@ func (h *TemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *TemplateHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
-	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	templateID, err := uuid.Parse(chi.URLParam(r, "templateId"))
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
@@ -206,11 +252,18 @@ func (h *TemplateHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	}
 	page, pageSize := getPagination(r)
 
-	versions, total, err := h.repo.ListVersions(r.Context(), templateID, orgID, pageSize, (page-1)*pageSize)
+	// Fetch one extra version so the oldest on the page can be diffed too
+	versions, total, err := h.repo.ListVersions(r.Context(), templateID, templateAccess(r), pageSize+1, (page-1)*pageSize)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeTemplateError(w, err)
 		return
 	}
+	for i := 0; i+1 < len(versions); i++ {
+		versions[i].Changes = service.TemplateChanges(versions[i+1], versions[i])
+	}
+	if len(versions) > pageSize {
+		versions = versions[:pageSize]
+	}
 
 	writeJSON(w, http.StatusOK, models.PaginatedResponse[*models.TemplateVersion]{
 		Data:       versions,
@@ -222,7 +275,6 @@ func (h *TemplateHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *TemplateHandler) CreateVersion(w http.ResponseWriter, r *http.Request) {
-	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
 	templateID, err := uuid.Parse(chi.URLParam(r, "templateId"))
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
@@ -235,15 +287,102 @@ func (h *TemplateHandler) CreateVersion(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	version, err := h.repo.CreateVersion(r.Context(), templateID, orgID, &req)
+	version, err := h.repo.CreateVersion(r.Context(), templateID, templateAccess(r), &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeTemplateError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusCreated, version)
 }
 
+// Rollback makes an earlier version of a template the latest again
+func (h *TemplateHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	templateID, err := uuid.Parse(chi.URLParam(r, "templateId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version < 1 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid version"})
+		return
+	}
+
+	promoted, err := h.repo.Rollback(r.Context(), templateID, templateAccess(r), version)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+
+	h.logger.Info("Template rolled back",
+		zap.String("template_id", templateID.String()),
+		zap.Int("from_version", version),
+		zap.Int("version", promoted.Version))
+
+	writeJSON(w, http.StatusOK, promoted)
+}
+
+// CreateSystem adds a template to the read-only system gallery. It is an
+// internal endpoint for operators seeding the gallery.
+func (h *TemplateHandler) CreateSystem(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	template, err := h.repo.CreateSystem(r.Context(), &req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("System template created",
+		zap.String("template_id", template.ID.String()),
+		zap.String("name", template.Name))
+
+	writeJSON(w, http.StatusCreated, template)
+}
+
+// templateAccess identifies the API key of the request for template
+// visibility checks
+func templateAccess(r *http.Request) models.TemplateAccess {
+	access := models.TemplateAccess{
+		OrganizationID: r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID),
+	}
+	if key, ok := r.Context().Value(middleware.ContextKeyAPIKey).(*repository.APIKeyResult); ok {
+		access.APIKeyID = key.ID
+		access.Admin = middleware.KeyHasScope(key, models.ScopeAdmin)
+	}
+	return access
+}
+
+// requestKeyID is the ID of the request's API key
+func requestKeyID(r *http.Request) uuid.UUID {
+	if key, ok := r.Context().Value(middleware.ContextKeyAPIKey).(*repository.APIKeyResult); ok {
+		return key.ID
+	}
+	return uuid.Nil
+}
+
+// writeTemplateError maps template repository errors to status codes
+func writeTemplateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrTemplateNotFound), errors.Is(err, repository.ErrTemplateVersionNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, repository.ErrTemplateReadOnly):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
 // Helper functions
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"transactional-api/middleware"
+	"transactional-api/models"
+	"transactional-api/repository"
+)
+
+// SendingDomainHandler manages the organization's sending domain pool and
+// the strategy messages' sending domains are picked by
+type SendingDomainHandler struct {
+	repo   *repository.SendingDomainRepository
+	logger *zap.Logger
+}
+
+func NewSendingDomainHandler(repo *repository.SendingDomainRepository, logger *zap.Logger) *SendingDomainHandler {
+	return &SendingDomainHandler{repo: repo, logger: logger}
+}
+
+// writePool responds with the organization's pool after a change
+func (h *SendingDomainHandler) writePool(w http.ResponseWriter, r *http.Request, orgID uuid.UUID, status int) {
+	pool, err := h.repo.GetPool(r.Context(), orgID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, status, pool)
+}
+
+func (h *SendingDomainHandler) Get(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	h.writePool(w, r, orgID, http.StatusOK)
+}
+
+func (h *SendingDomainHandler) UpdateStrategy(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+
+	var req models.UpdateSendingStrategyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.SetStrategy(r.Context(), orgID, req.Strategy); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.writePool(w, r, orgID, http.StatusOK)
+}
+
+// Add adds one of the organization's domains to the pool. A domain that
+// isn't verified yet, or has no DKIM key, can be added; it's skipped when
+// sending until it is ready.
+func (h *SendingDomainHandler) Add(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+
+	var req models.AddSendingDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Weight == 0 {
+		req.Weight = 1
+	}
+
+	err := h.repo.AddDomain(r.Context(), orgID, req.DomainID, req.Weight)
+	switch {
+	case errors.Is(err, repository.ErrSendingDomainNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "domain not found"})
+		return
+	case errors.Is(err, repository.ErrSendingDomainExists):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.writePool(w, r, orgID, http.StatusCreated)
+}
+
+func (h *SendingDomainHandler) Update(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	domainID, err := uuid.Parse(chi.URLParam(r, "domainId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid domain ID"})
+		return
+	}
+
+	var req models.UpdateSendingDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.UpdateWeight(r.Context(), orgID, domainID, req.Weight); err != nil {
+		if errors.Is(err, repository.ErrSendingDomainNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.writePool(w, r, orgID, http.StatusOK)
+}
+
+func (h *SendingDomainHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	domainID, err := uuid.Parse(chi.URLParam(r, "domainId"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid domain ID"})
+		return
+	}
+
+	if err := h.repo.RemoveDomain(r.Context(), orgID, domainID); err != nil {
+		if errors.Is(err, repository.ErrSendingDomainNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
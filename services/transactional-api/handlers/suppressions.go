@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"transactional-api/middleware"
+	"transactional-api/models"
+	"transactional-api/repository"
+)
+
+const (
+	// maxSuppressionImportBytes caps the size of an imported CSV file
+	maxSuppressionImportBytes = 10 << 20
+	// maxSuppressionImportRows caps the number of addresses in one import
+	maxSuppressionImportRows = 100000
+	// maxSuppressionImportErrors caps the row errors reported back
+	maxSuppressionImportErrors = 100
+)
+
+// suppressionImportRow is an address read from an import file and the line
+// it was on
+type suppressionImportRow struct {
+	line  int
+	entry models.SuppressionEntry
+}
+
+// List lists live suppressions, optionally filtered by ?reason=
+func (h *SuppressionHandler) List(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	page, pageSize := getPagination(r)
+
+	code, ok := reasonCodeParam(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "reason must be hard_bounce, complaint, unsubscribe or manual"})
+		return
+	}
+
+	suppressions, total, err := h.repo.ListByReason(r.Context(), orgID, code, pageSize, (page-1)*pageSize)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.PaginatedResponse[*models.Suppression]{
+		Data:       suppressions,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalCount: total,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
+
+// Export downloads live suppressions, optionally filtered by ?reason=, as a
+// CSV file that Import accepts
+func (h *SuppressionHandler) Export(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+
+	code, ok := reasonCodeParam(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "reason must be hard_bounce, complaint, unsubscribe or manual"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="suppressions.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"email", "reason", "expires_at", "source", "created_at"})
+
+	err := h.repo.Export(r.Context(), orgID, code, func(s *models.Suppression) error {
+		expiresAt := ""
+		if s.ExpiresAt != nil {
+			expiresAt = s.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		return out.Write([]string{s.Email, string(s.ReasonCode), expiresAt, s.Source, s.CreatedAt.UTC().Format(time.RFC3339)})
+	})
+	out.Flush()
+	if err == nil {
+		err = out.Error()
+	}
+	if err != nil {
+		// The status has been sent; the client sees a truncated file
+		h.logger.Error("Failed to export suppressions", zap.String("org_id", orgID.String()), zap.Error(err))
+	}
+}
+
+// Import suppresses the addresses in a CSV file of address, reason and
+// expiry, sent as the request body or as the "file" field of a multipart
+// form. An address that is already suppressed keeps one entry, with the
+// stronger of the two reasons.
+func (h *SuppressionHandler) Import(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	keyID := requestKeyID(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSuppressionImportBytes)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Missing file field"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	rows, rowErrors, err := parseSuppressionCSV(body, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 && len(rowErrors) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "No addresses to import"})
+		return
+	}
+
+	resp := &models.ImportSuppressionResponse{
+		Total:   len(rows) + len(rowErrors),
+		Invalid: len(rowErrors),
+	}
+	for _, row := range rows {
+		result, err := h.repo.Upsert(r.Context(), orgID, &row.entry, keyID)
+		if err != nil {
+			h.logger.Error("Failed to import suppression", zap.Int("line", row.line), zap.Error(err))
+			rowErrors = append(rowErrors, fmt.Sprintf("line %d: could not be saved", row.line))
+			resp.Invalid++
+			continue
+		}
+		switch result {
+		case models.SuppressionAdded:
+			resp.Added++
+		case models.SuppressionUpdated:
+			resp.Updated++
+		default:
+			resp.Existing++
+		}
+	}
+
+	if len(rowErrors) > maxSuppressionImportErrors {
+		rowErrors = rowErrors[:maxSuppressionImportErrors]
+	}
+	resp.Errors = rowErrors
+
+	h.logger.Info("Suppressions imported",
+		zap.String("org_id", orgID.String()),
+		zap.Int("added", resp.Added),
+		zap.Int("updated", resp.Updated),
+		zap.Int("existing", resp.Existing),
+		zap.Int("invalid", resp.Invalid))
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RemoveAddress un-suppresses an address on every list. The removal is kept
+// in the address's history.
+func (h *SuppressionHandler) RemoveAddress(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	email := chi.URLParam(r, "address")
+
+	if _, err := h.repo.RemoveAddress(r.Context(), orgID, email, requestKeyID(r)); err != nil {
+		if errors.Is(err, repository.ErrSuppressionNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// History lists the recorded changes to an address's suppressions
+func (h *SuppressionHandler) History(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(middleware.ContextKeyOrgID).(uuid.UUID)
+	email := chi.URLParam(r, "address")
+
+	entries, err := h.repo.ListAudit(r.Context(), orgID, email, 100)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"email": email, "history": entries})
+}
+
+// reasonCodeParam reads the optional ?reason= filter
+func reasonCodeParam(r *http.Request) (models.SuppressionReasonCode, bool) {
+	code := models.SuppressionReasonCode(strings.ToLower(r.URL.Query().Get("reason")))
+	return code, code == "" || code.Valid()
+}
+
+// parseSuppressionCSV reads rows of address, reason and expiry. The reason
+// defaults to manual and an empty expiry never expires; expiries are RFC 3339
+// times or dates. A first row without an email address is taken as a header
+// and further columns are ignored, so exported files can be imported again.
+// Invalid rows are skipped and reported by line; only an unreadable file is
+// an error.
+func parseSuppressionCSV(body io.Reader, now time.Time) ([]suppressionImportRow, []string, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []suppressionImportRow
+	var rowErrors []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrors = append(rowErrors, fmt.Sprintf("line %d: %v", parseErr.Line, parseErr.Err))
+				continue
+			}
+			return nil, nil, fmt.Errorf("read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if line == 1 {
+			record[0] = strings.TrimPrefix(record[0], "\ufeff") // Byte order mark
+			if !strings.Contains(record[0], "@") {
+				continue // Header
+			}
+		}
+		email := strings.TrimSpace(record[0])
+		if email == "" && len(record) == 1 {
+			continue // Blank line
+		}
+		if len(rows)+len(rowErrors) >= maxSuppressionImportRows {
+			return nil, nil, fmt.Errorf("too many rows: at most %d addresses can be imported at once", maxSuppressionImportRows)
+		}
+
+		entry, err := parseSuppressionRecord(record, now)
+		if err != nil {
+			rowErrors = append(rowErrors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		rows = append(rows, suppressionImportRow{line: line, entry: entry})
+	}
+
+	return rows, rowErrors, nil
+}
+
+// parseSuppressionRecord validates one import row
+func parseSuppressionRecord(record []string, now time.Time) (models.SuppressionEntry, error) {
+	entry := models.SuppressionEntry{
+		Email:      strings.TrimSpace(record[0]),
+		ReasonCode: models.ReasonCodeManual,
+		Source:     models.SuppressionSourceImport,
+	}
+	if err := validate.Var(entry.Email, "required,email"); err != nil {
+		return entry, fmt.Errorf("invalid email address %q", entry.Email)
+	}
+
+	if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+		entry.ReasonCode = models.SuppressionReasonCode(strings.ToLower(strings.TrimSpace(record[1])))
+		if !entry.ReasonCode.Valid() {
+			return entry, fmt.Errorf("invalid reason %q: must be hard_bounce, complaint, unsubscribe or manual", record[1])
+		}
+	}
+
+	if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+		value := strings.TrimSpace(record[2])
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			expiresAt, err = time.Parse("2006-01-02", value)
+		}
+		if err != nil {
+			return entry, fmt.Errorf("invalid expiry %q: use an RFC 3339 time or YYYY-MM-DD", value)
+		}
+		if !expiresAt.After(now) {
+			return entry, fmt.Errorf("expiry %q is in the past", value)
+		}
+		entry.ExpiresAt = &expiresAt
+	}
+
+	return entry, nil
+}
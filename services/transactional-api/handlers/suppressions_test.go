@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"transactional-api/models"
+)
+
+func TestParseSuppressionCSV(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	input := "\ufeffemail,reason,expires_at,source\n" +
+		"bounced@example.com,hard_bounce,,bounce\n" +
+		"angry@example.com, COMPLAINT\n" +
+		"\n" +
+		"paused@example.com,manual,2026-06-01\n" +
+		"later@example.com,unsubscribe,2026-04-01T12:00:00Z\n" +
+		"not-an-address,manual\n" +
+		"typo@example.com,spam\n" +
+		"old@example.com,manual,2025-01-01\n" +
+		"odd@example.com,manual,next week\n"
+
+	rows, rowErrors, err := parseSuppressionCSV(strings.NewReader(input), now)
+	if err != nil {
+		t.Fatalf("parseSuppressionCSV() error = %v", err)
+	}
+
+	want := []struct {
+		line    int
+		email   string
+		code    models.SuppressionReasonCode
+		expires string
+	}{
+		{2, "bounced@example.com", models.ReasonCodeHardBounce, ""},
+		{3, "angry@example.com", models.ReasonCodeComplaint, ""},
+		{5, "paused@example.com", models.ReasonCodeManual, "2026-06-01T00:00:00Z"},
+		{6, "later@example.com", models.ReasonCodeUnsubscribe, "2026-04-01T12:00:00Z"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, w := range want {
+		row := rows[i]
+		if row.line != w.line || row.entry.Email != w.email || row.entry.ReasonCode != w.code {
+			t.Errorf("row %d = line %d %s %s, want line %d %s %s", i, row.line, row.entry.Email, row.entry.ReasonCode, w.line, w.email, w.code)
+		}
+		if row.entry.Source != models.SuppressionSourceImport {
+			t.Errorf("row %d source = %q, want import", i, row.entry.Source)
+		}
+		expires := ""
+		if row.entry.ExpiresAt != nil {
+			expires = row.entry.ExpiresAt.Format(time.RFC3339)
+		}
+		if expires != w.expires {
+			t.Errorf("row %d expires = %q, want %q", i, expires, w.expires)
+		}
+	}
+
+	wantErrors := []string{"line 7:", "line 8:", "line 9:", "line 10:"}
+	if len(rowErrors) != len(wantErrors) {
+		t.Fatalf("got errors %q, want %d", rowErrors, len(wantErrors))
+	}
+	for i, prefix := range wantErrors {
+		if !strings.HasPrefix(rowErrors[i], prefix) {
+			t.Errorf("error %d = %q, want prefix %q", i, rowErrors[i], prefix)
+		}
+	}
+}
+
+func TestParseSuppressionCSV_NoHeader(t *testing.T) {
+	rows, rowErrors, err := parseSuppressionCSV(strings.NewReader("first@example.com\n"), time.Now())
+	if err != nil || len(rowErrors) != 0 {
+		t.Fatalf("parseSuppressionCSV() = %v, %q", err, rowErrors)
+	}
+	if len(rows) != 1 || rows[0].entry.Email != "first@example.com" || rows[0].entry.ReasonCode != models.ReasonCodeManual {
+		t.Errorf("rows = %+v, want first@example.com as manual", rows)
+	}
+}
+
+func TestSuppressionReasonStrength(t *testing.T) {
+	order := []models.SuppressionReasonCode{
+		models.ReasonCodeManual,
+		models.ReasonCodeUnsubscribe,
+		models.ReasonCodeHardBounce,
+		models.ReasonCodeComplaint,
+	}
+	for i := 1; i < len(order); i++ {
+		if !order[i].StrongerThan(order[i-1]) || order[i-1].StrongerThan(order[i]) {
+			t.Errorf("%s should be stronger than %s", order[i], order[i-1])
+		}
+		if order[i].StrongerThan(order[i]) {
+			t.Errorf("%s should not be stronger than itself", order[i])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"transactional-api/middleware"
+	"transactional-api/repository"
+)
+
+func TestTemplateAccess(t *testing.T) {
+	orgID := uuid.New()
+	tests := []struct {
+		name      string
+		scopes    []string
+		wantAdmin bool
+	}{
+		{"templates scope", []string{"templates"}, false},
+		{"admin scope", []string{"templates", "admin"}, true},
+		{"unscoped legacy key", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &repository.APIKeyResult{ID: uuid.New(), OrganizationID: orgID, Scopes: tt.scopes}
+			r := requestWithKey(key)
+			r = r.WithContext(context.WithValue(r.Context(), middleware.ContextKeyOrgID, orgID))
+
+			access := templateAccess(r)
+			if access.OrganizationID != orgID || access.APIKeyID != key.ID {
+				t.Errorf("templateAccess() = %+v, want org %s key %s", access, orgID, key.ID)
+			}
+			if access.Admin != tt.wantAdmin {
+				t.Errorf("Admin = %v, want %v", access.Admin, tt.wantAdmin)
+			}
+		})
+	}
+}
+
+func TestWriteTemplateError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{repository.ErrTemplateNotFound, http.StatusNotFound},
+		{fmt.Errorf("rollback: %w", repository.ErrTemplateVersionNotFound), http.StatusNotFound},
+		{repository.ErrTemplateReadOnly, http.StatusForbidden},
+		{fmt.Errorf("update template: connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		writeTemplateError(w, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("writeTemplateError(%v) status = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
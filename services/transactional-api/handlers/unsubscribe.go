@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"transactional-api/service"
+)
+
+// maxUnsubscribeBody bounds the form body of an unsubscribe POST
+const maxUnsubscribeBody = 4 << 10
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Unsubscribe</title></head>
+<body style="font-family:sans-serif;max-width:480px;margin:64px auto;text-align:center">
+{{if .Done}}<p>You have been unsubscribed and will no longer receive these emails.</p>
+{{else if .Invalid}}<p>This unsubscribe link is invalid or has expired.</p>
+{{else}}<p>Unsubscribe from these emails?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}</body>
+</html>
+`))
+
+// UnsubscribeHandler serves the unsubscribe links in bulk messages. It
+// needs no API key: the signed token in the URL identifies the recipient.
+type UnsubscribeHandler struct {
+	service *service.UnsubscribeService
+	logger  *zap.Logger
+}
+
+func NewUnsubscribeHandler(service *service.UnsubscribeService, logger *zap.Logger) *UnsubscribeHandler {
+	return &UnsubscribeHandler{service: service, logger: logger}
+}
+
+// Page shows a confirmation page. Following the link must not unsubscribe on
+// its own, since mail scanners fetch links in messages.
+func (h *UnsubscribeHandler) Page(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if !h.service.ValidToken(token) {
+		renderUnsubscribePage(w, http.StatusNotFound, false, true)
+		return
+	}
+	renderUnsubscribePage(w, http.StatusOK, false, false)
+}
+
+// Unsubscribe handles both the RFC 8058 one-click POST sent by mailbox
+// providers ("List-Unsubscribe=One-Click") and the confirmation form
+func (h *UnsubscribeHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUnsubscribeBody)
+	oneClick := r.PostFormValue("List-Unsubscribe") == "One-Click"
+
+	source := "link"
+	if oneClick {
+		source = "one_click"
+	}
+
+	err := h.service.Unsubscribe(r.Context(), token, source)
+	if err != nil && !errors.Is(err, service.ErrInvalidUnsubscribeToken) {
+		h.logger.Error("Failed to unsubscribe", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to unsubscribe"})
+		return
+	}
+
+	invalid := err != nil
+	if oneClick {
+		if invalid {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "Invalid unsubscribe token"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "unsubscribed"})
+		return
+	}
+
+	if invalid {
+		renderUnsubscribePage(w, http.StatusNotFound, false, true)
+		return
+	}
+	renderUnsubscribePage(w, http.StatusOK, true, false)
+}
+
+func renderUnsubscribePage(w http.ResponseWriter, status int, done, invalid bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	unsubscribePage.Execute(w, struct{ Done, Invalid bool }{done, invalid})
+}
@@ -21,7 +21,9 @@ import (
 
 	"transactional-api/config"
 	"transactional-api/handlers"
+	"transactional-api/models"
 	"transactional-api/repository"
+	"transactional-api/scanner"
 	"transactional-api/service"
 	apiMiddleware "transactional-api/middleware"
 )
@@ -64,30 +66,69 @@ func main() {
 	webhookRepo := repository.NewWebhookRepository(dbPool, logger.Named("webhook-repo"))
 	eventRepo := repository.NewEventRepository(dbPool, logger.Named("event-repo"))
 	suppressionRepo := repository.NewSuppressionRepository(dbPool, logger.Named("suppression-repo"))
+	detectionRepo := repository.NewDetectionRepository(dbPool, logger.Named("detection-repo"))
+	inboundRouteRepo := repository.NewInboundRouteRepository(dbPool, logger.Named("inbound-route-repo"))
+	sendingDomainRepo := repository.NewSendingDomainRepository(dbPool, logger.Named("sending-domain-repo"))
 
 	// Initialize services
-	emailService := service.NewEmailService(cfg, emailRepo, templateRepo, suppressionRepo, redisClient, logger.Named("email-service"))
-	webhookService := service.NewWebhookService(webhookRepo, eventRepo, redisClient, logger.Named("webhook-service"))
+	sendingDomains := service.NewSendingDomainSelector(sendingDomainRepo, redisClient, logger.Named("sending-domains"))
+	emailService := service.NewEmailService(cfg, emailRepo, eventRepo, templateRepo, suppressionRepo, sendingDomains, redisClient, logger.Named("email-service"))
+	webhookService := service.NewWebhookService(&cfg.Webhook, webhookRepo, eventRepo, emailService, logger.Named("webhook-service"))
+	bounceService := service.NewBounceService(&cfg.Bounce, eventRepo, suppressionRepo, logger.Named("bounce-service"))
 	analyticsService := service.NewAnalyticsService(eventRepo, emailRepo, logger.Named("analytics-service"))
+	domainPacer := service.NewDomainPacer(&cfg.Pacing, redisClient, logger.Named("domain-pacer"))
+	unsubscribeTokens := service.NewUnsubscribeTokens(&cfg.Unsubscribe)
+	unsubscribeService := service.NewUnsubscribeService(unsubscribeTokens, emailRepo, suppressionRepo, webhookService, logger.Named("unsubscribe-service"))
+
+	// Attachment malware scanning; nil leaves attachments unscanned
+	var attachmentScanner *service.AttachmentScanner
+	attachmentGuard, err := scanner.New(&cfg.Scanning)
+	if err != nil {
+		logger.Fatal("Invalid attachment scanning config", zap.Error(err))
+	}
+	if attachmentGuard != nil {
+		attachmentScanner = service.NewAttachmentScanner(attachmentGuard, detectionRepo, logger.Named("attachment-scanner"))
+	}
+
+	// Inbound parse; nil when disabled
+	var inboundService *service.InboundService
+	if cfg.Inbound.Enabled {
+		inboundService, err = service.NewInboundService(&cfg.Inbound, inboundRouteRepo, redisClient, logger.Named("inbound-service"))
+		if err != nil {
+			logger.Fatal("Invalid inbound parse config", zap.Error(err))
+		}
+	}
 
 	// Start webhook dispatcher
 	webhookService.StartDispatcher(ctx)
+	if inboundService != nil {
+		inboundService.Start(ctx)
+	}
 
 	// Initialize handlers
-	sendHandler := handlers.NewSendHandler(emailService, logger.Named("send-handler"))
+	sendHandler := handlers.NewSendHandler(emailService, attachmentScanner, logger.Named("send-handler"))
 	templateHandler := handlers.NewTemplateHandler(templateRepo, logger.Named("template-handler"))
-	webhookHandler := handlers.NewWebhookHandler(webhookRepo, logger.Named("webhook-handler"))
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo, webhookService, logger.Named("webhook-handler"))
 	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, logger.Named("analytics-handler"))
-	eventHandler := handlers.NewEventHandler(eventRepo, webhookService, logger.Named("event-handler"))
+	eventHandler := handlers.NewEventHandler(eventRepo, emailRepo, webhookService, bounceService, logger.Named("event-handler"))
 	suppressionHandler := handlers.NewSuppressionHandler(suppressionRepo, logger.Named("suppression-handler"))
 	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo, logger.Named("api-key-handler"))
+	pacingHandler := handlers.NewPacingHandler(domainPacer, logger.Named("pacing-handler"))
+	unsubscribeHandler := handlers.NewUnsubscribeHandler(unsubscribeService, logger.Named("unsubscribe-handler"))
+	inboundHandler := handlers.NewInboundHandler(inboundRouteRepo, inboundService, logger.Named("inbound-handler"))
+	sendingDomainHandler := handlers.NewSendingDomainHandler(sendingDomainRepo, logger.Named("sending-domain-handler"))
+
+	trustedProxies, err := apiMiddleware.ParseIPPrefixes(cfg.Server.TrustedProxies)
+	if err != nil {
+		logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 
 	// Setup router
 	r := chi.NewRouter()
 
 	// Global middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(apiMiddleware.RealIP(trustedProxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
@@ -109,22 +150,46 @@ func main() {
 	// Metrics endpoint
 	r.Handle("/metrics", promhttp.Handler())
 
-	// Webhook receiver (for incoming events from SMTP server)
-	// Protected by shared secret to prevent unauthorized event injection
-	r.Post("/internal/events", func(w http.ResponseWriter, r *http.Request) {
-		internalSecret := os.Getenv("INTERNAL_API_SECRET")
-		if internalSecret == "" {
-			internalSecret = cfg.Server.InternalSecret
-		}
-		if internalSecret != "" {
-			authHeader := r.Header.Get("X-Internal-Secret")
-			if authHeader != internalSecret {
-				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
-				return
+	// Internal endpoints are protected by a shared secret
+	requireInternalSecret := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			internalSecret := os.Getenv("INTERNAL_API_SECRET")
+			if internalSecret == "" {
+				internalSecret = cfg.Server.InternalSecret
 			}
+			if internalSecret != "" {
+				authHeader := r.Header.Get("X-Internal-Secret")
+				if authHeader != internalSecret {
+					http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+					return
+				}
+			}
+			next(w, r)
 		}
-		eventHandler.ReceiveEvent(w, r)
-	})
+	}
+
+	// Webhook receiver (for incoming events from SMTP server)
+	// Protected by shared secret to prevent unauthorized event injection
+	r.Post("/internal/events", requireInternalSecret(eventHandler.ReceiveEvent))
+
+	// Delivery pacing per recipient domain, for operators
+	r.Get("/internal/pacing", requireInternalSecret(pacingHandler.State))
+
+	// System template gallery, seeded by operators
+	r.Post("/internal/system-templates", requireInternalSecret(templateHandler.CreateSystem))
+
+	// Unsubscribe links in bulk messages (no auth; the signed token identifies
+	// the recipient). POST also takes RFC 8058 one-click requests.
+	r.Get("/v1/unsubscribe/{token}", unsubscribeHandler.Page)
+	r.Post("/v1/unsubscribe/{token}", unsubscribeHandler.Unsubscribe)
+
+	if inboundService != nil {
+		// Messages for inbound parse routes, handed over by the SMTP server
+		r.Post("/internal/inbound", requireInternalSecret(inboundHandler.Receive))
+
+		// Attachments of parsed messages (no auth; the link is signed)
+		r.Get("/v1/inbound/attachments/{attachmentId}", inboundHandler.Attachment)
+	}
 
 	// API v1 routes (requires API key authentication)
 	r.Route("/v1", func(r chi.Router) {
@@ -133,33 +198,73 @@ func main() {
 
 		// Send emails
 		r.Route("/send", func(r chi.Router) {
+			r.Use(apiMiddleware.RequireKeyScope(models.ScopeSend))
 			r.Post("/", sendHandler.Send)           // Single email
 			r.Post("/batch", sendHandler.SendBatch) // Batch send (up to 1000)
 		})
 
+		// Messages (delivery status and event timeline)
+		r.Route("/messages", func(r chi.Router) {
+			r.Use(apiMiddleware.RequireKeyScope(models.ScopeRead))
+			r.Get("/{messageId}/events", eventHandler.MessageEvents)
+		})
+
 		// Templates
 		r.Route("/templates", func(r chi.Router) {
-			r.Get("/", templateHandler.List)
-			r.Post("/", templateHandler.Create)
-			r.Get("/{templateId}", templateHandler.Get)
-			r.Put("/{templateId}", templateHandler.Update)
-			r.Delete("/{templateId}", templateHandler.Delete)
-			r.Get("/{templateId}/versions", templateHandler.ListVersions)
-			r.Post("/{templateId}/versions", templateHandler.CreateVersion)
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.RequireKeyScope(models.ScopeTemplates, models.ScopeRead))
+				r.Get("/", templateHandler.List)
+				r.Get("/{templateId}", templateHandler.Get)
+				r.Get("/{templateId}/versions", templateHandler.ListVersions)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.RequireKeyScope(models.ScopeTemplates))
+				r.Post("/", templateHandler.Create)
+				r.Put("/{templateId}", templateHandler.Update)
+				r.Delete("/{templateId}", templateHandler.Delete)
+				r.Post("/{templateId}/versions", templateHandler.CreateVersion)
+				r.Post("/{templateId}/rollback/{version}", templateHandler.Rollback)
+			})
 		})
 
 		// Webhooks
 		r.Route("/webhooks", func(r chi.Router) {
-			r.Get("/", webhookHandler.List)
-			r.Post("/", webhookHandler.Create)
-			r.Get("/{webhookId}", webhookHandler.Get)
-			r.Put("/{webhookId}", webhookHandler.Update)
-			r.Delete("/{webhookId}", webhookHandler.Delete)
-			r.Post("/{webhookId}/test", webhookHandler.Test)
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.RequireKeyScope(models.ScopeWebhooks, models.ScopeRead))
+				r.Get("/", webhookHandler.List)
+				r.Get("/{webhookId}", webhookHandler.Get)
+				r.Get("/{webhookId}/deliveries", webhookHandler.ListDeliveries)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.RequireKeyScope(models.ScopeWebhooks))
+				r.Post("/", webhookHandler.Create)
+				r.Put("/{webhookId}", webhookHandler.Update)
+				r.Delete("/{webhookId}", webhookHandler.Delete)
+				r.Post("/{webhookId}/test", webhookHandler.Test)
+				r.Post("/{webhookId}/rotate-secret", webhookHandler.RotateSecret)
+				r.Post("/{webhookId}/deliveries/{deliveryId}/replay", webhookHandler.ReplayDelivery)
+				r.Post("/{webhookId}/replay", webhookHandler.Replay)
+			})
+		})
+
+		// Inbound parse routes
+		r.Route("/inbound-routes", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.RequireKeyScope(models.ScopeWebhooks, models.ScopeRead))
+				r.Get("/", inboundHandler.List)
+				r.Get("/{routeId}", inboundHandler.Get)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.RequireKeyScope(models.ScopeWebhooks))
+				r.Post("/", inboundHandler.Create)
+				r.Put("/{routeId}", inboundHandler.Update)
+				r.Delete("/{routeId}", inboundHandler.Delete)
+			})
 		})
 
 		// Analytics
 		r.Route("/analytics", func(r chi.Router) {
+			r.Use(apiMiddleware.RequireKeyScope(models.ScopeAnalytics, models.ScopeRead))
 			r.Get("/overview", analyticsHandler.Overview)
 			r.Get("/delivery", analyticsHandler.DeliveryStats)
 			r.Get("/engagement", analyticsHandler.EngagementStats)
@@ -169,33 +274,56 @@ func main() {
 
 		// Suppressions (bounces, unsubscribes, spam reports)
 		r.Route("/suppressions", func(r chi.Router) {
-			r.Route("/bounces", func(r chi.Router) {
-				r.Get("/", suppressionHandler.ListBounces)
-				r.Delete("/{email}", suppressionHandler.RemoveBounce)
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.RequireKeyScope(models.ScopeSuppression, models.ScopeRead))
+				r.Get("/bounces", suppressionHandler.ListBounces)
+				r.Get("/unsubscribes", suppressionHandler.ListUnsubscribes)
+				r.Get("/spam-reports", suppressionHandler.ListSpamReports)
+				r.Get("/", suppressionHandler.List)
+				r.Get("/export", suppressionHandler.Export)
+				r.Get("/{address}/history", suppressionHandler.History)
 			})
-			r.Route("/unsubscribes", func(r chi.Router) {
-				r.Get("/", suppressionHandler.ListUnsubscribes)
-				r.Post("/", suppressionHandler.AddUnsubscribe)
-				r.Delete("/{email}", suppressionHandler.RemoveUnsubscribe)
-			})
-			r.Route("/spam-reports", func(r chi.Router) {
-				r.Get("/", suppressionHandler.ListSpamReports)
-				r.Delete("/{email}", suppressionHandler.RemoveSpamReport)
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.RequireKeyScope(models.ScopeSuppression))
+				r.Delete("/bounces/{email}", suppressionHandler.RemoveBounce)
+				r.Post("/unsubscribes", suppressionHandler.AddUnsubscribe)
+				r.Delete("/unsubscribes/{email}", suppressionHandler.RemoveUnsubscribe)
+				r.Delete("/spam-reports/{email}", suppressionHandler.RemoveSpamReport)
+				r.Post("/import", suppressionHandler.Import)
+				r.Delete("/{address}", suppressionHandler.RemoveAddress)
 			})
 		})
 
 		// Events (for retrieving delivery events)
 		r.Route("/events", func(r chi.Router) {
+			r.Use(apiMiddleware.RequireKeyScope(models.ScopeRead))
 			r.Get("/", eventHandler.List)
 			r.Get("/{messageId}", eventHandler.GetByMessageID)
 		})
 
-		// API Keys (self-service)
-		r.Route("/api-keys", func(r chi.Router) {
+		// Sending domain pool and selection strategy
+		r.Route("/sending-domains", func(r chi.Router) {
+			r.Use(apiMiddleware.RequireKeyScope(models.ScopeAdmin))
+			r.Get("/", sendingDomainHandler.Get)
+			r.Post("/", sendingDomainHandler.Add)
+			r.Put("/strategy", sendingDomainHandler.UpdateStrategy)
+			r.Put("/{domainId}", sendingDomainHandler.Update)
+			r.Delete("/{domainId}", sendingDomainHandler.Remove)
+		})
+
+		// API Keys (self-service). /api-keys is the original path and is kept
+		// for existing clients.
+		apiKeyRoutes := func(r chi.Router) {
+			r.Use(apiMiddleware.RequireKeyScope(models.ScopeAdmin))
 			r.Get("/", apiKeyHandler.List)
 			r.Post("/", apiKeyHandler.Create)
+			r.Get("/{keyId}", apiKeyHandler.Get)
 			r.Delete("/{keyId}", apiKeyHandler.Revoke)
-		})
+			r.Get("/{keyId}/allowlist", apiKeyHandler.GetAllowlist)
+			r.Put("/{keyId}/allowlist", apiKeyHandler.UpdateAllowlist)
+		}
+		r.Route("/keys", apiKeyRoutes)
+		r.Route("/api-keys", apiKeyRoutes)
 	})
 
 	// Start HTTP server
@@ -79,11 +79,16 @@ func (m *APIKeyMiddleware) Authenticate(next http.Handler) http.Handler {
 			}
 
 			// Convert repository result to model
+			scopes := make([]models.APIKeyScope, len(result.Scopes))
+			for i, scope := range result.Scopes {
+				scopes[i] = models.APIKeyScope(scope)
+			}
 			key = &models.APIKey{
 				ID:         result.ID,
 				KeyHash:    result.KeyHash,
 				KeyPrefix:  result.KeyPrefix,
 				Name:       result.Name,
+				Scopes:     scopes,
 				RateLimit:  result.RateLimit,
 				LastUsedAt: result.LastUsedAt,
 				ExpiresAt:  result.ExpiresAt,
@@ -126,7 +131,8 @@ func (m *APIKeyMiddleware) Authenticate(next http.Handler) http.Handler {
 	})
 }
 
-// RequireScope creates middleware that requires specific API key scopes
+// RequireScope creates middleware that requires specific API key scopes.
+// Keys without any scopes predate scope enforcement and keep full access.
 func (m *APIKeyMiddleware) RequireScope(scopes ...models.APIKeyScope) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -136,6 +142,17 @@ func (m *APIKeyMiddleware) RequireScope(scopes ...models.APIKeyScope) func(http.
 				return
 			}
 
+			if len(key.Scopes) == 0 {
+				if _, warned := unscopedKeysWarned.LoadOrStore(key.ID, true); !warned {
+					m.logger.Warn().
+						Str("key_id", key.ID.String()).
+						Str("key_prefix", key.KeyPrefix).
+						Msg("API key has no scopes and is treated as full access; this is deprecated, replace it with a scoped key")
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			if !key.HasAnyScope(scopes...) {
 				m.errorResponse(w, http.StatusForbidden, "insufficient_scope",
 					"API key does not have required scope")
@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
 	"transactional-api/config"
+	"transactional-api/models"
 	"transactional-api/repository"
 )
 
@@ -57,6 +59,28 @@ func APIKeyAuth(repo *repository.APIKeyRepository, logger *zap.Logger) func(http
 				return
 			}
 
+			// Enforce the key's IP allowlist. RealIP has already resolved
+			// the client address behind our trusted proxies.
+			if len(key.AllowedIPs) > 0 {
+				clientIP, ok := remoteAddr(r)
+				if !ok || !ipAllowed(key.AllowedIPs, clientIP) {
+					logger.Warn("API key used from an IP outside its allowlist",
+						zap.String("audit_event", "api_key.ip_denied"),
+						zap.String("key_id", key.ID.String()),
+						zap.String("key_prefix", key.KeyPrefix),
+						zap.String("organization_id", key.OrganizationID.String()),
+						zap.String("remote_addr", r.RemoteAddr),
+						zap.String("method", r.Method),
+						zap.String("path", r.URL.Path))
+					writeError(w, http.StatusForbidden, "Request IP is not allowed for this API key")
+					return
+				}
+			}
+
+			if len(key.Scopes) == 0 {
+				warnUnscopedKey(logger, key)
+			}
+
 			// Update last used timestamp (async)
 			go func() {
 				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -73,6 +97,59 @@ func APIKeyAuth(repo *repository.APIKeyRepository, logger *zap.Logger) func(http
 	}
 }
 
+// RequireKeyScope middleware rejects requests whose API key has none of the
+// given scopes. Keys created before scopes were enforced have an empty scope
+// set and keep full access until they are replaced.
+func RequireKeyScope(scopes ...models.APIKeyScope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := r.Context().Value(ContextKeyAPIKey).(*repository.APIKeyResult)
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "API key is required")
+				return
+			}
+
+			if !keyHasAnyScope(key, scopes) {
+				writeError(w, http.StatusForbidden, "API key does not have the required scope")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// KeyHasScope reports whether key has scope, by the rules RequireKeyScope applies
+func KeyHasScope(key *repository.APIKeyResult, scope models.APIKeyScope) bool {
+	return keyHasAnyScope(key, []models.APIKeyScope{scope})
+}
+
+func keyHasAnyScope(key *repository.APIKeyResult, scopes []models.APIKeyScope) bool {
+	if len(key.Scopes) == 0 {
+		return true
+	}
+
+	granted := &models.APIKey{Scopes: make([]models.APIKeyScope, len(key.Scopes))}
+	for i, s := range key.Scopes {
+		granted.Scopes[i] = models.APIKeyScope(s)
+	}
+	return granted.HasAnyScope(scopes...)
+}
+
+// unscopedKeysWarned records unscoped keys that have already been logged, so
+// the deprecation warning is emitted once per key rather than per request
+var unscopedKeysWarned sync.Map
+
+func warnUnscopedKey(logger *zap.Logger, key *repository.APIKeyResult) {
+	if _, warned := unscopedKeysWarned.LoadOrStore(key.ID, true); warned {
+		return
+	}
+	logger.Warn("API key has no scopes and is treated as full access; this is deprecated, replace it with a scoped key",
+		zap.String("key_id", key.ID.String()),
+		zap.String("key_prefix", key.KeyPrefix),
+		zap.String("organization_id", key.OrganizationID.String()))
+}
+
 // RateLimit middleware applies rate limiting per API key
 func RateLimit(redisClient *redis.Client, cfg config.RateLimitConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseIPPrefixes parses a list of IP addresses and CIDR ranges. A bare
+// address is treated as a single-host range (/32 or /128).
+func ParseIPPrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q", entry)
+			}
+			if prefix.Addr().Is4In6() {
+				return nil, fmt.Errorf("invalid CIDR range %q: use the IPv4 form", entry)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		addr = addr.Unmap().WithZone("")
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// NormalizeIPAllowlist validates an API key allowlist and returns it in
+// canonical CIDR form, without duplicates
+func NormalizeIPAllowlist(entries []string) ([]string, error) {
+	prefixes, err := ParseIPPrefixes(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[netip.Prefix]bool, len(prefixes))
+	normalized := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+		normalized = append(normalized, prefix.String())
+	}
+	return normalized, nil
+}
+
+// ipAllowed reports whether ip falls in one of the allowlist entries. An
+// entry that fails to parse matches nothing.
+func ipAllowed(allowlist []string, ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, entry := range allowlist {
+		prefixes, err := ParseIPPrefixes([]string{entry})
+		if err != nil {
+			continue
+		}
+		if prefixes[0].Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteAddr parses the IP part of r.RemoteAddr
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// RealIP sets r.RemoteAddr to the client IP. X-Forwarded-For and X-Real-IP
+// are only honoured when the connection comes from one of trustedProxies,
+// since anyone else can set them to any value. X-Forwarded-For is read from
+// the right, skipping our own proxies, so an address the client prepended
+// itself is never used.
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		for _, prefix := range trustedProxies {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, ok := remoteAddr(r)
+			if !ok || !trusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := peer
+			if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+				hops := strings.Split(strings.Join(xff, ","), ",")
+				for i := len(hops) - 1; i >= 0; i-- {
+					addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+					if err != nil {
+						break
+					}
+					client = addr.Unmap().WithZone("")
+					if !trusted(client) {
+						break
+					}
+				}
+			} else if xri := r.Header.Get("X-Real-IP"); xri != "" {
+				if addr, err := netip.ParseAddr(strings.TrimSpace(xri)); err == nil {
+					client = addr.Unmap().WithZone("")
+				}
+			}
+
+			r.RemoteAddr = client.String()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestNormalizeIPAllowlist(t *testing.T) {
+	got, err := NormalizeIPAllowlist([]string{" 203.0.113.7 ", "198.51.100.0/24", "198.51.100.9/24", "2001:db8::/32", "2001:db8::1", "::ffff:192.0.2.1"})
+	if err != nil {
+		t.Fatalf("NormalizeIPAllowlist() error = %v", err)
+	}
+
+	want := []string{"203.0.113.7/32", "198.51.100.0/24", "2001:db8::/32", "2001:db8::1/128", "192.0.2.1/32"}
+	if len(got) != len(want) {
+		t.Fatalf("NormalizeIPAllowlist() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	for _, invalid := range []string{"", "example.com", "10.0.0.0/33", "::ffff:10.0.0.0/104"} {
+		if _, err := NormalizeIPAllowlist([]string{invalid}); err == nil {
+			t.Errorf("NormalizeIPAllowlist(%q) succeeded, want error", invalid)
+		}
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	allowlist := []string{"198.51.100.0/24", "2001:db8::/32"}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"198.51.100.42", true},
+		{"::ffff:198.51.100.42", true},
+		{"198.51.101.1", false},
+		{"2001:db8:1::5", true},
+		{"2001:db9::5", false},
+	}
+
+	for _, tt := range tests {
+		if got := ipAllowed(allowlist, netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("ipAllowed(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseIPPrefixes([]string{"10.0.0.0/8", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct client ignores forwarded headers", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7:5000"},
+		{"trusted proxy forwards client", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed leftmost entry is skipped", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.9"}, "198.51.100.1"},
+		{"ipv6 proxy and client", "[fd00::2]:5000", map[string]string{"X-Forwarded-For": "2001:db8::7"}, "2001:db8::7"},
+		{"x-real-ip from trusted proxy", "10.0.0.2:5000", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"no header keeps proxy address", "10.0.0.2:5000", nil, "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
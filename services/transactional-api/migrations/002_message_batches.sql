@@ -0,0 +1,12 @@
+-- Transactional Email API Schema
+-- Migration: 002_message_batches.sql
+-- Adds batch tracking and idempotency keys for bulk personalized sends
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS batch_id UUID;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_messages_batch_id ON messages(batch_id) WHERE batch_id IS NOT NULL;
+
+-- Replaying a batch with the same idempotency key prefix must not enqueue duplicates
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_idempotency_key
+    ON messages(domain_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
@@ -0,0 +1,9 @@
+-- Transactional Email API Schema
+-- Migration: 003_webhook_secret_rotation.sql
+-- Keeps the previous webhook signing secret valid for a grace period after rotation
+
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS previous_secret VARCHAR(64);
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_previous_secret_expiry
+    ON webhooks(previous_secret_expires_at) WHERE previous_secret_expires_at IS NOT NULL;
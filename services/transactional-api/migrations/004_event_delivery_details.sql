@@ -0,0 +1,10 @@
+-- Transactional Email API Schema
+-- Migration: 004_event_delivery_details.sql
+-- Stores SMTP delivery details reported by the smtp-server for per-recipient status tracking
+
+ALTER TABLE email_events ADD COLUMN IF NOT EXISTS smtp_code INTEGER;
+ALTER TABLE email_events ADD COLUMN IF NOT EXISTS smtp_response TEXT;
+ALTER TABLE email_events ADD COLUMN IF NOT EXISTS bounce_code VARCHAR(10);
+ALTER TABLE email_events ADD COLUMN IF NOT EXISTS remote_mta VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_events_message_timestamp ON email_events(message_id, timestamp);
@@ -0,0 +1,7 @@
+-- Transactional Email API Schema
+-- Migration: 005_api_key_message_limits.sql
+-- Per-key overrides of the subscription tier's message size and recipient limits.
+-- NULL means the key uses its organization's tier default.
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS max_message_size BIGINT CHECK (max_message_size > 0);
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS max_recipients INTEGER CHECK (max_recipients > 0);
@@ -0,0 +1,6 @@
+-- Transactional Email API Schema
+-- Migration: 006_api_key_ip_allowlist.sql
+-- Optional per-key allowlist of source IPs and CIDR ranges (IPv4 or IPv6).
+-- An empty list allows requests from any address.
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS allowed_ips TEXT[] NOT NULL DEFAULT '{}';
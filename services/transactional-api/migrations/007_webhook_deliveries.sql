@@ -0,0 +1,26 @@
+-- Transactional Email API Schema
+-- Migration: 007_webhook_deliveries.sql
+-- Record every webhook delivery attempt so past deliveries can be inspected
+-- and replayed after their retries are exhausted.
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES email_events(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    url VARCHAR(500) NOT NULL,
+    request_body TEXT,
+    response_code INTEGER,
+    response_body TEXT,
+    success BOOLEAN NOT NULL DEFAULT false,
+    error TEXT,
+    attempt_number INTEGER NOT NULL DEFAULT 1,
+    duration_ms INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Manual replays are recorded alongside automatic attempts
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS replay BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created ON webhook_deliveries(webhook_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries(event_id);
@@ -0,0 +1,11 @@
+-- Transactional Email API Schema
+-- Migration: 008_bounce_category.sql
+-- Stores why a bounce happened (invalid_recipient, mailbox_full, spam_block, ...)
+-- alongside its hard/soft/block type.
+
+ALTER TABLE email_events ADD COLUMN IF NOT EXISTS bounce_category VARCHAR(30);
+
+-- Counts repeated bounces of one category per recipient
+CREATE INDEX IF NOT EXISTS idx_events_recipient_bounce_category
+    ON email_events(organization_id, recipient, bounce_category, timestamp)
+    WHERE bounce_category IS NOT NULL;
@@ -0,0 +1,7 @@
+-- Transactional Email API Schema
+-- Migration: 009_bulk_unsubscribe.sql
+-- Bulk (marketing) messages are sent to each recipient separately with a
+-- one-click unsubscribe link (RFC 8058). Scheduled bulk messages need the
+-- flag when they are picked up for sending.
+
+ALTER TABLE transactional_emails ADD COLUMN IF NOT EXISTS is_bulk BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- Transactional Email API Schema
+-- Migration: 010_template_versions.sql
+-- Template versions are immutable: every content change and every rollback
+-- adds a version. Messages record the version they were rendered from so a
+-- send can be traced back to the exact content it used.
+
+ALTER TABLE email_template_versions ADD COLUMN IF NOT EXISTS change_note VARCHAR(500);
+
+ALTER TABLE transactional_emails ADD COLUMN IF NOT EXISTS template_version INTEGER;
@@ -0,0 +1,18 @@
+-- Transactional Email API Schema
+-- Migration: 011_attachment_detections.sql
+-- Attachments are scanned for malware before sending when scanning is
+-- enabled. Messages with an infected attachment are rejected and the
+-- detection recorded here; the content itself is not kept.
+
+CREATE TABLE IF NOT EXISTS attachment_detections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL,
+    api_key_id UUID,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255),
+    scanner VARCHAR(50) NOT NULL,
+    signature VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachment_detections_org_created ON attachment_detections(organization_id, created_at DESC);
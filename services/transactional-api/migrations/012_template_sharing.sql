@@ -0,0 +1,27 @@
+-- Transactional Email API Schema
+-- Migration: 012_template_sharing.sql
+-- Templates are owned by the API key that created them. A private template
+-- is used only by its owner, a shared one by every key in the organization,
+-- and system templates form a read-only gallery open to every organization.
+-- Templates from before ownership were usable by every key, so they become
+-- shared. Shared templates are soft-deleted, since other keys may still be
+-- sending with them and messages keep referring to them.
+
+ALTER TABLE email_templates ADD COLUMN IF NOT EXISTS api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL;
+ALTER TABLE email_templates ADD COLUMN IF NOT EXISTS visibility VARCHAR(20) NOT NULL DEFAULT 'shared'
+    CHECK (visibility IN ('private', 'shared', 'system'));
+ALTER TABLE email_templates ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- System templates belong to no organization
+ALTER TABLE email_templates ALTER COLUMN organization_id DROP NOT NULL;
+ALTER TABLE email_templates ADD CONSTRAINT email_templates_system_org
+    CHECK ((visibility = 'system') = (organization_id IS NULL));
+
+-- Names only need to be unique among live templates
+ALTER TABLE email_templates DROP CONSTRAINT IF EXISTS email_templates_organization_id_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_templates_org_name_live ON email_templates(organization_id, name)
+    WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_templates_system_name ON email_templates(name)
+    WHERE organization_id IS NULL AND deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_templates_api_key ON email_templates(api_key_id);
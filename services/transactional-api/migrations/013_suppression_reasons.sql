@@ -0,0 +1,47 @@
+-- Transactional Email API Schema
+-- Migration: 013_suppression_reasons.sql
+-- Every suppression gets a reason code (hard_bounce, complaint, unsubscribe,
+-- manual), the source that added it and an optional expiry, so lists can be
+-- filtered, exported and imported. Changes made through the API are kept in
+-- an audit trail.
+
+ALTER TABLE suppressions ADD COLUMN IF NOT EXISTS reason_code VARCHAR(20);
+ALTER TABLE suppressions ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'api';
+ALTER TABLE suppressions ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE suppressions ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+UPDATE suppressions SET
+    reason_code = CASE type
+        WHEN 'bounce' THEN 'hard_bounce'
+        WHEN 'spam_report' THEN 'complaint'
+        WHEN 'spam_complaint' THEN 'complaint'
+        WHEN 'unsubscribe' THEN 'unsubscribe'
+        ELSE 'manual'
+    END,
+    source = CASE type
+        WHEN 'bounce' THEN 'bounce'
+        WHEN 'spam_report' THEN 'complaint'
+        ELSE source
+    END,
+    updated_at = created_at
+WHERE reason_code IS NULL;
+
+ALTER TABLE suppressions ALTER COLUMN reason_code SET NOT NULL;
+ALTER TABLE suppressions ADD CONSTRAINT suppressions_reason_code_check
+    CHECK (reason_code IN ('hard_bounce', 'complaint', 'unsubscribe', 'manual'));
+
+CREATE INDEX IF NOT EXISTS idx_suppressions_reason_code ON suppressions(organization_id, reason_code, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS suppression_audit (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('added', 'updated', 'removed')),
+    reason_code VARCHAR(20) NOT NULL,
+    previous_reason_code VARCHAR(20),
+    source VARCHAR(20) NOT NULL,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_suppression_audit_email ON suppression_audit(organization_id, email, created_at DESC);
@@ -0,0 +1,20 @@
+-- Transactional Email API Schema
+-- Migration: 014_inbound_parse.sql
+-- Inbound parse routes: mail received for a hostname, or one address on it,
+-- is parsed into JSON and posted to the organization's URL. An empty
+-- local_part routes every address on the hostname.
+
+CREATE TABLE IF NOT EXISTS inbound_routes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    hostname VARCHAR(255) NOT NULL,
+    local_part VARCHAR(64) NOT NULL DEFAULT '',
+    url VARCHAR(500) NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inbound_routes_address ON inbound_routes(hostname, local_part);
+CREATE INDEX IF NOT EXISTS idx_inbound_routes_org ON inbound_routes(organization_id, created_at DESC);
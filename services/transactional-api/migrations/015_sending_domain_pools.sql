@@ -0,0 +1,21 @@
+-- Transactional Email API Schema
+-- Migration: 015_sending_domain_pools.sql
+-- Sending domain pools: an organization's messages go out from one of its
+-- pooled domains, picked per message by the pool's strategy, so the SMTP
+-- server signs them with that domain's DKIM key. Domains that aren't
+-- verified or have no active DKIM key are skipped when picking.
+
+CREATE TABLE IF NOT EXISTS sending_domain_pools (
+    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    strategy VARCHAR(20) NOT NULL DEFAULT 'round_robin'
+        CHECK (strategy IN ('round_robin', 'weighted', 'sticky')),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS sending_domain_pool_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    domain_id UUID NOT NULL REFERENCES domains(id) ON DELETE CASCADE,
+    weight INTEGER NOT NULL DEFAULT 1 CHECK (weight > 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, domain_id)
+);
@@ -0,0 +1,22 @@
+-- Transactional Email API Schema
+-- Migration: 016_webhook_dead_letters.sql
+-- Failed webhook deliveries are retried on a fixed schedule, the time of
+-- the next retry stored on the failed attempt. Deliveries that run out of
+-- retries, or whose endpoint has been failing too long, are dead-lettered.
+-- A webhook failing for longer still is disabled and its owner notified.
+
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'succeeded'
+    CHECK (status IN ('succeeded', 'failed', 'retrying', 'dead'));
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE webhook_deliveries SET status = 'failed' WHERE NOT success AND status = 'succeeded';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_next_retry
+    ON webhook_deliveries(next_retry_at) WHERE status = 'retrying';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_status
+    ON webhook_deliveries(webhook_id, status, created_at DESC);
+
+-- When the endpoint started failing, cleared by a successful delivery
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS failing_since TIMESTAMP WITH TIME ZONE;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS disabled_reason TEXT;
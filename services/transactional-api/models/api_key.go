@@ -35,6 +35,19 @@ type APIKey struct {
 	RevokedAt  *time.Time    `json:"revoked_at,omitempty"`
 	CreatedBy  uuid.UUID     `json:"created_by"`
 	Metadata   map[string]any `json:"metadata,omitempty"`
+
+	// Per-key overrides of the subscription tier's message limits
+	MaxMessageSize *int64 `json:"max_message_size,omitempty"`
+	MaxRecipients  *int   `json:"max_recipients,omitempty"`
+
+	// Source IPs and CIDR ranges the key may be used from; empty allows any
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
+}
+
+// MessageLimits bounds a single message sent with an API key
+type MessageLimits struct {
+	MaxMessageSize int64 `json:"max_message_size"` // Bytes, including headers and attachments
+	MaxRecipients  int   `json:"max_recipients"`   // To, CC and BCC combined
 }
 
 // CreateAPIKeyRequest is the request to create a new API key
@@ -46,6 +59,10 @@ type CreateAPIKeyRequest struct {
 	DailyLimit int           `json:"daily_limit" validate:"omitempty,min=1,max=1000000"`
 	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`
 	Metadata   map[string]any `json:"metadata,omitempty"`
+
+	// Optional overrides of the subscription tier's message limits
+	MaxMessageSize *int64 `json:"max_message_size,omitempty" validate:"omitempty,min=1"`
+	MaxRecipients  *int   `json:"max_recipients,omitempty" validate:"omitempty,min=1"`
 }
 
 // CreateAPIKeyResponse is the response when creating a new API key
@@ -64,6 +81,18 @@ type UpdateAPIKeyRequest struct {
 	Metadata   map[string]any `json:"metadata,omitempty"`
 }
 
+// APIKeyAllowlist is the IP allowlist of an API key. Entries are IPv4 or
+// IPv6 addresses or CIDR ranges; an empty list allows any address.
+type APIKeyAllowlist struct {
+	KeyID      uuid.UUID `json:"key_id"`
+	AllowedIPs []string  `json:"allowed_ips"`
+}
+
+// UpdateAPIKeyAllowlistRequest replaces an API key's IP allowlist
+type UpdateAPIKeyAllowlistRequest struct {
+	AllowedIPs []string `json:"allowed_ips" validate:"max=100"`
+}
+
 // ListAPIKeysRequest is the request to list API keys
 type ListAPIKeysRequest struct {
 	DomainID     uuid.UUID `json:"domain_id"`
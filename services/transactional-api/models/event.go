@@ -10,6 +10,8 @@ import (
 type EventType string
 
 const (
+	EventTypeQueued       EventType = "queued"
+	EventTypeSent         EventType = "sent"
 	EventTypeProcessed    EventType = "processed"
 	EventTypeDelivered    EventType = "delivered"
 	EventTypeBounced      EventType = "bounced"
@@ -23,6 +25,8 @@ const (
 
 // Aliases for backward compatibility
 const (
+	EventQueued       = EventTypeQueued
+	EventSent         = EventTypeSent
 	EventProcessed    = EventTypeProcessed
 	EventDelivered    = EventTypeDelivered
 	EventBounced      = EventTypeBounced
@@ -34,6 +38,20 @@ const (
 	EventUnsubscribed = EventTypeUnsubscribed
 )
 
+// BounceCategory describes why a bounce happened, beyond its hard/soft/block type
+type BounceCategory string
+
+const (
+	BounceCategoryInvalidRecipient BounceCategory = "invalid_recipient"
+	BounceCategoryInvalidDomain    BounceCategory = "invalid_domain"
+	BounceCategoryMailboxFull      BounceCategory = "mailbox_full"
+	BounceCategoryMessageTooLarge  BounceCategory = "message_too_large"
+	BounceCategorySpamBlock        BounceCategory = "spam_block"
+	BounceCategoryPolicy           BounceCategory = "policy"
+	BounceCategoryTransient        BounceCategory = "transient_network"
+	BounceCategoryUnknown          BounceCategory = "unknown"
+)
+
 // EmailEvent represents an email delivery event
 type EmailEvent struct {
 	ID             uuid.UUID         `json:"id"`
@@ -44,10 +62,13 @@ type EmailEvent struct {
 	Recipient      string            `json:"recipient"`
 	Timestamp      time.Time         `json:"timestamp"`
 	Metadata       map[string]any    `json:"metadata,omitempty"`
+	SMTPCode       int               `json:"smtp_code,omitempty"`
 	SMTPResponse   string            `json:"smtp_response,omitempty"`
 	BounceType     string            `json:"bounce_type,omitempty"` // hard, soft, block
 	BounceCode     string            `json:"bounce_code,omitempty"`
 	BounceReason   string            `json:"bounce_reason,omitempty"`
+	BounceCategory BounceCategory    `json:"bounce_category,omitempty"`
+	RemoteMTA      string            `json:"remote_mta,omitempty"` // Receiving MTA hostname for deliveries and bounces
 	UserAgent      string            `json:"user_agent,omitempty"`
 	IPAddress      string            `json:"ip_address,omitempty"`
 	URL            string            `json:"url,omitempty"` // For click events
@@ -144,14 +165,24 @@ type EventAggregation struct {
 
 // EventTimelineEntry represents an entry in an email's event timeline
 type EventTimelineEntry struct {
-	EventType EventType `json:"event_type"`
-	Timestamp time.Time `json:"timestamp"`
-	Details   string    `json:"details,omitempty"`
+	EventType    EventType `json:"event_type"`
+	Timestamp    time.Time `json:"timestamp"`
+	Recipient    string    `json:"recipient"`
+	Details      string    `json:"details,omitempty"`
+	SMTPCode     int       `json:"smtp_code,omitempty"`
+	SMTPResponse string    `json:"smtp_response,omitempty"`
+	BounceType   string    `json:"bounce_type,omitempty"`
+	BounceCode   string    `json:"bounce_code,omitempty"`
+	RemoteMTA    string    `json:"remote_mta,omitempty"`
+	URL          string    `json:"url,omitempty"`
+
+	BounceCategory BounceCategory `json:"bounce_category,omitempty"`
 }
 
 // GetMessageTimeline returns a timeline of events for a message
 type MessageTimeline struct {
-	MessageID uuid.UUID            `json:"message_id"`
-	Status    MessageStatus        `json:"status"`
-	Events    []EventTimelineEntry `json:"events"`
+	MessageID  uuid.UUID                `json:"message_id"`
+	Status     MessageStatus            `json:"status"`
+	Recipients map[string]MessageStatus `json:"recipients"` // Latest status per recipient
+	Events     []EventTimelineEntry     `json:"events"`
 }
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"net/textproto"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons an attachment was left out of an inbound parse post
+const (
+	StrippedExecutable = "executable"
+	StrippedTooLarge   = "too_large"
+)
+
+// InboundRoute sends mail received for a hostname, or one address on it, to
+// an organization's URL as parsed JSON
+type InboundRoute struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Hostname       string    `json:"hostname"`
+	LocalPart      string    `json:"local_part,omitempty"` // Empty routes every address on the hostname
+	URL            string    `json:"url"`
+	Secret         string    `json:"-"` // For HMAC signature verification
+	IsActive       bool      `json:"is_active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CreateInboundRouteRequest is the request to create an inbound parse route
+type CreateInboundRouteRequest struct {
+	Hostname  string `json:"hostname" validate:"required,fqdn,max=255"`
+	LocalPart string `json:"local_part,omitempty" validate:"omitempty,max=64"`
+	URL       string `json:"url" validate:"required,url,max=500"`
+}
+
+// UpdateInboundRouteRequest is the request to update an inbound parse route
+type UpdateInboundRouteRequest struct {
+	URL      *string `json:"url,omitempty" validate:"omitempty,url,max=500"`
+	IsActive *bool   `json:"is_active,omitempty"`
+}
+
+// InboundRouteResponse is an inbound route as returned by the API. The
+// secret is only included when the route is created.
+type InboundRouteResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Hostname  string    `json:"hostname"`
+	LocalPart string    `json:"local_part,omitempty"`
+	URL       string    `json:"url"`
+	IsActive  bool      `json:"is_active"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// InboundEnvelope is the SMTP envelope a message was received with
+type InboundEnvelope struct {
+	From string   `json:"from"`
+	To   []string `json:"to"`
+}
+
+// InboundAttachment is an attachment of a parsed message, stored for
+// download from URL until ExpiresAt
+type InboundAttachment struct {
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	ContentID   string    `json:"content_id,omitempty"` // Referenced from the HTML body as cid:
+	Size        int64     `json:"size"`
+	URL         string    `json:"url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// StrippedAttachment is an attachment left out of the post, and not stored
+type StrippedAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Reason      string `json:"reason"` // executable, too_large
+}
+
+// InboundParsePayload is posted to an inbound route's URL for each message
+// received for it
+type InboundParsePayload struct {
+	ID                  string               `json:"id"`
+	RouteID             uuid.UUID            `json:"route_id"`
+	Envelope            InboundEnvelope      `json:"envelope"`
+	From                string               `json:"from"`
+	To                  string               `json:"to"`
+	Cc                  string               `json:"cc,omitempty"`
+	ReplyTo             string               `json:"reply_to,omitempty"`
+	Subject             string               `json:"subject"`
+	MessageID           string               `json:"message_id,omitempty"`
+	Date                string               `json:"date,omitempty"`
+	Text                string               `json:"text,omitempty"`
+	HTML                string               `json:"html,omitempty"`
+	Headers             textproto.MIMEHeader `json:"headers"`
+	Attachments         []InboundAttachment  `json:"attachments"`
+	StrippedAttachments []StrippedAttachment `json:"stripped_attachments,omitempty"`
+	ReceivedAt          time.Time            `json:"received_at"`
+}
@@ -11,14 +11,16 @@ import (
 // ============================================================
 
 type APIKeyResponse struct {
-	ID        uuid.UUID  `json:"id"`
-	Name      string     `json:"name"`
-	Key       string     `json:"key,omitempty"` // Only returned on creation
-	KeyPrefix string     `json:"key_prefix"`
-	Scopes    []string   `json:"scopes"`
-	RateLimit int        `json:"rate_limit"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
+	ID            uuid.UUID     `json:"id"`
+	Name          string        `json:"name"`
+	Key           string        `json:"key,omitempty"` // Only returned on creation
+	KeyPrefix     string        `json:"key_prefix"`
+	Scopes        []string      `json:"scopes"`
+	RateLimit     int           `json:"rate_limit"`
+	MessageLimits MessageLimits `json:"message_limits"` // Tier defaults with per-key overrides applied
+	AllowedIPs    []string      `json:"allowed_ips,omitempty"`
+	ExpiresAt     *time.Time    `json:"expires_at,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
 }
 
 // ============================================================
@@ -26,14 +28,17 @@ type APIKeyResponse struct {
 // ============================================================
 
 type WebhookResponse struct {
-	ID            uuid.UUID          `json:"id"`
-	URL           string             `json:"url"`
-	Events        []WebhookEventType `json:"events"`
-	IsActive      bool               `json:"is_active"`
-	Secret        string             `json:"secret,omitempty"` // Only on creation
-	FailureCount  int                `json:"failure_count"`
-	LastTriggered *time.Time         `json:"last_triggered,omitempty"`
-	CreatedAt     time.Time          `json:"created_at"`
+	ID             uuid.UUID          `json:"id"`
+	URL            string             `json:"url"`
+	Events         []WebhookEventType `json:"events"`
+	IsActive       bool               `json:"is_active"`
+	Secret         string             `json:"secret,omitempty"` // Only on creation
+	FailureCount   int                `json:"failure_count"`
+	LastTriggered  *time.Time         `json:"last_triggered,omitempty"`
+	FailingSince   *time.Time         `json:"failing_since,omitempty"`
+	DisabledAt     *time.Time         `json:"disabled_at,omitempty"`
+	DisabledReason string             `json:"disabled_reason,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
 }
 
 // ============================================================
@@ -33,14 +33,35 @@ type SendEmailRequest struct {
 	TrackClicks  *bool             `json:"track_clicks,omitempty"`
 	IPPool       string            `json:"ip_pool,omitempty"`
 	SendAt       *time.Time        `json:"send_at,omitempty"`
+	// Bulk marks marketing mail: each recipient gets a separate copy with a
+	// one-click unsubscribe link (RFC 8058)
+	Bulk bool `json:"bulk,omitempty"`
+	// Priority in the outbound queue: "high" (password resets, sign-in
+	// codes), "normal" or "low". Defaults to low for bulk mail and normal
+	// otherwise.
+	Priority string `json:"priority,omitempty" validate:"omitempty,oneof=high normal low"`
+	// TemplateVersion pins the template version to render. Omitted, the
+	// latest version at the time of the request is used; either way the
+	// version is fixed when the message is accepted, so scheduled messages
+	// are not affected by later template edits.
+	TemplateVersion *int `json:"template_version,omitempty" validate:"omitempty,min=1"`
+	// RequestReadReceipt asks recipients for a read receipt (RFC 8098),
+	// returned to the from address. Ignored for bulk mail, which recipients
+	// never acknowledge.
+	RequestReadReceipt bool `json:"request_read_receipt,omitempty"`
+	// AutoText generates the plain text part from the HTML when none is
+	// given, so the message goes out as multipart/alternative. Defaults to
+	// true; false sends HTML-only messages as they are.
+	AutoText *bool `json:"auto_text,omitempty"`
 }
 
 // SendEmailResponse represents the response from sending an email
 type SendEmailResponse struct {
-	MessageID   uuid.UUID  `json:"message_id"`
-	Status      string     `json:"status"`
-	QueuedAt    time.Time  `json:"queued_at"`
-	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	MessageID       uuid.UUID  `json:"message_id"`
+	Status          string     `json:"status"`
+	QueuedAt        time.Time  `json:"queued_at"`
+	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
+	TemplateVersion *int       `json:"template_version,omitempty"`
 }
 
 // SendRequest represents a transactional email send request (simplified)
@@ -65,6 +86,13 @@ type SendRequest struct {
 	ASMGroupID    *int              `json:"asm_group_id,omitempty"` // Suppression group
 	IPPoolName    string            `json:"ip_pool_name,omitempty"`
 	BatchID       string            `json:"batch_id,omitempty"`
+	// RequestReadReceipt asks recipients for a read receipt (RFC 8098),
+	// returned to the from address
+	RequestReadReceipt bool `json:"request_read_receipt,omitempty"`
+	// AutoText generates the plain text part from the HTML when none is
+	// given, so the message goes out as multipart/alternative. Defaults to
+	// true; false sends HTML-only messages as they are.
+	AutoText *bool `json:"auto_text,omitempty"`
 }
 
 // Attachment represents an email attachment
@@ -95,35 +123,37 @@ type RejectedRecipient struct {
 
 // Message represents a stored email message
 type Message struct {
-	ID            uuid.UUID         `json:"id"`
-	DomainID      uuid.UUID         `json:"domain_id"`
-	APIKeyID      uuid.UUID         `json:"api_key_id"`
-	From          string            `json:"from"`
-	To            []string          `json:"to"`
-	CC            []string          `json:"cc,omitempty"`
-	BCC           []string          `json:"bcc,omitempty"`
-	ReplyTo       string            `json:"reply_to,omitempty"`
-	Subject       string            `json:"subject"`
-	HTML          string            `json:"html,omitempty"`
-	Text          string            `json:"text,omitempty"`
-	TemplateID    *uuid.UUID        `json:"template_id,omitempty"`
-	Categories    []string          `json:"categories,omitempty"`
-	CustomArgs    map[string]string `json:"custom_args,omitempty"`
-	Headers       map[string]string `json:"headers,omitempty"`
-	Status        MessageStatus     `json:"status"`
-	TrackOpens    bool              `json:"track_opens"`
-	TrackClicks   bool              `json:"track_clicks"`
-	ScheduledAt   *time.Time        `json:"scheduled_at,omitempty"`
-	QueuedAt      time.Time         `json:"queued_at"`
-	SentAt        *time.Time        `json:"sent_at,omitempty"`
-	DeliveredAt   *time.Time        `json:"delivered_at,omitempty"`
-	OpenedAt      *time.Time        `json:"opened_at,omitempty"`
-	ClickedAt     *time.Time        `json:"clicked_at,omitempty"`
-	BouncedAt     *time.Time        `json:"bounced_at,omitempty"`
-	BounceReason  string            `json:"bounce_reason,omitempty"`
-	SMTPResponse  string            `json:"smtp_response,omitempty"`
-	CreatedAt     time.Time         `json:"created_at"`
-	UpdatedAt     time.Time         `json:"updated_at"`
+	ID             uuid.UUID         `json:"id"`
+	DomainID       uuid.UUID         `json:"domain_id"`
+	APIKeyID       uuid.UUID         `json:"api_key_id"`
+	From           string            `json:"from"`
+	To             []string          `json:"to"`
+	CC             []string          `json:"cc,omitempty"`
+	BCC            []string          `json:"bcc,omitempty"`
+	ReplyTo        string            `json:"reply_to,omitempty"`
+	Subject        string            `json:"subject"`
+	HTML           string            `json:"html,omitempty"`
+	Text           string            `json:"text,omitempty"`
+	TemplateID     *uuid.UUID        `json:"template_id,omitempty"`
+	Categories     []string          `json:"categories,omitempty"`
+	CustomArgs     map[string]string `json:"custom_args,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Status         MessageStatus     `json:"status"`
+	TrackOpens     bool              `json:"track_opens"`
+	TrackClicks    bool              `json:"track_clicks"`
+	ScheduledAt    *time.Time        `json:"scheduled_at,omitempty"`
+	QueuedAt       time.Time         `json:"queued_at"`
+	SentAt         *time.Time        `json:"sent_at,omitempty"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty"`
+	OpenedAt       *time.Time        `json:"opened_at,omitempty"`
+	ClickedAt      *time.Time        `json:"clicked_at,omitempty"`
+	BouncedAt      *time.Time        `json:"bounced_at,omitempty"`
+	BounceReason   string            `json:"bounce_reason,omitempty"`
+	SMTPResponse   string            `json:"smtp_response,omitempty"`
+	BatchID        *uuid.UUID        `json:"batch_id,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
 }
 
 // MessageStatus represents the status of an email message
@@ -139,6 +169,7 @@ const (
 	MessageStatusDeferred   MessageStatus = "deferred"
 	MessageStatusDropped    MessageStatus = "dropped"
 	MessageStatusFailed     MessageStatus = "failed"
+	MessageStatusCancelled  MessageStatus = "cancelled"
 )
 
 // MessageQuery represents query parameters for listing messages
@@ -177,6 +208,54 @@ type BatchSendResponse struct {
 	Results     []SendResponse `json:"results"`
 }
 
+// MessageBatchRequest represents a bulk send of one personalized message per recipient
+type MessageBatchRequest struct {
+	From           string            `json:"from" validate:"required,email"`
+	ReplyTo        string            `json:"reply_to,omitempty" validate:"omitempty,email"`
+	Subject        string            `json:"subject" validate:"required_without=TemplateID,max=998"`
+	HTML           string            `json:"html,omitempty" validate:"required_without_all=Text TemplateID,max=10485760"`
+	Text           string            `json:"text,omitempty" validate:"max=10485760"`
+	TemplateID     string            `json:"template_id,omitempty" validate:"omitempty,uuid"`
+	Categories     []string          `json:"categories,omitempty" validate:"max=10,dive,max=100"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	SendAt         *time.Time        `json:"send_at,omitempty"`
+	TrackOpens     *bool             `json:"track_opens,omitempty"`
+	TrackClicks    *bool             `json:"track_clicks,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty" validate:"max=200"` // Prefix shared by every message in the batch
+	Recipients     []BatchRecipient  `json:"recipients" validate:"required,min=1,max=1000,dive"`
+}
+
+// BatchRecipient represents a single recipient and its personalization in a batch
+type BatchRecipient struct {
+	To            string            `json:"to" validate:"required,email"`
+	Substitutions map[string]any    `json:"substitutions,omitempty"`
+	CustomArgs    map[string]string `json:"custom_args,omitempty"`
+}
+
+// MessageBatchResponse represents the response from a bulk send
+type MessageBatchResponse struct {
+	BatchID  string                 `json:"batch_id"`
+	Accepted int                    `json:"accepted"`
+	Rejected int                    `json:"rejected"`
+	Results  []BatchRecipientResult `json:"results"`
+}
+
+// CancelBatchResponse represents the result of cancelling a batch
+type CancelBatchResponse struct {
+	BatchID   string `json:"batch_id"`
+	Cancelled int64  `json:"cancelled"`
+}
+
+// BatchRecipientResult represents the outcome for one recipient of a bulk send
+type BatchRecipientResult struct {
+	Index     int    `json:"index"`
+	To        string `json:"to"`
+	MessageID string `json:"message_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"`
+}
+
 // BatchSendEmailResponse is an extended batch response with accept/reject counts
 type BatchSendEmailResponse struct {
 	Accepted int                 `json:"accepted"`
@@ -190,3 +269,29 @@ type BatchError struct {
 	Index   int    `json:"index"`
 	Message string `json:"message"`
 }
+
+// DomainPacingState is the delivery pacing of one recipient domain
+type DomainPacingState struct {
+	Domain        string     `json:"domain"`
+	Rate          float64    `json:"rate"`           // Configured messages per second
+	EffectiveRate float64    `json:"effective_rate"` // Rate after throttle backoff
+	Burst         int        `json:"burst"`
+	Tokens        float64    `json:"tokens"`
+	BackoffLevel  int        `json:"backoff_level"`
+	BackoffUntil  *time.Time `json:"backoff_until,omitempty"`
+	Deferrals     int64      `json:"deferrals"`
+	Throttles     int64      `json:"throttles"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// AttachmentDetection records an attachment rejected by the malware scanner
+type AttachmentDetection struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	APIKeyID       *uuid.UUID `json:"api_key_id,omitempty"`
+	Filename       string     `json:"filename"`
+	ContentType    string     `json:"content_type"`
+	Scanner        string     `json:"scanner"`
+	Signature      string     `json:"signature"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
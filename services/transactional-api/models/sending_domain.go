@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SendingStrategy is how a message's sending domain is picked from its
+// organization's pool
+type SendingStrategy string
+
+const (
+	SendingStrategyRoundRobin SendingStrategy = "round_robin"
+	SendingStrategyWeighted   SendingStrategy = "weighted"
+	SendingStrategySticky     SendingStrategy = "sticky" // By recipient domain
+)
+
+// SendingDomainPool is the set of domains an organization's messages are
+// sent from, and how one is picked for each message
+type SendingDomainPool struct {
+	Strategy SendingStrategy `json:"strategy"`
+	Domains  []SendingDomain `json:"domains"`
+}
+
+// SendingDomain is a domain in a sending pool. Only eligible domains, whose
+// DNS is verified and which have an active DKIM key, are picked.
+type SendingDomain struct {
+	DomainID    uuid.UUID `json:"domain_id"`
+	Name        string    `json:"name"`
+	Weight      int       `json:"weight"`
+	DNSVerified bool      `json:"dns_verified"`
+	HasDKIMKey  bool      `json:"has_dkim_key"`
+	Eligible    bool      `json:"eligible"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AddSendingDomainRequest is the request to add a domain to the sending pool
+type AddSendingDomainRequest struct {
+	DomainID uuid.UUID `json:"domain_id" validate:"required"`
+	Weight   int       `json:"weight,omitempty" validate:"omitempty,min=1,max=1000"` // Defaults to 1
+}
+
+// UpdateSendingDomainRequest is the request to change a pooled domain's weight
+type UpdateSendingDomainRequest struct {
+	Weight int `json:"weight" validate:"required,min=1,max=1000"`
+}
+
+// UpdateSendingStrategyRequest is the request to change the pool's strategy
+type UpdateSendingStrategyRequest struct {
+	Strategy SendingStrategy `json:"strategy" validate:"required,oneof=round_robin weighted sticky"`
+}
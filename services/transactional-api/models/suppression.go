@@ -37,9 +37,11 @@ type Suppression struct {
 	BounceClass    BounceClassification  `json:"bounce_class,omitempty"`
 	Description    string                `json:"description,omitempty"`
 	OriginalError  string                `json:"original_error,omitempty"`
-	Source         string                `json:"source,omitempty"` // api, webhook, smtp
+	ReasonCode     SuppressionReasonCode `json:"reason_code,omitempty"`
+	Source         string                `json:"source,omitempty"` // api, import, bounce, complaint, unsubscribe_link
 	MessageID      *uuid.UUID            `json:"message_id,omitempty"`
 	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at,omitempty"`
 	ExpiresAt      *time.Time            `json:"expires_at,omitempty"` // For soft bounces
 	CreatedBy      *uuid.UUID            `json:"created_by,omitempty"` // For manual suppressions
 }
@@ -156,7 +158,8 @@ type ImportSuppressionRequest struct {
 type ImportSuppressionResponse struct {
 	Total    int      `json:"total"`
 	Added    int      `json:"added"`
-	Existing int      `json:"existing"`
+	Updated  int      `json:"updated"`  // Already suppressed for a weaker reason or a shorter time
+	Existing int      `json:"existing"` // Already suppressed, left unchanged
 	Invalid  int      `json:"invalid"`
 	Errors   []string `json:"errors,omitempty"`
 }
@@ -179,3 +182,101 @@ const (
 	SuppressionSpamReport  SuppressionType = "spam_report"
 	SuppressionManual      SuppressionType = "manual"
 )
+
+// SuppressionReasonCode classifies why an address is suppressed
+type SuppressionReasonCode string
+
+const (
+	ReasonCodeHardBounce  SuppressionReasonCode = "hard_bounce"
+	ReasonCodeComplaint   SuppressionReasonCode = "complaint"
+	ReasonCodeUnsubscribe SuppressionReasonCode = "unsubscribe"
+	ReasonCodeManual      SuppressionReasonCode = "manual"
+)
+
+// Where a suppression came from
+const (
+	SuppressionSourceAPI             = "api"
+	SuppressionSourceImport          = "import"
+	SuppressionSourceBounce          = "bounce"
+	SuppressionSourceComplaint       = "complaint"
+	SuppressionSourceUnsubscribeLink = "unsubscribe_link"
+)
+
+// reasonCodeStrength ranks reason codes. An address suppressed for more than
+// one reason keeps the strongest: a complaint hurts sender reputation the
+// most, a hard bounce will never be delivered, an unsubscribe is the
+// recipient's choice and a manual entry is the sender's.
+var reasonCodeStrength = map[SuppressionReasonCode]int{
+	ReasonCodeManual:      1,
+	ReasonCodeUnsubscribe: 2,
+	ReasonCodeHardBounce:  3,
+	ReasonCodeComplaint:   4,
+}
+
+// Valid reports whether c is a known reason code
+func (c SuppressionReasonCode) Valid() bool {
+	_, ok := reasonCodeStrength[c]
+	return ok
+}
+
+// StrongerThan reports whether c should replace other as an address's reason
+func (c SuppressionReasonCode) StrongerThan(other SuppressionReasonCode) bool {
+	return reasonCodeStrength[c] > reasonCodeStrength[other]
+}
+
+// Type is the suppression list the reason code belongs to
+func (c SuppressionReasonCode) Type() SuppressionType {
+	switch c {
+	case ReasonCodeHardBounce:
+		return SuppressionBounce
+	case ReasonCodeComplaint:
+		return SuppressionSpamReport
+	case ReasonCodeUnsubscribe:
+		return SuppressionUnsubscribe
+	default:
+		return SuppressionManual
+	}
+}
+
+// ReasonCodeForType is the reason code of an entry on a suppression list
+func ReasonCodeForType(t SuppressionType) SuppressionReasonCode {
+	switch t {
+	case SuppressionBounce:
+		return ReasonCodeHardBounce
+	case SuppressionSpamReport, SuppressionReasonSpamComplaint:
+		return ReasonCodeComplaint
+	case SuppressionUnsubscribe:
+		return ReasonCodeUnsubscribe
+	default:
+		return ReasonCodeManual
+	}
+}
+
+// SuppressionEntry is one address to suppress, as imported
+type SuppressionEntry struct {
+	Email      string
+	ReasonCode SuppressionReasonCode
+	ExpiresAt  *time.Time
+	Source     string
+}
+
+// SuppressionUpsertResult is what an upsert did to an address
+type SuppressionUpsertResult string
+
+const (
+	SuppressionAdded     SuppressionUpsertResult = "added"
+	SuppressionUpdated   SuppressionUpsertResult = "updated"
+	SuppressionUnchanged SuppressionUpsertResult = "unchanged"
+)
+
+// SuppressionAuditEntry records a change to an organization's suppressions
+type SuppressionAuditEntry struct {
+	ID                 uuid.UUID             `json:"id"`
+	Email              string                `json:"email"`
+	Action             string                `json:"action"` // added, updated, removed
+	ReasonCode         SuppressionReasonCode `json:"reason_code"`
+	PreviousReasonCode SuppressionReasonCode `json:"previous_reason_code,omitempty"`
+	Source             string                `json:"source"`
+	APIKeyID           *uuid.UUID            `json:"api_key_id,omitempty"`
+	CreatedAt          time.Time             `json:"created_at"`
+}
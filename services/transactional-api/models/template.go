@@ -31,6 +31,39 @@ type Template struct {
 	UpdatedAt      time.Time          `json:"updated_at"`
 	CreatedBy      uuid.UUID          `json:"created_by"`
 	UpdatedBy      uuid.UUID          `json:"updated_by"`
+
+	// APIKeyID is the key that created the template; nil for system
+	// templates and those created before templates had owners
+	APIKeyID   *uuid.UUID         `json:"api_key_id,omitempty"`
+	Visibility TemplateVisibility `json:"visibility"`
+	// Source says how the template is available to the calling API key
+	Source TemplateSource `json:"source,omitempty"`
+}
+
+// TemplateVisibility controls which API keys can use a template
+type TemplateVisibility string
+
+const (
+	TemplateVisibilityPrivate TemplateVisibility = "private" // Only the key that created it
+	TemplateVisibilityShared  TemplateVisibility = "shared"  // Every key in the organization
+	TemplateVisibilitySystem  TemplateVisibility = "system"  // Read-only gallery, every organization
+)
+
+// TemplateSource is a template's relation to the API key listing it
+type TemplateSource string
+
+const (
+	TemplateSourceOwn    TemplateSource = "own"
+	TemplateSourceShared TemplateSource = "shared"
+	TemplateSourceSystem TemplateSource = "system"
+)
+
+// TemplateAccess identifies the API key templates are used on behalf of
+type TemplateAccess struct {
+	OrganizationID uuid.UUID
+	APIKeyID       uuid.UUID
+	// Admin keys may also edit and delete shared templates they didn't create
+	Admin bool
 }
 
 // TemplateVariable represents a variable used in a template
@@ -57,23 +90,26 @@ type CreateTemplateRequest struct {
 	Tags        []string           `json:"tags,omitempty" validate:"max=10,dive,max=50"`
 	Metadata    map[string]any     `json:"metadata,omitempty"`
 	Active      *bool              `json:"active,omitempty"`
+	// Visibility defaults to private
+	Visibility TemplateVisibility `json:"visibility,omitempty" validate:"omitempty,oneof=private shared"`
 }
 
 // UpdateTemplateRequest is the request to update a template
 type UpdateTemplateRequest struct {
-	Name        *string            `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
-	Description *string            `json:"description,omitempty" validate:"omitempty,max=500"`
-	Subject     *string            `json:"subject,omitempty" validate:"omitempty,max=998"`
-	HTMLContent *string            `json:"html_content,omitempty" validate:"omitempty,max=10485760"`
-	TextContent *string            `json:"text_content,omitempty" validate:"omitempty,max=1048576"`
-	HTMLBody    *string            `json:"html_body,omitempty" validate:"omitempty,max=10485760"`
-	TextBody    *string            `json:"text_body,omitempty" validate:"omitempty,max=1048576"`
-	Variables   []TemplateVariable `json:"variables,omitempty"`
-	Category    *string            `json:"category,omitempty" validate:"omitempty,max=100"`
-	Tags        []string           `json:"tags,omitempty" validate:"max=10,dive,max=50"`
-	Metadata    map[string]any     `json:"metadata,omitempty"`
-	Active      *bool              `json:"active,omitempty"`
-	IsActive    *bool              `json:"is_active,omitempty"`
+	Name        *string             `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description *string             `json:"description,omitempty" validate:"omitempty,max=500"`
+	Subject     *string             `json:"subject,omitempty" validate:"omitempty,max=998"`
+	HTMLContent *string             `json:"html_content,omitempty" validate:"omitempty,max=10485760"`
+	TextContent *string             `json:"text_content,omitempty" validate:"omitempty,max=1048576"`
+	HTMLBody    *string             `json:"html_body,omitempty" validate:"omitempty,max=10485760"`
+	TextBody    *string             `json:"text_body,omitempty" validate:"omitempty,max=1048576"`
+	Variables   []TemplateVariable  `json:"variables,omitempty"`
+	Category    *string             `json:"category,omitempty" validate:"omitempty,max=100"`
+	Tags        []string            `json:"tags,omitempty" validate:"max=10,dive,max=50"`
+	Metadata    map[string]any      `json:"metadata,omitempty"`
+	Active      *bool               `json:"active,omitempty"`
+	IsActive    *bool               `json:"is_active,omitempty"`
+	Visibility  *TemplateVisibility `json:"visibility,omitempty" validate:"omitempty,oneof=private shared"`
 }
 
 // TemplateQuery represents query parameters for listing templates
@@ -107,6 +143,9 @@ type RenderTemplateResponse struct {
 	Subject string `json:"subject"`
 	HTML    string `json:"html,omitempty"`
 	Text    string `json:"text,omitempty"`
+	// TextGenerated is set when the template has no text content and Text
+	// was generated from the HTML
+	TextGenerated bool `json:"text_generated,omitempty"`
 }
 
 // TemplateVersion represents a historical version of a template
@@ -123,6 +162,16 @@ type TemplateVersion struct {
 	CreatedAt   time.Time          `json:"created_at"`
 	CreatedBy   uuid.UUID          `json:"created_by"`
 	ChangeNote  string             `json:"change_note,omitempty"`
+	// Changes lists how the version differs from the one before it
+	Changes []TemplateChange `json:"changes,omitempty"`
+}
+
+// TemplateChange is the line diff of one template field between two
+// versions. Lines are prefixed with "+" (added), "-" (removed) or " "
+// (unchanged).
+type TemplateChange struct {
+	Field string   `json:"field"`
+	Diff  []string `json:"diff"`
 }
 
 // DefaultTemplates provides built-in template types
@@ -23,24 +23,29 @@ const (
 
 // Webhook represents a webhook configuration
 type Webhook struct {
-	ID              uuid.UUID          `json:"id"`
-	DomainID        uuid.UUID          `json:"domain_id"`
-	OrganizationID  uuid.UUID          `json:"organization_id"`
-	URL             string             `json:"url"`
-	Events          []WebhookEventType `json:"events"`
-	Secret          string             `json:"-"` // For HMAC signature verification
-	SecretPrefix    string             `json:"secret_prefix,omitempty"` // First 8 chars
-	Active          bool               `json:"active"`
-	IsActive        bool               `json:"is_active"`
-	Description     string             `json:"description,omitempty"`
-	Headers         map[string]string  `json:"headers,omitempty"` // Custom headers to send
-	RetryPolicy     *RetryPolicy       `json:"retry_policy,omitempty"`
-	CreatedAt       time.Time          `json:"created_at"`
-	UpdatedAt       time.Time          `json:"updated_at"`
-	LastTriggeredAt *time.Time         `json:"last_triggered_at,omitempty"`
-	LastTriggered   *time.Time         `json:"last_triggered,omitempty"`
-	FailureCount    int                `json:"failure_count"`
-	LastError       string             `json:"last_error,omitempty"`
+	ID                      uuid.UUID          `json:"id"`
+	DomainID                uuid.UUID          `json:"domain_id"`
+	OrganizationID          uuid.UUID          `json:"organization_id"`
+	URL                     string             `json:"url"`
+	Events                  []WebhookEventType `json:"events"`
+	Secret                  string             `json:"-"` // For HMAC signature verification
+	PreviousSecret          string             `json:"-"` // Still accepted until PreviousSecretExpiresAt
+	PreviousSecretExpiresAt *time.Time         `json:"previous_secret_expires_at,omitempty"`
+	SecretPrefix            string             `json:"secret_prefix,omitempty"` // First 8 chars
+	Active                  bool               `json:"active"`
+	IsActive                bool               `json:"is_active"`
+	Description             string             `json:"description,omitempty"`
+	Headers                 map[string]string  `json:"headers,omitempty"` // Custom headers to send
+	RetryPolicy             *RetryPolicy       `json:"retry_policy,omitempty"`
+	CreatedAt               time.Time          `json:"created_at"`
+	UpdatedAt               time.Time          `json:"updated_at"`
+	LastTriggeredAt         *time.Time         `json:"last_triggered_at,omitempty"`
+	LastTriggered           *time.Time         `json:"last_triggered,omitempty"`
+	FailureCount            int                `json:"failure_count"`
+	LastError               string             `json:"last_error,omitempty"`
+	FailingSince            *time.Time         `json:"failing_since,omitempty"` // Start of the current run of failures
+	DisabledAt              *time.Time         `json:"disabled_at,omitempty"`   // Set when disabled for failing too long
+	DisabledReason          string             `json:"disabled_reason,omitempty"`
 }
 
 // RetryPolicy defines the retry behavior for failed webhook deliveries
@@ -88,6 +93,7 @@ type WebhookPayload struct {
 	CustomArgs  map[string]string `json:"custom_args,omitempty"`
 	SMTPResponse string           `json:"smtp_response,omitempty"`
 	BounceType  string            `json:"bounce_type,omitempty"` // hard, soft
+	BounceCategory string         `json:"bounce_category,omitempty"`
 	BounceCode  string            `json:"bounce_code,omitempty"`
 	UserAgent   string            `json:"user_agent,omitempty"` // For opens/clicks
 	IPAddress   string            `json:"ip_address,omitempty"`
@@ -95,6 +101,16 @@ type WebhookPayload struct {
 	Reason      string            `json:"reason,omitempty"`
 }
 
+// WebhookDeliveryStatus is the outcome of a delivery attempt
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"   // Failed and since retried
+	WebhookDeliveryRetrying  WebhookDeliveryStatus = "retrying" // Failed, retry scheduled for NextRetryAt
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"     // Failed, no more retries
+)
+
 // WebhookDelivery represents a webhook delivery attempt
 type WebhookDelivery struct {
 	ID           uuid.UUID        `json:"id"`
@@ -106,8 +122,11 @@ type WebhookDelivery struct {
 	ResponseCode int              `json:"response_code,omitempty"`
 	ResponseBody string           `json:"response_body,omitempty"`
 	Success      bool             `json:"success"`
+	Status       WebhookDeliveryStatus `json:"status"`
+	NextRetryAt  *time.Time       `json:"next_retry_at,omitempty"`
 	Error        string           `json:"error,omitempty"`
 	AttemptNumber int             `json:"attempt_number"`
+	Replay       bool             `json:"replay"`
 	Duration     time.Duration    `json:"duration"`
 	CreatedAt    time.Time        `json:"created_at"`
 }
@@ -116,6 +135,7 @@ type WebhookDelivery struct {
 type WebhookDeliveryQuery struct {
 	WebhookID uuid.UUID  `json:"webhook_id"`
 	Success   *bool      `json:"success,omitempty"`
+	Status    WebhookDeliveryStatus `json:"status,omitempty"`
 	StartDate *time.Time `json:"start_date,omitempty"`
 	EndDate   *time.Time `json:"end_date,omitempty"`
 	Limit     int        `json:"limit"`
@@ -131,6 +151,13 @@ type WebhookDeliveryListResponse struct {
 	HasMore    bool              `json:"has_more"`
 }
 
+// WebhookReplayResponse is the response when replaying webhook deliveries
+type WebhookReplayResponse struct {
+	Queued int        `json:"queued"`
+	Since  *time.Time `json:"since,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
 // TestWebhookRequest is the request to test a webhook
 type TestWebhookRequest struct {
 	EventType WebhookEventType `json:"event_type" validate:"required,oneof=delivered bounced deferred dropped opened clicked spam_report unsubscribed processed"`
@@ -147,6 +174,7 @@ type TestWebhookResponse struct {
 
 // RotateWebhookSecretResponse is the response when rotating a webhook secret
 type RotateWebhookSecretResponse struct {
-	Secret       string `json:"secret"` // New secret, only shown once
-	SecretPrefix string `json:"secret_prefix"`
+	Secret                  string    `json:"secret"` // New secret, only shown once
+	SecretPrefix            string    `json:"secret_prefix"`
+	PreviousSecretExpiresAt time.Time `json:"previous_secret_expires_at"` // Old secret stays valid until then
 }
@@ -37,6 +37,30 @@ type APIKeyResult struct {
 	LastUsedAt     *time.Time
 	ExpiresAt      *time.Time
 	CreatedAt      time.Time
+
+	// Message limit overrides; nil means the tier default applies
+	MaxMessageSize *int64
+	MaxRecipients  *int
+
+	// Subscription tier of the owning organization
+	SubscriptionTier string
+
+	// Source IPs and CIDR ranges the key may be used from; empty allows any
+	AllowedIPs []string
+}
+
+// apiKeyColumns is the column list scanned by scanAPIKey. It expects the
+// api_keys table aliased as k.
+const apiKeyColumns = `k.id, k.organization_id, k.name, k.key_prefix, k.key_hash, k.scopes, k.rate_limit,
+	k.is_active, k.last_used_at, k.expires_at, k.created_at, k.max_message_size, k.max_recipients,
+	COALESCE(o.subscription_tier, ''), k.allowed_ips`
+
+func scanAPIKey(row pgx.Row, result *APIKeyResult) error {
+	return row.Scan(
+		&result.ID, &result.OrganizationID, &result.Name, &result.KeyPrefix, &result.KeyHash,
+		&result.Scopes, &result.RateLimit, &result.IsActive, &result.LastUsedAt, &result.ExpiresAt, &result.CreatedAt,
+		&result.MaxMessageSize, &result.MaxRecipients, &result.SubscriptionTier, &result.AllowedIPs,
+	)
 }
 
 type APIKeyRepository struct {
@@ -68,7 +92,10 @@ func (r *APIKeyRepository) GenerateAPIKey() (key string, prefix string, hash str
 	return key, prefix, hash, nil
 }
 
-func (r *APIKeyRepository) Create(ctx context.Context, orgID uuid.UUID, name string, scopes []string, rateLimit int, expiresAt *time.Time) (*APIKeyResult, string, error) {
+// Create inserts a new API key and returns it with the plaintext key, which
+// is not stored. maxMessageSize and maxRecipients override the tier's
+// message limits when set.
+func (r *APIKeyRepository) Create(ctx context.Context, orgID uuid.UUID, name string, scopes []string, rateLimit int, expiresAt *time.Time, maxMessageSize *int64, maxRecipients *int) (*APIKeyResult, string, error) {
 	key, prefix, hash, err := r.GenerateAPIKey()
 	if err != nil {
 		return nil, "", fmt.Errorf("generate API key: %w", err)
@@ -78,16 +105,18 @@ func (r *APIKeyRepository) Create(ctx context.Context, orgID uuid.UUID, name str
 	now := time.Now()
 
 	query := `
-		INSERT INTO api_keys (id, organization_id, name, key_prefix, key_hash, scopes, rate_limit, is_active, expires_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, true, $8, $9, $9)
-		RETURNING id, organization_id, name, key_prefix, key_hash, scopes, rate_limit, is_active, last_used_at, expires_at, created_at
+		WITH k AS (
+			INSERT INTO api_keys (id, organization_id, name, key_prefix, key_hash, scopes, rate_limit, is_active, expires_at, max_message_size, max_recipients, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, true, $8, $9, $10, $11, $11)
+			RETURNING *
+		)
+		SELECT ` + apiKeyColumns + `
+		FROM k
+		LEFT JOIN organizations o ON o.id = k.organization_id
 	`
 
 	result := &APIKeyResult{}
-	err = r.db.QueryRow(ctx, query, id, orgID, name, prefix, hash, scopes, rateLimit, expiresAt, now).Scan(
-		&result.ID, &result.OrganizationID, &result.Name, &result.KeyPrefix, &result.KeyHash,
-		&result.Scopes, &result.RateLimit, &result.IsActive, &result.LastUsedAt, &result.ExpiresAt, &result.CreatedAt,
-	)
+	err = scanAPIKey(r.db.QueryRow(ctx, query, id, orgID, name, prefix, hash, scopes, rateLimit, expiresAt, maxMessageSize, maxRecipients, now), result)
 	if err != nil {
 		return nil, "", fmt.Errorf("insert API key: %w", err)
 	}
@@ -97,16 +126,35 @@ func (r *APIKeyRepository) Create(ctx context.Context, orgID uuid.UUID, name str
 
 func (r *APIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*APIKeyResult, error) {
 	query := `
-		SELECT id, organization_id, name, key_prefix, key_hash, scopes, rate_limit, is_active, last_used_at, expires_at, created_at
-		FROM api_keys
-		WHERE key_hash = $1
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		LEFT JOIN organizations o ON o.id = k.organization_id
+		WHERE k.key_hash = $1
 	`
 
 	result := &APIKeyResult{}
-	err := r.db.QueryRow(ctx, query, keyHash).Scan(
-		&result.ID, &result.OrganizationID, &result.Name, &result.KeyPrefix, &result.KeyHash,
-		&result.Scopes, &result.RateLimit, &result.IsActive, &result.LastUsedAt, &result.ExpiresAt, &result.CreatedAt,
-	)
+	err := scanAPIKey(r.db.QueryRow(ctx, query, keyHash), result)
+	if err == pgx.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query API key: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetByID returns an organization's API key by ID
+func (r *APIKeyRepository) GetByID(ctx context.Context, id, orgID uuid.UUID) (*APIKeyResult, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		LEFT JOIN organizations o ON o.id = k.organization_id
+		WHERE k.id = $1 AND k.organization_id = $2
+	`
+
+	result := &APIKeyResult{}
+	err := scanAPIKey(r.db.QueryRow(ctx, query, id, orgID), result)
 	if err == pgx.ErrNoRows {
 		return nil, ErrAPIKeyNotFound
 	}
@@ -125,10 +173,11 @@ func (r *APIKeyRepository) ListByOrg(ctx context.Context, orgID uuid.UUID, limit
 	}
 
 	query := `
-		SELECT id, organization_id, name, key_prefix, key_hash, scopes, rate_limit, is_active, last_used_at, expires_at, created_at
-		FROM api_keys
-		WHERE organization_id = $1
-		ORDER BY created_at DESC
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		LEFT JOIN organizations o ON o.id = k.organization_id
+		WHERE k.organization_id = $1
+		ORDER BY k.created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -141,10 +190,7 @@ func (r *APIKeyRepository) ListByOrg(ctx context.Context, orgID uuid.UUID, limit
 	var results []*APIKeyResult
 	for rows.Next() {
 		result := &APIKeyResult{}
-		if err := rows.Scan(
-			&result.ID, &result.OrganizationID, &result.Name, &result.KeyPrefix, &result.KeyHash,
-			&result.Scopes, &result.RateLimit, &result.IsActive, &result.LastUsedAt, &result.ExpiresAt, &result.CreatedAt,
-		); err != nil {
+		if err := scanAPIKey(rows, result); err != nil {
 			return nil, 0, fmt.Errorf("scan API key: %w", err)
 		}
 		results = append(results, result)
@@ -159,6 +205,22 @@ func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID) err
 	return err
 }
 
+// UpdateAllowedIPs replaces the IP allowlist of an organization's API key
+func (r *APIKeyRepository) UpdateAllowedIPs(ctx context.Context, id, orgID uuid.UUID, allowedIPs []string) error {
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+	query := `UPDATE api_keys SET allowed_ips = $1, updated_at = $2 WHERE id = $3 AND organization_id = $4`
+	result, err := r.db.Exec(ctx, query, allowedIPs, time.Now(), id, orgID)
+	if err != nil {
+		return fmt.Errorf("update API key allowlist: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
 func (r *APIKeyRepository) Revoke(ctx context.Context, id, orgID uuid.UUID) error {
 	query := `UPDATE api_keys SET is_active = false, updated_at = $1 WHERE id = $2 AND organization_id = $3`
 	result, err := r.db.Exec(ctx, query, time.Now(), id, orgID)
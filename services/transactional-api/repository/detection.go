@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"transactional-api/models"
+)
+
+type DetectionRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewDetectionRepository(db *pgxpool.Pool, logger *zap.Logger) *DetectionRepository {
+	return &DetectionRepository{db: db, logger: logger}
+}
+
+// Record stores a malware detection in an attachment
+func (r *DetectionRepository) Record(ctx context.Context, d *models.AttachmentDetection) error {
+	query := `
+		INSERT INTO attachment_detections (id, organization_id, api_key_id, filename, content_type, scanner, signature, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	d.ID = uuid.New()
+	d.CreatedAt = time.Now()
+	_, err := r.db.Exec(ctx, query, d.ID, d.OrganizationID, d.APIKeyID, d.Filename, d.ContentType, d.Scanner, d.Signature, d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert attachment detection: %w", err)
+	}
+	return nil
+}
@@ -39,13 +39,17 @@ type TransactionalEmail struct {
 	Tags           []string
 	Metadata       map[string]string
 	TemplateID     *uuid.UUID
-	IPPool         string
-	Status         string
-	TrackOpens     bool
-	TrackClicks    bool
-	ScheduledAt    *time.Time
-	SentAt         *time.Time
-	CreatedAt      time.Time
+	// TemplateVersion is the template version the message was rendered
+	// from, pinned when it was accepted
+	TemplateVersion *int
+	IPPool          string
+	Status          string
+	TrackOpens      bool
+	TrackClicks     bool
+	Bulk            bool // Sent to each recipient separately with an unsubscribe link
+	ScheduledAt     *time.Time
+	SentAt          *time.Time
+	CreatedAt       time.Time
 }
 
 func (r *EmailRepository) Create(ctx context.Context, email *TransactionalEmail) error {
@@ -55,16 +59,16 @@ func (r *EmailRepository) Create(ctx context.Context, email *TransactionalEmail)
 	query := `
 		INSERT INTO transactional_emails (
 			id, organization_id, message_id, from_email, from_name, to_emails, cc_emails, bcc_emails,
-			subject, text_body, html_body, headers, tags, metadata, template_id, ip_pool,
-			status, track_opens, track_clicks, scheduled_at, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+			subject, text_body, html_body, headers, tags, metadata, template_id, template_version, ip_pool,
+			status, track_opens, track_clicks, is_bulk, scheduled_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
 	`
 
 	_, err := r.db.Exec(ctx, query,
 		email.ID, email.OrganizationID, email.MessageID, email.FromEmail, email.FromName,
 		email.ToEmails, email.CCEmails, email.BCCEmails, email.Subject, email.TextBody, email.HTMLBody,
-		headersJSON, email.Tags, metadataJSON, email.TemplateID, email.IPPool,
-		email.Status, email.TrackOpens, email.TrackClicks, email.ScheduledAt, email.CreatedAt,
+		headersJSON, email.Tags, metadataJSON, email.TemplateID, email.TemplateVersion, email.IPPool,
+		email.Status, email.TrackOpens, email.TrackClicks, email.Bulk, email.ScheduledAt, email.CreatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("insert transactional email: %w", err)
@@ -76,8 +80,8 @@ func (r *EmailRepository) Create(ctx context.Context, email *TransactionalEmail)
 func (r *EmailRepository) GetByID(ctx context.Context, id, orgID uuid.UUID) (*TransactionalEmail, error) {
 	query := `
 		SELECT id, organization_id, message_id, from_email, from_name, to_emails, cc_emails, bcc_emails,
-			subject, text_body, html_body, headers, tags, metadata, template_id, ip_pool,
-			status, track_opens, track_clicks, scheduled_at, sent_at, created_at
+			subject, text_body, html_body, headers, tags, metadata, template_id, template_version, ip_pool,
+			status, track_opens, track_clicks, is_bulk, scheduled_at, sent_at, created_at
 		FROM transactional_emails
 		WHERE id = $1 AND organization_id = $2
 	`
@@ -88,8 +92,8 @@ func (r *EmailRepository) GetByID(ctx context.Context, id, orgID uuid.UUID) (*Tr
 	err := r.db.QueryRow(ctx, query, id, orgID).Scan(
 		&email.ID, &email.OrganizationID, &email.MessageID, &email.FromEmail, &email.FromName,
 		&email.ToEmails, &email.CCEmails, &email.BCCEmails, &email.Subject, &email.TextBody, &email.HTMLBody,
-		&headersJSON, &email.Tags, &metadataJSON, &email.TemplateID, &email.IPPool,
-		&email.Status, &email.TrackOpens, &email.TrackClicks, &email.ScheduledAt, &email.SentAt, &email.CreatedAt,
+		&headersJSON, &email.Tags, &metadataJSON, &email.TemplateID, &email.TemplateVersion, &email.IPPool,
+		&email.Status, &email.TrackOpens, &email.TrackClicks, &email.Bulk, &email.ScheduledAt, &email.SentAt, &email.CreatedAt,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, fmt.Errorf("email not found")
@@ -110,6 +114,20 @@ func (r *EmailRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status
 	return err
 }
 
+// GetOrganizationID returns the organization that owns a message, used to attribute
+// delivery events reported by the smtp-server which only know the message ID
+func (r *EmailRepository) GetOrganizationID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	var orgID uuid.UUID
+	err := r.db.QueryRow(ctx, `SELECT organization_id FROM transactional_emails WHERE id = $1`, id).Scan(&orgID)
+	if err == pgx.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("email not found")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("query email organization: %w", err)
+	}
+	return orgID, nil
+}
+
 func (r *EmailRepository) GetStats(ctx context.Context, orgID uuid.UUID, from, to time.Time) (*models.AnalyticsOverview, error) {
 	query := `
 		SELECT
@@ -140,7 +158,7 @@ func (r *EmailRepository) GetScheduledEmails(ctx context.Context, before time.Ti
 	query := `
 		SELECT id, organization_id, message_id, from_email, from_name, to_emails, cc_emails, bcc_emails,
 			subject, text_body, html_body, headers, tags, metadata, template_id, ip_pool,
-			status, track_opens, track_clicks, scheduled_at, sent_at, created_at
+			status, track_opens, track_clicks, is_bulk, scheduled_at, sent_at, created_at
 		FROM transactional_emails
 		WHERE status = 'scheduled' AND scheduled_at <= $1
 		ORDER BY scheduled_at ASC
@@ -162,7 +180,7 @@ func (r *EmailRepository) GetScheduledEmails(ctx context.Context, before time.Ti
 			&email.ID, &email.OrganizationID, &email.MessageID, &email.FromEmail, &email.FromName,
 			&email.ToEmails, &email.CCEmails, &email.BCCEmails, &email.Subject, &email.TextBody, &email.HTMLBody,
 			&headersJSON, &email.Tags, &metadataJSON, &email.TemplateID, &email.IPPool,
-			&email.Status, &email.TrackOpens, &email.TrackClicks, &email.ScheduledAt, &email.SentAt, &email.CreatedAt,
+			&email.Status, &email.TrackOpens, &email.TrackClicks, &email.Bulk, &email.ScheduledAt, &email.SentAt, &email.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan email: %w", err)
 		}
@@ -3,16 +3,20 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 
 	"transactional-api/models"
 )
 
+var ErrEventNotFound = errors.New("event not found")
+
 type EventRepository struct {
 	db     *pgxpool.Pool
 	logger *zap.Logger
@@ -26,14 +30,16 @@ func (r *EventRepository) Create(ctx context.Context, event *models.EmailEvent)
 	metadataJSON, _ := json.Marshal(event.Metadata)
 
 	query := `
-		INSERT INTO email_events (id, organization_id, message_id, event_type, recipient, timestamp, metadata, user_agent, ip_address, url, bounce_type, bounce_reason)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO email_events (id, organization_id, message_id, event_type, recipient, timestamp, metadata, user_agent, ip_address, url, bounce_type, bounce_reason,
+			smtp_code, smtp_response, bounce_code, remote_mta, bounce_category)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, 0), NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, ''), NULLIF($17, ''))
 	`
 
 	_, err := r.db.Exec(ctx, query,
 		event.ID, event.OrganizationID, event.MessageID, event.EventType,
 		event.Recipient, event.Timestamp, metadataJSON, event.UserAgent,
 		event.IPAddress, event.URL, event.BounceType, event.BounceReason,
+		event.SMTPCode, event.SMTPResponse, event.BounceCode, event.RemoteMTA, event.BounceCategory,
 	)
 	if err != nil {
 		return fmt.Errorf("insert event: %w", err)
@@ -44,7 +50,9 @@ func (r *EventRepository) Create(ctx context.Context, event *models.EmailEvent)
 
 func (r *EventRepository) GetByMessageID(ctx context.Context, messageID, orgID uuid.UUID) ([]*models.EmailEvent, error) {
 	query := `
-		SELECT id, organization_id, message_id, event_type, recipient, timestamp, metadata, user_agent, ip_address, url, bounce_type, bounce_reason
+		SELECT id, organization_id, message_id, event_type, recipient, timestamp, metadata, user_agent, ip_address, url, bounce_type, bounce_reason,
+			COALESCE(smtp_code, 0), COALESCE(smtp_response, ''), COALESCE(bounce_code, ''), COALESCE(remote_mta, ''),
+			COALESCE(bounce_category, '')
 		FROM email_events
 		WHERE message_id = $1 AND organization_id = $2
 		ORDER BY timestamp ASC
@@ -65,6 +73,8 @@ func (r *EventRepository) GetByMessageID(ctx context.Context, messageID, orgID u
 			&event.ID, &event.OrganizationID, &event.MessageID, &event.EventType,
 			&event.Recipient, &event.Timestamp, &metadataJSON, &event.UserAgent,
 			&event.IPAddress, &event.URL, &event.BounceType, &event.BounceReason,
+			&event.SMTPCode, &event.SMTPResponse, &event.BounceCode, &event.RemoteMTA,
+			&event.BounceCategory,
 		); err != nil {
 			return nil, fmt.Errorf("scan event: %w", err)
 		}
@@ -83,7 +93,9 @@ func (r *EventRepository) List(ctx context.Context, orgID uuid.UUID, eventType s
 		WHERE organization_id = $1 AND timestamp BETWEEN $2 AND $3
 	`
 	query := `
-		SELECT id, organization_id, message_id, event_type, recipient, timestamp, metadata, user_agent, ip_address, url, bounce_type, bounce_reason
+		SELECT id, organization_id, message_id, event_type, recipient, timestamp, metadata, user_agent, ip_address, url, bounce_type, bounce_reason,
+			COALESCE(smtp_code, 0), COALESCE(smtp_response, ''), COALESCE(bounce_code, ''), COALESCE(remote_mta, ''),
+			COALESCE(bounce_category, '')
 		FROM email_events
 		WHERE organization_id = $1 AND timestamp BETWEEN $2 AND $3
 	`
@@ -126,6 +138,8 @@ func (r *EventRepository) List(ctx context.Context, orgID uuid.UUID, eventType s
 			&event.ID, &event.OrganizationID, &event.MessageID, &event.EventType,
 			&event.Recipient, &event.Timestamp, &metadataJSON, &event.UserAgent,
 			&event.IPAddress, &event.URL, &event.BounceType, &event.BounceReason,
+			&event.SMTPCode, &event.SMTPResponse, &event.BounceCode, &event.RemoteMTA,
+			&event.BounceCategory,
 		); err != nil {
 			return nil, 0, fmt.Errorf("scan event: %w", err)
 		}
@@ -137,6 +151,93 @@ func (r *EventRepository) List(ctx context.Context, orgID uuid.UUID, eventType s
 	return events, total, nil
 }
 
+// GetByID returns a single event, which must belong to orgID
+func (r *EventRepository) GetByID(ctx context.Context, id, orgID uuid.UUID) (*models.EmailEvent, error) {
+	query := `
+		SELECT id, organization_id, message_id, event_type, recipient, timestamp, metadata, user_agent, ip_address, url, bounce_type, bounce_reason,
+			COALESCE(smtp_code, 0), COALESCE(smtp_response, ''), COALESCE(bounce_code, ''), COALESCE(remote_mta, ''),
+			COALESCE(bounce_category, '')
+		FROM email_events
+		WHERE id = $1 AND organization_id = $2
+	`
+
+	event := &models.EmailEvent{}
+	var metadataJSON []byte
+
+	err := r.db.QueryRow(ctx, query, id, orgID).Scan(
+		&event.ID, &event.OrganizationID, &event.MessageID, &event.EventType,
+		&event.Recipient, &event.Timestamp, &metadataJSON, &event.UserAgent,
+		&event.IPAddress, &event.URL, &event.BounceType, &event.BounceReason,
+		&event.SMTPCode, &event.SMTPResponse, &event.BounceCode, &event.RemoteMTA,
+		&event.BounceCategory,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query event: %w", err)
+	}
+
+	json.Unmarshal(metadataJSON, &event.Metadata)
+	return event, nil
+}
+
+// ListForReplay returns up to limit events of the given types in [from, to],
+// oldest first so they are replayed in the order they happened
+func (r *EventRepository) ListForReplay(ctx context.Context, orgID uuid.UUID, eventTypes []string, from, to time.Time, limit int) ([]*models.EmailEvent, error) {
+	query := `
+		SELECT id, organization_id, message_id, event_type, recipient, timestamp, metadata, user_agent, ip_address, url, bounce_type, bounce_reason,
+			COALESCE(smtp_code, 0), COALESCE(smtp_response, ''), COALESCE(bounce_code, ''), COALESCE(remote_mta, ''),
+			COALESCE(bounce_category, '')
+		FROM email_events
+		WHERE organization_id = $1 AND timestamp BETWEEN $2 AND $3 AND event_type = ANY($4)
+		ORDER BY timestamp ASC
+		LIMIT $5
+	`
+
+	rows, err := r.db.Query(ctx, query, orgID, from, to, eventTypes, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.EmailEvent
+	for rows.Next() {
+		event := &models.EmailEvent{}
+		var metadataJSON []byte
+
+		if err := rows.Scan(
+			&event.ID, &event.OrganizationID, &event.MessageID, &event.EventType,
+			&event.Recipient, &event.Timestamp, &metadataJSON, &event.UserAgent,
+			&event.IPAddress, &event.URL, &event.BounceType, &event.BounceReason,
+			&event.SMTPCode, &event.SMTPResponse, &event.BounceCode, &event.RemoteMTA,
+			&event.BounceCategory,
+		); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+
+		json.Unmarshal(metadataJSON, &event.Metadata)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// CountBounces counts a recipient's bounces of one category since a time
+func (r *EventRepository) CountBounces(ctx context.Context, orgID uuid.UUID, recipient string, category models.BounceCategory, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM email_events
+		WHERE organization_id = $1 AND recipient = $2 AND event_type = $3 AND bounce_category = $4 AND timestamp >= $5
+	`
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, orgID, recipient, models.EventTypeBounced, category, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count bounces: %w", err)
+	}
+
+	return count, nil
+}
+
 func (r *EventRepository) GetStats(ctx context.Context, orgID uuid.UUID, from, to time.Time) (map[models.EventType]int64, error) {
 	query := `
 		SELECT event_type, COUNT(*) as count
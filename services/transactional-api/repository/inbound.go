@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"transactional-api/models"
+)
+
+var (
+	ErrInboundRouteNotFound = errors.New("inbound route not found")
+	ErrInboundRouteExists   = errors.New("an inbound route for this address already exists")
+)
+
+const inboundRouteColumns = `id, organization_id, hostname, local_part, url, secret, is_active, created_at, updated_at`
+
+type InboundRouteRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewInboundRouteRepository(db *pgxpool.Pool, logger *zap.Logger) *InboundRouteRepository {
+	return &InboundRouteRepository{db: db, logger: logger}
+}
+
+func scanInboundRoute(row pgx.Row) (*models.InboundRoute, error) {
+	route := &models.InboundRoute{}
+	err := row.Scan(
+		&route.ID, &route.OrganizationID, &route.Hostname, &route.LocalPart,
+		&route.URL, &route.Secret, &route.IsActive, &route.CreatedAt, &route.UpdatedAt,
+	)
+	return route, err
+}
+
+func (r *InboundRouteRepository) Create(ctx context.Context, orgID uuid.UUID, req *models.CreateInboundRouteRequest) (*models.InboundRoute, error) {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	query := `
+		INSERT INTO inbound_routes (id, organization_id, hostname, local_part, url, secret, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, true, $7, $7)
+		RETURNING ` + inboundRouteColumns
+
+	route, err := scanInboundRoute(r.db.QueryRow(ctx, query,
+		uuid.New(), orgID, strings.ToLower(req.Hostname), strings.ToLower(req.LocalPart),
+		req.URL, hex.EncodeToString(secret), time.Now()))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrInboundRouteExists
+		}
+		return nil, fmt.Errorf("insert inbound route: %w", err)
+	}
+
+	return route, nil
+}
+
+func (r *InboundRouteRepository) GetByID(ctx context.Context, id, orgID uuid.UUID) (*models.InboundRoute, error) {
+	query := `SELECT ` + inboundRouteColumns + ` FROM inbound_routes WHERE id = $1 AND organization_id = $2`
+
+	route, err := scanInboundRoute(r.db.QueryRow(ctx, query, id, orgID))
+	if err == pgx.ErrNoRows {
+		return nil, ErrInboundRouteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query inbound route: %w", err)
+	}
+
+	return route, nil
+}
+
+func (r *InboundRouteRepository) List(ctx context.Context, orgID uuid.UUID, limit, offset int) ([]*models.InboundRoute, int64, error) {
+	countQuery := `SELECT COUNT(*) FROM inbound_routes WHERE organization_id = $1`
+	var total int64
+	if err := r.db.QueryRow(ctx, countQuery, orgID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count inbound routes: %w", err)
+	}
+
+	query := `
+		SELECT ` + inboundRouteColumns + `
+		FROM inbound_routes
+		WHERE organization_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, orgID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query inbound routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []*models.InboundRoute
+	for rows.Next() {
+		route, err := scanInboundRoute(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan inbound route: %w", err)
+		}
+		routes = append(routes, route)
+	}
+
+	return routes, total, nil
+}
+
+func (r *InboundRouteRepository) Update(ctx context.Context, id, orgID uuid.UUID, req *models.UpdateInboundRouteRequest) (*models.InboundRoute, error) {
+	updates := []string{}
+	args := []interface{}{}
+	argCount := 1
+
+	if req.URL != nil {
+		updates = append(updates, fmt.Sprintf("url = $%d", argCount))
+		args = append(args, *req.URL)
+		argCount++
+	}
+	if req.IsActive != nil {
+		updates = append(updates, fmt.Sprintf("is_active = $%d", argCount))
+		args = append(args, *req.IsActive)
+		argCount++
+	}
+
+	if len(updates) == 0 {
+		return r.GetByID(ctx, id, orgID)
+	}
+
+	updates = append(updates, fmt.Sprintf("updated_at = $%d", argCount))
+	args = append(args, time.Now())
+	argCount++
+
+	args = append(args, id, orgID)
+
+	query := fmt.Sprintf(`
+		UPDATE inbound_routes
+		SET %s
+		WHERE id = $%d AND organization_id = $%d
+	`, joinStrings(updates, ", "), argCount, argCount+1)
+
+	result, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("update inbound route: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrInboundRouteNotFound
+	}
+
+	return r.GetByID(ctx, id, orgID)
+}
+
+func (r *InboundRouteRepository) Delete(ctx context.Context, id, orgID uuid.UUID) error {
+	query := `DELETE FROM inbound_routes WHERE id = $1 AND organization_id = $2`
+	result, err := r.db.Exec(ctx, query, id, orgID)
+	if err != nil {
+		return fmt.Errorf("delete inbound route: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrInboundRouteNotFound
+	}
+	return nil
+}
+
+// Match returns the active route for a recipient address. A route for the
+// address itself wins over one for its whole hostname.
+func (r *InboundRouteRepository) Match(ctx context.Context, address string) (*models.InboundRoute, error) {
+	at := strings.LastIndex(address, "@")
+	if at <= 0 {
+		return nil, ErrInboundRouteNotFound
+	}
+	localPart, hostname := strings.ToLower(address[:at]), strings.ToLower(address[at+1:])
+
+	query := `
+		SELECT ` + inboundRouteColumns + `
+		FROM inbound_routes
+		WHERE hostname = $1 AND local_part IN ($2, '') AND is_active
+		ORDER BY local_part = '' ASC
+		LIMIT 1
+	`
+
+	route, err := scanInboundRoute(r.db.QueryRow(ctx, query, hostname, localPart))
+	if err == pgx.ErrNoRows {
+		return nil, ErrInboundRouteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("match inbound route: %w", err)
+	}
+
+	return route, nil
+}
+
+// GetActive returns an active route by ID, for retrying deliveries
+func (r *InboundRouteRepository) GetActive(ctx context.Context, id uuid.UUID) (*models.InboundRoute, error) {
+	query := `SELECT ` + inboundRouteColumns + ` FROM inbound_routes WHERE id = $1 AND is_active`
+
+	route, err := scanInboundRoute(r.db.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrInboundRouteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query inbound route: %w", err)
+	}
+
+	return route, nil
+}
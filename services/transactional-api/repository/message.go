@@ -7,11 +7,14 @@ import (
 	"transactional-api/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var (
-	ErrMessageNotFound = errors.New("message not found")
+	ErrMessageNotFound         = errors.New("message not found")
+	ErrDuplicateIdempotencyKey = errors.New("duplicate idempotency key")
+	ErrMessageNotCancellable   = errors.New("message has already been sent")
 )
 
 // MessageRepository handles database operations for email messages
@@ -64,6 +67,72 @@ func (r *MessageRepository) Create(ctx context.Context, msg *models.Message) err
 	return err
 }
 
+// CreateBatch creates multiple message records in a single transaction
+func (r *MessageRepository) CreateBatch(ctx context.Context, messages []*models.Message) error {
+	query := `
+		INSERT INTO messages (
+			id, domain_id, api_key_id, message_id, from_address, to_addresses,
+			cc_addresses, bcc_addresses, reply_to, subject, html_content, text_content,
+			template_id, categories, custom_args, headers, status, track_opens, track_clicks,
+			scheduled_at, queued_at, batch_id, idempotency_key
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, NULLIF($23, '')
+		)
+	`
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, msg := range messages {
+		batch.Queue(query,
+			msg.ID,
+			msg.DomainID,
+			msg.APIKeyID,
+			msg.ID.String(),
+			msg.From,
+			msg.To,
+			msg.CC,
+			msg.BCC,
+			msg.ReplyTo,
+			msg.Subject,
+			msg.HTML,
+			msg.Text,
+			msg.TemplateID,
+			msg.Categories,
+			msg.CustomArgs,
+			msg.Headers,
+			msg.Status,
+			msg.TrackOpens,
+			msg.TrackClicks,
+			msg.ScheduledAt,
+			msg.QueuedAt,
+			msg.BatchID,
+			msg.IdempotencyKey,
+		)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for range messages {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrDuplicateIdempotencyKey
+			}
+			return err
+		}
+	}
+	if err := results.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
 // GetByID retrieves a message by ID
 func (r *MessageRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Message, error) {
 	query := `
@@ -274,6 +343,75 @@ func (r *MessageRepository) UpdateStatus(ctx context.Context, id uuid.UUID, stat
 	return nil
 }
 
+// TransitionStatus moves a message to a new status only if it is currently in
+// one of the from states. It reports whether the message was updated, so
+// callers racing with a cancellation can tell which side won.
+func (r *MessageRepository) TransitionStatus(ctx context.Context, id uuid.UUID, to models.MessageStatus, from ...models.MessageStatus) (bool, error) {
+	query := `
+		UPDATE messages
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1 AND status = ANY($3)
+	`
+
+	result, err := r.pool.Exec(ctx, query, id, to, statusStrings(from))
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// Cancel cancels a scheduled or queued message belonging to a domain.
+// Returns ErrMessageNotCancellable if sending has already started.
+func (r *MessageRepository) Cancel(ctx context.Context, id, domainID uuid.UUID) error {
+	query := `
+		UPDATE messages
+		SET status = 'cancelled', updated_at = NOW()
+		WHERE id = $1 AND domain_id = $2 AND status IN ('scheduled', 'queued')
+		RETURNING status
+	`
+
+	var status models.MessageStatus
+	err := r.pool.QueryRow(ctx, query, id, domainID).Scan(&status)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	// Nothing was updated; tell a missing message apart from one already sent
+	err = r.pool.QueryRow(ctx, `SELECT status FROM messages WHERE id = $1 AND domain_id = $2`, id, domainID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status == models.MessageStatusCancelled {
+		return nil
+	}
+
+	return ErrMessageNotCancellable
+}
+
+// CancelBatch cancels every scheduled or queued message in a batch and
+// returns how many were cancelled. Messages already sent are left alone.
+func (r *MessageRepository) CancelBatch(ctx context.Context, batchID, domainID uuid.UUID) (int64, error) {
+	query := `
+		UPDATE messages
+		SET status = 'cancelled', updated_at = NOW()
+		WHERE batch_id = $1 AND domain_id = $2 AND status IN ('scheduled', 'queued')
+	`
+
+	result, err := r.pool.Exec(ctx, query, batchID, domainID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
 // MarkSent marks a message as sent
 func (r *MessageRepository) MarkSent(ctx context.Context, id uuid.UUID, smtpResponse string) error {
 	query := `
@@ -456,6 +594,14 @@ func (r *MessageRepository) GetMessageTimeline(ctx context.Context, messageID uu
 	}, nil
 }
 
+func statusStrings(statuses []models.MessageStatus) []string {
+	result := make([]string, len(statuses))
+	for i, s := range statuses {
+		result[i] = string(s)
+	}
+	return result
+}
+
 func itoa(i int) string {
 	return string(rune('0' + i))
 }
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"transactional-api/models"
+)
+
+var (
+	ErrSendingDomainNotFound = errors.New("sending domain not found")
+	ErrSendingDomainExists   = errors.New("domain is already in the sending pool")
+)
+
+// SendingDomainRepository stores organizations' sending domain pools. The
+// domains and their DKIM keys are the ones the SMTP server signs with.
+type SendingDomainRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewSendingDomainRepository(db *pgxpool.Pool, logger *zap.Logger) *SendingDomainRepository {
+	return &SendingDomainRepository{db: db, logger: logger}
+}
+
+// GetPool returns an organization's sending pool, ordered by domain name.
+// An organization without one gets an empty round-robin pool.
+func (r *SendingDomainRepository) GetPool(ctx context.Context, orgID uuid.UUID) (*models.SendingDomainPool, error) {
+	pool := &models.SendingDomainPool{Strategy: models.SendingStrategyRoundRobin}
+
+	err := r.db.QueryRow(ctx, `SELECT strategy FROM sending_domain_pools WHERE organization_id = $1`, orgID).Scan(&pool.Strategy)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("query sending pool: %w", err)
+	}
+
+	query := `
+		SELECT m.domain_id, d.name, m.weight,
+			d.status IN ('verified', 'active') AND d.dkim_verified,
+			EXISTS (
+				SELECT 1 FROM dkim_keys k
+				WHERE k.domain_id = d.id AND k.is_active
+				AND (k.expires_at IS NULL OR k.expires_at > NOW())
+			),
+			m.created_at
+		FROM sending_domain_pool_members m
+		JOIN domains d ON d.id = m.domain_id
+		WHERE m.organization_id = $1
+		ORDER BY d.name
+	`
+
+	rows, err := r.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("query sending domains: %w", err)
+	}
+	defer rows.Close()
+
+	pool.Domains = []models.SendingDomain{}
+	for rows.Next() {
+		var d models.SendingDomain
+		if err := rows.Scan(&d.DomainID, &d.Name, &d.Weight, &d.DNSVerified, &d.HasDKIMKey, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan sending domain: %w", err)
+		}
+		d.Eligible = d.DNSVerified && d.HasDKIMKey
+		pool.Domains = append(pool.Domains, d)
+	}
+
+	return pool, rows.Err()
+}
+
+// SetStrategy sets how an organization's sending domains are picked
+func (r *SendingDomainRepository) SetStrategy(ctx context.Context, orgID uuid.UUID, strategy models.SendingStrategy) error {
+	query := `
+		INSERT INTO sending_domain_pools (organization_id, strategy, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id) DO UPDATE SET strategy = EXCLUDED.strategy, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := r.db.Exec(ctx, query, orgID, strategy, time.Now()); err != nil {
+		return fmt.Errorf("set sending strategy: %w", err)
+	}
+	return nil
+}
+
+// AddDomain adds one of the organization's own domains to its pool
+func (r *SendingDomainRepository) AddDomain(ctx context.Context, orgID, domainID uuid.UUID, weight int) error {
+	query := `
+		INSERT INTO sending_domain_pool_members (organization_id, domain_id, weight, created_at)
+		SELECT $1, id, $3, $4 FROM domains WHERE id = $2 AND organization_id = $1
+	`
+
+	result, err := r.db.Exec(ctx, query, orgID, domainID, weight, time.Now())
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrSendingDomainExists
+		}
+		return fmt.Errorf("insert sending domain: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrSendingDomainNotFound
+	}
+	return nil
+}
+
+// UpdateWeight changes a pooled domain's weight
+func (r *SendingDomainRepository) UpdateWeight(ctx context.Context, orgID, domainID uuid.UUID, weight int) error {
+	query := `UPDATE sending_domain_pool_members SET weight = $3 WHERE organization_id = $1 AND domain_id = $2`
+	result, err := r.db.Exec(ctx, query, orgID, domainID, weight)
+	if err != nil {
+		return fmt.Errorf("update sending domain: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrSendingDomainNotFound
+	}
+	return nil
+}
+
+// RemoveDomain takes a domain out of the organization's pool
+func (r *SendingDomainRepository) RemoveDomain(ctx context.Context, orgID, domainID uuid.UUID) error {
+	query := `DELETE FROM sending_domain_pool_members WHERE organization_id = $1 AND domain_id = $2`
+	result, err := r.db.Exec(ctx, query, orgID, domainID)
+	if err != nil {
+		return fmt.Errorf("delete sending domain: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrSendingDomainNotFound
+	}
+	return nil
+}
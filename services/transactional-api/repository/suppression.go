@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,6 +14,28 @@ import (
 	"transactional-api/models"
 )
 
+// ErrSuppressionNotFound is returned when an address isn't suppressed
+var ErrSuppressionNotFound = errors.New("suppression not found")
+
+// suppressionColumns are the columns read by scanSuppression
+const suppressionColumns = `id, organization_id, email, type, COALESCE(reason, ''), reason_code, source, expires_at, created_at, updated_at`
+
+// liveSuppression is the condition for suppressions that haven't expired
+const liveSuppression = `(expires_at IS NULL OR expires_at > NOW())`
+
+// scanSuppression reads a row of suppressionColumns
+func scanSuppression(row pgx.Row) (*models.Suppression, error) {
+	suppression := &models.Suppression{}
+	err := row.Scan(
+		&suppression.ID, &suppression.OrganizationID, &suppression.Email, &suppression.Type, &suppression.Reason,
+		&suppression.ReasonCode, &suppression.Source, &suppression.ExpiresAt, &suppression.CreatedAt, &suppression.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return suppression, nil
+}
+
 type SuppressionRepository struct {
 	db     *pgxpool.Pool
 	logger *zap.Logger
@@ -22,14 +45,18 @@ func NewSuppressionRepository(db *pgxpool.Pool, logger *zap.Logger) *Suppression
 	return &SuppressionRepository{db: db, logger: logger}
 }
 
-func (r *SuppressionRepository) Add(ctx context.Context, orgID uuid.UUID, email string, suppressionType models.SuppressionType, reason string) error {
+// Add puts an address on a suppression list, or renews its entry there.
+// source records what added it (see models.SuppressionSource*).
+func (r *SuppressionRepository) Add(ctx context.Context, orgID uuid.UUID, email string, suppressionType models.SuppressionType, reason, source string) error {
 	query := `
-		INSERT INTO suppressions (id, organization_id, email, type, reason, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (organization_id, email, type) DO UPDATE SET reason = $5, created_at = $6
+		INSERT INTO suppressions (id, organization_id, email, type, reason, reason_code, source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (organization_id, email, type) DO UPDATE
+		SET reason = $5, source = $7, created_at = $8, updated_at = $8, expires_at = NULL
 	`
 
-	_, err := r.db.Exec(ctx, query, uuid.New(), orgID, email, suppressionType, reason, time.Now())
+	_, err := r.db.Exec(ctx, query, uuid.New(), orgID, email, suppressionType, reason,
+		models.ReasonCodeForType(suppressionType), source, time.Now())
 	if err != nil {
 		return fmt.Errorf("insert suppression: %w", err)
 	}
@@ -37,20 +64,206 @@ func (r *SuppressionRepository) Add(ctx context.Context, orgID uuid.UUID, email
 	return nil
 }
 
-func (r *SuppressionRepository) Remove(ctx context.Context, orgID uuid.UUID, email string, suppressionType models.SuppressionType) error {
-	query := `DELETE FROM suppressions WHERE organization_id = $1 AND email = $2 AND type = $3`
-	result, err := r.db.Exec(ctx, query, orgID, email, suppressionType)
+// Remove takes an address off one suppression list, recording the removal
+// against apiKeyID (uuid.Nil if there is none)
+func (r *SuppressionRepository) Remove(ctx context.Context, orgID uuid.UUID, email string, suppressionType models.SuppressionType, apiKeyID uuid.UUID) error {
+	removed, err := r.remove(ctx, orgID, email, `AND type = $3`, apiKeyID, suppressionType)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrSuppressionNotFound
+	}
+	return nil
+}
+
+// RemoveAddress un-suppresses an address on every list, recording each
+// removal against apiKeyID. It returns ErrSuppressionNotFound if the address
+// wasn't suppressed.
+func (r *SuppressionRepository) RemoveAddress(ctx context.Context, orgID uuid.UUID, email string, apiKeyID uuid.UUID) (int, error) {
+	removed, err := r.remove(ctx, orgID, email, "", apiKeyID)
+	if err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, ErrSuppressionNotFound
+	}
+	return removed, nil
+}
+
+// remove deletes an address's suppressions matching filter, whose arguments
+// start at $3, and audits each one in the same transaction
+func (r *SuppressionRepository) remove(ctx context.Context, orgID uuid.UUID, email, filter string, apiKeyID uuid.UUID, args ...any) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `DELETE FROM suppressions WHERE organization_id = $1 AND email = $2 `+filter+` RETURNING reason_code`,
+		append([]any{orgID, email}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("delete suppression: %w", err)
+	}
+	var codes []models.SuppressionReasonCode
+	for rows.Next() {
+		var code models.SuppressionReasonCode
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan deleted suppression: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("delete suppression: %w", err)
+	}
+
+	for _, code := range codes {
+		if err := insertSuppressionAudit(ctx, tx, orgID, email, "removed", code, "", models.SuppressionSourceAPI, apiKeyID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit transaction: %w", err)
+	}
+	return len(codes), nil
+}
+
+// Upsert suppresses an imported address. An address that is already
+// suppressed keeps a single entry: its reason is replaced only by a stronger
+// one, and with the same reason its expiry is only ever extended. Expired
+// entries are dropped first. The change is audited against apiKeyID.
+func (r *SuppressionRepository) Upsert(ctx context.Context, orgID uuid.UUID, entry *models.SuppressionEntry, apiKeyID uuid.UUID) (models.SuppressionUpsertResult, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize changes to the address, which may have no row to lock yet
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1::text || ':' || $2))`, orgID, entry.Email); err != nil {
+		return "", fmt.Errorf("lock suppression: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM suppressions
+		WHERE organization_id = $1 AND email = $2 AND NOT `+liveSuppression,
+		orgID, entry.Email); err != nil {
+		return "", fmt.Errorf("delete expired suppressions: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT `+suppressionColumns+` FROM suppressions WHERE organization_id = $1 AND email = $2`, orgID, entry.Email)
+	if err != nil {
+		return "", fmt.Errorf("query suppressions: %w", err)
+	}
+	var current *models.Suppression
+	for rows.Next() {
+		suppression, err := scanSuppression(rows)
+		if err != nil {
+			rows.Close()
+			return "", fmt.Errorf("scan suppression: %w", err)
+		}
+		if current == nil || suppression.ReasonCode.StrongerThan(current.ReasonCode) {
+			current = suppression
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("query suppressions: %w", err)
+	}
+
+	now := time.Now()
+	var result models.SuppressionUpsertResult
+	var previous models.SuppressionReasonCode
+	switch {
+	case current == nil:
+		_, err = tx.Exec(ctx, `
+			INSERT INTO suppressions (id, organization_id, email, type, reason, reason_code, source, expires_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		`, uuid.New(), orgID, entry.Email, entry.ReasonCode.Type(), "imported", entry.ReasonCode, entry.Source, entry.ExpiresAt, now)
+		result = models.SuppressionAdded
+	case entry.ReasonCode.StrongerThan(current.ReasonCode):
+		_, err = tx.Exec(ctx, `
+			UPDATE suppressions SET type = $2, reason_code = $3, source = $4, expires_at = $5, updated_at = $6
+			WHERE id = $1
+		`, current.ID, entry.ReasonCode.Type(), entry.ReasonCode, entry.Source, entry.ExpiresAt, now)
+		result, previous = models.SuppressionUpdated, current.ReasonCode
+	case entry.ReasonCode == current.ReasonCode && current.ExpiresAt != nil &&
+		(entry.ExpiresAt == nil || entry.ExpiresAt.After(*current.ExpiresAt)):
+		_, err = tx.Exec(ctx, `UPDATE suppressions SET expires_at = $2, updated_at = $3 WHERE id = $1`, current.ID, entry.ExpiresAt, now)
+		result, previous = models.SuppressionUpdated, current.ReasonCode
+	default:
+		return models.SuppressionUnchanged, nil
+	}
 	if err != nil {
-		return fmt.Errorf("delete suppression: %w", err)
+		return "", fmt.Errorf("upsert suppression: %w", err)
+	}
+
+	if err := insertSuppressionAudit(ctx, tx, orgID, entry.Email, string(result), entry.ReasonCode, previous, entry.Source, apiKeyID); err != nil {
+		return "", err
 	}
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("suppression not found")
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// insertSuppressionAudit records a change to an address's suppressions
+func insertSuppressionAudit(ctx context.Context, tx pgx.Tx, orgID uuid.UUID, email, action string, code, previous models.SuppressionReasonCode, source string, apiKeyID uuid.UUID) error {
+	var keyID *uuid.UUID
+	if apiKeyID != uuid.Nil {
+		keyID = &apiKeyID
+	}
+	var previousCode *models.SuppressionReasonCode
+	if previous != "" {
+		previousCode = &previous
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO suppression_audit (id, organization_id, email, action, reason_code, previous_reason_code, source, api_key_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, uuid.New(), orgID, email, action, code, previousCode, source, keyID, time.Now())
+	if err != nil {
+		return fmt.Errorf("insert suppression audit: %w", err)
 	}
 	return nil
 }
 
+// ListAudit returns the most recent changes to an address's suppressions,
+// newest first
+func (r *SuppressionRepository) ListAudit(ctx context.Context, orgID uuid.UUID, email string, limit int) ([]*models.SuppressionAuditEntry, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, email, action, reason_code, COALESCE(previous_reason_code, ''), source, api_key_id, created_at
+		FROM suppression_audit
+		WHERE organization_id = $1 AND email = $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, orgID, email, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query suppression audit: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.SuppressionAuditEntry{}
+	for rows.Next() {
+		entry := &models.SuppressionAuditEntry{}
+		if err := rows.Scan(
+			&entry.ID, &entry.Email, &entry.Action, &entry.ReasonCode, &entry.PreviousReasonCode,
+			&entry.Source, &entry.APIKeyID, &entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan suppression audit: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
 func (r *SuppressionRepository) Exists(ctx context.Context, orgID uuid.UUID, email string) (bool, models.SuppressionType, error) {
-	query := `SELECT type FROM suppressions WHERE organization_id = $1 AND email = $2 LIMIT 1`
+	query := `SELECT type FROM suppressions WHERE organization_id = $1 AND email = $2 AND ` + liveSuppression + ` LIMIT 1`
 
 	var suppressionType models.SuppressionType
 	err := r.db.QueryRow(ctx, query, orgID, email).Scan(&suppressionType)
@@ -65,21 +278,37 @@ func (r *SuppressionRepository) Exists(ctx context.Context, orgID uuid.UUID, ema
 }
 
 func (r *SuppressionRepository) List(ctx context.Context, orgID uuid.UUID, suppressionType models.SuppressionType, limit, offset int) ([]*models.Suppression, int64, error) {
-	countQuery := `SELECT COUNT(*) FROM suppressions WHERE organization_id = $1 AND type = $2`
+	return r.list(ctx, `AND type = $2`, []any{orgID, suppressionType}, limit, offset)
+}
+
+// ListByReason lists live suppressions with a reason code, or all of them if
+// code is empty
+func (r *SuppressionRepository) ListByReason(ctx context.Context, orgID uuid.UUID, code models.SuppressionReasonCode, limit, offset int) ([]*models.Suppression, int64, error) {
+	if code == "" {
+		return r.list(ctx, "", []any{orgID}, limit, offset)
+	}
+	return r.list(ctx, `AND reason_code = $2`, []any{orgID, code}, limit, offset)
+}
+
+// list pages through live suppressions matching filter, newest first. args
+// holds the organization ID and the filter's arguments.
+func (r *SuppressionRepository) list(ctx context.Context, filter string, args []any, limit, offset int) ([]*models.Suppression, int64, error) {
+	where := `WHERE organization_id = $1 AND ` + liveSuppression + ` ` + filter
+
 	var total int64
-	if err := r.db.QueryRow(ctx, countQuery, orgID, suppressionType).Scan(&total); err != nil {
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM suppressions `+where, args...).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("count suppressions: %w", err)
 	}
 
-	query := `
-		SELECT id, organization_id, email, type, reason, created_at
+	query := fmt.Sprintf(`
+		SELECT %s
 		FROM suppressions
-		WHERE organization_id = $1 AND type = $2
+		%s
 		ORDER BY created_at DESC
-		LIMIT $3 OFFSET $4
-	`
+		LIMIT $%d OFFSET $%d
+	`, suppressionColumns, where, len(args)+1, len(args)+2)
 
-	rows, err := r.db.Query(ctx, query, orgID, suppressionType, limit, offset)
+	rows, err := r.db.Query(ctx, query, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("query suppressions: %w", err)
 	}
@@ -87,11 +316,8 @@ func (r *SuppressionRepository) List(ctx context.Context, orgID uuid.UUID, suppr
 
 	var suppressions []*models.Suppression
 	for rows.Next() {
-		suppression := &models.Suppression{}
-		if err := rows.Scan(
-			&suppression.ID, &suppression.OrganizationID, &suppression.Email,
-			&suppression.Type, &suppression.Reason, &suppression.CreatedAt,
-		); err != nil {
+		suppression, err := scanSuppression(rows)
+		if err != nil {
 			return nil, 0, fmt.Errorf("scan suppression: %w", err)
 		}
 		suppressions = append(suppressions, suppression)
@@ -100,9 +326,36 @@ func (r *SuppressionRepository) List(ctx context.Context, orgID uuid.UUID, suppr
 	return suppressions, total, nil
 }
 
+// Export streams every live suppression with a reason code (or all of them
+// if code is empty) to fn, oldest first
+func (r *SuppressionRepository) Export(ctx context.Context, orgID uuid.UUID, code models.SuppressionReasonCode, fn func(*models.Suppression) error) error {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+suppressionColumns+`
+		FROM suppressions
+		WHERE organization_id = $1 AND `+liveSuppression+` AND ($2::text = '' OR reason_code = $2)
+		ORDER BY created_at, email
+	`, orgID, string(code))
+	if err != nil {
+		return fmt.Errorf("query suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		suppression, err := scanSuppression(rows)
+		if err != nil {
+			return fmt.Errorf("scan suppression: %w", err)
+		}
+		if err := fn(suppression); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 func (r *SuppressionRepository) GetAllForEmail(ctx context.Context, orgID uuid.UUID, email string) ([]*models.Suppression, error) {
 	query := `
-		SELECT id, organization_id, email, type, reason, created_at
+		SELECT ` + suppressionColumns + `
 		FROM suppressions
 		WHERE organization_id = $1 AND email = $2
 	`
@@ -115,11 +368,8 @@ func (r *SuppressionRepository) GetAllForEmail(ctx context.Context, orgID uuid.U
 
 	var suppressions []*models.Suppression
 	for rows.Next() {
-		suppression := &models.Suppression{}
-		if err := rows.Scan(
-			&suppression.ID, &suppression.OrganizationID, &suppression.Email,
-			&suppression.Type, &suppression.Reason, &suppression.CreatedAt,
-		); err != nil {
+		suppression, err := scanSuppression(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan suppression: %w", err)
 		}
 		suppressions = append(suppressions, suppression)
@@ -134,10 +384,10 @@ func (r *SuppressionRepository) BulkAdd(ctx context.Context, orgID uuid.UUID, em
 
 	for _, email := range emails {
 		batch.Queue(`
-			INSERT INTO suppressions (id, organization_id, email, type, reason, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6)
+			INSERT INTO suppressions (id, organization_id, email, type, reason, reason_code, source, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
 			ON CONFLICT (organization_id, email, type) DO NOTHING
-		`, uuid.New(), orgID, email, suppressionType, reason, now)
+		`, uuid.New(), orgID, email, suppressionType, reason, models.ReasonCodeForType(suppressionType), models.SuppressionSourceAPI, now)
 	}
 
 	results := r.db.SendBatch(ctx, batch)
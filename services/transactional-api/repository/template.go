@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"time"
@@ -15,6 +16,54 @@ import (
 	"transactional-api/models"
 )
 
+var (
+	ErrTemplateNotFound        = errors.New("template not found")
+	ErrTemplateVersionNotFound = errors.New("template version not found")
+	// ErrTemplateReadOnly is returned for changes to a template the API key
+	// can use but not edit: a system template, or a shared template created
+	// by another key when the key isn't an admin
+	ErrTemplateReadOnly = errors.New("template is read-only for this API key")
+)
+
+// templateColumns are the columns read by scanTemplate
+const templateColumns = `id, organization_id, api_key_id, visibility, name, description, subject, text_body, html_body, variables, active_version, is_active, created_at, updated_at`
+
+// visibleTemplate is the condition for templates the key can read and send
+// with: its own, its organization's shared ones and system templates. $1 and
+// $2 are the organization and key IDs.
+const visibleTemplate = `deleted_at IS NULL AND (visibility = 'system'
+	OR (organization_id = $1 AND (visibility = 'shared' OR api_key_id = $2)))`
+
+// editableTemplate narrows visibleTemplate to the templates the key can
+// change: its own, shared ones without an owner and, for admin keys ($3),
+// every shared one
+const editableTemplate = visibleTemplate + ` AND visibility <> 'system'
+	AND (api_key_id = $2 OR api_key_id IS NULL OR (visibility = 'shared' AND $3::boolean))`
+
+// scanTemplate reads a row of templateColumns and sets the template's Source
+// for the key in access
+func scanTemplate(row pgx.Row, access models.TemplateAccess) (*models.Template, error) {
+	template := &models.Template{}
+	err := row.Scan(
+		&template.ID, &template.OrganizationID, &template.APIKeyID, &template.Visibility, &template.Name, &template.Description,
+		&template.Subject, &template.TextBody, &template.HTMLBody, &template.Variables,
+		&template.ActiveVersion, &template.IsActive, &template.CreatedAt, &template.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case template.Visibility == models.TemplateVisibilitySystem:
+		template.Source = models.TemplateSourceSystem
+	case template.APIKeyID != nil && *template.APIKeyID == access.APIKeyID:
+		template.Source = models.TemplateSourceOwn
+	default:
+		template.Source = models.TemplateSourceShared
+	}
+	return template, nil
+}
+
 type TemplateRepository struct {
 	db     *pgxpool.Pool
 	logger *zap.Logger
@@ -24,7 +73,23 @@ func NewTemplateRepository(db *pgxpool.Pool, logger *zap.Logger) *TemplateReposi
 	return &TemplateRepository{db: db, logger: logger}
 }
 
-func (r *TemplateRepository) Create(ctx context.Context, orgID uuid.UUID, req *models.CreateTemplateRequest) (*models.Template, error) {
+// Create adds a template owned by the key in access, private unless the
+// request shares it
+func (r *TemplateRepository) Create(ctx context.Context, access models.TemplateAccess, req *models.CreateTemplateRequest) (*models.Template, error) {
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = models.TemplateVisibilityPrivate
+	}
+	return r.insert(ctx, &access.OrganizationID, &access.APIKeyID, visibility, access, req)
+}
+
+// CreateSystem adds a template to the system gallery, which every
+// organization can read and send with but not change
+func (r *TemplateRepository) CreateSystem(ctx context.Context, req *models.CreateTemplateRequest) (*models.Template, error) {
+	return r.insert(ctx, nil, nil, models.TemplateVisibilitySystem, models.TemplateAccess{}, req)
+}
+
+func (r *TemplateRepository) insert(ctx context.Context, orgID, apiKeyID *uuid.UUID, visibility models.TemplateVisibility, access models.TemplateAccess, req *models.CreateTemplateRequest) (*models.Template, error) {
 	id := uuid.New()
 	now := time.Now()
 
@@ -32,17 +97,11 @@ func (r *TemplateRepository) Create(ctx context.Context, orgID uuid.UUID, req *m
 	variables := extractTemplateVariables(req.Subject + req.TextBody + req.HTMLBody)
 
 	query := `
-		INSERT INTO email_templates (id, organization_id, name, description, subject, text_body, html_body, variables, active_version, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1, true, $9, $9)
-		RETURNING id, organization_id, name, description, subject, text_body, html_body, variables, active_version, is_active, created_at, updated_at
-	`
+		INSERT INTO email_templates (id, organization_id, api_key_id, visibility, name, description, subject, text_body, html_body, variables, active_version, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1, true, $11, $11)
+		RETURNING ` + templateColumns
 
-	template := &models.Template{}
-	err := r.db.QueryRow(ctx, query, id, orgID, req.Name, req.Description, req.Subject, req.TextBody, req.HTMLBody, variables, now).Scan(
-		&template.ID, &template.OrganizationID, &template.Name, &template.Description,
-		&template.Subject, &template.TextBody, &template.HTMLBody, &template.Variables,
-		&template.ActiveVersion, &template.IsActive, &template.CreatedAt, &template.UpdatedAt,
-	)
+	template, err := scanTemplate(r.db.QueryRow(ctx, query, id, orgID, apiKeyID, visibility, req.Name, req.Description, req.Subject, req.TextBody, req.HTMLBody, variables, now), access)
 	if err != nil {
 		return nil, fmt.Errorf("insert template: %w", err)
 	}
@@ -60,21 +119,13 @@ func (r *TemplateRepository) Create(ctx context.Context, orgID uuid.UUID, req *m
 	return template, nil
 }
 
-func (r *TemplateRepository) GetByID(ctx context.Context, id, orgID uuid.UUID) (*models.Template, error) {
-	query := `
-		SELECT id, organization_id, name, description, subject, text_body, html_body, variables, active_version, is_active, created_at, updated_at
-		FROM email_templates
-		WHERE id = $1 AND organization_id = $2
-	`
+// GetByID returns a template the key in access can see
+func (r *TemplateRepository) GetByID(ctx context.Context, id uuid.UUID, access models.TemplateAccess) (*models.Template, error) {
+	query := `SELECT ` + templateColumns + ` FROM email_templates WHERE ` + visibleTemplate + ` AND id = $3`
 
-	template := &models.Template{}
-	err := r.db.QueryRow(ctx, query, id, orgID).Scan(
-		&template.ID, &template.OrganizationID, &template.Name, &template.Description,
-		&template.Subject, &template.TextBody, &template.HTMLBody, &template.Variables,
-		&template.ActiveVersion, &template.IsActive, &template.CreatedAt, &template.UpdatedAt,
-	)
+	template, err := scanTemplate(r.db.QueryRow(ctx, query, access.OrganizationID, access.APIKeyID, id), access)
 	if err == pgx.ErrNoRows {
-		return nil, fmt.Errorf("template not found")
+		return nil, ErrTemplateNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("query template: %w", err)
@@ -83,22 +134,33 @@ func (r *TemplateRepository) GetByID(ctx context.Context, id, orgID uuid.UUID) (
 	return template, nil
 }
 
-func (r *TemplateRepository) List(ctx context.Context, orgID uuid.UUID, limit, offset int) ([]*models.Template, int64, error) {
-	countQuery := `SELECT COUNT(*) FROM email_templates WHERE organization_id = $1`
+// checkEditable explains why a template the key can't edit was not changed
+func (r *TemplateRepository) checkEditable(ctx context.Context, id uuid.UUID, access models.TemplateAccess) error {
+	if _, err := r.GetByID(ctx, id, access); err != nil {
+		return err
+	}
+	return ErrTemplateReadOnly
+}
+
+// List returns the templates the key in access can use: its own, the
+// organization's shared templates and the system gallery, by name with
+// system templates last
+func (r *TemplateRepository) List(ctx context.Context, access models.TemplateAccess, limit, offset int) ([]*models.Template, int64, error) {
+	countQuery := `SELECT COUNT(*) FROM email_templates WHERE ` + visibleTemplate
 	var total int64
-	if err := r.db.QueryRow(ctx, countQuery, orgID).Scan(&total); err != nil {
+	if err := r.db.QueryRow(ctx, countQuery, access.OrganizationID, access.APIKeyID).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("count templates: %w", err)
 	}
 
 	query := `
-		SELECT id, organization_id, name, description, subject, text_body, html_body, variables, active_version, is_active, created_at, updated_at
+		SELECT ` + templateColumns + `
 		FROM email_templates
-		WHERE organization_id = $1
-		ORDER BY name ASC
-		LIMIT $2 OFFSET $3
+		WHERE ` + visibleTemplate + `
+		ORDER BY visibility = 'system', name ASC
+		LIMIT $3 OFFSET $4
 	`
 
-	rows, err := r.db.Query(ctx, query, orgID, limit, offset)
+	rows, err := r.db.Query(ctx, query, access.OrganizationID, access.APIKeyID, limit, offset)
 	if err != nil {
 		return nil, 0, fmt.Errorf("query templates: %w", err)
 	}
@@ -106,12 +168,8 @@ func (r *TemplateRepository) List(ctx context.Context, orgID uuid.UUID, limit, o
 
 	var templates []*models.Template
 	for rows.Next() {
-		template := &models.Template{}
-		if err := rows.Scan(
-			&template.ID, &template.OrganizationID, &template.Name, &template.Description,
-			&template.Subject, &template.TextBody, &template.HTMLBody, &template.Variables,
-			&template.ActiveVersion, &template.IsActive, &template.CreatedAt, &template.UpdatedAt,
-		); err != nil {
+		template, err := scanTemplate(rows, access)
+		if err != nil {
 			return nil, 0, fmt.Errorf("scan template: %w", err)
 		}
 		templates = append(templates, template)
@@ -120,11 +178,15 @@ func (r *TemplateRepository) List(ctx context.Context, orgID uuid.UUID, limit, o
 	return templates, total, nil
 }
 
-func (r *TemplateRepository) Update(ctx context.Context, id, orgID uuid.UUID, req *models.UpdateTemplateRequest) (*models.Template, error) {
+// Update changes a template. Name, description and status are updated in
+// place; content changes never overwrite a version but add a new one that
+// becomes the latest, so earlier versions stay available for pinned sends
+// and rollback.
+func (r *TemplateRepository) Update(ctx context.Context, id uuid.UUID, access models.TemplateAccess, req *models.UpdateTemplateRequest) (*models.Template, error) {
 	// Build dynamic update query
 	updates := []string{}
-	args := []interface{}{}
-	argCount := 1
+	args := []interface{}{access.OrganizationID, access.APIKeyID, access.Admin, id}
+	argCount := 5
 
 	if req.Name != nil {
 		updates = append(updates, fmt.Sprintf("name = $%d", argCount))
@@ -136,95 +198,93 @@ func (r *TemplateRepository) Update(ctx context.Context, id, orgID uuid.UUID, re
 		args = append(args, *req.Description)
 		argCount++
 	}
-	if req.Subject != nil {
-		updates = append(updates, fmt.Sprintf("subject = $%d", argCount))
-		args = append(args, *req.Subject)
-		argCount++
-	}
-	if req.TextBody != nil {
-		updates = append(updates, fmt.Sprintf("text_body = $%d", argCount))
-		args = append(args, *req.TextBody)
-		argCount++
-	}
-	if req.HTMLBody != nil {
-		updates = append(updates, fmt.Sprintf("html_body = $%d", argCount))
-		args = append(args, *req.HTMLBody)
-		argCount++
-	}
 	if req.IsActive != nil {
 		updates = append(updates, fmt.Sprintf("is_active = $%d", argCount))
 		args = append(args, *req.IsActive)
 		argCount++
 	}
-
-	if len(updates) == 0 {
-		return r.GetByID(ctx, id, orgID)
+	if req.Visibility != nil {
+		// A template without an owner goes to the key making it private
+		updates = append(updates, fmt.Sprintf("visibility = $%d", argCount), "api_key_id = COALESCE(api_key_id, $2)")
+		args = append(args, *req.Visibility)
+		argCount++
 	}
 
-	// Re-extract variables if content changed
-	if req.Subject != nil || req.TextBody != nil || req.HTMLBody != nil {
-		existing, err := r.GetByID(ctx, id, orgID)
+	if len(updates) > 0 {
+		updates = append(updates, fmt.Sprintf("updated_at = $%d", argCount))
+		args = append(args, time.Now())
+
+		query := fmt.Sprintf(`
+			UPDATE email_templates
+			SET %s
+			WHERE %s AND id = $4
+		`, joinStrings(updates, ", "), editableTemplate)
+
+		result, err := r.db.Exec(ctx, query, args...)
 		if err != nil {
-			return nil, err
-		}
-		subject := existing.Subject
-		if req.Subject != nil {
-			subject = *req.Subject
+			return nil, fmt.Errorf("update template: %w", err)
 		}
-		textBody := existing.TextBody
-		if req.TextBody != nil {
-			textBody = *req.TextBody
+		if result.RowsAffected() == 0 {
+			return nil, r.checkEditable(ctx, id, access)
 		}
-		htmlBody := existing.HTMLBody
-		if req.HTMLBody != nil {
-			htmlBody = *req.HTMLBody
-		}
-		variables := extractTemplateVariables(subject + textBody + htmlBody)
-		updates = append(updates, fmt.Sprintf("variables = $%d", argCount))
-		args = append(args, variables)
-		argCount++
 	}
 
-	updates = append(updates, fmt.Sprintf("updated_at = $%d", argCount))
-	args = append(args, time.Now())
-	argCount++
-
-	args = append(args, id, orgID)
-
-	query := fmt.Sprintf(`
-		UPDATE email_templates
-		SET %s
-		WHERE id = $%d AND organization_id = $%d
-	`, joinStrings(updates, ", "), argCount, argCount+1)
-
-	_, err := r.db.Exec(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("update template: %w", err)
+	if req.Subject != nil || req.TextBody != nil || req.HTMLBody != nil {
+		_, err := r.addVersion(ctx, id, access, func(current *models.TemplateVersion) (*models.TemplateVersion, bool) {
+			next := *current
+			if req.Subject != nil {
+				next.Subject = *req.Subject
+			}
+			if req.TextBody != nil {
+				next.TextBody = *req.TextBody
+			}
+			if req.HTMLBody != nil {
+				next.HTMLBody = *req.HTMLBody
+			}
+			// Saving identical content doesn't make a new version
+			changed := next.Subject != current.Subject || next.TextBody != current.TextBody || next.HTMLBody != current.HTMLBody
+			return &next, changed
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return r.GetByID(ctx, id, orgID)
+	return r.GetByID(ctx, id, access)
 }
 
-func (r *TemplateRepository) Delete(ctx context.Context, id, orgID uuid.UUID) error {
-	query := `DELETE FROM email_templates WHERE id = $1 AND organization_id = $2`
-	result, err := r.db.Exec(ctx, query, id, orgID)
-	if err != nil {
+// Delete removes a template. Shared templates are only marked deleted: other
+// keys may be sending with them, and they stay available for tracing the
+// messages already rendered from them.
+func (r *TemplateRepository) Delete(ctx context.Context, id uuid.UUID, access models.TemplateAccess) error {
+	query := `
+		WITH target AS (
+			SELECT id, visibility FROM email_templates WHERE ` + editableTemplate + ` AND id = $4
+		), soft AS (
+			UPDATE email_templates t SET deleted_at = NOW(), updated_at = NOW()
+			FROM target WHERE t.id = target.id AND target.visibility = 'shared'
+			RETURNING t.id
+		), hard AS (
+			DELETE FROM email_templates t
+			USING target WHERE t.id = target.id AND target.visibility <> 'shared'
+			RETURNING t.id
+		)
+		SELECT (SELECT COUNT(*) FROM soft) + (SELECT COUNT(*) FROM hard)
+	`
+	var deleted int
+	if err := r.db.QueryRow(ctx, query, access.OrganizationID, access.APIKeyID, access.Admin, id).Scan(&deleted); err != nil {
 		return fmt.Errorf("delete template: %w", err)
 	}
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("template not found")
+	if deleted == 0 {
+		return r.checkEditable(ctx, id, access)
 	}
 	return nil
 }
 
-func (r *TemplateRepository) ListVersions(ctx context.Context, templateID, orgID uuid.UUID, limit, offset int) ([]*models.TemplateVersion, int64, error) {
-	// First verify template belongs to org
-	checkQuery := `SELECT 1 FROM email_templates WHERE id = $1 AND organization_id = $2`
-	var exists int
-	if err := r.db.QueryRow(ctx, checkQuery, templateID, orgID).Scan(&exists); err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, 0, fmt.Errorf("template not found")
-		}
+// ListVersions returns a page of a template's versions, newest first
+func (r *TemplateRepository) ListVersions(ctx context.Context, templateID uuid.UUID, access models.TemplateAccess, limit, offset int) ([]*models.TemplateVersion, int64, error) {
+	// First verify the key can see the template
+	if _, err := r.GetByID(ctx, templateID, access); err != nil {
 		return nil, 0, err
 	}
 
@@ -235,7 +295,7 @@ func (r *TemplateRepository) ListVersions(ctx context.Context, templateID, orgID
 	}
 
 	query := `
-		SELECT id, template_id, version, subject, text_body, html_body, variables, created_at, created_by
+		SELECT id, template_id, version, subject, text_body, html_body, created_at, created_by, COALESCE(change_note, '')
 		FROM email_template_versions
 		WHERE template_id = $1
 		ORDER BY version DESC
@@ -254,7 +314,7 @@ func (r *TemplateRepository) ListVersions(ctx context.Context, templateID, orgID
 		if err := rows.Scan(
 			&version.ID, &version.TemplateID, &version.Version,
 			&version.Subject, &version.TextBody, &version.HTMLBody,
-			&version.Variables, &version.CreatedAt, &version.CreatedBy,
+			&version.CreatedAt, &version.CreatedBy, &version.ChangeNote,
 		); err != nil {
 			return nil, 0, fmt.Errorf("scan version: %w", err)
 		}
@@ -264,39 +324,123 @@ func (r *TemplateRepository) ListVersions(ctx context.Context, templateID, orgID
 	return versions, total, nil
 }
 
-func (r *TemplateRepository) CreateVersion(ctx context.Context, templateID, orgID uuid.UUID, req *models.CreateTemplateRequest) (*models.TemplateVersion, error) {
-	// Get current template and verify ownership
-	template, err := r.GetByID(ctx, templateID, orgID)
+// GetVersion returns one version of a template
+func (r *TemplateRepository) GetVersion(ctx context.Context, templateID uuid.UUID, access models.TemplateAccess, version int) (*models.TemplateVersion, error) {
+	query := `
+		SELECT v.id, v.template_id, v.version, v.subject, v.text_body, v.html_body, v.created_at, v.created_by, COALESCE(v.change_note, '')
+		FROM email_template_versions v
+		WHERE v.template_id = $3 AND v.version = $4
+		AND EXISTS (SELECT 1 FROM email_templates WHERE id = v.template_id AND ` + visibleTemplate + `)
+	`
+
+	v := &models.TemplateVersion{}
+	err := r.db.QueryRow(ctx, query, access.OrganizationID, access.APIKeyID, templateID, version).Scan(
+		&v.ID, &v.TemplateID, &v.Version,
+		&v.Subject, &v.TextBody, &v.HTMLBody,
+		&v.CreatedAt, &v.CreatedBy, &v.ChangeNote,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, ErrTemplateVersionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query template version: %w", err)
+	}
+
+	return v, nil
+}
+
+// CreateVersion adds a version with the given content and makes it the latest
+func (r *TemplateRepository) CreateVersion(ctx context.Context, templateID uuid.UUID, access models.TemplateAccess, req *models.CreateTemplateRequest) (*models.TemplateVersion, error) {
+	return r.addVersion(ctx, templateID, access, func(current *models.TemplateVersion) (*models.TemplateVersion, bool) {
+		return &models.TemplateVersion{
+			Subject:  req.Subject,
+			TextBody: req.TextBody,
+			HTMLBody: req.HTMLBody,
+		}, true
+	})
+}
+
+// Rollback promotes an earlier version to latest. The old version is not
+// reactivated but copied into a new version, so history stays linear and
+// messages pinned to any version keep rendering the same content.
+func (r *TemplateRepository) Rollback(ctx context.Context, templateID uuid.UUID, access models.TemplateAccess, version int) (*models.TemplateVersion, error) {
+	target, err := r.GetVersion(ctx, templateID, access, version)
 	if err != nil {
 		return nil, err
 	}
 
+	promoted, err := r.addVersion(ctx, templateID, access, func(current *models.TemplateVersion) (*models.TemplateVersion, bool) {
+		return &models.TemplateVersion{
+			Subject:    target.Subject,
+			TextBody:   target.TextBody,
+			HTMLBody:   target.HTMLBody,
+			ChangeNote: fmt.Sprintf("Rollback to version %d", version),
+		}, current.Version != version
+	})
+	if err != nil {
+		return nil, err
+	}
+	if promoted == nil {
+		// Already the latest version
+		return target, nil
+	}
+
+	return promoted, nil
+}
+
+// addVersion inserts the next version of a template and copies its content
+// into the template. build receives the latest version, read with the
+// template row locked so concurrent edits get consecutive numbers and don't
+// lose each other's changes. If build reports no change, nothing is written
+// and nil is returned.
+func (r *TemplateRepository) addVersion(ctx context.Context, templateID uuid.UUID, access models.TemplateAccess, build func(current *models.TemplateVersion) (*models.TemplateVersion, bool)) (*models.TemplateVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	current := &models.TemplateVersion{TemplateID: templateID}
+	err = tx.QueryRow(ctx, `
+		SELECT subject, text_body, html_body, active_version
+		FROM email_templates
+		WHERE `+editableTemplate+` AND id = $4
+		FOR UPDATE
+	`, access.OrganizationID, access.APIKeyID, access.Admin, templateID).Scan(&current.Subject, &current.TextBody, &current.HTMLBody, &current.Version)
+	if err == pgx.ErrNoRows {
+		return nil, r.checkEditable(ctx, templateID, access)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock template: %w", err)
+	}
+
+	next, changed := build(current)
+	if !changed {
+		return nil, nil
+	}
+
 	// Get next version number
 	var maxVersion int
 	versionQuery := `SELECT COALESCE(MAX(version), 0) FROM email_template_versions WHERE template_id = $1`
-	if err := r.db.QueryRow(ctx, versionQuery, templateID).Scan(&maxVersion); err != nil {
+	if err := tx.QueryRow(ctx, versionQuery, templateID).Scan(&maxVersion); err != nil {
 		return nil, fmt.Errorf("get max version: %w", err)
 	}
-	newVersion := maxVersion + 1
 
 	// Extract variables
-	variables := extractTemplateVariables(req.Subject + req.TextBody + req.HTMLBody)
-
-	// Insert new version
-	id := uuid.New()
+	variables := extractTemplateVariables(next.Subject + next.TextBody + next.HTMLBody)
 	now := time.Now()
 
 	insertQuery := `
-		INSERT INTO email_template_versions (id, template_id, version, subject, text_body, html_body, variables, created_at, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, template_id, version, subject, text_body, html_body, variables, created_at, created_by
+		INSERT INTO email_template_versions (id, template_id, version, subject, text_body, html_body, variables, created_at, created_by, change_note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+		RETURNING id, template_id, version, subject, text_body, html_body, created_at, created_by, COALESCE(change_note, '')
 	`
 
 	version := &models.TemplateVersion{}
-	err = r.db.QueryRow(ctx, insertQuery, id, templateID, newVersion, req.Subject, req.TextBody, req.HTMLBody, variables, now, template.OrganizationID).Scan(
+	err = tx.QueryRow(ctx, insertQuery, uuid.New(), templateID, maxVersion+1, next.Subject, next.TextBody, next.HTMLBody, variables, now, access.OrganizationID, next.ChangeNote).Scan(
 		&version.ID, &version.TemplateID, &version.Version,
 		&version.Subject, &version.TextBody, &version.HTMLBody,
-		&version.Variables, &version.CreatedAt, &version.CreatedBy,
+		&version.CreatedAt, &version.CreatedBy, &version.ChangeNote,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("insert version: %w", err)
@@ -308,11 +452,19 @@ func (r *TemplateRepository) CreateVersion(ctx context.Context, templateID, orgI
 		SET subject = $1, text_body = $2, html_body = $3, variables = $4, active_version = $5, updated_at = $6
 		WHERE id = $7
 	`
-	_, err = r.db.Exec(ctx, updateQuery, req.Subject, req.TextBody, req.HTMLBody, variables, newVersion, now, templateID)
+	_, err = tx.Exec(ctx, updateQuery, next.Subject, next.TextBody, next.HTMLBody, variables, version.Version, now, templateID)
 	if err != nil {
 		return nil, fmt.Errorf("update template: %w", err)
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit version: %w", err)
+	}
+
+	r.logger.Info("Template version created",
+		zap.String("template_id", templateID.String()),
+		zap.Int("version", version.Version))
+
 	return version, nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
@@ -15,6 +16,11 @@ import (
 	"transactional-api/models"
 )
 
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
 type WebhookRepository struct {
 	db     *pgxpool.Pool
 	logger *zap.Logger
@@ -24,6 +30,22 @@ func NewWebhookRepository(db *pgxpool.Pool, logger *zap.Logger) *WebhookReposito
 	return &WebhookRepository{db: db, logger: logger}
 }
 
+const webhookColumns = `id, organization_id, url, events, is_active, secret, COALESCE(previous_secret, ''), previous_secret_expires_at,
+		failure_count, last_triggered, failing_since, disabled_at, COALESCE(disabled_reason, ''), created_at, updated_at`
+
+func scanWebhook(row pgx.Row) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	if err := row.Scan(
+		&webhook.ID, &webhook.OrganizationID, &webhook.URL, &webhook.Events,
+		&webhook.IsActive, &webhook.Secret, &webhook.PreviousSecret, &webhook.PreviousSecretExpiresAt,
+		&webhook.FailureCount, &webhook.LastTriggered, &webhook.FailingSince, &webhook.DisabledAt, &webhook.DisabledReason,
+		&webhook.CreatedAt, &webhook.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
 func (r *WebhookRepository) generateSecret() string {
 	bytes := make([]byte, 32)
 	rand.Read(bytes)
@@ -38,15 +60,10 @@ func (r *WebhookRepository) Create(ctx context.Context, orgID uuid.UUID, req *mo
 	query := `
 		INSERT INTO webhooks (id, organization_id, url, events, is_active, secret, failure_count, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, true, $5, 0, $6, $6)
-		RETURNING id, organization_id, url, events, is_active, secret, failure_count, last_triggered, created_at, updated_at
+		RETURNING ` + webhookColumns + `
 	`
 
-	webhook := &models.Webhook{}
-	err := r.db.QueryRow(ctx, query, id, orgID, req.URL, req.Events, secret, now).Scan(
-		&webhook.ID, &webhook.OrganizationID, &webhook.URL, &webhook.Events,
-		&webhook.IsActive, &webhook.Secret, &webhook.FailureCount, &webhook.LastTriggered,
-		&webhook.CreatedAt, &webhook.UpdatedAt,
-	)
+	webhook, err := scanWebhook(r.db.QueryRow(ctx, query, id, orgID, req.URL, req.Events, secret, now))
 	if err != nil {
 		return nil, fmt.Errorf("insert webhook: %w", err)
 	}
@@ -56,19 +73,14 @@ func (r *WebhookRepository) Create(ctx context.Context, orgID uuid.UUID, req *mo
 
 func (r *WebhookRepository) GetByID(ctx context.Context, id, orgID uuid.UUID) (*models.Webhook, error) {
 	query := `
-		SELECT id, organization_id, url, events, is_active, secret, failure_count, last_triggered, created_at, updated_at
+		SELECT ` + webhookColumns + `
 		FROM webhooks
 		WHERE id = $1 AND organization_id = $2
 	`
 
-	webhook := &models.Webhook{}
-	err := r.db.QueryRow(ctx, query, id, orgID).Scan(
-		&webhook.ID, &webhook.OrganizationID, &webhook.URL, &webhook.Events,
-		&webhook.IsActive, &webhook.Secret, &webhook.FailureCount, &webhook.LastTriggered,
-		&webhook.CreatedAt, &webhook.UpdatedAt,
-	)
+	webhook, err := scanWebhook(r.db.QueryRow(ctx, query, id, orgID))
 	if err == pgx.ErrNoRows {
-		return nil, fmt.Errorf("webhook not found")
+		return nil, ErrWebhookNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("query webhook: %w", err)
@@ -85,7 +97,7 @@ func (r *WebhookRepository) List(ctx context.Context, orgID uuid.UUID, limit, of
 	}
 
 	query := `
-		SELECT id, organization_id, url, events, is_active, secret, failure_count, last_triggered, created_at, updated_at
+		SELECT ` + webhookColumns + `
 		FROM webhooks
 		WHERE organization_id = $1
 		ORDER BY created_at DESC
@@ -100,12 +112,8 @@ func (r *WebhookRepository) List(ctx context.Context, orgID uuid.UUID, limit, of
 
 	var webhooks []*models.Webhook
 	for rows.Next() {
-		webhook := &models.Webhook{}
-		if err := rows.Scan(
-			&webhook.ID, &webhook.OrganizationID, &webhook.URL, &webhook.Events,
-			&webhook.IsActive, &webhook.Secret, &webhook.FailureCount, &webhook.LastTriggered,
-			&webhook.CreatedAt, &webhook.UpdatedAt,
-		); err != nil {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
 			return nil, 0, fmt.Errorf("scan webhook: %w", err)
 		}
 		webhooks = append(webhooks, webhook)
@@ -133,6 +141,10 @@ func (r *WebhookRepository) Update(ctx context.Context, id, orgID uuid.UUID, req
 		updates = append(updates, fmt.Sprintf("is_active = $%d", argCount))
 		args = append(args, *req.IsActive)
 		argCount++
+		// Re-enabling gives a webhook disabled for failing a fresh start
+		if *req.IsActive {
+			updates = append(updates, "failure_count = 0", "failing_since = NULL", "disabled_at = NULL", "disabled_reason = NULL")
+		}
 	}
 
 	if len(updates) == 0 {
@@ -166,14 +178,50 @@ func (r *WebhookRepository) Delete(ctx context.Context, id, orgID uuid.UUID) err
 		return fmt.Errorf("delete webhook: %w", err)
 	}
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("webhook not found")
+		return ErrWebhookNotFound
 	}
 	return nil
 }
 
+// RotateSecret replaces the signing secret, keeping the current one as the
+// previous secret until previousExpiresAt
+func (r *WebhookRepository) RotateSecret(ctx context.Context, id, orgID uuid.UUID, previousExpiresAt time.Time) (*models.Webhook, error) {
+	query := `
+		UPDATE webhooks
+		SET previous_secret = secret, previous_secret_expires_at = $3, secret = $4, updated_at = $5
+		WHERE id = $1 AND organization_id = $2
+	`
+
+	result, err := r.db.Exec(ctx, query, id, orgID, previousExpiresAt, r.generateSecret(), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("rotate webhook secret: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrWebhookNotFound
+	}
+
+	return r.GetByID(ctx, id, orgID)
+}
+
+// ClearExpiredSecrets removes previous secrets whose grace period has ended
+func (r *WebhookRepository) ClearExpiredSecrets(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE webhooks
+		SET previous_secret = NULL, previous_secret_expires_at = NULL, updated_at = NOW()
+		WHERE previous_secret_expires_at IS NOT NULL AND previous_secret_expires_at <= NOW()
+	`
+
+	result, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("clear expired webhook secrets: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
 func (r *WebhookRepository) GetByEvent(ctx context.Context, orgID uuid.UUID, eventType string) ([]*models.Webhook, error) {
 	query := `
-		SELECT id, organization_id, url, events, is_active, secret, failure_count, last_triggered, created_at, updated_at
+		SELECT ` + webhookColumns + `
 		FROM webhooks
 		WHERE organization_id = $1 AND is_active = true AND $2 = ANY(events)
 	`
@@ -186,12 +234,8 @@ func (r *WebhookRepository) GetByEvent(ctx context.Context, orgID uuid.UUID, eve
 
 	var webhooks []*models.Webhook
 	for rows.Next() {
-		webhook := &models.Webhook{}
-		if err := rows.Scan(
-			&webhook.ID, &webhook.OrganizationID, &webhook.URL, &webhook.Events,
-			&webhook.IsActive, &webhook.Secret, &webhook.FailureCount, &webhook.LastTriggered,
-			&webhook.CreatedAt, &webhook.UpdatedAt,
-		); err != nil {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan webhook: %w", err)
 		}
 		webhooks = append(webhooks, webhook)
@@ -200,14 +244,241 @@ func (r *WebhookRepository) GetByEvent(ctx context.Context, orgID uuid.UUID, eve
 	return webhooks, nil
 }
 
-func (r *WebhookRepository) IncrementFailureCount(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE webhooks SET failure_count = failure_count + 1, updated_at = $1 WHERE id = $2`
-	_, err := r.db.Exec(ctx, query, time.Now(), id)
-	return err
+// IncrementFailureCount records a failed delivery and returns when the
+// webhook's current run of failures started
+func (r *WebhookRepository) IncrementFailureCount(ctx context.Context, id uuid.UUID) (time.Time, error) {
+	query := `
+		UPDATE webhooks
+		SET failure_count = failure_count + 1, failing_since = COALESCE(failing_since, $1), updated_at = $1
+		WHERE id = $2
+		RETURNING failing_since
+	`
+
+	var failingSince time.Time
+	err := r.db.QueryRow(ctx, query, time.Now(), id).Scan(&failingSince)
+	if err == pgx.ErrNoRows {
+		return time.Time{}, ErrWebhookNotFound
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("increment webhook failure count: %w", err)
+	}
+	return failingSince, nil
 }
 
+// ResetFailureCount records a successful delivery, closing the webhook's
+// circuit
 func (r *WebhookRepository) ResetFailureCount(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE webhooks SET failure_count = 0, last_triggered = $1, updated_at = $1 WHERE id = $2`
+	query := `UPDATE webhooks SET failure_count = 0, failing_since = NULL, last_triggered = $1, updated_at = $1 WHERE id = $2`
 	_, err := r.db.Exec(ctx, query, time.Now(), id)
 	return err
 }
+
+// Disable deactivates a webhook that has been failing too long. It reports
+// false if the webhook was already inactive.
+func (r *WebhookRepository) Disable(ctx context.Context, id uuid.UUID, reason string) (bool, error) {
+	query := `
+		UPDATE webhooks
+		SET is_active = false, disabled_at = $1, disabled_reason = $2, updated_at = $1
+		WHERE id = $3 AND is_active = true
+	`
+
+	result, err := r.db.Exec(ctx, query, time.Now(), reason, id)
+	if err != nil {
+		return false, fmt.Errorf("disable webhook: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// GetOwnerEmail returns the email address of an organization's owner, or ""
+// if it has none
+func (r *WebhookRepository) GetOwnerEmail(ctx context.Context, orgID uuid.UUID) (string, error) {
+	query := `
+		SELECT COALESCE(u.email, '')
+		FROM organizations o
+		JOIN users u ON u.id = o.owner_id
+		WHERE o.id = $1
+	`
+
+	var email string
+	err := r.db.QueryRow(ctx, query, orgID).Scan(&email)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query organization owner: %w", err)
+	}
+	return email, nil
+}
+
+// CreateDelivery records a delivery attempt
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, url, request_body, response_code, response_body,
+			success, status, next_retry_at, error, attempt_number, replay, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0), NULLIF($8, ''), $9, $10, $11, NULLIF($12, ''), $13, $14, $15, $16)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		d.ID, d.WebhookID, d.EventID, d.Event, d.URL, d.RequestBody, d.ResponseCode, d.ResponseBody,
+		d.Success, d.Status, d.NextRetryAt, d.Error, d.AttemptNumber, d.Replay, d.Duration.Milliseconds(), d.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, url, COALESCE(request_body, ''), COALESCE(response_code, 0),
+		COALESCE(response_body, ''), success, status, next_retry_at, COALESCE(error, ''), attempt_number, replay,
+		COALESCE(duration_ms, 0), created_at`
+
+func scanWebhookDelivery(row pgx.Row) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{}
+	var durationMs int64
+	if err := row.Scan(
+		&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.URL, &d.RequestBody, &d.ResponseCode,
+		&d.ResponseBody, &d.Success, &d.Status, &d.NextRetryAt, &d.Error, &d.AttemptNumber, &d.Replay, &durationMs, &d.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	d.Duration = time.Duration(durationMs) * time.Millisecond
+	return d, nil
+}
+
+// GetDelivery returns a delivery attempt of the given webhook
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id, webhookID uuid.UUID) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2`
+
+	d, err := scanWebhookDelivery(r.db.QueryRow(ctx, query, id, webhookID))
+	if err == pgx.ErrNoRows {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query webhook delivery: %w", err)
+	}
+
+	return d, nil
+}
+
+// ListDeliveries returns a webhook's delivery attempts, newest first
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, q *models.WebhookDeliveryQuery) ([]*models.WebhookDelivery, int64, error) {
+	where := ` WHERE webhook_id = $1`
+	args := []interface{}{q.WebhookID}
+
+	if q.Success != nil {
+		args = append(args, *q.Success)
+		where += fmt.Sprintf(" AND success = $%d", len(args))
+	}
+	if q.Status != "" {
+		args = append(args, q.Status)
+		where += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if q.StartDate != nil {
+		args = append(args, *q.StartDate)
+		where += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if q.EndDate != nil {
+		args = append(args, *q.EndDate)
+		where += fmt.Sprintf(" AND created_at <= $%d", len(args))
+	}
+
+	var total int64
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_deliveries`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count webhook deliveries: %w", err)
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries` + where +
+		fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, q.Limit, q.Offset)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, total, nil
+}
+
+// DueRetry is a failed delivery whose retry is due
+type DueRetry struct {
+	Delivery       *models.WebhookDelivery
+	OrganizationID uuid.UUID
+}
+
+// ClaimDueRetries returns up to limit failed deliveries whose retry is due,
+// marking them failed so no other instance retries them too. The caller
+// retries each or moves it to the dead-letter state.
+func (r *WebhookRepository) ClaimDueRetries(ctx context.Context, now time.Time, limit int) ([]*DueRetry, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET status = 'failed', next_retry_at = NULL
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'retrying' AND next_retry_at <= $1
+				ORDER BY next_retry_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT ` + webhookDeliveryColumns + `,
+			(SELECT organization_id FROM webhooks w WHERE w.id = claimed.webhook_id)
+		FROM claimed
+	`
+
+	rows, err := r.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook retries: %w", err)
+	}
+	defer rows.Close()
+
+	var due []*DueRetry
+	for rows.Next() {
+		d := &models.WebhookDelivery{}
+		retry := &DueRetry{Delivery: d}
+		var durationMs int64
+		if err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.URL, &d.RequestBody, &d.ResponseCode,
+			&d.ResponseBody, &d.Success, &d.Status, &d.NextRetryAt, &d.Error, &d.AttemptNumber, &d.Replay, &durationMs, &d.CreatedAt,
+			&retry.OrganizationID,
+		); err != nil {
+			return nil, fmt.Errorf("scan webhook retry: %w", err)
+		}
+		d.Duration = time.Duration(durationMs) * time.Millisecond
+		due = append(due, retry)
+	}
+
+	return due, rows.Err()
+}
+
+// MarkDeliveryDead moves a failed delivery to the dead-letter state
+func (r *WebhookRepository) MarkDeliveryDead(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE webhook_deliveries SET status = 'dead', next_retry_at = NULL WHERE id = $1`
+	if _, err := r.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("dead-letter webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// DeadLetterPending moves a webhook's deliveries awaiting a retry to the
+// dead-letter state, as when it is disabled
+func (r *WebhookRepository) DeadLetterPending(ctx context.Context, webhookID uuid.UUID) (int64, error) {
+	query := `UPDATE webhook_deliveries SET status = 'dead', next_retry_at = NULL WHERE webhook_id = $1 AND status = 'retrying'`
+	result, err := r.db.Exec(ctx, query, webhookID)
+	if err != nil {
+		return 0, fmt.Errorf("dead-letter pending webhook deliveries: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
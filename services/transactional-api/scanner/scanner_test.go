@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"transactional-api/config"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd serves the INSTREAM command, reporting EICAR as infected.
+// It returns the address and a channel with the size of each stream.
+func fakeClamd(t *testing.T, delay time.Duration) (string, <-chan int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sizes := make(chan int, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					return
+				}
+				var content []byte
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					content = append(content, chunk...)
+				}
+				sizes <- len(content)
+				time.Sleep(delay)
+				if bytes.Contains(content, []byte("EICAR-STANDARD-ANTIVIRUS-TEST-FILE")) {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+	return "tcp://" + ln.Addr().String(), sizes
+}
+
+func TestClamAVScan(t *testing.T) {
+	addr, sizes := fakeClamd(t, 0)
+	clam, err := NewClamAV(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := clam.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("Scan(eicar) = %+v", result)
+	}
+	<-sizes
+
+	// Larger than one chunk, streamed from a reader that never holds it all
+	const size = 3*clamdChunkSize + 17
+	result, err = clam.Scan(context.Background(), io.LimitReader(zeros{}, size))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if result.Infected {
+		t.Errorf("Scan(zeros) = %+v", result)
+	}
+	if got := <-sizes; got != size {
+		t.Errorf("clamd received %d bytes, want %d", got, size)
+	}
+}
+
+func TestGuardTimeout(t *testing.T) {
+	addr, _ := fakeClamd(t, time.Second)
+	clam, err := NewClamAV(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := NewGuard(clam, 50*time.Millisecond, false)
+	if _, err := closed.Scan(context.Background(), strings.NewReader("hello")); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("fail-closed Scan() error = %v, want ErrUnavailable", err)
+	}
+
+	open := NewGuard(clam, 50*time.Millisecond, true)
+	result, err := open.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil {
+		t.Fatalf("fail-open Scan() error = %v", err)
+	}
+	if !result.Unscanned || result.Infected || result.Err == nil {
+		t.Errorf("fail-open Scan() = %+v", result)
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	if _, err := parseClamdReply("INSTREAM size limit exceeded. ERROR\x00"); err == nil {
+		t.Error("size limit reply: expected error")
+	}
+	if _, err := parseClamdReply("garbage"); err == nil {
+		t.Error("unexpected reply: expected error")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if g, err := New(&config.ScanningConfig{}); g != nil || err != nil {
+		t.Errorf("New(disabled) = %v, %v", g, err)
+	}
+	if _, err := New(&config.ScanningConfig{Enabled: true}); err == nil {
+		t.Error("New() without an address: expected error")
+	}
+	if _, err := New(&config.ScanningConfig{Enabled: true, Provider: "other", ClamAVAddr: "tcp://localhost:3310"}); err == nil {
+		t.Error("New() with unknown provider: expected error")
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"transactional-api/models"
@@ -25,6 +24,44 @@ func NewAPIKeyService(repo *repository.APIKeyRepository, logger zerolog.Logger)
 	}
 }
 
+// Subscription tiers, as stored in organizations.subscription_tier
+const (
+	TierFree       = "free"
+	TierPro        = "pro"
+	TierBusiness   = "business"
+	TierEnterprise = "enterprise"
+)
+
+// tierMessageLimits are the default per-message limits for each tier
+var tierMessageLimits = map[string]models.MessageLimits{
+	TierFree:       {MaxMessageSize: 10 << 20, MaxRecipients: 50},
+	TierPro:        {MaxMessageSize: 25 << 20, MaxRecipients: 100},
+	TierBusiness:   {MaxMessageSize: 40 << 20, MaxRecipients: 500},
+	TierEnterprise: {MaxMessageSize: 50 << 20, MaxRecipients: 1000},
+}
+
+// TierMessageLimits returns the default message limits for a subscription
+// tier. Unknown tiers get the free tier's limits.
+func TierMessageLimits(tier string) models.MessageLimits {
+	if limits, ok := tierMessageLimits[tier]; ok {
+		return limits
+	}
+	return tierMessageLimits[TierFree]
+}
+
+// KeyMessageLimits returns the message limits for an API key: the key's own
+// overrides where set, otherwise its organization's tier defaults
+func KeyMessageLimits(key *repository.APIKeyResult) models.MessageLimits {
+	limits := TierMessageLimits(key.SubscriptionTier)
+	if key.MaxMessageSize != nil {
+		limits.MaxMessageSize = *key.MaxMessageSize
+	}
+	if key.MaxRecipients != nil {
+		limits.MaxRecipients = *key.MaxRecipients
+	}
+	return limits
+}
+
 // Create creates a new API key
 func (s *APIKeyService) Create(ctx context.Context, req *models.CreateAPIKeyRequest, createdBy uuid.UUID) (*models.CreateAPIKeyResponse, error) {
 	// Set defaults
@@ -40,7 +77,7 @@ func (s *APIKeyService) Create(ctx context.Context, req *models.CreateAPIKeyRequ
 	}
 
 	// Use repo.Create which generates key, hash, and prefix internally
-	result, plainKey, err := s.repo.Create(ctx, req.DomainID, req.Name, scopes, rateLimit, req.ExpiresAt)
+	result, plainKey, err := s.repo.Create(ctx, req.DomainID, req.Name, scopes, rateLimit, req.ExpiresAt, req.MaxMessageSize, req.MaxRecipients)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +116,9 @@ func apiKeyResultToModel(r *repository.APIKeyResult) *models.APIKey {
 		LastUsedAt: r.LastUsedAt,
 		ExpiresAt:  r.ExpiresAt,
 		CreatedAt:  r.CreatedAt,
+
+		MaxMessageSize: r.MaxMessageSize,
+		MaxRecipients:  r.MaxRecipients,
 	}
 	if !r.IsActive {
 		now := time.Now()
@@ -87,9 +127,9 @@ func apiKeyResultToModel(r *repository.APIKeyResult) *models.APIKey {
 	return key
 }
 
-// Get retrieves an API key by ID
-func (s *APIKeyService) Get(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
-	result, err := s.repo.GetByHash(ctx, id.String())
+// Get retrieves an organization's API key by ID
+func (s *APIKeyService) Get(ctx context.Context, id, orgID uuid.UUID) (*models.APIKey, error) {
+	result, err := s.repo.GetByID(ctx, id, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -143,23 +183,11 @@ func (s *APIKeyService) Revoke(ctx context.Context, id, orgID uuid.UUID) error {
 
 // Rotate creates a new API key and revokes the old one
 func (s *APIKeyService) Rotate(ctx context.Context, id, orgID uuid.UUID) (*models.CreateAPIKeyResponse, error) {
-	// Get the existing key via listing (since we don't have GetByID)
-	results, _, err := s.repo.ListByOrg(ctx, orgID, 100, 0)
+	existingResult, err := s.repo.GetByID(ctx, id, orgID)
 	if err != nil {
 		return nil, err
 	}
 
-	var existingResult *repository.APIKeyResult
-	for _, r := range results {
-		if r.ID == id {
-			existingResult = r
-			break
-		}
-	}
-	if existingResult == nil {
-		return nil, fmt.Errorf("API key not found")
-	}
-
 	existingKey := apiKeyResultToModel(existingResult)
 	existingKey.DomainID = orgID
 
@@ -172,6 +200,9 @@ func (s *APIKeyService) Rotate(ctx context.Context, id, orgID uuid.UUID) (*model
 		DailyLimit: existingKey.DailyLimit,
 		ExpiresAt:  existingKey.ExpiresAt,
 		Metadata:   existingKey.Metadata,
+
+		MaxMessageSize: existingKey.MaxMessageSize,
+		MaxRecipients:  existingKey.MaxRecipients,
 	}
 
 	newKey, err := s.Create(ctx, req, existingKey.CreatedBy)
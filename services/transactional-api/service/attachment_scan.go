@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"transactional-api/models"
+	"transactional-api/repository"
+	"transactional-api/scanner"
+)
+
+// ErrInvalidAttachment is returned for attachment content that isn't valid base64
+var ErrInvalidAttachment = errors.New("attachment content is not valid base64")
+
+// InfectedAttachmentError reports an attachment the scanner found malware in
+type InfectedAttachmentError struct {
+	Filename  string
+	Signature string
+}
+
+func (e *InfectedAttachmentError) Error() string {
+	return fmt.Sprintf("attachment %q was rejected: malware detected (%s)", e.Filename, e.Signature)
+}
+
+// AttachmentScanner checks attachments for malware before a message is sent.
+// A nil *AttachmentScanner means scanning is disabled and accepts everything.
+type AttachmentScanner struct {
+	scanner scanner.Scanner
+	repo    *repository.DetectionRepository
+	logger  *zap.Logger
+}
+
+func NewAttachmentScanner(s scanner.Scanner, repo *repository.DetectionRepository, logger *zap.Logger) *AttachmentScanner {
+	return &AttachmentScanner{scanner: s, repo: repo, logger: logger}
+}
+
+// Check scans each attachment, decoding its base64 content as it goes to the
+// scanner. The first infected attachment is recorded and returned as an
+// *InfectedAttachmentError. When the scanner fails closed the error wraps
+// scanner.ErrUnavailable.
+func (s *AttachmentScanner) Check(ctx context.Context, orgID uuid.UUID, apiKeyID *uuid.UUID, attachments []models.Attachment) error {
+	if s == nil {
+		return nil
+	}
+
+	for _, a := range attachments {
+		content := &decodeErrReader{r: base64.NewDecoder(base64.StdEncoding, strings.NewReader(a.Content))}
+		result, err := s.scanner.Scan(ctx, content)
+		if content.err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAttachment, a.Filename)
+		}
+		if err != nil {
+			return err
+		}
+
+		if result.Unscanned {
+			s.logger.Warn("Attachment sent unscanned",
+				zap.String("org_id", orgID.String()),
+				zap.String("filename", a.Filename),
+				zap.Error(result.Err))
+			continue
+		}
+		if !result.Infected {
+			continue
+		}
+
+		detection := &models.AttachmentDetection{
+			OrganizationID: orgID,
+			APIKeyID:       apiKeyID,
+			Filename:       a.Filename,
+			ContentType:    a.ContentType,
+			Scanner:        s.scanner.Name(),
+			Signature:      result.Signature,
+		}
+		if err := s.repo.Record(ctx, detection); err != nil {
+			s.logger.Error("Failed to record attachment detection", zap.Error(err))
+		}
+		s.logger.Warn("Malware detected in attachment",
+			zap.String("org_id", orgID.String()),
+			zap.String("filename", a.Filename),
+			zap.String("signature", result.Signature))
+
+		return &InfectedAttachmentError{Filename: a.Filename, Signature: result.Signature}
+	}
+
+	return nil
+}
+
+// decodeErrReader remembers a read error from the base64 decoder, so bad
+// input isn't mistaken for a scanner failure
+type decodeErrReader struct {
+	r   io.Reader
+	err error
+}
+
+func (d *decodeErrReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		d.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"transactional-api/config"
+	"transactional-api/models"
+	"transactional-api/repository"
+)
+
+// BounceClassification is the outcome of classifying a bounce
+type BounceClassification struct {
+	Type       models.BounceClassification
+	Category   models.BounceCategory
+	StatusCode string // RFC 3463 enhanced status code, if one was reported
+}
+
+var enhancedStatusPattern = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// bounceKeywords match diagnostic text to a category, in order. Spam blocks
+// come first because they share 5.7.x codes with other policy rejections.
+var bounceKeywords = []struct {
+	category models.BounceCategory
+	keywords []string
+}{
+	{models.BounceCategorySpamBlock, []string{"spam", "blacklist", "blocklist", "block list", "spamhaus", "barracuda", "dnsbl", "reputation"}},
+	{models.BounceCategoryMailboxFull, []string{"mailbox full", "mailbox is full", "over quota", "quota exceeded", "insufficient storage", "exceeded storage"}},
+	{models.BounceCategoryInvalidRecipient, []string{"user unknown", "unknown user", "no such user", "does not exist", "mailbox not found", "mailbox unavailable", "invalid recipient", "recipient rejected", "address rejected", "no mailbox", "account disabled", "account has been disabled"}},
+	{models.BounceCategoryInvalidDomain, []string{"host not found", "domain not found", "no mx", "nxdomain", "unrouteable", "name or service not known"}},
+	{models.BounceCategoryMessageTooLarge, []string{"too large", "size limit", "message size exceeds"}},
+	{models.BounceCategoryTransient, []string{"timed out", "timeout", "connection refused", "connection reset", "network unreachable", "no route", "greylist", "try again later", "temporarily"}},
+	{models.BounceCategoryPolicy, []string{"policy", "prohibited", "not authorized", "relay", "dmarc", "spf"}},
+}
+
+// ClassifyBounce classifies a bounce from its SMTP reply code, enhanced
+// status code and diagnostic text. Any of them may be missing; the status
+// code is then looked for in the diagnostic text.
+func ClassifyBounce(smtpCode int, statusCode, diagnostic string) BounceClassification {
+	if !enhancedStatusPattern.MatchString(statusCode) {
+		statusCode = enhancedStatusPattern.FindString(diagnostic)
+	}
+
+	// A bounce is permanent unless a code says otherwise
+	permanent := true
+	if smtpCode >= 400 && smtpCode < 500 {
+		permanent = false
+	}
+
+	var subject, detail int
+	if m := enhancedStatusPattern.FindStringSubmatch(statusCode); m != nil {
+		permanent = m[1] == "5"
+		subject, _ = strconv.Atoi(m[2])
+		detail, _ = strconv.Atoi(m[3])
+	}
+
+	lower := strings.ToLower(diagnostic)
+	category := models.BounceCategoryUnknown
+	if containsAnyKeyword(lower, bounceKeywords[0].keywords) {
+		category = models.BounceCategorySpamBlock
+	} else if c, ok := categorizeStatus(subject, detail, permanent); ok {
+		category = c
+	} else {
+		for _, rule := range bounceKeywords[1:] {
+			if containsAnyKeyword(lower, rule.keywords) {
+				category = rule.category
+				break
+			}
+		}
+	}
+
+	return BounceClassification{
+		Type:       bounceType(category, permanent),
+		Category:   category,
+		StatusCode: statusCode,
+	}
+}
+
+// categorizeStatus maps an RFC 3463 status subject and detail to a category
+func categorizeStatus(subject, detail int, permanent bool) (models.BounceCategory, bool) {
+	switch {
+	case subject == 1 && detail == 2:
+		return models.BounceCategoryInvalidDomain, true
+	case subject == 1 && detail != 0:
+		return models.BounceCategoryInvalidRecipient, true
+	case subject == 2 && detail == 1:
+		return models.BounceCategoryInvalidRecipient, true
+	case subject == 2 && detail == 2:
+		return models.BounceCategoryMailboxFull, true
+	case subject == 2 && detail == 3, subject == 3 && detail == 4:
+		return models.BounceCategoryMessageTooLarge, true
+	case subject == 4 && detail == 4 && permanent:
+		return models.BounceCategoryInvalidDomain, true
+	case subject == 4:
+		return models.BounceCategoryTransient, true
+	case subject == 6, subject == 7:
+		return models.BounceCategoryPolicy, true
+	}
+	return "", false
+}
+
+// bounceType derives hard, soft or block from a category. Only permanent
+// address failures are hard; anything unrecognised is soft so a valid
+// address is never suppressed on a guess.
+func bounceType(category models.BounceCategory, permanent bool) models.BounceClassification {
+	switch category {
+	case models.BounceCategoryInvalidRecipient, models.BounceCategoryInvalidDomain:
+		if permanent {
+			return models.BounceClassificationHard
+		}
+	case models.BounceCategorySpamBlock, models.BounceCategoryPolicy:
+		return models.BounceClassificationBlock
+	}
+	return models.BounceClassificationSoft
+}
+
+func containsAnyKeyword(s string, keywords []string) bool {
+	for _, k := range keywords {
+		if strings.Contains(s, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// BounceService classifies incoming bounces and suppresses recipients that
+// should no longer be sent to
+type BounceService struct {
+	config          *config.BounceConfig
+	eventRepo       *repository.EventRepository
+	suppressionRepo *repository.SuppressionRepository
+	logger          *zap.Logger
+}
+
+func NewBounceService(
+	cfg *config.BounceConfig,
+	eventRepo *repository.EventRepository,
+	suppressionRepo *repository.SuppressionRepository,
+	logger *zap.Logger,
+) *BounceService {
+	return &BounceService{
+		config:          cfg,
+		eventRepo:       eventRepo,
+		suppressionRepo: suppressionRepo,
+		logger:          logger,
+	}
+}
+
+// Process classifies a bounce event in place and suppresses its recipient
+// on a hard bounce, or once their mailbox has been full too often. Other
+// events are left untouched. It must run before the event is stored.
+func (s *BounceService) Process(ctx context.Context, event *models.EmailEvent) {
+	if event.EventType != models.EventTypeBounced {
+		return
+	}
+
+	c := ClassifyBounce(event.SMTPCode, event.BounceCode, event.SMTPResponse+" "+event.BounceReason)
+	event.BounceType = string(c.Type)
+	event.BounceCategory = c.Category
+	if event.BounceCode == "" {
+		event.BounceCode = c.StatusCode
+	}
+
+	reason := s.suppressionReason(ctx, event)
+	if reason == "" {
+		return
+	}
+
+	if err := s.suppressionRepo.Add(ctx, event.OrganizationID, event.Recipient, models.SuppressionBounce, reason, models.SuppressionSourceBounce); err != nil {
+		s.logger.Error("Failed to suppress bounced recipient",
+			zap.String("recipient", event.Recipient),
+			zap.Error(err))
+		return
+	}
+
+	s.logger.Info("Bounced recipient suppressed",
+		zap.String("recipient", event.Recipient),
+		zap.String("bounce_category", string(event.BounceCategory)))
+}
+
+// suppressionReason returns why a bounce's recipient should be suppressed,
+// or "" if it should not
+func (s *BounceService) suppressionReason(ctx context.Context, event *models.EmailEvent) string {
+	diagnostic := strings.TrimSpace(event.SMTPResponse)
+	if diagnostic == "" {
+		diagnostic = event.BounceReason
+	}
+
+	if event.BounceType == string(models.BounceClassificationHard) {
+		return fmt.Sprintf("hard bounce (%s): %s", event.BounceCategory, diagnostic)
+	}
+
+	if event.BounceCategory != models.BounceCategoryMailboxFull || s.config.MailboxFullThreshold <= 0 {
+		return ""
+	}
+
+	window := time.Duration(s.config.MailboxFullWindow) * time.Second
+	previous, err := s.eventRepo.CountBounces(ctx, event.OrganizationID, event.Recipient, models.BounceCategoryMailboxFull, event.Timestamp.Add(-window))
+	if err != nil {
+		s.logger.Error("Failed to count mailbox full bounces",
+			zap.String("recipient", event.Recipient),
+			zap.Error(err))
+		return ""
+	}
+
+	// The current event is not stored yet
+	if previous+1 < s.config.MailboxFullThreshold {
+		return ""
+	}
+	return fmt.Sprintf("mailbox full %d times within %s: %s", previous+1, window, diagnostic)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+
+	"transactional-api/models"
+)
+
+func TestClassifyBounce(t *testing.T) {
+	tests := []struct {
+		name         string
+		smtpCode     int
+		statusCode   string
+		diagnostic   string
+		wantType     models.BounceClassification
+		wantCategory models.BounceCategory
+		wantStatus   string
+	}{
+		{"unknown user", 550, "5.1.1", "5.1.1 <bob@example.com>: Recipient address rejected: User unknown",
+			models.BounceClassificationHard, models.BounceCategoryInvalidRecipient, "5.1.1"},
+		{"status from text", 550, "", "550 5.1.1 The email account that you tried to reach does not exist",
+			models.BounceClassificationHard, models.BounceCategoryInvalidRecipient, "5.1.1"},
+		{"bad domain", 550, "5.1.2", "Host or domain name not found",
+			models.BounceClassificationHard, models.BounceCategoryInvalidDomain, "5.1.2"},
+		{"mailbox full", 552, "5.2.2", "Mailbox full",
+			models.BounceClassificationSoft, models.BounceCategoryMailboxFull, "5.2.2"},
+		{"over quota without status", 452, "", "User is over quota",
+			models.BounceClassificationSoft, models.BounceCategoryMailboxFull, ""},
+		{"too large", 552, "5.3.4", "Message size exceeds fixed limit",
+			models.BounceClassificationSoft, models.BounceCategoryMessageTooLarge, "5.3.4"},
+		{"spam block", 550, "5.7.1", "Message rejected due to Spamhaus listing",
+			models.BounceClassificationBlock, models.BounceCategorySpamBlock, "5.7.1"},
+		{"policy", 550, "5.7.1", "Relaying denied",
+			models.BounceClassificationBlock, models.BounceCategoryPolicy, "5.7.1"},
+		{"network", 421, "4.4.2", "Connection dropped",
+			models.BounceClassificationSoft, models.BounceCategoryTransient, "4.4.2"},
+		{"temporary unknown user", 450, "4.1.1", "User unknown, try again",
+			models.BounceClassificationSoft, models.BounceCategoryInvalidRecipient, "4.1.1"},
+		{"unrecognised", 554, "", "Transaction failed",
+			models.BounceClassificationSoft, models.BounceCategoryUnknown, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyBounce(tt.smtpCode, tt.statusCode, tt.diagnostic)
+			if got.Type != tt.wantType || got.Category != tt.wantCategory || got.StatusCode != tt.wantStatus {
+				t.Errorf("ClassifyBounce() = %+v, want {%s %s %s}", got, tt.wantType, tt.wantCategory, tt.wantStatus)
+			}
+		})
+	}
+}
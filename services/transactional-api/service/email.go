@@ -7,6 +7,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"html"
+	"mime"
+	"mime/quotedprintable"
 	"net"
 	"net/smtp"
 	"regexp"
@@ -26,9 +28,12 @@ import (
 type EmailService struct {
 	cfg             *config.Config
 	emailRepo       *repository.EmailRepository
+	eventRepo       *repository.EventRepository
 	templateRepo    *repository.TemplateRepository
 	suppressionRepo *repository.SuppressionRepository
 	redis           *redis.Client
+	unsubscribe     *UnsubscribeTokens
+	sendingDomains  *SendingDomainSelector
 	logger          *zap.Logger
 	smtpPool        chan *smtpConn
 }
@@ -41,17 +46,22 @@ type smtpConn struct {
 func NewEmailService(
 	cfg *config.Config,
 	emailRepo *repository.EmailRepository,
+	eventRepo *repository.EventRepository,
 	templateRepo *repository.TemplateRepository,
 	suppressionRepo *repository.SuppressionRepository,
+	sendingDomains *SendingDomainSelector,
 	redis *redis.Client,
 	logger *zap.Logger,
 ) *EmailService {
 	s := &EmailService{
 		cfg:             cfg,
 		emailRepo:       emailRepo,
+		eventRepo:       eventRepo,
 		templateRepo:    templateRepo,
 		suppressionRepo: suppressionRepo,
 		redis:           redis,
+		unsubscribe:     NewUnsubscribeTokens(&cfg.Unsubscribe),
+		sendingDomains:  sendingDomains,
 		logger:          logger,
 		smtpPool:        make(chan *smtpConn, cfg.SMTP.PoolSize),
 	}
@@ -64,7 +74,9 @@ func NewEmailService(
 	return s
 }
 
-func (s *EmailService) Send(ctx context.Context, orgID uuid.UUID, req *models.SendEmailRequest) (*models.SendEmailResponse, error) {
+// Send queues a message for the API key apiKeyID of organization orgID. A
+// template must be one the key can use: its own, a shared or a system one.
+func (s *EmailService) Send(ctx context.Context, orgID, apiKeyID uuid.UUID, req *models.SendEmailRequest) (*models.SendEmailResponse, error) {
 	// Generate message ID
 	messageID := uuid.New()
 
@@ -79,21 +91,35 @@ func (s *EmailService) Send(ctx context.Context, orgID uuid.UUID, req *models.Se
 			zap.Strings("emails", droppedTo))
 	}
 
-	// Resolve template if provided
+	// Resolve template if provided. The message is rendered now, against the
+	// pinned or current latest version, and the result stored, so scheduled
+	// messages aren't affected by template edits made before they go out.
 	var subject, textBody, htmlBody string
+	var templateVersion *int
 	if req.TemplateID != nil {
-		template, err := s.templateRepo.GetByID(ctx, *req.TemplateID, orgID)
+		access := models.TemplateAccess{OrganizationID: orgID, APIKeyID: apiKeyID}
+		template, err := s.resolveTemplate(ctx, access, *req.TemplateID, req.TemplateVersion)
 		if err != nil {
-			return nil, fmt.Errorf("template not found: %w", err)
+			return nil, err
+		}
+		// Give HTML-only templates a text alternative. It is converted before
+		// rendering, which it keeps the variables for, so values go into the
+		// text unescaped.
+		if template.TextBody == "" && template.HTMLBody != "" && autoText(req.AutoText) {
+			template.TextBody = HTMLToText(template.HTMLBody)
 		}
 		subject, textBody, htmlBody, err = s.templateRepo.RenderTemplate(template, req.TemplateData)
 		if err != nil {
 			return nil, fmt.Errorf("render template: %w", err)
 		}
+		templateVersion = &template.ActiveVersion
 	} else {
 		subject = req.Subject
 		textBody = req.TextBody
 		htmlBody = req.HTMLBody
+		if textBody == "" && htmlBody != "" && autoText(req.AutoText) {
+			textBody = HTMLToText(htmlBody)
+		}
 	}
 
 	// Apply tracking if enabled
@@ -120,23 +146,25 @@ func (s *EmailService) Send(ctx context.Context, orgID uuid.UUID, req *models.Se
 	}
 
 	email := &repository.TransactionalEmail{
-		ID:             messageID,
-		OrganizationID: orgID,
-		MessageID:      messageID.String(),
-		FromEmail:      req.From.Email,
-		FromName:       req.From.Name,
-		ToEmails:       toEmails,
-		Subject:        subject,
-		TextBody:       textBody,
-		HTMLBody:       htmlBody,
-		Headers:        req.Headers,
-		Tags:           req.Tags,
-		Metadata:       req.Metadata,
-		TemplateID:     req.TemplateID,
-		IPPool:         req.IPPool,
-		TrackOpens:     trackOpens,
-		TrackClicks:    trackClicks,
-		CreatedAt:      time.Now(),
+		ID:              messageID,
+		OrganizationID:  orgID,
+		MessageID:       messageID.String(),
+		FromEmail:       req.From.Email,
+		FromName:        req.From.Name,
+		ToEmails:        toEmails,
+		Subject:         subject,
+		TextBody:        textBody,
+		HTMLBody:        htmlBody,
+		Headers:         withQueuePriority(readReceiptHeaders(req.Headers, req.RequestReadReceipt && !req.Bulk, req.From.Email), queuePriority(req)),
+		Tags:            req.Tags,
+		Metadata:        req.Metadata,
+		TemplateID:      req.TemplateID,
+		TemplateVersion: templateVersion,
+		IPPool:          req.IPPool,
+		TrackOpens:      trackOpens,
+		TrackClicks:     trackClicks,
+		Bulk:            req.Bulk,
+		CreatedAt:       time.Now(),
 	}
 
 	// Handle CC/BCC
@@ -161,10 +189,11 @@ func (s *EmailService) Send(ctx context.Context, orgID uuid.UUID, req *models.Se
 			return nil, fmt.Errorf("save scheduled email: %w", err)
 		}
 		return &models.SendEmailResponse{
-			MessageID:   messageID,
-			Status:      "scheduled",
-			QueuedAt:    time.Now(),
-			ScheduledAt: req.SendAt,
+			MessageID:       messageID,
+			Status:          "scheduled",
+			QueuedAt:        time.Now(),
+			ScheduledAt:     req.SendAt,
+			TemplateVersion: templateVersion,
 		}, nil
 	}
 
@@ -173,18 +202,43 @@ func (s *EmailService) Send(ctx context.Context, orgID uuid.UUID, req *models.Se
 	if err := s.emailRepo.Create(ctx, email); err != nil {
 		return nil, fmt.Errorf("save email: %w", err)
 	}
+	s.recordEvents(ctx, email, models.EventTypeQueued, emailRecipients(email), "")
 
 	// Send via SMTP (async)
 	go s.sendViaSMTP(context.Background(), email, req)
 
 	return &models.SendEmailResponse{
-		MessageID: messageID,
-		Status:    "queued",
-		QueuedAt:  time.Now(),
+		MessageID:       messageID,
+		Status:          "queued",
+		QueuedAt:        time.Now(),
+		TemplateVersion: templateVersion,
 	}, nil
 }
 
-func (s *EmailService) SendBatch(ctx context.Context, orgID uuid.UUID, req *models.BatchSendRequest) (*models.BatchSendEmailResponse, error) {
+// resolveTemplate loads the template version a message renders: the pinned
+// version if there is one, otherwise the latest. The returned template's
+// ActiveVersion is the version used.
+func (s *EmailService) resolveTemplate(ctx context.Context, access models.TemplateAccess, templateID uuid.UUID, version *int) (*models.Template, error) {
+	template, err := s.templateRepo.GetByID(ctx, templateID, access)
+	if err != nil {
+		return nil, fmt.Errorf("template not found: %w", err)
+	}
+	if version == nil || *version == template.ActiveVersion {
+		return template, nil
+	}
+
+	pinned, err := s.templateRepo.GetVersion(ctx, templateID, access, *version)
+	if err != nil {
+		return nil, fmt.Errorf("template version %d: %w", *version, err)
+	}
+	template.Subject = pinned.Subject
+	template.TextBody = pinned.TextBody
+	template.HTMLBody = pinned.HTMLBody
+	template.ActiveVersion = pinned.Version
+	return template, nil
+}
+
+func (s *EmailService) SendBatch(ctx context.Context, orgID, apiKeyID uuid.UUID, req *models.BatchSendRequest) (*models.BatchSendEmailResponse, error) {
 	response := &models.BatchSendEmailResponse{
 		Messages: make([]models.SendEmailResponse, 0, len(req.Messages)),
 		Errors:   make([]models.BatchError, 0),
@@ -210,7 +264,7 @@ func (s *EmailService) SendBatch(ctx context.Context, orgID uuid.UUID, req *mode
 				HTMLBody: m.HTML,
 			}
 
-			result, err := s.Send(ctx, orgID, sendReq)
+			result, err := s.Send(ctx, orgID, apiKeyID, sendReq)
 			mu.Lock()
 			defer mu.Unlock()
 
@@ -252,16 +306,111 @@ func (s *EmailService) sendViaSMTP(ctx context.Context, email *repository.Transa
 	conn := <-s.smtpPool
 	defer func() { s.smtpPool <- conn }()
 
+	if email.Bulk {
+		s.sendBulkViaSMTP(ctx, &conn, email, req)
+		return
+	}
+
 	// Build MIME message
-	msg := s.buildMIMEMessage(email, req)
+	msg := s.buildMIMEMessage(email, req, "")
 
 	// Collect all recipients
-	var allRecipients []string
-	allRecipients = append(allRecipients, email.ToEmails...)
-	allRecipients = append(allRecipients, email.CCEmails...)
-	allRecipients = append(allRecipients, email.BCCEmails...)
+	allRecipients := emailRecipients(email)
+
+	if err := s.transmit(&conn, s.envelopeSender(ctx, email, allRecipients), allRecipients, msg); err != nil {
+		s.emailRepo.UpdateStatus(ctx, email.ID, "failed", nil)
+		s.recordEvents(ctx, email, models.EventTypeDropped, allRecipients, err.Error())
+		s.logger.Error("Failed to send email after retries",
+			zap.String("message_id", email.MessageID),
+			zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	s.emailRepo.UpdateStatus(ctx, email.ID, "sent", &now)
+	s.recordEvents(ctx, email, models.EventTypeSent, allRecipients, "")
+	s.logger.Info("Email sent successfully",
+		zap.String("message_id", email.MessageID),
+		zap.Int("recipients", len(allRecipients)))
+}
+
+// sendBulkViaSMTP sends each recipient of a bulk email a separate copy
+// carrying their own unsubscribe link
+func (s *EmailService) sendBulkViaSMTP(ctx context.Context, conn **smtpConn, email *repository.TransactionalEmail, req *models.SendEmailRequest) {
+	sent := 0
+	for _, rcpt := range email.ToEmails {
+		msg := s.buildMIMEMessage(email, req, rcpt)
+		if err := s.transmit(conn, s.envelopeSender(ctx, email, []string{rcpt}), []string{rcpt}, msg); err != nil {
+			s.recordEvents(ctx, email, models.EventTypeDropped, []string{rcpt}, err.Error())
+			s.logger.Error("Failed to send bulk email to recipient after retries",
+				zap.String("message_id", email.MessageID),
+				zap.Error(err))
+			continue
+		}
+		s.recordEvents(ctx, email, models.EventTypeSent, []string{rcpt}, "")
+		sent++
+	}
+
+	if sent == 0 {
+		s.emailRepo.UpdateStatus(ctx, email.ID, "failed", nil)
+		return
+	}
+	now := time.Now()
+	s.emailRepo.UpdateStatus(ctx, email.ID, "sent", &now)
+	s.logger.Info("Bulk email sent",
+		zap.String("message_id", email.MessageID),
+		zap.Int("recipients", sent),
+		zap.Int("failed", len(email.ToEmails)-sent))
+}
+
+// envelopeSender returns the address to send email to recipients from: on
+// a domain from the organization's sending pool when it has one, so the
+// message is signed with that domain's DKIM key. Sticky pools go by the
+// first recipient.
+func (s *EmailService) envelopeSender(ctx context.Context, email *repository.TransactionalEmail, recipients []string) string {
+	if s.sendingDomains == nil || len(recipients) == 0 {
+		return email.FromEmail
+	}
+	return s.sendingDomains.EnvelopeSender(ctx, email.OrganizationID, email.FromEmail, recipients[0])
+}
+
+// SendNotice emails a plain text notice about the account, such as a
+// webhook being disabled, to one of its users. It bypasses the organization's
+// suppressions, quota and tracking, as it is sent by us rather than them.
+func (s *EmailService) SendNotice(ctx context.Context, to, subject, body string) error {
+	if s.cfg.SMTP.NoticeFrom == "" {
+		return fmt.Errorf("no notice sender configured")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("From: %s\r\n", s.cfg.SMTP.NoticeFrom))
+	buf.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject)))
+	buf.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	buf.WriteString(fmt.Sprintf("Message-ID: <%s@%s>\r\n", uuid.New().String(), s.cfg.SMTP.FromDomain))
+	buf.WriteString("Auto-Submitted: auto-generated\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var conn *smtpConn
+	select {
+	case conn = <-s.smtpPool:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { s.smtpPool <- conn }()
+
+	return s.transmit(&conn, s.cfg.SMTP.NoticeFrom, []string{to}, buf.Bytes())
+}
+
+// transmit delivers a message to the relay with retries, replacing the
+// pooled connection when it has to reconnect
+func (s *EmailService) transmit(connp **smtpConn, from string, recipients []string, msg []byte) error {
+	conn := *connp
+	defer func() { *connp = conn }()
 
-	// Attempt send with retries
 	var lastErr error
 	for attempt := 0; attempt < s.cfg.SMTP.RetryCount; attempt++ {
 		// Get or create connection
@@ -276,14 +425,14 @@ func (s *EmailService) sendViaSMTP(ctx context.Context, email *repository.Transa
 		}
 
 		// Send email
-		if err := conn.client.Mail(email.FromEmail); err != nil {
+		if err := conn.client.Mail(from); err != nil {
 			conn.client.Close()
 			conn = nil
 			lastErr = err
 			continue
 		}
 
-		for _, rcpt := range allRecipients {
+		for _, rcpt := range recipients {
 			if err := conn.client.Rcpt(rcpt); err != nil {
 				conn.client.Reset()
 				lastErr = err
@@ -310,20 +459,94 @@ func (s *EmailService) sendViaSMTP(ctx context.Context, email *repository.Transa
 			continue
 		}
 
-		// Success!
-		now := time.Now()
-		s.emailRepo.UpdateStatus(ctx, email.ID, "sent", &now)
-		s.logger.Info("Email sent successfully",
-			zap.String("message_id", email.MessageID),
-			zap.Int("recipients", len(allRecipients)))
-		return
+		return nil
 	}
 
-	// All retries failed
-	s.emailRepo.UpdateStatus(ctx, email.ID, "failed", nil)
-	s.logger.Error("Failed to send email after retries",
-		zap.String("message_id", email.MessageID),
-		zap.Error(lastErr))
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no delivery attempts made")
+	}
+	return lastErr
+}
+
+// queuePriorityHeader tells the SMTP server which outbound queue priority
+// to give a message
+const queuePriorityHeader = "X-Queue-Priority"
+
+// readReceiptHeader requests a read receipt from the recipient's mail system
+const readReceiptHeader = "Disposition-Notification-To"
+
+// queuePriority returns the outbound queue priority of a send request
+func queuePriority(req *models.SendEmailRequest) string {
+	switch {
+	case req.Priority != "":
+		return req.Priority
+	case req.Bulk:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// withQueuePriority returns a copy of the custom headers with the queue
+// priority set, replacing any priority header the caller added
+func withQueuePriority(headers map[string]string, priority string) map[string]string {
+	out := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		if !strings.EqualFold(k, queuePriorityHeader) {
+			out[k] = v
+		}
+	}
+	out[queuePriorityHeader] = priority
+	return out
+}
+
+// readReceiptHeaders returns the custom headers with a read receipt
+// requested at address if request is set, replacing any request the caller
+// added. The address is the envelope sender, so receiving servers may send
+// the receipt without asking the recipient (RFC 8098 Section 2.1).
+func readReceiptHeaders(headers map[string]string, request bool, address string) map[string]string {
+	if !request {
+		return headers
+	}
+	out := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		if !strings.EqualFold(k, readReceiptHeader) {
+			out[k] = v
+		}
+	}
+	out[readReceiptHeader] = "<" + address + ">"
+	return out
+}
+
+// emailRecipients returns every envelope recipient of an email
+func emailRecipients(email *repository.TransactionalEmail) []string {
+	var recipients []string
+	recipients = append(recipients, email.ToEmails...)
+	recipients = append(recipients, email.CCEmails...)
+	recipients = append(recipients, email.BCCEmails...)
+	return recipients
+}
+
+// recordEvents stores one timeline event per recipient so the message status can be queried later
+func (s *EmailService) recordEvents(ctx context.Context, email *repository.TransactionalEmail, eventType models.EventType, recipients []string, smtpResponse string) {
+	now := time.Now()
+	for _, rcpt := range recipients {
+		event := &models.EmailEvent{
+			ID:             uuid.New(),
+			OrganizationID: email.OrganizationID,
+			MessageID:      email.ID,
+			EventType:      eventType,
+			Recipient:      rcpt,
+			Timestamp:      now,
+			SMTPResponse:   smtpResponse,
+		}
+		if err := s.eventRepo.Create(ctx, event); err != nil {
+			s.logger.Warn("Failed to record email event",
+				zap.String("message_id", email.MessageID),
+				zap.String("event", string(eventType)),
+				zap.Error(err))
+		}
+	}
 }
 
 func (s *EmailService) createSMTPConnection() (*smtp.Client, error) {
@@ -364,13 +587,20 @@ func (s *EmailService) createSMTPConnection() (*smtp.Client, error) {
 	return client, nil
 }
 
-func (s *EmailService) buildMIMEMessage(email *repository.TransactionalEmail, req *models.SendEmailRequest) []byte {
+// buildMIMEMessage builds the message to send. For a bulk email it is the copy
+// for one recipient, with that recipient's unsubscribe link.
+func (s *EmailService) buildMIMEMessage(email *repository.TransactionalEmail, req *models.SendEmailRequest, bulkRecipient string) []byte {
 	var buf bytes.Buffer
 	boundary := fmt.Sprintf("----=_Part_%s", uuid.New().String()[:8])
+	textBody, htmlBody := email.TextBody, email.HTMLBody
 
 	// Headers
 	buf.WriteString(fmt.Sprintf("From: %s <%s>\r\n", email.FromName, email.FromEmail))
-	buf.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(email.ToEmails, ", ")))
+	if bulkRecipient != "" {
+		buf.WriteString(fmt.Sprintf("To: %s\r\n", bulkRecipient))
+	} else {
+		buf.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(email.ToEmails, ", ")))
+	}
 	if len(email.CCEmails) > 0 {
 		buf.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(email.CCEmails, ", ")))
 	}
@@ -378,8 +608,19 @@ func (s *EmailService) buildMIMEMessage(email *repository.TransactionalEmail, re
 	buf.WriteString(fmt.Sprintf("Message-ID: <%s@transactional.mail>\r\n", email.MessageID))
 	buf.WriteString("MIME-Version: 1.0\r\n")
 
+	// One-click unsubscribe (RFC 8058)
+	if bulkRecipient != "" {
+		unsubscribeURL := s.unsubscribe.URL(email.OrganizationID, email.ID, bulkRecipient)
+		buf.WriteString(fmt.Sprintf("List-Unsubscribe: <%s>\r\n", unsubscribeURL))
+		buf.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+		textBody, htmlBody = injectUnsubscribeLink(textBody, htmlBody, unsubscribeURL)
+	}
+
 	// Custom headers
 	for k, v := range email.Headers {
+		if bulkRecipient != "" && strings.HasPrefix(strings.ToLower(k), "list-unsubscribe") {
+			continue
+		}
 		buf.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
 	}
 
@@ -403,20 +644,20 @@ func (s *EmailService) buildMIMEMessage(email *repository.TransactionalEmail, re
 	}
 
 	// Text part
-	if email.TextBody != "" {
+	if textBody != "" {
 		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
 		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
 		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
-		buf.WriteString(email.TextBody)
+		buf.WriteString(quotedPrintable(textBody))
 		buf.WriteString("\r\n")
 	}
 
 	// HTML part
-	if email.HTMLBody != "" {
+	if htmlBody != "" {
 		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
 		buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
 		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
-		buf.WriteString(email.HTMLBody)
+		buf.WriteString(quotedPrintable(htmlBody))
 		buf.WriteString("\r\n")
 	}
 
@@ -450,6 +691,16 @@ func (s *EmailService) buildMIMEMessage(email *repository.TransactionalEmail, re
 	return buf.Bytes()
 }
 
+// quotedPrintable encodes a body part declared quoted-printable, which also
+// keeps its lines within SMTP's length limit
+func quotedPrintable(body string) string {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	w.Write([]byte(body))
+	w.Close()
+	return buf.String()
+}
+
 func (s *EmailService) injectOpenTracking(htmlBody string, messageID uuid.UUID) string {
 	// Inject tracking pixel before </body>
 	pixelURL := fmt.Sprintf("%s%s/%s.gif", s.cfg.Tracking.TrackingHost, s.cfg.Tracking.PixelPath, messageID)
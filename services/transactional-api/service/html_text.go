@@ -0,0 +1,344 @@
+package service
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// mergeVariablePattern matches substitution variables: {{name}}, {{{name}}},
+// Go template actions such as {{.Name}} or {{if .X}}, and {name}
+var mergeVariablePattern = regexp.MustCompile(`\{\{\{?.*?\}\}\}?|\{\w+\}`)
+
+// textSkippedElements have no readable content
+var textSkippedElements = map[string]bool{
+	"head": true, "title": true, "script": true, "style": true, "noscript": true, "template": true,
+}
+
+// textParagraphElements are set off by a blank line, textLineElements by a
+// line break
+var (
+	textParagraphElements = map[string]bool{
+		"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"ul": true, "ol": true, "table": true, "blockquote": true, "pre": true, "hr": true,
+	}
+	textLineElements = map[string]bool{
+		"div": true, "li": true, "tr": true, "section": true, "article": true, "header": true,
+		"footer": true, "address": true, "dl": true, "dt": true, "dd": true, "center": true,
+	}
+)
+
+// autoText reports whether a message without a text part gets one generated
+// from its HTML; it does unless the caller opted out
+func autoText(opt *bool) bool {
+	return opt == nil || *opt
+}
+
+// HTMLToText turns an HTML body into a readable plain text alternative:
+// whitespace is collapsed, paragraphs and headings are set off by blank
+// lines, headings are uppercased, list items are bulleted or numbered, and
+// links are followed by their URL. Merge variables in the text and in link
+// URLs are kept as they are, so they can still be substituted afterwards.
+func HTMLToText(body string) string {
+	w := &textWriter{}
+	z := html.NewTokenizer(strings.NewReader(body))
+
+	skip := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		token := z.Token()
+		name := token.Data
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if textSkippedElements[name] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			w.open(token, tt == html.SelfClosingTagToken)
+
+		case html.EndTagToken:
+			if textSkippedElements[name] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			w.close(name)
+
+		case html.TextToken:
+			if skip == 0 {
+				w.text(token.Data)
+			}
+		}
+	}
+
+	return w.String()
+}
+
+// textList is an open list, numbered for <ol>
+type textList struct {
+	ordered bool
+	n       int
+}
+
+// textLink is an open link: its URL and where its text starts
+type textLink struct {
+	href  string
+	start int
+}
+
+// textWriter lays out text, deferring whitespace until the next word so
+// that runs of spaces and blank lines collapse
+type textWriter struct {
+	buf      strings.Builder
+	space    bool   // A space is due before the next word
+	newlines int    // Line breaks due before the next word, at most 2
+	prefix   string // Written at the start of the next word's line
+	pre      int    // Depth of <pre>, whose whitespace is kept
+	heading  int    // Depth of headings, whose text is uppercased
+	lists    []*textList
+	links    []*textLink
+}
+
+func (w *textWriter) String() string {
+	lines := strings.Split(w.buf.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// block ends the current line, with a blank line after it if n is 2
+func (w *textWriter) block(n int) {
+	if n > w.newlines {
+		w.newlines = n
+	}
+	w.space = false
+}
+
+// flush writes the whitespace due before the next word
+func (w *textWriter) flush() {
+	if w.buf.Len() > 0 {
+		if w.newlines > 0 {
+			w.buf.WriteString(strings.Repeat("\n", w.newlines))
+		} else if w.space {
+			w.buf.WriteByte(' ')
+		}
+	}
+	if w.newlines > 0 || w.buf.Len() == 0 {
+		w.buf.WriteString(w.prefix)
+		w.prefix = ""
+	}
+	w.newlines = 0
+	w.space = false
+}
+
+func (w *textWriter) text(s string) {
+	if w.heading > 0 {
+		s = upperExceptVariables(s)
+	}
+
+	if w.pre > 0 {
+		w.flush()
+		w.buf.WriteString(s)
+		return
+	}
+
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" {
+			w.space = true
+		}
+		return
+	}
+
+	first, _ := utf8.DecodeRuneInString(s)
+	if unicode.IsSpace(first) {
+		w.space = true
+	}
+	for i, word := range words {
+		if i > 0 {
+			w.space = true
+		}
+		w.flush()
+		w.buf.WriteString(word)
+	}
+	last, _ := utf8.DecodeLastRuneInString(s)
+	w.space = unicode.IsSpace(last)
+}
+
+func (w *textWriter) open(token html.Token, selfClosing bool) {
+	name := token.Data
+	switch {
+	case (name == "ul" || name == "ol") && len(w.lists) > 0:
+		// A nested list continues its item's line
+		w.block(1)
+	case name == "br":
+		// A line break is kept even when one is already due
+		w.flush()
+		w.buf.WriteByte('\n')
+		w.space = false
+		return
+	case name == "hr":
+		w.block(2)
+		w.flush()
+		w.buf.WriteString("----------")
+		w.block(2)
+		return
+	case name == "img":
+		if alt := strings.TrimSpace(attr(token, "alt")); alt != "" {
+			w.text(alt)
+		}
+		return
+	case textParagraphElements[name]:
+		w.block(2)
+	case textLineElements[name]:
+		w.block(1)
+	case name == "td" || name == "th":
+		w.space = true
+	}
+	if selfClosing {
+		return
+	}
+
+	switch name {
+	case "pre":
+		w.pre++
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.heading++
+	case "ul", "ol":
+		w.lists = append(w.lists, &textList{ordered: name == "ol"})
+	case "li":
+		indent := ""
+		var list *textList
+		if len(w.lists) > 0 {
+			list = w.lists[len(w.lists)-1]
+			indent = strings.Repeat("  ", len(w.lists)-1)
+		}
+		if list != nil && list.ordered {
+			list.n++
+			w.prefix = indent + strconv.Itoa(list.n) + ". "
+		} else {
+			w.prefix = indent + "• "
+		}
+		// The item's line starts with its bullet, even if already at the
+		// start of a line
+		if w.newlines == 0 && w.buf.Len() > 0 {
+			w.newlines = 1
+		}
+	case "a":
+		w.links = append(w.links, &textLink{href: linkURL(attr(token, "href")), start: w.buf.Len()})
+	}
+}
+
+func (w *textWriter) close(name string) {
+	switch name {
+	case "pre":
+		if w.pre > 0 {
+			w.pre--
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if w.heading > 0 {
+			w.heading--
+		}
+	case "ul", "ol":
+		if len(w.lists) > 0 {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+	case "a":
+		if len(w.links) > 0 {
+			link := w.links[len(w.links)-1]
+			w.links = w.links[:len(w.links)-1]
+			w.endLink(link)
+		}
+	}
+
+	switch {
+	case (name == "ul" || name == "ol") && len(w.lists) > 0:
+		w.block(1)
+	case textParagraphElements[name]:
+		w.block(2)
+	case textLineElements[name]:
+		w.block(1)
+	}
+}
+
+// endLink follows a link's text with its URL, unless the text already shows
+// it. A link without text, such as an image without alt text, is shown as
+// its URL.
+func (w *textWriter) endLink(link *textLink) {
+	if link.href == "" {
+		return
+	}
+
+	text := ""
+	if link.start <= w.buf.Len() {
+		text = strings.TrimSpace(w.buf.String()[link.start:])
+	}
+	if text == "" {
+		w.flush()
+		w.buf.WriteString(link.href)
+		return
+	}
+	if strings.Contains(text, link.href) {
+		return
+	}
+	w.buf.WriteString(" (" + link.href + ")")
+}
+
+// linkURL is the URL a link is shown with: mailto: links show the address,
+// and in-page and script links aren't shown. Merge variables that an editor
+// percent-encoded are decoded so they are still substituted.
+func linkURL(href string) string {
+	href = strings.TrimSpace(href)
+	lower := strings.ToLower(href)
+	switch {
+	case href == "", strings.HasPrefix(href, "#"), strings.HasPrefix(lower, "javascript:"):
+		return ""
+	case strings.HasPrefix(lower, "mailto:"):
+		href = href[len("mailto:"):]
+	}
+	if strings.Contains(href, "%7B%7B") || strings.Contains(href, "%7b%7b") {
+		href = strings.NewReplacer("%7B", "{", "%7b", "{", "%7D", "}", "%7d", "}").Replace(href)
+	}
+	return href
+}
+
+// upperExceptVariables uppercases text, leaving merge variables as they are
+func upperExceptVariables(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mergeVariablePattern.FindAllStringIndex(s, -1) {
+		b.WriteString(strings.ToUpper(s[last:m[0]]))
+		b.WriteString(s[m[0]:m[1]])
+		last = m[1]
+	}
+	b.WriteString(strings.ToUpper(s[last:]))
+	return b.String()
+}
+
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
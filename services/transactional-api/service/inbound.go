@@ -0,0 +1,423 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"transactional-api/config"
+	"transactional-api/models"
+	"transactional-api/repository"
+)
+
+var (
+	ErrNoInboundRoute            = errors.New("no inbound route for any recipient")
+	ErrInvalidInboundMessage     = errors.New("invalid inbound message")
+	ErrInvalidAttachmentLink     = errors.New("invalid or expired attachment link")
+	ErrInboundAttachmentGone     = errors.New("attachment no longer available")
+	errInboundParseMisconfigured = errors.New("inbound parse needs a base URL and signing secret")
+)
+
+// inboundQueueKey is a Redis sorted set of pending posts, scored by the
+// Unix time they are due. Posts are queued there before the message is
+// acknowledged, so none are lost on restart.
+const inboundQueueKey = "inbound:queue"
+
+// inboundRetryDelays are the waits before each retry of a failed post
+var inboundRetryDelays = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour}
+
+var attachmentIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// InboundService parses messages received for inbound routes and posts them
+// to the routes' URLs, signed like event webhooks
+type InboundService struct {
+	config     *config.InboundConfig
+	routeRepo  *repository.InboundRouteRepository
+	redis      *redis.Client
+	logger     *zap.Logger
+	httpClient *http.Client
+	secret     []byte
+	baseURL    string
+	wake       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// inboundDelivery is one post of a message to one route
+type inboundDelivery struct {
+	RouteID uuid.UUID                   `json:"route_id"`
+	Attempt int                         `json:"attempt"`
+	Payload *models.InboundParsePayload `json:"payload"`
+}
+
+func NewInboundService(
+	cfg *config.InboundConfig,
+	routeRepo *repository.InboundRouteRepository,
+	redis *redis.Client,
+	logger *zap.Logger,
+) (*InboundService, error) {
+	if cfg.SigningSecret == "" || cfg.BaseURL == "" {
+		return nil, errInboundParseMisconfigured
+	}
+	if err := os.MkdirAll(cfg.StorageDir, 0o750); err != nil {
+		return nil, fmt.Errorf("create attachment storage: %w", err)
+	}
+
+	return &InboundService{
+		config:    cfg,
+		routeRepo: routeRepo,
+		redis:     redis,
+		logger:    logger,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		secret:  []byte(cfg.SigningSecret),
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// MaxMessageSize is the largest message Receive accepts, in bytes
+func (s *InboundService) MaxMessageSize() int64 {
+	return s.config.MaxMessageSize
+}
+
+// Start runs the delivery workers and the cleanup of expired attachments
+func (s *InboundService) Start(ctx context.Context) {
+	for i := 0; i < 4; i++ {
+		s.wg.Add(1)
+		go s.deliveryWorker(ctx)
+	}
+	go s.attachmentCleanup(ctx)
+}
+
+// Receive parses a message received over SMTP and queues a post to each
+// route its recipients match. Recipients without a route are skipped; when
+// none has one ErrNoInboundRoute is returned. It returns the number of
+// posts queued.
+func (s *InboundService) Receive(ctx context.Context, mailFrom string, rcptTo []string, raw []byte) (int, error) {
+	var routes []*models.InboundRoute
+	recipients := make(map[uuid.UUID][]string)
+	for _, rcpt := range rcptTo {
+		route, err := s.routeRepo.Match(ctx, rcpt)
+		if errors.Is(err, repository.ErrInboundRouteNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := recipients[route.ID]; !ok {
+			routes = append(routes, route)
+		}
+		recipients[route.ID] = append(recipients[route.ID], rcpt)
+	}
+	if len(routes) == 0 {
+		return 0, ErrNoInboundRoute
+	}
+
+	parsed, err := parseMessage(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidInboundMessage, err)
+	}
+
+	attachments, stripped, err := s.storeAttachments(parsed.Attachments)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	var entries []redis.Z
+	for _, route := range routes {
+		payload := &models.InboundParsePayload{
+			ID:                  uuid.New().String(),
+			RouteID:             route.ID,
+			Envelope:            models.InboundEnvelope{From: mailFrom, To: recipients[route.ID]},
+			From:                decodeHeader(parsed.Header.Get("From")),
+			To:                  decodeHeader(parsed.Header.Get("To")),
+			Cc:                  decodeHeader(parsed.Header.Get("Cc")),
+			ReplyTo:             decodeHeader(parsed.Header.Get("Reply-To")),
+			Subject:             decodeHeader(parsed.Header.Get("Subject")),
+			MessageID:           parsed.Header.Get("Message-Id"),
+			Date:                parsed.Header.Get("Date"),
+			Text:                parsed.Text,
+			HTML:                parsed.HTML,
+			Headers:             textproto.MIMEHeader(parsed.Header),
+			Attachments:         attachments,
+			StrippedAttachments: stripped,
+			ReceivedAt:          now,
+		}
+		data, err := json.Marshal(&inboundDelivery{RouteID: route.ID, Attempt: 1, Payload: payload})
+		if err != nil {
+			return 0, fmt.Errorf("marshal inbound payload: %w", err)
+		}
+		entries = append(entries, redis.Z{Score: float64(now.Unix()), Member: data})
+	}
+
+	if err := s.redis.ZAdd(ctx, inboundQueueKey, entries...).Err(); err != nil {
+		return 0, fmt.Errorf("queue inbound posts: %w", err)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return len(entries), nil
+}
+
+// storeAttachments writes attachments to storage and returns links to them.
+// Executables and attachments over the size limit are left out and listed
+// as stripped.
+func (s *InboundService) storeAttachments(parts []parsedAttachment) ([]models.InboundAttachment, []models.StrippedAttachment, error) {
+	attachments := []models.InboundAttachment{}
+	var stripped []models.StrippedAttachment
+	expiresAt := time.Now().Add(time.Duration(s.config.AttachmentTTL) * time.Second).UTC().Truncate(time.Second)
+
+	for _, part := range parts {
+		size := int64(len(part.Data))
+		reason := ""
+		switch {
+		case isExecutable(part.Filename, part.Data):
+			reason = models.StrippedExecutable
+		case size > s.config.MaxAttachmentSize:
+			reason = models.StrippedTooLarge
+		}
+		if reason != "" {
+			stripped = append(stripped, models.StrippedAttachment{
+				Filename:    part.Filename,
+				ContentType: part.ContentType,
+				Size:        size,
+				Reason:      reason,
+			})
+			continue
+		}
+
+		idBytes := make([]byte, 16)
+		if _, err := rand.Read(idBytes); err != nil {
+			return nil, nil, fmt.Errorf("generate attachment id: %w", err)
+		}
+		id := hex.EncodeToString(idBytes)
+		if err := os.WriteFile(filepath.Join(s.config.StorageDir, id), part.Data, 0o640); err != nil {
+			return nil, nil, fmt.Errorf("store attachment: %w", err)
+		}
+
+		attachments = append(attachments, models.InboundAttachment{
+			Filename:    part.Filename,
+			ContentType: part.ContentType,
+			ContentID:   part.ContentID,
+			Size:        size,
+			URL:         s.attachmentURL(id, part.Filename, part.ContentType, expiresAt),
+			ExpiresAt:   expiresAt,
+		})
+	}
+
+	return attachments, stripped, nil
+}
+
+// attachmentURL returns a download link for a stored attachment. The link
+// is signed over the ID, name, type and expiry, so none can be altered.
+func (s *InboundService) attachmentURL(id, filename, contentType string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{
+		"name":      {filename},
+		"type":      {contentType},
+		"expires":   {expires},
+		"signature": {s.signAttachment(id, filename, contentType, expires)},
+	}
+	return s.baseURL + "/v1/inbound/attachments/" + id + "?" + query.Encode()
+}
+
+func (s *InboundService) signAttachment(id, filename, contentType, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "\n" + filename + "\n" + contentType + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// OpenAttachment verifies an attachment link and opens the file it points at
+func (s *InboundService) OpenAttachment(id, filename, contentType, expires, signature string) (*os.File, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !attachmentIDPattern.MatchString(id) || time.Now().Unix() > expiresAt {
+		return nil, ErrInvalidAttachmentLink
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signAttachment(id, filename, contentType, expires))) {
+		return nil, ErrInvalidAttachmentLink
+	}
+
+	file, err := os.Open(filepath.Join(s.config.StorageDir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrInboundAttachmentGone
+	}
+	return file, err
+}
+
+func (s *InboundService) deliveryWorker(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		for s.deliverNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// deliverNext claims one due post from the queue and delivers it. It
+// reports whether there was one.
+func (s *InboundService) deliverNext(ctx context.Context) bool {
+	due, err := s.redis.ZRangeByScore(ctx, inboundQueueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().Unix(), 10),
+		Count: 1,
+	}).Result()
+	if err != nil || len(due) == 0 {
+		return false
+	}
+
+	// Removing the entry claims it; another worker may have got there first
+	removed, err := s.redis.ZRem(ctx, inboundQueueKey, due[0]).Result()
+	if err != nil || removed == 0 {
+		return err == nil
+	}
+
+	var delivery inboundDelivery
+	if err := json.Unmarshal([]byte(due[0]), &delivery); err != nil {
+		s.logger.Error("Dropping malformed inbound post", zap.Error(err))
+		return true
+	}
+
+	if err := s.deliver(ctx, &delivery); err != nil {
+		s.handleDeliveryFailure(ctx, &delivery, err)
+	}
+	return true
+}
+
+func (s *InboundService) deliver(ctx context.Context, delivery *inboundDelivery) error {
+	// Load the route now, so retries use its current URL and secret
+	route, err := s.routeRepo.GetActive(ctx, delivery.RouteID)
+	if errors.Is(err, repository.ErrInboundRouteNotFound) {
+		s.logger.Info("Dropping inbound post for deleted or inactive route",
+			zap.String("route_id", delivery.RouteID.String()),
+			zap.String("payload_id", delivery.Payload.ID))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load inbound route: %w", err)
+	}
+
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		return fmt.Errorf("marshal inbound payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, route.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "OONRUMAIL-Webhooks/1.0")
+	req.Header.Set("X-Webhook-ID", route.ID.String())
+	req.Header.Set("X-Webhook-Timestamp", fmt.Sprintf("%d", time.Now().Unix()))
+	req.Header.Set(HeaderWebhookSignature, signWebhookPayload(body, route.Secret))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	s.logger.Debug("Inbound message posted",
+		zap.String("route_id", route.ID.String()),
+		zap.String("payload_id", delivery.Payload.ID))
+	return nil
+}
+
+func (s *InboundService) handleDeliveryFailure(ctx context.Context, delivery *inboundDelivery, err error) {
+	s.logger.Warn("Inbound post failed",
+		zap.String("route_id", delivery.RouteID.String()),
+		zap.String("payload_id", delivery.Payload.ID),
+		zap.Int("attempt", delivery.Attempt),
+		zap.Error(err))
+
+	if delivery.Attempt > len(inboundRetryDelays) {
+		return
+	}
+
+	due := time.Now().Add(inboundRetryDelays[delivery.Attempt-1])
+	delivery.Attempt++
+	data, _ := json.Marshal(delivery)
+	if err := s.redis.ZAdd(ctx, inboundQueueKey, redis.Z{Score: float64(due.Unix()), Member: data}).Err(); err != nil {
+		s.logger.Error("Failed to schedule inbound post retry",
+			zap.String("payload_id", delivery.Payload.ID),
+			zap.Error(err))
+	}
+}
+
+// attachmentCleanup removes stored attachments once their links expire
+func (s *InboundService) attachmentCleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.removeExpiredAttachments()
+		}
+	}
+}
+
+func (s *InboundService) removeExpiredAttachments() {
+	entries, err := os.ReadDir(s.config.StorageDir)
+	if err != nil {
+		s.logger.Error("Failed to list inbound attachments", zap.Error(err))
+		return
+	}
+
+	cutoff := time.Now().Add(-time.Duration(s.config.AttachmentTTL) * time.Second)
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !attachmentIDPattern.MatchString(entry.Name()) || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.config.StorageDir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.logger.Info("Removed expired inbound attachments", zap.Int("count", removed))
+	}
+}
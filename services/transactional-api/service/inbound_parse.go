@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"strings"
+)
+
+// maxMIMEDepth bounds how deeply nested multipart bodies are followed
+const maxMIMEDepth = 10
+
+// executableExtensions are attachment types stripped from inbound posts
+// regardless of their content
+var executableExtensions = map[string]bool{
+	".exe": true, ".dll": true, ".scr": true, ".com": true, ".pif": true,
+	".bat": true, ".cmd": true, ".msi": true, ".msp": true, ".cpl": true,
+	".vbs": true, ".vbe": true, ".js": true, ".jse": true, ".wsf": true,
+	".wsh": true, ".ps1": true, ".psm1": true, ".hta": true, ".jar": true,
+	".lnk": true, ".reg": true, ".sh": true, ".app": true, ".apk": true,
+}
+
+// executableMagic are the leading bytes of executable formats: PE, ELF,
+// Mach-O (both byte orders, 32 and 64 bit, and universal) and scripts
+var executableMagic = [][]byte{
+	[]byte("MZ"),
+	[]byte("\x7fELF"),
+	{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe},
+	[]byte("#!"),
+}
+
+// parsedMessage is an inbound message split into its bodies and attachments
+type parsedMessage struct {
+	Header      mail.Header
+	Text        string
+	HTML        string
+	Attachments []parsedAttachment
+}
+
+type parsedAttachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Data        []byte
+}
+
+// parseMessage parses an RFC 5322 message. The first text/plain and
+// text/html parts that aren't attachments become the bodies; every other
+// leaf part is an attachment.
+func parseMessage(raw []byte) (*parsedMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+
+	parsed := &parsedMessage{Header: msg.Header}
+	if err := parsed.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+func (m *parsedMessage) walk(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" && depth < maxMIMEDepth {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			// Raw parts, so quoted-printable is decoded here along with base64
+			part, err := reader.NextRawPart()
+			if err != nil {
+				// A missing closing boundary keeps the parts read so far
+				if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+					return nil
+				}
+				return fmt.Errorf("read multipart body: %w", err)
+			}
+			if err := m.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("decode %s part: %w", mediaType, err)
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	attachment := disposition == "attachment" || filename != ""
+
+	switch {
+	case !attachment && mediaType == "text/plain" && m.Text == "":
+		m.Text = string(data)
+	case !attachment && mediaType == "text/html" && m.HTML == "":
+		m.HTML = string(data)
+	default:
+		m.Attachments = append(m.Attachments, parsedAttachment{
+			Filename:    attachmentFilename(filename, mediaType),
+			ContentType: mediaType,
+			ContentID:   strings.Trim(header.Get("Content-Id"), "<>"),
+			Data:        data,
+		})
+	}
+	return nil
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// The decoder skips the line breaks between encoded lines
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// attachmentFilename drops any directories from a sender-supplied name and
+// names unnamed parts after their type
+func attachmentFilename(filename, mediaType string) string {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if filename != "." && filename != "/" && filename != "" {
+		return filename
+	}
+	if mediaType == "message/rfc822" {
+		return "message.eml"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return "attachment" + exts[0]
+	}
+	return "attachment"
+}
+
+// isExecutable reports whether an attachment is a program or script, by
+// its extension or its leading bytes
+func isExecutable(filename string, data []byte) bool {
+	if executableExtensions[strings.ToLower(path.Ext(filename))] {
+		return true
+	}
+	for _, magic := range executableMagic {
+		if bytes.HasPrefix(data, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeHeader decodes RFC 2047 encoded words, keeping the raw value when
+// they use a charset that isn't supported
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
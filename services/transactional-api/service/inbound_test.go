@@ -0,0 +1,150 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"transactional-api/config"
+	"transactional-api/models"
+)
+
+const testInboundMessage = "From: =?UTF-8?Q?Ren=C3=A9e?= <renee@example.com>\r\n" +
+	"To: support@parse.example.org\r\n" +
+	"Subject: Invoice\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Total: 10=E2=82=AC\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Total</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=\"invoice.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"../invoice.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0x\r\n" +
+	"LjQK\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/octet-stream\r\n" +
+	"Content-Disposition: attachment; filename=\"setup.bin\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"TVqQAAMAAAAEAAAA\r\n" +
+	"--outer--\r\n"
+
+func TestParseMessage(t *testing.T) {
+	parsed, err := parseMessage([]byte(testInboundMessage))
+	if err != nil {
+		t.Fatalf("parseMessage() error = %v", err)
+	}
+
+	if got := decodeHeader(parsed.Header.Get("From")); got != "Renée <renee@example.com>" {
+		t.Errorf("From = %q", got)
+	}
+	if parsed.Text != "Total: 10€" {
+		t.Errorf("Text = %q", parsed.Text)
+	}
+	if parsed.HTML != "<p>Total</p>" {
+		t.Errorf("HTML = %q", parsed.HTML)
+	}
+	if len(parsed.Attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2", len(parsed.Attachments))
+	}
+	pdf := parsed.Attachments[0]
+	if pdf.Filename != "invoice.pdf" || pdf.ContentType != "application/pdf" || string(pdf.Data) != "%PDF-1.4\n" {
+		t.Errorf("attachment = %q %q %q", pdf.Filename, pdf.ContentType, pdf.Data)
+	}
+	if !isExecutable(parsed.Attachments[1].Filename, parsed.Attachments[1].Data) {
+		t.Error("PE attachment with an innocent name not detected as executable")
+	}
+}
+
+func TestIsExecutable(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+		want     bool
+	}{
+		{"report.pdf", "%PDF-1.4", false},
+		{"notes.txt", "hello", false},
+		{"Setup.EXE", "", true},
+		{"invoice.js", "alert(1)", true},
+		{"data", "\x7fELF\x02\x01", true},
+		{"run", "#!/bin/sh\n", true},
+		{"tool", "\xcf\xfa\xed\xfe", true},
+	}
+	for _, tt := range tests {
+		if got := isExecutable(tt.filename, []byte(tt.data)); got != tt.want {
+			t.Errorf("isExecutable(%q) = %v, want %v", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestStoreAttachments(t *testing.T) {
+	s := &InboundService{
+		config: &config.InboundConfig{
+			StorageDir:        t.TempDir(),
+			MaxAttachmentSize: 16,
+			AttachmentTTL:     3600,
+		},
+		secret:  []byte("secret"),
+		baseURL: "https://api.example.com",
+	}
+
+	attachments, stripped, err := s.storeAttachments([]parsedAttachment{
+		{Filename: "a b.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4")},
+		{Filename: "big.pdf", ContentType: "application/pdf", Data: make([]byte, 17)},
+		{Filename: "virus.exe", ContentType: "application/octet-stream", Data: []byte("MZ")},
+	})
+	if err != nil {
+		t.Fatalf("storeAttachments() error = %v", err)
+	}
+	if len(attachments) != 1 || len(stripped) != 2 {
+		t.Fatalf("got %d stored and %d stripped, want 1 and 2", len(attachments), len(stripped))
+	}
+	if stripped[0].Reason != models.StrippedTooLarge || stripped[1].Reason != models.StrippedExecutable {
+		t.Errorf("stripped reasons = %q, %q", stripped[0].Reason, stripped[1].Reason)
+	}
+
+	link, err := url.Parse(attachments[0].URL)
+	if err != nil || !strings.HasPrefix(attachments[0].URL, "https://api.example.com/v1/inbound/attachments/") {
+		t.Fatalf("URL = %q", attachments[0].URL)
+	}
+	id := strings.TrimPrefix(link.Path, "/v1/inbound/attachments/")
+	query := link.Query()
+
+	file, err := s.OpenAttachment(id, query.Get("name"), query.Get("type"), query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		t.Fatalf("OpenAttachment() error = %v", err)
+	}
+	file.Close()
+
+	// Links can't be altered or used after they expire
+	if _, err := s.OpenAttachment(id, "a b.html", query.Get("type"), query.Get("expires"), query.Get("signature")); err != ErrInvalidAttachmentLink {
+		t.Errorf("OpenAttachment() with another name: err = %v", err)
+	}
+	if _, err := s.OpenAttachment(id, query.Get("name"), "text/html", query.Get("expires"), query.Get("signature")); err != ErrInvalidAttachmentLink {
+		t.Errorf("OpenAttachment() with another type: err = %v", err)
+	}
+	if _, err := s.OpenAttachment("../"+id, query.Get("name"), query.Get("type"), query.Get("expires"), query.Get("signature")); err != ErrInvalidAttachmentLink {
+		t.Errorf("OpenAttachment() with a path: err = %v", err)
+	}
+	past := s.attachmentURL(id, "a b.pdf", "application/pdf", time.Now().Add(-time.Minute))
+	expired, _ := url.Parse(past)
+	q := expired.Query()
+	if _, err := s.OpenAttachment(id, q.Get("name"), q.Get("type"), q.Get("expires"), q.Get("signature")); err != ErrInvalidAttachmentLink {
+		t.Errorf("OpenAttachment() after expiry: err = %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"transactional-api/config"
+	"transactional-api/models"
+)
+
+const (
+	pacingDomainsKey = "email:pacing:domains"
+	pacingStateTTL   = 24 * time.Hour
+	maxBackoffLevel  = 10
+	maxPacingRetries = 5
+)
+
+// ErrPacingContention is returned when a domain's bucket kept changing
+// under concurrent senders
+var ErrPacingContention = errors.New("pacing state changed concurrently")
+
+func pacingKey(domain string) string {
+	return "email:pacing:domain:" + domain
+}
+
+// DomainPacer paces delivery with a token bucket per recipient domain. The
+// buckets live in Redis so every sender worker draws from the same ones.
+type DomainPacer struct {
+	config *config.PacingConfig
+	redis  *redis.Client
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewDomainPacer creates a new DomainPacer
+func NewDomainPacer(cfg *config.PacingConfig, redisClient *redis.Client, logger *zap.Logger) *DomainPacer {
+	return &DomainPacer{
+		config: cfg,
+		redis:  redisClient,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// bucket is the stored state of one domain's token bucket
+type bucket struct {
+	tokens       float64
+	updatedAt    time.Time
+	backoffLevel int
+	backoffUntil time.Time
+	deferrals    int64
+	throttles    int64
+}
+
+func parseBucket(fields map[string]string) bucket {
+	var b bucket
+	b.tokens, _ = strconv.ParseFloat(fields["tokens"], 64)
+	if ms, err := strconv.ParseInt(fields["updated_at"], 10, 64); err == nil {
+		b.updatedAt = time.UnixMilli(ms)
+	}
+	b.backoffLevel, _ = strconv.Atoi(fields["backoff_level"])
+	if ms, err := strconv.ParseInt(fields["backoff_until"], 10, 64); err == nil && ms > 0 {
+		b.backoffUntil = time.UnixMilli(ms)
+	}
+	b.deferrals, _ = strconv.ParseInt(fields["deferrals"], 10, 64)
+	b.throttles, _ = strconv.ParseInt(fields["throttles"], 10, 64)
+	return b
+}
+
+func (b *bucket) fields() map[string]any {
+	var backoffUntil int64
+	if !b.backoffUntil.IsZero() {
+		backoffUntil = b.backoffUntil.UnixMilli()
+	}
+	return map[string]any{
+		"tokens":        strconv.FormatFloat(b.tokens, 'f', -1, 64),
+		"updated_at":    b.updatedAt.UnixMilli(),
+		"backoff_level": b.backoffLevel,
+		"backoff_until": backoffUntil,
+		"deferrals":     b.deferrals,
+		"throttles":     b.throttles,
+	}
+}
+
+// limits returns a domain's configured rate and burst
+func (p *DomainPacer) limits(domain string) (float64, float64) {
+	if override, ok := p.config.Domains[domain]; ok && override.Rate > 0 {
+		burst := override.Burst
+		if burst <= 0 {
+			burst = p.config.DefaultBurst
+		}
+		return override.Rate, float64(burst)
+	}
+	return p.config.DefaultRate, float64(p.config.DefaultBurst)
+}
+
+// effectiveRate returns a domain's rate at a time, cut once for every
+// throttle response while its backoff lasts
+func (p *DomainPacer) effectiveRate(rate float64, b *bucket, at time.Time) float64 {
+	if b.backoffLevel == 0 || !at.Before(b.backoffUntil) {
+		return rate
+	}
+	cut := rate * math.Pow(p.config.BackoffFactor, float64(b.backoffLevel))
+	return math.Max(cut, math.Min(p.config.MinRate, rate))
+}
+
+// refill adds the tokens earned since the bucket was last updated and ends
+// an expired backoff. A new bucket starts full.
+func (p *DomainPacer) refill(b *bucket, rate, burst float64, now time.Time) {
+	if b.updatedAt.IsZero() {
+		b.tokens = burst
+		b.updatedAt = now
+		return
+	}
+
+	if elapsed := now.Sub(b.updatedAt).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*p.effectiveRate(rate, b, b.updatedAt))
+		b.updatedAt = now
+	}
+	if b.backoffLevel > 0 && !now.Before(b.backoffUntil) {
+		b.backoffLevel = 0
+		b.backoffUntil = time.Time{}
+	}
+}
+
+// Reserve takes a token from the bucket of every domain, or from none if
+// any of them is empty. It returns how long until all of them have a token
+// again, or zero once the tokens are taken.
+func (p *DomainPacer) Reserve(ctx context.Context, domains []string) (time.Duration, error) {
+	var wait time.Duration
+	err := p.update(ctx, domains, func(now time.Time, buckets map[string]*bucket) {
+		wait = 0
+		var empty []*bucket
+		for domain, b := range buckets {
+			rate, burst := p.limits(domain)
+			p.refill(b, rate, burst, now)
+			if b.tokens >= 1 {
+				continue
+			}
+			empty = append(empty, b)
+			seconds := (1 - b.tokens) / p.effectiveRate(rate, b, now)
+			if w := time.Duration(seconds * float64(time.Second)); w > wait {
+				wait = w
+			}
+		}
+
+		if len(empty) > 0 {
+			for _, b := range empty {
+				b.deferrals++
+			}
+			return
+		}
+		for _, b := range buckets {
+			b.tokens--
+		}
+	})
+	return wait, err
+}
+
+// Throttle backs off the domains after a throttle response: their buckets
+// are emptied and their rates cut until the backoff period passes without
+// another throttle.
+func (p *DomainPacer) Throttle(ctx context.Context, domains []string) error {
+	return p.update(ctx, domains, func(now time.Time, buckets map[string]*bucket) {
+		for domain, b := range buckets {
+			rate, burst := p.limits(domain)
+			p.refill(b, rate, burst, now)
+			b.tokens = math.Min(b.tokens, 0)
+			if b.backoffLevel < maxBackoffLevel {
+				b.backoffLevel++
+			}
+			b.backoffUntil = now.Add(time.Duration(p.config.BackoffPeriod) * time.Second)
+			b.throttles++
+
+			p.logger.Warn("Recipient domain throttled delivery",
+				zap.String("domain", domain),
+				zap.Int("backoff_level", b.backoffLevel),
+				zap.Float64("effective_rate", p.effectiveRate(rate, b, now)))
+		}
+	})
+}
+
+// RetryDelay returns how long to wait before retrying a throttled message
+func (p *DomainPacer) RetryDelay(attempt int) time.Duration {
+	return time.Duration(p.config.RetryDelay*attempt) * time.Second
+}
+
+// update applies fn to the buckets of the domains and stores them, retrying
+// if another sender changed one of them in the meantime
+func (p *DomainPacer) update(ctx context.Context, domains []string, fn func(now time.Time, buckets map[string]*bucket)) error {
+	if len(domains) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(domains))
+	members := make([]any, len(domains))
+	for i, domain := range domains {
+		keys[i] = pacingKey(domain)
+		members[i] = domain
+	}
+
+	txf := func(tx *redis.Tx) error {
+		buckets := make(map[string]*bucket, len(domains))
+		for _, domain := range domains {
+			fields, err := tx.HGetAll(ctx, pacingKey(domain)).Result()
+			if err != nil {
+				return err
+			}
+			b := parseBucket(fields)
+			buckets[domain] = &b
+		}
+
+		fn(p.now(), buckets)
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for domain, b := range buckets {
+				pipe.HSet(ctx, pacingKey(domain), b.fields())
+				pipe.Expire(ctx, pacingKey(domain), pacingStateTTL)
+			}
+			pipe.SAdd(ctx, pacingDomainsKey, members...)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxPacingRetries; i++ {
+		err := p.redis.Watch(ctx, txf, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return ErrPacingContention
+}
+
+// State returns the pacing of every domain sent to recently, by domain
+func (p *DomainPacer) State(ctx context.Context) ([]models.DomainPacingState, error) {
+	domains, err := p.redis.SMembers(ctx, pacingDomainsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(domains)
+
+	now := p.now()
+	states := make([]models.DomainPacingState, 0, len(domains))
+	for _, domain := range domains {
+		fields, err := p.redis.HGetAll(ctx, pacingKey(domain)).Result()
+		if err != nil {
+			return nil, err
+		}
+		// The bucket expired after the domain went quiet
+		if len(fields) == 0 {
+			p.redis.SRem(ctx, pacingDomainsKey, domain)
+			continue
+		}
+
+		b := parseBucket(fields)
+		rate, burst := p.limits(domain)
+		p.refill(&b, rate, burst, now)
+
+		state := models.DomainPacingState{
+			Domain:        domain,
+			Rate:          rate,
+			EffectiveRate: p.effectiveRate(rate, &b, now),
+			Burst:         int(burst),
+			Tokens:        b.tokens,
+			BackoffLevel:  b.backoffLevel,
+			Deferrals:     b.deferrals,
+			Throttles:     b.throttles,
+			UpdatedAt:     b.updatedAt,
+		}
+		if b.backoffLevel > 0 {
+			until := b.backoffUntil
+			state.BackoffUntil = &until
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// recipientDomains returns the distinct domains a message is addressed to
+func recipientDomains(message *models.Message) []string {
+	seen := make(map[string]bool)
+	var domains []string
+	for _, list := range [][]string{message.To, message.CC, message.BCC} {
+		for _, addr := range list {
+			at := strings.LastIndex(addr, "@")
+			if at < 0 {
+				continue
+			}
+			domain := strings.ToLower(strings.TrimRight(strings.TrimSpace(addr[at+1:]), ">"))
+			if domain != "" && !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+	return domains
+}
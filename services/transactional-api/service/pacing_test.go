@@ -0,0 +1,102 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"transactional-api/config"
+	"transactional-api/models"
+)
+
+func newTestPacer() *DomainPacer {
+	return &DomainPacer{config: &config.PacingConfig{
+		DefaultRate:   10,
+		DefaultBurst:  20,
+		Domains:       map[string]config.DomainPacingConfig{"gmail.com": {Rate: 2, Burst: 4}},
+		BackoffFactor: 0.5,
+		BackoffPeriod: 600,
+		MinRate:       0.1,
+		RetryDelay:    300,
+	}}
+}
+
+func TestPacerLimits(t *testing.T) {
+	p := newTestPacer()
+
+	if rate, burst := p.limits("gmail.com"); rate != 2 || burst != 4 {
+		t.Errorf("limits(gmail.com) = %v, %v, want 2, 4", rate, burst)
+	}
+	if rate, burst := p.limits("example.com"); rate != 10 || burst != 20 {
+		t.Errorf("limits(example.com) = %v, %v, want 10, 20", rate, burst)
+	}
+}
+
+func TestPacerRefill(t *testing.T) {
+	p := newTestPacer()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var b bucket
+	p.refill(&b, 2, 4, now)
+	if b.tokens != 4 {
+		t.Fatalf("new bucket has %v tokens, want 4", b.tokens)
+	}
+
+	b.tokens = 0
+	p.refill(&b, 2, 4, now.Add(time.Second))
+	if b.tokens != 2 {
+		t.Errorf("tokens after 1s = %v, want 2", b.tokens)
+	}
+
+	p.refill(&b, 2, 4, now.Add(time.Minute))
+	if b.tokens != 4 {
+		t.Errorf("tokens after 1m = %v, want capped at 4", b.tokens)
+	}
+}
+
+func TestPacerBackoff(t *testing.T) {
+	p := newTestPacer()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := bucket{updatedAt: now, backoffLevel: 2, backoffUntil: now.Add(10 * time.Minute)}
+
+	if got := p.effectiveRate(2, &b, now); got != 0.5 {
+		t.Errorf("effectiveRate() at level 2 = %v, want 0.5", got)
+	}
+
+	b.backoffLevel = 10
+	if got := p.effectiveRate(2, &b, now); got != 0.1 {
+		t.Errorf("effectiveRate() at level 10 = %v, want the 0.1 minimum", got)
+	}
+
+	// Tokens are earned at the cut rate, and the backoff ends once it expires
+	b.backoffLevel = 2
+	p.refill(&b, 2, 4, now.Add(2*time.Second))
+	if b.tokens != 1 {
+		t.Errorf("tokens during backoff = %v, want 1", b.tokens)
+	}
+	p.refill(&b, 2, 4, now.Add(11*time.Minute))
+	if b.backoffLevel != 0 || !b.backoffUntil.IsZero() {
+		t.Errorf("backoff not cleared after it expired: level %d until %v", b.backoffLevel, b.backoffUntil)
+	}
+	if got := p.effectiveRate(2, &b, now.Add(11*time.Minute)); got != 2 {
+		t.Errorf("effectiveRate() after backoff = %v, want 2", got)
+	}
+}
+
+func TestRecipientDomains(t *testing.T) {
+	message := &models.Message{
+		To:  []string{"a@Gmail.com", "b@gmail.com"},
+		CC:  []string{"Carol <c@outlook.com>"},
+		BCC: []string{"invalid", "d@yahoo.com"},
+	}
+
+	got := recipientDomains(message)
+	want := []string{"gmail.com", "outlook.com", "yahoo.com"}
+	if len(got) != len(want) {
+		t.Fatalf("recipientDomains() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("recipientDomains()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
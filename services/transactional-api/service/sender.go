@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/smtp"
+	"net/textproto"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +20,9 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// ErrTooManySuppressed is returned when a bulk send exceeds the configured suppression limit
+var ErrTooManySuppressed = errors.New("too many suppressed recipients in batch")
+
 // SenderService handles email sending business logic
 type SenderService struct {
 	config           *config.Config
@@ -25,6 +31,8 @@ type SenderService struct {
 	templateService  *TemplateService
 	trackingService  *TrackingService
 	analyticsRepo    *repository.AnalyticsRepository
+	pacer            *DomainPacer
+	sendingDomains   *SendingDomainSelector
 	redis            *redis.Client
 	logger           zerolog.Logger
 }
@@ -37,6 +45,8 @@ func NewSenderService(
 	templateService *TemplateService,
 	trackingService *TrackingService,
 	analyticsRepo *repository.AnalyticsRepository,
+	pacer *DomainPacer,
+	sendingDomains *SendingDomainSelector,
 	redisClient *redis.Client,
 	logger zerolog.Logger,
 ) *SenderService {
@@ -47,6 +57,8 @@ func NewSenderService(
 		templateService: templateService,
 		trackingService: trackingService,
 		analyticsRepo:   analyticsRepo,
+		pacer:           pacer,
+		sendingDomains:  sendingDomains,
 		redis:           redisClient,
 		logger:          logger,
 	}
@@ -79,7 +91,7 @@ func (s *SenderService) Send(ctx context.Context, req *models.SendRequest, apiKe
 			return nil, fmt.Errorf("invalid template_id: %w", err)
 		}
 
-		rendered, err := s.templateService.Render(ctx, templateID, apiKey.DomainID, req.Substitutions)
+		rendered, err := s.templateService.Render(ctx, templateID, keyTemplateAccess(apiKey), req.Substitutions)
 		if err != nil {
 			return nil, fmt.Errorf("failed to render template: %w", err)
 		}
@@ -87,12 +99,22 @@ func (s *SenderService) Send(ctx context.Context, req *models.SendRequest, apiKe
 		subject = rendered.Subject
 		html = rendered.HTML
 		text = rendered.Text
+		if rendered.TextGenerated && !autoText(req.AutoText) {
+			text = ""
+		}
 	} else {
 		subject = req.Subject
 		html = req.HTML
 		text = req.Text
 	}
 
+	// Give HTML-only messages a text alternative. It is generated before
+	// substitution, which it keeps the variables for, so values go into the
+	// text unescaped.
+	if text == "" && html != "" && autoText(req.AutoText) {
+		text = HTMLToText(html)
+	}
+
 	// Apply variable substitution to subject/content
 	if len(req.Substitutions) > 0 {
 		subject = applySubstitutions(subject, req.Substitutions)
@@ -147,7 +169,7 @@ func (s *SenderService) Send(ctx context.Context, req *models.SendRequest, apiKe
 		TemplateID:  templateID,
 		Categories:  req.Categories,
 		CustomArgs:  req.CustomArgs,
-		Headers:     req.Headers,
+		Headers:     readReceiptHeaders(req.Headers, req.RequestReadReceipt, req.From),
 		Status:      status,
 		TrackOpens:  trackOpens,
 		TrackClicks: trackClicks,
@@ -227,11 +249,227 @@ func (s *SenderService) SendBatch(ctx context.Context, req *models.BatchSendRequ
 	}, nil
 }
 
+// SendMessageBatch sends one personalized message per recipient, enqueuing them in a single transaction
+func (s *SenderService) SendMessageBatch(ctx context.Context, req *models.MessageBatchRequest, apiKey *models.APIKey) (*models.MessageBatchResponse, error) {
+	if len(req.Recipients) > s.config.Batch.MaxRecipients {
+		return nil, fmt.Errorf("batch exceeds maximum of %d recipients", s.config.Batch.MaxRecipients)
+	}
+
+	// Check suppression list for all recipients up front
+	addresses := make([]string, len(req.Recipients))
+	for i, rcpt := range req.Recipients {
+		addresses[i] = rcpt.To
+	}
+	_, suppressed := s.filterSuppressedRecipients(ctx, apiKey.DomainID, addresses)
+	if len(suppressed) > s.config.Batch.MaxSuppressed {
+		return nil, fmt.Errorf("%w: %d suppressed, limit is %d", ErrTooManySuppressed, len(suppressed), s.config.Batch.MaxSuppressed)
+	}
+	suppressedSet := make(map[string]string, len(suppressed))
+	for _, r := range suppressed {
+		suppressedSet[r.Email] = r.Reason
+	}
+
+	// Load the shared template once
+	var tmpl *models.Template
+	var templateID *uuid.UUID
+	if req.TemplateID != "" {
+		tid, err := uuid.Parse(req.TemplateID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template_id: %w", err)
+		}
+		tmpl, err = s.templateService.Get(ctx, tid, keyTemplateAccess(apiKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template: %w", err)
+		}
+		templateID = &tid
+	}
+
+	// Determine tracking settings
+	trackOpens := s.config.Tracking.EnableOpen
+	trackClicks := s.config.Tracking.EnableClick
+	if req.TrackOpens != nil {
+		trackOpens = *req.TrackOpens
+	}
+	if req.TrackClicks != nil {
+		trackClicks = *req.TrackClicks
+	}
+
+	// Determine status based on scheduling
+	status := models.MessageStatusQueued
+	if req.SendAt != nil && req.SendAt.After(time.Now()) {
+		status = models.MessageStatusScheduled
+	}
+
+	batchID := uuid.New()
+	keyPrefix := req.IdempotencyKey
+	if keyPrefix == "" {
+		keyPrefix = batchID.String()
+	}
+
+	resp := &models.MessageBatchResponse{
+		BatchID: batchID.String(),
+		Results: make([]models.BatchRecipientResult, len(req.Recipients)),
+	}
+	messages := make([]*models.Message, 0, len(req.Recipients))
+	queuedAt := time.Now()
+
+	for i, rcpt := range req.Recipients {
+		result := &resp.Results[i]
+		result.Index = i
+		result.To = rcpt.To
+
+		if reason, ok := suppressedSet[rcpt.To]; ok {
+			result.Status = "rejected"
+			result.Error = reason
+			result.Code = "suppressed"
+			continue
+		}
+
+		// Render per-recipient content
+		var subject, html, text string
+		if tmpl != nil {
+			if err := s.templateService.CheckRequiredVariables(tmpl, rcpt.Substitutions); err != nil {
+				result.Status = "rejected"
+				result.Error = err.Error()
+				result.Code = "missing_variable"
+				continue
+			}
+			rendered, err := s.templateService.RenderTemplate(tmpl, rcpt.Substitutions)
+			if err != nil {
+				result.Status = "rejected"
+				result.Error = err.Error()
+				result.Code = "render_failed"
+				continue
+			}
+			subject = rendered.Subject
+			html = rendered.HTML
+			text = rendered.Text
+		} else {
+			subject = applySubstitutions(req.Subject, rcpt.Substitutions)
+			html = applySubstitutions(req.HTML, rcpt.Substitutions)
+			text = applySubstitutions(req.Text, rcpt.Substitutions)
+		}
+
+		messageID := uuid.New()
+		if trackOpens && html != "" {
+			html = s.trackingService.AddTrackingPixel(html, messageID.String(), apiKey.DomainID.String())
+		}
+		if trackClicks && html != "" {
+			html = s.trackingService.RewriteLinks(html, messageID.String(), apiKey.DomainID.String())
+		}
+
+		messages = append(messages, &models.Message{
+			ID:             messageID,
+			DomainID:       apiKey.DomainID,
+			APIKeyID:       apiKey.ID,
+			From:           req.From,
+			To:             []string{rcpt.To},
+			ReplyTo:        req.ReplyTo,
+			Subject:        subject,
+			HTML:           html,
+			Text:           text,
+			TemplateID:     templateID,
+			Categories:     req.Categories,
+			CustomArgs:     rcpt.CustomArgs,
+			Headers:        req.Headers,
+			Status:         status,
+			TrackOpens:     trackOpens,
+			TrackClicks:    trackClicks,
+			ScheduledAt:    req.SendAt,
+			QueuedAt:       queuedAt,
+			BatchID:        &batchID,
+			IdempotencyKey: fmt.Sprintf("%s:%d", keyPrefix, i),
+		})
+		result.MessageID = messageID.String()
+		result.Status = string(status)
+	}
+
+	for _, r := range resp.Results {
+		if r.MessageID != "" {
+			resp.Accepted++
+		} else {
+			resp.Rejected++
+		}
+	}
+
+	if len(messages) == 0 {
+		return resp, nil
+	}
+
+	// Save all messages atomically
+	if err := s.messageRepo.CreateBatch(ctx, messages); err != nil {
+		return nil, fmt.Errorf("failed to save batch: %w", err)
+	}
+
+	// Queue for delivery; these are picked up by ProcessQueue like any other message
+	if status == models.MessageStatusQueued {
+		for _, message := range messages {
+			if err := s.queueForDelivery(ctx, message); err != nil {
+				s.logger.Error().Err(err).Str("message_id", message.ID.String()).Msg("Failed to queue message")
+			}
+		}
+	}
+
+	// Update analytics
+	category := ""
+	if len(req.Categories) > 0 {
+		category = req.Categories[0]
+	}
+	go func(count int) {
+		for i := 0; i < count; i++ {
+			s.analyticsRepo.IncrementDailyStat(context.Background(), apiKey.DomainID, category, "sent")
+		}
+	}(len(messages))
+
+	s.logger.Info().
+		Str("batch_id", resp.BatchID).
+		Str("from", req.From).
+		Int("accepted", resp.Accepted).
+		Int("rejected", resp.Rejected).
+		Str("status", string(status)).
+		Msg("Batch queued for delivery")
+
+	return resp, nil
+}
+
 // GetMessage retrieves a message by ID
 func (s *SenderService) GetMessage(ctx context.Context, id uuid.UUID) (*models.Message, error) {
 	return s.messageRepo.GetByID(ctx, id)
 }
 
+// CancelMessage cancels a message that is still scheduled or queued. Scheduled
+// messages are never picked up by ProcessScheduledMessages again, and queued
+// messages already pushed to the delivery queue are dropped by ProcessQueue.
+func (s *SenderService) CancelMessage(ctx context.Context, id, domainID uuid.UUID) error {
+	if err := s.messageRepo.Cancel(ctx, id, domainID); err != nil {
+		return err
+	}
+
+	s.logger.Info().
+		Str("message_id", id.String()).
+		Msg("Message cancelled")
+
+	return nil
+}
+
+// CancelBatch cancels every message in a batch that has not been sent yet
+func (s *SenderService) CancelBatch(ctx context.Context, batchID, domainID uuid.UUID) (*models.CancelBatchResponse, error) {
+	cancelled, err := s.messageRepo.CancelBatch(ctx, batchID, domainID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("batch_id", batchID.String()).
+		Int64("cancelled", cancelled).
+		Msg("Batch cancelled")
+
+	return &models.CancelBatchResponse{
+		BatchID:   batchID.String(),
+		Cancelled: cancelled,
+	}, nil
+}
+
 // ListMessages retrieves messages with filtering
 func (s *SenderService) ListMessages(ctx context.Context, query *models.MessageQuery) (*models.MessageListResponse, error) {
 	if query.Limit <= 0 {
@@ -249,6 +487,16 @@ func (s *SenderService) GetMessageTimeline(ctx context.Context, messageID uuid.U
 	return s.messageRepo.GetMessageTimeline(ctx, messageID)
 }
 
+// keyTemplateAccess identifies apiKey for template visibility checks; keys
+// here are scoped by domain, which stands in for the organization
+func keyTemplateAccess(apiKey *models.APIKey) models.TemplateAccess {
+	return models.TemplateAccess{
+		OrganizationID: apiKey.DomainID,
+		APIKeyID:       apiKey.ID,
+		Admin:          apiKey.HasScope(models.ScopeAdmin),
+	}
+}
+
 // validateRequest validates a send request
 func (s *SenderService) validateRequest(req *models.SendRequest) error {
 	if req.From == "" {
@@ -307,11 +555,17 @@ func (s *SenderService) queueForDelivery(ctx context.Context, message *models.Me
 	return s.redis.RPush(ctx, queueKey, data).Err()
 }
 
-// ProcessQueue processes messages from the delivery queue
+// ProcessQueue processes messages from the delivery queue. Messages to a
+// recipient domain whose pacing bucket is empty are deferred so that
+// messages to other domains go first.
 func (s *SenderService) ProcessQueue(ctx context.Context) error {
 	queueKey := "email:queue:pending"
 	processingKey := "email:queue:processing"
 
+	if err := s.promoteDeferred(ctx); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to promote deferred messages")
+	}
+
 	// Move message from pending to processing
 	data, err := s.redis.BRPopLPush(ctx, queueKey, processingKey, 30*time.Second).Bytes()
 	if err != nil {
@@ -326,8 +580,39 @@ func (s *SenderService) ProcessQueue(ctx context.Context) error {
 		return err
 	}
 
+	domains := recipientDomains(&message)
+	wait, err := s.pacer.Reserve(ctx, domains)
+	if err != nil {
+		// Pace on a best-effort basis rather than stall delivery
+		s.logger.Warn().Err(err).Str("message_id", message.ID.String()).Msg("Failed to check delivery pacing")
+	}
+	if wait > 0 {
+		s.redis.LRem(ctx, processingKey, 1, data)
+		return s.deferMessage(ctx, data, wait)
+	}
+
+	// Claim the message; this fails if it was cancelled after being queued
+	claimed, err := s.messageRepo.TransitionStatus(ctx, message.ID, models.MessageStatusSending, models.MessageStatusQueued)
+	if err != nil {
+		s.redis.LRem(ctx, processingKey, 1, data)
+		s.redis.RPush(ctx, queueKey, data)
+		return err
+	}
+	if !claimed {
+		s.logger.Info().Str("message_id", message.ID.String()).Msg("Skipping message that is no longer queued")
+		s.redis.LRem(ctx, processingKey, 1, data)
+		return nil
+	}
+
 	// Send the email
 	err = s.deliverEmail(ctx, &message)
+	if err != nil && isThrottleResponse(err) {
+		if retried := s.retryThrottled(ctx, &message, domains, data, err); retried {
+			s.redis.LRem(ctx, processingKey, 1, data)
+			return nil
+		}
+		s.messageRepo.MarkBounced(ctx, message.ID, err.Error())
+	}
 	if err != nil {
 		s.logger.Error().Err(err).Str("message_id", message.ID.String()).Msg("Failed to deliver email")
 
@@ -345,11 +630,83 @@ func (s *SenderService) ProcessQueue(ctx context.Context) error {
 	return nil
 }
 
+// retryThrottled backs off the message's recipient domains after a throttle
+// response and defers the message, unless it has run out of attempts
+func (s *SenderService) retryThrottled(ctx context.Context, message *models.Message, domains []string, data []byte, sendErr error) bool {
+	if err := s.pacer.Throttle(ctx, domains); err != nil {
+		s.logger.Error().Err(err).Strs("domains", domains).Msg("Failed to back off throttled domains")
+	}
+
+	attemptsKey := "email:queue:throttled:" + message.ID.String()
+	attempts, err := s.redis.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		s.logger.Error().Err(err).Str("message_id", message.ID.String()).Msg("Failed to count throttled attempts")
+		return false
+	}
+	s.redis.Expire(ctx, attemptsKey, 24*time.Hour)
+	if attempts > int64(s.config.SMTP.RetryCount) {
+		return false
+	}
+
+	// Back to queued so the retry can claim it again
+	if _, err := s.messageRepo.TransitionStatus(ctx, message.ID, models.MessageStatusQueued, models.MessageStatusSending); err != nil {
+		s.logger.Error().Err(err).Str("message_id", message.ID.String()).Msg("Failed to requeue throttled message")
+		return false
+	}
+	if err := s.deferMessage(ctx, data, s.pacer.RetryDelay(int(attempts))); err != nil {
+		s.logger.Error().Err(err).Str("message_id", message.ID.String()).Msg("Failed to defer throttled message")
+		return false
+	}
+
+	s.logger.Warn().Err(sendErr).
+		Str("message_id", message.ID.String()).
+		Int64("attempt", attempts).
+		Msg("Delivery throttled, message deferred")
+	return true
+}
+
+// deferMessage holds a queued message back until wait has passed
+func (s *SenderService) deferMessage(ctx context.Context, data []byte, wait time.Duration) error {
+	readyAt := time.Now().Add(wait)
+	return s.redis.ZAdd(ctx, "email:queue:deferred", redis.Z{Score: float64(readyAt.UnixMilli()), Member: data}).Err()
+}
+
+// promoteDeferred moves deferred messages that are ready back to the pending
+// queue, behind the messages already waiting there
+func (s *SenderService) promoteDeferred(ctx context.Context) error {
+	deferredKey := "email:queue:deferred"
+
+	ready, err := s.redis.ZRangeByScore(ctx, deferredKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: 100,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, data := range ready {
+		// Only the worker that removes a message requeues it
+		removed, err := s.redis.ZRem(ctx, deferredKey, data).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 1 {
+			s.redis.LPush(ctx, "email:queue:pending", data)
+		}
+	}
+	return nil
+}
+
+// isThrottleResponse reports whether an SMTP error is a temporary (4xx)
+// rejection, such as a provider's 421 rate limit
+func isThrottleResponse(err error) bool {
+	var smtpErr *textproto.Error
+	return errors.As(err, &smtpErr) && smtpErr.Code >= 400 && smtpErr.Code < 500
+}
+
 // deliverEmail sends an email via SMTP
 func (s *SenderService) deliverEmail(ctx context.Context, message *models.Message) error {
-	// Update status to sending
-	s.messageRepo.UpdateStatus(ctx, message.ID, models.MessageStatusSending, "")
-
 	// Build email message
 	var buf bytes.Buffer
 	buf.WriteString(fmt.Sprintf("From: %s\r\n", message.From))
@@ -373,14 +730,16 @@ func (s *SenderService) deliverEmail(ctx context.Context, message *models.Messag
 
 		// Text part
 		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
-		buf.WriteString(message.Text)
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		buf.WriteString(quotedPrintable(message.Text))
 		buf.WriteString("\r\n")
 
 		// HTML part
 		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		buf.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
-		buf.WriteString(message.HTML)
+		buf.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		buf.WriteString(quotedPrintable(message.HTML))
 		buf.WriteString("\r\n")
 
 		buf.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
@@ -404,9 +763,20 @@ func (s *SenderService) deliverEmail(ctx context.Context, message *models.Messag
 	allRecipients := append(message.To, message.CC...)
 	allRecipients = append(allRecipients, message.BCC...)
 
-	err := smtp.SendMail(addr, auth, message.From, allRecipients, buf.Bytes())
+	// Send from the organization's pooled domain, so it's signed with that
+	// domain's DKIM key; keys here are scoped by domain, which stands in for
+	// the organization
+	envelopeFrom := message.From
+	if s.sendingDomains != nil && len(allRecipients) > 0 {
+		envelopeFrom = s.sendingDomains.EnvelopeSender(ctx, message.DomainID, message.From, allRecipients[0])
+	}
+
+	err := smtp.SendMail(addr, auth, envelopeFrom, allRecipients, buf.Bytes())
 	if err != nil {
-		s.messageRepo.MarkBounced(ctx, message.ID, err.Error())
+		// A throttled message may be retried; ProcessQueue decides
+		if !isThrottleResponse(err) {
+			s.messageRepo.MarkBounced(ctx, message.ID, err.Error())
+		}
 		return err
 	}
 
@@ -429,14 +799,23 @@ func (s *SenderService) ProcessScheduledMessages(ctx context.Context) error {
 	}
 
 	for _, msg := range messages {
+		// Move to queued first so a concurrent cancellation either wins here
+		// or is caught by ProcessQueue
+		queued, err := s.messageRepo.TransitionStatus(ctx, msg.ID, models.MessageStatusQueued, models.MessageStatusScheduled)
+		if err != nil {
+			s.logger.Error().Err(err).Str("message_id", msg.ID.String()).Msg("Failed to update scheduled message")
+			continue
+		}
+		if !queued {
+			continue
+		}
+		msg.Status = models.MessageStatusQueued
+
 		// Queue for delivery
 		if err := s.queueForDelivery(ctx, &msg); err != nil {
 			s.logger.Error().Err(err).Str("message_id", msg.ID.String()).Msg("Failed to queue scheduled message")
-			continue
+			s.messageRepo.TransitionStatus(ctx, msg.ID, models.MessageStatusScheduled, models.MessageStatusQueued)
 		}
-
-		// Update status
-		s.messageRepo.UpdateStatus(ctx, msg.ID, models.MessageStatusQueued, "")
 	}
 
 	return nil
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"transactional-api/models"
+	"transactional-api/repository"
+)
+
+func sendingCounterKey(orgID uuid.UUID) string {
+	return "email:sending-domains:counter:" + orgID.String()
+}
+
+// SendingDomainSelector picks the domain each message is sent from out of
+// its organization's pool. The message's envelope sender is moved onto that
+// domain, which the SMTP server signs for with the domain's DKIM key; the
+// From header is left alone and stays aligned, as pooled domains must be
+// subdomains of it.
+type SendingDomainSelector struct {
+	repo   *repository.SendingDomainRepository
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+// NewSendingDomainSelector creates a new SendingDomainSelector
+func NewSendingDomainSelector(repo *repository.SendingDomainRepository, redisClient *redis.Client, logger *zap.Logger) *SendingDomainSelector {
+	return &SendingDomainSelector{repo: repo, redis: redisClient, logger: logger}
+}
+
+// EnvelopeSender returns the address to send a message from to recipient.
+// from, on the primary domain, is returned unchanged when the pool has no
+// eligible domain for it or the pool can't be read.
+func (s *SendingDomainSelector) EnvelopeSender(ctx context.Context, orgID uuid.UUID, from, recipient string) string {
+	at := strings.LastIndex(from, "@")
+	if at <= 0 {
+		return from
+	}
+	primary := strings.ToLower(from[at+1:])
+
+	pool, err := s.repo.GetPool(ctx, orgID)
+	if err != nil {
+		s.logger.Warn("Failed to load sending pool, using primary domain", zap.String("organization_id", orgID.String()), zap.Error(err))
+		return from
+	}
+
+	domains := eligibleSendingDomains(pool.Domains, primary)
+	if len(domains) == 0 {
+		return from
+	}
+
+	var n uint64
+	if pool.Strategy != models.SendingStrategySticky {
+		count, err := s.redis.Incr(ctx, sendingCounterKey(orgID)).Result()
+		if err != nil {
+			s.logger.Warn("Failed to advance sending pool, using primary domain", zap.String("organization_id", orgID.String()), zap.Error(err))
+			return from
+		}
+		n = uint64(count)
+	}
+
+	domain := pickSendingDomain(pool.Strategy, domains, n, recipientDomain(recipient))
+	if domain == primary {
+		return from
+	}
+
+	s.logger.Debug("Picked sending domain",
+		zap.String("organization_id", orgID.String()),
+		zap.String("domain", domain),
+		zap.String("strategy", string(pool.Strategy)))
+	return from[:at+1] + domain
+}
+
+// eligibleSendingDomains returns the pooled domains that can send for
+// primary: verified ones with a DKIM key that are primary itself or one of
+// its subdomains, so the signature aligns with the From header
+func eligibleSendingDomains(pool []models.SendingDomain, primary string) []models.SendingDomain {
+	var eligible []models.SendingDomain
+	for _, d := range pool {
+		name := strings.ToLower(d.Name)
+		if !d.Eligible || (name != primary && !strings.HasSuffix(name, "."+primary)) {
+			continue
+		}
+		d.Name = name
+		eligible = append(eligible, d)
+	}
+	return eligible
+}
+
+// pickSendingDomain picks one of domains, which must not be empty. n is the
+// pool's send counter. Weighted pools give each domain its weight's share of
+// every cycle through the counter; sticky pools always give a recipient
+// domain the same sending domain, and move few recipient domains when the
+// pool changes.
+func pickSendingDomain(strategy models.SendingStrategy, domains []models.SendingDomain, n uint64, rcptDomain string) string {
+	switch strategy {
+	case models.SendingStrategyWeighted:
+		var total uint64
+		for _, d := range domains {
+			total += uint64(max(d.Weight, 1))
+		}
+		slot := n % total
+		for _, d := range domains {
+			weight := uint64(max(d.Weight, 1))
+			if slot < weight {
+				return d.Name
+			}
+			slot -= weight
+		}
+
+	case models.SendingStrategySticky:
+		// Weighted rendezvous hashing
+		best, bestScore := domains[0].Name, math.Inf(-1)
+		for _, d := range domains {
+			h := fnv.New64a()
+			h.Write([]byte(rcptDomain))
+			h.Write([]byte{0})
+			h.Write([]byte(d.Name))
+			u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+			if score := -float64(max(d.Weight, 1)) / math.Log(u); score > bestScore {
+				best, bestScore = d.Name, score
+			}
+		}
+		return best
+	}
+
+	return domains[n%uint64(len(domains))].Name
+}
+
+// recipientDomain returns the lowercased domain of an address
+func recipientDomain(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return strings.ToLower(address[at+1:])
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"transactional-api/models"
+)
+
+func TestEligibleSendingDomains(t *testing.T) {
+	pool := []models.SendingDomain{
+		{Name: "m1.example.com", Weight: 1, Eligible: true},
+		{Name: "M2.Example.com", Weight: 1, Eligible: true},
+		{Name: "m3.example.com", Weight: 1, Eligible: false}, // Unverified or no DKIM key
+		{Name: "example.com", Weight: 1, Eligible: true},
+		{Name: "m1.other.com", Weight: 1, Eligible: true},   // Wouldn't align with From
+		{Name: "badexample.com", Weight: 1, Eligible: true}, // Not a subdomain
+	}
+
+	got := eligibleSendingDomains(pool, "example.com")
+	var names []string
+	for _, d := range got {
+		names = append(names, d.Name)
+	}
+	if want := "[m1.example.com m2.example.com example.com]"; fmt.Sprint(names) != want {
+		t.Errorf("eligibleSendingDomains() = %v, want %s", names, want)
+	}
+
+	if got := eligibleSendingDomains(pool[2:3], "example.com"); len(got) != 0 {
+		t.Errorf("eligibleSendingDomains() = %v, want none for an unverified pool", got)
+	}
+}
+
+func TestPickSendingDomainRoundRobin(t *testing.T) {
+	domains := []models.SendingDomain{{Name: "a.example.com"}, {Name: "b.example.com"}, {Name: "c.example.com"}}
+
+	var got []string
+	for n := uint64(1); n <= 4; n++ {
+		got = append(got, pickSendingDomain(models.SendingStrategyRoundRobin, domains, n, ""))
+	}
+	if want := "[b.example.com c.example.com a.example.com b.example.com]"; fmt.Sprint(got) != want {
+		t.Errorf("round robin = %v, want %s", got, want)
+	}
+}
+
+func TestPickSendingDomainWeighted(t *testing.T) {
+	domains := []models.SendingDomain{{Name: "a.example.com", Weight: 3}, {Name: "b.example.com", Weight: 1}}
+
+	counts := map[string]int{}
+	for n := uint64(0); n < 400; n++ {
+		counts[pickSendingDomain(models.SendingStrategyWeighted, domains, n, "")]++
+	}
+	if counts["a.example.com"] != 300 || counts["b.example.com"] != 100 {
+		t.Errorf("weighted counts = %v, want 300 and 100", counts)
+	}
+}
+
+func TestPickSendingDomainSticky(t *testing.T) {
+	domains := []models.SendingDomain{
+		{Name: "a.example.com", Weight: 1},
+		{Name: "b.example.com", Weight: 1},
+		{Name: "c.example.com", Weight: 1},
+	}
+
+	picked := map[string]string{}
+	used := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		rcpt := fmt.Sprintf("rcpt%d.test", i)
+		first := pickSendingDomain(models.SendingStrategySticky, domains, uint64(i), rcpt)
+		if again := pickSendingDomain(models.SendingStrategySticky, domains, uint64(i+7), rcpt); again != first {
+			t.Fatalf("sticky pick for %s = %s then %s, want the same", rcpt, first, again)
+		}
+		picked[rcpt] = first
+		used[first] = true
+	}
+	if len(used) != len(domains) {
+		t.Errorf("sticky picks used %d domains, want all %d", len(used), len(domains))
+	}
+
+	// Dropping a domain only moves the recipient domains that were on it
+	for rcpt, domain := range picked {
+		got := pickSendingDomain(models.SendingStrategySticky, domains[:2], 0, rcpt)
+		if domain != "c.example.com" && got != domain {
+			t.Errorf("%s moved from %s to %s when c.example.com left the pool", rcpt, domain, got)
+		}
+	}
+}
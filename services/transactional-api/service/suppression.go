@@ -28,7 +28,7 @@ logger: logger,
 func (s *SuppressionService) Add(ctx context.Context, domainID uuid.UUID, req *models.CreateSuppressionRequest, createdBy *uuid.UUID) (*models.Suppression, error) {
 suppressionType := reasonToType(req.Reason)
 
-if err := s.repo.Add(ctx, domainID, req.Email, suppressionType, string(req.Reason)); err != nil {
+if err := s.repo.Add(ctx, domainID, req.Email, suppressionType, string(req.Reason), models.SuppressionSourceAPI); err != nil {
 return nil, err
 }
 
@@ -160,7 +160,7 @@ HasMore:      int64(query.Offset+query.Limit) < total,
 
 // Remove removes an email from the suppression list
 func (s *SuppressionService) Remove(ctx context.Context, domainID uuid.UUID, email string, suppressionType models.SuppressionType) error {
-if err := s.repo.Remove(ctx, domainID, email, suppressionType); err != nil {
+if err := s.repo.Remove(ctx, domainID, email, suppressionType, uuid.Nil); err != nil {
 return err
 }
 
@@ -203,7 +203,7 @@ return stats, nil
 func (s *SuppressionService) ProcessBounce(ctx context.Context, domainID uuid.UUID, email, bounceType, bounceCode, smtpResponse string, messageID *uuid.UUID) error {
 reason := "bounce: " + bounceType + " " + smtpResponse
 
-if err := s.repo.Add(ctx, domainID, email, "bounce", reason); err != nil {
+if err := s.repo.Add(ctx, domainID, email, "bounce", reason, models.SuppressionSourceBounce); err != nil {
 return err
 }
 
@@ -217,7 +217,7 @@ return nil
 
 // ProcessSpamComplaint processes a spam complaint and adds to suppression
 func (s *SuppressionService) ProcessSpamComplaint(ctx context.Context, domainID uuid.UUID, email string, messageID *uuid.UUID) error {
-if err := s.repo.Add(ctx, domainID, email, "spam_complaint", "spam complaint"); err != nil {
+if err := s.repo.Add(ctx, domainID, email, "spam_complaint", "spam complaint", models.SuppressionSourceComplaint); err != nil {
 return err
 }
 
@@ -230,7 +230,7 @@ return nil
 
 // ProcessUnsubscribe processes an unsubscribe request
 func (s *SuppressionService) ProcessUnsubscribe(ctx context.Context, domainID uuid.UUID, email string, messageID *uuid.UUID) error {
-if err := s.repo.Add(ctx, domainID, email, "unsubscribe", "user unsubscribed"); err != nil {
+if err := s.repo.Add(ctx, domainID, email, "unsubscribe", "user unsubscribed", models.SuppressionSourceUnsubscribeLink); err != nil {
 return err
 }
 
@@ -3,6 +3,7 @@ package service
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"regexp"
@@ -14,6 +15,9 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// ErrMissingTemplateVariable is returned when a required template variable has no value
+var ErrMissingTemplateVariable = errors.New("missing required template variable")
+
 // TemplateService handles email template business logic
 type TemplateService struct {
 	repo   *repository.TemplateRepository
@@ -28,8 +32,8 @@ func NewTemplateService(repo *repository.TemplateRepository, logger zerolog.Logg
 	}
 }
 
-// Create creates a new email template
-func (s *TemplateService) Create(ctx context.Context, domainID uuid.UUID, req *models.CreateTemplateRequest, createdBy uuid.UUID) (*models.Template, error) {
+// Create creates a new email template owned by the key in access
+func (s *TemplateService) Create(ctx context.Context, access models.TemplateAccess, req *models.CreateTemplateRequest, createdBy uuid.UUID) (*models.Template, error) {
 	// Validate template syntax
 	if err := s.validateTemplate(req.Subject, req.HTMLContent, req.TextContent); err != nil {
 		return nil, fmt.Errorf("template validation failed: %w", err)
@@ -41,7 +45,7 @@ func (s *TemplateService) Create(ctx context.Context, domainID uuid.UUID, req *m
 		variables = s.extractVariables(req.Subject, req.HTMLContent, req.TextContent)
 	}
 
-	result, err := s.repo.Create(ctx, domainID, req)
+	result, err := s.repo.Create(ctx, access, req)
 	if err != nil {
 		return nil, err
 	}
@@ -54,15 +58,15 @@ func (s *TemplateService) Create(ctx context.Context, domainID uuid.UUID, req *m
 	return result, nil
 }
 
-// Get retrieves a template by ID
-func (s *TemplateService) Get(ctx context.Context, id, domainID uuid.UUID) (*models.Template, error) {
-	return s.repo.GetByID(ctx, id, domainID)
+// Get retrieves a template by ID, if the key in access can use it
+func (s *TemplateService) Get(ctx context.Context, id uuid.UUID, access models.TemplateAccess) (*models.Template, error) {
+	return s.repo.GetByID(ctx, id, access)
 }
 
 // GetByName retrieves a template by name within a domain (not yet implemented in repo)
-func (s *TemplateService) GetByName(ctx context.Context, domainID uuid.UUID, name string) (*models.Template, error) {
+func (s *TemplateService) GetByName(ctx context.Context, access models.TemplateAccess, name string) (*models.Template, error) {
 	// Fallback: list and filter
-	templates, _, err := s.repo.List(ctx, domainID, 100, 0)
+	templates, _, err := s.repo.List(ctx, access, 100, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -74,9 +78,9 @@ func (s *TemplateService) GetByName(ctx context.Context, domainID uuid.UUID, nam
 	return nil, fmt.Errorf("template not found: %s", name)
 }
 
-// List retrieves templates with filtering
-func (s *TemplateService) List(ctx context.Context, domainID uuid.UUID, query *models.TemplateQuery) (*models.TemplateListResponse, error) {
-	query.DomainID = domainID
+// List retrieves the key's own, shared and system templates
+func (s *TemplateService) List(ctx context.Context, access models.TemplateAccess, query *models.TemplateQuery) (*models.TemplateListResponse, error) {
+	query.DomainID = access.OrganizationID
 	if query.Limit <= 0 {
 		query.Limit = 20
 	}
@@ -84,7 +88,7 @@ func (s *TemplateService) List(ctx context.Context, domainID uuid.UUID, query *m
 		query.Limit = 100
 	}
 
-	templates, total, err := s.repo.List(ctx, domainID, query.Limit, query.Offset)
+	templates, total, err := s.repo.List(ctx, access, query.Limit, query.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +108,7 @@ func (s *TemplateService) List(ctx context.Context, domainID uuid.UUID, query *m
 }
 
 // Update updates an existing template
-func (s *TemplateService) Update(ctx context.Context, id, domainID uuid.UUID, req *models.UpdateTemplateRequest, updatedBy uuid.UUID) error {
+func (s *TemplateService) Update(ctx context.Context, id uuid.UUID, access models.TemplateAccess, req *models.UpdateTemplateRequest, updatedBy uuid.UUID) error {
 	// Validate template syntax if content changed
 	subject := ""
 	html := ""
@@ -125,7 +129,7 @@ func (s *TemplateService) Update(ctx context.Context, id, domainID uuid.UUID, re
 		}
 	}
 
-	_, err := s.repo.Update(ctx, id, domainID, req)
+	_, err := s.repo.Update(ctx, id, access, req)
 	if err != nil {
 		return err
 	}
@@ -137,9 +141,9 @@ func (s *TemplateService) Update(ctx context.Context, id, domainID uuid.UUID, re
 	return nil
 }
 
-// Delete soft-deletes a template
-func (s *TemplateService) Delete(ctx context.Context, id, domainID uuid.UUID) error {
-	if err := s.repo.Delete(ctx, id, domainID); err != nil {
+// Delete deletes a template; shared templates are soft-deleted
+func (s *TemplateService) Delete(ctx context.Context, id uuid.UUID, access models.TemplateAccess) error {
+	if err := s.repo.Delete(ctx, id, access); err != nil {
 		return err
 	}
 
@@ -151,12 +155,17 @@ func (s *TemplateService) Delete(ctx context.Context, id, domainID uuid.UUID) er
 }
 
 // Render renders a template with the provided substitutions
-func (s *TemplateService) Render(ctx context.Context, templateID, domainID uuid.UUID, substitutions map[string]any) (*models.RenderTemplateResponse, error) {
-	tmpl, err := s.repo.GetByID(ctx, templateID, domainID)
+func (s *TemplateService) Render(ctx context.Context, templateID uuid.UUID, access models.TemplateAccess, substitutions map[string]any) (*models.RenderTemplateResponse, error) {
+	tmpl, err := s.repo.GetByID(ctx, templateID, access)
 	if err != nil {
 		return nil, err
 	}
 
+	return s.RenderTemplate(tmpl, substitutions)
+}
+
+// RenderTemplate renders an already loaded template with the provided substitutions
+func (s *TemplateService) RenderTemplate(tmpl *models.Template, substitutions map[string]any) (*models.RenderTemplateResponse, error) {
 	// Apply default values for missing variables
 	data := make(map[string]any)
 	for _, v := range tmpl.Variables {
@@ -192,11 +201,34 @@ func (s *TemplateService) Render(ctx context.Context, templateID, domainID uuid.
 		}
 	}
 
-	return &models.RenderTemplateResponse{
+	return withGeneratedText(&models.RenderTemplateResponse{
 		Subject: subject,
 		HTML:    html,
 		Text:    text,
-	}, nil
+	}), nil
+}
+
+// withGeneratedText fills in the text of a rendered HTML-only template,
+// converted from the rendered HTML so substituted values are unescaped
+func withGeneratedText(rendered *models.RenderTemplateResponse) *models.RenderTemplateResponse {
+	if rendered.Text == "" && rendered.HTML != "" {
+		rendered.Text = HTMLToText(rendered.HTML)
+		rendered.TextGenerated = true
+	}
+	return rendered
+}
+
+// CheckRequiredVariables verifies that every required variable without a default has a value
+func (s *TemplateService) CheckRequiredVariables(tmpl *models.Template, substitutions map[string]any) error {
+	for _, v := range tmpl.Variables {
+		if !v.Required || v.DefaultValue != nil {
+			continue
+		}
+		if _, ok := substitutions[v.Name]; !ok {
+			return fmt.Errorf("%w: %s", ErrMissingTemplateVariable, v.Name)
+		}
+	}
+	return nil
 }
 
 // Preview renders a template preview without saving
@@ -225,11 +257,11 @@ func (s *TemplateService) Preview(ctx context.Context, subject, htmlContent, tex
 		}
 	}
 
-	return &models.RenderTemplateResponse{
+	return withGeneratedText(&models.RenderTemplateResponse{
 		Subject: renderedSubject,
 		HTML:    renderedHTML,
 		Text:    renderedText,
-	}, nil
+	}), nil
 }
 
 // Clone creates a copy of an existing template
@@ -328,8 +360,8 @@ func (s *TemplateService) extractVariables(subject, htmlContent, textContent str
 	return variables
 }
 
-// CreateDefaultTemplates creates default templates for a domain
-func (s *TemplateService) CreateDefaultTemplates(ctx context.Context, domainID, createdBy uuid.UUID) error {
+// CreateDefaultTemplates creates default templates for the key in access
+func (s *TemplateService) CreateDefaultTemplates(ctx context.Context, access models.TemplateAccess, createdBy uuid.UUID) error {
 	defaultTemplates := []models.CreateTemplateRequest{
 		{
 			Name:        "Welcome Email",
@@ -440,7 +472,7 @@ The {{company_name}} Team`,
 	}
 
 	for _, req := range defaultTemplates {
-		_, err := s.Create(ctx, domainID, &req, createdBy)
+		_, err := s.Create(ctx, access, &req, createdBy)
 		if err != nil {
 			// Ignore duplicate errors
 			if !strings.Contains(err.Error(), "already exists") {
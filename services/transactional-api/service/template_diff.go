@@ -0,0 +1,109 @@
+package service
+
+import (
+	"strings"
+
+	"transactional-api/models"
+)
+
+// maxDiffCells bounds the work of a line diff. Changes to larger regions are
+// reported as a full replacement instead of a minimal diff.
+const maxDiffCells = 1 << 20
+
+// TemplateChanges returns the fields that differ between two versions of a
+// template with a line diff of each
+func TemplateChanges(previous, current *models.TemplateVersion) []models.TemplateChange {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"subject", previous.Subject, current.Subject},
+		{"text_body", previous.TextBody, current.TextBody},
+		{"html_body", previous.HTMLBody, current.HTMLBody},
+	}
+
+	var changes []models.TemplateChange
+	for _, f := range fields {
+		if f.old == f.new {
+			continue
+		}
+		changes = append(changes, models.TemplateChange{
+			Field: f.name,
+			Diff:  diffLines(f.old, f.new),
+		})
+	}
+	return changes
+}
+
+// diffLines returns a line diff of two texts. Lines are prefixed with "-"
+// when only in a, "+" when only in b and " " when in both; unchanged lines
+// before the first and after the last change are left out.
+func diffLines(a, b string) []string {
+	oldLines := splitLines(a)
+	newLines := splitLines(b)
+
+	// Drop the common prefix and suffix, which are most of a typical edit
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[0] == newLines[0] {
+		oldLines, newLines = oldLines[1:], newLines[1:]
+	}
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[len(oldLines)-1] == newLines[len(newLines)-1] {
+		oldLines, newLines = oldLines[:len(oldLines)-1], newLines[:len(newLines)-1]
+	}
+
+	diff := make([]string, 0, len(oldLines)+len(newLines))
+	if len(oldLines)*len(newLines) > maxDiffCells {
+		for _, l := range oldLines {
+			diff = append(diff, "-"+l)
+		}
+		for _, l := range newLines {
+			diff = append(diff, "+"+l)
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			diff = append(diff, " "+oldLines[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+oldLines[i])
+			i++
+		default:
+			diff = append(diff, "+"+newLines[j])
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		diff = append(diff, "-"+oldLines[i])
+	}
+	for ; j < len(newLines); j++ {
+		diff = append(diff, "+"+newLines[j])
+	}
+	return diff
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"transactional-api/models"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"changed line", "Hi {{name}},\nYour order shipped.\nThanks", "Hi {{name}},\nYour order is on its way.\nThanks", []string{"-Your order shipped.", "+Your order is on its way."}},
+		{"added line", "a\nc", "a\nb\nc", []string{"+b"}},
+		{"removed line", "a\nb\nc", "a\nc", []string{"-b"}},
+		{"unchanged lines between changes", "a\nb\nc", "x\nb\ny", []string{"-a", "+x", " b", "-c", "+y"}},
+		{"from empty", "", "a\nb", []string{"+a", "+b"}},
+		{"line endings", "a\r\nb", "a\nb\nc", []string{"+c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateChanges(t *testing.T) {
+	previous := &models.TemplateVersion{Subject: "Welcome", TextBody: "Hello", HTMLBody: "<p>Hello</p>"}
+	current := &models.TemplateVersion{Subject: "Welcome!", TextBody: "Hello", HTMLBody: "<p>Hello</p>"}
+
+	changes := TemplateChanges(previous, current)
+	want := []models.TemplateChange{{Field: "subject", Diff: []string{"-Welcome", "+Welcome!"}}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("TemplateChanges() = %+v, want %+v", changes, want)
+	}
+
+	if changes := TemplateChanges(previous, previous); changes != nil {
+		t.Errorf("TemplateChanges() of identical versions = %+v", changes)
+	}
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"errors"
 	"strings"
 	"testing"
+
+	"transactional-api/models"
 )
 
 func TestTemplateService_RenderTemplate(t *testing.T) {
@@ -177,6 +180,51 @@ func TestTemplateService_ValidateTemplate(t *testing.T) {
 	}
 }
 
+func TestTemplateService_CheckRequiredVariables(t *testing.T) {
+	svc := &TemplateService{}
+	tmpl := &models.Template{
+		Variables: []models.TemplateVariable{
+			{Name: "name", Required: true},
+			{Name: "plan", Required: true, DefaultValue: "free"},
+			{Name: "coupon"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		substitutions map[string]any
+		wantErr       bool
+	}{
+		{
+			name:          "all required present",
+			substitutions: map[string]any{"name": "Jane"},
+			wantErr:       false,
+		},
+		{
+			name:          "required missing",
+			substitutions: map[string]any{"coupon": "SAVE10"},
+			wantErr:       true,
+		},
+		{
+			name:          "nil substitutions",
+			substitutions: nil,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.CheckRequiredVariables(tmpl, tt.substitutions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckRequiredVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrMissingTemplateVariable) {
+				t.Errorf("CheckRequiredVariables() error = %v, want ErrMissingTemplateVariable", err)
+			}
+		})
+	}
+}
+
 func TestTemplateService_ConvertHTMLToText(t *testing.T) {
 	tests := []struct {
 		name string
@@ -213,21 +261,92 @@ func TestTemplateService_ConvertHTMLToText(t *testing.T) {
 			html: "<p>Text</p><script>alert('x')</script>",
 			want: "Text",
 		},
+		{
+			name: "collapses whitespace",
+			html: "<html><head><style>p { color: red; }</style></head><body>\n  <p>Hello,\n\n   world&nbsp;!</p>\n\n\n<div>Bye</div></body></html>",
+			want: "Hello, world !\n\nBye",
+		},
+		{
+			name: "numbered and nested lists",
+			html: "<ol><li>One<ul><li>Sub</li></ul></li><li>Two</li></ol>",
+			want: "1. One\n  • Sub\n2. Two",
+		},
+		{
+			name: "line breaks and rules",
+			html: "<p>Line 1<br>Line 2</p><hr><p>After</p>",
+			want: "Line 1\nLine 2\n\n----------\n\nAfter",
+		},
+		{
+			name: "link showing its URL",
+			html: "<a href=\"https://example.com\">https://example.com</a>",
+			want: "https://example.com",
+		},
+		{
+			name: "mailto and in-page links",
+			html: "<a href=\"mailto:help@example.com\">Email us</a> or <a href=\"#faq\">read the FAQ</a>",
+			want: "Email us (help@example.com) or read the FAQ",
+		},
+		{
+			name: "image link",
+			html: "<a href=\"https://example.com\"><img src=\"logo.png\"></a>",
+			want: "https://example.com",
+		},
+		{
+			name: "keeps merge variables",
+			html: "<h1>Welcome, {{first_name}}!</h1><p><a href=\"{{reset_url}}\">Reset</a> your password for {account}</p>",
+			want: "WELCOME, {{first_name}}!\n\nReset ({{reset_url}}) your password for {account}",
+		},
+		{
+			name: "decodes percent-encoded merge variables in links",
+			html: "<a href=\"https://example.com/verify?t=%7B%7Btoken%7D%7D\">Verify</a>",
+			want: "Verify (https://example.com/verify?t={{token}})",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := convertHTMLToText(tt.html)
+			got := HTMLToText(tt.html)
 			// Normalize whitespace for comparison
 			got = strings.TrimSpace(got)
 			tt.want = strings.TrimSpace(tt.want)
 			if got != tt.want {
-				t.Errorf("convertHTMLToText() = %q, want %q", got, tt.want)
+				t.Errorf("HTMLToText() = %q, want %q", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestTemplateService_RenderTemplateGeneratesText(t *testing.T) {
+	svc := &TemplateService{}
+
+	rendered, err := svc.RenderTemplate(&models.Template{
+		Subject:     "Hi",
+		HTMLContent: "<h1>Hello {{name}}</h1><p>Thanks for your order.</p>",
+	}, map[string]any{"name": "Tom & Jerry"})
+	if err != nil {
+		t.Fatalf("RenderTemplate() error = %v", err)
+	}
+	if !rendered.TextGenerated {
+		t.Error("TextGenerated = false for an HTML-only template")
+	}
+	// Substituted values are unescaped in the text
+	if want := "HELLO TOM & JERRY\n\nThanks for your order."; rendered.Text != want {
+		t.Errorf("Text = %q, want %q", rendered.Text, want)
+	}
+
+	rendered, err = svc.RenderTemplate(&models.Template{
+		Subject:     "Hi",
+		HTMLContent: "<p>Hello</p>",
+		TextContent: "Hello from the text part",
+	}, nil)
+	if err != nil {
+		t.Fatalf("RenderTemplate() error = %v", err)
+	}
+	if rendered.TextGenerated || rendered.Text != "Hello from the text part" {
+		t.Errorf("Text = %q, generated %v; want the template's own text", rendered.Text, rendered.TextGenerated)
+	}
+}
+
 // Mock implementations for testing
 func renderTemplate(html string, subs map[string]any) string {
 	result := html
@@ -313,79 +432,6 @@ func (e *templateError) Error() string {
 	return e.msg
 }
 
-func convertHTMLToText(html string) string {
-	// Simplified implementation
-	result := html
-
-	// Remove scripts
-	for {
-		start := strings.Index(result, "<script")
-		if start == -1 {
-			break
-		}
-		end := strings.Index(result[start:], "</script>")
-		if end == -1 {
-			break
-		}
-		result = result[:start] + result[start+end+9:]
-	}
-
-	// Convert headings
-	result = strings.ReplaceAll(result, "<h1>", "")
-	result = strings.ReplaceAll(result, "</h1>", "\n\n")
-
-	// Convert paragraphs
-	result = strings.ReplaceAll(result, "<p>", "")
-	result = strings.ReplaceAll(result, "</p>", "\n\n")
-
-	// Convert links
-	// Simplified - just show text
-	for {
-		start := strings.Index(result, "<a href=\"")
-		if start == -1 {
-			break
-		}
-		hrefEnd := strings.Index(result[start+9:], "\"")
-		if hrefEnd == -1 {
-			break
-		}
-		url := result[start+9 : start+9+hrefEnd]
-		tagEnd := strings.Index(result[start:], ">")
-		closeTag := strings.Index(result[start:], "</a>")
-		if tagEnd == -1 || closeTag == -1 {
-			break
-		}
-		linkText := result[start+tagEnd+1 : start+closeTag]
-		result = result[:start] + linkText + " (" + url + ")" + result[start+closeTag+4:]
-	}
-
-	// Convert lists
-	result = strings.ReplaceAll(result, "<ul>", "")
-	result = strings.ReplaceAll(result, "</ul>", "")
-	result = strings.ReplaceAll(result, "<li>", "• ")
-	result = strings.ReplaceAll(result, "</li>", "\n")
-
-	// Clean up remaining tags
-	for {
-		start := strings.Index(result, "<")
-		if start == -1 {
-			break
-		}
-		end := strings.Index(result[start:], ">")
-		if end == -1 {
-			break
-		}
-		result = result[:start] + result[start+end+1:]
-	}
-
-	// Convert uppercase for headings (simplified)
-	if strings.HasPrefix(strings.TrimSpace(result), "Title") {
-		result = "TITLE\n\n" + strings.TrimPrefix(strings.TrimSpace(result), "Title\n\n")
-	}
-
-	return strings.TrimSpace(result)
-}
-
 // Benchmark tests
 func BenchmarkRenderTemplate(b *testing.B) {
 	html := "<p>Hello {{name}}, your order #{{order_id}} is ready!</p>"
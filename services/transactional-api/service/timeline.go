@@ -0,0 +1,69 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+
+	"transactional-api/models"
+)
+
+// eventStatus maps timeline events to the message status they imply
+// Engagement events prove delivery even if the delivery event is missing or late
+var eventStatus = map[models.EventType]models.MessageStatus{
+	models.EventTypeQueued:    models.MessageStatusQueued,
+	models.EventTypeSent:      models.MessageStatusSent,
+	models.EventTypeDeferred:  models.MessageStatusDeferred,
+	models.EventTypeDelivered: models.MessageStatusDelivered,
+	models.EventTypeBounced:   models.MessageStatusBounced,
+	models.EventTypeDropped:   models.MessageStatusDropped,
+	models.EventTypeOpened:    models.MessageStatusDelivered,
+	models.EventTypeClicked:   models.MessageStatusDelivered,
+}
+
+// BuildMessageTimeline orders the events of a message and derives its latest status,
+// both overall and per recipient
+func BuildMessageTimeline(messageID uuid.UUID, events []*models.EmailEvent) *models.MessageTimeline {
+	sorted := make([]*models.EmailEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	timeline := &models.MessageTimeline{
+		MessageID:  messageID,
+		Status:     models.MessageStatusQueued,
+		Recipients: make(map[string]models.MessageStatus),
+		Events:     make([]models.EventTimelineEntry, 0, len(sorted)),
+	}
+
+	for _, event := range sorted {
+		timeline.Events = append(timeline.Events, models.EventTimelineEntry{
+			EventType:    event.EventType,
+			Timestamp:    event.Timestamp,
+			Recipient:    event.Recipient,
+			Details:      event.BounceReason,
+			SMTPCode:     event.SMTPCode,
+			SMTPResponse: event.SMTPResponse,
+			BounceType:   event.BounceType,
+			BounceCode:   event.BounceCode,
+			RemoteMTA:    event.RemoteMTA,
+			URL:          event.URL,
+
+			BounceCategory: event.BounceCategory,
+		})
+
+		status, ok := eventStatus[event.EventType]
+		if !ok {
+			continue
+		}
+		// A late engagement event must not hide a bounce
+		if status == models.MessageStatusDelivered && timeline.Recipients[event.Recipient] == models.MessageStatusBounced {
+			continue
+		}
+		timeline.Recipients[event.Recipient] = status
+		timeline.Status = status
+	}
+
+	return timeline
+}
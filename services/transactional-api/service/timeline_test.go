@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"transactional-api/models"
+)
+
+func TestBuildMessageTimeline(t *testing.T) {
+	messageID := uuid.New()
+	base := time.Now()
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	events := []*models.EmailEvent{
+		{EventType: models.EventTypeOpened, Recipient: "a@example.com", Timestamp: at(5)},
+		{EventType: models.EventTypeQueued, Recipient: "a@example.com", Timestamp: at(0)},
+		{EventType: models.EventTypeQueued, Recipient: "b@example.com", Timestamp: at(0)},
+		{EventType: models.EventTypeSent, Recipient: "a@example.com", Timestamp: at(1)},
+		{EventType: models.EventTypeSent, Recipient: "b@example.com", Timestamp: at(1)},
+		{EventType: models.EventTypeBounced, Recipient: "b@example.com", Timestamp: at(2),
+			SMTPCode: 550, BounceCode: "5.1.1", BounceType: "hard", RemoteMTA: "mx.example.com"},
+		{EventType: models.EventTypeSpamReport, Recipient: "b@example.com", Timestamp: at(6)},
+	}
+
+	timeline := BuildMessageTimeline(messageID, events)
+
+	if len(timeline.Events) != len(events) {
+		t.Fatalf("got %d timeline entries, want %d", len(timeline.Events), len(events))
+	}
+	for i := 1; i < len(timeline.Events); i++ {
+		if timeline.Events[i].Timestamp.Before(timeline.Events[i-1].Timestamp) {
+			t.Fatalf("timeline not ordered at index %d", i)
+		}
+	}
+
+	if got := timeline.Recipients["a@example.com"]; got != models.MessageStatusDelivered {
+		t.Errorf("status of a@example.com = %s, want delivered", got)
+	}
+	if got := timeline.Recipients["b@example.com"]; got != models.MessageStatusBounced {
+		t.Errorf("status of b@example.com = %s, want bounced", got)
+	}
+	if timeline.Status != models.MessageStatusDelivered {
+		t.Errorf("overall status = %s, want delivered", timeline.Status)
+	}
+
+	for _, e := range timeline.Events {
+		if e.EventType == models.EventTypeBounced && (e.SMTPCode != 550 || e.RemoteMTA != "mx.example.com") {
+			t.Errorf("bounce entry lost SMTP details: %+v", e)
+		}
+	}
+}
+
+func TestBuildMessageTimeline_NoEvents(t *testing.T) {
+	timeline := BuildMessageTimeline(uuid.New(), nil)
+	if timeline.Status != models.MessageStatusQueued || len(timeline.Events) != 0 {
+		t.Errorf("unexpected empty timeline %+v", timeline)
+	}
+}
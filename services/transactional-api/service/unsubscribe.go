@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"transactional-api/config"
+	"transactional-api/models"
+	"transactional-api/repository"
+)
+
+// ErrInvalidUnsubscribeToken is returned for tokens that are malformed or
+// whose signature doesn't match
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+// unsubscribeURLPlaceholder may be used in bulk message bodies and templates
+// to place the recipient's unsubscribe link
+const unsubscribeURLPlaceholder = "{{unsubscribe_url}}"
+
+// unsubscribeMACSize is the length of the truncated HMAC-SHA256 signature
+const unsubscribeMACSize = 16
+
+// UnsubscribeToken identifies the message and recipient an unsubscribe
+// link was generated for
+type UnsubscribeToken struct {
+	OrganizationID uuid.UUID
+	MessageID      uuid.UUID
+	Recipient      string
+}
+
+// UnsubscribeTokens signs and verifies unsubscribe tokens. A token carries
+// the organization, message and recipient followed by an HMAC over them, so
+// links for other recipients can't be derived from one that is known.
+type UnsubscribeTokens struct {
+	secret  []byte
+	baseURL string
+}
+
+// NewUnsubscribeTokens creates a token signer from configuration
+func NewUnsubscribeTokens(cfg *config.UnsubscribeConfig) *UnsubscribeTokens {
+	return &UnsubscribeTokens{
+		secret:  []byte(cfg.SigningSecret),
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+	}
+}
+
+// Generate returns the token for one recipient of a message
+func (t *UnsubscribeTokens) Generate(orgID, messageID uuid.UUID, recipient string) string {
+	payload := make([]byte, 0, 32+len(recipient)+unsubscribeMACSize)
+	payload = append(payload, orgID[:]...)
+	payload = append(payload, messageID[:]...)
+	payload = append(payload, recipient...)
+	return base64.RawURLEncoding.EncodeToString(append(payload, t.sign(payload)...))
+}
+
+// Parse verifies a token and returns what it identifies
+func (t *UnsubscribeTokens) Parse(token string) (*UnsubscribeToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) <= 32+unsubscribeMACSize {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	payload, mac := data[:len(data)-unsubscribeMACSize], data[len(data)-unsubscribeMACSize:]
+	if !hmac.Equal(mac, t.sign(payload)) {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+
+	parsed := &UnsubscribeToken{Recipient: string(payload[32:])}
+	copy(parsed.OrganizationID[:], payload[:16])
+	copy(parsed.MessageID[:], payload[16:32])
+	return parsed, nil
+}
+
+// URL returns the unsubscribe link for one recipient of a message
+func (t *UnsubscribeTokens) URL(orgID, messageID uuid.UUID, recipient string) string {
+	return fmt.Sprintf("%s/v1/unsubscribe/%s", t.baseURL, t.Generate(orgID, messageID, recipient))
+}
+
+func (t *UnsubscribeTokens) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)[:unsubscribeMACSize]
+}
+
+// injectUnsubscribeLink places the unsubscribe URL in the message bodies.
+// The {{unsubscribe_url}} placeholder is replaced where present; otherwise
+// a footer with the link is added.
+func injectUnsubscribeLink(textBody, htmlBody, unsubscribeURL string) (string, string) {
+	if textBody != "" {
+		if strings.Contains(textBody, unsubscribeURLPlaceholder) {
+			textBody = strings.ReplaceAll(textBody, unsubscribeURLPlaceholder, unsubscribeURL)
+		} else {
+			textBody += "\r\n\r\nUnsubscribe: " + unsubscribeURL + "\r\n"
+		}
+	}
+
+	if htmlBody != "" {
+		escaped := html.EscapeString(unsubscribeURL)
+		if strings.Contains(htmlBody, unsubscribeURLPlaceholder) {
+			htmlBody = strings.ReplaceAll(htmlBody, unsubscribeURLPlaceholder, escaped)
+		} else {
+			footer := fmt.Sprintf(`<p style="font-size:12px;color:#666;text-align:center"><a href="%s">Unsubscribe</a></p>`, escaped)
+			if idx := strings.LastIndex(strings.ToLower(htmlBody), "</body>"); idx >= 0 {
+				htmlBody = htmlBody[:idx] + footer + htmlBody[idx:]
+			} else {
+				htmlBody += footer
+			}
+		}
+	}
+
+	return textBody, htmlBody
+}
+
+// UnsubscribeService handles unsubscribe requests from bulk message links
+type UnsubscribeService struct {
+	tokens          *UnsubscribeTokens
+	emailRepo       *repository.EmailRepository
+	suppressionRepo *repository.SuppressionRepository
+	webhookService  *WebhookService
+	logger          *zap.Logger
+}
+
+func NewUnsubscribeService(
+	tokens *UnsubscribeTokens,
+	emailRepo *repository.EmailRepository,
+	suppressionRepo *repository.SuppressionRepository,
+	webhookService *WebhookService,
+	logger *zap.Logger,
+) *UnsubscribeService {
+	return &UnsubscribeService{
+		tokens:          tokens,
+		emailRepo:       emailRepo,
+		suppressionRepo: suppressionRepo,
+		webhookService:  webhookService,
+		logger:          logger,
+	}
+}
+
+// ValidToken reports whether a token was signed by us
+func (s *UnsubscribeService) ValidToken(token string) bool {
+	_, err := s.tokens.Parse(token)
+	return err == nil
+}
+
+// Unsubscribe adds the token's recipient to the organization's unsubscribe
+// list and records an unsubscribed event. Repeating it is harmless.
+func (s *UnsubscribeService) Unsubscribe(ctx context.Context, token, source string) error {
+	parsed, err := s.tokens.Parse(token)
+	if err != nil {
+		return err
+	}
+
+	// The message must still exist and belong to the organization in the token
+	orgID, err := s.emailRepo.GetOrganizationID(ctx, parsed.MessageID)
+	if err != nil || orgID != parsed.OrganizationID {
+		return ErrInvalidUnsubscribeToken
+	}
+
+	suppressed, suppressionType, err := s.suppressionRepo.Exists(ctx, orgID, parsed.Recipient)
+	if err != nil {
+		return fmt.Errorf("check suppression: %w", err)
+	}
+	if suppressed && suppressionType == models.SuppressionUnsubscribe {
+		return nil
+	}
+
+	reason := fmt.Sprintf("unsubscribed via %s (message %s)", source, parsed.MessageID)
+	if err := s.suppressionRepo.Add(ctx, orgID, parsed.Recipient, models.SuppressionUnsubscribe, reason, models.SuppressionSourceUnsubscribeLink); err != nil {
+		return fmt.Errorf("add unsubscribe: %w", err)
+	}
+
+	event := &models.EmailEvent{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		MessageID:      parsed.MessageID,
+		EventType:      models.EventTypeUnsubscribed,
+		Recipient:      parsed.Recipient,
+		Timestamp:      time.Now(),
+		Metadata:       map[string]any{"source": source},
+	}
+	if err := s.webhookService.DispatchEvent(ctx, orgID, event); err != nil {
+		s.logger.Error("Failed to record unsubscribe event",
+			zap.String("message_id", parsed.MessageID.String()),
+			zap.Error(err))
+	}
+
+	s.logger.Info("Recipient unsubscribed",
+		zap.String("message_id", parsed.MessageID.String()),
+		zap.String("source", source))
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"transactional-api/config"
+)
+
+func TestUnsubscribeTokens(t *testing.T) {
+	tokens := NewUnsubscribeTokens(&config.UnsubscribeConfig{SigningSecret: "secret", BaseURL: "https://api.example.com/"})
+	orgID, messageID := uuid.New(), uuid.New()
+
+	token := tokens.Generate(orgID, messageID, "Bob@example.com")
+	parsed, err := tokens.Parse(token)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if parsed.OrganizationID != orgID || parsed.MessageID != messageID || parsed.Recipient != "Bob@example.com" {
+		t.Errorf("Parse() = %+v", parsed)
+	}
+
+	if got := tokens.URL(orgID, messageID, "bob@example.com"); !strings.HasPrefix(got, "https://api.example.com/v1/unsubscribe/") {
+		t.Errorf("URL() = %s", got)
+	}
+
+	// Tokens differ per recipient and can't be forged or altered
+	if tokens.Generate(orgID, messageID, "alice@example.com") == token {
+		t.Error("tokens for different recipients are equal")
+	}
+	other := NewUnsubscribeTokens(&config.UnsubscribeConfig{SigningSecret: "other"})
+	if _, err := other.Parse(token); err != ErrInvalidUnsubscribeToken {
+		t.Errorf("Parse() with another secret: err = %v", err)
+	}
+	tampered := []byte(token)
+	tampered[len(tampered)/2] ^= 1
+	for _, bad := range []string{string(tampered), "", "not-a-token", token[:20]} {
+		if _, err := tokens.Parse(bad); err != ErrInvalidUnsubscribeToken {
+			t.Errorf("Parse(%q): err = %v, want ErrInvalidUnsubscribeToken", bad, err)
+		}
+	}
+}
+
+func TestInjectUnsubscribeLink(t *testing.T) {
+	url := "https://api.example.com/v1/unsubscribe/abc?x=1&y=2"
+
+	text, html := injectUnsubscribeLink("Hi\r\nStop: {{unsubscribe_url}}", `<a href="{{unsubscribe_url}}">Stop</a>`, url)
+	if text != "Hi\r\nStop: "+url {
+		t.Errorf("text = %q", text)
+	}
+	if html != `<a href="https://api.example.com/v1/unsubscribe/abc?x=1&amp;y=2">Stop</a>` {
+		t.Errorf("html = %q", html)
+	}
+
+	// Without a placeholder a footer is added, inside <body>
+	text, html = injectUnsubscribeLink("Hi", "<html><body><p>Hi</p></BODY></html>", url)
+	if !strings.HasSuffix(text, "Unsubscribe: "+url+"\r\n") {
+		t.Errorf("text = %q", text)
+	}
+	if !strings.Contains(html, `Unsubscribe</a></p></BODY></html>`) {
+		t.Errorf("html = %q", html)
+	}
+
+	// Empty bodies stay empty
+	if text, html := injectUnsubscribeLink("", "", url); text != "" || html != "" {
+		t.Errorf("empty bodies changed: %q %q", text, html)
+	}
+}
@@ -7,23 +7,57 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
+	"transactional-api/config"
 	"transactional-api/models"
 	"transactional-api/repository"
 )
 
+// Signature headers sent with every delivery. During a secret rotation grace
+// period the payload is additionally signed with the previous secret.
+const (
+	HeaderWebhookSignature         = "X-Webhook-Signature"
+	HeaderWebhookSignaturePrevious = "X-Webhook-Signature-Previous"
+	HeaderWebhookReplay            = "X-Webhook-Replay"
+)
+
+var (
+	ErrWebhookInactive      = errors.New("webhook is not active")
+	ErrInvalidReplayWindow  = errors.New("replay window must end after it starts")
+	ErrReplayWindowTooLarge = errors.New("replay window is too large")
+	ErrTooManyReplayEvents  = errors.New("too many events to replay, narrow the window")
+)
+
+// maxRecordedResponseBody caps how much of a receiver's response is stored
+const maxRecordedResponseBody = 1024
+
+// retryJitter is the fraction a retry's delay is randomized by either way,
+// so deliveries that failed together aren't all retried at once
+const retryJitter = 0.1
+
+// retryBatchSize is how many due retries are claimed at a time
+const retryBatchSize = 100
+
+// NoticeSender emails notices to account owners
+type NoticeSender interface {
+	SendNotice(ctx context.Context, to, subject, body string) error
+}
+
 type WebhookService struct {
+	config      *config.WebhookConfig
 	webhookRepo *repository.WebhookRepository
 	eventRepo   *repository.EventRepository
-	redis       *redis.Client
+	notices     NoticeSender
 	logger      *zap.Logger
 	httpClient  *http.Client
 	dispatchCh  chan *webhookDispatch
@@ -32,20 +66,24 @@ type WebhookService struct {
 
 type webhookDispatch struct {
 	Webhook *models.Webhook
+	EventID uuid.UUID
 	Payload *models.WebhookPayload
 	Attempt int
+	Replay  bool // Manually replayed rather than dispatched when the event happened
 }
 
 func NewWebhookService(
+	cfg *config.WebhookConfig,
 	webhookRepo *repository.WebhookRepository,
 	eventRepo *repository.EventRepository,
-	redis *redis.Client,
+	notices NoticeSender,
 	logger *zap.Logger,
 ) *WebhookService {
 	return &WebhookService{
+		config:      cfg,
 		webhookRepo: webhookRepo,
 		eventRepo:   eventRepo,
-		redis:       redis,
+		notices:     notices,
 		logger:      logger,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
@@ -63,6 +101,9 @@ func (s *WebhookService) StartDispatcher(ctx context.Context) {
 
 	// Start retry processor
 	go s.retryProcessor(ctx)
+
+	// Start cleanup of rotated secrets
+	go s.secretCleanup(ctx)
 }
 
 func (s *WebhookService) dispatchWorker(ctx context.Context) {
@@ -79,7 +120,7 @@ func (s *WebhookService) dispatchWorker(ctx context.Context) {
 }
 
 func (s *WebhookService) retryProcessor(ctx context.Context) {
-	ticker := time.NewTicker(time.Minute)
+	ticker := time.NewTicker(15 * time.Second)
 	defer ticker.Stop()
 
 	for {
@@ -92,37 +133,87 @@ func (s *WebhookService) retryProcessor(ctx context.Context) {
 	}
 }
 
-func (s *WebhookService) processRetries(ctx context.Context) {
-	// Get failed webhooks from Redis and retry
-	keys, err := s.redis.Keys(ctx, "webhook:retry:*").Result()
-	if err != nil {
-		s.logger.Error("Failed to get retry keys", zap.Error(err))
-		return
+func (s *WebhookService) secretCleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleared, err := s.webhookRepo.ClearExpiredSecrets(ctx)
+			if err != nil {
+				s.logger.Error("Failed to clear expired webhook secrets", zap.Error(err))
+				continue
+			}
+			if cleared > 0 {
+				s.logger.Info("Cleared expired webhook secrets", zap.Int64("count", cleared))
+			}
+		}
 	}
+}
 
-	for _, key := range keys {
-		data, err := s.redis.Get(ctx, key).Bytes()
+// processRetries retries the failed deliveries whose retry is due
+func (s *WebhookService) processRetries(ctx context.Context) {
+	for {
+		due, err := s.webhookRepo.ClaimDueRetries(ctx, time.Now(), retryBatchSize)
 		if err != nil {
-			continue
+			s.logger.Error("Failed to claim webhook retries", zap.Error(err))
+			return
 		}
 
-		var dispatch webhookDispatch
-		if err := json.Unmarshal(data, &dispatch); err != nil {
-			s.redis.Del(ctx, key)
-			continue
+		for _, retry := range due {
+			if err := s.retry(ctx, retry); err != nil {
+				s.logger.Error("Failed to retry webhook delivery",
+					zap.String("delivery_id", retry.Delivery.ID.String()),
+					zap.Error(err))
+			}
 		}
 
-		// Check if max retries exceeded
-		if dispatch.Attempt >= 5 {
-			s.redis.Del(ctx, key)
-			continue
+		if len(due) < retryBatchSize {
+			return
 		}
+	}
+}
 
-		// Re-queue for delivery
-		dispatch.Attempt++
-		s.dispatchCh <- &dispatch
-		s.redis.Del(ctx, key)
+// retry re-sends the event of a failed delivery, unless the webhook has
+// since been disabled or its circuit opened, in which case the delivery is
+// dead-lettered
+func (s *WebhookService) retry(ctx context.Context, retry *repository.DueRetry) error {
+	d := retry.Delivery
+
+	webhook, err := s.webhookRepo.GetByID(ctx, d.WebhookID, retry.OrganizationID)
+	if err != nil {
+		return err
 	}
+	if !webhook.IsActive || s.circuitOpen(webhook, time.Now()) {
+		return s.webhookRepo.MarkDeliveryDead(ctx, d.ID)
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, d.EventID, retry.OrganizationID)
+	if errors.Is(err, repository.ErrEventNotFound) {
+		return s.webhookRepo.MarkDeliveryDead(ctx, d.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.enqueue(ctx, &webhookDispatch{
+		Webhook: webhook,
+		EventID: event.ID,
+		Payload: eventPayload(event),
+		Attempt: d.AttemptNumber + 1,
+		Replay:  d.Replay,
+	})
+}
+
+// circuitOpen reports whether a webhook has been failing for so long that
+// failed deliveries to it are no longer retried. Events are still sent to
+// it, and the first success closes the circuit.
+func (s *WebhookService) circuitOpen(webhook *models.Webhook, now time.Time) bool {
+	return webhook.FailingSince != nil &&
+		now.Sub(*webhook.FailingSince) >= time.Duration(s.config.CircuitBreakAfter)*time.Second
 }
 
 func (s *WebhookService) DispatchEvent(ctx context.Context, orgID uuid.UUID, event *models.EmailEvent) error {
@@ -138,6 +229,23 @@ func (s *WebhookService) DispatchEvent(ctx context.Context, orgID uuid.UUID, eve
 	}
 
 	// Create payload
+	payload := eventPayload(event)
+
+	// Queue for dispatch
+	for _, webhook := range webhooks {
+		s.dispatchCh <- &webhookDispatch{
+			Webhook: webhook,
+			EventID: event.ID,
+			Payload: payload,
+			Attempt: 1,
+		}
+	}
+
+	return nil
+}
+
+// eventPayload builds the webhook payload for a stored event
+func eventPayload(event *models.EmailEvent) *models.WebhookPayload {
 	payload := &models.WebhookPayload{
 		Event:     models.WebhookEventType(event.EventType),
 		Timestamp: event.Timestamp,
@@ -150,22 +258,130 @@ func (s *WebhookService) DispatchEvent(ctx context.Context, orgID uuid.UUID, eve
 
 	if event.BounceType != "" {
 		payload.BounceType = event.BounceType
+		payload.BounceCategory = string(event.BounceCategory)
+		payload.BounceCode = event.BounceCode
 		payload.Reason = event.BounceReason
 	}
 
-	// Queue for dispatch
-	for _, webhook := range webhooks {
-		s.dispatchCh <- &webhookDispatch{
+	return payload
+}
+
+// ReplayDelivery re-enqueues the event of a past delivery, whatever the
+// outcome of that delivery. Like any delivery it is sent to the webhook's
+// current URL and signed with its current secret.
+func (s *WebhookService) ReplayDelivery(ctx context.Context, orgID, webhookID, deliveryID uuid.UUID) error {
+	webhook, err := s.replayableWebhook(ctx, orgID, webhookID)
+	if err != nil {
+		return err
+	}
+
+	delivery, err := s.webhookRepo.GetDelivery(ctx, deliveryID, webhookID)
+	if err != nil {
+		return err
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, delivery.EventID, orgID)
+	if err != nil {
+		return err
+	}
+
+	return s.enqueue(ctx, &webhookDispatch{
+		Webhook: webhook,
+		EventID: event.ID,
+		Payload: eventPayload(event),
+		Attempt: 1,
+		Replay:  true,
+	})
+}
+
+// ReplayEvents re-enqueues every event in [since, until] that the webhook is
+// currently subscribed to. The window is capped by the configured maximum
+// duration and event count, and is rejected as a whole if it exceeds either.
+func (s *WebhookService) ReplayEvents(ctx context.Context, orgID, webhookID uuid.UUID, since, until time.Time) (int, error) {
+	if !until.After(since) {
+		return 0, ErrInvalidReplayWindow
+	}
+	if until.Sub(since) > time.Duration(s.config.MaxReplayWindow)*time.Second {
+		return 0, ErrReplayWindowTooLarge
+	}
+
+	webhook, err := s.replayableWebhook(ctx, orgID, webhookID)
+	if err != nil {
+		return 0, err
+	}
+
+	eventTypes := make([]string, len(webhook.Events))
+	for i, e := range webhook.Events {
+		eventTypes[i] = string(e)
+	}
+
+	// Fetch one more than the cap to tell whether the window exceeds it
+	events, err := s.eventRepo.ListForReplay(ctx, orgID, eventTypes, since, until, s.config.MaxReplayEvents+1)
+	if err != nil {
+		return 0, fmt.Errorf("list events: %w", err)
+	}
+	if len(events) > s.config.MaxReplayEvents {
+		return 0, ErrTooManyReplayEvents
+	}
+
+	for i, event := range events {
+		if err := s.enqueue(ctx, &webhookDispatch{
 			Webhook: webhook,
-			Payload: payload,
+			EventID: event.ID,
+			Payload: eventPayload(event),
 			Attempt: 1,
+			Replay:  true,
+		}); err != nil {
+			return i, err
 		}
 	}
 
-	return nil
+	s.logger.Info("Webhook events replayed",
+		zap.String("webhook_id", webhookID.String()),
+		zap.Time("since", since),
+		zap.Time("until", until),
+		zap.Int("count", len(events)))
+
+	return len(events), nil
+}
+
+func (s *WebhookService) replayableWebhook(ctx context.Context, orgID, webhookID uuid.UUID) (*models.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, webhookID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !webhook.IsActive {
+		return nil, ErrWebhookInactive
+	}
+	return webhook, nil
+}
+
+// enqueue queues a dispatch, giving up if ctx ends while the queue is full
+func (s *WebhookService) enqueue(ctx context.Context, dispatch *webhookDispatch) error {
+	select {
+	case s.dispatchCh <- dispatch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (s *WebhookService) deliverWebhook(ctx context.Context, dispatch *webhookDispatch) {
+	// Reload the webhook so deliveries queued before a secret rotation, and
+	// retries (which do not persist secrets), are signed with current secrets
+	webhook, err := s.webhookRepo.GetByID(ctx, dispatch.Webhook.ID, dispatch.Webhook.OrganizationID)
+	if err != nil {
+		s.logger.Error("Failed to load webhook for delivery",
+			zap.String("webhook_id", dispatch.Webhook.ID.String()),
+			zap.Error(err))
+		return
+	}
+	if !webhook.IsActive {
+		// Disabled since the delivery was queued
+		return
+	}
+	dispatch.Webhook = webhook
+
 	// Build request body
 	body, err := json.Marshal(dispatch.Payload)
 	if err != nil {
@@ -185,59 +401,233 @@ func (s *WebhookService) deliverWebhook(ctx context.Context, dispatch *webhookDi
 	req.Header.Set("User-Agent", "OONRUMAIL-Webhooks/1.0")
 	req.Header.Set("X-Webhook-ID", dispatch.Webhook.ID.String())
 	req.Header.Set("X-Webhook-Timestamp", fmt.Sprintf("%d", time.Now().Unix()))
+	if dispatch.Replay {
+		req.Header.Set(HeaderWebhookReplay, "true")
+	}
 
 	// Sign the payload
-	signature := s.signPayload(body, dispatch.Webhook.Secret)
-	req.Header.Set("X-Webhook-Signature", signature)
+	s.setSignatureHeaders(req, dispatch.Webhook, body, time.Now())
 
 	// Send request
+	start := time.Now()
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		s.handleDeliveryFailure(ctx, dispatch, err)
+		s.finishDelivery(ctx, dispatch, body, start, 0, "", err)
 		return
 	}
 	defer resp.Body.Close()
 
-	// Check response
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		// Success
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxRecordedResponseBody))
+	var deliveryErr error
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		deliveryErr = fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	s.finishDelivery(ctx, dispatch, body, start, resp.StatusCode, string(respBody), deliveryErr)
+}
+
+// finishDelivery stores a delivery attempt, so it can be inspected and
+// replayed, with what happens next: a success closes the webhook's circuit,
+// a failure is retried on schedule or dead-lettered
+func (s *WebhookService) finishDelivery(ctx context.Context, dispatch *webhookDispatch, body []byte, start time.Time, code int, respBody string, deliveryErr error) {
+	delivery := &models.WebhookDelivery{
+		ID:            uuid.New(),
+		WebhookID:     dispatch.Webhook.ID,
+		EventID:       dispatch.EventID,
+		Event:         dispatch.Payload.Event,
+		URL:           dispatch.Webhook.URL,
+		RequestBody:   string(body),
+		ResponseCode:  code,
+		ResponseBody:  respBody,
+		Success:       deliveryErr == nil,
+		Status:        models.WebhookDeliverySucceeded,
+		AttemptNumber: dispatch.Attempt,
+		Replay:        dispatch.Replay,
+		Duration:      time.Since(start),
+		CreatedAt:     start,
+	}
+	if deliveryErr == nil {
 		s.webhookRepo.ResetFailureCount(ctx, dispatch.Webhook.ID)
 		s.logger.Debug("Webhook delivered successfully",
 			zap.String("webhook_id", dispatch.Webhook.ID.String()),
 			zap.String("event", string(dispatch.Payload.Event)))
 	} else {
-		// HTTP error
-		s.handleDeliveryFailure(ctx, dispatch, fmt.Errorf("HTTP %d", resp.StatusCode))
+		delivery.Error = deliveryErr.Error()
+		delivery.Status, delivery.NextRetryAt = s.handleDeliveryFailure(ctx, dispatch, deliveryErr)
+	}
+
+	if err := s.webhookRepo.CreateDelivery(ctx, delivery); err != nil {
+		s.logger.Error("Failed to record webhook delivery",
+			zap.String("webhook_id", dispatch.Webhook.ID.String()),
+			zap.Error(err))
 	}
 }
 
-func (s *WebhookService) handleDeliveryFailure(ctx context.Context, dispatch *webhookDispatch, err error) {
+// handleDeliveryFailure records a failed delivery against the webhook and
+// returns the attempt's status, with when to retry it if it is to be.
+// Deliveries to an endpoint that has been failing longer than the circuit
+// breaker allows are dead-lettered, and after longer still the webhook is
+// disabled.
+func (s *WebhookService) handleDeliveryFailure(ctx context.Context, dispatch *webhookDispatch, err error) (models.WebhookDeliveryStatus, *time.Time) {
 	s.logger.Warn("Webhook delivery failed",
 		zap.String("webhook_id", dispatch.Webhook.ID.String()),
 		zap.Int("attempt", dispatch.Attempt),
 		zap.Error(err))
 
-	s.webhookRepo.IncrementFailureCount(ctx, dispatch.Webhook.ID)
+	now := time.Now()
+	failingSince, ferr := s.webhookRepo.IncrementFailureCount(ctx, dispatch.Webhook.ID)
+	if ferr != nil {
+		s.logger.Error("Failed to record webhook failure",
+			zap.String("webhook_id", dispatch.Webhook.ID.String()),
+			zap.Error(ferr))
+		failingSince = now
+	}
 
-	// Schedule retry if under max attempts
-	if dispatch.Attempt < 5 {
-		retryKey := fmt.Sprintf("webhook:retry:%s:%s", dispatch.Webhook.ID, dispatch.Payload.MessageID)
-		data, _ := json.Marshal(dispatch)
+	failingFor := now.Sub(failingSince)
+	if failingFor >= time.Duration(s.config.DisableAfter)*time.Second {
+		s.disable(ctx, dispatch.Webhook, failingSince, err)
+		return models.WebhookDeliveryDead, nil
+	}
 
-		// Exponential backoff: 1min, 5min, 15min, 30min, 1hr
-		delays := []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour}
-		delay := delays[dispatch.Attempt-1]
+	return s.nextRetry(dispatch.Attempt, failingFor, now)
+}
 
-		s.redis.Set(ctx, retryKey, data, delay)
+// nextRetry decides what follows failed attempt number attempt: a retry
+// after the scheduled delay, give or take retryJitter, or the dead-letter
+// state once the retries are used up or the circuit is open
+func (s *WebhookService) nextRetry(attempt int, failingFor time.Duration, now time.Time) (models.WebhookDeliveryStatus, *time.Time) {
+	if attempt > s.config.MaxRetries || failingFor >= time.Duration(s.config.CircuitBreakAfter)*time.Second {
+		return models.WebhookDeliveryDead, nil
 	}
+
+	delay := s.retryDelay(attempt)
+	delay += time.Duration((rand.Float64()*2 - 1) * retryJitter * float64(delay))
+	at := now.Add(delay)
+	return models.WebhookDeliveryRetrying, &at
+}
+
+// retryDelay is the scheduled wait before retrying failed attempt number
+// attempt. Past the end of the schedule the last delay repeats.
+func (s *WebhookService) retryDelay(attempt int) time.Duration {
+	schedule := s.config.RetrySchedule
+	i := attempt - 1
+	if i >= len(schedule) {
+		i = len(schedule) - 1
+	}
+	return time.Duration(schedule[i]) * time.Second
+}
+
+// disable deactivates a webhook that has been failing too long, moves its
+// deliveries awaiting a retry to the dead-letter state and tells the
+// organization's owner
+func (s *WebhookService) disable(ctx context.Context, webhook *models.Webhook, failingSince time.Time, lastErr error) {
+	reason := fmt.Sprintf("every delivery failed since %s, last with: %v", failingSince.UTC().Format(time.RFC3339), lastErr)
+
+	disabled, err := s.webhookRepo.Disable(ctx, webhook.ID, reason)
+	if err != nil {
+		s.logger.Error("Failed to disable webhook", zap.String("webhook_id", webhook.ID.String()), zap.Error(err))
+		return
+	}
+	if !disabled {
+		return
+	}
+
+	dead, err := s.webhookRepo.DeadLetterPending(ctx, webhook.ID)
+	if err != nil {
+		s.logger.Error("Failed to dead-letter pending webhook deliveries", zap.String("webhook_id", webhook.ID.String()), zap.Error(err))
+	}
+
+	s.logger.Warn("Webhook disabled after failing too long",
+		zap.String("webhook_id", webhook.ID.String()),
+		zap.Time("failing_since", failingSince),
+		zap.Int64("dead_lettered", dead))
+
+	s.notifyDisabled(ctx, webhook, failingSince, lastErr)
+}
+
+func (s *WebhookService) notifyDisabled(ctx context.Context, webhook *models.Webhook, failingSince time.Time, lastErr error) {
+	if s.notices == nil {
+		return
+	}
+
+	to, err := s.webhookRepo.GetOwnerEmail(ctx, webhook.OrganizationID)
+	if err != nil || to == "" {
+		s.logger.Warn("No owner to notify of disabled webhook",
+			zap.String("webhook_id", webhook.ID.String()),
+			zap.Error(err))
+		return
+	}
+
+	subject, body := webhookDisabledNotice(webhook, failingSince, lastErr)
+	if err := s.notices.SendNotice(ctx, to, subject, body); err != nil {
+		s.logger.Error("Failed to notify owner of disabled webhook",
+			zap.String("webhook_id", webhook.ID.String()),
+			zap.Error(err))
+	}
+}
+
+// webhookDisabledNotice is the notice sent to an organization's owner when
+// one of its webhooks is disabled
+func webhookDisabledNotice(webhook *models.Webhook, failingSince time.Time, lastErr error) (string, string) {
+	subject := "Your webhook has been disabled"
+	body := fmt.Sprintf(`Your webhook %s (%s) has been disabled because every delivery to it has failed since %s.
+
+Last error: %v
+
+Events are no longer sent to it. Deliveries still waiting to be retried were moved to the dead-letter queue:
+
+    GET /v1/webhooks/%s/deliveries?status=dead
+
+Once the endpoint is fixed, re-enable the webhook by setting "is_active": true with PUT /v1/webhooks/%s,
+then replay what it missed with POST /v1/webhooks/%s/replay.
+`,
+		webhook.URL, webhook.ID, failingSince.UTC().Format("Mon, 02 Jan 2006 15:04 MST"), lastErr,
+		webhook.ID, webhook.ID, webhook.ID)
+	return subject, body
 }
 
 func (s *WebhookService) signPayload(payload []byte, secret string) string {
+	return signWebhookPayload(payload, secret)
+}
+
+// signWebhookPayload returns the X-Webhook-Signature value for a payload,
+// shared by event webhooks and inbound parse posts
+func signWebhookPayload(payload []byte, secret string) string {
 	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(payload)
 	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
 }
 
+// setSignatureHeaders signs the body with the current secret and, while its
+// grace period lasts, with the previous secret so receivers can verify either
+func (s *WebhookService) setSignatureHeaders(req *http.Request, webhook *models.Webhook, body []byte, now time.Time) {
+	req.Header.Set(HeaderWebhookSignature, s.signPayload(body, webhook.Secret))
+
+	if webhook.PreviousSecret != "" && webhook.PreviousSecretExpiresAt != nil && now.Before(*webhook.PreviousSecretExpiresAt) {
+		req.Header.Set(HeaderWebhookSignaturePrevious, s.signPayload(body, webhook.PreviousSecret))
+	}
+}
+
+// RotateSecret issues a new signing secret. The old secret keeps being used
+// for a second signature until the configured grace period ends.
+func (s *WebhookService) RotateSecret(ctx context.Context, id, orgID uuid.UUID) (*models.RotateWebhookSecretResponse, error) {
+	expiresAt := time.Now().Add(time.Duration(s.config.SecretGracePeriod) * time.Second)
+
+	webhook, err := s.webhookRepo.RotateSecret(ctx, id, orgID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Webhook secret rotated",
+		zap.String("webhook_id", id.String()),
+		zap.Time("previous_secret_expires_at", expiresAt))
+
+	return &models.RotateWebhookSecretResponse{
+		Secret:                  webhook.Secret,
+		SecretPrefix:            webhook.Secret[:8],
+		PreviousSecretExpiresAt: expiresAt,
+	}, nil
+}
+
 func (s *WebhookService) TestWebhook(ctx context.Context, webhook *models.Webhook) error {
 	testPayload := &models.WebhookPayload{
 		Event:     "test",
@@ -258,7 +648,7 @@ func (s *WebhookService) TestWebhook(ctx context.Context, webhook *models.Webhoo
 	req.Header.Set("User-Agent", "OONRUMAIL-Webhooks/1.0")
 	req.Header.Set("X-Webhook-ID", webhook.ID.String())
 	req.Header.Set("X-Webhook-Timestamp", fmt.Sprintf("%d", time.Now().Unix()))
-	req.Header.Set("X-Webhook-Signature", s.signPayload(body, webhook.Secret))
+	s.setSignatureHeaders(req, webhook, body, time.Now())
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"transactional-api/config"
+	"transactional-api/models"
+)
+
+func TestWebhookService_SetSignatureHeaders(t *testing.T) {
+	s := &WebhookService{}
+	body := []byte(`{"event":"delivered"}`)
+	now := time.Now()
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name         string
+		webhook      *models.Webhook
+		wantPrevious bool
+	}{
+		{
+			name:    "no rotation",
+			webhook: &models.Webhook{Secret: "new-secret"},
+		},
+		{
+			name:         "within grace period",
+			webhook:      &models.Webhook{Secret: "new-secret", PreviousSecret: "old-secret", PreviousSecretExpiresAt: &future},
+			wantPrevious: true,
+		},
+		{
+			name:    "grace period expired",
+			webhook: &models.Webhook{Secret: "new-secret", PreviousSecret: "old-secret", PreviousSecretExpiresAt: &past},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+			s.setSignatureHeaders(req, tt.webhook, body, now)
+
+			if got, want := req.Header.Get(HeaderWebhookSignature), s.signPayload(body, "new-secret"); got != want {
+				t.Errorf("%s = %q, want %q", HeaderWebhookSignature, got, want)
+			}
+
+			previous := req.Header.Get(HeaderWebhookSignaturePrevious)
+			if !tt.wantPrevious {
+				if previous != "" {
+					t.Errorf("unexpected %s header %q", HeaderWebhookSignaturePrevious, previous)
+				}
+				return
+			}
+			if want := s.signPayload(body, "old-secret"); previous != want {
+				t.Errorf("%s = %q, want %q", HeaderWebhookSignaturePrevious, previous, want)
+			}
+		})
+	}
+}
+
+func TestWebhookService_NextRetry(t *testing.T) {
+	s := &WebhookService{config: &config.WebhookConfig{
+		MaxRetries:        5,
+		RetrySchedule:     []int{60, 300, 1800, 7200, 21600},
+		CircuitBreakAfter: 43200,
+	}}
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		attempt    int
+		failingFor time.Duration
+		wantDelay  time.Duration // Zero when dead-lettered
+	}{
+		{name: "first failure", attempt: 1, wantDelay: time.Minute},
+		{name: "second failure", attempt: 2, failingFor: time.Minute, wantDelay: 5 * time.Minute},
+		{name: "last retry", attempt: 5, failingFor: 3 * time.Hour, wantDelay: 6 * time.Hour},
+		{name: "retries used up", attempt: 6, failingFor: 9 * time.Hour},
+		{name: "circuit open", attempt: 1, failingFor: 12 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, at := s.nextRetry(tt.attempt, tt.failingFor, now)
+
+			if tt.wantDelay == 0 {
+				if status != models.WebhookDeliveryDead || at != nil {
+					t.Errorf("nextRetry() = %s, %v; want dead with no retry", status, at)
+				}
+				return
+			}
+
+			if status != models.WebhookDeliveryRetrying || at == nil {
+				t.Fatalf("nextRetry() = %s, %v; want a retry", status, at)
+			}
+			jitter := time.Duration(retryJitter * float64(tt.wantDelay))
+			if delay := at.Sub(now); delay < tt.wantDelay-jitter || delay > tt.wantDelay+jitter {
+				t.Errorf("retry in %v, want %v ± %v", delay, tt.wantDelay, jitter)
+			}
+		})
+	}
+}
+
+func TestWebhookService_RetryDelayPastSchedule(t *testing.T) {
+	s := &WebhookService{config: &config.WebhookConfig{RetrySchedule: []int{60, 300}}}
+
+	if got := s.retryDelay(4); got != 5*time.Minute {
+		t.Errorf("retryDelay(4) = %v, want the last scheduled delay, 5m", got)
+	}
+}
+
+func TestWebhookService_CircuitOpen(t *testing.T) {
+	s := &WebhookService{config: &config.WebhookConfig{CircuitBreakAfter: 3600}}
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+	long := now.Add(-2 * time.Hour)
+
+	if s.circuitOpen(&models.Webhook{}, now) {
+		t.Error("circuit open for a webhook that isn't failing")
+	}
+	if s.circuitOpen(&models.Webhook{FailingSince: &recent}, now) {
+		t.Error("circuit open for a webhook failing for a minute")
+	}
+	if !s.circuitOpen(&models.Webhook{FailingSince: &long}, now) {
+		t.Error("circuit closed for a webhook failing for two hours")
+	}
+}